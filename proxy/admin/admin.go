@@ -0,0 +1,314 @@
+// Package admin serves the proxy's operational HTTP endpoints.
+//
+// The admin server is intended to listen on a loopback or otherwise
+// private address, separate from any client-facing transport.
+//
+// # Endpoints
+//
+//   - GET /healthz: JSON health summary including the degradation level
+//   - GET /metrics: Prometheus text exposition of router metrics
+//   - GET /schema: Names, types, labels, meaning, and stability of the
+//     metrics and audit event and record fields, for generating and
+//     validating dashboards and SIEM parsers
+//   - GET /schedule: Time-window rule and maintenance mode status
+//   - POST /schedule/maintenance: Enable or disable maintenance mode
+//   - PUT /schedule/rules/{name}: Force a rule active, inactive, or auto
+//   - GET /sessions/{id}/pause: Pause state of a session
+//   - POST /sessions/{id}/pause: Pause a session's tool calls
+//   - POST /sessions/{id}/resume: Resume a paused session
+//   - GET /sessions/{id}/sampling: Server sampling requests awaiting
+//     approval
+//   - POST /sessions/{id}/sampling: Approve or deny a held sampling
+//     request
+//   - GET /tofu: Tools awaiting trust-on-first-use approval and approvals
+//   - POST /tofu/approve: Approve a tool fingerprint
+//   - POST /tofu/revoke: Revoke a tool approval
+//   - GET /catalog: Recorded snapshots of server tools, resources, and
+//     prompts listings
+//   - GET /catalog/diff: Entries added, removed, and modified between
+//     two snapshots
+//   - GET /slo: Service level objective burn rates and alert state
+//   - GET /policy: Policy engine rules in effect
+//   - PUT /policy: Replace the policy engine rules; not served without
+//     the admin token
+//   - GET /reload: Configuration reload counters and pending restarts
+//   - POST /reload: Re-read the configuration file, as SIGHUP does
+//   - GET /attestation: Statement of the running binary, features,
+//     policy digests, and sentinel library, signed with the attestation
+//     key; the nonce query parameter is signed along
+//   - POST /sentinel/reload: Load the sentinel library configured in
+//     ffi.library again (or reconnect a remote engine) as SIGUSR2 does,
+//     draining checks in flight
+//   - GET /audit/stream: Server-sent events of audit records as they
+//     are written, filtered by the session, tool, and verdict query
+//     parameters; requires the admin token
+//   - GET /ui/: Configuration UI rendered from the configuration schema
+//   - GET /ui/schema: JSON Schema of the configuration file
+//   - GET /ui/config: Configuration file and running configuration
+//   - PUT /ui/config: Replace the configuration file and reload it
+//   - GET /ui/blocks: Recent blocked decisions across sessions
+//   - GET /ui/stats: Per-session security summaries
+//
+// # Build Tags
+//
+// The nometrics tag leaves out GET /metrics, and noadminui the /ui
+// endpoints and the page they serve; see UIEnabled and MetricsEnabled.
+//
+// # Security Notes
+//
+// The admin port exposes session identifiers and security posture.
+// Never bind it to a public interface. Configuration edits through the
+// UI and the audit stream additionally require the admin token as a
+// bearer token; secrets are never sent back. So do endpoints that change
+// the running proxy, which are refused while no admin token is set and
+// accept only JSON bodies.
+package admin
+
+import (
+	"crypto/subtle"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/harden"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+// Server exposes admin endpoints for a set of router sessions.
+//
+// Server is safe for concurrent use.
+type Server struct {
+	ladder *degrade.Ladder
+
+	mu       sync.RWMutex
+	sessions map[string]*router.Router
+	schedule *schedule.Scheduler
+	tofu     *tofu.Store
+	catalog  *catalog.Store
+	slo      *slo.Monitor
+	policy   *policy.Engine
+	reloader *reload.Reloader
+	attester *attest.Attester
+	engine   sentinel.Reloadable
+	file     ConfigFile
+	privs    *harden.State
+	stream   *audit.Broadcaster
+
+	// editMu serializes configuration file edits
+	editMu sync.Mutex
+}
+
+// HealthResponse is the /healthz response body.
+type HealthResponse struct {
+	// Status is "ok", "degraded", or "failsafe"
+	Status string `json:"status"`
+
+	// DegradationLevel is the current ladder level
+	DegradationLevel string `json:"degradation_level"`
+
+	// Privileges is the proxy process's effective privilege state
+	Privileges *harden.State `json:"privileges,omitempty"`
+
+	// Sessions summarizes each registered session
+	Sessions []router.Health `json:"sessions"`
+}
+
+// New creates an admin server. ladder may be nil.
+func New(ladder *degrade.Ladder) *Server {
+	return &Server{
+		ladder:   ladder,
+		sessions: make(map[string]*router.Router),
+	}
+}
+
+// Register adds a router session to the admin views.
+func (s *Server) Register(r *router.Router) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[r.Health().SessionID] = r
+}
+
+// Unregister removes a router session.
+func (s *Server) Unregister(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// ConfigFile is the configuration file the admin UI edits.
+type ConfigFile struct {
+	// Path is the file (empty shows the running configuration only)
+	Path string
+
+	// Token authorizes edits, sent as "Authorization: Bearer <token>"
+	// (empty disables edits)
+	Token string
+}
+
+// SetConfigFile lets the admin UI show and edit the configuration file.
+// Edits also need a reloader (SetReloader) to apply them.
+//
+// # Security Notes
+//
+// An edit is written to the file, so the path must still resolve after
+// the process is confined with a chroot, and the process must be able
+// to write it.
+func (s *Server) SetConfigFile(f ConfigFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file = f
+}
+
+// SetPrivileges records the process privilege state reported by /healthz.
+func (s *Server) SetPrivileges(st harden.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.privs = &st
+}
+
+// Handler returns the admin HTTP handler.
+//
+// PUT /policy is served only if the admin token is set by then: with
+// it, a client could drop every rule.
+func (s *Server) Handler() http.Handler {
+	s.mu.RLock()
+	token := s.file.Token
+	s.mu.RUnlock()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.registerMetrics(mux)
+	mux.HandleFunc("GET /schema", s.handleSchema)
+	mux.HandleFunc("GET /schedule", s.handleScheduleStatus)
+	mux.HandleFunc("POST /schedule/maintenance", s.handleMaintenance)
+	mux.HandleFunc("PUT /schedule/rules/{name}", s.handleRuleOverride)
+	mux.HandleFunc("GET /sessions/{id}/pause", s.handlePauseStatus)
+	mux.HandleFunc("POST /sessions/{id}/pause", s.handlePause)
+	mux.HandleFunc("POST /sessions/{id}/resume", s.handleResume)
+	mux.HandleFunc("GET /sessions/{id}/sampling", s.handleSamplingStatus)
+	mux.HandleFunc("POST /sessions/{id}/sampling", s.handleSamplingDecision)
+	mux.HandleFunc("GET /tofu", s.handleTOFUStatus)
+	mux.HandleFunc("POST /tofu/approve", s.handleTOFUApprove)
+	mux.HandleFunc("POST /tofu/revoke", s.handleTOFURevoke)
+	mux.HandleFunc("GET /catalog", s.handleCatalog)
+	mux.HandleFunc("GET /catalog/diff", s.handleCatalogDiff)
+	mux.HandleFunc("GET /slo", s.handleSLOStatus)
+	mux.HandleFunc("GET /policy", s.handlePolicy)
+	if token != "" {
+		mux.HandleFunc("PUT /policy", s.handlePolicyReplace)
+	}
+	mux.HandleFunc("GET /reload", s.handleReloadStatus)
+	mux.HandleFunc("POST /reload", s.handleReload)
+	mux.HandleFunc("GET /attestation", s.handleAttestation)
+	mux.HandleFunc("POST /sentinel/reload", s.handleEngineReload)
+	mux.HandleFunc("GET /audit/stream", s.handleAuditStream)
+	s.registerUI(mux)
+	return mux
+}
+
+// authorized reports whether req carries token as its bearer token,
+// answering 401 if it does not.
+func authorized(w http.ResponseWriter, req *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-sentinel"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// authorizedChange reports whether req may change the running proxy:
+// the admin token must be configured (SetConfigFile) and sent as the
+// bearer token, and a body must be application/json, so neither a
+// client without the token nor a cross-site form post gets through.
+// Otherwise it answers 403, 401, or 415.
+func (s *Server) authorizedChange(w http.ResponseWriter, req *http.Request) bool {
+	s.mu.RLock()
+	token := s.file.Token
+	s.mu.RUnlock()
+	if token == "" {
+		http.Error(w, "changes through the admin API need admin_token", http.StatusForbidden)
+		return false
+	}
+	if !authorized(w, req, token) {
+		return false
+	}
+	if req.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			http.Error(w, "request body must be application/json", http.StatusUnsupportedMediaType)
+			return false
+		}
+	}
+	return true
+}
+
+// ListenAndServe serves the admin endpoints on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Serve serves the admin endpoints on an already bound listener, so the
+// port can be bound before privileges are dropped.
+func (s *Server) Serve(ln net.Listener) error {
+	return http.Serve(ln, s.Handler())
+}
+
+// routers returns registered sessions sorted by ID.
+func (s *Server) routers() []*router.Router {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]*router.Router, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, s.sessions[id])
+	}
+	return out
+}
+
+func (s *Server) level() degrade.Level {
+	if s.ladder == nil {
+		return degrade.LevelFull
+	}
+	return s.ladder.Level()
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	level := s.level()
+	resp := HealthResponse{
+		Status:           "ok",
+		DegradationLevel: level.String(),
+		Sessions:         []router.Health{},
+	}
+	s.mu.RLock()
+	resp.Privileges = s.privs
+	s.mu.RUnlock()
+	switch {
+	case level == degrade.LevelFailsafe:
+		resp.Status = "failsafe"
+	case level > degrade.LevelFull:
+		resp.Status = "degraded"
+	}
+	for _, r := range s.routers() {
+		resp.Sessions = append(resp.Sessions, r.Health())
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testToken is the admin token of servers under test.
+const testToken = "tok"
+
+// changeRequest returns a request to path carrying testToken, with body
+// sent as JSON.
+func changeRequest(method, path, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+func TestAuthorizedChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		auth        string
+		contentType string
+		body        string
+		code        int
+	}{
+		{"authorized", testToken, "Bearer " + testToken, "application/json", `{}`, http.StatusOK},
+		{"no body", testToken, "Bearer " + testToken, "", "", http.StatusOK},
+		{"charset", testToken, "Bearer " + testToken, "application/json; charset=utf-8", `{}`, http.StatusOK},
+		{"no token configured", "", "Bearer ", "application/json", `{}`, http.StatusForbidden},
+		{"no bearer token", testToken, "", "application/json", `{}`, http.StatusUnauthorized},
+		{"wrong token", testToken, "Bearer other", "application/json", `{}`, http.StatusUnauthorized},
+		{"cross-site form post", testToken, "Bearer " + testToken, "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"no content type", testToken, "Bearer " + testToken, "", `{}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(nil)
+			s.SetConfigFile(ConfigFile{Token: tt.token})
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			if s.authorizedChange(rec, req) != (tt.code == http.StatusOK) || rec.Code != tt.code {
+				t.Errorf("status %d, expected %d", rec.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+)
+
+// SetAttester serves statements signed by a through /attestation.
+func (s *Server) SetAttester(a *attest.Attester) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attester = a
+}
+
+func (s *Server) currentAttester() *attest.Attester {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.attester
+}
+
+// handleAttestation signs a statement of the running proxy, including
+// the nonce query parameter the verifier chose.
+func (s *Server) handleAttestation(w http.ResponseWriter, r *http.Request) {
+	a := s.currentAttester()
+	if a == nil {
+		http.Error(w, "attestation not configured", http.StatusNotFound)
+		return
+	}
+	signed, err := a.Attest(r.URL.Query().Get("nonce"))
+	if errors.Is(err, attest.ErrInvalidNonce) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, signed)
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+)
+
+func TestAttestationEndpoint(t *testing.T) {
+	s := New(nil)
+	h := s.Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/attestation"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /attestation without an attester = %d", rec.Code)
+	}
+
+	public, private, _ := attest.GenerateKey()
+	key, _ := attest.ParsePrivateKey(private)
+	a, err := attest.New(&attest.Config{Key: key, Version: "1.0.0", Policies: func() map[string]string {
+		return map[string]string{"policy": "abc"}
+	}})
+	if err != nil {
+		t.Fatalf("attest.New failed: %v", err)
+	}
+	s.SetAttester(a)
+
+	tests := []struct {
+		name  string
+		nonce string
+		code  int
+	}{
+		{"with nonce", "n-42", http.StatusOK},
+		{"without nonce", "", http.StatusOK},
+		{"control characters", "%0A", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get("/attestation?nonce=" + tt.nonce)
+			if rec.Code != tt.code {
+				t.Fatalf("GET /attestation = %d %s, expected %d", rec.Code, rec.Body, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var signed attest.Attestation
+			if err := json.Unmarshal(rec.Body.Bytes(), &signed); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			pub, _ := attest.ParsePublicKey(public)
+			stmt, err := attest.Verify(&signed, pub, tt.nonce)
+			if err != nil || stmt.Nonce != tt.nonce || stmt.Policies["policy"] != "abc" {
+				t.Errorf("Verify = %+v, %v", stmt, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// streamKeepalive is how often an idle audit stream sends a comment, so
+// proxies between the admin port and the dashboard keep it open.
+const streamKeepalive = 15 * time.Second
+
+// streamDecisions are the verdict filter values GET /audit/stream
+// accepts.
+var streamDecisions = map[string]bool{
+	string(router.VerdictAllowed): true,
+	string(router.VerdictBlocked): true,
+	string(router.VerdictError):   true,
+	audit.DecisionRelayed:         true,
+	audit.DecisionOrphaned:        true,
+	audit.DecisionDropped:         true,
+}
+
+// SetAuditStream lets GET /audit/stream follow the audit records written
+// to b. The endpoint also needs the admin token (SetConfigFile).
+func (s *Server) SetAuditStream(b *audit.Broadcaster) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stream = b
+}
+
+// handleAuditStream sends audit records as server-sent events until the
+// client disconnects. Each record is an "audit" event whose data is the
+// record's JSON, with its chain sequence number as the event ID when the
+// trail is chained. A "dropped" event reports records lost because the
+// client fell behind.
+func (s *Server) handleAuditStream(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	b, token := s.stream, s.file.Token
+	s.mu.RUnlock()
+	if b == nil || token == "" {
+		http.Error(w, "audit streaming is disabled", http.StatusForbidden)
+		return
+	}
+	if !authorized(w, req, token) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	q := req.URL.Query()
+	filter := audit.Filter{Session: q.Get("session"), Tool: q.Get("tool"), Decision: q.Get("verdict")}
+	if filter.Decision != "" && !streamDecisions[filter.Decision] {
+		http.Error(w, fmt.Sprintf("unknown verdict %q", filter.Decision), http.StatusBadRequest)
+		return
+	}
+
+	sub := b.Subscribe(filter, audit.DefaultStreamBuffer)
+	defer sub.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": audit stream\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	var reported uint64
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case rec, ok := <-sub.Records():
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped > reported {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped-reported)
+				reported = dropped
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				continue
+			}
+			if rec.Seq > 0 {
+				fmt.Fprintf(w, "id: %d\n", rec.Seq)
+			}
+			if _, err := fmt.Fprintf(w, "event: audit\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
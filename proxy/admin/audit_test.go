@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+)
+
+func TestAuditStream(t *testing.T) {
+	stream := audit.NewBroadcaster()
+	s := New(nil)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	get := func(query, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/audit/stream"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		return resp
+	}
+
+	if resp := get("", "tok"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status %d without a stream, expected 403", resp.StatusCode)
+	}
+	s.SetAuditStream(stream)
+	s.SetConfigFile(ConfigFile{Token: "tok"})
+
+	tests := []struct {
+		name  string
+		query string
+		token string
+		code  int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"wrong token", "", "nope", http.StatusUnauthorized},
+		{"unknown verdict", "?verdict=maybe", "tok", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := get(tt.query, tt.token)
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: status %d, expected %d", tt.name, resp.StatusCode, tt.code)
+		}
+	}
+
+	resp := get("?session=s1&verdict=blocked", "tok")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for stream.Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stream.Write(&audit.Record{Session: "s1", Tool: "search", Decision: "allowed"})
+	stream.Write(&audit.Record{Session: "s2", Tool: "shell", Decision: "blocked"})
+	stream.Write(&audit.Record{Seq: 7, Session: "s1", Tool: "shell", Decision: "blocked"})
+
+	lines := bufio.NewScanner(resp.Body)
+	var event []string
+	for lines.Scan() && !strings.HasPrefix(lines.Text(), "data: ") {
+		if !strings.HasPrefix(lines.Text(), ":") && lines.Text() != "" {
+			event = append(event, lines.Text())
+		}
+	}
+	data := lines.Text()
+	if strings.Join(event, ",") != "id: 7,event: audit" || !strings.Contains(data, `"tool":"shell"`) || !strings.Contains(data, `"session":"s1"`) {
+		t.Errorf("first event %v %s, expected the blocked shell call of s1", event, data)
+	}
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+)
+
+// SetCatalog exposes a catalog history through the admin API.
+func (s *Server) SetCatalog(store *catalog.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.catalog = store
+}
+
+func (s *Server) catalogStore(w http.ResponseWriter) *catalog.Store {
+	s.mu.RLock()
+	store := s.catalog
+	s.mu.RUnlock()
+	if store == nil {
+		http.Error(w, "catalog history not enabled", http.StatusNotFound)
+	}
+	return store
+}
+
+// handleCatalog lists snapshots, optionally filtered by the server and
+// kind query parameters.
+func (s *Server) handleCatalog(w http.ResponseWriter, req *http.Request) {
+	store := s.catalogStore(w)
+	if store == nil {
+		return
+	}
+	q := req.URL.Query()
+	snapshots := store.Snapshots(q.Get("server"), q.Get("kind"))
+	if snapshots == nil {
+		snapshots = []catalog.Summary{}
+	}
+	writeJSON(w, snapshots)
+}
+
+// handleCatalogDiff compares the snapshots given by the from and to
+// query parameters, or without them the two latest snapshots of the
+// server and kind parameters.
+func (s *Server) handleCatalogDiff(w http.ResponseWriter, req *http.Request) {
+	store := s.catalogStore(w)
+	if store == nil {
+		return
+	}
+	q := req.URL.Query()
+	var from, to int
+	if q.Get("from") == "" && q.Get("to") == "" {
+		if q.Get("server") == "" || q.Get("kind") == "" {
+			http.Error(w, "give from and to snapshot IDs, or a server and kind", http.StatusBadRequest)
+			return
+		}
+		snapshots := store.Snapshots(q.Get("server"), q.Get("kind"))
+		if len(snapshots) < 2 {
+			http.Error(w, "fewer than two snapshots of "+q.Get("server")+" "+q.Get("kind"), http.StatusNotFound)
+			return
+		}
+		from, to = snapshots[len(snapshots)-2].ID, snapshots[len(snapshots)-1].ID
+	} else {
+		var err1, err2 error
+		from, err1 = strconv.Atoi(q.Get("from"))
+		to, err2 = strconv.Atoi(q.Get("to"))
+		if err1 != nil || err2 != nil {
+			http.Error(w, "from and to must be snapshot IDs", http.StatusBadRequest)
+			return
+		}
+	}
+	diff, err := store.Diff(from, to)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, catalog.ErrNoSnapshot) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, diff)
+}
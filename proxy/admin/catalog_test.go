@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+)
+
+func TestCatalogEndpoints(t *testing.T) {
+	s := New(nil)
+	h := s.Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/catalog"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /catalog without a store returned %d", rec.Code)
+	}
+
+	store, _ := catalog.Open(nil)
+	s.SetCatalog(store)
+	store.Record("fs", catalog.KindTools, []json.RawMessage{json.RawMessage(`{"name":"read"}`)})
+	store.Record("fs", catalog.KindTools, []json.RawMessage{json.RawMessage(`{"name":"read"}`), json.RawMessage(`{"name":"write"}`)})
+	store.Record("web", catalog.KindTools, []json.RawMessage{json.RawMessage(`{"name":"fetch"}`)})
+
+	var snapshots []catalog.Summary
+	json.Unmarshal(get("/catalog?server=fs").Body.Bytes(), &snapshots)
+	if len(snapshots) != 2 || snapshots[1].Entries != 2 {
+		t.Fatalf("fs snapshots = %+v", snapshots)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		added  string
+	}{
+		{"by id", "/catalog/diff?from=1&to=2", http.StatusOK, "write"},
+		{"latest two", "/catalog/diff?server=fs&kind=tools", http.StatusOK, "write"},
+		{"across servers", "/catalog/diff?from=2&to=3", http.StatusOK, "fetch"},
+		{"one snapshot", "/catalog/diff?server=web&kind=tools", http.StatusNotFound, ""},
+		{"unknown id", "/catalog/diff?from=1&to=9", http.StatusNotFound, ""},
+		{"bad id", "/catalog/diff?from=1&to=x", http.StatusBadRequest, ""},
+		{"nothing given", "/catalog/diff", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.path)
+			if rec.Code != tt.status {
+				t.Fatalf("GET %s returned %d: %s", tt.path, rec.Code, rec.Body)
+			}
+			if tt.added == "" {
+				return
+			}
+			var d catalog.Diff
+			json.Unmarshal(rec.Body.Bytes(), &d)
+			if len(d.Added) != 1 || d.Added[0].Name != tt.added {
+				t.Errorf("diff = %+v, expected %s added", d, tt.added)
+			}
+		})
+	}
+}
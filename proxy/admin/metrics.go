@@ -0,0 +1,77 @@
+//go:build !nometrics
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// MetricsEnabled reports whether GET /metrics is compiled in; the
+// nometrics build tag leaves it out.
+const MetricsEnabled = true
+
+// registerMetrics adds the metrics endpoint to mux.
+func (s *Server) registerMetrics(mux *http.ServeMux) {
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	metrics := []router.Metric{{
+		Name:  "mcp_sentinel_proxy_degradation_level",
+		Help:  "Proxy-wide degradation ladder level (0 = full checks).",
+		Type:  "gauge",
+		Value: float64(s.level()),
+	}}
+	metrics = append(metrics, s.sloMetrics()...)
+	for _, r := range s.routers() {
+		metrics = append(metrics, r.Metrics()...)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	WritePrometheus(w, metrics)
+}
+
+// WritePrometheus renders metrics in Prometheus text exposition format.
+// Samples sharing a name are grouped under one HELP/TYPE header.
+func WritePrometheus(w interface{ Write([]byte) (int, error) }, metrics []router.Metric) {
+	byName := make(map[string][]router.Metric)
+	var names []string
+	for _, m := range metrics {
+		if _, ok := byName[m.Name]; !ok {
+			names = append(names, m.Name)
+		}
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+
+	for _, name := range names {
+		group := byName[name]
+		fmt.Fprintf(w, "# HELP %s %s\n", name, group[0].Help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, group[0].Type)
+		for _, m := range group {
+			fmt.Fprintf(w, "%s%s %g\n", name, formatLabels(m.Labels), m.Value)
+		}
+	}
+}
+
+// formatLabels renders a label set as {k="v",...} with sorted keys.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[k])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, v))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,12 @@
+//go:build nometrics
+
+package admin
+
+import "net/http"
+
+// MetricsEnabled reports whether GET /metrics is compiled in; the
+// nometrics build tag leaves it out.
+const MetricsEnabled = false
+
+// registerMetrics adds nothing: the build has no metrics endpoint.
+func (s *Server) registerMetrics(*http.ServeMux) {}
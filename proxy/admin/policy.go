@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+)
+
+// SetPolicy exposes a policy engine through the admin API.
+func (s *Server) SetPolicy(e *policy.Engine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = e
+}
+
+func (s *Server) policyEngine(w http.ResponseWriter) *policy.Engine {
+	s.mu.RLock()
+	e := s.policy
+	s.mu.RUnlock()
+	if e == nil {
+		http.Error(w, "no policy configured", http.StatusNotFound)
+	}
+	return e
+}
+
+func (s *Server) handlePolicy(w http.ResponseWriter, _ *http.Request) {
+	e := s.policyEngine(w)
+	if e == nil {
+		return
+	}
+	writeJSON(w, e.Set())
+}
+
+// handlePolicyReplace swaps in a new rule set for every session. An
+// invalid set is rejected and the current one stays in effect.
+func (s *Server) handlePolicyReplace(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	e := s.policyEngine(w)
+	if e == nil {
+		return
+	}
+	var set policy.Set
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&set); err != nil {
+		http.Error(w, "invalid policy body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := e.Replace(&set); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("audit: policy replaced through admin api: %d rules, default action %q", len(set.Rules), set.DefaultAction)
+	writeJSON(w, e.Set())
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+)
+
+func TestPolicyEndpoints(t *testing.T) {
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	h := s.Handler()
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(method, "/policy", body))
+		return rec
+	}
+
+	if rec := do(http.MethodGet, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /policy without an engine returned %d", rec.Code)
+	}
+
+	engine, _ := policy.New(&policy.Set{Rules: []policy.Rule{{Name: "shell", Tools: []string{"shell"}, Action: policy.ActionBlock}}})
+	s.SetPolicy(engine)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"replace", `{"rules":[{"name":"sudo","tools":["sudo"],"action":"block"}]}`, http.StatusOK},
+		{"invalid rule", `{"rules":[{"name":"x","action":"deny"}]}`, http.StatusBadRequest},
+		{"unknown field", `{"rulez":[]}`, http.StatusBadRequest},
+		{"invalid body", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(http.MethodPut, tt.body); rec.Code != tt.status {
+				t.Errorf("PUT /policy %s returned %d: %s", tt.body, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/policy", strings.NewReader(`{"default_action":"allow"}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /policy without the admin token returned %d", rec.Code)
+	}
+
+	var set policy.Set
+	json.Unmarshal(do(http.MethodGet, "").Body.Bytes(), &set)
+	if len(set.Rules) != 1 || set.Rules[0].Name != "sudo" {
+		t.Errorf("GET /policy = %+v, expected the replaced rules", set)
+	}
+	if !engine.Evaluate(policy.Request{Method: "tools/call", Tool: "sudo"}).Blocked() {
+		t.Error("replaced rules not in effect")
+	}
+}
+
+func TestPolicyReplace_NoToken(t *testing.T) {
+	s := New(nil)
+	engine, _ := policy.New(&policy.Set{DefaultAction: policy.ActionBlock})
+	s.SetPolicy(engine)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, changeRequest(http.MethodPut, "/policy", `{"default_action":"allow"}`))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /policy without an admin token configured returned %d, expected 405", rec.Code)
+	}
+	if engine.Set().DefaultAction != policy.ActionBlock {
+		t.Error("policy replaced")
+	}
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+)
+
+// SetReloader exposes configuration reloads through the admin API.
+func (s *Server) SetReloader(r *reload.Reloader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloader = r
+}
+
+func (s *Server) configReloader(w http.ResponseWriter) *reload.Reloader {
+	s.mu.RLock()
+	r := s.reloader
+	s.mu.RUnlock()
+	if r == nil {
+		http.Error(w, "configuration reload not available", http.StatusNotFound)
+	}
+	return r
+}
+
+func (s *Server) handleReloadStatus(w http.ResponseWriter, _ *http.Request) {
+	r := s.configReloader(w)
+	if r == nil {
+		return
+	}
+	writeJSON(w, r.Status())
+}
+
+// handleReload re-reads the configuration, as SIGHUP does. A rejected
+// configuration leaves the running one in effect.
+func (s *Server) handleReload(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	r := s.configReloader(w)
+	if r == nil {
+		return
+	}
+	result, err := r.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, result)
+}
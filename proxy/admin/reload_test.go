@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+)
+
+func TestReloadEndpoints(t *testing.T) {
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	h := s.Handler()
+	do := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(method, "/reload", ""))
+		return rec
+	}
+
+	if rec := do(http.MethodPost); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /reload without a reloader returned %d", rec.Code)
+	}
+
+	start := config.Default()
+	start.Upstreams = []config.Upstream{{Command: []string{"server"}}}
+	var loadErr error
+	s.SetReloader(reload.New(start, &reload.Config{Load: func() (*config.Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		next := *start
+		next.HighRiskTools = []string{"deploy"}
+		return &next, nil
+	}}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /reload without the admin token returned %d", rec.Code)
+	}
+
+	rec = do(http.MethodPost)
+	var result reload.Result
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || len(result.Applied) != 1 {
+		t.Errorf("POST /reload returned %d: %s", rec.Code, rec.Body)
+	}
+
+	loadErr = config.ErrSyntax
+	if rec := do(http.MethodPost); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /reload with a broken configuration returned %d", rec.Code)
+	}
+
+	var st reload.Status
+	json.Unmarshal(do(http.MethodGet).Body.Bytes(), &st)
+	if st.Reloads != 1 || st.Failures != 1 || st.LastError == "" {
+		t.Errorf("GET /reload = %+v", st)
+	}
+}
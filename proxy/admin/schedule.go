@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+)
+
+// SetSchedule exposes a scheduler through the admin API.
+func (s *Server) SetSchedule(sch *schedule.Scheduler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedule = sch
+}
+
+// overrideRequest is the PUT /schedule/rules/{name} body.
+type overrideRequest struct {
+	Override schedule.Override `json:"override"`
+}
+
+func (s *Server) scheduler(w http.ResponseWriter) *schedule.Scheduler {
+	s.mu.RLock()
+	sch := s.schedule
+	s.mu.RUnlock()
+	if sch == nil {
+		http.Error(w, "no schedule configured", http.StatusNotFound)
+	}
+	return sch
+}
+
+func (s *Server) handleScheduleStatus(w http.ResponseWriter, _ *http.Request) {
+	sch := s.scheduler(w)
+	if sch == nil {
+		return
+	}
+	writeJSON(w, sch.Status())
+}
+
+func (s *Server) handleMaintenance(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	sch := s.scheduler(w)
+	if sch == nil {
+		return
+	}
+	var m schedule.Maintenance
+	if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+		http.Error(w, "invalid maintenance body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sch.SetMaintenance(m)
+	log.Printf("audit: maintenance mode enabled=%t until=%s: %s", m.Enabled, m.Until, m.Reason)
+	writeJSON(w, sch.Status())
+}
+
+func (s *Server) handleRuleOverride(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	sch := s.scheduler(w)
+	if sch == nil {
+		return
+	}
+	var body overrideRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid override body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := req.PathValue("name")
+	if err := sch.SetOverride(name, body.Override); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, schedule.ErrUnknownRule) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("audit: schedule rule %q override set to %s", name, body.Override)
+	writeJSON(w, sch.Status())
+}
+
+// writeJSON writes v as a JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+)
+
+func TestScheduleEndpoints(t *testing.T) {
+	sch, err := schedule.New(&schedule.Config{
+		Rules: []schedule.Rule{{Name: "freeze", Cron: "* * * * *", Scope: schedule.ScopeAll}},
+	})
+	if err != nil {
+		t.Fatalf("schedule.New failed: %v", err)
+	}
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	s.SetSchedule(sch)
+	h := s.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(method, path, body))
+		return rec
+	}
+
+	if ok, _ := sch.Check("read_file"); ok {
+		t.Fatal("freeze rule should deny")
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/schedule/rules/freeze", strings.NewReader(`{"override":"inactive"}`)),
+		httptest.NewRequest(http.MethodPost, "/schedule/maintenance", strings.NewReader(`{"enabled":true}`)),
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without the admin token returned %d", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+	if ok, _ := sch.Check("read_file"); ok {
+		t.Fatal("rule lifted without the admin token")
+	}
+	if rec := do(http.MethodPut, "/schedule/rules/freeze", `{"override":"inactive"}`); rec.Code != http.StatusOK {
+		t.Fatalf("override returned %d: %s", rec.Code, rec.Body)
+	}
+	if ok, _ := sch.Check("read_file"); !ok {
+		t.Error("override should lift the rule")
+	}
+	if rec := do(http.MethodPut, "/schedule/rules/missing", `{"override":"active"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown rule returned %d", rec.Code)
+	}
+
+	do(http.MethodPost, "/schedule/maintenance", `{"enabled":true,"reason":"upgrade"}`)
+	if rec := do(http.MethodGet, "/schedule", ""); !strings.Contains(rec.Body.String(), `"reason":"upgrade"`) {
+		t.Errorf("status missing maintenance: %s", rec.Body)
+	}
+}
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// serverMetricDescs describes the metrics the admin server exports
+// besides each session's.
+var serverMetricDescs = []router.MetricDesc{
+	{Name: "mcp_sentinel_proxy_degradation_level", Type: "gauge", Help: "Proxy-wide degradation ladder level (0 = full checks).",
+		Labels: []string{}, Stability: router.StabilityStable},
+	{Name: "mcp_sentinel_slo_burn_rate", Type: "gauge", Help: "Error budget burn rate over the long alert window.",
+		Labels: []string{"objective", "window"}, Condition: "service level objectives configured", Stability: router.StabilityBeta},
+	{Name: "mcp_sentinel_slo_alert_firing", Type: "gauge", Help: "Whether the burn rate alert is firing (1 = firing).",
+		Labels: []string{"objective", "window"}, Condition: "service level objectives configured", Stability: router.StabilityBeta},
+}
+
+// TelemetrySchema describes the metrics GET /metrics serves, none in a
+// nometrics build, and the audit events and records of this version.
+func TelemetrySchema() router.TelemetrySchema {
+	schema := router.DescribeTelemetry()
+	schema.Metrics = append(append([]router.MetricDesc(nil), serverMetricDescs...), schema.Metrics...)
+	if !MetricsEnabled {
+		schema.Metrics = []router.MetricDesc{}
+	}
+	return schema
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, TelemetrySchema())
+}
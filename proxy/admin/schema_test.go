@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+func TestSchemaEndpoint(t *testing.T) {
+	cfg := router.DefaultConfig()
+	cfg.GasBudget = 100
+	s := New(nil)
+	s.Register(router.NewWithConfig(transport.NewStdioTransport(), sentinel.NewClient(), cfg))
+	m, err := slo.New(&slo.Config{Objectives: []slo.Objective{{Name: "call-latency", Latency: time.Second, Target: 0.95}}})
+	if err != nil {
+		t.Fatalf("slo.New failed: %v", err)
+	}
+	s.SetSLO(m)
+	h := s.Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var schema router.TelemetrySchema
+	rec := get("/schema")
+	if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /schema = %d %s", rec.Code, rec.Body)
+	}
+	if schema.Version != router.TelemetrySchemaVersion || len(schema.Events) == 0 || len(schema.AuditRecord) == 0 {
+		t.Errorf("schema = %+v", schema)
+	}
+	if !MetricsEnabled {
+		if len(schema.Metrics) != 0 {
+			t.Errorf("nometrics build describes %d metrics", len(schema.Metrics))
+		}
+		return
+	}
+
+	// Every metric served is described with its type
+	types := make(map[string]string)
+	for _, m := range schema.Metrics {
+		types[m.Name] = m.Type
+	}
+	served := 0
+	for _, line := range strings.Split(get("/metrics").Body.String(), "\n") {
+		var name, typ string
+		if n, _ := fmt.Sscanf(line, "# TYPE %s %s", &name, &typ); n != 2 {
+			continue
+		}
+		served++
+		if types[name] != typ {
+			t.Errorf("served %s %s, described as %q", typ, name, types[name])
+		}
+	}
+	if served < 30 {
+		t.Errorf("only %d metrics served", served)
+	}
+}
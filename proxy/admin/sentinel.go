@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// SetSentinel exposes reloads of the sentinel engine through the admin
+// API.
+func (s *Server) SetSentinel(e sentinel.Reloadable) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.engine = e
+}
+
+// handleEngineReload swaps in an updated sentinel engine, loaded from
+// the configured library. A failed reload leaves the running engine in
+// service.
+//
+// # Security Notes
+//
+// Loading a library runs its code inside the proxy, so the path is never
+// taken from the request: to switch libraries, change ffi.library in the
+// configuration and reload it first.
+func (s *Server) handleEngineReload(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	s.mu.RLock()
+	e := s.engine
+	s.mu.RUnlock()
+	if e == nil {
+		http.Error(w, "sentinel reload not available", http.StatusNotFound)
+		return
+	}
+	report, err := e.Reload(req.Context(), "")
+	switch {
+	case errors.Is(err, sentinel.ErrNotReloadable):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, sentinel.ErrDrain):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, report)
+	}
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// fakeEngine records the library of each reload and fails with err.
+type fakeEngine struct {
+	library string
+	err     error
+}
+
+func (e *fakeEngine) Reload(_ context.Context, library string) (*sentinel.ReloadReport, error) {
+	e.library = library
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &sentinel.ReloadReport{Library: library, ProtocolVersion: 1}, nil
+}
+
+func TestEngineReloadEndpoint(t *testing.T) {
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	h := s.Handler()
+	post := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post(changeRequest(http.MethodPost, "/sentinel/reload", "")); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /sentinel/reload without an engine = %d", rec.Code)
+	}
+
+	engine := &fakeEngine{}
+	s.SetSentinel(engine)
+	unauthorized := httptest.NewRequest(http.MethodPost, "/sentinel/reload", nil)
+	crossSite := changeRequest(http.MethodPost, "/sentinel/reload", `{"library":"/tmp/evil.so"}`)
+	crossSite.Header.Set("Content-Type", "text/plain")
+	tests := []struct {
+		name     string
+		req      *http.Request
+		err      error
+		code     int
+		reloaded bool
+	}{
+		{"configured library", changeRequest(http.MethodPost, "/sentinel/reload", ""), nil, http.StatusOK, true},
+		{"library in the body ignored", changeRequest(http.MethodPost, "/sentinel/reload", `{"library":"/tmp/evil.so"}`), nil, http.StatusOK, true},
+		{"without the admin token", unauthorized, nil, http.StatusUnauthorized, false},
+		{"cross-site form post", crossSite, nil, http.StatusUnsupportedMediaType, false},
+		{"stub build", changeRequest(http.MethodPost, "/sentinel/reload", ""), sentinel.ErrNotReloadable, http.StatusNotImplemented, true},
+		{"preflight", changeRequest(http.MethodPost, "/sentinel/reload", ""), fmt.Errorf("%w: no common envelope version", sentinel.ErrPreflight), http.StatusBadRequest, true},
+		{"drain", changeRequest(http.MethodPost, "/sentinel/reload", ""), sentinel.ErrDrain, http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.err, engine.library = tt.err, "unset"
+			rec := post(tt.req)
+			if rec.Code != tt.code || (engine.library != "unset") != tt.reloaded || (tt.reloaded && engine.library != "") {
+				t.Fatalf("POST /sentinel/reload = %d %s with library %q, expected %d", rec.Code, rec.Body, engine.library, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var report sentinel.ReloadReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || report.ProtocolVersion != 1 {
+				t.Errorf("report = %+v, %v", report, err)
+			}
+		})
+	}
+}
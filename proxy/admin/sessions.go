@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// pauseRequest is the POST /sessions/{id}/pause body.
+type pauseRequest struct {
+	Mode   router.PauseMode `json:"mode"`
+	Reason string           `json:"reason"`
+}
+
+// samplingDecision is the POST /sessions/{id}/sampling body.
+type samplingDecision struct {
+	// Request is the held request's ID as listed
+	Request string `json:"request"`
+	Approve bool   `json:"approve"`
+}
+
+// session returns the registered router with the path's session ID.
+func (s *Server) session(w http.ResponseWriter, req *http.Request) *router.Router {
+	id := req.PathValue("id")
+	s.mu.RLock()
+	r := s.sessions[id]
+	s.mu.RUnlock()
+	if r == nil {
+		http.Error(w, "unknown session "+id, http.StatusNotFound)
+	}
+	return r
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	r := s.session(w, req)
+	if r == nil {
+		return
+	}
+	body := pauseRequest{Mode: router.PauseReject}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid pause body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Reason == "" {
+		http.Error(w, "pause reason is required", http.StatusBadRequest)
+		return
+	}
+	if err := r.Pause(body.Mode, body.Reason); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, router.ErrInvalidPauseMode) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, r.PauseState())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	r := s.session(w, req)
+	if r == nil {
+		return
+	}
+	if !r.Resume() {
+		http.Error(w, "session is not paused", http.StatusConflict)
+		return
+	}
+	writeJSON(w, r.PauseState())
+}
+
+func (s *Server) handlePauseStatus(w http.ResponseWriter, req *http.Request) {
+	r := s.session(w, req)
+	if r == nil {
+		return
+	}
+	writeJSON(w, r.PauseState())
+}
+
+func (s *Server) handleSamplingStatus(w http.ResponseWriter, req *http.Request) {
+	r := s.session(w, req)
+	if r == nil {
+		return
+	}
+	writeJSON(w, r.PendingSampling())
+}
+
+func (s *Server) handleSamplingDecision(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	r := s.session(w, req)
+	if r == nil {
+		return
+	}
+	var body samplingDecision
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid sampling decision body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := r.DecideSampling(body.Request, body.Approve); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, router.ErrNoSamplingRequest) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, r.PendingSampling())
+}
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+func TestPauseEndpoints(t *testing.T) {
+	cfg := router.DefaultConfig()
+	cfg.SessionID = "s1"
+	r := router.NewWithConfig(transport.NewStdioTransport(), sentinel.NewClient(), cfg)
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	s.Register(r)
+	h := s.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(method, path, body))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		paused bool
+	}{
+		{"unknown session", http.MethodPost, "/sessions/nope/pause", `{"reason":"x"}`, http.StatusNotFound, false},
+		{"reason required", http.MethodPost, "/sessions/s1/pause", `{"mode":"queue"}`, http.StatusBadRequest, false},
+		{"invalid mode", http.MethodPost, "/sessions/s1/pause", `{"mode":"stop","reason":"x"}`, http.StatusBadRequest, false},
+		{"resume while running", http.MethodPost, "/sessions/s1/resume", "", http.StatusConflict, false},
+		{"pause", http.MethodPost, "/sessions/s1/pause", `{"mode":"queue","reason":"incident 42"}`, http.StatusOK, true},
+		{"status", http.MethodGet, "/sessions/s1/pause", "", http.StatusOK, true},
+		{"resume", http.MethodPost, "/sessions/s1/resume", "", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		rec := do(tt.method, tt.path, tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, expected %d: %s", tt.name, rec.Code, tt.code, rec.Body)
+		}
+		if r.PauseState().Paused != tt.paused {
+			t.Errorf("%s: paused = %v, expected %v", tt.name, r.PauseState().Paused, tt.paused)
+		}
+	}
+
+	for _, path := range []string{"/sessions/s1/pause", "/sessions/s1/resume"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"reason":"x"}`)))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without the admin token returned %d", path, rec.Code)
+		}
+	}
+
+	do(http.MethodPost, "/sessions/s1/pause", `{"reason":"default mode"}`)
+	if st := r.PauseState(); st.Mode != router.PauseReject {
+		t.Errorf("default mode = %q, expected reject", st.Mode)
+	}
+	if rec := do(http.MethodGet, "/healthz", ""); !strings.Contains(rec.Body.String(), `"paused":true`) {
+		t.Errorf("healthz missing pause state: %s", rec.Body)
+	}
+}
+
+func TestSamplingEndpoints(t *testing.T) {
+	cfg := router.DefaultConfig()
+	cfg.SessionID = "s1"
+	cfg.Sampling = &router.SamplingPolicy{Action: router.SamplingApprove}
+	r := router.NewWithConfig(transport.NewStdioTransport(), sentinel.NewClient(), cfg)
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	s.Register(r)
+	h := s.Handler()
+
+	unauthorized := httptest.NewRecorder()
+	h.ServeHTTP(unauthorized, httptest.NewRequest(http.MethodPost, "/sessions/s1/sampling", strings.NewReader(`{"request":"7","approve":true}`)))
+	if unauthorized.Code != http.StatusUnauthorized {
+		t.Errorf("POST /sessions/s1/sampling without the admin token returned %d", unauthorized.Code)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"unknown session", http.MethodGet, "/sessions/nope/sampling", "", http.StatusNotFound},
+		{"status", http.MethodGet, "/sessions/s1/sampling", "", http.StatusOK},
+		{"invalid body", http.MethodPost, "/sessions/s1/sampling", `{"approve":"yes"}`, http.StatusBadRequest},
+		{"not held", http.MethodPost, "/sessions/s1/sampling", `{"request":"7","approve":true}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(tt.method, tt.path, tt.body))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, expected %d: %s", tt.name, rec.Code, tt.code, rec.Body)
+		}
+	}
+}
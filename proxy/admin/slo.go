@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+)
+
+// SetSLO exposes a service level objective monitor through /slo and
+// /metrics.
+func (s *Server) SetSLO(m *slo.Monitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slo = m
+}
+
+func (s *Server) sloMonitor() *slo.Monitor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slo
+}
+
+func (s *Server) handleSLOStatus(w http.ResponseWriter, _ *http.Request) {
+	m := s.sloMonitor()
+	if m == nil {
+		http.Error(w, "no service level objectives configured", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, m.Status())
+}
+
+// sloMetrics returns burn rate and alert samples for each objective
+// and window.
+func (s *Server) sloMetrics() []router.Metric {
+	m := s.sloMonitor()
+	if m == nil {
+		return nil
+	}
+	var metrics []router.Metric
+	for _, st := range m.Status() {
+		for _, w := range st.Windows {
+			labels := map[string]string{"objective": st.Objective.Name, "window": w.Window}
+			firing := 0.0
+			if w.Firing {
+				firing = 1
+			}
+			metrics = append(metrics,
+				router.Metric{Name: "mcp_sentinel_slo_burn_rate", Help: "Error budget burn rate over the long alert window.", Type: "gauge", Labels: labels, Value: w.BurnRate},
+				router.Metric{Name: "mcp_sentinel_slo_alert_firing", Help: "Whether the burn rate alert is firing (1 = firing).", Type: "gauge", Labels: labels, Value: firing},
+			)
+		}
+	}
+	return metrics
+}
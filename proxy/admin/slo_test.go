@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+)
+
+func TestSLOEndpoints(t *testing.T) {
+	s := New(nil)
+	h := s.Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/slo"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /slo without a monitor returned %d", rec.Code)
+	}
+
+	m, err := slo.New(&slo.Config{Objectives: []slo.Objective{{Name: "call-latency", Latency: time.Second, Target: 0.95}}})
+	if err != nil {
+		t.Fatalf("slo.New failed: %v", err)
+	}
+	s.SetSLO(m)
+	m.Observe(slo.Observation{Method: "tools/call", AddedLatency: 2 * time.Second})
+
+	var status []slo.Status
+	json.Unmarshal(get("/slo").Body.Bytes(), &status)
+	if len(status) != 1 || status[0].Objective.Name != "call-latency" || status[0].Windows[0].BurnRate != 20 {
+		t.Errorf("GET /slo = %+v", status)
+	}
+
+	if !MetricsEnabled {
+		return
+	}
+	body := get("/metrics").Body.String()
+	if !strings.Contains(body, `mcp_sentinel_slo_burn_rate{objective="call-latency",window="1h0m0s/5m0s"} 20`) {
+		t.Errorf("metrics lack the burn rate:\n%s", body)
+	}
+}
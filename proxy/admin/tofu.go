@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+// SetTOFU exposes a trust-on-first-use store through the admin API.
+func (s *Server) SetTOFU(store *tofu.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tofu = store
+}
+
+// TOFUStatus is the GET /tofu response body.
+type TOFUStatus struct {
+	Pending   []tofu.Pending  `json:"pending"`
+	Approvals []tofu.Approval `json:"approvals"`
+}
+
+// tofuRequest is the POST /tofu/approve and /tofu/revoke body. An
+// empty fingerprint approves the pending one.
+type tofuRequest struct {
+	Server      string `json:"server"`
+	Tool        string `json:"tool"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (s *Server) tofuStore(w http.ResponseWriter) *tofu.Store {
+	s.mu.RLock()
+	store := s.tofu
+	s.mu.RUnlock()
+	if store == nil {
+		http.Error(w, "trust-on-first-use not enabled", http.StatusNotFound)
+	}
+	return store
+}
+
+func (s *Server) handleTOFUStatus(w http.ResponseWriter, _ *http.Request) {
+	store := s.tofuStore(w)
+	if store == nil {
+		return
+	}
+	writeJSON(w, TOFUStatus{Pending: store.Pending(), Approvals: store.Approvals()})
+}
+
+func (s *Server) handleTOFUApprove(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	store := s.tofuStore(w)
+	if store == nil {
+		return
+	}
+	var body tofuRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid approval body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := store.Approve(body.Server, body.Tool, body.Fingerprint, "admin api"); err != nil {
+		switch {
+		case errors.Is(err, tofu.ErrNotPending):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, tofu.ErrInvalidIdentity):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, tofu.ErrExplicitChange):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		// Not persisted, but in effect for this process
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TOFUStatus{Pending: store.Pending(), Approvals: store.Approvals()})
+}
+
+func (s *Server) handleTOFURevoke(w http.ResponseWriter, req *http.Request) {
+	if !s.authorizedChange(w, req) {
+		return
+	}
+	store := s.tofuStore(w)
+	if store == nil {
+		return
+	}
+	var body tofuRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid revoke body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := store.Revoke(body.Server, body.Tool); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, tofu.ErrUnknownTool) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, TOFUStatus{Pending: store.Pending(), Approvals: store.Approvals()})
+}
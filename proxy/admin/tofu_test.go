@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+func TestTOFUEndpoints(t *testing.T) {
+	s := New(nil)
+	s.SetConfigFile(ConfigFile{Token: testToken})
+	h := s.Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, changeRequest(method, path, body))
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/tofu", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /tofu without a store returned %d", rec.Code)
+	}
+
+	store, _ := tofu.Open(nil)
+	s.SetTOFU(store)
+	store.Check("files", "write", "sha256:w", "Write a file")
+
+	var status TOFUStatus
+	json.Unmarshal(do(http.MethodGet, "/tofu", "").Body.Bytes(), &status)
+	if len(status.Pending) != 1 || status.Pending[0].Fingerprint != "sha256:w" {
+		t.Fatalf("pending = %+v", status.Pending)
+	}
+
+	for _, path := range []string{"/tofu/approve", "/tofu/revoke"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"server":"files","tool":"write"}`)))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without the admin token returned %d", path, rec.Code)
+		}
+	}
+	if got := store.Check("files", "write", "sha256:w", ""); got == tofu.StatusApproved {
+		t.Fatal("approved without the admin token")
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"approve pending", "/tofu/approve", `{"server":"files","tool":"write"}`, http.StatusOK},
+		{"nothing pending", "/tofu/approve", `{"server":"files","tool":"delete"}`, http.StatusNotFound},
+		{"explicit fingerprint", "/tofu/approve", `{"server":"files","tool":"delete","fingerprint":"sha256:d"}`, http.StatusOK},
+		{"missing identity", "/tofu/approve", `{"tool":"x"}`, http.StatusBadRequest},
+		{"invalid body", "/tofu/approve", `{`, http.StatusBadRequest},
+		{"revoke", "/tofu/revoke", `{"server":"files","tool":"delete"}`, http.StatusOK},
+		{"revoke unknown", "/tofu/revoke", `{"server":"files","tool":"delete"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(http.MethodPost, tt.path, tt.body); rec.Code != tt.status {
+				t.Errorf("POST %s %s returned %d: %s", tt.path, tt.body, rec.Code, rec.Body)
+			}
+		})
+	}
+	if got := store.Check("files", "write", "sha256:w", ""); got != tofu.StatusApproved {
+		t.Errorf("write = %s after approval", got)
+	}
+}
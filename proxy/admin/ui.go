@@ -0,0 +1,257 @@
+//go:build !noadminui
+
+package admin
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+//go:embed ui/index.html
+var uiFiles embed.FS
+
+// maxConfigBody bounds a PUT /ui/config body.
+const maxConfigBody = 1 << 20
+
+// configView is the GET /ui/config response body.
+type configView struct {
+	// Path and Format are the file's, when there is one
+	Path   string        `json:"path,omitempty"`
+	Format config.Format `json:"format,omitempty"`
+
+	// Editable reports whether PUT /ui/config is enabled
+	Editable bool `json:"editable"`
+
+	// Config is the file's contents over the defaults, and Running the
+	// configuration in effect, with environment overrides applied;
+	// both have their secrets redacted
+	Config  json.RawMessage `json:"config,omitempty"`
+	Running json.RawMessage `json:"running,omitempty"`
+}
+
+// UIEnabled reports whether the admin UI is compiled in; the noadminui
+// build tag leaves it out.
+const UIEnabled = true
+
+// registerUI adds the UI endpoints to mux.
+func (s *Server) registerUI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ui", s.handleUIRedirect)
+	mux.HandleFunc("GET /ui/{$}", s.handleUI)
+	mux.HandleFunc("GET /ui/schema", s.handleUISchema)
+	mux.HandleFunc("GET /ui/config", s.handleUIConfig)
+	mux.HandleFunc("PUT /ui/config", s.handleUIConfigEdit)
+	mux.HandleFunc("GET /ui/blocks", s.handleUIBlocks)
+	mux.HandleFunc("GET /ui/stats", s.handleUIStats)
+}
+
+func (s *Server) handleUIRedirect(w http.ResponseWriter, req *http.Request) {
+	http.Redirect(w, req, "/ui/", http.StatusMovedPermanently)
+}
+
+func (s *Server) handleUI(w http.ResponseWriter, _ *http.Request) {
+	page, err := uiFiles.ReadFile("ui/index.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Write(page)
+}
+
+func (s *Server) handleUISchema(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(config.Schema())
+}
+
+func (s *Server) handleUIConfig(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	f, r := s.file, s.reloader
+	s.mu.RUnlock()
+
+	view := configView{Editable: f.Path != "" && f.Token != "" && r != nil}
+	if f.Path != "" {
+		cfg, format, _, err := readConfigFile(f.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		view.Path, view.Format = f.Path, format
+		if view.Config, err = encodeView(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if r != nil {
+		var err error
+		if view.Running, err = encodeView(r.Running()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, view)
+}
+
+// handleUIConfigEdit replaces the configuration file with the body, a
+// JSON configuration, and reloads it as SIGHUP does. Secrets left at
+// config.Redacted keep their values. The file is written in its own
+// format; a configuration the reload rejects is rolled back and the
+// running one stays in effect.
+func (s *Server) handleUIConfigEdit(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	f, r := s.file, s.reloader
+	s.mu.RUnlock()
+	if f.Path == "" || f.Token == "" || r == nil {
+		http.Error(w, "configuration edits are disabled", http.StatusForbidden)
+		return
+	}
+	if !authorized(w, req, f.Token) {
+		return
+	}
+
+	body, err := readBody(w, req)
+	if err != nil {
+		http.Error(w, "invalid configuration body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	next, err := config.Parse(body, config.FormatJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Edits are serialized so a rollback restores the file this edit
+	// replaced
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+	prev, format, old, err := readConfigFile(f.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	next.Unredact(prev)
+	data, err := next.Encode(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := writeFileAtomic(f.Path, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result, err := r.Reload()
+	if err != nil {
+		if restore := writeFileAtomic(f.Path, old); restore != nil {
+			log.Printf("admin: restoring %s after a rejected edit: %v", f.Path, restore)
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("audit: configuration file %s edited through admin ui", f.Path)
+	writeJSON(w, result)
+}
+
+// handleUIBlocks returns the most recent blocked decisions of every
+// session, newest first (?limit=, default 50).
+func (s *Server) handleUIBlocks(w http.ResponseWriter, req *http.Request) {
+	limit := 50
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	blocks := []router.Decision{}
+	for _, r := range s.routers() {
+		for _, d := range r.RecentDecisions(router.DefaultDecisionLogSize) {
+			if d.Verdict == router.VerdictBlocked {
+				blocks = append(blocks, d)
+			}
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Time.After(blocks[j].Time) })
+	if len(blocks) > limit {
+		blocks = blocks[:limit]
+	}
+	writeJSON(w, blocks)
+}
+
+// handleUIStats returns every session's summary.
+func (s *Server) handleUIStats(w http.ResponseWriter, _ *http.Request) {
+	stats := []router.Summary{}
+	for _, r := range s.routers() {
+		stats = append(stats, r.Summary())
+	}
+	writeJSON(w, stats)
+}
+
+// readConfigFile parses the file at path without environment overrides
+// and returns it with its format and raw contents.
+func readConfigFile(path string) (*config.Config, config.Format, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("admin: %w", err)
+	}
+	format := config.FormatOf(path, data)
+	cfg, err := config.Parse(data, format)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, format, data, nil
+}
+
+// encodeView encodes cfg as JSON with its secrets redacted.
+func encodeView(cfg *config.Config) (json.RawMessage, error) {
+	return cfg.Redact().Encode(config.FormatJSON)
+}
+
+// readBody reads a request body of at most maxConfigBody bytes.
+func readBody(w http.ResponseWriter, req *http.Request) ([]byte, error) {
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxConfigBody)).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in
+// the same directory, keeping the file's permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("admin: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("admin: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build noadminui
+
+package admin
+
+import "net/http"
+
+// UIEnabled reports whether the admin UI is compiled in; the noadminui
+// build tag leaves it out.
+const UIEnabled = false
+
+// registerUI adds nothing: the build has no admin UI.
+func (s *Server) registerUI(*http.ServeMux) {}
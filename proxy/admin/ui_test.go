@@ -0,0 +1,131 @@
+//go:build !noadminui
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+)
+
+const uiTestConfig = `upstreams:
+  - command: [server]
+high_risk_tools: [deploy]
+chain:
+  proxy_id: edge-1
+  key: chain-secret
+`
+
+func TestUI_Pages(t *testing.T) {
+	h := New(nil).Handler()
+	tests := []struct {
+		path  string
+		code  int
+		typ   string
+		token string
+	}{
+		{"/ui", http.StatusMovedPermanently, "", ""},
+		{"/ui/", http.StatusOK, "text/html; charset=utf-8", "<title>mcp-sentinel</title>"},
+		{"/ui/schema", http.StatusOK, "application/json", `"additionalProperties":false`},
+		{"/ui/stats", http.StatusOK, "application/json", "[]"},
+		{"/ui/blocks", http.StatusOK, "application/json", "[]"},
+		{"/ui/blocks?limit=0", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("GET %s returned %d", tt.path, rec.Code)
+		}
+		if tt.typ != "" && rec.Header().Get("Content-Type") != tt.typ {
+			t.Errorf("GET %s Content-Type = %q", tt.path, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Body.String(), tt.token) {
+			t.Errorf("GET %s body lacks %q", tt.path, tt.token)
+		}
+	}
+}
+
+func TestUI_ConfigEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.yaml")
+	if err := os.WriteFile(path, []byte(uiTestConfig), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	start, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(nil)
+	s.SetReloader(reload.New(start, &reload.Config{Load: func() (*config.Config, error) { return config.Load(path) }}))
+	h := s.Handler()
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/ui/config", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a token the configuration is shown but not editable
+	s.SetConfigFile(ConfigFile{Path: path})
+	var view configView
+	rec := do(http.MethodGet, "", "")
+	json.Unmarshal(rec.Body.Bytes(), &view)
+	if rec.Code != http.StatusOK || view.Editable || view.Format != config.FormatYAML {
+		t.Fatalf("GET /ui/config = %d %+v", rec.Code, view)
+	}
+	if strings.Contains(rec.Body.String(), "chain-secret") || !strings.Contains(string(view.Config), config.Redacted) {
+		t.Errorf("secret not redacted: %s", rec.Body)
+	}
+	if rec := do(http.MethodPut, "tok", `{}`); rec.Code != http.StatusForbidden {
+		t.Errorf("PUT without an admin token configured returned %d", rec.Code)
+	}
+
+	s.SetConfigFile(ConfigFile{Path: path, Token: "tok"})
+	edit := `{"upstreams":[{"command":["server"]}],"high_risk_tools":["deploy","rm"],
+		"chain":{"proxy_id":"edge-1","key":"[redacted]"}}`
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		code  int
+	}{
+		{"no token", "", edit, http.StatusUnauthorized},
+		{"wrong token", "nope", edit, http.StatusUnauthorized},
+		{"not json", "tok", `high_risk_tools: []`, http.StatusBadRequest},
+		{"unknown field", "tok", `{"gass":{}}`, http.StatusBadRequest},
+		{"rejected by reload", "tok", `{"upstreams":[{"command":["server"]}],"gas":{"budget":0}}`, http.StatusBadRequest},
+		{"applied", "tok", edit, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := do(http.MethodPut, tt.token, tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: PUT returned %d: %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+
+	// The file was rewritten once, in YAML, with the secret kept
+	data, _ := os.ReadFile(path)
+	got, err := config.Parse(data, config.FormatYAML)
+	if err != nil {
+		t.Fatalf("edited file does not parse: %v\n%s", err, data)
+	}
+	if len(got.HighRiskTools) != 2 || got.Chain.Key != "chain-secret" {
+		t.Errorf("edited file = %+v", got)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o640 {
+		t.Errorf("file mode = %v, want 0640", info.Mode().Perm())
+	}
+	if running := s.reloader.Running(); len(running.HighRiskTools) != 2 {
+		t.Errorf("running high-risk tools = %v", running.HighRiskTools)
+	}
+}
@@ -0,0 +1,350 @@
+// Package affinity keeps each session of a horizontally scaled proxy on
+// one replica by consistent hashing, so per-session security state
+// (gas, call history, taint, approvals) stays local and correct without
+// a shared store such as Redis.
+//
+// Every replica is configured with the same replica list. A session ID
+// hashes onto a ring of virtual nodes to the replica that owns it, and
+// each replica mints the IDs of the sessions it accepts so that they
+// hash to itself: whichever replica the load balancer sends an
+// initialize to owns that session from then on.
+//
+// # Hand-off Protocol
+//
+// A request for a session that reaches another replica, because the
+// load balancer does not route by session, is either
+//
+//   - redirected: answered with 307 Temporary Redirect to the same path
+//     on the owner, which preserves the method and body; or
+//   - handed off: proxied to the owner with the Mcp-Sentinel-Handoff
+//     header naming the forwarding replica, streaming the response
+//     back.
+//
+// A replica never hands a handed-off request on: if it does not own the
+// session either, as while replicas disagree about membership, it
+// answers 421 Misdirected Request and the client retries. Responses
+// name the replica that served them in Mcp-Sentinel-Replica.
+//
+// # Membership Changes
+//
+// Adding or removing a replica moves about 1/N of the ring. Sessions
+// already live on a replica are kept there when Config.Local reports
+// them, so only sessions created after the change follow the new ring;
+// sessions of a removed replica end with it.
+//
+// # Security Notes
+//
+// Replicas trust each other's hand-offs. Replica URLs should be on a
+// private network, and session IDs remain identifiers, not credentials.
+package affinity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// Hand-off modes.
+const (
+	// HandoffRedirect redirects misrouted requests to the owner
+	HandoffRedirect = "redirect"
+	// HandoffProxy proxies misrouted requests to the owner
+	HandoffProxy = "proxy"
+)
+
+// Headers of the hand-off protocol.
+const (
+	// HeaderHandoff names the replica that handed a request off
+	HeaderHandoff = "Mcp-Sentinel-Handoff"
+	// HeaderReplica names the replica that served a request
+	HeaderReplica = "Mcp-Sentinel-Replica"
+)
+
+// DefaultVirtualNodes is the number of ring points per replica.
+const DefaultVirtualNodes = 128
+
+// Configuration errors.
+var (
+	ErrNoReplicas     = errors.New("affinity: no replicas")
+	ErrInvalidReplica = errors.New("affinity: invalid replica")
+	ErrUnknownSelf    = errors.New("affinity: this replica is not in the replica list")
+	ErrInvalidHandoff = errors.New("affinity: invalid hand-off mode")
+)
+
+// Replica is one proxy replica.
+type Replica struct {
+	// ID names the replica; it must be the same on every replica
+	ID string
+
+	// URL is the replica's base URL, reachable from clients for
+	// redirects and from other replicas for hand-offs
+	URL string
+}
+
+// Config configures session affinity.
+type Config struct {
+	// Self is this replica's ID
+	Self string
+
+	// Replicas lists every replica, this one included
+	Replicas []Replica
+
+	// Handoff is HandoffRedirect or HandoffProxy (empty redirects)
+	Handoff string
+
+	// VirtualNodes is the number of ring points per replica (zero uses
+	// DefaultVirtualNodes)
+	VirtualNodes int
+
+	// Local reports whether a session is live on this replica; such
+	// sessions are served here whatever the ring says (nil trusts the
+	// ring alone)
+	Local func(session string) bool
+}
+
+// Stats counts routing outcomes.
+type Stats struct {
+	// Local counts requests served by this replica
+	Local uint64 `json:"local"`
+	// Redirected counts requests redirected to their owner
+	Redirected uint64 `json:"redirected"`
+	// HandedOff counts requests proxied to their owner
+	HandedOff uint64 `json:"handed_off"`
+	// Misdirected counts handed-off requests this replica did not own
+	Misdirected uint64 `json:"misdirected"`
+}
+
+// Affinity routes sessions to their replica.
+//
+// # Thread Safety
+//
+// Affinity is safe for concurrent use; SetReplicas may be called while
+// requests are served.
+type Affinity struct {
+	self    string
+	handoff string
+	vnodes  int
+	local   func(string) bool
+
+	mu      sync.RWMutex
+	ring    *ring
+	proxies map[string]*httputil.ReverseProxy
+
+	served, redirected, handedOff, misdirected atomic.Uint64
+}
+
+// ring is a consistent hash ring.
+type ring struct {
+	points   []uint64
+	owners   []string
+	replicas map[string]*url.URL
+}
+
+// New creates session affinity for this replica.
+//
+// # Returns
+//   - The Affinity
+//   - ErrNoReplicas, ErrInvalidReplica, ErrUnknownSelf, or
+//     ErrInvalidHandoff for an unusable configuration
+func New(cfg *Config) (*Affinity, error) {
+	a := &Affinity{self: cfg.Self, handoff: cfg.Handoff, vnodes: cfg.VirtualNodes, local: cfg.Local}
+	if a.handoff == "" {
+		a.handoff = HandoffRedirect
+	}
+	if a.handoff != HandoffRedirect && a.handoff != HandoffProxy {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHandoff, cfg.Handoff)
+	}
+	if a.vnodes <= 0 {
+		a.vnodes = DefaultVirtualNodes
+	}
+	if err := a.SetReplicas(cfg.Replicas); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetReplicas replaces the replica list, moving the sessions the ring
+// assigns differently; live local sessions stay (see Config.Local).
+//
+// # Returns
+//   - ErrNoReplicas, ErrInvalidReplica, or ErrUnknownSelf, leaving the
+//     replica list unchanged
+func (a *Affinity) SetReplicas(replicas []Replica) error {
+	r, err := newRing(replicas, a.vnodes)
+	if err != nil {
+		return err
+	}
+	if r.replicas[a.self] == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownSelf, a.self)
+	}
+	proxies := make(map[string]*httputil.ReverseProxy, len(r.replicas))
+	if a.handoff == HandoffProxy {
+		for id, u := range r.replicas {
+			if id != a.self {
+				proxies[id] = a.newProxy(id, u)
+			}
+		}
+	}
+	a.mu.Lock()
+	a.ring, a.proxies = r, proxies
+	a.mu.Unlock()
+	return nil
+}
+
+// newRing places vnodes points per replica on a ring.
+func newRing(replicas []Replica, vnodes int) (*ring, error) {
+	if len(replicas) == 0 {
+		return nil, ErrNoReplicas
+	}
+	r := &ring{replicas: make(map[string]*url.URL, len(replicas))}
+	type point struct {
+		hash  uint64
+		owner string
+	}
+	points := make([]point, 0, len(replicas)*vnodes)
+	for _, rep := range replicas {
+		if rep.ID == "" || r.replicas[rep.ID] != nil {
+			return nil, fmt.Errorf("%w: missing or duplicate ID %q", ErrInvalidReplica, rep.ID)
+		}
+		u, err := url.Parse(rep.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("%w: replica %s: URL must be http(s)://host[:port], got %q", ErrInvalidReplica, rep.ID, rep.URL)
+		}
+		u.Path = ""
+		r.replicas[rep.ID] = u
+		for i := 0; i < vnodes; i++ {
+			points = append(points, point{hash: hashKey(rep.ID + "#" + strconv.Itoa(i)), owner: rep.ID})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].owner < points[j].owner
+	})
+	for _, p := range points {
+		r.points = append(r.points, p.hash)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r, nil
+}
+
+// hashKey places a key on the ring.
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// owner returns the replica owning key: the first point at or after
+// its hash, wrapping around.
+func (r *ring) owner(key string) string {
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+// Owner returns the ID of the replica owning a session.
+func (a *Affinity) Owner(session string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.ring.owner(session)
+}
+
+// SessionID returns a new random 128-bit session ID owned by this
+// replica, for transport.StreamableServerConfig.NewSessionID.
+func (a *Affinity) SessionID() string {
+	for {
+		var b [16]byte
+		rand.Read(b[:])
+		id := hex.EncodeToString(b[:])
+		if a.Owner(id) == a.self {
+			return id
+		}
+	}
+}
+
+// Handler routes requests to next when this replica owns their
+// session, and to the owner otherwise. Requests without a session,
+// such as initialize, are served here.
+func (a *Affinity) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Header.Get(transport.HeaderSessionID)
+		if session == "" || (a.local != nil && a.local(session)) {
+			a.serve(w, r, next)
+			return
+		}
+		a.mu.RLock()
+		owner := a.ring.owner(session)
+		target, proxy := a.ring.replicas[owner], a.proxies[owner]
+		a.mu.RUnlock()
+
+		switch {
+		case owner == a.self:
+			a.serve(w, r, next)
+		case r.Header.Get(HeaderHandoff) != "":
+			// Never hand a request on: replicas disagree on the ring
+			a.misdirected.Add(1)
+			w.Header().Set(HeaderReplica, a.self)
+			http.Error(w, fmt.Sprintf("session belongs to replica %s", owner), http.StatusMisdirectedRequest)
+		case proxy != nil:
+			a.handedOff.Add(1)
+			r = r.Clone(r.Context())
+			r.Header.Set(HeaderHandoff, a.self)
+			proxy.ServeHTTP(w, r)
+		default:
+			a.redirected.Add(1)
+			location := *target
+			location.Path, location.RawQuery = r.URL.Path, r.URL.RawQuery
+			w.Header().Set(HeaderReplica, a.self)
+			http.Redirect(w, r, location.String(), http.StatusTemporaryRedirect)
+		}
+	})
+}
+
+// serve serves a request on this replica.
+func (a *Affinity) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	a.served.Add(1)
+	w.Header().Set(HeaderReplica, a.self)
+	next.ServeHTTP(w, r)
+}
+
+// newProxy creates the hand-off proxy to a replica. Responses are
+// flushed as they arrive so event streams are not buffered.
+func (a *Affinity) newProxy(id string, target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("replica %s unavailable: %v", id, err), http.StatusBadGateway)
+		},
+	}
+}
+
+// Stats returns the routing counters.
+func (a *Affinity) Stats() Stats {
+	return Stats{
+		Local:       a.served.Load(),
+		Redirected:  a.redirected.Load(),
+		HandedOff:   a.handedOff.Load(),
+		Misdirected: a.misdirected.Load(),
+	}
+}
@@ -0,0 +1,219 @@
+package affinity
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+func replicas(ids ...string) []Replica {
+	var out []Replica
+	for _, id := range ids {
+		out = append(out, Replica{ID: id, URL: "http://" + id + ":8080"})
+	}
+	return out
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		err  error
+	}{
+		{"no replicas", &Config{Self: "a"}, ErrNoReplicas},
+		{"unknown self", &Config{Self: "z", Replicas: replicas("a", "b")}, ErrUnknownSelf},
+		{"duplicate", &Config{Self: "a", Replicas: replicas("a", "a")}, ErrInvalidReplica},
+		{"missing ID", &Config{Self: "a", Replicas: []Replica{{ID: "a", URL: "http://a"}, {URL: "http://b"}}}, ErrInvalidReplica},
+		{"URL path", &Config{Self: "a", Replicas: []Replica{{ID: "a", URL: "http://a/mcp"}}}, ErrInvalidReplica},
+		{"URL scheme", &Config{Self: "a", Replicas: []Replica{{ID: "a", URL: "ws://a"}}}, ErrInvalidReplica},
+		{"hand-off", &Config{Self: "a", Replicas: replicas("a"), Handoff: "forward"}, ErrInvalidHandoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRing_Balance(t *testing.T) {
+	a, err := New(&Config{Self: "a", Replicas: replicas("a", "b", "c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const keys = 6000
+	before := make(map[string]string, keys)
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		before[key] = a.Owner(key)
+		counts[before[key]]++
+	}
+	for id, n := range counts {
+		if n < keys/5 || n > keys/2 {
+			t.Errorf("replica %s owns %d of %d sessions", id, n, keys)
+		}
+	}
+
+	// A fourth replica takes about a quarter, all from the others
+	if err := a.SetReplicas(replicas("a", "b", "c", "d")); err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for key, owner := range before {
+		if now := a.Owner(key); now != owner {
+			moved++
+			if now != "d" {
+				t.Fatalf("session %s moved from %s to %s", key, owner, now)
+			}
+		}
+	}
+	if moved < keys/8 || moved > keys*3/8 {
+		t.Errorf("%d of %d sessions moved", moved, keys)
+	}
+}
+
+func TestSessionID(t *testing.T) {
+	a, _ := New(&Config{Self: "b", Replicas: replicas("a", "b", "c")})
+	for i := 0; i < 20; i++ {
+		if id := a.SessionID(); a.Owner(id) != "b" || len(id) != 32 {
+			t.Fatalf("session %q is owned by %s", id, a.Owner(id))
+		}
+	}
+}
+
+// sessionOf returns a session ID owned by replica id.
+func sessionOf(t *testing.T, a *Affinity, id string) string {
+	t.Helper()
+	for i := 0; i < 10000; i++ {
+		if key := fmt.Sprintf("s%d", i); a.Owner(key) == id {
+			return key
+		}
+	}
+	t.Fatalf("no session for %s", id)
+	return ""
+}
+
+func TestHandler_Redirect(t *testing.T) {
+	live := map[string]bool{}
+	a, _ := New(&Config{Self: "a", Replicas: replicas("a", "b"), Local: func(s string) bool { return live[s] }})
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "served") }))
+	ours, theirs := sessionOf(t, a, "a"), sessionOf(t, a, "b")
+	live["moved"] = true
+
+	tests := []struct {
+		name     string
+		session  string
+		handoff  string
+		status   int
+		location string
+	}{
+		{"no session", "", "", http.StatusOK, ""},
+		{"owned", ours, "", http.StatusOK, ""},
+		{"live here", "moved", "", http.StatusOK, ""},
+		{"other replica", theirs, "", http.StatusTemporaryRedirect, "http://b:8080/mcp?x=1"},
+		{"handed off", theirs, "c", http.StatusMisdirectedRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp?x=1", strings.NewReader("{}"))
+			if tt.session != "" {
+				req.Header.Set(transport.HeaderSessionID, tt.session)
+			}
+			if tt.handoff != "" {
+				req.Header.Set(HeaderHandoff, tt.handoff)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Header().Get("Location") != tt.location {
+				t.Errorf("status %d, location %q; expected %d, %q", rec.Code, rec.Header().Get("Location"), tt.status, tt.location)
+			}
+			if rec.Header().Get(HeaderReplica) != "a" {
+				t.Errorf("%s = %q", HeaderReplica, rec.Header().Get(HeaderReplica))
+			}
+		})
+	}
+	if s := a.Stats(); s.Local != 3 || s.Redirected != 1 || s.Misdirected != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestHandler_Proxy(t *testing.T) {
+	// Two replicas, each serving sessions it owns with its own name
+	handlers := map[string]http.Handler{}
+	servers := map[string]*httptest.Server{}
+	for _, id := range []string{"a", "b"} {
+		id := id
+		servers[id] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handlers[id].ServeHTTP(w, r) }))
+		defer servers[id].Close()
+	}
+	members := []Replica{{ID: "a", URL: servers["a"].URL}, {ID: "b", URL: servers["b"].URL}}
+	var affinities = map[string]*Affinity{}
+	for _, id := range []string{"a", "b"} {
+		id := id
+		a, err := New(&Config{Self: id, Replicas: members, Handoff: HandoffProxy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		affinities[id] = a
+		handlers[id] = a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s:%s:%s", id, r.Header.Get(HeaderHandoff), body)
+		}))
+	}
+
+	session := sessionOf(t, affinities["a"], "b")
+	req, _ := http.NewRequest(http.MethodPost, servers["a"].URL+"/mcp", strings.NewReader("ping"))
+	req.Header.Set(transport.HeaderSessionID, session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "b:a:ping" || resp.Header.Get(HeaderReplica) != "b" {
+		t.Errorf("response %q from %q, expected b to serve a's hand-off", body, resp.Header.Get(HeaderReplica))
+	}
+	if affinities["a"].Stats().HandedOff != 1 || affinities["b"].Stats().Local != 1 {
+		t.Errorf("stats a=%+v b=%+v", affinities["a"].Stats(), affinities["b"].Stats())
+	}
+}
+
+func TestStreamableServer_SessionID(t *testing.T) {
+	a, _ := New(&Config{Self: "c", Replicas: replicas("a", "b", "c")})
+	s := transport.NewStreamableHTTPServerWithConfig(transport.StreamableServerConfig{NewSessionID: a.SessionID})
+	defer s.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Content-Type", "application/json")
+	go a.Handler(s).ServeHTTP(httptest.NewRecorder(), req)
+
+	// The session is assigned once the message is received
+	select {
+	case <-receive(s):
+	case <-time.After(2 * time.Second):
+		t.Fatal("initialize not received")
+	}
+	if owner := a.Owner(s.SessionID()); owner != "c" {
+		t.Errorf("session %q is owned by %s", s.SessionID(), owner)
+	}
+}
+
+// receive delivers the next message of s.
+func receive(s *transport.StreamableHTTPServer) <-chan []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		data, _ := s.Receive()
+		ch <- data
+	}()
+	return ch
+}
@@ -0,0 +1,201 @@
+// Package anomaly aggregates security signals into a per-session score.
+//
+// Individual checks see one message at a time. A compromised session
+// usually shows up as a pattern instead: repeated blocks, injection
+// detections, quota pressure, falling reputation. The Scorer combines
+// these signals into a single exponentially decaying score and trips
+// once it crosses a threshold, acting as a circuit breaker.
+//
+// # Scoring
+//
+// Each recorded signal adds its configured weight to the score. The
+// score decays with the configured half-life, so isolated incidents in
+// a long session fade while bursts accumulate.
+//
+// # Thread Safety
+//
+// Scorer is safe for concurrent use.
+package anomaly
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Signal identifies a kind of anomalous event.
+type Signal string
+
+// Known signal types.
+const (
+	// SignalBlock is recorded when a message is blocked by a security check
+	SignalBlock Signal = "block"
+	// SignalInjection is recorded when injected instructions are detected
+	SignalInjection Signal = "injection"
+	// SignalQuotaPressure is recorded when rate or gas quotas are near exhaustion
+	SignalQuotaPressure Signal = "quota_pressure"
+	// SignalReputationDrop is recorded when a server or client loses trust
+	SignalReputationDrop Signal = "reputation_drop"
+	// SignalReplay is recorded when an upstream re-delivers an event
+	SignalReplay Signal = "replay"
+	// SignalIgnoredBlock is recorded when a client retries a blocked call unchanged
+	SignalIgnoredBlock Signal = "ignored_block"
+)
+
+// Default scoring parameters.
+const (
+	DefaultThreshold = 10.0
+	DefaultHalfLife  = 5 * time.Minute
+	maxObservations  = 64
+)
+
+// DefaultWeights are the per-signal contributions used when a Config
+// does not specify a weight.
+var DefaultWeights = map[Signal]float64{
+	SignalBlock:          2.0,
+	SignalInjection:      4.0,
+	SignalQuotaPressure:  1.0,
+	SignalReputationDrop: 3.0,
+	SignalReplay:         4.0,
+	SignalIgnoredBlock:   3.0,
+}
+
+// Config contains anomaly scoring configuration.
+type Config struct {
+	// Weights maps signals to score contributions (DefaultWeights if nil;
+	// unknown signals contribute 1.0)
+	Weights map[Signal]float64
+
+	// Threshold is the score at which the session is terminated
+	Threshold float64
+
+	// HalfLife controls how quickly the score decays
+	HalfLife time.Duration
+}
+
+// DefaultConfig returns sensible default configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Weights:   DefaultWeights,
+		Threshold: DefaultThreshold,
+		HalfLife:  DefaultHalfLife,
+	}
+}
+
+// Observation is a single recorded signal.
+type Observation struct {
+	Time   time.Time `json:"time"`
+	Signal Signal    `json:"signal"`
+	Detail string    `json:"detail,omitempty"`
+	Score  float64   `json:"score"`
+}
+
+// Scorer accumulates signals for one session.
+type Scorer struct {
+	cfg Config
+	now func() time.Time
+
+	mu           sync.Mutex
+	score        float64
+	updated      time.Time
+	tripped      bool
+	observations []Observation
+}
+
+// NewScorer creates a scorer. A nil cfg uses DefaultConfig.
+func NewScorer(cfg *Config) *Scorer {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	c := *cfg
+	if c.Weights == nil {
+		c.Weights = DefaultWeights
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = DefaultThreshold
+	}
+	if c.HalfLife <= 0 {
+		c.HalfLife = DefaultHalfLife
+	}
+	return &Scorer{cfg: c, now: time.Now}
+}
+
+// Record adds a signal to the score.
+//
+// # Arguments
+//   - sig: Signal type
+//   - detail: Human-readable context for the incident record
+//
+// # Returns
+//   - The updated score
+//   - true exactly once: on the call that first crosses the threshold
+func (s *Scorer) Record(sig Signal, detail string) (score float64, tripped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.decayLocked(now)
+
+	weight, ok := s.cfg.Weights[sig]
+	if !ok {
+		weight = 1.0
+	}
+	s.score += weight
+
+	s.observations = append(s.observations, Observation{
+		Time:   now,
+		Signal: sig,
+		Detail: detail,
+		Score:  s.score,
+	})
+	if len(s.observations) > maxObservations {
+		s.observations = s.observations[len(s.observations)-maxObservations:]
+	}
+
+	if !s.tripped && s.score >= s.cfg.Threshold {
+		s.tripped = true
+		return s.score, true
+	}
+	return s.score, false
+}
+
+// Score returns the current decayed score.
+func (s *Scorer) Score() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decayLocked(s.now())
+	return s.score
+}
+
+// Tripped reports whether the threshold has been crossed.
+// Once tripped, a scorer stays tripped.
+func (s *Scorer) Tripped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tripped
+}
+
+// Threshold returns the configured trip threshold.
+func (s *Scorer) Threshold() float64 {
+	return s.cfg.Threshold
+}
+
+// Observations returns the most recent recorded signals, oldest first.
+func (s *Scorer) Observations() []Observation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Observation, len(s.observations))
+	copy(out, s.observations)
+	return out
+}
+
+// decayLocked applies exponential decay up to now. Caller must hold s.mu.
+func (s *Scorer) decayLocked(now time.Time) {
+	if !s.updated.IsZero() && s.score > 0 {
+		elapsed := now.Sub(s.updated)
+		if elapsed > 0 {
+			s.score *= math.Exp2(-float64(elapsed) / float64(s.cfg.HalfLife))
+		}
+	}
+	s.updated = now
+}
@@ -0,0 +1,55 @@
+package anomaly
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScorer_TripsOnce(t *testing.T) {
+	s := NewScorer(&Config{Threshold: 5})
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	tripCount := 0
+	for i := 0; i < 5; i++ {
+		if _, tripped := s.Record(SignalBlock, "blocked"); tripped {
+			tripCount++
+		}
+	}
+
+	if tripCount != 1 {
+		t.Errorf("expected exactly one trip, got %d", tripCount)
+	}
+	if !s.Tripped() {
+		t.Error("scorer should remain tripped")
+	}
+	if got := len(s.Observations()); got != 5 {
+		t.Errorf("expected 5 observations, got %d", got)
+	}
+}
+
+func TestScorer_Decay(t *testing.T) {
+	s := NewScorer(&Config{
+		Weights:   map[Signal]float64{SignalInjection: 8},
+		Threshold: 100,
+		HalfLife:  time.Minute,
+	})
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	s.Record(SignalInjection, "")
+	now = now.Add(time.Minute)
+
+	if got := s.Score(); math.Abs(got-4) > 1e-9 {
+		t.Errorf("expected score 4 after one half-life, got %f", got)
+	}
+}
+
+func TestScorer_UnknownSignalWeight(t *testing.T) {
+	s := NewScorer(nil)
+	score, _ := s.Record(Signal("custom"), "")
+	if score != 1.0 {
+		t.Errorf("expected default weight 1.0, got %f", score)
+	}
+}
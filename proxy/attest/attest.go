@@ -0,0 +1,432 @@
+// Package attest signs statements of what a proxy is running.
+//
+// A Statement records the SHA-256 of the proxy binary, the optional
+// features it was built with, digests of the configuration and policy
+// rules in effect, and the hash of the Rust sentinel library mapped
+// into the process. An Attester signs statements with an Ed25519 key;
+// platform teams collect them from every proxy (GET /attestation on the
+// admin port, or the attest subcommand), check them with Verify, and
+// compare them with the builds and policies they approved with
+// Approved.Check.
+//
+// # Usage
+//
+//	key, err := attest.ParsePrivateKey(cfg.Attestation.Key)
+//	a, err := attest.New(&attest.Config{Key: key, Version: Version, Policies: digests})
+//	signed, err := a.Attest(nonce)
+//
+//	stmt, err := attest.Verify(signed, publicKey, nonce)
+//	err = approved.Check(stmt)
+//
+// # Security Notes
+//
+// A statement is only as trustworthy as the host that signed it: a
+// proxy whose host is compromised can sign anything its key allows.
+// Keep the signing key readable by the proxy alone, and have the
+// verifier choose the nonce, so an old statement cannot be replayed as
+// a fresh one. The binary is hashed once, when the Attester is created,
+// from the file the process was started from.
+//
+// # Thread Safety
+//
+// Attester is safe for concurrent use.
+package attest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attestation errors.
+var (
+	ErrInvalidKey   = errors.New("attest: invalid signing key")
+	ErrInvalidNonce = errors.New("attest: invalid nonce")
+	ErrSignature    = errors.New("attest: signature does not verify")
+	ErrStatement    = errors.New("attest: malformed statement")
+	ErrNotApproved  = errors.New("attest: not approved")
+)
+
+// StatementType identifies the layout of Statement.
+const StatementType = "mcp-sentinel/attestation/v1"
+
+// MaxNonceLength bounds the nonce a verifier may ask to be signed.
+const MaxNonceLength = 128
+
+// Digest identifies a file by its SHA-256.
+type Digest struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Statement describes the running proxy.
+type Statement struct {
+	// Type is StatementType
+	Type string `json:"type"`
+
+	// Version and BuildTime are the proxy's build information
+	Version   string `json:"version"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+
+	// Binary is the executable the process was started from
+	Binary Digest `json:"binary"`
+
+	// Features maps each optional subsystem to whether the binary was
+	// built with it
+	Features map[string]bool `json:"features"`
+
+	// Policies maps names (such as config and policy) to the SHA-256
+	// of the settings in effect
+	Policies map[string]string `json:"policies"`
+
+	// FFI is the Rust sentinel library mapped into the process; nil
+	// when there is none, in the stub build or when the library is
+	// linked into Binary
+	FFI *Digest `json:"ffi,omitempty"`
+
+	Host   string    `json:"host"`
+	Issued time.Time `json:"issued"`
+
+	// Nonce is the verifier's challenge, signed to prove freshness
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Attestation is a signed Statement. The signature covers the
+// statement's compact JSON bytes, so re-indenting the attestation
+// leaves it valid while any other change breaks it.
+type Attestation struct {
+	Statement json.RawMessage `json:"statement"`
+
+	// KeyID names the signing key; see KeyID
+	KeyID string `json:"key_id"`
+
+	// Signature is the base64 Ed25519 signature of Statement
+	Signature string `json:"signature"`
+}
+
+// GenerateKey creates an Ed25519 key pair for attestation, each key
+// base64 encoded. The private key goes into the proxy's configuration;
+// the public key to whoever verifies the fleet.
+func GenerateKey() (public, private string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("attest: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(pub),
+		base64.StdEncoding.EncodeToString(priv.Seed()), nil
+}
+
+// ParsePrivateKey parses a base64 Ed25519 private key seed.
+func ParsePrivateKey(text string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidKey, len(raw), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// ParsePublicKey parses a base64 Ed25519 public key.
+func ParsePublicKey(text string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidKey, len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// KeyID returns the short hex fingerprint of a public key that
+// attestations name their signer by.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// FileDigest hashes the file at path.
+func FileDigest(path string) (Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Digest{}, fmt.Errorf("attest: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return Digest{}, fmt.Errorf("attest: %s: %w", path, err)
+	}
+	return Digest{Path: path, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// DigestJSON returns the hex SHA-256 of v encoded as JSON. Map keys
+// are sorted, so equal settings give equal digests.
+func DigestJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// mapsFile lists the files mapped into the process on Linux.
+const mapsFile = "/proc/self/maps"
+
+// findLibrary hashes the first file mapped into the process, as listed
+// in maps, whose name contains name.
+//
+// # Returns
+//   - The library's digest, or nil if none is mapped or maps cannot be
+//     read (other systems than Linux)
+//   - Error if the library cannot be hashed
+func findLibrary(maps, name string) (*Digest, error) {
+	f, err := os.Open(maps)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address perms offset dev inode path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+		path := fields[5]
+		if strings.Contains(filepath.Base(path), name) {
+			d, err := FileDigest(path)
+			if err != nil {
+				return nil, err
+			}
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+// Config configures an Attester.
+type Config struct {
+	// Key signs the statements (required)
+	Key ed25519.PrivateKey
+
+	// Version and BuildTime are the proxy's build information
+	Version   string
+	BuildTime string
+
+	// Features maps each optional subsystem to whether the binary was
+	// built with it
+	Features map[string]bool
+
+	// Policies returns the digests of the settings in effect; it is
+	// called for every statement, so reloads show (nil attests none)
+	Policies func() map[string]string
+
+	// Library is part of the file name of the Rust sentinel library to
+	// look for among the files mapped into the process (empty skips)
+	Library string
+}
+
+// Attester signs statements about the running proxy.
+type Attester struct {
+	key      ed25519.PrivateKey
+	keyID    string
+	policies func() map[string]string
+	now      func() time.Time
+
+	// mu guards base, whose library changes when the engine reloads
+	mu   sync.Mutex
+	base Statement
+}
+
+// New creates an Attester, hashing the running binary and the sentinel
+// library it loaded.
+//
+// # Returns
+//   - The Attester
+//   - Error if the key is missing or a file cannot be hashed
+func New(cfg *Config) (*Attester, error) {
+	if len(cfg.Key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: no Ed25519 key", ErrInvalidKey)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("attest: %w", err)
+	}
+	binary, err := FileDigest(exe)
+	if err != nil {
+		return nil, err
+	}
+	var ffi *Digest
+	if cfg.Library != "" {
+		if ffi, err = findLibrary(mapsFile, cfg.Library); err != nil {
+			return nil, err
+		}
+	}
+	host, _ := os.Hostname()
+	return &Attester{
+		key:   cfg.Key,
+		keyID: KeyID(cfg.Key.Public().(ed25519.PublicKey)),
+		base: Statement{
+			Type:      StatementType,
+			Version:   cfg.Version,
+			BuildTime: cfg.BuildTime,
+			GoVersion: runtime.Version(),
+			Binary:    binary,
+			Features:  cfg.Features,
+			FFI:       ffi,
+			Host:      host,
+		},
+		policies: cfg.Policies,
+		now:      time.Now,
+	}, nil
+}
+
+// KeyID returns the ID of the signing key.
+func (a *Attester) KeyID() string {
+	return a.keyID
+}
+
+// SetLibrary hashes the sentinel library at path into later
+// statements, after the engine was reloaded from it.
+func (a *Attester) SetLibrary(path string) error {
+	d, err := FileDigest(path)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.base.FFI = &d
+	return nil
+}
+
+// Attest signs a statement of the proxy as it runs now.
+//
+// # Arguments
+//   - nonce: The verifier's challenge to include (may be empty)
+//
+// # Returns
+//   - The signed statement
+//   - ErrInvalidNonce if the nonce is too long or not printable
+func (a *Attester) Attest(nonce string) (*Attestation, error) {
+	if len(nonce) > MaxNonceLength || strings.ContainsFunc(nonce, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return nil, fmt.Errorf("%w: at most %d printable characters", ErrInvalidNonce, MaxNonceLength)
+	}
+	a.mu.Lock()
+	s := a.base
+	a.mu.Unlock()
+	s.Policies = map[string]string{}
+	if a.policies != nil {
+		s.Policies = a.policies()
+	}
+	s.Issued = a.now().UTC()
+	s.Nonce = nonce
+	statement, err := json.Marshal(&s)
+	if err != nil {
+		return nil, fmt.Errorf("attest: %w", err)
+	}
+	return &Attestation{
+		Statement: statement,
+		KeyID:     a.keyID,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(a.key, statement)),
+	}, nil
+}
+
+// Verify checks an attestation's signature and returns its statement.
+//
+// # Arguments
+//   - att: The attestation
+//   - key: The public key the proxy's signing key belongs to
+//   - nonce: The challenge the statement must carry (empty accepts any)
+//
+// # Returns
+//   - The statement
+//   - ErrSignature if the signature does not verify or names another
+//     key, ErrStatement if the statement is malformed or carries
+//     another nonce
+func Verify(att *Attestation, key ed25519.PublicKey, nonce string) (*Statement, error) {
+	sig, err := base64.StdEncoding.DecodeString(att.Signature)
+	var statement bytes.Buffer
+	if err != nil || json.Compact(&statement, att.Statement) != nil {
+		return nil, ErrSignature
+	}
+	if att.KeyID != KeyID(key) || !ed25519.Verify(key, statement.Bytes(), sig) {
+		return nil, ErrSignature
+	}
+	var s Statement
+	if err := json.Unmarshal(att.Statement, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStatement, err)
+	}
+	if s.Type != StatementType {
+		return nil, fmt.Errorf("%w: type %q", ErrStatement, s.Type)
+	}
+	if nonce != "" && s.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce %q, expected %q", ErrStatement, s.Nonce, nonce)
+	}
+	return &s, nil
+}
+
+// Approved lists the builds and settings a fleet may run, by hex
+// SHA-256. An empty list accepts any value.
+type Approved struct {
+	Binaries []string `json:"binaries"`
+
+	// FFI lists approved sentinel libraries; a statement without one
+	// passes only if the list is empty
+	FFI []string `json:"ffi"`
+
+	// Policies maps a name of Statement.Policies to its approved
+	// digests; a statement missing a listed name fails
+	Policies map[string][]string `json:"policies"`
+}
+
+// Check compares a statement with the approved values.
+//
+// # Returns
+//   - ErrNotApproved naming every value that is not approved, or nil
+func (a *Approved) Check(s *Statement) error {
+	var problems []string
+	if len(a.Binaries) > 0 && !slices.Contains(a.Binaries, s.Binary.SHA256) {
+		problems = append(problems, "binary "+s.Binary.SHA256)
+	}
+	if len(a.FFI) > 0 {
+		switch {
+		case s.FFI == nil:
+			problems = append(problems, "no ffi library")
+		case !slices.Contains(a.FFI, s.FFI.SHA256):
+			problems = append(problems, "ffi "+s.FFI.SHA256)
+		}
+	}
+	names := make([]string, 0, len(a.Policies))
+	for name := range a.Policies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if approved := a.Policies[name]; len(approved) > 0 && !slices.Contains(approved, s.Policies[name]) {
+			problems = append(problems, fmt.Sprintf("%s %q", name, s.Policies[name]))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotApproved, strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package attest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newAttester creates an Attester with a fresh key, returning the
+// public key that verifies it.
+func newAttester(t *testing.T, policies map[string]string) (*Attester, string) {
+	t.Helper()
+	public, private, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	key, err := ParsePrivateKey(private)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey failed: %v", err)
+	}
+	a, err := New(&Config{
+		Key:      key,
+		Version:  "1.2.3",
+		Features: map[string]bool{"metrics": true, "ffi": false},
+		Policies: func() map[string]string { return policies },
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	a.now = func() time.Time { return time.Unix(1000, 0) }
+	return a, public
+}
+
+func TestAttest_Verify(t *testing.T) {
+	a, public := newAttester(t, map[string]string{"policy": "abc"})
+	key, err := ParsePublicKey(public)
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	signed, err := a.Attest("challenge-1")
+	if err != nil {
+		t.Fatalf("Attest failed: %v", err)
+	}
+
+	s, err := Verify(signed, key, "challenge-1")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if s.Version != "1.2.3" || s.Binary.SHA256 == "" || s.Policies["policy"] != "abc" || !s.Features["metrics"] || s.Issued.Unix() != 1000 {
+		t.Errorf("statement = %+v", s)
+	}
+
+	otherPublic, _, _ := GenerateKey()
+	var indented bytes.Buffer
+	json.Indent(&indented, signed.Statement, "", "  ")
+	reindented := *signed
+	reindented.Statement = indented.Bytes()
+	tampered := *signed
+	tampered.Statement = json.RawMessage(`{"type":"` + StatementType + `","version":"6.6.6"}`)
+	tests := []struct {
+		name  string
+		att   *Attestation
+		key   string
+		nonce string
+		err   error
+	}{
+		{"any nonce", signed, public, "", nil},
+		{"reindented", &reindented, public, "", nil},
+		{"other nonce", signed, public, "challenge-2", ErrStatement},
+		{"tampered", &tampered, public, "", ErrSignature},
+		{"other key", signed, otherPublic, "", ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, _ := ParsePublicKey(tt.key)
+			if _, err := Verify(tt.att, key, tt.nonce); !errors.Is(err, tt.err) {
+				t.Errorf("Verify = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAttest_Nonce(t *testing.T) {
+	a, _ := newAttester(t, nil)
+	tests := []struct {
+		name  string
+		nonce string
+		err   error
+	}{
+		{"empty", "", nil},
+		{"printable", "a1b2-c3", nil},
+		{"too long", string(make([]byte, MaxNonceLength+1)), ErrInvalidNonce},
+		{"control", "a\nb", ErrInvalidNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.Attest(tt.nonce); !errors.Is(err, tt.err) {
+				t.Errorf("Attest(%q) = %v, expected %v", tt.nonce, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseKeys_Invalid(t *testing.T) {
+	for _, text := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParsePrivateKey(text); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ParsePrivateKey(%q) = %v", text, err)
+		}
+		if _, err := ParsePublicKey(text); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ParsePublicKey(%q) = %v", text, err)
+		}
+	}
+	if _, err := New(&Config{}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("New without a key = %v", err)
+	}
+}
+
+func TestApproved_Check(t *testing.T) {
+	s := &Statement{
+		Binary:   Digest{SHA256: "b1"},
+		FFI:      &Digest{SHA256: "f1"},
+		Policies: map[string]string{"config": "c1", "policy": "p1"},
+	}
+	tests := []struct {
+		name     string
+		approved Approved
+		ok       bool
+	}{
+		{"nothing listed", Approved{}, true},
+		{"all approved", Approved{Binaries: []string{"b0", "b1"}, FFI: []string{"f1"}, Policies: map[string][]string{"policy": {"p1"}}}, true},
+		{"binary", Approved{Binaries: []string{"b0"}}, false},
+		{"ffi", Approved{FFI: []string{"f0"}}, false},
+		{"policy", Approved{Policies: map[string][]string{"policy": {"p0"}}}, false},
+		{"missing policy", Approved{Policies: map[string][]string{"tools": {"t1"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.approved.Check(s)
+			if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrNotApproved)) {
+				t.Errorf("Check = %v, expected ok %t", err, tt.ok)
+			}
+		})
+	}
+	if err := (&Approved{FFI: []string{"f1"}}).Check(&Statement{}); err == nil {
+		t.Error("a statement without a library passed an FFI list")
+	}
+}
+
+func TestAttester_SetLibrary(t *testing.T) {
+	a, public := newAttester(t, nil)
+	lib := filepath.Join(t.TempDir(), "libsentinel_ffi-2.so")
+	os.WriteFile(lib, []byte("library v2"), 0o644)
+	if err := a.SetLibrary(lib); err != nil {
+		t.Fatalf("SetLibrary failed: %v", err)
+	}
+	if err := a.SetLibrary(lib + ".missing"); err == nil {
+		t.Error("SetLibrary of a missing file succeeded")
+	}
+
+	signed, _ := a.Attest("")
+	key, _ := ParsePublicKey(public)
+	s, err := Verify(signed, key, "")
+	want, _ := FileDigest(lib)
+	if err != nil || s.FFI == nil || *s.FFI != want {
+		t.Errorf("statement library = %+v, %v; expected %+v", s.FFI, err, want)
+	}
+}
+
+func TestFindLibrary(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "libsentinel_ffi.so")
+	os.WriteFile(lib, []byte("library"), 0o644)
+	maps := filepath.Join(dir, "maps")
+	os.WriteFile(maps, []byte(
+		"7f00-7f01 r--p 00000000 08:01 1 /usr/lib/libc.so.6\n"+
+			"7f02-7f03 rw-p 00000000 00:00 0 [heap]\n"+
+			"7f04-7f05 r-xp 00000000 08:01 2 "+lib+"\n"), 0o644)
+
+	d, err := findLibrary(maps, "sentinel_ffi")
+	if err != nil || d == nil || d.Path != lib {
+		t.Fatalf("findLibrary = %+v, %v", d, err)
+	}
+	if want, _ := FileDigest(lib); d.SHA256 != want.SHA256 {
+		t.Errorf("digest = %s, expected %s", d.SHA256, want.SHA256)
+	}
+	if d, err := findLibrary(maps, "absent"); d != nil || err != nil {
+		t.Errorf("findLibrary(absent) = %+v, %v", d, err)
+	}
+	if d, err := findLibrary(filepath.Join(dir, "missing"), "sentinel_ffi"); d != nil || err != nil {
+		t.Errorf("findLibrary without maps = %+v, %v", d, err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package audit writes the proxy's structured audit trail.
+//
+// Every message the router sees produces one Record: when it arrived,
+// its session, direction, method and tool, the routing decision, the
+// reasons the security checks gave, and the latency. Records go to a
+// Sink. FileSink appends JSON lines to a rotated file; WriterSink and
+// HTTPSink ship them elsewhere (syslog, a log collector), and Multi
+// sends them to several sinks at once. Broadcaster passes them on to
+// live subscribers as they are written. ChainSink links the records by
+// SHA-256 hashes so tampering shows, and Verifier checks the links.
+// EncryptSink encrypts selected fields to a separate key, and
+// Decryptor restores them for an investigation.
+//
+// # Usage
+//
+//	sink, err := audit.NewFileSink("/var/log/mcp-sentinel/audit.jsonl", &audit.FileConfig{MaxBytes: 100 << 20})
+//	if err != nil {
+//		return err
+//	}
+//	defer sink.Close()
+//	cfg.Audit = sink
+//
+// # Security Notes
+//
+// Records carry no message contents, only metadata and check reasons,
+// unless the router is configured to record tool call payloads. Reasons
+// can quote tool names and argument fragments that matched a rule, so
+// the trail should be kept as private as the proxy's log, or those
+// fields encrypted with an EncryptSink.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Sink errors.
+var (
+	ErrClosed    = errors.New("audit: sink closed")
+	ErrQueueFull = errors.New("audit: queue full")
+)
+
+// Direction is which way a message travelled.
+type Direction string
+
+const (
+	// ClientToServer is a message from the client
+	ClientToServer Direction = "client_to_server"
+	// ServerToClient is a message from the server relayed to the client
+	ServerToClient Direction = "server_to_client"
+)
+
+// DecisionRelayed marks messages relayed without routing checks: the
+// server's own requests and notifications, and the client's responses
+// to them.
+const DecisionRelayed = "relayed"
+
+// DecisionOrphaned marks a request left unanswered past the router's
+// orphan deadline; the record is written when the deadline passes.
+const DecisionOrphaned = "orphaned"
+
+// DecisionDropped marks a response dropped because it answers no
+// outstanding request: an unknown, duplicate, or late ID.
+const DecisionDropped = "dropped"
+
+// Record is one audited message.
+type Record struct {
+	// Seq numbers the record in its hash chain, from 1 (zero when the
+	// trail is not chained; see ChainSink)
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Time is when the message arrived
+	Time time.Time `json:"time"`
+
+	// Session identifies the router session
+	Session string `json:"session"`
+
+	// Direction is which way the message travelled
+	Direction Direction `json:"direction"`
+
+	// Method is the JSON-RPC method (empty for responses)
+	Method string `json:"method,omitempty"`
+
+	// Tool is the tool called by a tools/call request
+	Tool string `json:"tool,omitempty"`
+
+	// DecisionID and TraceID identify the routing decision (empty for
+	// relayed messages)
+	DecisionID string `json:"decision_id,omitempty"`
+	TraceID    string `json:"trace_id,omitempty"`
+
+	// Decision is the verdict: allowed, blocked, error, or relayed; or
+	// orphaned or dropped for requests and responses that found no match
+	Decision string `json:"decision"`
+
+	// Reason is the reason given with the verdict
+	Reason string `json:"reason,omitempty"`
+
+	// Reasons are the security checks' reasons, as "check: reason"
+	Reasons []string `json:"reasons,omitempty"`
+
+	// Arguments is the start of a tools/call request's arguments, and
+	// Result of the response sent back, when the router records
+	// payloads (see router.Config.AuditPayloadBytes); either may be
+	// encrypted by an EncryptSink
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+
+	// Original is server content the router rewrote before delivering
+	// it, as JSON: the tool descriptions it sanitized, for one; it may
+	// be encrypted by an EncryptSink
+	Original string `json:"original,omitempty"`
+
+	// LatencyMS is the time from arrival to decision completion, and
+	// AddedLatencyMS the part not spent waiting on the server
+	LatencyMS      float64 `json:"latency_ms"`
+	AddedLatencyMS float64 `json:"added_latency_ms"`
+
+	// Prev is the hex SHA-256 of the previous record's JSON line in the
+	// hash chain (empty for the first record or an unchained trail)
+	Prev string `json:"prev,omitempty"`
+}
+
+// Sink receives audit records.
+//
+// # Thread Safety
+//
+// Implementations must be safe for concurrent use. Write is called on
+// the routing path and should not block for long.
+type Sink interface {
+	// Write records one message
+	Write(rec *Record) error
+
+	// Close flushes buffered records and releases the sink
+	Close() error
+}
+
+// WriterSink writes records as JSON lines to an io.Writer, one Write
+// call per record. A *syslog.Writer, for one, turns each into a syslog
+// message.
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closed bool
+}
+
+// NewWriterSink creates a sink writing to w. Close closes w if it is an
+// io.Closer.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write writes rec as one JSON line.
+func (s *WriterSink) Write(rec *Record) error {
+	line, err := marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the writer if it is an io.Closer.
+func (s *WriterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// multiSink fans records out to several sinks.
+type multiSink []Sink
+
+// Multi returns a sink writing every record to each of sinks. Write
+// and Close report the first error but always reach every sink.
+func Multi(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(rec *Record) error {
+	var first error
+	for _, s := range m {
+		if err := s.Write(rec); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m multiSink) Close() error {
+	var first error
+	for _, s := range m {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// marshal encodes rec as a JSON line.
+func marshal(rec *Record) ([]byte, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failSink fails every write.
+type failSink struct{ closed bool }
+
+func (s *failSink) Write(*Record) error { return errors.New("down") }
+func (s *failSink) Close() error        { s.closed = true; return nil }
+
+func testRecord(decision string) *Record {
+	return &Record{
+		Time:      time.Unix(1700000000, 0).UTC(),
+		Session:   "s1",
+		Direction: ClientToServer,
+		Method:    "tools/call",
+		Tool:      "read_file",
+		Decision:  decision,
+		Reasons:   []string{"registry: ok"},
+		LatencyMS: 1.5,
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewWriterSink(&buf)
+	for _, d := range []string{"allowed", "blocked"} {
+		if err := s.Write(testRecord(d)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	var got []Record
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q is not a record: %v", scanner.Text(), err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 2 || got[1].Decision != "blocked" || got[0].Tool != "read_file" || got[0].Reasons[0] != "registry: ok" {
+		t.Errorf("records = %+v", got)
+	}
+
+	s.Close()
+	if err := s.Write(testRecord("allowed")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close = %v, expected ErrClosed", err)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var buf bytes.Buffer
+	failing := &failSink{}
+	m := Multi(failing, NewWriterSink(&buf))
+	if err := m.Write(testRecord("allowed")); err == nil {
+		t.Error("Write did not report the failing sink")
+	}
+	if buf.Len() == 0 {
+		t.Error("a failing sink kept the record from the next one")
+	}
+	m.Close()
+	if !failing.closed {
+		t.Error("Close did not reach every sink")
+	}
+}
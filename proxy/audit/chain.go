@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrTampered reports a record that does not continue the hash chain:
+// one was altered, removed, inserted, or reordered.
+var ErrTampered = errors.New("audit: hash chain broken")
+
+// maxLine bounds one record line when reading a trail back.
+const maxLine = 1 << 20
+
+// Link is a position in a hash chain: the sequence number and the
+// hash of the record there. The zero Link is the start of a chain.
+type Link struct {
+	// Seq is the record's sequence number, from 1
+	Seq uint64 `json:"seq"`
+
+	// Hash is the hex SHA-256 of the record's JSON line, without its
+	// newline
+	Hash string `json:"hash"`
+}
+
+// hashLine returns the hex SHA-256 of a record line.
+func hashLine(line []byte) string {
+	sum := sha256.Sum256(bytes.TrimRight(line, "\n"))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainSink numbers records and chains each to the previous one by
+// hash before passing it on, so removing or editing any record breaks
+// every later link. Verify a trail with Verifier.
+//
+// The hash covers the record's JSON line exactly as the sinks write
+// it, so any sink below receives the same bytes that were hashed.
+//
+// # Security Notes
+//
+// The chain makes tampering evident, not impossible: someone able to
+// rewrite the whole trail can recompute every hash. Ship the trail, or
+// at least each Last link, somewhere the proxy host cannot rewrite.
+//
+// # Thread Safety
+//
+// Records are chained and written one at a time, in arrival order.
+type ChainSink struct {
+	next Sink
+
+	mu   sync.Mutex
+	last Link
+}
+
+// NewChainSink creates a sink chaining records into next, continuing
+// from the record at from (the zero Link starts a new chain; Tail finds
+// where a file left off).
+func NewChainSink(next Sink, from Link) *ChainSink {
+	return &ChainSink{next: next, last: from}
+}
+
+// Write stamps a copy of rec with the next sequence number and the
+// previous record's hash and writes it. The chain advances even when
+// next fails, so a sink that lost the record shows the gap.
+func (s *ChainSink) Write(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chained := *rec
+	chained.Seq = s.last.Seq + 1
+	chained.Prev = s.last.Hash
+	line, err := marshal(&chained)
+	if err != nil {
+		return err
+	}
+	s.last = Link{Seq: chained.Seq, Hash: hashLine(line)}
+	return s.next.Write(&chained)
+}
+
+// Last returns the link of the most recent record.
+func (s *ChainSink) Last() Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Close closes next.
+func (s *ChainSink) Close() error {
+	return s.next.Close()
+}
+
+// Tail returns the link of the last record in the trail at path, or in
+// its newest rotated file (path.1) when path is empty or missing, so a
+// restarted proxy continues the chain. It returns the zero Link when
+// there is no trail yet.
+func Tail(path string) (Link, error) {
+	for _, name := range []string{path, path + ".1"} {
+		line, err := lastLine(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Link{}, fmt.Errorf("audit: %w", err)
+		}
+		if line == nil {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return Link{}, fmt.Errorf("audit: %s: last record: %w", name, err)
+		}
+		if rec.Seq == 0 {
+			return Link{}, fmt.Errorf("audit: %s: last record is not chained", name)
+		}
+		return Link{Seq: rec.Seq, Hash: hashLine(line)}, nil
+	}
+	return Link{}, nil
+}
+
+// lastLine returns the last non-empty line of the file at name, or nil
+// if it has none, reading backwards from the end.
+func lastLine(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	const chunk = 64 << 10
+	var tail []byte
+	for pos := end; pos > 0; {
+		n := int64(chunk)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, pos); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if pos == 0 && len(trimmed) > 0 {
+			return trimmed, nil
+		}
+		if len(tail) > maxLine {
+			return nil, fmt.Errorf("%s: last line longer than %d bytes", name, maxLine)
+		}
+	}
+	return nil, nil
+}
+
+// Verifier checks that records continue one hash chain. Feed it the
+// lines of a trail in order, across rotated files oldest first.
+//
+// A chain starting mid-way (the first file kept after older ones were
+// rotated away) is accepted from its first record on, unless the
+// Verifier was created from a known Link.
+type Verifier struct {
+	last    Link
+	started bool
+	records int
+}
+
+// NewVerifier creates a verifier expecting the chain to continue from
+// from. The zero Link accepts any starting point.
+func NewVerifier(from Link) *Verifier {
+	return &Verifier{last: from, started: from != Link{}}
+}
+
+// Add checks the next record line. After an error the verifier stays
+// at the last good record.
+func (v *Verifier) Add(line []byte) error {
+	var rec Record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return fmt.Errorf("%w: malformed record: %v", ErrTampered, err)
+	}
+	switch {
+	case rec.Seq == 0:
+		return fmt.Errorf("%w: record is not chained", ErrTampered)
+	case !v.started && rec.Seq == 1 && rec.Prev != "":
+		return fmt.Errorf("%w: record 1 has a previous hash", ErrTampered)
+	case v.started && rec.Seq != v.last.Seq+1:
+		return fmt.Errorf("%w: record %d follows record %d", ErrTampered, rec.Seq, v.last.Seq)
+	case v.started && rec.Prev != v.last.Hash:
+		return fmt.Errorf("%w: record %d does not match the hash of record %d", ErrTampered, rec.Seq, v.last.Seq)
+	}
+	v.last = Link{Seq: rec.Seq, Hash: hashLine(line)}
+	v.started = true
+	v.records++
+	return nil
+}
+
+// Verify checks every line read from r, naming name and the line
+// number in errors.
+func (v *Verifier) Verify(name string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := v.Add(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("audit: %s: %w", name, err)
+	}
+	return nil
+}
+
+// VerifyFile checks the trail in the file at path.
+func (v *Verifier) VerifyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+	return v.Verify(path, f)
+}
+
+// Last returns the link of the last verified record.
+func (v *Verifier) Last() Link {
+	return v.last
+}
+
+// Records returns the number of records verified.
+func (v *Verifier) Records() int {
+	return v.records
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chainedTrail writes n chained records to a file sink at path,
+// continuing from Tail, and returns the sink's last link.
+func chainedTrail(t *testing.T, path string, cfg *FileConfig, n int) Link {
+	t.Helper()
+	from, err := Tail(path)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	file, err := NewFileSink(path, cfg)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	s := NewChainSink(file, from)
+	for i := 0; i < n; i++ {
+		if err := s.Write(testRecord("allowed")); err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+	}
+	s.Close()
+	return s.Last()
+}
+
+func TestChainSink_VerifiesAcrossRestartsAndRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	line, _ := marshal(testRecord("allowed"))
+	cfg := &FileConfig{MaxBytes: int64(4 * (len(line) + 100)), MaxFiles: 10}
+
+	chainedTrail(t, path, cfg, 5)
+	last := chainedTrail(t, path, cfg, 6)
+	if last.Seq != 11 {
+		t.Fatalf("restarted chain ended at seq %d, expected 11", last.Seq)
+	}
+
+	// Oldest first: path.N ... path.1, path
+	files, _ := filepath.Glob(path + ".*")
+	if len(files) < 2 {
+		t.Fatalf("expected rotated files, got %v", files)
+	}
+	v := NewVerifier(Link{})
+	for i := len(files); i >= 1; i-- {
+		if err := v.VerifyFile(fmt.Sprintf("%s.%d", path, i)); err != nil {
+			t.Fatalf("VerifyFile failed: %v", err)
+		}
+	}
+	if err := v.VerifyFile(path); err != nil {
+		t.Fatalf("VerifyFile failed: %v", err)
+	}
+	if v.Records() != 11 || v.Last() != last {
+		t.Errorf("verified %d records ending at %+v, expected 11 ending at %+v", v.Records(), v.Last(), last)
+	}
+
+	// The newest file alone verifies from its first record, but not
+	// from a known link it does not continue
+	if err := NewVerifier(Link{}).VerifyFile(path); err != nil {
+		t.Errorf("newest file alone: %v", err)
+	}
+	if err := NewVerifier(Link{Seq: 1, Hash: "00"}).VerifyFile(path); !errors.Is(err, ErrTampered) {
+		t.Errorf("wrong anchor: %v, expected ErrTampered", err)
+	}
+}
+
+func TestVerifier_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	chainedTrail(t, path, nil, 4)
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\n") + "\n"
+	}
+
+	tests := []struct {
+		name   string
+		edit   func([]string) []string
+		broken string
+	}{
+		{"intact", func(l []string) []string { return l }, ""},
+		{"altered", func(l []string) []string {
+			l[1] = strings.Replace(l[1], `"allowed"`, `"blocked"`, 1)
+			return l
+		}, ":3:"},
+		{"removed", func(l []string) []string { return append(l[:1], l[2:]...) }, ":2:"},
+		{"reordered", func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, ":2:"},
+		{"truncated from the start", func(l []string) []string { return l[2:] }, ""},
+		{"forged first record", func(l []string) []string {
+			return append([]string{strings.Replace(l[0], `"seq":1`, `"seq":1,"prev":"ab"`, 1)}, l[1:]...)
+		}, ":1:"},
+		{"unchained", func(l []string) []string {
+			return append(l, `{"session":"s1","decision":"allowed"}`+"\n")
+		}, ":5:"},
+	}
+	for _, tt := range tests {
+		edited := tt.edit(append([]string(nil), lines...))
+		err := NewVerifier(Link{}).Verify("trail", strings.NewReader(strings.Join(edited, "")))
+		switch {
+		case tt.broken == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.broken != "" && (!errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), tt.broken)):
+			t.Errorf("%s: error %v, expected ErrTampered at line %s", tt.name, err, tt.broken)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	if link, err := Tail(path); err != nil || link != (Link{}) {
+		t.Errorf("missing trail: %+v, %v", link, err)
+	}
+
+	last := chainedTrail(t, path, nil, 3)
+	if link, err := Tail(path); err != nil || link != last {
+		t.Errorf("Tail = %+v, %v, expected %+v", link, err, last)
+	}
+
+	// Just after rotation the current file is empty
+	os.Rename(path, path+".1")
+	os.WriteFile(path, nil, 0o600)
+	if link, err := Tail(path); err != nil || link != last {
+		t.Errorf("after rotation Tail = %+v, %v, expected %+v", link, err, last)
+	}
+
+	// A line longer than one read chunk is found whole
+	big := testRecord("blocked")
+	big.Reason = string(bytes.Repeat([]byte("x"), 100<<10))
+	s := NewChainSink(mustFileSink(t, path), last)
+	s.Write(big)
+	s.Close()
+	if link, err := Tail(path); err != nil || link != s.Last() {
+		t.Errorf("long line Tail = %+v, %v, expected %+v", link, err, s.Last())
+	}
+
+	unchained := filepath.Join(dir, "plain.jsonl")
+	f := mustFileSink(t, unchained)
+	f.Write(testRecord("allowed"))
+	f.Close()
+	if _, err := Tail(unchained); err == nil {
+		t.Error("Tail of an unchained trail should fail")
+	}
+}
+
+func mustFileSink(t *testing.T, path string) *FileSink {
+	t.Helper()
+	s, err := NewFileSink(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
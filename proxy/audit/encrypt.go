@@ -0,0 +1,345 @@
+package audit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Field encryption errors.
+var (
+	ErrInvalidKey   = errors.New("audit: invalid encryption key")
+	ErrUnknownField = errors.New("audit: field cannot be encrypted")
+	ErrDecrypt      = errors.New("audit: cannot decrypt field")
+)
+
+// Record fields that may be encrypted, by their JSON names.
+const (
+	FieldArguments = "arguments"
+	FieldResult    = "result"
+	FieldReason    = "reason"
+	FieldReasons   = "reasons"
+	FieldOriginal  = "original"
+)
+
+// encryptedPrefix starts every encrypted field value:
+// enc:v1:KEYID:EPHEMERAL:SEALED, with the recipient key ID in hex and
+// the ephemeral public key and nonce||ciphertext in base64url.
+const encryptedPrefix = "enc:v1:"
+
+// hkdfInfo separates the derived keys from any other use of the
+// recipient key.
+const hkdfInfo = "mcp-sentinel audit field encryption v1"
+
+// GenerateKey creates an X25519 key pair for field encryption, each
+// key base64 encoded. The public key goes into the proxy's
+// configuration; the private key stays with whoever investigates.
+func GenerateKey() (public, private string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("audit: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.StdEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// ParsePublicKey parses a base64 X25519 public key.
+func ParsePublicKey(text string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey parses a base64 X25519 private key.
+func ParsePrivateKey(text string) (*ecdh.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return key, nil
+}
+
+// KeyID returns the short hex fingerprint of a public key that
+// encrypted fields name their recipient by.
+func KeyID(key *ecdh.PublicKey) string {
+	sum := sha256.Sum256(key.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// fieldKey derives the AES-256-GCM cipher shared by an ephemeral key
+// and the recipient key.
+func fieldKey(secret []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, secret, salt, hkdfInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fieldAAD binds an encrypted value to its field and record, so it
+// cannot be moved to another record or field unnoticed.
+func fieldAAD(rec *Record, field string) []byte {
+	return []byte(field + "\x00" + rec.Session + "\x00" + rec.DecisionID)
+}
+
+// EncryptSink encrypts selected fields of each record to a recipient's
+// public key before passing the record on, so the trail's metadata
+// stays readable to anyone operating the proxy while payloads (tool
+// call arguments, result snippets, check reasons quoting them) can only
+// be read with the recipient's private key; see Decryptor.
+//
+// Each value is sealed with AES-256-GCM under a key agreed by X25519
+// between the recipient key and an ephemeral key generated when the
+// sink is created. Encrypted values are strings of the form
+// enc:v1:KEYID:EPHEMERAL:SEALED; empty fields stay empty.
+//
+// # Security Notes
+//
+// The proxy holds only the public key: neither the proxy host nor
+// anyone reading its configuration can decrypt the trail. The key is
+// separate from any transport key, so TLS termination or syslog
+// collectors never see payloads in the clear.
+//
+// Put the sink in front of a ChainSink so the chain hashes what is
+// stored, ciphertext included. Whether a field was empty, and roughly
+// how long it was, remain visible.
+//
+// # Thread Safety
+//
+// Safe for concurrent use if next is.
+type EncryptSink struct {
+	next      Sink
+	fields    map[string]bool
+	aead      cipher.AEAD
+	recipient string
+	ephemeral string
+}
+
+// NewEncryptSink creates a sink encrypting fields (FieldArguments,
+// FieldResult, FieldReason, FieldReasons, FieldOriginal) to the base64
+// X25519 public key and writing records to next.
+//
+// # Returns
+//
+// ErrInvalidKey for a malformed key, or ErrUnknownField for a field
+// that cannot be encrypted.
+func NewEncryptSink(next Sink, publicKey string, fields []string) (*EncryptSink, error) {
+	recipient, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		switch f {
+		case FieldArguments, FieldResult, FieldReason, FieldReasons, FieldOriginal:
+			selected[f] = true
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownField, f)
+		}
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	secret, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	aead, err := fieldKey(secret, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return &EncryptSink{
+		next:      next,
+		fields:    selected,
+		aead:      aead,
+		recipient: KeyID(recipient),
+		ephemeral: base64.RawURLEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+	}, nil
+}
+
+// Write encrypts the selected fields of a copy of rec and writes the
+// copy to next.
+func (s *EncryptSink) Write(rec *Record) error {
+	sealed := *rec
+	var err error
+	seal := func(field, value string) string {
+		if value == "" || err != nil {
+			return value
+		}
+		var out string
+		out, err = s.seal(&sealed, field, value)
+		return out
+	}
+	if s.fields[FieldArguments] {
+		sealed.Arguments = seal(FieldArguments, rec.Arguments)
+	}
+	if s.fields[FieldResult] {
+		sealed.Result = seal(FieldResult, rec.Result)
+	}
+	if s.fields[FieldReason] {
+		sealed.Reason = seal(FieldReason, rec.Reason)
+	}
+	if s.fields[FieldOriginal] {
+		sealed.Original = seal(FieldOriginal, rec.Original)
+	}
+	if s.fields[FieldReasons] && len(rec.Reasons) > 0 {
+		sealed.Reasons = make([]string, len(rec.Reasons))
+		for i, reason := range rec.Reasons {
+			sealed.Reasons[i] = seal(FieldReasons, reason)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return s.next.Write(&sealed)
+}
+
+// seal encrypts one field value of rec.
+func (s *EncryptSink) seal(rec *Record, field, value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(value)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("audit: %w", err)
+	}
+	out := s.aead.Seal(nonce, nonce, []byte(value), fieldAAD(rec, field))
+	return encryptedPrefix + s.recipient + ":" + s.ephemeral + ":" + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// Close closes next.
+func (s *EncryptSink) Close() error {
+	return s.next.Close()
+}
+
+// IsEncrypted reports whether a field value was encrypted by an
+// EncryptSink.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Decryptor restores the fields an EncryptSink encrypted, given the
+// recipient's private key.
+//
+// # Thread Safety
+//
+// Safe for concurrent use.
+type Decryptor struct {
+	key *ecdh.PrivateKey
+	kid string
+
+	mu      sync.Mutex
+	ciphers map[string]cipher.AEAD // by ephemeral key
+}
+
+// NewDecryptor creates a decryptor for the base64 X25519 private key.
+func NewDecryptor(privateKey string) (*Decryptor, error) {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Decryptor{key: key, kid: KeyID(key.PublicKey()), ciphers: make(map[string]cipher.AEAD)}, nil
+}
+
+// Decrypt replaces the encrypted fields of rec with their plaintext.
+// Fields that are not encrypted are left alone.
+//
+// # Returns
+//
+// ErrDecrypt wrapping the cause if a value was encrypted to another
+// key, was altered, or was moved from another record or field; rec is
+// then left partly decrypted.
+func (d *Decryptor) Decrypt(rec *Record) error {
+	var err error
+	open := func(field, value string) string {
+		if !IsEncrypted(value) || err != nil {
+			return value
+		}
+		var out string
+		out, err = d.open(rec, field, value)
+		return out
+	}
+	rec.Arguments = open(FieldArguments, rec.Arguments)
+	rec.Result = open(FieldResult, rec.Result)
+	rec.Reason = open(FieldReason, rec.Reason)
+	rec.Original = open(FieldOriginal, rec.Original)
+	for i, reason := range rec.Reasons {
+		rec.Reasons[i] = open(FieldReasons, reason)
+	}
+	return err
+}
+
+// open decrypts one field value of rec.
+func (d *Decryptor) open(rec *Record, field, value string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: %s: malformed value", ErrDecrypt, field)
+	}
+	kid, ephemeral, sealed := parts[0], parts[1], parts[2]
+	if kid != d.kid {
+		return "", fmt.Errorf("%w: %s: encrypted to key %s, not %s", ErrDecrypt, field, kid, d.kid)
+	}
+	aead, err := d.cipher(ephemeral)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrDecrypt, field, err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: %s: malformed value", ErrDecrypt, field)
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], fieldAAD(rec, field))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrDecrypt, field, err)
+	}
+	return string(plain), nil
+}
+
+// cipher returns the cipher shared with an ephemeral key, deriving it
+// the first time the key is seen.
+func (d *Decryptor) cipher(ephemeral string) (cipher.AEAD, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if aead, ok := d.ciphers[ephemeral]; ok {
+		return aead, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := d.key.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := fieldKey(secret, pub, d.key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	d.ciphers[ephemeral] = aead
+	return aead, nil
+}
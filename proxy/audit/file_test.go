@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	line, _ := marshal(testRecord("allowed"))
+	s, err := NewFileSink(path, &FileConfig{MaxBytes: int64(2 * len(line)), MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := s.Write(testRecord("allowed")); err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 7 records at 2 per file: path has 1, path.1 and path.2 have 2, the
+	// oldest 2 were removed
+	for name, want := range map[string]int{path: 1, path + ".1": 2, path + ".2": 2} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", name, err)
+		}
+		if got := bytes.Count(data, []byte("\n")); got != want {
+			t.Errorf("%s has %d records, expected %d", filepath.Base(name), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("path.3 exists beyond MaxFiles: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, expected 0600", info.Mode().Perm())
+	}
+}
+
+func TestFileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		s, err := NewFileSink(path, nil)
+		if err != nil {
+			t.Fatalf("NewFileSink failed: %v", err)
+		}
+		s.Write(testRecord("allowed"))
+		s.Close()
+	}
+	data, _ := os.ReadFile(path)
+	if got := bytes.Count(data, []byte("\n")); got != 2 {
+		t.Errorf("reopened file has %d records, expected 2", got)
+	}
+
+	s, _ := NewFileSink(path, nil)
+	if err := s.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	s.Close()
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("Rotate did not keep the old file: %v", err)
+	}
+}
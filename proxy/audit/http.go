@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPConfig configures an HTTPSink.
+type HTTPConfig struct {
+	// Client sends the requests (nil uses a client with a 10s timeout)
+	Client *http.Client
+
+	// BatchSize is the most records per POST (zero uses 100)
+	BatchSize int
+
+	// FlushInterval is the longest a record waits for its batch to fill
+	// (zero uses 1s)
+	FlushInterval time.Duration
+
+	// QueueSize bounds the records waiting to be sent; Write drops
+	// records and returns ErrQueueFull beyond it (zero uses 10000)
+	QueueSize int
+
+	// Header is added to every request, e.g. an Authorization token
+	Header http.Header
+}
+
+// HTTPSink POSTs batches of records to a collector as JSON lines
+// (Content-Type application/x-ndjson).
+//
+// Write only queues the record; a background goroutine sends batches,
+// so a slow collector never delays routing. Batches that fail to send
+// are logged and dropped.
+type HTTPSink struct {
+	url string
+	cfg HTTPConfig
+
+	queue   chan []byte
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewHTTPSink creates a sink posting to url and starts its sender. cfg
+// may be nil for defaults.
+func NewHTTPSink(url string, cfg *HTTPConfig) *HTTPSink {
+	s := &HTTPSink{url: url}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.Client == nil {
+		s.cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if s.cfg.BatchSize <= 0 {
+		s.cfg.BatchSize = 100
+	}
+	if s.cfg.FlushInterval <= 0 {
+		s.cfg.FlushInterval = time.Second
+	}
+	if s.cfg.QueueSize <= 0 {
+		s.cfg.QueueSize = 10000
+	}
+	s.queue = make(chan []byte, s.cfg.QueueSize)
+	s.done = make(chan struct{})
+	go s.run()
+	return s
+}
+
+// Write queues rec for the next batch.
+func (s *HTTPSink) Write(rec *Record) error {
+	line, err := marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+	select {
+	case s.queue <- line:
+		return nil
+	default:
+		s.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Dropped returns the number of records dropped on a full queue or a
+// failed POST.
+func (s *HTTPSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close sends the queued records and stops the sender.
+func (s *HTTPSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// run sends batches until the queue is closed and drained.
+func (s *HTTPSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	n := 0
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		if err := s.post(batch.Bytes()); err != nil {
+			s.dropped.Add(uint64(n))
+			log.Printf("audit: dropped %d records: %v", n, err)
+		}
+		batch.Reset()
+		n = 0
+	}
+	for {
+		select {
+		case line, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch.Write(line)
+			if n++; n >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// post sends one batch.
+func (s *HTTPSink) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/x-ndjson" || req.Header.Get("Authorization") != "Bearer t" {
+			t.Errorf("headers = %v", req.Header)
+		}
+		n := 0
+		for scanner := bufio.NewScanner(req.Body); scanner.Scan(); n++ {
+		}
+		mu.Lock()
+		batches = append(batches, n)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL, &HTTPConfig{BatchSize: 3, FlushInterval: time.Hour, Header: http.Header{"Authorization": {"Bearer t"}}})
+	for i := 0; i < 7; i++ {
+		if err := s.Write(testRecord("allowed")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := 0
+	for _, n := range batches {
+		if n > 3 {
+			t.Errorf("batch of %d records, expected at most 3", n)
+		}
+		total += n
+	}
+	if total != 7 {
+		t.Errorf("collector received %d records in %v, expected 7", total, batches)
+	}
+	if err := s.Write(testRecord("allowed")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close = %v, expected ErrClosed", err)
+	}
+}
+
+func TestHTTPSink_Failures(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL, &HTTPConfig{BatchSize: 1, QueueSize: 1})
+	// The sender holds one record in a stuck POST; the queue takes one more
+	var full bool
+	for i := 0; i < 10 && !full; i++ {
+		full = errors.Is(s.Write(testRecord("allowed")), ErrQueueFull)
+	}
+	if !full {
+		t.Error("Write never reported a full queue")
+	}
+	close(release)
+	s.Close()
+	if s.Dropped() < 2 {
+		t.Errorf("Dropped = %d, expected the full queue and failed posts counted", s.Dropped())
+	}
+}
@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultStreamBuffer is how many records a subscriber may fall behind
+// before records are dropped for it.
+const DefaultStreamBuffer = 256
+
+// Filter selects the records a subscriber receives. Empty fields match
+// every record.
+type Filter struct {
+	// Session matches Record.Session
+	Session string
+
+	// Tool matches Record.Tool
+	Tool string
+
+	// Decision matches Record.Decision
+	Decision string
+}
+
+// Match reports whether rec passes the filter.
+func (f Filter) Match(rec *Record) bool {
+	return (f.Session == "" || rec.Session == f.Session) &&
+		(f.Tool == "" || rec.Tool == f.Tool) &&
+		(f.Decision == "" || rec.Decision == f.Decision)
+}
+
+// Broadcaster is a sink that passes records on to live subscribers, such
+// as dashboards following the trail. It keeps no records: a subscriber
+// sees those written while it is subscribed.
+//
+// # Thread Safety
+//
+// Broadcaster is safe for concurrent use. Write never blocks: a
+// subscriber that falls more than its buffer behind loses records, and
+// Subscription.Dropped counts them.
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives the records of a Broadcaster that pass its
+// filter.
+type Subscription struct {
+	b       *Broadcaster
+	filter  Filter
+	ch      chan *Record
+	dropped atomic.Uint64
+}
+
+// NewBroadcaster creates a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the records passing f, buffering
+// up to buffer of them (zero uses DefaultStreamBuffer). The caller must
+// Close it. A subscription to a closed broadcaster receives nothing.
+func (b *Broadcaster) Subscribe(f Filter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	s := &Subscription{b: b, filter: f, ch: make(chan *Record, buffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Write passes a copy of rec to each subscriber whose filter it passes.
+func (b *Broadcaster) Write(rec *Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	var c *Record
+	for s := range b.subs {
+		if !s.filter.Match(rec) {
+			continue
+		}
+		if c == nil {
+			copied := *rec
+			c = &copied
+		}
+		select {
+		case s.ch <- c:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Close ends every subscription.
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for s := range b.subs {
+		close(s.ch)
+	}
+	clear(b.subs)
+	return nil
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Records returns the channel the subscription's records arrive on. It
+// is closed when the subscription or the broadcaster is.
+func (s *Subscription) Records() <-chan *Record {
+	return s.ch
+}
+
+// Dropped returns the number of records lost because the subscriber
+// fell behind.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if _, ok := s.b.subs[s]; ok {
+		delete(s.b.subs, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	all := b.Subscribe(Filter{}, 2)
+	blocked := b.Subscribe(Filter{Session: "s1", Decision: "blocked"}, 0)
+	search := b.Subscribe(Filter{Tool: "search"}, 0)
+
+	records := []*Record{
+		{Session: "s1", Tool: "search", Decision: "allowed"},
+		{Session: "s1", Tool: "shell", Decision: "blocked"},
+		{Session: "s2", Tool: "shell", Decision: "blocked"},
+	}
+	for _, rec := range records {
+		if err := b.Write(rec); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	records[0].Decision = "changed"
+
+	tests := []struct {
+		name     string
+		sub      *Subscription
+		expected []string
+		dropped  uint64
+	}{
+		{"unfiltered, buffer of 2", all, []string{"search", "shell"}, 1},
+		{"session and verdict", blocked, []string{"shell"}, 0},
+		{"tool", search, []string{"search"}, 0},
+	}
+	for _, tt := range tests {
+		var got []string
+		for len(tt.sub.Records()) > 0 {
+			rec := <-tt.sub.Records()
+			if rec.Decision == "changed" {
+				t.Errorf("%s: record not copied on Write", tt.name)
+			}
+			got = append(got, rec.Tool)
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("%s: received %v, expected %v", tt.name, got, tt.expected)
+		}
+		if tt.sub.Dropped() != tt.dropped {
+			t.Errorf("%s: dropped %d, expected %d", tt.name, tt.sub.Dropped(), tt.dropped)
+		}
+	}
+
+	search.Close()
+	search.Close()
+	if b.Subscribers() != 2 {
+		t.Errorf("Subscribers = %d after one Close, expected 2", b.Subscribers())
+	}
+	b.Close()
+	if _, ok := <-all.Records(); ok {
+		t.Error("subscription open after the broadcaster closed")
+	}
+	all.Close()
+	if err := b.Write(records[0]); err != ErrClosed {
+		t.Errorf("Write after Close = %v, expected ErrClosed", err)
+	}
+	if _, ok := <-b.Subscribe(Filter{}, 0).Records(); ok {
+		t.Error("subscription to a closed broadcaster is open")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// NewSyslogSink creates a sink sending each record as a syslog message
+// with the given tag, to the local syslog daemon when network is empty
+// or to addr over network ("udp", "tcp") otherwise.
+func NewSyslogSink(network, addr, tag string) (*WriterSink, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return NewWriterSink(w), nil
+}
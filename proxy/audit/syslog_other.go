@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+// NewSyslogSink reports that syslog is not available on this platform.
+func NewSyslogSink(network, addr, tag string) (*WriterSink, error) {
+	return nil, errors.New("audit: syslog is not supported on this platform")
+}
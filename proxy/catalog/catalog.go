@@ -0,0 +1,376 @@
+// Package catalog keeps the history of what MCP servers offer.
+//
+// Each time a server lists its tools, resources, or prompts and the
+// listing differs from the last one recorded, the Store keeps a
+// snapshot of it. Any two snapshots can then be compared (see Compare)
+// to see which entries a server added, removed, or modified, with
+// word-level diffs of changed descriptions.
+//
+// # Security Notes
+//
+// A third-party server can change what it offers between sessions: a
+// new tool, a broader schema, or a description that now carries
+// instructions to the model. The history lets a reviewer see exactly
+// what changed and when, rather than only that something did; tofu
+// decides whether a changed tool may be used at all.
+//
+// Entries are stored as the server sent them, before masking or
+// protocol shims, so descriptions in the history are untrusted text.
+//
+// # Thread Safety
+//
+// Store is safe for concurrent use.
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by the Store.
+var (
+	ErrInvalidStore = errors.New("catalog: invalid history file")
+	ErrUnknownKind  = errors.New("catalog: unknown catalog kind")
+	ErrNoSnapshot   = errors.New("catalog: no such snapshot")
+)
+
+// Catalog kinds, named after their list methods.
+const (
+	KindTools     = "tools"
+	KindResources = "resources"
+	KindPrompts   = "prompts"
+)
+
+// DefaultMaxSnapshots is the number of snapshots kept per server and
+// kind.
+const DefaultMaxSnapshots = 50
+
+// fileVersion is the history file format version.
+const fileVersion = 1
+
+// Config configures a Store.
+type Config struct {
+	// Path persists the history as JSON ("" keeps it in memory only)
+	Path string
+
+	// MaxSnapshots is how many snapshots are kept per server and kind;
+	// the oldest are dropped (zero uses DefaultMaxSnapshots)
+	MaxSnapshots int
+}
+
+// Snapshot is one recorded listing of a server's catalog.
+type Snapshot struct {
+	// ID orders snapshots across the whole store
+	ID     int       `json:"id"`
+	Server string    `json:"server"`
+	Kind   string    `json:"kind"`
+	Time   time.Time `json:"time"`
+
+	// Digest is a SHA-256 over the canonical entries
+	Digest string `json:"digest"`
+
+	// Entries are the listed definitions in canonical JSON, by tool or
+	// prompt name, or resource URI
+	Entries map[string]json.RawMessage `json:"entries"`
+}
+
+// Summary describes a snapshot without its entries.
+type Summary struct {
+	ID      int       `json:"id"`
+	Server  string    `json:"server"`
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Digest  string    `json:"digest"`
+	Entries int       `json:"entries"`
+}
+
+// file is the on-disk format of the history.
+type file struct {
+	Version   int         `json:"version"`
+	Snapshots []*Snapshot `json:"snapshots"`
+}
+
+// Store holds catalog snapshots.
+type Store struct {
+	path string
+	max  int
+
+	mu        sync.Mutex
+	snapshots []*Snapshot
+	nextID    int
+}
+
+// Open creates a Store, loading the persisted history.
+//
+// # Returns
+//   - The Store
+//   - ErrInvalidStore if the history file does not parse
+func Open(cfg *Config) (*Store, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	s := &Store{path: cfg.Path, max: cfg.MaxSnapshots, nextID: 1}
+	if s.max <= 0 {
+		s.max = DefaultMaxSnapshots
+	}
+	if s.path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("catalog: read %s: %w", s.path, err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, s.path)
+	}
+	s.snapshots = f
+	for _, snap := range f {
+		s.nextID = max(s.nextID, snap.ID+1)
+	}
+	return s, nil
+}
+
+// Parse decodes a history file, for reading one without opening a
+// Store over it.
+//
+// # Returns
+//   - The snapshots, oldest first
+//   - ErrInvalidStore if data is not a valid history
+func Parse(data []byte) ([]*Snapshot, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStore, err)
+	}
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrInvalidStore, f.Version, fileVersion)
+	}
+	for _, snap := range f.Snapshots {
+		if snap == nil || snap.ID <= 0 || snap.Server == "" || !validKind(snap.Kind) {
+			return nil, fmt.Errorf("%w: snapshot needs an id, server, and kind", ErrInvalidStore)
+		}
+	}
+	sort.SliceStable(f.Snapshots, func(i, j int) bool { return f.Snapshots[i].ID < f.Snapshots[j].ID })
+	return f.Snapshots, nil
+}
+
+// validKind reports whether kind is a catalog kind.
+func validKind(kind string) bool {
+	return kind == KindTools || kind == KindResources || kind == KindPrompts
+}
+
+// EntryKey returns the name a listed definition is keyed by: uri for
+// resources, name otherwise ("" if missing).
+func EntryKey(kind string, entry json.RawMessage) string {
+	var id struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	}
+	json.Unmarshal(entry, &id)
+	if kind == KindResources {
+		return id.URI
+	}
+	return id.Name
+}
+
+// Record stores a server's complete listing of one kind if it differs
+// from the last one recorded. Entries without a key (see EntryKey) are
+// skipped.
+//
+// # Arguments
+//   - server: Server identity, e.g. the initialize serverInfo name
+//   - kind: KindTools, KindResources, or KindPrompts
+//   - entries: The listed definitions, every page included
+//
+// # Returns
+//   - The new snapshot, or nil if the listing is unchanged
+//   - ErrUnknownKind, or an error saving the history; the snapshot is
+//     then kept in memory only
+func (s *Store) Record(server, kind string, entries []json.RawMessage) (*Snapshot, error) {
+	if !validKind(kind) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+	}
+	snap := &Snapshot{Server: server, Kind: kind, Entries: make(map[string]json.RawMessage, len(entries))}
+	for _, entry := range entries {
+		k := EntryKey(kind, entry)
+		if k == "" {
+			continue
+		}
+		canonical, err := canonicalize(entry)
+		if err != nil {
+			continue
+		}
+		snap.Entries[k] = canonical
+	}
+	snap.Digest = digest(snap.Entries)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last := s.latestLocked(server, kind); last != nil && last.Digest == snap.Digest {
+		return nil, nil
+	}
+	snap.ID, snap.Time = s.nextID, time.Now().UTC()
+	s.nextID++
+	s.snapshots = append(s.snapshots, snap)
+	s.pruneLocked(server, kind)
+	return snap, s.saveLocked()
+}
+
+// canonicalize re-encodes JSON with sorted keys and no insignificant
+// whitespace.
+func canonicalize(entry json.RawMessage) (json.RawMessage, error) {
+	var v interface{}
+	if err := json.Unmarshal(entry, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// digest hashes canonical entries in key order.
+func digest(entries map[string]json.RawMessage) string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%s%d:%s", len(k), k, len(entries[k]), entries[k])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// latestLocked returns the newest snapshot of a server's kind, or nil.
+// Caller must hold s.mu.
+func (s *Store) latestLocked(server, kind string) *Snapshot {
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		if snap := s.snapshots[i]; snap.Server == server && snap.Kind == kind {
+			return snap
+		}
+	}
+	return nil
+}
+
+// pruneLocked drops the oldest snapshots of a server's kind beyond the
+// limit. Caller must hold s.mu.
+func (s *Store) pruneLocked(server, kind string) {
+	n := 0
+	for _, snap := range s.snapshots {
+		if snap.Server == server && snap.Kind == kind {
+			n++
+		}
+	}
+	if n <= s.max {
+		return
+	}
+	drop := n - s.max
+	kept := s.snapshots[:0]
+	for _, snap := range s.snapshots {
+		if drop > 0 && snap.Server == server && snap.Kind == kind {
+			drop--
+			continue
+		}
+		kept = append(kept, snap)
+	}
+	clear(s.snapshots[len(kept):])
+	s.snapshots = kept
+}
+
+// Snapshots summarizes the recorded snapshots, oldest first. Empty
+// server or kind matches all.
+func (s *Store) Snapshots(server, kind string) []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Summarize(s.snapshots, server, kind)
+}
+
+// Summarize summarizes snapshots matching server and kind; empty
+// server or kind matches all.
+func Summarize(snapshots []*Snapshot, server, kind string) []Summary {
+	var out []Summary
+	for _, snap := range snapshots {
+		if (server == "" || snap.Server == server) && (kind == "" || snap.Kind == kind) {
+			out = append(out, Summary{
+				ID:      snap.ID,
+				Server:  snap.Server,
+				Kind:    snap.Kind,
+				Time:    snap.Time,
+				Digest:  snap.Digest,
+				Entries: len(snap.Entries),
+			})
+		}
+	}
+	return out
+}
+
+// Snapshot returns the snapshot with the given ID.
+//
+// # Returns
+//   - The snapshot; callers must not modify it
+//   - ErrNoSnapshot if there is none, e.g. because it was pruned
+func (s *Store) Snapshot(id int) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Find(s.snapshots, id)
+}
+
+// Find returns the snapshot with the given ID, or ErrNoSnapshot.
+func Find(snapshots []*Snapshot, id int) (*Snapshot, error) {
+	for _, snap := range snapshots {
+		if snap.ID == id {
+			return snap, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrNoSnapshot, id)
+}
+
+// Diff compares two snapshots by ID; see Compare.
+func (s *Store) Diff(from, to int) (*Diff, error) {
+	a, err := s.Snapshot(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.Snapshot(to)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(a, b), nil
+}
+
+// saveLocked writes the history atomically. Caller must hold s.mu.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(file{Version: fileVersion, Snapshots: s.snapshots}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("catalog: encode history: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".catalog-*")
+	if err != nil {
+		return fmt.Errorf("catalog: save history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("catalog: save history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("catalog: save history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("catalog: save history: %w", err)
+	}
+	return nil
+}
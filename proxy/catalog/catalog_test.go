@@ -0,0 +1,86 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tools(defs ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(defs))
+	for i, d := range defs {
+		out[i] = json.RawMessage(d)
+	}
+	return out
+}
+
+func TestStore_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	s, err := Open(&Config{Path: path, MaxSnapshots: 2})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	first, err := s.Record("fs", KindTools, tools(`{"name":"read","description":"Read a file"}`, `{"description":"no name"}`))
+	if err != nil || first == nil {
+		t.Fatalf("Record = %v, %v", first, err)
+	}
+	if len(first.Entries) != 1 || string(first.Entries["read"]) != `{"description":"Read a file","name":"read"}` {
+		t.Errorf("entries = %s", first.Entries)
+	}
+
+	// Reformatting is not a change
+	if snap, err := s.Record("fs", KindTools, tools(`{ "description": "Read a file", "name": "read" }`)); snap != nil || err != nil {
+		t.Errorf("unchanged listing recorded: %v, %v", snap, err)
+	}
+	// Nor is the same listing from another server
+	if snap, _ := s.Record("web", KindTools, tools(`{"name":"read","description":"Read a file"}`)); snap == nil {
+		t.Error("another server's listing not recorded")
+	}
+	s.Record("fs", KindTools, tools(`{"name":"read","description":"Read any file"}`))
+	s.Record("fs", KindTools, tools(`{"name":"read","description":"Read every file"}`))
+
+	if got := s.Snapshots("fs", KindTools); len(got) != 2 || got[0].ID != 3 || got[1].ID != 4 {
+		t.Errorf("fs snapshots after pruning = %+v", got)
+	}
+	if _, err := s.Snapshot(first.ID); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("pruned snapshot = %v, expected ErrNoSnapshot", err)
+	}
+	if _, err := s.Record("fs", "widgets", nil); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("unknown kind = %v, expected ErrUnknownKind", err)
+	}
+
+	// The history survives a restart and IDs keep increasing
+	s, err = Open(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := s.Snapshots("", ""); len(got) != 3 {
+		t.Errorf("reopened snapshots = %+v", got)
+	}
+	if snap, _ := s.Record("fs", KindPrompts, tools(`{"name":"summarize"}`)); snap == nil || snap.ID != 5 {
+		t.Errorf("snapshot after reopen = %+v", snap)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `{`},
+		{"wrong version", `{"version":2,"snapshots":[]}`},
+		{"missing kind", `{"version":1,"snapshots":[{"id":1,"server":"fs"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "catalog.json")
+			os.WriteFile(path, []byte(tt.data), 0o600)
+			if _, err := Open(&Config{Path: path}); !errors.Is(err, ErrInvalidStore) {
+				t.Errorf("Open = %v, expected ErrInvalidStore", err)
+			}
+		})
+	}
+}
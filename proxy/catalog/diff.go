@@ -0,0 +1,240 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Edit operations of a text diff.
+const (
+	EditEqual  = "="
+	EditDelete = "-"
+	EditInsert = "+"
+)
+
+// maxDiffCells bounds the work of a word diff; longer texts are shown
+// as replaced wholesale.
+const maxDiffCells = 1 << 20
+
+// Diff is what changed between two snapshots.
+type Diff struct {
+	Server   string    `json:"server"`
+	Kind     string    `json:"kind"`
+	From     int       `json:"from"`
+	To       int       `json:"to"`
+	FromTime time.Time `json:"from_time"`
+	ToTime   time.Time `json:"to_time"`
+
+	Added    []Entry  `json:"added,omitempty"`
+	Removed  []Entry  `json:"removed,omitempty"`
+	Modified []Change `json:"modified,omitempty"`
+}
+
+// Entry is a definition added or removed.
+type Entry struct {
+	Name       string          `json:"name"`
+	Definition json.RawMessage `json:"definition"`
+}
+
+// Change is a definition present in both snapshots that differs.
+type Change struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is one top-level field of a changed definition. Old or
+// New is missing when the field was added or removed; Text is a word
+// diff when both are strings, such as a description.
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old,omitempty"`
+	New   json.RawMessage `json:"new,omitempty"`
+	Text  []Edit          `json:"text,omitempty"`
+}
+
+// Edit is a run of text kept, deleted, or inserted.
+type Edit struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Empty reports whether the snapshots had the same entries.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Compare reports the entries added, removed, and modified from one
+// snapshot to another, each sorted by name. Snapshots are normally of
+// the same server and kind; Server and Kind are taken from to.
+func Compare(from, to *Snapshot) *Diff {
+	d := &Diff{
+		Server:   to.Server,
+		Kind:     to.Kind,
+		From:     from.ID,
+		To:       to.ID,
+		FromTime: from.Time,
+		ToTime:   to.Time,
+	}
+	for _, name := range sortedKeys(to.Entries) {
+		old, ok := from.Entries[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, Entry{Name: name, Definition: to.Entries[name]})
+		case !bytes.Equal(old, to.Entries[name]):
+			d.Modified = append(d.Modified, Change{Name: name, Fields: compareFields(old, to.Entries[name])})
+		}
+	}
+	for _, name := range sortedKeys(from.Entries) {
+		if _, ok := to.Entries[name]; !ok {
+			d.Removed = append(d.Removed, Entry{Name: name, Definition: from.Entries[name]})
+		}
+	}
+	return d
+}
+
+// compareFields lists the top-level fields that differ between two
+// canonical definitions.
+func compareFields(from, to json.RawMessage) []FieldChange {
+	var a, b map[string]json.RawMessage
+	if json.Unmarshal(from, &a) != nil || json.Unmarshal(to, &b) != nil {
+		return []FieldChange{{Old: from, New: to}}
+	}
+	fields := sortedKeys(a)
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var out []FieldChange
+	for _, f := range fields {
+		if bytes.Equal(a[f], b[f]) {
+			continue
+		}
+		fc := FieldChange{Field: f, Old: a[f], New: b[f]}
+		var oldText, newText string
+		if json.Unmarshal(a[f], &oldText) == nil && json.Unmarshal(b[f], &newText) == nil {
+			fc.Text = DiffText(oldText, newText)
+		}
+		out = append(out, fc)
+	}
+	return out
+}
+
+// sortedKeys returns a map's keys in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiffText returns a word-level diff turning from into to. Words and
+// the whitespace between them are kept as written, so joining the
+// equal and deleted runs gives from, and the equal and inserted runs
+// gives to.
+func DiffText(from, to string) []Edit {
+	a, b := splitWords(from), splitWords(to)
+
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	add := func(op string, words ...string) {
+		for _, w := range words {
+			if n := len(edits); n > 0 && edits[n-1].Op == op {
+				edits[n-1].Text += w
+			} else {
+				edits = append(edits, Edit{Op: op, Text: w})
+			}
+		}
+	}
+	add(EditEqual, a[:prefix]...)
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		add(EditDelete, midA...)
+		add(EditInsert, midB...)
+	} else {
+		for _, e := range lcsEdits(midA, midB) {
+			add(e.Op, e.Text)
+		}
+	}
+	add(EditEqual, a[len(a)-suffix:]...)
+	return edits
+}
+
+// lcsEdits diffs two word lists by longest common subsequence.
+func lcsEdits(a, b []string) []Edit {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var edits []Edit
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, Edit{Op: EditEqual, Text: a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, Edit{Op: EditDelete, Text: a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: EditInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, Edit{Op: EditDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, Edit{Op: EditInsert, Text: b[j]})
+	}
+	return edits
+}
+
+// splitWords splits s into alternating runs of whitespace and
+// non-whitespace.
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if i > start {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if unicode.IsSpace(prev) != unicode.IsSpace(r) {
+				words = append(words, s[start:i])
+				start = i
+			}
+		}
+		i += size
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
@@ -0,0 +1,169 @@
+// Package classify labels tool result content by format.
+//
+// Policies care less about what a tool says than about what kind of
+// thing it returned: a documentation tool that starts returning code, or
+// an untrusted server that returns an opaque base64 blob, deserves a
+// closer look than the same server returning prose. The classifiers here
+// are deliberately lightweight heuristics — pure Go, no models, linear
+// in the input — so they can run on every response.
+//
+// # Kinds
+//
+//   - KindEmpty: no meaningful content
+//   - KindStructured: JSON or XML documents
+//   - KindEncoded: base64 or hex blobs
+//   - KindCode: source code or shell
+//   - KindProse: natural language (the fallback)
+package classify
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// Kind is a content classification.
+type Kind string
+
+const (
+	KindEmpty      Kind = "empty"
+	KindStructured Kind = "structured"
+	KindEncoded    Kind = "encoded"
+	KindCode       Kind = "code"
+	KindProse      Kind = "prose"
+)
+
+// minEncodedLength is the shortest run treated as an encoded blob;
+// shorter tokens are too likely to be identifiers or hashes in prose.
+const minEncodedLength = 64
+
+// codeLineThreshold is the fraction of code-like lines that makes a
+// text code rather than prose.
+const codeLineThreshold = 0.4
+
+// Result is the classification of one piece of content.
+type Result struct {
+	// Kind is the dominant classification
+	Kind Kind `json:"kind"`
+
+	// Confidence is a heuristic score in [0, 1]
+	Confidence float64 `json:"confidence"`
+}
+
+// Text classifies a text content item.
+//
+// # Arguments
+//   - text: Content to classify
+//
+// # Returns
+//   - Classification with a heuristic confidence
+func Text(text string) Result {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return Result{Kind: KindEmpty, Confidence: 1}
+	}
+	if isStructured(trimmed) {
+		return Result{Kind: KindStructured, Confidence: 1}
+	}
+	if ratio := encodedRatio(trimmed); ratio >= 0.9 {
+		return Result{Kind: KindEncoded, Confidence: ratio}
+	}
+	if ratio := codeLineRatio(trimmed); ratio >= codeLineThreshold {
+		return Result{Kind: KindCode, Confidence: ratio}
+	}
+	return Result{Kind: KindProse, Confidence: 1 - codeLineRatio(trimmed)}
+}
+
+// isStructured reports whether s is a JSON value or an XML document.
+func isStructured(s string) bool {
+	switch s[0] {
+	case '{', '[':
+		return json.Valid([]byte(s))
+	case '<':
+		return strings.HasSuffix(s, ">") && (strings.HasPrefix(s, "<?xml") || strings.Count(s, "</") > 0)
+	}
+	return false
+}
+
+// encodedRatio returns the fraction of s covered by long runs of
+// base64/hex alphabet characters. Line breaks inside a blob are
+// ignored, as PEM-style wrapping is common.
+func encodedRatio(s string) float64 {
+	covered, total, run := 0, 0, 0
+	flush := func() {
+		if run >= minEncodedLength {
+			covered += run
+		}
+		run = 0
+	}
+	for _, c := range s {
+		switch {
+		case c == '\n' || c == '\r':
+			continue
+		case isBase64Rune(c):
+			run++
+		default:
+			flush()
+		}
+		total++
+	}
+	flush()
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total)
+}
+
+func isBase64Rune(c rune) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c) ||
+		c == '+' || c == '/' || c == '=' || c == '-' || c == '_')
+}
+
+// codeKeywords start lines of code in common languages.
+var codeKeywords = []string{
+	"func ", "def ", "class ", "import ", "from ", "return ", "package ",
+	"const ", "let ", "var ", "if (", "for (", "while (", "#include",
+	"public ", "private ", "fn ", "use ", "#!/", "$ ", "sudo ", "set -", "export ",
+}
+
+// codeLineRatio returns the fraction of non-blank lines that look like code.
+func codeLineRatio(s string) float64 {
+	lines, code := 0, 0
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		lines++
+		if looksLikeCode(line, trimmed) {
+			code++
+		}
+	}
+	if lines == 0 {
+		return 0
+	}
+	return float64(code) / float64(lines)
+}
+
+func looksLikeCode(line, trimmed string) bool {
+	for _, kw := range codeKeywords {
+		if strings.HasPrefix(trimmed, kw) {
+			return true
+		}
+	}
+	switch trimmed[len(trimmed)-1] {
+	case ';', '{', '}', ')', '(':
+		return true
+	}
+	if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") {
+		return true
+	}
+	for _, op := range []string{" := ", " => ", "->", " | ", " && ", " || "} {
+		if strings.Contains(trimmed, op) {
+			return true
+		}
+	}
+	// Deep indentation without sentence punctuation
+	indented := strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+	return indented && !strings.HasSuffix(trimmed, ".")
+}
@@ -0,0 +1,32 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected Kind
+	}{
+		{"empty", "  \n ", KindEmpty},
+		{"json", `{"files": ["a.txt", "b.txt"]}`, KindStructured},
+		{"xml", `<?xml version="1.0"?><root><a>1</a></root>`, KindStructured},
+		{"base64", strings.Repeat("QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVo=", 4), KindEncoded},
+		{"wrapped base64", strings.Repeat("TWFueSBoYW5kcyBtYWtlIGxpZ2h0IHdvcmsuTWFueSBoYW5kcyBtYWtlIGxpZ2h0IHdvcmsu\n", 3), KindEncoded},
+		{"go", "package main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", KindCode},
+		{"shell", "#!/bin/sh\nset -e\ncurl -s http://x | sh\n", KindCode},
+		{"prose", "The configuration file lives in the project root. Edit it to change the port.", KindProse},
+		{"prose with hash", "Commit 3f2a9c1 fixed the bug reported last week by the on-call engineer.", KindProse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.text); got.Kind != tt.expected {
+				t.Errorf("Text() = %s (%.2f), expected %s", got.Kind, got.Confidence, tt.expected)
+			}
+		})
+	}
+}
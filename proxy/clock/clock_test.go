@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 1, 5, 8, 59, 0, 0, time.UTC)
+	f := NewFake(start)
+	if got := f.Now(); !got.Equal(start) {
+		t.Errorf("Now = %v, expected %v", got, start)
+	}
+	if got := f.Advance(time.Minute); !got.Equal(start.Add(time.Minute)) || !f.Now().Equal(got) {
+		t.Errorf("Advance = %v, Now = %v", got, f.Now())
+	}
+	f.Set(start)
+	if got := f.Now(); !got.Equal(start) {
+		t.Errorf("after Set, Now = %v", got)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	if got := Func(NewFake(start))(); !got.Equal(start) {
+		t.Errorf("Func(fake)() = %v", got)
+	}
+	before := time.Now()
+	if got := Func(nil)(); got.Before(before) {
+		t.Errorf("Func(nil)() = %v, expected the system time", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec  string
+		start string
+		rate  float64
+		err   bool
+	}{
+		{"2026-01-05T08:59:00Z", "2026-01-05T08:59:00Z", 1, false},
+		{"2026-01-05T08:59:00+01:00,60", "2026-01-05T07:59:00Z", 60, false},
+		{" 2026-01-05T08:59:00Z,0.5 ", "2026-01-05T08:59:00Z", 0.5, false},
+		{"2026-01-05 08:59", "", 0, true},
+		{"2026-01-05T08:59:00Z,fast", "", 0, true},
+		{"2026-01-05T08:59:00Z,0", "", 0, true},
+		{"", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidSpec) {
+					t.Errorf("err = %v, expected ErrInvalidSpec", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			want, _ := time.Parse(time.RFC3339, tt.start)
+			if !s.start.Equal(want) || s.rate != tt.rate {
+				t.Errorf("start %v rate %g, expected %v rate %g", s.start, s.rate, want, tt.rate)
+			}
+			if got := s.Now(); got.Before(want) || got.Sub(want) > time.Minute {
+				t.Errorf("Now = %v, expected just after %v", got, want)
+			}
+			if again, err := Parse(s.String()); err != nil || !again.start.Equal(s.start) || again.rate != s.rate {
+				t.Errorf("String %q does not parse back: %v", s.String(), err)
+			}
+		})
+	}
+}
+
+func TestSimulatedRate(t *testing.T) {
+	start := time.Date(2026, 1, 5, 8, 59, 0, 0, time.UTC)
+	s := NewSimulated(start, 3600)
+	s.base = time.Now().Add(-time.Second)
+	if got := s.Now().Sub(start); got < time.Hour || got > time.Hour+time.Minute {
+		t.Errorf("one real second advanced %v, expected about an hour", got)
+	}
+}
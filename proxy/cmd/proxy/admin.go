@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/admin"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+)
+
+const sessionsUsage = `Usage:
+  mcp-sentinel-proxy --admin=ADDR sessions list
+  mcp-sentinel-proxy --admin=ADDR sessions inspect ID
+
+list prints the sessions of a running proxy: degradation level, gas
+used and budget, and whether each is paused, terminated, or degraded.
+inspect prints one session's health in full. ADDR is the proxy's
+--admin address, or set admin in --config.`
+
+const policyUsage = `Usage:
+  mcp-sentinel-proxy --admin=ADDR policy show
+  mcp-sentinel-proxy --admin=ADDR policy block-tool NAME [REASON]
+  mcp-sentinel-proxy --admin=ADDR policy unblock-tool NAME
+
+show prints the policy rules a running proxy enforces. block-tool adds
+a rule ahead of all others blocking calls to the tool NAME (a path.Match
+pattern) in every session, and unblock-tool removes it. The proxy must
+run with a policy engine (any policy.rules, or policy.default_action)
+and admin_token, which these commands send too: set
+MCP_SENTINEL_ADMIN_TOKEN, or admin_token in --config. The change lasts
+until the next reload or restart, so make it in the configuration file
+too to keep it.`
+
+// blockRulePrefix names the rules block-tool adds.
+const blockRulePrefix = "block-tool:"
+
+// adminClient calls the admin API of a running proxy.
+type adminClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+// newAdminClient returns a client for the admin address of cfg.
+func newAdminClient(cfg *config.Config) (*adminClient, error) {
+	addr := cfg.Admin
+	switch {
+	case addr == "":
+		return nil, withExit(ExitConfig, kindConfig, errors.New("no admin address: pass --admin=ADDR or set admin in --config"))
+	case strings.Contains(addr, "://"):
+	case strings.HasPrefix(addr, ":"):
+		addr = "http://127.0.0.1" + addr
+	default:
+		addr = "http://" + addr
+	}
+	return &adminClient{base: strings.TrimSuffix(addr, "/"), token: cfg.AdminToken, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// call sends a request with body encoded as JSON (nil sends none) and
+// decodes the JSON response into v.
+func (c *adminClient) call(method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, reader)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin api: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("admin api: %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("admin api: %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// runSessions runs a sessions subcommand against the admin API.
+func runSessions(args []string, cfg *config.Config, out io.Writer) error {
+	if len(args) == 0 || (args[0] == "list" && len(args) != 1) || (args[0] == "inspect" && len(args) != 2) {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", sessionsUsage))
+	}
+	c, err := newAdminClient(cfg)
+	if err != nil {
+		return err
+	}
+	var health admin.HealthResponse
+	switch args[0] {
+	case "list":
+		if err := c.call(http.MethodGet, "/healthz", nil, &health); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SESSION\tLEVEL\tGAS\tSTATE\tDEGRADED")
+		for _, s := range health.Sessions {
+			gas := fmt.Sprintf("%d", s.Gas.Used)
+			if s.Gas.Budget > 0 {
+				gas += fmt.Sprintf("/%d", s.Gas.Budget)
+			}
+			state := "active"
+			switch {
+			case s.Terminated:
+				state = "terminated"
+			case s.Paused:
+				state = "paused"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.SessionID, s.DegradationLevel, gas, state, strings.Join(s.ProtectionDegraded, ","))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d sessions, proxy %s\n", len(health.Sessions), health.Status)
+		return nil
+	case "inspect":
+		if err := c.call(http.MethodGet, "/healthz", nil, &health); err != nil {
+			return err
+		}
+		for _, s := range health.Sessions {
+			if s.SessionID == args[1] {
+				return printJSON(out, s)
+			}
+		}
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("no session %s", args[1]))
+	}
+	return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", sessionsUsage))
+}
+
+// runPolicy runs a policy subcommand against the admin API.
+func runPolicy(args []string, cfg *config.Config, out io.Writer) error {
+	switch {
+	case len(args) == 1 && args[0] == "show":
+	case len(args) >= 2 && len(args) <= 3 && args[0] == "block-tool":
+	case len(args) == 2 && args[0] == "unblock-tool":
+	default:
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", policyUsage))
+	}
+	c, err := newAdminClient(cfg)
+	if err != nil {
+		return err
+	}
+	var set policy.Set
+	if err := c.call(http.MethodGet, "/policy", nil, &set); err != nil {
+		return err
+	}
+	if args[0] == "show" {
+		return printJSON(out, set)
+	}
+
+	tool := args[1]
+	name := blockRulePrefix + tool
+	rules := make([]policy.Rule, 0, len(set.Rules)+1)
+	for _, r := range set.Rules {
+		if r.Name != name {
+			rules = append(rules, r)
+		}
+	}
+	if args[0] == "block-tool" {
+		reason := "tool " + tool + " blocked by an operator"
+		if len(args) == 3 {
+			reason = args[2]
+		}
+		rules = append([]policy.Rule{{Name: name, Methods: []string{"tools/call"}, Tools: []string{tool}, Action: policy.ActionBlock, Reason: reason}}, rules...)
+	} else if len(rules) == len(set.Rules) {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("tool %s is not blocked by block-tool", tool))
+	}
+	set.Rules = rules
+	if err := c.call(http.MethodPut, "/policy", set, &set); err != nil {
+		return err
+	}
+	verb := "blocked"
+	if args[0] == "unblock-tool" {
+		verb = "unblocked"
+	}
+	fmt.Fprintf(out, "%s %s; %d policy rules in effect\n", verb, tool, len(set.Rules))
+	return nil
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+)
+
+func TestSubcommands_UsageErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Admin = "127.0.0.1:1"
+	sessions := func(args []string) error { return runSessions(args, cfg, io.Discard) }
+	policy := func(args []string) error { return runPolicy(args, cfg, io.Discard) }
+	audit := func(args []string) error { return runAudit(args, io.Discard) }
+
+	tests := []struct {
+		name string
+		run  func([]string) error
+		args []string
+		want string
+	}{
+		{"sessions without a command", sessions, nil, "Usage:"},
+		{"sessions unknown command", sessions, []string{"kill"}, "Usage:"},
+		{"sessions list with an argument", sessions, []string{"list", "s1"}, "Usage:"},
+		{"sessions inspect without an ID", sessions, []string{"inspect"}, "Usage:"},
+		{"policy without a command", policy, nil, "Usage:"},
+		{"policy show with an argument", policy, []string{"show", "x"}, "Usage:"},
+		{"block-tool without a name", policy, []string{"block-tool"}, "Usage:"},
+		{"block-tool with extra arguments", policy, []string{"block-tool", "shell", "reason", "x"}, "Usage:"},
+		{"unblock-tool without a name", policy, []string{"unblock-tool"}, "Usage:"},
+		{"audit without a command", audit, nil, "Usage:"},
+		{"audit unknown command", audit, []string{"grep"}, "Usage:"},
+		{"audit tail without a file", audit, []string{"tail"}, "Usage:"},
+		{"audit tail negative count", audit, []string{"tail", "-n=-1", "trail.jsonl"}, "Usage:"},
+		{"audit tail unknown flag", audit, []string{"tail", "-x", "trail.jsonl"}, "not defined"},
+		{"audit tail missing file", audit, []string{"tail", filepath.Join(t.TempDir(), "none.jsonl")}, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(tt.args)
+			var ee *exitError
+			if !errors.As(err, &ee) || ee.code != ExitConfig {
+				t.Fatalf("err = %v, expected exit code %d", err, ExitConfig)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, expected it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	// Valid commands need an admin address
+	cfg.Admin = ""
+	for _, args := range [][]string{{"list"}, {"show"}} {
+		run := sessions
+		if args[0] == "show" {
+			run = policy
+		}
+		var ee *exitError
+		if err := run(args); !errors.As(err, &ee) || ee.code != ExitConfig || !strings.Contains(err.Error(), "no admin address") {
+			t.Errorf("%s without --admin = %v, expected a config error", args[0], err)
+		}
+	}
+}
+
+// fakeAdmin serves the admin endpoints the subcommands call: /healthz
+// with two sessions, and a /policy that keeps what is PUT.
+func fakeAdmin(t *testing.T) *config.Config {
+	t.Helper()
+	policySet := []byte(`{"rules":[{"name":"no-shell","tools":["shell"],"action":"block"}]}`)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"ok","sessions":[
+			{"session_id":"s1","degradation_level":"full","gas":{"used":3,"budget":10}},
+			{"session_id":"s2","degradation_level":"full","paused":true}]}`)
+	})
+	mux.HandleFunc("/policy", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPut {
+			policySet, _ = io.ReadAll(r.Body)
+		}
+		w.Write(policySet)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cfg := config.Default()
+	cfg.Admin = srv.URL
+	cfg.AdminToken = "secret"
+	return cfg
+}
+
+func TestSubcommands_AdminAPI(t *testing.T) {
+	cfg := fakeAdmin(t)
+	sessions := func(args []string, out io.Writer) error { return runSessions(args, cfg, out) }
+	policy := func(args []string, out io.Writer) error { return runPolicy(args, cfg, out) }
+
+	// The steps run in order: each policy change sees the last one
+	tests := []struct {
+		name string
+		run  func([]string, io.Writer) error
+		args []string
+		want []string
+		err  string
+	}{
+		{"sessions list", sessions, []string{"list"}, []string{"SESSION", "s1", "3/10", "active", "s2", "paused", "2 sessions, proxy ok"}, ""},
+		{"sessions inspect", sessions, []string{"inspect", "s2"}, []string{`"session_id": "s2"`, `"paused": true`}, ""},
+		{"sessions inspect unknown", sessions, []string{"inspect", "s9"}, nil, "no session s9"},
+		{"block-tool", policy, []string{"block-tool", "rm_*", "no deletes"}, []string{"blocked rm_*; 2 policy rules in effect"}, ""},
+		{"block-tool again replaces", policy, []string{"block-tool", "rm_*"}, []string{"blocked rm_*; 2 policy rules in effect"}, ""},
+		{"policy show", policy, []string{"show"}, []string{`"name": "block-tool:rm_*"`, `"reason": "tool rm_* blocked by an operator"`, `"no-shell"`}, ""},
+		{"unblock-tool", policy, []string{"unblock-tool", "rm_*"}, []string{"unblocked rm_*; 1 policy rules in effect"}, ""},
+		{"unblock-tool not blocked", policy, []string{"unblock-tool", "rm_*"}, nil, "is not blocked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := tt.run(tt.args, &out)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v failed: %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output lacks %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	// A refused request is reported with its status
+	cfg.AdminToken = "wrong"
+	if err := runPolicy([]string{"show"}, cfg, io.Discard); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("policy show with a wrong token = %v, expected a 401", err)
+	}
+}
+
+func TestAuditTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	trail := `{"time":"2026-01-02T03:04:05Z","session":"s1","decision":"allow","method":"tools/list"}
+not a record
+{"time":"2026-01-02T03:04:06Z","session":"s1","decision":"block","method":"tools/call","tool":"shell","reason":"denied"}
+`
+	if err := os.WriteFile(path, []byte(trail), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{path}, "2026-01-02T03:04:05Z  s1  allow     tools/list\nnot a record\n2026-01-02T03:04:06Z  s1  block     tools/call shell  \"denied\"\n"},
+		{[]string{"-n=1", path}, "2026-01-02T03:04:06Z  s1  block     tools/call shell  \"denied\"\n"},
+		{[]string{"-n=0", path}, ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := runAudit(append([]string{"tail"}, tt.args...), &out); err != nil {
+			t.Fatalf("audit tail %v failed: %v", tt.args, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("audit tail %v =\n%s\nexpected\n%s", tt.args, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+)
+
+const attestUsage = `Usage:
+  mcp-sentinel-proxy [--config=FILE] attest [--nonce=NONCE] [-- cmd args]
+  mcp-sentinel-proxy attest keygen
+  mcp-sentinel-proxy attest verify --public-key=FILE [--nonce=NONCE] [--approved=FILE] ATTESTATION
+
+Without a subcommand, attest prints a statement of this binary and the
+configuration it would run with, given the same flags and server
+command, signed with attestation.key. A running proxy serves its own at
+GET /attestation on the admin port.
+
+keygen prints a key pair: the private key for attestation.key (best set
+as MCP_SENTINEL_ATTESTATION_KEY), and the public key for verifiers.
+
+verify checks the signature of an attestation file (- reads stdin)
+with the public key in --public-key and prints its statement. --nonce
+requires the statement to carry that challenge; --approved checks it
+against a JSON file of approved SHA-256 digests:
+{"binaries": [...], "ffi": [...], "policies": {"policy": [...]}}.`
+
+// sentinelLibrary is part of the Rust sentinel library's file name.
+const sentinelLibrary = "sentinel_ffi"
+
+// runAttest runs an attest subcommand. load reads the configuration the
+// proxy would run with, given a server command.
+func runAttest(args []string, load func(command []string) (*config.Config, error), out io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "keygen":
+			public, private, err := attest.GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "public:  %s\nprivate: %s\n", public, private)
+			return nil
+		case "verify":
+			return runAttestVerify(args[1:], out)
+		}
+	}
+
+	fs := flag.NewFlagSet("attest", flag.ContinueOnError)
+	nonce := fs.String("nonce", "", "Challenge to include in the statement")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	cfg, err := load(fs.Args())
+	if err != nil {
+		return err
+	}
+	key, err := cfg.Attestation.SigningKey()
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if key == nil {
+		return withExit(ExitConfig, kindConfig, errors.New("attestation.key is not set; see attest keygen"))
+	}
+	a, err := newAttester(key, func() map[string]string { return policyDigests(cfg, nil) })
+	if err != nil {
+		return err
+	}
+	signed, err := a.Attest(*nonce)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	return printJSON(out, signed)
+}
+
+// runAttestVerify checks an attestation and prints its statement.
+func runAttestVerify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("attest verify", flag.ContinueOnError)
+	keyFile := fs.String("public-key", "", "File holding the base64 public key from attest keygen")
+	nonce := fs.String("nonce", "", "Challenge the statement must carry")
+	approvedFile := fs.String("approved", "", "JSON file of approved digests")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() != 1 || *keyFile == "" {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", attestUsage))
+	}
+	text, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	key, err := attest.ParsePublicKey(string(text))
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	var approved attest.Approved
+	if *approvedFile != "" {
+		data, err := os.ReadFile(*approvedFile)
+		if err != nil {
+			return withExit(ExitConfig, kindConfig, err)
+		}
+		if err := json.Unmarshal(data, &approved); err != nil {
+			return withExit(ExitConfig, kindConfig, fmt.Errorf("%s: %w", *approvedFile, err))
+		}
+	}
+
+	var data []byte
+	if path := fs.Arg(0); path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	var signed attest.Attestation
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("%w: %v", attest.ErrStatement, err)
+	}
+	stmt, err := attest.Verify(&signed, key, *nonce)
+	if err != nil {
+		return err
+	}
+	if err := approved.Check(stmt); err != nil {
+		return err
+	}
+	return printJSON(out, stmt)
+}
+
+// newAttester creates an Attester for this binary and its features.
+func newAttester(key ed25519.PrivateKey, policies func() map[string]string) (*attest.Attester, error) {
+	built := make(map[string]bool)
+	for _, f := range features() {
+		built[f.name] = f.enabled
+	}
+	return attest.New(&attest.Config{
+		Key:       key,
+		Version:   Version,
+		BuildTime: BuildTime,
+		Features:  built,
+		Policies:  policies,
+		Library:   sentinelLibrary,
+	})
+}
+
+// policyDigests returns the digests a statement attests: the
+// configuration with its secrets redacted, and the policy rules in
+// effect, which rules holds when the admin API may have replaced them.
+func policyDigests(cfg *config.Config, rules *policy.Engine) map[string]string {
+	digests := map[string]string{"config": attest.DigestJSON(cfg.Redact())}
+	if rules != nil {
+		digests["policy"] = attest.DigestJSON(rules.Set())
+	} else if set := cfg.Policy.Set(); set != nil {
+		digests["policy"] = attest.DigestJSON(set)
+	}
+	return digests
+}
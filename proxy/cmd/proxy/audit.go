@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+)
+
+const auditUsage = `Usage:
+  mcp-sentinel-proxy audit verify [--from=SEQ:HASH] FILE...
+  mcp-sentinel-proxy audit keygen
+  mcp-sentinel-proxy audit decrypt --key-file=FILE FILE...
+  mcp-sentinel-proxy audit tail [-n=N] [-f] FILE
+
+verify checks the hash chain of an audit trail written with
+audit.chain enabled. Give rotated files oldest first, e.g.
+audit.jsonl.2 audit.jsonl.1 audit.jsonl. --from anchors the chain at a
+link recorded elsewhere; without it the first record is trusted.
+
+keygen prints a key pair for audit.encrypt_fields: the public key for
+audit.encryption_key, and the private key to keep away from the proxy.
+decrypt prints the records of a trail with their encrypted fields
+restored using the private key in --key-file. Verify the original
+files: decrypted records no longer match the hash chain.
+
+tail prints the last N records of a trail (default 10) one per line:
+time, session, decision, method, tool, and reason. -f keeps printing
+records as they are written, across rotations, until interrupted.`
+
+// runAudit runs an audit subcommand.
+func runAudit(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", auditUsage))
+	}
+	switch args[0] {
+	case "verify":
+		return runAuditVerify(args[1:], out)
+	case "keygen":
+		public, private, err := audit.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "public:  %s\nprivate: %s\n", public, private)
+		return nil
+	case "decrypt":
+		return runAuditDecrypt(args[1:], out)
+	case "tail":
+		return runAuditTail(args[1:], out)
+	}
+	return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", auditUsage))
+}
+
+// runAuditVerify checks the hash chain of trail files.
+func runAuditVerify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	from := fs.String("from", "", "Link the chain must continue, as SEQ:HASH")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() == 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", auditUsage))
+	}
+	anchor, err := parseLink(*from)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+
+	v := audit.NewVerifier(anchor)
+	for _, path := range fs.Args() {
+		if err := v.VerifyFile(path); err != nil {
+			return err
+		}
+	}
+	last := v.Last()
+	fmt.Fprintf(out, "ok: %d records verified, last link %d:%s\n", v.Records(), last.Seq, last.Hash)
+	return nil
+}
+
+// runAuditDecrypt prints trail files with their encrypted fields
+// restored.
+func runAuditDecrypt(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("audit decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "File holding the base64 private key from audit keygen")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() == 0 || *keyFile == "" {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", auditUsage))
+	}
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	dec, err := audit.NewDecryptor(string(key))
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+
+	enc := json.NewEncoder(out)
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for n := 1; scanner.Scan(); n++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var rec audit.Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				f.Close()
+				return fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			if err := dec.Decrypt(&rec); err != nil {
+				f.Close()
+				return fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			enc.Encode(&rec)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// tailPoll is how often audit tail -f checks the trail for records.
+const tailPoll = 500 * time.Millisecond
+
+// runAuditTail prints the last records of a trail file, and with -f
+// follows it.
+func runAuditTail(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("audit tail", flag.ContinueOnError)
+	n := fs.Int("n", 10, "Number of records to print")
+	follow := fs.Bool("f", false, "Keep printing records as they are written")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() != 1 || *n < 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", auditUsage))
+	}
+	path := fs.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	defer func() { f.Close() }()
+
+	// Keep the last n lines; a partial last line waits for its end
+	reader := bufio.NewReaderSize(f, 64<<10)
+	var last []string
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			partial = line
+			break
+		}
+		if last = append(last, line); len(last) > *n {
+			last = last[1:]
+		}
+	}
+	for _, line := range last {
+		printRecord(out, line)
+	}
+	if !*follow {
+		return nil
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		partial += line
+		if err == nil {
+			printRecord(out, partial)
+			partial = ""
+			continue
+		}
+		time.Sleep(tailPoll)
+		// The sink renames a full file and starts a new one
+		current, statErr := os.Stat(path)
+		opened, openErr := f.Stat()
+		if statErr == nil && openErr == nil && !os.SameFile(current, opened) {
+			// Finish the rotated file before switching
+			if rest, _ := io.ReadAll(reader); len(rest) > 0 {
+				for _, line := range strings.SplitAfter(partial+string(rest), "\n") {
+					printRecord(out, line)
+				}
+			}
+			next, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, reader, partial = next, bufio.NewReaderSize(next, 64<<10), ""
+		}
+	}
+}
+
+// printRecord prints one line of a trail as a summary, or as it is if
+// it is not a record.
+func printRecord(out io.Writer, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	var rec audit.Record
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		fmt.Fprintln(out, line)
+		return
+	}
+	fmt.Fprintf(out, "%s  %s  %-8s  %s", rec.Time.Format(time.RFC3339), rec.Session, rec.Decision, rec.Method)
+	if rec.Tool != "" {
+		fmt.Fprintf(out, " %s", rec.Tool)
+	}
+	if rec.Reason != "" {
+		fmt.Fprintf(out, "  %q", rec.Reason)
+	}
+	fmt.Fprintln(out)
+}
+
+// parseLink parses SEQ:HASH; empty text is the zero Link.
+func parseLink(text string) (audit.Link, error) {
+	if text == "" {
+		return audit.Link{}, nil
+	}
+	seq, hash, ok := strings.Cut(text, ":")
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if !ok || err != nil || n == 0 || len(hash) != 64 {
+		return audit.Link{}, fmt.Errorf("--from must be SEQ:HASH with a hex SHA-256, got %q", text)
+	}
+	return audit.Link{Seq: n, Hash: strings.ToLower(hash)}, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+)
+
+const catalogUsage = `Usage:
+  mcp-sentinel-proxy catalog list [--server=NAME] [--kind=KIND] FILE
+  mcp-sentinel-proxy catalog diff FILE FROM [TO]
+
+FILE is the history written with --catalog-history. list prints its
+snapshots; KIND is tools, resources, or prompts. diff prints the
+entries added (+), removed (-), and modified (~) from snapshot FROM to
+snapshot TO, by default the latest of the same server and kind.
+Changed text is shown as [-removed-]{+inserted+}.`
+
+// runCatalog runs a catalog subcommand.
+func runCatalog(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", catalogUsage))
+	}
+	switch args[0] {
+	case "list":
+		return runCatalogList(args[1:], out)
+	case "diff":
+		return runCatalogDiff(args[1:], out)
+	}
+	return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", catalogUsage))
+}
+
+// readCatalog reads a history file.
+func readCatalog(path string) ([]*catalog.Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	snapshots, err := catalog.Parse(data)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return snapshots, nil
+}
+
+// runCatalogList prints the snapshots of a history file.
+func runCatalogList(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("catalog list", flag.ContinueOnError)
+	server := fs.String("server", "", "Only snapshots of this server")
+	kind := fs.String("kind", "", "Only snapshots of this kind: tools, resources, or prompts")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() != 1 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", catalogUsage))
+	}
+	snapshots, err := readCatalog(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, s := range catalog.Summarize(snapshots, *server, *kind) {
+		fmt.Fprintf(out, "%5d  %s  %-10s %-9s %3d entries  %s\n", s.ID, s.Time.Format("2006-01-02 15:04:05"), s.Server, s.Kind, s.Entries, s.Digest)
+	}
+	return nil
+}
+
+// runCatalogDiff prints what changed between two snapshots.
+func runCatalogDiff(args []string, out io.Writer) error {
+	if len(args) < 2 || len(args) > 3 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", catalogUsage))
+	}
+	snapshots, err := readCatalog(args[0])
+	if err != nil {
+		return err
+	}
+	ids := make([]int, len(args)-1)
+	for i, arg := range args[1:] {
+		if ids[i], err = strconv.Atoi(arg); err != nil {
+			return withExit(ExitConfig, kindConfig, fmt.Errorf("snapshot IDs must be numbers, got %q", arg))
+		}
+	}
+	from, err := catalog.Find(snapshots, ids[0])
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	var to *catalog.Snapshot
+	if len(ids) == 2 {
+		if to, err = catalog.Find(snapshots, ids[1]); err != nil {
+			return withExit(ExitConfig, kindConfig, err)
+		}
+	} else {
+		latest := catalog.Summarize(snapshots, from.Server, from.Kind)
+		to, _ = catalog.Find(snapshots, latest[len(latest)-1].ID)
+	}
+	writeCatalogDiff(out, catalog.Compare(from, to))
+	return nil
+}
+
+// writeCatalogDiff prints a diff for review.
+func writeCatalogDiff(out io.Writer, d *catalog.Diff) {
+	fmt.Fprintf(out, "%s %s: snapshot %d (%s) -> %d (%s)\n", d.Server, d.Kind,
+		d.From, d.FromTime.Format("2006-01-02 15:04:05"), d.To, d.ToTime.Format("2006-01-02 15:04:05"))
+	if d.Empty() {
+		fmt.Fprintln(out, "no changes")
+		return
+	}
+	for _, e := range d.Added {
+		fmt.Fprintf(out, "+ %s\n    %s\n", e.Name, e.Definition)
+	}
+	for _, e := range d.Removed {
+		fmt.Fprintf(out, "- %s\n", e.Name)
+	}
+	for _, c := range d.Modified {
+		fmt.Fprintf(out, "~ %s\n", c.Name)
+		for _, f := range c.Fields {
+			switch {
+			case f.Text != nil:
+				var b strings.Builder
+				for _, e := range f.Text {
+					switch e.Op {
+					case catalog.EditDelete:
+						b.WriteString("[-" + e.Text + "-]")
+					case catalog.EditInsert:
+						b.WriteString("{+" + e.Text + "+}")
+					default:
+						b.WriteString(e.Text)
+					}
+				}
+				fmt.Fprintf(out, "    %s: %s\n", f.Field, b.String())
+			case f.Old == nil:
+				fmt.Fprintf(out, "    %s added: %s\n", f.Field, f.New)
+			case f.New == nil:
+				fmt.Fprintf(out, "    %s removed: %s\n", f.Field, f.Old)
+			default:
+				fmt.Fprintf(out, "    %s: %s -> %s\n", f.Field, f.Old, f.New)
+			}
+		}
+	}
+}
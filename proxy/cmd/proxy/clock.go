@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+// hiddenFlags are accepted but left out of the usage message: they are
+// for rehearsing policies, not for running the proxy.
+var hiddenFlags = []string{"simulate-clock"}
+
+// printUsage prints the flags except hiddenFlags, with their defaults.
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	shown := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	shown.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(hiddenFlags, f.Name) {
+			return
+		}
+		shown.Var(f.Value, f.Name, f.Usage)
+		// Var takes the current value as the default
+		shown.Lookup(f.Name).DefValue = f.DefValue
+	})
+	shown.PrintDefaults()
+}
+
+// simulatedClock returns the clock --simulate-clock asks for, or nil for
+// the system clock.
+func simulatedClock(spec string) (clock.Clock, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	sim, err := clock.Parse(spec)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	log.Printf("audit: WARNING: simulated clock from %s: schedule windows, rate limits, the policy engine, and router caches follow it", sim)
+	return sim, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+)
+
+// loadConfig loads the --config file (Default without one) and its
+// environment overrides, then applies the flags given on the command
+// line, which win over both. command is the server command after --.
+func loadConfig(path string, command []string, upstreams upstreamFlags) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+
+	var upstreamURL string
+	flag.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		switch f.Name {
+		case "mode":
+			cfg.Mode = v
+		case "port":
+			cfg.Port, _ = strconv.Atoi(v)
+		case "admin":
+			cfg.Admin = v
+		case "error-format":
+			cfg.Logging.ErrorFormat = v
+		case "namespace-tools":
+			cfg.NamespaceTools, _ = strconv.ParseBool(v)
+		case "upstream-url":
+			upstreamURL = v
+		}
+	})
+
+	switch {
+	case len(upstreams) > 0 && (upstreamURL != "" || len(command) > 0):
+		return nil, withExit(ExitConfig, kindConfig, errors.New("--upstream cannot be combined with --upstream-url or a server command"))
+	case len(upstreams) > 0:
+		cfg.Upstreams = nil
+		for _, spec := range upstreams {
+			cfg.Upstreams = append(cfg.Upstreams, config.Upstream{Name: spec.name, URL: spec.url, Command: spec.command})
+		}
+	case upstreamURL != "":
+		cfg.Upstreams = []config.Upstream{{URL: upstreamURL}}
+	case len(command) > 0:
+		cfg.Upstreams = []config.Upstream{{Command: command}}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return cfg, nil
+}
+
+// setupLogging directs the log to the configured file. It runs before
+// the process is confined, while the path still resolves.
+func setupLogging(l config.Logging) error {
+	if l.UTC {
+		log.SetFlags(log.Flags() | log.LUTC)
+	}
+	if l.File == "" {
+		return nil
+	}
+	f, err := os.OpenFile(l.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	log.SetOutput(f)
+	return nil
+}
+
+// targetOf returns the upstream servers of cfg: a single unnamed
+// upstream is proxied directly, named ones through an upstream.Mux.
+func targetOf(cfg *config.Config) upstreamTarget {
+	target := upstreamTarget{namespace: cfg.NamespaceTools}
+	if len(cfg.Upstreams) == 1 && cfg.Upstreams[0].Name == "" {
+		target.url, target.command = cfg.Upstreams[0].URL, cfg.Upstreams[0].Command
+		target.oneshot = cfg.Upstreams[0].OneShot()
+		target.sandbox = cfg.Upstreams[0].Sandbox.Profile(cfg.Upstreams[0].Name)
+		return target
+	}
+	for _, u := range cfg.Upstreams {
+		target.multi = append(target.multi, upstreamSpec{name: u.Name, url: u.URL, command: u.Command, oneshot: u.OneShot(), sandbox: u.Sandbox.Profile(u.Name)})
+	}
+	return target
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// engineReloader reloads the process's sentinel engine with the ffi
+// settings of the running configuration and keeps attestation of the
+// loaded library current.
+type engineReloader struct {
+	client   *sentinel.Client
+	reloader *reload.Reloader
+	attester *attest.Attester
+}
+
+// Reload implements sentinel.Reloadable; an empty library loads
+// ffi.library.
+func (e *engineReloader) Reload(ctx context.Context, library string) (*sentinel.ReloadReport, error) {
+	ffi := e.reloader.Running().FFI
+	if library == "" {
+		library = ffi.Library
+	}
+	ctx, cancel := context.WithTimeout(ctx, ffi.Drain())
+	defer cancel()
+	report, err := e.client.Reload(ctx, library)
+	if err != nil {
+		log.Printf("audit: sentinel reload from %q failed, keeping the running engine: %v", library, err)
+		return nil, err
+	}
+	log.Printf("audit: sentinel reloaded from %q: envelope v%d, %d checks drained, checks paused %dms",
+		report.Library, report.ProtocolVersion, report.Drained, report.PauseMS)
+	if e.attester != nil && report.Library != "" {
+		if err := e.attester.SetLibrary(report.Library); err != nil {
+			log.Printf("attest: reloaded sentinel library not hashed; statements still name the previous one: %v", err)
+		}
+	}
+	return report, nil
+}
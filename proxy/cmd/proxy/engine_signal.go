@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchEngineReload reloads the engine on each SIGUSR2 until ctx ends.
+func watchEngineReload(ctx context.Context, e *engineReloader) {
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+	defer signal.Stop(usr2)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-usr2:
+			e.Reload(ctx, "")
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package main
+
+import "context"
+
+// watchEngineReload does nothing: there is no SIGUSR2 here, so engine
+// reloads go through the admin API only.
+func watchEngineReload(ctx context.Context, e *engineReloader) {}
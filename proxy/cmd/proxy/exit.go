@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Exit codes. Supervisors and installers may rely on these values; do
+// not renumber them.
+const (
+	// ExitOK is a clean shutdown (client disconnect or SIGINT/SIGTERM)
+	ExitOK = 0
+	// ExitFailure is any failure without a more specific code
+	ExitFailure = 1
+	// ExitConfig is an invalid flag or configuration value (the flag
+	// package also exits with 2 on a parse error)
+	ExitConfig = 2
+	// ExitBind is a listener that could not be bound
+	ExitBind = 3
+	// ExitFFI is a sentinel library that could not be loaded or shares
+	// no envelope version with the proxy
+	ExitFFI = 4
+	// ExitUpstream is an upstream server that could not be reached or
+	// started
+	ExitUpstream = 5
+	// ExitHardening is a failure to drop privileges or confine the
+	// process
+	ExitHardening = 6
+)
+
+// Failure kinds reported in machine-readable errors.
+const (
+	kindFailure   = "failure"
+	kindConfig    = "config"
+	kindBind      = "bind"
+	kindFFI       = "ffi"
+	kindUpstream  = "upstream"
+	kindHardening = "hardening"
+)
+
+// exitError is a fatal error carrying its exit code and kind.
+type exitError struct {
+	code int
+	kind string
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExit tags err with an exit code and kind.
+func withExit(code int, kind string, err error) error {
+	return &exitError{code: code, kind: kind, err: err}
+}
+
+// failureReport is the JSON object written to stderr for a fatal error
+// when --error-format=json is set.
+type failureReport struct {
+	Error   string    `json:"error"`
+	Kind    string    `json:"kind"`
+	Code    int       `json:"exit_code"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// jsonErrors selects machine-readable fatal errors (set from flags).
+var jsonErrors bool
+
+// fatal reports err and exits with its code. A message names the failed
+// step; an untagged err exits with ExitFailure.
+//
+// With --error-format=json the report is a single JSON object on one
+// line of stderr, with no log prefix, so it can be parsed directly.
+func fatal(msg string, err error) {
+	code, kind := ExitFailure, kindFailure
+	var ee *exitError
+	if errors.As(err, &ee) {
+		code, kind = ee.code, ee.kind
+	}
+	if !jsonErrors {
+		log.Printf("%s: %v", msg, err)
+		os.Exit(code)
+	}
+	data, _ := json.Marshal(failureReport{
+		Error:   fmt.Sprintf("%s: %v", msg, err),
+		Kind:    kind,
+		Code:    code,
+		Version: Version,
+		Time:    time.Now().UTC(),
+	})
+	fmt.Fprintln(os.Stderr, string(data))
+	os.Exit(code)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/admin"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// feature is an optional subsystem and whether this binary has it.
+type feature struct {
+	name    string
+	enabled bool
+}
+
+// features lists the optional subsystems chosen by build tags:
+// noadminui, nometrics, and norecorder leave out the admin UI, the
+// metrics endpoint, and reproduction bundles, and ffi links the Rust
+// sentinel in place of the stub.
+func features() []feature {
+	return []feature{
+		{"admin-ui", admin.UIEnabled},
+		{"metrics", admin.MetricsEnabled},
+		{"recorder", crash.ReproEnabled},
+		{"ffi", !sentinel.NewClient().Stub()},
+	}
+}
+
+// featureList formats features as "+name" or "-name", space separated.
+func featureList(list []feature) string {
+	parts := make([]string, len(list))
+	for i, f := range list {
+		sign := "-"
+		if f.enabled {
+			sign = "+"
+		}
+		parts[i] = sign + f.name
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+const fleetUsage = `Usage:
+  mcp-sentinel-proxy fleet sign --key=FILE MANIFEST
+  mcp-sentinel-proxy fleet verify --public-key=FILE SIGNED
+  mcp-sentinel-proxy fleet fingerprint FILE
+
+A fleet manifest pins, for a whole organization, the servers proxies
+may trust and the fingerprints of their tools; proxies started with
+--fleet-manifest and --fleet-key approve its pins without asking. Keys
+come from attest keygen.
+
+sign signs a manifest (- reads stdin) with the base64 private key in
+--key and prints the signed manifest to distribute. verify checks a
+signed manifest with the public key in --public-key and prints it.
+fingerprint prints the fingerprints to pin from a server's initialize
+result and tools/list result, or a JSON-RPC response carrying either.`
+
+// runFleet runs a fleet subcommand.
+func runFleet(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", fleetUsage))
+	}
+	switch args[0] {
+	case "sign":
+		return runFleetSign(args[1:], out)
+	case "verify":
+		return runFleetVerify(args[1:], out)
+	case "fingerprint":
+		return runFleetFingerprint(args[1:], out)
+	}
+	return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", fleetUsage))
+}
+
+// runFleetSign signs a fleet manifest.
+func runFleetSign(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("fleet sign", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "File holding the base64 private key from attest keygen")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() != 1 || *keyFile == "" {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", fleetUsage))
+	}
+	text, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	key, err := attest.ParsePrivateKey(string(text))
+	if err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var m tofu.FleetManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%w: %v", tofu.ErrFleetManifest, err)
+	}
+	if m.Type == "" {
+		m.Type = tofu.FleetManifestType
+	}
+	if m.Issued.IsZero() {
+		m.Issued = time.Now().UTC()
+	}
+	signed, err := tofu.SignFleetManifest(&m, key)
+	if err != nil {
+		return err
+	}
+	// Refuse to sign what proxies would refuse to load
+	if _, err := tofu.VerifyFleetManifest(signed, key.Public().(ed25519.PublicKey), time.Now()); err != nil {
+		return err
+	}
+	return printJSON(out, signed)
+}
+
+// runFleetVerify checks a signed fleet manifest and prints it.
+func runFleetVerify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("fleet verify", flag.ContinueOnError)
+	keyFile := fs.String("public-key", "", "File holding the base64 public key from attest keygen")
+	if err := fs.Parse(args); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if fs.NArg() != 1 || *keyFile == "" {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", fleetUsage))
+	}
+	m, err := readFleet(fs.Arg(0), *keyFile)
+	if err != nil {
+		return err
+	}
+	return printJSON(out, m)
+}
+
+// runFleetFingerprint prints the fingerprints of an initialize or
+// tools/list result.
+func runFleetFingerprint(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", fleetUsage))
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		return err
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(data, &result); err != nil {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	if inner, ok := result["result"]; ok {
+		// A JSON-RPC response
+		data = inner
+		result = nil
+		if err := json.Unmarshal(data, &result); err != nil {
+			return withExit(ExitConfig, kindConfig, err)
+		}
+	}
+
+	switch {
+	case result["serverInfo"] != nil:
+		fp, err := tofu.ServerFingerprint(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "server  %s\n", fp)
+	case result["tools"] != nil:
+		var tools []json.RawMessage
+		if err := json.Unmarshal(result["tools"], &tools); err != nil {
+			return withExit(ExitConfig, kindConfig, err)
+		}
+		for _, raw := range tools {
+			var tool struct {
+				Name string `json:"name"`
+			}
+			fp, err := tofu.Fingerprint(raw)
+			if err != nil || json.Unmarshal(raw, &tool) != nil {
+				return fmt.Errorf("%w: %s", tofu.ErrInvalidTool, strings.TrimSpace(string(raw)))
+			}
+			fmt.Fprintf(out, "tool    %s  %s\n", fp, tool.Name)
+		}
+	default:
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s holds neither an initialize nor a tools/list result", args[0]))
+	}
+	return nil
+}
+
+// readFleet reads the signed fleet manifest at path, verified with the
+// public key in keyFile.
+func readFleet(path, keyFile string) (*tofu.FleetManifest, error) {
+	if keyFile == "" {
+		return nil, withExit(ExitConfig, kindConfig, fmt.Errorf("%w: set --fleet-key", tofu.ErrFleetKey))
+	}
+	text, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	key, err := attest.ParsePublicKey(string(text))
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	m, err := tofu.ReadFleetManifest(path, key, time.Now())
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return m, nil
+}
+
+// readInput reads the file at path, or stdin for "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
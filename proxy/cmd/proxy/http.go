@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/admin"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+)
+
+// streamablePath is the MCP endpoint in streamable-http mode.
+const streamablePath = "/mcp"
+
+// shutdownTimeout bounds how long a stopping HTTP server waits for
+// requests in flight.
+const shutdownTimeout = 5 * time.Second
+
+// httpSessions serves Streamable HTTP clients. Each session gets its
+// own server transport, upstream connection, and router, so per-session
+// security state is kept apart just as for a stdio client.
+//
+// A POST without an Mcp-Session-Id starts a session; requests naming a
+// session go to its transport. A session ends when the client DELETEs
+// it, its upstream fails, or the proxy stops.
+type httpSessions struct {
+	target      upstreamTarget
+	client      *sentinel.Client
+	cfg         *router.Config
+	adminServer *admin.Server
+	reloader    *reload.Reloader
+	exporter    *usage.Exporter
+	reporter    *crash.Reporter
+
+	// newSessionID mints session IDs, e.g. ones that hash to this
+	// replica
+	newSessionID func() string
+
+	mu       sync.Mutex
+	ctx      context.Context
+	sessions map[string]*httpSession
+}
+
+// httpSession is one live Streamable HTTP session.
+type httpSession struct {
+	srv    *transport.StreamableHTTPServer
+	router *router.Router
+}
+
+// newHTTPSessions creates the session table; cfg is shared by every
+// session's router.
+func newHTTPSessions(target upstreamTarget, client *sentinel.Client, cfg *router.Config, adminServer *admin.Server, reloader *reload.Reloader, exporter *usage.Exporter, reporter *crash.Reporter) *httpSessions {
+	return &httpSessions{
+		target:      target,
+		client:      client,
+		cfg:         cfg,
+		adminServer: adminServer,
+		reloader:    reloader,
+		exporter:    exporter,
+		reporter:    reporter,
+		ctx:         context.Background(),
+		sessions:    make(map[string]*httpSession),
+
+		newSessionID: randomSessionID,
+	}
+}
+
+// Live reports whether a session is served here, for affinity.Config.Local.
+func (h *httpSessions) Live(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sessions[id] != nil
+}
+
+// ServeHTTP implements http.Handler for the MCP endpoint.
+func (h *httpSessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := r.Header.Get(transport.HeaderSessionID); id != "" {
+		h.mu.Lock()
+		s := h.sessions[id]
+		h.mu.Unlock()
+		if s == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		s.srv.ServeHTTP(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "missing "+transport.HeaderSessionID, http.StatusBadRequest)
+		return
+	}
+
+	s, err := h.start()
+	if err != nil {
+		log.Printf("Streamable HTTP session not started: %v", err)
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+	s.srv.ServeHTTP(w, r)
+	if s.srv.SessionID() == "" {
+		// Not an initialize request, so no session was created
+		s.srv.Close()
+	}
+}
+
+// start connects a new session's upstream and runs its router. The
+// session is listed once its transport accepts initialize.
+func (h *httpSessions) start() (*httpSession, error) {
+	upstream, cleanup, tools, err := h.target.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &httpSession{}
+	s.srv = transport.NewStreamableHTTPServerWithConfig(transport.StreamableServerConfig{
+		NewSessionID: func() string {
+			id := h.newSessionID()
+			h.mu.Lock()
+			h.sessions[id] = s
+			h.mu.Unlock()
+			return id
+		},
+	})
+	cfg := *h.cfg
+	cfg.UpstreamTools = tools
+	s.router = router.NewWithTransports(s.srv, upstream, h.client, &cfg)
+	watchReplays(upstream, s.router)
+
+	routerID := s.router.Health().SessionID
+	h.reloader.Register(s.router)
+	if h.exporter != nil {
+		h.exporter.Track(routerID, s.router.Usage)
+	}
+	if h.adminServer != nil {
+		h.adminServer.Register(s.router)
+	}
+
+	h.mu.Lock()
+	ctx := h.ctx
+	h.mu.Unlock()
+	h.reporter.Go(func() {
+		defer cleanup()
+		err := s.router.Run(ctx)
+		s.srv.Close()
+		if id := s.srv.SessionID(); id != "" {
+			h.mu.Lock()
+			delete(h.sessions, id)
+			h.mu.Unlock()
+		}
+		h.reloader.Unregister(routerID)
+		if h.exporter != nil {
+			h.exporter.Untrack(routerID)
+		}
+		if h.adminServer != nil {
+			h.adminServer.Unregister(routerID)
+		}
+		if err != nil && !errors.Is(err, transport.ErrClosed) && !errors.Is(err, context.Canceled) {
+			log.Printf("Streamable HTTP session %s ended: %v", routerID, err)
+		}
+	})
+	return s, nil
+}
+
+// closeAll ends every session.
+func (h *httpSessions) closeAll() {
+	h.mu.Lock()
+	sessions := make([]*httpSession, 0, len(h.sessions))
+	for _, s := range h.sessions {
+		sessions = append(sessions, s)
+	}
+	h.mu.Unlock()
+	for _, s := range sessions {
+		s.srv.Close()
+	}
+}
+
+// runStreamableHTTP serves the MCP endpoint on ln until SIGINT/SIGTERM
+// arrives or the listener fails. handler is h, possibly wrapped for
+// session affinity.
+func runStreamableHTTP(ln net.Listener, h *httpSessions, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	h.mu.Lock()
+	h.ctx = ctx
+	h.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.Handle(streamablePath, handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	h.reporter.Go(func() { served <- server.Serve(ln) })
+	log.Printf("Proxy ready - Streamable HTTP endpoint %s on %s", streamablePath, ln.Addr())
+
+	select {
+	case err := <-served:
+		h.closeAll()
+		return withExit(ExitBind, kindBind, err)
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down...")
+	h.closeAll()
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdown)
+	return nil
+}
+
+// randomSessionID returns a random 128-bit session ID.
+func randomSessionID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/affinity"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sessionstate"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/upstream"
+)
+
+// testSessions serves sessions whose upstream is a one-shot server
+// answering initialize and tools/list itself.
+func testSessions(t *testing.T) *httpSessions {
+	t.Helper()
+	target := upstreamTarget{oneshot: &upstream.OneShotConfig{Tools: []upstream.OneShotTool{{Name: "echo", Command: []string{"cat"}}}}}
+	reloader := reload.New(config.Default(), &reload.Config{Load: func() (*config.Config, error) { return config.Default(), nil }})
+	h := newHTTPSessions(target, sentinel.NewClient(), router.DefaultConfig(), nil, reloader, nil, crash.New(nil))
+	t.Cleanup(h.closeAll)
+	return h
+}
+
+// post sends one message to the MCP endpoint and returns the response
+// with its body read.
+func post(t *testing.T, url, session, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set(transport.HeaderSessionID, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestHTTPSessions(t *testing.T) {
+	h := testSessions(t)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, body := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	session := resp.Header.Get(transport.HeaderSessionID)
+	if resp.StatusCode != http.StatusOK || session == "" || !strings.Contains(body, `"serverInfo"`) {
+		t.Fatalf("initialize = %d, session %q: %s", resp.StatusCode, session, body)
+	}
+	if !h.Live(session) {
+		t.Fatalf("session %s is not live after initialize", session)
+	}
+
+	if resp, body := post(t, srv.URL, session, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); resp.StatusCode != http.StatusOK || !strings.Contains(body, `"echo"`) {
+		t.Errorf("tools/list = %d: %s", resp.StatusCode, body)
+	}
+	if resp, _ := post(t, srv.URL, "unknown", `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session = %d, expected 404", resp.StatusCode)
+	}
+	// A second initialize starts a separate session
+	resp, _ = post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	if other := resp.Header.Get(transport.HeaderSessionID); other == "" || other == session {
+		t.Errorf("second initialize got session %q, expected a new one", other)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	req.Header.Set(transport.HeaderSessionID, session)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE = %v, %v", resp, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Live(session) {
+		if time.Now().After(deadline) {
+			t.Fatal("deleted session still live")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHTTPSessions_Uninitialized(t *testing.T) {
+	h := testSessions(t)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	if resp, _ := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request before initialize = %d, expected 400", resp.StatusCode)
+	}
+	resp, err := http.Get(srv.URL)
+	if err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET without a session = %v, %v; expected 400", resp, err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.sessions) != 0 {
+		t.Errorf("sessions = %v, expected none", h.sessions)
+	}
+}
+
+func TestHTTPSessions_Affinity(t *testing.T) {
+	h := testSessions(t)
+	aff, err := affinity.New(&affinity.Config{
+		Self:     "r1",
+		Replicas: []affinity.Replica{{ID: "r1", URL: "http://r1:8080"}, {ID: "r2", URL: "http://r2:8080"}},
+		Local:    h.Live,
+	})
+	if err != nil {
+		t.Fatalf("affinity.New failed: %v", err)
+	}
+	h.newSessionID = aff.SessionID
+	srv := httptest.NewServer(aff.Handler(h))
+	defer srv.Close()
+
+	resp, _ := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	session := resp.Header.Get(transport.HeaderSessionID)
+	if session == "" || aff.Owner(session) != "r1" || resp.Header.Get(affinity.HeaderReplica) != "r1" {
+		t.Fatalf("initialize: session %q owned by %q, served by %q", session, aff.Owner(session), resp.Header.Get(affinity.HeaderReplica))
+	}
+
+	// A session owned by the other replica is redirected to it
+	foreign := "0"
+	for i := 0; aff.Owner(foreign) != "r2"; i++ {
+		foreign = strings.Repeat("f", i+1)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+	req.Header.Set(transport.HeaderSessionID, foreign)
+	resp, err = client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTemporaryRedirect || !strings.HasPrefix(resp.Header.Get("Location"), "http://r2:8080/") {
+		t.Errorf("foreign session = %v, %v; expected a redirect to r2", resp, err)
+	}
+}
+
+func TestHTTPSessions_Resumption(t *testing.T) {
+	h := testSessions(t)
+	store := sessionstate.NewMemoryStore()
+	h.cfg.StateStore, h.cfg.Resumption = store, true
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	initialize := func(token string) string {
+		t.Helper()
+		meta := ""
+		if token != "" {
+			meta = `,"_meta":{"` + router.MetaResumptionToken + `":"` + token + `"}`
+		}
+		_, body := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}`+meta+`}}`)
+		var resp struct {
+			Result struct {
+				Meta map[string]string `json:"_meta"`
+			} `json:"result"`
+		}
+		// The reply is one event-stream message
+		_, data, _ := strings.Cut(body, "data: ")
+		data, _, _ = strings.Cut(data, "\n")
+		json.Unmarshal([]byte(data), &resp)
+		return resp.Result.Meta[router.MetaResumptionToken]
+	}
+
+	first := initialize("")
+	if first == "" {
+		t.Fatal("initialize returned no resumption token")
+	}
+	// A new session presenting the token resumes it and gets the next one
+	second := initialize(first)
+	if second == "" || second == first {
+		t.Fatalf("resumed initialize returned token %q, expected a new one", second)
+	}
+	// The state moved to the new token, so the first is spent
+	if keys := store.Keys(); len(keys) != 1 {
+		t.Errorf("state keys = %v, expected only the resumed session's", keys)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// instancesEnv lists the instance IDs of the proxies that started this
+// one as a server command, outermost first. Server commands inherit it
+// with this proxy's ID appended.
+const instancesEnv = "MCP_SENTINEL_INSTANCES"
+
+// loopGuard returns the process's loop guard. It refuses to start when
+// maxHops proxies already started one another down to this one, which
+// happens when a server command starts the proxy again with the same
+// configuration: each process would otherwise start the next before any
+// request could reveal the loop.
+func loopGuard(maxHops int) (*router.LoopGuard, error) {
+	if maxHops <= 0 {
+		maxHops = router.DefaultMaxProxyHops
+	}
+	var ancestors []string
+	if v := os.Getenv(instancesEnv); v != "" {
+		ancestors = strings.Split(v, ",")
+	}
+	if len(ancestors) >= maxHops {
+		return nil, withExit(ExitConfig, kindConfig, fmt.Errorf("started by %d nested proxies (max_proxy_hops %d): a server command starts this proxy again", len(ancestors), maxHops))
+	}
+	guard := &router.LoopGuard{InstanceID: router.NewInstanceID(), MaxHops: maxHops}
+	if err := os.Setenv(instancesEnv, strings.Join(append(ancestors, guard.InstanceID), ",")); err != nil {
+		return nil, err
+	}
+	return guard, nil
+}
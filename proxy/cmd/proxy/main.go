@@ -6,16 +6,93 @@
 //
 // Usage:
 //
-//	mcp-sentinel-proxy                  # Start in stdio mode
-//	mcp-sentinel-proxy --mode=sse       # Start in SSE mode
-//	mcp-sentinel-proxy version          # Print version
+//	mcp-sentinel-proxy -- cmd args         # Stdio mode, proxying to a stdio server
+//	mcp-sentinel-proxy --upstream-url=URL  # Stdio mode, proxying to an SSE server
+//	mcp-sentinel-proxy --upstream=fs="fs-server /srv" --upstream=web=https://web.example/mcp
+//	                                       # Stdio mode, fronting several servers
+//	mcp-sentinel-proxy --config=proxy.yaml # Settings from a file (see package config)
+//	mcp-sentinel-proxy --mode=streamable-http --port=8080 -- cmd args
+//	                                       # Serve HTTP clients at /mcp, a server per session
+//	mcp-sentinel-proxy --mode=sse          # Start in SSE mode
+//	mcp-sentinel-proxy --mode=ws --upstream-url=wss://host/mcp
+//	                                       # Stdio mode, proxying to a WebSocket server
+//	mcp-sentinel-proxy --tofu-store=approvals.json -- cmd args
+//	                                       # Withhold tools until approved once
+//	mcp-sentinel-proxy --fleet-manifest=fleet.json --fleet-key=fleet.pub -- cmd args
+//	                                       # Start from organization-wide pins
+//	mcp-sentinel-proxy version             # Print version
+//	mcp-sentinel-proxy repl -- cmd args    # Interactive developer REPL
+//	mcp-sentinel-proxy audit verify audit.jsonl.1 audit.jsonl
+//	                                       # Check an audit trail's hash chain
+//	mcp-sentinel-proxy audit decrypt --key-file=audit.key audit.jsonl
+//	                                       # Read a trail's encrypted fields
+//	mcp-sentinel-proxy catalog diff catalog.json 3
+//	                                       # What a server changed since snapshot 3
+//	mcp-sentinel-proxy staging list /var/lib/mcp-sentinel/staging
+//	                                       # Writes a policy downgrade staged
+//	mcp-sentinel-proxy --admin=:9090 sessions list
+//	                                       # Sessions of a running proxy
+//	mcp-sentinel-proxy --admin=:9090 policy block-tool execute_command
+//	                                       # Block a tool in a running proxy
+//	mcp-sentinel-proxy audit tail -f /var/log/mcp-sentinel/audit.jsonl
+//	                                       # Follow an audit trail
+//	mcp-sentinel-proxy --config=proxy.yaml attest --nonce=N
+//	                                       # Signed statement of binary and policies
+//
+// Exit codes:
+//
+//	0  clean shutdown
+//	1  other failure
+//	2  invalid flags or configuration
+//	3  listener bind failure
+//	4  sentinel library load or version negotiation failure
+//	5  upstream server unreachable or failed to start
+//	6  privilege drop or confinement failure
+//
+// SIGHUP re-reads the configuration (see package reload); a broken
+// configuration is logged and the running one kept. SIGUSR2 reloads the
+// sentinel library from ffi.library, draining checks in flight; a
+// library that fails to load or preflight is logged and the running
+// one kept. With --chroot or
+// --user the --config path must still resolve and be readable after
+// confinement. The admin port serves a configuration UI at /ui/; with
+// admin_token set it can edit the --config file, which then has to be
+// writable too.
+//
+// With --error-format=json a fatal error is also written to stderr as a
+// single JSON object: {"error","kind","exit_code","version","time"}.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/admin"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/affinity"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/harden"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
 )
 
 // Version information set at build time.
@@ -24,33 +101,397 @@ var (
 	BuildTime = "development"
 )
 
+// envelopeVersion returns the FFI envelope version negotiated with the
+// sentinel library; the startup tests replace it to fail negotiation.
+var envelopeVersion = (*sentinel.Client).ProtocolVersion
+
 func main() {
 	// Parse flags
-	mode := flag.String("mode", "stdio", "Transport mode: stdio or sse")
-	port := flag.Int("port", 8080, "Port for SSE mode")
+	configPath := flag.String("config", "", "YAML or JSON configuration file; flags given on the command line override it")
+	// These override the configuration file and are read by loadConfig
+	flag.String("mode", "stdio", "Transport mode: stdio, streamable-http, sse, or ws")
+	flag.Int("port", 8080, "Port for the streamable-http and sse modes")
+	flag.String("upstream-url", "", "SSE base URL or ws:// / wss:// URL of the upstream MCP server (default: run the server command given after --)")
+	flag.String("admin", "", "Admin listen address for /healthz, /metrics, and /schema (empty disables)")
+	flag.Bool("namespace-tools", true, "With several --upstream servers, expose tools as NAME__tool")
+	var upstreams upstreamFlags
+	flag.Var(&upstreams, "upstream", "Upstream server NAME=URL or NAME=\"command args\"; repeat to front several servers")
+
+	failsafe := flag.String("failsafe", string(degrade.FailsafeBlockAll), "Degradation failsafe mode: block-all or allow-all")
+	crashDir := flag.String("crash-dir", "", "Directory for sanitized crash reports (empty disables)")
+	crashEndpoint := flag.String("crash-endpoint", "", "URL to POST sanitized crash reports to (empty disables)")
+	reproDir := flag.String("repro-dir", "", "Directory for sanitized reproduction bundles of server data that fails to parse or validate (empty disables)")
+	allowRoot := flag.Bool("allow-root", false, "Allow running with an effective UID of 0")
+	runAs := flag.String("user", "", "Drop privileges to user[:group] after binding ports")
+	chroot := flag.String("chroot", "", "Confine the process to this directory after binding ports")
+	workdir := flag.String("workdir", "", "Working directory after confinement")
+	umask := flag.String("umask", "", "File mode creation mask in octal, e.g. 0077 (empty keeps the current mask)")
+	errorFormat := flag.String("error-format", "text", "Fatal error format on stderr: text or json")
+	tofuStore := flag.String("tofu-store", "", "File persisting trust-on-first-use tool approvals; enables TOFU (empty disables)")
+	tofuManifest := flag.String("tofu-manifest", "", "File of pre-approved tool fingerprints for TOFU")
+	tofuPrompt := flag.Bool("tofu-prompt", false, "Ask on the terminal to approve new tool fingerprints")
+	fleetManifest := flag.String("fleet-manifest", "", "Signed fleet manifest of organization-wide tool pins and server trust levels for TOFU; enables TOFU")
+	fleetKey := flag.String("fleet-key", "", "File holding the base64 public key the fleet manifest is signed with")
+	tofuOnChange := flag.String("tofu-on-change", tofu.ChangeReapprove, "Changed definitions of approved tools: reapprove (prompt like a new tool) or block (approve only by fingerprint through the admin API)")
+	catalogHistory := flag.String("catalog-history", "", "File recording each change to the servers' tools, resources, and prompts listings (empty disables)")
+	simulateClock := flag.String("simulate-clock", "", "Run time-dependent policies on a simulated clock: START or START,RATE")
+	flag.Usage = printUsage
 	flag.Parse()
 
-	// Handle version command
-	if len(os.Args) > 1 && os.Args[1] == "version" {
+	jsonErrors = *errorFormat == "json"
+
+	// Handle subcommands
+	switch flag.Arg(0) {
+	case "version":
 		fmt.Printf("MCP Sentinel Proxy v%s\n", Version)
 		fmt.Printf("Build: %s\n", BuildTime)
+		fmt.Printf("Features: %s\n", featureList(features()))
+		return
+	case "repl":
+		if err := runREPL(flag.Args()[1:]); err != nil {
+			fatal("repl", err)
+		}
+		return
+	case "audit":
+		if err := runAudit(flag.Args()[1:], os.Stdout); err != nil {
+			fatal("audit", err)
+		}
+		return
+	case "catalog":
+		if err := runCatalog(flag.Args()[1:], os.Stdout); err != nil {
+			fatal("catalog", err)
+		}
+		return
+	case "staging":
+		if err := runStaging(flag.Args()[1:], os.Stdout); err != nil {
+			fatal("staging", err)
+		}
+		return
+	case "sessions", "policy":
+		cfg, err := loadConfig(*configPath, nil, upstreams)
+		if err != nil {
+			fatal(flag.Arg(0), err)
+		}
+		run := runSessions
+		if flag.Arg(0) == "policy" {
+			run = runPolicy
+		}
+		if err := run(flag.Args()[1:], cfg, os.Stdout); err != nil {
+			fatal(flag.Arg(0), err)
+		}
+		return
+	case "attest":
+		load := func(command []string) (*config.Config, error) { return loadConfig(*configPath, command, upstreams) }
+		if err := runAttest(flag.Args()[1:], load, os.Stdout); err != nil {
+			fatal("attest", err)
+		}
+		return
+	case "fleet":
+		if err := runFleet(flag.Args()[1:], os.Stdout); err != nil {
+			fatal("fleet", err)
+		}
 		return
 	}
 
+	cfg, err := loadConfig(*configPath, flag.Args(), upstreams)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	jsonErrors = cfg.Logging.ErrorFormat == "json"
+	if err := setupLogging(cfg.Logging); err != nil {
+		fatal("Cannot open log file", err)
+	}
+
+	reporter := crash.New(&crash.Config{Dir: *crashDir, Endpoint: *crashEndpoint})
+	reporter.SetVersion(Version)
+	defer reporter.Handle()
+
 	log.Printf("MCP Sentinel Proxy v%s starting...", Version)
-	log.Printf("Transport mode: %s", *mode)
+	log.Printf("Transport mode: %s", cfg.Mode)
+	clk, err := simulatedClock(*simulateClock)
+	if err != nil {
+		fatal("Invalid --simulate-clock", err)
+	}
+
+	ladderCfg := degrade.DefaultConfig()
+	ladderCfg.Failsafe = degrade.FailsafeMode(*failsafe)
+	ladder, err := degrade.New(ladderCfg)
+	if err != nil {
+		fatal("Invalid degradation ladder", withExit(ExitConfig, kindConfig, err))
+	}
+	ladder.OnTransition(func(t degrade.Transition) {
+		log.Printf("audit: degradation level %s -> %s (manual=%t): %s", t.From, t.To, t.Manual, t.Reason)
+	})
+
+	// Open files while their paths still resolve outside any chroot
+	var approvals *tofu.Store
+	if *tofuStore != "" || *tofuManifest != "" || *fleetManifest != "" || *tofuPrompt {
+		var fleet *tofu.FleetManifest
+		if *fleetManifest != "" {
+			if fleet, err = readFleet(*fleetManifest, *fleetKey); err != nil {
+				fatal("Invalid fleet manifest", err)
+			}
+			log.Printf("Fleet manifest of %s issued %s: %d servers", fleet.Issuer, fleet.Issued.Format(time.RFC3339), len(fleet.Servers))
+		}
+		if approvals, err = openTOFU(*tofuStore, *tofuManifest, *tofuOnChange, *tofuPrompt, fleet); err != nil {
+			fatal("Invalid TOFU configuration", err)
+		}
+		log.Printf("Trust-on-first-use enabled: %d tools approved", len(approvals.Approvals()))
+	}
+	var history *catalog.Store
+	if *catalogHistory != "" {
+		if history, err = catalog.Open(&catalog.Config{Path: *catalogHistory}); err != nil {
+			fatal("Invalid catalog history", withExit(ExitConfig, kindConfig, err))
+		}
+		log.Printf("Catalog history enabled: %d snapshots", len(history.Snapshots("", "")))
+	}
+	var monitor *slo.Monitor
+	if sloCfg := cfg.SLO.MonitorConfig(); sloCfg != nil {
+		if monitor, err = slo.New(sloCfg); err != nil {
+			fatal("Invalid SLO configuration", withExit(ExitConfig, kindConfig, err))
+		}
+		reporter.Go(func() { monitor.Run(context.Background()) })
+		log.Printf("Tracking %d service level objectives", len(sloCfg.Objectives))
+	}
+	var rules *policy.Engine
+	if set := cfg.Policy.Set(); set != nil {
+		if rules, err = policy.New(set); err != nil {
+			fatal("Invalid policy", withExit(ExitConfig, kindConfig, err))
+		}
+		rules.SetClock(clk)
+		log.Printf("Policy engine enabled: %d rules", len(set.Rules))
+	}
+	var limiter *ratelimit.Limiter
+	if lc := cfg.RateLimit.LimiterConfig(); lc != nil {
+		lc.Clock = clk
+		if limiter, err = ratelimit.New(lc); err != nil {
+			fatal("Invalid rate limits", withExit(ExitConfig, kindConfig, err))
+		}
+		log.Printf("Rate limiting enabled: %d tool limits", len(lc.Tools))
+	}
+	var sched *schedule.Scheduler
+	if sc := cfg.Schedule.SchedulerConfig(); sc != nil {
+		sc.Clock = clk
+		if sched, err = schedule.New(sc); err != nil {
+			fatal("Invalid schedule", withExit(ExitConfig, kindConfig, err))
+		}
+		log.Printf("Schedule enabled: %d rules in %s", len(sc.Rules), sc.Location)
+	}
+	reloader := reload.New(cfg, &reload.Config{
+		Load:   func() (*config.Config, error) { return loadConfig(*configPath, flag.Args(), upstreams) },
+		Policy: rules,
+	})
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	reporter.Go(func() { reloader.Run(context.Background(), hangup) })
+	tlsCfg, err := cfg.TLS.ClientConfig()
+	if err != nil {
+		fatal("Invalid TLS configuration", withExit(ExitConfig, kindConfig, err))
+	}
+	var auditStream *audit.Broadcaster
+	var streams []audit.Sink
+	if cfg.Audit.Stream {
+		auditStream = audit.NewBroadcaster()
+		streams = append(streams, auditStream)
+	}
+	auditSink, err := cfg.Audit.Open(streams...)
+	if err != nil {
+		fatal("Cannot open audit trail", withExit(ExitConfig, kindConfig, err))
+	}
+	if auditSink != nil {
+		defer auditSink.Close()
+		log.Println("Audit trail enabled")
+	}
+	tracer, err := cfg.Tracing.Tracer()
+	if err != nil {
+		fatal("Invalid tracing configuration", withExit(ExitConfig, kindConfig, err))
+	}
+	if tracer != nil {
+		defer tracer.Close()
+		log.Printf("Tracing to %s", cfg.Tracing.Endpoint)
+	}
+	usageExporter, err := cfg.Usage.Exporter()
+	if err != nil {
+		fatal("Invalid usage export configuration", withExit(ExitConfig, kindConfig, err))
+	}
+	if usageExporter != nil {
+		defer usageExporter.Close()
+		log.Printf("Usage records exported for tenant %q", cfg.Usage.Tenant)
+	}
+	redaction, err := cfg.Redaction.Middleware()
+	if err != nil {
+		fatal("Invalid redaction configuration", withExit(ExitConfig, kindConfig, err))
+	}
+	stateStore, err := cfg.SessionState.Open()
+	if err != nil {
+		fatal("Cannot open session state", withExit(ExitConfig, kindConfig, err))
+	}
+	// Hash the binary and sentinel library while their paths resolve
+	var attester *attest.Attester
+	if key, err := cfg.Attestation.SigningKey(); err != nil {
+		fatal("Invalid attestation key", withExit(ExitConfig, kindConfig, err))
+	} else if key != nil {
+		if attester, err = newAttester(key, func() map[string]string { return policyDigests(reloader.Running(), rules) }); err != nil {
+			fatal("Cannot attest the running binary", err)
+		}
+		log.Printf("Attestation enabled (key %s)", attester.KeyID())
+	}
+
+	// Bind listeners while still privileged
+	var adminServer *admin.Server
+	var adminListener net.Listener
+	if cfg.Admin != "" {
+		adminServer = admin.New(ladder)
+		adminListener, err = net.Listen("tcp", cfg.Admin)
+		if err != nil {
+			fatal("Admin listen failed", withExit(ExitBind, kindBind, err))
+		}
+	}
+	var mcpListener net.Listener
+	if cfg.Mode == "streamable-http" {
+		mcpListener, err = net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+		if err != nil {
+			fatal("Listen failed", withExit(ExitBind, kindBind, err))
+		}
+	}
 
-	switch *mode {
-	case "stdio":
-		log.Println("Starting stdio transport...")
-		// Future: Initialize StdioTransport and Router
-		log.Println("Proxy ready - reading from stdin")
+	hardening := harden.DefaultOptions()
+	hardening.AllowRoot = *allowRoot
+	hardening.User = *runAs
+	hardening.Chroot = *chroot
+	hardening.Workdir = *workdir
+	if *umask != "" {
+		if hardening.Umask, err = harden.ParseUmask(*umask); err != nil {
+			fatal("Invalid --umask", withExit(ExitConfig, kindConfig, err))
+		}
+	}
+	privs, err := harden.Apply(hardening)
+	if err != nil {
+		fatal(fmt.Sprintf("Hardening failed (%s)", privs), withExit(ExitHardening, kindHardening, err))
+	}
+	log.Printf("audit: privilege state: %s", privs)
+
+	if adminServer != nil {
+		adminServer.SetPrivileges(privs)
+		adminServer.SetTOFU(approvals)
+		adminServer.SetCatalog(history)
+		adminServer.SetSLO(monitor)
+		adminServer.SetPolicy(rules)
+		adminServer.SetSchedule(sched)
+		adminServer.SetReloader(reloader)
+		adminServer.SetAttester(attester)
+		adminServer.SetConfigFile(admin.ConfigFile{Path: *configPath, Token: cfg.AdminToken})
+		if auditStream != nil {
+			adminServer.SetAuditStream(auditStream)
+			log.Println("Audit stream served at /audit/stream")
+		}
+		reporter.Go(func() {
+			log.Printf("Admin endpoints listening on %s", adminListener.Addr())
+			if err := adminServer.Serve(adminListener); err != nil {
+				fatal("Admin server failed", err)
+			}
+		})
+	}
+
+	target := targetOf(cfg)
+	target.tls = tlsCfg
+	target.flush = cfg.Stdio.FlushPolicy()
+	routerCfg := cfg.RouterConfig()
+	routerCfg.TOFU = approvals
+	routerCfg.Catalog = history
+	routerCfg.SLO = monitor
+	routerCfg.Policy = rules
+	routerCfg.RateLimit = limiter
+	routerCfg.Schedule = sched
+	routerCfg.Audit = auditSink
+	routerCfg.Tracer = tracer
+	routerCfg.Clock = clk
+	guard, err := loopGuard(cfg.MaxProxyHops)
+	if err != nil {
+		fatal("Proxy loop", err)
+	}
+	routerCfg.LoopGuard = guard
+	log.Printf("Instance %s", guard.InstanceID)
+	if redaction != nil {
+		routerCfg.Middleware = middleware.New(redaction)
+		log.Printf("Secret redaction enabled (%s mode)", cfg.Redaction.Mode)
+	}
+	if stateStore != nil {
+		routerCfg.StateStore = stateStore
+		if routerCfg.Resumption {
+			log.Printf("Session state persisted (%s backend, resumable by token)", cfg.SessionState.Backend)
+		} else {
+			log.Printf("Session state persisted (%s backend, key %q)", cfg.SessionState.Backend, routerCfg.StateKey)
+		}
+	}
+	if *reproDir != "" && !crash.ReproEnabled {
+		log.Printf("Repro bundles unavailable: built with norecorder, --repro-dir ignored")
+	}
+	if repro := crash.NewReproRecorder(&crash.ReproConfig{Dir: *reproDir}); repro != nil {
+		repro.SetVersion(Version)
+		routerCfg.Repro = repro
+	}
+	if c := routerCfg.Chain; c != nil {
+		log.Printf("Sentinel chaining as %q (propagate=%t, trust upstream=%t)", c.ProxyID, c.Propagate, c.TrustUpstream)
+	}
+
+	client := sentinel.NewClientWithPool(cfg.FFI.Pool())
+	if envelopeVersion(client) == 0 {
+		fatal("Sentinel library failed", withExit(ExitFFI, kindFFI, errors.New("sentinel library shares no envelope version with the proxy")))
+	}
+	if client.Stub() {
+		log.Println("WARNING: built without the sentinel library: security checks pass without analysis; clients are told so")
+	}
+	if pool, ok := client.PoolStats(); ok {
+		log.Printf("Sentinel library: %d workers, call timeout %v", pool.Workers, cfg.FFI.CallTimeout)
+	}
+	engine := &engineReloader{client: client, reloader: reloader, attester: attester}
+	if adminServer != nil {
+		adminServer.SetSentinel(engine)
+	}
+	reporter.Go(func() { watchEngineReload(context.Background(), engine) })
+
+	switch cfg.Mode {
+	case "stdio", "ws":
+		if cfg.Mode == "ws" {
+			log.Printf("Starting stdio transport with WebSocket upstream %s...", target.url)
+		} else {
+			log.Println("Starting stdio transport...")
+		}
+		if err := runStdio(target, client, routerCfg, ladder, adminServer, reloader, usageExporter, reporter); err != nil {
+			fatal("Proxy failed", err)
+		}
+		log.Println("Proxy stopped")
+		return
+	case "streamable-http":
+		routerCfg.Degradation = ladder
+		sessions := newHTTPSessions(target, client, routerCfg, adminServer, reloader, usageExporter, reporter)
+		var handler http.Handler = sessions
+		if ac := cfg.Affinity.Config(); ac != nil {
+			// Sessions minted here hash to this replica; the handler
+			// routes the rest to their owners
+			ac.Local = sessions.Live
+			aff, err := affinity.New(ac)
+			if err != nil {
+				fatal("Invalid affinity configuration", withExit(ExitConfig, kindConfig, err))
+			}
+			sessions.newSessionID = aff.SessionID
+			handler = aff.Handler(sessions)
+			handoff := ac.Handoff
+			if handoff == "" {
+				handoff = affinity.HandoffRedirect
+			}
+			log.Printf("Session affinity: replica %s of %d (%s hand-off)", ac.Self, len(ac.Replicas), handoff)
+		}
+		if err := runStreamableHTTP(mcpListener, sessions, handler); err != nil {
+			fatal("Proxy failed", err)
+		}
+		log.Println("Proxy stopped")
+		return
 	case "sse":
-		log.Printf("Starting SSE transport on port %d...", *port)
+		log.Printf("Starting SSE transport on port %d...", cfg.Port)
 		// Future: Initialize SSETransport and Router
-		log.Printf("Proxy ready - listening on :%d", *port)
-	default:
-		log.Fatalf("Unknown transport mode: %s", *mode)
+		log.Printf("Proxy ready - listening on :%d", cfg.Port)
 	}
 
 	// Block forever (actual implementation will have event loop)
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// TestHelperProxy is not a real test: it is the proxy that the startup
+// tests launch by re-executing the test binary, with the command line
+// in PROXY_HELPER_ARGS.
+func TestHelperProxy(t *testing.T) {
+	if os.Getenv("PROXY_HELPER") != "1" {
+		return
+	}
+	var args []string
+	json.Unmarshal([]byte(os.Getenv("PROXY_HELPER_ARGS")), &args)
+	if os.Getenv("PROXY_HELPER_NO_ENVELOPE") == "1" {
+		envelopeVersion = func(*sentinel.Client) int { return 0 }
+	}
+	os.Args = append([]string{"mcp-sentinel-proxy"}, args...)
+	main()
+	os.Exit(0)
+}
+
+// runProxy runs the proxy with args and returns its exit code and
+// stderr.
+func runProxy(t *testing.T, env []string, args ...string) (int, string) {
+	t.Helper()
+	data, _ := json.Marshal(args)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProxy$")
+	cmd.Env = append(os.Environ(), append(env, "PROXY_HELPER=1", "PROXY_HELPER_ARGS="+string(data))...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("proxy did not run: %v", err)
+	}
+	return cmd.ProcessState.ExitCode(), stderr.String()
+}
+
+func TestStartupFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		code int
+		kind string
+	}{
+		{"invalid config", nil, []string{"--mode=bogus"}, ExitConfig, kindConfig},
+		{"upstream not started", nil, []string{"--allow-root", "--", "/nonexistent/mcp-server"}, ExitUpstream, kindUpstream},
+		{"no envelope version", []string{"PROXY_HELPER_NO_ENVELOPE=1"}, []string{"--allow-root", "--", "cat"}, ExitFFI, kindFFI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stderr := runProxy(t, tt.env, append([]string{"--error-format=json"}, tt.args...)...)
+			if code != tt.code {
+				t.Fatalf("exit code = %d, expected %d; stderr:\n%s", code, tt.code, stderr)
+			}
+
+			// The report is the last line, after any log output
+			lines := strings.Split(strings.TrimSpace(stderr), "\n")
+			last := lines[len(lines)-1]
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(last), &fields); err != nil {
+				t.Fatalf("last stderr line %q is not JSON: %v", last, err)
+			}
+			keys := make([]string, 0, len(fields))
+			for k := range fields {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			if want := []string{"error", "exit_code", "kind", "time", "version"}; !slices.Equal(keys, want) {
+				t.Errorf("report keys = %v, expected %v", keys, want)
+			}
+			var report failureReport
+			json.Unmarshal([]byte(last), &report)
+			if report.Code != tt.code || report.Kind != tt.kind || report.Error == "" || report.Version != Version {
+				t.Errorf("report = %+v, expected code %d kind %s", report, tt.code, tt.kind)
+			}
+			if time.Since(report.Time) > time.Minute || report.Time.Location() != time.UTC {
+				t.Errorf("report time = %v, expected now in UTC", report.Time)
+			}
+
+			// The text format exits with the same code
+			if code, _ := runProxy(t, tt.env, tt.args...); code != tt.code {
+				t.Errorf("text format exit code = %d, expected %d", code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// replTemplates are starting params for each MCP method.
+var replTemplates = map[string]string{
+	"initialize":                `{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"mcp-sentinel-repl","version":"` + Version + `"}}`,
+	"ping":                      `{}`,
+	"tools/list":                `{}`,
+	"tools/call":                `{"name":"","arguments":{}}`,
+	"resources/list":            `{}`,
+	"resources/read":            `{"uri":""}`,
+	"resources/subscribe":       `{"uri":""}`,
+	"prompts/list":              `{}`,
+	"prompts/get":               `{"name":"","arguments":{}}`,
+	"completion/complete":       `{"ref":{"type":"ref/prompt","name":""},"argument":{"name":"","value":""}}`,
+	"logging/setLevel":          `{"level":"info"}`,
+	"notifications/initialized": `{}`,
+}
+
+const replHelp = `Commands:
+  :help                     Show this help
+  :templates                List method templates
+  :t <method>               Print the params template for a method
+  :send <method> [params]   Send a request (params default to the template)
+  :notify <method> [params] Send a notification
+  :stats                    Show routing statistics
+  :quit                     Exit
+Any line starting with '{' is sent as a raw JSON-RPC message.
+Server notifications are printed as they arrive, marked <~.`
+
+// repl is an interactive workbench that sends messages through the
+// full security pipeline to a real upstream server.
+type repl struct {
+	router   *router.Router
+	upstream transport.Transport
+	out      io.Writer
+	nextID   int
+}
+
+// runREPL starts the developer REPL.
+//
+// The upstream is either an SSE server (--url) or a stdio server
+// command given after the flags.
+func runREPL(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	url := fs.String("url", "", "SSE base URL of the upstream MCP server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	upstream, cleanup, err := dialUpstream(*url, fs.Args(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	r := &repl{
+		router:   router.New(upstream, sentinel.NewClient()),
+		upstream: upstream,
+		out:      os.Stdout,
+		nextID:   1,
+	}
+	r.router.OnNotification(func(data []byte) {
+		fmt.Fprintf(r.out, "<~ %s\n", data)
+	})
+	watchReplays(upstream, r.router)
+	return r.loop(os.Stdin)
+}
+
+// watchReplays feeds replayed SSE events from upstream into the
+// router's anomaly score. Other transports are ignored.
+func watchReplays(upstream transport.Transport, r *router.Router) {
+	if sse, ok := upstream.(*transport.SSETransport); ok {
+		sse.OnReplay(func(rp transport.Replay) {
+			log.Printf("audit: %s", rp)
+			r.RecordAnomaly(anomaly.SignalReplay, rp.String())
+		})
+	}
+}
+
+// dialUpstream connects to the upstream server for the REPL and the
+// proxy: a WebSocket server for a ws:// or wss:// URL, an SSE server
+// for any other URL, and otherwise a spawned command. Errors carry
+// ExitConfig if no upstream was given and ExitUpstream if it could not
+// be reached or started. profile confines a spawned command (nil runs
+// it unconfined).
+func dialUpstream(url string, command []string, profile *sandbox.Profile, tlsCfg *tls.Config, flush *transport.FlushPolicy) (transport.Transport, func(), error) {
+	if isWebSocketURL(url) {
+		t, err := transport.DialWebSocketWithConfig(url, &transport.WebSocketConfig{
+			TLS:       tlsCfg,
+			Reconnect: transport.DefaultRestartPolicy(),
+			OnDisconnect: func(ev transport.DisconnectEvent) {
+				log.Printf("audit: upstream websocket %s disconnected after %s: %v (reconnecting=%t in %s)",
+					url, ev.Uptime.Round(time.Millisecond), ev.Err, ev.Reconnecting, ev.Delay)
+			},
+		})
+		if err != nil {
+			return nil, nil, withExit(ExitUpstream, kindUpstream, err)
+		}
+		return t, func() { t.Close() }, nil
+	}
+	if url != "" {
+		t := transport.NewSSETransport(url)
+		if tlsCfg != nil {
+			t.SetTLSConfig(tlsCfg)
+		}
+		if err := t.Connect(); err != nil {
+			return nil, nil, withExit(ExitUpstream, kindUpstream, err)
+		}
+		return t, func() { t.Close() }, nil
+	}
+	if len(command) == 0 {
+		return nil, nil, withExit(ExitConfig, kindConfig, errors.New("no upstream: specify a URL or a server command after --"))
+	}
+
+	p, err := transport.SpawnStdioServerWithConfig(command[0], command[1:], nil, &transport.SpawnConfig{
+		Restart: transport.DefaultRestartPolicy(),
+		Flush:   flush,
+		Sandbox: profile,
+		OnExit: func(ev transport.ExitEvent) {
+			log.Printf("audit: upstream server pid %d exited after %s: %v (restarting=%t in %s)",
+				ev.PID, ev.Uptime.Round(time.Millisecond), ev.Err, ev.Restarting, ev.Delay)
+		},
+	})
+	if err != nil {
+		return nil, nil, withExit(ExitUpstream, kindUpstream, err)
+	}
+	return p, func() { p.Close() }, nil
+}
+
+// isWebSocketURL reports whether url has a ws:// or wss:// scheme.
+func isWebSocketURL(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+// loop reads commands until EOF or :quit.
+func (r *repl) loop(in io.Reader) error {
+	fmt.Fprintln(r.out, "MCP Sentinel REPL - type :help for commands")
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for {
+		fmt.Fprint(r.out, "sentinel> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == ":quit" || line == ":q" {
+			return nil
+		}
+		if err := r.handle(line); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+}
+
+// handle executes a single REPL line.
+func (r *repl) handle(line string) error {
+	if strings.HasPrefix(line, "{") {
+		return r.route([]byte(line))
+	}
+
+	cmd, rest, _ := strings.Cut(line, " ")
+	method, params, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	switch cmd {
+	case ":help", ":h":
+		fmt.Fprintln(r.out, replHelp)
+	case ":templates":
+		methods := make([]string, 0, len(replTemplates))
+		for m := range replTemplates {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, m := range methods {
+			fmt.Fprintf(r.out, "  %-28s %s\n", m, replTemplates[m])
+		}
+	case ":t":
+		tmpl, ok := replTemplates[method]
+		if !ok {
+			return fmt.Errorf("no template for %q", method)
+		}
+		fmt.Fprintln(r.out, tmpl)
+	case ":send":
+		msg, err := r.build(method, params, true)
+		if err != nil {
+			return err
+		}
+		return r.route(msg)
+	case ":notify":
+		msg, err := r.build(method, params, false)
+		if err != nil {
+			return err
+		}
+		// Notifications get no response, so they bypass RouteMessage
+		fmt.Fprintf(r.out, "-> %s\n", msg)
+		return r.upstream.Send(msg)
+	case ":stats":
+		s := r.router.Stats()
+		fmt.Fprintf(r.out, "received=%d forwarded=%d blocked=%d errors=%d\n",
+			s.MessagesReceived, s.MessagesForwarded, s.MessagesBlocked, s.Errors)
+		printCounts(r.out, "method", s.Methods)
+		printCounts(r.out, "tool", s.Tools)
+	default:
+		return fmt.Errorf("unknown command %q (try :help)", cmd)
+	}
+	return nil
+}
+
+// printCounts prints per-name verdict counts, sorted by name.
+func printCounts(w io.Writer, label string, counts map[string]router.VerdictCounts) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := counts[name]
+		fmt.Fprintf(w, "  %s %s: total=%d allowed=%d blocked=%d errors=%d\n",
+			label, name, c.Total, c.Allowed, c.Blocked, c.Errors)
+	}
+}
+
+// build constructs a request or notification from a method and params.
+func (r *repl) build(method, params string, request bool) ([]byte, error) {
+	if method == "" {
+		return nil, errors.New("method required")
+	}
+	if params == "" {
+		params = replTemplates[method]
+	}
+	var p json.RawMessage
+	if params != "" {
+		if !json.Valid([]byte(params)) {
+			return nil, errors.New("params are not valid JSON")
+		}
+		p = json.RawMessage(params)
+	}
+
+	var msg *jsonrpc.Message
+	var err error
+	if request {
+		msg, err = jsonrpc.NewRequest(method, p, r.nextID)
+		r.nextID++
+	} else {
+		msg, err = jsonrpc.NewNotification(method, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(msg)
+}
+
+// route sends a message through the pipeline and prints the outcome.
+func (r *repl) route(data []byte) error {
+	fmt.Fprintf(r.out, "-> %s\n", data)
+
+	start := time.Now()
+	response, err := r.router.RouteMessage(data)
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(r.out, "verdict: error (%s)\n", elapsed)
+		return err
+	}
+
+	fmt.Fprintf(r.out, "verdict: %s (%s)\n", verdict(response), elapsed)
+	fmt.Fprintf(r.out, "<- %s\n", response)
+	return nil
+}
+
+// verdict summarizes a routed response for display.
+func verdict(response []byte) string {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		return "unparseable response"
+	}
+	if resp.Error == nil {
+		return "allowed"
+	}
+	var data router.ErrorData
+	json.Unmarshal(resp.Error.Data, &data)
+	if strings.HasPrefix(resp.Error.Message, "Blocked") || resp.Error.Message == "Session terminated" {
+		return fmt.Sprintf("BLOCKED: %s [decision %s]", data.Reason, data.DecisionID)
+	}
+	return fmt.Sprintf("error %d: %s %s", resp.Error.Code, resp.Error.Message, data.Reason)
+}
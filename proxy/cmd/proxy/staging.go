@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/staging"
+)
+
+const stagingUsage = `Usage:
+  mcp-sentinel-proxy staging list DIR
+  mcp-sentinel-proxy staging promote DIR ID
+  mcp-sentinel-proxy staging discard DIR ID
+
+DIR is the staging_dir of a policy downgrade rule. list prints the
+writes redirected into it, with their state; promote moves a staged
+write to the path the tool call meant to write, and discard deletes
+it. Both are recorded in DIR's journal.`
+
+// runStaging runs a staging subcommand.
+func runStaging(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", stagingUsage))
+	}
+	switch {
+	case args[0] == "list" && len(args) == 2:
+		entries, err := staging.List(args[1])
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Fprintf(out, "%s  %-9s  %s  %s -> %s  (rule %s, session %s)\n",
+				e.ID, e.State, e.Time.Format("2006-01-02T15:04:05Z"), e.Staged, e.Original, e.Rule, e.Session)
+		}
+		return nil
+	case args[0] == "promote" && len(args) == 3:
+		e, err := staging.Promote(args[1], args[2])
+		if err != nil {
+			return stagingError(err)
+		}
+		fmt.Fprintf(out, "promoted %s to %s\n", e.ID, e.Original)
+		return nil
+	case args[0] == "discard" && len(args) == 3:
+		e, err := staging.Discard(args[1], args[2])
+		if err != nil {
+			return stagingError(err)
+		}
+		fmt.Fprintf(out, "discarded %s (meant for %s)\n", e.ID, e.Original)
+		return nil
+	}
+	return withExit(ExitConfig, kindConfig, fmt.Errorf("%s", stagingUsage))
+}
+
+// stagingError classifies a journal error: naming an entry that cannot
+// change is a usage error.
+func stagingError(err error) error {
+	if errors.Is(err, staging.ErrUnknownEntry) || errors.Is(err, staging.ErrNotStaged) {
+		return withExit(ExitConfig, kindConfig, err)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/admin"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/reload"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+)
+
+// runStdio proxies a client on stdin/stdout to the upstream server until
+// the client disconnects or SIGINT/SIGTERM arrives.
+//
+// The upstream is an SSE or WebSocket server, a stdio server command,
+// or several of these multiplexed by an upstream.Mux. cfg is the
+// router configuration; runStdio adds the degradation ladder and the
+// upstream tool resolver, and registers the session for reloads and
+// usage export.
+func runStdio(target upstreamTarget, client *sentinel.Client, cfg *router.Config, ladder *degrade.Ladder, adminServer *admin.Server, reloader *reload.Reloader, exporter *usage.Exporter, reporter *crash.Reporter) error {
+	upstream, cleanup, tools, err := target.connect()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cfg.Degradation = ladder
+	cfg.UpstreamTools = tools
+	r := router.NewWithTransports(transport.NewStdioTransportWithConfig(os.Stdout, os.Stdin, target.flush), upstream, client, cfg)
+
+	watchReplays(upstream, r)
+	reporter.SetDecisionSource(func(n int) []string {
+		var ids []string
+		for _, d := range r.RecentDecisions(n) {
+			ids = append(ids, d.ID)
+		}
+		return ids
+	})
+	reloader.Register(r)
+	defer reloader.Unregister(r.Health().SessionID)
+	if exporter != nil {
+		exporter.Track(r.Health().SessionID, r.Usage)
+		defer exporter.Untrack(r.Health().SessionID)
+	}
+	if adminServer != nil {
+		adminServer.Register(r)
+		defer adminServer.Unregister(r.Health().SessionID)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan error, 1)
+	reporter.Go(func() { done <- r.Run(ctx) })
+	log.Println("Proxy ready - reading from stdin")
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Receive blocks on stdin, so do not wait for Run to notice
+		log.Println("Shutting down...")
+		r.EndSession()
+		return nil
+	}
+	if errors.Is(err, transport.ErrClosed) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
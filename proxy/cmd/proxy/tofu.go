@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+// openTOFU opens the trust-on-first-use store. With prompt, new
+// fingerprints are put to the operator on the controlling terminal;
+// stdin and stdout carry MCP traffic, so /dev/tty is opened here,
+// before any chroot. fleet, if not nil, is the verified fleet manifest.
+func openTOFU(path, manifest, onChange string, prompt bool, fleet *tofu.FleetManifest) (*tofu.Store, error) {
+	cfg := &tofu.Config{Path: path, Manifest: manifest, OnChange: onChange, Fleet: fleet}
+	if prompt {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return nil, withExit(ExitConfig, kindConfig, fmt.Errorf("--tofu-prompt needs a terminal: %w", err))
+		}
+		cfg.Prompt = ttyPrompt(tty)
+	}
+	store, err := tofu.Open(cfg)
+	if err != nil {
+		return nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return store, nil
+}
+
+// ttyPrompt asks the operator on tty to approve a fingerprint.
+func ttyPrompt(tty *os.File) func(tofu.Pending) bool {
+	in := bufio.NewReader(tty)
+	return func(p tofu.Pending) bool {
+		fmt.Fprintf(tty, "\nTool %q of server %q is %s.\n", p.Tool, p.Server, p.Status)
+		if p.Description != "" {
+			fmt.Fprintf(tty, "  description: %s\n", strings.ReplaceAll(p.Description, "\n", "\n               "))
+		}
+		if p.Previous != "" {
+			fmt.Fprintf(tty, "  previously approved: %s\n", p.Previous)
+		}
+		fmt.Fprintf(tty, "  fingerprint: %s\nApprove? [y/N] ", p.Fingerprint)
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
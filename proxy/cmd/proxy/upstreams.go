@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/upstream"
+)
+
+// upstreamFlags collects repeated --upstream NAME=TARGET flags. TARGET
+// is a URL (SSE, or ws:// / wss://) or a server command line.
+type upstreamFlags []upstreamSpec
+
+// upstreamSpec is one named upstream: a URL, a server command, or a
+// one-shot upstream from the config file.
+type upstreamSpec struct {
+	name    string
+	url     string
+	command []string
+	oneshot *upstream.OneShotConfig
+
+	// sandbox confines the server command (nil runs it unconfined)
+	sandbox *sandbox.Profile
+}
+
+func (f *upstreamFlags) String() string {
+	var parts []string
+	for _, s := range *f {
+		target := s.url
+		if target == "" {
+			target = strings.Join(s.command, " ")
+		}
+		parts = append(parts, s.name+"="+target)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *upstreamFlags) Set(value string) error {
+	name, target, ok := strings.Cut(value, "=")
+	if !ok || name == "" || strings.TrimSpace(target) == "" {
+		return fmt.Errorf("expected NAME=TARGET, got %q", value)
+	}
+	spec := upstreamSpec{name: name, url: target}
+	if !strings.Contains(target, "://") {
+		spec.url, spec.command = "", strings.Fields(target)
+	}
+	*f = append(*f, spec)
+	return nil
+}
+
+// upstreamTarget is the server side of the proxy: one server given by
+// --upstream-url or a command after --, or several --upstream flags.
+type upstreamTarget struct {
+	url     string
+	command []string
+	oneshot *upstream.OneShotConfig
+	sandbox *sandbox.Profile
+
+	multi     upstreamFlags
+	namespace bool
+
+	// tls configures HTTPS and wss:// connections (nil uses system
+	// defaults)
+	tls *tls.Config
+
+	// flush batches writes to stdio servers and the stdio client (nil
+	// writes each message at once)
+	flush *transport.FlushPolicy
+}
+
+// connect dials the upstream servers.
+//
+// # Returns
+//   - The server transport and a function that closes it
+//   - A ToolResolver when several servers are multiplexed (nil otherwise)
+//   - An error carrying ExitConfig or ExitUpstream
+func (u upstreamTarget) connect() (transport.Transport, func(), router.ToolResolver, error) {
+	if len(u.multi) == 0 {
+		t, cleanup, err := dialSpec(upstreamSpec{url: u.url, command: u.command, oneshot: u.oneshot, sandbox: u.sandbox}, u.tls, u.flush)
+		return t, cleanup, nil, err
+	}
+	if u.url != "" || len(u.command) > 0 || u.oneshot != nil {
+		return nil, nil, nil, withExit(ExitConfig, kindConfig, errors.New("--upstream cannot be combined with --upstream-url or a server command"))
+	}
+
+	var ups []upstream.Upstream
+	closeAll := func() {
+		for _, up := range ups {
+			up.Transport.Close()
+		}
+	}
+	for _, spec := range u.multi {
+		t, _, err := dialSpec(spec, u.tls, u.flush)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("upstream %s: %w", spec.name, err)
+		}
+		up := upstream.Upstream{Name: spec.name, Transport: t}
+		if u.namespace {
+			up.Prefix = spec.name
+		}
+		ups = append(ups, up)
+	}
+
+	mux, err := upstream.New(ups...)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return mux, func() { mux.Close() }, mux, nil
+}
+
+// dialSpec connects to one upstream, starting a one-shot upstream in
+// process.
+func dialSpec(spec upstreamSpec, tlsCfg *tls.Config, flush *transport.FlushPolicy) (transport.Transport, func(), error) {
+	if spec.oneshot == nil {
+		return dialUpstream(spec.url, spec.command, spec.sandbox, tlsCfg, flush)
+	}
+	o, err := upstream.NewOneShot(spec.oneshot)
+	if err != nil {
+		return nil, nil, withExit(ExitConfig, kindConfig, err)
+	}
+	return o, func() { o.Close() }, nil
+}
@@ -0,0 +1,3020 @@
+// Package config loads the proxy's configuration file.
+//
+// A file is YAML or JSON, chosen by its extension (.yaml, .yml, .json)
+// or, for any other name, by whether it starts with '{'. Fields left
+// out of the file keep the values from Default, and unknown fields are
+// errors, so a misspelled key cannot silently drop a policy.
+//
+// # Example
+//
+//	mode: stdio
+//	admin: 127.0.0.1:9090
+//	upstreams:
+//	  - name: fs
+//	    command: [fs-server, /srv]
+//	    sandbox:
+//	      enabled: true
+//	      read_write_paths: [/srv]
+//	      network: none
+//	      strict: true
+//	  - name: web
+//	    url: https://web.example/mcp
+//	  - name: fn
+//	    tools:
+//	      - name: resize_image
+//	        description: Resize an image
+//	        input_schema: '{"type":"object","required":["url"]}'
+//	        command: [resize, --stdin]
+//	        timeout: 10s
+//	        warm: 2
+//	      - name: translate
+//	        url: https://functions.example/translate
+//	request_timeout: 2m
+//	orphan_after: 5m
+//	distinct_error_codes: true
+//	explain_blocks: true
+//	max_proxy_hops: 16
+//	partial_results:
+//	  enabled: true
+//	gas:
+//	  budget: 500000
+//	  max_call_depth: 8
+//	context_budget:
+//	  threshold: 2000000
+//	  max_result_bytes: 8192
+//	high_risk_tools: [execute_command, write_file]
+//	check_order: [tool_policy, budget]
+//	policy:
+//	  deny: ["*__delete_*"]
+//	  rules:
+//	    - name: secrets
+//	      tools: [read_file]
+//	      arguments: {path: "^/etc/"}
+//	      action: require-council
+//	    - name: stage-writes
+//	      tools: [write_file]
+//	      action: downgrade
+//	      downgrade: {stage_argument: path, workspace: /srv/work, staging_dir: /srv/staging}
+//	logging:
+//	  file: /var/log/mcp-sentinel.log
+//	tls:
+//	  ca_file: /etc/mcp-sentinel/ca.pem
+//	slo:
+//	  webhook: https://alerts.example/hooks/mcp-sentinel
+//	  objectives:
+//	    - name: tools-call-latency
+//	      method: tools/call
+//	      latency: 500ms
+//	      target: 0.95
+//	    - name: block-error-rate
+//	      verdicts: [blocked, error]
+//	      target: 0.999
+//	chain:
+//	  proxy_id: edge-1
+//	  propagate: true
+//	taint:
+//	  enabled: true
+//	  action: council
+//	schema_validation:
+//	  enabled: true
+//	resource_templates:
+//	  enabled: true
+//	  variables:
+//	    ticket: "[A-Z]+-[0-9]+"
+//	  templates: ["file:///srv/docs/{name}"]
+//	resource_inspection:
+//	  enabled: true
+//	  max_bytes: 4194304
+//	  servers:
+//	    - name: "docs-*"
+//	      max_bytes: 65536
+//	response_inspection:
+//	  enabled: true
+//	  action: sanitize
+//	tool_descriptions:
+//	  enabled: true
+//	  action: redact
+//	server_mask:
+//	  enabled: true
+//	  name: tools
+//	  strip_urls: true
+//	  strip_versions: true
+//	normalization:
+//	  enabled: true
+//	  fold_arguments: false
+//	  block: false
+//	notifications:
+//	  enabled: true
+//	  methods: ["notifications/message", "notifications/tools/list_changed"]
+//	  max_bytes: 65536
+//	  require_subscription: true
+//	sampling:
+//	  enabled: true
+//	  action: allow
+//	  on_injection: approve
+//	  council: true
+//	  max_tokens: 4096
+//	  approval_timeout: 2m
+//	elicitation:
+//	  enabled: true
+//	  sensitive_fields: [password, token, otp]
+//	roots:
+//	  enabled: true
+//	  servers: ["fs-*"]
+//	  prefixes: ["file:///home/dev/project"]
+//	read_receipts:
+//	  enabled: true
+//	  escalate_after: 3
+//	conformance:
+//	  enabled: true
+//	  reject_at: 10
+//	registry_fast_path:
+//	  enabled: true
+//	  tools: [read_file, search]
+//	  max_age: 5m
+//	audit:
+//	  file: /var/log/mcp-sentinel/audit.jsonl
+//	  max_bytes: 104857600
+//	  chain: true
+//	  payload_bytes: 4096
+//	  encrypt_fields: [arguments, result]
+//	  encryption_key: 3p5XbH0pGjg3tjsGbJ4Uq1eFzX2k0mJ5q7C1f6YtA1w=
+//	  stream: true
+//	stdio:
+//	  flush_delay: 1ms
+//	tracing:
+//	  endpoint: http://localhost:4318/v1/traces
+//	  sample_ratio: 0.1
+//	usage:
+//	  tenant: acme
+//	  file: /var/log/mcp-sentinel/usage.csv
+//	  format: csv
+//	  interval: 1m
+//	session_state:
+//	  backend: file
+//	  path: /var/lib/mcp-sentinel/sessions.json
+//	  resumption: true
+//	affinity:
+//	  self: replica-1
+//	  handoff: proxy
+//	  replicas:
+//	    - id: replica-1
+//	      url: http://10.0.0.11:8080
+//	    - id: replica-2
+//	      url: http://10.0.0.12:8080
+//	redaction:
+//	  mode: redact
+//	  patterns:
+//	    internal-token: 'corp-tok-([0-9a-f]{32})'
+//	rate_limit:
+//	  session: {rate: 20, burst: 40}
+//	  tools:
+//	    - {tool: execute_command, rate: 0.5, burst: 3}
+//	schedule:
+//	  enabled: true
+//	  time_zone: Europe/Berlin
+//	  read_only_tools: [read_file, search]
+//	  rules:
+//	    - name: business-hours
+//	      cron: "* 9-17 * * 1-5"
+//	      outside: true
+//	      scope: mutating
+//	  limits:
+//	    - {category: shell, tools: [execute_command], max_in_flight: 1}
+//	    - {category: network, tools: ["fetch_*"], max_in_flight: 4}
+//	  limit_wait: 2s
+//	ffi:
+//	  library: /opt/mcp-sentinel/lib/libsentinel_ffi-1.4.0.so
+//	  drain_timeout: 10s
+//	  workers: 8
+//	  call_timeout: 2s
+//
+// # Environment Overrides
+//
+// Every scalar or list field can be overridden by an environment
+// variable named EnvPrefix plus its path in upper case, with dots
+// replaced by underscores: MCP_SENTINEL_GAS_BUDGET sets gas.budget and
+// MCP_SENTINEL_POLICY_DENY="shell,sudo" sets policy.deny. Lists are
+// comma-separated. Upstreams are only configurable in the file. Secrets
+// such as chain.key, attestation.key, and admin_token are best set this
+// way (MCP_SENTINEL_CHAIN_KEY, MCP_SENTINEL_ATTESTATION_KEY,
+// MCP_SENTINEL_ADMIN_TOKEN): none of the EnvPrefix variables are passed
+// on to spawned servers or one-shot commands.
+//
+// # Errors
+//
+// Syntax errors name the line; every other error names the offending
+// field by its path, such as "upstreams[1].url".
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/affinity"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mask"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/scanner"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/secrets"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sessionstate"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/upstream"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+)
+
+// Configuration errors.
+var (
+	ErrSyntax  = errors.New("config: syntax error")
+	ErrInvalid = errors.New("config: invalid configuration")
+)
+
+// EnvPrefix starts the name of every environment override. Spawned
+// servers do not inherit these variables.
+const EnvPrefix = transport.ProxyEnvPrefix
+
+// Format is a configuration file format.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// Config is the proxy configuration.
+type Config struct {
+	// Mode is the transport mode: stdio, streamable-http, sse, or ws
+	Mode string `json:"mode"`
+
+	// Port is the listen port in streamable-http and sse modes
+	Port int `json:"port"`
+
+	// Admin is the admin listen address (empty disables)
+	Admin string `json:"admin"`
+
+	// AdminToken is the bearer token that authorizes configuration
+	// edits from the admin UI and admin endpoints that change the
+	// running proxy (empty disables them)
+	AdminToken string `json:"admin_token" secret:"true"`
+
+	// Upstreams are the servers to proxy to; several are multiplexed
+	// and must be named
+	Upstreams []Upstream `json:"upstreams"`
+
+	// NamespaceTools exposes the tools of several upstreams as
+	// NAME__tool
+	NamespaceTools bool `json:"namespace_tools"`
+
+	// RequestTimeout is how long a request waits for the upstream's
+	// response before it is cancelled (zero waits indefinitely)
+	RequestTimeout time.Duration `json:"request_timeout"`
+
+	// OrphanAfter is how long a request may go unanswered before it is
+	// reported as orphaned (zero uses the router default, negative
+	// disables the check)
+	OrphanAfter time.Duration `json:"orphan_after"`
+
+	// DistinctErrorCodes answers policy refusals with their own error
+	// code in place of -32600 and -32602
+	DistinctErrorCodes bool `json:"distinct_error_codes"`
+
+	// ExplainBlocks offers clients the sentinel/why tool, which explains
+	// the session's blocked calls by decision ID
+	ExplainBlocks bool `json:"explain_blocks"`
+
+	// MaxProxyHops is how many sentinels may precede this one on a
+	// session's path, through server URLs or commands, before the
+	// session is refused as a loop (zero uses
+	// router.DefaultMaxProxyHops)
+	MaxProxyHops int `json:"max_proxy_hops"`
+
+	// PartialResults configures salvage of a timed-out tool call's
+	// progress output
+	PartialResults PartialResults `json:"partial_results"`
+
+	// Gas bounds each session's tool use
+	Gas Gas `json:"gas"`
+
+	// ContextBudget trims large results once a session has delivered
+	// enough content to the client
+	ContextBudget ContextBudget `json:"context_budget"`
+
+	// HighRiskTools are the server tool names that require a council
+	// vote (nil uses the router's built-in list)
+	HighRiskTools []string `json:"high_risk_tools"`
+
+	// CheckOrder orders the tools/call checks by stage name (see
+	// router.DefaultStageOrder); checks left out run after those listed
+	// (nil uses the default order)
+	CheckOrder []string `json:"check_order"`
+
+	// Policy allows or denies tool calls by name
+	Policy Policy `json:"policy"`
+
+	// Logging configures the log and fatal error output
+	Logging Logging `json:"logging"`
+
+	// TLS configures HTTPS and wss:// upstream connections
+	TLS TLS `json:"tls"`
+
+	// SLO configures service level objective alerts
+	SLO SLO `json:"slo"`
+
+	// Chain configures cooperation with other sentinels chained in
+	// front of or behind this one
+	Chain Chain `json:"chain"`
+
+	// Taint configures tracking of tool call arguments copied from
+	// earlier tool results
+	Taint Taint `json:"taint"`
+
+	// SchemaValidation configures validation of tool call arguments
+	// against the input schemas the server lists
+	SchemaValidation SchemaValidation `json:"schema_validation"`
+
+	// ResourceTemplates configures checks of resource URIs expanded
+	// from the server's resource templates
+	ResourceTemplates ResourceTemplates `json:"resource_templates"`
+
+	// ResourceInspection configures size and content type checks of
+	// resources/read results
+	ResourceInspection ResourceInspection `json:"resource_inspection"`
+
+	// ResponseInspection submits tool result and resource text to the
+	// sentinel before it reaches the client
+	ResponseInspection ResponseInspection `json:"response_inspection"`
+
+	// ToolDescriptions configures scanning of the tool metadata in
+	// tools/list results
+	ToolDescriptions ToolDescriptions `json:"tool_descriptions"`
+
+	// ServerMask rewrites the server identity and tool descriptions
+	// presented to the client
+	ServerMask ServerMask `json:"server_mask"`
+
+	// Normalization configures the removal of invisible Unicode and
+	// homoglyphs from client messages
+	Normalization Normalization `json:"normalization"`
+
+	// Notifications restricts the notifications the server sends on
+	// its own
+	Notifications Notifications `json:"notifications"`
+
+	// Sampling screens the server's requests to run the client's model
+	Sampling Sampling `json:"sampling"`
+
+	// Elicitation screens the server's requests for user input
+	Elicitation Elicitation `json:"elicitation"`
+
+	// Roots decides which servers may list the client's roots
+	Roots Roots `json:"roots"`
+
+	// ReadReceipts configures remediation notices for blocked tool calls
+	// and escalation of sessions that ignore them
+	ReadReceipts ReadReceipts `json:"read_receipts"`
+
+	// Conformance configures scoring of the client's protocol
+	// conformance and escalation of nonconforming clients
+	Conformance Conformance `json:"conformance"`
+
+	// RegistryFastPath lets repeated, already verified tool calls skip
+	// registry re-validation
+	RegistryFastPath RegistryFastPath `json:"registry_fast_path"`
+
+	// Audit configures the per-message audit trail
+	Audit Audit `json:"audit"`
+
+	// Stdio configures writing to stdio peers: the client in stdio mode
+	// and stdio server commands
+	Stdio Stdio `json:"stdio"`
+
+	// Tracing exports OpenTelemetry spans of the routing pipeline
+	Tracing Tracing `json:"tracing"`
+
+	// Usage exports per-tenant, per-session consumption records
+	Usage Usage `json:"usage"`
+
+	// SessionState persists each session's security context across
+	// restarts
+	SessionState SessionState `json:"session_state"`
+
+	// Affinity keeps each session on one replica of a horizontally
+	// scaled streamable-http deployment by consistent hashing
+	Affinity Affinity `json:"affinity"`
+
+	// Redaction masks secrets in tool call arguments and results
+	Redaction Redaction `json:"redaction"`
+
+	// RateLimit limits the rate of tool calls globally, per session,
+	// and per tool
+	RateLimit RateLimit `json:"rate_limit"`
+
+	// Schedule denies tool calls by time window and enables the admin
+	// API's maintenance mode
+	Schedule Schedule `json:"schedule"`
+
+	// Attestation signs statements of the running binary, features,
+	// and policies for fleet verification
+	Attestation Attestation `json:"attestation"`
+
+	// FFI configures the calls into the Rust sentinel library and
+	// reloading it while the proxy runs
+	FFI FFI `json:"ffi"`
+}
+
+// Upstream is one upstream server, given by exactly one of URL,
+// Command, and Tools.
+type Upstream struct {
+	// Name identifies the upstream and prefixes its namespaced tools
+	// (required with several upstreams)
+	Name string `json:"name"`
+
+	// URL is an SSE (http, https) or WebSocket (ws, wss) server URL
+	URL string `json:"url"`
+
+	// Command is a stdio server command and its arguments
+	Command []string `json:"command"`
+
+	// Tools makes this a one-shot upstream: the proxy serves the tools
+	// listed and runs a command or calls an HTTP function for each
+	// call, without a persistent server
+	Tools []OneShotTool `json:"tools"`
+
+	// Sandbox confines the processes of a command or one-shot upstream
+	Sandbox Sandbox `json:"sandbox"`
+}
+
+// Sandbox confines an upstream's server or tool processes; see
+// sandbox.Profile.
+type Sandbox struct {
+	// Enabled runs the processes under the profile
+	Enabled bool `json:"enabled"`
+
+	// ReadWritePaths are directories the processes may read and write
+	ReadWritePaths []string `json:"read_write_paths"`
+
+	// ReadOnlyPaths are directories the processes may only read
+	ReadOnlyPaths []string `json:"read_only_paths"`
+
+	// Network is all, loopback, or none (empty uses none)
+	Network string `json:"network"`
+
+	// Helper overrides the sandbox helper binary (bwrap or
+	// sandbox-exec)
+	Helper string `json:"helper"`
+
+	// Strict refuses to start a process that cannot be fully confined
+	// instead of falling back to reduced confinement
+	Strict bool `json:"strict"`
+}
+
+// Profile returns the sandbox profile named after the upstream, or nil
+// when the sandbox is disabled.
+func (s *Sandbox) Profile(name string) *sandbox.Profile {
+	if !s.Enabled {
+		return nil
+	}
+	if name == "" {
+		name = "upstream"
+	}
+	return &sandbox.Profile{
+		Name:           name,
+		ReadWritePaths: s.ReadWritePaths,
+		ReadOnlyPaths:  s.ReadOnlyPaths,
+		Network:        sandbox.NetworkScope(s.Network),
+		Helper:         s.Helper,
+		Strict:         s.Strict,
+	}
+}
+
+// OneShotTool is a tool of a one-shot upstream, given by exactly one of
+// Command and URL. See upstream.OneShotTool for how it is invoked.
+type OneShotTool struct {
+	// Name is the tool name
+	Name string `json:"name"`
+
+	// Description is the tool description listed to the client
+	Description string `json:"description"`
+
+	// InputSchema is the tool's JSON Schema, as a JSON object in a
+	// string (empty lists {"type":"object"})
+	InputSchema string `json:"input_schema"`
+
+	// Command is run once per call, with the arguments on standard
+	// input
+	Command []string `json:"command"`
+
+	// Env adds KEY=VALUE entries to the command's environment
+	Env []string `json:"env"`
+
+	// URL is an HTTP function the arguments are POSTed to
+	URL string `json:"url"`
+
+	// Timeout bounds one call (zero uses the upstream default)
+	Timeout time.Duration `json:"timeout"`
+
+	// MaxConcurrent bounds the calls running at once (zero uses the
+	// upstream default)
+	MaxConcurrent int `json:"max_concurrent"`
+
+	// Warm keeps this many command processes started ahead of calls
+	Warm int `json:"warm"`
+
+	// MaxOutput bounds the output kept per call, in bytes (zero uses
+	// the upstream default)
+	MaxOutput int `json:"max_output"`
+}
+
+// OneShot returns the upstream.OneShotConfig of a one-shot upstream,
+// or nil for a server upstream.
+func (u Upstream) OneShot() *upstream.OneShotConfig {
+	if len(u.Tools) == 0 {
+		return nil
+	}
+	cfg := &upstream.OneShotConfig{Name: u.Name, Sandbox: u.Sandbox.Profile(u.Name)}
+	for _, t := range u.Tools {
+		tool := upstream.OneShotTool{
+			Name:          t.Name,
+			Description:   t.Description,
+			Command:       t.Command,
+			Env:           t.Env,
+			URL:           t.URL,
+			Timeout:       t.Timeout,
+			MaxConcurrent: t.MaxConcurrent,
+			Warm:          t.Warm,
+			MaxOutput:     t.MaxOutput,
+		}
+		if t.InputSchema != "" {
+			tool.InputSchema = json.RawMessage(t.InputSchema)
+		}
+		cfg.Tools = append(cfg.Tools, tool)
+	}
+	return cfg
+}
+
+// Gas bounds each session's tool use.
+type Gas struct {
+	// Budget is the maximum gas per session
+	Budget uint64 `json:"budget"`
+
+	// MaxCallDepth is the maximum nested call depth
+	MaxCallDepth int `json:"max_call_depth"`
+}
+
+// ContextBudget bounds the content each session delivers to the
+// client; see router.ContextBudget.
+type ContextBudget struct {
+	// Threshold is the content bytes delivered before large results
+	// are trimmed (0 disables the budget)
+	Threshold uint64 `json:"threshold"`
+
+	// MaxResultBytes is the content kept of each trimmed result (0
+	// uses router.DefaultContextResultBytes)
+	MaxResultBytes int `json:"max_result_bytes"`
+}
+
+// validate checks the result size.
+func (b *ContextBudget) validate() error {
+	if b.MaxResultBytes < 0 {
+		return invalid("context_budget.max_result_bytes", "must not be negative, got %d", b.MaxResultBytes)
+	}
+	return nil
+}
+
+// RouterConfig returns the router context budget, or nil when it is
+// disabled.
+func (b *ContextBudget) RouterConfig() *router.ContextBudget {
+	if b.Threshold == 0 {
+		return nil
+	}
+	return &router.ContextBudget{Threshold: b.Threshold, MaxResultBytes: b.MaxResultBytes}
+}
+
+// Policy allows or denies tool calls by name pattern (see
+// router.ToolPolicy) and holds the rules of the policy engine (see
+// package policy).
+type Policy struct {
+	// Allow lists permitted tool name patterns (empty allows every
+	// tool not denied)
+	Allow []string `json:"allow"`
+
+	// Deny lists refused tool name patterns
+	Deny []string `json:"deny"`
+
+	// Rules are the policy engine's rules, evaluated in order; they
+	// replace the built-in high-risk tools and gas prices
+	Rules []policy.Rule `json:"rules"`
+
+	// DefaultAction applies to requests no rule decides: allow or
+	// block (empty allows)
+	DefaultAction policy.Action `json:"default_action"`
+
+	// DefaultGas prices tool calls no rule prices (zero uses
+	// policy.DefaultGas)
+	DefaultGas uint64 `json:"default_gas"`
+}
+
+// Set returns the policy engine rules, or nil when none are
+// configured and the built-in rules apply.
+func (p *Policy) Set() *policy.Set {
+	if len(p.Rules) == 0 && p.DefaultAction == "" && p.DefaultGas == 0 {
+		return nil
+	}
+	return &policy.Set{Rules: p.Rules, DefaultAction: p.DefaultAction, DefaultGas: p.DefaultGas}
+}
+
+// Logging configures the log and fatal error output.
+type Logging struct {
+	// File receives the log, appended to (empty logs to stderr)
+	File string `json:"file"`
+
+	// ErrorFormat is the fatal error format on stderr: text or json
+	ErrorFormat string `json:"error_format"`
+
+	// UTC timestamps log lines in UTC instead of local time
+	UTC bool `json:"utc"`
+}
+
+// SLO configures service level objectives on the latency the proxy adds
+// and the messages it blocks or fails; see package slo.
+type SLO struct {
+	// Objectives are the objectives to evaluate (empty disables SLO
+	// tracking)
+	Objectives []slo.Objective `json:"objectives"`
+
+	// Windows are the burn rate alert conditions (empty uses
+	// slo.DefaultWindows)
+	Windows []slo.BurnWindow `json:"windows"`
+
+	// Interval is how often burn rates are evaluated (zero uses
+	// slo.DefaultInterval)
+	Interval time.Duration `json:"interval"`
+
+	// MinEvents is the number of events an alert window needs before it
+	// fires (zero uses slo.DefaultMinEvents)
+	MinEvents int `json:"min_events"`
+
+	// Webhook receives alerts as JSON POSTs (empty only logs them)
+	Webhook string `json:"webhook"`
+}
+
+// Chain configures cooperation with other sentinels in a proxy chain;
+// see router.ChainConfig. It is disabled without a proxy ID.
+type Chain struct {
+	// ProxyID names this proxy in chain metadata (empty disables)
+	ProxyID string `json:"proxy_id"`
+
+	// Key is the secret shared by every proxy in the chain to sign
+	// chain metadata (empty sends it unsigned and trusts none)
+	Key string `json:"key" secret:"true"`
+
+	// Propagate adds chain metadata to forwarded requests; set it when
+	// another sentinel sits between this proxy and the server
+	Propagate bool `json:"propagate"`
+
+	// TrustUpstream skips checks a verified sentinel in front of this
+	// one already ran and allowed (requires Key)
+	TrustUpstream bool `json:"trust_upstream"`
+}
+
+// Taint configures argument provenance tracking; see
+// router.TaintTracking.
+type Taint struct {
+	// Enabled turns tracking on
+	Enabled bool `json:"enabled"`
+
+	// MinLength is the shortest argument value matched (zero uses the
+	// router default)
+	MinLength int `json:"min_length"`
+
+	// MaxBytes bounds the result text retained per session (zero uses
+	// the router default)
+	MaxBytes int `json:"max_bytes"`
+
+	// CriticalArguments are argument name patterns whose taint is acted
+	// on (empty uses router.DefaultCriticalArguments)
+	CriticalArguments []string `json:"critical_arguments"`
+
+	// Action is flag, council, or block (empty uses council)
+	Action string `json:"action"`
+
+	// RiskScore is the council risk score of tainted calls (zero uses
+	// the router default)
+	RiskScore float64 `json:"risk_score"`
+}
+
+// RouterConfig returns the router taint tracking configuration, or nil
+// when tracking is disabled.
+func (t *Taint) RouterConfig() *router.TaintTracking {
+	if !t.Enabled {
+		return nil
+	}
+	return &router.TaintTracking{
+		MinLength:         t.MinLength,
+		MaxBytes:          t.MaxBytes,
+		CriticalArguments: t.CriticalArguments,
+		Action:            router.TaintAction(t.Action),
+		RiskScore:         t.RiskScore,
+	}
+}
+
+// Audit configures the audit trail's sinks; see package audit. Each
+// configured sink receives every record; none disables the trail.
+type Audit struct {
+	// File receives records as JSON lines (empty disables)
+	File string `json:"file"`
+
+	// MaxBytes rotates File at this size (zero never rotates)
+	MaxBytes int64 `json:"max_bytes"`
+
+	// MaxFiles is the number of rotated files kept (zero keeps 5)
+	MaxFiles int `json:"max_files"`
+
+	// Syslog sends records to syslog: "local" for the local daemon, or
+	// udp://host:port or tcp://host:port (empty disables)
+	Syslog string `json:"syslog"`
+
+	// URL receives batches of records by HTTP POST (empty disables)
+	URL string `json:"url"`
+
+	// Chain links the records by SHA-256 hashes so tampering is
+	// evident; see audit.ChainSink. A File trail continues the chain it
+	// holds across restarts
+	Chain bool `json:"chain"`
+
+	// PayloadBytes records up to this many bytes of each tool call's
+	// arguments and response (zero records none)
+	PayloadBytes int `json:"payload_bytes"`
+
+	// EncryptFields lists the record fields encrypted to EncryptionKey:
+	// arguments, result, reason, reasons, original (empty encrypts none)
+	EncryptFields []string `json:"encrypt_fields"`
+
+	// EncryptionKey is the base64 X25519 public key of whoever may read
+	// the encrypted fields, from "mcp-sentinel-proxy audit keygen"; see
+	// audit.EncryptSink
+	EncryptionKey string `json:"encryption_key"`
+
+	// Stream serves the records as they are written at the admin
+	// endpoint GET /audit/stream; it requires admin and admin_token
+	Stream bool `json:"stream"`
+}
+
+// validate checks the audit settings without opening any sink.
+func (a *Audit) validate() error {
+	if a.MaxBytes < 0 {
+		return invalid("audit.max_bytes", "must not be negative")
+	}
+	if a.MaxFiles < 0 {
+		return invalid("audit.max_files", "must not be negative, got %d", a.MaxFiles)
+	}
+	if a.Syslog != "" && a.Syslog != "local" {
+		parsed, err := url.Parse(a.Syslog)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "udp" && parsed.Scheme != "tcp") {
+			return invalid("audit.syslog", "must be local or a udp:// or tcp:// address, got %q", a.Syslog)
+		}
+	}
+	if a.URL != "" {
+		parsed, err := url.Parse(a.URL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return invalid("audit.url", "must be an http or https URL, got %q", a.URL)
+		}
+	}
+	if a.PayloadBytes < 0 {
+		return invalid("audit.payload_bytes", "must not be negative, got %d", a.PayloadBytes)
+	}
+	if len(a.EncryptFields) > 0 && a.EncryptionKey == "" {
+		return invalid("audit.encryption_key", "is required with audit.encrypt_fields")
+	}
+	if a.EncryptionKey != "" {
+		if _, err := audit.ParsePublicKey(a.EncryptionKey); err != nil {
+			return invalid("audit.encryption_key", "%v", err)
+		}
+	}
+	for _, f := range a.EncryptFields {
+		switch f {
+		case audit.FieldArguments, audit.FieldResult, audit.FieldReason, audit.FieldReasons, audit.FieldOriginal:
+		default:
+			return invalid("audit.encrypt_fields", "unknown field %q (arguments, result, reason, reasons, original)", f)
+		}
+	}
+	return nil
+}
+
+// Open opens the configured sinks and returns them as one, or nil when
+// none is configured. The extra sinks, such as the audit.Broadcaster
+// of Stream, receive the records along with the configured ones,
+// chained and encrypted alike; they are not closed if Open fails.
+//
+// # Security Notes
+//
+// Call it before the process is confined to a chroot, while the file
+// and syslog socket paths still resolve.
+func (a *Audit) Open(extra ...audit.Sink) (audit.Sink, error) {
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	var sinks []audit.Sink
+	fail := func(field string, err error) (audit.Sink, error) {
+		audit.Multi(sinks...).Close()
+		return nil, invalid(field, "%v", err)
+	}
+	if a.File != "" {
+		s, err := audit.NewFileSink(a.File, &audit.FileConfig{MaxBytes: a.MaxBytes, MaxFiles: a.MaxFiles})
+		if err != nil {
+			return fail("audit.file", err)
+		}
+		sinks = append(sinks, s)
+	}
+	if a.Syslog != "" {
+		var network, addr string
+		if a.Syslog != "local" {
+			parsed, _ := url.Parse(a.Syslog)
+			network, addr = parsed.Scheme, parsed.Host
+		}
+		s, err := audit.NewSyslogSink(network, addr, "mcp-sentinel")
+		if err != nil {
+			return fail("audit.syslog", err)
+		}
+		sinks = append(sinks, s)
+	}
+	if a.URL != "" {
+		sinks = append(sinks, audit.NewHTTPSink(a.URL, nil))
+	}
+	var from audit.Link
+	if a.Chain && a.File != "" {
+		var err error
+		if from, err = audit.Tail(a.File); err != nil {
+			return fail("audit.chain", fmt.Errorf("%w (rotate the file to start a new chain)", err))
+		}
+	}
+	sinks = append(sinks, extra...)
+	var sink audit.Sink
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		sink = sinks[0]
+	default:
+		sink = audit.Multi(sinks...)
+	}
+	if a.Chain {
+		sink = audit.NewChainSink(sink, from)
+	}
+	if len(a.EncryptFields) > 0 {
+		// Encrypt first so the chain hashes the ciphertext
+		encrypted, err := audit.NewEncryptSink(sink, a.EncryptionKey, a.EncryptFields)
+		if err != nil {
+			sink.Close()
+			return nil, invalid("audit.encryption_key", "%v", err)
+		}
+		sink = encrypted
+	}
+	return sink, nil
+}
+
+// Stdio configures how messages are written to stdio peers; see
+// transport.FlushPolicy. The zero value writes each message at once.
+type Stdio struct {
+	// FlushDelay holds messages for up to this long to write bursts
+	// together, trading latency for throughput (zero disables)
+	FlushDelay time.Duration `json:"flush_delay"`
+
+	// BufferSize is the most bytes held before a flush (zero uses
+	// transport.DefaultFrameBufferSize)
+	BufferSize int `json:"buffer_size"`
+}
+
+// validate checks the stdio settings.
+func (s *Stdio) validate() error {
+	if s.FlushDelay < 0 {
+		return invalid("stdio.flush_delay", "must not be negative, got %s", s.FlushDelay)
+	}
+	if s.BufferSize < 0 {
+		return invalid("stdio.buffer_size", "must not be negative, got %d", s.BufferSize)
+	}
+	return nil
+}
+
+// FlushPolicy returns the transport flush policy, or nil for the zero
+// value.
+func (s *Stdio) FlushPolicy() *transport.FlushPolicy {
+	if *s == (Stdio{}) {
+		return nil
+	}
+	return &transport.FlushPolicy{Delay: s.FlushDelay, BufferSize: s.BufferSize}
+}
+
+// Tracing configures OpenTelemetry tracing of the routing pipeline;
+// see package tracing. It is disabled without an endpoint.
+type Tracing struct {
+	// Endpoint is the OTLP/HTTP traces URL of a collector, such as
+	// http://localhost:4318/v1/traces; spans are sent JSON-encoded
+	Endpoint string `json:"endpoint"`
+
+	// ServiceName is the service.name resource attribute (empty uses
+	// tracing.DefaultServiceName)
+	ServiceName string `json:"service_name"`
+
+	// Headers are added to every export request, e.g. a collector API
+	// key; their values are secrets
+	Headers map[string]string `json:"headers" secret:"true"`
+
+	// SampleRatio is the fraction of messages traced, from 0 to 1
+	// (zero traces every message)
+	SampleRatio float64 `json:"sample_ratio"`
+
+	// Interval is the longest a span waits to be exported (zero uses
+	// 5s)
+	Interval time.Duration `json:"interval"`
+}
+
+// validate checks the tracing settings.
+func (t *Tracing) validate() error {
+	if t.Endpoint != "" {
+		parsed, err := url.Parse(t.Endpoint)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return invalid("tracing.endpoint", "must be an http or https URL, got %q", t.Endpoint)
+		}
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return invalid("tracing.sample_ratio", "must be between 0 and 1, got %v", t.SampleRatio)
+	}
+	if t.Interval < 0 {
+		return invalid("tracing.interval", "must not be negative, got %s", t.Interval)
+	}
+	for name := range t.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return invalid("tracing.headers", "malformed header name %q", name)
+		}
+	}
+	return nil
+}
+
+// Tracer returns a tracer exporting to the endpoint, or nil when
+// tracing is disabled. Close it to send the last spans.
+func (t *Tracing) Tracer() (*tracing.Tracer, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if t.Endpoint == "" {
+		return nil, nil
+	}
+	header := make(http.Header)
+	for name, value := range t.Headers {
+		header.Set(name, value)
+	}
+	exporter := tracing.NewOTLPExporter(t.Endpoint, &tracing.OTLPConfig{
+		ServiceName:   t.ServiceName,
+		FlushInterval: t.Interval,
+		Header:        header,
+	})
+	return tracing.New(exporter, &tracing.Config{SampleRatio: t.SampleRatio}), nil
+}
+
+// Usage configures the usage accounting export; see package usage. It
+// is disabled without a file or endpoint.
+type Usage struct {
+	// Tenant labels every record
+	Tenant string `json:"tenant"`
+
+	// File appends records to a file in Format
+	File string `json:"file"`
+
+	// Format is csv or json for File (empty uses json); an Endpoint
+	// always receives otlp
+	Format string `json:"format"`
+
+	// Endpoint is the OTLP/HTTP metrics URL of a collector, such as
+	// http://localhost:4318/v1/metrics
+	Endpoint string `json:"endpoint"`
+
+	// Headers are added to every export request; their values are
+	// secrets
+	Headers map[string]string `json:"headers" secret:"true"`
+
+	// Interval is how often records are written (zero uses
+	// usage.DefaultInterval)
+	Interval time.Duration `json:"interval"`
+}
+
+// validate checks the export settings.
+func (u *Usage) validate() error {
+	if u.File != "" && u.Endpoint != "" {
+		return invalid("usage", "set file or endpoint, not both")
+	}
+	switch u.Format {
+	case "", usage.FormatCSV, usage.FormatJSON:
+		if u.Endpoint != "" && u.Format != "" {
+			return invalid("usage.format", "an endpoint receives %s, got %q", usage.FormatOTLP, u.Format)
+		}
+	case usage.FormatOTLP:
+		if u.Endpoint == "" {
+			return invalid("usage.format", "%s needs an endpoint", usage.FormatOTLP)
+		}
+	default:
+		return invalid("usage.format", "must be %s, %s, or %s, got %q", usage.FormatCSV, usage.FormatJSON, usage.FormatOTLP, u.Format)
+	}
+	if u.Endpoint != "" {
+		parsed, err := url.Parse(u.Endpoint)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return invalid("usage.endpoint", "must be an http or https URL, got %q", u.Endpoint)
+		}
+	}
+	if u.Interval < 0 {
+		return invalid("usage.interval", "must not be negative, got %s", u.Interval)
+	}
+	for name := range u.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return invalid("usage.headers", "malformed header name %q", name)
+		}
+	}
+	return nil
+}
+
+// Exporter returns a usage exporter, or nil when the export is
+// disabled. Close it to write the last records.
+func (u *Usage) Exporter() (*usage.Exporter, error) {
+	if err := u.validate(); err != nil {
+		return nil, err
+	}
+	var w usage.Writer
+	switch {
+	case u.Endpoint != "":
+		header := make(http.Header)
+		for name, value := range u.Headers {
+			header.Set(name, value)
+		}
+		w = usage.NewOTLPWriter(u.Endpoint, &usage.OTLPConfig{Header: header})
+	case u.File != "":
+		format := u.Format
+		if format == "" {
+			format = usage.FormatJSON
+		}
+		var err error
+		if w, err = usage.OpenFile(u.File, format); err != nil {
+			return nil, invalid("usage.file", "%v", err)
+		}
+	default:
+		return nil, nil
+	}
+	return usage.NewExporter(w, &usage.Config{Tenant: u.Tenant, Interval: u.Interval}), nil
+}
+
+// Session state backends.
+const (
+	StateMemory = "memory"
+	StateFile   = "file"
+)
+
+// DefaultStateKey is the key session state is saved under when
+// SessionState.Key is empty.
+const DefaultStateKey = "default"
+
+// SessionState configures persistence of session state; see package
+// sessionstate. It is disabled without a backend.
+type SessionState struct {
+	// Backend is memory or file; there are no database backends
+	Backend string `json:"backend"`
+
+	// Path is the state file of the file backend
+	Path string `json:"path"`
+
+	// Key identifies this proxy's sessions in the store, so proxies
+	// sharing a file keep apart (empty uses DefaultStateKey)
+	Key string `json:"key"`
+
+	// Resumption keys each session's state by a token issued at
+	// initialize instead of Key, so a client reconnecting over another
+	// transport resumes its session by presenting the token
+	Resumption bool `json:"resumption"`
+}
+
+// validate checks the session state settings without opening the
+// store.
+func (s *SessionState) validate() error {
+	switch s.Backend {
+	case "", StateMemory:
+	case StateFile:
+		if s.Path == "" {
+			return invalid("session_state.path", "is required with the file backend")
+		}
+	default:
+		return invalid("session_state.backend", "must be memory or file, got %q", s.Backend)
+	}
+	if s.Resumption && s.Backend == "" {
+		return invalid("session_state.resumption", "needs a backend")
+	}
+	if s.Resumption && s.Key != "" {
+		return invalid("session_state.key", "does not apply with resumption, which keys state by token")
+	}
+	return nil
+}
+
+// Open returns the configured store, or nil when persistence is
+// disabled.
+//
+// # Security Notes
+//
+// Call it before the process is confined to a chroot, while the state
+// file path still resolves.
+func (s *SessionState) Open() (sessionstate.Store, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	switch s.Backend {
+	case StateMemory:
+		return sessionstate.NewMemoryStore(), nil
+	case StateFile:
+		store, err := sessionstate.OpenFileStore(s.Path)
+		if err != nil {
+			return nil, invalid("session_state.path", "%v", err)
+		}
+		return store, nil
+	}
+	return nil, nil
+}
+
+// Affinity configures consistent-hash session affinity across proxy
+// replicas; see package affinity. It is disabled without replicas.
+type Affinity struct {
+	// Self is this replica's ID among Replicas
+	Self string `json:"self"`
+
+	// Replicas lists every replica, with the same list on each
+	Replicas []Replica `json:"replicas"`
+
+	// Handoff is redirect or proxy (empty redirects)
+	Handoff string `json:"handoff"`
+
+	// VirtualNodes is the number of ring points per replica (zero uses
+	// the affinity default)
+	VirtualNodes int `json:"virtual_nodes"`
+}
+
+// Replica is one proxy replica.
+type Replica struct {
+	// ID names the replica
+	ID string `json:"id"`
+
+	// URL is the replica's base URL, http(s)://host[:port]
+	URL string `json:"url"`
+}
+
+// validate checks that enabled affinity can route sessions.
+func (a *Affinity) validate(mode string) error {
+	cfg := a.Config()
+	if cfg == nil {
+		if a.Self != "" || a.Handoff != "" {
+			return invalid("affinity.replicas", "is required to enable affinity")
+		}
+		return nil
+	}
+	if mode != "streamable-http" {
+		return invalid("affinity", "requires mode streamable-http, got %q", mode)
+	}
+	if a.VirtualNodes < 0 {
+		return invalid("affinity.virtual_nodes", "must not be negative, got %d", a.VirtualNodes)
+	}
+	if _, err := affinity.New(cfg); err != nil {
+		return invalid("affinity", "%v", err)
+	}
+	return nil
+}
+
+// Config returns the affinity configuration, or nil when affinity is
+// disabled.
+func (a *Affinity) Config() *affinity.Config {
+	if len(a.Replicas) == 0 {
+		return nil
+	}
+	cfg := &affinity.Config{Self: a.Self, Handoff: a.Handoff, VirtualNodes: a.VirtualNodes}
+	for _, r := range a.Replicas {
+		cfg.Replicas = append(cfg.Replicas, affinity.Replica{ID: r.ID, URL: r.URL})
+	}
+	return cfg
+}
+
+// Redaction configures detection of secrets in tool call arguments
+// and results; see package secrets. It is disabled without a mode.
+type Redaction struct {
+	// Mode is redact, block, or log
+	Mode string `json:"mode"`
+
+	// Disable lists built-in rules to skip, such as high-entropy
+	Disable []string `json:"disable"`
+
+	// Patterns maps extra rule names to regular expressions; a
+	// pattern's first capture group, if it has one, is the secret
+	Patterns map[string]string `json:"patterns"`
+
+	// EntropyThreshold is the entropy in bits per character at which a
+	// random-looking string is a secret (zero uses 4.0)
+	EntropyThreshold float64 `json:"entropy_threshold"`
+
+	// EntropyMinLength is the shortest string the entropy heuristic
+	// considers (zero uses 24)
+	EntropyMinLength int `json:"entropy_min_length"`
+}
+
+// validate checks the redaction settings.
+func (r *Redaction) validate() error {
+	switch secrets.Action(r.Mode) {
+	case "", secrets.ActionRedact, secrets.ActionBlock, secrets.ActionLog:
+	default:
+		return invalid("redaction.mode", "must be redact, block, or log, got %q", r.Mode)
+	}
+	if r.EntropyThreshold < 0 {
+		return invalid("redaction.entropy_threshold", "must not be negative, got %v", r.EntropyThreshold)
+	}
+	if r.EntropyMinLength < 0 {
+		return invalid("redaction.entropy_min_length", "must not be negative, got %d", r.EntropyMinLength)
+	}
+	if _, err := secrets.New(r.config()); err != nil {
+		return invalid("redaction.patterns", "%v", err)
+	}
+	return nil
+}
+
+// config returns the redactor configuration.
+func (r *Redaction) config() secrets.Config {
+	return secrets.Config{
+		Disable:          r.Disable,
+		Extra:            r.Patterns,
+		EntropyThreshold: r.EntropyThreshold,
+		EntropyMinLength: r.EntropyMinLength,
+	}
+}
+
+// Middleware returns the redaction middleware for the router's
+// middleware chain, or nil when redaction is disabled.
+func (r *Redaction) Middleware() (middleware.Middleware, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	if r.Mode == "" {
+		return nil, nil
+	}
+	redactor, err := secrets.New(r.config())
+	if err != nil {
+		return nil, err
+	}
+	return redactor.Middleware(secrets.Action(r.Mode)), nil
+}
+
+// RateLimit configures the tool call rate limits; see package
+// ratelimit. It is disabled when no limit is set.
+type RateLimit struct {
+	// Global limits the calls of all sessions together
+	Global ratelimit.Limit `json:"global"`
+
+	// Session limits each session's calls
+	Session ratelimit.Limit `json:"session"`
+
+	// Tools limit calls by tool name or pattern; the first match
+	// applies
+	Tools []ratelimit.ToolLimit `json:"tools"`
+}
+
+// validate checks the rate limits.
+func (r *RateLimit) validate() error {
+	if cfg := r.LimiterConfig(); cfg != nil {
+		if err := cfg.Validate(); err != nil {
+			return invalid("rate_limit", "%v", err)
+		}
+	}
+	return nil
+}
+
+// LimiterConfig returns the ratelimit.Config of the limits, or nil if
+// none is set.
+func (r *RateLimit) LimiterConfig() *ratelimit.Config {
+	if r.Global == (ratelimit.Limit{}) && r.Session == (ratelimit.Limit{}) && len(r.Tools) == 0 {
+		return nil
+	}
+	return &ratelimit.Config{Global: r.Global, Session: r.Session, Tools: r.Tools}
+}
+
+// Schedule configures time-window rules for tool calls; see package
+// schedule.
+type Schedule struct {
+	// Enabled turns the scheduler on, and with it the admin API's
+	// maintenance mode and rule overrides
+	Enabled bool `json:"enabled"`
+
+	// Rules are evaluated in order; the first applying rule denies
+	Rules []schedule.Rule `json:"rules"`
+
+	// ReadOnlyTools pass mutating-scope rules and maintenance mode
+	ReadOnlyTools []string `json:"read_only_tools"`
+
+	// TimeZone is the IANA zone of the windows, such as Europe/Berlin
+	// (empty uses local time)
+	TimeZone string `json:"time_zone"`
+
+	// Limits cap the calls in flight per tool category, shared by all
+	// sessions
+	Limits []schedule.Limit `json:"limits"`
+
+	// LimitWait is how long a call waits for a free slot before it is
+	// refused (zero refuses at once)
+	LimitWait time.Duration `json:"limit_wait"`
+}
+
+// validate checks the rules, limits, and time zone.
+func (s *Schedule) validate() error {
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return invalid("schedule.time_zone", "%v", err)
+	}
+	if _, err := schedule.New(&schedule.Config{Rules: s.Rules}); err != nil {
+		return invalid("schedule.rules", "%v", err)
+	}
+	if _, err := schedule.New(&schedule.Config{Limits: s.Limits}); err != nil {
+		return invalid("schedule.limits", "%v", err)
+	}
+	if s.LimitWait < 0 {
+		return invalid("schedule.limit_wait", "must not be negative")
+	}
+	return nil
+}
+
+// SchedulerConfig returns the scheduler configuration, or nil when the
+// schedule is disabled.
+func (s *Schedule) SchedulerConfig() *schedule.Config {
+	if !s.Enabled {
+		return nil
+	}
+	// Validated to load; "" is UTC to LoadLocation
+	loc := time.Local
+	if s.TimeZone != "" {
+		loc, _ = time.LoadLocation(s.TimeZone)
+	}
+	return &schedule.Config{
+		Rules:         s.Rules,
+		ReadOnlyTools: s.ReadOnlyTools,
+		Location:      loc,
+		Limits:        s.Limits,
+		LimitWait:     s.LimitWait,
+	}
+}
+
+// Attestation configures signed statements of the running binary and
+// settings; see package attest. It is disabled without a key.
+type Attestation struct {
+	// Key is the base64 Ed25519 private key from attest keygen
+	Key string `json:"key" secret:"true"`
+}
+
+func (a *Attestation) validate() error {
+	if a.Key == "" {
+		return nil
+	}
+	if _, err := attest.ParsePrivateKey(a.Key); err != nil {
+		return invalid("attestation.key", "%v", err)
+	}
+	return nil
+}
+
+// SigningKey returns the attestation signing key, or nil when
+// attestation is disabled.
+func (a *Attestation) SigningKey() (ed25519.PrivateKey, error) {
+	if a.Key == "" {
+		return nil, nil
+	}
+	key, err := attest.ParsePrivateKey(a.Key)
+	if err != nil {
+		return nil, invalid("attestation.key", "%v", err)
+	}
+	return key, nil
+}
+
+// FFI configures the sentinel library's worker pool (see
+// sentinel.PoolConfig) and reloads (see sentinel.Client.Reload).
+type FFI struct {
+	// Library is the shared library SIGUSR2 loads, and the admin API
+	// loads when a request names none (empty: only requests naming a
+	// library reload)
+	Library string `json:"library"`
+
+	// DrainTimeout bounds the wait for checks in flight before the
+	// swap (zero uses DefaultDrainTimeout)
+	DrainTimeout time.Duration `json:"drain_timeout"`
+
+	// Workers is the number of checks the library runs at once (zero
+	// uses the number of CPUs); read at startup only
+	Workers int `json:"workers"`
+
+	// CallTimeout fails each check the library has not answered in time
+	// (zero waits as long as the request does); read at startup only
+	CallTimeout time.Duration `json:"call_timeout"`
+}
+
+// DefaultDrainTimeout is the default FFI.DrainTimeout.
+const DefaultDrainTimeout = 10 * time.Second
+
+// validate checks the reload settings.
+func (f *FFI) validate() error {
+	if f.Library != "" && !filepath.IsAbs(f.Library) {
+		return invalid("ffi.library", "must be an absolute path, got %q", f.Library)
+	}
+	if f.DrainTimeout < 0 {
+		return invalid("ffi.drain_timeout", "must not be negative")
+	}
+	if f.Workers < 0 {
+		return invalid("ffi.workers", "must not be negative")
+	}
+	if f.CallTimeout < 0 {
+		return invalid("ffi.call_timeout", "must not be negative")
+	}
+	return nil
+}
+
+// Pool returns the sentinel worker pool configuration.
+func (f *FFI) Pool() *sentinel.PoolConfig {
+	return &sentinel.PoolConfig{Workers: f.Workers, Timeout: f.CallTimeout}
+}
+
+// Drain returns the drain timeout in effect.
+func (f *FFI) Drain() time.Duration {
+	if f.DrainTimeout == 0 {
+		return DefaultDrainTimeout
+	}
+	return f.DrainTimeout
+}
+
+// SchemaValidation configures tool call argument validation; see
+// router.SchemaValidation.
+type SchemaValidation struct {
+	// Enabled turns argument validation on
+	Enabled bool `json:"enabled"`
+
+	// RequireListed refuses calls to tools without a listed input
+	// schema
+	RequireListed bool `json:"require_listed"`
+}
+
+// RouterConfig returns the router schema validation configuration, or
+// nil when validation is disabled.
+func (s *SchemaValidation) RouterConfig() *router.SchemaValidation {
+	if !s.Enabled {
+		return nil
+	}
+	return &router.SchemaValidation{RequireListed: s.RequireListed}
+}
+
+// ResourceInspection configures checks of resources/read results; see
+// router.ResourceInspection.
+type ResourceInspection struct {
+	// Enabled turns the checks on
+	Enabled bool `json:"enabled"`
+
+	// MaxBytes bounds the content of one result (zero uses the router
+	// default)
+	MaxBytes int `json:"max_bytes"`
+
+	// Servers sets limits by server name; the first match applies
+	Servers []ResourceServer `json:"servers"`
+
+	// Patterns adds injection scanner rules for decoded blobs, by name
+	Patterns map[string]string `json:"patterns"`
+}
+
+// ResourceServer sets the resource limits of servers by name.
+type ResourceServer struct {
+	// Name is a path.Match pattern of the server names, as reported at
+	// initialize
+	Name string `json:"name"`
+
+	// MaxBytes bounds the content of one result (zero uses the
+	// section's max_bytes)
+	MaxBytes int `json:"max_bytes"`
+}
+
+// validate checks the limits and patterns.
+func (ri *ResourceInspection) validate() error {
+	if ri.MaxBytes < 0 {
+		return invalid("resource_inspection.max_bytes", "must not be negative")
+	}
+	for i, s := range ri.Servers {
+		field := fmt.Sprintf("resource_inspection.servers[%d]", i)
+		if _, err := path.Match(s.Name, ""); err != nil || s.Name == "" {
+			return invalid(field+".name", "invalid pattern %q", s.Name)
+		}
+		if s.MaxBytes < 0 {
+			return invalid(field+".max_bytes", "must not be negative")
+		}
+	}
+	if _, err := scanner.New(scanner.Config{Extra: ri.Patterns}); err != nil {
+		return invalid("resource_inspection.patterns", "%v", err)
+	}
+	return nil
+}
+
+// RouterConfig returns the router resource inspection, or nil when it is
+// disabled.
+func (ri *ResourceInspection) RouterConfig() *router.ResourceInspection {
+	if !ri.Enabled {
+		return nil
+	}
+	p := &router.ResourceInspection{MaxBytes: ri.MaxBytes}
+	for _, s := range ri.Servers {
+		p.Servers = append(p.Servers, router.ResourceLimits{Server: s.Name, MaxBytes: s.MaxBytes})
+	}
+	if len(ri.Patterns) > 0 {
+		// Validated to compile
+		p.Scanner, _ = scanner.New(scanner.Config{Extra: ri.Patterns})
+	}
+	return p
+}
+
+// ServerMask configures the sanitized view of the server's identity;
+// see package mask.
+type ServerMask struct {
+	// Enabled turns masking on
+	Enabled bool `json:"enabled"`
+
+	// Name replaces serverInfo.name (empty keeps the server's name)
+	Name string `json:"name"`
+
+	// Version replaces serverInfo.version (empty keeps it, unless
+	// strip_versions removes it)
+	Version string `json:"version"`
+
+	// StripURLs removes URLs from descriptions and drops serverInfo
+	// websiteUrl and icons
+	StripURLs bool `json:"strip_urls"`
+
+	// StripVersions removes version numbers from descriptions and
+	// serverInfo
+	StripVersions bool `json:"strip_versions"`
+
+	// DropInstructions removes the initialize instructions
+	DropInstructions bool `json:"drop_instructions"`
+
+	// Remove lists regular expressions deleted from descriptions and
+	// instructions
+	Remove []string `json:"remove"`
+
+	// MaxDescription truncates descriptions to this many runes (zero
+	// for no limit)
+	MaxDescription int `json:"max_description"`
+}
+
+// maskConfig returns the mask package form of the settings.
+func (m *ServerMask) maskConfig() mask.Config {
+	return mask.Config{
+		Name:             m.Name,
+		Version:          m.Version,
+		StripURLs:        m.StripURLs,
+		StripVersions:    m.StripVersions,
+		DropInstructions: m.DropInstructions,
+		Remove:           m.Remove,
+		MaxDescription:   m.MaxDescription,
+	}
+}
+
+// validate checks the removal patterns and limits.
+func (m *ServerMask) validate() error {
+	if m.MaxDescription < 0 {
+		return invalid("server_mask.max_description", "must not be negative, got %d", m.MaxDescription)
+	}
+	for i, pattern := range m.Remove {
+		if _, err := regexp.Compile(pattern); err != nil || pattern == "" {
+			return invalid(fmt.Sprintf("server_mask.remove[%d]", i), "malformed pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// RouterConfig returns the router's server masker, or nil when masking
+// is disabled.
+func (m *ServerMask) RouterConfig() *mask.Masker {
+	if !m.Enabled {
+		return nil
+	}
+	// Validated to compile
+	masker, _ := mask.New(m.maskConfig())
+	return masker
+}
+
+// ResponseInspection configures sentinel checks of tool result and
+// resource text; see router.ResponseInspection.
+type ResponseInspection struct {
+	// Enabled turns the checks on
+	Enabled bool `json:"enabled"`
+
+	// Action is block or sanitize (empty uses block)
+	Action string `json:"action"`
+
+	// RiskScore is the council risk score of each item (zero uses the
+	// router default)
+	RiskScore float64 `json:"risk_score"`
+
+	// MaxItemBytes truncates each item sent to the sentinel (zero uses
+	// the router default)
+	MaxItemBytes int `json:"max_item_bytes"`
+}
+
+// validate checks the action and limits.
+func (ri *ResponseInspection) validate() error {
+	switch router.ResponseAction(ri.Action) {
+	case "", router.ResponseBlock, router.ResponseSanitize:
+	default:
+		return invalid("response_inspection.action", "must be block or sanitize, got %q", ri.Action)
+	}
+	if ri.RiskScore < 0 || ri.RiskScore > 1 {
+		return invalid("response_inspection.risk_score", "must be between 0 and 1, got %g", ri.RiskScore)
+	}
+	if ri.MaxItemBytes < 0 {
+		return invalid("response_inspection.max_item_bytes", "must not be negative, got %d", ri.MaxItemBytes)
+	}
+	return nil
+}
+
+// RouterConfig returns the router response inspection, or nil when it
+// is disabled.
+func (ri *ResponseInspection) RouterConfig() *router.ResponseInspection {
+	if !ri.Enabled {
+		return nil
+	}
+	return &router.ResponseInspection{
+		Action:       router.ResponseAction(ri.Action),
+		RiskScore:    ri.RiskScore,
+		MaxItemBytes: ri.MaxItemBytes,
+	}
+}
+
+// ToolDescriptions configures scanning of tool metadata in tools/list
+// results; see router.ToolSanitization.
+type ToolDescriptions struct {
+	// Enabled turns the scanning on
+	Enabled bool `json:"enabled"`
+
+	// Action is taken on flagged text: flag, redact, or strip (empty
+	// uses redact)
+	Action string `json:"action"`
+
+	// Patterns adds injection scanner rules, by name
+	Patterns map[string]string `json:"patterns"`
+}
+
+// validate checks the action and patterns.
+func (td *ToolDescriptions) validate() error {
+	switch router.ToolDescriptionAction(td.Action) {
+	case "", router.ToolDescriptionFlag, router.ToolDescriptionRedact, router.ToolDescriptionStrip:
+	default:
+		return invalid("tool_descriptions.action", "must be flag, redact, or strip, got %q", td.Action)
+	}
+	if _, err := scanner.New(scanner.Config{Extra: td.Patterns}); err != nil {
+		return invalid("tool_descriptions.patterns", "%v", err)
+	}
+	return nil
+}
+
+// RouterConfig returns the router tool sanitization, or nil when it is
+// disabled.
+func (td *ToolDescriptions) RouterConfig() *router.ToolSanitization {
+	if !td.Enabled {
+		return nil
+	}
+	p := &router.ToolSanitization{Action: router.ToolDescriptionAction(td.Action)}
+	if len(td.Patterns) > 0 {
+		// Validated to compile
+		p.Scanner, _ = scanner.New(scanner.Config{Extra: td.Patterns})
+	}
+	return p
+}
+
+// Normalization configures Unicode normalization of client messages;
+// see router.Normalization.
+type Normalization struct {
+	// Enabled turns normalization on
+	Enabled bool `json:"enabled"`
+
+	// FoldArguments replaces homoglyphs in string arguments as well
+	FoldArguments bool `json:"fold_arguments"`
+
+	// Block refuses requests whose method or tool name needed
+	// normalizing instead of rewriting them
+	Block bool `json:"block"`
+}
+
+// RouterConfig returns the router normalization, or nil when it is
+// disabled.
+func (n *Normalization) RouterConfig() *router.Normalization {
+	if !n.Enabled {
+		return nil
+	}
+	return &router.Normalization{FoldArguments: n.FoldArguments, Block: n.Block}
+}
+
+// ResourceTemplates configures resource template expansion checks;
+// see router.ResourceTemplatePolicy.
+type ResourceTemplates struct {
+	// Enabled turns the checks on
+	Enabled bool `json:"enabled"`
+
+	// Variables maps template variable names to regular expressions
+	// their decoded values must match in full
+	Variables map[string]string `json:"variables"`
+
+	// Templates lists URI templates to enforce even if the server
+	// does not list them
+	Templates []string `json:"templates"`
+}
+
+// validate checks the patterns and templates.
+func (r *ResourceTemplates) validate() error {
+	for name, pattern := range r.Variables {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return invalid("resource_templates.variables."+name, "%v", err)
+		}
+	}
+	for i, t := range r.Templates {
+		if err := router.ValidateURITemplate(t); err != nil {
+			return invalid(fmt.Sprintf("resource_templates.templates[%d]", i), "%v", err)
+		}
+	}
+	return nil
+}
+
+// RouterConfig returns the router resource template policy, or nil
+// when the checks are disabled.
+func (r *ResourceTemplates) RouterConfig() *router.ResourceTemplatePolicy {
+	if !r.Enabled {
+		return nil
+	}
+	p := &router.ResourceTemplatePolicy{Templates: r.Templates}
+	for name, pattern := range r.Variables {
+		// Anchored so the pattern constrains the whole value
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			continue
+		}
+		if p.Variables == nil {
+			p.Variables = make(map[string]*regexp.Regexp)
+		}
+		p.Variables[name] = re
+	}
+	return p
+}
+
+// Notifications configures the server notification policy; see
+// router.NotificationPolicy. Spoofed proxy notices and refused resource
+// URIs are dropped even when disabled.
+type Notifications struct {
+	// Enabled turns the policy on
+	Enabled bool `json:"enabled"`
+
+	// Methods lists the notification methods relayed to the client
+	// (empty uses router.DefaultServerNotifications)
+	Methods []string `json:"methods"`
+
+	// MaxBytes drops larger notifications (0 for no limit)
+	MaxBytes int `json:"max_bytes"`
+
+	// RequireSubscription drops resource updates for URIs the client
+	// has not subscribed to
+	RequireSubscription bool `json:"require_subscription"`
+}
+
+// validate checks the methods and size limit.
+func (n *Notifications) validate() error {
+	for i, m := range n.Methods {
+		if !strings.HasPrefix(m, "notifications/") {
+			return invalid(fmt.Sprintf("notifications.methods[%d]", i), "%q is not a notification method", m)
+		}
+	}
+	if n.MaxBytes < 0 {
+		return invalid("notifications.max_bytes", "must not be negative")
+	}
+	return nil
+}
+
+// RouterConfig returns the router notification policy, or nil when it
+// is disabled.
+func (n *Notifications) RouterConfig() *router.NotificationPolicy {
+	if !n.Enabled {
+		return nil
+	}
+	p := &router.NotificationPolicy{MaxBytes: n.MaxBytes, RequireSubscription: n.RequireSubscription}
+	if len(n.Methods) > 0 {
+		p.Methods = n.Methods
+	}
+	return p
+}
+
+// Sampling configures screening of server sampling requests; see
+// router.SamplingPolicy.
+type Sampling struct {
+	// Enabled turns screening on
+	Enabled bool `json:"enabled"`
+
+	// Action is applied to requests nothing flagged: allow, deny, or
+	// approve (empty allows)
+	Action string `json:"action"`
+
+	// OnInjection is applied to requests the scanner or council
+	// flagged (empty denies)
+	OnInjection string `json:"on_injection"`
+
+	// Patterns adds injection scanner rules, by name
+	Patterns map[string]string `json:"patterns"`
+
+	// Council submits each request to a council vote as well
+	Council bool `json:"council"`
+
+	// RiskScore is the risk score of council votes (0 uses 0.7)
+	RiskScore float64 `json:"risk_score"`
+
+	// MaxTokens denies requests for longer completions (0 for no limit)
+	MaxTokens int `json:"max_tokens"`
+
+	// AllowAllServersContext relays requests for the context of all
+	// the client's servers
+	AllowAllServersContext bool `json:"allow_all_servers_context"`
+
+	// ApprovalTimeout bounds the wait for an operator's approval (zero
+	// uses router.DefaultSamplingApprovalTimeout)
+	ApprovalTimeout time.Duration `json:"approval_timeout"`
+}
+
+// validate checks the actions, limits, and patterns.
+func (s *Sampling) validate() error {
+	for field, action := range map[string]string{"sampling.action": s.Action, "sampling.on_injection": s.OnInjection} {
+		switch router.SamplingAction(action) {
+		case "", router.SamplingAllow, router.SamplingDeny, router.SamplingApprove:
+		default:
+			return invalid(field, "must be allow, deny, or approve, got %q", action)
+		}
+	}
+	if s.RiskScore < 0 || s.RiskScore > 1 {
+		return invalid("sampling.risk_score", "must be between 0 and 1, got %g", s.RiskScore)
+	}
+	if s.MaxTokens < 0 {
+		return invalid("sampling.max_tokens", "must not be negative, got %d", s.MaxTokens)
+	}
+	if s.ApprovalTimeout < 0 {
+		return invalid("sampling.approval_timeout", "must not be negative")
+	}
+	if _, err := scanner.New(scanner.Config{Extra: s.Patterns}); err != nil {
+		return invalid("sampling.patterns", "%v", err)
+	}
+	return nil
+}
+
+// RouterConfig returns the router sampling policy, or nil when it is
+// disabled.
+func (s *Sampling) RouterConfig() *router.SamplingPolicy {
+	if !s.Enabled {
+		return nil
+	}
+	p := &router.SamplingPolicy{
+		Action:                 router.SamplingAction(s.Action),
+		OnInjection:            router.SamplingAction(s.OnInjection),
+		Council:                s.Council,
+		RiskScore:              s.RiskScore,
+		MaxTokens:              s.MaxTokens,
+		AllowAllServersContext: s.AllowAllServersContext,
+		ApprovalTimeout:        s.ApprovalTimeout,
+	}
+	if len(s.Patterns) > 0 {
+		// Validated to compile
+		p.Scanner, _ = scanner.New(scanner.Config{Extra: s.Patterns})
+	}
+	return p
+}
+
+// Elicitation configures screening of server elicitation requests;
+// see router.ElicitationPolicy.
+type Elicitation struct {
+	// Enabled turns screening on
+	Enabled bool `json:"enabled"`
+
+	// Deny declines every request
+	Deny bool `json:"deny"`
+
+	// SensitiveFields lists the field names stripped (empty uses
+	// router.DefaultSensitiveFields)
+	SensitiveFields []string `json:"sensitive_fields"`
+
+	// Patterns adds injection scanner rules, by name
+	Patterns map[string]string `json:"patterns"`
+}
+
+// validate checks the patterns.
+func (e *Elicitation) validate() error {
+	for i, f := range e.SensitiveFields {
+		if strings.TrimSpace(f) == "" {
+			return invalid(fmt.Sprintf("elicitation.sensitive_fields[%d]", i), "must not be empty")
+		}
+	}
+	if _, err := scanner.New(scanner.Config{Extra: e.Patterns}); err != nil {
+		return invalid("elicitation.patterns", "%v", err)
+	}
+	return nil
+}
+
+// RouterConfig returns the router elicitation policy, or nil when it is
+// disabled.
+func (e *Elicitation) RouterConfig() *router.ElicitationPolicy {
+	if !e.Enabled {
+		return nil
+	}
+	p := &router.ElicitationPolicy{Deny: e.Deny}
+	if len(e.SensitiveFields) > 0 {
+		p.SensitiveFields = e.SensitiveFields
+	}
+	if len(e.Patterns) > 0 {
+		// Validated to compile
+		p.Scanner, _ = scanner.New(scanner.Config{Extra: e.Patterns})
+	}
+	return p
+}
+
+// Roots configures which servers may list the client's roots; see
+// router.RootsPolicy.
+type Roots struct {
+	// Enabled turns the policy on; with no servers listed, every
+	// server is refused
+	Enabled bool `json:"enabled"`
+
+	// Servers lists the server names, as path.Match patterns, that may
+	// list roots
+	Servers []string `json:"servers"`
+
+	// Prefixes limits the roots relayed to URIs beginning with one of
+	// them (empty relays every root)
+	Prefixes []string `json:"prefixes"`
+}
+
+// validate checks the server patterns and prefixes.
+func (r *Roots) validate() error {
+	for i, pattern := range r.Servers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return invalid(fmt.Sprintf("roots.servers[%d]", i), "invalid pattern %q", pattern)
+		}
+	}
+	for i, prefix := range r.Prefixes {
+		if u, err := url.Parse(prefix); err != nil || u.Scheme == "" {
+			return invalid(fmt.Sprintf("roots.prefixes[%d]", i), "%q is not an absolute URI", prefix)
+		}
+	}
+	return nil
+}
+
+// RouterConfig returns the router roots policy, or nil when it is
+// disabled.
+func (r *Roots) RouterConfig() *router.RootsPolicy {
+	if !r.Enabled {
+		return nil
+	}
+	return &router.RootsPolicy{Servers: r.Servers, Prefixes: r.Prefixes}
+}
+
+// PartialResults configures salvage of timed-out tool call output;
+// see router.PartialResults.
+type PartialResults struct {
+	// Enabled turns salvage on
+	Enabled bool `json:"enabled"`
+
+	// MaxBytes bounds the output kept per call (zero uses the router
+	// default)
+	MaxBytes int `json:"max_bytes"`
+}
+
+// RouterConfig returns the router partial result configuration, or nil
+// when salvage is disabled.
+func (p *PartialResults) RouterConfig() *router.PartialResults {
+	if !p.Enabled {
+		return nil
+	}
+	return &router.PartialResults{MaxBytes: p.MaxBytes}
+}
+
+// validate checks the partial result settings.
+func (p *PartialResults) validate() error {
+	if p.MaxBytes < 0 {
+		return invalid("partial_results.max_bytes", "must not be negative, got %d", p.MaxBytes)
+	}
+	return nil
+}
+
+// ReadReceipts configures blocked call notices and escalation; see
+// router.ReadReceipts.
+type ReadReceipts struct {
+	// Enabled turns read receipts on
+	Enabled bool `json:"enabled"`
+
+	// RetryWindow is how soon an identical retry ignores a block (zero
+	// uses the router default)
+	RetryWindow time.Duration `json:"retry_window"`
+
+	// EscalateAfter is the number of ignored blocks that escalates a
+	// session (zero uses the router default)
+	EscalateAfter int `json:"escalate_after"`
+
+	// Escalation is council or block (empty uses council)
+	Escalation string `json:"escalation"`
+}
+
+// RouterConfig returns the router read receipt configuration, or nil
+// when they are disabled.
+func (r *ReadReceipts) RouterConfig() *router.ReadReceipts {
+	if !r.Enabled {
+		return nil
+	}
+	return &router.ReadReceipts{
+		RetryWindow:   r.RetryWindow,
+		EscalateAfter: r.EscalateAfter,
+		Escalation:    router.ReceiptEscalation(r.Escalation),
+	}
+}
+
+// RegistryFastPath configures reuse of registry verifications for
+// identical tool calls; see router.RegistryFastPath.
+type RegistryFastPath struct {
+	// Enabled turns the fast path on
+	Enabled bool `json:"enabled"`
+
+	// Tools restricts the fast path to these tools, usually hot,
+	// read-only ones (empty allows all)
+	Tools []string `json:"tools"`
+
+	// MaxAge bounds how long a verification is reused (zero reuses it
+	// until the server lists its tools again)
+	MaxAge time.Duration `json:"max_age"`
+
+	// MaxEntries bounds the cached verifications (zero uses the router
+	// default)
+	MaxEntries int `json:"max_entries"`
+}
+
+// RouterConfig returns the router fast path configuration, or nil when
+// it is disabled.
+func (f *RegistryFastPath) RouterConfig() *router.RegistryFastPath {
+	if !f.Enabled {
+		return nil
+	}
+	return &router.RegistryFastPath{
+		Tools:      f.Tools,
+		MaxAge:     f.MaxAge,
+		MaxEntries: f.MaxEntries,
+	}
+}
+
+// validate checks the fast path settings.
+func (f *RegistryFastPath) validate() error {
+	for i, name := range f.Tools {
+		if strings.TrimSpace(name) == "" {
+			return invalid(fmt.Sprintf("registry_fast_path.tools[%d]", i), "must not be empty")
+		}
+	}
+	if f.MaxAge < 0 {
+		return invalid("registry_fast_path.max_age", "must not be negative")
+	}
+	if f.MaxEntries < 0 {
+		return invalid("registry_fast_path.max_entries", "must not be negative, got %d", f.MaxEntries)
+	}
+	return nil
+}
+
+// Conformance configures client protocol conformance scoring; see
+// router.Conformance.
+type Conformance struct {
+	// Enabled turns conformance scoring on
+	Enabled bool `json:"enabled"`
+
+	// StrictAt is the score that requires a council vote for every
+	// tool call (zero uses the router default)
+	StrictAt float64 `json:"strict_at"`
+
+	// RejectAt is the score that refuses the client's requests (zero
+	// uses the router default)
+	RejectAt float64 `json:"reject_at"`
+
+	// HalfLife controls how quickly the score decays (zero uses the
+	// router default)
+	HalfLife time.Duration `json:"half_life"`
+
+	// RetryWindow is how soon an identical retry of a blocked call
+	// counts as ignoring the error (zero uses the router default)
+	RetryWindow time.Duration `json:"retry_window"`
+}
+
+// RouterConfig returns the router conformance configuration, or nil
+// when scoring is disabled.
+func (c *Conformance) RouterConfig() *router.Conformance {
+	if !c.Enabled {
+		return nil
+	}
+	return &router.Conformance{
+		StrictAt:    c.StrictAt,
+		RejectAt:    c.RejectAt,
+		HalfLife:    c.HalfLife,
+		RetryWindow: c.RetryWindow,
+	}
+}
+
+// validate checks the conformance settings.
+func (c *Conformance) validate() error {
+	if c.StrictAt < 0 {
+		return invalid("conformance.strict_at", "must not be negative, got %g", c.StrictAt)
+	}
+	if c.RejectAt < 0 {
+		return invalid("conformance.reject_at", "must not be negative, got %g", c.RejectAt)
+	}
+	if c.StrictAt > 0 && c.RejectAt > 0 && c.StrictAt > c.RejectAt {
+		return invalid("conformance.strict_at", "must not exceed reject_at (%g), got %g", c.RejectAt, c.StrictAt)
+	}
+	if c.HalfLife < 0 {
+		return invalid("conformance.half_life", "must not be negative")
+	}
+	if c.RetryWindow < 0 {
+		return invalid("conformance.retry_window", "must not be negative")
+	}
+	return nil
+}
+
+// validate checks the read receipt settings.
+func (r *ReadReceipts) validate() error {
+	switch router.ReceiptEscalation(r.Escalation) {
+	case "", router.ReceiptCouncil, router.ReceiptBlock:
+	default:
+		return invalid("read_receipts.escalation", "must be council or block, got %q", r.Escalation)
+	}
+	if r.RetryWindow < 0 {
+		return invalid("read_receipts.retry_window", "must not be negative")
+	}
+	if r.EscalateAfter < 0 {
+		return invalid("read_receipts.escalate_after", "must not be negative, got %d", r.EscalateAfter)
+	}
+	return nil
+}
+
+// validate checks the taint tracking settings.
+func (t *Taint) validate() error {
+	switch router.TaintAction(t.Action) {
+	case "", router.TaintFlag, router.TaintCouncil, router.TaintBlock:
+	default:
+		return invalid("taint.action", "must be flag, council, or block, got %q", t.Action)
+	}
+	if t.MinLength < 0 {
+		return invalid("taint.min_length", "must not be negative, got %d", t.MinLength)
+	}
+	if t.MaxBytes < 0 {
+		return invalid("taint.max_bytes", "must not be negative, got %d", t.MaxBytes)
+	}
+	if t.RiskScore < 0 || t.RiskScore > 1 {
+		return invalid("taint.risk_score", "must be between 0 and 1, got %g", t.RiskScore)
+	}
+	for i, pattern := range t.CriticalArguments {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return invalid(fmt.Sprintf("taint.critical_arguments[%d]", i), "malformed pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// TLS configures HTTPS and wss:// upstream connections. The zero value
+// uses system defaults.
+type TLS struct {
+	// CAFile is a PEM bundle of CAs trusted instead of the system pool
+	CAFile string `json:"ca_file"`
+
+	// CertFile and KeyFile are a PEM client certificate and its key
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// ServerName overrides the name verified in server certificates
+	ServerName string `json:"server_name"`
+
+	// MinVersion is the lowest accepted TLS version: "1.2" or "1.3"
+	// (empty uses the Go default)
+	MinVersion string `json:"min_version"`
+
+	// InsecureSkipVerify disables server certificate verification;
+	// for testing only
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// Default returns the configuration used for fields a file leaves out.
+func Default() *Config {
+	rc := router.DefaultConfig()
+	return &Config{
+		Mode:           "stdio",
+		Port:           8080,
+		NamespaceTools: true,
+		Gas: Gas{
+			Budget:       rc.GasBudget,
+			MaxCallDepth: rc.MaxCallDepth,
+		},
+		Logging: Logging{ErrorFormat: "text"},
+	}
+}
+
+// Load reads the configuration file at path, applies environment
+// overrides, and validates the result. With an empty path it starts
+// from Default.
+//
+// # Returns
+//   - The validated configuration
+//   - An error wrapping ErrSyntax or ErrInvalid, or a file error
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if cfg, err = Parse(data, FormatOf(path, data)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FormatOf picks the format of a file from its name or content.
+func FormatOf(path string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Parse decodes a configuration over Default. It does not validate.
+func Parse(data []byte, format Format) (*Config, error) {
+	var tree interface{}
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("%w: data after the top-level object", ErrSyntax)
+		}
+	case FormatYAML:
+		var err error
+		if tree, err = decodeYAML(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalid, format)
+	}
+
+	cfg := Default()
+	if tree == nil {
+		return cfg, nil
+	}
+	if _, ok := tree.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%w: the top level must be a mapping", ErrInvalid)
+	}
+	if err := assign("", reflect.ValueOf(cfg).Elem(), tree); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ApplyEnv overrides fields from environment variables found by lookup
+// (usually os.LookupEnv).
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv("", reflect.ValueOf(c).Elem(), lookup)
+}
+
+// Validate checks every field, reporting the first invalid one.
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case "stdio", "streamable-http", "sse", "ws":
+	default:
+		return invalid("mode", "must be stdio, streamable-http, sse, or ws, got %q", c.Mode)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return invalid("port", "must be between 1 and 65535, got %d", c.Port)
+	}
+	if err := c.validateUpstreams(); err != nil {
+		return err
+	}
+	if c.RequestTimeout < 0 {
+		return invalid("request_timeout", "must not be negative")
+	}
+	if c.MaxProxyHops < 0 {
+		return invalid("max_proxy_hops", "must not be negative")
+	}
+	if err := c.PartialResults.validate(); err != nil {
+		return err
+	}
+	if c.Gas.Budget == 0 {
+		return invalid("gas.budget", "must be positive")
+	}
+	if c.Gas.MaxCallDepth < 1 {
+		return invalid("gas.max_call_depth", "must be positive, got %d", c.Gas.MaxCallDepth)
+	}
+	for i, name := range c.HighRiskTools {
+		if strings.TrimSpace(name) == "" {
+			return invalid(fmt.Sprintf("high_risk_tools[%d]", i), "must not be empty")
+		}
+	}
+	if err := router.ValidateStageOrder(c.CheckOrder, nil); err != nil {
+		return invalid("check_order", "%v", err)
+	}
+	for _, list := range []struct {
+		field    string
+		patterns []string
+	}{{"policy.allow", c.Policy.Allow}, {"policy.deny", c.Policy.Deny}} {
+		for i, pattern := range list.patterns {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return invalid(fmt.Sprintf("%s[%d]", list.field, i), "malformed tool pattern %q", pattern)
+			}
+		}
+	}
+	if set := c.Policy.Set(); set != nil {
+		if err := set.Validate(); err != nil {
+			return fmt.Errorf("%w: policy.rules: %w", ErrInvalid, err)
+		}
+	}
+	switch c.Logging.ErrorFormat {
+	case "text", "json":
+	default:
+		return invalid("logging.error_format", "must be text or json, got %q", c.Logging.ErrorFormat)
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+	if err := c.Chain.validate(); err != nil {
+		return err
+	}
+	if err := c.Taint.validate(); err != nil {
+		return err
+	}
+	if err := c.ResourceTemplates.validate(); err != nil {
+		return err
+	}
+	if err := c.Notifications.validate(); err != nil {
+		return err
+	}
+	if err := c.Sampling.validate(); err != nil {
+		return err
+	}
+	if err := c.ContextBudget.validate(); err != nil {
+		return err
+	}
+	if err := c.Elicitation.validate(); err != nil {
+		return err
+	}
+	if err := c.Roots.validate(); err != nil {
+		return err
+	}
+	if err := c.ResourceInspection.validate(); err != nil {
+		return err
+	}
+	if err := c.ResponseInspection.validate(); err != nil {
+		return err
+	}
+	if err := c.ToolDescriptions.validate(); err != nil {
+		return err
+	}
+	if err := c.ServerMask.validate(); err != nil {
+		return err
+	}
+	if err := c.ReadReceipts.validate(); err != nil {
+		return err
+	}
+	if err := c.Conformance.validate(); err != nil {
+		return err
+	}
+	if err := c.RegistryFastPath.validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.validate(); err != nil {
+		return err
+	}
+	if c.Audit.Stream && (c.Admin == "" || c.AdminToken == "") {
+		return invalid("audit.stream", "requires admin and admin_token")
+	}
+	if err := c.Stdio.validate(); err != nil {
+		return err
+	}
+	if err := c.Tracing.validate(); err != nil {
+		return err
+	}
+	if err := c.Usage.validate(); err != nil {
+		return err
+	}
+	if err := c.SessionState.validate(); err != nil {
+		return err
+	}
+	if err := c.Affinity.validate(c.Mode); err != nil {
+		return err
+	}
+	if err := c.Redaction.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.Schedule.validate(); err != nil {
+		return err
+	}
+	if err := c.Attestation.validate(); err != nil {
+		return err
+	}
+	if err := c.FFI.validate(); err != nil {
+		return err
+	}
+	return c.SLO.validate()
+}
+
+// validateUpstreams checks the upstream list and its fit with Mode.
+func (c *Config) validateUpstreams() error {
+	seen := make(map[string]bool)
+	for i, u := range c.Upstreams {
+		field := fmt.Sprintf("upstreams[%d]", i)
+		switch {
+		case len(c.Upstreams) > 1 && u.Name == "":
+			return invalid(field+".name", "is required with several upstreams")
+		case strings.ContainsAny(u.Name, " \t="):
+			return invalid(field+".name", "must not contain spaces or '=', got %q", u.Name)
+		case u.Name != "" && seen[u.Name]:
+			return invalid(field+".name", "duplicates upstream %q", u.Name)
+		case u.URL == "" && len(u.Command) == 0 && len(u.Tools) == 0:
+			return invalid(field, "needs a url, a command, or tools")
+		case u.URL != "" && len(u.Command) > 0:
+			return invalid(field, "has both a url and a command")
+		case len(u.Tools) > 0 && (u.URL != "" || len(u.Command) > 0):
+			return invalid(field, "has tools and a url or a command")
+		case u.Sandbox.Enabled && u.URL != "":
+			return invalid(field+".sandbox", "applies to a command or tools, not a url")
+		}
+		switch sandbox.NetworkScope(u.Sandbox.Network) {
+		case "", sandbox.NetworkAll, sandbox.NetworkLoopback, sandbox.NetworkNone:
+		default:
+			return invalid(field+".sandbox.network", "must be all, loopback, or none, got %q", u.Sandbox.Network)
+		}
+		seen[u.Name] = true
+		if err := validateOneShotTools(field, u.Tools); err != nil {
+			return err
+		}
+		if u.URL != "" {
+			parsed, err := url.Parse(u.URL)
+			if err != nil || parsed.Host == "" {
+				return invalid(field+".url", "must be an absolute URL, got %q", u.URL)
+			}
+			switch parsed.Scheme {
+			case "http", "https", "ws", "wss":
+			default:
+				return invalid(field+".url", "scheme must be http, https, ws, or wss, got %q", parsed.Scheme)
+			}
+			if (c.Mode == "streamable-http" || c.Mode == "sse") && isSelf(parsed, c.Port) {
+				return invalid(field+".url", "leads back to this proxy's own port %d", c.Port)
+			}
+		}
+		for j, arg := range u.Command {
+			if j == 0 && strings.TrimSpace(arg) == "" {
+				return invalid(field+".command[0]", "must name a program")
+			}
+		}
+	}
+
+	if c.Mode == "ws" {
+		if len(c.Upstreams) != 1 || !strings.HasPrefix(c.Upstreams[0].URL, "ws") {
+			return invalid("upstreams", "mode ws requires exactly one ws:// or wss:// upstream")
+		}
+	}
+	return nil
+}
+
+// isSelf reports whether u addresses the local host at port.
+func isSelf(u *url.URL, port int) bool {
+	p := u.Port()
+	if p == "" {
+		p = "80"
+		if u.Scheme == "https" || u.Scheme == "wss" {
+			p = "443"
+		}
+	}
+	if p != strconv.Itoa(port) {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// validateOneShotTools checks the tools of a one-shot upstream.
+func validateOneShotTools(field string, tools []OneShotTool) error {
+	seen := make(map[string]bool)
+	for i, t := range tools {
+		tf := fmt.Sprintf("%s.tools[%d]", field, i)
+		switch {
+		case t.Name == "":
+			return invalid(tf+".name", "is required")
+		case seen[t.Name]:
+			return invalid(tf+".name", "duplicates tool %q", t.Name)
+		case t.URL == "" && len(t.Command) == 0:
+			return invalid(tf, "needs a url or a command")
+		case t.URL != "" && len(t.Command) > 0:
+			return invalid(tf, "has both a url and a command")
+		case len(t.Command) > 0 && strings.TrimSpace(t.Command[0]) == "":
+			return invalid(tf+".command[0]", "must name a program")
+		case t.Warm > 0 && t.URL != "":
+			return invalid(tf+".warm", "applies only to commands")
+		case t.Timeout < 0:
+			return invalid(tf+".timeout", "must not be negative, got %v", t.Timeout)
+		case t.MaxConcurrent < 0 || t.Warm < 0 || t.MaxOutput < 0:
+			return invalid(tf, "max_concurrent, warm, and max_output must not be negative")
+		}
+		seen[t.Name] = true
+		if t.URL != "" {
+			parsed, err := url.Parse(t.URL)
+			if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+				return invalid(tf+".url", "must be an absolute http or https URL, got %q", t.URL)
+			}
+		}
+		if t.InputSchema != "" {
+			var schema map[string]interface{}
+			if err := json.Unmarshal([]byte(t.InputSchema), &schema); err != nil || schema == nil {
+				return invalid(tf+".input_schema", "must be a JSON object")
+			}
+		}
+		for _, kv := range t.Env {
+			if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+				return invalid(tf+".env", "entries must be KEY=VALUE, got %q", kv)
+			}
+		}
+	}
+	return nil
+}
+
+// RouterConfig returns router.DefaultConfig with the configured gas
+// limits, request timeout, high-risk tools, tool policy, chaining, taint tracking, schema
+// validation, read receipts, and conformance scoring applied. The policy engine is
+// created by the caller from Policy.Set, since it is shared across
+// sessions.
+func (c *Config) RouterConfig() *router.Config {
+	rc := router.DefaultConfig()
+	rc.GasBudget = c.Gas.Budget
+	rc.MaxCallDepth = c.Gas.MaxCallDepth
+	rc.RequestTimeout = c.RequestTimeout
+	rc.OrphanAfter = c.OrphanAfter
+	rc.DistinctErrorCodes = c.DistinctErrorCodes
+	rc.ExplainBlocks = c.ExplainBlocks
+	rc.PartialResults = c.PartialResults.RouterConfig()
+	rc.AuditPayloadBytes = c.Audit.PayloadBytes
+	settings := c.RouterSettings()
+	rc.HighRiskTools = settings.HighRiskTools
+	rc.StageOrder = c.CheckOrder
+	rc.ToolPolicy = settings.ToolPolicy
+	rc.Chain = c.Chain.RouterConfig()
+	rc.TaintTracking = c.Taint.RouterConfig()
+	rc.SchemaValidation = c.SchemaValidation.RouterConfig()
+	rc.ResourceTemplates = c.ResourceTemplates.RouterConfig()
+	rc.Notifications = c.Notifications.RouterConfig()
+	rc.Sampling = c.Sampling.RouterConfig()
+	rc.ContextBudget = c.ContextBudget.RouterConfig()
+	rc.Elicitation = c.Elicitation.RouterConfig()
+	rc.Roots = c.Roots.RouterConfig()
+	rc.ResourceInspection = c.ResourceInspection.RouterConfig()
+	rc.ResponseInspection = c.ResponseInspection.RouterConfig()
+	rc.ToolSanitization = c.ToolDescriptions.RouterConfig()
+	rc.ServerMask = c.ServerMask.RouterConfig()
+	rc.Normalization = c.Normalization.RouterConfig()
+	rc.ReadReceipts = c.ReadReceipts.RouterConfig()
+	rc.Conformance = c.Conformance.RouterConfig()
+	rc.RegistryFastPath = c.RegistryFastPath.RouterConfig()
+	if c.SessionState.Backend != "" {
+		rc.StateKey = c.SessionState.Key
+		if rc.StateKey == "" {
+			rc.StateKey = DefaultStateKey
+		}
+		rc.Resumption = c.SessionState.Resumption
+	}
+	return rc
+}
+
+// RouterSettings returns the router settings a running session can
+// take on with router.Reconfigure: the tool policy and high-risk tools.
+func (c *Config) RouterSettings() router.Settings {
+	var s router.Settings
+	s.HighRiskTools = c.HighRiskTools
+	if len(c.Policy.Allow) > 0 || len(c.Policy.Deny) > 0 {
+		s.ToolPolicy = &router.ToolPolicy{Allow: c.Policy.Allow, Deny: c.Policy.Deny}
+	}
+	return s
+}
+
+// RouterConfig returns the router chain configuration, or nil when
+// chaining is disabled.
+func (c *Chain) RouterConfig() *router.ChainConfig {
+	if c.ProxyID == "" {
+		return nil
+	}
+	rc := &router.ChainConfig{
+		ProxyID:       c.ProxyID,
+		Propagate:     c.Propagate,
+		TrustUpstream: c.TrustUpstream,
+	}
+	if c.Key != "" {
+		rc.Key = []byte(c.Key)
+	}
+	return rc
+}
+
+// validate checks that an enabled chain configuration can work.
+func (c *Chain) validate() error {
+	if c.ProxyID == "" {
+		if c.Key != "" || c.Propagate || c.TrustUpstream {
+			return invalid("chain.proxy_id", "is required to enable chaining")
+		}
+		return nil
+	}
+	if strings.ContainsAny(c.ProxyID, " \t/") {
+		return invalid("chain.proxy_id", "must not contain spaces or '/', got %q", c.ProxyID)
+	}
+	if c.TrustUpstream && c.Key == "" {
+		return invalid("chain.trust_upstream", "requires chain.key")
+	}
+	return nil
+}
+
+// validate checks the objectives and windows.
+func (s *SLO) validate() error {
+	seen := make(map[string]bool)
+	for i, obj := range s.Objectives {
+		field := fmt.Sprintf("slo.objectives[%d]", i)
+		switch {
+		case obj.Name == "":
+			return invalid(field+".name", "is required")
+		case seen[obj.Name]:
+			return invalid(field+".name", "duplicates objective %q", obj.Name)
+		case obj.Target <= 0 || obj.Target >= 1:
+			return invalid(field+".target", "must be between 0 and 1, got %g", obj.Target)
+		case obj.Latency < 0:
+			return invalid(field+".latency", "must be positive")
+		case (obj.Latency > 0) == (len(obj.Verdicts) > 0):
+			return invalid(field, "needs exactly one of latency and verdicts")
+		}
+		seen[obj.Name] = true
+		for j, v := range obj.Verdicts {
+			switch router.Verdict(v) {
+			case router.VerdictAllowed, router.VerdictBlocked, router.VerdictError:
+			default:
+				return invalid(fmt.Sprintf("%s.verdicts[%d]", field, j), "must be allowed, blocked, or error, got %q", v)
+			}
+		}
+	}
+	for i, w := range s.Windows {
+		field := fmt.Sprintf("slo.windows[%d]", i)
+		switch {
+		case w.Short <= 0:
+			return invalid(field+".short", "must be positive")
+		case w.Long < w.Short:
+			return invalid(field+".long", "must not be shorter than short")
+		case w.Rate <= 0:
+			return invalid(field+".rate", "must be positive")
+		}
+	}
+	if s.Interval < 0 {
+		return invalid("slo.interval", "must not be negative")
+	}
+	if s.Webhook != "" {
+		parsed, err := url.Parse(s.Webhook)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return invalid("slo.webhook", "must be an http or https URL, got %q", s.Webhook)
+		}
+	}
+	return nil
+}
+
+// MonitorConfig returns the slo.Config of the objectives, or nil if
+// none are configured.
+func (s *SLO) MonitorConfig() *slo.Config {
+	if len(s.Objectives) == 0 {
+		return nil
+	}
+	cfg := &slo.Config{
+		Objectives: s.Objectives,
+		Interval:   s.Interval,
+		MinEvents:  s.MinEvents,
+		Webhook:    s.Webhook,
+	}
+	if len(s.Windows) > 0 {
+		cfg.Windows = s.Windows
+	}
+	return cfg
+}
+
+// validate checks the TLS settings without reading any files.
+func (t *TLS) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return invalid("tls.cert_file", "and tls.key_file must be set together")
+	}
+	if _, err := t.minVersion(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TLS) minVersion() (uint16, error) {
+	switch t.MinVersion {
+	case "":
+		return 0, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, invalid("tls.min_version", "must be 1.2 or 1.3, got %q", t.MinVersion)
+}
+
+// ClientConfig loads the certificates and returns the client TLS
+// configuration, or nil for the zero value.
+//
+// # Security Notes
+//
+// Call it before the process is confined to a chroot, while the
+// certificate paths still resolve.
+func (t *TLS) ClientConfig() (*tls.Config, error) {
+	if *t == (TLS{}) {
+		return nil, nil
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	min, _ := t.minVersion()
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		MinVersion:         min,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, invalid("tls.ca_file", "%v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, invalid("tls.ca_file", "no PEM certificates in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, invalid("tls.cert_file", "%v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// invalid returns an ErrInvalid error naming field.
+func invalid(field, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalid, field, fmt.Sprintf(format, args...))
+}
+
+// durationType is decoded from text such as "30s".
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldName returns the configuration key of a struct field.
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+// join appends key to a field path.
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// assign stores a decoded value into v, naming path in errors.
+func assign(path string, v reflect.Value, node interface{}) error {
+	if node == nil {
+		// An explicit null keeps the default
+		return nil
+	}
+	if n, ok := node.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			node = i
+		} else if f, err := n.Float64(); err == nil {
+			node = f
+		}
+	}
+
+	if v.Type() == durationType {
+		text, ok := node.(string)
+		d, err := time.ParseDuration(text)
+		if !ok || err != nil {
+			return invalid(path, "expected a duration such as \"500ms\" or \"5m\", got %s", describe(node))
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return invalid(path, "expected a mapping, got %s", describe(node))
+		}
+		fields := make(map[string]int, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			fields[fieldName(v.Type().Field(i))] = i
+		}
+		for key, child := range m {
+			i, ok := fields[key]
+			if !ok {
+				return invalid(join(path, key), "unknown field")
+			}
+			if err := assign(join(path, key), v.Field(i), child); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		items, ok := node.([]interface{})
+		if !ok {
+			return invalid(path, "expected a list, got %s", describe(node))
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if item == nil {
+				return invalid(fmt.Sprintf("%s[%d]", path, i), "must not be null")
+			}
+			if err := assign(fmt.Sprintf("%s[%d]", path, i), s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Map:
+		entries, ok := node.(map[string]interface{})
+		if !ok {
+			return invalid(path, "expected a mapping, got %s", describe(node))
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(entries))
+		for key, entry := range entries {
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(join(path, key), value, entry); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), value)
+		}
+		v.Set(m)
+	case reflect.String:
+		switch n := node.(type) {
+		case string:
+			v.SetString(n)
+		case int64:
+			// Unquoted numbers are accepted where text is expected
+			v.SetString(strconv.FormatInt(n, 10))
+		default:
+			return invalid(path, "expected a string, got %s", describe(node))
+		}
+	case reflect.Bool:
+		b, ok := node.(bool)
+		if !ok {
+			return invalid(path, "expected true or false, got %s", describe(node))
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, ok := node.(int64)
+		if !ok {
+			return invalid(path, "expected an integer, got %s", describe(node))
+		}
+		v.SetInt(n)
+	case reflect.Uint64:
+		n, ok := node.(int64)
+		if !ok || n < 0 {
+			return invalid(path, "expected a non-negative integer, got %s", describe(node))
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float64:
+		switch n := node.(type) {
+		case float64:
+			v.SetFloat(n)
+		case int64:
+			v.SetFloat(float64(n))
+		default:
+			return invalid(path, "expected a number, got %s", describe(node))
+		}
+	default:
+		return invalid(path, "unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// describe names the type of a decoded value for error messages.
+func describe(node interface{}) string {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		return "a mapping"
+	case []interface{}:
+		return "a list"
+	case string:
+		return fmt.Sprintf("%q", n)
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+// applyEnv overrides the scalar and string list fields under v.
+func applyEnv(path string, v reflect.Value, lookup func(string) (string, bool)) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := join(path, fieldName(v.Type().Field(i)))
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(field, fv, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Map || fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.String {
+			continue
+		}
+
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(field, ".", "_"))
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		var node interface{} = value
+		switch fv.Kind() {
+		case reflect.Slice:
+			items := []interface{}{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			node = items
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return invalid(fmt.Sprintf("%s (%s)", name, field), "expected true or false, got %q", value)
+			}
+			node = b
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return invalid(fmt.Sprintf("%s (%s)", name, field), "expected a number, got %q", value)
+			}
+			node = f
+		case reflect.Int, reflect.Int64, reflect.Uint64:
+			if fv.Type() == durationType {
+				// assign parses durations from text
+				break
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return invalid(fmt.Sprintf("%s (%s)", name, field), "expected an integer, got %q", value)
+			}
+			node = n
+		}
+		if err := assign(fmt.Sprintf("%s (%s)", name, field), fv, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,907 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+)
+
+const exampleYAML = `
+mode: stdio
+admin: 127.0.0.1:9090
+upstreams:
+  - name: fs
+    command: [fs-server, /srv]
+  - name: web
+    url: https://web.example/mcp
+  - name: fn
+    tools:
+      - name: resize
+        input_schema: '{"type":"object"}'
+        command: [resize, --stdin]
+        timeout: 10s
+        warm: 2
+gas:
+  budget: 500000
+  max_call_depth: 8
+high_risk_tools: [execute_command, write_file]
+policy:
+  deny: ["*__delete_*"]
+logging:
+  file: /var/log/mcp-sentinel.log
+tls:
+  min_version: "1.3"
+`
+
+const exampleJSON = `{
+  "mode": "stdio",
+  "admin": "127.0.0.1:9090",
+  "upstreams": [
+    {"name": "fs", "command": ["fs-server", "/srv"]},
+    {"name": "web", "url": "https://web.example/mcp"},
+    {"name": "fn", "tools": [{"name": "resize", "input_schema": "{\"type\":\"object\"}", "command": ["resize", "--stdin"], "timeout": "10s", "warm": 2}]}
+  ],
+  "gas": {"budget": 500000, "max_call_depth": 8},
+  "high_risk_tools": ["execute_command", "write_file"],
+  "policy": {"deny": ["*__delete_*"]},
+  "logging": {"file": "/var/log/mcp-sentinel.log"},
+  "tls": {"min_version": "1.3"}
+}`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	want := Default()
+	want.Admin = "127.0.0.1:9090"
+	want.Upstreams = []Upstream{
+		{Name: "fs", Command: []string{"fs-server", "/srv"}},
+		{Name: "web", URL: "https://web.example/mcp"},
+		{Name: "fn", Tools: []OneShotTool{{Name: "resize", InputSchema: `{"type":"object"}`, Command: []string{"resize", "--stdin"}, Timeout: 10 * time.Second, Warm: 2}}},
+	}
+	want.Gas = Gas{Budget: 500000, MaxCallDepth: 8}
+	want.HighRiskTools = []string{"execute_command", "write_file"}
+	want.Policy.Deny = []string{"*__delete_*"}
+	want.Logging.File = "/var/log/mcp-sentinel.log"
+	want.TLS.MinVersion = "1.3"
+
+	for name, doc := range map[string]string{"proxy.yaml": exampleYAML, "proxy.json": exampleJSON, "proxy.conf": exampleJSON} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			os.WriteFile(path, []byte(doc), 0o600)
+			got, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load = %+v, expected %+v", got, want)
+			}
+		})
+	}
+
+	rc := want.RouterConfig()
+	if rc.GasBudget != 500000 || rc.MaxCallDepth != 8 || len(rc.HighRiskTools) != 2 || rc.ToolPolicy == nil {
+		t.Errorf("RouterConfig = %+v", rc)
+	}
+	if Default().RouterConfig().ToolPolicy != nil {
+		t.Error("an empty policy should leave ToolPolicy nil")
+	}
+	want.OrphanAfter = time.Minute
+	if got := want.RouterConfig().OrphanAfter; got != time.Minute {
+		t.Errorf("RouterConfig OrphanAfter = %v", got)
+	}
+	want.DistinctErrorCodes = true
+	if !want.RouterConfig().DistinctErrorCodes {
+		t.Error("RouterConfig DistinctErrorCodes not set")
+	}
+	want.ExplainBlocks = true
+	if !want.RouterConfig().ExplainBlocks {
+		t.Error("RouterConfig ExplainBlocks not set")
+	}
+	if Default().RouterConfig().SchemaValidation != nil {
+		t.Error("schema validation should be off by default")
+	}
+	want.SchemaValidation = SchemaValidation{Enabled: true, RequireListed: true}
+	if sv := want.RouterConfig().SchemaValidation; sv == nil || !sv.RequireListed {
+		t.Errorf("SchemaValidation = %+v", sv)
+	}
+	if Default().RouterConfig().ResourceTemplates != nil {
+		t.Error("resource template checks should be off by default")
+	}
+	want.ResourceTemplates = ResourceTemplates{Enabled: true, Variables: map[string]string{"id": "[0-9]+"}}
+	if rt := want.RouterConfig().ResourceTemplates; rt == nil || !rt.Variables["id"].MatchString("42") || rt.Variables["id"].MatchString("42;x") {
+		t.Errorf("ResourceTemplates = %+v", rt)
+	}
+	if Default().RouterConfig().Notifications != nil {
+		t.Error("the notification policy should be off by default")
+	}
+	want.Notifications = Notifications{Enabled: true, Methods: []string{}, MaxBytes: 4096}
+	if np := want.RouterConfig().Notifications; np == nil || np.Methods != nil || np.MaxBytes != 4096 {
+		t.Errorf("Notifications = %+v", np)
+	}
+	if Default().RouterConfig().ContextBudget != nil {
+		t.Error("the context budget should be off by default")
+	}
+	want.ContextBudget = ContextBudget{Threshold: 1 << 20}
+	if cb := want.RouterConfig().ContextBudget; cb == nil || cb.Threshold != 1<<20 {
+		t.Errorf("ContextBudget = %+v", cb)
+	}
+	if Default().RouterConfig().ResourceInspection != nil {
+		t.Error("resource inspection should be off by default")
+	}
+	want.ResourceInspection = ResourceInspection{Enabled: true, Servers: []ResourceServer{{Name: "docs-*", MaxBytes: 64}}}
+	if ri := want.RouterConfig().ResourceInspection; ri == nil || len(ri.Servers) != 1 || ri.Servers[0].Server != "docs-*" || ri.Servers[0].MaxBytes != 64 {
+		t.Errorf("ResourceInspection = %+v", ri)
+	}
+	if Default().RouterConfig().ResponseInspection != nil {
+		t.Error("response inspection should be off by default")
+	}
+	want.ResponseInspection = ResponseInspection{Enabled: true, Action: "sanitize", RiskScore: 0.7}
+	if ri := want.RouterConfig().ResponseInspection; ri == nil || ri.Action != router.ResponseSanitize || ri.RiskScore != 0.7 {
+		t.Errorf("ResponseInspection = %+v", ri)
+	}
+	if Default().RouterConfig().ToolSanitization != nil {
+		t.Error("tool description scanning should be off by default")
+	}
+	want.ToolDescriptions = ToolDescriptions{Enabled: true, Action: "strip", Patterns: map[string]string{"exfil": "sidenote"}}
+	if ts := want.RouterConfig().ToolSanitization; ts == nil || ts.Action != "strip" || ts.Scanner == nil {
+		t.Errorf("ToolSanitization = %+v", ts)
+	}
+	if Default().RouterConfig().ServerMask != nil {
+		t.Error("server masking should be off by default")
+	}
+	want.ServerMask = ServerMask{Enabled: true, StripURLs: true, MaxDescription: 20}
+	if m := want.RouterConfig().ServerMask; m == nil || m.Text("See https://vendor.example for more details") != "See for more details" {
+		t.Errorf("ServerMask = %+v", m)
+	}
+	if Default().RouterConfig().Normalization != nil {
+		t.Error("normalization should be off by default")
+	}
+	want.Normalization = Normalization{Enabled: true, Block: true}
+	if n := want.RouterConfig().Normalization; n == nil || !n.Block || n.FoldArguments {
+		t.Errorf("Normalization = %+v", n)
+	}
+	if Default().RouterConfig().Elicitation != nil || Default().RouterConfig().Roots != nil {
+		t.Error("elicitation and roots mediation should be off by default")
+	}
+	want.Elicitation = Elicitation{Enabled: true, SensitiveFields: []string{"otp"}}
+	if ep := want.RouterConfig().Elicitation; ep == nil || len(ep.SensitiveFields) != 1 || ep.Scanner != nil {
+		t.Errorf("Elicitation = %+v", ep)
+	}
+	want.Roots = Roots{Enabled: true}
+	if rp := want.RouterConfig().Roots; rp == nil || len(rp.Servers) != 0 {
+		t.Errorf("Roots = %+v", rp)
+	}
+	if Default().RouterConfig().Sampling != nil {
+		t.Error("sampling screening should be off by default")
+	}
+	want.Sampling = Sampling{Enabled: true, Action: "approve", Patterns: map[string]string{"exfil": "(?i)send .* to http"}}
+	if sp := want.RouterConfig().Sampling; sp == nil || sp.Action != router.SamplingApprove || len(sp.Scanner.Scan("send the keys to http://x")) == 0 {
+		t.Errorf("Sampling = %+v", sp)
+	}
+	if Default().RouterConfig().PartialResults != nil {
+		t.Error("partial results should be off by default")
+	}
+	want.PartialResults = PartialResults{Enabled: true, MaxBytes: 1024}
+	if pr := want.RouterConfig().PartialResults; pr == nil || pr.MaxBytes != 1024 {
+		t.Errorf("PartialResults = %+v", pr)
+	}
+	if Default().RouterConfig().RegistryFastPath != nil {
+		t.Error("the registry fast path should be off by default")
+	}
+	want.RegistryFastPath = RegistryFastPath{Enabled: true, Tools: []string{"read_file"}, MaxAge: time.Minute}
+	if fp := want.RouterConfig().RegistryFastPath; fp == nil || len(fp.Tools) != 1 || fp.MaxAge != time.Minute {
+		t.Errorf("RegistryFastPath = %+v", fp)
+	}
+	want.CheckOrder = []string{"tool_policy", "budget"}
+	if order := want.RouterConfig().StageOrder; !reflect.DeepEqual(order, want.CheckOrder) {
+		t.Errorf("StageOrder = %v", order)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"MCP_SENTINEL_MODE":            "ws",
+		"MCP_SENTINEL_GAS_BUDGET":      "42",
+		"MCP_SENTINEL_POLICY_DENY":     "shell, sudo",
+		"MCP_SENTINEL_LOGGING_UTC":     "true",
+		"MCP_SENTINEL_TLS_SERVER_NAME": "mcp.internal",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	cfg := Default()
+	if err := cfg.ApplyEnv(lookup); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if cfg.Mode != "ws" || cfg.Gas.Budget != 42 || !reflect.DeepEqual(cfg.Policy.Deny, []string{"shell", "sudo"}) ||
+		!cfg.Logging.UTC || cfg.TLS.ServerName != "mcp.internal" {
+		t.Errorf("ApplyEnv = %+v", cfg)
+	}
+
+	env["MCP_SENTINEL_GAS_MAX_CALL_DEPTH"] = "deep"
+	err := Default().ApplyEnv(lookup)
+	if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "MCP_SENTINEL_GAS_MAX_CALL_DEPTH (gas.max_call_depth)") {
+		t.Errorf("ApplyEnv with a bad integer = %v", err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		field string
+	}{
+		{"unknown field", "gas:\n  budgt: 5\n", "gas.budgt: unknown field"},
+		{"wrong type", "port: eighty\n", "port: expected an integer"},
+		{"negative budget", "gas: {budget: -1}\n", "gas.budget: expected a non-negative integer"},
+		{"list expected", "high_risk_tools: shell\n", "high_risk_tools: expected a list"},
+		{"list item type", "upstreams:\n  - command: [srv, {a: 1}]\n", "upstreams[0].command[1]: expected a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc), FormatYAML)
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("Parse = %v, expected an error naming %q", err, tt.field)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte(`{"mode": }`), FormatJSON); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Parse of bad JSON = %v, expected ErrSyntax", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		field  string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"mode", func(c *Config) { c.Mode = "tcp" }, "mode"},
+		{"port", func(c *Config) { c.Port = 70000 }, "port"},
+		{"unnamed upstreams", func(c *Config) {
+			c.Upstreams = []Upstream{{URL: "https://a/mcp"}, {Name: "b", URL: "https://b/mcp"}}
+		}, "upstreams[0].name"},
+		{"duplicate upstreams", func(c *Config) {
+			c.Upstreams = []Upstream{{Name: "a", URL: "https://a/mcp"}, {Name: "a", Command: []string{"srv"}}}
+		}, "upstreams[1].name"},
+		{"url and command", func(c *Config) {
+			c.Upstreams = []Upstream{{URL: "https://a/mcp", Command: []string{"srv"}}}
+		}, "upstreams[0]"},
+		{"url scheme", func(c *Config) { c.Upstreams = []Upstream{{URL: "ftp://a/mcp"}} }, "upstreams[0].url"},
+		{"one-shot", func(c *Config) {
+			c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", Command: []string{"a"}, Warm: 1}, {Name: "b", URL: "https://fn/b"}}}}
+		}, ""},
+		{"one-shot and command", func(c *Config) {
+			c.Upstreams = []Upstream{{Command: []string{"srv"}, Tools: []OneShotTool{{Name: "a", Command: []string{"a"}}}}}
+		}, "upstreams[0]"},
+		{"one-shot duplicate tool", func(c *Config) {
+			c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", Command: []string{"a"}}, {Name: "a", URL: "https://fn/a"}}}}
+		}, "upstreams[0].tools[1].name"},
+		{"one-shot tool target", func(c *Config) { c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a"}}}} }, "upstreams[0].tools[0]"},
+		{"one-shot warm url", func(c *Config) {
+			c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", URL: "https://fn/a", Warm: 1}}}}
+		}, "upstreams[0].tools[0].warm"},
+		{"one-shot url scheme", func(c *Config) { c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", URL: "ws://fn/a"}}}} }, "upstreams[0].tools[0].url"},
+		{"one-shot schema", func(c *Config) {
+			c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", Command: []string{"a"}, InputSchema: "[1]"}}}}
+		}, "upstreams[0].tools[0].input_schema"},
+		{"one-shot env", func(c *Config) {
+			c.Upstreams = []Upstream{{Tools: []OneShotTool{{Name: "a", Command: []string{"a"}, Env: []string{"NOVALUE"}}}}}
+		}, "upstreams[0].tools[0].env"},
+		{"sandbox", func(c *Config) {
+			c.Upstreams = []Upstream{{Command: []string{"srv"}, Sandbox: Sandbox{Enabled: true, Network: "loopback", Strict: true}}}
+		}, ""},
+		{"sandbox url", func(c *Config) {
+			c.Upstreams = []Upstream{{URL: "https://a/mcp", Sandbox: Sandbox{Enabled: true}}}
+		}, "upstreams[0].sandbox"},
+		{"sandbox network", func(c *Config) {
+			c.Upstreams = []Upstream{{Command: []string{"srv"}, Sandbox: Sandbox{Enabled: true, Network: "intranet"}}}
+		}, "upstreams[0].sandbox.network"},
+		{"ws mode without ws upstream", func(c *Config) {
+			c.Mode = "ws"
+			c.Upstreams = []Upstream{{URL: "https://a/mcp"}}
+		}, "upstreams"},
+		{"zero budget", func(c *Config) { c.Gas.Budget = 0 }, "gas.budget"},
+		{"check order", func(c *Config) { c.CheckOrder = []string{"tool_policy", "budget"} }, ""},
+		{"check order unknown", func(c *Config) { c.CheckOrder = []string{"tool_policy", "firewall"} }, "check_order"},
+		{"check order repeated", func(c *Config) { c.CheckOrder = []string{"taint", "taint"} }, "check_order"},
+		{"bad pattern", func(c *Config) { c.Policy.Deny = []string{"shell", "[a-"} }, "policy.deny[1]"},
+		{"error format", func(c *Config) { c.Logging.ErrorFormat = "xml" }, "logging.error_format"},
+		{"cert without key", func(c *Config) { c.TLS.CertFile = "cert.pem" }, "tls.cert_file"},
+		{"tls version", func(c *Config) { c.TLS.MinVersion = "1.0" }, "tls.min_version"},
+		{"chain", func(c *Config) { c.Chain = Chain{ProxyID: "edge", Key: "k", Propagate: true, TrustUpstream: true} }, ""},
+		{"chain without proxy ID", func(c *Config) { c.Chain.Propagate = true }, "chain.proxy_id"},
+		{"chain proxy ID", func(c *Config) { c.Chain.ProxyID = "edge/1" }, "chain.proxy_id"},
+		{"taint", func(c *Config) { c.Taint = Taint{Enabled: true, Action: "block", RiskScore: 0.8} }, ""},
+		{"taint action", func(c *Config) { c.Taint.Action = "warn" }, "taint.action"},
+		{"taint risk", func(c *Config) { c.Taint.RiskScore = 2 }, "taint.risk_score"},
+		{"taint pattern", func(c *Config) { c.Taint.CriticalArguments = []string{"url", "[x"} }, "taint.critical_arguments[1]"},
+		{"read receipts", func(c *Config) { c.ReadReceipts = ReadReceipts{Enabled: true, Escalation: "block"} }, ""},
+		{"audit", func(c *Config) { c.Audit = Audit{File: "audit.jsonl", Syslog: "udp://logs:514"} }, ""},
+		{"audit syslog", func(c *Config) { c.Audit.Syslog = "logs:514" }, "audit.syslog"},
+		{"audit url", func(c *Config) { c.Audit.URL = "ftp://collect.example" }, "audit.url"},
+		{"audit rotation", func(c *Config) { c.Audit.MaxFiles = -1 }, "audit.max_files"},
+		{"audit encryption", func(c *Config) {
+			c.Audit = Audit{PayloadBytes: 4096, EncryptFields: []string{"arguments", "reasons"}, EncryptionKey: testAuditKey}
+		}, ""},
+		{"audit payload bytes", func(c *Config) { c.Audit.PayloadBytes = -1 }, "audit.payload_bytes"},
+		{"audit encryption without key", func(c *Config) { c.Audit.EncryptFields = []string{"result"} }, "audit.encryption_key"},
+		{"audit encryption key", func(c *Config) { c.Audit.EncryptionKey = "c2hvcnQ=" }, "audit.encryption_key"},
+		{"audit encrypt fields", func(c *Config) {
+			c.Audit.EncryptionKey, c.Audit.EncryptFields = testAuditKey, []string{"session"}
+		}, "audit.encrypt_fields"},
+		{"read receipts escalation", func(c *Config) { c.ReadReceipts.Escalation = "terminate" }, "read_receipts.escalation"},
+		{"read receipts window", func(c *Config) { c.ReadReceipts.RetryWindow = -time.Second }, "read_receipts.retry_window"},
+		{"registry fast path", func(c *Config) {
+			c.RegistryFastPath = RegistryFastPath{Enabled: true, Tools: []string{"read_file"}, MaxAge: time.Minute}
+		}, ""},
+		{"registry fast path tool", func(c *Config) { c.RegistryFastPath.Tools = []string{""} }, "registry_fast_path.tools[0]"},
+		{"registry fast path age", func(c *Config) { c.RegistryFastPath.MaxAge = -time.Second }, "registry_fast_path.max_age"},
+		{"conformance thresholds", func(c *Config) { c.Conformance.StrictAt, c.Conformance.RejectAt = 12, 10 }, "conformance.strict_at"},
+		{"request timeout", func(c *Config) { c.RequestTimeout = -time.Second }, "request_timeout"},
+		{"partial results bytes", func(c *Config) { c.PartialResults.MaxBytes = -1 }, "partial_results.max_bytes"},
+		{"conformance half-life", func(c *Config) { c.Conformance.HalfLife = -time.Minute }, "conformance.half_life"},
+		{"chain trust without key", func(c *Config) { c.Chain = Chain{ProxyID: "inner", TrustUpstream: true} }, "chain.trust_upstream"},
+		{"tracing", func(c *Config) {
+			c.Tracing = Tracing{Endpoint: "http://otel:4318/v1/traces", SampleRatio: 0.5, Headers: map[string]string{"X-Api-Key": "k"}}
+		}, ""},
+		{"tracing endpoint", func(c *Config) { c.Tracing.Endpoint = "otel:4317" }, "tracing.endpoint"},
+		{"tracing sample ratio", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, "tracing.sample_ratio"},
+		{"tracing header", func(c *Config) { c.Tracing.Headers = map[string]string{"X Key": "k"} }, "tracing.headers"},
+		{"usage file", func(c *Config) { c.Usage = Usage{Tenant: "acme", File: "/tmp/usage.csv", Format: "csv"} }, ""},
+		{"usage endpoint", func(c *Config) { c.Usage = Usage{Endpoint: "http://otel:4318/v1/metrics", Format: "otlp"} }, ""},
+		{"usage both", func(c *Config) { c.Usage = Usage{File: "/tmp/usage.csv", Endpoint: "http://otel:4318/v1/metrics"} }, "usage"},
+		{"usage format", func(c *Config) { c.Usage = Usage{File: "/tmp/usage.csv", Format: "xml"} }, "usage.format"},
+		{"usage otlp file", func(c *Config) { c.Usage = Usage{File: "/tmp/usage.csv", Format: "otlp"} }, "usage.format"},
+		{"usage endpoint url", func(c *Config) { c.Usage.Endpoint = "otel:4318" }, "usage.endpoint"},
+		{"usage interval", func(c *Config) { c.Usage.Interval = -time.Second }, "usage.interval"},
+		{"session state file", func(c *Config) { c.SessionState = SessionState{Backend: StateFile, Path: "/tmp/s.json"} }, ""},
+		{"session state path", func(c *Config) { c.SessionState.Backend = StateFile }, "session_state.path"},
+		{"session state sqlite", func(c *Config) { c.SessionState.Backend = "sqlite" }, "session_state.backend"},
+		{"session state backend", func(c *Config) { c.SessionState.Backend = "redis" }, "session_state.backend"},
+		{"session state resumption", func(c *Config) { c.SessionState = SessionState{Backend: StateMemory, Resumption: true} }, ""},
+		{"resumption without backend", func(c *Config) { c.SessionState.Resumption = true }, "session_state.resumption"},
+		{"resumption with key", func(c *Config) { c.SessionState = SessionState{Backend: StateMemory, Key: "a", Resumption: true} }, "session_state.key"},
+		{"affinity", func(c *Config) {
+			c.Mode = "streamable-http"
+			c.Affinity = Affinity{Self: "r1", Handoff: "proxy", Replicas: []Replica{{ID: "r1", URL: "http://r1:8080"}, {ID: "r2", URL: "http://r2:8080"}}}
+		}, ""},
+		{"affinity mode", func(c *Config) { c.Affinity = Affinity{Self: "r1", Replicas: []Replica{{ID: "r1", URL: "http://r1"}}} }, "affinity"},
+		{"affinity self", func(c *Config) {
+			c.Mode = "streamable-http"
+			c.Affinity = Affinity{Self: "r3", Replicas: []Replica{{ID: "r1", URL: "http://r1"}}}
+		}, "affinity"},
+		{"affinity in sse mode", func(c *Config) {
+			c.Mode = "sse"
+			c.Affinity = Affinity{Self: "r1", Replicas: []Replica{{ID: "r1", URL: "http://r1"}}}
+		}, "affinity"},
+		{"affinity without replicas", func(c *Config) { c.Affinity.Self = "r1" }, "affinity.replicas"},
+		{"affinity hand-off", func(c *Config) {
+			c.Mode = "streamable-http"
+			c.Affinity = Affinity{Self: "r1", Handoff: "bounce", Replicas: []Replica{{ID: "r1", URL: "http://r1"}}}
+		}, "affinity"},
+		{"redaction", func(c *Config) {
+			c.Redaction = Redaction{Mode: "block", Disable: []string{"high-entropy"}, Patterns: map[string]string{"corp": `corp-([0-9a-f]{8})`}}
+		}, ""},
+		{"redaction mode", func(c *Config) { c.Redaction.Mode = "mask" }, "redaction.mode"},
+		{"redaction pattern", func(c *Config) { c.Redaction.Patterns = map[string]string{"bad": "("} }, "redaction.patterns"},
+		{"redaction entropy threshold", func(c *Config) { c.Redaction.EntropyThreshold = -1 }, "redaction.entropy_threshold"},
+		{"redaction entropy min length", func(c *Config) { c.Redaction.EntropyMinLength = -1 }, "redaction.entropy_min_length"},
+		{"rate limit", func(c *Config) {
+			c.RateLimit = RateLimit{Session: ratelimit.Limit{Rate: 20}, Tools: []ratelimit.ToolLimit{{Tool: "execute_command", Rate: 0.5, Scope: ratelimit.ScopeGlobal}}}
+		}, ""},
+		{"rate limit burst without rate", func(c *Config) { c.RateLimit.Global.Burst = 10 }, "rate_limit"},
+		{"rate limit tool scope", func(c *Config) {
+			c.RateLimit.Tools = []ratelimit.ToolLimit{{Tool: "search", Rate: 1, Scope: "tenant"}}
+		}, "rate_limit"},
+		{"attestation key", func(c *Config) { c.Attestation.Key = "c2hvcnQ=" }, "attestation.key"},
+		{"ffi", func(c *Config) { c.FFI = FFI{Library: "/opt/lib/libsentinel_ffi-2.so", DrainTimeout: time.Second} }, ""},
+		{"ffi library", func(c *Config) { c.FFI.Library = "libsentinel_ffi.so" }, "ffi.library"},
+		{"ffi drain timeout", func(c *Config) { c.FFI.DrainTimeout = -time.Second }, "ffi.drain_timeout"},
+		{"max proxy hops", func(c *Config) { c.MaxProxyHops = -1 }, "max_proxy_hops"},
+		{"sse upstream is self", func(c *Config) {
+			c.Mode, c.Port = "sse", 8080
+			c.Upstreams = []Upstream{{URL: "http://127.0.0.1:8080/sse"}}
+		}, "upstreams[0].url"},
+		{"sse upstream on another port", func(c *Config) {
+			c.Mode, c.Port = "sse", 8080
+			c.Upstreams = []Upstream{{URL: "http://localhost:8081/sse"}}
+		}, ""},
+		{"stdio upstream on the sse port", func(c *Config) {
+			c.Port = 8080
+			c.Upstreams = []Upstream{{URL: "http://localhost:8080/sse"}}
+		}, ""},
+		{"ffi pool", func(c *Config) { c.FFI.Workers, c.FFI.CallTimeout = 4, 2*time.Second }, ""},
+		{"ffi workers", func(c *Config) { c.FFI.Workers = -1 }, "ffi.workers"},
+		{"ffi call timeout", func(c *Config) { c.FFI.CallTimeout = -time.Second }, "ffi.call_timeout"},
+		{"resource templates", func(c *Config) {
+			c.ResourceTemplates = ResourceTemplates{Enabled: true, Variables: map[string]string{"id": "[0-9]+"}, Templates: []string{"db://{table}/{id}"}}
+		}, ""},
+		{"resource template pattern", func(c *Config) { c.ResourceTemplates.Variables = map[string]string{"id": "("} }, "resource_templates.variables.id"},
+		{"resource template", func(c *Config) { c.ResourceTemplates.Templates = []string{"db://{table"} }, "resource_templates.templates[0]"},
+		{"notification method", func(c *Config) { c.Notifications.Methods = []string{"tools/call"} }, "notifications.methods[0]"},
+		{"notification size", func(c *Config) { c.Notifications.MaxBytes = -1 }, "notifications.max_bytes"},
+		{"context result size", func(c *Config) { c.ContextBudget.MaxResultBytes = -1 }, "context_budget.max_result_bytes"},
+		{"sampling action", func(c *Config) { c.Sampling.Action = "ask" }, "sampling.action"},
+		{"elicitation field", func(c *Config) { c.Elicitation.SensitiveFields = []string{" "} }, "elicitation.sensitive_fields[0]"},
+		{"elicitation pattern", func(c *Config) { c.Elicitation.Patterns = map[string]string{"bad": "["} }, "elicitation.patterns"},
+		{"roots server", func(c *Config) { c.Roots.Servers = []string{"fs-["} }, "roots.servers[0]"},
+		{"roots prefix", func(c *Config) { c.Roots.Prefixes = []string{"/home/dev"} }, "roots.prefixes[0]"},
+		{"resource size", func(c *Config) { c.ResourceInspection.MaxBytes = -1 }, "resource_inspection.max_bytes"},
+		{"audit stream without a token", func(c *Config) { c.Admin, c.Audit.Stream = "127.0.0.1:9090", true }, "audit.stream"},
+		{"resource server", func(c *Config) { c.ResourceInspection.Servers = []ResourceServer{{Name: ""}} }, "resource_inspection.servers[0].name"},
+		{"resource server size", func(c *Config) {
+			c.ResourceInspection.Servers = []ResourceServer{{Name: "docs", MaxBytes: -1}}
+		}, "resource_inspection.servers[0].max_bytes"},
+		{"response inspection", func(c *Config) { c.ResponseInspection = ResponseInspection{Enabled: true, Action: "sanitize"} }, ""},
+		{"response inspection action", func(c *Config) { c.ResponseInspection.Action = "redact" }, "response_inspection.action"},
+		{"response inspection risk", func(c *Config) { c.ResponseInspection.RiskScore = 1.5 }, "response_inspection.risk_score"},
+		{"response inspection item size", func(c *Config) { c.ResponseInspection.MaxItemBytes = -1 }, "response_inspection.max_item_bytes"},
+		{"server mask", func(c *Config) {
+			c.ServerMask = ServerMask{Enabled: true, Name: "tools", Remove: []string{"(?i)powered by .*"}}
+		}, ""},
+		{"server mask pattern", func(c *Config) { c.ServerMask.Remove = []string{"("} }, "server_mask.remove[0]"},
+		{"server mask description", func(c *Config) { c.ServerMask.MaxDescription = -1 }, "server_mask.max_description"},
+		{"schedule rule", func(c *Config) {
+			c.Schedule.Rules = []schedule.Rule{{Name: "nights", Cron: "* 0-6 * * *", Scope: "writes"}}
+		}, "schedule.rules"},
+		{"schedule cron", func(c *Config) {
+			c.Schedule.Rules = []schedule.Rule{{Name: "nights", Cron: "* 25 * * *", Scope: schedule.ScopeAll}}
+		}, "schedule.rules"},
+		{"schedule limit", func(c *Config) {
+			c.Schedule.Limits = []schedule.Limit{{Category: "shell", Tools: []string{"execute_command"}, MaxInFlight: 1}}
+		}, ""},
+		{"schedule limit category", func(c *Config) {
+			c.Schedule.Limits = []schedule.Limit{{Category: "shell", MaxInFlight: 1}, {Category: "shell", MaxInFlight: 2}}
+		}, "schedule.limits"},
+		{"schedule limit size", func(c *Config) {
+			c.Schedule.Limits = []schedule.Limit{{Category: "shell", MaxInFlight: -1}}
+		}, "schedule.limits"},
+		{"schedule limit wait", func(c *Config) { c.Schedule.LimitWait = -time.Second }, "schedule.limit_wait"},
+		{"schedule time zone", func(c *Config) { c.Schedule.TimeZone = "Mars/Olympus" }, "schedule.time_zone"},
+		{"tool description action", func(c *Config) { c.ToolDescriptions.Action = "drop" }, "tool_descriptions.action"},
+		{"tool description pattern", func(c *Config) { c.ToolDescriptions.Patterns = map[string]string{"bad": "("} }, "tool_descriptions.patterns"},
+		{"sampling injection action", func(c *Config) { c.Sampling.OnInjection = "log" }, "sampling.on_injection"},
+		{"sampling tokens", func(c *Config) { c.Sampling.MaxTokens = -1 }, "sampling.max_tokens"},
+		{"sampling pattern", func(c *Config) { c.Sampling.Patterns = map[string]string{"bad": "("} }, "sampling.patterns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, expected success", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), ": "+tt.field+": ") {
+				t.Errorf("Validate = %v, expected an error naming %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestTLS_ClientConfig(t *testing.T) {
+	if cfg, err := (&TLS{}).ClientConfig(); cfg != nil || err != nil {
+		t.Errorf("zero TLS = %v, %v, expected nil", cfg, err)
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(ca, []byte("not a certificate"), 0o600)
+	if _, err := (&TLS{CAFile: ca}).ClientConfig(); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "tls.ca_file") {
+		t.Errorf("ClientConfig with a bad CA file = %v", err)
+	}
+
+	cfg, err := (&TLS{ServerName: "mcp.internal", MinVersion: "1.3"}).ClientConfig()
+	if err != nil || cfg.ServerName != "mcp.internal" || cfg.MinVersion == 0 {
+		t.Errorf("ClientConfig = %+v, %v", cfg, err)
+	}
+}
+
+// testAuditKey is an X25519 public key for audit field encryption.
+const testAuditKey = "3p5XbH0pGjg3tjsGbJ4Uq1eFzX2k0mJ5q7C1f6YtA1w="
+
+func TestAudit_Open(t *testing.T) {
+	if sink, err := (&Audit{}).Open(); sink != nil || err != nil {
+		t.Errorf("zero Audit = %v, %v, expected nil", sink, err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "audit.jsonl")
+	if _, err := (&Audit{File: missing}).Open(); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "audit.file") {
+		t.Errorf("Open with an unwritable file = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := (&Audit{File: path, URL: "http://127.0.0.1:1/audit"}).Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	sink.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("audit file not created: %v", err)
+	}
+
+	// An unchained trail cannot be continued as a chain
+	sink, _ = (&Audit{File: path}).Open()
+	sink.Write(&audit.Record{Session: "s1", Decision: "allowed"})
+	sink.Close()
+	if _, err := (&Audit{File: path, Chain: true}).Open(); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "audit.chain") {
+		t.Errorf("Open chaining an unchained trail = %v", err)
+	}
+
+	// A chained trail continues across reopening
+	chained := filepath.Join(t.TempDir(), "chained.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := (&Audit{File: chained, Chain: true}).Open()
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		sink.Write(&audit.Record{Session: "s1", Decision: "allowed"})
+		sink.Close()
+	}
+	v := audit.NewVerifier(audit.Link{})
+	if err := v.VerifyFile(chained); err != nil || v.Records() != 2 || v.Last().Seq != 2 {
+		t.Errorf("chained trail: %d records, last %+v, %v", v.Records(), v.Last(), err)
+	}
+
+	// Encrypted fields are chained as ciphertext
+	public, private, _ := audit.GenerateKey()
+	encrypted := filepath.Join(t.TempDir(), "encrypted.jsonl")
+	sink, err = (&Audit{File: encrypted, Chain: true, EncryptFields: []string{"arguments"}, EncryptionKey: public}).Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	sink.Write(&audit.Record{Session: "s1", Decision: "allowed", Arguments: `{"path":"/etc/passwd"}`})
+	sink.Close()
+	data, _ := os.ReadFile(encrypted)
+	if strings.Contains(string(data), "passwd") {
+		t.Errorf("arguments written in the clear: %s", data)
+	}
+	var rec audit.Record
+	json.Unmarshal(data, &rec)
+	dec, _ := audit.NewDecryptor(private)
+	if err := dec.Decrypt(&rec); err != nil || rec.Arguments != `{"path":"/etc/passwd"}` {
+		t.Errorf("Decrypt = %q, %v", rec.Arguments, err)
+	}
+	if err := audit.NewVerifier(audit.Link{}).VerifyFile(encrypted); err != nil {
+		t.Errorf("encrypted trail: %v", err)
+	}
+
+	// A stream sees the records as chained and encrypted
+	stream := audit.NewBroadcaster()
+	sub := stream.Subscribe(audit.Filter{}, 1)
+	sink, err = (&Audit{Chain: true, EncryptFields: []string{"arguments"}, EncryptionKey: public}).Open(stream)
+	if err != nil {
+		t.Fatalf("Open with a stream failed: %v", err)
+	}
+	sink.Write(&audit.Record{Session: "s1", Decision: "allowed", Arguments: `{"path":"/etc/passwd"}`})
+	sink.Close()
+	if got := <-sub.Records(); got.Seq != 1 || strings.Contains(got.Arguments, "passwd") {
+		t.Errorf("streamed record = %+v", got)
+	}
+}
+
+func TestSessionState_Open(t *testing.T) {
+	if store, err := (&SessionState{}).Open(); store != nil || err != nil {
+		t.Errorf("zero SessionState = %v, %v, expected nil", store, err)
+	}
+	if store, err := (&SessionState{Backend: StateMemory}).Open(); err != nil || store == nil {
+		t.Errorf("memory backend = %v, %v", store, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := (&SessionState{Backend: StateFile, Path: path}).Open(); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "session_state.path") {
+		t.Errorf("Open with a corrupt file = %v", err)
+	}
+	os.Remove(path)
+	if store, err := (&SessionState{Backend: StateFile, Path: path}).Open(); err != nil || store == nil {
+		t.Errorf("file backend = %v, %v", store, err)
+	}
+
+	cfg := Default()
+	if rc := cfg.RouterConfig(); rc.StateKey != "" {
+		t.Errorf("StateKey = %q without a backend", rc.StateKey)
+	}
+	cfg.SessionState.Backend = StateMemory
+	if rc := cfg.RouterConfig(); rc.StateKey != DefaultStateKey {
+		t.Errorf("StateKey = %q, expected %q", rc.StateKey, DefaultStateKey)
+	}
+	cfg.SessionState.Resumption = true
+	if rc := cfg.RouterConfig(); !rc.Resumption {
+		t.Error("Resumption not passed to the router")
+	}
+}
+
+func TestRedaction_Middleware(t *testing.T) {
+	if mw, err := (&Redaction{}).Middleware(); mw != nil || err != nil {
+		t.Errorf("zero Redaction = %v, %v, expected nil", mw, err)
+	}
+	if mw, err := (&Redaction{Mode: "redact"}).Middleware(); mw == nil || err != nil {
+		t.Errorf("redact mode = %v, %v", mw, err)
+	}
+	if _, err := (&Redaction{Mode: "erase"}).Middleware(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Middleware with an unknown mode = %v", err)
+	}
+
+	cfg, err := Parse([]byte("redaction:\n  mode: log\n  entropy_threshold: 4.5\n  patterns:\n    corp: 'corp-[0-9]+'\n"), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := Redaction{Mode: "log", EntropyThreshold: 4.5, Patterns: map[string]string{"corp": "corp-[0-9]+"}}
+	if !reflect.DeepEqual(cfg.Redaction, want) {
+		t.Errorf("Redaction = %+v, expected %+v", cfg.Redaction, want)
+	}
+}
+
+func TestAttestation_SigningKey(t *testing.T) {
+	if key, err := (&Attestation{}).SigningKey(); key != nil || err != nil {
+		t.Errorf("zero Attestation = %v, %v, expected nil", key, err)
+	}
+	_, private, _ := attest.GenerateKey()
+	env := map[string]string{"MCP_SENTINEL_ATTESTATION_KEY": private}
+	cfg := Default()
+	if err := cfg.ApplyEnv(func(name string) (string, bool) { v, ok := env[name]; return v, ok }); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if key, err := cfg.Attestation.SigningKey(); key == nil || err != nil {
+		t.Errorf("SigningKey = %v, %v", key, err)
+	}
+	if redacted := cfg.Redact(); redacted.Attestation.Key != Redacted {
+		t.Errorf("redacted key = %q", redacted.Attestation.Key)
+	}
+}
+
+func TestParse_RateLimit(t *testing.T) {
+	if Default().RateLimit.LimiterConfig() != nil {
+		t.Error("no limits should leave rate limiting disabled")
+	}
+	doc := `
+rate_limit:
+  global: {rate: 200, burst: 400}
+  tools:
+    - {tool: execute_command, rate: 0.5, burst: 3}
+    - {tool: "*__delete_*", rate: 0.1, scope: global}
+`
+	cfg, err := Parse([]byte(doc), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := &ratelimit.Config{
+		Global: ratelimit.Limit{Rate: 200, Burst: 400},
+		Tools: []ratelimit.ToolLimit{
+			{Tool: "execute_command", Rate: 0.5, Burst: 3},
+			{Tool: "*__delete_*", Rate: 0.1, Scope: ratelimit.ScopeGlobal},
+		},
+	}
+	if got := cfg.RateLimit.LimiterConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LimiterConfig = %+v, expected %+v", got, want)
+	}
+}
+
+func TestParse_Schedule(t *testing.T) {
+	if Default().Schedule.SchedulerConfig() != nil {
+		t.Error("the schedule should be off by default")
+	}
+	doc := `
+schedule:
+  enabled: true
+  time_zone: Europe/Berlin
+  read_only_tools: [read_file]
+  rules:
+    - {name: business-hours, cron: "* 9-17 * * 1-5", outside: true, scope: mutating}
+    - {name: backups, cron: "0 2 * * 0", duration: 2h, scope: all}
+  limits:
+    - {category: shell, tools: [execute_command], max_in_flight: 1}
+    - {category: network, tools: ["fetch_*"], max_in_flight: 4}
+  limit_wait: 2s
+`
+	cfg, err := Parse([]byte(doc), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	sc := cfg.Schedule.SchedulerConfig()
+	if sc == nil || sc.Location.String() != "Europe/Berlin" || !reflect.DeepEqual(sc.ReadOnlyTools, []string{"read_file"}) {
+		t.Fatalf("SchedulerConfig = %+v", sc)
+	}
+	want := []schedule.Rule{
+		{Name: "business-hours", Cron: "* 9-17 * * 1-5", Outside: true, Scope: schedule.ScopeMutating},
+		{Name: "backups", Cron: "0 2 * * 0", Duration: 2 * time.Hour, Scope: schedule.ScopeAll},
+	}
+	if !reflect.DeepEqual(sc.Rules, want) {
+		t.Errorf("Rules = %+v, expected %+v", sc.Rules, want)
+	}
+	limits := []schedule.Limit{
+		{Category: "shell", Tools: []string{"execute_command"}, MaxInFlight: 1},
+		{Category: "network", Tools: []string{"fetch_*"}, MaxInFlight: 4},
+	}
+	if !reflect.DeepEqual(sc.Limits, limits) || sc.LimitWait != 2*time.Second {
+		t.Errorf("Limits = %+v, wait %v; expected %+v, 2s", sc.Limits, sc.LimitWait, limits)
+	}
+
+	// A second call to a full category is refused
+	sc.LimitWait = 0
+	sch, err := schedule.New(sc)
+	if err != nil {
+		t.Fatalf("schedule.New failed: %v", err)
+	}
+	release, _ := sch.Acquire(context.Background(), "execute_command")
+	if release == nil {
+		t.Fatal("first shell call was refused")
+	}
+	defer release()
+	if again, reason := sch.Acquire(context.Background(), "execute_command"); again != nil || !strings.Contains(reason, "shell") {
+		t.Errorf("second shell call: reason %q, expected the shell limit to refuse it", reason)
+	}
+}
+
+func TestParse_SLO(t *testing.T) {
+	doc := `
+slo:
+  interval: 10s
+  webhook: https://alerts.example/hook
+  objectives:
+    - name: tools-call-latency
+      method: tools/call
+      latency: 500ms
+      target: 0.95
+    - name: block-error-rate
+      verdicts: [blocked, error]
+      target: 0.999
+  windows:
+    - {long: 1h, short: 5m, rate: 14.4}
+`
+	cfg, err := Parse([]byte(doc), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	mc := cfg.SLO.MonitorConfig()
+	if mc == nil || len(mc.Objectives) != 2 || mc.Objectives[0].Latency != 500*time.Millisecond ||
+		mc.Objectives[1].Target != 0.999 || mc.Windows[0].Short != 5*time.Minute || mc.Interval != 10*time.Second {
+		t.Errorf("MonitorConfig = %+v", mc)
+	}
+	if Default().SLO.MonitorConfig() != nil {
+		t.Error("no objectives should disable SLO tracking")
+	}
+
+	tests := []struct {
+		name  string
+		doc   string
+		field string
+	}{
+		{"bad duration", "slo:\n  objectives:\n    - {name: a, latency: fast, target: 0.9}\n", "slo.objectives[0].latency: expected a duration"},
+		{"both kinds", "slo:\n  objectives:\n    - {name: a, latency: 1s, verdicts: [error], target: 0.9}\n", "slo.objectives[0]: needs exactly one"},
+		{"target", "slo:\n  objectives:\n    - {name: a, latency: 1s, target: 95}\n", "slo.objectives[0].target"},
+		{"verdict", "slo:\n  objectives:\n    - {name: a, verdicts: [denied], target: 0.9}\n", "slo.objectives[0].verdicts[0]"},
+		{"window", "slo:\n  windows:\n    - {long: 1m, short: 1h, rate: 2}\n", "slo.windows[0].long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.doc), FormatYAML)
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("error = %v, expected one naming %q", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestParse_PolicyRules(t *testing.T) {
+	doc := `
+policy:
+  default_gas: 50
+  rules:
+    - name: secrets
+      tools: [read_file]
+      arguments: {path: "^/etc/|\\.env$"}
+      action: require-council
+    - name: web-burst
+      servers: [web]
+      action: rate-limit
+      rate: 0.5
+      burst: 10
+    - name: stage-writes
+      tools: [write_file]
+      action: downgrade
+      downgrade: {stage_argument: path, workspace: /srv/work, staging_dir: /var/lib/mcp-sentinel/staging}
+`
+	cfg, err := Parse([]byte(doc), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	set := cfg.Policy.Set()
+	if set == nil || len(set.Rules) != 3 || set.DefaultGas != 50 {
+		t.Fatalf("Set = %+v", set)
+	}
+	if got := set.Rules[0].Arguments["path"]; got != `^/etc/|\.env$` {
+		t.Errorf("arguments.path = %q", got)
+	}
+	if r := set.Rules[1]; r.Action != policy.ActionRateLimit || r.Rate != 0.5 || r.Burst != 10 {
+		t.Errorf("rules[1] = %+v", r)
+	}
+	if d := set.Rules[2].Downgrade; d.StageArgument != "path" || d.StagingDir != "/var/lib/mcp-sentinel/staging" {
+		t.Errorf("rules[2].downgrade = %+v", d)
+	}
+	if Default().Policy.Set() != nil {
+		t.Error("no rules should leave the built-in policy in effect")
+	}
+
+	tests := []struct {
+		name  string
+		doc   string
+		field string
+	}{
+		{"arguments type", "policy:\n  rules:\n    - {name: a, arguments: [x], action: block}\n", "policy.rules[0].arguments: expected a mapping"},
+		{"argument value", "policy:\n  rules:\n    - {name: a, arguments: {p: [x]}, action: block}\n", "policy.rules[0].arguments.p: expected a string"},
+		{"action", "policy:\n  rules:\n    - {name: a, action: deny}\n", `policy.rules: policy: invalid rule: rules[0] (a): unknown action "deny"`},
+		{"regexp", "policy:\n  rules:\n    - {name: a, arguments: {p: \"(\"}, action: block}\n", "policy.rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.doc), FormatYAML)
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("error = %v, expected one naming %q", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestUpstreamOneShot(t *testing.T) {
+	if (Upstream{Command: []string{"srv"}}).OneShot() != nil {
+		t.Error("server upstream has a one-shot config")
+	}
+	u := Upstream{Name: "fn", Tools: []OneShotTool{
+		{Name: "a", Command: []string{"a"}, InputSchema: `{"type":"object"}`, Warm: 2},
+		{Name: "b", URL: "https://fn/b", Timeout: time.Second},
+	}}
+	cfg := u.OneShot()
+	if cfg == nil || cfg.Name != "fn" || len(cfg.Tools) != 2 {
+		t.Fatalf("OneShot = %+v", cfg)
+	}
+	if a := cfg.Tools[0]; string(a.InputSchema) != `{"type":"object"}` || a.Warm != 2 || a.Command[0] != "a" {
+		t.Errorf("tool a = %+v", a)
+	}
+	if b := cfg.Tools[1]; b.InputSchema != nil || b.URL != "https://fn/b" || b.Timeout != time.Second {
+		t.Errorf("tool b = %+v", b)
+	}
+	if cfg.Sandbox != nil {
+		t.Errorf("sandbox = %+v, expected none", cfg.Sandbox)
+	}
+
+	u.Sandbox = Sandbox{Enabled: true, ReadOnlyPaths: []string{"/srv"}, Strict: true}
+	if p := u.OneShot().Sandbox; p == nil || p.Name != "fn" || p.ReadOnlyPaths[0] != "/srv" || !p.Strict {
+		t.Errorf("sandbox = %+v", p)
+	}
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encode renders c as a configuration file in format, leaving out the
+// fields that equal Default so the file reads like one written by hand.
+// Parse decodes the result back to c. Comments and key order from an
+// earlier file are not preserved.
+func (c *Config) Encode(format Format) ([]byte, error) {
+	tree := encodeTree(reflect.ValueOf(c).Elem(), reflect.ValueOf(Default()).Elem())
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(tree, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		var b bytes.Buffer
+		if err := writeYAML(&b, tree.(mapping), 0); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w: unknown format %q", ErrInvalid, format)
+}
+
+// entry is one key of an encoded mapping.
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// mapping is an encoded struct or map, keeping its key order.
+type mapping []entry
+
+// MarshalJSON encodes m as a JSON object in key order.
+func (m mapping) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.key)
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// encodeTree converts v into mappings, lists, and scalars. Struct
+// fields equal to their counterpart in base are left out; list and map
+// entries always stay.
+func encodeTree(v, base reflect.Value) interface{} {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m := mapping{}
+		for i := 0; i < v.NumField(); i++ {
+			fv, fb := v.Field(i), base.Field(i)
+			if reflect.DeepEqual(fv.Interface(), fb.Interface()) {
+				continue
+			}
+			m = append(m, entry{fieldName(v.Type().Field(i)), encodeTree(fv, fb)})
+		}
+		return m
+	case reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = encodeTree(v.Index(i), reflect.Zero(v.Type().Elem()))
+		}
+		return items
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		m := make(mapping, 0, len(keys))
+		for _, k := range keys {
+			value := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			m = append(m, entry{k, encodeTree(value, reflect.Zero(value.Type()))})
+		}
+		return m
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int64:
+		return v.Int()
+	case reflect.Uint64:
+		return v.Uint()
+	case reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
+
+// writeYAML writes m as a block mapping at indent. Strings are double
+// quoted, and lists of scalars use the one-line flow style, which is
+// also JSON.
+func writeYAML(b *bytes.Buffer, m mapping, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	for _, e := range m {
+		b.WriteString(pad)
+		b.WriteString(yamlKey(e.key))
+		b.WriteByte(':')
+		switch v := e.value.(type) {
+		case mapping:
+			if len(v) > 0 {
+				b.WriteByte('\n')
+				if err := writeYAML(b, v, indent+2); err != nil {
+					return err
+				}
+				continue
+			}
+		case []interface{}:
+			if len(v) > 0 && !scalars(v) {
+				b.WriteByte('\n')
+				if err := writeYAMLList(b, v, indent+2); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		flow, err := flowValue(e.value)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", e.key, err)
+		}
+		b.WriteByte(' ')
+		b.Write(flow)
+		b.WriteByte('\n')
+	}
+	return nil
+}
+
+// writeYAMLList writes items as a block sequence at indent. A mapping
+// item starts on the "- " line and continues below it.
+func writeYAMLList(b *bytes.Buffer, items []interface{}, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range items {
+		m, ok := item.(mapping)
+		if !ok || len(m) == 0 {
+			flow, err := json.Marshal(item)
+			if err != nil {
+				return fmt.Errorf("config: %w", err)
+			}
+			b.WriteString(pad + "- ")
+			b.Write(flow)
+			b.WriteByte('\n')
+			continue
+		}
+		var nested bytes.Buffer
+		if err := writeYAML(&nested, m, indent+2); err != nil {
+			return err
+		}
+		b.WriteString(pad + "- ")
+		b.Write(nested.Bytes()[indent+2:])
+	}
+	return nil
+}
+
+// flowValue encodes v on one line, spacing list items as people do.
+func flowValue(v interface{}) ([]byte, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return json.Marshal(v)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		part, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = string(part)
+	}
+	return []byte("[" + strings.Join(parts, ", ") + "]"), nil
+}
+
+// scalars reports whether no item is a mapping or list, so items fit
+// on one line.
+func scalars(items []interface{}) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case mapping, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// yamlKey quotes key unless it is a plain word.
+func yamlKey(key string) string {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return strconv.Quote(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
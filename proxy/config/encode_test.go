@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+)
+
+func TestEncode_RoundTrip(t *testing.T) {
+	full, err := Parse([]byte(exampleYAML), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full.NamespaceTools = false
+	full.Chain = Chain{ProxyID: "edge-1", Key: `k"ey #1`, Propagate: true}
+	full.Policy.Rules = []policy.Rule{
+		{Name: "secrets", Tools: []string{"read_file"}, Arguments: map[string]string{"path": "^/etc/", "odd key": "a: b"}, Action: policy.ActionCouncil},
+		{Name: "limit", Action: policy.ActionRateLimit, Rate: 0.5, Burst: 2},
+	}
+	full.SLO = SLO{
+		Objectives: []slo.Objective{{Name: "latency", Method: "tools/call", Latency: 500 * time.Millisecond, Target: 0.95}},
+		Interval:   time.Minute,
+	}
+	full.Taint = Taint{Enabled: true, RiskScore: 1, CriticalArguments: []string{}}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"default", Default()},
+		{"example", mustParse(t, exampleJSON, FormatJSON)},
+		{"full", full},
+	}
+	for _, tt := range tests {
+		for _, format := range []Format{FormatYAML, FormatJSON} {
+			data, err := tt.cfg.Encode(format)
+			if err != nil {
+				t.Fatalf("%s %s: Encode: %v", tt.name, format, err)
+			}
+			got, err := Parse(data, format)
+			if err != nil {
+				t.Fatalf("%s %s: Parse: %v\n%s", tt.name, format, err, data)
+			}
+			if !reflect.DeepEqual(got, tt.cfg) {
+				t.Errorf("%s %s: round trip =\n%+v\nwant\n%+v\n%s", tt.name, format, got, tt.cfg, data)
+			}
+		}
+	}
+}
+
+func TestEncode_LeavesOutDefaults(t *testing.T) {
+	cfg := Default()
+	cfg.Gas.Budget = 42
+	data, err := cfg.Encode(FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "gas:\n  budget: 42\n"; string(data) != want {
+		t.Errorf("Encode = %q, want %q", data, want)
+	}
+	if !strings.Contains(string(mustEncode(t, Default(), FormatJSON)), "{}") {
+		t.Error("the default configuration should encode as an empty object")
+	}
+}
+
+func mustParse(t *testing.T, data string, format Format) *Config {
+	t.Helper()
+	cfg, err := Parse([]byte(data), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func mustEncode(t *testing.T, cfg *Config, format Format) []byte {
+	t.Helper()
+	data, err := cfg.Encode(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
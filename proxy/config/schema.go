@@ -0,0 +1,157 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/secrets"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+)
+
+// Redacted replaces secrets in the configuration Redact returns.
+const Redacted = "[redacted]"
+
+// schemaEnums lists the accepted values of text fields that Validate
+// checks against a fixed set, by path ("[]" stands for any list index).
+var schemaEnums = map[string][]string{
+	"mode":                     {"stdio", "sse", "ws"},
+	"logging.error_format":     {"text", "json"},
+	"tls.min_version":          {"", "1.2", "1.3"},
+	"taint.action":             {"", "flag", "council", "block"},
+	"read_receipts.escalation": {"", "council", "block"},
+	"policy.default_action":    {"", string(policy.ActionAllow), string(policy.ActionBlock)},
+	"rate_limit.tools[].scope": {"", string(ratelimit.ScopeSession), string(ratelimit.ScopeGlobal)},
+	"usage.format":             {"", usage.FormatCSV, usage.FormatJSON, usage.FormatOTLP},
+	"sampling.action":          {"", string(router.SamplingAllow), string(router.SamplingDeny), string(router.SamplingApprove)},
+	"sampling.on_injection":    {"", string(router.SamplingAllow), string(router.SamplingDeny), string(router.SamplingApprove)},
+	"redaction.mode":           {"", string(secrets.ActionRedact), string(secrets.ActionBlock), string(secrets.ActionLog)},
+	"tool_descriptions.action": {"", string(router.ToolDescriptionFlag), string(router.ToolDescriptionRedact), string(router.ToolDescriptionStrip)},
+	"policy.rules[].action": {"", string(policy.ActionAllow), string(policy.ActionBlock),
+		string(policy.ActionCouncil), string(policy.ActionRateLimit), string(policy.ActionDowngrade)},
+}
+
+// Schema returns a JSON Schema (draft 2020-12) describing the
+// configuration file, for editors and the admin UI. Properties keep the
+// order of the file's fields, defaults are those of Default, durations
+// are strings with the non-standard format "duration", and secrets are
+// marked writeOnly.
+func Schema() json.RawMessage {
+	s := schemaOf("", reflect.ValueOf(Default()).Elem(), reflect.StructField{})
+	s = append(mapping{
+		{"$schema", "https://json-schema.org/draft/2020-12/schema"},
+		{"title", "mcp-sentinel proxy configuration"},
+	}, s...)
+	data, _ := json.Marshal(s)
+	return data
+}
+
+// schemaOf describes v, whose default value it holds, at path. f is the
+// struct field holding v, if any.
+func schemaOf(path string, v reflect.Value, f reflect.StructField) mapping {
+	var s mapping
+	switch {
+	case v.Type() == durationType:
+		s = mapping{{"type", "string"}, {"format", "duration"}}
+	case v.Kind() == reflect.Struct:
+		props := mapping{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			props = append(props, entry{fieldName(field), schemaOf(join(path, fieldName(field)), v.Field(i), field)})
+		}
+		return mapping{{"type", "object"}, {"properties", props}, {"additionalProperties", false}}
+	case v.Kind() == reflect.Slice:
+		item := reflect.New(v.Type().Elem()).Elem()
+		return mapping{{"type", "array"}, {"items", schemaOf(path+"[]", item, reflect.StructField{})}}
+	case v.Kind() == reflect.Map:
+		value := reflect.New(v.Type().Elem()).Elem()
+		return mapping{{"type", "object"}, {"additionalProperties", schemaOf(path+".*", value, reflect.StructField{})}}
+	case v.Kind() == reflect.String:
+		s = mapping{{"type", "string"}}
+	case v.Kind() == reflect.Bool:
+		s = mapping{{"type", "boolean"}}
+	case v.Kind() == reflect.Uint64:
+		s = mapping{{"type", "integer"}, {"minimum", 0}}
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		s = mapping{{"type", "integer"}}
+	case v.Kind() == reflect.Float64:
+		s = mapping{{"type", "number"}}
+	}
+	if values, ok := schemaEnums[path]; ok {
+		s = append(s, entry{"enum", values})
+	}
+	if isSecret(f) {
+		s = append(s, entry{"writeOnly", true})
+	} else if !v.IsZero() {
+		s = append(s, entry{"default", encodeTree(v, reflect.Zero(v.Type()))})
+	}
+	return s
+}
+
+// isSecret reports whether field holds a secret, marked by the struct
+// tag secret:"true".
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+// Redact returns a copy of c with every secret that is set replaced by
+// Redacted, for display.
+func (c *Config) Redact() *Config {
+	out := *c
+	redact(reflect.ValueOf(&out).Elem(), reflect.Value{})
+	return &out
+}
+
+// Unredact restores the secrets in c still holding Redacted from prev,
+// so a configuration edited from Redact's copy keeps the secrets it
+// could not see.
+func (c *Config) Unredact(prev *Config) {
+	redact(reflect.ValueOf(c).Elem(), reflect.ValueOf(prev).Elem())
+}
+
+// redact walks the struct fields under v: with an invalid prev it
+// replaces set secrets by Redacted, otherwise it replaces Redacted by
+// the secret in prev. Every value of a secret map is a secret.
+func redact(v, prev reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, fv := v.Type().Field(i), v.Field(i)
+		var pv reflect.Value
+		if prev.IsValid() {
+			pv = prev.Field(i)
+		}
+		switch {
+		case fv.Kind() == reflect.Struct:
+			redact(fv, pv)
+		case isSecret(field) && fv.Kind() == reflect.Map && !fv.IsNil():
+			redactMap(fv, pv)
+		case !isSecret(field) || fv.Kind() != reflect.String:
+		case !prev.IsValid() && fv.String() != "":
+			fv.SetString(Redacted)
+		case prev.IsValid() && strings.TrimSpace(fv.String()) == Redacted:
+			fv.SetString(pv.String())
+		}
+	}
+}
+
+// redactMap applies redact to the string values of the map v, which
+// it replaces by a copy so a shared map is never modified.
+func redactMap(v, prev reflect.Value) {
+	out := reflect.MakeMapWithSize(v.Type(), v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		value := iter.Value().String()
+		switch {
+		case !prev.IsValid() && value != "":
+			value = Redacted
+		case prev.IsValid() && strings.TrimSpace(value) == Redacted:
+			if pv := prev.MapIndex(iter.Key()); pv.IsValid() {
+				value = pv.String()
+			}
+		}
+		out.SetMapIndex(iter.Key(), reflect.ValueOf(value).Convert(v.Type().Elem()))
+	}
+	v.Set(out)
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Type                 string `json:"type"`
+		AdditionalProperties bool   `json:"additionalProperties"`
+		Properties           map[string]struct {
+			Type       string                     `json:"type"`
+			Enum       []string                   `json:"enum"`
+			Default    interface{}                `json:"default"`
+			Properties map[string]json.RawMessage `json:"properties"`
+			Items      struct {
+				Type string `json:"type"`
+			} `json:"items"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Type != "object" || schema.AdditionalProperties {
+		t.Errorf("top level = %+v, want a closed object", schema)
+	}
+	// Every configuration key is described
+	top := reflect.TypeOf(Config{})
+	if len(schema.Properties) != top.NumField() {
+		t.Errorf("schema has %d properties, Config has %d fields", len(schema.Properties), top.NumField())
+	}
+
+	mode := schema.Properties["mode"]
+	if mode.Type != "string" || mode.Default != "stdio" || len(mode.Enum) != 3 {
+		t.Errorf("mode = %+v", mode)
+	}
+	if up := schema.Properties["upstreams"]; up.Type != "array" || up.Items.Type != "object" {
+		t.Errorf("upstreams = %+v", up)
+	}
+	if _, ok := schema.Properties["chain"].Properties["key"]; !ok {
+		t.Error("chain.key missing")
+	}
+
+	var chainKey struct {
+		WriteOnly bool        `json:"writeOnly"`
+		Default   interface{} `json:"default"`
+	}
+	json.Unmarshal(schema.Properties["chain"].Properties["key"], &chainKey)
+	if !chainKey.WriteOnly {
+		t.Error("chain.key should be writeOnly")
+	}
+	var interval struct {
+		Format string `json:"format"`
+	}
+	json.Unmarshal(schema.Properties["slo"].Properties["interval"], &interval)
+	if interval.Format != "duration" {
+		t.Errorf("slo.interval format = %q", interval.Format)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cfg := Default()
+	cfg.AdminToken = "admin-secret"
+	cfg.Chain = Chain{ProxyID: "edge-1", Key: "chain-secret"}
+	cfg.Tracing.Headers = map[string]string{"X-Api-Key": "header-secret", "X-Empty": ""}
+
+	shown := cfg.Redact()
+	if shown.AdminToken != Redacted || shown.Chain.Key != Redacted || shown.Chain.ProxyID != "edge-1" {
+		t.Errorf("Redact = %+v", shown)
+	}
+	if h := shown.Tracing.Headers; h["X-Api-Key"] != Redacted || h["X-Empty"] != "" {
+		t.Errorf("Redact headers = %v", h)
+	}
+	if cfg.AdminToken != "admin-secret" || cfg.Chain.Key != "chain-secret" || cfg.Tracing.Headers["X-Api-Key"] != "header-secret" {
+		t.Error("Redact modified the original")
+	}
+	if empty := Default().Redact(); empty.AdminToken != "" || empty.Chain.Key != "" {
+		t.Error("unset secrets should stay empty")
+	}
+
+	// An edit keeps the placeholder for one secret and replaces the other
+	edited := *shown
+	edited.Chain.Key = "rotated"
+	edited.Tracing.Headers = map[string]string{"X-Api-Key": Redacted, "X-New": "added"}
+	edited.Unredact(cfg)
+	if edited.AdminToken != "admin-secret" || edited.Chain.Key != "rotated" {
+		t.Errorf("Unredact = %+v", edited)
+	}
+	if h := edited.Tracing.Headers; h["X-Api-Key"] != "header-secret" || h["X-New"] != "added" || len(h) != 2 {
+		t.Errorf("Unredact headers = %v", h)
+	}
+}
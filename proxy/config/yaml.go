@@ -0,0 +1,516 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is one significant line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlParser decodes the block-structured subset of YAML used by
+// configuration files: mappings, sequences, plain and quoted scalars,
+// flow collections on one line, literal (|) and folded (>) block
+// scalars, and comments. Anchors, aliases, tags, and multiple
+// documents are refused rather than misread.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+
+	// raw keeps every line, including blank ones, for block scalars
+	raw []string
+}
+
+// decodeYAML parses data into nil, bool, int64, float64, string,
+// []interface{}, and map[string]interface{} values.
+func decodeYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	for i, line := range p.raw {
+		body := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(body, "\t") {
+			return nil, syntaxError(i+1, "tabs are not allowed in indentation")
+		}
+		text, err := stripComment(body)
+		if err != nil {
+			return nil, syntaxError(i+1, err.Error())
+		}
+		if text == "" {
+			continue
+		}
+		if text == "---" && len(p.lines) == 0 {
+			continue
+		}
+		if text == "---" || text == "..." {
+			return nil, syntaxError(i+1, "multiple documents are not supported")
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(line) - len(body), text: text})
+	}
+	if len(p.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, syntaxError(p.lines[p.pos].num, "unexpected indentation")
+	}
+	return v, nil
+}
+
+// block parses the mapping or sequence whose entries start at indent.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses "- item" lines at indent.
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, syntaxError(line.num, "unexpected indentation")
+		}
+		if !isSequenceItem(line.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			// The item is the nested block below
+			p.pos++
+			v, err := p.nested(line)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+
+		// Reparse the item's text as a block at its own column, so
+		// "- name: x" continues with "  url: y" below it
+		col := indent + len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{num: line.num, indent: col, text: rest}
+		if isSequenceItem(rest) || isMappingEntry(rest) {
+			v, err := p.block(col)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		p.pos++
+		v, err := p.value(line.num, rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// mapping parses "key: value" lines at indent.
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	entries := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, syntaxError(line.num, "unexpected indentation")
+		}
+		if isSequenceItem(line.text) {
+			return nil, syntaxError(line.num, "sequence item where a mapping key was expected")
+		}
+		key, rest, err := splitKey(line.text)
+		if err != nil {
+			return nil, syntaxError(line.num, err.Error())
+		}
+		if _, dup := entries[key]; dup {
+			return nil, syntaxError(line.num, fmt.Sprintf("duplicate key %q", key))
+		}
+		p.pos++
+
+		var v interface{}
+		if rest == "" {
+			v, err = p.nested(line)
+		} else {
+			v, err = p.value(line.num, rest, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = v
+	}
+	return entries, nil
+}
+
+// nested parses the block below parent, or returns nil if there is
+// none. A sequence may sit at the parent's own indentation.
+func (p *yamlParser) nested(parent yamlLine) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > parent.indent || (next.indent == parent.indent && isSequenceItem(next.text) && !isSequenceItem(parent.text)) {
+		return p.block(next.indent)
+	}
+	return nil, nil
+}
+
+// value decodes an inline value: a block scalar header, a flow
+// collection, or a scalar.
+func (p *yamlParser) value(num int, text string, indent int) (interface{}, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.blockScalar(num, text, indent)
+	case '&', '*', '!':
+		return nil, syntaxError(num, "anchors, aliases, and tags are not supported")
+	case '[', '{':
+		f := &flowParser{s: text}
+		v, err := f.value()
+		if err == nil {
+			f.space()
+			if f.i < len(f.s) {
+				err = fmt.Errorf("unexpected %q after flow collection", f.s[f.i:])
+			}
+		}
+		if err != nil {
+			return nil, syntaxError(num, err.Error())
+		}
+		return v, nil
+	}
+	v, err := scalar(text)
+	if err != nil {
+		return nil, syntaxError(num, err.Error())
+	}
+	return v, nil
+}
+
+// blockScalar reads a literal or folded scalar whose header is on line
+// num. Its content is every following line indented beyond indent.
+func (p *yamlParser) blockScalar(num int, header string, indent int) (interface{}, error) {
+	style, chomp := header[0], byte(0)
+	if len(header) > 1 {
+		chomp = header[1]
+		if len(header) > 2 || (chomp != '-' && chomp != '+') {
+			return nil, syntaxError(num, fmt.Sprintf("unsupported block scalar header %q", header))
+		}
+	}
+
+	// Skip the significant lines the content spans
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		p.pos++
+	}
+	end := len(p.raw)
+	if p.pos < len(p.lines) {
+		end = p.lines[p.pos].num - 1
+	}
+
+	var content []string
+	contentIndent := -1
+	for _, line := range p.raw[num:end] {
+		body := strings.TrimLeft(line, " ")
+		if body == "" {
+			content = append(content, "")
+			continue
+		}
+		if contentIndent < 0 {
+			contentIndent = len(line) - len(body)
+		}
+		if len(line)-len(body) < contentIndent {
+			return nil, syntaxError(num, "block scalar lines are less indented than its first line")
+		}
+		content = append(content, line[contentIndent:])
+	}
+
+	// Trailing blank lines belong to the chomping indicator
+	trailing := 0
+	for trailing < len(content) && content[len(content)-1-trailing] == "" {
+		trailing++
+	}
+	lines := content[:len(content)-trailing]
+
+	var text string
+	if style == '|' {
+		text = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || lines[i-1] == "":
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == '-':
+	case chomp == '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// isSequenceItem reports whether text starts a sequence item.
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isMappingEntry reports whether text is a "key: value" entry.
+func isMappingEntry(text string) bool {
+	if text[0] == '[' || text[0] == '{' {
+		return false
+	}
+	_, _, err := splitKey(text)
+	return err == nil
+}
+
+// splitKey splits "key: value" into its key and value text.
+func splitKey(text string) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := quotedEnd(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		key, err := scalar(text[:end])
+		if err != nil {
+			return "", "", err
+		}
+		rest := text[end:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", fmt.Errorf("expected ':' after key")
+		}
+		return key.(string), strings.TrimSpace(rest[1:]), nil
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", fmt.Errorf("expected 'key: value', got %q", text)
+		}
+		i = len(text) - 1
+	}
+	key := strings.TrimSpace(text[:i])
+	if key == "" {
+		return "", "", fmt.Errorf("empty mapping key")
+	}
+	return key, strings.TrimSpace(text[i+1:]), nil
+}
+
+// quotedEnd returns the index just past the quoted string text starts
+// with, or -1 if it is unterminated.
+func quotedEnd(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q && q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripComment removes a trailing comment and surrounding space.
+func stripComment(line string) (string, error) {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"' || c == '\'':
+			if i > 0 && line[i-1] != ' ' && line[i-1] != '[' && line[i-1] != '{' && line[i-1] != ',' && line[i-1] != '-' && line[i-1] != ':' {
+				continue
+			}
+			end := quotedEnd(line[i:])
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quoted string")
+			}
+			i += end - 1
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimRight(line[:i], " "), nil
+		}
+	}
+	return strings.TrimRight(line, " "), nil
+}
+
+// scalar decodes a plain or quoted scalar using the YAML core schema.
+func scalar(text string) (interface{}, error) {
+	switch text[0] {
+	case '"':
+		if quotedEnd(text) != len(text) {
+			return nil, fmt.Errorf("malformed double-quoted string %s", text)
+		}
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("malformed double-quoted string %s", text)
+		}
+		return s, nil
+	case '\'':
+		if quotedEnd(text) != len(text) {
+			return nil, fmt.Errorf("malformed single-quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases, and tags are not supported")
+	}
+
+	switch text {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && strings.ContainsAny(text, "0123456789") {
+		return f, nil
+	}
+	return text, nil
+}
+
+// flowParser decodes a one-line flow collection such as [a, "b"] or
+// {name: x, tools: [y]}.
+type flowParser struct {
+	s string
+	i int
+}
+
+func (f *flowParser) space() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flowParser) value() (interface{}, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, fmt.Errorf("unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		end := quotedEnd(f.s[f.i:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+		text := f.s[f.i : f.i+end]
+		f.i += end
+		return scalar(text)
+	}
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		if f.s[f.i] == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+		f.i++
+	}
+	text := strings.TrimSpace(f.s[start:f.i])
+	if text == "" {
+		return nil, fmt.Errorf("empty flow entry")
+	}
+	return scalar(text)
+}
+
+func (f *flowParser) sequence() (interface{}, error) {
+	f.i++
+	items := []interface{}{}
+	for {
+		f.space()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return items, nil
+		}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowParser) mapping() (interface{}, error) {
+	f.i++
+	entries := map[string]interface{}{}
+	for {
+		f.space()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return entries, nil
+		}
+		k, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		f.space()
+		if f.i >= len(f.s) || f.s[f.i] != ':' {
+			return nil, fmt.Errorf("expected ':' after flow mapping key %q", key)
+		}
+		f.i++
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := entries[key]; dup {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		entries[key] = v
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes a comma, leaving a closing bracket for the caller.
+func (f *flowParser) separator(closing byte) error {
+	f.space()
+	switch {
+	case f.i >= len(f.s):
+		return fmt.Errorf("unterminated flow collection")
+	case f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.s[f.i] == closing:
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c', got %q", closing, f.s[f.i:])
+}
+
+// syntaxError reports a YAML syntax error on line num.
+func syntaxError(num int, msg string) error {
+	return fmt.Errorf("%w: line %d: %s", ErrSyntax, num, msg)
+}
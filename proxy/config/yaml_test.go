@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want interface{}
+	}{
+		{
+			name: "scalars",
+			doc:  "---\na: 1\nb: 1.5\nc: true\nd: ~\ne: text#not a comment # comment\nf: \"quoted: \\\"x\\\"\" # comment\ng: 'it''s'\nh: 0077\n",
+			want: map[string]interface{}{"a": int64(1), "b": 1.5, "c": true, "d": nil, "e": "text#not a comment", "f": `quoted: "x"`, "g": "it's", "h": int64(77)},
+		},
+		{
+			name: "nested mappings and sequences",
+			doc:  "gas:\n  budget: 5\nups:\n  - name: fs\n    command:\n    - fs-server\n    - /srv\n  - url: https://x/mcp\nflat:\n- a\n- b\n",
+			want: map[string]interface{}{
+				"gas": map[string]interface{}{"budget": int64(5)},
+				"ups": []interface{}{
+					map[string]interface{}{"name": "fs", "command": []interface{}{"fs-server", "/srv"}},
+					map[string]interface{}{"url": "https://x/mcp"},
+				},
+				"flat": []interface{}{"a", "b"},
+			},
+		},
+		{
+			name: "flow collections",
+			doc:  `tools: [shell, "a, b", 'c']` + "\nup: {name: fs, command: [srv, -v]}\nempty: []\n",
+			want: map[string]interface{}{
+				"tools": []interface{}{"shell", "a, b", "c"},
+				"up":    map[string]interface{}{"name": "fs", "command": []interface{}{"srv", "-v"}},
+				"empty": []interface{}{},
+			},
+		},
+		{
+			name: "block scalars",
+			doc:  "lit: |\n  line one\n\n  line two\nfold: >-\n  one\n  two\nnext: x\n",
+			want: map[string]interface{}{"lit": "line one\n\nline two\n", "fold": "one two", "next": "x"},
+		},
+		{
+			name: "empty document",
+			doc:  "# only a comment\n",
+			want: map[string]interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeYAML([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("decodeYAML failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeYAML = %#v, expected %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeYAML_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n"},
+		{"duplicate key", "a: 1\na: 2\n"},
+		{"bad indentation", "a: 1\n   b: 2\n"},
+		{"alias", "a: *ref\n"},
+		{"unterminated quote", "a: \"x\n"},
+		{"unterminated flow", "a: [1, 2\n"},
+		{"missing colon", "just text\n"},
+		{"second document", "a: 1\n---\nb: 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeYAML([]byte(tt.doc)); !errors.Is(err, ErrSyntax) {
+				t.Errorf("decodeYAML = %v, expected ErrSyntax", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+// Package crash writes sanitized crash reports.
+//
+// When the proxy panics, a report is written to a local directory and,
+// optionally, posted to a configured endpoint so maintainers can debug
+// field failures. Reports are built for sharing: they carry the stack,
+// build information, and the IDs of the last routing decisions, but no
+// message contents unless explicitly enabled.
+//
+// ReproRecorder likewise captures reproduction bundles when server data
+// fails to parse or validate: the offending bytes, scrubbed and bounded,
+// with the session, server, and build they came from, ready to attach
+// to an interoperability bug report. The norecorder build tag leaves
+// the recorder out; NewReproRecorder then always returns nil.
+//
+// # Usage
+//
+//	reporter := crash.New(&crash.Config{Dir: "/var/lib/mcp-sentinel/crash"})
+//	defer reporter.Handle()
+//
+// Handle re-panics after reporting, so the process still exits with the
+// runtime's panic status. Goroutines other than main need their own
+// boundary: wrap them with reporter.Go.
+//
+// # Security Notes
+//
+// Panic values often embed the data being processed. Unless
+// IncludeMessages is set, quoted strings and JSON fragments in the panic
+// value are replaced with placeholders and the value is truncated.
+package crash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultRecentDecisions is the number of decision IDs included by default.
+const DefaultRecentDecisions = 20
+
+// maxPanicValue bounds the sanitized panic value length.
+const maxPanicValue = 256
+
+// Config contains crash reporting configuration.
+type Config struct {
+	// Dir receives crash reports (empty disables local reports)
+	Dir string
+
+	// Endpoint receives crash reports by HTTP POST (empty disables upload)
+	Endpoint string
+
+	// RecentDecisions is how many recent decision IDs to include
+	// (zero uses DefaultRecentDecisions)
+	RecentDecisions int
+
+	// IncludeMessages keeps the panic value unsanitized; it may then
+	// contain message contents
+	IncludeMessages bool
+}
+
+// BuildInfo identifies the binary that crashed.
+type BuildInfo struct {
+	GoVersion   string `json:"go_version"`
+	Module      string `json:"module,omitempty"`
+	Version     string `json:"version,omitempty"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	VCSModified bool   `json:"vcs_modified,omitempty"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+}
+
+// Report is a single crash report.
+type Report struct {
+	Time        time.Time `json:"time"`
+	Panic       string    `json:"panic"`
+	Sanitized   bool      `json:"sanitized"`
+	Goroutine   string    `json:"goroutine"`
+	Stack       string    `json:"stack"`
+	Build       BuildInfo `json:"build"`
+	DecisionIDs []string  `json:"decision_ids,omitempty"`
+}
+
+// Reporter writes crash reports.
+type Reporter struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	decisions func(n int) []string
+	version   string
+}
+
+// New creates a reporter. A nil cfg reports nowhere but still logs.
+func New(cfg *Config) *Reporter {
+	r := &Reporter{client: &http.Client{Timeout: 5 * time.Second}}
+	if cfg != nil {
+		r.cfg = *cfg
+	}
+	if r.cfg.RecentDecisions <= 0 {
+		r.cfg.RecentDecisions = DefaultRecentDecisions
+	}
+	return r
+}
+
+// SetVersion records the application version in build info.
+func (r *Reporter) SetVersion(version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = version
+}
+
+// SetDecisionSource registers a function returning the IDs of the most
+// recent n routing decisions.
+func (r *Reporter) SetDecisionSource(fn func(n int) []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisions = fn
+}
+
+// Handle reports a panic in progress and re-panics. It must be called
+// directly by defer.
+func (r *Reporter) Handle() {
+	if p := recover(); p != nil {
+		r.Report(p, debug.Stack())
+		panic(p)
+	}
+}
+
+// Go runs fn in a new goroutine with a crash-reporting boundary.
+func (r *Reporter) Go(fn func()) {
+	go func() {
+		defer r.Handle()
+		fn()
+	}()
+}
+
+// Report builds, writes, and uploads a report for panic value p.
+// Failures are logged; reporting never panics.
+func (r *Reporter) Report(p interface{}, stack []byte) *Report {
+	report := r.build(p, stack)
+
+	if r.cfg.Dir != "" {
+		if path, err := r.write(report); err != nil {
+			log.Printf("crash: failed to write report: %v", err)
+		} else {
+			log.Printf("crash: report written to %s", path)
+		}
+	}
+	if r.cfg.Endpoint != "" {
+		if err := r.upload(report); err != nil {
+			log.Printf("crash: failed to upload report: %v", err)
+		}
+	}
+	return report
+}
+
+// build assembles a report.
+func (r *Reporter) build(p interface{}, stack []byte) *Report {
+	r.mu.Lock()
+	decisions, version := r.decisions, r.version
+	r.mu.Unlock()
+
+	value := fmt.Sprint(p)
+	if !r.cfg.IncludeMessages {
+		value = Sanitize(value)
+	}
+	goroutine, rest, _ := bytes.Cut(stack, []byte("\n"))
+
+	report := &Report{
+		Time:      time.Now().UTC(),
+		Panic:     value,
+		Sanitized: !r.cfg.IncludeMessages,
+		Goroutine: string(goroutine),
+		Stack:     string(rest),
+		Build:     buildInfo(version),
+	}
+	if decisions != nil {
+		report.DecisionIDs = safeDecisions(decisions, r.cfg.RecentDecisions)
+	}
+	return report
+}
+
+// safeDecisions calls the decision source, tolerating its failure while
+// the process is already crashing.
+func safeDecisions(fn func(int) []string, n int) (ids []string) {
+	defer func() {
+		if recover() != nil {
+			ids = nil
+		}
+	}()
+	return fn(n)
+}
+
+// write stores report as JSON in the report directory.
+func (r *Reporter) write(report *Report) (string, error) {
+	if err := os.MkdirAll(r.cfg.Dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.cfg.Dir, fmt.Sprintf("crash-%d.json", report.Time.UnixNano()))
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// upload posts report to the configured endpoint.
+func (r *Reporter) upload(report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(r.cfg.Endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// buildInfo reads the binary's embedded build information.
+func buildInfo(version string) BuildInfo {
+	b := BuildInfo{
+		GoVersion: runtime.Version(),
+		Version:   version,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	b.Module = info.Main.Path
+	if b.Version == "" {
+		b.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.VCSRevision = s.Value
+		case "vcs.modified":
+			b.VCSModified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+var (
+	quotedString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`)
+	jsonFragment = regexp.MustCompile(`[{\[][^{}\[\]]*[}\]]`)
+)
+
+// Sanitize strips likely message contents from a panic value: quoted
+// strings and JSON fragments are replaced with placeholders and the
+// result is truncated.
+func Sanitize(value string) string {
+	value = quotedString.ReplaceAllString(value, `"<redacted>"`)
+	for {
+		next := jsonFragment.ReplaceAllString(value, "<json>")
+		if next == value {
+			break
+		}
+		value = next
+	}
+	if len(value) > maxPanicValue {
+		value = value[:maxPanicValue] + "…"
+	}
+	return value
+}
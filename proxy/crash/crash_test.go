@@ -0,0 +1,62 @@
+package crash
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{`index out of range [5] with length 3`, `index out of range <json> with length 3`},
+		{`bad token "sk-live-abc123"`, `bad token "<redacted>"`},
+		{`unexpected {"path":"/home/alice/.ssh/id_rsa","nested":{"a":1}}`, `unexpected <json>`},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.in); got != tt.expected {
+			t.Errorf("Sanitize(%q) = %q, expected %q", tt.in, got, tt.expected)
+		}
+	}
+	if got := Sanitize(strings.Repeat("x", 1000)); len(got) > maxPanicValue+len("…") {
+		t.Errorf("expected truncation, got %d bytes", len(got))
+	}
+}
+
+func TestReporter_WritesReport(t *testing.T) {
+	dir := t.TempDir()
+	r := New(&Config{Dir: dir, RecentDecisions: 2})
+	r.SetVersion("1.2.3")
+	r.SetDecisionSource(func(n int) []string {
+		return []string{"d-1", "d-2", "d-3"}[:n]
+	})
+
+	func() {
+		defer func() { recover() }()
+		defer r.Handle()
+		panic(`secret "hunter2" leaked`)
+	}()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("bad report: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("report leaked panic contents")
+	}
+	if len(report.DecisionIDs) != 2 || report.Build.Version != "1.2.3" || report.Stack == "" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if info, _ := os.Stat(files[0]); info.Mode().Perm() != 0o600 {
+		t.Errorf("report mode = %v, expected 0600", info.Mode().Perm())
+	}
+}
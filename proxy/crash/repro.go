@@ -0,0 +1,246 @@
+package crash
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Reproduction bundle defaults.
+const (
+	// DefaultReproBytes is the offending data kept in a bundle
+	DefaultReproBytes = 4096
+	// DefaultReproBundles is the number of bundles kept in the directory
+	DefaultReproBundles = 100
+)
+
+// Kinds of failure a bundle reproduces.
+const (
+	// ReproParse is data that is not a JSON-RPC message
+	ReproParse = "parse"
+	// ReproValidation is a message whose result does not match its
+	// method's schema
+	ReproValidation = "validation"
+)
+
+// maxReproSeen bounds the digests remembered to skip repeated bundles.
+const maxReproSeen = 4096
+
+// ReproConfig contains reproduction bundle configuration.
+type ReproConfig struct {
+	// Dir receives the bundles (required)
+	Dir string
+
+	// MaxBytes is how much of the offending data is kept (zero uses
+	// DefaultReproBytes)
+	MaxBytes int
+
+	// MaxBundles is how many bundles are kept; the oldest are removed
+	// (zero uses DefaultReproBundles)
+	MaxBundles int
+
+	// IncludeMessages keeps the offending data unsanitized; it may then
+	// contain message contents
+	IncludeMessages bool
+}
+
+// Bundle is a minimal reproduction of a failure to process data from
+// an MCP peer: the offending bytes and enough context to report the
+// interoperability bug.
+type Bundle struct {
+	Time time.Time `json:"time"`
+
+	// Kind is ReproParse or ReproValidation, and Error the failure
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+
+	// Session and Direction locate the data; Method is the request it
+	// answered, if known
+	Session   string `json:"session,omitempty"`
+	Direction string `json:"direction"`
+	Method    string `json:"method,omitempty"`
+
+	// ProtocolVersion, ServerName, and ServerVersion are from the
+	// server's initialize result, if seen
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+	ServerName      string `json:"server_name,omitempty"`
+	ServerVersion   string `json:"server_version,omitempty"`
+
+	// Size and SHA256 describe the original data; Data is its first
+	// MaxBytes, sanitized unless IncludeMessages is set
+	Size      int    `json:"size"`
+	SHA256    string `json:"sha256"`
+	Data      []byte `json:"data"`
+	Text      string `json:"text,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Sanitized bool   `json:"sanitized"`
+
+	Build BuildInfo `json:"build"`
+}
+
+// ReproRecorder writes reproduction bundles to a directory. A router
+// hands it the data it failed to parse or validate; see router.Config.
+//
+// Identical data is captured once per process, and the directory keeps
+// at most MaxBundles bundles, so a peer repeating the same malformed
+// message cannot fill the disk.
+//
+// # Security Notes
+//
+// Unless IncludeMessages is set, the data is scrubbed the way it could
+// be reproduced without its contents: object keys, numbers, JSON
+// punctuation, escapes, control characters, and invalid UTF-8 are
+// kept, while letters, digits, and other characters in string values,
+// and words outside strings other than true, false, and null, are
+// replaced; see ScrubJSON.
+//
+// # Thread Safety
+//
+// Safe for concurrent use.
+type ReproRecorder struct {
+	cfg ReproConfig
+
+	mu      sync.Mutex
+	version string
+	seen    map[string]bool
+	ring    []string
+	next    int
+}
+
+// SetVersion records the application version in build info.
+func (r *ReproRecorder) SetVersion(version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = version
+}
+
+// ScrubJSON replaces the likely contents of JSON-ish data while keeping
+// what makes it malformed: object keys, numbers, punctuation,
+// whitespace, escape sequences, control characters, and invalid UTF-8
+// survive; letters and digits in string values become x and 0,
+// non-ASCII characters x, and words outside strings other than true,
+// false, and null x as well. The result has the same length as data
+// except where a multi-byte character became one x.
+func ScrubJSON(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := stringEnd(data, i)
+			if isKey(data, end) {
+				out = append(out, data[i:end]...)
+			} else {
+				out = scrubString(out, data[i:end])
+			}
+			i = end
+		case c == '-' || c >= '0' && c <= '9':
+			// Keep numbers whole, exponents included
+			j := i + 1
+			for j < len(data) && strings.IndexByte("0123456789.eE+-", data[j]) >= 0 {
+				j++
+			}
+			out = append(out, data[i:j]...)
+			i = j
+		case isLetter(c):
+			j := i
+			for j < len(data) && isLetter(data[j]) {
+				j++
+			}
+			switch word := string(data[i:j]); word {
+			case "true", "false", "null":
+				out = append(out, word...)
+			default:
+				out = append(out, strings.Repeat("x", j-i)...)
+			}
+			i = j
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				out = append(out, c)
+			} else {
+				out = append(out, 'x')
+			}
+			i += size
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out
+}
+
+// stringEnd returns the index just past the string starting at the
+// quote at data[start], or len(data) if it is unterminated.
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// isKey reports whether the string ending before end is an object key.
+func isKey(data []byte, end int) bool {
+	for ; end < len(data); end++ {
+		switch data[end] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ':':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// scrubString appends a scrubbed copy of a quoted string to out.
+func scrubString(out, s []byte) []byte {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case (i == 0 || i == len(s)-1) && c == '"':
+			out = append(out, c)
+			i++
+		case c == '\\' && i+1 < len(s):
+			n := 2
+			if s[i+1] == 'u' && i+6 <= len(s) {
+				n = 6
+			}
+			out = append(out, s[i:i+n]...)
+			i += n
+		case c < 0x20:
+			out = append(out, c)
+			i++
+		case c < utf8.RuneSelf:
+			switch {
+			case c >= '0' && c <= '9':
+				out = append(out, '0')
+			case isLetter(c):
+				out = append(out, 'x')
+			default:
+				out = append(out, c)
+			}
+			i++
+		default:
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				out = append(out, c)
+			} else {
+				out = append(out, 'x')
+			}
+			i += size
+		}
+	}
+	return out
+}
+
+// isLetter reports whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,17 @@
+//go:build norecorder
+
+package crash
+
+// ReproEnabled reports whether reproduction bundles are compiled in;
+// the norecorder build tag leaves them out.
+const ReproEnabled = false
+
+// NewReproRecorder returns nil: the build records no bundles.
+func NewReproRecorder(*ReproConfig) *ReproRecorder {
+	return nil
+}
+
+// Capture records nothing and returns "".
+func (r *ReproRecorder) Capture(*Bundle, []byte) string {
+	return ""
+}
@@ -0,0 +1,119 @@
+//go:build !norecorder
+
+package crash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// ReproEnabled reports whether reproduction bundles are compiled in;
+// the norecorder build tag leaves them out.
+const ReproEnabled = true
+
+// NewReproRecorder creates a recorder; nil when cfg is nil or has no
+// Dir.
+func NewReproRecorder(cfg *ReproConfig) *ReproRecorder {
+	if cfg == nil || cfg.Dir == "" {
+		return nil
+	}
+	r := &ReproRecorder{
+		cfg:  *cfg,
+		seen: make(map[string]bool),
+		ring: make([]string, maxReproSeen),
+	}
+	if r.cfg.MaxBytes <= 0 {
+		r.cfg.MaxBytes = DefaultReproBytes
+	}
+	if r.cfg.MaxBundles <= 0 {
+		r.cfg.MaxBundles = DefaultReproBundles
+	}
+	return r
+}
+
+// Capture completes b with the offending data and build information and
+// writes it to the directory. It returns the bundle's path, or "" if
+// the same data was captured before. Failures are logged.
+//
+// # Arguments
+//   - b: The failure's context; Time, Size, SHA256, Data, Text,
+//     Truncated, Sanitized, and Build are filled in
+//   - data: The offending bytes as received
+func (r *ReproRecorder) Capture(b *Bundle, data []byte) string {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	if r.seen[digest] {
+		r.mu.Unlock()
+		return ""
+	}
+	if old := r.ring[r.next]; old != "" {
+		delete(r.seen, old)
+	}
+	r.ring[r.next] = digest
+	r.seen[digest] = true
+	r.next = (r.next + 1) % len(r.ring)
+	version := r.version
+	r.mu.Unlock()
+
+	b.Time = time.Now().UTC()
+	b.Size, b.SHA256 = len(data), digest
+	if len(data) > r.cfg.MaxBytes {
+		data, b.Truncated = data[:r.cfg.MaxBytes], true
+	}
+	b.Sanitized = !r.cfg.IncludeMessages
+	if b.Sanitized {
+		data = ScrubJSON(data)
+		b.Error = Sanitize(b.Error)
+	}
+	b.Data = data
+	if utf8.Valid(data) {
+		b.Text = string(data)
+	}
+	b.Build = buildInfo(version)
+
+	path, err := r.write(b)
+	if err != nil {
+		log.Printf("crash: failed to write repro bundle: %v", err)
+		return ""
+	}
+	log.Printf("crash: %s failure reproduced in %s", b.Kind, path)
+	return path
+}
+
+// write stores b in the directory and removes the oldest bundles beyond
+// MaxBundles.
+func (r *ReproRecorder) write(b *Bundle) (string, error) {
+	if err := os.MkdirAll(r.cfg.Dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.cfg.Dir, fmt.Sprintf("repro-%d-%s.json", b.Time.UnixNano(), b.SHA256[:12]))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bundles, _ := filepath.Glob(filepath.Join(r.cfg.Dir, "repro-*.json"))
+	if len(bundles) > r.cfg.MaxBundles {
+		// Names start with the capture time, so they sort oldest first
+		sort.Strings(bundles)
+		for _, old := range bundles[:len(bundles)-r.cfg.MaxBundles] {
+			os.Remove(old)
+		}
+	}
+	return path, nil
+}
@@ -0,0 +1,75 @@
+//go:build !norecorder
+
+package crash
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScrubJSON(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","id":7,"result":{"text":"Secret 42"}}`, `{"jsonrpc":"0.0","id":7,"result":{"text":"xxxxxx 00"}}`},
+		{`{"ok":true,"v":null,"a":[1.5e3,false]}`, `{"ok":true,"v":null,"a":[1.5e3,false]}`},
+		{`Starting server on :8080`, `xxxxxxxx xxxxxx xx :8080`},
+		{`{"s":"tab\there é é"}`, `{"s":"xxx\txxxx x x"}`},
+		{"{\"s\":\"bad\x01\xff\"}", "{\"s\":\"xxx\x01\xff\"}"},
+		{`{"unterminated":"abc`, `{"unterminated":"xxx`},
+	}
+	for _, tt := range tests {
+		if got := string(ScrubJSON([]byte(tt.in))); got != tt.expected {
+			t.Errorf("ScrubJSON(%q) = %q, expected %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestReproRecorder_Capture(t *testing.T) {
+	if NewReproRecorder(nil) != nil || NewReproRecorder(&ReproConfig{}) != nil {
+		t.Fatal("recorder without a directory")
+	}
+	dir := filepath.Join(t.TempDir(), "repro")
+	r := NewReproRecorder(&ReproConfig{Dir: dir, MaxBytes: 16, MaxBundles: 2})
+	r.SetVersion("1.2.3")
+
+	data := []byte(`{"jsonrpc":"2.0","id":1,"result":{"token":"hunter2"}` + "\n")
+	path := r.Capture(&Bundle{Kind: ReproParse, Error: `invalid character "x"`, Method: "tools/call", ServerName: "fs"}, data)
+	if path == "" {
+		t.Fatal("Capture wrote no bundle")
+	}
+	if again := r.Capture(&Bundle{Kind: ReproParse}, data); again != "" {
+		t.Errorf("identical data captured twice: %s", again)
+	}
+
+	raw, _ := os.ReadFile(path)
+	var b Bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		t.Fatalf("bad bundle: %v", err)
+	}
+	if strings.Contains(string(raw), "hunter2") || strings.Contains(b.Error, `"x"`) {
+		t.Errorf("bundle leaked contents: %s", raw)
+	}
+	if b.Size != len(data) || !b.Truncated || len(b.Data) != 16 || b.Text != string(b.Data) || !b.Sanitized ||
+		b.Method != "tools/call" || b.ServerName != "fs" || b.Build.Version != "1.2.3" || len(b.SHA256) != 64 {
+		t.Errorf("unexpected bundle: %+v", b)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("bundle mode = %v, expected 0600", info.Mode().Perm())
+	}
+
+	// Only the newest MaxBundles are kept
+	r.Capture(&Bundle{Kind: ReproValidation}, []byte(`{"a":1}`))
+	r.Capture(&Bundle{Kind: ReproValidation}, []byte(`{"a":2}`))
+	files, _ := filepath.Glob(filepath.Join(dir, "repro-*.json"))
+	if len(files) != 2 {
+		t.Errorf("%d bundles kept, expected 2", len(files))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("oldest bundle not removed: %v", err)
+	}
+}
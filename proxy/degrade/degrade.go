@@ -0,0 +1,280 @@
+// Package degrade implements the security degradation ladder.
+//
+// When the sentinel backend becomes unhealthy the proxy has to choose
+// between availability and protection. Rather than leaving that choice
+// implicit, the ladder makes it an explicit, configured sequence of
+// levels with automatic, audited transitions driven by backend health:
+//
+//	full → skip-council → native-only → failsafe (block-all | allow-all)
+//
+// # Transitions
+//
+//   - FailureThreshold consecutive backend failures step one level down
+//   - RecoveryThreshold consecutive successes step one level up
+//   - Operators can pin a level with Set; automatic transitions resume
+//     after Release
+//
+// # Thread Safety
+//
+// Ladder is safe for concurrent use.
+package degrade
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Level is a rung on the degradation ladder. Higher is more degraded.
+type Level int
+
+const (
+	// LevelFull runs every check
+	LevelFull Level = iota
+	// LevelSkipCouncil skips council voting but keeps registry and state checks
+	LevelSkipCouncil
+	// LevelNativeOnly skips all FFI checks and relies on Go-native checks
+	LevelNativeOnly
+	// LevelFailsafe stops checking entirely and applies the failsafe mode
+	LevelFailsafe
+)
+
+// String returns the configuration name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelFull:
+		return "full"
+	case LevelSkipCouncil:
+		return "skip-council"
+	case LevelNativeOnly:
+		return "native-only"
+	case LevelFailsafe:
+		return "failsafe"
+	default:
+		return fmt.Sprintf("level-%d", int(l))
+	}
+}
+
+// ParseLevel parses a level name as produced by Level.String.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelFull; l <= LevelFailsafe; l++ {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("degrade: unknown level %q", s)
+}
+
+// FailsafeMode is the behavior at LevelFailsafe.
+type FailsafeMode string
+
+const (
+	// FailsafeBlockAll rejects every checked message (fail-closed)
+	FailsafeBlockAll FailsafeMode = "block-all"
+	// FailsafeAllowAll forwards every message unchecked (fail-open)
+	FailsafeAllowAll FailsafeMode = "allow-all"
+)
+
+// ErrInvalidLadder is returned for inconsistent ladder configuration.
+var ErrInvalidLadder = errors.New("degrade: invalid ladder configuration")
+
+// Config contains degradation ladder configuration.
+type Config struct {
+	// Levels lists the rungs in order, starting at LevelFull. Levels not
+	// listed are skipped during transitions. Default: all four.
+	Levels []Level
+
+	// Failsafe selects block-all or allow-all at LevelFailsafe
+	// (default: FailsafeBlockAll)
+	Failsafe FailsafeMode
+
+	// FailureThreshold is the consecutive failures that step down
+	FailureThreshold int
+
+	// RecoveryThreshold is the consecutive successes that step up
+	RecoveryThreshold int
+}
+
+// DefaultConfig returns a fail-closed ladder using every level.
+func DefaultConfig() *Config {
+	return &Config{
+		Levels:            []Level{LevelFull, LevelSkipCouncil, LevelNativeOnly, LevelFailsafe},
+		Failsafe:          FailsafeBlockAll,
+		FailureThreshold:  3,
+		RecoveryThreshold: 10,
+	}
+}
+
+// Transition records a change of level.
+type Transition struct {
+	Time   time.Time `json:"time"`
+	From   Level     `json:"from"`
+	To     Level     `json:"to"`
+	Reason string    `json:"reason"`
+	Manual bool      `json:"manual"`
+}
+
+// Ladder tracks backend health and the current degradation level.
+type Ladder struct {
+	cfg Config
+
+	mu           sync.Mutex
+	index        int // position in cfg.Levels
+	failures     int
+	successes    int
+	pinned       bool
+	onTransition []func(Transition)
+}
+
+// New creates a ladder starting at its first (least degraded) level.
+// A nil cfg uses DefaultConfig.
+func New(cfg *Config) (*Ladder, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	c := *cfg
+	if len(c.Levels) == 0 {
+		c.Levels = DefaultConfig().Levels
+	}
+	if c.Failsafe == "" {
+		c.Failsafe = FailsafeBlockAll
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 3
+	}
+	if c.RecoveryThreshold <= 0 {
+		c.RecoveryThreshold = 10
+	}
+
+	if c.Levels[0] != LevelFull {
+		return nil, fmt.Errorf("%w: ladder must start at %s", ErrInvalidLadder, LevelFull)
+	}
+	for i := 1; i < len(c.Levels); i++ {
+		if c.Levels[i] <= c.Levels[i-1] || c.Levels[i] > LevelFailsafe {
+			return nil, fmt.Errorf("%w: levels must be strictly increasing", ErrInvalidLadder)
+		}
+	}
+	switch c.Failsafe {
+	case FailsafeBlockAll, FailsafeAllowAll:
+	default:
+		return nil, fmt.Errorf("%w: unknown failsafe mode %q", ErrInvalidLadder, c.Failsafe)
+	}
+
+	return &Ladder{cfg: c}, nil
+}
+
+// OnTransition registers a callback invoked after every level change.
+// Callbacks run synchronously and must not call back into the ladder.
+func (l *Ladder) OnTransition(fn func(Transition)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onTransition = append(l.onTransition, fn)
+}
+
+// Level returns the current level.
+func (l *Ladder) Level() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.Levels[l.index]
+}
+
+// Failsafe returns the configured failsafe mode.
+func (l *Ladder) Failsafe() FailsafeMode {
+	return l.cfg.Failsafe
+}
+
+// Pinned reports whether an operator has pinned the level.
+func (l *Ladder) Pinned() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pinned
+}
+
+// ReportSuccess records a healthy backend call.
+func (l *Ladder) ReportSuccess() {
+	l.mu.Lock()
+	l.failures = 0
+	l.successes++
+	if l.pinned || l.index == 0 || l.successes < l.cfg.RecoveryThreshold {
+		l.mu.Unlock()
+		return
+	}
+	l.successes = 0
+	t := l.moveLocked(l.index-1, "backend recovered", false)
+	l.mu.Unlock()
+	l.notify(t)
+}
+
+// ReportFailure records a failed backend call.
+func (l *Ladder) ReportFailure(err error) {
+	l.mu.Lock()
+	l.successes = 0
+	l.failures++
+	if l.pinned || l.index == len(l.cfg.Levels)-1 || l.failures < l.cfg.FailureThreshold {
+		l.mu.Unlock()
+		return
+	}
+	l.failures = 0
+	t := l.moveLocked(l.index+1, fmt.Sprintf("backend failing: %v", err), false)
+	l.mu.Unlock()
+	l.notify(t)
+}
+
+// Set pins the ladder at level until Release is called.
+func (l *Ladder) Set(level Level, reason string) error {
+	l.mu.Lock()
+	idx := -1
+	for i, lv := range l.cfg.Levels {
+		if lv == level {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: level %s not in ladder", ErrInvalidLadder, level)
+	}
+	l.pinned = true
+	t := l.moveLocked(idx, reason, true)
+	l.mu.Unlock()
+	l.notify(t)
+	return nil
+}
+
+// Release resumes automatic transitions from the current level.
+func (l *Ladder) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pinned = false
+	l.failures, l.successes = 0, 0
+}
+
+// moveLocked changes the level index. Caller must hold l.mu.
+// Returns nil if the level did not change.
+func (l *Ladder) moveLocked(idx int, reason string, manual bool) *Transition {
+	if idx == l.index {
+		return nil
+	}
+	t := &Transition{
+		Time:   time.Now().UTC(),
+		From:   l.cfg.Levels[l.index],
+		To:     l.cfg.Levels[idx],
+		Reason: reason,
+		Manual: manual,
+	}
+	l.index = idx
+	return t
+}
+
+// notify invokes transition callbacks outside the lock.
+func (l *Ladder) notify(t *Transition) {
+	if t == nil {
+		return
+	}
+	l.mu.Lock()
+	callbacks := append([]func(Transition){}, l.onTransition...)
+	l.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(*t)
+	}
+}
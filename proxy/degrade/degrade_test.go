@@ -0,0 +1,92 @@
+package degrade
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLadder_StepsDownAndRecovers(t *testing.T) {
+	l, err := New(&Config{FailureThreshold: 2, RecoveryThreshold: 2})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var transitions []Transition
+	l.OnTransition(func(tr Transition) { transitions = append(transitions, tr) })
+
+	boom := errors.New("ffi timeout")
+	for i := 0; i < 4; i++ {
+		l.ReportFailure(boom)
+	}
+	if l.Level() != LevelNativeOnly {
+		t.Fatalf("expected %s after 4 failures, got %s", LevelNativeOnly, l.Level())
+	}
+
+	l.ReportSuccess()
+	l.ReportSuccess()
+	if l.Level() != LevelSkipCouncil {
+		t.Errorf("expected %s after recovery, got %s", LevelSkipCouncil, l.Level())
+	}
+	if len(transitions) != 3 {
+		t.Errorf("expected 3 transitions, got %d", len(transitions))
+	}
+}
+
+func TestLadder_SkipsUnlistedLevels(t *testing.T) {
+	l, err := New(&Config{
+		Levels:           []Level{LevelFull, LevelFailsafe},
+		Failsafe:         FailsafeAllowAll,
+		FailureThreshold: 1,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	l.ReportFailure(errors.New("down"))
+	if l.Level() != LevelFailsafe {
+		t.Errorf("expected direct step to failsafe, got %s", l.Level())
+	}
+	if l.Failsafe() != FailsafeAllowAll {
+		t.Errorf("expected allow-all failsafe, got %s", l.Failsafe())
+	}
+}
+
+func TestLadder_ManualPin(t *testing.T) {
+	l, _ := New(&Config{FailureThreshold: 1, RecoveryThreshold: 1})
+	if err := l.Set(LevelSkipCouncil, "maintenance"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	l.ReportSuccess()
+	if l.Level() != LevelSkipCouncil {
+		t.Error("pinned level should ignore health reports")
+	}
+	l.Release()
+	l.ReportSuccess()
+	if l.Level() != LevelFull {
+		t.Errorf("expected recovery after release, got %s", l.Level())
+	}
+}
+
+func TestLadder_InvalidConfig(t *testing.T) {
+	bad := []*Config{
+		{Levels: []Level{LevelSkipCouncil}},
+		{Levels: []Level{LevelFull, LevelFull}},
+		{Failsafe: "maybe"},
+	}
+	for _, cfg := range bad {
+		if _, err := New(cfg); !errors.Is(err, ErrInvalidLadder) {
+			t.Errorf("New(%+v) = %v, expected ErrInvalidLadder", cfg, err)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for l := LevelFull; l <= LevelFailsafe; l++ {
+		got, err := ParseLevel(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), got, err)
+		}
+	}
+	if _, err := ParseLevel("half"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
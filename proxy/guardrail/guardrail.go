@@ -0,0 +1,214 @@
+// Package guardrail pins tool arguments to fixed or constrained values.
+//
+// Some arguments are too dangerous to leave to the agent even when the
+// call itself is allowed: where a file may be written, how long a
+// command may run. A Guard rewrites such arguments before the request
+// is checked and forwarded, and reports every rewrite so it can be
+// logged.
+//
+// # Constraints
+//
+//   - Fixed: the argument is always set to this value
+//   - Default: the argument is set to this value when absent
+//   - Min / Max: numeric arguments are clamped into range
+//   - PathPrefix: string paths are confined under this directory
+//
+// # Example
+//
+//	guard := guardrail.New([]guardrail.Rule{
+//	    {Tool: "write_file", Constraints: []guardrail.Constraint{
+//	        {Arg: "directory", PathPrefix: "/workspace/out"},
+//	    }},
+//	    {Tool: "execute_command", Constraints: []guardrail.Constraint{
+//	        {Arg: "timeout", Max: guardrail.Float(30), Default: json.RawMessage(`30`)},
+//	    }},
+//	})
+package guardrail
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrUnsupportedValue is returned when a constrained argument has a
+// type the constraint cannot be applied to.
+var ErrUnsupportedValue = errors.New("guardrail: argument type does not match constraint")
+
+// Constraint pins a single top-level argument.
+type Constraint struct {
+	// Arg is the argument name
+	Arg string `json:"arg"`
+
+	// Fixed forces the argument to this JSON value
+	Fixed json.RawMessage `json:"fixed,omitempty"`
+
+	// Default is inserted when the argument is absent
+	Default json.RawMessage `json:"default,omitempty"`
+
+	// Min clamps numeric arguments from below
+	Min *float64 `json:"min,omitempty"`
+
+	// Max clamps numeric arguments from above
+	Max *float64 `json:"max,omitempty"`
+
+	// PathPrefix confines string path arguments under a directory
+	PathPrefix string `json:"path_prefix,omitempty"`
+}
+
+// Rule applies constraints to one tool.
+type Rule struct {
+	// Tool is the tool name
+	Tool string `json:"tool"`
+
+	// Constraints are applied in order
+	Constraints []Constraint `json:"constraints"`
+}
+
+// Rewrite records one argument change made by the guard.
+type Rewrite struct {
+	Tool string          `json:"tool"`
+	Arg  string          `json:"arg"`
+	From json.RawMessage `json:"from,omitempty"`
+	To   json.RawMessage `json:"to"`
+}
+
+// Guard applies argument constraints to tools/call params.
+//
+// Guard is immutable after construction and safe for concurrent use.
+type Guard struct {
+	rules map[string][]Constraint
+}
+
+// New creates a guard from rules. Rules for the same tool are merged.
+func New(rules []Rule) *Guard {
+	g := &Guard{rules: make(map[string][]Constraint, len(rules))}
+	for _, rule := range rules {
+		g.rules[rule.Tool] = append(g.rules[rule.Tool], rule.Constraints...)
+	}
+	return g
+}
+
+// Float returns a pointer to v, for use in Min and Max.
+func Float(v float64) *float64 {
+	return &v
+}
+
+// Apply constrains the arguments of a tools/call.
+//
+// # Arguments
+//   - toolName: Tool being invoked
+//   - params: Raw tools/call params ({"name":..., "arguments":{...}})
+//
+// # Returns
+//   - Params to forward (unchanged if no rewrite happened)
+//   - Rewrites performed, in constraint order
+//   - Error if params are malformed or a constrained argument has an
+//     incompatible type (callers should block the call)
+func (g *Guard) Apply(toolName string, params json.RawMessage) (json.RawMessage, []Rewrite, error) {
+	constraints := g.rules[toolName]
+	if len(constraints) == 0 {
+		return params, nil, nil
+	}
+
+	var call map[string]json.RawMessage
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, nil, fmt.Errorf("guardrail: malformed params: %w", err)
+	}
+	args := map[string]json.RawMessage{}
+	if raw, ok := call["arguments"]; ok && !bytes.Equal(raw, []byte("null")) {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, nil, fmt.Errorf("guardrail: malformed arguments: %w", err)
+		}
+	}
+
+	var rewrites []Rewrite
+	for _, c := range constraints {
+		current, present := args[c.Arg]
+		next, err := c.apply(current, present)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s.%s: %v", ErrUnsupportedValue, toolName, c.Arg, err)
+		}
+		if next == nil || (present && jsonEqual(current, next)) {
+			continue
+		}
+		args[c.Arg] = next
+		rewrites = append(rewrites, Rewrite{Tool: toolName, Arg: c.Arg, From: current, To: next})
+	}
+	if len(rewrites) == 0 {
+		return params, nil, nil
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("guardrail: encode arguments: %w", err)
+	}
+	call["arguments"] = encoded
+	out, err := json.Marshal(call)
+	if err != nil {
+		return nil, nil, fmt.Errorf("guardrail: encode params: %w", err)
+	}
+	return out, rewrites, nil
+}
+
+// apply returns the constrained value, or nil to leave the argument as is.
+func (c *Constraint) apply(current json.RawMessage, present bool) (json.RawMessage, error) {
+	if len(c.Fixed) > 0 {
+		return c.Fixed, nil
+	}
+	if !present {
+		if len(c.Default) > 0 {
+			return c.Default, nil
+		}
+		return nil, nil
+	}
+
+	if c.Min != nil || c.Max != nil {
+		var n float64
+		if err := json.Unmarshal(current, &n); err != nil {
+			return nil, errors.New("expected number")
+		}
+		if c.Min != nil && n < *c.Min {
+			n = *c.Min
+		}
+		if c.Max != nil && n > *c.Max {
+			n = *c.Max
+		}
+		return json.Marshal(n)
+	}
+
+	if c.PathPrefix != "" {
+		var p string
+		if err := json.Unmarshal(current, &p); err != nil {
+			return nil, errors.New("expected string path")
+		}
+		return json.Marshal(confinePath(c.PathPrefix, p))
+	}
+	return nil, nil
+}
+
+// confinePath places p under prefix. Paths already under prefix are
+// only cleaned; anything else (including ".." escapes) is re-rooted.
+func confinePath(prefix, p string) string {
+	prefix = path.Clean("/" + prefix)
+	cleaned := path.Clean("/" + p)
+	if !path.IsAbs(p) {
+		cleaned = path.Clean(prefix + "/" + p)
+	}
+	if cleaned == prefix || strings.HasPrefix(cleaned, prefix+"/") {
+		return cleaned
+	}
+	return path.Join(prefix, path.Clean("/"+p))
+}
+
+// jsonEqual compares two JSON values after compaction.
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
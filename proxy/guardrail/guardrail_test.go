@@ -0,0 +1,66 @@
+package guardrail
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestGuard_Apply(t *testing.T) {
+	g := New([]Rule{
+		{Tool: "write_file", Constraints: []Constraint{
+			{Arg: "directory", PathPrefix: "/workspace/out"},
+		}},
+		{Tool: "execute_command", Constraints: []Constraint{
+			{Arg: "timeout", Max: Float(30), Default: json.RawMessage(`30`)},
+			{Arg: "shell", Fixed: json.RawMessage(`false`)},
+		}},
+	})
+
+	tests := []struct {
+		tool     string
+		params   string
+		expected string
+		rewrites int
+	}{
+		{"write_file", `{"name":"write_file","arguments":{"directory":"/workspace/out/a"}}`,
+			`{"directory":"/workspace/out/a"}`, 0},
+		{"write_file", `{"name":"write_file","arguments":{"directory":"/etc"}}`,
+			`{"directory":"/workspace/out/etc"}`, 1},
+		{"write_file", `{"name":"write_file","arguments":{"directory":"../../etc"}}`,
+			`{"directory":"/workspace/out/etc"}`, 1},
+		{"execute_command", `{"name":"execute_command","arguments":{"command":"ls","timeout":600}}`,
+			`{"command":"ls","shell":false,"timeout":30}`, 2},
+		{"execute_command", `{"name":"execute_command","arguments":{"command":"ls"}}`,
+			`{"command":"ls","shell":false,"timeout":30}`, 2},
+		{"read_file", `{"name":"read_file","arguments":{"path":"/etc/passwd"}}`,
+			`{"path":"/etc/passwd"}`, 0},
+	}
+
+	for _, tt := range tests {
+		out, rewrites, err := g.Apply(tt.tool, json.RawMessage(tt.params))
+		if err != nil {
+			t.Fatalf("Apply(%s) failed: %v", tt.params, err)
+		}
+		var call struct {
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		json.Unmarshal(out, &call)
+		if !jsonEqual(call.Arguments, json.RawMessage(tt.expected)) {
+			t.Errorf("Apply(%s) arguments = %s, expected %s", tt.params, call.Arguments, tt.expected)
+		}
+		if len(rewrites) != tt.rewrites {
+			t.Errorf("Apply(%s) made %d rewrites, expected %d", tt.params, len(rewrites), tt.rewrites)
+		}
+	}
+}
+
+func TestGuard_TypeMismatch(t *testing.T) {
+	g := New([]Rule{{Tool: "execute_command", Constraints: []Constraint{
+		{Arg: "timeout", Max: Float(30)},
+	}}})
+	_, _, err := g.Apply("execute_command", json.RawMessage(`{"arguments":{"timeout":"forever"}}`))
+	if !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package harden applies runtime hardening to the proxy process itself.
+//
+// The proxy mediates every tool call an agent makes, so it should hold
+// as little privilege as possible. Apply runs once at startup, after
+// listeners are bound:
+//
+//  1. Set the umask
+//  2. Confine the filesystem view with chroot (requires root)
+//  3. Change to the configured working directory
+//  4. Drop to an unprivileged user and group
+//  5. Refuse to continue as root unless explicitly allowed
+//
+// The resulting privilege state is returned for logging and health
+// endpoints.
+//
+// # Security Notes
+//
+// Privilege dropping is irreversible: supplementary groups are cleared
+// and the real, effective, and saved IDs are all changed.
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Hardening errors.
+var (
+	ErrRunningAsRoot = errors.New("harden: refusing to run as root (use --allow-root to override)")
+	ErrUnsupported   = errors.New("harden: not supported on this platform")
+	ErrUnknownUser   = errors.New("harden: unknown user or group")
+)
+
+// Options selects the hardening steps to apply.
+type Options struct {
+	// AllowRoot permits continuing with an effective UID of 0
+	AllowRoot bool `json:"allow_root"`
+
+	// User is the account to drop to: "name", "uid", "name:group", or
+	// "uid:gid" (empty keeps the current user); names are looked up
+	// before the chroot, in the host's user database
+	User string `json:"user,omitempty"`
+
+	// Chroot confines the process to this directory (empty disables)
+	Chroot string `json:"chroot,omitempty"`
+
+	// Workdir is the working directory after confinement (empty keeps
+	// the current directory, or "/" after a chroot)
+	Workdir string `json:"workdir,omitempty"`
+
+	// Umask is the file mode creation mask (negative keeps the current mask)
+	Umask int `json:"umask"`
+}
+
+// DefaultOptions refuses root and leaves everything else unchanged.
+func DefaultOptions() Options {
+	return Options{Umask: -1}
+}
+
+// State is the process's effective privilege state.
+type State struct {
+	UID     int    `json:"uid"`
+	GID     int    `json:"gid"`
+	EUID    int    `json:"euid"`
+	EGID    int    `json:"egid"`
+	Groups  []int  `json:"groups"`
+	Root    bool   `json:"root"`
+	Dropped bool   `json:"dropped"`
+	Chroot  string `json:"chroot,omitempty"`
+	Workdir string `json:"workdir,omitempty"`
+	Umask   string `json:"umask,omitempty"`
+}
+
+// String summarizes the state for logs.
+func (s State) String() string {
+	parts := []string{fmt.Sprintf("uid=%d euid=%d gid=%d egid=%d", s.UID, s.EUID, s.GID, s.EGID)}
+	if s.Root {
+		parts = append(parts, "ROOT")
+	}
+	if s.Dropped {
+		parts = append(parts, "privileges dropped")
+	}
+	if s.Chroot != "" {
+		parts = append(parts, "chroot="+s.Chroot)
+	}
+	if s.Workdir != "" {
+		parts = append(parts, "workdir="+s.Workdir)
+	}
+	if s.Umask != "" {
+		parts = append(parts, "umask="+s.Umask)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseUmask parses an octal umask such as "0077" or "027".
+func ParseUmask(s string) (int, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("harden: invalid umask %q", s)
+	}
+	return int(n), nil
+}
+
+// resolveUser resolves a "user[:group]" spec to numeric IDs. Without a
+// group, the user's primary group is used.
+func resolveUser(spec string) (uid, gid int, err error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+
+	if uid, err = strconv.Atoi(name); err != nil {
+		u, lookupErr := user.Lookup(name)
+		if lookupErr != nil {
+			return 0, 0, fmt.Errorf("%w: %s", ErrUnknownUser, name)
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+		gid, _ = strconv.Atoi(u.Gid)
+	} else if !hasGroup {
+		u, lookupErr := user.LookupId(name)
+		if lookupErr != nil {
+			return 0, 0, fmt.Errorf("%w: uid %s has no account; specify uid:gid", ErrUnknownUser, name)
+		}
+		gid, _ = strconv.Atoi(u.Gid)
+	}
+
+	if hasGroup {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, lookupErr := user.LookupGroup(group)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("%w: group %s", ErrUnknownUser, group)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	if uid < 0 || gid < 0 {
+		return 0, 0, fmt.Errorf("%w: negative id in %q", ErrUnknownUser, spec)
+	}
+	return uid, gid, nil
+}
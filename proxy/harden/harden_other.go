@@ -0,0 +1,26 @@
+//go:build !linux && !darwin
+
+// Platforms without POSIX privilege controls.
+
+package harden
+
+import "os"
+
+// Apply fails if any hardening step is requested; none can be enforced here.
+func Apply(opts Options) (State, error) {
+	if opts.User != "" || opts.Chroot != "" || opts.Umask >= 0 {
+		return Current(), ErrUnsupported
+	}
+	if opts.Workdir != "" {
+		if err := os.Chdir(opts.Workdir); err != nil {
+			return Current(), err
+		}
+	}
+	return Current(), nil
+}
+
+// Current returns an empty privilege state with the working directory.
+func Current() State {
+	wd, _ := os.Getwd()
+	return State{UID: -1, GID: -1, EUID: -1, EGID: -1, Workdir: wd}
+}
@@ -0,0 +1,114 @@
+//go:build linux || darwin
+
+package harden
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Apply hardens the process according to opts.
+//
+// # Returns
+//   - The resulting privilege state
+//   - Error if any step fails or the process would remain root without
+//     AllowRoot; the caller should exit
+func Apply(opts Options) (State, error) {
+	return apply(opts, osSystem)
+}
+
+// system holds the calls apply makes, so tests can record their order.
+type system struct {
+	umask       func(mask int) int
+	chroot      func(path string) error
+	chdir       func(dir string) error
+	resolveUser func(spec string) (uid, gid int, err error)
+	setgroups   func(gids []int) error
+	setgid      func(gid int) error
+	setuid      func(uid int) error
+}
+
+// osSystem is the system of the running process.
+var osSystem = system{
+	umask:       syscall.Umask,
+	chroot:      syscall.Chroot,
+	chdir:       os.Chdir,
+	resolveUser: resolveUser,
+	setgroups:   syscall.Setgroups,
+	setgid:      syscall.Setgid,
+	setuid:      syscall.Setuid,
+}
+
+// apply is Apply with its calls made through sys.
+func apply(opts Options, sys system) (State, error) {
+	var st State
+	if opts.Umask >= 0 {
+		sys.umask(opts.Umask)
+		st.Umask = fmt.Sprintf("%04o", opts.Umask)
+	}
+
+	// The user is resolved before the chroot: the jail rarely holds the
+	// user database a name is looked up in
+	var uid, gid int
+	if opts.User != "" {
+		var err error
+		if uid, gid, err = sys.resolveUser(opts.User); err != nil {
+			return Current(), err
+		}
+	}
+
+	if opts.Chroot != "" {
+		if err := sys.chroot(opts.Chroot); err != nil {
+			return Current(), fmt.Errorf("harden: chroot %s: %w", opts.Chroot, err)
+		}
+		if err := sys.chdir("/"); err != nil {
+			return Current(), fmt.Errorf("harden: chdir after chroot: %w", err)
+		}
+		st.Chroot = opts.Chroot
+	}
+	if opts.Workdir != "" {
+		if err := sys.chdir(opts.Workdir); err != nil {
+			return Current(), fmt.Errorf("harden: workdir %s: %w", opts.Workdir, err)
+		}
+	}
+
+	dropped := false
+	if opts.User != "" {
+		// Order matters: groups and gid can only be changed while still privileged
+		if err := sys.setgroups([]int{}); err != nil {
+			return Current(), fmt.Errorf("harden: clear supplementary groups: %w", err)
+		}
+		if err := sys.setgid(gid); err != nil {
+			return Current(), fmt.Errorf("harden: setgid %d: %w", gid, err)
+		}
+		if err := sys.setuid(uid); err != nil {
+			return Current(), fmt.Errorf("harden: setuid %d: %w", uid, err)
+		}
+		dropped = true
+	}
+
+	cur := Current()
+	cur.Dropped = dropped
+	cur.Chroot = st.Chroot
+	cur.Umask = st.Umask
+	if cur.Root && !opts.AllowRoot {
+		return cur, ErrRunningAsRoot
+	}
+	return cur, nil
+}
+
+// Current returns the process's privilege state.
+func Current() State {
+	groups, _ := syscall.Getgroups()
+	wd, _ := os.Getwd()
+	return State{
+		UID:     syscall.Getuid(),
+		GID:     syscall.Getgid(),
+		EUID:    syscall.Geteuid(),
+		EGID:    syscall.Getegid(),
+		Groups:  groups,
+		Root:    syscall.Geteuid() == 0,
+		Workdir: wd,
+	}
+}
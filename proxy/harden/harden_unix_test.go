@@ -0,0 +1,113 @@
+//go:build linux || darwin
+
+package harden
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseUmask(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected int
+		ok       bool
+	}{
+		{"0077", 0o077, true},
+		{"027", 0o027, true},
+		{"0999", 0, false},
+		{"7777", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseUmask(tt.in)
+		if (err == nil) != tt.ok || got != tt.expected {
+			t.Errorf("ParseUmask(%q) = %o, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestResolveUser(t *testing.T) {
+	if uid, gid, err := resolveUser("65534:65534"); err != nil || uid != 65534 || gid != 65534 {
+		t.Errorf("resolveUser(65534:65534) = %d, %d, %v", uid, gid, err)
+	}
+	if uid, _, err := resolveUser("root"); err != nil || uid != 0 {
+		t.Errorf("resolveUser(root) = %d, %v", uid, err)
+	}
+	if _, _, err := resolveUser("no-such-user-xyz"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("expected ErrUnknownUser, got %v", err)
+	}
+}
+
+func TestApply_RefusesRoot(t *testing.T) {
+	st, err := Apply(DefaultOptions())
+	if os.Geteuid() == 0 {
+		if !errors.Is(err, ErrRunningAsRoot) || !st.Root {
+			t.Errorf("expected ErrRunningAsRoot as root, got %v (%s)", err, st)
+		}
+		opts := DefaultOptions()
+		opts.AllowRoot = true
+		if _, err := Apply(opts); err != nil {
+			t.Errorf("AllowRoot should permit root: %v", err)
+		}
+		return
+	}
+	if err != nil || st.Root {
+		t.Errorf("unprivileged Apply failed: %v (%s)", err, st)
+	}
+}
+
+// recordingSystem returns a system that records its calls in calls and
+// fails the call named fail.
+func recordingSystem(calls *[]string, fail string) system {
+	call := func(name string) error {
+		*calls = append(*calls, name)
+		if name == fail {
+			return errors.New(name + " failed")
+		}
+		return nil
+	}
+	return system{
+		umask:  func(int) int { call("umask"); return 0 },
+		chroot: func(string) error { return call("chroot") },
+		chdir:  func(dir string) error { return call("chdir " + dir) },
+		resolveUser: func(string) (int, int, error) {
+			if err := call("resolveUser"); err != nil {
+				return 0, 0, ErrUnknownUser
+			}
+			return 65534, 65534, nil
+		},
+		setgroups: func([]int) error { return call("setgroups") },
+		setgid:    func(int) error { return call("setgid") },
+		setuid:    func(int) error { return call("setuid") },
+	}
+}
+
+func TestApply_Order(t *testing.T) {
+	opts := Options{AllowRoot: true, User: "nobody", Chroot: "/var/empty", Workdir: "/run", Umask: 0o077}
+	tests := []struct {
+		name     string
+		fail     string
+		expected string
+	}{
+		{"all steps", "", "umask,resolveUser,chroot,chdir /,chdir /run,setgroups,setgid,setuid"},
+		{"unknown user fails before the chroot", "resolveUser", "umask,resolveUser"},
+		{"failed chroot keeps the user", "chroot", "umask,resolveUser,chroot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			st, err := apply(opts, recordingSystem(&calls, tt.fail))
+			if got := strings.Join(calls, ","); got != tt.expected {
+				t.Errorf("calls %s, expected %s", got, tt.expected)
+			}
+			if (err != nil) != (tt.fail != "") {
+				t.Errorf("err = %v", err)
+			}
+			if err == nil && (!st.Dropped || st.Chroot != "/var/empty" || st.Umask != "0077") {
+				t.Errorf("state = %+v", st)
+			}
+		})
+	}
+}
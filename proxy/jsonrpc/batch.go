@@ -0,0 +1,94 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Batch errors.
+var (
+	// ErrEmptyBatch is returned for a batch with no messages, which
+	// JSON-RPC 2.0 treats as an invalid request
+	ErrEmptyBatch = errors.New("jsonrpc: empty batch")
+
+	// ErrNotObject is the Err of a batch element that is not a JSON
+	// object, such as a number or a nested array; JSON-RPC 2.0 answers
+	// it as an invalid request
+	ErrNotObject = errors.New("jsonrpc: batch element is not an object")
+)
+
+// BatchElement is one element of a batch.
+type BatchElement struct {
+	// Raw is the element as received
+	Raw json.RawMessage
+
+	// Message is the parsed element, or nil if it is not a valid
+	// message
+	Message *Message
+
+	// Err is why the element is not a valid message
+	Err error
+}
+
+// IsBatch reports whether data is a batch: a top-level JSON array. It
+// looks only at the first non-whitespace byte.
+func IsBatch(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+// ParseBatch parses a JSON-RPC 2.0 batch: a top-level array of
+// messages.
+//
+// Elements are parsed independently, as the specification requires: an
+// invalid element does not fail the batch, but is returned with its
+// Err set so it can be answered with its own error. Batches do not
+// nest: an element that is itself an array fails with ErrNotObject
+// like any other element that is not an object.
+//
+// # Arguments
+//   - data: Raw JSON bytes of the batch
+//
+// # Returns
+//   - The elements in order
+//   - ErrInvalidJSON if data is not a JSON array, or ErrEmptyBatch if
+//     the array is empty
+//
+// # Example
+//
+//	elems, err := jsonrpc.ParseBatch([]byte(`[{"jsonrpc":"2.0","method":"ping","id":1},42]`))
+//	// elems[0].Message.Method == "ping"; elems[1].Err != nil
+func ParseBatch(data []byte) ([]BatchElement, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	if len(raws) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	elems := make([]BatchElement, len(raws))
+	for i, raw := range raws {
+		elems[i].Raw = raw
+		if !bytes.HasPrefix(bytes.TrimLeft(raw, " \t\r\n"), []byte{'{'}) {
+			elems[i].Err = ErrNotObject
+			continue
+		}
+		elems[i].Message, elems[i].Err = Parse(raw)
+	}
+	return elems, nil
+}
+
+// SerializeBatch converts messages to a JSON-RPC batch.
+//
+// # Returns
+//   - JSON bytes of the array
+//   - ErrEmptyBatch if msgs is empty: an empty batch is not valid, and
+//     a batch of notifications is answered with nothing at all
+func SerializeBatch(msgs []*Message) ([]byte, error) {
+	if len(msgs) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	return json.Marshal(msgs)
+}
@@ -0,0 +1,75 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestIsBatch(t *testing.T) {
+	tests := []struct {
+		data  string
+		batch bool
+	}{
+		{`[{"jsonrpc":"2.0","method":"ping","id":1}]`, true},
+		{" \n\t[]", true},
+		{`{"jsonrpc":"2.0","method":"ping","id":1}`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsBatch([]byte(tt.data)); got != tt.batch {
+			t.Errorf("IsBatch(%q) = %t, expected %t", tt.data, got, tt.batch)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	elems, err := ParseBatch([]byte(`[
+		{"jsonrpc":"2.0","method":"tools/list","id":1},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"1.0","method":"ping","id":2},
+		42,
+		[{"jsonrpc":"2.0","method":"ping","id":3}]
+	]`))
+	if err != nil {
+		t.Fatalf("ParseBatch failed: %v", err)
+	}
+	if len(elems) != 5 {
+		t.Fatalf("got %d elements, expected 5", len(elems))
+	}
+	if elems[0].Err != nil || elems[0].Message.Type() != TypeRequest || elems[1].Message.Type() != TypeNotification {
+		t.Errorf("valid elements = %+v, %+v", elems[0], elems[1])
+	}
+	if !errors.Is(elems[2].Err, ErrInvalidVersion) || elems[2].Message != nil {
+		t.Errorf("element 2 = %+v, expected ErrInvalidVersion", elems[2])
+	}
+	if !errors.Is(elems[3].Err, ErrNotObject) || string(elems[3].Raw) != "42" {
+		t.Errorf("element 3 = %+v, expected ErrNotObject", elems[3])
+	}
+	if !errors.Is(elems[4].Err, ErrNotObject) || elems[4].Message != nil {
+		t.Errorf("nested batch = %+v, expected ErrNotObject", elems[4])
+	}
+
+	if _, err := ParseBatch([]byte(`[]`)); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("empty batch = %v, expected ErrEmptyBatch", err)
+	}
+	if _, err := ParseBatch([]byte(`[{"jsonrpc":"2.0"`)); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("truncated batch = %v, expected ErrInvalidJSON", err)
+	}
+}
+
+func TestSerializeBatch(t *testing.T) {
+	a, _ := NewResponse(json.RawMessage(`1`), map[string]string{"ok": "yes"})
+	b, _ := NewErrorResponse(json.RawMessage(`"b"`), MethodNotFound, "Method not found", nil)
+	data, err := SerializeBatch([]*Message{a, b})
+	if err != nil {
+		t.Fatalf("SerializeBatch failed: %v", err)
+	}
+	elems, err := ParseBatch(data)
+	if err != nil || len(elems) != 2 || string(elems[0].Message.ID) != "1" || elems[1].Message.Error.Code != MethodNotFound {
+		t.Errorf("round trip of %s = %+v, %v", data, elems, err)
+	}
+	if _, err := SerializeBatch(nil); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("empty SerializeBatch = %v, expected ErrEmptyBatch", err)
+	}
+}
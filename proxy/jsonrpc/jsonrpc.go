@@ -12,6 +12,9 @@
 //   - Notification: Has method and params but no id (fire-and-forget)
 //   - Response: Has result or error, and id matching a request
 //
+// A batch is a JSON array of such messages; see ParseBatch and
+// SerializeBatch.
+//
 // # MCP-Specific Methods
 //
 // Common MCP methods intercepted by the proxy:
@@ -42,6 +45,19 @@ import (
 // JSON-RPC 2.0 version constant.
 const Version = "2.0"
 
+// nullID is the explicit null request ID.
+const nullID = "null"
+
+// NullID returns the explicit null request ID, a new copy on every
+// call so no caller can alter another's.
+//
+// JSON-RPC 2.0 requires error responses to carry "id": null when the
+// request ID could not be determined (parse errors, invalid requests).
+// Omitting the field is not equivalent and strict clients reject it.
+func NullID() json.RawMessage {
+	return json.RawMessage(nullID)
+}
+
 // Common errors returned by the parser.
 var (
 	ErrInvalidJSON    = errors.New("jsonrpc: invalid JSON")
@@ -303,8 +319,11 @@ func NewResponse(id json.RawMessage, result interface{}) (*Message, error) {
 
 // NewErrorResponse creates a new JSON-RPC error response.
 //
+// A nil or empty id is serialized as "id": null, as JSON-RPC 2.0
+// requires for errors that cannot be attributed to a request.
+//
 // # Arguments
-//   - id: Request ID this is responding to (nil for parse errors)
+//   - id: Request ID this is responding to (nil or NullID for parse errors)
 //   - code: Error code (use constants like ParseError, InvalidRequest)
 //   - message: Human-readable error message
 //   - data: Optional additional error data
@@ -312,6 +331,10 @@ func NewResponse(id json.RawMessage, result interface{}) (*Message, error) {
 // # Returns
 //   - New Message configured as an error response
 func NewErrorResponse(id json.RawMessage, code int, message string, data interface{}) (*Message, error) {
+	if len(id) == 0 {
+		id = NullID()
+	}
+
 	msg := &Message{
 		JSONRPC: Version,
 		ID:      id,
@@ -369,3 +392,26 @@ func ExtractToolName(msg *Message) string {
 	}
 	return params.Name
 }
+
+// ExtractResourceURI extracts the resource URI from resources/read or
+// resources/subscribe params.
+//
+// Returns empty string if not a resource message or if uri not found.
+func ExtractResourceURI(msg *Message) string {
+	switch msg.Method {
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+	default:
+		return ""
+	}
+	if len(msg.Params) == 0 {
+		return ""
+	}
+
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return ""
+	}
+	return params.URI
+}
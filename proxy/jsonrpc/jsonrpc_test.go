@@ -253,3 +253,61 @@ func TestError_Error(t *testing.T) {
 		t.Errorf("Error() = %q, expected %q", e.Error(), expected)
 	}
 }
+
+func TestExtractResourceURI(t *testing.T) {
+	msg := &Message{
+		JSONRPC: Version,
+		Method:  "resources/read",
+		Params:  json.RawMessage(`{"uri":"file:///etc/hosts"}`),
+	}
+	if uri := ExtractResourceURI(msg); uri != "file:///etc/hosts" {
+		t.Errorf("expected 'file:///etc/hosts', got %q", uri)
+	}
+
+	msg.Method = "tools/call"
+	if uri := ExtractResourceURI(msg); uri != "" {
+		t.Errorf("expected empty string for non-resource method, got %q", uri)
+	}
+}
+
+func TestNewErrorResponse_NullID(t *testing.T) {
+	for _, id := range []json.RawMessage{nil, {}, NullID()} {
+		msg, err := NewErrorResponse(id, ParseError, "Parse error", nil)
+		if err != nil {
+			t.Fatalf("NewErrorResponse failed: %v", err)
+		}
+
+		data, err := Serialize(msg)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("failed to decode serialized response: %v", err)
+		}
+		got, ok := raw["id"]
+		if !ok {
+			t.Fatalf("serialized error response omits id: %s", data)
+		}
+		if string(got) != "null" {
+			t.Errorf("expected id null, got %s", got)
+		}
+
+		parsed, err := Parse(data)
+		if err != nil {
+			t.Fatalf("Parse of null-id error failed: %v", err)
+		}
+		if parsed.Type() != TypeResponse {
+			t.Errorf("expected TypeResponse, got %v", parsed.Type())
+		}
+	}
+}
+
+func TestNullID_Copy(t *testing.T) {
+	id := NullID()
+	id[0] = 'X'
+	if got := string(NullID()); got != "null" {
+		t.Errorf("changing one NullID changed the next: %s", got)
+	}
+}
@@ -0,0 +1,534 @@
+// Package jsonscan is an incremental JSON scanner for large MCP results.
+//
+// Security checks on a tools/call result need only a few of its fields:
+// the content blocks, isError, and the structured content. Decoding a
+// multi-megabyte result with encoding/json copies every string out of
+// the raw bytes, and generic decoding adds a map per object on top. The
+// Scanner instead walks the document once from an io.Reader and keeps
+// a bounded prefix of each string the caller asks for, so the memory a
+// check needs does not grow with the size of the result.
+//
+// # Usage
+//
+// The Scanner is pull-based. Object and Array call back once per member
+// or element, and the callback consumes the value with String, Bool,
+// Capture, a nested Object or Array, or Skip:
+//
+//	s := jsonscan.NewScanner(r)
+//	err := s.Object(func(key string) error {
+//		if key == "isError" {
+//			isError, err = s.Bool()
+//			return err
+//		}
+//		return s.Skip()
+//	})
+//
+// # Security Notes
+//
+// Nesting is limited to MaxDepth so hostile documents cannot exhaust
+// the stack, and every string is validated while it is skipped. A
+// caller that keeps only a prefix of a field has inspected only that
+// prefix and must treat the rest as unchecked.
+//
+// # Thread Safety
+//
+// A Scanner must not be used concurrently.
+package jsonscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Scanner errors.
+var (
+	ErrSyntax   = errors.New("jsonscan: syntax error")
+	ErrTooDeep  = errors.New("jsonscan: nesting too deep")
+	ErrTrailing = errors.New("jsonscan: data after top-level value")
+)
+
+// MaxDepth is the deepest nesting of objects and arrays accepted.
+const MaxDepth = 256
+
+// maxKeyBytes bounds the object keys a Scanner keeps.
+const maxKeyBytes = 256
+
+// maxNumberBytes bounds the length of a number literal.
+const maxNumberBytes = 512
+
+// Kind is the type of the next JSON value.
+type Kind int
+
+const (
+	Invalid Kind = iota
+	Object
+	Array
+	String
+	Number
+	Bool
+	Null
+)
+
+// Scanner reads JSON values incrementally.
+type Scanner struct {
+	r       *bufio.Reader
+	depth   int
+	offset  int64
+	capture *capture
+}
+
+// capture records the bytes of a value being skipped.
+type capture struct {
+	buf   []byte
+	max   int
+	total int64
+}
+
+// NewScanner creates a Scanner reading from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReaderSize(r, 32*1024)}
+}
+
+// Offset returns the number of bytes consumed so far.
+func (s *Scanner) Offset() int64 {
+	return s.offset
+}
+
+// syntaxError reports an unexpected byte at the current offset.
+func (s *Scanner) syntaxError(what string) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrSyntax, what, s.offset)
+}
+
+// readByte consumes one byte. End of input is always unexpected here,
+// since it is only called inside a value.
+func (s *Scanner) readByte() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	s.offset++
+	if cp := s.capture; cp != nil {
+		cp.total++
+		if len(cp.buf) < cp.max {
+			cp.buf = append(cp.buf, c)
+		}
+	}
+	return c, nil
+}
+
+// peek skips whitespace and returns the next byte without consuming it.
+func (s *Scanner) peek() (byte, error) {
+	for {
+		b, err := s.r.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\n', '\r':
+			s.readByte()
+			continue
+		}
+		return b[0], nil
+	}
+}
+
+// expect consumes the next non-space byte, which must be c.
+func (s *Scanner) expect(c byte) error {
+	if _, err := s.peek(); err != nil {
+		return err
+	}
+	got, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	if got != c {
+		return s.syntaxError(fmt.Sprintf("expected %q, found %q", c, got))
+	}
+	return nil
+}
+
+// Peek returns the kind of the next value without consuming it.
+func (s *Scanner) Peek() (Kind, error) {
+	c, err := s.peek()
+	if err != nil {
+		return Invalid, err
+	}
+	switch {
+	case c == '{':
+		return Object, nil
+	case c == '[':
+		return Array, nil
+	case c == '"':
+		return String, nil
+	case c == 't' || c == 'f':
+		return Bool, nil
+	case c == 'n':
+		return Null, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return Number, nil
+	}
+	return Invalid, s.syntaxError(fmt.Sprintf("unexpected %q", c))
+}
+
+// enter opens an object or array.
+func (s *Scanner) enter(open byte) error {
+	if s.depth >= MaxDepth {
+		return ErrTooDeep
+	}
+	if err := s.expect(open); err != nil {
+		return err
+	}
+	s.depth++
+	return nil
+}
+
+// Object reads an object, calling fn with each key. fn must consume
+// the member's value. Keys longer than 256 bytes are truncated.
+func (s *Scanner) Object(fn func(key string) error) error {
+	if err := s.enter('{'); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err != nil {
+		return err
+	} else if c == '}' {
+		s.readByte()
+		s.depth--
+		return nil
+	}
+	for {
+		if c, err := s.peek(); err != nil {
+			return err
+		} else if c != '"' {
+			return s.syntaxError("expected object key")
+		}
+		key, _, err := s.String(maxKeyBytes)
+		if err != nil {
+			return err
+		}
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+		if _, err := s.peek(); err != nil {
+			return err
+		}
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ',':
+		case '}':
+			s.depth--
+			return nil
+		default:
+			return s.syntaxError(fmt.Sprintf("expected ',' or '}', found %q", c))
+		}
+	}
+}
+
+// Array reads an array, calling fn with each index. fn must consume
+// the element.
+func (s *Scanner) Array(fn func(i int) error) error {
+	if err := s.enter('['); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err != nil {
+		return err
+	} else if c == ']' {
+		s.readByte()
+		s.depth--
+		return nil
+	}
+	for i := 0; ; i++ {
+		if err := fn(i); err != nil {
+			return err
+		}
+		if _, err := s.peek(); err != nil {
+			return err
+		}
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ',':
+		case ']':
+			s.depth--
+			return nil
+		default:
+			return s.syntaxError(fmt.Sprintf("expected ',' or ']', found %q", c))
+		}
+	}
+}
+
+// String reads a string, keeping at most max bytes of its decoded
+// value (max <= 0 keeps nothing). A cut never splits a UTF-8 sequence.
+//
+// # Returns
+//   - The kept prefix
+//   - The full decoded length in bytes
+func (s *Scanner) String(max int) (string, int64, error) {
+	if err := s.expect('"'); err != nil {
+		return "", 0, err
+	}
+	var buf []byte
+	var total int64
+	emit := func(b ...byte) {
+		total += int64(len(b))
+		if room := max - len(buf); room > 0 {
+			buf = append(buf, b[:min(room, len(b))]...)
+		}
+	}
+	var enc [utf8.UTFMax]byte
+
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case c == '"':
+			if total > int64(len(buf)) {
+				buf = trimPartialRune(buf)
+			}
+			return string(buf), total, nil
+		case c < 0x20:
+			return "", 0, s.syntaxError("control character in string")
+		case c != '\\':
+			emit(c)
+			continue
+		}
+
+		e, err := s.readByte()
+		if err != nil {
+			return "", 0, err
+		}
+		switch e {
+		case '"', '\\', '/':
+			emit(e)
+		case 'b':
+			emit('\b')
+		case 'f':
+			emit('\f')
+		case 'n':
+			emit('\n')
+		case 'r':
+			emit('\r')
+		case 't':
+			emit('\t')
+		case 'u':
+			r, err := s.hex4()
+			if err != nil {
+				return "", 0, err
+			}
+			if utf16.IsSurrogate(r) {
+				r = s.lowSurrogate(r)
+			}
+			n := utf8.EncodeRune(enc[:], r)
+			emit(enc[:n]...)
+		default:
+			return "", 0, s.syntaxError(fmt.Sprintf("invalid escape %q", e))
+		}
+	}
+}
+
+// hex4 reads the four hex digits of a \u escape.
+func (s *Scanner) hex4() (rune, error) {
+	var r rune
+	for range 4 {
+		c, err := s.readByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, s.syntaxError("invalid \\u escape")
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, nil
+}
+
+// lowSurrogate completes a surrogate pair if the next escape is its low
+// half; an unpaired surrogate decodes to U+FFFD like encoding/json.
+func (s *Scanner) lowSurrogate(high rune) rune {
+	if next, err := s.r.Peek(2); err != nil || next[0] != '\\' || next[1] != 'u' {
+		return utf8.RuneError
+	}
+	ahead, err := s.r.Peek(6)
+	if err != nil {
+		return utf8.RuneError
+	}
+	var low rune
+	for _, c := range ahead[2:] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return utf8.RuneError
+		}
+		low = low<<4 | rune(c)
+	}
+	combined := utf16.DecodeRune(high, low)
+	if combined == utf8.RuneError {
+		// Leave the next escape to be decoded on its own
+		return utf8.RuneError
+	}
+	for range 6 {
+		s.readByte()
+	}
+	return combined
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of buf.
+func trimPartialRune(buf []byte) []byte {
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				return buf[:i]
+			}
+			break
+		}
+	}
+	return buf
+}
+
+// Bool reads true or false.
+func (s *Scanner) Bool() (bool, error) {
+	c, err := s.peek()
+	if err != nil {
+		return false, err
+	}
+	if c == 't' {
+		return true, s.literal("true")
+	}
+	return false, s.literal("false")
+}
+
+// literal consumes an exact keyword.
+func (s *Scanner) literal(word string) error {
+	for i := 0; i < len(word); i++ {
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		if c != word[i] {
+			return s.syntaxError("invalid literal")
+		}
+	}
+	return nil
+}
+
+// number consumes a number literal.
+func (s *Scanner) number() error {
+	var buf []byte
+	for {
+		b, err := s.r.Peek(1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(b) == 0 {
+			break
+		}
+		c := b[0]
+		if !(c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E') {
+			break
+		}
+		s.readByte()
+		if len(buf) >= maxNumberBytes {
+			return s.syntaxError("number too long")
+		}
+		buf = append(buf, c)
+	}
+	if !json.Valid(buf) {
+		return s.syntaxError("invalid number")
+	}
+	return nil
+}
+
+// Skip consumes the next value, validating it.
+func (s *Scanner) Skip() error {
+	kind, err := s.Peek()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case Object:
+		return s.Object(func(string) error { return s.Skip() })
+	case Array:
+		return s.Array(func(int) error { return s.Skip() })
+	case String:
+		_, _, err := s.String(0)
+		return err
+	case Number:
+		return s.number()
+	case Bool:
+		_, err := s.Bool()
+		return err
+	default:
+		return s.literal("null")
+	}
+}
+
+// Capture consumes the next value and returns its raw bytes, keeping
+// at most max of them. Captures do not nest.
+//
+// # Returns
+//   - The raw value (complete only if total <= max)
+//   - The value's full length in bytes
+func (s *Scanner) Capture(max int) (json.RawMessage, int64, error) {
+	if _, err := s.peek(); err != nil {
+		return nil, 0, err
+	}
+	cp := &capture{max: max}
+	s.capture = cp
+	err := s.Skip()
+	s.capture = nil
+	if err != nil {
+		return nil, 0, err
+	}
+	return cp.buf, cp.total, nil
+}
+
+// End checks that only whitespace follows the top-level value.
+func (s *Scanner) End() error {
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			s.offset++
+			continue
+		}
+		return ErrTrailing
+	}
+}
@@ -0,0 +1,176 @@
+package jsonscan
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScanner_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		max      int
+		expected string
+		total    int64
+	}{
+		{"plain", `"hello"`, 100, "hello", 5},
+		{"escapes", `"a\"b\\c\/d\n\t"`, 100, "a\"b\\c/d\n\t", 9},
+		{"unicode escape", `"caf\u00e9"`, 100, "caf\u00e9", 5},
+		{"surrogate pair", `"\ud83d\ude00"`, 100, "\U0001F600", 4},
+		{"unpaired surrogate", `"\ud83dx"`, 100, "\uFFFDx", 4},
+		{"prefix kept", `"abcdefgh"`, 3, "abc", 8},
+		{"cut never splits a rune", `"a\u00e9"`, 2, "a", 3},
+		{"nothing kept", `"abc"`, 0, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := NewScanner(strings.NewReader(tt.input)).String(tt.max)
+			if err != nil {
+				t.Fatalf("String failed: %v", err)
+			}
+			if got != tt.expected || total != tt.total {
+				t.Errorf("String = %q (%d bytes), expected %q (%d bytes)", got, total, tt.expected, tt.total)
+			}
+		})
+	}
+}
+
+func TestScanner_Skip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"nested document", `{"a":[1,-2.5e3,true,false,null,{"b":"c"}],"d":{}}`, nil},
+		{"whitespace", " { \"a\" : [ 1 , 2 ] } \n", nil},
+		{"unterminated", `{"a":[1,2`, io.ErrUnexpectedEOF},
+		{"missing colon", `{"a" 1}`, ErrSyntax},
+		{"trailing comma", `[1,]`, ErrSyntax},
+		{"bad literal", `tru`, io.ErrUnexpectedEOF},
+		{"bad number", `[1-]`, ErrSyntax},
+		{"control character", "\"a\x01\"", ErrSyntax},
+		{"bad escape", `"\x"`, ErrSyntax},
+		{"too deep", strings.Repeat("[", MaxDepth+1) + strings.Repeat("]", MaxDepth+1), ErrTooDeep},
+		{"trailing data", `{} {}`, ErrTrailing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(strings.NewReader(tt.input))
+			err := s.Skip()
+			if err == nil {
+				err = s.End()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Skip(%q) error = %v, expected %v", tt.input, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestScanner_Capture(t *testing.T) {
+	s := NewScanner(strings.NewReader(`{"id": {"x": [1, 2]}, "n": 3}`))
+	var raw json.RawMessage
+	var total int64
+	err := s.Object(func(key string) error {
+		if key == "id" {
+			var err error
+			raw, total, err = s.Capture(100)
+			return err
+		}
+		return s.Skip()
+	})
+	if err != nil {
+		t.Fatalf("Object failed: %v", err)
+	}
+	if string(raw) != `{"x": [1, 2]}` || total != int64(len(raw)) {
+		t.Errorf("Capture = %s (%d bytes)", raw, total)
+	}
+}
+
+func TestScanToolResponse(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":7,"result":{
+		"content":[
+			{"type":"text","text":"hello \u00e9","annotations":{"audience":["user"]}},
+			{"type":"resource_link","uri":"file:///tmp/a","name":"a"},
+			{"type":"resource","resource":{"uri":"file:///b","blob":"QUJD"}},
+			"not an object",
+			{"type":"text","text":"` + strings.Repeat("x", 100) + `"}
+		],
+		"structuredContent":{"temperature":21.5},
+		"isError":true,
+		"_meta":{"big":[1,2,3]}
+	}}`
+
+	got, err := ScanToolResponse(strings.NewReader(input), Limits{MaxText: 50})
+	if err != nil {
+		t.Fatalf("ScanToolResponse failed: %v", err)
+	}
+	if string(got.ID) != "7" || got.Error != nil || got.Bytes != int64(len(input)) {
+		t.Errorf("envelope: id %s, error %s, bytes %d", got.ID, got.Error, got.Bytes)
+	}
+	r := got.Result
+	if r == nil || !r.IsError || got.Items != 5 || len(r.Content) != 5 {
+		t.Fatalf("result = %+v, items %d", r, got.Items)
+	}
+	if r.Content[0].Text != "hello \u00e9" || r.Content[1].URI != "file:///tmp/a" || r.Content[2].Resource.Blob != "QUJD" {
+		t.Errorf("content = %+v", r.Content)
+	}
+	if r.Content[3].Type != "" || len(r.Content[4].Text) != 50 {
+		t.Errorf("expected an empty placeholder and a 50-byte prefix, got %+v", r.Content[3:])
+	}
+	if string(r.StructuredContent) != `{"temperature":21.5}` {
+		t.Errorf("structuredContent = %s", r.StructuredContent)
+	}
+	if len(got.TruncatedItems) != 1 || got.TruncatedItems[0] != 4 || !got.Truncated() {
+		t.Errorf("truncated items = %v", got.TruncatedItems)
+	}
+
+	errResp, err := ScanToolResponse(strings.NewReader(`{"jsonrpc":"2.0","id":"a","error":{"code":-32000,"message":"boom"}}`), Limits{})
+	if err != nil || errResp.Result != nil || !strings.Contains(string(errResp.Error), "boom") {
+		t.Errorf("error response = %+v, %v", errResp, err)
+	}
+}
+
+// newGeneratedResponse streams a tools/call response with one text
+// block of n bytes without holding it in memory.
+func newGeneratedResponse(n int) io.Reader {
+	return io.MultiReader(
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"`),
+		io.LimitReader(repeatReader('a'), int64(n)),
+		strings.NewReader(`"}],"isError":false}}`),
+	)
+}
+
+// repeatReader yields an endless run of one byte.
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestScanToolResponse_BoundedMemory(t *testing.T) {
+	const size = 32 << 20
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	got, err := ScanToolResponse(newGeneratedResponse(size), DefaultLimits())
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("ScanToolResponse failed: %v", err)
+	}
+
+	if len(got.Result.Content[0].Text) != DefaultLimits().MaxText || got.Bytes < size {
+		t.Errorf("kept %d bytes of a %d byte response", len(got.Result.Content[0].Text), got.Bytes)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("scanning a %d MiB result allocated %d bytes, expected under 1 MiB", size>>20, allocated)
+	}
+}
@@ -0,0 +1,255 @@
+package jsonscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// Limits bounds what ScanToolResponse keeps.
+type Limits struct {
+	// MaxText is the prefix kept of each string field of a content
+	// block (default 64 KiB)
+	MaxText int
+
+	// MaxItems is the number of content blocks kept; later blocks are
+	// counted and skipped (default 1024)
+	MaxItems int
+
+	// MaxStructured is the largest structuredContent kept verbatim
+	// (default 64 KiB)
+	MaxStructured int
+}
+
+// DefaultLimits returns the default scan limits.
+func DefaultLimits() Limits {
+	return Limits{MaxText: 64 * 1024, MaxItems: 1024, MaxStructured: 64 * 1024}
+}
+
+// ToolResponse is the security-relevant view of a tools/call response.
+type ToolResponse struct {
+	// ID is the response ID
+	ID json.RawMessage
+
+	// Error is the raw error object of an error response (nil otherwise;
+	// truncated to MaxText)
+	Error json.RawMessage
+
+	// Result holds the scanned fields of a successful response. String
+	// fields of content blocks are prefixes of at most MaxText bytes,
+	// Content holds at most MaxItems blocks, and StructuredContent is
+	// nil if it exceeded MaxStructured. _meta is not kept.
+	Result *mcptypes.CallToolResult
+
+	// Items is the number of content blocks in the response
+	Items int
+
+	// TruncatedItems lists the blocks with a string cut to MaxText
+	TruncatedItems []int
+
+	// StructuredBytes is the size of structuredContent (0 if absent)
+	StructuredBytes int64
+
+	// Bytes is the size of the response
+	Bytes int64
+}
+
+// Truncated reports whether any field the checks use was cut short.
+func (t *ToolResponse) Truncated() bool {
+	if len(t.TruncatedItems) > 0 {
+		return true
+	}
+	if t.Result == nil {
+		return false
+	}
+	return t.Items > len(t.Result.Content) || (t.StructuredBytes > 0 && t.Result.StructuredContent == nil)
+}
+
+// ScanToolResponse scans a JSON-RPC tools/call response from r.
+//
+// # Arguments
+//   - r: The raw response
+//   - lim: Retention limits (zero fields use the defaults)
+//
+// # Returns
+//   - The scanned response
+//   - ErrSyntax, ErrTooDeep, or a read error if the document is not
+//     valid JSON
+func ScanToolResponse(r io.Reader, lim Limits) (*ToolResponse, error) {
+	def := DefaultLimits()
+	if lim.MaxText <= 0 {
+		lim.MaxText = def.MaxText
+	}
+	if lim.MaxItems <= 0 {
+		lim.MaxItems = def.MaxItems
+	}
+	if lim.MaxStructured <= 0 {
+		lim.MaxStructured = def.MaxStructured
+	}
+
+	s := NewScanner(r)
+	out := &ToolResponse{}
+	err := s.Object(func(key string) error {
+		var err error
+		switch key {
+		case "id":
+			out.ID, _, err = s.Capture(maxKeyBytes)
+		case "error":
+			out.Error, _, err = s.Capture(lim.MaxText)
+		case "result":
+			err = out.scanResult(s, lim)
+		default:
+			err = s.Skip()
+		}
+		return err
+	})
+	if err == nil {
+		err = s.End()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonscan: tool response: %w", err)
+	}
+	out.Bytes = s.Offset()
+	return out, nil
+}
+
+// scanResult reads a CallToolResult.
+func (t *ToolResponse) scanResult(s *Scanner, lim Limits) error {
+	if kind, err := s.Peek(); err != nil || kind != Object {
+		if err != nil {
+			return err
+		}
+		return s.Skip()
+	}
+	result := &mcptypes.CallToolResult{}
+	t.Result = result
+	return s.Object(func(key string) error {
+		switch key {
+		case "content":
+			if kind, err := s.Peek(); err != nil || kind != Array {
+				if err != nil {
+					return err
+				}
+				return s.Skip()
+			}
+			return s.Array(func(i int) error {
+				t.Items++
+				if i >= lim.MaxItems {
+					return s.Skip()
+				}
+				item, truncated, err := scanContent(s, lim.MaxText)
+				if truncated {
+					t.TruncatedItems = append(t.TruncatedItems, i)
+				}
+				result.Content = append(result.Content, item)
+				return err
+			})
+		case "isError":
+			if kind, err := s.Peek(); err != nil || kind != Bool {
+				if err != nil {
+					return err
+				}
+				return s.Skip()
+			}
+			var err error
+			result.IsError, err = s.Bool()
+			return err
+		case "structuredContent":
+			raw, total, err := s.Capture(lim.MaxStructured)
+			if err != nil {
+				return err
+			}
+			t.StructuredBytes = total
+			if total <= int64(lim.MaxStructured) {
+				result.StructuredContent = raw
+			}
+			return nil
+		default:
+			return s.Skip()
+		}
+	})
+}
+
+// stringField reads a string value, skipping values of other types.
+func stringField(s *Scanner, max int, truncated *bool) (string, error) {
+	kind, err := s.Peek()
+	if err != nil {
+		return "", err
+	}
+	if kind != String {
+		return "", s.Skip()
+	}
+	v, total, err := s.String(max)
+	if total > int64(len(v)) {
+		*truncated = true
+	}
+	return v, err
+}
+
+// scanContent reads one content block. A block that is not an object
+// is skipped and kept as an empty Content so indexes stay aligned.
+func scanContent(s *Scanner, max int) (mcptypes.Content, bool, error) {
+	var c mcptypes.Content
+	truncated := false
+	if kind, err := s.Peek(); err != nil || kind != Object {
+		if err != nil {
+			return c, false, err
+		}
+		return c, false, s.Skip()
+	}
+	err := s.Object(func(key string) error {
+		var err error
+		switch key {
+		case "type":
+			c.Type, err = stringField(s, maxKeyBytes, &truncated)
+		case "text":
+			c.Text, err = stringField(s, max, &truncated)
+		case "data":
+			c.Data, err = stringField(s, max, &truncated)
+		case "mimeType":
+			c.MimeType, err = stringField(s, maxKeyBytes, &truncated)
+		case "uri":
+			c.URI, err = stringField(s, max, &truncated)
+		case "name":
+			c.Name, err = stringField(s, max, &truncated)
+		case "description":
+			c.Description, err = stringField(s, max, &truncated)
+		case "resource":
+			c.Resource, err = scanResourceContents(s, max, &truncated)
+		default:
+			err = s.Skip()
+		}
+		return err
+	})
+	return c, truncated, err
+}
+
+// scanResourceContents reads an embedded resource.
+func scanResourceContents(s *Scanner, max int, truncated *bool) (*mcptypes.ResourceContents, error) {
+	if kind, err := s.Peek(); err != nil || kind != Object {
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.Skip()
+	}
+	rc := &mcptypes.ResourceContents{}
+	err := s.Object(func(key string) error {
+		var err error
+		switch key {
+		case "uri":
+			rc.URI, err = stringField(s, max, truncated)
+		case "mimeType":
+			rc.MimeType, err = stringField(s, maxKeyBytes, truncated)
+		case "text":
+			rc.Text, err = stringField(s, max, truncated)
+		case "blob":
+			rc.Blob, err = stringField(s, max, truncated)
+		default:
+			err = s.Skip()
+		}
+		return err
+	})
+	return rc, err
+}
@@ -0,0 +1,251 @@
+// Package mask presents a sanitized view of an MCP server's identity.
+//
+// Server-controlled strings reach the model verbatim: serverInfo,
+// initialize instructions, and tool descriptions. Vendor URLs, version
+// numbers, and promotional copy in them leak implementation details to
+// clients and double as a prompt-injection channel. A Masker rewrites
+// these fields according to configuration while leaving every
+// functional field, including input and output schemas, untouched.
+//
+// # Rewritten Fields
+//
+//   - initialize: serverInfo name, version, title, websiteUrl, icons;
+//     instructions
+//   - tools/list: each tool's description
+//
+// # Thread Safety
+//
+// Masker is immutable and safe for concurrent use.
+package mask
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// ErrInvalidPattern is returned when a removal pattern does not compile.
+var ErrInvalidPattern = errors.New("mask: invalid pattern")
+
+var (
+	urlPattern     = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"')\]]+`)
+	versionPattern = regexp.MustCompile(`(?i)\bv?\d+\.\d+(?:\.\d+)*(?:[-+][0-9a-z.]+)?\b`)
+	emptyBrackets  = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
+	spaceRun       = regexp.MustCompile(`[ \t]{2,}`)
+)
+
+// Config selects what is masked.
+type Config struct {
+	// Name replaces serverInfo.name ("" keeps the server's name)
+	Name string
+
+	// Version replaces serverInfo.version ("" keeps it, unless
+	// StripVersions removes it)
+	Version string
+
+	// StripURLs removes URLs from descriptions and drops serverInfo
+	// websiteUrl and icons
+	StripURLs bool
+
+	// StripVersions removes version numbers from descriptions and
+	// serverInfo
+	StripVersions bool
+
+	// DropInstructions removes the initialize instructions field
+	DropInstructions bool
+
+	// Remove lists regular expressions whose matches are deleted from
+	// descriptions and instructions, e.g. promotional taglines
+	Remove []string
+
+	// MaxDescription truncates descriptions to this many runes
+	// (0 for no limit)
+	MaxDescription int
+}
+
+// Masker rewrites server identity fields in responses.
+type Masker struct {
+	cfg    Config
+	remove []*regexp.Regexp
+}
+
+// New creates a Masker from cfg.
+//
+// # Returns
+//   - ErrInvalidPattern if a Remove entry is not a valid regular expression
+func New(cfg Config) (*Masker, error) {
+	m := &Masker{cfg: cfg}
+	for _, p := range cfg.Remove {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidPattern, p, err)
+		}
+		m.remove = append(m.remove, re)
+	}
+	return m, nil
+}
+
+// Text masks a description: it applies URL, version, and pattern
+// removal and then the MaxDescription limit.
+func (m *Masker) Text(s string) string {
+	out := m.clean(s)
+	if n := m.cfg.MaxDescription; n > 0 {
+		if runes := []rune(out); len(runes) > n {
+			out = strings.TrimSpace(string(runes[:n])) + "…"
+		}
+	}
+	return out
+}
+
+// clean applies URL, version, and pattern removal to free text.
+func (m *Masker) clean(s string) string {
+	out := s
+	for _, re := range m.remove {
+		out = re.ReplaceAllString(out, "")
+	}
+	if m.cfg.StripURLs {
+		out = urlPattern.ReplaceAllString(out, "")
+	}
+	if m.cfg.StripVersions {
+		out = versionPattern.ReplaceAllString(out, "")
+	}
+	if out != s {
+		out = emptyBrackets.ReplaceAllString(out, "")
+		out = spaceRun.ReplaceAllString(out, " ")
+		// Drop separators left dangling by a removed suffix
+		out = strings.TrimRight(strings.TrimSpace(out), " -–—|:,")
+	}
+	return out
+}
+
+// Response masks a server response to method in place.
+//
+// # Returns
+//   - true if msg.Result was rewritten
+//   - An error if the result is not a JSON object
+func (m *Masker) Response(method string, msg *jsonrpc.Message) (bool, error) {
+	if msg.Error != nil || len(msg.Result) == 0 {
+		return false, nil
+	}
+	if method != "initialize" && method != "tools/list" {
+		return false, nil
+	}
+	var result object
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return false, fmt.Errorf("mask: decode %s result: %w", method, err)
+	}
+
+	var changed bool
+	switch method {
+	case "initialize":
+		changed = m.initializeResult(result)
+	case "tools/list":
+		changed = m.tools(result)
+	}
+	if !changed {
+		return false, nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return false, fmt.Errorf("mask: encode %s result: %w", method, err)
+	}
+	msg.Result = data
+	return true, nil
+}
+
+// object is a JSON object with raw member values, preserving fields the
+// masker does not touch.
+type object map[string]json.RawMessage
+
+// initializeResult masks serverInfo and instructions.
+func (m *Masker) initializeResult(result object) bool {
+	changed := false
+	var info object
+	if json.Unmarshal(result["serverInfo"], &info) == nil && info != nil && m.serverInfo(info) {
+		result["serverInfo"], _ = json.Marshal(info)
+		changed = true
+	}
+	if result["instructions"] != nil {
+		if m.cfg.DropInstructions {
+			delete(result, "instructions")
+			changed = true
+		} else if m.textField(result, "instructions", false) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// serverInfo rewrites the server's Implementation object.
+func (m *Masker) serverInfo(info object) bool {
+	changed := false
+	if m.cfg.Name != "" {
+		info["name"], _ = json.Marshal(m.cfg.Name)
+		changed = true
+		// A title would still reveal the original product name
+		if info["title"] != nil {
+			delete(info, "title")
+		}
+	} else if m.textField(info, "title", false) {
+		changed = true
+	}
+	switch {
+	case m.cfg.Version != "":
+		info["version"], _ = json.Marshal(m.cfg.Version)
+		changed = true
+	case m.cfg.StripVersions && info["version"] != nil:
+		// version is required by the schema; present it as empty
+		info["version"], _ = json.Marshal("")
+		changed = true
+	}
+	if m.cfg.StripURLs {
+		for _, key := range []string{"websiteUrl", "icons"} {
+			if info[key] != nil {
+				delete(info, key)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// tools masks the description of every tool in a tools/list result.
+func (m *Masker) tools(result object) bool {
+	var tools []object
+	if json.Unmarshal(result["tools"], &tools) != nil {
+		return false
+	}
+	changed := false
+	for _, t := range tools {
+		if t != nil && m.textField(t, "description", true) {
+			changed = true
+		}
+	}
+	if changed {
+		result["tools"], _ = json.Marshal(tools)
+	}
+	return changed
+}
+
+// textField masks the string member key of o. limit applies
+// MaxDescription; other fields are only cleaned.
+func (m *Masker) textField(o object, key string, limit bool) bool {
+	var s string
+	if json.Unmarshal(o[key], &s) != nil {
+		return false
+	}
+	masked := m.clean(s)
+	if limit {
+		masked = m.Text(s)
+	}
+	if masked == s {
+		return false
+	}
+	o[key], _ = json.Marshal(masked)
+	return true
+}
@@ -0,0 +1,117 @@
+package mask
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+func TestMasker_Text(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		input    string
+		expected string
+	}{
+		{
+			name:     "no masking",
+			cfg:      Config{},
+			input:    "Search docs v2.1 at https://acme.example",
+			expected: "Search docs v2.1 at https://acme.example",
+		},
+		{
+			name:     "strip urls",
+			cfg:      Config{StripURLs: true},
+			input:    "Search docs (https://acme.example/docs) fast",
+			expected: "Search docs fast",
+		},
+		{
+			name:     "strip versions",
+			cfg:      Config{StripVersions: true},
+			input:    "AcmeSearch v2.1.0-beta searches docs",
+			expected: "AcmeSearch searches docs",
+		},
+		{
+			name:     "remove promotional pattern",
+			cfg:      Config{Remove: []string{`(?i)try acme pro[^.]*\.`}},
+			input:    "Searches docs. Try Acme Pro today for faster results.",
+			expected: "Searches docs.",
+		},
+		{
+			name:     "truncate",
+			cfg:      Config{MaxDescription: 10},
+			input:    "Searches the documentation index",
+			expected: "Searches t…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if got := m.Text(tt.input); got != tt.expected {
+				t.Errorf("Text(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMasker_Response(t *testing.T) {
+	m, err := New(Config{
+		Name:             "mcp-server",
+		StripURLs:        true,
+		StripVersions:    true,
+		DropInstructions: true,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		result   string
+		expected string
+	}{
+		{
+			name:     "initialize",
+			method:   "initialize",
+			result:   `{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"instructions":"Visit acme.example","serverInfo":{"name":"acme-search","title":"Acme Search","version":"2.1.0","websiteUrl":"https://acme.example"}}`,
+			expected: `{"capabilities":{"tools":{}},"protocolVersion":"2025-06-18","serverInfo":{"name":"mcp-server","version":""}}`,
+		},
+		{
+			name:     "tool descriptions keep schemas",
+			method:   "tools/list",
+			result:   `{"tools":[{"name":"search","description":"Acme Search 2.1 - https://acme.example","inputSchema":{"type":"object","properties":{"q":{"type":"string","description":"see https://acme.example"}}}}]}`,
+			expected: `{"tools":[{"description":"Acme Search","inputSchema":{"type":"object","properties":{"q":{"type":"string","description":"see https://acme.example"}}},"name":"search"}]}`,
+		},
+		{
+			name:     "other methods untouched",
+			method:   "resources/list",
+			result:   `{"resources":[{"uri":"file:///a","name":"a","description":"v1.0 https://x.example"}]}`,
+			expected: `{"resources":[{"uri":"file:///a","name":"a","description":"v1.0 https://x.example"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &jsonrpc.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Result: json.RawMessage(tt.result)}
+			if _, err := m.Response(tt.method, msg); err != nil {
+				t.Fatalf("Response failed: %v", err)
+			}
+			if string(msg.Result) != tt.expected {
+				t.Errorf("result = %s\nexpected  %s", msg.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	if _, err := New(Config{Remove: []string{"("}}); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected ErrInvalidPattern, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package mcptypes
+
+import "encoding/json"
+
+// Elicitation response actions.
+const (
+	ElicitAccept  = "accept"
+	ElicitDecline = "decline"
+	ElicitCancel  = "cancel"
+)
+
+// ElicitParams are the params of elicitation/create, a server's request
+// that the client ask the user for input.
+type ElicitParams struct {
+	Message string `json:"message"`
+	// RequestedSchema is a flat object schema of the requested fields
+	RequestedSchema ElicitSchema `json:"requestedSchema"`
+	Meta            Meta         `json:"_meta,omitempty"`
+}
+
+// ElicitSchema is the restricted JSON Schema of elicitation/create:
+// an object of primitive-typed properties.
+type ElicitSchema struct {
+	Type       string                     `json:"type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required,omitempty"`
+}
+
+// ElicitResult is the result of elicitation/create.
+type ElicitResult struct {
+	Action  string                     `json:"action"`
+	Content map[string]json.RawMessage `json:"content,omitempty"`
+	Meta    Meta                       `json:"_meta,omitempty"`
+}
+
+// Root is a directory or file the client exposes to servers.
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+	Meta Meta   `json:"_meta,omitempty"`
+}
+
+// ListRootsResult is the result of roots/list.
+type ListRootsResult struct {
+	Roots []Root `json:"roots"`
+	Meta  Meta   `json:"_meta,omitempty"`
+}
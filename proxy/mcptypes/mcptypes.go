@@ -0,0 +1,286 @@
+// Package mcptypes provides typed Go models of MCP protocol entities.
+//
+// The structs mirror the Model Context Protocol specification so that
+// routing, policies, and scanners can address typed fields instead of
+// re-unmarshalling anonymous structs at every use site. Field names and
+// JSON tags follow the spec's camelCase wire format.
+//
+// # Decoding
+//
+// DecodeParams and DecodeResult convert jsonrpc.Message payloads:
+//
+//	params, err := mcptypes.DecodeParams[mcptypes.CallToolParams](msg)
+//	result, err := mcptypes.DecodeResult[mcptypes.CallToolResult](resp)
+//
+// DecodeParamsStrict and DecodeResultStrict do the same but reject
+// fields the structs do not model, for policies that must not act on a
+// message they only partly understand.
+//
+// # Compatibility
+//
+// Unknown fields are ignored by the lenient decoders. Code that rewrites
+// messages and must preserve fields this package does not model should
+// operate on the raw JSON instead. _meta, experimental capabilities,
+// and schemas are free-form, so the strict decoders accept anything
+// there.
+package mcptypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// Decoding errors.
+var (
+	ErrNoParams     = errors.New("mcptypes: message has no params")
+	ErrNoResult     = errors.New("mcptypes: message has no result")
+	ErrUnknownField = errors.New("mcptypes: unknown field")
+)
+
+// Content block types.
+const (
+	ContentText         = "text"
+	ContentImage        = "image"
+	ContentAudio        = "audio"
+	ContentResource     = "resource"
+	ContentResourceLink = "resource_link"
+)
+
+// Meta is the reserved _meta object carried by params and results.
+type Meta map[string]interface{}
+
+// Implementation identifies a client or server (clientInfo / serverInfo).
+type Implementation struct {
+	Name    string `json:"name"`
+	Title   string `json:"title,omitempty"`
+	Version string `json:"version"`
+}
+
+// Annotations are hints attached to content and resources.
+type Annotations struct {
+	Audience     []string `json:"audience,omitempty"`
+	Priority     *float64 `json:"priority,omitempty"`
+	LastModified string   `json:"lastModified,omitempty"`
+}
+
+// Content is a content block in tool results, prompt messages, and
+// sampling messages. Type selects which fields are meaningful:
+//
+//   - "text": Text
+//   - "image", "audio": Data (base64) and MimeType
+//   - "resource": Resource
+//   - "resource_link": URI, Name, Title, Description, MimeType, Size
+type Content struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text,omitempty"`
+	Data        string            `json:"data,omitempty"`
+	MimeType    string            `json:"mimeType,omitempty"`
+	Resource    *ResourceContents `json:"resource,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Size        *int64            `json:"size,omitempty"`
+	Annotations *Annotations      `json:"annotations,omitempty"`
+	Meta        Meta              `json:"_meta,omitempty"`
+}
+
+// ToolAnnotations are untrusted hints a server gives about a tool.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// Tool describes a tool offered by a server.
+type Tool struct {
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description,omitempty"`
+	InputSchema  json.RawMessage  `json:"inputSchema"`
+	OutputSchema json.RawMessage  `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+	Meta         Meta             `json:"_meta,omitempty"`
+}
+
+// Resource describes a resource offered by a server.
+type Resource struct {
+	URI         string       `json:"uri"`
+	Name        string       `json:"name"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Size        *int64       `json:"size,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+// ResourceTemplate describes a parameterized family of resources.
+type ResourceTemplate struct {
+	URITemplate string       `json:"uriTemplate"`
+	Name        string       `json:"name"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+// ResourceContents is the body of a resource: Text or base64 Blob.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+	Meta     Meta   `json:"_meta,omitempty"`
+}
+
+// PromptArgument describes an argument a prompt accepts.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt describes a prompt template offered by a server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+	Meta        Meta             `json:"_meta,omitempty"`
+}
+
+// PromptMessage is one message of a rendered prompt.
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// ServerCapabilities are the features a server declares in initialize.
+type ServerCapabilities struct {
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+	Logging      *struct{}                  `json:"logging,omitempty"`
+	Completions  *struct{}                  `json:"completions,omitempty"`
+	Prompts      *ListChangedCapability     `json:"prompts,omitempty"`
+	Resources    *ResourcesCapability       `json:"resources,omitempty"`
+	Tools        *ListChangedCapability     `json:"tools,omitempty"`
+}
+
+// ClientCapabilities are the features a client declares in initialize.
+type ClientCapabilities struct {
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+	Roots        *ListChangedCapability     `json:"roots,omitempty"`
+	Sampling     *struct{}                  `json:"sampling,omitempty"`
+	Elicitation  *struct{}                  `json:"elicitation,omitempty"`
+}
+
+// ListChangedCapability declares list_changed notification support.
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability declares resource subscription support.
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// DecodeParams decodes a request or notification's params into T.
+//
+// # Arguments
+//   - msg: Parsed JSON-RPC message
+//
+// # Returns
+//   - Decoded params
+//   - ErrNoParams if the message has none, or a decode error
+func DecodeParams[T any](msg *jsonrpc.Message) (*T, error) {
+	if len(msg.Params) == 0 {
+		return nil, ErrNoParams
+	}
+	var v T
+	if err := json.Unmarshal(msg.Params, &v); err != nil {
+		return nil, fmt.Errorf("mcptypes: decode %s params: %w", msg.Method, err)
+	}
+	return &v, nil
+}
+
+// DecodeResult decodes a successful response's result into T.
+//
+// # Arguments
+//   - msg: Parsed JSON-RPC response
+//
+// # Returns
+//   - Decoded result
+//   - ErrNoResult if the message is an error or has no result
+func DecodeResult[T any](msg *jsonrpc.Message) (*T, error) {
+	if msg.Error != nil || len(msg.Result) == 0 {
+		return nil, ErrNoResult
+	}
+	var v T
+	if err := json.Unmarshal(msg.Result, &v); err != nil {
+		return nil, fmt.Errorf("mcptypes: decode result: %w", err)
+	}
+	return &v, nil
+}
+
+// DecodeParamsStrict is DecodeParams rejecting fields T does not model.
+//
+// # Returns
+//   - Decoded params
+//   - ErrNoParams if the message has none, ErrUnknownField naming the
+//     first unmodeled field, or another decode error
+func DecodeParamsStrict[T any](msg *jsonrpc.Message) (*T, error) {
+	if len(msg.Params) == 0 {
+		return nil, ErrNoParams
+	}
+	var v T
+	if err := decodeStrict(msg.Params, &v); err != nil {
+		return nil, fmt.Errorf("mcptypes: decode %s params: %w", msg.Method, err)
+	}
+	return &v, nil
+}
+
+// DecodeResultStrict is DecodeResult rejecting fields T does not model.
+//
+// # Returns
+//   - Decoded result
+//   - ErrNoResult if the message is an error or has no result,
+//     ErrUnknownField naming the first unmodeled field, or another
+//     decode error
+func DecodeResultStrict[T any](msg *jsonrpc.Message) (*T, error) {
+	if msg.Error != nil || len(msg.Result) == 0 {
+		return nil, ErrNoResult
+	}
+	var v T
+	if err := decodeStrict(msg.Result, &v); err != nil {
+		return nil, fmt.Errorf("mcptypes: decode result: %w", err)
+	}
+	return &v, nil
+}
+
+// decodeStrict unmarshals data into v, failing on unknown fields and
+// trailing data.
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		// encoding/json reports unknown fields only by message
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("%w %s", ErrUnknownField, field)
+		}
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after value")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mcptypes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+func TestDecodeParams_CallTool(t *testing.T) {
+	msg, err := jsonrpc.Parse([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"/tmp"}}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	params, err := DecodeParams[CallToolParams](msg)
+	if err != nil {
+		t.Fatalf("DecodeParams failed: %v", err)
+	}
+	if params.Name != "read_file" || string(params.Arguments) != `{"path":"/tmp"}` {
+		t.Errorf("unexpected params: %+v", params)
+	}
+}
+
+func TestDecodeResult(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"tool result", `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"hi"},{"type":"resource","resource":{"uri":"file:///a","blob":"AA=="}}]}}`, nil},
+		{"error response", `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"no"}}`, ErrNoResult},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, _ := jsonrpc.Parse([]byte(tt.data))
+			result, err := DecodeResult[CallToolResult](msg)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DecodeResult error = %v, expected %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if len(result.Content) != 2 || result.Content[0].Text != "hi" || result.Content[1].Resource.Blob != "AA==" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestDecodeResult_Initialize(t *testing.T) {
+	msg, _ := jsonrpc.Parse([]byte(`{"jsonrpc":"2.0","id":0,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{"listChanged":true},"resources":{"subscribe":true}},"serverInfo":{"name":"fs","version":"1.2"}}}`))
+	result, err := DecodeResult[InitializeResult](msg)
+	if err != nil {
+		t.Fatalf("DecodeResult failed: %v", err)
+	}
+	caps := result.Capabilities
+	if caps.Tools == nil || !caps.Tools.ListChanged || caps.Resources == nil || !caps.Resources.Subscribe || caps.Prompts != nil {
+		t.Errorf("unexpected capabilities: %+v", caps)
+	}
+	if result.ServerInfo.Name != "fs" {
+		t.Errorf("unexpected server info: %+v", result.ServerInfo)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"modeled fields", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","arguments":{"any":"thing"},"_meta":{"progressToken":1}}}`, nil},
+		{"unknown field", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","sudo":true}}`, ErrUnknownField},
+		{"no params", `{"jsonrpc":"2.0","id":1,"method":"tools/call"}`, ErrNoParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, _ := jsonrpc.Parse([]byte(tt.data))
+			_, err := DecodeParamsStrict[CallToolParams](msg)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("DecodeParamsStrict error = %v, expected %v", err, tt.err)
+			}
+			if tt.err == nil {
+				if _, err := DecodeParams[CallToolParams](msg); err != nil {
+					t.Errorf("DecodeParams failed: %v", err)
+				}
+			}
+		})
+	}
+
+	// Unknown fields are found in nested structs too
+	msg, _ := jsonrpc.Parse([]byte(`{"jsonrpc":"2.0","id":2,"result":{"role":"assistant","content":{"type":"text","text":"hi","extra":1},"model":"m"}}`))
+	if _, err := DecodeResultStrict[CreateMessageResult](msg); !errors.Is(err, ErrUnknownField) || !strings.Contains(err.Error(), `"extra"`) {
+		t.Errorf("DecodeResultStrict error = %v, expected ErrUnknownField naming extra", err)
+	}
+	if result, err := DecodeResult[CreateMessageResult](msg); err != nil || result.Content.Text != "hi" || result.Model != "m" {
+		t.Errorf("DecodeResult = %+v, %v", result, err)
+	}
+}
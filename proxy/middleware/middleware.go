@@ -1,8 +1,55 @@
 // Package middleware provides request/response interception
 package middleware
 
-// Middleware defines a function that processes MCP messages
-type Middleware func(msg []byte, next func([]byte) ([]byte, error)) ([]byte, error)
+import (
+	"bytes"
+	"context"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// Direction is the way a message travels through the proxy.
+type Direction string
+
+const (
+	// ClientToServer is a client message on its way to the server; the
+	// response a middleware gets back from next travels the other way
+	ClientToServer Direction = "client_to_server"
+	// ServerToClient is a server request or notification on its way to
+	// the client
+	ServerToClient Direction = "server_to_client"
+)
+
+// MessageContext describes the message a middleware handles, so it can
+// decide without re-parsing and stop when the message is cancelled.
+type MessageContext struct {
+	// Context is cancelled when the message is: the client cancels it,
+	// it times out, or the session ends
+	Context context.Context
+
+	// SessionID identifies the proxy session
+	SessionID string
+
+	// Direction is the way the message travels
+	Direction Direction
+
+	// Message is the parsed message (nil if it does not parse); the
+	// chain re-parses it when a middleware passes a rewritten message
+	// on
+	Message *jsonrpc.Message
+
+	// Transport names the transport the message arrived on, e.g.
+	// "stdio" (see transport.Name)
+	Transport string
+}
+
+// Handler processes a message and returns the reply, if any.
+type Handler func(mc *MessageContext, msg []byte) ([]byte, error)
+
+// Middleware defines a function that processes MCP messages. It either
+// answers msg itself or passes it, possibly rewritten and with a
+// derived context, to next.
+type Middleware func(mc *MessageContext, msg []byte, next Handler) ([]byte, error)
 
 // Chain combines multiple middlewares into a single chain
 type Chain struct {
@@ -14,10 +61,19 @@ func New(middlewares ...Middleware) *Chain {
 	return &Chain{middlewares: middlewares}
 }
 
-// Execute runs the middleware chain
-func (c *Chain) Execute(msg []byte, final func([]byte) ([]byte, error)) ([]byte, error) {
-	if len(c.middlewares) == 0 {
-		return final(msg)
+// Execute runs the middleware chain. A middleware is not called once
+// mc.Context is done; the chain returns the context's error instead.
+func (c *Chain) Execute(mc *MessageContext, msg []byte, final Handler) ([]byte, error) {
+	if mc == nil {
+		mc = &MessageContext{}
+	}
+	if mc.Context == nil {
+		ctx := *mc
+		ctx.Context = context.Background()
+		mc = &ctx
+	}
+	if mc.Message == nil {
+		mc = reparse(mc, msg)
 	}
 
 	// Build the chain from end to start
@@ -25,10 +81,32 @@ func (c *Chain) Execute(msg []byte, final func([]byte) ([]byte, error)) ([]byte,
 	for i := len(c.middlewares) - 1; i >= 0; i-- {
 		mw := c.middlewares[i]
 		next := handler
-		handler = func(m []byte) ([]byte, error) {
-			return mw(m, next)
+		handler = func(mc *MessageContext, m []byte) ([]byte, error) {
+			if err := mc.Context.Err(); err != nil {
+				return nil, err
+			}
+			return mw(mc, m, func(out *MessageContext, rewritten []byte) ([]byte, error) {
+				if out == nil {
+					out = mc
+				} else if out.Context == nil {
+					ctx := *out
+					ctx.Context = mc.Context
+					out = &ctx
+				}
+				if !bytes.Equal(rewritten, m) {
+					out = reparse(out, rewritten)
+				}
+				return next(out, rewritten)
+			})
 		}
 	}
 
-	return handler(msg)
+	return handler(mc, msg)
+}
+
+// reparse returns a copy of mc describing msg.
+func reparse(mc *MessageContext, msg []byte) *MessageContext {
+	out := *mc
+	out.Message, _ = jsonrpc.Parse(msg)
+	return &out
 }
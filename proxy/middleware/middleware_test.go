@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type contextKey struct{}
+
+func TestChain_PassesMessageContext(t *testing.T) {
+	first := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		out := *mc
+		out.Context = context.WithValue(mc.Context, contextKey{}, "from first")
+		return next(&out, msg)
+	}
+	var seen *MessageContext
+	second := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		seen = mc
+		return next(mc, msg)
+	}
+
+	mc := &MessageContext{Context: context.Background(), SessionID: "s1", Direction: ClientToServer, Transport: "stdio"}
+	reply, err := New(first, second).Execute(mc, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`),
+		func(mc *MessageContext, msg []byte) ([]byte, error) { return []byte("done"), nil })
+	if err != nil || string(reply) != "done" {
+		t.Fatalf("Execute = %s, %v", reply, err)
+	}
+	if seen == nil || seen.SessionID != "s1" || seen.Direction != ClientToServer || seen.Transport != "stdio" {
+		t.Fatalf("second middleware saw %+v", seen)
+	}
+	if v, _ := seen.Context.Value(contextKey{}).(string); v != "from first" {
+		t.Errorf("context value = %q, expected the first middleware's", v)
+	}
+	if seen.Message == nil || seen.Message.Method != "ping" {
+		t.Errorf("message = %+v, expected the parsed ping", seen.Message)
+	}
+}
+
+func TestChain_NilContextInherited(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey{}, "outer")
+	first := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		return next(&MessageContext{SessionID: "s2"}, msg)
+	}
+	var seen *MessageContext
+	second := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		seen = mc
+		return nil, nil
+	}
+	New(first, second).Execute(&MessageContext{Context: ctx}, []byte(`{}`), nil)
+	if seen == nil || seen.SessionID != "s2" || seen.Context.Value(contextKey{}) != "outer" {
+		t.Errorf("second middleware saw %+v", seen)
+	}
+}
+
+func TestChain_CancelledContextStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	called := []string{}
+	first := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		called = append(called, "first")
+		cancel()
+		return next(mc, msg)
+	}
+	second := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		called = append(called, "second")
+		return next(mc, msg)
+	}
+	final := func(mc *MessageContext, msg []byte) ([]byte, error) {
+		called = append(called, "final")
+		return nil, nil
+	}
+
+	_, err := New(first, second).Execute(&MessageContext{Context: ctx}, []byte(`{}`), final)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, expected context.Canceled", err)
+	}
+	if len(called) != 1 {
+		t.Errorf("called %v, expected the chain to stop after the first middleware", called)
+	}
+
+	called = nil
+	if _, err := New(first).Execute(&MessageContext{Context: ctx}, []byte(`{}`), final); !errors.Is(err, context.Canceled) || len(called) != 0 {
+		t.Errorf("already cancelled: called %v, err %v; expected no middleware to run", called, err)
+	}
+}
+
+func TestChain_RewriteReparsed(t *testing.T) {
+	rewrite := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		return next(mc, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	}
+	var before, after *MessageContext
+	observe := func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		after = mc
+		return next(mc, msg)
+	}
+
+	mc := &MessageContext{Context: context.Background()}
+	New(func(mc *MessageContext, msg []byte, next Handler) ([]byte, error) {
+		before = mc
+		return next(mc, msg)
+	}, rewrite, observe).Execute(mc, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`),
+		func(mc *MessageContext, msg []byte) ([]byte, error) { return nil, nil })
+
+	// Reparsing copies the context, leaving the first middleware's alone
+	if before == nil || before.Message == nil || before.Message.Method != "ping" {
+		t.Fatalf("first middleware saw %+v", before)
+	}
+	if after == nil || after.Message == nil || after.Message.Method != "tools/list" {
+		t.Errorf("middleware after the rewrite saw %+v, expected the rewritten message parsed", after)
+	}
+}
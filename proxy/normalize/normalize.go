@@ -0,0 +1,184 @@
+// Package normalize neutralizes text that reads one way to a person or
+// a pattern and another way to the machine: invisible Unicode and
+// homoglyphs.
+//
+// Invisible characters (zero-width spaces, bidi overrides, Unicode tag
+// characters) split a word so a pattern no longer matches it, or hide
+// text from the person reviewing it. Homoglyphs, such as Cyrillic "о"
+// for Latin "o" or fullwidth letters, make a name or phrase look like
+// one the rules know while comparing unequal to it.
+//
+// # Skeletons
+//
+// Skeleton removes invisible characters and replaces each homoglyph
+// with the ASCII character it imitates, in the spirit of the UTS #39
+// skeleton but with a fixed table covering the Latin look-alikes of
+// Cyrillic, Greek, and Armenian, fullwidth forms, mathematical
+// alphanumerics, Unicode spaces, and dashes, slashes, and quotes. Two
+// strings with the same skeleton are meant to look alike; the skeleton
+// is for comparing and matching, not for display, since it also folds
+// legitimate non-Latin text.
+//
+// # Thread Safety
+//
+// Every function here is safe for concurrent use.
+package normalize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Changes counts what normalizing a text removed or replaced.
+type Changes struct {
+	// Invisible is the number of invisible characters removed
+	Invisible int `json:"invisible,omitempty"`
+
+	// Homoglyphs is the number of characters replaced by the ASCII
+	// characters they imitate
+	Homoglyphs int `json:"homoglyphs,omitempty"`
+}
+
+// Any reports whether anything changed.
+func (c Changes) Any() bool {
+	return c.Invisible > 0 || c.Homoglyphs > 0
+}
+
+// Add returns the sum of c and o.
+func (c Changes) Add(o Changes) Changes {
+	return Changes{Invisible: c.Invisible + o.Invisible, Homoglyphs: c.Homoglyphs + o.Homoglyphs}
+}
+
+// IsInvisible reports whether c renders as nothing: a format character
+// (zero-width and bidi controls), a Unicode tag character, or a
+// supplementary variation selector. Soft hyphens and zero-width joiners
+// are common in legitimate text and do not count.
+func IsInvisible(c rune) bool {
+	switch {
+	case c >= 0xE0000 && c <= 0xE007F:
+		return true
+	case c >= 0xE0100 && c <= 0xE01EF:
+		return true
+	case c == '\u00ad', c == '\u200d':
+		return false
+	}
+	return unicode.Is(unicode.Cf, c)
+}
+
+// Fold returns the ASCII character c imitates, or c itself.
+func Fold(c rune) rune {
+	switch {
+	case c < utf8.RuneSelf:
+		return c
+	case c >= 0xFF01 && c <= 0xFF5E:
+		// Fullwidth ASCII
+		return c - 0xFEE0
+	case c >= 0x1D400 && c <= 0x1D6A3:
+		// Mathematical alphanumeric letters: 13 styles of A-Z a-z
+		i := (c - 0x1D400) % 52
+		if i < 26 {
+			return 'A' + i
+		}
+		return 'a' + i - 26
+	case c >= 0x1D7CE && c <= 0x1D7FF:
+		// Mathematical digits: 5 styles of 0-9
+		return '0' + (c-0x1D7CE)%10
+	case unicode.Is(unicode.Zs, c):
+		return ' '
+	}
+	if f, ok := confusables[c]; ok {
+		return f
+	}
+	return c
+}
+
+// confusables maps single characters to the ASCII characters they
+// imitate, beyond the ranges Fold computes.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+	'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'һ': 'h', 'ӏ': 'l', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M',
+	'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'Ѕ': 'S',
+	'І': 'I', 'Ј': 'J', 'Ү': 'Y', 'Ԛ': 'Q', 'Ԝ': 'W',
+	// Greek
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'α': 'a', 'ι': 'i', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'υ': 'u',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'ց': 'g',
+	// Latin
+	'ı': 'i', 'ȷ': 'j', 'ɑ': 'a', 'ɡ': 'g', 'ℓ': 'l', 'ⅰ': 'i', 'ⅼ': 'l',
+	// Punctuation
+	'‐': '-', '‑': '-', '‒': '-', '–': '-', '—': '-', '―': '-', '−': '-',
+	'⁄': '/', '∕': '/', '∶': ':', '․': '.', '‘': '\'', '’': '\'',
+	'ʻ': '\'', 'ʼ': '\'', '“': '"', '”': '"', '﹍': '_', '﹎': '_', '﹏': '_',
+}
+
+// StripInvisible returns s without invisible characters.
+func StripInvisible(s string) (string, Changes) {
+	var c Changes
+	out := strings.Map(func(r rune) rune {
+		if IsInvisible(r) {
+			c.Invisible++
+			return -1
+		}
+		return r
+	}, s)
+	return out, c
+}
+
+// Skeleton returns s without invisible characters and with homoglyphs
+// replaced by the ASCII characters they imitate.
+func Skeleton(s string) (string, Changes) {
+	var c Changes
+	out := strings.Map(func(r rune) rune {
+		if IsInvisible(r) {
+			c.Invisible++
+			return -1
+		}
+		if f := Fold(r); f != r {
+			c.Homoglyphs++
+			return f
+		}
+		return r
+	}, s)
+	return out, c
+}
+
+// Mapped is the skeleton of a text with the position in the text of
+// each of its bytes, so matches in the skeleton can be traced back.
+type Mapped struct {
+	// Text is the skeleton
+	Text string
+
+	// start and end are the byte range in the original text of the
+	// character each skeleton byte comes from
+	start, end []int
+}
+
+// Map returns the skeleton of s with its positions in s.
+func Map(s string) *Mapped {
+	var b strings.Builder
+	m := &Mapped{}
+	for i, r := range s {
+		if IsInvisible(r) {
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		n, _ := b.WriteRune(Fold(r))
+		for range n {
+			m.start = append(m.start, i)
+			m.end = append(m.end, i+size)
+		}
+	}
+	m.Text = b.String()
+	return m
+}
+
+// Span returns the byte range in the original text of the skeleton
+// bytes [i, j), which must be a non-empty range of Text.
+func (m *Mapped) Span(i, j int) (int, int) {
+	return m.start[i], m.end[j-1]
+}
@@ -0,0 +1,74 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		changes  Changes
+	}{
+		{"ascii", "execute_command", "execute_command", Changes{}},
+		{"cyrillic o", "execute_cоmmand", "execute_command", Changes{Homoglyphs: 1}},
+		{"greek capitals", "ΑΒΕ", "ABE", Changes{Homoglyphs: 3}},
+		{"fullwidth", "ｒｅａｄ_ｆｉｌｅ", "read_file", Changes{Homoglyphs: 8}},
+		{"math bold", "\U0001d42c\U0001d421\U0001d41e\U0001d425\U0001d425", "shell", Changes{Homoglyphs: 5}},
+		{"math digits", "\U0001d7ce\U0001d7d7\U0001d7f0", "094", Changes{Homoglyphs: 3}},
+		{"zero width", "del\u200bete", "delete", Changes{Invisible: 1}},
+		{"bidi override", "\u202eelif_daer", "elif_daer", Changes{Invisible: 1}},
+		{"tag characters", "ok\U000e0041\U000e0042", "ok", Changes{Invisible: 2}},
+		{"spaces and dashes", "a\u00a0b\u2014c", "a b-c", Changes{Homoglyphs: 2}},
+		{"joiner kept", "\U0001f468\u200d\U0001f469", "\U0001f468\u200d\U0001f469", Changes{}},
+		{"unmapped script kept", "日本", "日本", Changes{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes := Skeleton(tt.text)
+			if got != tt.expected || changes != tt.changes {
+				t.Errorf("Skeleton(%q) = %q, %+v; expected %q, %+v", tt.text, got, changes, tt.expected, tt.changes)
+			}
+			if changes.Any() != (got != tt.text) {
+				t.Errorf("Any = %v for a changed text %v", changes.Any(), got != tt.text)
+			}
+		})
+	}
+}
+
+func TestStripInvisible(t *testing.T) {
+	got, changes := StripInvisible("cоp\u200by\u2066")
+	if got != "cоpy" || changes != (Changes{Invisible: 2}) {
+		t.Errorf("StripInvisible = %q, %+v", got, changes)
+	}
+	if sum := changes.Add(Changes{Invisible: 1, Homoglyphs: 2}); sum != (Changes{Invisible: 3, Homoglyphs: 2}) {
+		t.Errorf("Add = %+v", sum)
+	}
+}
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		text  string
+		match string
+		span  string
+	}{
+		{"say ign\u200bore this", "ignore", "ign\u200bore"},
+		{"x іgnоrе y", "ignore", "іgnоrе"},
+		{"ｉｇ then", "ig", "ｉｇ"},
+		{"bad \xff byte ignore", "ignore", "ignore"},
+	}
+	for _, tt := range tests {
+		m := Map(tt.text)
+		i := strings.Index(m.Text, tt.match)
+		if i < 0 {
+			t.Errorf("Map(%q).Text = %q, expected it to contain %q", tt.text, m.Text, tt.match)
+			continue
+		}
+		start, end := m.Span(i, i+len(tt.match))
+		if got := tt.text[start:end]; got != tt.span {
+			t.Errorf("Map(%q) span = %q, expected %q", tt.text, got, tt.span)
+		}
+	}
+}
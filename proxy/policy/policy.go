@@ -0,0 +1,788 @@
+// Package policy decides what the proxy does with each MCP request
+// from an ordered list of operator rules.
+//
+// A rule matches on the JSON-RPC method, the tool name, the upstream
+// server providing the tool, and regular expressions over tool
+// arguments. Its action allows or blocks the request, requires a
+// council vote before a tool call is forwarded, rate-limits matching
+// requests, or downgrades a tool call into a safer equivalent. A rule
+// may also price matching tool calls in gas.
+//
+// # Evaluation
+//
+// Rules are evaluated in order. The first matching rule with an allow,
+// block, or require-council action decides; later rules are not
+// consulted. A rate-limit rule blocks a request once its budget is
+// spent and otherwise lets evaluation continue, so limits can be
+// layered over the deciding rules. A request no rule decides gets the
+// set's DefaultAction. Gas is priced by the first matching rule with a
+// Gas value, whatever its action.
+//
+// A downgrade rule rewrites the tool call and evaluation continues
+// with the rewritten call, so the rules for the safer tool still
+// apply; the verdict lists every transform made. A rewritten call's
+// server is unknown, so later rules naming servers do not match it.
+//
+// Allow only ends evaluation: an allowed tool call still runs every
+// sentinel check.
+//
+// # Example
+//
+//	rules:
+//	  - name: no-shell
+//	    tools: ["*shell*", sudo]
+//	    action: block
+//	  - name: secrets
+//	    tools: [read_file]
+//	    arguments: {path: "^/etc/|\\.env$"}
+//	    action: require-council
+//	  - name: web-burst
+//	    servers: [web]
+//	    action: rate-limit
+//	    rate: 2
+//	    burst: 10
+//	  - name: writes
+//	    tools: [write_file]
+//	    gas: 500
+//	  - name: cat-is-read
+//	    tools: [execute_command]
+//	    arguments: {command: '^cat\s+(?P<file>[^\s;|&<>]+)$'}
+//	    action: downgrade
+//	    downgrade: {tool: read_file, arguments: {path: "${file}"}}
+//	  - name: stage-writes
+//	    tools: [write_file]
+//	    action: downgrade
+//	    downgrade:
+//	      stage_argument: path
+//	      workspace: /srv/work
+//	      staging_dir: /srv/staging
+//
+// # Thread Safety
+//
+// Engine is safe for concurrent use. Replace swaps the rule set
+// atomically; a request is evaluated entirely under one set.
+package policy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+)
+
+// ErrInvalidRule is returned for a rule set that cannot be compiled.
+var ErrInvalidRule = errors.New("policy: invalid rule")
+
+// DefaultGas is the gas price of tool calls no rule prices when a set
+// leaves DefaultGas zero.
+const DefaultGas = 200
+
+// maxBuckets bounds the rate limiter state kept across sessions.
+const maxBuckets = 10000
+
+// Action is what a rule does with a matching request.
+type Action string
+
+const (
+	// ActionAllow lets the request through to the sentinel checks
+	ActionAllow Action = "allow"
+	// ActionBlock refuses the request
+	ActionBlock Action = "block"
+	// ActionCouncil allows a tool call only after a council vote
+	ActionCouncil Action = "require-council"
+	// ActionRateLimit blocks matching requests beyond Rate per second
+	ActionRateLimit Action = "rate-limit"
+	// ActionDowngrade rewrites a tool call as the rule's Downgrade says
+	ActionDowngrade Action = "downgrade"
+)
+
+// templateRef matches a ${name} reference in a downgrade argument
+// template.
+var templateRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Rule matches requests and acts on them. Empty match fields match
+// anything; a rule with no match fields matches every request.
+type Rule struct {
+	// Name identifies the rule in verdicts and audit records
+	Name string `json:"name"`
+
+	// Methods are JSON-RPC method patterns in path.Match syntax, such
+	// as "tools/call" or "resources/*"
+	Methods []string `json:"methods,omitempty"`
+
+	// Tools are tool name patterns in path.Match syntax, matched
+	// against both the name the client called and, behind a
+	// multiplexing transport, the server's own name for the tool;
+	// only tools/call requests have a tool name
+	Tools []string `json:"tools,omitempty"`
+
+	// Servers are upstream server names; requests whose server is not
+	// known do not match
+	Servers []string `json:"servers,omitempty"`
+
+	// Arguments maps tool argument names to regular expressions that
+	// must all match; "*" matches if any argument does. Strings are
+	// matched as they are, other values as compact JSON
+	Arguments map[string]string `json:"arguments,omitempty"`
+
+	// Action is done with matching requests (empty only prices gas)
+	Action Action `json:"action,omitempty"`
+
+	// Reason explains a block to the client (empty uses a generic
+	// reason naming the rule)
+	Reason string `json:"reason,omitempty"`
+
+	// Rate and Burst bound a rate-limit rule: each session may make
+	// Burst matching requests at once, refilled at Rate per second
+	// (zero Burst allows max(1, Rate))
+	Rate  float64 `json:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+
+	// Gas prices matching tool calls (zero leaves pricing to later
+	// rules)
+	Gas uint64 `json:"gas,omitempty"`
+
+	// Downgrade says how a downgrade rule rewrites matching tool calls
+	Downgrade Downgrade `json:"downgrade,omitzero"`
+}
+
+// Downgrade rewrites a tool call into a safer equivalent, such as a
+// shell command that only reads a file into read_file, or a write
+// outside the workspace into a write to a staging directory.
+type Downgrade struct {
+	// Tool replaces the called tool (empty keeps it)
+	Tool string `json:"tool,omitempty"`
+
+	// Arguments replaces the call's arguments (empty keeps them). Each
+	// value is a template in which ${name} expands to the named capture
+	// group of the rule's argument expressions or, failing that, to the
+	// call's argument of that name; a value that is just ${argument}
+	// copies the argument whatever its type. A call with a reference
+	// that resolves to nothing is not downgraded
+	Arguments map[string]string `json:"arguments,omitempty"`
+
+	// StageArgument names a file path argument to redirect into
+	// StagingDir when it falls outside Workspace, both absolute paths;
+	// calls with the path inside Workspace are not downgraded. The write
+	// lands at StagingDir/ID/original-path, where ID names the transform
+	StageArgument string `json:"stage_argument,omitempty"`
+	Workspace     string `json:"workspace,omitempty"`
+	StagingDir    string `json:"staging_dir,omitempty"`
+}
+
+// Transform records a downgrade made to a tool call.
+type Transform struct {
+	// Rule names the downgrade rule
+	Rule string `json:"rule"`
+
+	// FromTool and ToTool are the tool before and after the downgrade
+	FromTool string `json:"from_tool"`
+	ToTool   string `json:"to_tool"`
+
+	// Arguments are the arguments after the downgrade
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// ID identifies a staged write, which moved the path Original to
+	// Staged in StagingDir (all empty unless the rule stages)
+	ID         string `json:"id,omitempty"`
+	Original   string `json:"original,omitempty"`
+	Staged     string `json:"staged,omitempty"`
+	StagingDir string `json:"staging_dir,omitempty"`
+}
+
+// Set is an ordered rule list and its defaults.
+type Set struct {
+	// Rules are evaluated in order
+	Rules []Rule `json:"rules"`
+
+	// DefaultAction applies when no rule decides: allow or block
+	// (empty allows)
+	DefaultAction Action `json:"default_action,omitempty"`
+
+	// DefaultGas prices tool calls no rule prices (zero uses
+	// DefaultGas)
+	DefaultGas uint64 `json:"default_gas,omitempty"`
+}
+
+// Builtin returns the rules the proxy applies without a configured
+// policy: council votes for tools that execute code or change files,
+// and the built-in gas prices.
+func Builtin() *Set {
+	return &Set{Rules: []Rule{
+		{Name: "builtin-high-risk", Tools: []string{"execute_command", "write_file", "delete_file", "run_script", "sudo", "shell"}, Action: ActionCouncil},
+		{Name: "builtin-gas-execute", Tools: []string{"execute_command"}, Gas: 1000},
+		{Name: "builtin-gas-write", Tools: []string{"write_file"}, Gas: 500},
+		{Name: "builtin-gas-read", Tools: []string{"read_file"}, Gas: 100},
+		{Name: "builtin-gas-list", Tools: []string{"list_directory"}, Gas: 50},
+	}}
+}
+
+// Request is what a rule is matched against.
+type Request struct {
+	// Session keys rate limits
+	Session string
+
+	// Method is the JSON-RPC method
+	Method string
+
+	// Tool is the tool name the client called (tools/call only)
+	Tool string
+
+	// Server names the upstream providing Tool and ServerTool is that
+	// server's own name for it (both empty if unknown)
+	Server     string
+	ServerTool string
+
+	// Arguments are the tools/call arguments
+	Arguments json.RawMessage
+}
+
+// Verdict is the outcome of evaluating a request.
+type Verdict struct {
+	// Action is allow, block, or require-council
+	Action Action `json:"action"`
+
+	// Rule names the deciding rule (empty for the default action)
+	Rule string `json:"rule,omitempty"`
+
+	// Reason explains a block
+	Reason string `json:"reason,omitempty"`
+
+	// RateLimited reports a block by a rate-limit rule
+	RateLimited bool `json:"rate_limited,omitempty"`
+
+	// RetryAfter is how long until a rate-limited request would be
+	// allowed
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+
+	// Transforms are the downgrades made to a tool call, in order; the
+	// last holds the call to forward
+	Transforms []Transform `json:"transforms,omitempty"`
+}
+
+// Blocked reports whether the request must be refused.
+func (v Verdict) Blocked() bool {
+	return v.Action == ActionBlock
+}
+
+// Downgraded returns the tool call to forward in place of the
+// request's, if a rule downgraded it.
+func (v Verdict) Downgraded() (tool string, arguments json.RawMessage, ok bool) {
+	if len(v.Transforms) == 0 {
+		return "", nil, false
+	}
+	t := v.Transforms[len(v.Transforms)-1]
+	return t.ToTool, t.Arguments, true
+}
+
+// Validate reports the first rule that cannot be compiled.
+func (s *Set) Validate() error {
+	_, err := compile(s)
+	return err
+}
+
+// compiled is a validated Set ready for evaluation.
+type compiled struct {
+	set        Set
+	rules      []compiledRule
+	defaultGas uint64
+}
+
+type compiledRule struct {
+	Rule
+	args map[string]*regexp.Regexp
+}
+
+// compile validates s and prepares its regular expressions.
+func compile(s *Set) (*compiled, error) {
+	c := &compiled{set: *s, defaultGas: s.DefaultGas}
+	c.set.Rules = append([]Rule(nil), s.Rules...)
+	if c.defaultGas == 0 {
+		c.defaultGas = DefaultGas
+	}
+	switch s.DefaultAction {
+	case "", ActionAllow, ActionBlock:
+	default:
+		return nil, fmt.Errorf("%w: default action must be allow or block, got %q", ErrInvalidRule, s.DefaultAction)
+	}
+
+	names := make(map[string]bool, len(s.Rules))
+	for i, rule := range s.Rules {
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: rules[%d] (%s): %s", ErrInvalidRule, i, rule.Name, fmt.Sprintf(format, args...))
+		}
+		switch {
+		case rule.Name == "":
+			return nil, fmt.Errorf("%w: rules[%d]: a name is required", ErrInvalidRule, i)
+		case names[rule.Name]:
+			return nil, invalid("duplicate rule name")
+		}
+		names[rule.Name] = true
+
+		switch rule.Action {
+		case ActionAllow, ActionBlock, ActionCouncil:
+		case ActionDowngrade:
+			if err := rule.Downgrade.validate(); err != nil {
+				return nil, invalid("%v", err)
+			}
+		case ActionRateLimit:
+			if rule.Rate <= 0 || math.IsInf(rule.Rate, 0) || math.IsNaN(rule.Rate) {
+				return nil, invalid("rate must be a positive number of requests per second")
+			}
+			if rule.Burst < 0 {
+				return nil, invalid("burst must not be negative")
+			}
+		case "":
+			if rule.Gas == 0 {
+				return nil, invalid("an action or a gas price is required")
+			}
+		default:
+			return nil, invalid("unknown action %q", rule.Action)
+		}
+		if rule.Action != ActionDowngrade && !isZero(rule.Downgrade) {
+			return nil, invalid("downgrade is only valid with action %s", ActionDowngrade)
+		}
+		for _, pattern := range append(append([]string(nil), rule.Methods...), rule.Tools...) {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return nil, invalid("malformed pattern %q", pattern)
+			}
+		}
+
+		cr := compiledRule{Rule: rule}
+		if len(rule.Arguments) > 0 {
+			cr.args = make(map[string]*regexp.Regexp, len(rule.Arguments))
+			for name, expr := range rule.Arguments {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					return nil, invalid("argument %q: %v", name, err)
+				}
+				cr.args[name] = re
+			}
+		}
+		c.rules = append(c.rules, cr)
+	}
+	return c, nil
+}
+
+// Engine evaluates requests against a replaceable rule set.
+type Engine struct {
+	current atomic.Pointer[compiled]
+
+	// buckets holds rate limit state per rule and session; it is reset
+	// when the set is replaced
+	mu      sync.Mutex
+	buckets map[bucketKey]*ratelimit.Bucket
+
+	// now returns the current time and newID a staged write's ID
+	// (replaced in tests)
+	now   func() time.Time
+	newID func() string
+}
+
+type bucketKey struct{ rule, session string }
+
+// New creates an engine evaluating set.
+//
+// # Returns
+//   - The engine
+//   - An error wrapping ErrInvalidRule if set does not compile
+func New(set *Set) (*Engine, error) {
+	e := &Engine{buckets: make(map[bucketKey]*ratelimit.Bucket), now: time.Now, newID: randomID}
+	if err := e.Replace(set); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetClock makes the engine refill rate limits by c (nil restores the
+// system clock). Call it before the engine evaluates requests.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.now = clock.Func(c)
+}
+
+// Replace swaps in a new rule set. An invalid set is rejected and the
+// current one stays in effect. Rate limits start afresh.
+func (e *Engine) Replace(set *Set) error {
+	c, err := compile(set)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.current.Store(c)
+	e.buckets = make(map[bucketKey]*ratelimit.Bucket)
+	e.mu.Unlock()
+	return nil
+}
+
+// Set returns a copy of the rule set in effect.
+func (e *Engine) Set() Set {
+	set := e.current.Load().set
+	set.Rules = append([]Rule(nil), set.Rules...)
+	return set
+}
+
+// Evaluate decides a request.
+func (e *Engine) Evaluate(req Request) Verdict {
+	c := e.current.Load()
+	args := decodeArguments(req.Arguments)
+	var transforms []Transform
+	decide := func(v Verdict) Verdict {
+		v.Transforms = transforms
+		return v
+	}
+	for i := range c.rules {
+		rule := &c.rules[i]
+		if rule.Action == "" || !rule.matches(req, args) {
+			continue
+		}
+		switch rule.Action {
+		case ActionDowngrade:
+			t, ok := e.downgrade(rule, req, args)
+			if !ok {
+				continue
+			}
+			transforms = append(transforms, t)
+			req.Tool, req.Server, req.ServerTool, req.Arguments = t.ToTool, "", "", t.Arguments
+			args = decodeArguments(req.Arguments)
+		case ActionRateLimit:
+			if wait := e.take(rule, req.Session); wait > 0 {
+				return decide(Verdict{
+					Action:      ActionBlock,
+					Rule:        rule.Name,
+					Reason:      rule.reason(fmt.Sprintf("rate limit of %g requests per second exceeded (rule %s)", rule.Rate, rule.Name)),
+					RateLimited: true,
+					RetryAfter:  wait,
+				})
+			}
+		case ActionBlock:
+			return decide(Verdict{Action: ActionBlock, Rule: rule.Name, Reason: rule.reason(fmt.Sprintf("blocked by policy rule %s", rule.Name))})
+		default:
+			return decide(Verdict{Action: rule.Action, Rule: rule.Name, Reason: rule.Reason})
+		}
+	}
+	if c.set.DefaultAction == ActionBlock {
+		return decide(Verdict{Action: ActionBlock, Reason: "no policy rule allows this request"})
+	}
+	return decide(Verdict{Action: ActionAllow})
+}
+
+// validate checks a downgrade rule's rewrite.
+func (d *Downgrade) validate() error {
+	if d.Tool == "" && len(d.Arguments) == 0 && d.StageArgument == "" {
+		return errors.New("a downgrade needs a tool, arguments, or a stage argument")
+	}
+	if d.StageArgument != "" && (!path.IsAbs(d.Workspace) || !path.IsAbs(d.StagingDir)) {
+		return errors.New("staging needs an absolute workspace and staging_dir")
+	}
+	if d.StageArgument == "" && (d.Workspace != "" || d.StagingDir != "") {
+		return errors.New("workspace and staging_dir need a stage_argument")
+	}
+	return nil
+}
+
+// isZero reports whether d sets nothing.
+func isZero(d Downgrade) bool {
+	return d.Tool == "" && len(d.Arguments) == 0 && d.StageArgument == "" && d.Workspace == "" && d.StagingDir == ""
+}
+
+// downgrade applies a downgrade rule to a tool call, reporting false if
+// the rule does not apply: the call is not a tool call, a template
+// reference resolves to nothing, or a staged path is in the workspace.
+func (e *Engine) downgrade(rule *compiledRule, req Request, args map[string]json.RawMessage) (Transform, bool) {
+	d := &rule.Downgrade
+	if req.Tool == "" {
+		return Transform{}, false
+	}
+	t := Transform{Rule: rule.Name, FromTool: req.Tool, ToTool: req.Tool}
+	if d.Tool != "" {
+		t.ToTool = d.Tool
+	}
+
+	out := args
+	if out == nil {
+		out = map[string]json.RawMessage{}
+	}
+	if len(d.Arguments) > 0 {
+		groups := rule.captures(args)
+		out = make(map[string]json.RawMessage, len(d.Arguments))
+		for name, template := range d.Arguments {
+			value, ok := expand(template, groups, args)
+			if !ok {
+				return Transform{}, false
+			}
+			out[name] = value
+		}
+	}
+
+	if d.StageArgument != "" {
+		var target string
+		if json.Unmarshal(out[d.StageArgument], &target) != nil || target == "" {
+			return Transform{}, false
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(d.Workspace, target)
+		}
+		target = path.Clean(target)
+		workspace := path.Clean(d.Workspace)
+		if target == workspace || strings.HasPrefix(target, strings.TrimSuffix(workspace, "/")+"/") {
+			return Transform{}, false
+		}
+		t.ID = e.newID()
+		t.Original = target
+		t.Staged = path.Join(d.StagingDir, t.ID, target)
+		t.StagingDir = d.StagingDir
+		staged, _ := json.Marshal(t.Staged)
+		copied := make(map[string]json.RawMessage, len(out))
+		for k, v := range out {
+			copied[k] = v
+		}
+		copied[d.StageArgument] = staged
+		out = copied
+	}
+
+	var err error
+	if t.Arguments, err = json.Marshal(out); err != nil {
+		return Transform{}, false
+	}
+	return t, true
+}
+
+// captures returns the named capture groups of the rule's argument
+// expressions matched against args. A group in several expressions
+// takes the value of the first argument name in sorted order.
+func (r *compiledRule) captures(args map[string]json.RawMessage) map[string]string {
+	groups := make(map[string]string)
+	names := make([]string, 0, len(r.args))
+	for name := range r.args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re := r.args[name]
+		var texts []string
+		if name == "*" {
+			keys := make([]string, 0, len(args))
+			for k := range args {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				texts = append(texts, argumentText(args[k]))
+			}
+		} else if value, ok := args[name]; ok {
+			texts = append(texts, argumentText(value))
+		}
+		for _, text := range texts {
+			m := re.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			for i, group := range re.SubexpNames() {
+				if _, seen := groups[group]; group != "" && !seen && i < len(m) {
+					groups[group] = m[i]
+				}
+			}
+			break
+		}
+	}
+	return groups
+}
+
+// expand renders a downgrade argument template.
+func expand(template string, groups map[string]string, args map[string]json.RawMessage) (json.RawMessage, bool) {
+	if m := templateRef.FindStringSubmatch(template); m != nil && m[0] == template {
+		if _, isGroup := groups[m[1]]; !isGroup {
+			value, ok := args[m[1]]
+			return value, ok
+		}
+	}
+	ok := true
+	text := templateRef.ReplaceAllStringFunc(template, func(ref string) string {
+		name := templateRef.FindStringSubmatch(ref)[1]
+		if value, found := groups[name]; found {
+			return value
+		}
+		if value, found := args[name]; found {
+			return argumentText(value)
+		}
+		ok = false
+		return ""
+	})
+	if !ok {
+		return nil, false
+	}
+	value, _ := json.Marshal(text)
+	return value, true
+}
+
+// randomID returns a random 64-bit hex ID.
+func randomID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Cost prices a tool call in gas. Its signature matches the router's
+// GasModel, so an Engine can price calls directly; rules naming
+// servers never match here.
+func (e *Engine) Cost(tool string, params json.RawMessage, _ int) uint64 {
+	c := e.current.Load()
+	req := Request{Method: "tools/call", Tool: tool}
+	var call struct {
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal(params, &call) == nil {
+		req.Arguments = call.Arguments
+	}
+	args := decodeArguments(req.Arguments)
+	for i := range c.rules {
+		if rule := &c.rules[i]; rule.Gas > 0 && rule.matches(req, args) {
+			return rule.Gas
+		}
+	}
+	return c.defaultGas
+}
+
+// take spends one token from the session's bucket for rule, or returns
+// how long until one is available.
+func (e *Engine) take(rule *compiledRule, session string) time.Duration {
+	burst := rule.burst()
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := bucketKey{rule.Name, session}
+	b := e.buckets[key]
+	if b == nil {
+		if len(e.buckets) >= maxBuckets {
+			e.prune(now)
+		}
+		b = ratelimit.NewBucket(burst, now)
+		e.buckets[key] = b
+	}
+	return b.Take(now, rule.Rate, burst)
+}
+
+// prune drops buckets idle long enough to have refilled, which behave
+// exactly like new ones. Called with mu held.
+func (e *Engine) prune(now time.Time) {
+	c := e.current.Load()
+	rules := make(map[string]*compiledRule, len(c.rules))
+	for i := range c.rules {
+		rules[c.rules[i].Name] = &c.rules[i]
+	}
+	for key, b := range e.buckets {
+		rule := rules[key.rule]
+		if rule == nil || b.Full(now, rule.Rate, rule.burst()) {
+			delete(e.buckets, key)
+		}
+	}
+}
+
+// burst returns the bucket capacity of a rate-limit rule.
+func (r *compiledRule) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return math.Max(1, r.Rate)
+}
+
+// reason returns the rule's reason or fallback.
+func (r *compiledRule) reason(fallback string) string {
+	if r.Reason != "" {
+		return r.Reason
+	}
+	return fallback
+}
+
+// matches reports whether every match field of the rule matches req.
+func (r *compiledRule) matches(req Request, args map[string]json.RawMessage) bool {
+	if len(r.Methods) > 0 && !matchAny(r.Methods, req.Method) {
+		return false
+	}
+	if len(r.Tools) > 0 && !matchAny(r.Tools, req.Tool) && !matchAny(r.Tools, req.ServerTool) {
+		return false
+	}
+	if len(r.Servers) > 0 && (req.Server == "" || !contains(r.Servers, req.Server)) {
+		return false
+	}
+	for name, re := range r.args {
+		if name == "*" {
+			if !matchAnyArgument(re, args) {
+				return false
+			}
+			continue
+		}
+		value, ok := args[name]
+		if !ok || !re.MatchString(argumentText(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyArgument(re *regexp.Regexp, args map[string]json.RawMessage) bool {
+	for _, value := range args {
+		if re.MatchString(argumentText(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeArguments splits a tool argument object; anything else has no
+// arguments.
+func decodeArguments(raw json.RawMessage) map[string]json.RawMessage {
+	var args map[string]json.RawMessage
+	if len(raw) > 0 && json.Unmarshal(raw, &args) != nil {
+		return nil
+	}
+	return args
+}
+
+// argumentText is the text an argument regular expression sees.
+func argumentText(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, value) != nil {
+		return string(value)
+	}
+	return buf.String()
+}
@@ -0,0 +1,210 @@
+// Package queue provides bounded, observable FIFO queues for the
+// stages of the routing pipeline.
+//
+// Unbounded internal queues hide latency problems: they absorb bursts
+// silently until memory or end-to-end latency becomes an outage. Every
+// queue here has a fixed capacity, rejects or blocks producers when
+// full, and reports its depth and the age of its oldest entry so
+// saturation is visible in metrics before it is visible to users.
+//
+// # Thread Safety
+//
+// Queue is safe for concurrent producers and consumers.
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Queue errors.
+var (
+	ErrFull   = errors.New("queue: full")
+	ErrClosed = errors.New("queue: closed")
+)
+
+// Stats is a point-in-time view of a queue.
+type Stats struct {
+	// Name identifies the queue in metrics
+	Name string `json:"name"`
+
+	// Depth is the number of queued items
+	Depth int `json:"depth"`
+
+	// Capacity is the maximum depth
+	Capacity int `json:"capacity"`
+
+	// OldestAge is how long the head item has been waiting
+	OldestAge time.Duration `json:"oldest_age"`
+
+	// Enqueued counts accepted items
+	Enqueued uint64 `json:"enqueued"`
+
+	// Rejected counts items refused because the queue was full
+	Rejected uint64 `json:"rejected"`
+}
+
+type entry struct {
+	data []byte
+	at   time.Time
+}
+
+// Queue is a bounded FIFO of messages.
+type Queue struct {
+	name string
+
+	mu       sync.Mutex
+	buf      []entry
+	head     int
+	size     int
+	closed   bool
+	enqueued uint64
+	rejected uint64
+
+	// notEmpty and notFull wake blocked consumers and producers
+	notEmpty chan struct{}
+	notFull  chan struct{}
+
+	now func() time.Time
+}
+
+// New creates a queue holding at most capacity items (minimum 1).
+func New(name string, capacity int) *Queue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Queue{
+		name:     name,
+		buf:      make([]entry, capacity),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// TryPush enqueues data without blocking.
+//
+// # Returns
+//   - ErrFull if the queue is at capacity
+//   - ErrClosed if the queue has been closed
+func (q *Queue) TryPush(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if q.size == len(q.buf) {
+		q.rejected++
+		return ErrFull
+	}
+	q.pushLocked(data)
+	return nil
+}
+
+// Push enqueues data, blocking while the queue is full.
+//
+// # Returns
+//   - ctx.Err() if the context ends first
+//   - ErrClosed if the queue has been closed
+func (q *Queue) Push(ctx context.Context, data []byte) error {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrClosed
+		}
+		if q.size < len(q.buf) {
+			q.pushLocked(data)
+			q.mu.Unlock()
+			return nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.notFull:
+		}
+	}
+}
+
+// Pop dequeues the oldest item, blocking while the queue is empty.
+//
+// # Returns
+//   - Item data and how long it waited in the queue
+//   - ctx.Err() if the context ends first
+//   - ErrClosed once the queue is closed and drained
+func (q *Queue) Pop(ctx context.Context) ([]byte, time.Duration, error) {
+	for {
+		q.mu.Lock()
+		if q.size > 0 {
+			e := q.buf[q.head]
+			q.buf[q.head] = entry{}
+			q.head = (q.head + 1) % len(q.buf)
+			q.size--
+			more := q.size > 0
+			q.mu.Unlock()
+
+			signal(q.notFull)
+			if more {
+				signal(q.notEmpty)
+			}
+			return e.data, q.now().Sub(e.at), nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return nil, 0, ErrClosed
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-q.notEmpty:
+		}
+	}
+}
+
+// Close stops accepting items. Queued items can still be popped.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	signal(q.notEmpty)
+	signal(q.notFull)
+}
+
+// Stats returns the queue's current depth, capacity, and head age.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := Stats{
+		Name:     q.name,
+		Depth:    q.size,
+		Capacity: len(q.buf),
+		Enqueued: q.enqueued,
+		Rejected: q.rejected,
+	}
+	if q.size > 0 {
+		s.OldestAge = q.now().Sub(q.buf[q.head].at)
+	}
+	return s
+}
+
+// pushLocked appends data. Caller must hold q.mu and ensure space.
+func (q *Queue) pushLocked(data []byte) {
+	q.buf[(q.head+q.size)%len(q.buf)] = entry{data: data, at: q.now()}
+	q.size++
+	q.enqueued++
+	signal(q.notEmpty)
+}
+
+// signal performs a non-blocking wake-up.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueue_BoundedFIFO(t *testing.T) {
+	q := New("ingress", 2)
+	now := time.Unix(1000, 0)
+	q.now = func() time.Time { return now }
+
+	if err := q.TryPush([]byte("a")); err != nil {
+		t.Fatalf("TryPush failed: %v", err)
+	}
+	now = now.Add(time.Second)
+	q.TryPush([]byte("b"))
+	if err := q.TryPush([]byte("c")); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	s := q.Stats()
+	if s.Depth != 2 || s.Rejected != 1 || s.OldestAge != 2*time.Second {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	data, waited, err := q.Pop(context.Background())
+	if err != nil || string(data) != "a" || waited != 2*time.Second {
+		t.Errorf("Pop = %q, %v, %v", data, waited, err)
+	}
+}
+
+func TestQueue_PushBlocksUntilSpace(t *testing.T) {
+	q := New("egress", 1)
+	q.TryPush([]byte("a"))
+
+	done := make(chan error, 1)
+	go func() { done <- q.Push(context.Background(), []byte("b")) }()
+
+	select {
+	case <-done:
+		t.Fatal("Push should block while full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.Pop(context.Background())
+	if err := <-done; err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if data, _, _ := q.Pop(context.Background()); string(data) != "b" {
+		t.Errorf("expected b, got %q", data)
+	}
+}
+
+func TestQueue_CloseDrains(t *testing.T) {
+	q := New("q", 4)
+	q.TryPush([]byte("a"))
+	q.Close()
+
+	if err := q.TryPush([]byte("b")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if data, _, err := q.Pop(context.Background()); err != nil || string(data) != "a" {
+		t.Errorf("expected queued item after close, got %q, %v", data, err)
+	}
+	if _, _, err := q.Pop(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed once drained, got %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+// Package ratelimit limits the rate of tool calls with token buckets.
+//
+// Limits apply at three levels: one bucket for every call the process
+// forwards (Global), one per session (Session), and one per tool, given
+// by name or pattern and shared per session or by every session
+// (Tools). A call is allowed only when every bucket it draws on holds a
+// token; it then spends one from each, so a refused call costs nothing.
+//
+// A refused call learns how long until it would be allowed (see
+// Exceeded.RetryAfter), which the router returns as a retry hint.
+//
+// # Example
+//
+// Contain an agent hammering execute_command while leaving reads free:
+//
+//	&Config{
+//	    Global:  Limit{Rate: 200, Burst: 400},
+//	    Session: Limit{Rate: 20, Burst: 40},
+//	    Tools: []ToolLimit{
+//	        {Tool: "execute_command", Rate: 0.5, Burst: 3},
+//	    },
+//	}
+//
+// # Thread Safety
+//
+// Limiter is safe for concurrent use and is typically shared by all
+// sessions, so the global and tool-wide buckets span them. Bucket is
+// not; its owner serializes access.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+// maxBuckets bounds the bucket state kept across sessions.
+const maxBuckets = 10000
+
+// ErrInvalidLimit reports a configuration that cannot be enforced.
+var ErrInvalidLimit = errors.New("ratelimit: invalid limit")
+
+// Scope selects who shares a tool limit's bucket.
+type Scope string
+
+const (
+	// ScopeSession gives each session its own bucket (the default)
+	ScopeSession Scope = "session"
+	// ScopeGlobal shares one bucket among all sessions
+	ScopeGlobal Scope = "global"
+)
+
+// Limit is a token bucket's refill rate and capacity.
+type Limit struct {
+	// Rate is the number of calls per second allowed on average (zero
+	// disables the limit)
+	Rate float64 `json:"rate"`
+
+	// Burst is the number of calls allowed at once (zero uses Rate,
+	// and at least 1)
+	Burst int `json:"burst"`
+}
+
+// ToolLimit limits the calls to the tools matching Tool.
+type ToolLimit struct {
+	// Tool is a tool name or path.Match pattern such as "*__delete_*"
+	Tool string `json:"tool"`
+
+	// Rate is the number of calls per second allowed on average
+	Rate float64 `json:"rate"`
+
+	// Burst is the number of calls allowed at once (zero uses Rate,
+	// and at least 1)
+	Burst int `json:"burst"`
+
+	// Scope is session (the default) or global
+	Scope Scope `json:"scope"`
+}
+
+// Config sets the limits. The first tool limit matching a call applies.
+type Config struct {
+	// Global limits every call of every session
+	Global Limit `json:"global"`
+
+	// Session limits each session's calls
+	Session Limit `json:"session"`
+
+	// Tools limit calls by tool
+	Tools []ToolLimit `json:"tools"`
+
+	// Clock refills the buckets (nil uses the system clock)
+	Clock clock.Clock `json:"-"`
+}
+
+// Validate checks that every limit can be enforced.
+//
+// # Returns
+//   - ErrInvalidLimit naming the first limit in error
+func (c *Config) Validate() error {
+	if err := c.Global.validate(); err != nil {
+		return fmt.Errorf("%w: global: %v", ErrInvalidLimit, err)
+	}
+	if err := c.Session.validate(); err != nil {
+		return fmt.Errorf("%w: session: %v", ErrInvalidLimit, err)
+	}
+	for i, t := range c.Tools {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("%w: tools[%d] (%s): %v", ErrInvalidLimit, i, t.Tool, err)
+		}
+	}
+	return nil
+}
+
+// validate checks one limit; zero is valid and disabled.
+func (l Limit) validate() error {
+	switch {
+	case l.Rate < 0 || math.IsInf(l.Rate, 0) || math.IsNaN(l.Rate):
+		return fmt.Errorf("rate must be a positive number of calls per second")
+	case l.Burst < 0:
+		return fmt.Errorf("burst must not be negative")
+	case l.Rate == 0 && l.Burst != 0:
+		return fmt.Errorf("burst requires a rate")
+	}
+	return nil
+}
+
+// validate checks a tool limit.
+func (t ToolLimit) validate() error {
+	if t.Tool == "" {
+		return fmt.Errorf("a tool name or pattern is required")
+	}
+	if _, err := path.Match(t.Tool, ""); err != nil {
+		return fmt.Errorf("tool pattern: %v", err)
+	}
+	if t.Rate == 0 {
+		return fmt.Errorf("rate must be a positive number of calls per second")
+	}
+	switch t.Scope {
+	case "", ScopeSession, ScopeGlobal:
+	default:
+		return fmt.Errorf("scope must be session or global, got %q", t.Scope)
+	}
+	return Limit{Rate: t.Rate, Burst: t.Burst}.validate()
+}
+
+// capacity returns the bucket capacity of the limit.
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, l.Rate)
+}
+
+// Bucket is a token bucket. The zero value is empty; use NewBucket for
+// a full one.
+type Bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket holding burst tokens at now.
+func NewBucket(burst float64, now time.Time) *Bucket {
+	return &Bucket{tokens: burst, last: now}
+}
+
+// Take spends a token if the bucket, refilled at rate per second up to
+// burst, holds one at now.
+//
+// # Returns
+//   - Zero if a token was spent, otherwise how long until one will be
+//     available
+func (b *Bucket) Take(now time.Time, rate, burst float64) time.Duration {
+	if wait := b.wait(now, rate, burst); wait > 0 {
+		return wait
+	}
+	b.tokens--
+	return 0
+}
+
+// Full reports whether the bucket would be full at now, in which case
+// it behaves exactly like a new one.
+func (b *Bucket) Full(now time.Time, rate, burst float64) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= burst
+}
+
+// wait refills the bucket to now and returns how long until it holds a
+// token (zero if it holds one).
+func (b *Bucket) wait(now time.Time, rate, burst float64) time.Duration {
+	if now.After(b.last) {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / rate * float64(time.Second)))
+}
+
+// Exceeded describes a refused call.
+type Exceeded struct {
+	// Limit names the limit: global, session, or the tool pattern
+	Limit string
+
+	// Rate is the limit's rate in calls per second
+	Rate float64
+
+	// RetryAfter is how long until the call would be allowed, if no
+	// other call spends the tokens first
+	RetryAfter time.Duration
+}
+
+// Error describes the exceeded limit.
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("%s rate limit of %g calls per second exceeded; retry after %s",
+		e.Limit, e.Rate, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Limiter enforces a Config.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*Bucket
+}
+
+// Bucket owners that are not tool limits.
+const (
+	limitGlobal  = -1
+	limitSession = -2
+)
+
+// bucketKey identifies a bucket: a limit, by tool limit index or
+// limitGlobal or limitSession, and the session for per-session limits.
+type bucketKey struct {
+	limit   int
+	session string
+}
+
+// New creates a limiter enforcing cfg.
+//
+// # Returns
+//   - ErrInvalidLimit if cfg does not validate
+func New(cfg *Config) (*Limiter, error) {
+	l := &Limiter{now: time.Now, buckets: make(map[bucketKey]*Bucket)}
+	if cfg == nil {
+		return l, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	l.cfg = *cfg
+	l.cfg.Tools = append([]ToolLimit(nil), cfg.Tools...)
+	l.now = clock.Func(cfg.Clock)
+	return l, nil
+}
+
+// draw is a bucket a call draws on, with its limit.
+type draw struct {
+	key   bucketKey
+	name  string
+	limit Limit
+}
+
+// Allow charges a call to tool by session against every limit that
+// applies, spending a token from each only if all hold one.
+//
+// # Returns
+//   - nil if the call is allowed
+//   - The exceeded limit with the longest wait otherwise
+func (l *Limiter) Allow(session, tool string) *Exceeded {
+	var draws []draw
+	if l.cfg.Global.Rate > 0 {
+		draws = append(draws, draw{bucketKey{limitGlobal, ""}, "global", l.cfg.Global})
+	}
+	if l.cfg.Session.Rate > 0 {
+		draws = append(draws, draw{bucketKey{limitSession, session}, "session", l.cfg.Session})
+	}
+	for i, t := range l.cfg.Tools {
+		if ok, _ := path.Match(t.Tool, tool); !ok {
+			continue
+		}
+		key := bucketKey{i, session}
+		if t.Scope == ScopeGlobal {
+			key.session = ""
+		}
+		draws = append(draws, draw{key, "tool " + t.Tool, Limit{Rate: t.Rate, Burst: t.Burst}})
+		break
+	}
+	if len(draws) == 0 {
+		return nil
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var exceeded *Exceeded
+	buckets := make([]*Bucket, len(draws))
+	for i, d := range draws {
+		buckets[i] = l.bucket(d, now)
+		if wait := buckets[i].wait(now, d.limit.Rate, d.limit.capacity()); wait > 0 && (exceeded == nil || wait > exceeded.RetryAfter) {
+			exceeded = &Exceeded{Limit: d.name, Rate: d.limit.Rate, RetryAfter: wait}
+		}
+	}
+	if exceeded != nil {
+		return exceeded
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return nil
+}
+
+// bucket returns the bucket of d, creating a full one. Called with mu
+// held.
+func (l *Limiter) bucket(d draw, now time.Time) *Bucket {
+	b := l.buckets[d.key]
+	if b == nil {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = NewBucket(d.limit.capacity(), now)
+		l.buckets[d.key] = b
+	}
+	return b
+}
+
+// prune drops full buckets, which behave exactly like new ones. Called
+// with mu held.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		limit := l.cfg.Global
+		switch {
+		case key.limit == limitSession:
+			limit = l.cfg.Session
+		case key.limit >= 0:
+			t := l.cfg.Tools[key.limit]
+			limit = Limit{Rate: t.Rate, Burst: t.Burst}
+		}
+		if b.Full(now, limit.Rate, limit.capacity()) {
+			delete(l.buckets, key)
+		}
+	}
+}
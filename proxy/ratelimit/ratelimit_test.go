@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	l, err := New(&Config{
+		Global:  Limit{Rate: 10, Burst: 5},
+		Session: Limit{Rate: 1, Burst: 3},
+		Tools: []ToolLimit{
+			{Tool: "execute_command", Rate: 0.5, Burst: 1},
+			{Tool: "*__delete_*", Rate: 0.1, Scope: ScopeGlobal},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		session string
+		tool    string
+		limit   string
+		retry   time.Duration
+	}{
+		{"tool within burst", "a", "execute_command", "", 0},
+		{"tool exhausted", "a", "execute_command", "tool execute_command", 2 * time.Second},
+		{"refusal spends nothing", "a", "read_file", "", 0},
+		{"tool bucket is per session", "b", "execute_command", "", 0},
+		{"session burst left", "a", "read_file", "", 0},
+		{"session exhausted", "a", "read_file", "session", time.Second},
+		{"global tool limit", "c", "fs__delete_file", "", 0},
+		{"shared by sessions", "d", "fs__delete_file", "tool *__delete_*", 10 * time.Second},
+		{"global burst spent", "e", "read_file", "global", 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exceeded := l.Allow(tt.session, tt.tool)
+			if tt.limit == "" {
+				if exceeded != nil {
+					t.Fatalf("Allow = %v, expected allowed", exceeded)
+				}
+				return
+			}
+			if exceeded == nil || exceeded.Limit != tt.limit || exceeded.RetryAfter != tt.retry {
+				t.Fatalf("Allow = %+v, expected %s with retry %s", exceeded, tt.limit, tt.retry)
+			}
+		})
+	}
+
+	now = now.Add(2 * time.Second)
+	if exceeded := l.Allow("a", "execute_command"); exceeded != nil {
+		t.Errorf("Allow after refill = %v", exceeded)
+	}
+}
+
+func TestLimiter_Clock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	l, err := New(&Config{Session: Limit{Rate: 1.0 / 60, Burst: 1}, Clock: fake})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if exceeded := l.Allow("a", "search"); exceeded != nil {
+		t.Fatalf("first call limited: %v", exceeded)
+	}
+	if exceeded := l.Allow("a", "search"); exceeded == nil || exceeded.RetryAfter != time.Minute {
+		t.Fatalf("Allow = %+v, expected a retry after a minute", exceeded)
+	}
+	fake.Advance(time.Minute)
+	if exceeded := l.Allow("a", "search"); exceeded != nil {
+		t.Errorf("Allow after a minute = %v", exceeded)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		invalid bool
+	}{
+		{"empty", Config{}, false},
+		{"full", Config{Global: Limit{Rate: 1}, Tools: []ToolLimit{{Tool: "x*", Rate: 2, Burst: 4, Scope: ScopeGlobal}}}, false},
+		{"negative rate", Config{Session: Limit{Rate: -1}}, true},
+		{"burst without rate", Config{Global: Limit{Burst: 3}}, true},
+		{"tool without rate", Config{Tools: []ToolLimit{{Tool: "x"}}}, true},
+		{"tool without name", Config{Tools: []ToolLimit{{Rate: 1}}}, true},
+		{"bad pattern", Config{Tools: []ToolLimit{{Tool: "[", Rate: 1}}}, true},
+		{"unknown scope", Config{Tools: []ToolLimit{{Tool: "x", Rate: 1, Scope: "tenant"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if got := errors.Is(err, ErrInvalidLimit); got != tt.invalid {
+				t.Errorf("Validate = %v, expected invalid %t", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestBucket_Take(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := NewBucket(2, now)
+	for i := 0; i < 2; i++ {
+		if wait := b.Take(now, 4, 2); wait != 0 {
+			t.Fatalf("take %d waited %s", i, wait)
+		}
+	}
+	if wait := b.Take(now, 4, 2); wait != 250*time.Millisecond {
+		t.Errorf("empty bucket wait = %s, expected 250ms", wait)
+	}
+	if b.Full(now.Add(400*time.Millisecond), 4, 2) {
+		t.Error("bucket full before refilling")
+	}
+	if !b.Full(now.Add(time.Second), 4, 2) {
+		t.Error("bucket not full after refilling")
+	}
+}
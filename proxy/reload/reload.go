@@ -0,0 +1,271 @@
+// Package reload re-reads the proxy configuration while the proxy runs.
+//
+// A Reloader is triggered by SIGHUP or the admin API. It loads the
+// configuration again (file, environment, and command line flags),
+// validates all of it, and only then applies the settings a running
+// proxy can take on:
+//
+//   - policy.rules, policy.default_action, policy.default_gas: replaced
+//     in the shared policy engine, overriding rules set through the
+//     admin API
+//   - policy.allow, policy.deny, high_risk_tools: reconfigured on every
+//     registered router session
+//   - ffi.library, ffi.drain_timeout: used by the next sentinel library
+//     reload
+//
+// Other changes, such as listen addresses, upstreams, or TLS, take
+// effect at the next restart; they are logged and reported in
+// Result.Restart and Status.Restart until then. Enabling or disabling
+// the policy engine also needs a restart, since sessions are created
+// with or without one.
+//
+// # Security Notes
+//
+// A configuration that fails to load or validate is rejected as a
+// whole and the running one stays in effect, so a broken edit cannot
+// take down the proxy or leave it half reconfigured. Sessions keep
+// their transports, pending requests, gas, and history; replacing the
+// policy rules does reset their rate limits.
+//
+// # Thread Safety
+//
+// Reloader is safe for concurrent use; reloads are serialized.
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+)
+
+// ErrRejected wraps the reason a reloaded configuration was not applied.
+var ErrRejected = errors.New("reload: configuration rejected")
+
+// reloadable lists the top-level configuration fields, by JSON name,
+// applied without a restart.
+var reloadable = map[string]bool{"policy": true, "high_risk_tools": true, "ffi": true}
+
+// Config configures a Reloader.
+type Config struct {
+	// Load reads and validates the configuration (required)
+	Load func() (*config.Config, error)
+
+	// Policy is the shared policy engine (nil when the proxy started
+	// without policy rules)
+	Policy *policy.Engine
+}
+
+// Result describes an applied reload.
+type Result struct {
+	// Applied lists the reloadable fields that changed
+	Applied []string `json:"applied"`
+
+	// Restart lists changed fields that take effect at the next restart
+	Restart []string `json:"restart,omitempty"`
+}
+
+// Status summarizes the reloads so far.
+type Status struct {
+	// Reloads and Failures count applied and rejected reloads
+	Reloads  uint64 `json:"reloads"`
+	Failures uint64 `json:"failures"`
+
+	// LastAttempt is when a reload last ran (zero before the first)
+	LastAttempt time.Time `json:"last_attempt"`
+
+	// LastError is why the last reload was rejected (empty if applied)
+	LastError string `json:"last_error,omitempty"`
+
+	// Restart lists fields changed since startup that need a restart
+	Restart []string `json:"restart,omitempty"`
+}
+
+// Reloader applies configuration changes to a running proxy.
+type Reloader struct {
+	load   func() (*config.Config, error)
+	policy *policy.Engine
+
+	mu       sync.Mutex
+	running  *config.Config
+	sessions map[string]*router.Router
+	status   Status
+}
+
+// New creates a Reloader for a proxy running with current.
+//
+// # Arguments
+//
+//   - current: The configuration the proxy started with
+//   - cfg: How to load the configuration and what to apply it to
+func New(current *config.Config, cfg *Config) *Reloader {
+	return &Reloader{
+		load:     cfg.Load,
+		policy:   cfg.Policy,
+		running:  current,
+		sessions: make(map[string]*router.Router),
+	}
+}
+
+// Register adds a router session to reconfigure on reload. The session
+// takes on the settings of the last applied reload, so sessions created
+// from the startup configuration do not miss one.
+func (r *Reloader) Register(rt *router.Router) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := rt.Reconfigure(r.running.RouterSettings()); err != nil {
+		// The running configuration was validated before it was applied
+		log.Printf("reload: cannot apply settings to new session: %v", err)
+	}
+	r.sessions[rt.Health().SessionID] = rt
+}
+
+// Unregister removes a router session.
+func (r *Reloader) Unregister(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sessionID)
+}
+
+// Status returns the reload counters and pending restart fields.
+func (r *Reloader) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.status
+	st.Restart = append([]string(nil), st.Restart...)
+	return st
+}
+
+// Running returns the configuration in effect: the last one applied,
+// with fields awaiting a restart still at their start-up values. The
+// caller must not modify it.
+func (r *Reloader) Running() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// Run reloads on every value from trigger (usually SIGHUP delivered by
+// signal.Notify) until ctx is done.
+func (r *Reloader) Run(ctx context.Context, trigger <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+			r.Reload()
+		}
+	}
+}
+
+// Reload loads and validates the configuration, then applies it.
+//
+// # Returns
+//
+// The applied and restart-pending changes, or an error wrapping
+// ErrRejected when the configuration was not applied; the running
+// configuration is then unchanged.
+func (r *Reloader) Reload() (*Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.LastAttempt = time.Now()
+
+	next, err := r.load()
+	if err == nil {
+		err = validate(next)
+	}
+	if err != nil {
+		r.status.Failures++
+		r.status.LastError = err.Error()
+		log.Printf("audit: configuration reload rejected, keeping the running configuration: %v", err)
+		return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+
+	// Everything below is validated and cannot fail
+	effective, result := r.plan(next)
+	if r.policy != nil {
+		if set := effective.Policy.Set(); set != nil && !sameSet(r.policy.Set(), *set) {
+			r.policy.Replace(set)
+		}
+	}
+	settings := effective.RouterSettings()
+	for _, rt := range r.sessions {
+		rt.Reconfigure(settings)
+	}
+	r.running = effective
+
+	r.status.Reloads++
+	r.status.LastError = ""
+	r.status.Restart = result.Restart
+	log.Printf("audit: configuration reloaded: applied %v, %d sessions reconfigured", result.Applied, len(r.sessions))
+	if len(result.Restart) > 0 {
+		log.Printf("reload: changes to %s take effect at the next restart", strings.Join(result.Restart, ", "))
+	}
+	return result, nil
+}
+
+// validate re-checks what a reload applies, whatever Load validated,
+// so a swap never starts with input that could fail halfway.
+func validate(c *config.Config) error {
+	if set := c.Policy.Set(); set != nil {
+		if err := set.Validate(); err != nil {
+			return err
+		}
+	}
+	if tp := c.RouterSettings().ToolPolicy; tp != nil {
+		return tp.Validate()
+	}
+	return nil
+}
+
+// plan returns the configuration to run, next with the fields that
+// need a restart kept at their running values, and the changes.
+func (r *Reloader) plan(next *config.Config) (*config.Config, *Result) {
+	effective := *next
+	result := &Result{Applied: []string{}}
+
+	// Sessions were created with or without the policy engine
+	if (r.policy == nil) != (effective.Policy.Set() == nil) {
+		p := r.running.Policy
+		effective.Policy.Rules, effective.Policy.DefaultAction, effective.Policy.DefaultGas = p.Rules, p.DefaultAction, p.DefaultGas
+		result.Restart = append(result.Restart, "policy.rules")
+	}
+
+	run := reflect.ValueOf(r.running).Elem()
+	eff := reflect.ValueOf(&effective).Elem()
+	for i := 0; i < eff.NumField(); i++ {
+		name := strings.Split(eff.Type().Field(i).Tag.Get("json"), ",")[0]
+		if reflect.DeepEqual(run.Field(i).Interface(), eff.Field(i).Interface()) {
+			continue
+		}
+		if !reloadable[name] {
+			result.Restart = append(result.Restart, name)
+			eff.Field(i).Set(run.Field(i))
+			continue
+		}
+		result.Applied = append(result.Applied, name)
+	}
+	return &effective, result
+}
+
+// sameSet reports whether two rule sets are equal.
+func sameSet(a, b policy.Set) bool {
+	if a.DefaultAction != b.DefaultAction || a.DefaultGas != b.DefaultGas || len(a.Rules) != len(b.Rules) {
+		return false
+	}
+	for i := range a.Rules {
+		if !reflect.DeepEqual(a.Rules[i], b.Rules[i]) {
+			return false
+		}
+	}
+	return true
+}
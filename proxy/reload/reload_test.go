@@ -0,0 +1,196 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/config"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/router"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// fakeTransport never delivers a message.
+type fakeTransport struct{}
+
+func (fakeTransport) Send([]byte) error        { return nil }
+func (fakeTransport) Receive() ([]byte, error) { return nil, transport.ErrClosed }
+func (fakeTransport) Close() error             { return nil }
+
+var _ transport.Transport = fakeTransport{}
+
+func startConfig() *config.Config {
+	c := config.Default()
+	c.Upstreams = []config.Upstream{{Command: []string{"server"}}}
+	c.Policy.Deny = []string{"shell"}
+	c.Policy.Rules = []policy.Rule{{Name: "no-sudo", Tools: []string{"sudo"}, Action: policy.ActionBlock}}
+	return c
+}
+
+// setup returns a reloader whose Load returns *next, and a registered
+// session.
+func setup(t *testing.T, next **config.Config, loadErr *error) (*Reloader, *policy.Engine, *router.Router) {
+	t.Helper()
+	start := startConfig()
+	engine, err := policy.New(start.Policy.Set())
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	r := New(start, &Config{
+		Load: func() (*config.Config, error) {
+			if *loadErr != nil {
+				return nil, *loadErr
+			}
+			c := **next
+			return &c, nil
+		},
+		Policy: engine,
+	})
+	rc := start.RouterConfig()
+	rc.Policy = engine
+	rt := router.NewWithConfig(fakeTransport{}, sentinel.NewClient(), rc)
+	r.Register(rt)
+	return r, engine, rt
+}
+
+func TestReload_Applies(t *testing.T) {
+	next := startConfig()
+	next.Policy.Deny = []string{"deploy"}
+	next.Policy.Rules = []policy.Rule{{Name: "no-rm", Tools: []string{"rm"}, Action: policy.ActionBlock}}
+	next.HighRiskTools = []string{"deploy"}
+	next.Port = 9999
+	var loadErr error
+	r, engine, _ := setup(t, &next, &loadErr)
+
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if !slices.Equal(result.Applied, []string{"high_risk_tools", "policy"}) {
+		t.Errorf("Applied = %v", result.Applied)
+	}
+	if !slices.Equal(result.Restart, []string{"port"}) {
+		t.Errorf("Restart = %v", result.Restart)
+	}
+	if set := engine.Set(); len(set.Rules) != 1 || set.Rules[0].Name != "no-rm" {
+		t.Errorf("policy rules = %+v, expected the reloaded rule", set.Rules)
+	}
+	if running := r.Running(); running.Port != 8080 || !slices.Equal(running.HighRiskTools, []string{"deploy"}) {
+		t.Errorf("Running = port %d, high-risk %v", running.Port, running.HighRiskTools)
+	}
+
+	// The restart field stays pending; an unchanged reload applies nothing
+	result, err = r.Reload()
+	if err != nil {
+		t.Fatalf("second Reload failed: %v", err)
+	}
+	if len(result.Applied) != 0 || !slices.Equal(result.Restart, []string{"port"}) {
+		t.Errorf("second reload = %+v", result)
+	}
+	if st := r.Status(); st.Reloads != 2 || st.Failures != 0 || !slices.Equal(st.Restart, []string{"port"}) {
+		t.Errorf("Status = %+v", st)
+	}
+}
+
+func TestReload_RejectedKeepsRunning(t *testing.T) {
+	next := startConfig()
+	var loadErr error
+	r, engine, _ := setup(t, &next, &loadErr)
+
+	loadErr = config.ErrInvalid
+	if _, err := r.Reload(); !errors.Is(err, ErrRejected) || !errors.Is(err, config.ErrInvalid) {
+		t.Fatalf("Reload = %v, expected ErrRejected wrapping the load error", err)
+	}
+
+	// A configuration Load did not validate is still refused as a whole
+	loadErr = nil
+	bad := startConfig()
+	bad.Policy.Deny = []string{"[x"}
+	bad.Policy.Rules = []policy.Rule{{Name: "new", Tools: []string{"x"}, Action: policy.ActionBlock}}
+	next = bad
+	if _, err := r.Reload(); !errors.Is(err, ErrRejected) {
+		t.Fatalf("Reload = %v, expected ErrRejected", err)
+	}
+	if set := engine.Set(); set.Rules[0].Name != "no-sudo" {
+		t.Errorf("policy swapped by a rejected reload: %+v", set.Rules)
+	}
+	st := r.Status()
+	if st.Failures != 2 || st.Reloads != 0 || st.LastError == "" || st.LastAttempt.IsZero() {
+		t.Errorf("Status = %+v", st)
+	}
+}
+
+func TestReload_PolicyEngineToggle(t *testing.T) {
+	next := startConfig()
+	next.Policy.Rules = nil
+	next.Policy.Allow = []string{"read_*"}
+	var loadErr error
+	r, engine, _ := setup(t, &next, &loadErr)
+
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if !slices.Equal(result.Applied, []string{"policy"}) || !slices.Equal(result.Restart, []string{"policy.rules"}) {
+		t.Errorf("Reload = %+v, expected the allow list applied and the rules pending", result)
+	}
+	if set := engine.Set(); len(set.Rules) != 1 {
+		t.Errorf("policy rules = %+v, expected the running rules kept", set.Rules)
+	}
+}
+
+func TestReload_NewSessionGetsReloadedSettings(t *testing.T) {
+	next := startConfig()
+	next.Policy.Deny = []string{"deploy"}
+	var loadErr error
+	r, _, _ := setup(t, &next, &loadErr)
+	if _, err := r.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// A session created from the startup configuration
+	rt := router.NewWithConfig(fakeTransport{}, sentinel.NewClient(), startConfig().RouterConfig())
+	r.Register(rt)
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "deploy"}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, _ := rt.RouteMessage(data)
+	if resp, err := jsonrpc.Parse(response); err != nil || resp.Error == nil {
+		t.Errorf("tools/call deploy = %s, expected the reloaded deny list to refuse it", response)
+	}
+	r.Unregister(rt.Health().SessionID)
+	if len(r.sessions) != 1 {
+		t.Errorf("sessions = %d, expected 1 after Unregister", len(r.sessions))
+	}
+}
+
+func TestRun(t *testing.T) {
+	next := startConfig()
+	var loadErr error
+	r, _, _ := setup(t, &next, &loadErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	trigger := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, trigger)
+		close(done)
+	}()
+	trigger <- syscall.SIGHUP
+	trigger <- syscall.SIGHUP
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if st := r.Status(); st.Reloads < 1 {
+		t.Errorf("Reloads = %d after two signals", st.Reloads)
+	}
+}
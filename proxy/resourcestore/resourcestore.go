@@ -0,0 +1,249 @@
+// Package resourcestore provides a content-addressed local store for
+// MCP resources/read results.
+//
+// When enabled, the router serves repeated reads of the same URI from
+// the same server out of the store instead of the upstream server, and periodically re-reads
+// the upstream to revalidate. Every stored result is kept on disk under
+// its SHA-256 digest, giving operators offline forensic access to
+// exactly what agents read.
+//
+// # Layout
+//
+//	<dir>/index.json          server and URI → digest and timestamps
+//	<dir>/objects/<sha256>    raw resources/read result JSON
+//
+// # Security Notes
+//
+//   - Objects are verified against their digest on every read; a
+//     mismatch is reported as ErrCorrupt and the entry is dropped
+//   - Entries are keyed by the server as well as the URI, so a store
+//     directory shared by several proxies never answers one server's
+//     read with another server's content
+//   - Objects are never deleted when a URI's content changes, so the
+//     history of what was served is preserved
+package resourcestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRevalidateAfter is how long a stored result is served before
+// the upstream is consulted again.
+const DefaultRevalidateAfter = 5 * time.Minute
+
+// Common errors returned by the store.
+var (
+	ErrNotFound = errors.New("resourcestore: not found")
+	ErrCorrupt  = errors.New("resourcestore: object digest mismatch")
+)
+
+// Entry describes the stored state of a single resource URI.
+type Entry struct {
+	// Server is the name of the server that provided the resource
+	Server string `json:"server"`
+
+	// URI is the resource URI
+	URI string `json:"uri"`
+
+	// Digest is the hex SHA-256 of the stored result
+	Digest string `json:"digest"`
+
+	// FetchedAt is when the current content was first stored
+	FetchedAt time.Time `json:"fetched_at"`
+
+	// ValidatedAt is when the upstream last confirmed the content
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// Store is a content-addressed resource cache backed by a directory.
+//
+// Store is safe for concurrent use.
+type Store struct {
+	dir             string
+	revalidateAfter time.Duration
+	now             func() time.Time
+
+	mu    sync.Mutex
+	index map[string]Entry
+}
+
+// Open opens (or creates) a store rooted at dir.
+//
+// # Arguments
+//   - dir: Directory holding the index and objects
+//   - revalidateAfter: Age after which reads go back to the upstream
+//     (zero uses DefaultRevalidateAfter)
+//
+// # Returns
+//   - Store ready for use
+//   - Error if the directory or index cannot be read
+func Open(dir string, revalidateAfter time.Duration) (*Store, error) {
+	if revalidateAfter <= 0 {
+		revalidateAfter = DefaultRevalidateAfter
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0o700); err != nil {
+		return nil, fmt.Errorf("resourcestore: create dir: %w", err)
+	}
+
+	s := &Store{
+		dir:             dir,
+		revalidateAfter: revalidateAfter,
+		now:             time.Now,
+		index:           make(map[string]Entry),
+	}
+
+	data, err := os.ReadFile(s.indexPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("resourcestore: read index: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.index); err != nil {
+			return nil, fmt.Errorf("resourcestore: parse index: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Lookup returns the stored result for uri as read from server.
+//
+// # Returns
+//   - Stored result bytes
+//   - fresh: true if the entry is younger than the revalidation interval
+//   - Error: ErrNotFound if absent, ErrCorrupt if verification fails
+func (s *Store) Lookup(server, uri string) (result []byte, fresh bool, err error) {
+	s.mu.Lock()
+	entry, ok := s.index[key(server, uri)]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+
+	data, err := s.Object(entry.Digest)
+	if err != nil {
+		if errors.Is(err, ErrCorrupt) || errors.Is(err, ErrNotFound) {
+			s.mu.Lock()
+			delete(s.index, key(server, uri))
+			s.mu.Unlock()
+		}
+		return nil, false, err
+	}
+
+	fresh = s.now().Sub(entry.ValidatedAt) < s.revalidateAfter
+	return data, fresh, nil
+}
+
+// Put stores result as the current content for uri as read from server.
+//
+// If the content matches the stored digest only the validation time is
+// refreshed. Returns whether the content changed compared to the
+// previous entry (false for a first store).
+func (s *Store) Put(server, uri string, result []byte) (changed bool, err error) {
+	sum := sha256.Sum256(result)
+	digest := hex.EncodeToString(sum[:])
+
+	path := s.objectPath(digest)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := writeFileAtomic(path, result); err != nil {
+			return false, fmt.Errorf("resourcestore: write object: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	k := key(server, uri)
+	prev, existed := s.index[k]
+	entry := Entry{Server: server, URI: uri, Digest: digest, FetchedAt: now, ValidatedAt: now}
+	if existed && prev.Digest == digest {
+		entry.FetchedAt = prev.FetchedAt
+	}
+	s.index[k] = entry
+
+	if err := s.saveIndexLocked(); err != nil {
+		return false, err
+	}
+	return existed && prev.Digest != digest, nil
+}
+
+// Object reads and verifies a stored object by digest.
+func (s *Store) Object(digest string) ([]byte, error) {
+	data, err := os.ReadFile(s.objectPath(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resourcestore: read object: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != digest {
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// Entries returns a snapshot of the index.
+func (s *Store) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.index))
+	for _, e := range s.index {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// key is the index key of uri as read from server. The quoted server
+// name ends at its closing quote, so no server and URI pair can collide
+// with another.
+func key(server, uri string) string {
+	return strconv.Quote(server) + uri
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.dir, "index.json")
+}
+
+func (s *Store) objectPath(digest string) string {
+	return filepath.Join(s.dir, "objects", digest)
+}
+
+// saveIndexLocked persists the index. Caller must hold s.mu.
+func (s *Store) saveIndexLocked() error {
+	data, err := json.MarshalIndent(s.index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("resourcestore: encode index: %w", err)
+	}
+	if err := writeFileAtomic(s.indexPath(), data); err != nil {
+		return fmt.Errorf("resourcestore: write index: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,106 @@
+package resourcestore
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStore_PutLookup(t *testing.T) {
+	s, err := Open(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, _, err := s.Lookup("fs", "file:///a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	changed, err := s.Put("fs", "file:///a", []byte(`{"contents":[]}`))
+	if err != nil || changed {
+		t.Fatalf("first Put: changed=%v err=%v", changed, err)
+	}
+
+	data, fresh, err := s.Lookup("fs", "file:///a")
+	if err != nil || !fresh || string(data) != `{"contents":[]}` {
+		t.Fatalf("Lookup: data=%s fresh=%v err=%v", data, fresh, err)
+	}
+
+	changed, err = s.Put("fs", "file:///a", []byte(`{"contents":[1]}`))
+	if err != nil || !changed {
+		t.Fatalf("second Put should report change: changed=%v err=%v", changed, err)
+	}
+}
+
+func TestStore_KeyedByServer(t *testing.T) {
+	s, err := Open(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, err := s.Put("fs", "file:///a", []byte(`{"contents":["fs"]}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, _, err := s.Lookup("other", "file:///a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("another server's entry must not be served, got %v", err)
+	}
+
+	changed, err := s.Put("other", "file:///a", []byte(`{"contents":["other"]}`))
+	if err != nil || changed {
+		t.Fatalf("first Put for other server: changed=%v err=%v", changed, err)
+	}
+	data, _, err := s.Lookup("fs", "file:///a")
+	if err != nil || string(data) != `{"contents":["fs"]}` {
+		t.Errorf("Lookup fs: data=%s err=%v", data, err)
+	}
+	if len(s.Entries()) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(s.Entries()))
+	}
+}
+
+func TestStore_Staleness(t *testing.T) {
+	s, err := Open(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Put("fs", "file:///a", []byte(`{}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, fresh, _ := s.Lookup("fs", "file:///a"); fresh {
+		t.Error("entry should be stale after revalidation interval")
+	}
+}
+
+func TestStore_Corruption(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := s.Put("fs", "file:///a", []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	entry := s.Entries()[0]
+	if err := os.WriteFile(s.objectPath(entry.Digest), []byte(`{"ok":false}`), 0o600); err != nil {
+		t.Fatalf("tamper failed: %v", err)
+	}
+
+	if _, _, err := s.Lookup("fs", "file:///a"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+
+	// Reopening preserves the index
+	s2, err := Open(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if len(s2.Entries()) != 1 {
+		t.Errorf("expected 1 persisted entry, got %d", len(s2.Entries()))
+	}
+}
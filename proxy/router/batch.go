@@ -0,0 +1,120 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// DefaultMaxBatchSize is the most messages a client batch may hold when
+// Config.MaxBatchSize is zero.
+const DefaultMaxBatchSize = 64
+
+// routeBatch routes each message of a JSON-RPC batch as if it had been
+// sent on its own, in order, and returns the responses as one batch.
+// A batch of notifications and client responses has no response.
+//
+// The server sees the messages individually, so it needs no batch
+// support of its own.
+//
+// # Security Notes
+//
+// Every element passes the same checks, rate limits, and gas budget as
+// a lone message and gets its own decision and audit record; batching
+// never bypasses or amortizes a check. Batches larger than
+// MaxBatchSize are refused whole, so one message cannot fan out into
+// unbounded upstream work. Batches do not nest: an element that is an
+// array, or anything else but an object, is answered as an invalid
+// request without being routed, as is an object that is not a valid
+// message.
+func (r *Router) routeBatch(ctx context.Context, data []byte) ([]byte, error) {
+	elems, err := jsonrpc.ParseBatch(data)
+	switch {
+	case errors.Is(err, jsonrpc.ErrEmptyBatch):
+		return r.refuseBatch(jsonrpc.InvalidRequest, "Invalid request", "empty batch")
+	case err != nil:
+		return r.refuseBatch(jsonrpc.ParseError, "Parse error", err.Error())
+	case len(elems) > r.maxBatchSize:
+		return r.refuseBatch(jsonrpc.InvalidRequest, "Invalid request",
+			fmt.Sprintf("batch of %d messages exceeds the limit of %d", len(elems), r.maxBatchSize))
+	}
+
+	ctx, span := r.tracer.Start(ctx, "batch")
+	span.SetAttribute("rpc.batch_size", len(elems))
+	defer span.End()
+
+	var responses [][]byte
+	var firstErr error
+	for i, e := range elems {
+		if e.Err != nil {
+			// Never routed, so a nested batch cannot multiply the limit;
+			// the batch parsed, so no element is a parse error
+			reason := fmt.Sprintf("batch element %d is not a JSON-RPC object", i)
+			if !errors.Is(e.Err, jsonrpc.ErrNotObject) {
+				reason = fmt.Sprintf("batch element %d: %v", i, e.Err)
+			}
+			response, _ := r.refuseBatchMessage(reason)
+			responses = append(responses, response)
+			continue
+		}
+		if e.Message.Type() == jsonrpc.TypeResponse && r.upstream != nil {
+			r.relayClientResponse(e.Message, e.Raw)
+			continue
+		}
+		response, err := r.routeMessage(ctx, e.Raw)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if response == nil {
+			continue
+		}
+		if !json.Valid(response) {
+			// One unparseable server response must not void the batch
+			r.captureRepro(crash.ReproParse, e.Message.Method, response, errors.New("invalid JSON in batched response"))
+			response, _ = r.refuseBatchElement(e.Message.ID)
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) == 0 {
+		return nil, firstErr
+	}
+	return append([]byte{'['}, append(bytes.Join(responses, []byte{','}), ']')...), firstErr
+}
+
+// refuseBatch answers a batch that cannot be routed with one error,
+// recorded as a decision of its own.
+func (r *Router) refuseBatch(code int, message, reason string) ([]byte, error) {
+	r.stats.MessagesReceived.Add(1)
+	r.stats.Errors.Add(1)
+	d := r.newDecision()
+	defer r.finish(d)
+	log.Printf("router: session %s: refused batch: %s", r.sessionID, reason)
+	return r.errorResponse(d, VerdictError, jsonrpc.NullID(), code, message, reason)
+}
+
+// refuseBatchMessage answers one element of a batch that cannot be
+// routed as Invalid Request, recorded as a decision of its own.
+func (r *Router) refuseBatchMessage(reason string) ([]byte, error) {
+	r.stats.MessagesReceived.Add(1)
+	r.stats.Errors.Add(1)
+	d := r.newDecision()
+	defer r.finish(d)
+	return r.errorResponse(d, VerdictError, jsonrpc.NullID(), jsonrpc.InvalidRequest, "Invalid request", reason)
+}
+
+// refuseBatchElement answers a batched request whose response could not
+// be included in the batch.
+func (r *Router) refuseBatchElement(id json.RawMessage) ([]byte, error) {
+	data := &ErrorData{Reason: "the server response is not valid JSON", Failure: FailureUpstream, Retry: RetryBackoff}
+	resp, err := jsonrpc.NewErrorResponse(id, CodeUpstreamFailed, "Invalid server response", data)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(resp)
+}
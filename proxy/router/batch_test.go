@@ -0,0 +1,131 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_Batch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.MaxBatchSize = 4
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	var forwarded []string
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		forwarded = append(forwarded, msg.Method)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]string{"echo": msg.Method})
+		return jsonrpc.Serialize(resp)
+	}
+	r.notifyFunc = func(data []byte) error {
+		forwarded = append(forwarded, "notification")
+		return nil
+	}
+
+	response, err := r.RouteMessage([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"summarize","arguments":{}}},
+		{"jsonrpc":"2.0","method":"notifications/progress","params":{}},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"shell","arguments":{}}},
+		42
+	]`))
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	elems, err := jsonrpc.ParseBatch(response)
+	if err != nil || len(elems) != 3 {
+		t.Fatalf("response %s: %d elements, %v", response, len(elems), err)
+	}
+	if string(elems[0].Message.ID) != "1" || elems[0].Message.Error != nil {
+		t.Errorf("allowed call answered %s", elems[0].Raw)
+	}
+	if string(elems[1].Message.ID) != "2" || elems[1].Message.Error == nil || !strings.Contains(string(elems[1].Raw), "denied") {
+		t.Errorf("denied call answered %s", elems[1].Raw)
+	}
+	if string(elems[2].Message.ID) != "null" || elems[2].Message.Error.Code != jsonrpc.InvalidRequest {
+		t.Errorf("invalid element answered %s", elems[2].Raw)
+	}
+	if strings.Join(forwarded, ",") != "tools/call,notification" {
+		t.Errorf("forwarded %v, expected the allowed call and the notification", forwarded)
+	}
+	if s := r.Stats(); s.MessagesReceived != 4 || s.MessagesBlocked != 1 {
+		t.Errorf("stats = %+v, expected 4 received and 1 blocked", s)
+	}
+
+	// A batch of notifications has no response
+	if response, err := r.RouteMessage([]byte(`[{"jsonrpc":"2.0","method":"notifications/progress"}]`)); response != nil || err != nil {
+		t.Errorf("notification batch answered %s, %v", response, err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		code int
+	}{
+		{"empty", `[]`, jsonrpc.InvalidRequest},
+		{"too large", `[` + strings.TrimSuffix(strings.Repeat(`{"jsonrpc":"2.0","method":"ping","id":1},`, 5), ",") + `]`, jsonrpc.InvalidRequest},
+		{"malformed", `[{"jsonrpc":"2.0"`, jsonrpc.ParseError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := r.RouteMessage([]byte(tt.data))
+			var msg jsonrpc.Message
+			if err := json.Unmarshal(response, &msg); err != nil || msg.Error == nil || msg.Error.Code != tt.code {
+				t.Errorf("response %s, expected a single error %d", response, tt.code)
+			}
+		})
+	}
+}
+
+func TestRouteMessage_NestedBatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxBatchSize = 2
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	forwarded := 0
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwarded++
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]string{})
+		return jsonrpc.Serialize(resp)
+	}
+
+	ping := `{"jsonrpc":"2.0","method":"ping","id":1}`
+	tests := []struct {
+		name  string
+		data  string
+		codes []int
+	}{
+		{"nested batches", `[[` + ping + `,` + ping + `],[` + ping + `,` + ping + `]]`, []int{jsonrpc.InvalidRequest, jsonrpc.InvalidRequest}},
+		{"nested beside a message", `[` + ping + `,[` + ping + `]]`, []int{0, jsonrpc.InvalidRequest}},
+		{"scalars", `[null,"ping"]`, []int{jsonrpc.InvalidRequest, jsonrpc.InvalidRequest}},
+		{"invalid objects", `[{"jsonrpc":"2.0","id":1,"method":5},{"jsonrpc":"1.0","id":2,"method":"ping"}]`, []int{jsonrpc.InvalidRequest, jsonrpc.InvalidRequest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = 0
+			response, _ := r.RouteMessage([]byte(tt.data))
+			var answers []jsonrpc.Message
+			if err := json.Unmarshal(response, &answers); err != nil || len(answers) != len(tt.codes) {
+				t.Fatalf("response %s, expected a flat batch of %d answers", response, len(tt.codes))
+			}
+			expectedForwards := 0
+			for i, code := range tt.codes {
+				switch {
+				case code == 0:
+					expectedForwards++
+					if answers[i].Error != nil {
+						t.Errorf("answer %d = %+v, expected a result", i, answers[i])
+					}
+				case answers[i].Error == nil || answers[i].Error.Code != code || string(answers[i].ID) != "null":
+					t.Errorf("answer %d = %+v, expected error %d with a null id", i, answers[i], code)
+				}
+			}
+			if forwarded != expectedForwards {
+				t.Errorf("forwarded %d messages, expected %d", forwarded, expectedForwards)
+			}
+		})
+	}
+}
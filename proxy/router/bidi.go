@@ -0,0 +1,268 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// NewWithTransports creates a Router between a client and a server.
+//
+// Client requests and notifications are checked and forwarded to the
+// server. Server responses are matched to the waiting client request by
+// ID; server-initiated requests and notifications are relayed to the
+// client, and the client's responses to them are relayed back.
+// Responses in either direction that answer no outstanding request are
+// dropped (see pendingTable). Client
+// requests are routed concurrently, so a server can issue requests of
+// its own (sampling, elicitation) while a tool call is pending.
+//
+// # Arguments
+//   - client: Transport facing the MCP client
+//   - server: Transport to the MCP server
+//   - s: Sentinel client for security checks
+//   - cfg: Router configuration (nil uses DefaultConfig)
+func NewWithTransports(client, server transport.Transport, s *sentinel.Client, cfg *Config) *Router {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	r := NewWithConfig(client, s, cfg)
+	r.upstream = server
+	r.notifyFunc = server.Send
+	r.pending = newPendingTable()
+	r.relayed = newPendingTable()
+	r.turns = make(map[string]*sendTurn)
+	return r
+}
+
+// sendTurn orders writes to the server. Client messages are checked
+// concurrently, but each is written only after every earlier message
+// was written or abandoned, so the server sees them in arrival order.
+type sendTurn struct {
+	prev <-chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// release lets the next message be written. Safe to call repeatedly.
+func (t *sendTurn) release() {
+	t.once.Do(func() { close(t.done) })
+}
+
+// takeTurn removes and returns the send turn of request id, if any.
+func (r *Router) takeTurn(id string) *sendTurn {
+	r.turnsMu.Lock()
+	defer r.turnsMu.Unlock()
+	t := r.turns[id]
+	delete(r.turns, id)
+	return t
+}
+
+// serverLoop reads server messages until the server transport fails:
+// responses go to the waiting client request, everything else is
+// relayed to the client.
+func (r *Router) serverLoop() {
+	for {
+		data, err := r.upstream.Receive()
+		if err != nil {
+			if errors.Is(err, transport.ErrServerExited) {
+				// Requests in flight are lost; the restarted server takes new ones
+				log.Printf("router: session %s: %v", r.sessionID, err)
+				r.pending.fail(err, false)
+				continue
+			}
+			err = fmt.Errorf("router: server receive failed: %w", err)
+			r.pending.fail(err, true)
+			r.serverErr <- err
+			return
+		}
+		r.stats.FromServer.Add(1)
+
+		msg, err := jsonrpc.Parse(data)
+		if err != nil {
+			log.Printf("router: session %s: dropped malformed server message: %v", r.sessionID, err)
+			r.captureRepro(crash.ReproParse, "", data, err)
+			continue
+		}
+		if msg.Type() == jsonrpc.TypeResponse {
+			r.acceptServerResponse(msg, data)
+			continue
+		}
+
+		// Server-initiated request or notification
+		if r.partials != nil && msg.Method == "notifications/progress" {
+			r.partials.add(msg.Params)
+		}
+		if msg.Type() == jsonrpc.TypeNotification {
+			if data = r.screenNotification(msg, data); data == nil {
+				continue
+			}
+		}
+		if msg.Type() == jsonrpc.TypeRequest {
+			if err := r.relayed.track(string(msg.ID), msg.Method, r.requestTimeout); err != nil {
+				log.Printf("router: session %s: server reused request id %s", r.sessionID, msg.ID)
+			}
+			r.calls.serverRequest(string(msg.ID))
+			switch {
+			case msg.Method == "sampling/createMessage" && r.sampling != nil:
+				// Council votes and approvals must not hold up responses
+				go r.relaySampling(msg, data)
+				continue
+			case msg.Method == "elicitation/create" && r.elicitation != nil:
+				if data = r.screenElicitation(msg, data); data == nil {
+					continue
+				}
+			case msg.Method == "roots/list" && r.roots != nil:
+				if !r.screenRoots(msg) {
+					continue
+				}
+			}
+		}
+		r.relayToClient(msg, data)
+	}
+}
+
+// relayToClient relays a server request or notification to the client.
+func (r *Router) relayToClient(msg *jsonrpc.Message, data []byte) {
+	data, err := r.relayServerMessage(msg, data)
+	if data == nil {
+		log.Printf("router: session %s: middleware dropped server %s: %v", r.sessionID, msg.Method, err)
+		return
+	}
+	r.stats.RelayedToClient.Add(1)
+	r.stats.BytesToClient.Add(uint64(len(data)))
+	r.auditRelay(audit.ServerToClient, msg)
+	if err := r.transport.Send(data); err != nil {
+		log.Printf("router: session %s: relay to client failed: %v", r.sessionID, err)
+	}
+}
+
+// relayClientResponse relays the client's answer to a server-initiated
+// request to the server.
+func (r *Router) relayClientResponse(msg *jsonrpc.Message, data []byte) {
+	r.calls.answered(string(msg.ID))
+	method := r.relayed.method(string(msg.ID))
+	if !r.acceptClientResponse(msg) {
+		return
+	}
+	switch {
+	case method == "elicitation/create" && r.elicitation != nil:
+		data = r.filterElicitResult(msg, data)
+	case method == "roots/list" && r.roots != nil:
+		data = r.filterRoots(msg, data)
+	}
+	r.stats.RelayedToServer.Add(1)
+	r.stats.BytesFromClient.Add(uint64(len(data)))
+	if method == "sampling/createMessage" && r.sampling != nil {
+		r.samplingAnswered(msg)
+	} else {
+		r.auditRelay(audit.ClientToServer, msg)
+	}
+	if err := r.upstream.Send(data); err != nil {
+		log.Printf("router: session %s: relay to server failed: %v", r.sessionID, err)
+	}
+}
+
+// runBidirectional serves a client and a server concurrently until the
+// client disconnects, the server fails, or ctx ends.
+func (r *Router) runBidirectional(ctx context.Context) error {
+	r.serverOnce.Do(func() { go r.serverLoop() })
+	sweepDone := make(chan struct{})
+	defer close(sweepDone)
+	go r.sweepLoop(sweepDone)
+
+	var inflight sync.WaitGroup
+
+	clientErr := make(chan error, 1)
+	go func() {
+		prev := make(chan struct{})
+		close(prev)
+		for {
+			data, err := r.transport.Receive()
+			if err != nil {
+				clientErr <- fmt.Errorf("router: receive failed: %w", err)
+				return
+			}
+
+			msg, err := jsonrpc.Parse(data)
+			typ := jsonrpc.TypeUnknown
+			if err == nil {
+				typ = msg.Type()
+			}
+
+			// The client answering a server-initiated request
+			if typ == jsonrpc.TypeResponse {
+				r.relayClientResponse(msg, data)
+				continue
+			}
+
+			turn := &sendTurn{prev: prev, done: make(chan struct{})}
+			prev = turn.done
+
+			// Notifications are quick to route and must not overtake
+			// the requests before them (initialize, then initialized)
+			if typ == jsonrpc.TypeNotification {
+				<-turn.prev
+				if _, err := r.RouteMessageContext(ctx, data); err != nil {
+					log.Printf("router: session %s: %v", r.sessionID, err)
+				}
+				turn.release()
+				continue
+			}
+			if typ == jsonrpc.TypeRequest {
+				r.turnsMu.Lock()
+				if _, dup := r.turns[string(msg.ID)]; !dup {
+					r.turns[string(msg.ID)] = turn
+				}
+				r.turnsMu.Unlock()
+			}
+
+			inflight.Add(1)
+			go func() {
+				defer inflight.Done()
+				// Requests that are never written (blocked, malformed)
+				// give up their turn when routing ends
+				defer turn.release()
+				if typ == jsonrpc.TypeRequest {
+					defer r.takeTurn(string(msg.ID))
+				}
+				response, err := r.RouteMessageContext(ctx, data)
+				if err != nil {
+					log.Printf("router: session %s: %v", r.sessionID, err)
+				}
+				if response == nil {
+					return
+				}
+				if err := r.send(response); err != nil {
+					log.Printf("router: session %s: send failed: %v", r.sessionID, err)
+				}
+			}()
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-clientErr:
+		// The client may close its end right after writing; let requests
+		// already forwarded finish before ending the session
+		r.endPause(true)
+		inflight.Wait()
+		return err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-r.serverErr:
+	}
+	// Release routes still waiting on the server, then let them answer
+	r.endPause(true)
+	r.pending.fail(errors.New("router: session ended"), true)
+	inflight.Wait()
+	return err
+}
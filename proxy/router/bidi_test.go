@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// chanTransport is one end of an in-memory message pipe.
+type chanTransport struct {
+	in  chan []byte
+	out chan []byte
+}
+
+// newPipe returns two connected transports.
+func newPipe() (*chanTransport, *chanTransport) {
+	a, b := make(chan []byte, 16), make(chan []byte, 16)
+	return &chanTransport{in: a, out: b}, &chanTransport{in: b, out: a}
+}
+
+func (c *chanTransport) Send(data []byte) error {
+	c.out <- append([]byte(nil), data...)
+	return nil
+}
+
+func (c *chanTransport) Receive() ([]byte, error) {
+	data, ok := <-c.in
+	if !ok {
+		return nil, errors.New("closed")
+	}
+	return data, nil
+}
+
+func (c *chanTransport) Close() error {
+	close(c.out)
+	return nil
+}
+
+func expectMessage(t *testing.T, tr *chanTransport, contains string) {
+	t.Helper()
+	select {
+	case data := <-tr.in:
+		if !strings.Contains(string(data), contains) {
+			t.Fatalf("expected message containing %s, got %s", contains, data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", contains)
+	}
+}
+
+// nextMessage returns the next message tr receives.
+func nextMessage(t *testing.T, tr *chanTransport) []byte {
+	t.Helper()
+	select {
+	case data := <-tr.in:
+		return data
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func TestRunBidirectional_ServerRequestDuringToolCall(t *testing.T) {
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	// The tool call is forwarded; while it is pending the server asks
+	// the client for a sampling result, which must flow both ways
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"summarize","arguments":{}}}`))
+	expectMessage(t, server, `"method":"tools/call"`)
+
+	server.Send([]byte(`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{}}`))
+	expectMessage(t, client, `"method":"sampling/createMessage"`)
+
+	client.Send([]byte(`{"jsonrpc":"2.0","id":"s1","result":{"content":{"type":"text","text":"hi"}}}`))
+	expectMessage(t, server, `"id":"s1"`)
+
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+	expectMessage(t, client, `notifications/progress`)
+
+	server.Send([]byte(`{"jsonrpc":"2.0","id":99,"result":{}}`))
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`))
+	expectMessage(t, client, `"id":1`)
+
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after client disconnect")
+	}
+
+	tests := []struct {
+		name     string
+		got      uint64
+		expected uint64
+	}{
+		{"client requests received", r.stats.MessagesReceived.Load(), 1},
+		{"forwarded to server", r.stats.MessagesForwarded.Load(), 1},
+		{"from server", r.stats.FromServer.Load(), 4},
+		{"relayed to client", r.stats.RelayedToClient.Load(), 2},
+		{"relayed to server", r.stats.RelayedToServer.Load(), 1},
+		{"unmatched responses", r.stats.UnmatchedResponses.Load(), 1},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s = %d, expected %d", tt.name, tt.got, tt.expected)
+		}
+	}
+}
+
+func TestRunBidirectional_PreservesClientOrder(t *testing.T) {
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// Requests are routed concurrently, but the server must still see
+	// initialize before the initialized notification that follows it
+	sequence := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"read_file","arguments":{}}}`,
+	}
+	for _, msg := range sequence {
+		client.Send([]byte(msg))
+	}
+	for _, method := range []string{"initialize", "notifications/initialized", "tools/list", "tools/call"} {
+		expectMessage(t, server, `"method":"`+method+`"`)
+	}
+}
+
+func TestPendingTable(t *testing.T) {
+	p := newPendingTable()
+	ch, err := p.add("1", "tools/call")
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if _, err := p.add("1", "tools/call"); !errors.Is(err, ErrDuplicateRequestID) {
+		t.Errorf("expected ErrDuplicateRequestID, got %v", err)
+	}
+	if !p.deliver("1", []byte("ok")) || string(<-ch) != "ok" {
+		t.Error("response not delivered")
+	}
+	if p.deliver("1", nil) {
+		t.Error("response delivered twice")
+	}
+
+	ch, _ = p.add("2", "tools/call")
+	p.fail(errors.New("gone"), true)
+	if _, ok := <-ch; ok {
+		t.Error("pending request not released on failure")
+	}
+	if _, err := p.add("3", "tools/call"); err == nil {
+		t.Error("expected permanent failure to reject new requests")
+	}
+}
@@ -0,0 +1,246 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+)
+
+// CodeGasExhausted is the JSON-RPC error code returned for tool calls
+// refused because the session's gas budget cannot cover them.
+const CodeGasExhausted = -32008
+
+// CodeCallDepthExceeded is the JSON-RPC error code returned for tool
+// calls nested deeper than Config.MaxCallDepth.
+const CodeCallDepthExceeded = -32009
+
+// NotifyGasBudget is the notification sent to the client when the
+// session's gas budget runs low and when it is exhausted.
+const NotifyGasBudget = "notifications/sentinel/gas_budget"
+
+// gasLowPercent is the share of the budget used that triggers the low
+// budget notice.
+const gasLowPercent = 80
+
+// Gas budget notice levels, in the order they are reached.
+const (
+	gasNoticeNone int32 = iota
+	gasNoticeLow
+	gasNoticeExhausted
+)
+
+// GasStatus is the session's gas budget and what is left of it.
+type GasStatus struct {
+	// Budget is the session's gas budget (zero is unlimited)
+	Budget    uint64 `json:"budget"`
+	Used      uint64 `json:"used"`
+	Remaining uint64 `json:"remaining"`
+	Exhausted bool   `json:"exhausted"`
+}
+
+// Gas returns the session's gas budget and consumption.
+func (r *Router) Gas() GasStatus {
+	s := GasStatus{Budget: r.gasBudget, Used: r.gasUsed.Load()}
+	if s.Budget > 0 {
+		if s.Used < s.Budget {
+			s.Remaining = s.Budget - s.Used
+		}
+		s.Exhausted = s.Remaining == 0
+	}
+	return s
+}
+
+// Usage returns the session's cumulative consumption, for a
+// usage.Exporter.
+func (r *Router) Usage() usage.Counters {
+	return usage.Counters{
+		Calls:       r.stats.ToolCalls.Load(),
+		Messages:    r.stats.MessagesReceived.Load(),
+		Blocked:     r.stats.MessagesBlocked.Load(),
+		RateLimited: r.stats.RateLimited.Load(),
+		Gas:         r.gasUsed.Load(),
+		BytesIn:     r.stats.BytesFromClient.Load(),
+		BytesOut:    r.stats.BytesToClient.Load(),
+	}
+}
+
+// callStack correlates nested tool calls. A server request relayed to
+// the client while tool calls are in flight, such as a sampling
+// request, is attributed to the deepest of them; a tool call the client
+// makes while such a request awaits its answer is nested one level
+// below it.
+//
+// # Security Notes
+//
+// MCP carries no parent ID for nested calls, so parallel calls sharing
+// the session are not told apart: attribution to the deepest call errs
+// towards counting a call as more deeply nested, not less.
+type callStack struct {
+	mu sync.Mutex
+	// calls maps in-flight tool calls, by decision ID, to their depth
+	calls map[string]int
+	// relayed maps server requests awaiting the client to the tool call
+	// they were attributed to
+	relayed map[string]relayedCall
+}
+
+// relayedCall is the tool call a server request was attributed to.
+type relayedCall struct {
+	decision string
+	depth    int
+}
+
+// enter records a tool call as in flight and returns its depth.
+func (s *callStack) enter(decision string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	depth := 1
+	for _, parent := range s.relayed {
+		depth = max(depth, parent.depth+1)
+	}
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[decision] = depth
+	return depth
+}
+
+// leave records a tool call as answered; server requests attributed to
+// it can no longer start nested calls.
+func (s *callStack) leave(decision string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.calls, decision)
+	for id, parent := range s.relayed {
+		if parent.decision == decision {
+			delete(s.relayed, id)
+		}
+	}
+}
+
+// serverRequest attributes a server request to the deepest tool call in
+// flight, if any.
+func (s *callStack) serverRequest(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var parent relayedCall
+	for decision, depth := range s.calls {
+		if depth > parent.depth {
+			parent = relayedCall{decision: decision, depth: depth}
+		}
+	}
+	if parent.depth == 0 {
+		return
+	}
+	if s.relayed == nil {
+		s.relayed = make(map[string]relayedCall)
+	}
+	s.relayed[id] = parent
+}
+
+// answered forgets a server request the client has answered.
+func (s *callStack) answered(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.relayed, id)
+}
+
+// depth returns the deepest tool call in flight (zero if none).
+func (s *callStack) depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deepest := 0
+	for _, depth := range s.calls {
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
+
+// checkBudget enforces the call depth and gas limits on a tool call.
+// The call stays on the call stack until d is finished.
+//
+// # Returns
+//   - An error response for the client if the call is refused
+//   - Whether the call was refused
+//
+// # Security Notes
+//
+// The gas check prices the call before it is charged, so calls checked
+// concurrently can together overshoot the budget by their own costs, as
+// can result-size top-ups; the calls after them are refused.
+func (r *Router) checkBudget(d *Decision, msg *jsonrpc.Message) ([]byte, bool) {
+	d.depth = r.calls.enter(d.ID)
+	if d.depth > 1 {
+		d.Details = withDetailMap(d.Details, "call_depth", d.depth)
+	}
+	if r.maxCallDepth > 0 && d.depth > r.maxCallDepth {
+		r.stats.MessagesBlocked.Add(1)
+		r.stats.CallDepthExceeded.Add(1)
+		reason := fmt.Sprintf("tool call nested %d deep exceeds the maximum call depth of %d", d.depth, r.maxCallDepth)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, CodeCallDepthExceeded, "Call depth exceeded", reason)
+		return reply, true
+	}
+
+	if r.gasBudget == 0 {
+		return nil, false
+	}
+	if _, charged := d.upstreamCharged(); charged {
+		// The sentinel that charges the call enforces its budget
+		return nil, false
+	}
+	cost := r.currentGasModel().Cost(d.Tool, msg.Params, 0)
+	status := r.Gas()
+	if cost <= status.Remaining && !status.Exhausted {
+		return nil, false
+	}
+	r.stats.MessagesBlocked.Add(1)
+	r.stats.GasExhausted.Add(1)
+	r.noteGas(true)
+	reason := fmt.Sprintf("tool call costs %d gas but %d of the session's %d remain", cost, status.Remaining, status.Budget)
+	reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, CodeGasExhausted, "Gas budget exhausted", reason)
+	return reply, true
+}
+
+// noteGas tells the client once when the gas budget runs low and once
+// when it is exhausted, or a call was refused for want of gas.
+func (r *Router) noteGas(refused bool) {
+	status := r.Gas()
+	if status.Budget == 0 {
+		return
+	}
+	level := gasNoticeNone
+	switch {
+	case status.Exhausted || refused:
+		level = gasNoticeExhausted
+	case float64(status.Used) >= float64(status.Budget)*gasLowPercent/100:
+		level = gasNoticeLow
+	}
+	for {
+		prev := r.gasNotice.Load()
+		if level <= prev {
+			return
+		}
+		if r.gasNotice.CompareAndSwap(prev, level) {
+			break
+		}
+	}
+
+	log.Printf("router: session %s: gas budget %d used of %d", r.sessionID, status.Used, status.Budget)
+	if r.upstream == nil {
+		// The transport is the server connection
+		return
+	}
+	params := map[string]interface{}{
+		"session_id": r.sessionID,
+		"budget":     status.Budget,
+		"used":       status.Used,
+		"remaining":  status.Remaining,
+		"exhausted":  level == gasNoticeExhausted,
+	}
+	if err := r.notify(NotifyGasBudget, params); err != nil {
+		log.Printf("router: session %s: failed to send gas budget notice: %v", r.sessionID, err)
+	}
+}
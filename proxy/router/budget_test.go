@@ -0,0 +1,190 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestGasBudget_Enforced(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.GasBudget = 250
+	cfg.GasModel = GasModelFunc(func(string, json.RawMessage, int) uint64 { return 100 })
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	forwarded := 0
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwarded++
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	for i, wantCode := range []int{0, 0, CodeGasExhausted, CodeGasExhausted} {
+		req := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`
+		response, _ := r.RouteMessage([]byte(req))
+		resp, _ := jsonrpc.Parse(response)
+		if code := errorCode(resp); code != wantCode {
+			t.Fatalf("call %d: error code %d (%+v), expected %d", i+1, code, resp.Error, wantCode)
+		}
+	}
+	if forwarded != 2 {
+		t.Errorf("forwarded %d calls, expected 2", forwarded)
+	}
+	want := GasStatus{Budget: 250, Used: 200, Remaining: 50}
+	if got := r.Gas(); got != want {
+		t.Errorf("Gas() = %+v, expected %+v", got, want)
+	}
+	if got := r.Health().Gas; got != want {
+		t.Errorf("Health().Gas = %+v", got)
+	}
+	if r.stats.GasExhausted.Load() != 2 {
+		t.Errorf("GasExhausted = %d, expected 2", r.stats.GasExhausted.Load())
+	}
+}
+
+func TestRouter_Usage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.GasBudget = 150
+	cfg.GasModel = GasModelFunc(func(string, json.RawMessage, int) uint64 { return 100 })
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	var sent uint64
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	req := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`
+	for i := 0; i < 2; i++ {
+		response, _ := r.RouteMessage([]byte(req))
+		sent += uint64(len(response))
+	}
+	batch := `[` + req + `]`
+	response, _ := r.RouteMessage([]byte(batch))
+	sent += uint64(len(response))
+
+	u := r.Usage()
+	want := struct{ calls, messages, blocked, gas, in, out uint64 }{3, 3, 2, 100, uint64(2*len(req) + len(batch)), sent}
+	got := struct{ calls, messages, blocked, gas, in, out uint64 }{u.Calls, u.Messages, u.Blocked, u.Gas, u.BytesIn, u.BytesOut}
+	if got != want {
+		t.Errorf("Usage() = %+v, expected %+v", got, want)
+	}
+}
+
+func TestGasBudget_Unlimited(t *testing.T) {
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), &Config{})
+	r.gasUsed.Store(1 << 40)
+	if got := r.Gas(); got.Exhausted || got.Remaining != 0 {
+		t.Errorf("Gas() = %+v, expected an unlimited budget", got)
+	}
+	req := &jsonrpc.Message{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "tools/call", Params: json.RawMessage(`{"name":"search"}`)}
+	if _, blocked := r.checkBudget(r.newDecision(), req); blocked {
+		t.Error("call refused without a budget")
+	}
+}
+
+func TestGasBudget_Notice(t *testing.T) {
+	client, clientSide := newPipe()
+	_, serverSide := newPipe()
+	cfg := DefaultConfig()
+	cfg.GasBudget = 1000
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+	defer r.EndSession()
+
+	r.gasUsed.Store(500)
+	r.noteGas(false)
+	r.gasUsed.Store(850)
+	r.noteGas(false)
+	expectMessage(t, client, `"exhausted":false`)
+
+	// Each level is announced once
+	r.noteGas(false)
+	r.gasUsed.Store(1000)
+	r.noteGas(false)
+	expectMessage(t, client, `"exhausted":true`)
+	r.noteGas(true)
+	select {
+	case data := <-client.in:
+		t.Errorf("unexpected message %s", data)
+	default:
+	}
+}
+
+func TestCallDepth_Nested(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCallDepth = 2
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	// Each call makes the server ask the client for a sampling, during
+	// which the client calls the tool again
+	var depths []int
+	var codes []int
+	serverRequests := 0
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		depths = append(depths, r.calls.depth())
+		serverRequests++
+		id := string(rune('a' + serverRequests))
+		r.calls.serverRequest(id)
+		nested, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"recurse"}}`))
+		resp, _ := jsonrpc.Parse(nested)
+		codes = append(codes, errorCode(resp))
+		r.calls.answered(id)
+		result, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(result)
+	}
+
+	response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"recurse"}}`))
+	if resp, _ := jsonrpc.Parse(response); resp.Error != nil {
+		t.Fatalf("outer call failed: %+v", resp.Error)
+	}
+	// Depth 1 forwards and nests depth 2, which forwards and nests depth
+	// 3, which is refused
+	if len(depths) != 2 || depths[0] != 1 || depths[1] != 2 {
+		t.Errorf("depths while forwarding = %v, expected [1 2]", depths)
+	}
+	if len(codes) != 2 || codes[0] != CodeCallDepthExceeded || codes[1] != 0 {
+		t.Errorf("nested call codes = %v, expected [%d 0]", codes, CodeCallDepthExceeded)
+	}
+	if r.stats.CallDepthExceeded.Load() != 1 {
+		t.Errorf("CallDepthExceeded = %d, expected 1", r.stats.CallDepthExceeded.Load())
+	}
+	if d := r.calls.depth(); d != 0 {
+		t.Errorf("depth after all calls = %d, expected 0", d)
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	var s callStack
+	if d := s.enter("a"); d != 1 {
+		t.Errorf("first call depth = %d, expected 1", d)
+	}
+	if d := s.enter("b"); d != 1 {
+		t.Errorf("parallel call depth = %d, expected 1", d)
+	}
+	s.serverRequest("sampling-1")
+	if d := s.enter("c"); d != 2 {
+		t.Errorf("nested call depth = %d, expected 2", d)
+	}
+	s.answered("sampling-1")
+	if d := s.enter("d"); d != 1 {
+		t.Errorf("call after the answer depth = %d, expected 1", d)
+	}
+
+	// A request outlives neither its call nor its answer
+	s.serverRequest("sampling-2")
+	s.leave("c")
+	if d := s.enter("e"); d != 1 {
+		t.Errorf("call after the parent left depth = %d, expected 1", d)
+	}
+}
+
+// errorCode returns the error code of a response (zero for success).
+func errorCode(resp *jsonrpc.Message) int {
+	if resp == nil || resp.Error == nil {
+		return 0
+	}
+	return resp.Error.Code
+}
@@ -0,0 +1,127 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// catalogKinds maps the list methods whose results are recorded to
+// their catalog kind.
+var catalogKinds = map[string]string{
+	"tools/list":     catalog.KindTools,
+	"resources/list": catalog.KindResources,
+	"prompts/list":   catalog.KindPrompts,
+}
+
+// maxCatalogPages bounds the pages of one listing collected; a longer
+// listing is not recorded.
+const maxCatalogPages = 100
+
+// catalogPages holds the pages of listings still being paginated, by
+// catalog kind.
+type catalogPages struct {
+	mu      sync.Mutex
+	entries map[string][]json.RawMessage
+	pages   map[string]int
+}
+
+// recordCatalog adds a list response to the catalog history once the
+// listing is complete: a request without a cursor starts a listing,
+// and a result without nextCursor ends it.
+//
+// Tools of a multiplexing transport are recorded per upstream under
+// the server's own names, so each upstream's history is its own.
+func (r *Router) recordCatalog(msg *jsonrpc.Message, response []byte) {
+	kind := catalogKinds[msg.Method]
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || resp.Result == nil {
+		return
+	}
+	var result struct {
+		Tools      []json.RawMessage `json:"tools"`
+		Resources  []json.RawMessage `json:"resources"`
+		Prompts    []json.RawMessage `json:"prompts"`
+		NextCursor string            `json:"nextCursor"`
+	}
+	if json.Unmarshal(resp.Result, &result) != nil {
+		return
+	}
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(msg.Params, &params)
+	page := map[string][]json.RawMessage{
+		catalog.KindTools:     result.Tools,
+		catalog.KindResources: result.Resources,
+		catalog.KindPrompts:   result.Prompts,
+	}[kind]
+
+	p := &r.catalogPages
+	p.mu.Lock()
+	if p.entries == nil {
+		p.entries, p.pages = make(map[string][]json.RawMessage), make(map[string]int)
+	}
+	if params.Cursor == "" {
+		p.entries[kind], p.pages[kind] = nil, 0
+	} else if p.pages[kind] == 0 {
+		// A continuation of a listing not seen from its start
+		p.mu.Unlock()
+		return
+	}
+	p.entries[kind] = append(p.entries[kind], page...)
+	p.pages[kind]++
+	entries, pages := p.entries[kind], p.pages[kind]
+	if result.NextCursor == "" || pages >= maxCatalogPages {
+		delete(p.entries, kind)
+		delete(p.pages, kind)
+	}
+	p.mu.Unlock()
+	if result.NextCursor != "" {
+		return
+	}
+
+	for server, list := range r.catalogServers(kind, entries) {
+		snap, err := r.catalog.Record(server, kind, list)
+		if err != nil {
+			log.Printf("router: session %s: catalog history: %v", r.sessionID, err)
+		}
+		if snap != nil {
+			log.Printf("router: session %s: %s %s catalog changed (snapshot %d)", r.sessionID, server, kind, snap.ID)
+		}
+	}
+}
+
+// catalogServers splits a complete listing by the server providing
+// each entry.
+func (r *Router) catalogServers(kind string, entries []json.RawMessage) map[string][]json.RawMessage {
+	r.server.mu.Lock()
+	server := r.server.name
+	r.server.mu.Unlock()
+	if server == "" {
+		server = unnamedServer
+	}
+	if kind != catalog.KindTools || r.upstreamTools == nil {
+		return map[string][]json.RawMessage{server: entries}
+	}
+
+	out := make(map[string][]json.RawMessage)
+	for _, entry := range entries {
+		var tool map[string]json.RawMessage
+		if json.Unmarshal(entry, &tool) != nil {
+			continue
+		}
+		upstream, name, ok := r.upstreamTools.Resolve(catalog.EntryKey(kind, entry))
+		if !ok {
+			out[server] = append(out[server], entry)
+			continue
+		}
+		tool["name"], _ = json.Marshal(name)
+		renamed, _ := json.Marshal(tool)
+		out[upstream] = append(out[upstream], renamed)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestCatalogHistory(t *testing.T) {
+	store, _ := catalog.Open(nil)
+	cfg := DefaultConfig()
+	cfg.Catalog = store
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	description := "Read a file"
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		var result interface{}
+		switch {
+		case msg.Method == "initialize":
+			result = map[string]interface{}{"serverInfo": map[string]string{"name": "files"}}
+		case msg.Method == "tools/list" && len(msg.Params) == 0:
+			result = map[string]interface{}{
+				"tools":      []interface{}{map[string]string{"name": "read", "description": description}},
+				"nextCursor": "page2",
+			}
+		case msg.Method == "tools/list":
+			result = map[string]interface{}{"tools": []interface{}{map[string]string{"name": "write"}}}
+		case msg.Method == "prompts/list":
+			result = map[string]interface{}{"prompts": []interface{}{map[string]string{"name": "summarize"}}}
+		}
+		resp, _ := jsonrpc.NewResponse(msg.ID, result)
+		return jsonrpc.Serialize(resp)
+	}
+	route := func(method string, params interface{}) {
+		t.Helper()
+		req, _ := jsonrpc.NewRequest(method, params, 1)
+		data, _ := jsonrpc.Serialize(req)
+		if _, err := r.RouteMessage(data); err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+	}
+
+	route("initialize", map[string]interface{}{})
+	// A continuation seen without its first page is not a listing
+	route("tools/list", map[string]string{"cursor": "page2"})
+	if got := store.Snapshots("", ""); len(got) != 0 {
+		t.Fatalf("partial listing recorded: %+v", got)
+	}
+	route("tools/list", nil)
+	if got := store.Snapshots("", ""); len(got) != 0 {
+		t.Fatalf("first page recorded before the last: %+v", got)
+	}
+	route("tools/list", map[string]string{"cursor": "page2"})
+	route("prompts/list", nil)
+	got := store.Snapshots("files", "")
+	if len(got) != 2 || got[0].Kind != catalog.KindTools || got[0].Entries != 2 || got[1].Kind != catalog.KindPrompts {
+		t.Fatalf("snapshots = %+v", got)
+	}
+
+	description = "Read a file and report it"
+	route("tools/list", nil)
+	route("tools/list", map[string]string{"cursor": "page2"})
+	tools := store.Snapshots("files", catalog.KindTools)
+	if len(tools) != 2 {
+		t.Fatalf("tools snapshots = %+v", tools)
+	}
+	d, err := store.Diff(tools[0].ID, tools[1].ID)
+	if err != nil || len(d.Modified) != 1 || d.Modified[0].Name != "read" {
+		t.Errorf("diff = %+v, %v", d, err)
+	}
+}
+
+func TestCatalogHistory_Upstreams(t *testing.T) {
+	store, _ := catalog.Open(nil)
+	cfg := DefaultConfig()
+	cfg.Catalog = store
+	cfg.UpstreamTools = staticResolver{}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"tools": []interface{}{
+			map[string]string{"name": "fs__read"},
+			map[string]string{"name": "web__fetch"},
+			map[string]string{"name": "web__search"},
+		}})
+		return jsonrpc.Serialize(resp)
+	}
+	req, _ := jsonrpc.NewRequest("tools/list", nil, 1)
+	data, _ := jsonrpc.Serialize(req)
+	r.RouteMessage(data)
+
+	for server, want := range map[string]string{"fs": "read", "web": "fetch"} {
+		got := store.Snapshots(server, catalog.KindTools)
+		if len(got) != 1 {
+			t.Fatalf("%s snapshots = %+v", server, got)
+		}
+		snap, _ := store.Snapshot(got[0].ID)
+		if _, ok := snap.Entries[want]; !ok {
+			t.Errorf("%s entries = %s, expected %s under the server's own name", server, snap.Entries, want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package router
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// MetaChain is the _meta key carrying chained sentinel metadata on
+// forwarded requests and their results.
+const MetaChain = "io.mcp-sentinel/chain"
+
+// ErrChainConfig is returned by ChainConfig.Validate.
+var ErrChainConfig = errors.New("router: invalid chain configuration")
+
+// ChainConfig lets sentinels chained in front of one another (client →
+// sentinel A → sentinel B → server) cooperate instead of repeating
+// each other's work.
+//
+// A proxy with Propagate set adds a signed hop record under MetaChain
+// to each request it forwards: its proxy ID, decision ID, verdict, the
+// checks it ran, and the gas it charged. The next proxy verifies the
+// signature with the shared Key and then
+//
+//   - adopts the chain's trace ID for its decision and audit events, so
+//     the events both proxies emit for one request can be correlated
+//     and de-duplicated
+//   - does not charge gas a proxy closer to the client already charged
+//   - with TrustUpstream, skips the sentinel checks of a tool call the
+//     previous hop ran and allowed; local policy (pause, schedule, tool
+//     policy, TOFU, argument guards) still applies
+//
+// Each proxy also adds its hop to the result's _meta on the way back;
+// the proxy nearest the client records the downstream decisions and
+// removes the metadata before the client sees it.
+//
+// # Security Notes
+//
+// A client can write any _meta it likes, so chain metadata is only
+// honored with a valid signature from a proxy holding Key. The
+// signature covers the method, tool name, and arguments, so it cannot
+// be moved onto a different call. Metadata that fails verification is
+// stripped, counted, and ignored. Result metadata is informational and
+// unsigned; it never affects a decision.
+type ChainConfig struct {
+	// ProxyID names this proxy in hop records (required)
+	ProxyID string
+
+	// Key signs and verifies chain metadata; every proxy in the chain
+	// shares it (empty sends unsigned metadata and verifies nothing)
+	Key []byte
+
+	// Propagate adds chain metadata to forwarded requests; enable it
+	// when another sentinel sits between this proxy and the server
+	// (false strips chain metadata before the server sees it)
+	Propagate bool
+
+	// TrustUpstream skips the sentinel checks of tool calls a verified
+	// previous hop already ran and allowed
+	TrustUpstream bool
+}
+
+// Validate reports a configuration that cannot work.
+func (c *ChainConfig) Validate() error {
+	switch {
+	case c.ProxyID == "":
+		return fmt.Errorf("%w: a proxy ID is required", ErrChainConfig)
+	case c.TrustUpstream && len(c.Key) == 0:
+		return fmt.Errorf("%w: trusting upstream checks requires a key", ErrChainConfig)
+	}
+	return nil
+}
+
+// ChainHop is one proxy's record of a request.
+type ChainHop struct {
+	Proxy      string   `json:"proxy"`
+	DecisionID string   `json:"decision_id"`
+	Verdict    Verdict  `json:"verdict,omitempty"`
+	Checks     []string `json:"checks,omitempty"`
+	DeferredTo string   `json:"deferred_to,omitempty"`
+	Gas        uint64   `json:"gas,omitempty"`
+}
+
+// ChainMeta is the value of MetaChain.
+type ChainMeta struct {
+	TraceID   string     `json:"trace_id"`
+	Hops      []ChainHop `json:"hops"`
+	Signature string     `json:"sig,omitempty"`
+}
+
+// last returns the hop nearest this proxy.
+func (m *ChainMeta) last() *ChainHop {
+	if m == nil || len(m.Hops) == 0 {
+		return nil
+	}
+	return &m.Hops[len(m.Hops)-1]
+}
+
+// chainSubject is the request content a chain signature covers.
+type chainSubject struct {
+	TraceID   string          `json:"trace_id"`
+	Hops      []ChainHop      `json:"hops"`
+	Method    string          `json:"method"`
+	Tool      string          `json:"tool,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// sign returns the signature of meta over a request.
+func (c *ChainConfig) sign(meta *ChainMeta, method string, params map[string]json.RawMessage) string {
+	subject := chainSubject{TraceID: meta.TraceID, Hops: meta.Hops, Method: method}
+	json.Unmarshal(params["name"], &subject.Tool)
+	if args := params["arguments"]; len(args) > 0 {
+		var buf bytes.Buffer
+		if json.Compact(&buf, args) == nil {
+			subject.Arguments = buf.Bytes()
+		}
+	}
+	data, _ := json.Marshal(subject)
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// chainParams decodes request params and their _meta.
+func chainParams(raw json.RawMessage) (params, meta map[string]json.RawMessage, ok bool) {
+	params = map[string]json.RawMessage{}
+	if len(raw) > 0 && json.Unmarshal(raw, &params) != nil {
+		return nil, nil, false
+	}
+	if params == nil {
+		params = map[string]json.RawMessage{}
+	}
+	meta = map[string]json.RawMessage{}
+	if m, found := params["_meta"]; found && json.Unmarshal(m, &meta) != nil {
+		return nil, nil, false
+	}
+	if meta == nil {
+		meta = map[string]json.RawMessage{}
+	}
+	return params, meta, true
+}
+
+// readChain detects chain metadata on a client request. Verified
+// metadata is kept on d and its trace ID replaces the decision's own.
+func (r *Router) readChain(d *Decision, msg *jsonrpc.Message) {
+	if !bytes.Contains(msg.Params, []byte(MetaChain)) {
+		return
+	}
+	params, meta, ok := chainParams(msg.Params)
+	raw, found := meta[MetaChain]
+	if !ok || !found {
+		return
+	}
+	r.stats.ChainedRequests.Add(1)
+
+	var in ChainMeta
+	if json.Unmarshal(raw, &in) != nil || in.TraceID == "" || len(in.Hops) == 0 {
+		r.rejectChain(d, "malformed chain metadata")
+		return
+	}
+	if r.chain == nil {
+		if !r.chainNoticed.Swap(true) {
+			log.Printf("router: session %s: requests carry chain metadata from sentinel %q; configure chaining to use it", r.sessionID, in.last().Proxy)
+		}
+		d.Details = withDetailMap(d.Details, "chain_unverified", in.last().Proxy)
+		return
+	}
+	if len(r.chain.Key) == 0 {
+		d.Details = withDetailMap(d.Details, "chain_unverified", in.last().Proxy)
+		return
+	}
+	sig := in.Signature
+	in.Signature = ""
+	if !hmac.Equal([]byte(sig), []byte(r.chain.sign(&in, msg.Method, params))) {
+		r.rejectChain(d, "chain metadata signature mismatch")
+		return
+	}
+
+	d.chainIn = &in
+	d.TraceID = in.TraceID
+	d.Details = withDetailMap(d.Details, "chain_upstream", in.last().Proxy)
+}
+
+// rejectChain records chain metadata that failed verification.
+func (r *Router) rejectChain(d *Decision, reason string) {
+	r.stats.ChainRejected.Add(1)
+	d.Details = withDetailMap(d.Details, "chain_rejected", reason)
+	log.Printf("router: session %s: decision %s: ignored %s", r.sessionID, d.ID, reason)
+}
+
+// deferredChecks returns the previous hop if it allowed a tool call
+// after running every sentinel check this proxy would run, or nil if
+// this proxy must check the call itself.
+func (r *Router) deferredChecks(d *Decision) *ChainHop {
+	if r.chain == nil || !r.chain.TrustUpstream {
+		return nil
+	}
+	// Local failsafe blocks whatever upstream allowed
+	if r.DegradationLevel() == degrade.LevelFailsafe {
+		return nil
+	}
+	hop := d.chainIn.last()
+	if hop == nil || hop.Verdict != VerdictAllowed {
+		return nil
+	}
+	required := []string{CheckRegistry, CheckState}
+	if r.needsCouncil(d, d.Tool) {
+		required = append(required, CheckCouncil)
+	}
+	for _, check := range required {
+		if !slices.Contains(hop.Checks, check) {
+			return nil
+		}
+	}
+	return hop
+}
+
+// deferToUpstream allows a tool call on the strength of hop's checks.
+// The call still counts towards session state and gas.
+func (r *Router) deferToUpstream(d *Decision, msg *jsonrpc.Message, hop *ChainHop) *sentinel.CheckResult {
+	r.stats.ChecksDeferred.Add(1)
+	d.deferredTo = hop
+	d.event(EventCheck, map[string]interface{}{"check": "deferred", "proxy": hop.Proxy, "checks": hop.Checks})
+
+	r.toolsMu.Lock()
+	r.previousTools = append(r.previousTools, d.Tool)
+	r.toolsMu.Unlock()
+	r.chargeGas(d, msg)
+
+	return &sentinel.CheckResult{
+		Allowed: true,
+		Reason:  fmt.Sprintf("checks deferred to sentinel %s", hop.Proxy),
+		Details: map[string]interface{}{"deferred_to": hop.Proxy, "upstream_decision": hop.DecisionID},
+	}
+}
+
+// upstreamCharged reports whether a verified previous hop charged gas
+// for the request, naming the proxy.
+func (d *Decision) upstreamCharged() (string, bool) {
+	if d == nil || d.chainIn == nil {
+		return "", false
+	}
+	for _, hop := range d.chainIn.Hops {
+		if hop.Gas > 0 {
+			return hop.Proxy, true
+		}
+	}
+	return "", false
+}
+
+// writeChain replaces the chain metadata of a request about to be
+// forwarded: it is removed, and with Propagate this proxy's hop is
+// appended and the whole record re-signed.
+func (r *Router) writeChain(d *Decision, msg *jsonrpc.Message, data []byte) []byte {
+	if !r.chain.Propagate && !bytes.Contains(data, []byte(MetaChain)) {
+		return data
+	}
+	out, err := jsonrpc.Parse(data)
+	if err != nil {
+		return data
+	}
+	params, meta, ok := chainParams(out.Params)
+	if !ok {
+		return data
+	}
+	delete(meta, MetaChain)
+
+	if r.chain.Propagate {
+		chain := ChainMeta{TraceID: d.TraceID}
+		if d.chainIn != nil {
+			chain.Hops = append(chain.Hops, d.chainIn.Hops...)
+		}
+		hop := ChainHop{Proxy: r.chain.ProxyID, DecisionID: d.ID, Verdict: d.Verdict, Checks: d.checks}
+		if deferred := d.deferredTo; deferred != nil {
+			hop.Checks, hop.DeferredTo = deferred.Checks, deferred.Proxy
+		}
+		if d.gas != nil {
+			hop.Gas = d.gas.amount
+		}
+		chain.Hops = append(chain.Hops, hop)
+		if len(r.chain.Key) > 0 {
+			chain.Signature = r.chain.sign(&chain, msg.Method, params)
+		}
+		meta[MetaChain], _ = json.Marshal(chain)
+	}
+
+	if len(meta) == 0 {
+		delete(params, "_meta")
+	} else {
+		params["_meta"], _ = json.Marshal(meta)
+	}
+	if len(params) == 0 && len(out.Params) == 0 {
+		return data
+	}
+	out.Params, _ = json.Marshal(params)
+	rewritten, err := jsonrpc.Serialize(out)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+// chainResponse records the downstream hops in a result's _meta. A
+// proxy that received a chained request adds its own hop for the proxy
+// before it; the proxy nearest the client removes the metadata.
+func (r *Router) chainResponse(d *Decision, response []byte) []byte {
+	if !bytes.Contains(response, []byte(MetaChain)) && d.chainIn == nil {
+		return response
+	}
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response
+	}
+	result, meta, ok := chainParams(resp.Result)
+	if !ok {
+		return response
+	}
+
+	var down ChainMeta
+	if raw, found := meta[MetaChain]; found && json.Unmarshal(raw, &down) == nil {
+		var ids []string
+		for _, hop := range down.Hops {
+			ids = append(ids, hop.Proxy+"/"+hop.DecisionID)
+		}
+		d.Details = withDetailMap(d.Details, "chain_downstream", ids)
+	}
+	delete(meta, MetaChain)
+
+	if d.chainIn != nil {
+		down.TraceID = d.TraceID
+		down.Hops = append(down.Hops, ChainHop{Proxy: r.chain.ProxyID, DecisionID: d.ID, Verdict: d.Verdict})
+		meta[MetaChain], _ = json.Marshal(down)
+	}
+	if len(meta) == 0 {
+		delete(result, "_meta")
+	} else {
+		result["_meta"], _ = json.Marshal(meta)
+	}
+	resp.Result, _ = json.Marshal(result)
+	out, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return response
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// chainedRouters connects client → a → b → server and returns the
+// routers and a pointer to the last request the server received.
+func chainedRouters(t *testing.T, a, b *ChainConfig) (*Router, *Router, *[]byte) {
+	t.Helper()
+	var received []byte
+	cfgB := DefaultConfig()
+	cfgB.Chain = b
+	rb := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfgB)
+	rb.forwardFunc = func(data []byte) ([]byte, error) {
+		received = data
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	cfgA := DefaultConfig()
+	cfgA.Chain = a
+	ra := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfgA)
+	ra.forwardFunc = rb.RouteMessage
+	return ra, rb, &received
+}
+
+func toolCall(t *testing.T, tool string, args map[string]interface{}) []byte {
+	t.Helper()
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool, "arguments": args}, 1)
+	data, err := jsonrpc.Serialize(req)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return data
+}
+
+func TestChain_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ChainConfig
+		wantErr bool
+	}{
+		{"propagate", ChainConfig{ProxyID: "a", Key: []byte("k"), Propagate: true}, false},
+		{"unsigned", ChainConfig{ProxyID: "a", Propagate: true}, false},
+		{"missing ID", ChainConfig{Key: []byte("k")}, true},
+		{"trust without key", ChainConfig{ProxyID: "b", TrustUpstream: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrChainConfig) {
+				t.Errorf("Validate() = %v, expected ErrChainConfig", err)
+			}
+		})
+	}
+}
+
+func TestChain_Propagation(t *testing.T) {
+	key := []byte("shared")
+	tests := []struct {
+		name      string
+		trust     bool
+		wantDefer bool
+	}{
+		{"verify only", false, false},
+		{"trust upstream", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ra, rb, received := chainedRouters(t,
+				&ChainConfig{ProxyID: "edge", Key: key, Propagate: true},
+				&ChainConfig{ProxyID: "inner", Key: key, TrustUpstream: tt.trust})
+
+			response, err := ra.RouteMessage(toolCall(t, "read_file", map[string]interface{}{"path": "/tmp/x"}))
+			if err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+			if bytes.Contains(response, []byte(MetaChain)) {
+				t.Errorf("client response carries chain metadata: %s", response)
+			}
+			if bytes.Contains(*received, []byte(MetaChain)) {
+				t.Errorf("server request carries chain metadata: %s", *received)
+			}
+
+			da, db := ra.RecentDecisions(1)[0], rb.RecentDecisions(1)[0]
+			if db.TraceID != da.ID || da.TraceID != da.ID {
+				t.Errorf("trace IDs = %q, %q; expected both %q", da.TraceID, db.TraceID, da.ID)
+			}
+			if down, _ := da.Details["chain_downstream"].([]string); len(down) != 1 || down[0] != "inner/"+db.ID {
+				t.Errorf("chain_downstream = %v, expected inner/%s", da.Details["chain_downstream"], db.ID)
+			}
+			if db.Details["chain_upstream"] != "edge" {
+				t.Errorf("chain_upstream = %v, expected edge", db.Details["chain_upstream"])
+			}
+			if db.Details["gas_charged_by"] != "edge" || rb.gasUsed.Load() != 0 {
+				t.Errorf("inner charged %d gas (details %v); edge already charged", rb.gasUsed.Load(), db.Details)
+			}
+			if ra.gasUsed.Load() == 0 {
+				t.Error("edge charged no gas")
+			}
+
+			_, deferred := db.Details["deferred_to"]
+			if deferred != tt.wantDefer || (rb.stats.ChecksDeferred.Load() == 1) != tt.wantDefer {
+				t.Errorf("deferred = %t (%d counted), expected %t", deferred, rb.stats.ChecksDeferred.Load(), tt.wantDefer)
+			}
+			if got := db.Verdict; got != VerdictAllowed {
+				t.Errorf("inner verdict = %s, expected allowed", got)
+			}
+		})
+	}
+}
+
+func TestChain_HighRiskNotDeferredWithoutCouncil(t *testing.T) {
+	key := []byte("shared")
+	ra, rb, _ := chainedRouters(t,
+		&ChainConfig{ProxyID: "edge", Key: key, Propagate: true},
+		&ChainConfig{ProxyID: "inner", Key: key, TrustUpstream: true})
+	// Only the inner proxy considers the tool high-risk
+	ra.highRiskTools = map[string]bool{}
+	rb.highRiskTools = map[string]bool{"read_file": true}
+
+	if _, err := ra.RouteMessage(toolCall(t, "read_file", nil)); err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	if db := rb.RecentDecisions(1)[0]; db.Details["deferred_to"] != nil {
+		t.Errorf("inner deferred a council check edge never ran: %v", db.Details)
+	}
+}
+
+func TestChain_UntrustedMetadata(t *testing.T) {
+	key := []byte("shared")
+	signed := func(t *testing.T, args map[string]interface{}) []byte {
+		// Capture what a propagating edge proxy forwards
+		var out []byte
+		ra := NewWithConfig(&mockTransport{}, sentinel.NewClient(), &Config{Chain: &ChainConfig{ProxyID: "edge", Key: key, Propagate: true}})
+		ra.forwardFunc = func(data []byte) ([]byte, error) {
+			out = data
+			resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{})
+			return jsonrpc.Serialize(resp)
+		}
+		ra.RouteMessage(toolCall(t, "read_file", args))
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		chain      *ChainConfig
+		data       func(t *testing.T) []byte
+		wantDetail string
+		rejected   uint64
+	}{
+		{
+			name:  "forged signature",
+			chain: &ChainConfig{ProxyID: "inner", Key: key, TrustUpstream: true},
+			data: func(t *testing.T) []byte {
+				return bytes.Replace(signed(t, map[string]interface{}{"path": "/tmp/x"}), []byte(`"sig":"`), []byte(`"sig":"00`), 1)
+			},
+			wantDetail: "chain_rejected",
+			rejected:   1,
+		},
+		{
+			name:  "arguments changed after signing",
+			chain: &ChainConfig{ProxyID: "inner", Key: key, TrustUpstream: true},
+			data: func(t *testing.T) []byte {
+				return bytes.Replace(signed(t, map[string]interface{}{"path": "/tmp/x"}), []byte("/tmp/x"), []byte("/etc/shadow"), 1)
+			},
+			wantDetail: "chain_rejected",
+			rejected:   1,
+		},
+		{
+			name:  "wrong key",
+			chain: &ChainConfig{ProxyID: "inner", Key: []byte("other"), TrustUpstream: true},
+			data: func(t *testing.T) []byte {
+				return signed(t, map[string]interface{}{"path": "/tmp/x"})
+			},
+			wantDetail: "chain_rejected",
+			rejected:   1,
+		},
+		{
+			name:  "client-written metadata",
+			chain: &ChainConfig{ProxyID: "inner", Key: key, TrustUpstream: true},
+			data: func(t *testing.T) []byte {
+				params := map[string]interface{}{
+					"name": "read_file",
+					"_meta": map[string]interface{}{MetaChain: ChainMeta{TraceID: "t", Hops: []ChainHop{
+						{Proxy: "edge", DecisionID: "d", Verdict: VerdictAllowed, Checks: []string{CheckRegistry, CheckState}, Gas: 1},
+					}}},
+				}
+				req, _ := jsonrpc.NewRequest("tools/call", params, 1)
+				data, _ := jsonrpc.Serialize(req)
+				return data
+			},
+			wantDetail: "chain_rejected",
+			rejected:   1,
+		},
+		{
+			name:  "chaining not configured",
+			chain: nil,
+			data: func(t *testing.T) []byte {
+				return signed(t, map[string]interface{}{"path": "/tmp/x"})
+			},
+			wantDetail: "chain_unverified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(t)
+			cfg := DefaultConfig()
+			cfg.Chain = tt.chain
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			var forwarded []byte
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				forwarded = data
+				resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{})
+				return jsonrpc.Serialize(resp)
+			}
+			if _, err := r.RouteMessage(data); err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+
+			d := r.RecentDecisions(1)[0]
+			if _, ok := d.Details[tt.wantDetail]; !ok {
+				t.Errorf("details = %v, expected %s", d.Details, tt.wantDetail)
+			}
+			if d.TraceID != d.ID {
+				t.Errorf("trace ID = %q, expected own ID %q", d.TraceID, d.ID)
+			}
+			if d.Details["deferred_to"] != nil || d.Details["gas_charged_by"] != nil || r.gasUsed.Load() == 0 {
+				t.Errorf("unverified metadata was trusted: %v (gas %d)", d.Details, r.gasUsed.Load())
+			}
+			if got := r.stats.ChainRejected.Load(); got != tt.rejected {
+				t.Errorf("ChainRejected = %d, expected %d", got, tt.rejected)
+			}
+			if r.stats.ChainedRequests.Load() != 1 {
+				t.Errorf("ChainedRequests = %d, expected 1", r.stats.ChainedRequests.Load())
+			}
+			if tt.chain != nil && bytes.Contains(forwarded, []byte(MetaChain)) {
+				t.Errorf("unverified metadata forwarded: %s", forwarded)
+			}
+		})
+	}
+}
+
+func TestChain_AuditEventsShareTraceID(t *testing.T) {
+	key := []byte("shared")
+	emitted := map[string][]Event{}
+	ra, rb, _ := chainedRouters(t,
+		&ChainConfig{ProxyID: "edge", Key: key, Propagate: true},
+		&ChainConfig{ProxyID: "inner", Key: key})
+	ra.eventSink = sinkFunc(func(events []Event) { emitted["edge"] = append(emitted["edge"], events...) })
+	rb.eventSink = sinkFunc(func(events []Event) { emitted["inner"] = append(emitted["inner"], events...) })
+
+	if _, err := ra.RouteMessage(toolCall(t, "read_file", nil)); err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	trace := ra.RecentDecisions(1)[0].ID
+	for _, name := range []string{"edge", "inner"} {
+		events := emitted[name]
+		if len(events) == 0 {
+			t.Fatalf("%s emitted no events", name)
+		}
+		for _, e := range events {
+			if e.TraceID != trace {
+				t.Errorf("%s event %s trace ID = %q, expected %q", name, e.Kind, e.TraceID, trace)
+			}
+		}
+	}
+}
+
+func TestChain_SignatureCoversArgumentsNotFormatting(t *testing.T) {
+	c := &ChainConfig{ProxyID: "edge", Key: []byte("k")}
+	meta := &ChainMeta{TraceID: "t", Hops: []ChainHop{{Proxy: "edge", DecisionID: "d"}}}
+	params := func(args string) map[string]json.RawMessage {
+		return map[string]json.RawMessage{"name": json.RawMessage(`"read_file"`), "arguments": json.RawMessage(args)}
+	}
+	if c.sign(meta, "tools/call", params(`{"path": "/a"}`)) != c.sign(meta, "tools/call", params(`{"path":"/a"}`)) {
+		t.Error("signature depends on argument whitespace")
+	}
+	if c.sign(meta, "tools/call", params(`{"path":"/a"}`)) == c.sign(meta, "tools/call", params(`{"path":"/b"}`)) {
+		t.Error("signature does not cover arguments")
+	}
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// CompletionLimits bounds completion/complete traffic.
+//
+// Completion values flow straight into user-visible UI and, once
+// accepted, into prompts. The proxy caps how many and how large they
+// may be and drops any value that looks like injected instructions or
+// an exfiltrated secret.
+type CompletionLimits struct {
+	// MaxValues is the maximum number of suggestions delivered
+	MaxValues int
+
+	// MaxValueLength is the maximum length of a single suggestion and
+	// of the argument value a client may send
+	MaxValueLength int
+}
+
+// DefaultCompletionLimits returns the MCP spec maximum of 100 values
+// and a conservative per-value length.
+func DefaultCompletionLimits() *CompletionLimits {
+	return &CompletionLimits{
+		MaxValues:      100,
+		MaxValueLength: 256,
+	}
+}
+
+// suspiciousCompletion matches injection phrasing and secret formats.
+var suspiciousCompletion = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)ignore (all |any )?(previous|prior|above) (instructions|prompts)`),
+	regexp.MustCompile(`(?i)(system prompt|you are now|new instructions:)`),
+	regexp.MustCompile(`<\|?(im_start|im_end|system)\|?>`),
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`\b(sk|ghp|gho|xox[abp])[-_][A-Za-z0-9]{16,}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`(?i)https?://\S+[?&]\S*=(\S{32,})`),
+}
+
+// checkCompletionRequest rejects oversized completion argument values.
+// Returns a non-empty reason if the request must be refused.
+func (r *Router) checkCompletionRequest(msg *jsonrpc.Message) string {
+	params, err := mcptypes.DecodeParams[mcptypes.CompleteParams](msg)
+	if err != nil {
+		return "malformed completion params"
+	}
+	if len(params.Argument.Value) > r.completionLimits.MaxValueLength {
+		return "completion argument exceeds maximum length"
+	}
+	return ""
+}
+
+// sanitizeCompletion filters and caps suggestions in a completion
+// response. Responses that are errors or cannot be decoded are
+// returned unchanged.
+func (r *Router) sanitizeCompletion(response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		return response
+	}
+
+	result, err := mcptypes.DecodeResult[mcptypes.CompleteResult](resp)
+	if err != nil {
+		r.captureValidation("completion/complete", response, err)
+		return response
+	}
+
+	limits := r.completionLimits
+	values := result.Completion.Values
+	kept := make([]string, 0, len(values))
+	dropped := 0
+	for _, v := range values {
+		if len(v) > limits.MaxValueLength || suspiciousCompletionValue(v) {
+			dropped++
+			continue
+		}
+		kept = append(kept, v)
+	}
+	if len(kept) > limits.MaxValues {
+		kept = kept[:limits.MaxValues]
+		result.Completion.HasMore = true
+	}
+	if dropped == 0 && len(kept) == len(values) {
+		return response
+	}
+	if dropped > 0 {
+		log.Printf("router: dropped %d suspicious or oversized completion values", dropped)
+	}
+
+	result.Completion.Values = kept
+	if result.Completion.Total != nil {
+		total := *result.Completion.Total - dropped
+		if total < len(kept) {
+			total = len(kept)
+		}
+		result.Completion.Total = &total
+	}
+
+	sanitized, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return response
+	}
+	data, err := jsonrpc.Serialize(sanitized)
+	if err != nil {
+		return response
+	}
+	return data
+}
+
+// suspiciousCompletionValue reports whether a suggestion contains
+// injection phrasing, secret material, or invisible characters.
+func suspiciousCompletionValue(v string) bool {
+	for _, re := range suspiciousCompletion {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return strings.ContainsFunc(v, func(c rune) bool {
+		return unicode.Is(unicode.Cf, c) || (unicode.IsControl(c) && c != '\t')
+	})
+}
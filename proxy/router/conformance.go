@@ -0,0 +1,355 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// CodeNonconforming is the JSON-RPC error code for requests refused
+// because the client persistently broke the protocol.
+const CodeNonconforming = -32007
+
+// maxTrackedIDs bounds the request IDs remembered for duplicate and
+// cancellation checks; the oldest are forgotten first.
+const maxTrackedIDs = 4096
+
+// Violation identifies a kind of protocol conformance violation.
+type Violation string
+
+// Conformance violations.
+const (
+	// ViolationDuplicateID is a request reusing an ID already used in
+	// the session
+	ViolationDuplicateID Violation = "duplicate_id"
+	// ViolationBeforeInitialize is a request other than initialize or
+	// ping before the server answered initialize
+	ViolationBeforeInitialize Violation = "before_initialize"
+	// ViolationMissingInitialized is a request sent after the
+	// initialize result without notifications/initialized (counted
+	// once per session)
+	ViolationMissingInitialized Violation = "missing_initialized"
+	// ViolationRepeatedInitialize is a second initialize request
+	ViolationRepeatedInitialize Violation = "repeated_initialize"
+	// ViolationIgnoredError is a tool call retried unchanged right
+	// after it was blocked
+	ViolationIgnoredError Violation = "ignored_error"
+	// ViolationUnknownCancel is a cancellation naming no request the
+	// client sent
+	ViolationUnknownCancel Violation = "unknown_cancel"
+	// ViolationCancelInitialize is a cancellation of initialize, which
+	// the protocol forbids
+	ViolationCancelInitialize Violation = "cancel_initialize"
+)
+
+// DefaultViolationWeights are the score contributions used when a
+// Conformance does not specify a weight.
+var DefaultViolationWeights = map[Violation]float64{
+	ViolationDuplicateID:        2.0,
+	ViolationBeforeInitialize:   1.0,
+	ViolationMissingInitialized: 2.0,
+	ViolationRepeatedInitialize: 2.0,
+	ViolationIgnoredError:       2.0,
+	ViolationUnknownCancel:      1.0,
+	ViolationCancelInitialize:   2.0,
+}
+
+// Conformance scores how well the client follows the MCP protocol:
+// unique request IDs, the initialize / notifications/initialized
+// handshake, not retrying refused calls, and cancelling only requests
+// it sent.
+//
+// Each violation adds its weight to a score that decays with HalfLife,
+// so an occasional slip fades while a persistently nonconforming client
+// accumulates. At StrictAt the session is held to stricter policy: every
+// tool call needs a council vote. At RejectAt every later request but
+// ping is refused with CodeNonconforming for the rest of the session.
+//
+// # Security Notes
+//
+// A client that cannot keep its own IDs or handshake straight is either
+// badly broken or probing the proxy and server for parsing and state
+// bugs. Both are better contained at the proxy than passed upstream.
+type Conformance struct {
+	// Weights maps violations to score contributions
+	// (DefaultViolationWeights if nil; unlisted violations contribute
+	// 1.0)
+	Weights map[Violation]float64
+
+	// StrictAt is the score that requires a council vote for every
+	// tool call (zero uses 4)
+	StrictAt float64
+
+	// RejectAt is the score that refuses the client's requests (zero
+	// uses 10)
+	RejectAt float64
+
+	// HalfLife controls how quickly the score decays (zero uses 10m)
+	HalfLife time.Duration
+
+	// RetryWindow is how soon an identical tool call after a block
+	// counts as ignoring the error (zero uses 10s)
+	RetryWindow time.Duration
+}
+
+// ConformanceReport is the client's conformance so far.
+type ConformanceReport struct {
+	Score      float64           `json:"score"`
+	Violations map[Violation]int `json:"violations,omitempty"`
+	Strict     bool              `json:"strict"`
+	Rejected   bool              `json:"rejected"`
+}
+
+// conformanceLog tracks the client's protocol state and score.
+type conformanceLog struct {
+	cfg    Conformance
+	scorer *anomaly.Scorer
+
+	mu          sync.Mutex
+	initID      string // ID of the initialize request ("" before it)
+	ready       bool   // the server answered initialize
+	initialized bool   // the client sent notifications/initialized
+	reported    bool   // ViolationMissingInitialized was counted
+	inflight    map[string]bool
+	ids         map[string]bool
+	idRing      []string
+	idNext      int
+	refused     map[string]time.Time
+	violations  map[Violation]int
+	strict      bool
+	rejected    bool
+}
+
+// newConformanceLog applies defaults to cfg.
+func newConformanceLog(cfg *Conformance) *conformanceLog {
+	c := *cfg
+	if c.Weights == nil {
+		c.Weights = DefaultViolationWeights
+	}
+	if c.StrictAt <= 0 {
+		c.StrictAt = 4
+	}
+	if c.RejectAt <= 0 {
+		c.RejectAt = 10
+	}
+	if c.HalfLife <= 0 {
+		c.HalfLife = 10 * time.Minute
+	}
+	if c.RetryWindow <= 0 {
+		c.RetryWindow = 10 * time.Second
+	}
+	weights := make(map[anomaly.Signal]float64, len(c.Weights))
+	for v, w := range c.Weights {
+		weights[anomaly.Signal(v)] = w
+	}
+	return &conformanceLog{
+		cfg:        c,
+		scorer:     anomaly.NewScorer(&anomaly.Config{Weights: weights, Threshold: c.RejectAt, HalfLife: c.HalfLife}),
+		inflight:   make(map[string]bool),
+		ids:        make(map[string]bool),
+		idRing:     make([]string, maxTrackedIDs),
+		refused:    make(map[string]time.Time),
+		violations: make(map[Violation]int),
+	}
+}
+
+// checkConformance records the protocol violations in msg, escalates
+// the session as its score rises, and returns an error reply if the
+// client is rejected. A rejected notification is dropped with a nil
+// reply.
+func (r *Router) checkConformance(d *Decision, msg *jsonrpc.Message) ([]byte, bool) {
+	l := r.conformance
+	violations := l.observe(d, msg)
+
+	var score float64
+	var tripped bool
+	for _, v := range violations {
+		var t bool
+		score, t = l.scorer.Record(anomaly.Signal(v.kind), v.detail)
+		tripped = tripped || t
+		r.stats.ConformanceViolations.Add(1)
+		d.Details = withDetailMap(d.Details, "conformance_violation", string(v.kind))
+		d.finding(sentinel.Finding{
+			Source:   sentinel.EvidenceConformance,
+			Kind:     string(v.kind),
+			Severity: min(1, score/l.cfg.RejectAt),
+			Summary:  v.detail,
+		})
+	}
+	if len(violations) == 0 {
+		score = l.scorer.Score()
+	}
+
+	// Strictness follows the decaying score; rejection is final
+	l.mu.Lock()
+	becameStrict := score >= l.cfg.StrictAt && !l.strict
+	l.strict = score >= l.cfg.StrictAt
+	l.rejected = l.rejected || tripped
+	strict, rejected := l.strict, l.rejected
+	l.mu.Unlock()
+
+	if becameStrict {
+		log.Printf("audit: session %s held to strict policy at conformance score %.1f", r.sessionID, score)
+		r.RecordAnomaly(anomaly.SignalReputationDrop, fmt.Sprintf("client conformance score %.1f", score))
+	}
+	if tripped {
+		log.Printf("audit: session %s rejected at conformance score %.1f", r.sessionID, score)
+	}
+	if rejected && msg.Method != "ping" {
+		r.stats.MessagesBlocked.Add(1)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, CodeNonconforming, "Client nonconforming",
+			fmt.Sprintf("requests refused after repeated protocol violations (score %.1f)", score))
+		if msg.Type() == jsonrpc.TypeNotification {
+			reply = nil
+		}
+		return reply, true
+	}
+	if strict {
+		d.Details = withDetailMap(d.Details, "conformance_strict", true)
+		d.requireCouncil = true
+	}
+	return nil, false
+}
+
+// conformanceViolation is one violation found in a message.
+type conformanceViolation struct {
+	kind   Violation
+	detail string
+}
+
+// observe updates the protocol state with msg and returns its
+// violations.
+func (l *conformanceLog) observe(d *Decision, msg *jsonrpc.Message) []conformanceViolation {
+	var found []conformanceViolation
+	add := func(kind Violation, format string, args ...interface{}) {
+		found = append(found, conformanceViolation{kind: kind, detail: fmt.Sprintf(format, args...)})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch msg.Type() {
+	case jsonrpc.TypeRequest:
+		id := string(msg.ID)
+		if l.inflight[id] || l.ids[id] {
+			add(ViolationDuplicateID, "request ID %s reused", id)
+		} else {
+			d.inflightID = id
+			l.inflight[id] = true
+			l.rememberID(id)
+		}
+		switch {
+		case msg.Method == "initialize":
+			if l.initID != "" {
+				add(ViolationRepeatedInitialize, "initialize sent again as request %s", id)
+			} else {
+				l.initID = id
+			}
+		case msg.Method == "ping":
+		case !l.ready:
+			add(ViolationBeforeInitialize, "%s sent before the initialize result", msg.Method)
+		case !l.initialized && !l.reported:
+			l.reported = true
+			add(ViolationMissingInitialized, "%s sent without notifications/initialized", msg.Method)
+		}
+		if msg.Method == "tools/call" {
+			if d.retryKey == "" {
+				d.retryKey = retryKey(jsonrpc.ExtractToolName(msg), msg.Params)
+			}
+			now := time.Now()
+			for key, at := range l.refused {
+				if now.Sub(at) > l.cfg.RetryWindow {
+					delete(l.refused, key)
+				}
+			}
+			if _, ok := l.refused[d.retryKey]; ok {
+				delete(l.refused, d.retryKey)
+				add(ViolationIgnoredError, "blocked call to %s retried unchanged", jsonrpc.ExtractToolName(msg))
+			}
+		}
+	case jsonrpc.TypeNotification:
+		switch msg.Method {
+		case "notifications/initialized":
+			l.initialized = true
+		case "notifications/cancelled":
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			id := string(p.RequestID)
+			switch {
+			case len(p.RequestID) == 0:
+				add(ViolationUnknownCancel, "cancellation without a requestId")
+			case id == l.initID:
+				add(ViolationCancelInitialize, "initialize request %s cancelled", id)
+			case !l.inflight[id] && !l.ids[id]:
+				add(ViolationUnknownCancel, "cancellation of unknown request %s", id)
+			}
+		}
+	}
+	for _, v := range found {
+		l.violations[v.kind]++
+	}
+	return found
+}
+
+// rememberID adds a request ID to the bounded set of used IDs. The
+// caller holds l.mu.
+func (l *conformanceLog) rememberID(id string) {
+	if old := l.idRing[l.idNext]; old != "" {
+		delete(l.ids, old)
+	}
+	l.idRing[l.idNext] = id
+	l.ids[id] = true
+	l.idNext = (l.idNext + 1) % len(l.idRing)
+}
+
+// answered records that the request d routed has been answered; an
+// answered initialize completes the server's side of the handshake.
+func (l *conformanceLog) answered(d *Decision) {
+	if d.inflightID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.inflight, d.inflightID)
+	if d.inflightID == l.initID && d.Method == "initialize" && d.Verdict == VerdictAllowed {
+		l.ready = true
+	}
+}
+
+// blocked remembers a blocked tool call, so retrying it unchanged
+// counts as ignoring the error.
+func (l *conformanceLog) blocked(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refused[key] = time.Now()
+}
+
+// Conformance returns the client's protocol conformance so far; the
+// zero report when conformance scoring is disabled.
+func (r *Router) Conformance() ConformanceReport {
+	l := r.conformance
+	if l == nil {
+		return ConformanceReport{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report := ConformanceReport{
+		Score:    l.scorer.Score(),
+		Strict:   l.strict,
+		Rejected: l.rejected,
+	}
+	if len(l.violations) > 0 {
+		report.Violations = make(map[Violation]int, len(l.violations))
+		for v, n := range l.violations {
+			report.Violations[v] = n
+		}
+	}
+	return report
+}
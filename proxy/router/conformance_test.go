@@ -0,0 +1,164 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// conformanceStep is one client message: a request when id is set,
+// otherwise a notification.
+type conformanceStep struct {
+	method string
+	params interface{}
+	id     interface{}
+}
+
+// newConformanceRouter returns a router scoring conformance whose
+// council denies every vote and whose server answers every request.
+func newConformanceRouter(cfg *Conformance) *Router {
+	rc := DefaultConfig()
+	rc.Conformance = cfg
+	client := sentinel.NewFusedClient(nil, sentinel.Member{Name: "test", Backend: &councilDenyBackend{}})
+	r := NewWithConfig(&mockTransport{}, client, rc)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]string{"status": "ok"})
+		return jsonrpc.Serialize(resp)
+	}
+	r.notifyFunc = func([]byte) error { return nil }
+	return r
+}
+
+// routeStep routes one step and returns the parsed reply, if any.
+func routeStep(t *testing.T, r *Router, step conformanceStep) *jsonrpc.Message {
+	t.Helper()
+	var msg *jsonrpc.Message
+	if step.id != nil {
+		msg, _ = jsonrpc.NewRequest(step.method, step.params, step.id)
+	} else {
+		msg, _ = jsonrpc.NewNotification(step.method, step.params)
+	}
+	data, _ := jsonrpc.Serialize(msg)
+	reply, err := r.RouteMessage(data)
+	if err != nil {
+		t.Fatalf("RouteMessage(%s) failed: %v", data, err)
+	}
+	if reply == nil {
+		return nil
+	}
+	resp, err := jsonrpc.Parse(reply)
+	if err != nil {
+		t.Fatalf("malformed reply %s: %v", reply, err)
+	}
+	return resp
+}
+
+var (
+	stepInitialize  = conformanceStep{"initialize", map[string]interface{}{"protocolVersion": "2025-06-18"}, 1}
+	stepInitialized = conformanceStep{"notifications/initialized", nil, nil}
+	blockedCall     = map[string]interface{}{"name": "execute_command", "arguments": map[string]interface{}{"command": "rm -rf /"}}
+)
+
+func TestConformance_Violations(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []conformanceStep
+		want  map[Violation]int
+	}{
+		{"conforming session", []conformanceStep{
+			stepInitialize, stepInitialized,
+			{"tools/list", nil, 2},
+			{"tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "a"}}, 3},
+			{"notifications/cancelled", map[string]interface{}{"requestId": 3}, nil},
+			{"ping", nil, "p-1"},
+		}, nil},
+		{"duplicate ID", []conformanceStep{
+			stepInitialize, stepInitialized, {"tools/list", nil, 2}, {"tools/list", nil, 2},
+		}, map[Violation]int{ViolationDuplicateID: 1}},
+		{"request before initialize", []conformanceStep{
+			{"ping", nil, 1}, {"tools/list", nil, 2},
+		}, map[Violation]int{ViolationBeforeInitialize: 1}},
+		{"missing initialized", []conformanceStep{
+			stepInitialize, {"tools/list", nil, 2}, {"tools/list", nil, 3},
+		}, map[Violation]int{ViolationMissingInitialized: 1}},
+		{"repeated initialize", []conformanceStep{
+			stepInitialize, stepInitialized, {"initialize", nil, 2},
+		}, map[Violation]int{ViolationRepeatedInitialize: 1}},
+		{"bad cancellations", []conformanceStep{
+			stepInitialize, stepInitialized,
+			{"notifications/cancelled", map[string]interface{}{"requestId": 99}, nil},
+			{"notifications/cancelled", map[string]interface{}{"requestId": 1}, nil},
+			{"notifications/cancelled", map[string]interface{}{"reason": "no ID"}, nil},
+		}, map[Violation]int{ViolationUnknownCancel: 2, ViolationCancelInitialize: 1}},
+		{"ignored error", []conformanceStep{
+			stepInitialize, stepInitialized, {"tools/call", blockedCall, 2}, {"tools/call", blockedCall, 3},
+		}, map[Violation]int{ViolationIgnoredError: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newConformanceRouter(&Conformance{})
+			for _, step := range tt.steps {
+				routeStep(t, r, step)
+			}
+			report := r.Conformance()
+			if len(report.Violations) != len(tt.want) {
+				t.Fatalf("violations = %v, expected %v", report.Violations, tt.want)
+			}
+			for v, n := range tt.want {
+				if report.Violations[v] != n {
+					t.Errorf("%s = %d, expected %d", v, report.Violations[v], n)
+				}
+			}
+			if tt.want == nil && report.Score != 0 {
+				t.Errorf("conforming session scored %v", report.Score)
+			}
+			if got := r.Stats().ConformanceViolations; int(got) != sum(tt.want) {
+				t.Errorf("ConformanceViolations = %d, expected %d", got, sum(tt.want))
+			}
+		})
+	}
+}
+
+func TestConformance_Escalation(t *testing.T) {
+	r := newConformanceRouter(&Conformance{StrictAt: 1.5, RejectAt: 3.5})
+	routeStep(t, r, stepInitialize)
+	routeStep(t, r, stepInitialized)
+	readFile := map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "a"}}
+	if resp := routeStep(t, r, conformanceStep{"tools/call", readFile, 2}); resp.Error != nil {
+		t.Fatalf("conforming call refused: %+v", resp.Error)
+	}
+
+	// A reused ID makes the session strict: tool calls need a council
+	// vote, which this council denies
+	resp := routeStep(t, r, conformanceStep{"tools/call", readFile, 2})
+	if resp.Error == nil || !r.Conformance().Strict {
+		t.Fatalf("strict session allowed a call without a vote: %+v", r.Conformance())
+	}
+
+	// Another reaches RejectAt: every request but ping is refused
+	routeStep(t, r, conformanceStep{"tools/list", nil, 2})
+	if !r.Conformance().Rejected {
+		t.Fatalf("report = %+v, expected rejected", r.Conformance())
+	}
+	resp = routeStep(t, r, conformanceStep{"tools/list", nil, 10})
+	if resp.Error == nil || resp.Error.Code != CodeNonconforming {
+		t.Errorf("rejected client's request answered %+v", resp)
+	}
+	if resp := routeStep(t, r, conformanceStep{"ping", nil, 11}); resp.Error != nil {
+		t.Errorf("ping refused: %+v", resp.Error)
+	}
+	if resp := routeStep(t, r, conformanceStep{"notifications/cancelled", map[string]interface{}{"requestId": 10}, nil}); resp != nil {
+		t.Errorf("rejected notification answered %+v", resp)
+	}
+}
+
+// sum adds up violation counts.
+func sum(counts map[Violation]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/classify"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// ContentAction is what a content rule does when it matches.
+type ContentAction string
+
+const (
+	// ContentBlock replaces the tool result with an error
+	ContentBlock ContentAction = "block"
+	// ContentFlag forwards the result and records the match
+	ContentFlag ContentAction = "flag"
+)
+
+// ContentRule matches classified tool result content.
+type ContentRule struct {
+	// Tools limits the rule to these tools (empty matches every tool)
+	Tools []string
+
+	// Kinds are the classifications the rule matches
+	Kinds []classify.Kind
+
+	// MinConfidence ignores classifications below this confidence
+	MinConfidence float64
+
+	// Action is taken when any content item matches
+	Action ContentAction
+}
+
+// ContentPolicy classifies tools/call result content and applies rules.
+//
+// Rules are evaluated in order; the first matching block rule rejects
+// the result, and every matching flag rule is recorded on the decision.
+// A router fronts a single server, so server trust is expressed by
+// which rules the operator configures for that router, e.g. blocking
+// KindEncoded from an untrusted server or flagging KindCode from a
+// documentation tool.
+type ContentPolicy struct {
+	Rules []ContentRule
+}
+
+// contentMatch is the decision-detail form of a matching rule.
+type contentMatch struct {
+	Index  int           `json:"item"`
+	Kind   classify.Kind `json:"kind"`
+	Action ContentAction `json:"action"`
+}
+
+// classifyToolResult classifies each content item of a tools/call
+// result (nil if it did not decode) and applies the content policy.
+//
+// # Returns
+//   - Non-empty reason if the result must be blocked
+func (r *Router) classifyToolResult(d *Decision, result *mcptypes.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+
+	kinds := make([]classify.Result, 0, len(result.Content))
+	var matches []contentMatch
+	blockReason := ""
+	for i, item := range result.Content {
+		var c classify.Result
+		switch {
+		case item.Type == mcptypes.ContentText:
+			c = classify.Text(item.Text)
+		case item.Resource != nil && item.Resource.Blob != "":
+			c = classify.Result{Kind: classify.KindEncoded, Confidence: 1}
+		case item.Resource != nil:
+			c = classify.Text(item.Resource.Text)
+		default:
+			// Images and audio are binary by definition
+			continue
+		}
+		kinds = append(kinds, c)
+
+		for _, rule := range r.contentPolicy.Rules {
+			if !rule.matches(d.Tool, c) {
+				continue
+			}
+			matches = append(matches, contentMatch{Index: i, Kind: c.Kind, Action: rule.Action})
+			severity := 0.4
+			if rule.Action == ContentBlock {
+				severity = 0.8
+			}
+			r.findings.add(sentinel.Finding{
+				Source:   sentinel.EvidenceContent,
+				Kind:     string(c.Kind),
+				Severity: severity,
+				Summary:  fmt.Sprintf("%s result item %d classified as %s (%s rule)", d.Tool, i, c.Kind, rule.Action),
+				Detail:   c,
+			})
+			if rule.Action == ContentFlag {
+				r.stats.ContentFlags.Add(1)
+			}
+			if rule.Action == ContentBlock && blockReason == "" {
+				blockReason = fmt.Sprintf("tool result item %d classified as %s", i, c.Kind)
+			}
+		}
+	}
+
+	d.Details = withDetailMap(d.Details, "content_kinds", kinds)
+	if len(matches) > 0 {
+		d.Details = withDetailMap(d.Details, "content_matches", matches)
+		log.Printf("router: session %s: %s result content matched %d content rules", r.sessionID, d.Tool, len(matches))
+	}
+	return blockReason
+}
+
+// matches reports whether the rule applies to a classified item of tool.
+func (rule *ContentRule) matches(tool string, c classify.Result) bool {
+	if c.Confidence < rule.MinConfidence {
+		return false
+	}
+	if len(rule.Tools) > 0 && !slices.Contains(rule.Tools, tool) {
+		return false
+	}
+	for _, k := range rule.Kinds {
+		if k == c.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// withDetailMap returns a copy of details with an additional entry.
+func withDetailMap(details map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(details)+1)
+	for k, v := range details {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/classify"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestContentPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentPolicy = &ContentPolicy{Rules: []ContentRule{
+		{Kinds: []classify.Kind{classify.KindEncoded}, Action: ContentBlock},
+		{Tools: []string{"search_docs"}, Kinds: []classify.Kind{classify.KindCode}, Action: ContentFlag},
+	}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	var reply string
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": reply}},
+		})
+		return jsonrpc.Serialize(resp)
+	}
+	call := func(tool string) *jsonrpc.Message {
+		t.Helper()
+		req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool, "arguments": map[string]string{}}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		response, err := r.RouteMessage(data)
+		if err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+		resp, _ := jsonrpc.Parse(response)
+		return resp
+	}
+
+	reply = strings.Repeat("QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVo=", 4)
+	if resp := call("fetch"); resp.Error == nil {
+		t.Error("encoded blob should be blocked")
+	}
+
+	reply = "func main() {\n\tos.Exit(1)\n}"
+	if resp := call("search_docs"); resp.Error != nil {
+		t.Errorf("flag rule should not block: %v", resp.Error)
+	}
+	d := r.RecentDecisions(1)[0]
+	if d.Details["content_matches"] == nil {
+		t.Error("expected flagged content recorded on decision")
+	}
+
+	reply = "Plain documentation text."
+	if resp := call("search_docs"); resp.Error != nil {
+		t.Errorf("prose should pass: %v", resp.Error)
+	}
+}
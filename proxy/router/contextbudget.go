@@ -0,0 +1,193 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// NotifyContextBudget is the notification sent to the client when a
+// result first takes the session past its context budget.
+const NotifyContextBudget = "notifications/sentinel/context_budget"
+
+// DefaultContextResultBytes is the content kept of a result trimmed for
+// the context budget when the budget sets no size.
+const DefaultContextResultBytes = 8 << 10
+
+// bytesPerToken estimates model tokens from content bytes.
+const bytesPerToken = 4
+
+// ContextBudget bounds the content a session delivers to the client's
+// model: tool results and resource contents, counted by response size.
+//
+// Once a result would take the session past Threshold, a result larger
+// than MaxResultBytes is trimmed to that size: text is cut, binary
+// items that no longer fit are dropped, structured content is removed,
+// and a notice saying so is added to the result. The client is sent a
+// NotifyContextBudget notification the first time a result reaches
+// past the threshold.
+//
+// # Security Notes
+//
+// Context stuffing floods the model with server-chosen text until the
+// user's instructions fall out of its window, and every byte delivered
+// is paid for in tokens. Trimming keeps later results useful but
+// bounded; the model sees that content was left out.
+type ContextBudget struct {
+	// Threshold is the content bytes delivered before large results
+	// are trimmed
+	Threshold uint64
+
+	// MaxResultBytes is the content kept of each trimmed result (0
+	// uses DefaultContextResultBytes)
+	MaxResultBytes int
+}
+
+// ContextUsage is the content a session delivered to the client.
+type ContextUsage struct {
+	// Threshold is the session's context budget (zero is unlimited)
+	Threshold uint64 `json:"threshold"`
+
+	// Bytes is the content delivered, after trimming
+	Bytes uint64 `json:"bytes"`
+
+	// Tokens estimates Bytes in model tokens
+	Tokens uint64 `json:"tokens"`
+
+	// Exceeded reports whether the session reached the threshold, so
+	// large results are trimmed
+	Exceeded bool `json:"exceeded"`
+
+	// Trimmed is the number of results trimmed for the budget
+	Trimmed uint64 `json:"trimmed"`
+}
+
+// Context returns the session's delivered content and context budget.
+func (r *Router) Context() ContextUsage {
+	u := ContextUsage{Bytes: r.contextBytes.Load(), Trimmed: r.stats.ContextTrimmed.Load()}
+	u.Tokens = u.Bytes / bytesPerToken
+	if r.contextBudget != nil {
+		u.Threshold = r.contextBudget.Threshold
+		u.Exceeded = r.contextNotice.Load()
+	}
+	return u
+}
+
+// chargeContext counts a tools/call or resources/read response against
+// the context budget, trimming it if it is large and the budget is
+// spent, and returns the response to deliver.
+func (r *Router) chargeContext(d *Decision, msg *jsonrpc.Message, response []byte) []byte {
+	b := r.contextBudget
+	used := r.contextBytes.Load()
+	exceeded := b != nil && used+uint64(len(response)) > b.Threshold
+	if exceeded && len(response) > b.MaxResultBytes {
+		if trimmed, ok := r.trimResult(msg, response); ok {
+			r.stats.ContextTrimmed.Add(1)
+			d.Details = withDetailMap(d.Details, "context_trimmed", map[string]int{"from": len(response), "to": len(trimmed)})
+			log.Printf("router: session %s: trimmed %s result from %d to %d bytes: context budget of %d bytes exceeded", r.sessionID, msg.Method, len(response), len(trimmed), b.Threshold)
+			response = trimmed
+		}
+	}
+	total := r.contextBytes.Add(uint64(len(response)))
+	if exceeded && r.contextNotice.CompareAndSwap(false, true) {
+		r.noteContext(total)
+	}
+	return response
+}
+
+// trimResult cuts the content of a tools/call or resources/read
+// response to the budget's MaxResultBytes. It reports false if the
+// response has no content to trim.
+func (r *Router) trimResult(msg *jsonrpc.Message, response []byte) ([]byte, bool) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return nil, false
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result == nil {
+		return nil, false
+	}
+	field := "content"
+	if msg.Method == "resources/read" {
+		field = "contents"
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(result[field], &items); err != nil {
+		return nil, false
+	}
+
+	allowance := r.contextBudget.MaxResultBytes
+	kept := items[:0]
+	for _, item := range items {
+		if text, ok := itemText(item); ok {
+			if len(text) > allowance {
+				setItemText(item, cutText(text, allowance))
+			}
+			allowance -= min(len(text), allowance)
+			kept = append(kept, item)
+			continue
+		}
+		// Binary content is kept whole or not at all
+		raw, _ := json.Marshal(item)
+		if len(raw) > allowance {
+			continue
+		}
+		allowance -= len(raw)
+		kept = append(kept, item)
+	}
+	notice := fmt.Sprintf("[mcp-sentinel: result trimmed to %d bytes; the session's context budget of %d bytes is exceeded]", r.contextBudget.MaxResultBytes, r.contextBudget.Threshold)
+	encoded, _ := json.Marshal(notice)
+	if msg.Method == "resources/read" {
+		uri, _ := json.Marshal(jsonrpc.ExtractResourceURI(msg))
+		kept = append(kept, map[string]json.RawMessage{"uri": uri, "mimeType": json.RawMessage(`"text/plain"`), "text": encoded})
+	} else {
+		kept = append(kept, map[string]json.RawMessage{"type": json.RawMessage(`"text"`), "text": encoded})
+	}
+	result[field], err = json.Marshal(kept)
+	if err != nil {
+		return nil, false
+	}
+	// Structured content duplicates the content it was trimmed from
+	delete(result, "structuredContent")
+
+	trimmed, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return nil, false
+	}
+	out, err := jsonrpc.Serialize(trimmed)
+	return out, err == nil
+}
+
+// cutText returns the longest prefix of text of at most n bytes that
+// ends on a character boundary.
+func cutText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// noteContext tells the client its session passed the context budget.
+func (r *Router) noteContext(total uint64) {
+	log.Printf("router: session %s: context budget exceeded: %d of %d bytes delivered", r.sessionID, total, r.contextBudget.Threshold)
+	if r.upstream == nil {
+		// The transport is the server connection
+		return
+	}
+	params := map[string]interface{}{
+		"session_id":       r.sessionID,
+		"threshold":        r.contextBudget.Threshold,
+		"bytes":            total,
+		"tokens":           total / bytesPerToken,
+		"max_result_bytes": r.contextBudget.MaxResultBytes,
+	}
+	if err := r.notify(NotifyContextBudget, params); err != nil {
+		log.Printf("router: session %s: failed to send context budget notice: %v", r.sessionID, err)
+	}
+}
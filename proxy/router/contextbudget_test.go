@@ -0,0 +1,86 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestContextBudget(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContextBudget = &ContextBudget{Threshold: 3000, MaxResultBytes: 100}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	text := strings.Repeat("é", 500) // 1000 bytes
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{
+			"content": []interface{}{
+				map[string]interface{}{"type": "text", "text": text},
+				map[string]interface{}{"type": "image", "data": strings.Repeat("A", 200), "mimeType": "image/png"},
+			},
+			"structuredContent": map[string]interface{}{"text": text},
+		})
+		return jsonrpc.Serialize(resp)
+	}
+
+	call := func() *mcptypes.CallToolResult {
+		t.Helper()
+		response, err := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`))
+		if err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+		msg, _ := jsonrpc.Parse(response)
+		result, err := mcptypes.DecodeResult[mcptypes.CallToolResult](msg)
+		if err != nil {
+			t.Fatalf("result does not decode: %v: %s", err, response)
+		}
+		return result
+	}
+
+	// Results within the budget are delivered whole
+	if result := call(); len(result.Content) != 2 || result.Content[0].Text != text {
+		t.Fatalf("result under the threshold changed: %+v", result)
+	}
+	result := call()
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"text cut on a character boundary", len(result.Content[0].Text) == 100 && json.Valid([]byte(`"`+result.Content[0].Text+`"`))},
+		{"image dropped", len(result.Content) == 2 && result.Content[1].Type == "text"},
+		{"notice added", strings.Contains(result.Content[len(result.Content)-1].Text, "context budget of 3000 bytes")},
+		{"structured content removed", result.StructuredContent == nil},
+	}
+	for _, tt := range tests {
+		if !tt.ok {
+			t.Errorf("%s: %+v", tt.name, result)
+		}
+	}
+
+	u := r.Context()
+	if !u.Exceeded || u.Trimmed != 1 || u.Tokens != u.Bytes/4 || !r.contextNotice.Load() {
+		t.Errorf("Context() = %+v", u)
+	}
+}
+
+func TestCutText(t *testing.T) {
+	tests := []struct {
+		text     string
+		n        int
+		expected string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+	}
+	for _, tt := range tests {
+		if got := cutText(tt.text, tt.n); got != tt.expected {
+			t.Errorf("cutText(%q, %d) = %q, expected %q", tt.text, tt.n, got, tt.expected)
+		}
+	}
+}
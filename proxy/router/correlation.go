@@ -0,0 +1,512 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// ErrDuplicateRequestID is returned when a client reuses the ID of a
+// request that is still awaiting its response.
+var ErrDuplicateRequestID = errors.New("router: duplicate request id in flight")
+
+// ErrRequestTimeout is returned when the server does not answer a
+// request within Config.RequestTimeout.
+var ErrRequestTimeout = errors.New("router: request timed out")
+
+// maxSettledIDs bounds the answered and abandoned request IDs
+// remembered to tell duplicate and late responses from unknown ones.
+const maxSettledIDs = 1024
+
+// maxRelayedRequests bounds the server-initiated requests awaiting the
+// client's response; the oldest is forgotten when the table is full.
+const maxRelayedRequests = 1024
+
+// DefaultOrphanAfter is how long a request may go unanswered before it
+// is reported as orphaned.
+const DefaultOrphanAfter = 5 * time.Minute
+
+// Pending request directions, as reported by PendingRequests.
+const (
+	// DirectionToServer is a client request awaiting the server
+	DirectionToServer = "to_server"
+	// DirectionToClient is a server request awaiting the client
+	DirectionToClient = "to_client"
+)
+
+// PendingRequest is a request awaiting its response.
+type PendingRequest struct {
+	ID        string        `json:"id"`
+	Method    string        `json:"method"`
+	Direction string        `json:"direction"`
+	Age       time.Duration `json:"age_ns"`
+}
+
+// responseMatch classifies a response by its ID.
+type responseMatch int
+
+const (
+	// responseUnknown answers no request ever sent (or long forgotten)
+	responseUnknown responseMatch = iota
+	// responseDelivered answers an outstanding request
+	responseDelivered
+	// responseDuplicate answers a request already answered
+	responseDuplicate
+	// responseLate answers a request abandoned, e.g. on timeout
+	responseLate
+)
+
+// String returns the classification for logs.
+func (m responseMatch) String() string {
+	switch m {
+	case responseDelivered:
+		return "delivered"
+	case responseDuplicate:
+		return "duplicate"
+	case responseLate:
+		return "late"
+	}
+	return "unknown"
+}
+
+// pendingRequest is one outstanding request.
+type pendingRequest struct {
+	ch       chan []byte // nil for relayed requests nobody waits on
+	method   string
+	sent     time.Time
+	orphaned bool
+}
+
+// pendingTable correlates responses with the outstanding requests of
+// one session and direction.
+//
+// Settled IDs, whose request was answered or abandoned, are remembered
+// for a while, so a second response to the same ID is told apart from
+// one that arrives after its request timed out, and both from a
+// response to an ID that was never used.
+//
+// # Security Notes
+//
+// An unsolicited response is a known MCP attack: a peer that can inject
+// a response with a guessed ID substitutes its own result for the real
+// one, and a duplicate can overwrite a result already delivered. Every
+// response is delivered at most once and only to a request sent with
+// its ID; anything else is dropped.
+type pendingTable struct {
+	mu      sync.Mutex
+	byID    map[string]*pendingRequest
+	settled map[string]responseMatch
+	ring    []string
+	next    int
+	closed  error
+}
+
+func newPendingTable() *pendingTable {
+	return &pendingTable{
+		byID:    make(map[string]*pendingRequest),
+		settled: make(map[string]responseMatch),
+		ring:    make([]string, maxSettledIDs),
+	}
+}
+
+// add registers a request the caller waits on; its response is sent on
+// the returned channel, which is closed if the request is abandoned by
+// fail.
+func (p *pendingTable) add(id, method string) (chan []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed != nil {
+		return nil, p.closed
+	}
+	if _, ok := p.byID[id]; ok {
+		return nil, ErrDuplicateRequestID
+	}
+	ch := make(chan []byte, 1)
+	p.byID[id] = &pendingRequest{ch: ch, method: method, sent: time.Now()}
+	delete(p.settled, id)
+	return ch, nil
+}
+
+// track registers a relayed request nobody waits on. Requests older
+// than timeout (when positive) are abandoned first, and the oldest is
+// forgotten once maxRelayedRequests are outstanding.
+func (p *pendingTable) track(id, method string, timeout time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.byID[id]; ok {
+		return ErrDuplicateRequestID
+	}
+	now := time.Now()
+	var oldest string
+	for pid, req := range p.byID {
+		if timeout > 0 && now.Sub(req.sent) > timeout {
+			p.settleLocked(pid, responseLate)
+			continue
+		}
+		if oldest == "" || req.sent.Before(p.byID[oldest].sent) {
+			oldest = pid
+		}
+	}
+	if len(p.byID) >= maxRelayedRequests {
+		p.settleLocked(oldest, responseLate)
+	}
+	p.byID[id] = &pendingRequest{method: method, sent: now}
+	delete(p.settled, id)
+	return nil
+}
+
+// remove forgets a request the caller stopped waiting for. If no
+// response was delivered, a later one is classified as late.
+func (p *pendingTable) remove(id string, ch chan []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if req, ok := p.byID[id]; ok && req.ch == ch {
+		p.settleLocked(id, responseLate)
+	}
+}
+
+// match settles the request a response answers and hands the response
+// to its waiter, if any.
+func (p *pendingTable) match(id string, data []byte) responseMatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	req, ok := p.byID[id]
+	if !ok {
+		return p.settled[id]
+	}
+	p.settleLocked(id, responseDuplicate)
+	if req.ch != nil {
+		req.ch <- data
+	}
+	return responseDelivered
+}
+
+// method returns the method of outstanding request id, or "".
+func (p *pendingTable) method(id string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if req, ok := p.byID[id]; ok {
+		return req.method
+	}
+	return ""
+}
+
+// deliver hands a response to the waiting request. It reports false if
+// no request with that ID is pending.
+func (p *pendingTable) deliver(id string, data []byte) bool {
+	return p.match(id, data) == responseDelivered
+}
+
+// settleLocked removes an outstanding request and remembers how a later
+// response to its ID is classified. The caller holds p.mu.
+func (p *pendingTable) settleLocked(id string, later responseMatch) {
+	delete(p.byID, id)
+	if old := p.ring[p.next]; old != "" {
+		delete(p.settled, old)
+	}
+	p.ring[p.next] = id
+	p.settled[id] = later
+	p.next = (p.next + 1) % len(p.ring)
+}
+
+// fail aborts every pending request. A permanent failure also rejects
+// future requests with err.
+func (p *pendingTable) fail(err error, permanent bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, req := range p.byID {
+		if req.ch != nil {
+			close(req.ch)
+		}
+		p.settleLocked(id, responseLate)
+	}
+	if permanent {
+		p.closed = err
+	}
+}
+
+// err returns the permanent failure, if any.
+func (p *pendingTable) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// orphans marks the requests outstanding for age or longer as orphaned
+// and returns those not marked before. Relayed requests nobody waits on
+// are forgotten, so a late answer is dropped; a waiter keeps waiting.
+func (p *pendingTable) orphans(age time.Duration, now time.Time, direction string) []PendingRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []PendingRequest
+	for id, req := range p.byID {
+		if req.orphaned || now.Sub(req.sent) < age {
+			continue
+		}
+		req.orphaned = true
+		out = append(out, PendingRequest{ID: id, Method: req.method, Direction: direction, Age: now.Sub(req.sent)})
+		if req.ch == nil {
+			p.settleLocked(id, responseLate)
+		}
+	}
+	return out
+}
+
+// list appends the outstanding requests to out.
+func (p *pendingTable) list(out []PendingRequest, direction string) []PendingRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, req := range p.byID {
+		out = append(out, PendingRequest{ID: id, Method: req.method, Direction: direction, Age: now.Sub(req.sent)})
+	}
+	return out
+}
+
+// len returns the number of outstanding requests.
+func (p *pendingTable) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.byID)
+}
+
+// PendingRequests returns the session's requests awaiting a response,
+// oldest first: client requests awaiting the server and server requests
+// awaiting the client. It is empty for routers not created with
+// NewWithTransports.
+func (r *Router) PendingRequests() []PendingRequest {
+	if r.pending == nil {
+		return nil
+	}
+	out := r.pending.list(nil, DirectionToServer)
+	out = r.relayed.list(out, DirectionToClient)
+	sort.Slice(out, func(i, j int) bool { return out[i].Age > out[j].Age })
+	return out
+}
+
+// sweepOrphans reports the requests unanswered for orphanAfter as
+// orphaned: they are counted, logged, and audited once each, and server
+// requests the client never answered are forgotten.
+func (r *Router) sweepOrphans(now time.Time) {
+	if r.pending == nil || r.orphanAfter < 0 {
+		return
+	}
+	orphans := r.pending.orphans(r.orphanAfter, now, DirectionToServer)
+	orphans = append(orphans, r.relayed.orphans(r.orphanAfter, now, DirectionToClient)...)
+	for _, req := range orphans {
+		dir := audit.ClientToServer
+		if req.Direction == DirectionToServer {
+			r.stats.OrphanedRequests.Add(1)
+		} else {
+			dir = audit.ServerToClient
+			r.stats.OrphanedRelayed.Add(1)
+		}
+		reason := fmt.Sprintf("request %s unanswered after %v", req.ID, req.Age.Round(time.Second))
+		log.Printf("router: session %s: orphaned %s %s", r.sessionID, req.Method, reason)
+		if r.audit != nil {
+			r.writeAudit(&audit.Record{
+				Time:      now.UTC(),
+				Session:   r.sessionID,
+				Direction: dir,
+				Method:    req.Method,
+				Decision:  audit.DecisionOrphaned,
+				Reason:    reason,
+			})
+		}
+	}
+}
+
+// sweepLoop sweeps for orphaned requests until done is closed.
+func (r *Router) sweepLoop(done <-chan struct{}) {
+	if r.orphanAfter < 0 {
+		return
+	}
+	ticker := time.NewTicker(max(r.orphanAfter/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.sweepOrphans(now)
+		case <-done:
+			return
+		}
+	}
+}
+
+// auditDropped records a response dropped because it answers no
+// outstanding request.
+func (r *Router) auditDropped(dir audit.Direction, msg *jsonrpc.Message, m responseMatch) {
+	if r.audit == nil {
+		return
+	}
+	r.writeAudit(&audit.Record{
+		Time:      time.Now().UTC(),
+		Session:   r.sessionID,
+		Direction: dir,
+		Decision:  audit.DecisionDropped,
+		Reason:    fmt.Sprintf("%s response for id %s", m, msg.ID),
+	})
+}
+
+// exchange sends a request to the server and waits for the response
+// with the same ID, delivered by serverLoop. With a RequestTimeout, a
+// request left unanswered is cancelled on the server and fails with
+// ErrRequestTimeout.
+func (r *Router) exchange(data []byte) ([]byte, error) {
+	msg, err := jsonrpc.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	id := string(msg.ID)
+	ch, err := r.pending.add(id, msg.Method)
+	if err != nil {
+		return nil, err
+	}
+	defer r.pending.remove(id, ch)
+	var token string
+	if r.partials != nil && msg.Method == "tools/call" {
+		if token = progressToken(msg); token != "" {
+			r.partials.start(token)
+			defer r.partials.finish(token)
+		}
+	}
+
+	r.serverOnce.Do(func() { go r.serverLoop() })
+	turn := r.takeTurn(id)
+	if turn != nil {
+		<-turn.prev
+	}
+	err = r.upstream.Send(data)
+	if turn != nil {
+		turn.release()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout <-chan time.Time
+	if r.requestTimeout > 0 {
+		timer := time.NewTimer(r.requestTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			if err := r.pending.err(); err != nil {
+				return nil, err
+			}
+			return nil, transport.ErrServerExited
+		}
+		return response, nil
+	case <-timeout:
+		r.stats.RequestTimeouts.Add(1)
+		r.cancelUpstream(msg.ID, "request timed out")
+		err := fmt.Errorf("%w after %v", ErrRequestTimeout, r.requestTimeout)
+		if token != "" {
+			if out := r.partials.finish(token); out != nil && len(out.chunks) > 0 {
+				return nil, &partialTimeout{err: err, output: out}
+			}
+		}
+		return nil, err
+	}
+}
+
+// cancelUpstream tells the server to stop working on request id.
+func (r *Router) cancelUpstream(id json.RawMessage, reason string) {
+	cancel, err := jsonrpc.NewNotification("notifications/cancelled", map[string]interface{}{
+		"requestId": id,
+		"reason":    reason,
+	})
+	if err != nil {
+		return
+	}
+	data, err := jsonrpc.Serialize(cancel)
+	if err != nil {
+		return
+	}
+	if err := r.upstream.Send(data); err != nil {
+		log.Printf("router: session %s: cancelling request %s failed: %v", r.sessionID, id, err)
+	}
+}
+
+// acceptServerResponse delivers a server response to the waiting client
+// request. Responses to no outstanding request are dropped; a
+// duplicate one is reported to the anomaly scorer as a replay.
+func (r *Router) acceptServerResponse(msg *jsonrpc.Message, data []byte) {
+	m := r.pending.match(string(msg.ID), data)
+	switch m {
+	case responseDelivered:
+		return
+	case responseDuplicate:
+		r.stats.DuplicateResponses.Add(1)
+		r.RecordAnomaly(anomaly.SignalReplay, fmt.Sprintf("server answered request %s twice", msg.ID))
+		log.Printf("router: session %s: dropped duplicate server response for id %s", r.sessionID, msg.ID)
+	case responseLate:
+		r.stats.LateResponses.Add(1)
+		log.Printf("router: session %s: dropped late server response for id %s", r.sessionID, msg.ID)
+	default:
+		r.stats.UnmatchedResponses.Add(1)
+		log.Printf("router: session %s: dropped server response with unknown id %s", r.sessionID, msg.ID)
+	}
+	r.auditDropped(audit.ServerToClient, msg, m)
+}
+
+// acceptClientResponse reports whether a client response answers an
+// outstanding server-initiated request and may be relayed to the
+// server.
+func (r *Router) acceptClientResponse(msg *jsonrpc.Message) bool {
+	m := r.relayed.match(string(msg.ID), nil)
+	if m == responseDelivered {
+		return true
+	}
+	r.stats.ClientResponsesRejected.Add(1)
+	log.Printf("router: session %s: dropped %s client response for id %s", r.sessionID, m, msg.ID)
+	r.auditDropped(audit.ClientToServer, msg, m)
+	return false
+}
+
+// receiveResponse reads the server's response to request from the
+// shared transport of a router without a separate server transport.
+// Responses to other IDs are dropped, and so are server requests, which
+// this mode cannot relay. Notifications go to the OnNotification
+// handler; a malformed message is returned for the caller to reject.
+func (r *Router) receiveResponse(request []byte) ([]byte, error) {
+	req, err := jsonrpc.Parse(request)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		data, err := r.receiveServer()
+		if err != nil {
+			return nil, err
+		}
+		msg, err := jsonrpc.Parse(data)
+		if err != nil {
+			r.captureRepro(crash.ReproParse, req.Method, data, err)
+			return data, nil
+		}
+		switch {
+		case msg.Type() == jsonrpc.TypeNotification:
+			r.deliverNotification(msg, data)
+		case msg.Type() != jsonrpc.TypeResponse:
+			log.Printf("router: session %s: dropped server %s awaiting response %s", r.sessionID, msg.Method, req.ID)
+		case string(msg.ID) != string(req.ID):
+			r.stats.UnmatchedResponses.Add(1)
+			log.Printf("router: session %s: dropped server response with id %s awaiting %s", r.sessionID, msg.ID, req.ID)
+			r.auditDropped(audit.ServerToClient, msg, responseUnknown)
+		default:
+			return data, nil
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestPendingTable_Classification(t *testing.T) {
+	p := newPendingTable()
+	answered, _ := p.add("1", "tools/call")
+	abandoned, _ := p.add("2", "tools/list")
+	p.track("s1", "sampling/createMessage", 0)
+
+	if got := p.list(nil, DirectionToServer); len(got) != 3 {
+		t.Fatalf("outstanding = %+v", got)
+	}
+	p.remove("2", abandoned)
+
+	tests := []struct {
+		id   string
+		want responseMatch
+	}{
+		{"1", responseDelivered},
+		{"1", responseDuplicate},
+		{"2", responseLate},
+		{"s1", responseDelivered},
+		{"s1", responseDuplicate},
+		{"99", responseUnknown},
+	}
+	for _, tt := range tests {
+		if got := p.match(tt.id, []byte("ok")); got != tt.want {
+			t.Errorf("match(%s) = %s, expected %s", tt.id, got, tt.want)
+		}
+	}
+	if string(<-answered) != "ok" {
+		t.Error("response not delivered")
+	}
+
+	// A reused ID is outstanding again
+	p.add("1", "tools/call")
+	if got := p.match("1", nil); got != responseDelivered {
+		t.Errorf("reused ID matched as %s", got)
+	}
+
+	// Relayed requests expire and are bounded
+	p.track("old", "roots/list", 0)
+	time.Sleep(5 * time.Millisecond)
+	p.track("new", "roots/list", time.Millisecond)
+	if got := p.match("old", nil); got != responseLate {
+		t.Errorf("expired request matched as %s", got)
+	}
+	for i := 0; i < maxRelayedRequests+1; i++ {
+		p.track(fmt.Sprintf("bulk-%d", i), "roots/list", 0)
+	}
+	if n := p.len(); n != maxRelayedRequests {
+		t.Errorf("outstanding = %d, expected %d", n, maxRelayedRequests)
+	}
+}
+
+func TestRunBidirectional_Correlation(t *testing.T) {
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	cfg := DefaultConfig()
+	cfg.RequestTimeout = 50 * time.Millisecond
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// A duplicate response is dropped, not delivered to a later request
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	expectMessage(t, server, `"method":"tools/list"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`))
+	expectMessage(t, client, `"tools":[]`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"evil"}]}}`))
+
+	// A client response to no server request is not relayed
+	client.Send([]byte(`{"jsonrpc":"2.0","id":"forged","result":{}}`))
+	server.Send([]byte(`{"jsonrpc":"2.0","id":"s1","method":"roots/list"}`))
+	expectMessage(t, client, `"method":"roots/list"`)
+	if pending := r.PendingRequests(); len(pending) != 1 || pending[0].Direction != DirectionToClient || pending[0].Method != "roots/list" {
+		t.Errorf("PendingRequests = %+v", pending)
+	}
+	client.Send([]byte(`{"jsonrpc":"2.0","id":"s1","result":{"roots":[]}}`))
+	expectMessage(t, server, `"id":"s1"`)
+
+	// An unanswered request times out: the client gets an error and
+	// the server a cancellation; the late answer is dropped
+	client.Send([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file","arguments":{}}}`))
+	expectMessage(t, server, `"method":"tools/call"`)
+	expectMessage(t, server, `"method":"notifications/cancelled"`)
+	expectMessage(t, client, `"id":2,"error"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":2,"result":{"content":[]}}`))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.stats.LateResponses.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	s := r.Stats()
+	tests := []struct {
+		name     string
+		got      uint64
+		expected uint64
+	}{
+		{"duplicate responses", s.DuplicateResponses, 1},
+		{"rejected client responses", s.ClientResponsesRejected, 1},
+		{"relayed to server", s.RelayedToServer, 1},
+		{"request timeouts", s.RequestTimeouts, 1},
+		{"late responses", s.LateResponses, 1},
+		{"unmatched responses", s.UnmatchedResponses, 0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s = %d, expected %d", tt.name, tt.got, tt.expected)
+		}
+	}
+	select {
+	case data := <-client.in:
+		t.Errorf("unexpected message to client: %s", data)
+	default:
+	}
+}
+
+func TestReceiveResponse_MatchesID(t *testing.T) {
+	replies := []string{
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{}}`,
+		`{"jsonrpc":"2.0","id":7,"result":{"forged":true}}`,
+		`{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`,
+	}
+	mt := &mockTransport{receiveFunc: func() ([]byte, error) {
+		if len(replies) == 0 {
+			return nil, errors.New("eof")
+		}
+		next := replies[0]
+		replies = replies[1:]
+		return []byte(next), nil
+	}}
+	r := New(mt, sentinel.NewClient())
+
+	req, _ := jsonrpc.NewRequest("tools/list", nil, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, err := r.RouteMessage(data)
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	if !strings.Contains(string(response), `"ok":true`) {
+		t.Errorf("response = %s", response)
+	}
+	if got := r.stats.UnmatchedResponses.Load(); got != 1 {
+		t.Errorf("UnmatchedResponses = %d, expected 1", got)
+	}
+}
+
+func TestSweepOrphans(t *testing.T) {
+	rec := &auditRecorder{}
+	cfg := DefaultConfig()
+	cfg.OrphanAfter = time.Minute
+	cfg.Audit = rec
+	r := NewWithTransports(&mockTransport{}, &mockTransport{}, sentinel.NewClient(), cfg)
+
+	waiting, _ := r.pending.add("1", "tools/call")
+	r.relayed.track("s1", "sampling/createMessage", 0)
+	r.sweepOrphans(time.Now())
+	if len(rec.records) != 0 {
+		t.Fatalf("fresh requests reported: %+v", rec.records)
+	}
+
+	later := time.Now().Add(2 * time.Minute)
+	r.sweepOrphans(later)
+	r.sweepOrphans(later)
+	s := r.Stats()
+	if s.OrphanedRequests != 1 || s.OrphanedRelayed != 1 {
+		t.Errorf("orphaned = %d to server, %d to client; expected 1 each", s.OrphanedRequests, s.OrphanedRelayed)
+	}
+	if len(rec.records) != 2 {
+		t.Fatalf("audit records = %+v, expected one per orphan", rec.records)
+	}
+	for _, record := range rec.records {
+		if record.Decision != audit.DecisionOrphaned || !strings.Contains(record.Reason, "unanswered after 2m") {
+			t.Errorf("audit record = %+v", record)
+		}
+	}
+
+	// The waiter keeps waiting; the unanswered server request is gone
+	if r.pending.len() != 1 || r.relayed.len() != 0 {
+		t.Errorf("outstanding = %d to server, %d to client; expected 1 and 0", r.pending.len(), r.relayed.len())
+	}
+	r.acceptServerResponse(&jsonrpc.Message{ID: []byte("1")}, []byte("ok"))
+	if string(<-waiting) != "ok" {
+		t.Error("orphaned request's response not delivered")
+	}
+	if r.acceptClientResponse(&jsonrpc.Message{ID: []byte(`"s1"`)}) {
+		t.Error("answer to a forgotten server request relayed")
+	}
+}
+
+func TestAuditDroppedResponses(t *testing.T) {
+	rec := &auditRecorder{}
+	cfg := DefaultConfig()
+	cfg.Audit = rec
+	r := NewWithTransports(&mockTransport{}, &mockTransport{}, sentinel.NewClient(), cfg)
+
+	r.acceptServerResponse(&jsonrpc.Message{ID: []byte("7")}, nil)
+	r.acceptClientResponse(&jsonrpc.Message{ID: []byte(`"forged"`)})
+	want := []struct {
+		dir    audit.Direction
+		reason string
+	}{
+		{audit.ServerToClient, "unknown response for id 7"},
+		{audit.ClientToServer, `unknown response for id "forged"`},
+	}
+	if len(rec.records) != len(want) {
+		t.Fatalf("audit records = %+v", rec.records)
+	}
+	for i, w := range want {
+		if got := rec.records[i]; got.Decision != audit.DecisionDropped || got.Direction != w.dir || got.Reason != w.reason {
+			t.Errorf("record %d = %+v, expected %s %q", i, got, w.dir, w.reason)
+		}
+	}
+}
+
+func TestSweepOrphans_Disabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OrphanAfter = -1
+	r := NewWithTransports(&mockTransport{}, &mockTransport{}, sentinel.NewClient(), cfg)
+	r.pending.add("1", "tools/call")
+	r.sweepOrphans(time.Now().Add(time.Hour))
+	if n := r.Stats().OrphanedRequests; n != 0 {
+		t.Errorf("OrphanedRequests = %d with the check disabled", n)
+	}
+}
@@ -0,0 +1,281 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+)
+
+// MetaDecisionID is the _meta key carrying the decision ID on successful
+// results when decision annotation is enabled.
+const MetaDecisionID = "io.mcp-sentinel/decisionId"
+
+// DefaultDecisionLogSize is the number of recent decisions retained for lookup.
+const DefaultDecisionLogSize = 1024
+
+// Verdict is the outcome of routing a single message.
+type Verdict string
+
+// Routing verdicts.
+const (
+	VerdictAllowed Verdict = "allowed"
+	VerdictBlocked Verdict = "blocked"
+	VerdictError   Verdict = "error"
+)
+
+// Decision records how the router handled one message.
+//
+// Every routed message is assigned a decision ID. The ID is returned to
+// the client in error data (and optionally in result _meta), so a user
+// reporting "my call was blocked" can be matched to this record.
+type Decision struct {
+	ID        string                 `json:"id"`
+	SessionID string                 `json:"session_id"`
+	Time      time.Time              `json:"time"`
+	Method    string                 `json:"method,omitempty"`
+	Tool      string                 `json:"tool,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	Verdict   Verdict                `json:"verdict"`
+	Reason    string                 `json:"reason,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+
+	// collect enables audit event buffering; events is owned by the
+	// goroutine routing the message until the decision is finished
+	collect bool
+	events  []Event
+
+	// gas is the pre-call charge awaiting settlement and depth the
+	// call's nesting depth (tools/call only)
+	gas   *gasCharge
+	depth int
+
+	// partial marks a response salvaged from a timed-out tool call
+	partial bool
+
+	// code is the error code of the reply the router generated itself
+	// (zero when the message was forwarded)
+	code int
+
+	// started is when routing began and upstream the time spent
+	// waiting on the server, so the proxy's added latency is the
+	// difference
+	started  time.Time
+	upstream time.Duration
+
+	// checks lists the checks that ran and passed; chainIn is the
+	// verified chain metadata of the request and deferredTo the hop
+	// whose checks were trusted instead of running them (both may be
+	// nil)
+	checks     []string
+	chainIn    *ChainMeta
+	deferredTo *ChainHop
+
+	// requireCouncil is set when the policy, argument taint, or read
+	// receipt escalation requires a council vote, and taint lists
+	// arguments copied from results
+	requireCouncil bool
+	taint          []TaintedArgument
+
+	// downgrades are the policy downgrades applied to the call, whose
+	// staged writes are journaled once it clears every check
+	downgrades []policy.Transform
+
+	// retryKey identifies a tool call for read receipts and conformance
+	// scoring (empty when both are disabled)
+	retryKey string
+
+	// inflightID is the request ID conformance scoring holds as in
+	// flight until the decision finishes (empty when it is disabled)
+	inflightID string
+
+	// reasons collects the checks' reasons, as "check: reason", for the
+	// audit trail
+	reasons []string
+
+	// auditArguments and auditResult are the tool call payloads
+	// recorded in the audit trail (empty unless AuditPayloadBytes is
+	// set)
+	auditArguments string
+	auditResult    string
+
+	// auditOriginal is server content rewritten before delivery, as
+	// JSON, recorded in the audit trail
+	auditOriginal string
+
+	// findings is the checks' evidence for a council vote on the call
+	findings []sentinel.Finding
+
+	// ctx carries the trace of the stage routing the message, under
+	// span, the message's root span (nil when tracing is disabled)
+	ctx  context.Context
+	span *tracing.Span
+}
+
+// ran records that a check passed. d may be nil.
+func (d *Decision) ran(check string) {
+	if d != nil {
+		d.checks = append(d.checks, check)
+	}
+}
+
+// ErrorData is the data object attached to error responses the router
+// generates itself.
+type ErrorData struct {
+	// Reason explains why the message was rejected
+	Reason string `json:"reason"`
+
+	// DecisionID identifies the decision record for this message
+	DecisionID string `json:"decision_id"`
+
+	// Failure says whose failure the error reports
+	Failure FailureClass `json:"failure,omitempty"`
+
+	// Retry says whether and when the request may succeed if sent
+	// again
+	Retry RetryHint `json:"retry,omitempty"`
+
+	// Truncated marks Partial as the incomplete output of a request
+	// that timed out
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Partial is the tool result salvaged from the server's output
+	// before the timeout; see PartialResults
+	Partial json.RawMessage `json:"partial,omitempty"`
+
+	// RetryAfterMS is how long a rate-limited client should wait before
+	// retrying, in milliseconds
+	RetryAfterMS int64 `json:"retry_after_ms,omitempty"`
+}
+
+// decisionLog is a fixed-size ring of recent decisions indexed by ID.
+type decisionLog struct {
+	mu   sync.Mutex
+	ring []*Decision
+	next int
+	byID map[string]*Decision
+}
+
+// newDecisionLog creates a decision log holding up to size records.
+func newDecisionLog(size int) *decisionLog {
+	if size <= 0 {
+		size = DefaultDecisionLogSize
+	}
+	return &decisionLog{
+		ring: make([]*Decision, size),
+		byID: make(map[string]*Decision, size),
+	}
+}
+
+// record stores a decision, evicting the oldest if full.
+func (l *decisionLog) record(d *Decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if old := l.ring[l.next]; old != nil {
+		delete(l.byID, old.ID)
+	}
+	l.ring[l.next] = d
+	l.byID[d.ID] = d
+	l.next = (l.next + 1) % len(l.ring)
+}
+
+// get returns a copy of the decision with the given ID.
+func (l *decisionLog) get(id string) (Decision, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.byID[id]
+	if !ok {
+		return Decision{}, false
+	}
+	return *d, true
+}
+
+// recent returns up to n decisions, newest first.
+func (l *decisionLog) recent(n int) []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Decision, 0, n)
+	for i := 1; i <= len(l.ring) && len(out) < n; i++ {
+		d := l.ring[(l.next-i+len(l.ring))%len(l.ring)]
+		if d == nil {
+			break
+		}
+		out = append(out, *d)
+	}
+	return out
+}
+
+// newDecision starts a decision record for an incoming message.
+func (r *Router) newDecision() *Decision {
+	d := &Decision{
+		ID:        newDecisionID(),
+		SessionID: r.sessionID,
+		Time:      time.Now().UTC(),
+		collect:   r.eventSink != nil,
+		started:   time.Now(),
+		ctx:       context.Background(),
+	}
+	d.TraceID = d.ID
+	return d
+}
+
+// Decision returns the recorded decision with the given ID.
+func (r *Router) Decision(id string) (Decision, bool) {
+	return r.decisions.get(id)
+}
+
+// RecentDecisions returns up to n recent decisions, newest first.
+func (r *Router) RecentDecisions(n int) []Decision {
+	return r.decisions.recent(n)
+}
+
+// annotateDecision adds the decision ID to a successful result's _meta.
+// Responses without an object result are returned unchanged.
+func annotateDecision(response []byte, decisionID string) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result == nil {
+		return response
+	}
+	meta := map[string]interface{}{}
+	if raw, ok := result["_meta"]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return response
+		}
+	}
+	meta[MetaDecisionID] = decisionID
+
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return response
+	}
+	result["_meta"] = encoded
+
+	annotated, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return response
+	}
+	data, err := jsonrpc.Serialize(annotated)
+	if err != nil {
+		return response
+	}
+	return data
+}
+
+// newDecisionID returns a random decision identifier.
+func newDecisionID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return "d-" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_DecisionIDInErrors(t *testing.T) {
+	r := New(&mockTransport{}, sentinel.NewClient())
+
+	response, err := r.RouteMessage([]byte(`{invalid`))
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	resp, _ := jsonrpc.Parse(response)
+
+	var data ErrorData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatalf("failed to decode error data: %v", err)
+	}
+	if data.DecisionID == "" {
+		t.Fatal("error response missing decision ID")
+	}
+
+	d, ok := r.Decision(data.DecisionID)
+	if !ok {
+		t.Fatalf("decision %s not found", data.DecisionID)
+	}
+	if d.Verdict != VerdictError || d.Reason != data.Reason {
+		t.Errorf("unexpected decision record: %+v", d)
+	}
+}
+
+func TestRouteMessage_DecisionAnnotation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AnnotateDecisions = true
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{
+			"tools": []interface{}{},
+			"_meta": map[string]string{"upstream": "kept"},
+		})
+		return jsonrpc.Serialize(resp)
+	}
+
+	req, _ := jsonrpc.NewRequest("tools/list", nil, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, err := r.RouteMessage(data)
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+
+	resp, _ := jsonrpc.Parse(response)
+	var result struct {
+		Meta map[string]string `json:"_meta"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if result.Meta["upstream"] != "kept" {
+		t.Error("existing _meta entries should be preserved")
+	}
+
+	id := result.Meta[MetaDecisionID]
+	d, ok := r.Decision(id)
+	if !ok || d.Verdict != VerdictAllowed || d.Method != "tools/list" {
+		t.Errorf("decision %q not recorded correctly: %+v", id, d)
+	}
+}
+
+func TestDecisionLog_Eviction(t *testing.T) {
+	l := newDecisionLog(2)
+	for _, id := range []string{"a", "b", "c"} {
+		l.record(&Decision{ID: id})
+	}
+	if _, ok := l.get("a"); ok {
+		t.Error("oldest decision should be evicted")
+	}
+	recent := l.recent(5)
+	if len(recent) != 2 || recent[0].ID != "c" || recent[1].ID != "b" {
+		t.Errorf("unexpected recent decisions: %+v", recent)
+	}
+}
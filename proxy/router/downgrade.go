@@ -0,0 +1,94 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/staging"
+)
+
+// applyDowngrade rewrites a tool call that policy downgrade rules
+// transformed, so the checks that follow and the server see the safer
+// call. Each transform is logged and audited; staged writes are
+// journaled and the client told by commitDowngrades once the call has
+// cleared every check.
+//
+// # Returns
+//   - Message bytes to forward
+//   - Error if the call could not be rewritten
+func (r *Router) applyDowngrade(d *Decision, msg *jsonrpc.Message, verdict policy.Verdict) ([]byte, error) {
+	tool, args, _ := verdict.Downgraded()
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil {
+		return nil, fmt.Errorf("downgrade: malformed params")
+	}
+	params["name"], _ = json.Marshal(tool)
+	params["arguments"] = args
+	rewritten, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("downgrade: %w", err)
+	}
+
+	msg.Params = rewritten
+	data, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return nil, fmt.Errorf("downgrade: %w", err)
+	}
+
+	for _, t := range verdict.Transforms {
+		log.Printf("router: session %s: %s", r.sessionID, downgradeText(t))
+		d.event(EventRewrite, map[string]interface{}{"rule": t.Rule, "from_tool": t.FromTool, "to_tool": t.ToTool, "staged": t.Staged})
+	}
+	r.stats.Downgrades.Add(uint64(len(verdict.Transforms)))
+	d.Details = withDetailMap(d.Details, "downgrades", verdict.Transforms)
+	d.downgrades = verdict.Transforms
+	return data, nil
+}
+
+// commitDowngrades journals the staged writes of a downgraded call that
+// cleared every check and tells the client about each downgrade. A call
+// refused by a later check leaves no journal entry for a write that
+// never happened.
+//
+// # Returns
+//   - Error if a staged write could not be journaled; the call must
+//     then be refused, since a write that is not journaled could not
+//     be reversed
+func (r *Router) commitDowngrades(d *Decision) error {
+	for _, t := range d.downgrades {
+		if t.Staged != "" {
+			entry := staging.Entry{ID: t.ID, Session: r.sessionID, Rule: t.Rule, Tool: t.FromTool, Original: t.Original, Staged: t.Staged}
+			if err := staging.Record(t.StagingDir, entry); err != nil {
+				return fmt.Errorf("downgrade: %w", err)
+			}
+		}
+	}
+	for _, t := range d.downgrades {
+		r.notifyDowngrade(downgradeText(t))
+	}
+	return nil
+}
+
+// downgradeText describes a downgrade for the log and the client.
+func downgradeText(t policy.Transform) string {
+	if t.Staged != "" {
+		return fmt.Sprintf("policy rule %s redirected the %s write to %s into staging as %s (entry %s)", t.Rule, t.FromTool, t.Original, t.Staged, t.ID)
+	}
+	return fmt.Sprintf("policy rule %s downgraded %s to %s", t.Rule, t.FromTool, t.ToTool)
+}
+
+// notifyDowngrade tells the client, with a logging notification, that
+// a tool call was not made as requested.
+func (r *Router) notifyDowngrade(text string) {
+	if r.upstream == nil {
+		// The transport is the server connection
+		return
+	}
+	params := map[string]interface{}{"level": "notice", "logger": protectionLogger, "data": "MCP Sentinel " + text}
+	if err := r.notify("notifications/message", params); err != nil {
+		log.Printf("router: session %s: failed to send downgrade notice: %v", r.sessionID, err)
+	}
+}
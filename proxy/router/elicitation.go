@@ -0,0 +1,255 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/scanner"
+)
+
+// DefaultSensitiveFields lists the field names an ElicitationPolicy
+// strips when it lists none. Names match ignoring case, spaces, dashes,
+// and underscores, anywhere in a field's name or title.
+var DefaultSensitiveFields = []string{
+	"password", "passphrase", "passcode", "secret", "token", "apikey",
+	"privatekey", "credential", "ssn", "socialsecurity", "creditcard",
+	"cardnumber", "cvv", "cvc", "iban", "routingnumber",
+}
+
+// ElicitationPolicy mediates the elicitation/create requests a server
+// sends to have the client ask the user for input.
+//
+// A request whose message or field descriptions the injection scanner
+// flags, or that does not decode, is declined on the user's behalf: the
+// server receives an ElicitDecline result and the client never sees the
+// request. Fields asking for credentials or payment data are stripped
+// from the requested schema, and from the client's answer should it
+// return them anyway.
+//
+// # Security Notes
+//
+// Elicitation puts server-written text in front of the user with the
+// client's authority, a natural phishing channel: "re-enter your
+// password to continue". Stripping is by field name and title only; a
+// server can still ask for a secret in a free-text field, which the
+// scanner does not recognize as such.
+type ElicitationPolicy struct {
+	// Deny declines every request
+	Deny bool
+
+	// Scanner finds injections in the message and field descriptions
+	// (nil uses the scanner's default rules)
+	Scanner *scanner.Scanner
+
+	// SensitiveFields lists the field names stripped (nil uses
+	// DefaultSensitiveFields)
+	SensitiveFields []string
+}
+
+// elicitationGate applies an ElicitationPolicy.
+type elicitationGate struct {
+	policy    ElicitationPolicy
+	scanner   *scanner.Scanner
+	sensitive []string
+}
+
+// newElicitationGate fills in the defaults of p.
+func newElicitationGate(p *ElicitationPolicy) *elicitationGate {
+	g := &elicitationGate{policy: *p, scanner: p.Scanner}
+	if g.scanner == nil {
+		// The default rules always compile
+		g.scanner, _ = scanner.New(scanner.Config{})
+	}
+	fields := p.SensitiveFields
+	if fields == nil {
+		fields = DefaultSensitiveFields
+	}
+	for _, f := range fields {
+		g.sensitive = append(g.sensitive, foldFieldName(f))
+	}
+	return g
+}
+
+// foldFieldName lowercases name and removes separators.
+func foldFieldName(name string) string {
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case ' ', '-', '_', '.':
+			return -1
+		}
+		return c
+	}, strings.ToLower(name))
+}
+
+// isSensitive reports whether a field's name or title matches a
+// sensitive field name.
+func (g *elicitationGate) isSensitive(name, title string) bool {
+	name, title = foldFieldName(name), foldFieldName(title)
+	for _, s := range g.sensitive {
+		if strings.Contains(name, s) || (title != "" && strings.Contains(title, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// elicitField is what the gate reads of a requested field.
+type elicitField struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// screenElicitation checks a server's elicitation/create request. It
+// returns the request to relay, with sensitive fields stripped, or nil
+// if the request was declined.
+func (r *Router) screenElicitation(msg *jsonrpc.Message, data []byte) []byte {
+	g := r.elicitation
+	if g.policy.Deny {
+		r.declineElicitation(msg, "elicitation is not allowed")
+		return nil
+	}
+	params, err := mcptypes.DecodeParams[mcptypes.ElicitParams](msg)
+	if err != nil {
+		r.declineElicitation(msg, "malformed elicitation request: "+err.Error())
+		return nil
+	}
+
+	texts := []string{params.Message}
+	var stripped []string
+	for name, raw := range params.RequestedSchema.Properties {
+		var f elicitField
+		json.Unmarshal(raw, &f)
+		texts = append(texts, f.Title, f.Description)
+		if g.isSensitive(name, f.Title) {
+			stripped = append(stripped, name)
+		}
+	}
+	var rules []string
+	for _, text := range texts {
+		for _, f := range g.scanner.Scan(text) {
+			if !slices.Contains(rules, f.Rule) {
+				rules = append(rules, f.Rule)
+			}
+		}
+	}
+	if len(rules) > 0 {
+		r.declineElicitation(msg, "injection detected: "+strings.Join(rules, ", "))
+		return nil
+	}
+	if len(stripped) == 0 {
+		return data
+	}
+
+	slices.Sort(stripped)
+	if len(stripped) == len(params.RequestedSchema.Properties) {
+		r.declineElicitation(msg, "every requested field is sensitive: "+strings.Join(stripped, ", "))
+		return nil
+	}
+	for _, name := range stripped {
+		delete(params.RequestedSchema.Properties, name)
+	}
+	params.RequestedSchema.Required = slices.DeleteFunc(params.RequestedSchema.Required, func(name string) bool {
+		return slices.Contains(stripped, name)
+	})
+	out, err := rewriteParams(msg, params)
+	if err != nil {
+		r.declineElicitation(msg, "elicitation request not rewritten: "+err.Error())
+		return nil
+	}
+	r.stats.ElicitationFieldsStripped.Add(uint64(len(stripped)))
+	log.Printf("router: session %s: stripped sensitive fields %v from elicitation request %s", r.sessionID, stripped, msg.ID)
+	return out
+}
+
+// rewriteParams returns msg serialized with params in place of its own.
+// msg itself is not modified.
+func rewriteParams(msg *jsonrpc.Message, params interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	rewritten := *msg
+	rewritten.Params = encoded
+	return jsonrpc.Serialize(&rewritten)
+}
+
+// declineElicitation answers an elicitation request with a decline in
+// place of the client.
+func (r *Router) declineElicitation(msg *jsonrpc.Message, reason string) {
+	r.stats.ElicitationsDeclined.Add(1)
+	log.Printf("audit: session %s: declined elicitation request %s: %s", r.sessionID, msg.ID, reason)
+	r.auditServerRequest(msg, reason)
+	r.answerServer(msg, mcptypes.ElicitResult{Action: mcptypes.ElicitDecline})
+}
+
+// filterElicitResult strips sensitive fields from the client's answer
+// to an elicitation request and returns the answer to relay.
+func (r *Router) filterElicitResult(msg *jsonrpc.Message, data []byte) []byte {
+	result, err := mcptypes.DecodeResult[mcptypes.ElicitResult](msg)
+	if err != nil {
+		return data
+	}
+	var stripped []string
+	for name := range result.Content {
+		if r.elicitation.isSensitive(name, "") {
+			stripped = append(stripped, name)
+			delete(result.Content, name)
+		}
+	}
+	if len(stripped) == 0 {
+		return data
+	}
+	resp, err := jsonrpc.NewResponse(msg.ID, result)
+	if err != nil {
+		return data
+	}
+	out, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return data
+	}
+	r.stats.ElicitationFieldsStripped.Add(uint64(len(stripped)))
+	log.Printf("router: session %s: stripped sensitive fields %v from the answer to elicitation request %s", r.sessionID, stripped, msg.ID)
+	return out
+}
+
+// answerServer answers a server request in place of the client, which
+// never sees it.
+func (r *Router) answerServer(msg *jsonrpc.Message, result interface{}) {
+	id := string(msg.ID)
+	r.relayed.match(id, nil)
+	r.calls.answered(id)
+	resp, err := jsonrpc.NewResponse(msg.ID, result)
+	if err != nil {
+		log.Printf("router: session %s: %v", r.sessionID, err)
+		return
+	}
+	data, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		log.Printf("router: session %s: %v", r.sessionID, err)
+		return
+	}
+	if err := r.upstream.Send(data); err != nil {
+		log.Printf("router: session %s: relay to server failed: %v", r.sessionID, err)
+	}
+}
+
+// auditServerRequest records a server request the proxy refused.
+func (r *Router) auditServerRequest(msg *jsonrpc.Message, reason string) {
+	if r.audit == nil {
+		return
+	}
+	r.writeAudit(&audit.Record{
+		Time:      time.Now().UTC(),
+		Session:   r.sessionID,
+		Direction: audit.ServerToClient,
+		Method:    msg.Method,
+		Decision:  string(VerdictBlocked),
+		Reason:    reason,
+	})
+}
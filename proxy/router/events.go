@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+)
+
+// EventKind identifies a step in handling one message.
+type EventKind string
+
+// Audit event kinds, in the order they occur for a request.
+const (
+	EventReceived  EventKind = "received"
+	EventCheck     EventKind = "check"
+	EventRewrite   EventKind = "rewrite"
+	EventVerdict   EventKind = "verdict"
+	EventForwarded EventKind = "forwarded"
+	EventBlocked   EventKind = "blocked"
+	EventFailed    EventKind = "failed"
+)
+
+// Event is one audit event for a routed message.
+type Event struct {
+	DecisionID string                 `json:"decision_id"`
+	SessionID  string                 `json:"session_id"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	Seq        int                    `json:"seq"`
+	Time       time.Time              `json:"time"`
+	Kind       EventKind              `json:"kind"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// EventSink receives audit events.
+//
+// Events for one message are buffered while it is processed and
+// delivered in a single Emit call once its decision is final, in Seq
+// order. A sink that writes each batch atomically therefore keeps every
+// request's received → checks → verdict → outcome sequence contiguous,
+// however many messages are processed in parallel.
+type EventSink interface {
+	Emit(events []Event)
+}
+
+// event appends an audit event to the decision's buffer. It is a no-op
+// for nil decisions and when no sink is configured.
+func (d *Decision) event(kind EventKind, fields map[string]interface{}) {
+	if d == nil || !d.collect {
+		return
+	}
+	d.events = append(d.events, Event{
+		DecisionID: d.ID,
+		SessionID:  d.SessionID,
+		TraceID:    d.TraceID,
+		Seq:        len(d.events),
+		Time:       time.Now().UTC(),
+		Kind:       kind,
+		Fields:     fields,
+	})
+}
+
+// finish records a completed decision and flushes its audit events.
+func (r *Router) finish(d *Decision) {
+	d.endSpan()
+	r.decisions.record(d)
+	r.stats.breakdown.observe(d)
+	if r.conformance != nil {
+		r.conformance.answered(d)
+	}
+	if r.eventSink != nil && len(d.events) > 0 {
+		r.eventSink.Emit(d.events)
+	}
+	if r.slo != nil {
+		r.slo.Observe(slo.Observation{
+			Method:       d.Method,
+			Verdict:      string(d.Verdict),
+			AddedLatency: time.Since(d.started) - d.upstream,
+		})
+	}
+	if r.audit != nil {
+		latency := time.Since(d.started)
+		r.writeAudit(&audit.Record{
+			Time:           d.Time,
+			Session:        r.sessionID,
+			Direction:      audit.ClientToServer,
+			Method:         d.Method,
+			Tool:           d.Tool,
+			DecisionID:     d.ID,
+			TraceID:        d.TraceID,
+			Decision:       string(d.Verdict),
+			Reason:         d.Reason,
+			Reasons:        d.reasons,
+			Arguments:      d.auditArguments,
+			Result:         d.auditResult,
+			Original:       d.auditOriginal,
+			LatencyMS:      milliseconds(latency),
+			AddedLatencyMS: milliseconds(latency - d.upstream),
+		})
+	}
+}
+
+// auditRelay records a message relayed without routing checks.
+func (r *Router) auditRelay(dir audit.Direction, msg *jsonrpc.Message) {
+	if r.audit == nil {
+		return
+	}
+	r.writeAudit(&audit.Record{
+		Time:      time.Now().UTC(),
+		Session:   r.sessionID,
+		Direction: dir,
+		Method:    msg.Method,
+		Decision:  audit.DecisionRelayed,
+	})
+}
+
+// writeAudit hands a record to the audit sink, counting failures.
+func (r *Router) writeAudit(rec *audit.Record) {
+	if err := r.audit.Write(rec); err != nil {
+		if r.stats.AuditErrors.Add(1) == 1 {
+			log.Printf("router: session %s: audit record not written: %v", r.sessionID, err)
+		}
+	}
+}
+
+// toolArguments returns the arguments of a tools/call request, or nil.
+func toolArguments(msg *jsonrpc.Message) json.RawMessage {
+	var params struct {
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		return nil
+	}
+	return params.Arguments
+}
+
+// payloadSnippet returns up to max bytes of a payload for the audit
+// trail, cut at a UTF-8 boundary and marked when truncated.
+func payloadSnippet(payload []byte, max int) string {
+	if len(payload) <= max {
+		return string(payload)
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(payload[cut]) {
+		cut--
+	}
+	return string(payload[:cut]) + "...(truncated)"
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// JSONLineSink writes events as JSON lines, one batch per Write call.
+//
+// JSONLineSink is safe for concurrent use.
+type JSONLineSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLineSink creates a sink writing to w.
+func NewJSONLineSink(w io.Writer) *JSONLineSink {
+	return &JSONLineSink{w: w}
+}
+
+// Emit writes a batch of events contiguously.
+func (s *JSONLineSink) Emit(events []Event) {
+	var buf []byte
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(buf)
+}
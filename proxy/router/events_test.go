@@ -0,0 +1,238 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestAuditEvents_ContiguousUnderConcurrency(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.AuditEvents = NewJSONLineSink(&buf)
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		time.Sleep(time.Millisecond)
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	const n = 32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var data []byte
+			if i%2 == 0 {
+				req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{
+					"name":      fmt.Sprintf("tool-%d", i),
+					"arguments": map[string]interface{}{},
+				}, i)
+				data, _ = jsonrpc.Serialize(req)
+			} else {
+				data = []byte(`{invalid`)
+			}
+			if _, err := r.RouteMessage(data); err != nil {
+				t.Errorf("RouteMessage failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var events []Event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	finished := map[string]bool{}
+	decisions := 0
+	for i, e := range events {
+		if i == 0 || e.DecisionID != events[i-1].DecisionID {
+			if finished[e.DecisionID] {
+				t.Fatalf("events for %s are not contiguous", e.DecisionID)
+			}
+			finished[e.DecisionID] = true
+			decisions++
+			if e.Seq != 0 {
+				t.Errorf("decision %s starts at seq %d", e.DecisionID, e.Seq)
+			}
+			continue
+		}
+		if e.Seq != events[i-1].Seq+1 {
+			t.Errorf("decision %s: seq %d follows %d", e.DecisionID, e.Seq, events[i-1].Seq)
+		}
+	}
+	if decisions != n {
+		t.Errorf("expected events for %d decisions, got %d", n, decisions)
+	}
+}
+
+func TestAuditEvents_RequestLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected []EventKind
+	}{
+		{
+			name:     "forwarded tool call",
+			request:  `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+			expected: []EventKind{EventReceived, EventCheck, EventCheck, EventVerdict, EventForwarded},
+		},
+		{
+			name:     "parse error",
+			request:  `{invalid`,
+			expected: []EventKind{EventVerdict, EventFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]Event
+			cfg := DefaultConfig()
+			cfg.AuditEvents = sinkFunc(func(events []Event) { got = append(got, events) })
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{"content": []interface{}{}})
+				return jsonrpc.Serialize(resp)
+			}
+
+			if _, err := r.RouteMessage([]byte(tt.request)); err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("expected one batch, got %d", len(got))
+			}
+			var kinds []EventKind
+			for _, e := range got[0] {
+				kinds = append(kinds, e.Kind)
+			}
+			if fmt.Sprint(kinds) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, kinds)
+			}
+		})
+	}
+}
+
+// sinkFunc adapts a function to EventSink.
+type sinkFunc func([]Event)
+
+func (f sinkFunc) Emit(events []Event) { f(events) }
+
+// auditRecorder collects audit records.
+type auditRecorder struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (a *auditRecorder) Write(rec *audit.Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, *rec)
+	return nil
+}
+
+func (a *auditRecorder) Close() error { return nil }
+
+func TestAudit_EveryMessage(t *testing.T) {
+	rec := &auditRecorder{}
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.Audit = rec
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"summarize","arguments":{}}}`))
+	expectMessage(t, server, `"method":"tools/call"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{}}`))
+	expectMessage(t, client, `"method":"sampling/createMessage"`)
+	client.Send([]byte(`{"jsonrpc":"2.0","id":"s1","result":{}}`))
+	expectMessage(t, server, `"id":"s1"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`))
+	expectMessage(t, client, `"id":1`)
+	client.Send([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"shell","arguments":{}}}`))
+	expectMessage(t, client, `"id":2`)
+
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after client disconnect")
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	byKey := make(map[string]audit.Record)
+	for _, r := range rec.records {
+		byKey[string(r.Direction)+" "+r.Method+" "+r.Tool+" "+r.Decision] = r
+	}
+	if len(rec.records) != 4 {
+		t.Errorf("%d records, expected 4: %+v", len(rec.records), rec.records)
+	}
+	allowed, ok := byKey["client_to_server tools/call summarize allowed"]
+	if !ok || allowed.DecisionID == "" || allowed.Session != r.sessionID || allowed.LatencyMS < allowed.AddedLatencyMS {
+		t.Errorf("allowed call record = %+v (found %t)", allowed, ok)
+	}
+	blocked, ok := byKey["client_to_server tools/call shell blocked"]
+	if !ok || !strings.Contains(blocked.Reason, "denied") {
+		t.Errorf("blocked call record = %+v (found %t)", blocked, ok)
+	}
+	for _, key := range []string{"server_to_client sampling/createMessage  relayed", "client_to_server   relayed"} {
+		if _, ok := byKey[key]; !ok {
+			t.Errorf("no %q record in %v", key, rec.records)
+		}
+	}
+}
+
+func TestAudit_Payloads(t *testing.T) {
+	rec := &auditRecorder{}
+	cfg := DefaultConfig()
+	cfg.Audit = rec
+	cfg.AuditPayloadBytes = 32
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"naïve résumé of a long file"}]}}`), nil
+	}
+
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"summarize","arguments":{"path":"notes.txt"}}}`))
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.records) != 2 {
+		t.Fatalf("%d records, expected 2", len(rec.records))
+	}
+	call, ping := rec.records[0], rec.records[1]
+	if call.Arguments != `{"path":"notes.txt"}` {
+		t.Errorf("Arguments = %q", call.Arguments)
+	}
+	if !strings.HasPrefix(call.Result, `{"jsonrpc":"2.0","id":1,"result"`) || !strings.HasSuffix(call.Result, "...(truncated)") ||
+		!utf8.ValidString(call.Result) {
+		t.Errorf("Result = %q, expected a truncated snippet", call.Result)
+	}
+	if ping.Arguments != "" || ping.Result != "" {
+		t.Errorf("ping record has payloads: %+v", ping)
+	}
+}
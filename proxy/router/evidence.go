@@ -0,0 +1,122 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// maxSessionFindings bounds the findings a session keeps from earlier
+// messages for later council votes.
+const maxSessionFindings = 16
+
+// evidenceWindow is how far back anomaly signals are reported to the
+// council; the default anomaly half-life decays a signal to an eighth
+// of its weight in this time.
+const evidenceWindow = 15 * time.Minute
+
+// findingLog keeps the most recent findings about earlier messages of
+// a session, such as flagged tool result content. The zero value is
+// ready to use.
+type findingLog struct {
+	mu    sync.Mutex
+	items []sentinel.Finding
+}
+
+// add records f, dropping the oldest finding when full.
+func (l *findingLog) add(f sentinel.Finding) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, f)
+	if len(l.items) > maxSessionFindings {
+		l.items = l.items[len(l.items)-maxSessionFindings:]
+	}
+}
+
+// snapshot returns a copy of the findings, oldest first.
+func (l *findingLog) snapshot() []sentinel.Finding {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]sentinel.Finding(nil), l.items...)
+}
+
+// finding records evidence about the message d routes for a council
+// vote on it. d may be nil.
+func (d *Decision) finding(f sentinel.Finding) {
+	if d != nil {
+		d.findings = append(d.findings, f)
+	}
+}
+
+// evidence collects the Go-side evidence for a council vote on d's
+// call: the findings of its own checks, the session's earlier
+// findings, and recent anomaly signals. It returns nil when nothing was
+// found, so a clean call can still be answered from the council memo.
+func (r *Router) evidence(d *Decision) *sentinel.Evidence {
+	var findings []sentinel.Finding
+	var checks []string
+	if d != nil {
+		findings, checks = append(findings, d.findings...), d.checks
+	}
+	findings = append(findings, r.findings.snapshot()...)
+
+	var score *sentinel.AnomalyEvidence
+	if r.anomaly != nil {
+		threshold := r.anomaly.Threshold()
+		score = &sentinel.AnomalyEvidence{Score: r.anomaly.Score(), Threshold: threshold}
+		cutoff := time.Now().Add(-evidenceWindow)
+		for _, o := range r.anomaly.Observations() {
+			if o.Time.Before(cutoff) {
+				continue
+			}
+			findings = append(findings, sentinel.Finding{
+				Source:   sentinel.EvidenceAnomaly,
+				Kind:     string(o.Signal),
+				Severity: min(1, o.Score/threshold),
+				Summary:  o.Detail,
+				Detail:   o,
+			})
+		}
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Severity > findings[j].Severity })
+	return &sentinel.Evidence{
+		Version:      sentinel.EvidenceVersion,
+		Findings:     findings,
+		Anomaly:      score,
+		ChecksPassed: checks,
+	}
+}
+
+// evidenceCouncil adds the Go-side evidence to a council vote request.
+func (r *Router) evidenceCouncil(d *Decision, req *sentinel.CouncilVoteRequest) {
+	ev := r.evidence(d)
+	if ev == nil {
+		return
+	}
+	if req.Context == nil {
+		req.Context = make(map[string]interface{})
+	}
+	req.Context[sentinel.ContextEvidence] = ev
+}
+
+// taintFinding describes a tainted argument as council evidence.
+func taintFinding(t TaintedArgument) sentinel.Finding {
+	f := sentinel.Finding{Source: sentinel.EvidenceTaint, Kind: "argument", Severity: 0.3, Detail: t}
+	how := "partly"
+	if t.Full {
+		how = "in full"
+		f.Severity = 0.5
+	}
+	if t.Critical {
+		f.Kind = "critical_argument"
+		f.Severity += 0.5
+	}
+	f.Summary = fmt.Sprintf("argument %s copied %s from a %s result", t.Path, how, t.SourceTool)
+	return f
+}
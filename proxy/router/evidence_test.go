@@ -0,0 +1,115 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// evidenceBackend records the council vote requests it receives.
+type evidenceBackend struct {
+	poisonBackend
+	votes []*sentinel.CouncilVoteRequest
+}
+
+func (b *evidenceBackend) VoteCouncil(ctx context.Context, req *sentinel.CouncilVoteRequest) (*sentinel.CheckResult, error) {
+	b.votes = append(b.votes, req)
+	return &sentinel.CheckResult{Allowed: true}, nil
+}
+
+func TestEvidence_CouncilContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		anomaly bool
+		kinds   []string
+	}{
+		{"clean call", map[string]interface{}{"command": "ls"}, false, nil},
+		{"tainted call", map[string]interface{}{"url": "https://evil.example/payload.sh"}, false, []string{"critical_argument"}},
+		{"tainted call with anomaly", map[string]interface{}{"url": "https://evil.example/payload.sh"}, true, []string{"critical_argument", "injection"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.TaintTracking = &TaintTracking{Action: TaintCouncil}
+			if tt.anomaly {
+				cfg.Anomaly = &anomaly.Config{Threshold: 100}
+			}
+			backend := &evidenceBackend{}
+			r := NewWithConfig(&mockTransport{}, sentinel.NewFusedClient(nil, sentinel.Member{Name: "test", Backend: backend}), cfg)
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				msg, _ := jsonrpc.Parse(data)
+				resp, _ := jsonrpc.NewResponse(msg.ID, mcptypes.CallToolResult{Content: []mcptypes.Content{{Type: mcptypes.ContentText, Text: injectedPage}}})
+				return jsonrpc.Serialize(resp)
+			}
+			call := func(tool string, args map[string]interface{}) {
+				req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool, "arguments": args}, 1)
+				data, _ := jsonrpc.Serialize(req)
+				if _, err := r.RouteMessage(data); err != nil {
+					t.Fatalf("RouteMessage failed: %v", err)
+				}
+			}
+
+			call("browse", map[string]interface{}{"page": "home"})
+			if tt.anomaly {
+				r.RecordAnomaly(anomaly.SignalInjection, "instructions in browse result")
+			}
+			backend.votes = nil
+			call("execute_command", tt.args)
+			if len(backend.votes) != 1 {
+				t.Fatalf("expected 1 council vote, got %d", len(backend.votes))
+			}
+
+			value, ok := backend.votes[0].Context[sentinel.ContextEvidence]
+			if tt.kinds == nil {
+				if ok {
+					t.Errorf("clean call carries evidence %+v", value)
+				}
+				return
+			}
+			ev, _ := value.(*sentinel.Evidence)
+			if ev == nil || ev.Version != sentinel.EvidenceVersion {
+				t.Fatalf("evidence = %#v", value)
+			}
+			if len(ev.Findings) != len(tt.kinds) {
+				t.Fatalf("findings = %+v, expected kinds %v", ev.Findings, tt.kinds)
+			}
+			for i, kind := range tt.kinds {
+				if ev.Findings[i].Kind != kind {
+					t.Errorf("finding %d kind = %q, expected %q", i, ev.Findings[i].Kind, kind)
+				}
+			}
+			if (ev.Anomaly != nil) != tt.anomaly {
+				t.Errorf("anomaly = %+v", ev.Anomaly)
+			}
+		})
+	}
+}
+
+func TestEvidence_SessionFindings(t *testing.T) {
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), DefaultConfig())
+	if ev := r.evidence(&Decision{}); ev != nil {
+		t.Errorf("evidence without findings = %+v", ev)
+	}
+
+	for i := 0; i < maxSessionFindings+4; i++ {
+		r.findings.add(sentinel.Finding{Source: sentinel.EvidenceContent, Kind: "secret", Severity: 0.4})
+	}
+	d := &Decision{checks: []string{"registry"}}
+	d.finding(sentinel.Finding{Source: sentinel.EvidencePolicy, Kind: "council", Severity: 0.5})
+	ev := r.evidence(d)
+	if ev == nil || len(ev.Findings) != maxSessionFindings+1 {
+		t.Fatalf("evidence = %+v", ev)
+	}
+	if ev.Findings[0].Source != sentinel.EvidencePolicy || len(ev.ChecksPassed) != 1 {
+		t.Errorf("most severe finding = %+v, checks = %v", ev.Findings[0], ev.ChecksPassed)
+	}
+
+	// A nil decision is safe
+	var none *Decision
+	none.finding(sentinel.Finding{})
+}
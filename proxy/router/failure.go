@@ -0,0 +1,94 @@
+package router
+
+import (
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/upstream"
+)
+
+// CodeBlocked is the error code of a request refused by security
+// policy without a code of its own, when Config.DistinctErrorCodes is
+// set.
+const CodeBlocked = -32013
+
+// CodeUpstreamFailed is the error code of a request the server could not
+// be reached for or did not answer properly.
+const CodeUpstreamFailed = upstream.CodeUpstreamFailed
+
+// FailureClass says whose failure an error response reports, so a
+// client can tell a refusal from an outage.
+type FailureClass string
+
+const (
+	// FailurePolicy is a request refused by security policy
+	FailurePolicy FailureClass = "policy"
+
+	// FailureUpstream is a server that failed, timed out, or could not
+	// be reached
+	FailureUpstream FailureClass = "upstream"
+
+	// FailureInternal is the proxy failing to process the request
+	FailureInternal FailureClass = "internal"
+
+	// FailureRequest is a malformed request
+	FailureRequest FailureClass = "request"
+)
+
+// RetryHint says whether and when a failed request may succeed if sent
+// again.
+type RetryHint string
+
+const (
+	// RetryNever means the same request fails again: skip it or change
+	// it
+	RetryNever RetryHint = "never"
+
+	// RetryBackoff means the failure is likely transient: retry with
+	// backoff
+	RetryBackoff RetryHint = "backoff"
+
+	// RetryAfter means retry once the error's retry_after_ms has passed
+	RetryAfter RetryHint = "after"
+
+	// RetryAskUser means a person must approve the request first
+	RetryAskUser RetryHint = "ask_user"
+)
+
+// classifyError returns the failure class and retry hint of an error
+// response with the given verdict and code.
+func classifyError(verdict Verdict, code int, data *ErrorData) (FailureClass, RetryHint) {
+	switch code {
+	case CodeRateLimited:
+		if data.RetryAfterMS > 0 {
+			return FailurePolicy, RetryAfter
+		}
+		return FailurePolicy, RetryBackoff
+	case CodePaused:
+		// An operator resumes the session
+		return FailurePolicy, RetryBackoff
+	case CodeApprovalRequired:
+		return FailurePolicy, RetryAskUser
+	case CodeRequestTimeout, CodeUpstreamFailed:
+		return FailureUpstream, RetryBackoff
+	case CodeProxyLoop:
+		// The server is this proxy again until it is reconfigured
+		return FailureUpstream, RetryNever
+	case CodeOverloaded, jsonrpc.InternalError:
+		return FailureInternal, RetryBackoff
+	case jsonrpc.ParseError:
+		return FailureRequest, RetryNever
+	}
+	if verdict == VerdictBlocked {
+		return FailurePolicy, RetryNever
+	}
+	return FailureRequest, RetryNever
+}
+
+// blockCode returns the code to send for a policy refusal: CodeBlocked
+// in place of the generic JSON-RPC codes when distinct codes are on.
+func (r *Router) blockCode(verdict Verdict, code int) int {
+	if r.distinctErrorCodes && verdict == VerdictBlocked &&
+		(code == jsonrpc.InvalidRequest || code == jsonrpc.InvalidParams) {
+		return CodeBlocked
+	}
+	return code
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestErrorResponses_Failure(t *testing.T) {
+	engine, err := policy.New(&policy.Set{Rules: []policy.Rule{
+		{Name: "no-resources", Methods: []string{"resources/read"}, Action: policy.ActionBlock, Reason: "resources are disabled"},
+		{Name: "limit", Tools: []string{"search"}, Action: policy.ActionRateLimit, Rate: 0.001, Burst: 1},
+	}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		distinct bool
+		request  string
+		code     int
+		failure  FailureClass
+		retry    RetryHint
+	}{
+		{"policy refusal", false, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"file:///a"}}`, jsonrpc.InvalidRequest, FailurePolicy, RetryNever},
+		{"policy refusal, distinct codes", true, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"file:///a"}}`, CodeBlocked, FailurePolicy, RetryNever},
+		{"rate limited", false, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`, CodeRateLimited, FailurePolicy, RetryAfter},
+		{"upstream failure", true, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"down"}}`, CodeUpstreamFailed, FailureUpstream, RetryBackoff},
+		{"malformed request", true, `{"jsonrpc":`, jsonrpc.ParseError, FailureRequest, RetryNever},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Policy = engine
+			cfg.DistinctErrorCodes = tt.distinct
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				msg, _ := jsonrpc.Parse(data)
+				if jsonrpc.ExtractToolName(msg) == "down" {
+					return nil, errors.New("connection refused")
+				}
+				resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+				return jsonrpc.Serialize(resp)
+			}
+			if tt.code == CodeRateLimited {
+				// Spend the burst
+				r.RouteMessage([]byte(tt.request))
+			}
+
+			response, _ := r.RouteMessage([]byte(tt.request))
+			resp, err := jsonrpc.Parse(response)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if errorCode(resp) != tt.code {
+				t.Fatalf("response %s, expected error code %d", response, tt.code)
+			}
+			var data ErrorData
+			if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+				t.Fatalf("error data does not decode: %v", err)
+			}
+			if data.Failure != tt.failure || data.Retry != tt.retry {
+				t.Errorf("failure %q, retry %q; expected %q, %q", data.Failure, data.Retry, tt.failure, tt.retry)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		verdict Verdict
+		code    int
+		data    ErrorData
+		failure FailureClass
+		retry   RetryHint
+	}{
+		{VerdictBlocked, jsonrpc.InvalidParams, ErrorData{}, FailurePolicy, RetryNever},
+		{VerdictBlocked, CodeGasExhausted, ErrorData{}, FailurePolicy, RetryNever},
+		{VerdictBlocked, CodeRateLimited, ErrorData{}, FailurePolicy, RetryBackoff},
+		{VerdictBlocked, CodePaused, ErrorData{}, FailurePolicy, RetryBackoff},
+		{VerdictBlocked, CodeApprovalRequired, ErrorData{}, FailurePolicy, RetryAskUser},
+		{VerdictError, CodeRequestTimeout, ErrorData{}, FailureUpstream, RetryBackoff},
+		{VerdictError, CodeOverloaded, ErrorData{}, FailureInternal, RetryBackoff},
+		{VerdictError, jsonrpc.InternalError, ErrorData{}, FailureInternal, RetryBackoff},
+		{VerdictError, jsonrpc.InvalidRequest, ErrorData{}, FailureRequest, RetryNever},
+	}
+	for _, tt := range tests {
+		failure, retry := classifyError(tt.verdict, tt.code, &tt.data)
+		if failure != tt.failure || retry != tt.retry {
+			t.Errorf("classifyError(%s, %d) = %q, %q; expected %q, %q", tt.verdict, tt.code, failure, retry, tt.failure, tt.retry)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+// RegistryFastPath configures the verified-call performance mode.
+//
+// When enabled, a tools/call whose tool name and arguments are
+// byte-identical (after whitespace normalization) to a call that already
+// passed the registry check since the last registry pin skips registry
+// re-validation. State and gas checks always run. Every skip is
+// recorded in the check result details so the tradeoff is visible in
+// the audit trail.
+//
+// A registry pin is the most recent tools/list response seen by the
+// router; any new listing invalidates all cached verifications.
+type RegistryFastPath struct {
+	// Tools restricts the fast path to these tools (empty allows all).
+	// Intended for hot, read-only tools.
+	Tools []string
+
+	// MaxAge bounds how long a verification may be reused
+	// (zero means until the next registry pin)
+	MaxAge time.Duration
+
+	// MaxEntries bounds the number of cached verifications
+	MaxEntries int
+}
+
+// verifiedCalls remembers tool calls that passed the registry check.
+type verifiedCalls struct {
+	cfg   RegistryFastPath
+	tools map[string]bool
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]time.Time
+}
+
+// newVerifiedCalls creates the verified-call cache, aging entries by c.
+func newVerifiedCalls(cfg *RegistryFastPath, c clock.Clock) *verifiedCalls {
+	v := &verifiedCalls{
+		cfg:     *cfg,
+		now:     clock.Func(c),
+		entries: make(map[[sha256.Size]byte]time.Time),
+	}
+	if v.cfg.MaxEntries <= 0 {
+		v.cfg.MaxEntries = 4096
+	}
+	if len(cfg.Tools) > 0 {
+		v.tools = make(map[string]bool, len(cfg.Tools))
+		for _, name := range cfg.Tools {
+			v.tools[name] = true
+		}
+	}
+	return v
+}
+
+// eligible reports whether a tool may use the fast path.
+func (v *verifiedCalls) eligible(toolName string) bool {
+	return v.tools == nil || v.tools[toolName]
+}
+
+// verified reports whether an identical call passed the registry check
+// since the last pin.
+func (v *verifiedCalls) verified(toolName string, params json.RawMessage) bool {
+	if !v.eligible(toolName) {
+		return false
+	}
+	key := callKey(toolName, params)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	at, ok := v.entries[key]
+	if !ok {
+		return false
+	}
+	if v.cfg.MaxAge > 0 && v.now().Sub(at) > v.cfg.MaxAge {
+		delete(v.entries, key)
+		return false
+	}
+	return true
+}
+
+// remember records a call that passed the registry check.
+func (v *verifiedCalls) remember(toolName string, params json.RawMessage) {
+	if !v.eligible(toolName) {
+		return
+	}
+	key := callKey(toolName, params)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.entries) >= v.cfg.MaxEntries {
+		// Simplest safe policy: start over rather than track recency
+		v.entries = make(map[[sha256.Size]byte]time.Time)
+	}
+	v.entries[key] = v.now()
+}
+
+// reset drops all verifications, e.g. on a new registry pin.
+func (v *verifiedCalls) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.entries = make(map[[sha256.Size]byte]time.Time)
+}
+
+// callKey hashes a tool name and whitespace-normalized params.
+func callKey(toolName string, params json.RawMessage) [sha256.Size]byte {
+	var buf bytes.Buffer
+	buf.WriteString(toolName)
+	buf.WriteByte(0)
+	if err := json.Compact(&buf, params); err != nil {
+		buf.Write(params)
+	}
+	return sha256.Sum256(buf.Bytes())
+}
+
+// InvalidateRegistryFastPath drops all cached registry verifications so
+// the next call to every tool is fully re-validated.
+func (r *Router) InvalidateRegistryFastPath() {
+	if r.verified != nil {
+		r.verified.reset()
+	}
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRegistryFastPath(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RegistryFastPath = &RegistryFastPath{Tools: []string{"read_file"}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), "ok")
+		return jsonrpc.Serialize(resp)
+	}
+
+	route := func(method string, params interface{}) {
+		t.Helper()
+		req, _ := jsonrpc.NewRequest(method, params, 1)
+		data, _ := jsonrpc.Serialize(req)
+		if _, err := r.RouteMessage(data); err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+	}
+	call := func(tool, path string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":      tool,
+			"arguments": map[string]string{"path": path},
+		}
+	}
+
+	route("tools/call", call("read_file", "/a"))
+	route("tools/call", call("read_file", "/a"))
+	if got := r.stats.RegistrySkipped.Load(); got != 1 {
+		t.Errorf("expected 1 skipped registry check, got %d", got)
+	}
+
+	// Different arguments are re-validated
+	route("tools/call", call("read_file", "/b"))
+	if got := r.stats.RegistrySkipped.Load(); got != 1 {
+		t.Errorf("different args should not skip, got %d skips", got)
+	}
+
+	// Ineligible tools are always re-validated
+	route("tools/call", call("write_file", "/a"))
+	route("tools/call", call("write_file", "/a"))
+	if got := r.stats.RegistrySkipped.Load(); got != 1 {
+		t.Errorf("ineligible tool should not skip, got %d skips", got)
+	}
+
+	// A new tools/list is a new registry pin
+	route("tools/list", nil)
+	route("tools/call", call("read_file", "/a"))
+	if got := r.stats.RegistrySkipped.Load(); got != 1 {
+		t.Errorf("tools/list should invalidate the fast path, got %d skips", got)
+	}
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"encoding/json"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+)
+
+// GasModel prices tool calls against the session gas budget.
+//
+// Cost is called twice per forwarded call: once before forwarding with
+// resultSize 0, and once after the result arrives with its size in
+// bytes. The pre-call cost is charged immediately; any increase in the
+// post-call cost is charged as a top-up. Models that ignore resultSize
+// therefore charge exactly once.
+//
+// # Thread Safety
+//
+// Implementations must be safe for concurrent use.
+type GasModel interface {
+	Cost(tool string, params json.RawMessage, resultSize int) uint64
+}
+
+// GasModelFunc adapts a function to GasModel.
+type GasModelFunc func(tool string, params json.RawMessage, resultSize int) uint64
+
+// Cost calls f.
+func (f GasModelFunc) Cost(tool string, params json.RawMessage, resultSize int) uint64 {
+	return f(tool, params, resultSize)
+}
+
+// TableGasModel charges a fixed cost per tool name.
+type TableGasModel struct {
+	// Costs maps tool names to gas costs
+	Costs map[string]uint64
+
+	// Default is charged for tools not in Costs
+	Default uint64
+}
+
+// Cost returns the table cost for tool.
+func (m *TableGasModel) Cost(tool string, _ json.RawMessage, _ int) uint64 {
+	if cost, ok := m.Costs[tool]; ok {
+		return cost
+	}
+	return m.Default
+}
+
+// DefaultGasModel returns the built-in per-tool cost table, the gas
+// prices of policy.Builtin.
+func DefaultGasModel() *TableGasModel {
+	m := &TableGasModel{Costs: make(map[string]uint64), Default: policy.DefaultGas}
+	for _, rule := range policy.Builtin().Rules {
+		for _, tool := range rule.Tools {
+			if _, priced := m.Costs[tool]; rule.Gas > 0 && !priced {
+				m.Costs[tool] = rule.Gas
+			}
+		}
+	}
+	return m
+}
+
+// defaultGas is shared by routers without a custom model.
+var defaultGas = DefaultGasModel()
+
+// gasModelHolder lets a GasModel interface value live in an atomic.Pointer.
+type gasModelHolder struct{ GasModel }
+
+// SetGasModel replaces the router's gas model. A nil model restores the
+// default table. Calls already in flight settle under the model that
+// charged them.
+func (r *Router) SetGasModel(m GasModel) {
+	if m == nil {
+		m = defaultGas
+	}
+	r.gasModel.Store(&gasModelHolder{m})
+}
+
+// currentGasModel returns the active gas model.
+func (r *Router) currentGasModel() GasModel {
+	if h := r.gasModel.Load(); h != nil {
+		return h.GasModel
+	}
+	return defaultGas
+}
+
+// gasCharge records what a tool call was charged before forwarding.
+type gasCharge struct {
+	model  GasModel
+	tool   string
+	params json.RawMessage
+	amount uint64
+}
+
+// chargeGas charges the pre-call cost of a tool call and remembers it on
+// d for settlement. d may be nil.
+func (r *Router) chargeGas(d *Decision, msg *jsonrpc.Message) {
+	if proxy, charged := d.upstreamCharged(); charged {
+		// One call is charged once along a chain of sentinels
+		d.Details = withDetailMap(d.Details, "gas_charged_by", proxy)
+		return
+	}
+	model := r.currentGasModel()
+	tool := jsonrpc.ExtractToolName(msg)
+	amount := model.Cost(tool, msg.Params, 0)
+	r.gasUsed.Add(amount)
+	if d != nil {
+		d.gas = &gasCharge{model: model, tool: tool, params: msg.Params, amount: amount}
+	}
+	r.noteGas(false)
+}
+
+// settleGas charges any increase in cost once the result size is known.
+func (r *Router) settleGas(d *Decision, response []byte) {
+	if d.gas == nil {
+		return
+	}
+	if final := d.gas.model.Cost(d.gas.tool, d.gas.params, len(response)); final > d.gas.amount {
+		r.gasUsed.Add(final - d.gas.amount)
+		r.noteGas(false)
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestGasModel_Charging(t *testing.T) {
+	result := `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"` + strings.Repeat("x", 1000) + `"}]}}`
+
+	tests := []struct {
+		name     string
+		model    GasModel
+		expected uint64
+	}{
+		{
+			name:     "default table",
+			model:    nil,
+			expected: 200,
+		},
+		{
+			name:     "custom table",
+			model:    &TableGasModel{Costs: map[string]uint64{"search": 7}, Default: 1},
+			expected: 7,
+		},
+		{
+			name: "bytes processed settles after result",
+			model: GasModelFunc(func(tool string, params json.RawMessage, resultSize int) uint64 {
+				return uint64(len(params) + resultSize)
+			}),
+			expected: uint64(len(`{"name":"search","arguments":{"q":"go"}}`) + len(result)),
+		},
+		{
+			name: "lower post-call cost is not refunded",
+			model: GasModelFunc(func(_ string, _ json.RawMessage, resultSize int) uint64 {
+				if resultSize == 0 {
+					return 50
+				}
+				return 10
+			}),
+			expected: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.GasModel = tt.model
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			r.forwardFunc = func([]byte) ([]byte, error) { return []byte(result), nil }
+
+			req := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"q":"go"}}}`
+			response, err := r.RouteMessage([]byte(req))
+			if err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+			if resp, _ := jsonrpc.Parse(response); resp.Error != nil {
+				t.Fatalf("unexpected error response: %v", resp.Error)
+			}
+			if got := r.gasUsed.Load(); got != tt.expected {
+				t.Errorf("gas used = %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetGasModel_ReplacesAndRestores(t *testing.T) {
+	r := New(&mockTransport{}, sentinel.NewClient())
+	r.SetGasModel(GasModelFunc(func(string, json.RawMessage, int) uint64 { return 3 }))
+	if got := r.currentGasModel().Cost("read_file", nil, 0); got != 3 {
+		t.Errorf("custom model cost = %d, expected 3", got)
+	}
+	r.SetGasModel(nil)
+	if got := r.currentGasModel().Cost("read_file", nil, 0); got != 100 {
+		t.Errorf("default model cost = %d, expected 100", got)
+	}
+}
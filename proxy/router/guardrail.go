@@ -0,0 +1,59 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// applyGuardrails pins tools/call arguments before the call is checked.
+//
+// The rewritten message is what the sentinel checks and what the server
+// receives, so checks never approve arguments that differ from those
+// actually forwarded.
+//
+// # Returns
+//   - Message bytes to forward (data itself if nothing was rewritten)
+//   - Rewrites performed (nil if none)
+//   - Error if the arguments could not be constrained
+func (r *Router) applyGuardrails(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, []guardrailRewrite, error) {
+	toolName := jsonrpc.ExtractToolName(msg)
+	params, rewrites, err := r.guard.Apply(toolName, msg.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rewrites) == 0 {
+		return data, nil, nil
+	}
+
+	msg.Params = params
+	rewritten, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make([]guardrailRewrite, 0, len(rewrites))
+	for _, rw := range rewrites {
+		log.Printf("router: session %s: guardrail rewrote %s.%s: %s -> %s",
+			r.sessionID, rw.Tool, rw.Arg, orAbsent(rw.From), rw.To)
+		out = append(out, guardrailRewrite{Arg: rw.Arg, From: rw.From, To: rw.To})
+		d.event(EventRewrite, map[string]interface{}{"arg": rw.Arg, "from": orAbsent(rw.From), "to": string(rw.To)})
+	}
+	return rewritten, out, nil
+}
+
+// guardrailRewrite is the decision-detail form of a guardrail rewrite.
+type guardrailRewrite struct {
+	Arg  string          `json:"arg"`
+	From json.RawMessage `json:"from,omitempty"`
+	To   json.RawMessage `json:"to"`
+}
+
+// orAbsent renders a missing argument value for logging.
+func orAbsent(v json.RawMessage) string {
+	if len(v) == 0 {
+		return "<absent>"
+	}
+	return string(v)
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/guardrail"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestArgumentGuard_RewritesBeforeForward(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ArgumentGuard = guardrail.New([]guardrail.Rule{
+		{Tool: "write_file", Constraints: []guardrail.Constraint{
+			{Arg: "directory", PathPrefix: "/workspace/out"},
+		}},
+	})
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	var forwarded []byte
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwarded = data
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), "ok")
+		return jsonrpc.Serialize(resp)
+	}
+
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{
+		"name":      "write_file",
+		"arguments": map[string]string{"directory": "/etc"},
+	}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	if _, err := r.RouteMessage(data); err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+
+	msg, err := jsonrpc.Parse(forwarded)
+	if err != nil {
+		t.Fatalf("forwarded message unparseable: %v", err)
+	}
+	var params struct {
+		Arguments map[string]string `json:"arguments"`
+	}
+	json.Unmarshal(msg.Params, &params)
+	if got := params.Arguments["directory"]; got != "/workspace/out/etc" {
+		t.Errorf("forwarded directory = %q, expected /workspace/out/etc", got)
+	}
+
+	decisions := r.RecentDecisions(1)
+	if len(decisions) != 1 || decisions[0].Details["argument_rewrites"] == nil {
+		t.Error("expected rewrite recorded in decision details")
+	}
+}
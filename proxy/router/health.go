@@ -0,0 +1,187 @@
+package router
+
+import (
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// Health summarizes a session's security posture for health endpoints.
+type Health struct {
+	SessionID         string `json:"session_id"`
+	DegradationLevel  string `json:"degradation_level"`
+	DegradationPinned bool   `json:"degradation_pinned"`
+	Terminated        bool   `json:"terminated"`
+	Paused            bool   `json:"paused"`
+
+	// ProtectionDegraded lists why checks are weaker than configured;
+	// see Router.ProtectionDegraded
+	ProtectionDegraded []string `json:"protection_degraded,omitempty"`
+
+	// Gas is the session's gas budget and CallDepth the deepest tool
+	// call in flight
+	Gas       GasStatus `json:"gas"`
+	CallDepth int       `json:"call_depth"`
+}
+
+// Metric is a single exported metric sample.
+type Metric struct {
+	Name   string
+	Help   string
+	Type   string // "counter" or "gauge"
+	Labels map[string]string
+	Value  float64
+}
+
+// Health returns the session's current health summary.
+func (r *Router) Health() Health {
+	h := Health{
+		SessionID:        r.sessionID,
+		DegradationLevel: r.DegradationLevel().String(),
+		Terminated:       r.terminated.Load(),
+		Paused:           r.PauseState().Paused,
+
+		ProtectionDegraded: r.ProtectionDegraded(),
+
+		Gas:       r.Gas(),
+		CallDepth: r.calls.depth(),
+	}
+	if r.ladder != nil {
+		h.DegradationPinned = r.ladder.Pinned()
+	}
+	return h
+}
+
+// Metrics returns the session's metric samples, labeled by session.
+func (r *Router) Metrics() []Metric {
+	labels := map[string]string{"session": r.sessionID}
+	received, forwarded, blocked, errs := r.GetStats()
+	degraded := r.ProtectionDegraded()
+	metrics := []Metric{
+		{"mcp_sentinel_messages_received_total", "Messages received from the client.", "counter", labels, float64(received)},
+		{"mcp_sentinel_messages_forwarded_total", "Messages forwarded to the server.", "counter", labels, float64(forwarded)},
+		{"mcp_sentinel_messages_blocked_total", "Messages blocked by security checks.", "counter", labels, float64(blocked)},
+		{"mcp_sentinel_errors_total", "Routing errors.", "counter", labels, float64(errs)},
+		{"mcp_sentinel_responses_sanitized_total", "Server responses delivered with rejected content removed.", "counter", labels, float64(r.stats.ResponsesSanitized.Load())},
+		{"mcp_sentinel_large_results_scanned_total", "Tool results checked with a bounded incremental scan.", "counter", labels, float64(r.stats.LargeResultsScanned.Load())},
+		{"mcp_sentinel_tools_withheld_total", "Listed tools withheld pending trust-on-first-use approval.", "counter", labels, float64(r.stats.ToolsWithheld.Load())},
+		{"mcp_sentinel_tools_changed_total", "Listed tools whose definition changed since it was approved.", "counter", labels, float64(r.stats.ToolsChanged.Load())},
+		{"mcp_sentinel_chained_requests_total", "Requests carrying chain metadata from another sentinel.", "counter", labels, float64(r.stats.ChainedRequests.Load())},
+		{"mcp_sentinel_chain_rejected_total", "Chain metadata ignored because it failed verification.", "counter", labels, float64(r.stats.ChainRejected.Load())},
+		{"mcp_sentinel_tainted_calls_total", "Tool calls with arguments copied from earlier tool results.", "counter", labels, float64(r.stats.TaintedCalls.Load())},
+		{"mcp_sentinel_ignored_blocks_total", "Blocked tool calls retried unchanged by the client.", "counter", labels, float64(r.stats.IgnoredBlocks.Load())},
+		{"mcp_sentinel_audit_errors_total", "Audit records the audit sink failed to write.", "counter", labels, float64(r.stats.AuditErrors.Load())},
+		{"mcp_sentinel_rate_limited_total", "Requests refused by a rate limit or policy rate limit.", "counter", labels, float64(r.stats.RateLimited.Load())},
+		{"mcp_sentinel_downgrades_total", "Tool calls rewritten into safer ones by policy downgrade rules.", "counter", labels, float64(r.stats.Downgrades.Load())},
+		{"mcp_sentinel_checks_deferred_total", "Tool calls whose checks were deferred to a trusted upstream sentinel.", "counter", labels, float64(r.stats.ChecksDeferred.Load())},
+		{"mcp_sentinel_conformance_violations_total", "Client protocol conformance violations.", "counter", labels, float64(r.stats.ConformanceViolations.Load())},
+		{"mcp_sentinel_concurrency_limited_total", "Tool calls denied by a concurrency limit.", "counter", labels, float64(r.stats.ConcurrencyLimited.Load())},
+		{"mcp_sentinel_schema_violations_total", "Tool calls refused because their arguments did not match the input schema.", "counter", labels, float64(r.stats.SchemaViolations.Load())},
+		{"mcp_sentinel_gas_exhausted_total", "Tool calls refused because the gas budget could not cover them.", "counter", labels, float64(r.stats.GasExhausted.Load())},
+		{"mcp_sentinel_call_depth_exceeded_total", "Tool calls refused for nesting deeper than the maximum call depth.", "counter", labels, float64(r.stats.CallDepthExceeded.Load())},
+		{"mcp_sentinel_notifications_blocked_total", "Server notifications blocked before reaching the client.", "counter", labels, float64(r.stats.NotificationsBlocked.Load())},
+		{"mcp_sentinel_sampling_requests_total", "Server sampling requests screened.", "counter", labels, float64(r.stats.SamplingRequests.Load())},
+		{"mcp_sentinel_sampling_refused_total", "Server sampling requests refused before reaching the client.", "counter", labels, float64(r.stats.SamplingRefused.Load())},
+		{"mcp_sentinel_context_bytes", "Tool result and resource content delivered to the client.", "gauge", labels, float64(r.contextBytes.Load())},
+		{"mcp_sentinel_context_trimmed_total", "Results trimmed because the session exceeded its context budget.", "counter", labels, float64(r.stats.ContextTrimmed.Load())},
+		{"mcp_sentinel_elicitations_declined_total", "Server elicitation requests declined on the user's behalf.", "counter", labels, float64(r.stats.ElicitationsDeclined.Load())},
+		{"mcp_sentinel_elicitation_fields_stripped_total", "Sensitive fields stripped from elicitation requests and answers.", "counter", labels, float64(r.stats.ElicitationFieldsStripped.Load())},
+		{"mcp_sentinel_roots_refused_total", "Server roots/list requests answered with an empty list.", "counter", labels, float64(r.stats.RootsRefused.Load())},
+		{"mcp_sentinel_roots_filtered_total", "Client roots withheld from servers for being outside the permitted prefixes.", "counter", labels, float64(r.stats.RootsFiltered.Load())},
+		{"mcp_sentinel_resources_rejected_total", "resources/read results refused for their size or content type.", "counter", labels, float64(r.stats.ResourcesRejected.Load())},
+		{"mcp_sentinel_tool_descriptions_flagged_total", "tool metadata fields in tools/list results that matched injection rules.", "counter", labels, float64(r.stats.ToolDescriptionsFlagged.Load())},
+		{"mcp_sentinel_normalized_total", "client messages and tools/list results rewritten to remove invisible or look-alike characters.", "counter", labels, float64(r.stats.Normalized.Load())},
+		{"mcp_sentinel_proxy_loops_total", "initialize requests refused because the server leads back to this proxy.", "counter", labels, float64(r.stats.ProxyLoops.Load())},
+		{"mcp_sentinel_blocks_explained_total", "Blocked decisions explained through the sentinel/why tool.", "counter", labels, float64(r.stats.BlocksExplained.Load())},
+		{"mcp_sentinel_client_bytes_total", "Message bytes received from the client.", "counter", withLabel(labels, "direction", DirectionToServer), float64(r.stats.BytesFromClient.Load())},
+		{"mcp_sentinel_client_bytes_total", "Message bytes sent to the client.", "counter", withLabel(labels, "direction", DirectionToClient), float64(r.stats.BytesToClient.Load())},
+		{"mcp_sentinel_gas_used", "Gas consumed by the session.", "gauge", labels, float64(r.gasUsed.Load())},
+		{"mcp_sentinel_degradation_level", "Current degradation ladder level (0 = full checks).", "gauge", labels, float64(r.DegradationLevel())},
+		{"mcp_sentinel_protection_degraded", "Whether security checks are weaker than configured (1 = degraded).", "gauge", labels, boolGauge(len(degraded) > 0)},
+		{"mcp_sentinel_session_paused", "Whether an operator has paused the session (1 = paused).", "gauge", labels, boolGauge(r.PauseState().Paused)},
+		{"mcp_sentinel_paused_calls", "Tool calls held by an operator pause.", "gauge", labels, float64(r.pauseQueued.Load())},
+	}
+	if gas := r.Gas(); gas.Budget > 0 {
+		metrics = append(metrics,
+			Metric{"mcp_sentinel_gas_budget", "Gas budget of the session.", "gauge", labels, float64(gas.Budget)},
+			Metric{"mcp_sentinel_gas_remaining", "Gas left in the session's budget.", "gauge", labels, float64(gas.Remaining)},
+		)
+	}
+	if r.upstream != nil {
+		metrics = append(metrics,
+			Metric{"mcp_sentinel_server_messages_total", "Messages received from the server.", "counter", labels, float64(r.stats.FromServer.Load())},
+			Metric{"mcp_sentinel_relayed_total", "Server-initiated messages relayed to the client.", "counter", withLabel(labels, "direction", "to_client"), float64(r.stats.RelayedToClient.Load())},
+			Metric{"mcp_sentinel_relayed_total", "Client responses relayed to the server.", "counter", withLabel(labels, "direction", "to_server"), float64(r.stats.RelayedToServer.Load())},
+			Metric{"mcp_sentinel_unmatched_responses_total", "Server responses matching no pending request.", "counter", labels, float64(r.stats.UnmatchedResponses.Load())},
+			Metric{"mcp_sentinel_duplicate_responses_total", "Server responses to requests already answered.", "counter", labels, float64(r.stats.DuplicateResponses.Load())},
+			Metric{"mcp_sentinel_late_responses_total", "Server responses to requests already timed out or abandoned.", "counter", labels, float64(r.stats.LateResponses.Load())},
+			Metric{"mcp_sentinel_client_responses_rejected_total", "Client responses matching no pending server request.", "counter", labels, float64(r.stats.ClientResponsesRejected.Load())},
+			Metric{"mcp_sentinel_request_timeouts_total", "Requests the server did not answer in time.", "counter", labels, float64(r.stats.RequestTimeouts.Load())},
+			Metric{"mcp_sentinel_partial_results_total", "Timed-out tool calls answered with their salvaged partial output.", "counter", labels, float64(r.stats.PartialResults.Load())},
+			Metric{"mcp_sentinel_orphaned_requests_total", "Requests the server left unanswered past the orphan deadline.", "counter", withLabel(labels, "direction", DirectionToServer), float64(r.stats.OrphanedRequests.Load())},
+			Metric{"mcp_sentinel_orphaned_requests_total", "Requests the client left unanswered past the orphan deadline.", "counter", withLabel(labels, "direction", DirectionToClient), float64(r.stats.OrphanedRelayed.Load())},
+			Metric{"mcp_sentinel_pending_requests", "Requests awaiting the server's response.", "gauge", withLabel(labels, "direction", DirectionToServer), float64(r.pending.len())},
+			Metric{"mcp_sentinel_pending_requests", "Requests awaiting the client's response.", "gauge", withLabel(labels, "direction", DirectionToClient), float64(r.relayed.len())},
+		)
+	}
+	for _, reason := range degraded {
+		metrics = append(metrics, Metric{"mcp_sentinel_protection_degraded_reason", "Reasons security checks are weaker than configured.", "gauge", withLabel(labels, "reason", reason), 1})
+	}
+	metrics = append(metrics, r.panicMetrics()...)
+	return append(metrics, r.queueMetrics()...)
+}
+
+// boolGauge converts a flag to a gauge value.
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// DegradationLevel returns the current degradation level, or LevelFull
+// if no ladder is configured.
+func (r *Router) DegradationLevel() degrade.Level {
+	if r.ladder == nil {
+		return degrade.LevelFull
+	}
+	return r.ladder.Level()
+}
+
+// reportBackend feeds the outcome of a sentinel call into the ladder.
+func (r *Router) reportBackend(err error) {
+	if r.ladder == nil {
+		return
+	}
+	if err != nil {
+		r.ladder.ReportFailure(err)
+		return
+	}
+	r.ladder.ReportSuccess()
+}
+
+// failsafeResult applies the ladder's failsafe mode.
+func (r *Router) failsafeResult() *sentinel.CheckResult {
+	details := map[string]interface{}{"degradation_level": degrade.LevelFailsafe.String()}
+	if r.ladder.Failsafe() == degrade.FailsafeAllowAll {
+		return &sentinel.CheckResult{
+			Allowed: true,
+			Reason:  "failsafe allow-all: security checks disabled",
+			Details: details,
+		}
+	}
+	return &sentinel.CheckResult{
+		Allowed: false,
+		Reason:  "failsafe block-all: security backend unavailable",
+		Details: details,
+	}
+}
+
+// withLabel returns a copy of labels with one more label set.
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_DegradationFailsafe(t *testing.T) {
+	ladder, err := degrade.New(nil)
+	if err != nil {
+		t.Fatalf("degrade.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Degradation = ladder
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), "ok")
+		return jsonrpc.Serialize(resp)
+	}
+
+	params := map[string]interface{}{"name": "read_file", "arguments": map[string]string{}}
+	req, _ := jsonrpc.NewRequest("tools/call", params, 1)
+	data, _ := jsonrpc.Serialize(req)
+
+	// Native-only skips FFI checks but still forwards
+	ladder.Set(degrade.LevelNativeOnly, "test")
+	response, _ := r.RouteMessage(data)
+	if resp, _ := jsonrpc.Parse(response); resp.Error != nil {
+		t.Errorf("native-only should allow, got %v", resp.Error)
+	}
+
+	// Block-all failsafe refuses tool calls
+	ladder.Set(degrade.LevelFailsafe, "test")
+	response, _ = r.RouteMessage(data)
+	if resp, _ := jsonrpc.Parse(response); resp.Error == nil {
+		t.Error("failsafe block-all should block tool calls")
+	}
+
+	if h := r.Health(); h.DegradationLevel != "failsafe" || !h.DegradationPinned {
+		t.Errorf("unexpected health: %+v", h)
+	}
+}
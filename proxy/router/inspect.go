@@ -0,0 +1,222 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// ResponseAction is what the router does with server content the
+// sentinel rejects.
+type ResponseAction string
+
+const (
+	// ResponseBlock replaces the whole response with an error
+	ResponseBlock ResponseAction = "block"
+	// ResponseSanitize replaces only the rejected items' text
+	ResponseSanitize ResponseAction = "sanitize"
+)
+
+// SanitizedText replaces content removed by ResponseSanitize.
+const SanitizedText = "[content removed by mcp-sentinel]"
+
+// ResponseInspection submits text returned by the server to the
+// sentinel council before it reaches the client.
+//
+// Each text item of a tools/call result and each text entry of a
+// resources/read result is voted on separately, so a sanitizing policy
+// can drop a poisoned item while keeping the rest.
+//
+// # Security Notes
+//
+// This is the response half of the threat model: a malicious server
+// can plant instructions in content the model will read. Inspection
+// fails closed; if the sentinel errors the response is not delivered.
+// It is skipped, and the skip recorded on the decision, once the
+// degradation ladder has shed council checks.
+type ResponseInspection struct {
+	// Action is taken on rejected content (default ResponseBlock)
+	Action ResponseAction
+
+	// RiskScore is passed to the council with each item (default 0.5)
+	RiskScore float64
+
+	// MaxItemBytes truncates each item sent to the sentinel; the
+	// delivered content is never truncated (default 64 KiB)
+	MaxItemBytes int
+}
+
+// inspectedItem is the decision-detail form of a rejected item.
+type inspectedItem struct {
+	Index  int    `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// inspectResponse runs a tools/call or resources/read response through
+// the sentinel and applies the configured action. scanned is the
+// incrementally scanned result of a large tools/call response (nil to
+// decode the response); its text prefixes are what the sentinel sees.
+//
+// # Returns
+//   - The response to deliver (sanitized if needed)
+//   - Non-empty reason if the response must be blocked
+//   - Error if the sentinel could not inspect the content
+func (r *Router) inspectResponse(d *Decision, msg *jsonrpc.Message, response []byte, scanned *mcptypes.CallToolResult) ([]byte, string, error) {
+	if level := r.DegradationLevel(); level >= degrade.LevelSkipCouncil {
+		d.Details = withDetailMap(d.Details, "response_inspection", "skipped: "+level.String())
+		return response, "", nil
+	}
+
+	field, subject := "content", fmt.Sprintf("tool result: %s", d.Tool)
+	if msg.Method == "resources/read" {
+		field, subject = "contents", fmt.Sprintf("resource: %s", jsonrpc.ExtractResourceURI(msg))
+	}
+
+	// decode materializes the items, which a scanned response defers
+	// until an item must be sanitized
+	var resp *jsonrpc.Message
+	var result map[string]json.RawMessage
+	var items []map[string]json.RawMessage
+	decode := func() bool {
+		var err error
+		resp, err = jsonrpc.Parse(response)
+		if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+			return false
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil || result == nil {
+			return false
+		}
+		return json.Unmarshal(result[field], &items) == nil
+	}
+
+	var texts []string
+	if scanned != nil {
+		for _, c := range scanned.Content {
+			text := c.Text
+			if c.Resource != nil {
+				text = c.Resource.Text
+			}
+			texts = append(texts, text)
+		}
+	} else {
+		if !decode() {
+			return response, "", nil
+		}
+		for _, item := range items {
+			text, _ := itemText(item)
+			texts = append(texts, text)
+		}
+	}
+
+	cfg := r.responseInspection
+	var rejected []inspectedItem
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		if len(text) > cfg.MaxItemBytes {
+			text = text[:cfg.MaxItemBytes]
+		}
+		req := &sentinel.CouncilVoteRequest{
+			Action:    "Deliver " + subject,
+			ToolName:  d.Tool,
+			RiskScore: cfg.RiskScore,
+			Context:   map[string]interface{}{"direction": "response", "item": i, "content": text},
+		}
+		verdict, err := r.runCheck(d, CheckResponse, func() (*sentinel.CheckResult, error) {
+			return r.sentinel.VoteCouncil(d.ctx, req)
+		})
+		r.reportBackend(err)
+		if err != nil {
+			return nil, "", err
+		}
+		if !verdict.Allowed {
+			rejected = append(rejected, inspectedItem{Index: i, Reason: verdict.Reason})
+		}
+	}
+	if len(rejected) == 0 {
+		return response, "", nil
+	}
+
+	d.Details = withDetailMap(d.Details, "response_rejected", rejected)
+	log.Printf("router: session %s: sentinel rejected %d item(s) of %s", r.sessionID, len(rejected), subject)
+	if cfg.Action != ResponseSanitize {
+		return nil, fmt.Sprintf("%s item %d rejected: %s", subject, rejected[0].Index, rejected[0].Reason), nil
+	}
+
+	if items == nil && (!decode() || len(items) < len(texts)) {
+		return nil, fmt.Sprintf("%s item %d rejected: %s", subject, rejected[0].Index, rejected[0].Reason), nil
+	}
+	for _, rej := range rejected {
+		setItemText(items[rej.Index], SanitizedText)
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return nil, "", fmt.Errorf("router: encode sanitized %s: %w", field, err)
+	}
+	result[field] = encoded
+	sanitized, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return nil, "", fmt.Errorf("router: build sanitized response: %w", err)
+	}
+	r.stats.ResponsesSanitized.Add(1)
+	out, err := jsonrpc.Serialize(sanitized)
+	return out, "", err
+}
+
+// applyResponseInspection inspects *response in place. It reports true
+// with the reply to send instead when the response must not be
+// delivered.
+func (r *Router) applyResponseInspection(d *Decision, msg *jsonrpc.Message, response *[]byte, scanned *mcptypes.CallToolResult) ([]byte, bool) {
+	inspected, reason, err := r.inspectResponse(d, msg, *response, scanned)
+	if err != nil {
+		r.stats.Errors.Add(1)
+		reply, _ := r.errorResponse(d, VerdictError, msg.ID, jsonrpc.InternalError, "Security check failed", err.Error())
+		return reply, true
+	}
+	if reason != "" {
+		r.stats.MessagesBlocked.Add(1)
+		r.RecordAnomaly(anomaly.SignalBlock, reason)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Blocked by security", reason)
+		return reply, true
+	}
+	*response = inspected
+	return nil, false
+}
+
+// itemText returns the text of a content block or resource contents
+// entry, looking inside embedded resources.
+func itemText(item map[string]json.RawMessage) (string, bool) {
+	if raw, ok := item["resource"]; ok {
+		var inner map[string]json.RawMessage
+		if json.Unmarshal(raw, &inner) != nil {
+			return "", false
+		}
+		item = inner
+	}
+	var text string
+	if raw, ok := item["text"]; !ok || json.Unmarshal(raw, &text) != nil {
+		return "", false
+	}
+	return text, true
+}
+
+// setItemText replaces the text located by itemText.
+func setItemText(item map[string]json.RawMessage, text string) {
+	encoded, _ := json.Marshal(text)
+	if raw, ok := item["resource"]; ok {
+		var inner map[string]json.RawMessage
+		if json.Unmarshal(raw, &inner) == nil {
+			inner["text"] = encoded
+			item["resource"], _ = json.Marshal(inner)
+		}
+		return
+	}
+	item["text"] = encoded
+}
@@ -0,0 +1,229 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// Check names used for panic isolation, metrics, and policy overrides.
+const (
+	CheckRegistry         = "registry"
+	CheckState            = "state"
+	CheckCouncil          = "council"
+	CheckCompletion       = "completion"
+	CheckGuardrail        = "guardrail"
+	CheckURIScheme        = "uri_scheme"
+	CheckResourceTemplate = "resource_template"
+	CheckResponse         = "response"
+	CheckPolicy           = "policy"
+	CheckTaint            = "taint"
+	CheckSchema           = "schema"
+)
+
+// PanicMode selects what a panicking (or disabled) check decides.
+type PanicMode string
+
+const (
+	// PanicFailClosed blocks the message (default)
+	PanicFailClosed PanicMode = "fail-closed"
+	// PanicFailOpen treats the check as passed
+	PanicFailOpen PanicMode = "fail-open"
+)
+
+// PanicPolicy configures per-check panic isolation.
+//
+// Every check runs inside its own recover boundary regardless of this
+// policy; the policy only decides the outcome of a panic and when a
+// repeatedly panicking check is taken out of service.
+//
+// # Security Notes
+//
+// Recovery covers Go panics, including those raised by Go code on the
+// sentinel side of the cgo boundary. A crash inside native Rust code is
+// not a Go panic and still terminates the process.
+type PanicPolicy struct {
+	// Mode is the outcome of a panic for checks without an override
+	Mode PanicMode
+
+	// Overrides sets the mode for individual checks by name
+	Overrides map[string]PanicMode
+
+	// DisableAfter disables a check once it has panicked this many times
+	// in the session; a disabled check is no longer run and always
+	// decides according to its mode (zero never disables)
+	DisableAfter int
+
+	// OnDisable is invoked when a check is disabled (may be nil)
+	OnDisable func(sessionID, check string, panics uint64)
+}
+
+// DefaultPanicPolicy returns a fail-closed policy that disables a check
+// after five panics.
+func DefaultPanicPolicy() *PanicPolicy {
+	return &PanicPolicy{
+		Mode:         PanicFailClosed,
+		DisableAfter: 5,
+	}
+}
+
+// mode returns the configured mode for check.
+func (p *PanicPolicy) mode(check string) PanicMode {
+	if m, ok := p.Overrides[check]; ok {
+		return m
+	}
+	if p.Mode == "" {
+		return PanicFailClosed
+	}
+	return p.Mode
+}
+
+// checkIsolation tracks panics and disabled checks for one session.
+type checkIsolation struct {
+	policy PanicPolicy
+
+	mu       sync.Mutex
+	panics   map[string]uint64
+	disabled map[string]bool
+}
+
+func newCheckIsolation(policy *PanicPolicy) *checkIsolation {
+	if policy == nil {
+		policy = &PanicPolicy{Mode: PanicFailClosed}
+	}
+	return &checkIsolation{
+		policy:   *policy,
+		panics:   make(map[string]uint64),
+		disabled: make(map[string]bool),
+	}
+}
+
+// isDisabled reports whether check has been taken out of service.
+func (c *checkIsolation) isDisabled(check string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.disabled[check]
+}
+
+// recordPanic counts a panic and reports whether it disabled the check.
+func (c *checkIsolation) recordPanic(check string) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.panics[check]++
+	n := c.panics[check]
+	limit := c.policy.DisableAfter
+	if limit > 0 && n >= uint64(limit) && !c.disabled[check] {
+		c.disabled[check] = true
+		return n, true
+	}
+	return n, false
+}
+
+// snapshot returns panic counts and disabled flags sorted by check name.
+func (c *checkIsolation) snapshot() (names []string, panics map[string]uint64, disabled map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	panics = make(map[string]uint64, len(c.panics))
+	disabled = make(map[string]bool, len(c.disabled))
+	for name, n := range c.panics {
+		names = append(names, name)
+		panics[name] = n
+		disabled[name] = c.disabled[name]
+	}
+	sort.Strings(names)
+	return names, panics, disabled
+}
+
+// runCheck executes a single check inside a recover boundary and
+// records its outcome as an audit event on d (which may be nil).
+//
+// A panic is converted into a result according to the check's panic
+// mode, so one faulty check cannot take down the proxy. Disabled checks
+// are not run at all.
+func (r *Router) runCheck(d *Decision, check string, fn func() (*sentinel.CheckResult, error)) (result *sentinel.CheckResult, err error) {
+	// Sentinel calls in fn trace under the check's span
+	if d != nil {
+		ctx, span := d.startSpan("check." + check)
+		prev := d.ctx
+		d.ctx = ctx
+		defer func() {
+			d.ctx = prev
+			span.SetError(err)
+			if err == nil {
+				span.SetAttribute("sentinel.allowed", result.Allowed)
+			}
+			span.End()
+		}()
+	}
+	defer func() {
+		fields := map[string]interface{}{"check": check}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["allowed"], fields["reason"] = result.Allowed, result.Reason
+			if d != nil && result.Reason != "" {
+				d.reasons = append(d.reasons, check+": "+result.Reason)
+			}
+			// A fail-open isolated check verified nothing
+			if result.Allowed && result.Details["isolated_check"] == nil {
+				d.ran(check)
+			}
+		}
+		d.event(EventCheck, fields)
+	}()
+
+	if r.isolation.isDisabled(check) {
+		return r.isolatedResult(check, "disabled after repeated panics"), nil
+	}
+
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		n, disabled := r.isolation.recordPanic(check)
+		log.Printf("router: session %s: check %s panicked (%d total): %v\n%s",
+			r.sessionID, check, n, p, debug.Stack())
+		if disabled {
+			log.Printf("router: ALERT: session %s: check %s disabled after %d panics; now %s",
+				r.sessionID, check, n, r.isolation.policy.mode(check))
+			if r.isolation.policy.OnDisable != nil {
+				r.isolation.policy.OnDisable(r.sessionID, check, n)
+			}
+		}
+		result, err = r.isolatedResult(check, fmt.Sprintf("panicked: %v", p)), nil
+	}()
+	return fn()
+}
+
+// isolatedResult is the decision of a panicked or disabled check.
+func (r *Router) isolatedResult(check, why string) *sentinel.CheckResult {
+	mode := r.isolation.policy.mode(check)
+	return &sentinel.CheckResult{
+		Allowed: mode == PanicFailOpen,
+		Reason:  fmt.Sprintf("%s check %s (%s)", check, why, mode),
+		Details: map[string]interface{}{"isolated_check": check},
+	}
+}
+
+// panicMetrics returns per-check panic and disablement samples.
+func (r *Router) panicMetrics() []Metric {
+	names, panics, disabled := r.isolation.snapshot()
+	metrics := make([]Metric, 0, 2*len(names))
+	for _, name := range names {
+		labels := map[string]string{"session": r.sessionID, "check": name}
+		off := 0.0
+		if disabled[name] {
+			off = 1
+		}
+		metrics = append(metrics,
+			Metric{"mcp_sentinel_check_panics_total", "Panics recovered per security check.", "counter", labels, float64(panics[name])},
+			Metric{"mcp_sentinel_check_disabled", "Whether a security check was disabled after repeated panics.", "gauge", labels, off},
+		)
+	}
+	return metrics
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRunCheck_RecoversAndDisables(t *testing.T) {
+	var disabledCheck string
+	cfg := DefaultConfig()
+	cfg.CheckPanics = &PanicPolicy{
+		Mode:         PanicFailClosed,
+		Overrides:    map[string]PanicMode{CheckCouncil: PanicFailOpen},
+		DisableAfter: 2,
+		OnDisable:    func(_, check string, _ uint64) { disabledCheck = check },
+	}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	calls := 0
+	boom := func() (*sentinel.CheckResult, error) {
+		calls++
+		panic("nil map write")
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := r.runCheck(nil, CheckState, boom)
+		if err != nil {
+			t.Fatalf("runCheck returned error: %v", err)
+		}
+		if result.Allowed {
+			t.Error("fail-closed check should block after a panic")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("disabled check should not run, got %d calls", calls)
+	}
+	if disabledCheck != CheckState {
+		t.Errorf("expected OnDisable for %s, got %q", CheckState, disabledCheck)
+	}
+
+	if result, _ := r.runCheck(nil, CheckCouncil, boom); !result.Allowed {
+		t.Error("fail-open override should allow after a panic")
+	}
+
+	found := false
+	for _, m := range r.Metrics() {
+		if m.Name == "mcp_sentinel_check_panics_total" && m.Labels["check"] == CheckState {
+			found = m.Value == 2
+		}
+	}
+	if !found {
+		t.Error("expected panic counter of 2 for state check")
+	}
+}
@@ -0,0 +1,136 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// NotifySessionTerminated is sent to the client when the kill-switch trips.
+const NotifySessionTerminated = "notifications/sentinel/session_terminated"
+
+// Incident is the bundle written when a session is terminated.
+type Incident struct {
+	SessionID     string                `json:"session_id"`
+	Time          time.Time             `json:"time"`
+	Score         float64               `json:"score"`
+	Threshold     float64               `json:"threshold"`
+	Trigger       string                `json:"trigger"`
+	Signals       []anomaly.Observation `json:"signals"`
+	PreviousTools []string              `json:"previous_tools"`
+	GasUsed       uint64                `json:"gas_used"`
+	Received      uint64                `json:"messages_received"`
+	Forwarded     uint64                `json:"messages_forwarded"`
+	Blocked       uint64                `json:"messages_blocked"`
+	Errors        uint64                `json:"errors"`
+}
+
+// RecordAnomaly feeds a signal into the session's anomaly score.
+//
+// Components outside the router (transports, scanners, quota managers)
+// call this to contribute evidence. When the score crosses the
+// configured threshold the session is terminated: all further messages
+// are refused, the client is notified, and an incident bundle is written.
+// Does nothing if anomaly scoring is disabled.
+func (r *Router) RecordAnomaly(sig anomaly.Signal, detail string) {
+	if r.anomaly == nil {
+		return
+	}
+	score, tripped := r.anomaly.Record(sig, detail)
+	if tripped {
+		r.terminate(score, detail)
+	}
+}
+
+// Terminated reports whether the kill-switch has ended this session.
+func (r *Router) Terminated() bool {
+	return r.terminated.Load()
+}
+
+// terminate ends the session after the kill-switch trips.
+func (r *Router) terminate(score float64, trigger string) {
+	if !r.terminated.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("router: session %s terminated (anomaly score %.2f): %s", r.sessionID, score, trigger)
+	r.endPause(true)
+	if r.stateStore != nil {
+		// A restart must not undo the termination
+		r.saveState()
+	}
+
+	incident := r.buildIncident(score, trigger)
+	if r.incidentDir != "" {
+		if path, err := writeIncident(r.incidentDir, incident); err != nil {
+			log.Printf("router: failed to write incident bundle: %v", err)
+		} else {
+			log.Printf("router: incident bundle written to %s", path)
+		}
+	}
+
+	params := map[string]interface{}{
+		"session_id": r.sessionID,
+		"score":      score,
+		"reason":     trigger,
+	}
+	if err := r.notify(NotifySessionTerminated, params); err != nil {
+		log.Printf("router: failed to notify client of termination: %v", err)
+	}
+}
+
+// buildIncident snapshots session state for the incident bundle.
+func (r *Router) buildIncident(score float64, trigger string) *Incident {
+	r.toolsMu.Lock()
+	tools := make([]string, len(r.previousTools))
+	copy(tools, r.previousTools)
+	r.toolsMu.Unlock()
+
+	received, forwarded, blocked, errs := r.GetStats()
+	return &Incident{
+		SessionID:     r.sessionID,
+		Time:          time.Now().UTC(),
+		Score:         score,
+		Threshold:     r.anomaly.Threshold(),
+		Trigger:       trigger,
+		Signals:       r.anomaly.Observations(),
+		PreviousTools: tools,
+		GasUsed:       r.gasUsed.Load(),
+		Received:      received,
+		Forwarded:     forwarded,
+		Blocked:       blocked,
+		Errors:        errs,
+	}
+}
+
+// writeIncident writes an incident bundle as JSON into dir.
+func writeIncident(dir string, incident *Incident) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(incident, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("incident-%s-%d.json", incident.SessionID, incident.Time.UnixNano())
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// notify sends a notification to the client.
+func (r *Router) notify(method string, params interface{}) error {
+	msg, err := jsonrpc.NewNotification(method, params)
+	if err != nil {
+		return err
+	}
+	data, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return err
+	}
+	return r.transport.Send(data)
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestKillSwitch_TerminatesSession(t *testing.T) {
+	var sent [][]byte
+	mt := &mockTransport{
+		sendFunc: func(data []byte) error {
+			sent = append(sent, data)
+			return nil
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.Anomaly = &anomaly.Config{Threshold: 3}
+	cfg.IncidentDir = t.TempDir()
+	r := NewWithConfig(mt, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		t.Fatal("terminated session must not forward")
+		return nil, nil
+	}
+
+	r.RecordAnomaly(anomaly.SignalBlock, "first")
+	if r.Terminated() {
+		t.Fatal("session terminated below threshold")
+	}
+	r.RecordAnomaly(anomaly.SignalBlock, "second")
+	if !r.Terminated() {
+		t.Fatal("session should be terminated above threshold")
+	}
+
+	// Client was notified
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(sent))
+	}
+	note, err := jsonrpc.Parse(sent[0])
+	if err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+	if note.Method != NotifySessionTerminated {
+		t.Errorf("expected %s, got %s", NotifySessionTerminated, note.Method)
+	}
+
+	// Incident bundle was written
+	matches, _ := filepath.Glob(filepath.Join(cfg.IncidentDir, "incident-*.json"))
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 incident bundle, got %d", len(matches))
+	}
+	if info, err := os.Stat(matches[0]); err != nil || info.Size() == 0 {
+		t.Errorf("incident bundle empty or unreadable: %v", err)
+	}
+
+	// Further messages are refused
+	req, _ := jsonrpc.NewRequest("tools/list", nil, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, err := r.RouteMessage(data)
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	resp, _ := jsonrpc.Parse(response)
+	if resp.Error == nil {
+		t.Error("expected error response from terminated session")
+	}
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"bytes"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonscan"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// DefaultLargeResultThreshold is the tools/call response size above
+// which result checks scan the response incrementally.
+const DefaultLargeResultThreshold = 1 << 20
+
+// resultScan is the decision-detail form of an incremental scan.
+type resultScan struct {
+	Bytes     int64 `json:"bytes"`
+	Items     int   `json:"items"`
+	Truncated []int `json:"truncated_items,omitempty"`
+}
+
+// toolResult extracts the result of a tools/call response for the
+// result checks, once per response.
+//
+// Responses above the large-result threshold are scanned with
+// jsonscan, keeping a bounded prefix of each content string (at least
+// the inspection item size) instead of a full copy of the document.
+//
+// # Returns
+//   - The result (nil if the response has none or does not decode)
+//   - The same result if it came from a scan, for checks that must
+//     know their input is bounded; nil if it was fully decoded
+//
+// # Security Notes
+//
+// Checks on a scanned result see only the kept prefixes and the first
+// jsonscan.Limits.MaxItems content blocks. Response inspection already
+// truncates items to MaxItemBytes, so the scan does not narrow it
+// except for results with more blocks than the item limit.
+func (r *Router) toolResult(d *Decision, response []byte) (result, scanned *mcptypes.CallToolResult) {
+	if r.largeResultThreshold <= 0 || len(response) <= r.largeResultThreshold {
+		resp, err := jsonrpc.Parse(response)
+		if err != nil {
+			r.captureRepro(crash.ReproParse, "tools/call", response, err)
+			return nil, nil
+		}
+		result, err := mcptypes.DecodeResult[mcptypes.CallToolResult](resp)
+		if err != nil {
+			r.captureValidation("tools/call", response, err)
+			return nil, nil
+		}
+		return result, nil
+	}
+
+	lim := jsonscan.DefaultLimits()
+	if r.responseInspection != nil {
+		lim.MaxText = max(lim.MaxText, r.responseInspection.MaxItemBytes)
+	}
+	scan, err := jsonscan.ScanToolResponse(bytes.NewReader(response), lim)
+	if err != nil {
+		log.Printf("router: session %s: %s result of %d bytes did not scan: %v", r.sessionID, d.Tool, len(response), err)
+		r.captureRepro(crash.ReproParse, "tools/call", response, err)
+		return nil, nil
+	}
+	d.Details = withDetailMap(d.Details, "result_scan", resultScan{Bytes: scan.Bytes, Items: scan.Items, Truncated: scan.TruncatedItems})
+	r.stats.LargeResultsScanned.Add(1)
+	if scan.Result == nil {
+		return nil, nil
+	}
+	return scan.Result, scan.Result
+}
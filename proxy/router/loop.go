@@ -0,0 +1,102 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// MetaInstances is the _meta key listing, on a forwarded initialize
+// request, the instance IDs of the sentinels it has passed through,
+// nearest the client first.
+const MetaInstances = "io.mcp-sentinel/instances"
+
+// CodeProxyLoop is the error code of an initialize request refused
+// because its path through sentinels loops.
+const CodeProxyLoop = -32015
+
+// DefaultMaxProxyHops is the default LoopGuard.MaxHops.
+const DefaultMaxProxyHops = 16
+
+// LoopGuard refuses sessions whose server is, directly or through other
+// proxies, this proxy again, which would otherwise forward each request
+// to itself until resources run out.
+//
+// Each sentinel appends its instance ID under MetaInstances to the
+// _meta of the initialize request it forwards. A proxy that finds its
+// own ID there has received its own request back, and refuses it with
+// CodeProxyLoop; so does one that finds MaxHops IDs already, which ends
+// a loop through fresh processes, such as a server command that starts
+// the proxy again with the same configuration. The refusal travels back
+// along the loop, so the client's initialize fails instead of hanging.
+//
+// # Security Notes
+//
+// A client can write any _meta it likes, but only to fail its own
+// session: instance IDs are random, and padding the list only reaches
+// MaxHops sooner. Servers see the IDs, which identify the proxy process
+// and nothing else.
+type LoopGuard struct {
+	// InstanceID identifies this proxy process (required); every
+	// session of the process shares it
+	InstanceID string
+
+	// MaxHops refuses initialize requests that have already passed
+	// through this many sentinels (zero uses DefaultMaxProxyHops)
+	MaxHops int
+}
+
+// NewInstanceID returns a random instance ID for LoopGuard.
+func NewInstanceID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// checkLoop appends this proxy's instance ID to an initialize request's
+// MetaInstances. It returns the message to forward, or an error reply
+// and true if the request has looped.
+func (r *Router) checkLoop(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, bool) {
+	params, meta, ok := chainParams(msg.Params)
+	if !ok {
+		return data, false
+	}
+	var path []string
+	if raw, found := meta[MetaInstances]; found && json.Unmarshal(raw, &path) != nil {
+		path = nil
+	}
+
+	maxHops := r.loopGuard.MaxHops
+	if maxHops <= 0 {
+		maxHops = DefaultMaxProxyHops
+	}
+	var reason string
+	switch {
+	case slices.Contains(path, r.loopGuard.InstanceID):
+		reason = fmt.Sprintf("proxy loop: the request already passed through this proxy (instance %s)", r.loopGuard.InstanceID)
+	case len(path) >= maxHops:
+		reason = fmt.Sprintf("proxy loop: the request already passed through %d sentinels (limit %d)", len(path), maxHops)
+	}
+	if reason != "" {
+		d.Details = withDetailMap(d.Details, "instances", path)
+		log.Printf("audit: session %s: refused initialize: %s; the server is configured to reach this proxy again", r.sessionID, reason)
+		r.stats.ProxyLoops.Add(1)
+		r.stats.MessagesBlocked.Add(1)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, CodeProxyLoop, "Proxy loop", reason)
+		return reply, true
+	}
+
+	meta[MetaInstances], _ = json.Marshal(append(path, r.loopGuard.InstanceID))
+	params["_meta"], _ = json.Marshal(meta)
+	msg.Params, _ = json.Marshal(params)
+	rewritten, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return data, false
+	}
+	return rewritten, false
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestLoopGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		path     []string
+		refused  bool
+		forwards []string
+	}{
+		{"first hop", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+			nil, false, []string{"self"}},
+		{"behind another sentinel", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"_meta":{"io.mcp-sentinel/instances":["edge"]}}}`,
+			nil, false, []string{"edge", "self"}},
+		{"own instance", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"_meta":{"io.mcp-sentinel/instances":["self","edge"]}}}`,
+			[]string{"self", "edge"}, true, nil},
+		{"too many hops", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"_meta":{"io.mcp-sentinel/instances":["a","b","c"]}}}`,
+			[]string{"a", "b", "c"}, true, nil},
+		{"other methods unmarked", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+			nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LoopGuard = &LoopGuard{InstanceID: "self", MaxHops: 3}
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			var forwarded []byte
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				forwarded = data
+				req, _ := jsonrpc.Parse(data)
+				resp, _ := jsonrpc.NewResponse(req.ID, map[string]interface{}{})
+				return jsonrpc.Serialize(resp)
+			}
+
+			response, _ := r.RouteMessage([]byte(tt.request))
+			resp, _ := jsonrpc.Parse(response)
+			if tt.refused {
+				if errorCode(resp) != CodeProxyLoop || forwarded != nil {
+					t.Fatalf("response %s (forwarded %s), expected a proxy loop refusal", response, forwarded)
+				}
+				if got := r.Stats().ProxyLoops; got != 1 {
+					t.Errorf("ProxyLoops = %d, expected 1", got)
+				}
+				return
+			}
+			var sent struct {
+				Params struct {
+					Meta map[string][]string `json:"_meta"`
+				} `json:"params"`
+			}
+			if err := json.Unmarshal(forwarded, &sent); err != nil {
+				t.Fatalf("forwarded %s: %v", forwarded, err)
+			}
+			if got := sent.Params.Meta[MetaInstances]; !slices.Equal(got, tt.forwards) {
+				t.Errorf("forwarded instances %v, expected %v", got, tt.forwards)
+			}
+		})
+	}
+}
+
+func TestLoopGuard_SelfUpstream(t *testing.T) {
+	// Two sessions of one proxy process, the first forwarding to the second
+	guard := &LoopGuard{InstanceID: NewInstanceID()}
+	newSession := func() *Router {
+		cfg := DefaultConfig()
+		cfg.LoopGuard = guard
+		return NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	}
+	outer, inner := newSession(), newSession()
+	outer.forwardFunc = inner.RouteMessage
+	inner.forwardFunc = outer.RouteMessage
+
+	response, _ := outer.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || errorCode(resp) != CodeProxyLoop {
+		t.Fatalf("response %s, expected the inner session's proxy loop refusal", response)
+	}
+	if outer.Stats().ProxyLoops != 0 || inner.Stats().ProxyLoops != 1 {
+		t.Errorf("ProxyLoops outer %d, inner %d; expected 0 and 1", outer.Stats().ProxyLoops, inner.Stats().ProxyLoops)
+	}
+}
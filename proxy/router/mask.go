@@ -0,0 +1,29 @@
+package router
+
+import (
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// maskResponse rewrites server identity fields shown to the client.
+// Responses that cannot be masked are returned unchanged.
+func (r *Router) maskResponse(msg *jsonrpc.Message, response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		return response
+	}
+	changed, err := r.masker.Response(msg.Method, resp)
+	if err != nil {
+		log.Printf("router: session %s: %v", r.sessionID, err)
+		return response
+	}
+	if !changed {
+		return response
+	}
+	data, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return response
+	}
+	return data
+}
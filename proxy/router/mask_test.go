@@ -0,0 +1,33 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mask"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_MasksServerIdentity(t *testing.T) {
+	m, err := mask.New(mask.Config{Name: "mcp-server", StripURLs: true})
+	if err != nil {
+		t.Fatalf("mask.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.ServerMask = m
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func([]byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{},"serverInfo":{"name":"acme-search","version":"2.1.0","websiteUrl":"https://acme.example"}}}`), nil
+	}
+
+	response, err := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"c","version":"1"}}}`))
+	if err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	if strings.Contains(string(response), "acme") {
+		t.Errorf("server identity leaked to client: %s", response)
+	}
+	if !strings.Contains(string(response), `"name":"mcp-server"`) {
+		t.Errorf("expected masked server name, got %s", response)
+	}
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// messageContext describes a message to the middleware chain and the
+// tools/call stages.
+func (r *Router) messageContext(ctx context.Context, msg *jsonrpc.Message, dir middleware.Direction) *middleware.MessageContext {
+	name := transport.Name(r.transport)
+	if dir == middleware.ServerToClient && r.upstream != nil {
+		name = transport.Name(r.upstream)
+	}
+	return &middleware.MessageContext{
+		Context:   ctx,
+		SessionID: r.sessionID,
+		Direction: dir,
+		Message:   msg,
+		Transport: name,
+	}
+}
+
+// requestCancels holds the cancel functions of client requests being
+// routed, by request ID.
+type requestCancels struct {
+	mu   sync.Mutex
+	byID map[string]*requestCancel
+}
+
+// requestCancel cancels one request's context.
+type requestCancel struct {
+	cancel context.CancelFunc
+}
+
+// cancellable derives a request's routing context, cancelled when the
+// client cancels the request; release frees it once routing ends.
+func (r *Router) cancellable(ctx context.Context, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	rc := &requestCancel{cancel: cancel}
+	key := string(id)
+	c := &r.cancels
+	c.mu.Lock()
+	if c.byID == nil {
+		c.byID = make(map[string]*requestCancel)
+	}
+	c.byID[key] = rc
+	c.mu.Unlock()
+	return ctx, func() {
+		c.mu.Lock()
+		if c.byID[key] == rc {
+			delete(c.byID, key)
+		}
+		c.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest cancels the routing context of the request a client's
+// notifications/cancelled names.
+func (r *Router) cancelRequest(params json.RawMessage) {
+	var note struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &note) != nil {
+		return
+	}
+	r.cancels.mu.Lock()
+	rc := r.cancels.byID[string(note.RequestID)]
+	r.cancels.mu.Unlock()
+	if rc != nil {
+		rc.cancel()
+	}
+}
+
+// relayServerMessage passes a server request or notification through
+// the middleware chain on its way to the client. It returns the
+// message to relay, or nil if a middleware dropped it.
+func (r *Router) relayServerMessage(msg *jsonrpc.Message, data []byte) ([]byte, error) {
+	if r.middleware == nil {
+		return data, nil
+	}
+	mc := r.messageContext(context.Background(), msg, middleware.ServerToClient)
+	return r.middleware.Execute(mc, data, func(_ *middleware.MessageContext, data []byte) ([]byte, error) {
+		return data, nil
+	})
+}
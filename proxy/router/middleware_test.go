@@ -0,0 +1,102 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestMiddleware_Directions(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	cfg := DefaultConfig()
+	cfg.Middleware = middleware.New(func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+		mu.Lock()
+		seen = append(seen, string(mc.Direction)+" "+mc.Message.Method)
+		mu.Unlock()
+		if mc.Direction == middleware.ServerToClient && mc.Message.Method == "notifications/message" {
+			return nil, nil // dropped
+		}
+		return next(mc, data)
+	})
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	expectMessage(t, server, `"method":"ping"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"hidden"}}`))
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+	expectMessage(t, client, `notifications/progress`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	expectMessage(t, client, `"id":1`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"client_to_server ping",
+		"server_to_client notifications/message",
+		"server_to_client notifications/progress",
+	}
+	if strings.Join(seen, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("middleware saw %q, expected %q", seen, expected)
+	}
+}
+
+func TestMiddleware_ClientCancel(t *testing.T) {
+	entered := make(chan struct{})
+	cfg := DefaultConfig()
+	cfg.Stages = []Stage{{Name: "slow", Middleware: func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+		close(entered)
+		<-mc.Context.Done()
+		return nil, mc.Context.Err()
+	}}}
+	var forwarded []string
+	r := newStagedRouter(cfg, &forwarded)
+
+	type result struct {
+		response []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search"}}`))
+		done <- result{response, err}
+	}()
+	<-entered
+
+	// Cancelling another request leaves the call running
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}}`))
+	select {
+	case <-done:
+		t.Fatal("call ended on another request's cancellation")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`))
+	select {
+	case res := <-done:
+		if res.response != nil || res.err == nil || !strings.Contains(res.err.Error(), "cancelled") {
+			t.Errorf("cancelled call = %s, %v", res.response, res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("call not cancelled")
+	}
+	for _, f := range forwarded {
+		if strings.Contains(f, "tools/call") {
+			t.Errorf("cancelled call was forwarded: %s", f)
+		}
+	}
+	if s := r.Stats(); s.Errors != 1 {
+		t.Errorf("errors = %d, expected 1", s.Errors)
+	}
+}
@@ -0,0 +1,213 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/normalize"
+)
+
+// Normalization neutralizes invisible Unicode and homoglyphs before any
+// check reads a client message (see package normalize).
+//
+// The method name and, in tools/call, the tool name are replaced by
+// their skeletons, so tools/call spelled with a Cyrillic "а" is checked
+// and forwarded as tools/call, and a deny rule for execute_command also
+// matches the name spelled with a Greek "ο". String arguments, keys
+// included, lose their invisible characters; FoldArguments also folds
+// their homoglyphs. In tools/list results, invisible characters are
+// removed from tool titles and descriptions, and tool names that are
+// not their own skeleton are reported.
+//
+// # Security Notes
+//
+// Folding arguments changes legitimate non-Latin text, such as Greek or
+// Cyrillic prose, into look-alike ASCII; leave FoldArguments off unless
+// the tools take identifiers only. The content scanner matches
+// skeletons whether or not normalization is enabled.
+type Normalization struct {
+	// FoldArguments replaces homoglyphs in string arguments as well
+	FoldArguments bool
+
+	// Block refuses requests whose method or tool name needed
+	// normalizing instead of rewriting them
+	Block bool
+}
+
+// normalizedRequest is the decision-detail form of a normalized request.
+type normalizedRequest struct {
+	Method    string            `json:"method,omitempty"`
+	Tool      string            `json:"tool,omitempty"`
+	Arguments normalize.Changes `json:"arguments"`
+}
+
+// normalizeRequest normalizes a client message in place. It returns the
+// message to route, or an error reply and true if the message is
+// refused.
+func (r *Router) normalizeRequest(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, bool) {
+	if msg.Method == "" {
+		return data, false
+	}
+	var detail normalizedRequest
+	method, changes := normalize.Skeleton(msg.Method)
+	if changes.Any() {
+		detail.Method = msg.Method
+	}
+
+	var params map[string]interface{}
+	var args normalize.Changes
+	if method == "tools/call" && len(msg.Params) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(msg.Params))
+		dec.UseNumber()
+		if dec.Decode(&params) == nil {
+			if name, ok := params["name"].(string); ok {
+				if folded, c := normalize.Skeleton(name); c.Any() {
+					detail.Tool = name
+					params["name"] = folded
+				}
+			}
+			if arguments, ok := params["arguments"]; ok {
+				params["arguments"], args = r.normalizeValue(arguments)
+			}
+		}
+	}
+	if detail.Method == "" && detail.Tool == "" && !args.Any() {
+		return data, false
+	}
+
+	detail.Arguments = args
+	d.Details = withDetailMap(d.Details, "normalized", detail)
+	if r.normalization.Block && (detail.Method != "" || detail.Tool != "") {
+		name := "tool"
+		if detail.Method != "" {
+			name = "method"
+		}
+		reason := fmt.Sprintf("%s name contains invisible or look-alike characters", name)
+		r.stats.MessagesBlocked.Add(1)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Blocked by security", reason)
+		return reply, true
+	}
+
+	var notes []string
+	if detail.Method != "" {
+		notes = append(notes, fmt.Sprintf("method %q as %s", detail.Method, method))
+	}
+	if detail.Tool != "" {
+		notes = append(notes, fmt.Sprintf("tool %q as %s", detail.Tool, params["name"]))
+	}
+	if args.Any() {
+		notes = append(notes, fmt.Sprintf("arguments (%d invisible, %d look-alike characters)", args.Invisible, args.Homoglyphs))
+	}
+	d.reasons = append(d.reasons, "normalization: "+strings.Join(notes, "; "))
+	log.Printf("audit: session %s: normalized %s", r.sessionID, strings.Join(notes, "; "))
+	r.stats.Normalized.Add(1)
+
+	msg.Method, d.Method = method, method
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return data, false
+		}
+		msg.Params = encoded
+	}
+	rewritten, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return data, false
+	}
+	return rewritten, false
+}
+
+// normalizeValue normalizes the strings in a decoded JSON value, object
+// keys included.
+func (r *Router) normalizeValue(v interface{}) (interface{}, normalize.Changes) {
+	clean := normalize.StripInvisible
+	if r.normalization.FoldArguments {
+		clean = normalize.Skeleton
+	}
+	var total normalize.Changes
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch n := v.(type) {
+		case string:
+			s, c := clean(n)
+			total = total.Add(c)
+			return s
+		case []interface{}:
+			for i := range n {
+				n[i] = walk(n[i])
+			}
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(n))
+			for key, value := range n {
+				k, c := clean(key)
+				total = total.Add(c)
+				out[k] = walk(value)
+			}
+			return out
+		}
+		return v
+	}
+	return walk(v), total
+}
+
+// normalizeListing removes invisible characters from the tool titles
+// and descriptions of a tools/list response and reports tool names that
+// are not their own skeleton. It returns the response to deliver.
+func (r *Router) normalizeListing(d *Decision, response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response
+	}
+	dec := json.NewDecoder(bytes.NewReader(resp.Result))
+	dec.UseNumber()
+	var result map[string]interface{}
+	if err := dec.Decode(&result); err != nil {
+		return response
+	}
+	tools, _ := result["tools"].([]interface{})
+
+	var spoofed []string
+	var changes normalize.Changes
+	for _, t := range tools {
+		tool, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := tool["name"].(string); ok {
+			if folded, c := normalize.Skeleton(name); c.Any() {
+				spoofed = append(spoofed, fmt.Sprintf("%q (reads as %s)", name, folded))
+			}
+		}
+		for _, field := range []string{"title", "description"} {
+			if text, ok := tool[field].(string); ok {
+				stripped, c := normalize.StripInvisible(text)
+				tool[field] = stripped
+				changes = changes.Add(c)
+			}
+		}
+	}
+	if len(spoofed) > 0 {
+		d.Details = withDetailMap(d.Details, "spoofed_tool_names", spoofed)
+		d.reasons = append(d.reasons, "normalization: tool names with invisible or look-alike characters: "+strings.Join(spoofed, ", "))
+		log.Printf("audit: session %s: server lists tool names with invisible or look-alike characters: %s", r.sessionID, strings.Join(spoofed, ", "))
+	}
+	if !changes.Any() {
+		return response
+	}
+
+	r.stats.Normalized.Add(1)
+	d.Details = withDetailMap(d.Details, "normalized_descriptions", changes)
+	out, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return response
+	}
+	data, err := jsonrpc.Serialize(out)
+	if err != nil {
+		return response
+	}
+	return data
+}
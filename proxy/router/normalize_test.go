@@ -0,0 +1,85 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestNormalization(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Normalization
+		request   string
+		forwarded string
+		refused   string
+	}{
+		{"homoglyph tool denied", Normalization{},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"shеll","arguments":{}}}`, "", "denied"},
+		{"invisible method checked", Normalization{},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/\u200bcall","params":{"name":"shell","arguments":{}}}`, "", "denied"},
+		{"block mode", Normalization{Block: true},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"ｒｅａｄ","arguments":{}}}`, "", "tool name contains"},
+		{"arguments stripped", Normalization{},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"te\u2060xt":"a\u200bb рwd"}}}`, `"arguments":{"text":"ab рwd"}`, ""},
+		{"arguments folded", Normalization{FoldArguments: true},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"cmd":["рwd",7]}}}`, `"arguments":{"cmd":["pwd",7]}`, ""},
+		{"clean request untouched", Normalization{Block: true},
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"text":"καλημέρα"}}}`, `"text":"καλημέρα"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+			cfg.Normalization = &tt.policy
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			var forwarded string
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				forwarded = string(data)
+				req, _ := jsonrpc.Parse(data)
+				resp, _ := jsonrpc.NewResponse(req.ID, map[string]interface{}{"content": []interface{}{}})
+				return jsonrpc.Serialize(resp)
+			}
+
+			response, _ := r.RouteMessage([]byte(tt.request))
+			resp, err := jsonrpc.Parse(response)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if tt.refused != "" {
+				if resp.Error == nil || !strings.Contains(string(resp.Error.Data), tt.refused) {
+					t.Errorf("response %s, expected a refusal for %q", response, tt.refused)
+				}
+				return
+			}
+			if resp.Error != nil || !strings.Contains(forwarded, tt.forwarded) {
+				t.Errorf("forwarded %s (response %s), expected %s", forwarded, response, tt.forwarded)
+			}
+		})
+	}
+}
+
+func TestNormalization_Listing(t *testing.T) {
+	listing := `{"jsonrpc":"2.0","id":1,"result":{"tools":[` +
+		`{"name":"read_fіle","description":"Reads\u200b a file.\u2067"},` +
+		`{"name":"echo","title":"E\u2063cho","description":"Echoes."}]}}`
+	cfg := DefaultConfig()
+	cfg.Normalization = &Normalization{}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func([]byte) ([]byte, error) { return []byte(listing), nil }
+
+	response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	for _, want := range []string{`"description":"Reads a file."`, `"title":"Echo"`, `"name":"read_fіle"`} {
+		if !strings.Contains(string(response), want) {
+			t.Errorf("response %s, expected %s", response, want)
+		}
+	}
+	if got := r.stats.Normalized.Load(); got != 1 {
+		t.Errorf("Normalized = %d, expected 1", got)
+	}
+	if recent := r.RecentDecisions(1); len(recent) != 1 || recent[0].Details["spoofed_tool_names"] == nil {
+		t.Errorf("decisions %+v, expected the spoofed tool name reported", recent)
+	}
+}
@@ -0,0 +1,265 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// sentinelNotifications is the method prefix of the notifications the
+// proxy itself sends the client.
+const sentinelNotifications = "notifications/sentinel/"
+
+// serverInboxSize bounds the server messages read ahead of the request
+// waiting for them.
+const serverInboxSize = 64
+
+// DefaultServerNotifications lists the notifications MCP servers send,
+// used when a NotificationPolicy lists no methods.
+var DefaultServerNotifications = []string{
+	"notifications/cancelled",
+	"notifications/progress",
+	"notifications/message",
+	"notifications/resources/updated",
+	"notifications/resources/list_changed",
+	"notifications/tools/list_changed",
+	"notifications/prompts/list_changed",
+}
+
+// NotificationPolicy restricts the notifications a server sends on its
+// own initiative, outside any response.
+//
+// Every server notification is checked whether or not a policy is
+// configured: notifications in the proxy's own notifications/sentinel/
+// namespace are dropped, and the URI of notifications/resources/updated
+// must pass the session's URISchemePolicy and ResourceTemplatePolicy.
+// The policy adds:
+//   - A method allowlist
+//   - A size limit
+//   - Dropping resource updates for URIs the client never subscribed to
+//
+// # Security Notes
+//
+// Notifications reach the client without a request to answer, so a
+// compromised server can use them to prompt a model (logging messages
+// are often shown to it), to impersonate the proxy's own notices, or to
+// steer the client toward a resource it was never meant to read.
+type NotificationPolicy struct {
+	// Methods lists the notification methods relayed to the client
+	// (nil uses DefaultServerNotifications)
+	Methods []string
+
+	// MaxBytes drops larger notifications (0 for no limit)
+	MaxBytes int
+
+	// RequireSubscription drops notifications/resources/updated for
+	// URIs the client has not subscribed to
+	RequireSubscription bool
+}
+
+// allows reports whether method may be relayed.
+func (p *NotificationPolicy) allows(method string) bool {
+	methods := p.Methods
+	if methods == nil {
+		methods = DefaultServerNotifications
+	}
+	return slices.Contains(methods, method)
+}
+
+// subscriptions tracks the resource URIs the client subscribed to.
+type subscriptions struct {
+	mu   sync.Mutex
+	uris map[string]bool
+}
+
+// record notes a successful resources/subscribe or
+// resources/unsubscribe.
+func (s *subscriptions) record(msg *jsonrpc.Message) {
+	params, err := mcptypes.DecodeParams[mcptypes.ReadResourceParams](msg)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Method == "resources/unsubscribe" {
+		delete(s.uris, params.URI)
+		return
+	}
+	if s.uris == nil {
+		s.uris = make(map[string]bool)
+	}
+	s.uris[params.URI] = true
+}
+
+// has reports whether the client subscribed to uri.
+func (s *subscriptions) has(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uris[uri]
+}
+
+// serverMessage is a server message read ahead by notificationLoop.
+type serverMessage struct {
+	data []byte
+	err  error
+}
+
+// OnNotification registers a handler for the notifications the server
+// sends on its own, for routers whose transport is the server
+// connection (New and NewWithConfig).
+//
+// Registering a handler starts reading the server in the background,
+// so notifications are checked and handed to fn as they arrive rather
+// than dropped, whether or not a request is waiting. Responses are
+// passed on to the request waiting for them. Drive such a router with
+// RouteMessage; Run would read the same transport. Routers created with
+// NewWithTransports relay notifications to the client transport and
+// ignore the handler.
+//
+// # Arguments
+//   - fn: Called with each notification that passed its checks, from
+//     one goroutine in arrival order
+//
+// # Thread Safety
+//
+// Call once, before routing messages.
+func (r *Router) OnNotification(fn func(data []byte)) {
+	if r.upstream != nil {
+		return
+	}
+	r.notificationHandler = fn
+	r.inbox = make(chan serverMessage, serverInboxSize)
+	go r.notificationLoop()
+}
+
+// notificationLoop reads the server until its transport fails,
+// delivering notifications and queueing everything else for
+// receiveResponse.
+func (r *Router) notificationLoop() {
+	for {
+		data, err := r.transport.Receive()
+		if err != nil {
+			r.inbox <- serverMessage{err: err}
+			close(r.inbox)
+			return
+		}
+		if msg, err := jsonrpc.Parse(data); err == nil && msg.Type() == jsonrpc.TypeNotification {
+			r.deliverNotification(msg, data)
+			continue
+		}
+		r.inbox <- serverMessage{data: data}
+	}
+}
+
+// receiveServer returns the next server message not yet delivered.
+func (r *Router) receiveServer() ([]byte, error) {
+	if r.inbox == nil {
+		return r.transport.Receive()
+	}
+	m, ok := <-r.inbox
+	if !ok {
+		return nil, fmt.Errorf("router: server connection closed")
+	}
+	return m.data, m.err
+}
+
+// deliverNotification checks a server notification and hands it to the
+// notification handler, for routers without a client transport.
+func (r *Router) deliverNotification(msg *jsonrpc.Message, data []byte) {
+	if data = r.screenNotification(msg, data); data == nil {
+		return
+	}
+	data, err := r.relayServerMessage(msg, data)
+	if data == nil {
+		log.Printf("router: session %s: middleware dropped server %s: %v", r.sessionID, msg.Method, err)
+		return
+	}
+	if r.notificationHandler == nil {
+		log.Printf("router: session %s: dropped server %s: no notification handler", r.sessionID, msg.Method)
+		return
+	}
+	r.stats.RelayedToClient.Add(1)
+	r.stats.BytesToClient.Add(uint64(len(data)))
+	r.auditRelay(audit.ServerToClient, msg)
+	r.notificationHandler(data)
+}
+
+// screenNotification applies the notification checks to a server
+// notification. It returns the notification to relay, or nil if it was
+// blocked.
+func (r *Router) screenNotification(msg *jsonrpc.Message, data []byte) []byte {
+	if msg.Method == "notifications/tools/list_changed" {
+		// Calls verified against the old tool set must be checked again
+		r.InvalidateRegistryFastPath()
+	}
+	reason := r.checkNotification(msg, len(data))
+	if reason == "" {
+		return data
+	}
+	r.stats.NotificationsBlocked.Add(1)
+	log.Printf("router: session %s: blocked server %s: %s", r.sessionID, msg.Method, reason)
+	if r.audit != nil {
+		r.writeAudit(&audit.Record{
+			Time:      time.Now().UTC(),
+			Session:   r.sessionID,
+			Direction: audit.ServerToClient,
+			Method:    msg.Method,
+			Decision:  string(VerdictBlocked),
+			Reason:    reason,
+		})
+	}
+	return nil
+}
+
+// checkNotification returns a non-empty reason if a server notification
+// of size bytes must not reach the client.
+func (r *Router) checkNotification(msg *jsonrpc.Message, size int) string {
+	if strings.HasPrefix(msg.Method, sentinelNotifications) {
+		return fmt.Sprintf("%s is reserved for the proxy's own notices", msg.Method)
+	}
+	p := r.notifications
+	if p != nil && !p.allows(msg.Method) {
+		return fmt.Sprintf("notification method %s is not allowed", msg.Method)
+	}
+	if p != nil && p.MaxBytes > 0 && size > p.MaxBytes {
+		return fmt.Sprintf("notification of %d bytes exceeds the %d byte limit", size, p.MaxBytes)
+	}
+	if msg.Method != "notifications/resources/updated" {
+		return ""
+	}
+
+	params, err := mcptypes.DecodeParams[mcptypes.ReadResourceParams](msg)
+	if err != nil {
+		return "malformed resource update params"
+	}
+	if r.uriSchemes != nil {
+		if reason := r.uriSchemes.Check(params.URI); reason != "" {
+			return reason
+		}
+	}
+	if r.resourceTemplates != nil {
+		if reason := r.resourceTemplates.check(params.URI); reason != "" {
+			return reason
+		}
+	}
+	if p != nil && p.RequireSubscription && !r.subscriptions.has(params.URI) {
+		return fmt.Sprintf("resource URI %q was not subscribed to", truncateURI(params.URI))
+	}
+	return ""
+}
+
+// recordSubscription tracks a resources/subscribe or
+// resources/unsubscribe the server accepted.
+func (r *Router) recordSubscription(msg *jsonrpc.Message, response []byte) {
+	if resp, err := jsonrpc.Parse(response); err != nil || resp.Error != nil {
+		return
+	}
+	r.subscriptions.record(msg)
+}
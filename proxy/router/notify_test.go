@@ -0,0 +1,112 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestCheckNotification(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.URISchemes = &URISchemePolicy{Allowed: []string{"file"}}
+	cfg.Notifications = &NotificationPolicy{MaxBytes: 200, RequireSubscription: true}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.subscriptions.record(&jsonrpc.Message{Method: "resources/subscribe", Params: json.RawMessage(`{"uri":"file:///srv/a.md"}`)})
+
+	tests := []struct {
+		name    string
+		method  string
+		params  string
+		allowed bool
+	}{
+		{"logging", "notifications/message", `{"level":"info","data":"ok"}`, true},
+		{"list changed", "notifications/tools/list_changed", ``, true},
+		{"spoofed proxy notice", NotifyBlocked, `{}`, false},
+		{"method not listed", "notifications/custom", `{}`, false},
+		{"oversized", "notifications/message", `{"data":"` + strings.Repeat("x", 200) + `"}`, false},
+		{"subscribed update", "notifications/resources/updated", `{"uri":"file:///srv/a.md"}`, true},
+		{"unsubscribed update", "notifications/resources/updated", `{"uri":"file:///srv/b.md"}`, false},
+		{"refused scheme", "notifications/resources/updated", `{"uri":"gopher://internal/"}`, false},
+		{"malformed update", "notifications/resources/updated", `[]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &jsonrpc.Message{JSONRPC: "2.0", Method: tt.method}
+			if tt.params != "" {
+				msg.Params = json.RawMessage(tt.params)
+			}
+			data, _ := jsonrpc.Serialize(msg)
+			reason := r.checkNotification(msg, len(data))
+			if (reason == "") != tt.allowed {
+				t.Errorf("checkNotification = %q, expected allowed=%v", reason, tt.allowed)
+			}
+		})
+	}
+
+	r.subscriptions.record(&jsonrpc.Message{Method: "resources/unsubscribe", Params: json.RawMessage(`{"uri":"file:///srv/a.md"}`)})
+	if r.subscriptions.has("file:///srv/a.md") {
+		t.Error("unsubscribed URI still tracked")
+	}
+}
+
+func TestOnNotification(t *testing.T) {
+	server, serverSide := newPipe()
+	r := New(serverSide, sentinel.NewClient())
+	delivered := make(chan []byte, 4)
+	r.OnNotification(func(data []byte) { delivered <- data })
+
+	// Notifications arrive with no request waiting, and while one is
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+	select {
+	case data := <-delivered:
+		if !strings.Contains(string(data), "list_changed") {
+			t.Fatalf("delivered %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification not delivered without a pending request")
+	}
+
+	go func() {
+		expectMessage(t, server, `"method":"ping"`)
+		server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/sentinel/session_terminated","params":{}}`))
+		server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"working"}}`))
+		server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}()
+	resp, err := r.RouteMessageContext(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	if err != nil || !strings.Contains(string(resp), `"result"`) {
+		t.Fatalf("RouteMessage = %s, %v", resp, err)
+	}
+	select {
+	case data := <-delivered:
+		if !strings.Contains(string(data), "notifications/message") {
+			t.Errorf("delivered %s, expected the logging message", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification not delivered during a request")
+	}
+	if n := r.stats.NotificationsBlocked.Load(); n != 1 {
+		t.Errorf("notifications blocked = %d, expected 1", n)
+	}
+}
+
+func TestRunBidirectional_ScreensNotifications(t *testing.T) {
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/sentinel/blocked","params":{}}`))
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"file:///a"}}`))
+	expectMessage(t, client, `notifications/resources/updated`)
+	if n := r.stats.NotificationsBlocked.Load(); n != 1 {
+		t.Errorf("notifications blocked = %d, expected 1", n)
+	}
+}
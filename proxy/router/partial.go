@@ -0,0 +1,165 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// CodeRequestTimeout is the JSON-RPC error code returned for requests
+// the server did not answer within Config.RequestTimeout.
+const CodeRequestTimeout = -32010
+
+// DefaultPartialBytes is the partial output kept per request when
+// PartialResults.MaxBytes is zero.
+const DefaultPartialBytes = 64 << 10
+
+// PartialResults configures salvage of the output a server produced
+// before a tools/call timed out.
+//
+// Servers report output as they go in notifications/progress messages
+// for the progress token the client put in the request's _meta. The
+// router keeps those messages; when the call times out, they are
+// checked like a tool result and delivered in the timeout error's
+// data, marked as truncated, instead of being discarded.
+//
+// # Security Notes
+//
+// Salvaged output passes the same result checks as a complete result
+// (URI schemes, response inspection, content policy, taint tracking);
+// output they block is withheld, and the client gets the block instead.
+type PartialResults struct {
+	// MaxBytes bounds the output kept per request; later messages are
+	// dropped (zero uses DefaultPartialBytes)
+	MaxBytes int
+}
+
+// partialOutput is the progress output received for one request.
+type partialOutput struct {
+	chunks  []string
+	size    int
+	dropped int
+}
+
+// partialLog collects progress output by progress token for tool calls
+// awaiting their response.
+type partialLog struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	byToken map[string]*partialOutput
+}
+
+// newPartialLog creates a partial output log.
+func newPartialLog(cfg *PartialResults) *partialLog {
+	l := &partialLog{maxBytes: cfg.MaxBytes, byToken: make(map[string]*partialOutput)}
+	if l.maxBytes <= 0 {
+		l.maxBytes = DefaultPartialBytes
+	}
+	return l
+}
+
+// progressToken returns the progress token of a request, or "".
+func progressToken(msg *jsonrpc.Message) string {
+	var params struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		return ""
+	}
+	return string(params.Meta.ProgressToken)
+}
+
+// start begins collecting output for token.
+func (l *partialLog) start(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.byToken[token] = &partialOutput{}
+}
+
+// add keeps the message of a notifications/progress for a collected
+// token. Malformed notifications and empty messages are ignored.
+func (l *partialLog) add(params json.RawMessage) {
+	var progress struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+		Message       string          `json:"message"`
+	}
+	if json.Unmarshal(params, &progress) != nil || progress.Message == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.byToken[string(progress.ProgressToken)]
+	if out == nil {
+		return
+	}
+	if out.size+len(progress.Message) > l.maxBytes {
+		out.dropped++
+		return
+	}
+	out.chunks = append(out.chunks, progress.Message)
+	out.size += len(progress.Message)
+}
+
+// finish stops collecting for token and returns what was collected.
+func (l *partialLog) finish(token string) *partialOutput {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.byToken[token]
+	delete(l.byToken, token)
+	return out
+}
+
+// partialTimeout is a request timeout with the output salvaged.
+type partialTimeout struct {
+	err    error
+	output *partialOutput
+}
+
+func (e *partialTimeout) Error() string { return e.err.Error() }
+func (e *partialTimeout) Unwrap() error { return e.err }
+
+// salvageResult builds the tools/call response the salvaged output is
+// checked as: an error result holding the output and a truncation
+// notice.
+func salvageResult(id json.RawMessage, out *partialOutput, reason string) ([]byte, error) {
+	notice := fmt.Sprintf("[output truncated: %s]", reason)
+	if out.dropped > 0 {
+		notice = fmt.Sprintf("[output truncated: %s; %d later progress messages exceeded the salvage limit]", reason, out.dropped)
+	}
+	resp, err := jsonrpc.NewResponse(id, map[string]interface{}{
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": strings.Join(out.chunks, "\n")},
+			map[string]interface{}{"type": "text", "text": notice},
+		},
+		"isError": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(resp)
+}
+
+// partialResponse turns the checked salvage result into the timeout
+// error delivered to the client, carrying the result as partial data.
+func (r *Router) partialResponse(d *Decision, id json.RawMessage, response []byte) ([]byte, error) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Result == nil {
+		// Checks replaced the result with an error of their own
+		return response, nil
+	}
+	r.stats.PartialResults.Add(1)
+	d.Verdict = VerdictError
+	d.event(EventFailed, nil)
+	data := &ErrorData{Reason: d.Reason, DecisionID: d.ID, Failure: FailureUpstream, Retry: RetryBackoff, Truncated: true, Partial: resp.Result}
+	reply, err := jsonrpc.NewErrorResponse(id, CodeRequestTimeout, "Request timed out", data)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(reply)
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestPartialResults_Salvage(t *testing.T) {
+	tests := []struct {
+		name     string
+		salvage  *PartialResults
+		progress []string
+		partial  string // expected salvaged text ("" for none)
+		notice   string
+	}{
+		{"salvaged", &PartialResults{}, []string{"line 1", "line 2"}, "line 1\nline 2", "[output truncated: the server did not finish within 200ms]"},
+		{"limited", &PartialResults{MaxBytes: 8}, []string{"line 1", "line 2", "line 3"}, "line 1", "2 later progress messages exceeded the salvage limit"},
+		{"no output", &PartialResults{}, nil, "", ""},
+		{"disabled", nil, []string{"line 1"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, clientSide := newPipe()
+			server, serverSide := newPipe()
+			cfg := DefaultConfig()
+			cfg.RequestTimeout = 200 * time.Millisecond
+			cfg.PartialResults = tt.salvage
+			r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go r.Run(ctx)
+
+			client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"build","_meta":{"progressToken":"p1"}}}`))
+			expectMessage(t, server, `"method":"tools/call"`)
+			for i, message := range tt.progress {
+				note, _ := jsonrpc.NewNotification("notifications/progress", map[string]interface{}{
+					"progressToken": "p1", "progress": i + 1, "message": message,
+				})
+				data, _ := jsonrpc.Serialize(note)
+				server.Send(data)
+				expectMessage(t, client, message)
+			}
+			// Progress for another request is not salvaged
+			server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"p2","progress":1,"message":"other"}}`))
+			expectMessage(t, client, `"other"`)
+
+			var resp *jsonrpc.Message
+			select {
+			case data := <-client.in:
+				resp, _ = jsonrpc.Parse(data)
+			case <-time.After(2 * time.Second):
+				t.Fatal("no timeout error")
+			}
+			if errorCode(resp) != CodeRequestTimeout {
+				t.Fatalf("response = %+v, expected code %d", resp.Error, CodeRequestTimeout)
+			}
+			var data ErrorData
+			json.Unmarshal(resp.Error.Data, &data)
+			if tt.partial == "" {
+				if data.Truncated || data.Partial != nil || r.stats.PartialResults.Load() != 0 {
+					t.Errorf("unexpected salvage: %+v", data)
+				}
+				return
+			}
+
+			var result struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+				IsError bool `json:"isError"`
+			}
+			json.Unmarshal(data.Partial, &result)
+			if !data.Truncated || !result.IsError || len(result.Content) != 2 {
+				t.Fatalf("error data = %+v", data)
+			}
+			if result.Content[0].Text != tt.partial {
+				t.Errorf("salvaged text = %q, expected %q", result.Content[0].Text, tt.partial)
+			}
+			if !strings.Contains(result.Content[1].Text, tt.notice) {
+				t.Errorf("truncation notice = %q, expected %q", result.Content[1].Text, tt.notice)
+			}
+			if r.stats.PartialResults.Load() != 1 {
+				t.Errorf("PartialResults = %d, expected 1", r.stats.PartialResults.Load())
+			}
+			if d, ok := r.Decision(data.DecisionID); !ok || d.Verdict != VerdictError {
+				t.Errorf("decision = %+v", d)
+			}
+		})
+	}
+}
+
+func TestPartialLog(t *testing.T) {
+	l := newPartialLog(&PartialResults{MaxBytes: 10})
+	l.start(`"a"`)
+	for _, params := range []string{
+		`{"progressToken":"a","message":"12345"}`,
+		`{"progressToken":"a","message":`,
+		`{"progressToken":"a"}`,
+		`{"progressToken":"b","message":"other"}`,
+		`{"progressToken":"a","message":"123456"}`,
+		`{"progressToken":"a","message":"12345"}`,
+	} {
+		l.add(json.RawMessage(params))
+	}
+	out := l.finish(`"a"`)
+	if out == nil || strings.Join(out.chunks, ",") != "12345,12345" || out.dropped != 1 {
+		t.Errorf("output = %+v", out)
+	}
+	if l.finish(`"a"`) != nil {
+		t.Error("output kept after finish")
+	}
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrInvalidPauseMode is returned by Pause for an unknown mode.
+var ErrInvalidPauseMode = errors.New("router: invalid pause mode")
+
+// CodePaused is the JSON-RPC error code returned for tool calls refused
+// while a session is paused. It is in the implementation-defined server
+// error range.
+const CodePaused = -32004
+
+// PauseMode selects what happens to tool calls while a session is paused.
+type PauseMode string
+
+const (
+	// PauseReject answers new tool calls with a "paused by operator" error
+	PauseReject PauseMode = "reject"
+	// PauseQueue holds new tool calls until the session is resumed
+	PauseQueue PauseMode = "queue"
+)
+
+// PauseState describes whether and how a session is paused.
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Mode   PauseMode `json:"mode,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+
+	// Queued is the number of tool calls currently held (PauseQueue)
+	Queued int64 `json:"queued"`
+}
+
+// pause is an active pause. resumed is closed when it ends; abandoned
+// is set first if held calls must be refused rather than released.
+type pause struct {
+	mode      PauseMode
+	reason    string
+	since     time.Time
+	resumed   chan struct{}
+	abandoned bool
+}
+
+// Pause freezes tool calls in the session without discarding any of
+// its state (gas, call history, anomaly score, pending requests).
+// Other methods, such as pings and listings, continue to flow.
+// Pausing an already paused session updates its mode and reason;
+// calls already queued stay queued.
+//
+// # Security Notes
+//
+// Pausing is an incident-response tool: it stops an agent from acting
+// while an operator investigates, without killing the context the
+// agent would need to continue if the alarm is false. Tool calls
+// checked before the pause began are not recalled.
+func (r *Router) Pause(mode PauseMode, reason string) error {
+	if mode != PauseReject && mode != PauseQueue {
+		return fmt.Errorf("%w: %q", ErrInvalidPauseMode, mode)
+	}
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	if r.pause != nil {
+		r.pause.mode, r.pause.reason = mode, reason
+	} else {
+		r.pause = &pause{mode: mode, reason: reason, since: time.Now().UTC(), resumed: make(chan struct{})}
+	}
+	log.Printf("audit: session %s paused (%s): %s", r.sessionID, mode, reason)
+	return nil
+}
+
+// Resume ends a pause and releases queued tool calls, which are then
+// checked as if they had just arrived. Returns false if the session
+// was not paused.
+func (r *Router) Resume() bool {
+	if !r.endPause(false) {
+		return false
+	}
+	log.Printf("audit: session %s resumed", r.sessionID)
+	return true
+}
+
+// PauseState returns the session's current pause state.
+func (r *Router) PauseState() PauseState {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	st := PauseState{Queued: r.pauseQueued.Load()}
+	if p := r.pause; p != nil {
+		st.Paused, st.Mode, st.Reason, st.Since = true, p.mode, p.reason, p.since
+	}
+	return st
+}
+
+// endPause clears the pause and wakes queued calls. When the session
+// ends or is terminated the calls are abandoned: they are refused
+// instead of being checked and forwarded.
+func (r *Router) endPause(abandon bool) bool {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	if r.pause == nil {
+		return false
+	}
+	r.pause.abandoned = abandon
+	close(r.pause.resumed)
+	r.pause = nil
+	return true
+}
+
+// holdIfPaused applies the pause to a tool call. Queued calls block
+// until the pause ends.
+//
+// # Returns
+//   - Non-empty reason if the call must be refused
+func (r *Router) holdIfPaused(d *Decision) string {
+	for {
+		r.pauseMu.Lock()
+		p := r.pause
+		var mode PauseMode
+		var reason string
+		if p != nil {
+			mode, reason = p.mode, p.reason
+		}
+		r.pauseMu.Unlock()
+		if p == nil {
+			return ""
+		}
+		if mode == PauseReject {
+			return "paused by operator: " + reason
+		}
+
+		r.pauseQueued.Add(1)
+		start := time.Now()
+		<-p.resumed
+		r.pauseQueued.Add(-1)
+		d.event(EventCheck, map[string]interface{}{"check": "pause", "reason": reason, "held_ms": time.Since(start).Milliseconds()})
+		if p.abandoned {
+			return "session ended while paused: " + reason
+		}
+		// A new pause may have started, possibly in reject mode
+	}
+}
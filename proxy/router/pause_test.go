@@ -0,0 +1,77 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestPause(t *testing.T) {
+	r := New(&mockTransport{}, sentinel.NewClient())
+	r.forwardFunc = func([]byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	route := func(method string) *jsonrpc.Message {
+		req, _ := jsonrpc.NewRequest(method, map[string]interface{}{"name": "read_file", "arguments": map[string]string{}}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		response, _ := r.RouteMessage(data)
+		resp, _ := jsonrpc.Parse(response)
+		return resp
+	}
+
+	if err := r.Pause("freeze", "x"); !errors.Is(err, ErrInvalidPauseMode) {
+		t.Errorf("expected ErrInvalidPauseMode, got %v", err)
+	}
+
+	// Reject mode refuses tool calls but lets other traffic through
+	if err := r.Pause(PauseReject, "investigating"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if resp := route("tools/call"); resp.Error == nil || resp.Error.Code != CodePaused {
+		t.Errorf("expected paused error, got %+v", resp.Error)
+	}
+	if resp := route("tools/list"); resp.Error != nil {
+		t.Errorf("non-tool traffic should flow while paused: %v", resp.Error)
+	}
+
+	// Queue mode holds the call until resume, then checks it normally
+	r.Pause(PauseQueue, "investigating")
+	done := make(chan *jsonrpc.Message, 1)
+	go func() { done <- route("tools/call") }()
+	deadline := time.Now().Add(2 * time.Second)
+	for r.PauseState().Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("call was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !r.Resume() {
+		t.Fatal("Resume reported session not paused")
+	}
+	if resp := <-done; resp.Error != nil {
+		t.Errorf("queued call should pass after resume: %v", resp.Error)
+	}
+	if r.Resume() {
+		t.Error("second Resume should report not paused")
+	}
+
+	// Ending the session refuses held calls instead of releasing them
+	r.Pause(PauseQueue, "investigating")
+	go func() { done <- route("tools/call") }()
+	for r.PauseState().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	r.endPause(true)
+	if resp := <-done; resp.Error == nil || resp.Error.Code != CodePaused {
+		t.Errorf("abandoned call should be refused, got %+v", resp.Error)
+	}
+
+	if st := r.PauseState(); st.Paused || st.Queued != 0 {
+		t.Errorf("unexpected state after session end: %+v", st)
+	}
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/queue"
+)
+
+// CodeOverloaded is the JSON-RPC error code returned when the proxy's
+// ingress queue is saturated. It is in the implementation-defined
+// server error range.
+const CodeOverloaded = -32001
+
+// PipelineConfig bounds the queues between the routing stages.
+//
+//	transport.Receive → [ingress] → RouteMessage → [egress] → transport.Send
+//
+// When the ingress queue is full, requests are answered immediately with
+// a CodeOverloaded error instead of waiting; notifications are dropped.
+// A full egress queue blocks routing, which in turn fills ingress.
+type PipelineConfig struct {
+	// IngressDepth is the capacity of the receive → route queue
+	IngressDepth int
+
+	// EgressDepth is the capacity of the route → send queue
+	EgressDepth int
+}
+
+// DefaultPipelineConfig returns queue depths suitable for a single session.
+func DefaultPipelineConfig() *PipelineConfig {
+	return &PipelineConfig{
+		IngressDepth: 256,
+		EgressDepth:  256,
+	}
+}
+
+// runPipeline runs receive, route, and send as concurrent stages
+// connected by bounded queues.
+//
+// When the client transport ends, messages already queued are still
+// routed and their responses sent before the receive error is returned,
+// so a client that closes its end right after writing loses nothing.
+// A send failure or cancelled context stops the pipeline immediately.
+func (r *Router) runPipeline(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer r.ingress.Close()
+	defer r.egress.Close()
+
+	recvErr := make(chan error, 1)
+	sendErr := make(chan error, 1)
+	go func() { recvErr <- r.receiveStage(ctx) }()
+	go r.routeStage(ctx)
+	go func() { sendErr <- r.sendStage(ctx) }()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-sendErr:
+		if err != nil {
+			return err
+		}
+		// Egress drained: receive ended and everything queued was sent
+		return <-recvErr
+	}
+}
+
+// receiveStage reads from the transport into the ingress queue. When
+// the transport fails it closes ingress so later stages drain and stop.
+func (r *Router) receiveStage(ctx context.Context) error {
+	for {
+		data, err := r.transport.Receive()
+		if err != nil {
+			r.ingress.Close()
+			return fmt.Errorf("router: receive failed: %w", err)
+		}
+		err = r.ingress.TryPush(data)
+		switch {
+		case errors.Is(err, queue.ErrFull):
+			r.rejectOverloaded(data)
+		case err != nil:
+			return ctx.Err()
+		}
+	}
+}
+
+// routeStage routes ingress messages into the egress queue, closing
+// egress once ingress is closed and drained.
+func (r *Router) routeStage(ctx context.Context) {
+	for {
+		data, _, err := r.ingress.Pop(ctx)
+		if err != nil {
+			if errors.Is(err, queue.ErrClosed) {
+				r.egress.Close()
+			}
+			return
+		}
+		response, err := r.RouteMessageContext(ctx, data)
+		if err != nil {
+			log.Printf("router: session %s: %v", r.sessionID, err)
+		}
+		if response == nil {
+			continue
+		}
+		if err := r.egress.Push(ctx, response); err != nil {
+			return
+		}
+	}
+}
+
+// sendStage writes egress messages to the transport. It returns nil
+// once egress is closed and drained.
+func (r *Router) sendStage(ctx context.Context) error {
+	for {
+		response, _, err := r.egress.Pop(ctx)
+		if errors.Is(err, queue.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return ctx.Err()
+		}
+		if err := r.send(response); err != nil {
+			return fmt.Errorf("router: send failed: %w", err)
+		}
+	}
+}
+
+// rejectOverloaded answers a request that did not fit in the ingress
+// queue. The error bypasses egress when that queue is also full, so
+// the client is never left waiting on a message the proxy dropped.
+func (r *Router) rejectOverloaded(data []byte) {
+	r.stats.Overloaded.Add(1)
+
+	msg, err := jsonrpc.Parse(data)
+	if err != nil || msg.Type() != jsonrpc.TypeRequest {
+		log.Printf("router: session %s: ingress queue full; dropped message", r.sessionID)
+		return
+	}
+
+	d := r.newDecision()
+	d.Method = msg.Method
+	defer r.finish(d)
+	response, err := r.errorResponse(d, VerdictError, msg.ID, CodeOverloaded, "Proxy overloaded", "ingress queue saturated")
+	if err != nil {
+		return
+	}
+	if err := r.egress.TryPush(response); err != nil {
+		if err := r.transport.Send(response); err != nil {
+			log.Printf("router: session %s: overload response not delivered: %v", r.sessionID, err)
+		}
+	}
+}
+
+// queueMetrics returns depth, head age, and rejection samples for the
+// pipeline queues.
+func (r *Router) queueMetrics() []Metric {
+	if r.ingress == nil {
+		return nil
+	}
+	var metrics []Metric
+	for _, q := range []*queue.Queue{r.ingress, r.egress} {
+		s := q.Stats()
+		labels := map[string]string{"session": r.sessionID, "queue": s.Name}
+		metrics = append(metrics,
+			Metric{"mcp_sentinel_queue_depth", "Messages waiting between routing stages.", "gauge", labels, float64(s.Depth)},
+			Metric{"mcp_sentinel_queue_capacity", "Maximum queue depth.", "gauge", labels, float64(s.Capacity)},
+			Metric{"mcp_sentinel_queue_oldest_age_seconds", "Age of the oldest queued message.", "gauge", labels, s.OldestAge.Seconds()},
+			Metric{"mcp_sentinel_queue_rejected_total", "Messages rejected because the queue was full.", "counter", labels, float64(s.Rejected)},
+		)
+	}
+	return metrics
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestPipeline_OverloadResponse(t *testing.T) {
+	forwarding := make(chan struct{}, 1)
+	release := make(chan struct{})
+	sent := make(chan []byte, 8)
+
+	requests := make(chan []byte, 3)
+	for id := 1; id <= 3; id++ {
+		req, _ := jsonrpc.NewRequest("tools/list", nil, id)
+		data, _ := jsonrpc.Serialize(req)
+		requests <- data
+	}
+	received := 0
+	mt := &mockTransport{
+		receiveFunc: func() ([]byte, error) {
+			received++
+			if received == 2 {
+				// Ensure the first message is being routed
+				<-forwarding
+			}
+			select {
+			case data := <-requests:
+				return data, nil
+			case <-release:
+				return nil, errors.New("eof")
+			}
+		},
+		sendFunc: func(data []byte) error {
+			sent <- data
+			return nil
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.Pipeline = &PipelineConfig{IngressDepth: 1, EgressDepth: 4}
+	r := NewWithConfig(mt, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		select {
+		case forwarding <- struct{}{}:
+		default:
+		}
+		<-release
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), "ok")
+		return jsonrpc.Serialize(resp)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background()) }()
+
+	select {
+	case data := <-sent:
+		resp, _ := jsonrpc.Parse(data)
+		if resp.Error == nil || resp.Error.Code != CodeOverloaded || string(resp.ID) != "3" {
+			t.Errorf("expected overload error for id 3, got %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no overload response")
+	}
+	if r.stats.Overloaded.Load() != 1 {
+		t.Errorf("expected 1 overloaded message, got %d", r.stats.Overloaded.Load())
+	}
+
+	close(release)
+	if err := <-done; err == nil {
+		t.Error("expected Run to stop on receive error")
+	}
+}
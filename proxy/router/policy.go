@@ -0,0 +1,98 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// CodeRateLimited is the JSON-RPC error code returned for requests
+// refused by a policy rate limit, a rate limit, or a concurrency limit.
+const CodeRateLimited = -32006
+
+// retryAfterMS returns a retry hint in whole milliseconds, rounded up
+// so a client waiting that long finds a token.
+func retryAfterMS(wait time.Duration) int64 {
+	return int64((wait + time.Millisecond - 1) / time.Millisecond)
+}
+
+// builtinPolicy holds the built-in high-risk tools and gas prices used
+// without a configured policy.
+var builtinPolicy = mustPolicy(policy.Builtin())
+
+func mustPolicy(set *policy.Set) *policy.Engine {
+	e, err := policy.New(set)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// checkPolicy evaluates a client request against the policy engine. It
+// returns the message to route, rewritten if a rule downgraded it, or
+// an error reply and true if the request is refused; a require-council
+// verdict is remembered on d for checkToolCall.
+func (r *Router) checkPolicy(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, bool) {
+	req := policy.Request{Session: r.sessionID, Method: msg.Method}
+	if msg.Method == "tools/call" {
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		req.Tool, req.Arguments = params.Name, params.Arguments
+		if r.upstreamTools != nil {
+			req.Server, req.ServerTool, _ = r.upstreamTools.Resolve(req.Tool)
+		}
+	}
+
+	var verdict policy.Verdict
+	result, _ := r.runCheck(d, CheckPolicy, func() (*sentinel.CheckResult, error) {
+		verdict = r.policy.Evaluate(req)
+		return &sentinel.CheckResult{Allowed: !verdict.Blocked(), Reason: verdict.Reason}, nil
+	})
+	if verdict.Rule != "" {
+		d.Details = withDetailMap(d.Details, "policy_rule", verdict.Rule)
+	}
+	if result.Allowed && len(verdict.Transforms) > 0 {
+		rewritten, err := r.applyDowngrade(d, msg, verdict)
+		if err != nil {
+			r.stats.Errors.Add(1)
+			reply, _ := r.errorResponse(d, VerdictError, msg.ID, jsonrpc.InternalError, "Downgrade failed", err.Error())
+			return reply, true
+		}
+		data = rewritten
+	}
+	if result.Allowed {
+		if verdict.Action == policy.ActionCouncil {
+			d.requireCouncil = true
+			d.finding(sentinel.Finding{
+				Source:   sentinel.EvidencePolicy,
+				Kind:     string(policy.ActionCouncil),
+				Severity: 0.5,
+				Summary:  fmt.Sprintf("policy rule %q requires a council vote: %s", verdict.Rule, verdict.Reason),
+			})
+		}
+		return data, false
+	}
+
+	r.stats.MessagesBlocked.Add(1)
+	if verdict.RateLimited {
+		r.stats.RateLimited.Add(1)
+		reply, _ := r.errorResponseData(d, VerdictBlocked, msg.ID, CodeRateLimited, "Rate limited",
+			&ErrorData{Reason: result.Reason, RetryAfterMS: retryAfterMS(verdict.RetryAfter)})
+		return reply, true
+	}
+	reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Blocked by policy", result.Reason)
+	return reply, true
+}
+
+// needsCouncil reports whether a tool call requires a council vote:
+// the policy requires one, or the server tool is high-risk.
+func (r *Router) needsCouncil(d *Decision, tool string) bool {
+	return (d != nil && d.requireCouncil) || r.isHighRisk(r.serverToolName(tool))
+}
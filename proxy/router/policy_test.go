@@ -0,0 +1,204 @@
+package router
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/staging"
+)
+
+func TestPolicy_Routing(t *testing.T) {
+	engine, err := policy.New(&policy.Set{Rules: []policy.Rule{
+		{Name: "no-resources", Methods: []string{"resources/read"}, Action: policy.ActionBlock, Reason: "resources are disabled"},
+		{Name: "limit", Tools: []string{"search"}, Action: policy.ActionRateLimit, Rate: 0.001, Burst: 1},
+		{Name: "etc", Tools: []string{"read_file"}, Arguments: map[string]string{"path": "^/etc/"}, Action: policy.ActionCouncil},
+		{Name: "price", Tools: []string{"read_file"}, Gas: 7},
+	}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Policy = engine
+
+	tests := []struct {
+		name    string
+		method  string
+		params  map[string]interface{}
+		code    int
+		rule    string
+		council bool
+	}{
+		{"allowed", "tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]string{"path": "/srv/a"}}, 0, "", false},
+		{"built-in list replaced", "tools/call", map[string]interface{}{"name": "execute_command"}, 0, "", false},
+		{"require council", "tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]string{"path": "/etc/passwd"}}, 0, "etc", true},
+		{"method blocked", "resources/read", map[string]interface{}{"uri": "file:///a"}, jsonrpc.InvalidRequest, "no-resources", false},
+		{"within rate", "tools/call", map[string]interface{}{"name": "search"}, 0, "", false},
+		{"rate limited", "tools/call", map[string]interface{}{"name": "search"}, CodeRateLimited, "limit", false},
+	}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := jsonrpc.NewRequest(tt.method, tt.params, 1)
+			data, _ := jsonrpc.Serialize(req)
+			response, _ := r.RouteMessage(data)
+			resp, err := jsonrpc.Parse(response)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			if tt.code == 0 && resp.Error != nil {
+				t.Fatalf("error %+v, expected success", resp.Error)
+			}
+			if tt.code != 0 && (resp.Error == nil || resp.Error.Code != tt.code) {
+				t.Fatalf("response %s, expected error code %d", response, tt.code)
+			}
+			d := r.RecentDecisions(1)[0]
+			if rule, _ := d.Details["policy_rule"].(string); rule != tt.rule {
+				t.Errorf("policy_rule = %q, expected %q", rule, tt.rule)
+			}
+			if got := slices.Contains(d.checks, CheckCouncil); got != tt.council {
+				t.Errorf("council ran = %t, expected %t (checks %v)", got, tt.council, d.checks)
+			}
+		})
+	}
+	if got := r.stats.RateLimited.Load(); got != 1 {
+		t.Errorf("RateLimited = %d, expected 1", got)
+	}
+}
+
+func TestPolicy_Gas(t *testing.T) {
+	engine, err := policy.New(&policy.Set{DefaultGas: 3, Rules: []policy.Rule{
+		{Name: "price", Tools: []string{"read_file"}, Gas: 7},
+	}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Policy = engine
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	for _, tool := range []string{"read_file", "other"} {
+		req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		r.RouteMessage(data)
+	}
+	if got := r.gasUsed.Load(); got != 10 {
+		t.Errorf("gas used = %d, expected 7 + 3", got)
+	}
+
+	// Replacing the rules reprices the next call
+	if err := engine.Replace(&policy.Set{Rules: []policy.Rule{{Name: "price", Tools: []string{"read_file"}, Gas: 100}}}); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "read_file"}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	r.RouteMessage(data)
+	if got := r.gasUsed.Load(); got != 110 {
+		t.Errorf("gas used = %d, expected 110 after replace", got)
+	}
+}
+
+func TestPolicy_Downgrade(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := policy.New(&policy.Set{Rules: []policy.Rule{
+		{Name: "cat-is-read", Tools: []string{"execute_command"}, Arguments: map[string]string{"command": `^cat\s+(?P<file>\S+)$`},
+			Action: policy.ActionDowngrade, Downgrade: policy.Downgrade{Tool: "read_file", Arguments: map[string]string{"path": "${file}"}}},
+		{Name: "stage-writes", Tools: []string{"write_file"}, Action: policy.ActionDowngrade,
+			Downgrade: policy.Downgrade{StageArgument: "path", Workspace: "/srv/work", StagingDir: dir}},
+	}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Policy = engine
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	var forwarded *jsonrpc.Message
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwarded, _ = jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		tool   string
+		path   string
+	}{
+		{"cat becomes read_file", map[string]interface{}{"name": "execute_command", "arguments": map[string]string{"command": "cat /srv/a"}}, "read_file", "/srv/a"},
+		{"write staged", map[string]interface{}{"name": "write_file", "arguments": map[string]string{"path": "/etc/app.conf", "content": "x"}}, "write_file", dir},
+		{"write in workspace", map[string]interface{}{"name": "write_file", "arguments": map[string]string{"path": "/srv/work/a", "content": "x"}}, "write_file", "/srv/work/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := jsonrpc.NewRequest("tools/call", tt.params, 1)
+			data, _ := jsonrpc.Serialize(req)
+			response, _ := r.RouteMessage(data)
+			if resp, err := jsonrpc.Parse(response); err != nil || resp.Error != nil {
+				t.Fatalf("response %s, expected success", response)
+			}
+			var params struct {
+				Name      string            `json:"name"`
+				Arguments map[string]string `json:"arguments"`
+			}
+			if forwarded == nil || json.Unmarshal(forwarded.Params, &params) != nil {
+				t.Fatal("call was not forwarded")
+			}
+			if params.Name != tt.tool || !strings.HasPrefix(params.Arguments["path"], tt.path) {
+				t.Errorf("forwarded %s %v, expected %s with path %s", params.Name, params.Arguments, tt.tool, tt.path)
+			}
+		})
+	}
+
+	entries, err := staging.List(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("staging entries = %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.Original != "/etc/app.conf" || e.Rule != "stage-writes" || e.State != staging.StateStaged {
+		t.Errorf("staging entry = %+v", e)
+	}
+	if got := r.stats.Downgrades.Load(); got != 2 {
+		t.Errorf("Downgrades = %d, expected 2", got)
+	}
+}
+
+func TestPolicy_DowngradeRefusedLater(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := policy.New(&policy.Set{Rules: []policy.Rule{
+		{Name: "stage-writes", Tools: []string{"write_file"}, Action: policy.ActionDowngrade,
+			Downgrade: policy.Downgrade{StageArgument: "path", Workspace: "/srv/work", StagingDir: dir}},
+	}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Policy = engine
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"write_file"}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		t.Error("refused call was forwarded")
+		return nil, nil
+	}
+
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "write_file", "arguments": map[string]string{"path": "/etc/app.conf", "content": "x"}}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, _ := r.RouteMessage(data)
+	if resp, err := jsonrpc.Parse(response); err != nil || resp.Error == nil {
+		t.Fatalf("response %s, expected the tool policy to refuse the call", response)
+	}
+	if entries, err := staging.List(dir); err != nil || len(entries) != 0 {
+		t.Errorf("staging entries = %+v, %v; expected none for a refused call", entries, err)
+	}
+}
@@ -0,0 +1,107 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+)
+
+// Reasons the session's protection is weaker than a full deployment's,
+// as reported by ProtectionDegraded. Degradation levels and disabled
+// checks are reported with their name after a colon.
+const (
+	// DegradedStub means sentinel checks pass without analysis
+	DegradedStub = "stub-sentinel"
+	// DegradedFFI means the sentinel library shares no envelope version
+	DegradedFFI = "ffi-unavailable"
+	// DegradedLevel prefixes a degradation ladder level above full
+	DegradedLevel = "degradation"
+	// DegradedFailsafeOpen means the failsafe level allows everything
+	DegradedFailsafeOpen = "failsafe-allow-all"
+	// DegradedCheck prefixes a fail-open check disabled after panics
+	DegradedCheck = "check-disabled"
+)
+
+// protectionLogger names the proxy in logging notifications.
+const protectionLogger = "mcp-sentinel"
+
+// protectionNotice tracks what the client was last told about the
+// session's protection.
+type protectionNotice struct {
+	mu          sync.Mutex
+	initialized bool
+	announced   []string
+}
+
+// ProtectionDegraded lists why the session's security checks are
+// weaker than configured, or nil if they run in full.
+//
+// # Security Notes
+//
+// A stub build allows everything while looking like a working proxy;
+// so does a failsafe allow-all ladder or a fail-open check taken out of
+// service. Health, metrics, and a notice to the client say so, so
+// nobody assumes protection that is not there.
+func (r *Router) ProtectionDegraded() []string {
+	var reasons []string
+	if r.sentinel.Stub() {
+		reasons = append(reasons, DegradedStub)
+	}
+	if r.sentinel.ProtocolVersion() == 0 {
+		reasons = append(reasons, DegradedFFI)
+	}
+	if level := r.DegradationLevel(); level > degrade.LevelFull {
+		reasons = append(reasons, DegradedLevel+":"+level.String())
+		if level == degrade.LevelFailsafe && r.ladder.Failsafe() == degrade.FailsafeAllowAll {
+			reasons = append(reasons, DegradedFailsafeOpen)
+		}
+	}
+	names, _, disabled := r.isolation.snapshot()
+	for _, check := range names {
+		if disabled[check] && r.isolation.policy.mode(check) == PanicFailOpen {
+			reasons = append(reasons, DegradedCheck+":"+check)
+		}
+	}
+	return reasons
+}
+
+// announceProtection tells the client, with a logging notification,
+// when the session's protection is degraded and whenever that changes.
+// The first notice follows notifications/initialized; before it the
+// client may not be ready for server messages.
+func (r *Router) announceProtection(method string) {
+	if r.upstream == nil {
+		// The transport is the server connection
+		return
+	}
+	p := &r.protection
+	p.mu.Lock()
+	if method == "notifications/initialized" {
+		p.initialized = true
+	}
+	if !p.initialized {
+		p.mu.Unlock()
+		return
+	}
+	reasons := r.ProtectionDegraded()
+	if slices.Equal(reasons, p.announced) {
+		p.mu.Unlock()
+		return
+	}
+	p.announced = reasons
+	p.mu.Unlock()
+
+	level, text := "warning", fmt.Sprintf("MCP Sentinel security checks are degraded (%s): tool calls and results are not fully checked", strings.Join(reasons, ", "))
+	if len(reasons) == 0 {
+		level, text = "notice", "MCP Sentinel security checks are running in full again"
+	}
+	log.Printf("router: session %s: %s", r.sessionID, text)
+	params := map[string]interface{}{"level": level, "logger": protectionLogger, "data": text}
+	if err := r.notify("notifications/message", params); err != nil {
+		log.Printf("router: session %s: failed to send protection notice: %v", r.sessionID, err)
+	}
+}
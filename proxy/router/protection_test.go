@@ -0,0 +1,75 @@
+package router
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestProtectionDegraded(t *testing.T) {
+	analyzed := sentinel.NewFusedClient(nil, sentinel.Member{Name: "remote", Backend: &poisonBackend{}})
+	tests := []struct {
+		name   string
+		client *sentinel.Client
+		level  degrade.Level
+		mode   degrade.FailsafeMode
+		want   []string
+	}{
+		{"full", analyzed, degrade.LevelFull, degrade.FailsafeBlockAll, nil},
+		{"skip council", analyzed, degrade.LevelSkipCouncil, degrade.FailsafeBlockAll, []string{"degradation:skip-council"}},
+		{"failsafe block-all", analyzed, degrade.LevelFailsafe, degrade.FailsafeBlockAll, []string{"degradation:failsafe"}},
+		{"failsafe allow-all", analyzed, degrade.LevelFailsafe, degrade.FailsafeAllowAll, []string{"degradation:failsafe", DegradedFailsafeOpen}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ladderCfg := degrade.DefaultConfig()
+			ladderCfg.Failsafe = tt.mode
+			ladder, _ := degrade.New(ladderCfg)
+			ladder.Set(tt.level, "test")
+			cfg := DefaultConfig()
+			cfg.Degradation = ladder
+			r := NewWithConfig(&mockTransport{}, tt.client, cfg)
+			if got := r.ProtectionDegraded(); !slices.Equal(got, tt.want) {
+				t.Errorf("ProtectionDegraded() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+
+	// Whether the default client is a stub depends on the build
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), DefaultConfig())
+	if got := slices.Contains(r.ProtectionDegraded(), DegradedStub); got != sentinel.NewClient().Stub() {
+		t.Errorf("stub reported = %v, expected %v", got, !got)
+	}
+	if got := slices.Contains(r.Health().ProtectionDegraded, DegradedStub); got != sentinel.NewClient().Stub() {
+		t.Errorf("health stub reported = %v", got)
+	}
+}
+
+func TestAnnounceProtection(t *testing.T) {
+	ladder, _ := degrade.New(degrade.DefaultConfig())
+	ladder.Set(degrade.LevelSkipCouncil, "test")
+	client, clientSide := newPipe()
+	_, serverSide := newPipe()
+	cfg := DefaultConfig()
+	cfg.Degradation = ladder
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewFusedClient(nil, sentinel.Member{Name: "remote", Backend: &poisonBackend{}}), cfg)
+	defer r.EndSession()
+	initialized := []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	r.RouteMessage(initialized)
+	expectMessage(t, client, `degraded (degradation:skip-council)`)
+
+	// Unchanged protection is announced once
+	r.RouteMessage(initialized)
+	ladder.Set(degrade.LevelFull, "test")
+	r.RouteMessage(initialized)
+	expectMessage(t, client, "running in full again")
+	r.RouteMessage(initialized)
+	select {
+	case data := <-client.in:
+		t.Errorf("unexpected message %s", data)
+	default:
+	}
+}
@@ -0,0 +1,263 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// NotifyBlocked follows the error response to a blocked tool call when
+// read receipts are enabled.
+const NotifyBlocked = "notifications/sentinel/blocked"
+
+// ReceiptEscalation is what a session gets once it has ignored too many
+// blocks.
+type ReceiptEscalation string
+
+const (
+	// ReceiptCouncil requires a council vote for every later tool call
+	ReceiptCouncil ReceiptEscalation = "council"
+	// ReceiptBlock refuses every later tool call
+	ReceiptBlock ReceiptEscalation = "block"
+)
+
+// maxPendingReceipts bounds the notifications waiting for their error
+// response to be sent, for callers that drive RouteMessage themselves.
+const maxPendingReceipts = 64
+
+// ReadReceipts follows each blocked tool call's error response with a
+// NotifyBlocked notification carrying remediation guidance, and watches
+// whether the client heeded it.
+//
+// A client that sends the identical call (same tool, same arguments)
+// again within RetryWindow of the block ignored it: the retry is
+// recorded in its decision ("ignored_block"), counted, and reported to
+// the anomaly scorer. After EscalateAfter ignored blocks the session is
+// escalated for the rest of its life.
+//
+// # Security Notes
+//
+// Agents that loop on a refused call either never surface the error to
+// their model or are being steered around it. Neither should keep the
+// same trust, and escalation takes the decision away from the client.
+type ReadReceipts struct {
+	// RetryWindow is how soon an identical call after a block counts
+	// as ignoring it (zero uses 10s)
+	RetryWindow time.Duration
+
+	// EscalateAfter is the number of ignored blocks that escalates the
+	// session (zero uses 3)
+	EscalateAfter int
+
+	// Escalation applies to an escalated session (empty uses
+	// ReceiptCouncil)
+	Escalation ReceiptEscalation
+}
+
+// BlockedNotice is the NotifyBlocked notification's params.
+type BlockedNotice struct {
+	// RequestID is the ID of the blocked request
+	RequestID json.RawMessage `json:"request_id"`
+
+	// DecisionID identifies the block's decision
+	DecisionID string `json:"decision_id"`
+
+	// Tool is the blocked tool
+	Tool string `json:"tool"`
+
+	// Reason is why the call was blocked
+	Reason string `json:"reason"`
+
+	// Remediation tells the client what to do instead
+	Remediation string `json:"remediation"`
+
+	// Ignored counts the session's ignored blocks so far
+	Ignored int `json:"ignored"`
+
+	// Escalated reports that the session has been escalated
+	Escalated bool `json:"escalated"`
+}
+
+// receiptBlock is a blocked call a retry would ignore.
+type receiptBlock struct {
+	at         time.Time
+	decisionID string
+}
+
+// receiptLog tracks a session's blocks and ignored ones.
+type receiptLog struct {
+	cfg ReadReceipts
+	now func() time.Time
+
+	mu        sync.Mutex
+	blocks    map[string]receiptBlock
+	pending   map[string][]byte
+	ignored   int
+	escalated bool
+}
+
+// newReceiptLog applies defaults to cfg and ages blocks by c.
+func newReceiptLog(cfg *ReadReceipts, clk clock.Clock) *receiptLog {
+	c := *cfg
+	if c.RetryWindow <= 0 {
+		c.RetryWindow = 10 * time.Second
+	}
+	if c.EscalateAfter <= 0 {
+		c.EscalateAfter = 3
+	}
+	if c.Escalation == "" {
+		c.Escalation = ReceiptCouncil
+	}
+	return &receiptLog{cfg: c, now: clock.Func(clk), blocks: make(map[string]receiptBlock), pending: make(map[string][]byte)}
+}
+
+// retryKey identifies a tool call by its tool and canonical arguments.
+func retryKey(tool string, params json.RawMessage) string {
+	var p struct {
+		Arguments interface{} `json:"arguments"`
+	}
+	json.Unmarshal(params, &p)
+	// Marshaling sorts object keys
+	args, _ := json.Marshal(p.Arguments)
+	return tool + "\x00" + string(args)
+}
+
+// checkReceipt detects a retry of a blocked call and applies the
+// session's escalation. It returns an error reply if the call is
+// refused.
+func (r *Router) checkReceipt(d *Decision, msg *jsonrpc.Message) ([]byte, bool) {
+	l := r.receipts
+	d.retryKey = retryKey(d.Tool, msg.Params)
+
+	l.mu.Lock()
+	now := l.now()
+	for key, b := range l.blocks {
+		if now.Sub(b.at) > l.cfg.RetryWindow {
+			delete(l.blocks, key)
+		}
+	}
+	prior, retried := l.blocks[d.retryKey]
+	tripped := false
+	if retried {
+		delete(l.blocks, d.retryKey)
+		l.ignored++
+		if l.ignored >= l.cfg.EscalateAfter && !l.escalated {
+			l.escalated, tripped = true, true
+		}
+	}
+	ignored, escalated := l.ignored, l.escalated
+	l.mu.Unlock()
+
+	if retried {
+		r.stats.IgnoredBlocks.Add(1)
+		d.Details = withDetailMap(d.Details, "ignored_block", prior.decisionID)
+		d.finding(sentinel.Finding{
+			Source:   sentinel.EvidenceReadReceipt,
+			Kind:     "ignored_block",
+			Severity: min(1, 0.4+0.2*float64(ignored)),
+			Summary:  fmt.Sprintf("identical call blocked by decision %s was retried (%d ignored blocks this session)", prior.decisionID, ignored),
+		})
+		r.RecordAnomaly(anomaly.SignalIgnoredBlock, fmt.Sprintf("%s retried after block %s", d.Tool, prior.decisionID))
+	}
+	if tripped {
+		log.Printf("audit: session %s escalated to %s after %d ignored blocks", r.sessionID, l.cfg.Escalation, ignored)
+	}
+	if !escalated {
+		return nil, false
+	}
+	d.Details = withDetailMap(d.Details, "receipt_escalation", string(l.cfg.Escalation))
+	if l.cfg.Escalation == ReceiptBlock {
+		r.stats.MessagesBlocked.Add(1)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Session escalated",
+			fmt.Sprintf("tool calls refused after %d ignored blocks", ignored))
+		return reply, true
+	}
+	d.requireCouncil = true
+	return nil, false
+}
+
+// receiptBlocked remembers a blocked tool call and prepares its
+// NotifyBlocked follow-up, sent after the error response.
+func (r *Router) receiptBlocked(d *Decision, id json.RawMessage, code int, reason string) {
+	l := r.receipts
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if code != CodePaused {
+		// A paused call is expected to be retried once resumed
+		l.blocks[d.retryKey] = receiptBlock{at: l.now(), decisionID: d.ID}
+	}
+	notice := BlockedNotice{
+		RequestID:   id,
+		DecisionID:  d.ID,
+		Tool:        d.Tool,
+		Reason:      reason,
+		Remediation: remediation(code, l.escalated),
+		Ignored:     l.ignored,
+		Escalated:   l.escalated,
+	}
+	msg, err := jsonrpc.NewNotification(NotifyBlocked, notice)
+	if err != nil {
+		return
+	}
+	data, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return
+	}
+	if len(l.pending) >= maxPendingReceipts {
+		clear(l.pending)
+	}
+	l.pending[string(id)] = data
+}
+
+// remediation returns guidance for a blocked call's error code.
+func remediation(code int, escalated bool) string {
+	if escalated {
+		return "This session ignored repeated blocks and is under stricter policy. Stop retrying blocked calls and ask the user how to proceed."
+	}
+	switch code {
+	case CodePaused:
+		return "An operator paused this session. Retry after it is resumed."
+	case CodeRateLimited:
+		return "Calls to this tool are rate limited. Wait before calling it again."
+	case CodeApprovalRequired:
+		return "This tool awaits operator approval. Do not retry until it is approved."
+	case jsonrpc.InvalidParams:
+		return "The arguments were refused. Do not retry them; revise the request or ask the user."
+	}
+	return "This call is not permitted. Do not retry it; choose a different approach or ask the user."
+}
+
+// send writes a response to the client, followed by any read receipt
+// for it.
+func (r *Router) send(response []byte) error {
+	if err := r.transport.Send(response); err != nil {
+		return err
+	}
+	if r.receipts == nil {
+		return nil
+	}
+	l := r.receipts
+	l.mu.Lock()
+	if len(l.pending) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	var id string
+	if msg, err := jsonrpc.Parse(response); err == nil {
+		id = string(msg.ID)
+	}
+	notice, ok := l.pending[id]
+	delete(l.pending, id)
+	l.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return r.transport.Send(notice)
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestReadReceipts(t *testing.T) {
+	tests := []struct {
+		name       string
+		escalation ReceiptEscalation
+		blocked    bool
+		council    bool
+	}{
+		{"council", ReceiptCouncil, false, true},
+		{"block", ReceiptBlock, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent [][]byte
+			cfg := DefaultConfig()
+			cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+			cfg.ReadReceipts = &ReadReceipts{EscalateAfter: 2, Escalation: tt.escalation}
+			r := NewWithConfig(&mockTransport{sendFunc: func(data []byte) error {
+				sent = append(sent, data)
+				return nil
+			}}, sentinel.NewClient(), cfg)
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+				return jsonrpc.Serialize(resp)
+			}
+			call := func(id int, tool, args string) *jsonrpc.Message {
+				req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool, "arguments": json.RawMessage(args)}, id)
+				data, _ := jsonrpc.Serialize(req)
+				response, _ := r.RouteMessage(data)
+				sent = nil
+				if err := r.send(response); err != nil {
+					t.Fatalf("send failed: %v", err)
+				}
+				resp, err := jsonrpc.Parse(response)
+				if err != nil {
+					t.Fatalf("Parse failed: %v", err)
+				}
+				return resp
+			}
+
+			if resp := call(1, "shell", `{"cmd":"ls","dir":"/"}`); resp.Error == nil {
+				t.Fatal("denied tool not blocked")
+			}
+			if len(sent) != 2 {
+				t.Fatalf("sent %d messages, expected the error and a receipt", len(sent))
+			}
+			notice, _ := jsonrpc.Parse(sent[1])
+			var params BlockedNotice
+			json.Unmarshal(notice.Params, &params)
+			if notice.Method != NotifyBlocked || string(params.RequestID) != "1" || params.Tool != "shell" || params.Remediation == "" {
+				t.Errorf("receipt = %s", sent[1])
+			}
+			first := r.RecentDecisions(1)[0]
+
+			// Other arguments are a new call; reordered keys are the same call
+			call(2, "shell", `{"cmd":"pwd"}`)
+			if r.stats.IgnoredBlocks.Load() != 0 {
+				t.Fatal("different arguments counted as a retry")
+			}
+			call(3, "shell", `{"dir":"/","cmd":"ls"}`)
+			d := r.RecentDecisions(1)[0]
+			if d.Details["ignored_block"] != first.ID || r.stats.IgnoredBlocks.Load() != 1 {
+				t.Errorf("ignored_block = %v, IgnoredBlocks = %d", d.Details["ignored_block"], r.stats.IgnoredBlocks.Load())
+			}
+
+			if resp := call(4, "read_file", `{"path":"/srv/a"}`); resp.Error != nil {
+				t.Fatalf("allowed call refused before escalation: %+v", resp.Error)
+			}
+			call(5, "shell", `{"cmd":"pwd"}`)
+
+			resp := call(6, "read_file", `{"path":"/srv/a"}`)
+			if blocked := resp.Error != nil; blocked != tt.blocked {
+				t.Errorf("escalated call blocked = %t, expected %t", blocked, tt.blocked)
+			}
+			d = r.RecentDecisions(1)[0]
+			if d.Details["receipt_escalation"] != string(tt.escalation) {
+				t.Errorf("receipt_escalation = %v", d.Details["receipt_escalation"])
+			}
+			if ran := slices.Contains(d.checks, CheckCouncil); ran != tt.council {
+				t.Errorf("council ran = %t, expected %t", ran, tt.council)
+			}
+		})
+	}
+}
+
+func TestReadReceipts_RetryWindow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.ReadReceipts = &ReadReceipts{RetryWindow: time.Millisecond}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "shell"}, 1)
+	data, _ := jsonrpc.Serialize(req)
+
+	r.RouteMessage(data)
+	time.Sleep(5 * time.Millisecond)
+	r.RouteMessage(data)
+	if r.stats.IgnoredBlocks.Load() != 0 {
+		t.Error("retry after the window counted as ignoring the block")
+	}
+	// The late retry was blocked in turn; an immediate one ignores it
+	r.RouteMessage(data)
+	if r.stats.IgnoredBlocks.Load() != 1 {
+		t.Errorf("IgnoredBlocks = %d, expected 1", r.stats.IgnoredBlocks.Load())
+	}
+}
+
+func TestReadReceipts_PendingUntilSent(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.ReadReceipts = &ReadReceipts{}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	for i := 0; i < 2*maxPendingReceipts; i++ {
+		req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "shell"}, i)
+		data, _ := jsonrpc.Serialize(req)
+		r.RouteMessage(data)
+	}
+	if n := len(r.receipts.pending); n > maxPendingReceipts {
+		t.Errorf("%d receipts pending, expected at most %d", n, maxPendingReceipts)
+	}
+}
@@ -0,0 +1,57 @@
+package router
+
+// Settings are the router settings that can change while a session
+// runs. The policy engine and gas model are shared and replaced in
+// place (policy.Engine.Replace, SetGasModel); everything else in Config
+// is fixed at NewWithConfig.
+type Settings struct {
+	// ToolPolicy allows or denies tool calls by name (nil allows every
+	// tool)
+	ToolPolicy *ToolPolicy
+
+	// HighRiskTools lists the server tool names that require a council
+	// vote (nil uses the built-in list)
+	HighRiskTools []string
+}
+
+// Reconfigure swaps in new settings without interrupting the session:
+// its transports, pending requests, gas, and history are kept. Invalid
+// settings are rejected and the current ones stay in effect.
+//
+// # Thread Safety
+//
+// Safe to call while messages are routed. A message already past a
+// check keeps the verdict it got under the previous settings.
+func (r *Router) Reconfigure(s Settings) error {
+	if s.ToolPolicy != nil {
+		if err := s.ToolPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	highRisk := toolSet(s.HighRiskTools)
+
+	r.settingsMu.Lock()
+	defer r.settingsMu.Unlock()
+	r.toolPolicy = s.ToolPolicy
+	r.highRiskTools = highRisk
+	return nil
+}
+
+// currentToolPolicy returns the tool policy in effect (may be nil).
+func (r *Router) currentToolPolicy() *ToolPolicy {
+	r.settingsMu.RLock()
+	defer r.settingsMu.RUnlock()
+	return r.toolPolicy
+}
+
+// toolSet indexes tool names, keeping nil as nil.
+func toolSet(names []string) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestReconfigure(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	call := func(tool string) bool {
+		req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		response, _ := r.RouteMessage(data)
+		resp, err := jsonrpc.Parse(response)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		return resp.Error == nil
+	}
+
+	if call("shell") || !call("deploy") {
+		t.Fatal("initial tool policy not applied")
+	}
+	gas := r.gasUsed.Load()
+
+	if err := r.Reconfigure(Settings{ToolPolicy: &ToolPolicy{Deny: []string{"deploy"}}, HighRiskTools: []string{"deploy"}}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if !call("shell") || call("deploy") {
+		t.Error("reconfigured tool policy not applied")
+	}
+	if !r.isHighRisk("deploy") || r.isHighRisk("execute_command") {
+		t.Error("reconfigured high-risk tools not applied")
+	}
+	if r.gasUsed.Load() <= gas {
+		t.Error("session gas reset by Reconfigure")
+	}
+
+	// An invalid policy keeps the current settings
+	if err := r.Reconfigure(Settings{ToolPolicy: &ToolPolicy{Deny: []string{"[x"}}}); err == nil {
+		t.Fatal("Reconfigure accepted a malformed pattern")
+	}
+	if call("deploy") || !r.isHighRisk("deploy") {
+		t.Error("settings changed by a rejected Reconfigure")
+	}
+
+	if err := r.Reconfigure(Settings{}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if !call("deploy") || !r.isHighRisk("execute_command") {
+		t.Error("empty settings did not restore the defaults")
+	}
+}
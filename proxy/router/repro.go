@@ -0,0 +1,71 @@
+package router
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// serverIdentity is the server's initialize result, kept for the
+// context of reproduction bundles.
+type serverIdentity struct {
+	mu       sync.Mutex
+	protocol string
+	name     string
+	version  string
+}
+
+// noteServer records the server's identity from its initialize
+// response; a result that does not decode is captured as a validation
+// failure.
+func (r *Router) noteServer(response []byte) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		r.captureRepro(crash.ReproParse, "initialize", response, err)
+		return
+	}
+	result, err := mcptypes.DecodeResult[mcptypes.InitializeResult](resp)
+	if err != nil {
+		r.captureValidation("initialize", response, err)
+		return
+	}
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	r.server.protocol = result.ProtocolVersion
+	r.server.name = result.ServerInfo.Name
+	r.server.version = result.ServerInfo.Version
+}
+
+// captureValidation captures a result that failed to decode; error
+// responses and responses without a result are not failures.
+func (r *Router) captureValidation(method string, data []byte, err error) {
+	if !errors.Is(err, mcptypes.ErrNoResult) {
+		r.captureRepro(crash.ReproValidation, method, data, err)
+	}
+}
+
+// captureRepro writes a reproduction bundle for server data the router
+// could not process, if bundles are enabled. method is the request the
+// data answered ("" if unknown).
+func (r *Router) captureRepro(kind, method string, data []byte, err error) {
+	if r.repro == nil {
+		return
+	}
+	r.server.mu.Lock()
+	b := &crash.Bundle{
+		Kind:            kind,
+		Error:           err.Error(),
+		Session:         r.sessionID,
+		Direction:       string(audit.ServerToClient),
+		Method:          method,
+		ProtocolVersion: r.server.protocol,
+		ServerName:      r.server.name,
+		ServerVersion:   r.server.version,
+	}
+	r.server.mu.Unlock()
+	r.repro.Capture(b, data)
+}
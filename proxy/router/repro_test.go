@@ -0,0 +1,94 @@
+//go:build !norecorder
+
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// readBundles decodes the reproduction bundles in dir.
+func readBundles(t *testing.T, dir string) []crash.Bundle {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "repro-*.json"))
+	var bundles []crash.Bundle
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		var b crash.Bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatalf("bad bundle %s: %v", f, err)
+		}
+		bundles = append(bundles, b)
+	}
+	return bundles
+}
+
+func TestRepro_CapturesServerFailures(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Repro = crash.NewReproRecorder(&crash.ReproConfig{Dir: dir})
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`))
+	expectMessage(t, server, `"method":"initialize"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","serverInfo":{"name":"fs","version":"0.9"},"capabilities":{}}}`))
+	expectMessage(t, client, `"id":1`)
+	server.Send([]byte(`Listening on stdio`))
+	server.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`))
+	expectMessage(t, client, `"method":"notifications/message"`)
+
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after client disconnect")
+	}
+
+	bundles := readBundles(t, dir)
+	if len(bundles) != 1 {
+		t.Fatalf("%d bundles, expected 1: %+v", len(bundles), bundles)
+	}
+	b := bundles[0]
+	if b.Kind != crash.ReproParse || b.Text != "xxxxxxxxx xx xxxxx" || b.Session != r.sessionID ||
+		b.ServerName != "fs" || b.ServerVersion != "0.9" || b.ProtocolVersion != "2025-06-18" {
+		t.Errorf("bundle = %+v", b)
+	}
+}
+
+func TestRepro_CapturesInvalidResults(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Repro = crash.NewReproRecorder(&crash.ReproConfig{Dir: dir})
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	responses := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":2025}}`,
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"Method not found"}}`,
+	}
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		out := responses[0]
+		responses = responses[1:]
+		return []byte(out), nil
+	}
+
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}}`))
+
+	bundles := readBundles(t, dir)
+	if len(bundles) != 1 || bundles[0].Kind != crash.ReproValidation || bundles[0].Method != "initialize" {
+		t.Errorf("bundles = %+v, expected one initialize validation failure", bundles)
+	}
+}
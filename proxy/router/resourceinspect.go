@@ -0,0 +1,221 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/scanner"
+)
+
+// DefaultResourceMaxBytes bounds the content of one resources/read
+// result when the inspection sets no size.
+const DefaultResourceMaxBytes = 4 << 20
+
+// ResourceLimits are the limits resources/read results of the servers
+// matching Server are held to.
+type ResourceLimits struct {
+	// Server is a path.Match pattern of the server names, as reported
+	// at initialize, the limits apply to
+	Server string
+
+	// MaxBytes bounds the content of one result, text plus decoded
+	// blobs (0 uses the inspection's MaxBytes)
+	MaxBytes int
+}
+
+// ResourceInspection checks resources/read results against the content
+// types they declare before they reach the client.
+//
+// A result is refused when:
+//   - its content, text plus decoded blobs, exceeds the size limit
+//   - a blob is not valid base64
+//   - content declared as text, JSON, XML, or another textual type is
+//     binary, or binary content declared as an image, audio, or video
+//     is sent as text
+//   - a blob's sniffed type is of another kind than the type it
+//     declares, such as HTML declared as image/png
+//   - a blob that decodes to text contains an injection
+//
+// # Security Notes
+//
+// Text entries are scanned by the content scanner as they are; blobs
+// are opaque to it, so a server can hide instructions in base64 under
+// any declared type. Sniffing reads only the first 512 bytes and knows
+// a fixed set of formats: content it does not recognize is held to its
+// declared type only as far as text versus binary.
+type ResourceInspection struct {
+	// MaxBytes bounds the content of one result for servers without
+	// limits of their own (0 uses DefaultResourceMaxBytes)
+	MaxBytes int
+
+	// Servers sets limits by server; the first match applies
+	Servers []ResourceLimits
+
+	// Scanner finds injections in blobs that decode to text (nil uses
+	// the scanner's default rules)
+	Scanner *scanner.Scanner
+}
+
+// resourceInspector applies a ResourceInspection.
+type resourceInspector struct {
+	ResourceInspection
+	scanner *scanner.Scanner
+}
+
+// newResourceInspector fills in the defaults of p.
+func newResourceInspector(p *ResourceInspection) *resourceInspector {
+	ri := &resourceInspector{ResourceInspection: *p, scanner: p.Scanner}
+	if ri.scanner == nil {
+		// The default rules always compile
+		ri.scanner, _ = scanner.New(scanner.Config{})
+	}
+	if ri.MaxBytes <= 0 {
+		ri.MaxBytes = DefaultResourceMaxBytes
+	}
+	return ri
+}
+
+// maxBytes returns the size limit for the named server.
+func (ri *resourceInspector) maxBytes(server string) int {
+	for _, l := range ri.Servers {
+		if ok, _ := path.Match(l.Server, server); ok && server != "" {
+			if l.MaxBytes > 0 {
+				return l.MaxBytes
+			}
+			break
+		}
+	}
+	return ri.MaxBytes
+}
+
+// inspectResource checks a resources/read response and returns the
+// reason it must be refused, or "" to deliver it.
+func (r *Router) inspectResource(d *Decision, response []byte) string {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil {
+		return ""
+	}
+	result, err := mcptypes.DecodeResult[mcptypes.ReadResourceResult](resp)
+	if err != nil {
+		return "malformed resources/read result: " + err.Error()
+	}
+
+	r.server.mu.Lock()
+	server := r.server.name
+	r.server.mu.Unlock()
+	limit := r.resourceInspection.maxBytes(server)
+
+	size := 0
+	for i, c := range result.Contents {
+		content, blob := []byte(c.Text), c.Blob != ""
+		if blob {
+			content, err = base64.StdEncoding.DecodeString(c.Blob)
+			if err != nil {
+				return fmt.Sprintf("contents[%d]: blob is not valid base64", i)
+			}
+		}
+		if size += len(content); size > limit {
+			return fmt.Sprintf("resource content exceeds %d bytes", limit)
+		}
+		if reason := r.resourceInspection.checkContent(c.MimeType, content, blob); reason != "" {
+			return fmt.Sprintf("contents[%d]: %s", i, reason)
+		}
+	}
+	d.Details = withDetailMap(d.Details, "resource_bytes", size)
+	return ""
+}
+
+// checkContent checks one entry of a resources/read result, its text or
+// decoded blob, against its declared MIME type.
+func (ri *resourceInspector) checkContent(declared string, content []byte, blob bool) string {
+	mediaType, _, _ := mime.ParseMediaType(declared)
+	text := isText(content)
+	switch {
+	case textualType(mediaType) && !text:
+		return fmt.Sprintf("binary content declared as %s", mediaType)
+	case !blob && !text:
+		return "binary content in a text entry"
+	case !blob && binaryKind(mediaType):
+		return fmt.Sprintf("text entry declared as %s", mediaType)
+	}
+	if blob && mediaType != "" && mediaType != "application/octet-stream" && !textualType(mediaType) {
+		sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+		if sniffed != "application/octet-stream" && sniffed != "text/plain" && kindOf(sniffed) != kindOf(mediaType) {
+			return fmt.Sprintf("declared as %s but content is %s", mediaType, sniffed)
+		}
+	}
+	if blob && text {
+		if findings := ri.scanner.Scan(string(content)); len(findings) > 0 {
+			return "injection in decoded blob: " + findings[0].Rule
+		}
+	}
+	return ""
+}
+
+// isText reports whether content is text: valid UTF-8 without NUL or
+// the control bytes sniffing takes for binary.
+func isText(content []byte) bool {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+	return strings.HasPrefix(http.DetectContentType(content), "text/")
+}
+
+// textualApplicationTypes are the application/ media types of text.
+var textualApplicationTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/sql":        true,
+	"application/graphql":    true,
+	"application/x-sh":       true,
+}
+
+// textualType reports whether a media type is text.
+func textualType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || textualApplicationTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "+yaml")
+}
+
+// binaryKind reports whether a media type is an image, audio, or video
+// format that cannot be text.
+func binaryKind(mediaType string) bool {
+	switch kindOf(mediaType) {
+	case "image", "audio", "video":
+		return !textualType(mediaType)
+	}
+	return false
+}
+
+// kindOf returns the top-level type of a media type.
+func kindOf(mediaType string) string {
+	kind, _, _ := strings.Cut(mediaType, "/")
+	return kind
+}
+
+// applyResourceInspection refuses a resources/read response that fails
+// inspection, returning the reply and true.
+func (r *Router) applyResourceInspection(d *Decision, msg *jsonrpc.Message, response []byte) ([]byte, bool) {
+	reason := r.inspectResource(d, response)
+	if reason == "" {
+		return nil, false
+	}
+	r.stats.ResourcesRejected.Add(1)
+	r.stats.MessagesBlocked.Add(1)
+	log.Printf("audit: session %s: refused resource %s: %s", r.sessionID, jsonrpc.ExtractResourceURI(msg), reason)
+	reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Resource rejected", reason)
+	return reply, true
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/resourcestore"
+)
+
+// readThrough serves a resources/read request from the resource store.
+//
+// Entries are kept per server, named by its initialize result. Fresh
+// entries are answered locally with the request's ID. Missing or
+// stale entries are forwarded to the server and the successful result
+// is stored, which also revalidates the previously stored content.
+func (r *Router) readThrough(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, error) {
+	uri := jsonrpc.ExtractResourceURI(msg)
+	r.server.mu.Lock()
+	server := r.server.name
+	r.server.mu.Unlock()
+	if server == "" {
+		server = unnamedServer
+	}
+
+	if uri != "" {
+		cached, fresh, err := r.resourceStore.Lookup(server, uri)
+		switch {
+		case err == nil && fresh:
+			resp, err := jsonrpc.NewResponse(msg.ID, json.RawMessage(cached))
+			if err != nil {
+				r.stats.Errors.Add(1)
+				return nil, fmt.Errorf("router: build stored response: %w", err)
+			}
+			r.stats.ServedFromStore.Add(1)
+			return jsonrpc.Serialize(resp)
+		case errors.Is(err, resourcestore.ErrCorrupt):
+			log.Printf("router: stored resource %q failed verification, refetching", uri)
+		}
+	}
+
+	response, err := r.forward(d, msg, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri == "" {
+		return response, nil
+	}
+	if resp, err := jsonrpc.Parse(response); err == nil && resp.Error == nil && len(resp.Result) > 0 {
+		changed, err := r.resourceStore.Put(server, uri, resp.Result)
+		if err != nil {
+			log.Printf("router: failed to store resource %q: %v", uri, err)
+		} else if changed {
+			log.Printf("router: resource %q changed upstream since last validation", uri)
+		}
+	}
+	return response, nil
+}
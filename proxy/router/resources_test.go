@@ -0,0 +1,56 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/resourcestore"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_ResourceReadThrough(t *testing.T) {
+	store, err := resourcestore.Open(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.ResourceStore = store
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	forwards := 0
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwards++
+		req, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(req.ID, map[string]interface{}{
+			"contents": []map[string]string{{"uri": "file:///notes.txt", "text": "hello"}},
+		})
+		return jsonrpc.Serialize(resp)
+	}
+
+	for i := 1; i <= 2; i++ {
+		req, _ := jsonrpc.NewRequest("resources/read", map[string]string{"uri": "file:///notes.txt"}, i)
+		data, _ := jsonrpc.Serialize(req)
+
+		response, err := r.RouteMessage(data)
+		if err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+		resp, err := jsonrpc.Parse(response)
+		if err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if string(resp.ID) != string(json.RawMessage(req.ID)) {
+			t.Errorf("response id = %s, expected %s", resp.ID, req.ID)
+		}
+	}
+
+	if forwards != 1 {
+		t.Errorf("expected 1 upstream read, got %d", forwards)
+	}
+	if served := r.stats.ServedFromStore.Load(); served != 1 {
+		t.Errorf("expected 1 read served from store, got %d", served)
+	}
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sessionstate"
+)
+
+// MetaResumptionToken is the _meta key carrying a session's resumption
+// token: in the initialize result the router issues it, and in a later
+// initialize request the client presents it to resume the session.
+const MetaResumptionToken = "io.mcp-sentinel/resumptionToken"
+
+// resumptionPrefix starts the state keys of resumable sessions.
+const resumptionPrefix = "resume/"
+
+// newResumptionToken returns a random 256-bit token.
+func newResumptionToken() string {
+	var b [32]byte
+	rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// resumptionKey returns the state key of a token. It is a digest, so
+// the store holds no token a reader could present.
+func resumptionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return resumptionPrefix + hex.EncodeToString(sum[:])
+}
+
+// resumeSession takes the resumption token off an initialize request
+// and, if it names a saved session, restores that session's security
+// context into this one. The saved state moves to this session's own
+// key, so each token resumes once; the initialize result carries the
+// next.
+//
+// # Returns
+//   - Message bytes to forward, without the token
+//
+// # Security Notes
+//
+// The token is removed before forwarding, so the server never sees
+// it. An unknown token is logged and the session starts fresh: the
+// client could as well have presented none, so resumption carries an
+// honest client's context across reconnects but cannot hold one that
+// wants to start over. A terminated session is resumed terminated and
+// its state left under the token, so reconnecting with it keeps being
+// refused.
+func (r *Router) resumeSession(d *Decision, msg *jsonrpc.Message, data []byte) []byte {
+	params, meta, ok := chainParams(msg.Params)
+	raw, found := meta[MetaResumptionToken]
+	if !ok || !found {
+		return data
+	}
+	delete(meta, MetaResumptionToken)
+	if len(meta) == 0 {
+		delete(params, "_meta")
+	} else {
+		params["_meta"], _ = json.Marshal(meta)
+	}
+	msg.Params, _ = json.Marshal(params)
+	if rewritten, err := jsonrpc.Serialize(msg); err == nil {
+		data = rewritten
+	}
+
+	var token string
+	if json.Unmarshal(raw, &token) != nil || token == "" {
+		log.Printf("router: session %s: ignored a malformed resumption token", r.sessionID)
+		return data
+	}
+	key := resumptionKey(token)
+	if key == r.stateKey {
+		return data
+	}
+	saved, err := r.stateStore.Load(key)
+	if err != nil {
+		if !errors.Is(err, sessionstate.ErrNotFound) {
+			log.Printf("router: session %s: resumption state not loaded: %v", r.sessionID, err)
+		} else {
+			log.Printf("router: session %s: unknown or spent resumption token; starting fresh", r.sessionID)
+		}
+		return data
+	}
+
+	r.applyState(saved)
+	d.Details = withDetailMap(d.Details, "resumed", saved.Session)
+	log.Printf("router: session %s: resumed session %s (%d tool calls, gas %d, terminated=%v)",
+		r.sessionID, saved.Session, len(saved.PreviousTools), saved.GasUsed, saved.Terminated)
+	if saved.Terminated {
+		return data
+	}
+	r.saveState()
+	if err := r.stateStore.Delete(key); err != nil {
+		log.Printf("router: session %s: failed to retire resumption state: %v", r.sessionID, err)
+	}
+	return data
+}
+
+// issueResumption adds the session's resumption token to an initialize
+// result's _meta. Error and malformed responses are returned unchanged.
+func (r *Router) issueResumption(response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response
+	}
+	result, meta, ok := chainParams(resp.Result)
+	if !ok {
+		return response
+	}
+	meta[MetaResumptionToken], _ = json.Marshal(r.resumeToken)
+	result["_meta"], _ = json.Marshal(meta)
+	resp.Result, _ = json.Marshal(result)
+	out, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return response
+	}
+	// The state exists from the first reply, so a client that
+	// reconnects before its first tool call can still resume
+	r.saveState()
+	return out
+}
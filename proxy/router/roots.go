@@ -0,0 +1,122 @@
+package router
+
+import (
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// RootsPolicy decides which servers may list the client's roots, the
+// directories and files it exposes, and which roots they see.
+//
+// A server not listed receives an empty roots/list result without the
+// client being asked. For listed servers, roots outside Prefixes are
+// removed from the client's answer.
+//
+// # Security Notes
+//
+// Roots tell a server where the user's projects live and invite it to
+// operate there; a server has no need to know about workspaces it is
+// not meant to touch. Servers are identified by the name they report
+// at initialize, which a server chooses itself: the policy keeps roots
+// from servers that are honest about who they are, it does not
+// authenticate them. Until the server has initialized, it is refused.
+type RootsPolicy struct {
+	// Servers lists the server names, as path.Match patterns, that may
+	// list roots (empty refuses every server)
+	Servers []string
+
+	// Prefixes limits the roots relayed to URIs beginning with one of
+	// them, such as file:///home/dev/project (empty relays every root)
+	Prefixes []string
+}
+
+// rootsAllowed reports whether the server may list roots.
+func (r *Router) rootsAllowed() (bool, string) {
+	r.server.mu.Lock()
+	name := r.server.name
+	r.server.mu.Unlock()
+	if name == "" {
+		return false, "server has not identified itself"
+	}
+	for _, pattern := range r.roots.Servers {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true, ""
+		}
+	}
+	return false, "server " + name + " may not list roots"
+}
+
+// screenRoots checks a server's roots/list request. It reports false if
+// the request was answered with an empty list.
+func (r *Router) screenRoots(msg *jsonrpc.Message) bool {
+	ok, reason := r.rootsAllowed()
+	if ok {
+		return true
+	}
+	r.stats.RootsRefused.Add(1)
+	log.Printf("audit: session %s: withheld roots from request %s: %s", r.sessionID, msg.ID, reason)
+	r.auditServerRequest(msg, reason)
+	r.answerServer(msg, mcptypes.ListRootsResult{Roots: []mcptypes.Root{}})
+	return false
+}
+
+// filterRoots removes the roots outside the policy's prefixes from the
+// client's answer to roots/list and returns the answer to relay.
+func (r *Router) filterRoots(msg *jsonrpc.Message, data []byte) []byte {
+	if len(r.roots.Prefixes) == 0 {
+		return data
+	}
+	result, err := mcptypes.DecodeResult[mcptypes.ListRootsResult](msg)
+	if err != nil {
+		return data
+	}
+	kept := make([]mcptypes.Root, 0, len(result.Roots))
+	for _, root := range result.Roots {
+		if r.rootPermitted(root.URI) {
+			kept = append(kept, root)
+		}
+	}
+	removed := len(result.Roots) - len(kept)
+	if removed == 0 {
+		return data
+	}
+	result.Roots = kept
+	resp, err := jsonrpc.NewResponse(msg.ID, result)
+	if err != nil {
+		return data
+	}
+	out, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return data
+	}
+	r.stats.RootsFiltered.Add(uint64(removed))
+	log.Printf("router: session %s: withheld %d roots outside the permitted prefixes", r.sessionID, removed)
+	return out
+}
+
+// rootPermitted reports whether uri is under a permitted prefix. A
+// prefix matches whole path segments, so file:///srv/a does not admit
+// file:///srv/ab, and roots climbing out with ".." segments are refused.
+func (r *Router) rootPermitted(uri string) bool {
+	decoded, err := url.PathUnescape(uri)
+	if err != nil {
+		return false
+	}
+	for _, seg := range strings.Split(decoded, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	for _, prefix := range r.roots.Prefixes {
+		p := strings.TrimSuffix(prefix, "/")
+		if uri == p || strings.HasPrefix(uri, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRootPermitted(t *testing.T) {
+	r := &Router{roots: &RootsPolicy{Prefixes: []string{"file:///home/dev/project/"}}}
+	tests := []struct {
+		uri      string
+		expected bool
+	}{
+		{"file:///home/dev/project", true},
+		{"file:///home/dev/project/sub", true},
+		{"file:///home/dev/projects", false},
+		{"file:///home/dev/project/../secrets", false},
+		{"file:///home/dev/project/%2e%2e/secrets", false},
+		{"file:///etc", false},
+	}
+	for _, tt := range tests {
+		if got := r.rootPermitted(tt.uri); got != tt.expected {
+			t.Errorf("rootPermitted(%q) = %v, expected %v", tt.uri, got, tt.expected)
+		}
+	}
+}
+
+func TestRunBidirectional_Roots(t *testing.T) {
+	client, clientSide := newPipe()
+	server, serverSide := newPipe()
+	cfg := DefaultConfig()
+	cfg.Roots = &RootsPolicy{Servers: []string{"fs-*"}, Prefixes: []string{"file:///work"}}
+	r := NewWithTransports(clientSide, serverSide, sentinel.NewClient(), cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// Refused before the server has identified itself
+	server.Send([]byte(`{"jsonrpc":"2.0","id":"r1","method":"roots/list"}`))
+	expectMessage(t, server, `"roots":[]`)
+
+	client.Send([]byte(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"roots":{}},"clientInfo":{"name":"c","version":"1"}}}`))
+	expectMessage(t, server, `"method":"initialize"`)
+	server.Send([]byte(`{"jsonrpc":"2.0","id":0,"result":{"protocolVersion":"2025-06-18","capabilities":{},"serverInfo":{"name":"fs-main","version":"1"}}}`))
+	expectMessage(t, client, `"serverInfo"`)
+
+	server.Send([]byte(`{"jsonrpc":"2.0","id":"r2","method":"roots/list"}`))
+	expectMessage(t, client, `"method":"roots/list"`)
+	client.Send([]byte(`{"jsonrpc":"2.0","id":"r2","result":{"roots":[{"uri":"file:///work/app"},{"uri":"file:///home/me/.ssh"}]}}`))
+	answer := nextMessage(t, server)
+	if !strings.Contains(string(answer), "file:///work/app") || strings.Contains(string(answer), ".ssh") {
+		t.Errorf("roots answer = %s", answer)
+	}
+	if st := r.Stats(); st.RootsRefused != 1 || st.RootsFiltered != 1 {
+		t.Errorf("roots refused = %d, filtered = %d, expected 1 and 1", st.RootsRefused, st.RootsFiltered)
+	}
+}
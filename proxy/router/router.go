@@ -16,9 +16,9 @@
 // # Security Pipeline
 //
 // Each message passes through three checks:
-//   1. Registry Guard: Schema validation
-//   2. State Monitor: Cycle detection, gas limits
-//   3. Cognitive Council: Consensus voting (for high-risk actions)
+//  1. Registry Guard: Schema validation
+//  2. State Monitor: Cycle detection, gas limits
+//  3. Cognitive Council: Consensus voting (for high-risk actions)
 //
 // # Usage
 //
@@ -34,50 +34,340 @@ package router
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/catalog"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/crash"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/degrade"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/guardrail"
 	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mask"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/policy"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/queue"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/resourcestore"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schema"
 	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sessionstate"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/shim"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
 	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
 )
 
 // Router manages MCP message routing with security checks.
 type Router struct {
-	// transport handles message I/O
+	// transport handles message I/O; with an upstream it faces the client
 	transport transport.Transport
 
+	// upstream carries messages to the MCP server; when nil, transport
+	// is the server connection and RouteMessage is driven by the caller
+	upstream transport.Transport
+
+	// pending correlates server responses with client requests, relayed
+	// client responses with server requests, and serverOnce starts the
+	// loop that reads them (upstream mode only)
+	pending    *pendingTable
+	relayed    *pendingTable
+	serverOnce sync.Once
+	serverErr  chan error
+
+	// turns orders client requests' writes to the server by ID
+	turns   map[string]*sendTurn
+	turnsMu sync.Mutex
+
 	// sentinel provides security checks
 	sentinel *sentinel.Client
 
 	// sessionID identifies the current session for state tracking
 	sessionID string
 
-	// callDepth tracks nested tool calls
-	callDepth atomic.Int32
+	// calls tracks the nesting of tool calls in flight and
+	// maxCallDepth bounds it (zero is unlimited)
+	calls        callStack
+	maxCallDepth int
+
+	// gasUsed tracks cumulative gas consumption against gasBudget (zero
+	// is unlimited) and gasNotice the last budget notice sent
+	gasUsed   atomic.Uint64
+	gasBudget uint64
+	gasNotice atomic.Int32
+
+	// contextBytes is the content delivered to the client, bounded by
+	// contextBudget (may be nil); contextNotice is set once the client
+	// was told the budget is exceeded
+	contextBytes  atomic.Uint64
+	contextBudget *ContextBudget
+	contextNotice atomic.Bool
 
-	// gasUsed tracks cumulative gas consumption
-	gasUsed atomic.Uint64
+	// gasModel prices tool calls (default table when unset)
+	gasModel atomic.Pointer[gasModelHolder]
 
 	// previousTools tracks tool call history for cycle detection
 	previousTools []string
 	toolsMu       sync.Mutex
 
 	// stats tracks routing statistics
-	stats Stats
+	stats counters
+
+	// councilMemo caches council verdicts (may be nil)
+	councilMemo *sentinel.CouncilMemo
+
+	// policyVersion is folded into council memoization keys
+	policyVersion string
+
+	// resourceStore serves resources/read from local storage (may be nil)
+	resourceStore *resourcestore.Store
+
+	// anomaly scores session signals for the kill-switch (may be nil)
+	anomaly *anomaly.Scorer
+
+	// incidentDir receives incident bundles on termination
+	incidentDir string
+
+	// terminated is set once the kill-switch trips
+	terminated atomic.Bool
+
+	// completionLimits bounds completion/complete traffic (may be nil)
+	completionLimits *CompletionLimits
+
+	// verified caches registry verifications for the fast path (may be nil)
+	verified *verifiedCalls
+
+	// decisions retains recent routing decisions for lookup by ID
+	decisions *decisionLog
+
+	// annotateDecisions adds decision IDs to successful results' _meta
+	annotateDecisions bool
+
+	// explainBlocks lists and answers the sentinel/why tool
+	explainBlocks bool
+
+	// distinctErrorCodes answers generic policy refusals with CodeBlocked
+	distinctErrorCodes bool
+
+	// ladder selects which checks run based on backend health (may be nil)
+	ladder *degrade.Ladder
+
+	// guard pins tool arguments before checks and forwarding (may be nil)
+	guard *guardrail.Guard
+
+	// isolation recovers check panics and disables faulty checks
+	isolation *checkIsolation
+
+	// contentPolicy classifies tool result content (may be nil)
+	contentPolicy *ContentPolicy
+
+	// ingress and egress connect the pipeline stages (nil when Run
+	// processes messages sequentially)
+	ingress *queue.Queue
+	egress  *queue.Queue
+
+	// started is when the router was created
+	started time.Time
+
+	// summaryMode controls the end-of-session summary
+	summaryMode SummaryMode
+	endOnce     sync.Once
+
+	// protocolShims enables protocol revision translation; the shim is
+	// established from the initialize exchange
+	protocolShims  bool
+	shimMu         sync.Mutex
+	clientRevision shim.Revision
+	shim           *shim.Shim
+
+	// schedule denies tool calls by time window (may be nil)
+	schedule *schedule.Scheduler
+
+	// rateLimit limits the rate of tool calls (may be nil)
+	rateLimit *ratelimit.Limiter
+
+	// eventSink receives per-message audit event batches (may be nil)
+	eventSink EventSink
+
+	// audit receives one record per message seen (may be nil)
+	audit audit.Sink
+
+	// auditPayloadBytes bounds the tool call payloads recorded in the
+	// audit trail (zero records none)
+	auditPayloadBytes int
+
+	// maxBatchSize bounds the messages in a client batch
+	maxBatchSize int
+
+	// repro captures server data that fails to parse or validate (may
+	// be nil); server is the server's identity from initialize, kept
+	// for bundles and catalog history
+	repro  *crash.ReproRecorder
+	server serverIdentity
+
+	// masker sanitizes server identity shown to the client (may be nil)
+	masker *mask.Masker
+
+	// uriSchemes pins permitted resource URI schemes (may be nil)
+	uriSchemes *URISchemePolicy
+
+	// resourceTemplates checks resource template expansions (may be nil)
+	resourceTemplates *resourceTemplates
+
+	// notifications restricts server notifications (may be nil) and
+	// subscriptions holds the client's resource subscriptions
+	notifications *NotificationPolicy
+	subscriptions subscriptions
+
+	// notificationHandler receives server notifications when the
+	// transport is the server connection, read ahead into inbox by
+	// notificationLoop (nil without OnNotification)
+	notificationHandler func([]byte)
+	inbox               chan serverMessage
+
+	// sampling mediates server sampling requests (may be nil)
+	sampling *samplingGate
+
+	// elicitation mediates server elicitation requests and roots
+	// decides which servers may list roots (either may be nil)
+	elicitation *elicitationGate
+	roots       *RootsPolicy
+
+	// resourceInspection checks resources/read results (may be nil)
+	resourceInspection *resourceInspector
+
+	// toolSanitizer scans tools/list results for poisoned tool
+	// metadata (may be nil)
+	toolSanitizer *toolSanitizer
+
+	// normalization neutralizes invisible Unicode and homoglyphs in
+	// client messages (may be nil)
+	normalization *Normalization
+
+	// loopGuard refuses initialize requests that have looped back to
+	// this proxy (may be nil)
+	loopGuard *LoopGuard
+
+	// responseInspection votes on server content before delivery (may be nil)
+	responseInspection *ResponseInspection
+
+	// middleware wraps every forwarded request/response exchange (may be nil)
+	middleware *middleware.Chain
+
+	// cancels cancels the routing context of requests the client
+	// cancels
+	cancels requestCancels
+
+	// stages is the tools/call stage order and userStages the
+	// registered stages by name
+	stages     []string
+	userStages map[string]middleware.Middleware
+
+	// upstreamTools resolves namespaced tool names (may be nil)
+	upstreamTools ToolResolver
+
+	// tofu holds trust-on-first-use approvals (may be nil) and
+	// tofuSession this session's fingerprints
+	tofu        *tofu.Store
+	tofuSession *tofuSession
+
+	// protection is what the client was told of degraded checks
+	protection protectionNotice
+
+	// catalog records the server's listings (may be nil) and
+	// catalogPages collects paginated ones until their last page
+	catalog      *catalog.Store
+	catalogPages catalogPages
+
+	// slo tracks service level objectives (may be nil)
+	slo *slo.Monitor
+
+	// chain configures cooperation with other sentinels (may be nil)
+	// and chainNoticed records that unconfigured chaining was logged
+	chain        *ChainConfig
+	chainNoticed atomic.Bool
+
+	// taint holds the session's tool result text for argument
+	// provenance (nil disables tracking)
+	taint *taintLog
+
+	// schemas caches the listed tools' input schemas for argument
+	// validation (nil with schemaValidation disables it)
+	schemaValidation *SchemaValidation
+	schemas          *schema.Set
+
+	// policy decides requests by operator rules (may be nil)
+	policy *policy.Engine
+
+	// receipts follows blocks with remediation notices and watches for
+	// retries that ignore them (nil disables read receipts)
+	receipts *receiptLog
+
+	// conformance scores the client's protocol conformance (nil
+	// disables scoring)
+	conformance *conformanceLog
+
+	// findings keeps evidence from earlier messages for council votes
+	findings findingLog
+
+	// tracer traces each message through the pipeline (may be nil)
+	tracer *tracing.Tracer
+
+	// toolPolicy allows or denies calls by tool name (may be nil)
+	toolPolicy *ToolPolicy
+
+	// partials collects progress output of tool calls for salvage on
+	// timeout (nil disables salvage)
+	partials *partialLog
+
+	// stateStore saves the session's security context under stateKey
+	// (may be nil) and pins the listed tools' fingerprints for it
+	stateStore sessionstate.Store
+	stateKey   string
+	pins       statePins
+
+	// resumeToken is issued at initialize to resume the session's
+	// state, saved under its digest (empty without resumption)
+	resumeToken string
+
+	// highRiskTools replaces the built-in high-risk tool set (nil
+	// uses isHighRiskTool, or only the policy when one is configured)
+	highRiskTools map[string]bool
+
+	// settingsMu guards toolPolicy and highRiskTools, which Reconfigure
+	// replaces while the session runs
+	settingsMu sync.RWMutex
+
+	// largeResultThreshold is the tools/call response size above which
+	// checks use an incremental scan (0 always decodes)
+	largeResultThreshold int
+
+	// requestTimeout bounds the wait for a server response (0 waits
+	// indefinitely) and orphanAfter reports requests unanswered that
+	// long (negative never)
+	requestTimeout time.Duration
+	orphanAfter    time.Duration
+
+	// pause is the operator pause in effect (nil when running)
+	pause       *pause
+	pauseMu     sync.Mutex
+	pauseQueued atomic.Int64
 
 	// forwardFunc sends messages to the MCP server
 	// Can be replaced for testing
 	forwardFunc func([]byte) ([]byte, error)
-}
 
-// Stats contains routing statistics.
-type Stats struct {
-	MessagesReceived atomic.Uint64
-	MessagesForwarded atomic.Uint64
-	MessagesBlocked  atomic.Uint64
-	Errors           atomic.Uint64
+	// notifyFunc sends notifications to the MCP server without waiting
+	// for a reply. Can be replaced for testing
+	notifyFunc func([]byte) error
 }
 
 // Config contains router configuration.
@@ -85,19 +375,325 @@ type Config struct {
 	// SessionID for state tracking (generated if empty)
 	SessionID string
 
-	// GasBudget is the maximum gas allowed per session
+	// GasBudget is the maximum gas allowed per session; tool calls it
+	// cannot cover are refused with CodeGasExhausted (zero is
+	// unlimited)
 	GasBudget uint64
 
-	// MaxCallDepth is the maximum nested call depth
+	// ContextBudget trims large results once the session's delivered
+	// content passes a threshold (nil never trims)
+	ContextBudget *ContextBudget
+
+	// MaxCallDepth is the maximum nested call depth; deeper tool calls
+	// are refused with CodeCallDepthExceeded (zero is unlimited)
 	MaxCallDepth int
+
+	// CouncilMemo caches council verdicts for repeated high-risk actions
+	// (nil disables memoization)
+	CouncilMemo *sentinel.CouncilMemo
+
+	// PolicyVersion identifies the active policy set; it is part of the
+	// council memoization key so a policy change invalidates verdicts
+	PolicyVersion string
+
+	// ResourceStore enables read-through caching of resources/read
+	// results (nil forwards every read to the server)
+	ResourceStore *resourcestore.Store
+
+	// Anomaly enables per-session anomaly scoring with automatic
+	// session termination (nil disables the kill-switch)
+	Anomaly *anomaly.Config
+
+	// IncidentDir receives an incident bundle when a session is
+	// terminated (empty disables bundle capture)
+	IncidentDir string
+
+	// CompletionLimits caps and filters completion/complete suggestions
+	// (nil passes completions through untouched)
+	CompletionLimits *CompletionLimits
+
+	// RegistryFastPath lets identical, previously verified tool calls
+	// skip registry re-validation (nil always re-validates)
+	RegistryFastPath *RegistryFastPath
+
+	// Clock ages registry fast path entries and read receipts (nil uses
+	// the system clock)
+	Clock clock.Clock
+
+	// DecisionLogSize is the number of recent decisions retained for
+	// lookup by ID (zero uses DefaultDecisionLogSize)
+	DecisionLogSize int
+
+	// RequestTimeout is how long a request forwarded to the server
+	// (NewWithTransports only) waits for its response before it is
+	// cancelled and answered with an error (zero waits indefinitely)
+	RequestTimeout time.Duration
+
+	// OrphanAfter is how long a request in either direction may go
+	// unanswered before it is counted, logged, and audited as orphaned
+	// (NewWithTransports only); server requests the client leaves
+	// unanswered are then forgotten (zero uses DefaultOrphanAfter,
+	// negative disables the check)
+	OrphanAfter time.Duration
+
+	// AnnotateDecisions adds the decision ID to successful results'
+	// _meta so clients can quote it when reporting problems
+	AnnotateDecisions bool
+
+	// DistinctErrorCodes answers policy refusals that would carry the
+	// generic codes -32600 and -32602 with CodeBlocked instead, so the
+	// code alone tells a refusal from a malformed request
+	DistinctErrorCodes bool
+
+	// ExplainBlocks adds the synthetic tool WhyToolName to tools/list
+	// results and answers its calls itself: given the decision ID of a
+	// blocked request, it returns which kind of check refused it, the
+	// reason, and what the user could change, so the model can explain
+	// a block instead of relaying an opaque error. Only the session's
+	// own decisions are looked up, and their details, arguments, and
+	// results are never returned; the reason is the one the client
+	// already received, stripped of control and invisible characters.
+	// The calls bypass the checks and are not charged gas
+	ExplainBlocks bool
+
+	// Degradation is the shared degradation ladder driven by sentinel
+	// backend health (nil always runs every check)
+	Degradation *degrade.Ladder
+
+	// ArgumentGuard pins tool arguments to fixed or constrained values,
+	// rewriting tools/call requests before they are checked and
+	// forwarded (nil forwards arguments unchanged)
+	ArgumentGuard *guardrail.Guard
+
+	// CheckPanics decides the outcome of a panicking check and when it
+	// is disabled (nil fails closed and never disables)
+	CheckPanics *PanicPolicy
+
+	// ContentPolicy classifies tools/call result content and blocks or
+	// flags matching kinds (nil skips classification)
+	ContentPolicy *ContentPolicy
+
+	// Pipeline runs receive, route, and send as concurrent stages
+	// joined by bounded queues (nil processes messages sequentially)
+	Pipeline *PipelineConfig
+
+	// SessionSummary selects whether a security summary is logged or
+	// sent to the client when the session ends (default SummaryOff)
+	SessionSummary SummaryMode
+
+	// ProtocolShims translates between differing client and server
+	// protocol revisions, stripping features the receiving side does
+	// not support (false passes traffic through untranslated)
+	ProtocolShims bool
+
+	// Schedule denies tool calls by time window and maintenance mode;
+	// it is usually shared across sessions (nil disables scheduling)
+	Schedule *schedule.Scheduler
+
+	// RateLimit limits the rate of tool calls globally, per session,
+	// and per tool; it is usually shared across sessions (nil disables
+	// rate limiting)
+	RateLimit *ratelimit.Limiter
+
+	// AuditEvents receives each message's audit events as one ordered
+	// batch once its decision is final (nil disables event collection)
+	AuditEvents EventSink
+
+	// Audit receives one record per message the router sees, routed or
+	// relayed (nil disables the audit trail)
+	Audit audit.Sink
+
+	// AuditPayloadBytes records up to this many bytes of each tool
+	// call's arguments and response in its audit record (zero records
+	// none); see audit.EncryptSink to keep them from operators
+	AuditPayloadBytes int
+
+	// GasModel prices tool calls against GasBudget (nil uses
+	// DefaultGasModel); replace it at runtime with SetGasModel
+	GasModel GasModel
+
+	// MaxBatchSize is the most messages a client batch may hold; larger
+	// batches are refused whole (zero uses DefaultMaxBatchSize)
+	MaxBatchSize int
+
+	// Repro captures reproduction bundles of server data that fails to
+	// parse or validate; it is usually shared across sessions (nil
+	// disables capture)
+	Repro *crash.ReproRecorder
+
+	// ServerMask rewrites serverInfo and tool descriptions presented to
+	// the client (nil passes them through unchanged)
+	ServerMask *mask.Masker
+
+	// URISchemes restricts the resource URI schemes clients may read or
+	// subscribe to and tool results may link or embed (nil allows any)
+	URISchemes *URISchemePolicy
+
+	// ResourceTemplates checks the variable values of resource URIs
+	// expanded from the server's resource templates (nil leaves them
+	// unchecked)
+	ResourceTemplates *ResourceTemplatePolicy
+
+	// Notifications restricts the notifications the server sends on its
+	// own; they are checked for spoofed proxy notices and refused
+	// resource URIs even without it (nil relays any method)
+	Notifications *NotificationPolicy
+
+	// Sampling screens the server's sampling/createMessage requests
+	// before they reach the client's model (nil relays them unchecked;
+	// NewWithTransports only, as other routers cannot relay them)
+	Sampling *SamplingPolicy
+
+	// Elicitation screens the server's elicitation/create requests and
+	// strips sensitive fields (nil relays them unchecked;
+	// NewWithTransports only)
+	Elicitation *ElicitationPolicy
+
+	// Roots decides which servers may list the client's roots and
+	// which roots they see (nil relays roots/list unchecked;
+	// NewWithTransports only)
+	Roots *RootsPolicy
+
+	// ResourceInspection checks resources/read results for their size
+	// and declared content types (nil delivers them unchecked)
+	ResourceInspection *ResourceInspection
+
+	// ToolSanitization scans tools/list results for instructions hidden
+	// in tool metadata (nil delivers them unchecked)
+	ToolSanitization *ToolSanitization
+
+	// Normalization neutralizes invisible Unicode and homoglyphs in
+	// client messages before they are checked (nil routes them as sent)
+	Normalization *Normalization
+
+	// LoopGuard refuses sessions whose server leads back to this proxy
+	// (nil forwards initialize requests unmarked)
+	LoopGuard *LoopGuard
+
+	// ResponseInspection submits tool result and resource text to the
+	// sentinel before it reaches the client (nil delivers it unchecked)
+	ResponseInspection *ResponseInspection
+
+	// Middleware wraps each request forwarded to the server and its
+	// response, e.g. a scanner.Scanner stage, and each server request
+	// and notification relayed to the client (nil forwards directly)
+	Middleware *middleware.Chain
+
+	// Stages registers middlewares in the tools/call pipeline, run
+	// among the built-in checks in StageOrder (nil runs only the
+	// checks)
+	Stages []Stage
+
+	// StageOrder orders the tools/call stages by name, built-in and
+	// registered; stages it leaves out run after it, built-in ones
+	// first (nil uses DefaultStageOrder, then Stages)
+	StageOrder []string
+
+	// UpstreamTools resolves the tool names of a server transport that
+	// fronts several servers, e.g. an upstream.Mux; decisions record
+	// the providing upstream and risk checks use the server's own tool
+	// name (nil takes names as given)
+	UpstreamTools ToolResolver
+
+	// TOFU requires a one-time approval of each tool fingerprint: tools
+	// awaiting approval are withheld from tools/list and their calls
+	// refused; the store is usually shared across sessions (nil
+	// disables TOFU)
+	TOFU *tofu.Store
+
+	// Catalog keeps the history of the server's tools, resources, and
+	// prompts listings for review; it is usually shared across sessions
+	// (nil disables the history)
+	Catalog *catalog.Store
+
+	// SLO receives each routed message's verdict and added latency for
+	// service level objective alerts; it is usually shared across
+	// sessions (nil disables SLO tracking)
+	SLO *slo.Monitor
+
+	// Policy allows, blocks, rate-limits, or requires a council vote
+	// for requests by operator rules, and prices tool calls unless
+	// GasModel is set; it replaces the built-in high-risk tool list
+	// and is usually shared across sessions (nil uses the built-in
+	// rules, policy.Builtin)
+	Policy *policy.Engine
+
+	// TaintTracking traces tool call arguments to earlier tool results
+	// and raises the risk of calls acting on copied URLs, commands, and
+	// paths (nil disables tracking)
+	TaintTracking *TaintTracking
+
+	// SchemaValidation refuses tool calls whose arguments do not match
+	// the inputSchema the server listed for the tool (nil disables)
+	SchemaValidation *SchemaValidation
+
+	// ReadReceipts follows blocked tool calls with a remediation notice
+	// and escalates sessions that keep retrying them (nil disables)
+	ReadReceipts *ReadReceipts
+
+	// Conformance scores the client's protocol conformance and holds
+	// persistently nonconforming clients to stricter policy or rejects
+	// them (nil disables)
+	Conformance *Conformance
+
+	// ToolPolicy allows or denies tool calls by name before any
+	// sentinel check (nil allows every tool); replace it at runtime
+	// with Reconfigure
+	ToolPolicy *ToolPolicy
+
+	// Chain lets this proxy cooperate with other sentinels in front of
+	// or behind it: it shares trace IDs, avoids charging gas twice, and
+	// optionally defers to checks a trusted sentinel already ran (nil
+	// only detects chaining)
+	Chain *ChainConfig
+
+	// HighRiskTools lists the server tool names that require a council
+	// vote, replacing the built-in list (nil uses the built-in list);
+	// replace it at runtime with Reconfigure
+	HighRiskTools []string
+
+	// LargeResultThreshold is the tools/call response size in bytes
+	// above which result checks use a bounded incremental scan instead
+	// of decoding the whole result (0 always decodes)
+	LargeResultThreshold int
+
+	// Tracer records a span per routed message with child spans for
+	// parsing, each check, and forwarding; it is usually shared across
+	// sessions (nil disables tracing)
+	Tracer *tracing.Tracer
+
+	// PartialResults delivers the progress output of a tools/call that
+	// times out, checked and marked as truncated, in the timeout error
+	// (NewWithTransports only; nil discards it)
+	PartialResults *PartialResults
+
+	// StateStore saves the session's tool history, gas used, tool
+	// pins, and kill-switch termination, and the router resumes them
+	// when created; it is usually shared across sessions (nil keeps
+	// state for the session only)
+	StateStore sessionstate.Store
+
+	// StateKey is the key state is saved under, stable across restarts
+	// (empty uses SessionID, which only resumes a reused session ID)
+	StateKey string
+
+	// Resumption keys the state by a resumption token instead of
+	// StateKey: the initialize result carries the token, and a client
+	// reconnecting over any transport presents it in its next
+	// initialize to resume the session (needs StateStore)
+	Resumption bool
 }
 
 // DefaultConfig returns sensible default configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		SessionID:    generateSessionID(),
-		GasBudget:    1000000,
-		MaxCallDepth: 10,
+		SessionID:        generateSessionID(),
+		GasBudget:        1000000,
+		MaxCallDepth:     10,
+		CompletionLimits: DefaultCompletionLimits(),
+		CheckPanics:      DefaultPanicPolicy(),
+
+		LargeResultThreshold: DefaultLargeResultThreshold,
 	}
 }
 
@@ -116,83 +712,592 @@ func New(t transport.Transport, s *sentinel.Client) *Router {
 // NewWithConfig creates a Router with custom configuration.
 func NewWithConfig(t transport.Transport, s *sentinel.Client, cfg *Config) *Router {
 	r := &Router{
-		transport:     t,
-		sentinel:      s,
-		sessionID:     cfg.SessionID,
-		previousTools: make([]string, 0, 100),
+		transport:         t,
+		sentinel:          s,
+		sessionID:         cfg.SessionID,
+		gasBudget:         cfg.GasBudget,
+		maxCallDepth:      cfg.MaxCallDepth,
+		previousTools:     make([]string, 0, 100),
+		serverErr:         make(chan error, 1),
+		councilMemo:       cfg.CouncilMemo,
+		policyVersion:     cfg.PolicyVersion,
+		resourceStore:     cfg.ResourceStore,
+		incidentDir:       cfg.IncidentDir,
+		completionLimits:  cfg.CompletionLimits,
+		decisions:         newDecisionLog(cfg.DecisionLogSize),
+		annotateDecisions: cfg.AnnotateDecisions,
+		explainBlocks:     cfg.ExplainBlocks,
+		ladder:            cfg.Degradation,
+		guard:             cfg.ArgumentGuard,
+		isolation:         newCheckIsolation(cfg.CheckPanics),
+		contentPolicy:     cfg.ContentPolicy,
+		started:           time.Now(),
+		summaryMode:       cfg.SessionSummary,
+		protocolShims:     cfg.ProtocolShims,
+		schedule:          cfg.Schedule,
+		rateLimit:         cfg.RateLimit,
+		eventSink:         cfg.AuditEvents,
+		audit:             cfg.Audit,
+		auditPayloadBytes: cfg.AuditPayloadBytes,
+		repro:             cfg.Repro,
+		maxBatchSize:      cfg.MaxBatchSize,
+		masker:            cfg.ServerMask,
+		uriSchemes:        cfg.URISchemes,
+		middleware:        cfg.Middleware,
+		upstreamTools:     cfg.UpstreamTools,
+		tofu:              cfg.TOFU,
+		catalog:           cfg.Catalog,
+		toolPolicy:        cfg.ToolPolicy,
+		slo:               cfg.SLO,
+		chain:             cfg.Chain,
+		policy:            cfg.Policy,
+		tracer:            cfg.Tracer,
+
+		largeResultThreshold: cfg.LargeResultThreshold,
+		requestTimeout:       cfg.RequestTimeout,
+		orphanAfter:          cfg.OrphanAfter,
+		distinctErrorCodes:   cfg.DistinctErrorCodes,
+	}
+	if cfg.GasModel != nil {
+		r.SetGasModel(cfg.GasModel)
+	} else if cfg.Policy != nil {
+		r.SetGasModel(cfg.Policy)
+	}
+	if cfg.ResponseInspection != nil {
+		ri := *cfg.ResponseInspection
+		if ri.Action == "" {
+			ri.Action = ResponseBlock
+		}
+		if ri.RiskScore == 0 {
+			ri.RiskScore = 0.5
+		}
+		if ri.MaxItemBytes <= 0 {
+			ri.MaxItemBytes = 64 << 10
+		}
+		r.responseInspection = &ri
+	}
+	if cfg.TOFU != nil {
+		r.tofuSession = newTOFUSession(r, cfg.TOFU)
+	}
+	if cfg.ResourceTemplates != nil {
+		r.resourceTemplates = newResourceTemplates(cfg.ResourceTemplates)
+	}
+	if cfg.ContextBudget != nil {
+		cb := *cfg.ContextBudget
+		if cb.MaxResultBytes <= 0 {
+			cb.MaxResultBytes = DefaultContextResultBytes
+		}
+		r.contextBudget = &cb
+	}
+	r.notifications = cfg.Notifications
+	if cfg.Sampling != nil {
+		r.sampling = newSamplingGate(cfg.Sampling)
+	}
+	if cfg.Elicitation != nil {
+		r.elicitation = newElicitationGate(cfg.Elicitation)
+	}
+	r.roots = cfg.Roots
+	if cfg.ResourceInspection != nil {
+		r.resourceInspection = newResourceInspector(cfg.ResourceInspection)
+	}
+	if cfg.ToolSanitization != nil {
+		r.toolSanitizer = newToolSanitizer(cfg.ToolSanitization)
+	}
+	r.normalization = cfg.Normalization
+	r.loopGuard = cfg.LoopGuard
+	if cfg.TaintTracking != nil {
+		r.taint = newTaintLog(cfg.TaintTracking)
+	}
+	if cfg.SchemaValidation != nil {
+		r.schemaValidation, r.schemas = cfg.SchemaValidation, schema.NewSet()
+	}
+	if cfg.ReadReceipts != nil {
+		r.receipts = newReceiptLog(cfg.ReadReceipts, cfg.Clock)
+	}
+	if cfg.Conformance != nil {
+		r.conformance = newConformanceLog(cfg.Conformance)
+	}
+	if r.maxBatchSize <= 0 {
+		r.maxBatchSize = DefaultMaxBatchSize
+	}
+	if r.orphanAfter == 0 {
+		r.orphanAfter = DefaultOrphanAfter
+	}
+	r.highRiskTools = toolSet(cfg.HighRiskTools)
+	if cfg.Anomaly != nil {
+		r.anomaly = anomaly.NewScorer(cfg.Anomaly)
+	}
+	if cfg.Pipeline != nil {
+		r.ingress = queue.New("ingress", cfg.Pipeline.IngressDepth)
+		r.egress = queue.New("egress", cfg.Pipeline.EgressDepth)
+	}
+	if cfg.PartialResults != nil {
+		r.partials = newPartialLog(cfg.PartialResults)
+	}
+	if cfg.StateStore != nil {
+		r.stateStore, r.stateKey = cfg.StateStore, cfg.StateKey
+		if cfg.Resumption {
+			// Only a client presenting its token resumes anything
+			r.resumeToken = newResumptionToken()
+			r.stateKey = resumptionKey(r.resumeToken)
+		} else {
+			if r.stateKey == "" {
+				r.stateKey = r.sessionID
+			}
+			r.restoreState()
+		}
+	}
+	r.stages = stageOrder(cfg.StageOrder, cfg.Stages)
+	for _, s := range cfg.Stages {
+		if r.userStages == nil {
+			r.userStages = make(map[string]middleware.Middleware)
+		}
+		r.userStages[s.Name] = s.Middleware
+	}
+	if cfg.RegistryFastPath != nil {
+		r.verified = newVerifiedCalls(cfg.RegistryFastPath, cfg.Clock)
+		log.Printf("router: registry fast path enabled; verified calls skip registry re-validation until the next tools/list")
 	}
 	// Default forward function (can be replaced for testing)
 	r.forwardFunc = r.defaultForward
+	r.notifyFunc = r.transport.Send
 	return r
 }
 
 // RouteMessage routes a single JSON-RPC message through security checks.
 //
 // This is the main entry point for message processing. It:
-//   1. Parses the message as JSON-RPC
-//   2. Runs security checks for tool calls
-//   3. Forwards allowed messages or returns error responses
+//  1. Parses the message as JSON-RPC
+//  2. Runs security checks for tool calls
+//  3. Forwards allowed messages or returns error responses
+//
+// A batch (a JSON array of messages) is routed element by element and
+// answered with a batch of the responses, in order.
 //
 // # Arguments
-//   - data: Raw JSON-RPC message bytes
+//   - data: Raw JSON-RPC message or batch bytes
 //
 // # Returns
-//   - Response bytes (forwarded response or error)
-//   - Error if processing fails
+//   - Response bytes (forwarded response or error); nil for
+//     notifications, which are forwarded without awaiting a reply
+//   - Error if forwarding fails; for requests the response is then an
+//     error reply that should still be sent to the client
 //
 // # Security Notes
 //
 // All tool call messages (tools/call) are checked by sentinel.
 // Non-tool messages are forwarded without security checks.
 func (r *Router) RouteMessage(data []byte) ([]byte, error) {
+	return r.RouteMessageContext(context.Background(), data)
+}
+
+// RouteMessageContext is RouteMessage within ctx. With a Tracer, the
+// message is traced as a "route" span, a child of the span in ctx if
+// any, and ctx is passed on to the sentinel checks.
+func (r *Router) RouteMessageContext(ctx context.Context, data []byte) ([]byte, error) {
+	r.stats.BytesFromClient.Add(uint64(len(data)))
+	response, err := r.routeMessage(ctx, data)
+	r.stats.BytesToClient.Add(uint64(len(response)))
+	return response, err
+}
+
+// routeMessage routes one message or batch for RouteMessageContext.
+func (r *Router) routeMessage(ctx context.Context, data []byte) ([]byte, error) {
+	if jsonrpc.IsBatch(data) {
+		return r.routeBatch(ctx, data)
+	}
 	r.stats.MessagesReceived.Add(1)
 
+	d := r.newDecision()
+	defer r.finish(d)
+	d.ctx, d.span = r.tracer.Start(ctx, "route")
+
 	// Parse JSON-RPC message
+	_, parse := d.startSpan("parse")
 	msg, err := jsonrpc.Parse(data)
+	parse.SetError(err)
+	parse.End()
 	if err != nil {
 		r.stats.Errors.Add(1)
-		return r.errorResponse(nil, jsonrpc.ParseError, "Parse error", err.Error())
+		return r.errorResponse(d, VerdictError, jsonrpc.NullID(), jsonrpc.ParseError, "Parse error", err.Error())
+	}
+	d.Method = msg.Method
+	if r.normalization != nil {
+		out, refused := r.normalizeRequest(d, msg, data)
+		if refused {
+			return out, nil
+		}
+		data = out
+	}
+	switch {
+	case msg.Type() == jsonrpc.TypeRequest:
+		var release func()
+		d.ctx, release = r.cancellable(d.ctx, msg.ID)
+		defer release()
+	case msg.Method == "notifications/cancelled":
+		r.cancelRequest(msg.Params)
+	}
+	r.readChain(d, msg)
+	d.event(EventReceived, map[string]interface{}{"method": msg.Method})
+	if r.resumeToken != "" && msg.Method == "initialize" && msg.Type() == jsonrpc.TypeRequest {
+		data = r.resumeSession(d, msg, data)
+	}
+	if r.loopGuard != nil && msg.Method == "initialize" && msg.Type() == jsonrpc.TypeRequest {
+		out, refused := r.checkLoop(d, msg, data)
+		if refused {
+			return out, nil
+		}
+		data = out
+	}
+
+	// A terminated session accepts nothing further
+	if r.terminated.Load() {
+		r.stats.MessagesBlocked.Add(1)
+		return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Session terminated", "session terminated by anomaly kill-switch")
+	}
+	if msg.Type() == jsonrpc.TypeRequest || msg.Method == "notifications/initialized" {
+		r.announceProtection(msg.Method)
+	}
+	if r.explainBlocks && msg.Method == "tools/call" && msg.Type() == jsonrpc.TypeRequest &&
+		jsonrpc.ExtractToolName(msg) == WhyToolName {
+		return r.answerWhy(d, msg)
+	}
+
+	if r.conformance != nil {
+		if reply, refused := r.checkConformance(d, msg); refused {
+			return reply, nil
+		}
+	}
+
+	if r.receipts != nil && msg.Method == "tools/call" && msg.Type() == jsonrpc.TypeRequest {
+		d.Tool = jsonrpc.ExtractToolName(msg)
+		if reply, blocked := r.checkReceipt(d, msg); blocked {
+			return reply, nil
+		}
+	}
+
+	if r.policy != nil && msg.Type() == jsonrpc.TypeRequest {
+		out, blocked := r.checkPolicy(d, msg, data)
+		if blocked {
+			return out, nil
+		}
+		data = out
 	}
 
 	// Only check tool calls
 	if msg.Method == "tools/call" {
-		result, err := r.checkToolCall(msg)
-		if err != nil {
-			r.stats.Errors.Add(1)
-			return r.errorResponse(msg.ID, jsonrpc.InternalError, "Security check failed", err.Error())
+		d.Tool = jsonrpc.ExtractToolName(msg)
+		r.stats.ToolCalls.Add(1)
+		if r.audit != nil && r.auditPayloadBytes > 0 {
+			d.auditArguments = payloadSnippet(toolArguments(msg), r.auditPayloadBytes)
+		}
+
+		if reason := r.holdIfPaused(d); reason != "" {
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(d, VerdictBlocked, msg.ID, CodePaused, "Session paused", reason)
 		}
+		if r.terminated.Load() {
+			// Terminated while the call was held
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Session terminated", "session terminated by anomaly kill-switch")
+		}
+
+		if r.stateStore != nil {
+			defer r.saveState()
+		}
+		defer r.calls.leave(d.ID)
+		// The checks run as stages in the configured order
+		return r.runStages(d, msg, data, func(data []byte) ([]byte, error) {
+			return r.deliver(d, msg, data)
+		})
+	}
+
+	// Bound completion arguments before they reach the server
+	if msg.Method == "completion/complete" && r.completionLimits != nil {
+		result, _ := r.runCheck(d, CheckCompletion, func() (*sentinel.CheckResult, error) {
+			reason := r.checkCompletionRequest(msg)
+			return &sentinel.CheckResult{Allowed: reason == "", Reason: reason}, nil
+		})
+		if !result.Allowed {
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidParams, "Blocked by security", result.Reason)
+		}
+	}
+
+	// Refuse resources whose scheme is not pinned
+	if (msg.Method == "resources/read" || msg.Method == "resources/subscribe") && r.uriSchemes != nil {
+		result, _ := r.runCheck(d, CheckURIScheme, func() (*sentinel.CheckResult, error) {
+			reason := r.checkResourceRequest(msg)
+			return &sentinel.CheckResult{Allowed: reason == "", Reason: reason}, nil
+		})
+		if !result.Allowed {
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidParams, "Blocked by security", result.Reason)
+		}
+	}
+
+	// Refuse template expansions that escape the template
+	if (msg.Method == "resources/read" || msg.Method == "resources/subscribe") && r.resourceTemplates != nil {
+		result, _ := r.runCheck(d, CheckResourceTemplate, func() (*sentinel.CheckResult, error) {
+			reason := r.checkTemplateRequest(msg)
+			return &sentinel.CheckResult{Allowed: reason == "", Reason: reason}, nil
+		})
 		if !result.Allowed {
 			r.stats.MessagesBlocked.Add(1)
-			return r.errorResponse(msg.ID, jsonrpc.InvalidRequest, "Blocked by security", result.Reason)
+			return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidParams, "Blocked by security", result.Reason)
 		}
 	}
 
-	// Forward message to server
-	response, err := r.forwardFunc(data)
+	return r.deliver(d, msg, data)
+}
+
+// deliver forwards a message that passed its checks, or answers it
+// locally, and checks the response.
+func (r *Router) deliver(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, error) {
+	if err := r.commitDowngrades(d); err != nil {
+		r.stats.Errors.Add(1)
+		return r.errorResponse(d, VerdictError, msg.ID, jsonrpc.InternalError, "Downgrade failed", err.Error())
+	}
+	var err error
+	d.Verdict = VerdictAllowed
+	d.event(EventVerdict, map[string]interface{}{"verdict": VerdictAllowed, "reason": d.Reason})
+
+	if r.protocolShims {
+		reply, err := r.shimRequest(msg)
+		if err != nil {
+			r.stats.Errors.Add(1)
+			return r.errorResponse(d, VerdictError, msg.ID, jsonrpc.InternalError, "Protocol shim failed", err.Error())
+		}
+		if reply != nil {
+			d.Reason = "answered by protocol shim"
+			d.event(EventForwarded, map[string]interface{}{"local": "protocol shim"})
+			return jsonrpc.Serialize(reply)
+		}
+	}
+
+	if r.chain != nil {
+		data = r.writeChain(d, msg, data)
+	}
+
+	// Notifications expect no reply; forwarding one as a request would
+	// consume the server's next unrelated message as its response
+	if msg.Type() == jsonrpc.TypeNotification {
+		if err := r.notifyFunc(data); err != nil {
+			r.stats.Errors.Add(1)
+			d.Verdict, d.Reason = VerdictError, err.Error()
+			d.event(EventFailed, map[string]interface{}{"error": err.Error()})
+			return nil, fmt.Errorf("router: forward failed: %w", err)
+		}
+		r.stats.MessagesForwarded.Add(1)
+		d.event(EventForwarded, nil)
+		return nil, nil
+	}
+
+	var response []byte
+	if msg.Method == "resources/read" && r.resourceStore != nil {
+		// Serve resource reads through the local store when enabled
+		response, err = r.readThrough(d, msg, data)
+	} else {
+		response, err = r.forward(d, msg, data)
+	}
+	var salvaged *partialTimeout
+	if errors.As(err, &salvaged) && msg.Method == "tools/call" {
+		// Deliver what the server produced, once checked like a result
+		d.Reason, d.partial = err.Error(), true
+		response, err = salvageResult(msg.ID, salvaged.output, fmt.Sprintf("the server did not finish within %v", r.requestTimeout))
+	}
+	if errors.Is(err, ErrRequestTimeout) {
+		reply, _ := r.errorResponse(d, VerdictError, msg.ID, CodeRequestTimeout, "Request timed out", err.Error())
+		return reply, err
+	}
+	if err != nil {
+		// Answer the client so it is not left waiting on this ID
+		reply, _ := r.errorResponse(d, VerdictError, msg.ID, CodeUpstreamFailed, "Upstream unavailable", err.Error())
+		return reply, err
+	}
+	d.event(EventForwarded, nil)
+	if (r.repro != nil || r.catalog != nil || r.stateStore != nil || r.roots != nil || r.resourceInspection != nil) && msg.Method == "initialize" {
+		r.noteServer(response)
+	}
+	response = r.chainResponse(d, response)
+	if r.catalog != nil && catalogKinds[msg.Method] != "" {
+		r.recordCatalog(msg, response)
+	}
+
+	if r.schemas != nil && msg.Method == "tools/list" {
+		r.recordSchemas(response)
+	}
+	if r.resourceTemplates != nil && msg.Method == "resources/templates/list" {
+		r.recordTemplates(response)
+	}
+	if msg.Method == "resources/subscribe" || msg.Method == "resources/unsubscribe" {
+		r.recordSubscription(msg, response)
+	}
+	if r.stateStore != nil && msg.Method == "tools/list" {
+		r.pinTools(d, response)
+	}
+
+	if r.tofu != nil && (msg.Method == "initialize" || msg.Method == "tools/list") {
+		response = r.applyTOFU(d, msg, response)
+	}
+	if r.protocolShims {
+		response = r.shimResponse(msg, response)
+	}
+	if r.masker != nil {
+		response = r.maskResponse(msg, response)
+	}
+	if r.resumeToken != "" && msg.Method == "initialize" {
+		response = r.issueResumption(response)
+	}
+
+	switch msg.Method {
+	case "tools/call":
+		r.settleGas(d, response)
+		if r.uriSchemes == nil && r.responseInspection == nil && r.contentPolicy == nil && r.taint == nil {
+			break
+		}
+		result, scanned := r.toolResult(d, response)
+		if r.uriSchemes != nil {
+			if reason := r.checkResultResources(result); reason != "" {
+				r.stats.MessagesBlocked.Add(1)
+				return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Blocked by security", reason)
+			}
+		}
+		if r.responseInspection != nil {
+			if reply, blocked := r.applyResponseInspection(d, msg, &response, scanned); blocked {
+				return reply, nil
+			}
+			if _, sanitized := d.Details["response_rejected"]; sanitized {
+				// Classify what will be delivered, not what was removed
+				result, _ = r.toolResult(d, response)
+			}
+		}
+		if r.contentPolicy != nil {
+			if reason := r.classifyToolResult(d, result); reason != "" {
+				r.stats.MessagesBlocked.Add(1)
+				return r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidRequest, "Blocked by security", reason)
+			}
+		}
+		if r.taint != nil && result != nil {
+			// Only what the client will see can flow into later calls
+			r.taint.record(d.Tool, d.ID, result)
+		}
+	case "resources/read":
+		if r.resourceInspection != nil {
+			if reply, blocked := r.applyResourceInspection(d, msg, response); blocked {
+				return reply, nil
+			}
+		}
+		if r.responseInspection != nil {
+			if reply, blocked := r.applyResponseInspection(d, msg, &response, nil); blocked {
+				return reply, nil
+			}
+		}
+	case "completion/complete":
+		if r.completionLimits != nil {
+			response = r.sanitizeCompletion(response)
+		}
+	case "tools/list":
+		// A new listing is a new registry pin
+		r.InvalidateRegistryFastPath()
+		if r.toolSanitizer != nil {
+			response = r.sanitizeTools(d, response)
+		}
+		if r.normalization != nil {
+			response = r.normalizeListing(d, response)
+		}
+		if r.explainBlocks {
+			response = listWhyTool(msg, response)
+		}
+	}
+
+	if msg.Method == "tools/call" || msg.Method == "resources/read" {
+		response = r.chargeContext(d, msg, response)
+	}
+	if msg.Method == "tools/call" && r.audit != nil && r.auditPayloadBytes > 0 {
+		d.auditResult = payloadSnippet(response, r.auditPayloadBytes)
+	}
+	if d.partial {
+		return r.partialResponse(d, msg.ID, response)
+	}
+	if r.annotateDecisions {
+		response = annotateDecision(response, d.ID)
+	}
+	return response, nil
+}
+
+// forward sends a message to the server and returns its response,
+// adding the time spent waiting on the server to d.
+func (r *Router) forward(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, error) {
+	var response []byte
+	var err error
+	send := func(_ *middleware.MessageContext, data []byte) ([]byte, error) {
+		_, span := d.startSpan("forward")
+		start := time.Now()
+		defer func() { d.upstream += time.Since(start) }()
+		response, err := r.forwardFunc(data)
+		span.SetError(err)
+		span.End()
+		return response, err
+	}
+	if r.middleware != nil {
+		response, err = r.middleware.Execute(r.messageContext(d.ctx, msg, middleware.ClientToServer), data, send)
+	} else {
+		response, err = send(nil, data)
+	}
 	if err != nil {
 		r.stats.Errors.Add(1)
 		return nil, fmt.Errorf("router: forward failed: %w", err)
 	}
-
 	r.stats.MessagesForwarded.Add(1)
 	return response, nil
 }
 
 // checkToolCall runs security checks for a tool call message.
-func (r *Router) checkToolCall(msg *jsonrpc.Message) (*sentinel.CheckResult, error) {
+func (r *Router) checkToolCall(d *Decision, msg *jsonrpc.Message) (*sentinel.CheckResult, error) {
 	toolName := jsonrpc.ExtractToolName(msg)
 
-	// Registry check
-	registryReq := &sentinel.RegistryCheckRequest{
-		ToolName: toolName,
-		Params:   msg.Params,
+	level := r.DegradationLevel()
+	switch level {
+	case degrade.LevelFailsafe:
+		return r.failsafeResult(), nil
+	case degrade.LevelNativeOnly:
+		r.chargeGas(d, msg)
+		return &sentinel.CheckResult{
+			Allowed: true,
+			Reason:  "degraded: FFI checks skipped",
+			Details: map[string]interface{}{"degradation_level": level.String()},
+		}, nil
 	}
-	result, err := r.sentinel.CheckRegistry(registryReq)
-	if err != nil {
-		return nil, err
-	}
-	if !result.Allowed {
-		return result, nil
+
+	// Registry check, unless an identical call was verified since the
+	// last registry pin and the fast path is enabled
+	var result *sentinel.CheckResult
+	var err error
+	registrySkipped := r.verified != nil && r.verified.verified(toolName, msg.Params)
+	if registrySkipped {
+		// Verified by an earlier registry check
+		r.stats.RegistrySkipped.Add(1)
+		d.ran(CheckRegistry)
+	} else {
+		registryReq := &sentinel.RegistryCheckRequest{
+			ToolName: toolName,
+			Params:   msg.Params,
+		}
+		result, err = r.runCheck(d, CheckRegistry, func() (*sentinel.CheckResult, error) {
+			return r.sentinel.CheckRegistry(d.ctx, registryReq)
+		})
+		r.reportBackend(err)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Allowed {
+			return result, nil
+		}
+		// A fail-open isolated check verified nothing
+		if r.verified != nil && result.Details["isolated_check"] == nil {
+			r.verified.remember(toolName, msg.Params)
+		}
 	}
 
 	// State check
@@ -205,11 +1310,14 @@ func (r *Router) checkToolCall(msg *jsonrpc.Message) (*sentinel.CheckResult, err
 	stateReq := &sentinel.StateCheckRequest{
 		SessionID:     r.sessionID,
 		ToolName:      toolName,
-		CallDepth:     int(r.callDepth.Load()),
+		CallDepth:     d.depth,
 		GasUsed:       r.gasUsed.Load(),
 		PreviousTools: prevTools,
 	}
-	result, err = r.sentinel.CheckState(stateReq)
+	result, err = r.runCheck(d, CheckState, func() (*sentinel.CheckResult, error) {
+		return r.sentinel.CheckState(d.ctx, stateReq)
+	})
+	r.reportBackend(err)
 	if err != nil {
 		return nil, err
 	}
@@ -217,36 +1325,111 @@ func (r *Router) checkToolCall(msg *jsonrpc.Message) (*sentinel.CheckResult, err
 		return result, nil
 	}
 
-	// Council check for high-risk tools
-	if isHighRiskTool(toolName) {
+	// Council check for high-risk tools, unless degraded past it
+	if r.needsCouncil(d, toolName) && level < degrade.LevelSkipCouncil {
 		councilReq := &sentinel.CouncilVoteRequest{
 			Action:    fmt.Sprintf("Execute tool: %s", toolName),
 			ToolName:  toolName,
 			RiskScore: 0.7, // High risk threshold
 		}
-		result, err = r.sentinel.VoteCouncil(councilReq)
+		if r.taint != nil {
+			r.taintCouncil(d, councilReq)
+		}
+		r.evidenceCouncil(d, councilReq)
+		result, err = r.runCheck(d, CheckCouncil, func() (*sentinel.CheckResult, error) {
+			return r.voteCouncil(d.ctx, councilReq, msg.Params)
+		})
+		r.reportBackend(err)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Update gas usage
-	r.gasUsed.Add(estimateGas(toolName))
+	r.chargeGas(d, msg)
 
+	if registrySkipped {
+		result = withDetail(result, "registry_skipped", "verified-call fast path")
+	}
+	if level != degrade.LevelFull {
+		result = withDetail(result, "degradation_level", level.String())
+	}
+	return result, nil
+}
+
+// withDetail returns a copy of result with an additional detail entry.
+func withDetail(result *sentinel.CheckResult, key string, value interface{}) *sentinel.CheckResult {
+	details := make(map[string]interface{}, len(result.Details)+1)
+	for k, v := range result.Details {
+		details[k] = v
+	}
+	details[key] = value
+	return &sentinel.CheckResult{
+		Allowed: result.Allowed,
+		Reason:  result.Reason,
+		Details: details,
+	}
+}
+
+// voteCouncil submits a council vote, consulting the memo cache first.
+func (r *Router) voteCouncil(ctx context.Context, req *sentinel.CouncilVoteRequest, params json.RawMessage) (*sentinel.CheckResult, error) {
+	if r.councilMemo == nil || r.councilMemo.Bypass(req) {
+		return r.sentinel.VoteCouncil(ctx, req)
+	}
+
+	key := r.councilMemo.Key(req.ToolName, params, req.RiskScore, r.policyVersion)
+	if cached, ok := r.councilMemo.Get(key); ok {
+		tracing.FromContext(ctx).SetAttribute("sentinel.memo_hit", true)
+		return cached, nil
+	}
+
+	result, err := r.sentinel.VoteCouncil(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	r.councilMemo.Put(key, result)
 	return result, nil
 }
 
 // defaultForward sends a message through the transport and reads response.
 func (r *Router) defaultForward(data []byte) ([]byte, error) {
+	if r.upstream != nil {
+		return r.exchange(data)
+	}
 	if err := r.transport.Send(data); err != nil {
 		return nil, err
 	}
-	return r.transport.Receive()
+	return r.receiveResponse(data)
 }
 
-// errorResponse creates a JSON-RPC error response.
-func (r *Router) errorResponse(id json.RawMessage, code int, message, data string) ([]byte, error) {
-	resp, err := jsonrpc.NewErrorResponse(id, code, message, data)
+// errorResponse creates a JSON-RPC error response and records the
+// verdict and reason on the decision.
+func (r *Router) errorResponse(d *Decision, verdict Verdict, id json.RawMessage, code int, message, reason string) ([]byte, error) {
+	return r.errorResponseData(d, verdict, id, code, message, &ErrorData{Reason: reason})
+}
+
+// errorResponseData is errorResponse for error data beyond the reason,
+// which is data.Reason.
+func (r *Router) errorResponseData(d *Decision, verdict Verdict, id json.RawMessage, code int, message string, data *ErrorData) ([]byte, error) {
+	reason := data.Reason
+	d.Verdict, d.Reason, d.code = verdict, reason, code
+	d.event(EventVerdict, map[string]interface{}{"verdict": verdict, "reason": reason})
+	if verdict == VerdictBlocked {
+		d.event(EventBlocked, nil)
+	} else {
+		d.event(EventFailed, nil)
+	}
+	if verdict == VerdictBlocked && d.retryKey != "" {
+		if r.receipts != nil {
+			r.receiptBlocked(d, id, code, reason)
+		}
+		if r.conformance != nil && code != CodePaused && code != CodeNonconforming {
+			r.conformance.blocked(d.retryKey)
+		}
+	}
+	data.DecisionID = d.ID
+	data.Failure, data.Retry = classifyError(verdict, code, data)
+	resp, err := jsonrpc.NewErrorResponse(id, r.blockCode(verdict, code), message, data)
 	if err != nil {
 		return nil, err
 	}
@@ -256,8 +1439,19 @@ func (r *Router) errorResponse(id json.RawMessage, code int, message, data strin
 // Run starts the router's message processing loop.
 //
 // It reads messages from the transport, routes them, and sends responses.
-// Run blocks until the context is cancelled or an error occurs.
+// With a PipelineConfig the three steps run as concurrent stages joined
+// by bounded queues. Routers created with NewWithTransports instead
+// carry traffic in both directions concurrently (PipelineConfig does not
+// apply). Run blocks until the context is cancelled or an error occurs.
 func (r *Router) Run(ctx context.Context) error {
+	defer r.EndSession()
+	defer r.endPause(true)
+	if r.upstream != nil {
+		return r.runBidirectional(ctx)
+	}
+	if r.ingress != nil {
+		return r.runPipeline(ctx)
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -271,64 +1465,48 @@ func (r *Router) Run(ctx context.Context) error {
 			return fmt.Errorf("router: receive failed: %w", err)
 		}
 
-		// Route message
-		response, err := r.RouteMessage(data)
+		// Route message; notifications produce no response
+		response, err := r.RouteMessageContext(ctx, data)
 		if err != nil {
-			// Log error but continue processing
+			log.Printf("router: session %s: %v", r.sessionID, err)
+		}
+		if response == nil {
 			continue
 		}
 
 		// Send response back to client
-		if err := r.transport.Send(response); err != nil {
+		if err := r.send(response); err != nil {
 			return fmt.Errorf("router: send failed: %w", err)
 		}
 	}
 }
 
-// Stats returns the current routing statistics.
-func (r *Router) Stats() Stats {
-	return Stats{
-		MessagesReceived:  atomic.Uint64{},
-		MessagesForwarded: atomic.Uint64{},
-		MessagesBlocked:   atomic.Uint64{},
-		Errors:            atomic.Uint64{},
-	}
-}
-
-// GetStats returns a snapshot of current statistics.
-func (r *Router) GetStats() (received, forwarded, blocked, errors uint64) {
-	return r.stats.MessagesReceived.Load(),
-		r.stats.MessagesForwarded.Load(),
-		r.stats.MessagesBlocked.Load(),
-		r.stats.Errors.Load()
+// isHighRiskTool returns true for tools the built-in policy sends to a
+// council vote.
+func isHighRiskTool(name string) bool {
+	verdict := builtinPolicy.Evaluate(policy.Request{Method: "tools/call", Tool: name})
+	return verdict.Action == policy.ActionCouncil
 }
 
-// isHighRiskTool returns true for tools that require council voting.
-func isHighRiskTool(name string) bool {
-	highRiskTools := map[string]bool{
-		"execute_command": true,
-		"write_file":      true,
-		"delete_file":     true,
-		"run_script":      true,
-		"sudo":            true,
-		"shell":           true,
-	}
-	return highRiskTools[name]
+// isHighRisk reports whether a server tool requires a council vote
+// outside any policy verdict.
+func (r *Router) isHighRisk(name string) bool {
+	r.settingsMu.RLock()
+	highRisk := r.highRiskTools
+	r.settingsMu.RUnlock()
+	switch {
+	case highRisk != nil:
+		return highRisk[name]
+	case r.policy != nil:
+		// A configured policy replaces the built-in list
+		return false
+	}
+	return isHighRiskTool(name)
 }
 
-// estimateGas returns an estimated gas cost for a tool.
+// estimateGas returns the default model's gas cost for a tool.
 func estimateGas(name string) uint64 {
-	// Base gas costs by category
-	gasCosts := map[string]uint64{
-		"read_file":       100,
-		"write_file":      500,
-		"execute_command": 1000,
-		"list_directory":  50,
-	}
-	if cost, ok := gasCosts[name]; ok {
-		return cost
-	}
-	return 200 // Default cost
+	return defaultGas.Cost(name, nil, 0)
 }
 
 // generateSessionID creates a unique session identifier.
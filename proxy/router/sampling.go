@@ -0,0 +1,390 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/scanner"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// CheckSampling names the council vote on a sampling request.
+const CheckSampling = "sampling"
+
+// CodeSamplingRefused is the JSON-RPC error code returned to a server
+// whose sampling/createMessage request the proxy refused. It is in the
+// implementation-defined server error range.
+const CodeSamplingRefused = -32012
+
+// ErrNoSamplingRequest is returned by DecideSampling for a request that
+// is not awaiting approval.
+var ErrNoSamplingRequest = errors.New("router: no sampling request awaiting approval")
+
+// DefaultSamplingApprovalTimeout is how long a sampling request waits
+// for an operator when its policy sets no timeout.
+const DefaultSamplingApprovalTimeout = 2 * time.Minute
+
+// samplingPreviewBytes bounds the text shown to an approving operator.
+const samplingPreviewBytes = 1024
+
+// SamplingAction is what happens to a sampling request.
+type SamplingAction string
+
+const (
+	// SamplingAllow relays the request to the client
+	SamplingAllow SamplingAction = "allow"
+	// SamplingDeny answers the server with a CodeSamplingRefused error
+	SamplingDeny SamplingAction = "deny"
+	// SamplingApprove holds the request until an operator decides
+	// (see DecideSampling); requests still undecided at the timeout
+	// are denied
+	SamplingApprove SamplingAction = "approve"
+)
+
+// SamplingPolicy mediates the sampling/createMessage requests a server
+// sends to have the client run its model.
+//
+// Each request is decoded, its system prompt and message text are run
+// through the injection scanner and, optionally, a council vote, and
+// the policy's action is applied: Action for clean requests,
+// OnInjection for flagged ones. Malformed requests, requests for more
+// than MaxTokens, and requests to include the context of the client's
+// other servers are denied. Every request and the client's answer are
+// logged and audited.
+//
+// # Security Notes
+//
+// Sampling hands text chosen by the server to the client's model,
+// often with the user's conversation attached, and the completion goes
+// back to the server: a direct prompt injection path, and with
+// includeContext "allServers" an exfiltration path for what other
+// servers returned. Held and evaluated requests are relayed in their
+// own goroutine, so they may reach the client after server messages
+// sent later.
+type SamplingPolicy struct {
+	// Action is applied to requests nothing flagged (empty allows)
+	Action SamplingAction
+
+	// OnInjection is applied to requests the scanner or council
+	// flagged (empty denies)
+	OnInjection SamplingAction
+
+	// Scanner finds injections in the request text (nil uses the
+	// scanner's default rules)
+	Scanner *scanner.Scanner
+
+	// Council submits each request to a council vote as well
+	Council bool
+
+	// RiskScore is the risk score of council votes (0 uses 0.7)
+	RiskScore float64
+
+	// MaxTokens denies requests for longer completions (0 for no limit)
+	MaxTokens int
+
+	// AllowAllServersContext relays requests whose includeContext is
+	// "allServers"
+	AllowAllServersContext bool
+
+	// ApprovalTimeout bounds the wait for an operator (zero uses
+	// DefaultSamplingApprovalTimeout)
+	ApprovalTimeout time.Duration
+}
+
+// SamplingRequest is a sampling request awaiting operator approval.
+type SamplingRequest struct {
+	// ID is the JSON-RPC request ID as sent by the server
+	ID string `json:"id"`
+
+	// Messages is the number of messages in the request
+	Messages int `json:"messages"`
+
+	// MaxTokens is the completion length requested
+	MaxTokens int `json:"max_tokens"`
+
+	// Reason is why the request was flagged, if it was
+	Reason string `json:"reason,omitempty"`
+
+	// Preview is the start of the system prompt and message text
+	Preview string `json:"preview"`
+
+	// Received is when the request arrived
+	Received time.Time `json:"received"`
+}
+
+// heldSampling is a request waiting in samplingGate.held.
+type heldSampling struct {
+	req      SamplingRequest
+	decision chan bool
+}
+
+// samplingGate applies a SamplingPolicy to one session's requests.
+type samplingGate struct {
+	policy  SamplingPolicy
+	scanner *scanner.Scanner
+
+	mu   sync.Mutex
+	held map[string]*heldSampling
+}
+
+// newSamplingGate fills in the defaults of p.
+func newSamplingGate(p *SamplingPolicy) *samplingGate {
+	g := &samplingGate{policy: *p, scanner: p.Scanner, held: make(map[string]*heldSampling)}
+	if g.policy.Action == "" {
+		g.policy.Action = SamplingAllow
+	}
+	if g.policy.OnInjection == "" {
+		g.policy.OnInjection = SamplingDeny
+	}
+	if g.policy.RiskScore <= 0 {
+		g.policy.RiskScore = 0.7
+	}
+	if g.policy.ApprovalTimeout <= 0 {
+		g.policy.ApprovalTimeout = DefaultSamplingApprovalTimeout
+	}
+	if g.scanner == nil {
+		// The default rules always compile
+		g.scanner, _ = scanner.New(scanner.Config{})
+	}
+	return g
+}
+
+// samplingText returns the text of a sampling request the model reads.
+func samplingText(p *mcptypes.CreateMessageParams) []string {
+	var texts []string
+	if p.SystemPrompt != "" {
+		texts = append(texts, p.SystemPrompt)
+	}
+	for _, m := range p.Messages {
+		if m.Content.Text != "" {
+			texts = append(texts, m.Content.Text)
+		}
+		if m.Content.Resource != nil && m.Content.Resource.Text != "" {
+			texts = append(texts, m.Content.Resource.Text)
+		}
+	}
+	return texts
+}
+
+// PendingSampling lists the session's sampling requests awaiting
+// approval, oldest first.
+func (r *Router) PendingSampling() []SamplingRequest {
+	if r.sampling == nil {
+		return nil
+	}
+	g := r.sampling
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]SamplingRequest, 0, len(g.held))
+	for _, h := range g.held {
+		out = append(out, h.req)
+	}
+	slices.SortFunc(out, func(a, b SamplingRequest) int { return a.Received.Compare(b.Received) })
+	return out
+}
+
+// DecideSampling approves or denies a held sampling request.
+//
+// # Arguments
+//   - id: The request's JSON-RPC ID as listed by PendingSampling
+//   - approve: Relay the request to the client, or refuse it
+//
+// # Returns
+//   - ErrNoSamplingRequest if no request with id is awaiting approval
+func (r *Router) DecideSampling(id string, approve bool) error {
+	if r.sampling == nil {
+		return fmt.Errorf("%w: %s", ErrNoSamplingRequest, id)
+	}
+	g := r.sampling
+	g.mu.Lock()
+	h := g.held[id]
+	delete(g.held, id)
+	g.mu.Unlock()
+	if h == nil {
+		return fmt.Errorf("%w: %s", ErrNoSamplingRequest, id)
+	}
+	h.decision <- approve
+	return nil
+}
+
+// relaySampling applies the sampling policy to a server's
+// sampling/createMessage request, then relays or refuses it.
+func (r *Router) relaySampling(msg *jsonrpc.Message, data []byte) {
+	r.stats.SamplingRequests.Add(1)
+	d := r.newDecision()
+	d.Method = msg.Method
+	params, action, reason := r.screenSampling(d, msg)
+	if action == SamplingApprove {
+		action, reason = r.awaitSamplingApproval(msg, params, reason)
+	}
+	if action != SamplingAllow {
+		if reason == "" {
+			reason = "sampling denied by policy"
+		}
+		r.refuseSampling(d, msg, reason)
+		return
+	}
+	log.Printf("audit: session %s: relayed sampling request %s (%d messages, max %d tokens)", r.sessionID, msg.ID, len(params.Messages), params.MaxTokens)
+	r.relayToClient(msg, data)
+}
+
+// screenSampling decodes a sampling request and decides its action.
+// The reason explains a denial or what flagged the request.
+func (r *Router) screenSampling(d *Decision, msg *jsonrpc.Message) (*mcptypes.CreateMessageParams, SamplingAction, string) {
+	p := &r.sampling.policy
+	params, err := mcptypes.DecodeParams[mcptypes.CreateMessageParams](msg)
+	if err != nil {
+		return &mcptypes.CreateMessageParams{}, SamplingDeny, "malformed sampling request: " + err.Error()
+	}
+	if p.MaxTokens > 0 && params.MaxTokens > p.MaxTokens {
+		return params, SamplingDeny, fmt.Sprintf("requested %d tokens, limit is %d", params.MaxTokens, p.MaxTokens)
+	}
+	if params.IncludeContext == "allServers" && !p.AllowAllServersContext {
+		return params, SamplingDeny, "context of all servers requested"
+	}
+
+	texts := samplingText(params)
+	var rules []string
+	for _, text := range texts {
+		for _, f := range r.sampling.scanner.Scan(text) {
+			if !slices.Contains(rules, f.Rule) {
+				rules = append(rules, f.Rule)
+			}
+		}
+	}
+	if len(rules) > 0 {
+		return params, p.OnInjection, "injection detected: " + strings.Join(rules, ", ")
+	}
+
+	if p.Council {
+		req := &sentinel.CouncilVoteRequest{
+			Action:    "Run the client's model for the server",
+			ToolName:  msg.Method,
+			RiskScore: p.RiskScore,
+			Context: map[string]interface{}{
+				"direction":       "request",
+				"system_prompt":   params.SystemPrompt,
+				"messages":        texts,
+				"include_context": params.IncludeContext,
+			},
+		}
+		result, err := r.runCheck(d, CheckSampling, func() (*sentinel.CheckResult, error) {
+			return r.sentinel.VoteCouncil(d.ctx, req)
+		})
+		r.reportBackend(err)
+		if err != nil {
+			// Unvetted sampling is refused rather than relayed
+			return params, SamplingDeny, "council unavailable: " + err.Error()
+		}
+		if !result.Allowed {
+			return params, p.OnInjection, "council: " + result.Reason
+		}
+	}
+	return params, p.Action, ""
+}
+
+// awaitSamplingApproval holds a sampling request until an operator
+// decides or the approval timeout passes.
+func (r *Router) awaitSamplingApproval(msg *jsonrpc.Message, params *mcptypes.CreateMessageParams, reason string) (SamplingAction, string) {
+	g := r.sampling
+	id := string(msg.ID)
+	preview := strings.Join(samplingText(params), "\n")
+	if len(preview) > samplingPreviewBytes {
+		preview = preview[:samplingPreviewBytes]
+	}
+	h := &heldSampling{
+		req: SamplingRequest{
+			ID:        id,
+			Messages:  len(params.Messages),
+			MaxTokens: params.MaxTokens,
+			Reason:    reason,
+			Preview:   preview,
+			Received:  time.Now().UTC(),
+		},
+		decision: make(chan bool, 1),
+	}
+	g.mu.Lock()
+	g.held[id] = h
+	g.mu.Unlock()
+	log.Printf("audit: session %s: sampling request %s awaits approval", r.sessionID, id)
+
+	timer := time.NewTimer(g.policy.ApprovalTimeout)
+	defer timer.Stop()
+	select {
+	case approved := <-h.decision:
+		if approved {
+			return SamplingAllow, ""
+		}
+		return SamplingDeny, "denied by operator"
+	case <-timer.C:
+		g.mu.Lock()
+		delete(g.held, id)
+		g.mu.Unlock()
+		// An approval racing the timeout already sent its decision
+		select {
+		case approved := <-h.decision:
+			if approved {
+				return SamplingAllow, ""
+			}
+		default:
+		}
+		return SamplingDeny, "approval timed out"
+	}
+}
+
+// refuseSampling answers a sampling request with an error to the server
+// in place of the client.
+func (r *Router) refuseSampling(d *Decision, msg *jsonrpc.Message, reason string) {
+	id := string(msg.ID)
+	r.relayed.match(id, nil)
+	r.calls.answered(id)
+	r.stats.SamplingRefused.Add(1)
+	log.Printf("audit: session %s: refused sampling request %s: %s", r.sessionID, msg.ID, reason)
+	r.auditSampling(audit.ServerToClient, d.ID, string(VerdictBlocked), reason)
+	reply, err := r.errorResponse(d, VerdictBlocked, msg.ID, CodeSamplingRefused, "Sampling refused", reason)
+	if err != nil {
+		log.Printf("router: session %s: %v", r.sessionID, err)
+		return
+	}
+	if err := r.upstream.Send(reply); err != nil {
+		log.Printf("router: session %s: relay to server failed: %v", r.sessionID, err)
+	}
+}
+
+// samplingAnswered logs the client's answer to a sampling request.
+func (r *Router) samplingAnswered(msg *jsonrpc.Message) {
+	reason := "error"
+	if msg.Error == nil {
+		if result, err := mcptypes.DecodeResult[mcptypes.CreateMessageResult](msg); err != nil {
+			reason = "malformed result"
+		} else {
+			reason = fmt.Sprintf("model %q, stop reason %q", result.Model, result.StopReason)
+		}
+	}
+	log.Printf("audit: session %s: sampling request %s answered: %s", r.sessionID, msg.ID, reason)
+	r.auditSampling(audit.ClientToServer, "", audit.DecisionRelayed, reason)
+}
+
+// auditSampling records a sampling request or answer.
+func (r *Router) auditSampling(dir audit.Direction, decisionID, decision, reason string) {
+	if r.audit == nil {
+		return
+	}
+	r.writeAudit(&audit.Record{
+		Time:       time.Now().UTC(),
+		Session:    r.sessionID,
+		Direction:  dir,
+		Method:     "sampling/createMessage",
+		DecisionID: decisionID,
+		Decision:   decision,
+		Reason:     reason,
+	})
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schedule"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestRouteMessage_ConcurrencyLimit(t *testing.T) {
+	sched, err := schedule.New(&schedule.Config{
+		Limits: []schedule.Limit{{Category: "exec", Tools: []string{"execute_command"}, MaxInFlight: 1}},
+	})
+	if err != nil {
+		t.Fatalf("schedule.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Schedule = sched
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	forwarding, finish := make(chan struct{}), make(chan struct{})
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		if string(msg.ID) == "1" {
+			close(forwarding)
+			<-finish
+		}
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	call := func(id string) []byte {
+		response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":` + id + `,"method":"tools/call","params":{"name":"execute_command","arguments":{"command":"ls"}}}`))
+		return response
+	}
+
+	done := make(chan []byte)
+	go func() { done <- call("1") }()
+	<-forwarding
+
+	// The first call holds the only slot until its response is in
+	response := call("2")
+	var msg jsonrpc.Message
+	if err := json.Unmarshal(response, &msg); err != nil || msg.Error == nil || msg.Error.Code != CodeRateLimited ||
+		!strings.Contains(string(msg.Error.Data), "concurrency limit") {
+		t.Errorf("concurrent call answered %s, expected a concurrency limit error", response)
+	}
+	close(finish)
+	if response := <-done; strings.Contains(string(response), "error") {
+		t.Errorf("first call answered %s", response)
+	}
+	if response := call("3"); strings.Contains(string(response), "error") {
+		t.Errorf("call after the slot was released answered %s", response)
+	}
+	if s := r.Stats(); s.ConcurrencyLimited != 1 {
+		t.Errorf("ConcurrencyLimited = %d, expected 1", s.ConcurrencyLimited)
+	}
+}
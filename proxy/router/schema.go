@@ -0,0 +1,102 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schema"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// SchemaValidation configures local validation of tool call arguments
+// against the inputSchema of the tools the server listed.
+//
+// # Security Notes
+//
+// The sentinel's Registry Guard validates arguments too, but a stub
+// build passes everything. Validating in the proxy keeps malformed or
+// smuggled arguments, such as unexpected properties a tool would
+// silently accept, from reaching the server in every build.
+type SchemaValidation struct {
+	// RequireListed refuses calls to tools the server has not listed
+	// with a usable inputSchema in this session (default forwards them
+	// unchecked)
+	RequireListed bool
+}
+
+// recordSchemas caches the input schemas of a tools/list response.
+// Pages are merged, so every page of a listing is covered; a tool keeps
+// its schema until a later listing replaces it.
+func (r *Router) recordSchemas(response []byte) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || resp.Result == nil {
+		return
+	}
+	var result struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if json.Unmarshal(resp.Result, &result) != nil {
+		return
+	}
+	for _, tool := range result.Tools {
+		if tool.Name == "" || len(tool.InputSchema) == 0 {
+			continue
+		}
+		if err := r.schemas.Put(tool.Name, tool.InputSchema); err != nil {
+			log.Printf("router: session %s: tool %q: arguments not validated: %v", r.sessionID, tool.Name, err)
+		}
+	}
+}
+
+// checkSchema validates the arguments of a tool call as it will be
+// forwarded.
+//
+// # Returns
+//   - An error response for the client if the call is refused
+//   - Whether the call was refused
+func (r *Router) checkSchema(d *Decision, msg *jsonrpc.Message, data []byte) ([]byte, bool) {
+	var violations []schema.Violation
+	result, _ := r.runCheck(d, CheckSchema, func() (*sentinel.CheckResult, error) {
+		var params struct {
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if forwarded, err := jsonrpc.Parse(data); err == nil {
+			json.Unmarshal(forwarded.Params, &params)
+		}
+		var listed bool
+		violations, listed = r.schemas.Validate(d.Tool, params.Arguments)
+		if !listed {
+			if r.schemaValidation.RequireListed {
+				return &sentinel.CheckResult{Allowed: false, Reason: fmt.Sprintf("tool %q has no listed input schema", d.Tool)}, nil
+			}
+			return &sentinel.CheckResult{Allowed: true}, nil
+		}
+		if len(violations) == 0 {
+			return &sentinel.CheckResult{Allowed: true}, nil
+		}
+		text := make([]string, len(violations))
+		for i, v := range violations {
+			text[i] = v.String()
+		}
+		return &sentinel.CheckResult{
+			Allowed: false,
+			Reason:  fmt.Sprintf("arguments do not match the input schema of %q: %s", d.Tool, strings.Join(text, "; ")),
+		}, nil
+	})
+	if result.Allowed {
+		return nil, false
+	}
+	r.stats.MessagesBlocked.Add(1)
+	if len(violations) > 0 {
+		r.stats.SchemaViolations.Add(1)
+		d.Details = withDetailMap(d.Details, "schema_violations", violations)
+	}
+	reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidParams, "Invalid arguments", result.Reason)
+	return reply, true
+}
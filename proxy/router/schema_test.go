@@ -0,0 +1,104 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/schema"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		requireListed bool
+		tool          string
+		args          map[string]interface{}
+		wantCode      int // 0 means forwarded
+		violations    int
+	}{
+		{"valid", false, "read_file", map[string]interface{}{"path": "/tmp/a"}, 0, 0},
+		{"missing required", false, "read_file", map[string]interface{}{}, jsonrpc.InvalidParams, 1},
+		{"wrong type", false, "read_file", map[string]interface{}{"path": 7}, jsonrpc.InvalidParams, 1},
+		{"smuggled property", false, "read_file", map[string]interface{}{"path": "/tmp/a", "exec": "sh"}, jsonrpc.InvalidParams, 1},
+		{"unlisted forwarded", false, "write_file", map[string]interface{}{"x": 1}, 0, 0},
+		{"unlisted refused", true, "write_file", map[string]interface{}{"x": 1}, jsonrpc.InvalidParams, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.SchemaValidation = &SchemaValidation{RequireListed: tt.requireListed}
+			r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+			forwarded := 0
+			r.forwardFunc = func(data []byte) ([]byte, error) {
+				msg, _ := jsonrpc.Parse(data)
+				result := map[string]interface{}{"content": []interface{}{}}
+				if msg.Method == "tools/list" {
+					result = map[string]interface{}{"tools": []interface{}{
+						map[string]interface{}{
+							"name": "read_file",
+							"inputSchema": map[string]interface{}{
+								"type":                 "object",
+								"properties":           map[string]interface{}{"path": map[string]interface{}{"type": "string"}},
+								"required":             []string{"path"},
+								"additionalProperties": false,
+							},
+						},
+					}}
+				} else {
+					forwarded++
+				}
+				resp, _ := jsonrpc.NewResponse(msg.ID, result)
+				return jsonrpc.Serialize(resp)
+			}
+			route := func(method string, params interface{}) *jsonrpc.Message {
+				req, _ := jsonrpc.NewRequest(method, params, 1)
+				data, _ := jsonrpc.Serialize(req)
+				response, _ := r.RouteMessage(data)
+				resp, err := jsonrpc.Parse(response)
+				if err != nil {
+					t.Fatalf("Parse failed: %v", err)
+				}
+				return resp
+			}
+
+			route("tools/list", map[string]interface{}{})
+			resp := route("tools/call", map[string]interface{}{"name": tt.tool, "arguments": tt.args})
+			if tt.wantCode == 0 {
+				if resp.Error != nil || forwarded != 1 {
+					t.Fatalf("call not forwarded: %+v", resp.Error)
+				}
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != tt.wantCode || forwarded != 0 {
+				t.Fatalf("error = %+v (forwarded %d), expected code %d", resp.Error, forwarded, tt.wantCode)
+			}
+			d := r.RecentDecisions(1)[0]
+			if d.Verdict != VerdictBlocked {
+				t.Errorf("verdict = %s, expected blocked", d.Verdict)
+			}
+			violations, _ := d.Details["schema_violations"].([]schema.Violation)
+			if len(violations) != tt.violations || r.stats.SchemaViolations.Load() != uint64(min(tt.violations, 1)) {
+				t.Errorf("schema_violations = %v, SchemaViolations = %d", d.Details["schema_violations"], r.stats.SchemaViolations.Load())
+			}
+		})
+	}
+}
+
+func TestSchemaValidation_Disabled(t *testing.T) {
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), DefaultConfig())
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, json.RawMessage(`{"tools":[{"name":"t","inputSchema":{"required":["a"]}}]}`))
+		return jsonrpc.Serialize(resp)
+	}
+	for _, method := range []string{"tools/list", "tools/call"} {
+		req, _ := jsonrpc.NewRequest(method, map[string]interface{}{"name": "t"}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		response, _ := r.RouteMessage(data)
+		if resp, _ := jsonrpc.Parse(response); resp.Error != nil {
+			t.Errorf("%s: unexpected error %+v", method, resp.Error)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sessionstate"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+// statePins holds the fingerprints of the tools the server listed, and
+// those a saved state pinned before, for the session state store.
+type statePins struct {
+	mu       sync.Mutex
+	server   string
+	prints   map[string]string
+	restored map[string]string
+}
+
+// restoreState resumes the security context saved under the state key:
+// the tool history, gas used, tool pins, and kill-switch termination.
+//
+// # Security Notes
+//
+// A state that fails to load is logged and the session starts fresh;
+// refusing to start would let a corrupted store deny service.
+func (r *Router) restoreState() {
+	saved, err := r.stateStore.Load(r.stateKey)
+	if errors.Is(err, sessionstate.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("router: session %s: state %q not restored: %v", r.sessionID, r.stateKey, err)
+		return
+	}
+	r.applyState(saved)
+	log.Printf("router: session %s: resumed state %q of session %s (%d tool calls, gas %d, terminated=%v)",
+		r.sessionID, r.stateKey, saved.Session, len(saved.PreviousTools), saved.GasUsed, saved.Terminated)
+}
+
+// applyState takes on the tool history, gas used, tool pins, and
+// kill-switch termination of a saved state.
+func (r *Router) applyState(saved *sessionstate.State) {
+	r.toolsMu.Lock()
+	r.previousTools = append(r.previousTools, saved.PreviousTools...)
+	r.toolsMu.Unlock()
+	r.gasUsed.Store(saved.GasUsed)
+	r.pins.mu.Lock()
+	r.pins.server = saved.Server
+	r.pins.restored = saved.Pins
+	r.pins.mu.Unlock()
+	if saved.Terminated {
+		r.terminated.Store(true)
+	}
+}
+
+// saveState saves the session's security context under the state key.
+// Failures are logged.
+func (r *Router) saveState() {
+	r.toolsMu.Lock()
+	tools := append([]string(nil), r.previousTools...)
+	r.toolsMu.Unlock()
+
+	r.pins.mu.Lock()
+	pins := r.pins.prints
+	if pins == nil {
+		pins = r.pins.restored
+	}
+	state := &sessionstate.State{
+		Session:       r.sessionID,
+		PreviousTools: tools,
+		GasUsed:       r.gasUsed.Load(),
+		Server:        r.pins.server,
+		Pins:          pins,
+		Terminated:    r.terminated.Load(),
+		Updated:       time.Now().UTC(),
+	}
+	// Save copies the state, so the lock covers reading the pins
+	err := r.stateStore.Save(r.stateKey, state)
+	r.pins.mu.Unlock()
+	if err != nil {
+		log.Printf("router: session %s: failed to save state %q: %v", r.sessionID, r.stateKey, err)
+	}
+}
+
+// pinTools records the fingerprints of a tools/list response and flags
+// tools whose definition changed since the saved state pinned them.
+// Pages are merged like the schema cache.
+func (r *Router) pinTools(d *Decision, response []byte) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || resp.Result == nil {
+		return
+	}
+	var result struct {
+		Tools []json.RawMessage `json:"tools"`
+	}
+	if json.Unmarshal(resp.Result, &result) != nil {
+		return
+	}
+	r.server.mu.Lock()
+	server := r.server.name
+	r.server.mu.Unlock()
+
+	r.pins.mu.Lock()
+	if r.pins.prints == nil || r.pins.server != server {
+		if r.pins.server != server {
+			// Pins of another server say nothing about this one
+			r.pins.restored = nil
+		}
+		r.pins.server = server
+		r.pins.prints = make(map[string]string)
+	}
+	var changed []string
+	for _, raw := range result.Tools {
+		var tool struct {
+			Name string `json:"name"`
+		}
+		fp, err := tofu.Fingerprint(raw)
+		if err != nil || json.Unmarshal(raw, &tool) != nil || tool.Name == "" {
+			continue
+		}
+		if pinned, ok := r.pins.restored[tool.Name]; ok && pinned != fp {
+			changed = append(changed, tool.Name)
+		}
+		r.pins.prints[tool.Name] = fp
+	}
+	r.pins.mu.Unlock()
+
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		d.Details = withDetailMap(d.Details, "pins_changed", changed)
+		log.Printf("router: session %s: tools changed since state %q was saved: %v", r.sessionID, r.stateKey, changed)
+	}
+	r.saveState()
+}
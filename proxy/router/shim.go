@@ -0,0 +1,88 @@
+package router
+
+import (
+	"log"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/shim"
+)
+
+// currentShim returns the session's active protocol shim, or nil.
+func (r *Router) currentShim() *shim.Shim {
+	r.shimMu.Lock()
+	defer r.shimMu.Unlock()
+	if r.shim == nil || !r.shim.Active() {
+		return nil
+	}
+	return r.shim
+}
+
+// shimRequest records the client's revision from initialize and adapts
+// later requests for the server.
+//
+// # Returns
+//   - A local reply to send instead of forwarding (nil to forward)
+func (r *Router) shimRequest(msg *jsonrpc.Message) (*jsonrpc.Message, error) {
+	if msg.Method == "initialize" {
+		if params, err := mcptypes.DecodeParams[mcptypes.InitializeParams](msg); err == nil {
+			r.shimMu.Lock()
+			r.clientRevision = shim.Revision(params.ProtocolVersion)
+			r.shim = nil
+			r.shimMu.Unlock()
+		}
+		return nil, nil
+	}
+	s := r.currentShim()
+	if s == nil {
+		return nil, nil
+	}
+	return s.Request(msg)
+}
+
+// shimResponse establishes the shim from the initialize result and
+// adapts server results for the client. Responses that cannot be
+// adapted are returned unchanged.
+func (r *Router) shimResponse(msg *jsonrpc.Message, response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		return response
+	}
+
+	if msg.Method == "initialize" {
+		result, err := mcptypes.DecodeResult[mcptypes.InitializeResult](resp)
+		if err != nil {
+			return response
+		}
+		r.shimMu.Lock()
+		s, err := shim.New(r.clientRevision, shim.Revision(result.ProtocolVersion))
+		r.shim = s
+		r.shimMu.Unlock()
+		if err != nil {
+			log.Printf("router: session %s: protocol shim disabled: %v", r.sessionID, err)
+			return response
+		}
+		if s.Active() {
+			log.Printf("router: session %s: protocol shim translating client %s <-> server %s",
+				r.sessionID, s.Client(), s.Server())
+		}
+	}
+
+	s := r.currentShim()
+	if s == nil {
+		return response
+	}
+	changed, err := s.Response(msg.Method, resp)
+	if err != nil {
+		log.Printf("router: session %s: %v", r.sessionID, err)
+		return response
+	}
+	if !changed {
+		return response
+	}
+	data, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return response
+	}
+	return data
+}
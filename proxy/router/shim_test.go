@@ -0,0 +1,58 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestProtocolShims_NewerClientOlderServer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ProtocolShims = true
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	forwarded := 0
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		forwarded++
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`0`), map[string]interface{}{
+			"protocolVersion": "2024-11-05",
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "old", "version": "0.1"},
+		})
+		return jsonrpc.Serialize(resp)
+	}
+
+	route := func(method string, params interface{}) *jsonrpc.Message {
+		t.Helper()
+		req, _ := jsonrpc.NewRequest(method, params, 0)
+		data, _ := jsonrpc.Serialize(req)
+		response, err := r.RouteMessage(data)
+		if err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+		resp, _ := jsonrpc.Parse(response)
+		return resp
+	}
+
+	resp := route("initialize", map[string]interface{}{
+		"protocolVersion": "2025-06-18",
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "new", "version": "2"},
+	})
+	result, err := mcptypes.DecodeResult[mcptypes.InitializeResult](resp)
+	if err != nil || result.ProtocolVersion != "2025-06-18" {
+		t.Fatalf("client should see its own revision, got %+v, %v", result, err)
+	}
+
+	// The old server never sees completion requests
+	resp = route("completion/complete", map[string]interface{}{
+		"ref":      map[string]string{"type": "ref/prompt", "name": "p"},
+		"argument": map[string]string{"name": "a", "value": "x"},
+	})
+	if resp.Error != nil || forwarded != 1 {
+		t.Errorf("completion should be answered locally: %v, forwarded %d", resp.Error, forwarded)
+	}
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/slo"
+)
+
+func TestSLO_Observations(t *testing.T) {
+	monitor, err := slo.New(&slo.Config{Objectives: []slo.Objective{
+		{Name: "latency", Method: "tools/list", Latency: 40 * time.Millisecond, Target: 0.9},
+		{Name: "blocks", Verdicts: []string{string(VerdictBlocked)}, Target: 0.9},
+	}})
+	if err != nil {
+		t.Fatalf("slo.New failed: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.SLO = monitor
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		// A slow server is not latency the proxy added
+		time.Sleep(50 * time.Millisecond)
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"tools": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	route := func(method string, params interface{}) {
+		req, _ := jsonrpc.NewRequest(method, params, 1)
+		data, _ := jsonrpc.Serialize(req)
+		r.RouteMessage(data)
+	}
+	route("tools/list", nil)
+	route("tools/call", map[string]interface{}{"name": "shell"})
+
+	st := monitor.Status()
+	if w := st[0].Windows[0]; w.Events != 1 || w.BurnRate != 0 {
+		t.Errorf("latency objective = %+v, expected one good event", w)
+	}
+	if w := st[1].Windows[0]; w.Events != 2 || w.BurnRate != 5 {
+		t.Errorf("block objective = %+v, expected one bad event of two", w)
+	}
+}
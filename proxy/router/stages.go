@@ -0,0 +1,352 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/anomaly"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// Names of the built-in stages of the tools/call pipeline, in their
+// default order.
+const (
+	// StageRateLimit enforces the tool call rate limits
+	StageRateLimit = "rate_limit"
+	// StageBudget charges and enforces the gas budget and call depth
+	StageBudget = "budget"
+	// StageSchedule enforces the tool schedule and concurrency limits
+	StageSchedule = "schedule"
+	// StageToolPolicy allows or denies tools by name
+	StageToolPolicy = "tool_policy"
+	// StageTOFU requires approval of new tool fingerprints
+	StageTOFU = "tofu"
+	// StageGuardrail pins or rewrites arguments
+	StageGuardrail = "guardrail"
+	// StageSchema validates arguments against the listed input schema
+	StageSchema = "schema"
+	// StageTaint traces arguments to earlier tool results
+	StageTaint = "taint"
+	// StageSentinel runs the sentinel checks: registry, state, and
+	// council
+	StageSentinel = "sentinel"
+)
+
+// Stage order errors.
+var (
+	ErrUnknownStage   = errors.New("router: unknown stage")
+	ErrDuplicateStage = errors.New("router: duplicate stage")
+)
+
+// Stage is a named middleware in the tools/call pipeline, run in
+// Config.StageOrder among the built-in checks.
+//
+// A stage receives the call's request and either answers it itself,
+// refusing the call, or passes the request on with next and returns
+// the response, which by then has passed the response checks and is
+// what the client receives. A stage may rewrite the request it passes
+// on; the stages after it then check the rewritten call, which must
+// keep the request's ID and tool name.
+type Stage struct {
+	// Name places the stage in Config.StageOrder; it must not be a
+	// built-in stage name
+	Name string
+
+	// Middleware is the stage
+	Middleware middleware.Middleware
+}
+
+// DefaultStageOrder returns the built-in stages in their default order.
+func DefaultStageOrder() []string {
+	return []string{
+		StageRateLimit, StageBudget, StageSchedule, StageToolPolicy, StageTOFU,
+		StageGuardrail, StageSchema, StageTaint, StageSentinel,
+	}
+}
+
+// stagedCall is the state a tools/call carries through the stages.
+type stagedCall struct {
+	d   *Decision
+	msg *jsonrpc.Message
+}
+
+// checkStage is a built-in stage.
+type checkStage func(r *Router, c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error)
+
+// checkStages are the built-in stages by name.
+var checkStages = map[string]checkStage{
+	StageRateLimit:  (*Router).rateLimitStage,
+	StageBudget:     (*Router).budgetStage,
+	StageSchedule:   (*Router).scheduleStage,
+	StageToolPolicy: (*Router).toolPolicyStage,
+	StageTOFU:       (*Router).tofuStage,
+	StageGuardrail:  (*Router).guardrailStage,
+	StageSchema:     (*Router).schemaStage,
+	StageTaint:      (*Router).taintStage,
+	StageSentinel:   (*Router).sentinelStage,
+}
+
+// ValidateStageOrder checks a stage order against the built-in stages
+// and the stages given.
+//
+// # Returns
+//   - ErrUnknownStage for a name that is neither built in nor given,
+//     or a given stage named like a built-in one
+//   - ErrDuplicateStage for a name listed or given twice
+func ValidateStageOrder(order []string, stages []Stage) error {
+	known := make(map[string]bool, len(checkStages)+len(stages))
+	for name := range checkStages {
+		known[name] = true
+	}
+	for _, s := range stages {
+		switch {
+		case s.Name == "" || checkStages[s.Name] != nil:
+			return fmt.Errorf("%w: stage %q cannot be registered", ErrUnknownStage, s.Name)
+		case known[s.Name]:
+			return fmt.Errorf("%w: %q", ErrDuplicateStage, s.Name)
+		}
+		known[s.Name] = true
+	}
+	seen := make(map[string]bool, len(order))
+	for _, name := range order {
+		switch {
+		case !known[name]:
+			return fmt.Errorf("%w: %q", ErrUnknownStage, name)
+		case seen[name]:
+			return fmt.Errorf("%w: %q", ErrDuplicateStage, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// stageOrder resolves the stages run for each tools/call: the order
+// given, or the built-in order followed by the stages given. Unknown
+// and repeated names are skipped, and stages the order leaves out run
+// after it, built-in ones first, so a short order cannot disable a
+// check.
+func stageOrder(order []string, stages []Stage) []string {
+	known := make(map[string]bool, len(checkStages)+len(stages))
+	for name := range checkStages {
+		known[name] = true
+	}
+	for _, s := range stages {
+		if s.Name != "" && s.Middleware != nil && checkStages[s.Name] == nil {
+			known[s.Name] = true
+		}
+	}
+
+	var resolved []string
+	seen := make(map[string]bool, len(known))
+	add := func(name string) {
+		if known[name] && !seen[name] {
+			seen[name] = true
+			resolved = append(resolved, name)
+		}
+	}
+	for _, name := range order {
+		add(name)
+	}
+	for _, name := range DefaultStageOrder() {
+		add(name)
+	}
+	for _, s := range stages {
+		add(s.Name)
+	}
+	return resolved
+}
+
+// runStages runs a tools/call through the stages and, if none refuses
+// it, deliver.
+func (r *Router) runStages(d *Decision, msg *jsonrpc.Message, data []byte, deliver func([]byte) ([]byte, error)) ([]byte, error) {
+	c := &stagedCall{d: d, msg: msg}
+	stages := make([]middleware.Middleware, 0, len(r.stages))
+	for _, name := range r.stages {
+		if check := checkStages[name]; check != nil {
+			stages = append(stages, func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+				return check(r, c, data, func(data []byte) ([]byte, error) { return next(mc, data) })
+			})
+			continue
+		}
+		stages = append(stages, r.userStage(c, name, r.userStages[name]))
+	}
+	mc := r.messageContext(d.ctx, msg, middleware.ClientToServer)
+	reply, err := middleware.New(stages...).Execute(mc, data, func(_ *middleware.MessageContext, data []byte) ([]byte, error) {
+		return deliver(data)
+	})
+	if reply == nil && errors.Is(err, context.Canceled) {
+		// Cancelled by the client or the session's end: no reply is due
+		r.stats.Errors.Add(1)
+		d.Verdict, d.Reason = VerdictError, "request cancelled"
+		d.event(EventFailed, map[string]interface{}{"error": d.Reason})
+		return nil, fmt.Errorf("router: request %s cancelled", msg.ID)
+	}
+	return reply, err
+}
+
+// userStage adapts a registered stage, checking a request it rewrites
+// so later stages check what will be forwarded.
+func (r *Router) userStage(c *stagedCall, name string, mw middleware.Middleware) middleware.Middleware {
+	return func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+		return mw(mc, data, func(out *middleware.MessageContext, rewritten []byte) ([]byte, error) {
+			if bytes.Equal(rewritten, data) {
+				return next(out, rewritten)
+			}
+			msg, err := jsonrpc.Parse(rewritten)
+			if err != nil || !bytes.Equal(msg.ID, c.msg.ID) || msg.Method != c.msg.Method || jsonrpc.ExtractToolName(msg) != c.d.Tool {
+				r.stats.Errors.Add(1)
+				return r.errorResponse(c.d, VerdictError, c.msg.ID, jsonrpc.InternalError, "Stage failed",
+					fmt.Sprintf("stage %s rewrote the request's ID, method, or tool", name))
+			}
+			c.msg = msg
+			return next(out, rewritten)
+		})
+	}
+}
+
+// rateLimitStage refuses calls beyond the rate limits, with a hint of
+// when to retry. It runs first by default so a refused call is not
+// charged to the budget.
+func (r *Router) rateLimitStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.rateLimit == nil {
+		return next(data)
+	}
+	if exceeded := r.rateLimit.Allow(r.sessionID, c.d.Tool); exceeded != nil {
+		r.stats.MessagesBlocked.Add(1)
+		r.stats.RateLimited.Add(1)
+		c.d.Details = withDetailMap(c.d.Details, "rate_limit", exceeded.Limit)
+		return r.errorResponseData(c.d, VerdictBlocked, c.msg.ID, CodeRateLimited, "Rate limited",
+			&ErrorData{Reason: exceeded.Error(), RetryAfterMS: retryAfterMS(exceeded.RetryAfter)})
+	}
+	return next(data)
+}
+
+// budgetStage charges the call against the gas budget and call depth.
+func (r *Router) budgetStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if reply, blocked := r.checkBudget(c.d, c.msg); blocked {
+		return reply, nil
+	}
+	return next(data)
+}
+
+// scheduleStage holds a schedule slot until the call's response is in.
+func (r *Router) scheduleStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.schedule == nil {
+		return next(data)
+	}
+	if ok, reason := r.schedule.Check(c.d.Tool); !ok {
+		r.stats.MessagesBlocked.Add(1)
+		return r.errorResponse(c.d, VerdictBlocked, c.msg.ID, jsonrpc.InvalidRequest, "Blocked by schedule", reason)
+	}
+	release, reason := r.schedule.Acquire(c.d.ctx, c.d.Tool)
+	if release == nil {
+		r.stats.MessagesBlocked.Add(1)
+		r.stats.ConcurrencyLimited.Add(1)
+		return r.errorResponse(c.d, VerdictBlocked, c.msg.ID, CodeRateLimited, "Concurrency limit", reason)
+	}
+	defer release()
+	return next(data)
+}
+
+// toolPolicyStage refuses tools the tool policy denies.
+func (r *Router) toolPolicyStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if tp := r.currentToolPolicy(); tp != nil {
+		if reason := tp.Check(c.d.Tool); reason != "" {
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(c.d, VerdictBlocked, c.msg.ID, jsonrpc.InvalidRequest, "Blocked by policy", reason)
+		}
+	}
+	return next(data)
+}
+
+// tofuStage refuses tools awaiting approval.
+func (r *Router) tofuStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.tofu != nil {
+		if reason := r.checkTOFU(c.d.Tool); reason != "" {
+			r.stats.MessagesBlocked.Add(1)
+			return r.errorResponse(c.d, VerdictBlocked, c.msg.ID, CodeApprovalRequired, "Approval required", reason)
+		}
+	}
+	return next(data)
+}
+
+// guardrailStage pins arguments so the stages after it check what will
+// be forwarded.
+func (r *Router) guardrailStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.guard == nil {
+		return next(data)
+	}
+	var rewrites []guardrailRewrite
+	pinned, _ := r.runCheck(c.d, CheckGuardrail, func() (*sentinel.CheckResult, error) {
+		out, rw, err := r.applyGuardrails(c.d, c.msg, data)
+		if err != nil {
+			return &sentinel.CheckResult{Allowed: false, Reason: err.Error()}, nil
+		}
+		data, rewrites = out, rw
+		return &sentinel.CheckResult{Allowed: true}, nil
+	})
+	if !pinned.Allowed {
+		r.stats.MessagesBlocked.Add(1)
+		return r.errorResponse(c.d, VerdictBlocked, c.msg.ID, jsonrpc.InvalidParams, "Blocked by security", pinned.Reason)
+	}
+	if len(rewrites) > 0 {
+		r.stats.ArgumentRewrites.Add(uint64(len(rewrites)))
+		c.d.Details = withDetailMap(c.d.Details, "argument_rewrites", rewrites)
+	}
+	return next(data)
+}
+
+// schemaStage refuses arguments that do not match the input schema.
+func (r *Router) schemaStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.schemas != nil {
+		if reply, blocked := r.checkSchema(c.d, c.msg, data); blocked {
+			return reply, nil
+		}
+	}
+	return next(data)
+}
+
+// taintStage raises the risk of calls acting on earlier results.
+func (r *Router) taintStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	if r.taint != nil {
+		if reply, blocked := r.checkTaint(c.d, c.msg); blocked {
+			return reply, nil
+		}
+	}
+	return next(data)
+}
+
+// sentinelStage runs the sentinel checks, or defers them to a trusted
+// sentinel upstream.
+func (r *Router) sentinelStage(c *stagedCall, data []byte, next func([]byte) ([]byte, error)) ([]byte, error) {
+	d := c.d
+	var result *sentinel.CheckResult
+	if hop := r.deferredChecks(d); hop != nil {
+		result = r.deferToUpstream(d, c.msg, hop)
+	} else {
+		var err error
+		if result, err = r.checkToolCall(d, c.msg); err != nil {
+			r.stats.Errors.Add(1)
+			return r.errorResponse(d, VerdictError, c.msg.ID, jsonrpc.InternalError, "Security check failed", err.Error())
+		}
+	}
+	if r.upstreamTools != nil {
+		if upstream, _, ok := r.upstreamTools.Resolve(d.Tool); ok {
+			result = withDetail(result, "upstream", upstream)
+		}
+	}
+	for k, v := range result.Details {
+		d.Details = withDetailMap(d.Details, k, v)
+	}
+	if !result.Allowed {
+		r.stats.MessagesBlocked.Add(1)
+		r.RecordAnomaly(anomaly.SignalBlock, result.Reason)
+		return r.errorResponse(d, VerdictBlocked, c.msg.ID, jsonrpc.InvalidRequest, "Blocked by security", result.Reason)
+	}
+	d.Reason = result.Reason
+	return next(data)
+}
@@ -0,0 +1,203 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/ratelimit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// newStagedRouter creates a router denying the tool "shell" and
+// charging 100 gas per call, recording what it forwards.
+func newStagedRouter(cfg *Config, forwarded *[]string) *Router {
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.GasModel = GasModelFunc(func(string, json.RawMessage, int) uint64 { return 100 })
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		*forwarded = append(*forwarded, string(data))
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+	return r
+}
+
+func TestStages_Order(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string
+		seen  []string // tools the registered stage sees
+		code  int      // error code of the shell call
+	}{
+		{"default", nil, []string{"search"}, CodeGasExhausted},
+		{"stage first", []string{"audit"}, []string{"search", "shell"}, CodeGasExhausted},
+		{"policy before budget", []string{StageToolPolicy, StageBudget}, []string{"search"}, jsonrpc.InvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			var forwarded []string
+			cfg := DefaultConfig()
+			cfg.GasBudget = 150
+			cfg.StageOrder = tt.order
+			cfg.Stages = []Stage{{Name: "audit", Middleware: func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+				seen = append(seen, jsonrpc.ExtractToolName(mc.Message))
+				return next(mc, data)
+			}}}
+			r := newStagedRouter(cfg, &forwarded)
+
+			r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`))
+			response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"shell"}}`))
+			if resp, _ := jsonrpc.Parse(response); errorCode(resp) != tt.code {
+				t.Errorf("shell call = %s, expected code %d", response, tt.code)
+			}
+			if !reflect.DeepEqual(seen, tt.seen) {
+				t.Errorf("stage saw %v, expected %v", seen, tt.seen)
+			}
+			if len(forwarded) != 1 {
+				t.Errorf("forwarded %d calls, expected 1", len(forwarded))
+			}
+		})
+	}
+}
+
+func TestStages_Rewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite string
+		refused bool
+	}{
+		{"arguments", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"q":"redacted"}}}`, false},
+		{"tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"shell","arguments":{}}}`, true},
+		{"id", `{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"search","arguments":{}}}`, true},
+		{"malformed", `{"jsonrpc":`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded []string
+			cfg := DefaultConfig()
+			cfg.Stages = []Stage{{Name: "rewrite", Middleware: func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+				return next(mc, []byte(tt.rewrite))
+			}}}
+			cfg.StageOrder = []string{"rewrite"}
+			r := newStagedRouter(cfg, &forwarded)
+
+			response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"q":"secret"}}}`))
+			resp, _ := jsonrpc.Parse(response)
+			if tt.refused {
+				if errorCode(resp) != jsonrpc.InternalError || len(forwarded) != 0 {
+					t.Errorf("response = %s, forwarded %v; expected a refusal", response, forwarded)
+				}
+				return
+			}
+			if resp.Error != nil || len(forwarded) != 1 || !strings.Contains(forwarded[0], "redacted") {
+				t.Errorf("response = %s, forwarded %v", response, forwarded)
+			}
+		})
+	}
+}
+
+func TestStages_Response(t *testing.T) {
+	var forwarded []string
+	cfg := DefaultConfig()
+	cfg.Stages = []Stage{{Name: "tag", Middleware: func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+		response, err := next(mc, data)
+		return []byte(strings.Replace(string(response), `"content":[]`, `"content":[],"tagged":true`, 1)), err
+	}}}
+	r := newStagedRouter(cfg, &forwarded)
+	response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`))
+	if !strings.Contains(string(response), `"tagged":true`) {
+		t.Errorf("response = %s, expected the stage's rewrite", response)
+	}
+	// Requests other than tools/call do not run the stages
+	response, _ = r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	if strings.Contains(string(response), "tagged") {
+		t.Errorf("ping response = %s ran the stages", response)
+	}
+}
+
+func TestStages_RateLimit(t *testing.T) {
+	limiter, err := ratelimit.New(&ratelimit.Config{Tools: []ratelimit.ToolLimit{{Tool: "search", Rate: 0.5, Burst: 1}}})
+	if err != nil {
+		t.Fatalf("ratelimit.New failed: %v", err)
+	}
+	var forwarded []string
+	cfg := DefaultConfig()
+	cfg.GasBudget = 150
+	cfg.RateLimit = limiter
+	r := newStagedRouter(cfg, &forwarded)
+
+	r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search"}}`))
+	response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"search"}}`))
+	resp, _ := jsonrpc.Parse(response)
+	var data ErrorData
+	if errorCode(resp) != CodeRateLimited || json.Unmarshal(resp.Error.Data, &data) != nil {
+		t.Fatalf("second call = %s, expected a rate limit", response)
+	}
+	if data.RetryAfterMS < 1900 || data.RetryAfterMS > 2000 {
+		t.Errorf("retry_after_ms = %d, expected about 2000", data.RetryAfterMS)
+	}
+	// The refused call runs no later stage, so it is not charged gas
+	if got := r.gasUsed.Load(); got != 100 {
+		t.Errorf("gas used = %d, expected 100", got)
+	}
+	if s := r.Stats(); s.RateLimited != 1 || len(forwarded) != 1 {
+		t.Errorf("RateLimited = %d with %d forwarded, expected 1 and 1", s.RateLimited, len(forwarded))
+	}
+}
+
+func TestStageOrder(t *testing.T) {
+	noop := middleware.Middleware(func(mc *middleware.MessageContext, data []byte, next middleware.Handler) ([]byte, error) {
+		return next(mc, data)
+	})
+	tests := []struct {
+		name     string
+		order    []string
+		stages   []Stage
+		expected []string
+	}{
+		{"default", nil, nil, DefaultStageOrder()},
+		{"registered last", nil, []Stage{{Name: "a", Middleware: noop}}, append(DefaultStageOrder(), "a")},
+		{"left out run after", []string{"a", StageSentinel}, []Stage{{Name: "a", Middleware: noop}},
+			[]string{"a", StageSentinel, StageRateLimit, StageBudget, StageSchedule, StageToolPolicy, StageTOFU, StageGuardrail, StageSchema, StageTaint}},
+		{"unknown and repeated skipped", []string{"x", StageTaint, StageTaint}, nil,
+			[]string{StageTaint, StageRateLimit, StageBudget, StageSchedule, StageToolPolicy, StageTOFU, StageGuardrail, StageSchema, StageSentinel}},
+		{"built-in name not registered", nil, []Stage{{Name: StageBudget, Middleware: noop}}, DefaultStageOrder()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stageOrder(tt.order, tt.stages); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("stageOrder = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateStageOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		order  []string
+		stages []Stage
+		err    error
+	}{
+		{"default", DefaultStageOrder(), nil, nil},
+		{"registered", []string{"a", StageBudget}, []Stage{{Name: "a"}}, nil},
+		{"unknown", []string{"a"}, nil, ErrUnknownStage},
+		{"repeated", []string{StageBudget, StageBudget}, nil, ErrDuplicateStage},
+		{"built-in name", nil, []Stage{{Name: StageTaint}}, ErrUnknownStage},
+		{"registered twice", nil, []Stage{{Name: "a"}, {Name: "a"}}, ErrDuplicateStage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateStageOrder(tt.order, tt.stages); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateStageOrder() = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package router
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsFoldDelay is how long finished decisions wait before the
+// background fold adds them to the per-method and per-tool counts.
+const statsFoldDelay = 100 * time.Millisecond
+
+// maxStatsKeys bounds the distinct methods and tools counted
+// separately; later names are counted under StatsOther.
+const maxStatsKeys = 256
+
+// StatsOther is the StatsSnapshot.Methods and Tools key counting every
+// name beyond the first 256.
+const StatsOther = "(other)"
+
+// counters holds the router's live statistics. The scalar counters are
+// atomics updated where each event happens; the per-method and per-tool
+// breakdown is aggregated from finished decisions.
+type counters struct {
+	MessagesReceived          atomic.Uint64
+	MessagesForwarded         atomic.Uint64
+	MessagesBlocked           atomic.Uint64
+	Errors                    atomic.Uint64
+	ServedFromStore           atomic.Uint64
+	RegistrySkipped           atomic.Uint64
+	Overloaded                atomic.Uint64
+	ToolCalls                 atomic.Uint64
+	ArgumentRewrites          atomic.Uint64
+	ContentFlags              atomic.Uint64
+	ResponsesSanitized        atomic.Uint64
+	LargeResultsScanned       atomic.Uint64
+	ToolsWithheld             atomic.Uint64
+	ToolsChanged              atomic.Uint64
+	ChainedRequests           atomic.Uint64
+	ChainRejected             atomic.Uint64
+	ChecksDeferred            atomic.Uint64
+	RateLimited               atomic.Uint64
+	Downgrades                atomic.Uint64
+	TaintedCalls              atomic.Uint64
+	IgnoredBlocks             atomic.Uint64
+	AuditErrors               atomic.Uint64
+	ConformanceViolations     atomic.Uint64
+	ConcurrencyLimited        atomic.Uint64
+	SchemaViolations          atomic.Uint64
+	GasExhausted              atomic.Uint64
+	CallDepthExceeded         atomic.Uint64
+	NotificationsBlocked      atomic.Uint64
+	SamplingRequests          atomic.Uint64
+	SamplingRefused           atomic.Uint64
+	ContextTrimmed            atomic.Uint64
+	ElicitationsDeclined      atomic.Uint64
+	ElicitationFieldsStripped atomic.Uint64
+	RootsRefused              atomic.Uint64
+	RootsFiltered             atomic.Uint64
+	ResourcesRejected         atomic.Uint64
+	ToolDescriptionsFlagged   atomic.Uint64
+	Normalized                atomic.Uint64
+	ProxyLoops                atomic.Uint64
+	BlocksExplained           atomic.Uint64
+	BytesFromClient           atomic.Uint64
+	BytesToClient             atomic.Uint64
+
+	// Server-to-client direction (NewWithTransports only)
+	FromServer         atomic.Uint64
+	RelayedToClient    atomic.Uint64
+	RelayedToServer    atomic.Uint64
+	UnmatchedResponses atomic.Uint64
+
+	// Response correlation (NewWithTransports only, except
+	// UnmatchedResponses)
+	DuplicateResponses      atomic.Uint64
+	LateResponses           atomic.Uint64
+	ClientResponsesRejected atomic.Uint64
+	RequestTimeouts         atomic.Uint64
+	PartialResults          atomic.Uint64
+	OrphanedRequests        atomic.Uint64
+	OrphanedRelayed         atomic.Uint64
+
+	breakdown statsAggregator
+}
+
+// VerdictCounts counts finished decisions by verdict.
+type VerdictCounts struct {
+	Total   uint64 `json:"total"`
+	Allowed uint64 `json:"allowed"`
+	Blocked uint64 `json:"blocked"`
+	Errors  uint64 `json:"errors"`
+}
+
+// add counts one decision with verdict v.
+func (c *VerdictCounts) add(v Verdict) {
+	c.Total++
+	switch v {
+	case VerdictAllowed:
+		c.Allowed++
+	case VerdictBlocked:
+		c.Blocked++
+	case VerdictError:
+		c.Errors++
+	}
+}
+
+// StatsSnapshot is a point-in-time copy of a router's statistics. It
+// holds plain values and may be kept, compared, and encoded freely.
+type StatsSnapshot struct {
+	// Time is when the snapshot was taken
+	Time time.Time `json:"time"`
+
+	MessagesReceived          uint64 `json:"messages_received"`
+	MessagesForwarded         uint64 `json:"messages_forwarded"`
+	MessagesBlocked           uint64 `json:"messages_blocked"`
+	Errors                    uint64 `json:"errors"`
+	ServedFromStore           uint64 `json:"served_from_store"`
+	RegistrySkipped           uint64 `json:"registry_skipped"`
+	Overloaded                uint64 `json:"overloaded"`
+	ToolCalls                 uint64 `json:"tool_calls"`
+	ArgumentRewrites          uint64 `json:"argument_rewrites"`
+	ContentFlags              uint64 `json:"content_flags"`
+	ResponsesSanitized        uint64 `json:"responses_sanitized"`
+	LargeResultsScanned       uint64 `json:"large_results_scanned"`
+	ToolsWithheld             uint64 `json:"tools_withheld"`
+	ToolsChanged              uint64 `json:"tools_changed"`
+	ChainedRequests           uint64 `json:"chained_requests"`
+	ChainRejected             uint64 `json:"chain_rejected"`
+	ChecksDeferred            uint64 `json:"checks_deferred"`
+	RateLimited               uint64 `json:"rate_limited"`
+	Downgrades                uint64 `json:"downgrades"`
+	TaintedCalls              uint64 `json:"tainted_calls"`
+	IgnoredBlocks             uint64 `json:"ignored_blocks"`
+	AuditErrors               uint64 `json:"audit_errors"`
+	ConformanceViolations     uint64 `json:"conformance_violations"`
+	ConcurrencyLimited        uint64 `json:"concurrency_limited"`
+	SchemaViolations          uint64 `json:"schema_violations"`
+	GasExhausted              uint64 `json:"gas_exhausted"`
+	CallDepthExceeded         uint64 `json:"call_depth_exceeded"`
+	NotificationsBlocked      uint64 `json:"notifications_blocked"`
+	SamplingRequests          uint64 `json:"sampling_requests"`
+	SamplingRefused           uint64 `json:"sampling_refused"`
+	ContextTrimmed            uint64 `json:"context_trimmed"`
+	ElicitationsDeclined      uint64 `json:"elicitations_declined"`
+	ElicitationFieldsStripped uint64 `json:"elicitation_fields_stripped"`
+	RootsRefused              uint64 `json:"roots_refused"`
+	RootsFiltered             uint64 `json:"roots_filtered"`
+	ResourcesRejected         uint64 `json:"resources_rejected"`
+	ToolDescriptionsFlagged   uint64 `json:"tool_descriptions_flagged"`
+	Normalized                uint64 `json:"normalized"`
+	ProxyLoops                uint64 `json:"proxy_loops"`
+	BlocksExplained           uint64 `json:"blocks_explained"`
+	BytesFromClient           uint64 `json:"bytes_from_client"`
+	BytesToClient             uint64 `json:"bytes_to_client"`
+
+	// Server-to-client direction (NewWithTransports only)
+	FromServer         uint64 `json:"from_server"`
+	RelayedToClient    uint64 `json:"relayed_to_client"`
+	RelayedToServer    uint64 `json:"relayed_to_server"`
+	UnmatchedResponses uint64 `json:"unmatched_responses"`
+
+	// Response correlation (NewWithTransports only, except
+	// UnmatchedResponses)
+	DuplicateResponses      uint64 `json:"duplicate_responses"`
+	LateResponses           uint64 `json:"late_responses"`
+	ClientResponsesRejected uint64 `json:"client_responses_rejected"`
+	RequestTimeouts         uint64 `json:"request_timeouts"`
+	PartialResults          uint64 `json:"partial_results"`
+	OrphanedRequests        uint64 `json:"orphaned_requests"`
+	OrphanedRelayed         uint64 `json:"orphaned_relayed"`
+
+	// Methods counts finished decisions by JSON-RPC method
+	Methods map[string]VerdictCounts `json:"methods"`
+
+	// Tools counts finished tools/call decisions by tool name
+	Tools map[string]VerdictCounts `json:"tools"`
+}
+
+// Stats returns a snapshot of the router's statistics.
+//
+// The Methods and Tools counts include every decision finished before
+// the call, however recently. Counters bumped when a message arrives
+// are read last, so MessagesReceived is never less than the decisions
+// in Methods or the messages forwarded, blocked, or failed.
+//
+// # Thread Safety
+//
+// Stats may be called concurrently with routing; messages still being
+// routed are counted as received only.
+func (r *Router) Stats() StatsSnapshot {
+	c := &r.stats
+	s := StatsSnapshot{
+		MessagesForwarded:         c.MessagesForwarded.Load(),
+		MessagesBlocked:           c.MessagesBlocked.Load(),
+		Errors:                    c.Errors.Load(),
+		ServedFromStore:           c.ServedFromStore.Load(),
+		RegistrySkipped:           c.RegistrySkipped.Load(),
+		Overloaded:                c.Overloaded.Load(),
+		ArgumentRewrites:          c.ArgumentRewrites.Load(),
+		ContentFlags:              c.ContentFlags.Load(),
+		ResponsesSanitized:        c.ResponsesSanitized.Load(),
+		LargeResultsScanned:       c.LargeResultsScanned.Load(),
+		ToolsWithheld:             c.ToolsWithheld.Load(),
+		ToolsChanged:              c.ToolsChanged.Load(),
+		ChainedRequests:           c.ChainedRequests.Load(),
+		ChainRejected:             c.ChainRejected.Load(),
+		ChecksDeferred:            c.ChecksDeferred.Load(),
+		RateLimited:               c.RateLimited.Load(),
+		Downgrades:                c.Downgrades.Load(),
+		TaintedCalls:              c.TaintedCalls.Load(),
+		IgnoredBlocks:             c.IgnoredBlocks.Load(),
+		AuditErrors:               c.AuditErrors.Load(),
+		ConformanceViolations:     c.ConformanceViolations.Load(),
+		ConcurrencyLimited:        c.ConcurrencyLimited.Load(),
+		SchemaViolations:          c.SchemaViolations.Load(),
+		GasExhausted:              c.GasExhausted.Load(),
+		CallDepthExceeded:         c.CallDepthExceeded.Load(),
+		NotificationsBlocked:      c.NotificationsBlocked.Load(),
+		SamplingRequests:          c.SamplingRequests.Load(),
+		SamplingRefused:           c.SamplingRefused.Load(),
+		ContextTrimmed:            c.ContextTrimmed.Load(),
+		ElicitationsDeclined:      c.ElicitationsDeclined.Load(),
+		ElicitationFieldsStripped: c.ElicitationFieldsStripped.Load(),
+		RootsRefused:              c.RootsRefused.Load(),
+		RootsFiltered:             c.RootsFiltered.Load(),
+		ResourcesRejected:         c.ResourcesRejected.Load(),
+		ToolDescriptionsFlagged:   c.ToolDescriptionsFlagged.Load(),
+		Normalized:                c.Normalized.Load(),
+		ProxyLoops:                c.ProxyLoops.Load(),
+		BlocksExplained:           c.BlocksExplained.Load(),
+		BytesFromClient:           c.BytesFromClient.Load(),
+		BytesToClient:             c.BytesToClient.Load(),
+		RelayedToClient:           c.RelayedToClient.Load(),
+		RelayedToServer:           c.RelayedToServer.Load(),
+		UnmatchedResponses:        c.UnmatchedResponses.Load(),
+		DuplicateResponses:        c.DuplicateResponses.Load(),
+		LateResponses:             c.LateResponses.Load(),
+		RequestTimeouts:           c.RequestTimeouts.Load(),
+		PartialResults:            c.PartialResults.Load(),
+
+		ClientResponsesRejected: c.ClientResponsesRejected.Load(),
+		OrphanedRequests:        c.OrphanedRequests.Load(),
+		OrphanedRelayed:         c.OrphanedRelayed.Load(),
+	}
+	s.Methods, s.Tools = c.breakdown.snapshot()
+	// Counted on arrival, so read last
+	s.ToolCalls = c.ToolCalls.Load()
+	s.FromServer = c.FromServer.Load()
+	s.MessagesReceived = c.MessagesReceived.Load()
+	s.Time = time.Now()
+	return s
+}
+
+// GetStats returns a snapshot of current statistics.
+func (r *Router) GetStats() (received, forwarded, blocked, errors uint64) {
+	return r.stats.MessagesReceived.Load(),
+		r.stats.MessagesForwarded.Load(),
+		r.stats.MessagesBlocked.Load(),
+		r.stats.Errors.Load()
+}
+
+// statsSample is one finished decision awaiting aggregation.
+type statsSample struct {
+	method  string
+	tool    string
+	verdict Verdict
+}
+
+// statsAggregator keeps the per-method and per-tool counts.
+//
+// Recording a decision only appends it to a pending batch; a timer folds
+// the batch into the counts in the background, so the routing path
+// never hashes names under a shared lock. Stats folds the pending batch
+// itself before copying, so snapshots are never stale.
+//
+// # Security Notes
+//
+// Method and tool names come from the client. Only the first
+// maxStatsKeys names of each are counted separately, so a client cannot
+// grow the counts without bound by inventing names.
+type statsAggregator struct {
+	// mu guards pending and armed
+	mu      sync.Mutex
+	pending []statsSample
+	armed   bool
+
+	// foldMu guards the counts and spare, and serializes folds
+	foldMu  sync.Mutex
+	spare   []statsSample
+	methods map[string]*VerdictCounts
+	tools   map[string]*VerdictCounts
+}
+
+// observe queues a finished decision for aggregation.
+func (a *statsAggregator) observe(d *Decision) {
+	a.mu.Lock()
+	a.pending = append(a.pending, statsSample{method: d.Method, tool: d.Tool, verdict: d.Verdict})
+	if !a.armed {
+		a.armed = true
+		time.AfterFunc(statsFoldDelay, a.fold)
+	}
+	a.mu.Unlock()
+}
+
+// fold adds the pending batch to the counts.
+func (a *statsAggregator) fold() {
+	a.foldMu.Lock()
+	defer a.foldMu.Unlock()
+	a.foldLocked()
+}
+
+// foldLocked swaps out the pending batch and counts it. The caller holds
+// foldMu.
+func (a *statsAggregator) foldLocked() {
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = a.spare[:0]
+	a.armed = false
+	a.mu.Unlock()
+
+	if a.methods == nil {
+		a.methods = make(map[string]*VerdictCounts)
+		a.tools = make(map[string]*VerdictCounts)
+	}
+	for i := range batch {
+		sample := &batch[i]
+		countIn(a.methods, sample.method).add(sample.verdict)
+		if sample.tool != "" {
+			countIn(a.tools, sample.tool).add(sample.verdict)
+		}
+		*sample = statsSample{}
+	}
+	a.spare = batch[:0]
+}
+
+// snapshot folds the pending batch and copies the counts.
+func (a *statsAggregator) snapshot() (methods, tools map[string]VerdictCounts) {
+	a.foldMu.Lock()
+	defer a.foldMu.Unlock()
+	a.foldLocked()
+	return copyCounts(a.methods), copyCounts(a.tools)
+}
+
+// countIn returns the counts for key, or for StatsOther once m holds
+// maxStatsKeys names.
+func countIn(m map[string]*VerdictCounts, key string) *VerdictCounts {
+	if c, ok := m[key]; ok {
+		return c
+	}
+	if len(m) >= maxStatsKeys {
+		key = StatsOther
+		if c, ok := m[key]; ok {
+			return c
+		}
+	}
+	c := &VerdictCounts{}
+	m[key] = c
+	return c
+}
+
+// copyCounts copies a count map by value.
+func copyCounts(m map[string]*VerdictCounts) map[string]VerdictCounts {
+	out := make(map[string]VerdictCounts, len(m))
+	for k, c := range m {
+		out[k] = *c
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// councilDenyBackend allows every check but the council vote.
+type councilDenyBackend struct{ poisonBackend }
+
+func (b *councilDenyBackend) VoteCouncil(context.Context, *sentinel.CouncilVoteRequest) (*sentinel.CheckResult, error) {
+	return &sentinel.CheckResult{Allowed: false, Reason: "council denied"}, nil
+}
+
+func TestStats_Snapshot(t *testing.T) {
+	client := sentinel.NewFusedClient(nil, sentinel.Member{Name: "test", Backend: &councilDenyBackend{}})
+	r := New(&mockTransport{}, client)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		resp, _ := jsonrpc.NewResponse(msg.ID, map[string]string{"status": "ok"})
+		return jsonrpc.Serialize(resp)
+	}
+	route := func(method string, params interface{}) {
+		req, _ := jsonrpc.NewRequest(method, params, 1)
+		data, _ := jsonrpc.Serialize(req)
+		r.RouteMessage(data)
+	}
+
+	route("tools/list", nil)
+	route("tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "/tmp/a"}})
+	route("tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "/tmp/b"}})
+	route("tools/call", map[string]interface{}{"name": "execute_command", "arguments": map[string]interface{}{"command": "ls"}})
+	r.RouteMessage([]byte("not json"))
+
+	s := r.Stats()
+	if s.MessagesReceived != 5 || s.ToolCalls != 3 || s.MessagesForwarded+s.MessagesBlocked+s.Errors != 5 {
+		t.Errorf("totals = %+v", s)
+	}
+	calls := s.Methods["tools/call"]
+	if calls != (VerdictCounts{Total: 3, Allowed: 2, Blocked: 1}) {
+		t.Errorf("tools/call counts = %+v", calls)
+	}
+	if s.Methods["tools/list"] != (VerdictCounts{Total: 1, Allowed: 1}) {
+		t.Errorf("tools/list counts = %+v", s.Methods["tools/list"])
+	}
+	if s.Tools["read_file"] != (VerdictCounts{Total: 2, Allowed: 2}) || s.Tools["execute_command"] != (VerdictCounts{Total: 1, Blocked: 1}) || len(s.Tools) != 2 {
+		t.Errorf("tool counts = %+v", s.Tools)
+	}
+	var total uint64
+	for _, c := range s.Methods {
+		total += c.Total
+	}
+	if total != s.MessagesReceived {
+		t.Errorf("method totals add up to %d, expected %d", total, s.MessagesReceived)
+	}
+
+	// The snapshot is a copy
+	s.Methods["tools/call"] = VerdictCounts{}
+	if r.Stats().Methods["tools/call"] != calls {
+		t.Error("modifying a snapshot changed the router's counts")
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("snapshot does not encode: %v", err)
+	}
+}
+
+func TestStats_Aggregator(t *testing.T) {
+	var a statsAggregator
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				a.observe(&Decision{Method: "tools/call", Tool: fmt.Sprintf("tool-%d", i), Verdict: VerdictAllowed})
+				if i%100 == 0 {
+					a.fold()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	methods, tools := a.snapshot()
+	if methods["tools/call"].Total != 4000 || methods["tools/call"].Allowed != 4000 {
+		t.Errorf("tools/call counts = %+v", methods["tools/call"])
+	}
+	if len(tools) != maxStatsKeys+1 {
+		t.Errorf("counted %d tools, expected %d plus %s", len(tools), maxStatsKeys, StatsOther)
+	}
+	var total uint64
+	for _, c := range tools {
+		total += c.Total
+	}
+	if total != 4000 || tools[StatsOther].Total == 0 {
+		t.Errorf("tool totals = %d, other = %+v", total, tools[StatsOther])
+	}
+}
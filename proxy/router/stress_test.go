@@ -0,0 +1,275 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// Stress parameters. Run a heavier pass with, for example:
+//
+//	go test -race -run TestStress ./router -stress.sessions=256 -stress.messages=500
+var (
+	stressSessions = flag.Int("stress.sessions", 24, "concurrent synthetic sessions in TestStress")
+	stressMessages = flag.Int("stress.messages", 120, "messages per synthetic session in TestStress")
+	stressSeed     = flag.Int64("stress.seed", 0, "random seed for TestStress (0 picks one)")
+)
+
+// stressTools mixes high-risk tools (council checks) with cheap ones.
+var stressTools = []string{"read_file", "write_file", "execute_command", "list_directory", "search", "shell"}
+
+// flakyBackend randomly blocks or fails checks.
+type flakyBackend struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (b *flakyBackend) roll() (*sentinel.CheckResult, error) {
+	b.mu.Lock()
+	n := b.rng.Intn(20)
+	b.mu.Unlock()
+	switch n {
+	case 0:
+		return nil, errors.New("backend unavailable")
+	case 1:
+		return &sentinel.CheckResult{Allowed: false, Reason: "synthetic block"}, nil
+	}
+	return &sentinel.CheckResult{Allowed: true, Reason: "ok"}, nil
+}
+
+func (b *flakyBackend) CheckRegistry(context.Context, *sentinel.RegistryCheckRequest) (*sentinel.CheckResult, error) {
+	return b.roll()
+}
+
+func (b *flakyBackend) CheckState(context.Context, *sentinel.StateCheckRequest) (*sentinel.CheckResult, error) {
+	return b.roll()
+}
+
+func (b *flakyBackend) VoteCouncil(context.Context, *sentinel.CouncilVoteRequest) (*sentinel.CheckResult, error) {
+	return b.roll()
+}
+
+// stressClient feeds a scripted message sequence to a router and
+// collects what the router sends back.
+type stressClient struct {
+	ctx     context.Context
+	inbound chan []byte
+
+	mu   sync.Mutex
+	sent int
+}
+
+func (c *stressClient) Send([]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent++
+	return nil
+}
+
+func (c *stressClient) Receive() ([]byte, error) {
+	select {
+	case data, ok := <-c.inbound:
+		if !ok {
+			return nil, errors.New("client closed")
+		}
+		return data, nil
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+}
+
+func (c *stressClient) Close() error { return nil }
+
+// stressMessage returns a random message and whether it expects a reply.
+func stressMessage(rng *rand.Rand, id int) (string, bool) {
+	tool := stressTools[rng.Intn(len(stressTools))]
+	switch rng.Intn(10) {
+	case 0:
+		return `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`, false
+	case 1:
+		return `{"jsonrpc":"2.0","id":` + fmt.Sprint(id) + `,"method":"tools/call"`, true // truncated
+	case 2:
+		return `{"jsonrpc":"2.0","id":` + fmt.Sprint(id) + `,"result":{}}`, true // response sent as request
+	case 3:
+		return `{"jsonrpc":"2.0","id":` + fmt.Sprint(id) + `,"method":"tools/list"}`, true
+	case 4:
+		return `{"jsonrpc":"2.0","id":` + fmt.Sprint(id) + `,"method":"tools/call","params":{}}`, true
+	}
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":{"n":%d}}}`,
+		id, tool, rng.Intn(100)), true
+}
+
+// TestStress drives many concurrent synthetic sessions with random
+// tool sequences, malformed messages, upstream failures, and
+// cancellations, then checks per-session invariants. It is most useful
+// under the race detector.
+func TestStress(t *testing.T) {
+	sessions, messages := *stressSessions, *stressMessages
+	if testing.Short() {
+		sessions, messages = 4, 30
+	}
+	seed := *stressSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("stress seed %d", seed)
+
+	backend := &flakyBackend{rng: rand.New(rand.NewSource(seed))}
+	client := sentinel.NewFusedClient(nil, sentinel.Member{Name: "flaky", Backend: backend})
+
+	var wg sync.WaitGroup
+	for s := 0; s < sessions; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			runStressSession(t, client, rand.New(rand.NewSource(seed+int64(s))), s, messages)
+		}(s)
+	}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(60 * time.Second):
+		t.Fatal("sessions stuck: stress run did not finish")
+	}
+}
+
+// runStressSession runs one session and checks its invariants.
+func runStressSession(t *testing.T, client *sentinel.Client, rng *rand.Rand, s, messages int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := DefaultConfig()
+	cfg.DecisionLogSize = messages + 1
+	if s%2 == 1 {
+		cfg.Pipeline = &PipelineConfig{IngressDepth: 8, EgressDepth: 8}
+	}
+	c := &stressClient{ctx: ctx, inbound: make(chan []byte)}
+	r := NewWithConfig(c, client, cfg)
+
+	var upstreamMu sync.Mutex
+	upstreamRng := rand.New(rand.NewSource(rng.Int63()))
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		upstreamMu.Lock()
+		n := upstreamRng.Intn(20)
+		upstreamMu.Unlock()
+		switch n {
+		case 0:
+			return nil, errors.New("upstream reset")
+		case 1:
+			time.Sleep(time.Millisecond)
+		}
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`), nil
+	}
+	r.notifyFunc = func([]byte) error { return nil }
+
+	// A quarter of the sessions are cancelled part way through
+	cancelAt := -1
+	if s%4 == 0 {
+		cancelAt = rng.Intn(messages)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+
+	var sent, expectReplies int
+	for i := 0; i < messages; i++ {
+		if i == cancelAt {
+			cancel()
+			break
+		}
+		msg, reply := stressMessage(rng, i)
+		select {
+		case c.inbound <- []byte(msg):
+			sent++
+			if reply {
+				expectReplies++
+			}
+		case <-ctx.Done():
+		}
+	}
+	if cancelAt < 0 {
+		close(c.inbound)
+	}
+
+	select {
+	case <-runErr:
+	case <-time.After(10 * time.Second):
+		t.Errorf("session %d: Run did not return", s)
+		return
+	}
+
+	checkStressInvariants(t, r, s, sent, expectReplies, c, cancelAt < 0)
+}
+
+// checkStressInvariants verifies gas, stats, and reply accounting.
+func checkStressInvariants(t *testing.T, r *Router, s, sent, expectReplies int, c *stressClient, completed bool) {
+	decisions := r.RecentDecisions(sent + 1)
+	received, forwarded, blocked, errs := r.GetStats()
+
+	// Every message delivered to Run was routed once or rejected as
+	// overload; overload rejections of requests are error decisions
+	// outside the received count
+	overloaded := r.stats.Overloaded.Load()
+	if completed && received+overloaded != uint64(sent) {
+		t.Errorf("session %d: sent %d, received %d, overloaded %d", s, sent, received, overloaded)
+	}
+	rejected := uint64(len(decisions)) - received
+	if uint64(len(decisions)) < received || rejected > overloaded {
+		t.Errorf("session %d: %d decisions for %d received, %d overloaded", s, len(decisions), received, overloaded)
+	}
+
+	// Stats agree with decision verdicts
+	var allowed, denied, failed uint64
+	var charged uint64
+	for _, d := range decisions {
+		switch d.Verdict {
+		case VerdictAllowed:
+			allowed++
+			if d.Method == "tools/call" && d.gas == nil {
+				t.Errorf("session %d: allowed tool call %s was not charged gas", s, d.ID)
+			}
+		case VerdictBlocked:
+			denied++
+		case VerdictError:
+			failed++
+		}
+		if d.gas != nil {
+			charged += d.gas.amount
+		}
+	}
+	if allowed != forwarded || denied != blocked || failed != errs+rejected {
+		t.Errorf("session %d: verdicts allowed=%d blocked=%d error=%d, stats forwarded=%d blocked=%d errors=%d",
+			s, allowed, denied, failed, forwarded, blocked, errs)
+	}
+
+	// No gas is charged outside a recorded decision or lost in races
+	if used := r.gasUsed.Load(); used != charged {
+		t.Errorf("session %d: gas used %d, decisions charged %d", s, used, charged)
+	}
+
+	// Completed sessions answer every request. Overload drops messages
+	// that are not well-formed requests, since they have no ID to answer.
+	if completed {
+		c.mu.Lock()
+		replies := uint64(c.sent)
+		c.mu.Unlock()
+		if replies > uint64(expectReplies) || replies+overloaded-rejected < uint64(expectReplies) {
+			t.Errorf("session %d: %d replies for %d requests (%d overloaded)", s, replies, expectReplies, overloaded)
+		}
+	}
+
+	// Decisions must serialize cleanly for audit export
+	if _, err := json.Marshal(decisions); err != nil {
+		t.Errorf("session %d: decisions not serializable: %v", s, err)
+	}
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"log"
+	"time"
+)
+
+// NotifySessionSummary is sent to the client when a session ends and
+// SummaryNotify is configured.
+const NotifySessionSummary = "notifications/sentinel/session_summary"
+
+// SummaryMode selects how the end-of-session summary is surfaced.
+type SummaryMode string
+
+const (
+	// SummaryOff produces no summary (default)
+	SummaryOff SummaryMode = ""
+	// SummaryLog writes the summary to the proxy log
+	SummaryLog SummaryMode = "log"
+	// SummaryNotify logs the summary and sends it to the client
+	SummaryNotify SummaryMode = "notify"
+)
+
+// Summary describes what the sentinel did during a session, in terms an
+// end user can read: how many calls were checked, what was blocked or
+// changed, and how close the session came to its limits.
+type Summary struct {
+	SessionID        string        `json:"session_id"`
+	Started          time.Time     `json:"started"`
+	Duration         time.Duration `json:"duration_ns"`
+	Messages         uint64        `json:"messages"`
+	ToolCalls        uint64        `json:"tool_calls"`
+	Blocked          uint64        `json:"blocked"`
+	ArgumentRewrites uint64        `json:"argument_rewrites"`
+	ContentFlags     uint64        `json:"content_flags"`
+	Overloaded       uint64        `json:"overloaded"`
+	Errors           uint64        `json:"errors"`
+	GasUsed          uint64        `json:"gas_used"`
+	ContextBytes     uint64        `json:"context_bytes"`
+	Anomalies        int           `json:"anomalies"`
+	AnomalyScore     float64       `json:"anomaly_score"`
+	DegradationLevel string        `json:"degradation_level"`
+	Terminated       bool          `json:"terminated"`
+}
+
+// Summary returns the session's security summary so far.
+func (r *Router) Summary() Summary {
+	received, _, blocked, errs := r.GetStats()
+	s := Summary{
+		SessionID:        r.sessionID,
+		Started:          r.started,
+		Duration:         time.Since(r.started),
+		Messages:         received,
+		ToolCalls:        r.stats.ToolCalls.Load(),
+		Blocked:          blocked,
+		ArgumentRewrites: r.stats.ArgumentRewrites.Load(),
+		ContentFlags:     r.stats.ContentFlags.Load(),
+		Overloaded:       r.stats.Overloaded.Load(),
+		Errors:           errs,
+		GasUsed:          r.gasUsed.Load(),
+		ContextBytes:     r.contextBytes.Load(),
+		DegradationLevel: r.DegradationLevel().String(),
+		Terminated:       r.terminated.Load(),
+	}
+	if r.anomaly != nil {
+		s.Anomalies = len(r.anomaly.Observations())
+		s.AnomalyScore = r.anomaly.Score()
+	}
+	return s
+}
+
+// EndSession emits the end-of-session summary according to the
+// configured SummaryMode. Run calls it when it returns; callers that
+// drive RouteMessage directly should call it when the session closes.
+// Only the first call emits anything.
+func (r *Router) EndSession() {
+	r.endOnce.Do(func() {
+		if r.tofuSession != nil {
+			r.tofuSession.cancel()
+		}
+		if r.stateStore != nil {
+			r.saveState()
+		}
+		if r.summaryMode == SummaryOff {
+			return
+		}
+		s := r.Summary()
+		log.Printf("router: session %s summary: %d tool calls, %d blocked, %d rewritten, %d flagged, gas %d, %d anomalies (score %.2f), terminated=%v",
+			s.SessionID, s.ToolCalls, s.Blocked, s.ArgumentRewrites, s.ContentFlags, s.GasUsed, s.Anomalies, s.AnomalyScore, s.Terminated)
+		if r.summaryMode == SummaryNotify {
+			if err := r.notify(NotifySessionSummary, s); err != nil {
+				log.Printf("router: failed to send session summary: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestEndSession_NotifiesSummaryOnce(t *testing.T) {
+	var notes []*jsonrpc.Message
+	mt := &mockTransport{sendFunc: func(data []byte) error {
+		msg, _ := jsonrpc.Parse(data)
+		notes = append(notes, msg)
+		return nil
+	}}
+	cfg := DefaultConfig()
+	cfg.SessionSummary = SummaryNotify
+	r := NewWithConfig(mt, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), "ok")
+		return jsonrpc.Serialize(resp)
+	}
+
+	for _, tool := range []string{"read_file", "list_directory"} {
+		req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tool, "arguments": map[string]string{}}, 1)
+		data, _ := jsonrpc.Serialize(req)
+		r.RouteMessage(data)
+	}
+
+	r.EndSession()
+	r.EndSession()
+
+	if len(notes) != 1 || notes[0].Method != NotifySessionSummary {
+		t.Fatalf("expected one summary notification, got %d", len(notes))
+	}
+	var s Summary
+	if err := json.Unmarshal(notes[0].Params, &s); err != nil {
+		t.Fatalf("bad summary params: %v", err)
+	}
+	if s.ToolCalls != 2 || s.GasUsed != 150 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
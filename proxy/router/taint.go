@@ -0,0 +1,344 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// TaintAction is what happens to a tool call whose critical argument
+// was copied from an earlier tool result.
+type TaintAction string
+
+const (
+	// TaintFlag only records the provenance in the decision
+	TaintFlag TaintAction = "flag"
+	// TaintCouncil requires a council vote at a raised risk score
+	TaintCouncil TaintAction = "council"
+	// TaintBlock refuses the call
+	TaintBlock TaintAction = "block"
+)
+
+// DefaultCriticalArguments are the argument name patterns treated as
+// critical when TaintTracking.CriticalArguments is nil.
+var DefaultCriticalArguments = []string{
+	"url", "uri", "href", "endpoint", "*_url", "*_uri", "*url",
+	"command", "cmd", "script", "shell", "args", "argv",
+	"path", "*_path", "file", "filename", "*_file", "dir", "directory",
+	"target", "destination", "dest", "to", "recipient*",
+}
+
+// TaintTracking tracks argument provenance: values in a tool call's
+// arguments that appear verbatim in results of earlier tool calls in
+// the session.
+//
+// Tool results are the easiest place for an attacker to plant
+// instructions; a URL, command, or path that an agent copies out of a
+// fetched web page into its next call is data flowing from the attacker
+// straight into an action. Such calls are recorded in the decision
+// ("tainted_arguments"), passed to the council, and handled by Action
+// when a critical argument is entirely result-derived.
+//
+// # Security Notes
+//
+// Matching is verbatim. Values shorter than MinLength are ignored so
+// common words do not taint every call, and an attacker can evade
+// detection by having the agent transform a value. Tracking raises the
+// cost of the obvious injection path; it does not prove absence of
+// influence.
+type TaintTracking struct {
+	// MinLength is the shortest argument value (or word of a value)
+	// matched against results (zero uses 8)
+	MinLength int
+
+	// MaxBytes bounds the result text retained per session; the oldest
+	// results are forgotten first (zero uses 1 MiB)
+	MaxBytes int
+
+	// CriticalArguments are path.Match patterns for argument names
+	// (the last path element, lower case) whose taint triggers Action;
+	// URLs and absolute paths are critical whatever their name (nil
+	// uses DefaultCriticalArguments)
+	CriticalArguments []string
+
+	// Action handles calls with a fully tainted critical argument
+	// (empty uses TaintCouncil)
+	Action TaintAction
+
+	// RiskScore is the council risk score for such calls (zero uses
+	// 0.9)
+	RiskScore float64
+}
+
+// TaintedArgument records where an argument value was seen before.
+type TaintedArgument struct {
+	// Path locates the argument, e.g. "url" or "options.headers[0]"
+	Path string `json:"path"`
+
+	// Critical reports a critical argument
+	Critical bool `json:"critical"`
+
+	// Full reports that the whole value appeared in a result; otherwise
+	// only a word of it did
+	Full bool `json:"full"`
+
+	// SourceTool and SourceDecision identify the result it came from
+	SourceTool     string `json:"source_tool"`
+	SourceDecision string `json:"source_decision"`
+}
+
+// taintSource is the text of one earlier tool result.
+type taintSource struct {
+	tool       string
+	decisionID string
+	text       string
+}
+
+// taintLog holds a session's recent tool result text.
+type taintLog struct {
+	cfg TaintTracking
+
+	mu      sync.Mutex
+	sources []taintSource
+	size    int
+}
+
+// newTaintLog applies defaults to cfg.
+func newTaintLog(cfg *TaintTracking) *taintLog {
+	c := *cfg
+	if c.MinLength <= 0 {
+		c.MinLength = 8
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 1 << 20
+	}
+	if c.CriticalArguments == nil {
+		c.CriticalArguments = DefaultCriticalArguments
+	}
+	if c.Action == "" {
+		c.Action = TaintCouncil
+	}
+	if c.RiskScore == 0 {
+		c.RiskScore = 0.9
+	}
+	return &taintLog{cfg: c}
+}
+
+// record remembers the text of a delivered tool result.
+func (l *taintLog) record(tool, decisionID string, result *mcptypes.CallToolResult) {
+	var parts []string
+	for _, c := range result.Content {
+		parts = append(parts, c.Text, c.URI)
+		if c.Resource != nil {
+			parts = append(parts, c.Resource.URI, c.Resource.Text)
+		}
+	}
+	if len(result.StructuredContent) > 0 {
+		walkStrings(result.StructuredContent, func(_, s string) { parts = append(parts, s) })
+	}
+	text := strings.Join(parts, "\n")
+	if len(strings.TrimSpace(text)) < l.cfg.MinLength {
+		return
+	}
+	if len(text) > l.cfg.MaxBytes {
+		text = text[len(text)-l.cfg.MaxBytes:]
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sources = append(l.sources, taintSource{tool: tool, decisionID: decisionID, text: text})
+	l.size += len(text)
+	for l.size > l.cfg.MaxBytes {
+		l.size -= len(l.sources[0].text)
+		l.sources = l.sources[1:]
+	}
+}
+
+// trace returns the arguments whose values appear in recorded results,
+// sorted by path.
+func (l *taintLog) trace(arguments json.RawMessage) []TaintedArgument {
+	var tainted []TaintedArgument
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.sources) == 0 {
+		return nil
+	}
+	walkStrings(arguments, func(argPath, value string) {
+		if len(value) < l.cfg.MinLength {
+			return
+		}
+		src, full := l.find(value)
+		if src == nil {
+			return
+		}
+		tainted = append(tainted, TaintedArgument{
+			Path:           argPath,
+			Critical:       l.critical(argPath, value),
+			Full:           full,
+			SourceTool:     src.tool,
+			SourceDecision: src.decisionID,
+		})
+	})
+	sort.Slice(tainted, func(i, j int) bool { return tainted[i].Path < tainted[j].Path })
+	return tainted
+}
+
+// find returns the newest result containing value, or failing that one
+// of its words. Called with mu held.
+func (l *taintLog) find(value string) (*taintSource, bool) {
+	for i := len(l.sources) - 1; i >= 0; i-- {
+		if strings.Contains(l.sources[i].text, value) {
+			return &l.sources[i], true
+		}
+	}
+	for _, word := range strings.FieldsFunc(value, isWordBreak) {
+		if len(word) < l.cfg.MinLength || len(word) == len(value) {
+			continue
+		}
+		for i := len(l.sources) - 1; i >= 0; i-- {
+			if strings.Contains(l.sources[i].text, word) {
+				return &l.sources[i], false
+			}
+		}
+	}
+	return nil, false
+}
+
+// critical reports whether an argument's taint triggers the action.
+func (l *taintLog) critical(argPath, value string) bool {
+	if strings.Contains(value, "://") || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "~/") {
+		return true
+	}
+	// List elements take the name of their list
+	name := argPath
+	for strings.HasSuffix(name, "]") && strings.Contains(name, "[") {
+		name = name[:strings.LastIndex(name, "[")]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range l.cfg.CriticalArguments {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordBreak separates the words of an argument value.
+func isWordBreak(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', ';', '|', '&', '"', '\'', '`', '(', ')', '<', '>':
+		return true
+	}
+	return false
+}
+
+// walkStrings calls fn with the path and value of each string in a JSON
+// document.
+func walkStrings(raw json.RawMessage, fn func(path, value string)) {
+	var v interface{}
+	if json.Unmarshal(raw, &v) != nil {
+		return
+	}
+	var walk func(string, interface{})
+	walk = func(p string, v interface{}) {
+		switch v := v.(type) {
+		case string:
+			fn(p, v)
+		case map[string]interface{}:
+			for k, child := range v {
+				if p == "" {
+					walk(k, child)
+				} else {
+					walk(p+"."+k, child)
+				}
+			}
+		case []interface{}:
+			for i, child := range v {
+				walk(fmt.Sprintf("%s[%d]", p, i), child)
+			}
+		}
+	}
+	walk("", v)
+}
+
+// checkTaint traces a tool call's arguments to earlier results and
+// applies the taint action. It returns an error reply if the call is
+// refused.
+func (r *Router) checkTaint(d *Decision, msg *jsonrpc.Message) ([]byte, bool) {
+	var params struct {
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	json.Unmarshal(msg.Params, &params)
+
+	var tainted []TaintedArgument
+	result, _ := r.runCheck(d, CheckTaint, func() (*sentinel.CheckResult, error) {
+		tainted = r.taint.trace(params.Arguments)
+		for _, t := range tainted {
+			if t.Critical && t.Full && r.taint.cfg.Action == TaintBlock {
+				return &sentinel.CheckResult{
+					Allowed: false,
+					Reason:  fmt.Sprintf("argument %s was copied from a %s result", t.Path, t.SourceTool),
+				}, nil
+			}
+		}
+		// The audit event names what was copied from where
+		var copied []string
+		for _, t := range tainted {
+			copied = append(copied, fmt.Sprintf("%s from %s %s", t.Path, t.SourceTool, t.SourceDecision))
+		}
+		return &sentinel.CheckResult{Allowed: true, Reason: strings.Join(copied, ", ")}, nil
+	})
+	if len(tainted) > 0 {
+		r.stats.TaintedCalls.Add(1)
+		d.taint = tainted
+		d.Details = withDetailMap(d.Details, "tainted_arguments", tainted)
+		for _, t := range tainted {
+			d.finding(taintFinding(t))
+		}
+	}
+	if !result.Allowed {
+		r.stats.MessagesBlocked.Add(1)
+		reply, _ := r.errorResponse(d, VerdictBlocked, msg.ID, jsonrpc.InvalidParams, "Blocked by security", result.Reason)
+		return reply, true
+	}
+	if d.taintCritical() && r.taint.cfg.Action == TaintCouncil {
+		d.requireCouncil = true
+	}
+	return nil, false
+}
+
+// taintCritical reports whether a critical argument of the call was
+// entirely copied from a tool result.
+func (d *Decision) taintCritical() bool {
+	for _, t := range d.taint {
+		if t.Critical && t.Full {
+			return true
+		}
+	}
+	return false
+}
+
+// taintCouncil adds the call's provenance to a council vote request,
+// raising its risk score for critical taint.
+func (r *Router) taintCouncil(d *Decision, req *sentinel.CouncilVoteRequest) {
+	if d == nil || len(d.taint) == 0 {
+		return
+	}
+	if req.Context == nil {
+		req.Context = make(map[string]interface{})
+	}
+	req.Context["tainted_arguments"] = d.taint
+	if d.taintCritical() {
+		req.RiskScore = max(req.RiskScore, r.taint.cfg.RiskScore)
+	}
+}
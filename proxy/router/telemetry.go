@@ -0,0 +1,251 @@
+package router
+
+import "github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+
+// TelemetrySchemaVersion numbers the layout of TelemetrySchema. It
+// changes when a stable metric or audit field is renamed or removed.
+const TelemetrySchemaVersion = 1
+
+// Stability says what changes a metric, event kind, or field may see.
+type Stability string
+
+const (
+	// StabilityStable changes only with TelemetrySchemaVersion
+	StabilityStable Stability = "stable"
+	// StabilityBeta may change labels or meaning in a minor release
+	StabilityBeta Stability = "beta"
+	// StabilityExperimental may change or go away in any release
+	StabilityExperimental Stability = "experimental"
+)
+
+// TelemetrySchema describes the metrics and audit output of the running
+// version, for generating and validating dashboards and SIEM parsers.
+type TelemetrySchema struct {
+	// Version is TelemetrySchemaVersion
+	Version int `json:"schema_version"`
+
+	// Metrics describes each metric name
+	Metrics []MetricDesc `json:"metrics"`
+
+	// Event describes the fields of every audit event, and Events each
+	// event kind
+	Event  []FieldDesc `json:"event"`
+	Events []EventDesc `json:"events"`
+
+	// AuditRecord describes the fields of an audit trail record (see
+	// package audit)
+	AuditRecord []FieldDesc `json:"audit_record"`
+}
+
+// MetricDesc describes a metric name.
+type MetricDesc struct {
+	Name string `json:"name"`
+
+	// Type is counter or gauge
+	Type string `json:"type"`
+
+	// Help is the metric's meaning, as in its HELP line
+	Help string `json:"help"`
+
+	// Labels are the label names of every sample
+	Labels []string `json:"labels"`
+
+	// Condition says when the metric is exported, if not always
+	Condition string `json:"condition,omitempty"`
+
+	Stability Stability `json:"stability"`
+}
+
+// EventDesc describes an audit event kind.
+type EventDesc struct {
+	Kind        EventKind `json:"kind"`
+	Description string    `json:"description"`
+
+	// Fields are the keys the kind may carry in Event.Fields
+	Fields []FieldDesc `json:"fields"`
+
+	Stability Stability `json:"stability"`
+}
+
+// FieldDesc describes a JSON field.
+type FieldDesc struct {
+	Name string `json:"name"`
+
+	// Type is a JSON Schema type, or "date-time" for RFC 3339 strings
+	Type string `json:"type"`
+
+	Description string `json:"description"`
+
+	// Enum lists the values of a field with a fixed set
+	Enum []string `json:"enum,omitempty"`
+
+	// Optional fields may be absent
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Conditions of metrics not exported by every session.
+const (
+	conditionBudget   = "sessions with a gas budget"
+	conditionUpstream = "sessions relaying both directions over an upstream connection"
+)
+
+// Label sets.
+var (
+	sessionLabels   = []string{"session"}
+	directionLabels = []string{"session", "direction"}
+)
+
+// metricDescs describes the metrics of Router.Metrics.
+var metricDescs = []MetricDesc{
+	{"mcp_sentinel_messages_received_total", "counter", "Messages received from the client.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_messages_forwarded_total", "counter", "Messages forwarded to the server.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_messages_blocked_total", "counter", "Messages blocked by security checks.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_errors_total", "counter", "Routing errors.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_responses_sanitized_total", "counter", "Server responses delivered with rejected content removed.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_large_results_scanned_total", "counter", "Tool results checked with a bounded incremental scan.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_tools_withheld_total", "counter", "Listed tools withheld pending trust-on-first-use approval.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_tools_changed_total", "counter", "Listed tools whose definition changed since it was approved.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_chained_requests_total", "counter", "Requests carrying chain metadata from another sentinel.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_chain_rejected_total", "counter", "Chain metadata ignored because it failed verification.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_tainted_calls_total", "counter", "Tool calls with arguments copied from earlier tool results.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_ignored_blocks_total", "counter", "Blocked tool calls retried unchanged by the client.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_audit_errors_total", "counter", "Audit records the audit sink failed to write.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_rate_limited_total", "counter", "Requests refused by a rate limit or policy rate limit.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_downgrades_total", "counter", "Tool calls rewritten into safer ones by policy downgrade rules.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_checks_deferred_total", "counter", "Tool calls whose checks were deferred to a trusted upstream sentinel.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_conformance_violations_total", "counter", "Client protocol conformance violations.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_concurrency_limited_total", "counter", "Tool calls denied by a concurrency limit.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_schema_violations_total", "counter", "Tool calls refused because their arguments did not match the input schema.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_gas_exhausted_total", "counter", "Tool calls refused because the gas budget could not cover them.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_call_depth_exceeded_total", "counter", "Tool calls refused for nesting deeper than the maximum call depth.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_notifications_blocked_total", "counter", "Server notifications blocked before reaching the client.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_sampling_requests_total", "counter", "Server sampling requests screened.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_sampling_refused_total", "counter", "Server sampling requests refused before reaching the client.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_context_bytes", "gauge", "Tool result and resource content delivered to the client.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_context_trimmed_total", "counter", "Results trimmed because the session exceeded its context budget.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_elicitations_declined_total", "counter", "Server elicitation requests declined on the user's behalf.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_elicitation_fields_stripped_total", "counter", "Sensitive fields stripped from elicitation requests and answers.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_roots_refused_total", "counter", "Server roots/list requests answered with an empty list.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_roots_filtered_total", "counter", "Client roots withheld from servers for being outside the permitted prefixes.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_resources_rejected_total", "counter", "resources/read results refused for their size or content type.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_tool_descriptions_flagged_total", "counter", "tool metadata fields in tools/list results that matched injection rules.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_normalized_total", "counter", "client messages and tools/list results rewritten to remove invisible or look-alike characters.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_proxy_loops_total", "counter", "initialize requests refused because the server leads back to this proxy.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_blocks_explained_total", "counter", "Blocked decisions explained through the sentinel/why tool.", sessionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_client_bytes_total", "counter", "Message bytes received from the client (direction to_server) and sent to it (to_client).", directionLabels, "", StabilityExperimental},
+	{"mcp_sentinel_gas_used", "gauge", "Gas consumed by the session.", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_degradation_level", "gauge", "Current degradation ladder level (0 = full checks).", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_protection_degraded", "gauge", "Whether security checks are weaker than configured (1 = degraded).", sessionLabels, "", StabilityStable},
+	{"mcp_sentinel_session_paused", "gauge", "Whether an operator has paused the session (1 = paused).", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_paused_calls", "gauge", "Tool calls held by an operator pause.", sessionLabels, "", StabilityBeta},
+	{"mcp_sentinel_gas_budget", "gauge", "Gas budget of the session.", sessionLabels, conditionBudget, StabilityStable},
+	{"mcp_sentinel_gas_remaining", "gauge", "Gas left in the session's budget.", sessionLabels, conditionBudget, StabilityStable},
+	{"mcp_sentinel_server_messages_total", "counter", "Messages received from the server.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_relayed_total", "counter", "Server-initiated messages relayed to the client (direction to_client) and client responses relayed to the server (to_server).", directionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_unmatched_responses_total", "counter", "Server responses matching no pending request.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_duplicate_responses_total", "counter", "Server responses to requests already answered.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_late_responses_total", "counter", "Server responses to requests already timed out or abandoned.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_client_responses_rejected_total", "counter", "Client responses matching no pending server request.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_request_timeouts_total", "counter", "Requests the server did not answer in time.", sessionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_partial_results_total", "counter", "Timed-out tool calls answered with their salvaged partial output.", sessionLabels, conditionUpstream, StabilityExperimental},
+	{"mcp_sentinel_orphaned_requests_total", "counter", "Requests left unanswered past the orphan deadline, by the server (direction to_server) or the client (to_client).", directionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_pending_requests", "gauge", "Requests awaiting the server's (direction to_server) or the client's (to_client) response.", directionLabels, conditionUpstream, StabilityBeta},
+	{"mcp_sentinel_protection_degraded_reason", "gauge", "Reasons security checks are weaker than configured.", []string{"session", "reason"}, "one sample per reason while degraded", StabilityBeta},
+	{"mcp_sentinel_check_panics_total", "counter", "Panics recovered per security check.", []string{"session", "check"}, "checks that have panicked", StabilityBeta},
+	{"mcp_sentinel_check_disabled", "gauge", "Whether a security check was disabled after repeated panics.", []string{"session", "check"}, "checks that have panicked", StabilityBeta},
+	{"mcp_sentinel_queue_depth", "gauge", "Messages waiting between routing stages.", []string{"session", "queue"}, "sessions with a routing pipeline", StabilityBeta},
+	{"mcp_sentinel_queue_capacity", "gauge", "Maximum queue depth.", []string{"session", "queue"}, "sessions with a routing pipeline", StabilityBeta},
+	{"mcp_sentinel_queue_oldest_age_seconds", "gauge", "Age of the oldest queued message.", []string{"session", "queue"}, "sessions with a routing pipeline", StabilityBeta},
+	{"mcp_sentinel_queue_rejected_total", "counter", "Messages rejected because the queue was full.", []string{"session", "queue"}, "sessions with a routing pipeline", StabilityBeta},
+}
+
+// verdicts lists the Verdict values.
+var verdicts = []string{string(VerdictAllowed), string(VerdictBlocked), string(VerdictError)}
+
+// eventFields describes the fields of Event.
+var eventFields = []FieldDesc{
+	{Name: "decision_id", Type: "string", Description: "Decision the event belongs to; see the ErrorData decision_id of a refused request"},
+	{Name: "session_id", Type: "string", Description: "Session that received the message"},
+	{Name: "trace_id", Type: "string", Description: "Distributed trace of the message", Optional: true},
+	{Name: "seq", Type: "integer", Description: "Position of the event among its decision's events, from 0"},
+	{Name: "time", Type: "date-time", Description: "When the event occurred, in UTC"},
+	{Name: "kind", Type: "string", Description: "Event kind", Enum: eventKinds()},
+	{Name: "fields", Type: "object", Description: "Kind-specific fields", Optional: true},
+}
+
+// eventDescs describes the event kinds, in the order they occur.
+var eventDescs = []EventDesc{
+	{EventReceived, "A message arrived from the client.", []FieldDesc{
+		{Name: "method", Type: "string", Description: "JSON-RPC method"},
+	}, StabilityStable},
+	{EventCheck, "A security check ran, or a call was held or deferred.", []FieldDesc{
+		{Name: "check", Type: "string", Description: "Check name, or deferred or pause"},
+		{Name: "allowed", Type: "boolean", Description: "Whether the check passed the message", Optional: true},
+		{Name: "reason", Type: "string", Description: "Why the check decided as it did, or why a call was paused", Optional: true},
+		{Name: "error", Type: "string", Description: "Why the check could not run", Optional: true},
+		{Name: "proxy", Type: "string", Description: "Upstream sentinel a deferred check was left to", Optional: true},
+		{Name: "checks", Type: "array", Description: "Checks the upstream sentinel runs", Optional: true},
+		{Name: "held_ms", Type: "integer", Description: "How long a pause held the call", Optional: true},
+	}, StabilityStable},
+	{EventRewrite, "The request was rewritten before forwarding: an argument by a guardrail, or the call by a policy downgrade.", []FieldDesc{
+		{Name: "arg", Type: "string", Description: "Guardrail: argument rewritten", Optional: true},
+		{Name: "from", Type: "string", Description: "Guardrail: argument value before", Optional: true},
+		{Name: "to", Type: "string", Description: "Guardrail: argument value after", Optional: true},
+		{Name: "rule", Type: "string", Description: "Downgrade: policy rule", Optional: true},
+		{Name: "from_tool", Type: "string", Description: "Downgrade: tool called", Optional: true},
+		{Name: "to_tool", Type: "string", Description: "Downgrade: tool forwarded", Optional: true},
+		{Name: "staged", Type: "string", Description: "Downgrade: path a write was redirected to, if staged", Optional: true},
+	}, StabilityBeta},
+	{EventVerdict, "The decision on the message.", []FieldDesc{
+		{Name: "verdict", Type: "string", Description: "Decision", Enum: verdicts},
+		{Name: "reason", Type: "string", Description: "Why, for a refused message"},
+	}, StabilityStable},
+	{EventForwarded, "The message went to the server, or was answered by the proxy in its place.", []FieldDesc{
+		{Name: "local", Type: "string", Description: "What answered the message locally", Optional: true},
+	}, StabilityStable},
+	{EventBlocked, "The message was refused by a check.", nil, StabilityStable},
+	{EventFailed, "The message could not be handled.", []FieldDesc{
+		{Name: "error", Type: "string", Description: "What failed", Optional: true},
+	}, StabilityStable},
+}
+
+// auditRecordFields describes the fields of audit.Record.
+var auditRecordFields = []FieldDesc{
+	{Name: "seq", Type: "integer", Description: "Position in the hash chain, from 1", Optional: true},
+	{Name: "time", Type: "date-time", Description: "When the message was received"},
+	{Name: "session", Type: "string", Description: "Session that carried the message"},
+	{Name: "direction", Type: "string", Description: "Which way the message travelled", Enum: []string{string(audit.ClientToServer), string(audit.ServerToClient)}},
+	{Name: "method", Type: "string", Description: "JSON-RPC method", Optional: true},
+	{Name: "tool", Type: "string", Description: "Tool of a tools/call", Optional: true},
+	{Name: "decision_id", Type: "string", Description: "Decision record of a routed request", Optional: true},
+	{Name: "trace_id", Type: "string", Description: "Distributed trace of the message", Optional: true},
+	{Name: "decision", Type: "string", Description: "Verdict, or what happened to a message without one",
+		Enum: append(append([]string(nil), verdicts...), audit.DecisionRelayed, audit.DecisionOrphaned, audit.DecisionDropped)},
+	{Name: "reason", Type: "string", Description: "Why the message was refused", Optional: true},
+	{Name: "reasons", Type: "array", Description: "Each check's reason, including checks that passed", Optional: true},
+	{Name: "arguments", Type: "string", Description: "Tool call arguments, truncated or encrypted as configured", Optional: true},
+	{Name: "result", Type: "string", Description: "Tool call result, truncated or encrypted as configured", Optional: true},
+	{Name: "original", Type: "string", Description: "Server content rewritten before delivery, as JSON, encrypted as configured", Optional: true},
+	{Name: "latency_ms", Type: "number", Description: "Time from receipt to reply"},
+	{Name: "added_latency_ms", Type: "number", Description: "Part of latency_ms spent in the proxy"},
+	{Name: "prev", Type: "string", Description: "Hash of the previous record in the chain", Optional: true},
+}
+
+// eventKinds lists the EventKind values.
+func eventKinds() []string {
+	return []string{string(EventReceived), string(EventCheck), string(EventRewrite), string(EventVerdict),
+		string(EventForwarded), string(EventBlocked), string(EventFailed)}
+}
+
+// DescribeTelemetry returns the schema of the router's metrics, audit
+// events, and audit records. Callers exporting metrics of their own
+// append their descriptions to Metrics.
+func DescribeTelemetry() TelemetrySchema {
+	return TelemetrySchema{
+		Version:     TelemetrySchemaVersion,
+		Metrics:     append([]MetricDesc(nil), metricDescs...),
+		Event:       eventFields,
+		Events:      eventDescs,
+		AuditRecord: auditRecordFields,
+	}
+}
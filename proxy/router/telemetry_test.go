@@ -0,0 +1,84 @@
+package router
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/audit"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestDescribeTelemetry_Metrics(t *testing.T) {
+	described := make(map[string]MetricDesc)
+	for _, m := range DescribeTelemetry().Metrics {
+		if _, dup := described[m.Name]; dup {
+			t.Errorf("%s described twice", m.Name)
+		}
+		described[m.Name] = m
+	}
+
+	budgeted := DefaultConfig()
+	budgeted.GasBudget = 1000
+	pipelined := DefaultConfig()
+	pipelined.Pipeline = &PipelineConfig{IngressDepth: 1, EgressDepth: 1}
+	routers := map[string]*Router{
+		"upstream": NewWithTransports(&mockTransport{}, &mockTransport{}, sentinel.NewClient(), budgeted),
+		"pipeline": NewWithConfig(&mockTransport{}, sentinel.NewClient(), pipelined),
+	}
+	for name, r := range routers {
+		for _, m := range r.Metrics() {
+			desc, ok := described[m.Name]
+			if !ok {
+				t.Errorf("%s: metric %s is not described", name, m.Name)
+				continue
+			}
+			labels := slices.Sorted(maps.Keys(m.Labels))
+			expected := slices.Sorted(slices.Values(desc.Labels))
+			if m.Type != desc.Type || !slices.Equal(labels, expected) {
+				t.Errorf("%s: metric %s is a %s labeled %v, described as a %s labeled %v", name, m.Name, m.Type, labels, desc.Type, expected)
+			}
+		}
+	}
+}
+
+func TestDescribeTelemetry_Audit(t *testing.T) {
+	schema := DescribeTelemetry()
+
+	// Every audit.Record field is described, in order
+	var fields []string
+	rt := reflect.TypeOf(audit.Record{})
+	for i := 0; i < rt.NumField(); i++ {
+		fields = append(fields, strings.Split(rt.Field(i).Tag.Get("json"), ",")[0])
+	}
+	var recordFields []string
+	for _, f := range schema.AuditRecord {
+		recordFields = append(recordFields, f.Name)
+	}
+	if !slices.Equal(fields, recordFields) {
+		t.Errorf("audit record fields described %v, expected %v", recordFields, fields)
+	}
+
+	fields = nil
+	rt = reflect.TypeOf(Event{})
+	for i := 0; i < rt.NumField(); i++ {
+		fields = append(fields, strings.Split(rt.Field(i).Tag.Get("json"), ",")[0])
+	}
+	var eventFields []string
+	for _, f := range schema.Event {
+		eventFields = append(eventFields, f.Name)
+	}
+	if !slices.Equal(fields, eventFields) {
+		t.Errorf("event fields described %v, expected %v", eventFields, fields)
+	}
+
+	var kinds []string
+	for _, e := range schema.Events {
+		kinds = append(kinds, string(e.Kind))
+	}
+	if !slices.Equal(kinds, eventKinds()) {
+		t.Errorf("event kinds described %v, expected %v", kinds, eventKinds())
+	}
+}
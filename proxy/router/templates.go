@@ -0,0 +1,322 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// ErrURITemplate is returned for a malformed RFC 6570 URI template.
+var ErrURITemplate = errors.New("router: malformed URI template")
+
+// maxResourceTemplates bounds the templates remembered per session.
+const maxResourceTemplates = 1024
+
+// maxDecodeRounds bounds how often a template value is percent-decoded
+// looking for a traversal.
+const maxDecodeRounds = 3
+
+// ResourceTemplatePolicy checks the values a client substitutes into
+// the server's resource URI templates.
+//
+// The router learns the templates from resources/templates/list
+// responses, adding any listed in Templates. When a resources/read or
+// resources/subscribe URI matches a template, each variable's value is
+// refused if, at any of up to three rounds of percent-decoding, it:
+//   - Contains a ".." path segment, with / or \ as separator
+//   - Contains a NUL or other control character
+//   - Starts a new absolute path where the template joins it under a
+//     directory
+//   - Fails the pattern given for its variable in Variables (matched
+//     against the fully decoded value)
+//
+// # Security Notes
+//
+// A template such as file:///srv/docs/{name} invites a client, or a
+// model steering it, to expand name to ../../etc/passwd: the server
+// typically joins the value onto a directory and reads whatever it
+// names. A URI matching several templates must pass all of them, since
+// the router cannot tell which the server will pick. URIs matching no
+// template are not checked here; pin their schemes with URISchemePolicy.
+type ResourceTemplatePolicy struct {
+	// Variables maps template variable names to patterns their values
+	// must match; anchor them (^...$) to constrain the whole value
+	Variables map[string]*regexp.Regexp
+
+	// Templates lists URI templates to enforce even if the server
+	// never lists them
+	Templates []string
+}
+
+// templateExpr is one {...} expression of a URI template.
+type templateExpr struct {
+	// op is the RFC 6570 operator, or 0 for simple expansion
+	op byte
+	// vars are the variable names without modifiers
+	vars []string
+	// underDir is set when the expression follows a literal ending in
+	// a path separator, so its value is joined under a directory
+	underDir bool
+}
+
+// uriTemplate is a parsed URI template.
+type uriTemplate struct {
+	raw   string
+	re    *regexp.Regexp
+	exprs []templateExpr
+}
+
+// ValidateURITemplate reports whether template is a well-formed RFC
+// 6570 URI template.
+func ValidateURITemplate(template string) error {
+	_, err := parseURITemplate(template)
+	return err
+}
+
+// parseURITemplate compiles template into a pattern matching its
+// expansions, with a capture group per expression.
+func parseURITemplate(template string) (*uriTemplate, error) {
+	t := &uriTemplate{raw: template}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	rest := template
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("%w: unbalanced '}' in %q", ErrURITemplate, template)
+		}
+		literal := rest[:open]
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] != '}' {
+			return nil, fmt.Errorf("%w: unterminated expression in %q", ErrURITemplate, template)
+		}
+		body := rest[open+1 : open+1+end]
+		rest = rest[open+1+end+1:]
+
+		var expr templateExpr
+		if body != "" && strings.IndexByte("+#./;?&", body[0]) >= 0 {
+			expr.op, body = body[0], body[1:]
+		}
+		for _, name := range strings.Split(body, ",") {
+			name = strings.TrimSuffix(name, "*")
+			if i := strings.IndexByte(name, ':'); i >= 0 {
+				name = name[:i]
+			}
+			if name == "" || strings.ContainsAny(name, " {}") {
+				return nil, fmt.Errorf("%w: bad variable in %q", ErrURITemplate, template)
+			}
+			expr.vars = append(expr.vars, name)
+		}
+		expr.underDir = expr.op == '/' || (strings.HasSuffix(literal, "/") && !strings.HasSuffix(literal, "//"))
+		t.exprs = append(t.exprs, expr)
+
+		switch expr.op {
+		case 0, '+':
+			pattern.WriteString("(.*?)")
+		default:
+			pattern.WriteString("(?:" + regexp.QuoteMeta(string(expr.op)) + "(.*?))?")
+		}
+	}
+	pattern.WriteString("$")
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURITemplate, err)
+	}
+	t.re = re
+	return t, nil
+}
+
+// variable is one expanded template variable.
+type variable struct {
+	name     string
+	value    string
+	underDir bool
+}
+
+// match returns the variable values of uri if it is an expansion of
+// the template.
+func (t *uriTemplate) match(uri string) ([]variable, bool) {
+	groups := t.re.FindStringSubmatch(uri)
+	if groups == nil {
+		return nil, false
+	}
+	var vars []variable
+	for i, expr := range t.exprs {
+		captured := groups[i+1]
+		if captured == "" {
+			continue
+		}
+		switch expr.op {
+		case ';', '?', '&':
+			sep := "&"
+			if expr.op == ';' {
+				sep = ";"
+			}
+			for _, pair := range strings.Split(captured, sep) {
+				name, value, _ := strings.Cut(pair, "=")
+				vars = append(vars, variable{name: name, value: value})
+			}
+		default:
+			values := []string{captured}
+			if len(expr.vars) > 1 {
+				sep := ","
+				if expr.op == '.' || expr.op == '/' {
+					sep = string(expr.op)
+				}
+				values = strings.SplitN(captured, sep, len(expr.vars))
+			}
+			for j, value := range values {
+				vars = append(vars, variable{name: expr.vars[j], value: value, underDir: expr.underDir})
+			}
+		}
+	}
+	return vars, true
+}
+
+// resourceTemplates holds the session's known resource templates.
+type resourceTemplates struct {
+	policy *ResourceTemplatePolicy
+
+	mu        sync.Mutex
+	templates map[string]*uriTemplate
+}
+
+// newResourceTemplates returns the template set of a session,
+// starting with the policy's own templates.
+func newResourceTemplates(p *ResourceTemplatePolicy) *resourceTemplates {
+	rt := &resourceTemplates{policy: p, templates: make(map[string]*uriTemplate)}
+	for _, raw := range p.Templates {
+		if err := rt.add(raw); err != nil {
+			log.Printf("router: resource template not enforced: %v", err)
+		}
+	}
+	return rt
+}
+
+// add parses and remembers a template.
+func (rt *resourceTemplates) add(raw string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.templates[raw]; ok {
+		return nil
+	}
+	if len(rt.templates) >= maxResourceTemplates {
+		return fmt.Errorf("%d templates already known; %q ignored", maxResourceTemplates, raw)
+	}
+	t, err := parseURITemplate(raw)
+	if err != nil {
+		return err
+	}
+	rt.templates[raw] = t
+	return nil
+}
+
+// check returns a non-empty reason if uri expands a known template
+// with a value the policy refuses.
+func (rt *resourceTemplates) check(uri string) string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for raw, t := range rt.templates {
+		vars, ok := t.match(uri)
+		if !ok {
+			continue
+		}
+		for _, v := range vars {
+			if reason := rt.policy.checkValue(v); reason != "" {
+				return fmt.Sprintf("resource URI %q expands template %q: variable %q %s", truncateURI(uri), raw, v.name, reason)
+			}
+		}
+	}
+	return ""
+}
+
+// checkValue returns a non-empty reason if an expanded value is
+// refused.
+func (p *ResourceTemplatePolicy) checkValue(v variable) string {
+	value := v.value
+	for round := 0; ; round++ {
+		if reason := escapesTemplate(value, v.underDir); reason != "" {
+			return reason
+		}
+		decoded, err := url.PathUnescape(value)
+		if err != nil {
+			return "is not validly percent-encoded"
+		}
+		if decoded == value {
+			break
+		}
+		if round == maxDecodeRounds {
+			return "is percent-encoded too many times"
+		}
+		value = decoded
+	}
+	if re := p.Variables[v.name]; re != nil && !re.MatchString(value) {
+		return fmt.Sprintf("value %q does not match %s", truncateURI(value), re)
+	}
+	return ""
+}
+
+// escapesTemplate returns a non-empty reason if value could address
+// something outside the template's scope.
+func escapesTemplate(value string, underDir bool) string {
+	for _, c := range value {
+		if unicode.IsControl(c) {
+			return "contains a control character"
+		}
+	}
+	for _, segment := range strings.FieldsFunc(value, func(c rune) bool { return c == '/' || c == '\\' }) {
+		if segment == ".." {
+			return "contains a .. path segment"
+		}
+	}
+	if underDir && (strings.HasPrefix(value, "/") || strings.HasPrefix(value, "\\") ||
+		(len(value) >= 2 && value[1] == ':' && unicode.IsLetter(rune(value[0])))) {
+		return "is an absolute path"
+	}
+	return ""
+}
+
+// recordTemplates remembers the templates of a resources/templates/list
+// response.
+func (r *Router) recordTemplates(response []byte) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || resp.Result == nil {
+		return
+	}
+	var result mcptypes.ListResourceTemplatesResult
+	if json.Unmarshal(resp.Result, &result) != nil {
+		return
+	}
+	for _, t := range result.ResourceTemplates {
+		if err := r.resourceTemplates.add(t.URITemplate); err != nil {
+			log.Printf("router: session %s: resource template not enforced: %v", r.sessionID, err)
+		}
+	}
+}
+
+// checkTemplateRequest applies the template policy to a resources/read
+// or resources/subscribe request.
+//
+// # Returns
+//   - Non-empty reason if the request must be refused
+func (r *Router) checkTemplateRequest(msg *jsonrpc.Message) string {
+	params, err := mcptypes.DecodeParams[mcptypes.ReadResourceParams](msg)
+	if err != nil {
+		return "malformed resource params"
+	}
+	return r.resourceTemplates.check(params.URI)
+}
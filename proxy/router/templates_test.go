@@ -0,0 +1,135 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestParseURITemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		uri      string
+		vars     map[string]string
+	}{
+		{"file:///srv/docs/{name}", "file:///srv/docs/a.md", map[string]string{"name": "a.md"}},
+		{"file:///srv/docs/{name}", "file:///srv/other/a.md", nil},
+		{"db://{table}/{id}", "db://users/42", map[string]string{"table": "users", "id": "42"}},
+		{"repo://{owner}/{repo}/blob{/path*}", "repo://me/x/blob/src/main.go", map[string]string{"owner": "me", "repo": "x", "path": "src/main.go"}},
+		{"search://items{?q,limit}", "search://items?q=cats&limit=5", map[string]string{"q": "cats", "limit": "5"}},
+		{"search://items{?q,limit}", "search://items", map[string]string{}},
+		{"file:///{+path}", "file:///etc/hosts", map[string]string{"path": "etc/hosts"}},
+		{"doc://{x,y}", "doc://1,2", map[string]string{"x": "1", "y": "2"}},
+		{"doc://{name:3}.txt", "doc://abc.txt", map[string]string{"name": "abc"}},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseURITemplate(tt.template)
+		if err != nil {
+			t.Fatalf("parseURITemplate(%q) failed: %v", tt.template, err)
+		}
+		vars, ok := tmpl.match(tt.uri)
+		if ok != (tt.vars != nil) {
+			t.Errorf("%q match %q = %v, expected %v", tt.template, tt.uri, ok, tt.vars != nil)
+			continue
+		}
+		got := make(map[string]string)
+		for _, v := range vars {
+			got[v.name] = v.value
+		}
+		for name, value := range tt.vars {
+			if got[name] != value {
+				t.Errorf("%q match %q: %s = %q, expected %q", tt.template, tt.uri, name, got[name], value)
+			}
+		}
+	}
+
+	for _, bad := range []string{"file:///{name", "file:///name}", "db://{}", "db://{a,}", "db://{a{b}}"} {
+		if err := ValidateURITemplate(bad); !errors.Is(err, ErrURITemplate) {
+			t.Errorf("ValidateURITemplate(%q) = %v, expected ErrURITemplate", bad, err)
+		}
+	}
+}
+
+func TestResourceTemplates_Check(t *testing.T) {
+	rt := newResourceTemplates(&ResourceTemplatePolicy{
+		Variables: map[string]*regexp.Regexp{"id": regexp.MustCompile(`^[0-9]+$`)},
+		Templates: []string{"file:///srv/docs/{name}", "db://{table}/{id}", "file:///{+path}"},
+	})
+
+	tests := []struct {
+		uri     string
+		allowed bool
+	}{
+		{"file:///srv/docs/readme.md", true},
+		{"file:///srv/docs/a..b.md", true},
+		{"file:///srv/docs/../../etc/passwd", false},
+		{"file:///srv/docs/..%2F..%2Fetc%2Fpasswd", false},
+		{"file:///srv/docs/%252e%252e%252fetc", false},
+		{"file:///srv/docs/..\\secret", false},
+		{"file:///srv/docs/%2Fetc%2Fpasswd", false},
+		{"file:///srv/docs/C:%5Cwindows", false},
+		{"file:///srv/docs/a%00.md", false},
+		{"file:///srv/docs/%zz", false},
+		{"file:///srv/docs/%25252525", false},
+		{"file:///etc/hosts", true},
+		{"file:///srv/../etc/hosts", false},
+		{"db://users/42", true},
+		{"db://users/42;drop", false},
+		{"https://example.com/anything/../x", true},
+	}
+	for _, tt := range tests {
+		reason := rt.check(tt.uri)
+		if (reason == "") != tt.allowed {
+			t.Errorf("check(%q) = %q, expected allowed=%v", tt.uri, reason, tt.allowed)
+		}
+	}
+}
+
+func TestResourceTemplates_Routing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ResourceTemplates = &ResourceTemplatePolicy{}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		msg, _ := jsonrpc.Parse(data)
+		var result interface{} = map[string]interface{}{"contents": []interface{}{}}
+		if msg.Method == "resources/templates/list" {
+			result = map[string]interface{}{"resourceTemplates": []map[string]string{
+				{"uriTemplate": "file:///srv/docs/{name}", "name": "docs"},
+			}}
+		}
+		resp, _ := jsonrpc.NewResponse(msg.ID, result)
+		return jsonrpc.Serialize(resp)
+	}
+
+	read := func(uri string) *jsonrpc.Message {
+		t.Helper()
+		params, _ := json.Marshal(map[string]string{"uri": uri})
+		msg := &jsonrpc.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Method: "resources/read", Params: params}
+		data, _ := jsonrpc.Serialize(msg)
+		out, err := r.RouteMessage(data)
+		if err != nil {
+			t.Fatalf("RouteMessage failed: %v", err)
+		}
+		resp, _ := jsonrpc.Parse(out)
+		return resp
+	}
+
+	// Until the server lists the template, expansions are unchecked
+	if resp := read("file:///srv/docs/../../etc/passwd"); resp.Error != nil {
+		t.Fatalf("unlisted template checked: %+v", resp.Error)
+	}
+	list, _ := jsonrpc.Serialize(&jsonrpc.Message{JSONRPC: "2.0", ID: json.RawMessage(`2`), Method: "resources/templates/list"})
+	if _, err := r.RouteMessage(list); err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+	if resp := read("file:///srv/docs/../../etc/passwd"); resp.Error == nil || resp.Error.Code != jsonrpc.InvalidParams {
+		t.Errorf("traversal response = %+v, expected InvalidParams", resp.Error)
+	}
+	if resp := read("file:///srv/docs/notes.md"); resp.Error != nil {
+		t.Errorf("plain read refused: %+v", resp.Error)
+	}
+}
@@ -0,0 +1,197 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tofu"
+)
+
+// CodeApprovalRequired is the JSON-RPC error code returned for tool
+// calls refused because the tool awaits trust-on-first-use approval.
+const CodeApprovalRequired = -32005
+
+// unnamedServer identifies servers whose initialize result has no
+// serverInfo name.
+const unnamedServer = "unnamed"
+
+// tofuSession is the session's view of the TOFU store: the server's
+// identity and the fingerprint of each tool it listed.
+type tofuSession struct {
+	mu     sync.Mutex
+	server string
+	prints map[string]string
+
+	// refused is why none of the server's tools are approved: its
+	// fingerprint differs from the one the fleet manifest pins for its
+	// name, or the manifest blocks it ("" otherwise)
+	refused string
+
+	// cancel ends the approval subscription
+	cancel func()
+}
+
+// newTOFUSession subscribes r to approvals in store.
+func newTOFUSession(r *Router, store *tofu.Store) *tofuSession {
+	ts := &tofuSession{server: unnamedServer, prints: make(map[string]string)}
+	ts.cancel = store.Subscribe(r.tofuApproved)
+	return ts
+}
+
+// applyTOFU records the server identity from initialize and withholds
+// unapproved tools from tools/list.
+//
+// # Security Notes
+//
+// Fingerprints are taken over the server's own definitions, before
+// masking or protocol shims, and a withheld tool's description never
+// reaches the client, so an unapproved description cannot carry
+// instructions to the model. A server whose fingerprint differs from
+// the one the fleet manifest pins for its name is taken for an
+// impostor: its tools are withheld without consulting the store, so
+// neither its pins nor a trusted level apply to it.
+func (r *Router) applyTOFU(d *Decision, msg *jsonrpc.Message, response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || resp.Result == nil {
+		return response
+	}
+	switch msg.Method {
+	case "initialize":
+		var result struct {
+			ServerInfo struct {
+				Name string `json:"name"`
+			} `json:"serverInfo"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		server := result.ServerInfo.Name
+		if server == "" {
+			server = unnamedServer
+		}
+		fp, _ := tofu.ServerFingerprint(resp.Result)
+		var refused string
+		switch r.tofu.CheckServer(server, fp) {
+		case tofu.StatusChanged:
+			refused = fmt.Sprintf("server %q does not match the fingerprint its fleet manifest pins (%s)", server, fp)
+			d.Details = withDetailMap(d.Details, "tofu_server", fp)
+			log.Printf("audit: session %s: server %q (%s) does not match its fleet manifest pin; withholding its tools", r.sessionID, server, fp)
+		case tofu.StatusBlocked:
+			refused = fmt.Sprintf("server %q is blocked by the fleet manifest", server)
+			log.Printf("audit: session %s: server %q is blocked by the fleet manifest; withholding its tools", r.sessionID, server)
+		}
+		r.tofuSession.mu.Lock()
+		r.tofuSession.server = server
+		r.tofuSession.refused = refused
+		r.tofuSession.mu.Unlock()
+	case "tools/list":
+		return r.withholdUnapproved(d, resp, response)
+	}
+	return response
+}
+
+// withholdUnapproved removes tools whose fingerprint is not approved.
+func (r *Router) withholdUnapproved(d *Decision, resp *jsonrpc.Message, response []byte) []byte {
+	var result map[string]json.RawMessage
+	var tools []json.RawMessage
+	if json.Unmarshal(resp.Result, &result) != nil || json.Unmarshal(result["tools"], &tools) != nil {
+		return response
+	}
+
+	ts := r.tofuSession
+	ts.mu.Lock()
+	server, refused := ts.server, ts.refused
+	ts.mu.Unlock()
+
+	kept := make([]json.RawMessage, 0, len(tools))
+	var withheld, changed []string
+	for _, raw := range tools {
+		var tool struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+		fp, err := tofu.Fingerprint(raw)
+		if err != nil || json.Unmarshal(raw, &tool) != nil || tool.Name == "" {
+			withheld = append(withheld, "(malformed)")
+			continue
+		}
+		ts.mu.Lock()
+		ts.prints[tool.Name] = fp
+		ts.mu.Unlock()
+		if refused != "" {
+			withheld = append(withheld, tool.Name)
+			continue
+		}
+		if status := r.tofu.Check(server, tool.Name, fp, tool.Description); status != tofu.StatusApproved {
+			withheld = append(withheld, tool.Name)
+			if status == tofu.StatusChanged {
+				changed = append(changed, tool.Name)
+			}
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	if len(withheld) == 0 {
+		return response
+	}
+
+	r.stats.ToolsWithheld.Add(uint64(len(withheld)))
+	d.Details = withDetailMap(d.Details, "tofu_withheld", withheld)
+	if len(changed) > 0 {
+		// An approved definition rewritten by the server: a possible
+		// rug pull, flagged apart from tools never seen before
+		r.stats.ToolsChanged.Add(uint64(len(changed)))
+		d.Details = withDetailMap(d.Details, "tofu_changed", changed)
+		log.Printf("router: session %s: %s changed approved tools: %v", r.sessionID, server, changed)
+	}
+	log.Printf("router: session %s: withheld %d tools of %s awaiting approval: %v", r.sessionID, len(withheld), server, withheld)
+	result["tools"], _ = json.Marshal(kept)
+	resp.Result, _ = json.Marshal(result)
+	out, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return response
+	}
+	return out
+}
+
+// checkTOFU returns why a tool call must wait for approval, or "".
+func (r *Router) checkTOFU(tool string) string {
+	ts := r.tofuSession
+	ts.mu.Lock()
+	server, fp, refused := ts.server, ts.prints[tool], ts.refused
+	ts.mu.Unlock()
+	if refused != "" {
+		return refused
+	}
+	if fp == "" {
+		return fmt.Sprintf("tool %q has not been listed in this session, so its fingerprint is unknown", tool)
+	}
+	switch status := r.tofu.Check(server, tool, fp, ""); status {
+	case tofu.StatusApproved:
+		return ""
+	case tofu.StatusBlocked:
+		return fmt.Sprintf("tool %q of server %q is blocked by the fleet manifest", tool, server)
+	default:
+		return fmt.Sprintf("tool %q of server %q (%s, %s) awaits trust-on-first-use approval", tool, server, status, fp)
+	}
+}
+
+// tofuApproved tells the client to re-list tools after one of this
+// session's withheld tools was approved.
+func (r *Router) tofuApproved(a tofu.Approval) {
+	if r.upstream == nil {
+		// The transport is the server connection
+		return
+	}
+	ts := r.tofuSession
+	ts.mu.Lock()
+	listed := ts.server == a.Server && ts.prints[a.Tool] == a.Fingerprint
+	ts.mu.Unlock()
+	if !listed {
+		return
+	}
+	if err := r.notify("notifications/tools/list_changed", nil); err != nil {
+		log.Printf("router: session %s: failed to announce approved tool %q: %v", r.sessionID, a.Tool, err)
+	}
+}
@@ -0,0 +1,198 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/scanner"
+)
+
+// ToolDescriptionAction is what the router does with a tool description
+// the scanner flags.
+type ToolDescriptionAction string
+
+const (
+	// ToolDescriptionFlag delivers the listing unchanged and records
+	// the findings
+	ToolDescriptionFlag ToolDescriptionAction = "flag"
+	// ToolDescriptionRedact removes the matched spans
+	ToolDescriptionRedact ToolDescriptionAction = "redact"
+	// ToolDescriptionStrip replaces the whole text with
+	// StrippedDescription
+	ToolDescriptionStrip ToolDescriptionAction = "strip"
+)
+
+// StrippedDescription replaces text removed by ToolDescriptionStrip.
+const StrippedDescription = "[description removed by mcp-sentinel]"
+
+// ToolSanitization scans the tools/list results the server sends for
+// instructions hidden in tool metadata: each tool's title and
+// description, the string values of its annotations, and the titles and
+// descriptions anywhere in its input and output schemas.
+//
+// Flagged text is recorded on the decision, and its original is written
+// to the audit trail as the record's original field, whatever the
+// action.
+//
+// # Security Notes
+//
+// Tool poisoning plants instructions where only the model looks: a
+// description the user never reads, often behind an <IMPORTANT> tag,
+// an HTML comment, or invisible characters. Redaction removes what the
+// rules match, not the sentence around it; strip when a listing that
+// matched should be trusted no further. The listing is sanitized after
+// trust-on-first-use fingerprinting and catalog recording, which see
+// what the server sent.
+type ToolSanitization struct {
+	// Action is taken on flagged text (default ToolDescriptionRedact)
+	Action ToolDescriptionAction
+
+	// Scanner finds the injections (nil uses the scanner's default
+	// rules)
+	Scanner *scanner.Scanner
+}
+
+// toolSanitizer applies a ToolSanitization.
+type toolSanitizer struct {
+	action  ToolDescriptionAction
+	scanner *scanner.Scanner
+}
+
+// newToolSanitizer fills in the defaults of p.
+func newToolSanitizer(p *ToolSanitization) *toolSanitizer {
+	ts := &toolSanitizer{action: p.Action, scanner: p.Scanner}
+	if ts.action == "" {
+		ts.action = ToolDescriptionRedact
+	}
+	if ts.scanner == nil {
+		// The default rules always compile
+		ts.scanner, _ = scanner.New(scanner.Config{})
+	}
+	return ts
+}
+
+// flaggedText is the decision-detail form of flagged tool metadata.
+type flaggedText struct {
+	Tool  string   `json:"tool"`
+	Path  string   `json:"path"`
+	Rules []string `json:"rules"`
+}
+
+// sanitizeTools scans a tools/list response and returns the response to
+// deliver.
+func (r *Router) sanitizeTools(d *Decision, response []byte) []byte {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response
+	}
+	dec := json.NewDecoder(bytes.NewReader(resp.Result))
+	dec.UseNumber()
+	var result map[string]interface{}
+	if err := dec.Decode(&result); err != nil {
+		return response
+	}
+	tools, _ := result["tools"].([]interface{})
+
+	ts := r.toolSanitizer
+	var flagged []flaggedText
+	original := make(map[string]string)
+	for i, t := range tools {
+		tool, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := tool["name"].(string)
+		visit := func(path, text string) string {
+			findings := ts.scanner.Scan(text)
+			if len(findings) == 0 {
+				return text
+			}
+			var rules []string
+			for _, f := range findings {
+				if !slices.Contains(rules, f.Rule) {
+					rules = append(rules, f.Rule)
+				}
+			}
+			path = fmt.Sprintf("tools[%d].%s", i, path)
+			flagged = append(flagged, flaggedText{Tool: name, Path: path, Rules: rules})
+			original[path] = text
+			switch ts.action {
+			case ToolDescriptionStrip:
+				return StrippedDescription
+			case ToolDescriptionRedact:
+				redacted, _ := ts.scanner.Redact(text)
+				return redacted
+			}
+			return text
+		}
+		for _, field := range []string{"title", "description"} {
+			if text, ok := tool[field].(string); ok {
+				tool[field] = visit(field, text)
+			}
+		}
+		if annotations, ok := tool["annotations"].(map[string]interface{}); ok {
+			for _, key := range slices.Sorted(maps.Keys(annotations)) {
+				if text, ok := annotations[key].(string); ok {
+					annotations[key] = visit("annotations."+key, text)
+				}
+			}
+		}
+		for _, field := range []string{"inputSchema", "outputSchema"} {
+			sanitizeSchema(tool[field], field, visit)
+		}
+	}
+	if len(flagged) == 0 {
+		return response
+	}
+
+	r.stats.ToolDescriptionsFlagged.Add(uint64(len(flagged)))
+	d.Details = withDetailMap(d.Details, "tool_descriptions", flagged)
+	d.reasons = append(d.reasons, fmt.Sprintf("tool_descriptions: %d fields matched injection rules (%s)", len(flagged), ts.action))
+	if encoded, err := json.Marshal(original); err == nil {
+		d.auditOriginal = string(encoded)
+	}
+	var paths []string
+	for _, f := range flagged {
+		paths = append(paths, f.Path)
+	}
+	log.Printf("audit: session %s: tools/list fields matched injection rules (%s): %s", r.sessionID, ts.action, strings.Join(paths, ", "))
+	if ts.action == ToolDescriptionFlag {
+		return response
+	}
+
+	out, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return response
+	}
+	data, err := jsonrpc.Serialize(out)
+	if err != nil {
+		return response
+	}
+	return data
+}
+
+// sanitizeSchema visits the title and description strings anywhere in
+// a JSON Schema, replacing each with what visit returns.
+func sanitizeSchema(node interface{}, path string, visit func(path, text string) string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, key := range slices.Sorted(maps.Keys(n)) {
+			v := n[key]
+			if text, ok := v.(string); ok && (key == "title" || key == "description") {
+				n[key] = visit(path+"."+key, text)
+				continue
+			}
+			sanitizeSchema(v, path+"."+key, visit)
+		}
+	case []interface{}:
+		for i, v := range n {
+			sanitizeSchema(v, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	}
+}
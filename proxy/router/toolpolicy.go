@@ -0,0 +1,60 @@
+package router
+
+import (
+	"fmt"
+	"path"
+)
+
+// ToolPolicy allows or denies tool calls by name before any sentinel
+// check runs.
+//
+// Patterns use path.Match syntax ("fs__*", "delete_?") and match the
+// tool name the client called, so tools of a namespaced upstream are
+// matched with their prefix.
+//
+// # Security Notes
+//
+// Deny wins over Allow. With a non-empty Allow list every tool not
+// listed is refused, including tools a server adds later.
+type ToolPolicy struct {
+	// Allow lists permitted tool name patterns (empty allows every tool
+	// not denied)
+	Allow []string
+
+	// Deny lists refused tool name patterns
+	Deny []string
+}
+
+// Check returns a non-empty reason if tool may not be called.
+func (p *ToolPolicy) Check(tool string) string {
+	if pattern, ok := matchTool(p.Deny, tool); ok {
+		return fmt.Sprintf("tool %q is denied by policy pattern %q", tool, pattern)
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	if _, ok := matchTool(p.Allow, tool); ok {
+		return ""
+	}
+	return fmt.Sprintf("tool %q is not in the policy allow list", tool)
+}
+
+// Validate reports the first malformed pattern.
+func (p *ToolPolicy) Validate() error {
+	for _, pattern := range append(append([]string(nil), p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("router: tool pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchTool returns the first pattern matching tool.
+func matchTool(patterns []string, tool string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestToolPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ToolPolicy
+		tool    string
+		allowed bool
+	}{
+		{"empty policy", ToolPolicy{}, "shell", true},
+		{"denied", ToolPolicy{Deny: []string{"shell"}}, "shell", false},
+		{"deny pattern", ToolPolicy{Deny: []string{"delete_*"}}, "delete_file", false},
+		{"not denied", ToolPolicy{Deny: []string{"delete_*"}}, "read_file", true},
+		{"allowed", ToolPolicy{Allow: []string{"fs__*"}}, "fs__read", true},
+		{"not allowed", ToolPolicy{Allow: []string{"fs__*"}}, "web__fetch", false},
+		{"deny wins", ToolPolicy{Allow: []string{"fs__*"}, Deny: []string{"fs__write"}}, "fs__write", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := tt.policy.Check(tt.tool)
+			if (reason == "") != tt.allowed {
+				t.Errorf("Check(%q) = %q, expected allowed=%v", tt.tool, reason, tt.allowed)
+			}
+		})
+	}
+
+	if err := (&ToolPolicy{Deny: []string{"[a-"}}).Validate(); err == nil {
+		t.Error("Validate accepted a malformed pattern")
+	}
+}
+
+func TestToolPolicy_Routing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	cfg.HighRiskTools = []string{"deploy"}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(jsonrpc.NullID(), map[string]interface{}{"content": []interface{}{}})
+		return jsonrpc.Serialize(resp)
+	}
+
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "shell"}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	response, _ := r.RouteMessage(data)
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error == nil {
+		t.Fatalf("denied tool response = %s, expected an error", response)
+	}
+	if r.stats.MessagesBlocked.Load() != 1 {
+		t.Errorf("MessagesBlocked = %d, expected 1", r.stats.MessagesBlocked.Load())
+	}
+
+	if !r.isHighRisk("deploy") || r.isHighRisk("execute_command") {
+		t.Error("HighRiskTools did not replace the built-in list")
+	}
+}
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"context"
+	"errors"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+)
+
+// startSpan starts a span for a stage of routing d's message, as a
+// child of the stage in progress. It returns a nil span when the
+// message is not traced.
+func (d *Decision) startSpan(name string) (context.Context, *tracing.Span) {
+	return tracing.Start(d.ctx, name)
+}
+
+// endSpan records the decision on the message's root span and ends it.
+func (d *Decision) endSpan() {
+	if d.span == nil {
+		return
+	}
+	d.span.SetAttribute("mcp.session_id", d.SessionID)
+	d.span.SetAttribute("mcp.decision_id", d.ID)
+	d.span.SetAttribute("sentinel.trace_id", d.TraceID)
+	d.span.SetAttribute("rpc.method", d.Method)
+	if d.Tool != "" {
+		d.span.SetAttribute("mcp.tool", d.Tool)
+	}
+	d.span.SetAttribute("mcp.verdict", string(d.Verdict))
+	if d.Verdict == VerdictError {
+		d.span.SetError(errors.New(d.Reason))
+	}
+	d.span.End()
+}
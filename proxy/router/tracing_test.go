@@ -0,0 +1,92 @@
+package router
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+)
+
+// spanRecorder keeps every exported span.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *spanRecorder) Close() error { return nil }
+
+func TestTracing_RoutingSpans(t *testing.T) {
+	rec := &spanRecorder{}
+	cfg := DefaultConfig()
+	cfg.Tracer = tracing.New(rec, nil)
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]string{"status": "ok"})
+		return jsonrpc.Serialize(resp)
+	}
+
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{
+		"name":      "execute_command",
+		"arguments": map[string]interface{}{"command": "ls"},
+	}, 1)
+	data, _ := jsonrpc.Serialize(req)
+	if _, err := r.RouteMessage(data); err != nil {
+		t.Fatalf("RouteMessage failed: %v", err)
+	}
+
+	byName := make(map[string]*tracing.SpanData)
+	for _, s := range rec.spans {
+		byName[s.Name] = s
+	}
+	root := byName["route"]
+	if root == nil {
+		t.Fatalf("no route span among %d spans", len(rec.spans))
+	}
+	tests := []struct {
+		name   string
+		parent string
+	}{
+		{"parse", "route"},
+		{"check.registry", "route"},
+		{"sentinel.registry_check", "check.registry"},
+		{"check.state", "route"},
+		{"sentinel.state_check", "check.state"},
+		{"check.council", "route"},
+		{"sentinel.council_vote", "check.council"},
+		{"forward", "route"},
+	}
+	for _, tt := range tests {
+		s, parent := byName[tt.name], byName[tt.parent]
+		if s == nil {
+			t.Errorf("no %s span", tt.name)
+			continue
+		}
+		if s.Context.TraceID != root.Context.TraceID {
+			t.Errorf("%s span is in trace %v, expected %v", tt.name, s.Context.TraceID, root.Context.TraceID)
+		}
+		if parent != nil && s.Parent != parent.Context.SpanID {
+			t.Errorf("%s span's parent is %v, expected %s %v", tt.name, s.Parent, tt.parent, parent.Context.SpanID)
+		}
+	}
+	if root.Attrs["rpc.method"] != "tools/call" || root.Attrs["mcp.tool"] != "execute_command" || root.Attrs["mcp.verdict"] != string(VerdictAllowed) {
+		t.Errorf("route attributes = %v", root.Attrs)
+	}
+
+	// Without a tracer nothing is recorded
+	rec.spans = nil
+	r = New(&mockTransport{}, sentinel.NewClient())
+	r.forwardFunc = func([]byte) ([]byte, error) { return nil, nil }
+	r.RouteMessage(data)
+	if len(rec.spans) != 0 {
+		t.Errorf("untraced router exported %d spans", len(rec.spans))
+	}
+}
@@ -0,0 +1,25 @@
+package router
+
+// ToolResolver maps the tool names a client sees to the server that
+// provides each tool, for servers reached through a multiplexing
+// transport such as upstream.Mux.
+type ToolResolver interface {
+	// Resolve returns the name of the upstream providing tool and that
+	// server's own name for it, or false if no upstream provides it
+	Resolve(tool string) (upstream, name string, ok bool)
+}
+
+// serverToolName returns the name the providing server uses for tool.
+//
+// # Security Notes
+//
+// Risk classification uses this name so a namespaced high-risk tool
+// ("sh__execute_command") is treated like the bare one.
+func (r *Router) serverToolName(tool string) string {
+	if r.upstreamTools != nil {
+		if _, name, ok := r.upstreamTools.Resolve(tool); ok {
+			return name
+		}
+	}
+	return tool
+}
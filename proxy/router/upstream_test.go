@@ -0,0 +1,66 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// staticResolver resolves "<upstream>__<tool>" names.
+type staticResolver struct{}
+
+func (staticResolver) Resolve(tool string) (string, string, bool) {
+	upstream, name, ok := strings.Cut(tool, "__")
+	return upstream, name, ok
+}
+
+// councilBackend allows everything and counts council votes.
+type councilBackend struct {
+	poisonBackend
+	votes int
+}
+
+func (b *councilBackend) VoteCouncil(ctx context.Context, req *sentinel.CouncilVoteRequest) (*sentinel.CheckResult, error) {
+	b.votes++
+	return &sentinel.CheckResult{Allowed: true}, nil
+}
+
+func TestUpstreamTools(t *testing.T) {
+	tests := []struct {
+		tool         string
+		wantUpstream interface{}
+		wantVotes    int
+	}{
+		{"sh__execute_command", "sh", 1},
+		{"fs__read_file", "fs", 0},
+		{"plain", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.UpstreamTools = staticResolver{}
+			backend := &councilBackend{}
+			r := NewWithConfig(&mockTransport{}, sentinel.NewFusedClient(nil, sentinel.Member{Name: "test", Backend: backend}), cfg)
+			r.forwardFunc = func([]byte) ([]byte, error) {
+				resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{"content": []interface{}{}})
+				return jsonrpc.Serialize(resp)
+			}
+
+			req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": tt.tool}, 1)
+			data, _ := jsonrpc.Serialize(req)
+			if _, err := r.RouteMessage(data); err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+			if backend.votes != tt.wantVotes {
+				t.Errorf("council votes = %d, expected %d", backend.votes, tt.wantVotes)
+			}
+			if d := r.RecentDecisions(1); len(d) != 1 || d[0].Details["upstream"] != tt.wantUpstream {
+				t.Errorf("decision upstream = %v, expected %v", d[0].Details["upstream"], tt.wantUpstream)
+			}
+		})
+	}
+}
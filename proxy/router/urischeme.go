@@ -0,0 +1,168 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+)
+
+// URISchemePolicy pins which resource URI schemes the session may use.
+//
+// The policy applies to resources/read and resources/subscribe requests
+// from the client and to resource links and embedded resources in
+// tools/call results, since a client may follow either without further
+// confirmation.
+//
+// # Security Notes
+//
+// Schemes such as gopher: and javascript: have been used to smuggle
+// requests to internal services or run script in a client's renderer;
+// anything not listed is refused. data: URIs are refused when their
+// media type or payload marks them as executable, even if data is
+// listed.
+type URISchemePolicy struct {
+	// Allowed lists the permitted schemes without the trailing colon,
+	// compared case-insensitively
+	Allowed []string
+}
+
+// DefaultURISchemePolicy permits local files, HTTPS, git, and
+// non-executable data: URIs.
+func DefaultURISchemePolicy() *URISchemePolicy {
+	return &URISchemePolicy{
+		Allowed: []string{"file", "https", "git", "data"},
+	}
+}
+
+// executableMediaTypes are data: media types a client could execute or
+// render with script.
+var executableMediaTypes = []string{
+	"application/javascript",
+	"application/ecmascript",
+	"application/x-javascript",
+	"text/javascript",
+	"text/ecmascript",
+	"text/html",
+	"application/xhtml+xml",
+	"image/svg+xml",
+	"application/x-sh",
+	"application/x-shellscript",
+	"application/x-executable",
+	"application/x-elf",
+	"application/x-mach-binary",
+	"application/x-msdownload",
+	"application/x-msdos-program",
+	"application/vnd.microsoft.portable-executable",
+	"application/java-archive",
+	"application/wasm",
+}
+
+// executableMagic are leading payload bytes of native executables and
+// scripts.
+var executableMagic = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	[]byte("\xcf\xfa\xed\xfe"),
+	[]byte("\xfe\xed\xfa\xcf"),
+	[]byte("\x00asm"),
+	[]byte("#!"),
+}
+
+// Check returns a non-empty reason if uri is not permitted.
+func (p *URISchemePolicy) Check(uri string) string {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, "/?#") {
+		return fmt.Sprintf("resource URI %q has no scheme", truncateURI(uri))
+	}
+	scheme = strings.ToLower(scheme)
+	if !slices.ContainsFunc(p.Allowed, func(s string) bool { return strings.EqualFold(s, scheme) }) {
+		return fmt.Sprintf("resource URI scheme %q is not allowed", scheme)
+	}
+	if scheme == "data" && executableData(rest) {
+		return "data: URI carries executable content"
+	}
+	return ""
+}
+
+// executableData reports whether the part of a data: URI after the
+// colon declares an executable media type or decodes to an executable.
+func executableData(rest string) bool {
+	header, payload, _ := strings.Cut(rest, ",")
+	params := strings.Split(header, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	if slices.Contains(executableMediaTypes, mediaType) {
+		return true
+	}
+
+	var head []byte
+	if slices.Contains(params[1:], "base64") {
+		// Only the first few bytes are needed to match a signature
+		n := min(len(payload), 16)
+		n -= n % 4
+		decoded, err := base64.StdEncoding.DecodeString(payload[:n])
+		if err != nil {
+			return false
+		}
+		head = decoded
+	} else {
+		head = []byte(payload)
+	}
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResourceRequest applies the scheme policy to a resources/read or
+// resources/subscribe request.
+//
+// # Returns
+//   - Non-empty reason if the request must be refused
+func (r *Router) checkResourceRequest(msg *jsonrpc.Message) string {
+	params, err := mcptypes.DecodeParams[mcptypes.ReadResourceParams](msg)
+	if err != nil {
+		return "malformed resource params"
+	}
+	return r.uriSchemes.Check(params.URI)
+}
+
+// checkResultResources applies the scheme policy to resource links and
+// embedded resources in a tools/call result (nil if it did not decode).
+//
+// # Returns
+//   - Non-empty reason if the result must be blocked
+func (r *Router) checkResultResources(result *mcptypes.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	for i, item := range result.Content {
+		var uri string
+		switch {
+		case item.Type == mcptypes.ContentResourceLink:
+			uri = item.URI
+		case item.Resource != nil:
+			uri = item.Resource.URI
+		default:
+			continue
+		}
+		if reason := r.uriSchemes.Check(uri); reason != "" {
+			return fmt.Sprintf("tool result item %d: %s", i, reason)
+		}
+	}
+	return ""
+}
+
+// truncateURI shortens a URI for inclusion in an error reason.
+func truncateURI(uri string) string {
+	if len(uri) > 64 {
+		return uri[:64] + "..."
+	}
+	return uri
+}
@@ -0,0 +1,117 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+func TestURISchemePolicy_Check(t *testing.T) {
+	elf := base64.StdEncoding.EncodeToString([]byte("\x7fELF\x02\x01\x01\x00payload"))
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nrest"))
+
+	tests := []struct {
+		uri     string
+		allowed bool
+	}{
+		{"file:///home/user/notes.md", true},
+		{"https://example.com/doc", true},
+		{"HTTPS://example.com/doc", true},
+		{"git://example.com/repo.git", true},
+		{"data:text/plain,hello", true},
+		{"data:image/png;base64," + png, true},
+		{"http://example.com/doc", false},
+		{"gopher://127.0.0.1:6379/_FLUSHALL", false},
+		{"javascript:alert(1)", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"data:application/octet-stream;base64," + elf, false},
+		{"data:,#!/bin/sh", false},
+		{"/etc/passwd", false},
+		{"", false},
+	}
+
+	p := DefaultURISchemePolicy()
+	for _, tt := range tests {
+		reason := p.Check(tt.uri)
+		if (reason == "") != tt.allowed {
+			t.Errorf("Check(%q) = %q, expected allowed=%v", tt.uri, reason, tt.allowed)
+		}
+	}
+}
+
+func TestURISchemes_Routing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.URISchemes = DefaultURISchemePolicy()
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+
+	var content []map[string]interface{}
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		resp, _ := jsonrpc.NewResponse(json.RawMessage(`1`), map[string]interface{}{"content": content})
+		return jsonrpc.Serialize(resp)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		params  map[string]interface{}
+		content []map[string]interface{}
+		allowed bool
+	}{
+		{
+			name:    "read file resource",
+			method:  "resources/read",
+			params:  map[string]interface{}{"uri": "file:///tmp/a.txt"},
+			allowed: true,
+		},
+		{
+			name:   "subscribe gopher resource",
+			method: "resources/subscribe",
+			params: map[string]interface{}{"uri": "gopher://internal:70/"},
+		},
+		{
+			name:   "tool result links javascript",
+			method: "tools/call",
+			params: map[string]interface{}{"name": "search", "arguments": map[string]string{}},
+			content: []map[string]interface{}{
+				{"type": "text", "text": "see link"},
+				{"type": "resource_link", "uri": "javascript:alert(1)", "name": "x"},
+			},
+		},
+		{
+			name:   "tool result embeds executable data",
+			method: "tools/call",
+			params: map[string]interface{}{"name": "search", "arguments": map[string]string{}},
+			content: []map[string]interface{}{
+				{"type": "resource", "resource": map[string]string{"uri": "data:text/javascript,alert(1)", "text": "alert(1)"}},
+			},
+		},
+		{
+			name:   "tool result links https",
+			method: "tools/call",
+			params: map[string]interface{}{"name": "search", "arguments": map[string]string{}},
+			content: []map[string]interface{}{
+				{"type": "resource_link", "uri": "https://example.com/a", "name": "a"},
+			},
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content = tt.content
+			req, _ := jsonrpc.NewRequest(tt.method, tt.params, 1)
+			data, _ := jsonrpc.Serialize(req)
+			response, err := r.RouteMessage(data)
+			if err != nil {
+				t.Fatalf("RouteMessage failed: %v", err)
+			}
+			resp, _ := jsonrpc.Parse(response)
+			if (resp.Error == nil) != tt.allowed {
+				t.Errorf("allowed = %v, expected %v (error %v)", resp.Error == nil, tt.allowed, resp.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/normalize"
+)
+
+// WhyToolName is the synthetic tool that explains blocked decisions when
+// Config.ExplainBlocks is set.
+const WhyToolName = "sentinel/why"
+
+// maxWhyReason bounds the reason quoted in an explanation, in bytes.
+const maxWhyReason = 300
+
+// whyTool is the tools/list entry of WhyToolName.
+var whyTool = mcptypes.Tool{
+	Name:  WhyToolName,
+	Title: "Explain a blocked action",
+	Description: "Explains why mcp-sentinel blocked an action in this session: which check refused it " +
+		"and what the user could change. Pass the decision_id from the error's data.",
+	InputSchema: json.RawMessage(`{"type":"object","properties":{"decision_id":{"type":"string",` +
+		`"description":"The decision_id of the blocked call"}},"required":["decision_id"]}`),
+	Annotations: &mcptypes.ToolAnnotations{ReadOnlyHint: &whyReadOnly},
+}
+
+// whyReadOnly is the readOnlyHint of whyTool.
+var whyReadOnly = true
+
+// Explanation is the structured result of a sentinel/why call.
+type Explanation struct {
+	DecisionID string `json:"decision_id"`
+	Method     string `json:"method,omitempty"`
+	Tool       string `json:"tool,omitempty"`
+
+	// Check names the kind of check that refused the action
+	Check string `json:"check"`
+
+	// Reason is the check's reason, stripped of control and invisible
+	// characters and shortened
+	Reason string `json:"reason"`
+
+	// Guidance says what the user could change for the action to be
+	// allowed
+	Guidance string `json:"guidance"`
+}
+
+// blockExplanation returns the kind of check behind a block with the
+// given error code and what the user could change about it.
+func blockExplanation(code int) (check, guidance string) {
+	switch code {
+	case CodeRateLimited:
+		return "rate limit", "Wait before calling the tool again, or ask an operator to raise its rate limit."
+	case CodePaused:
+		return "operator pause", "An operator paused the session; the action can be retried once they resume it."
+	case CodeApprovalRequired:
+		return "tool approval", "An operator must approve this tool, or its changed definition, before it can be used."
+	case CodeGasExhausted:
+		return "gas budget", "The session spent its call budget. Start a new session, or ask an operator for a larger budget."
+	case CodeCallDepthExceeded:
+		return "call depth", "The call was nested too deeply. Make the tool calls directly instead of through other tools."
+	case CodeNonconforming:
+		return "conformance", "The session kept retrying refused calls. Change the request instead of repeating it."
+	case CodeSamplingRefused:
+		return "sampling policy", "The server's request to the model was refused; an operator can change the sampling policy."
+	case CodeProxyLoop:
+		return "loop guard", "The server is configured to reach this proxy again; an operator must fix the server address."
+	case jsonrpc.InvalidParams:
+		return "argument checks", "Change the arguments: remove what the reason names, or ask the user for values that are permitted."
+	}
+	return "security policy", "The policy does not permit this action. Choose a different approach, or ask an operator to change the policy."
+}
+
+// answerWhy answers a sentinel/why call from the session's decision log.
+// Only blocked decisions of this session are explained; their details,
+// arguments, and results are never included.
+func (r *Router) answerWhy(d *Decision, msg *jsonrpc.Message) ([]byte, error) {
+	d.Tool = WhyToolName
+	d.Verdict = VerdictAllowed
+	d.event(EventVerdict, map[string]interface{}{"verdict": VerdictAllowed, "reason": "answered by " + WhyToolName})
+
+	var params struct {
+		Arguments struct {
+			DecisionID string `json:"decision_id"`
+		} `json:"arguments"`
+	}
+	json.Unmarshal(msg.Params, &params)
+	id := params.Arguments.DecisionID
+
+	var result mcptypes.CallToolResult
+	blocked, found := r.decisions.get(id)
+	switch {
+	case id == "":
+		result = whyError("decision_id is required.")
+	case !found:
+		result = whyError(fmt.Sprintf("No decision %q in this session; it may be too old to look up.", id))
+	case blocked.Verdict != VerdictBlocked:
+		result = whyError(fmt.Sprintf("Decision %q was not blocked (verdict %s).", id, blocked.Verdict))
+	default:
+		check, guidance := blockExplanation(blocked.code)
+		e := Explanation{
+			DecisionID: blocked.ID,
+			Method:     blocked.Method,
+			Tool:       blocked.Tool,
+			Check:      check,
+			Reason:     sanitizeReason(blocked.Reason),
+			Guidance:   guidance,
+		}
+		action := e.Method
+		if e.Tool != "" {
+			action = fmt.Sprintf("The call to %s", e.Tool)
+		}
+		text := fmt.Sprintf("%s was blocked by the %s check: %s\n%s", action, e.Check, e.Reason, e.Guidance)
+		result.Content = []mcptypes.Content{{Type: mcptypes.ContentText, Text: text}}
+		result.StructuredContent, _ = json.Marshal(e)
+		r.stats.BlocksExplained.Add(1)
+	}
+	d.Reason = "answered by " + WhyToolName
+	d.event(EventForwarded, map[string]interface{}{"local": WhyToolName})
+
+	resp, err := jsonrpc.NewResponse(msg.ID, result)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(resp)
+}
+
+// whyError is a sentinel/why result reporting a failed lookup.
+func whyError(text string) mcptypes.CallToolResult {
+	return mcptypes.CallToolResult{
+		Content: []mcptypes.Content{{Type: mcptypes.ContentText, Text: text}},
+		IsError: true,
+	}
+}
+
+// sanitizeReason prepares a recorded reason for the model: control and
+// invisible characters are removed, whitespace collapsed, and the text
+// cut to maxWhyReason bytes.
+func sanitizeReason(reason string) string {
+	reason, _ = normalize.StripInvisible(reason)
+	reason = strings.Join(strings.FieldsFunc(reason, func(c rune) bool {
+		return unicode.IsSpace(c) || unicode.IsControl(c)
+	}), " ")
+	return payloadSnippet([]byte(reason), maxWhyReason)
+}
+
+// listWhyTool adds WhyToolName to the first page of a tools/list
+// response, replacing any server tool of the same name, which calls
+// could not reach anyway.
+func listWhyTool(msg *jsonrpc.Message, response []byte) []byte {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(msg.Params, &params)
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 || params.Cursor != "" {
+		return response
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result == nil {
+		return response
+	}
+	var tools []json.RawMessage
+	if err := json.Unmarshal(result["tools"], &tools); err != nil && result["tools"] != nil {
+		return response
+	}
+	listed := make([]json.RawMessage, 0, len(tools)+1)
+	for _, t := range tools {
+		var tool struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(t, &tool) == nil && tool.Name == WhyToolName {
+			continue
+		}
+		listed = append(listed, t)
+	}
+	entry, _ := json.Marshal(whyTool)
+	result["tools"], _ = json.Marshal(append(listed, entry))
+
+	out, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return response
+	}
+	data, err := jsonrpc.Serialize(out)
+	if err != nil {
+		return response
+	}
+	return data
+}
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/mcptypes"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sentinel"
+)
+
+// newWhyRouter returns a router explaining its blocks, whose policy
+// denies the tool shell, and the number of messages it forwards.
+func newWhyRouter(explain bool) (*Router, *int) {
+	cfg := DefaultConfig()
+	cfg.ExplainBlocks = explain
+	cfg.ToolPolicy = &ToolPolicy{Deny: []string{"shell"}}
+	r := NewWithConfig(&mockTransport{}, sentinel.NewClient(), cfg)
+	forwarded := new(int)
+	r.forwardFunc = func(data []byte) ([]byte, error) {
+		*forwarded++
+		req, _ := jsonrpc.Parse(data)
+		if req.Method == "tools/list" {
+			resp, _ := jsonrpc.NewResponse(req.ID, json.RawMessage(
+				`{"tools":[{"name":"read","inputSchema":{}},{"name":"sentinel/why","inputSchema":{}}]}`))
+			return jsonrpc.Serialize(resp)
+		}
+		resp, _ := jsonrpc.NewResponse(req.ID, mcptypes.CallToolResult{Content: []mcptypes.Content{{Type: mcptypes.ContentText, Text: "ok"}}})
+		return jsonrpc.Serialize(resp)
+	}
+	return r, forwarded
+}
+
+// decisionOf routes a tools/call of tool and returns its decision ID.
+func decisionOf(t *testing.T, r *Router, tool string) string {
+	t.Helper()
+	r.RouteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":%q}}`, tool)))
+	return r.RecentDecisions(1)[0].ID
+}
+
+// askWhy calls sentinel/why with the given decision ID.
+func askWhy(t *testing.T, r *Router, id string) mcptypes.CallToolResult {
+	t.Helper()
+	response, err := r.RouteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"sentinel/why","arguments":{"decision_id":%q}}}`, id)))
+	if err != nil {
+		t.Fatalf("sentinel/why: %v", err)
+	}
+	resp, _ := jsonrpc.Parse(response)
+	var result mcptypes.CallToolResult
+	if resp == nil || resp.Error != nil || json.Unmarshal(resp.Result, &result) != nil {
+		t.Fatalf("sentinel/why response %s, expected a tool result", response)
+	}
+	return result
+}
+
+func TestExplainBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		id      string
+		isError bool
+		check   string
+	}{
+		{"blocked call", "shell", "", false, "security policy"},
+		{"allowed call", "read", "", true, ""},
+		{"unknown decision", "", "d-unknown", true, ""},
+		{"missing decision", "", "", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, forwarded := newWhyRouter(true)
+			id := tt.id
+			if tt.tool != "" {
+				id = decisionOf(t, r, tt.tool)
+			}
+			before := *forwarded
+
+			result := askWhy(t, r, id)
+			if *forwarded != before {
+				t.Error("sentinel/why was forwarded to the server")
+			}
+			if result.IsError != tt.isError {
+				t.Fatalf("isError = %v, expected %v: %+v", result.IsError, tt.isError, result)
+			}
+			if tt.isError {
+				return
+			}
+			var e Explanation
+			if err := json.Unmarshal(result.StructuredContent, &e); err != nil {
+				t.Fatalf("structured content %s: %v", result.StructuredContent, err)
+			}
+			if e.DecisionID != id || e.Tool != tt.tool || e.Check != tt.check || e.Reason == "" || e.Guidance == "" {
+				t.Errorf("explanation %+v", e)
+			}
+			if len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, tt.check) {
+				t.Errorf("text content %+v, expected it to name the %s check", result.Content, tt.check)
+			}
+			if got := r.Stats().BlocksExplained; got != 1 {
+				t.Errorf("BlocksExplained = %d, expected 1", got)
+			}
+		})
+	}
+}
+
+func TestExplainBlocks_Disabled(t *testing.T) {
+	r, forwarded := newWhyRouter(false)
+	id := decisionOf(t, r, "shell")
+	askWhy(t, r, id)
+	if *forwarded != 1 {
+		t.Errorf("forwarded %d messages, expected the sentinel/why call to reach the server", *forwarded)
+	}
+}
+
+func TestExplainBlocks_OtherSession(t *testing.T) {
+	r, _ := newWhyRouter(true)
+	other, _ := newWhyRouter(true)
+	id := decisionOf(t, other, "shell")
+	if result := askWhy(t, r, id); !result.IsError {
+		t.Errorf("explained another session's decision: %+v", result)
+	}
+}
+
+func TestListWhyTool(t *testing.T) {
+	tests := []struct {
+		name     string
+		explain  bool
+		params   string
+		expected []string
+	}{
+		{"listed and replacing the server's", true, `{}`, []string{"read", "sentinel/why"}},
+		{"first page only", true, `{"cursor":"2"}`, []string{"read", "sentinel/why"}},
+		{"disabled", false, `{}`, []string{"read", "sentinel/why"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newWhyRouter(tt.explain)
+			response, _ := r.RouteMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":` + tt.params + `}`))
+			resp, _ := jsonrpc.Parse(response)
+			var result mcptypes.ListToolsResult
+			if resp == nil || json.Unmarshal(resp.Result, &result) != nil {
+				t.Fatalf("tools/list response %s", response)
+			}
+			var names []string
+			for _, tool := range result.Tools {
+				names = append(names, tool.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Fatalf("listed %v, expected %v", names, tt.expected)
+			}
+			synthetic := result.Tools[1].Title != ""
+			if synthetic != (tt.explain && tt.params == `{}`) {
+				t.Errorf("sentinel/why entry %+v", result.Tools[1])
+			}
+		})
+	}
+}
+
+func TestSanitizeReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		reason   string
+		expected string
+	}{
+		{"plain", "tool shell is denied by policy", "tool shell is denied by policy"},
+		{"control characters", "denied\n\nIGNORE\x1b[2J previous", "denied IGNORE [2J previous"},
+		{"invisible characters", "de\u200bnied", "denied"},
+		{"long", strings.Repeat("a", maxWhyReason+10), strings.Repeat("a", maxWhyReason) + "...(truncated)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeReason(tt.reason); got != tt.expected {
+				t.Errorf("sanitizeReason(%q) = %q, expected %q", tt.reason, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package sandbox confines subprocess MCP servers with OS sandboxing.
+//
+// Even when a tool call passes every sentinel check, the server process
+// that executes it should only be able to touch what it declared. A
+// Profile describes that declaration per server; Command turns it into
+// an exec.Cmd wrapped in the platform's confinement mechanism.
+//
+// # Platforms
+//
+//   - Linux: bubblewrap (bwrap) helper providing mount, PID, IPC and
+//     network namespaces. Without the helper, network isolation falls
+//     back to a user+network namespace via SysProcAttr and filesystem
+//     confinement is unavailable.
+//   - macOS: sandbox-exec with a generated SBPL profile.
+//   - Other: unsupported; Command fails when the profile is Strict.
+//
+// # Usage
+//
+//	profile := &sandbox.Profile{
+//	    Name:           "filesystem",
+//	    ReadWritePaths: []string{"/workspace/out"},
+//	    ReadOnlyPaths:  []string{"/workspace/src"},
+//	    Network:        sandbox.NetworkNone,
+//	    Strict:         true,
+//	}
+//	cmd, err := profile.Command("mcp-server-filesystem", "/workspace")
+//
+// # Security Notes
+//
+//   - The sandbox confines the server process, not the proxy
+//   - A non-Strict profile falls back to the best available
+//     confinement, logging what it lost; use Strict for servers that
+//     must never run unconfined
+//   - Paths are resolved to absolute form before being handed to the helper
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// Common errors returned by sandbox setup.
+var (
+	ErrUnsupported   = errors.New("sandbox: confinement unsupported on this platform")
+	ErrHelperMissing = errors.New("sandbox: sandbox helper not found")
+	ErrInvalidScope  = errors.New("sandbox: invalid network scope")
+)
+
+// NetworkScope limits what network access a sandboxed server has.
+type NetworkScope string
+
+const (
+	// NetworkAll leaves network access unrestricted
+	NetworkAll NetworkScope = "all"
+	// NetworkLoopback allows only loopback traffic
+	NetworkLoopback NetworkScope = "loopback"
+	// NetworkNone denies all network access
+	NetworkNone NetworkScope = "none"
+)
+
+// Profile declares the resources a subprocess server may access.
+type Profile struct {
+	// Name identifies the profile in logs
+	Name string `json:"name"`
+
+	// ReadWritePaths are directories the server may read and write
+	ReadWritePaths []string `json:"read_write_paths,omitempty"`
+
+	// ReadOnlyPaths are directories the server may only read
+	ReadOnlyPaths []string `json:"read_only_paths,omitempty"`
+
+	// Network restricts network access (default: NetworkNone)
+	Network NetworkScope `json:"network,omitempty"`
+
+	// Helper overrides the sandbox helper binary (bwrap or sandbox-exec)
+	Helper string `json:"helper,omitempty"`
+
+	// Strict refuses to start the server if full confinement is unavailable
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Command returns an exec.Cmd running name with args under the profile.
+//
+// # Arguments
+//   - name: Server executable
+//   - args: Server arguments
+//
+// # Returns
+//   - Command ready to have its pipes attached and be started
+//   - Error if the profile is invalid or confinement is unavailable
+//     for a Strict profile
+func (p *Profile) Command(name string, args ...string) (*exec.Cmd, error) {
+	if p == nil {
+		return exec.Command(name, args...), nil
+	}
+	resolved, err := p.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return command(resolved, name, args)
+}
+
+// resolve validates the profile and returns a copy with absolute paths
+// and defaults applied.
+func (p *Profile) resolve() (*Profile, error) {
+	out := *p
+	if out.Network == "" {
+		out.Network = NetworkNone
+	}
+	switch out.Network {
+	case NetworkAll, NetworkLoopback, NetworkNone:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidScope, out.Network)
+	}
+
+	var err error
+	if out.ReadWritePaths, err = absPaths(p.ReadWritePaths); err != nil {
+		return nil, err
+	}
+	if out.ReadOnlyPaths, err = absPaths(p.ReadOnlyPaths); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// absPaths resolves paths to cleaned absolute form.
+func absPaths(paths []string) ([]string, error) {
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: resolve %q: %w", path, err)
+		}
+		out = append(out, abs)
+	}
+	return out, nil
+}
@@ -0,0 +1,56 @@
+//go:build darwin
+
+// macOS confinement via sandbox-exec and a generated SBPL profile.
+
+package sandbox
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultHelper is the macOS sandbox launcher.
+const defaultHelper = "/usr/bin/sandbox-exec"
+
+// command builds the confined command for macOS.
+func command(p *Profile, name string, args []string) (*exec.Cmd, error) {
+	helper := p.Helper
+	if helper == "" {
+		helper = defaultHelper
+	}
+	path, err := exec.LookPath(helper)
+	if err != nil {
+		if p.Strict {
+			return nil, fmt.Errorf("%w: %s", ErrHelperMissing, helper)
+		}
+		log.Printf("sandbox: %s not found, profile %q not enforced", helper, p.Name)
+		return exec.Command(name, args...), nil
+	}
+
+	full := append([]string{"-p", sbplProfile(p), "--", name}, args...)
+	return exec.Command(path, full...), nil
+}
+
+// sbplProfile renders a profile as Sandbox Profile Language.
+func sbplProfile(p *Profile) string {
+	var b strings.Builder
+	b.WriteString("(version 1)\n(deny default)\n")
+	b.WriteString("(allow process-exec process-fork signal sysctl-read mach-lookup)\n")
+	b.WriteString("(allow file-read* (subpath \"/usr\") (subpath \"/System\") (subpath \"/Library\") (subpath \"/private/etc\") (subpath \"/dev\"))\n")
+	for _, dir := range p.ReadOnlyPaths {
+		fmt.Fprintf(&b, "(allow file-read* (subpath %s))\n", strconv.Quote(dir))
+	}
+	for _, dir := range p.ReadWritePaths {
+		fmt.Fprintf(&b, "(allow file-read* file-write* (subpath %s))\n", strconv.Quote(dir))
+	}
+	switch p.Network {
+	case NetworkAll:
+		b.WriteString("(allow network*)\n")
+	case NetworkLoopback:
+		b.WriteString("(allow network* (local ip \"localhost:*\") (remote ip \"localhost:*\"))\n")
+	}
+	return b.String()
+}
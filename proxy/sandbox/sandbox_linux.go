@@ -0,0 +1,79 @@
+//go:build linux
+
+// Linux confinement via bubblewrap, with a namespace-only fallback.
+
+package sandbox
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"syscall"
+)
+
+// defaultHelper is the bubblewrap binary name.
+const defaultHelper = "bwrap"
+
+// systemReadOnly are host paths every server needs to execute binaries.
+var systemReadOnly = []string{"/usr", "/bin", "/lib", "/lib64", "/etc/ssl", "/etc/resolv.conf"}
+
+// command builds the confined command for Linux.
+func command(p *Profile, name string, args []string) (*exec.Cmd, error) {
+	helper := p.Helper
+	if helper == "" {
+		helper = defaultHelper
+	}
+
+	path, err := exec.LookPath(helper)
+	if err != nil {
+		if p.Strict {
+			return nil, fmt.Errorf("%w: %s", ErrHelperMissing, helper)
+		}
+		log.Printf("sandbox: %s not found, profile %q limited to network isolation", helper, p.Name)
+		return namespaceCommand(p, name, args), nil
+	}
+
+	return exec.Command(path, bwrapArgs(p, name, args)...), nil
+}
+
+// bwrapArgs builds the bubblewrap argument list for a profile.
+func bwrapArgs(p *Profile, name string, args []string) []string {
+	out := []string{
+		"--die-with-parent",
+		"--new-session",
+		"--unshare-pid",
+		"--unshare-ipc",
+		"--unshare-uts",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+	}
+	if p.Network != NetworkAll {
+		// A fresh network namespace only has loopback, which covers
+		// both NetworkNone and NetworkLoopback.
+		out = append(out, "--unshare-net")
+	}
+	for _, dir := range systemReadOnly {
+		out = append(out, "--ro-bind-try", dir, dir)
+	}
+	for _, dir := range p.ReadOnlyPaths {
+		out = append(out, "--ro-bind", dir, dir)
+	}
+	for _, dir := range p.ReadWritePaths {
+		out = append(out, "--bind", dir, dir)
+	}
+	out = append(out, "--", name)
+	return append(out, args...)
+}
+
+// namespaceCommand isolates the network without a helper binary.
+func namespaceCommand(p *Profile, name string, args []string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if p.Network != NetworkAll {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+			Pdeathsig:  syscall.SIGKILL,
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	p, err := (&Profile{
+		Name:           "fs",
+		ReadWritePaths: []string{"/workspace/out"},
+		ReadOnlyPaths:  []string{"/workspace/src"},
+	}).resolve()
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	args := bwrapArgs(p, "server", []string{"--root", "/workspace"})
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"--unshare-net",
+		"--bind /workspace/out /workspace/out",
+		"--ro-bind /workspace/src /workspace/src",
+		"-- server --root /workspace",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("bwrap args missing %q: %s", want, joined)
+		}
+	}
+}
+
+func TestBwrapArgs_NetworkAll(t *testing.T) {
+	p, _ := (&Profile{Network: NetworkAll}).resolve()
+	if slices.Contains(bwrapArgs(p, "server", nil), "--unshare-net") {
+		t.Error("NetworkAll should not unshare the network namespace")
+	}
+}
+
+func TestProfile_Validation(t *testing.T) {
+	_, err := (&Profile{Network: "intranet"}).Command("true")
+	if !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("expected ErrInvalidScope, got %v", err)
+	}
+
+	_, err = (&Profile{Helper: "no-such-sandbox-helper", Strict: true}).Command("true")
+	if !errors.Is(err, ErrHelperMissing) {
+		t.Errorf("expected ErrHelperMissing for strict profile, got %v", err)
+	}
+}
+
+func TestCommand_FallbackLogged(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	cmd, err := (&Profile{Name: "fs", Helper: "no-such-sandbox-helper"}).Command("true")
+	if err != nil {
+		t.Fatalf("non-strict profile should fall back, got %v", err)
+	}
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.Cloneflags == 0 {
+		t.Error("fallback should still isolate the network")
+	}
+	if !strings.Contains(buf.String(), `profile "fs" limited to network isolation`) {
+		t.Errorf("fallback not logged: %q", buf.String())
+	}
+}
@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+)
+
+// Action is what the middleware does with a response that has findings.
+type Action string
+
+const (
+	// ActionBlock replaces the response with an error
+	ActionBlock Action = "block"
+	// ActionRedact removes the matched spans and delivers the rest
+	ActionRedact Action = "redact"
+	// ActionLog delivers the response unchanged and logs the findings
+	ActionLog Action = "log"
+)
+
+// CodeInjectionDetected is the JSON-RPC error code of a blocked
+// response. It is in the implementation-defined server error range.
+const CodeInjectionDetected = -32003
+
+// scannedMethods are the methods whose results carry model-readable
+// content.
+var scannedMethods = map[string]bool{
+	"tools/call":     true,
+	"resources/read": true,
+	"prompts/get":    true,
+}
+
+// ItemFindings are the findings in one text field of a response.
+type ItemFindings struct {
+	// Path locates the field, e.g. "content[1].text"
+	Path string `json:"path"`
+
+	// Findings are the matches in the field
+	Findings []Finding `json:"findings"`
+}
+
+// ScanResponse scans the text fields of a tools/call, resources/read,
+// or prompts/get response: every "text" string in the result and a
+// prompt's description.
+//
+// # Arguments
+//   - method: The request method the response answers
+//   - response: Raw JSON-RPC response
+//   - redact: Rewrite the response with findings removed
+//
+// # Returns
+//   - The response, rewritten if redact is set and anything was found
+//   - Findings grouped by field (nil if none, or for other methods and
+//     error responses)
+func (s *Scanner) ScanResponse(method string, response []byte, redact bool) ([]byte, []ItemFindings, error) {
+	if !scannedMethods[method] {
+		return response, nil, nil
+	}
+	resp, err := jsonrpc.Parse(response)
+	if err != nil || resp.Error != nil || len(resp.Result) == 0 {
+		return response, nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(resp.Result))
+	dec.UseNumber()
+	var result interface{}
+	if err := dec.Decode(&result); err != nil {
+		return response, nil, nil
+	}
+
+	var items []ItemFindings
+	visit := func(path, text string) string {
+		var out string
+		var findings []Finding
+		if redact {
+			out, findings = s.Redact(text)
+		} else {
+			out, findings = text, s.Scan(text)
+		}
+		if len(findings) > 0 {
+			items = append(items, ItemFindings{Path: path, Findings: findings})
+		}
+		return out
+	}
+	if obj, ok := result.(map[string]interface{}); ok && method == "prompts/get" {
+		if desc, ok := obj["description"].(string); ok {
+			obj["description"] = visit("description", desc)
+		}
+	}
+	result = walkText(result, "", visit)
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+
+	if len(items) == 0 || !redact {
+		return response, items, nil
+	}
+	rewritten, err := jsonrpc.NewResponse(resp.ID, result)
+	if err != nil {
+		return nil, items, fmt.Errorf("scanner: build redacted response: %w", err)
+	}
+	data, err := jsonrpc.Serialize(rewritten)
+	if err != nil {
+		return nil, items, fmt.Errorf("scanner: encode redacted response: %w", err)
+	}
+	return data, items, nil
+}
+
+// walkText calls visit on every string stored under a "text" key and
+// stores the returned value in its place.
+func walkText(v interface{}, path string, visit func(path, text string) string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			if text, ok := child.(string); ok && k == "text" {
+				v[k] = visit(childPath, text)
+				continue
+			}
+			v[k] = walkText(child, childPath, visit)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = walkText(child, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	}
+	return v
+}
+
+// Middleware returns a middleware stage that scans the response to each
+// tools/call, resources/read, and prompts/get request and applies
+// action to responses with findings.
+//
+// # Security Notes
+//
+// Findings are heuristics. ActionBlock suits untrusted servers;
+// ActionRedact keeps benign content flowing while removing the carrier
+// of an injection; ActionLog is for tuning rules before enforcing them.
+func (s *Scanner) Middleware(action Action) middleware.Middleware {
+	return func(mc *middleware.MessageContext, msg []byte, next middleware.Handler) ([]byte, error) {
+		response, err := next(mc, msg)
+		if err != nil {
+			return response, err
+		}
+		req := mc.Message
+		if req == nil || mc.Direction == middleware.ServerToClient || !scannedMethods[req.Method] {
+			return response, nil
+		}
+
+		out, items, err := s.ScanResponse(req.Method, response, action == ActionRedact)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return response, nil
+		}
+		log.Printf("scanner: %s response: %d field(s) matched injection rules (%s, first %s at %s)",
+			req.Method, len(items), action, items[0].Findings[0].Rule, items[0].Path)
+
+		switch action {
+		case ActionBlock:
+			reply, err := jsonrpc.NewErrorResponse(req.ID, CodeInjectionDetected, "Blocked by content scanner", items)
+			if err != nil {
+				return nil, err
+			}
+			return jsonrpc.Serialize(reply)
+		case ActionRedact:
+			return out, nil
+		default:
+			return response, nil
+		}
+	}
+}
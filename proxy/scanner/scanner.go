@@ -0,0 +1,231 @@
+// Package scanner detects prompt-injection patterns in server content.
+//
+// Tool results, resource contents, and prompt templates are read by the
+// model as if they were trusted context. A server (or anything the
+// server fetched) can hide instructions in them. The scanner looks for
+// the common carriers of such instructions using pure-Go heuristics
+// that are linear in the input and safe to run on every response.
+//
+// # Rules
+//
+//   - instruction-override: "ignore previous instructions" phrasing
+//   - role-marker: chat template tokens such as <|im_start|> or [INST]
+//   - hidden-instructions: HTML comments and pseudo-tags addressed to
+//     the model, and requests to keep things from the user
+//   - invisible-unicode: zero-width, bidi control, and tag characters
+//   - data-uri: base64 data: URIs carrying opaque payloads
+//
+// Pattern rules also run on the text's skeleton (see package normalize),
+// so an instruction spelled with homoglyphs or split by zero-width
+// characters still matches; such findings cover the original text.
+//
+// # Thread Safety
+//
+// Scanner is immutable and safe for concurrent use.
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/normalize"
+)
+
+// ErrInvalidPattern is returned when an extra rule does not compile.
+var ErrInvalidPattern = errors.New("scanner: invalid pattern")
+
+// Built-in rule names.
+const (
+	RuleOverride   = "instruction-override"
+	RuleRoleMarker = "role-marker"
+	RuleHidden     = "hidden-instructions"
+	RuleInvisible  = "invisible-unicode"
+	RuleDataURI    = "data-uri"
+)
+
+// RedactedText replaces matched spans when redacting.
+const RedactedText = "[redacted]"
+
+// maxExcerpt bounds the matched text recorded in a finding.
+const maxExcerpt = 80
+
+// builtinPatterns are the regular-expression rules. Invisible Unicode
+// is matched rune by rune instead.
+var builtinPatterns = []struct {
+	rule string
+	re   *regexp.Regexp
+}{
+	{RuleOverride, regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override)\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding|original)\s+(?:instructions|prompts?|directions|rules|guidelines|context)`)},
+	{RuleOverride, regexp.MustCompile(`(?i)\b(?:you are now|from now on,? you|new instructions\s*:)`)},
+	{RuleRoleMarker, regexp.MustCompile(`<\|?(?:im_start|im_end|system|assistant|endoftext)\|?>|\[/?INST\]|<</?SYS>>`)},
+	{RuleHidden, regexp.MustCompile(`(?is)<!--.*?[a-z]{3,}.*?-->`)},
+	{RuleHidden, regexp.MustCompile(`(?i)</?(?:important|instructions?|system|secret|hidden)>`)},
+	{RuleHidden, regexp.MustCompile(`(?i)\b(?:do not|don't|never)\s+(?:tell|mention|reveal|inform|show)\s+(?:this\s+to\s+)?(?:the\s+)?user\b`)},
+	{RuleDataURI, regexp.MustCompile(`(?i)data:[a-z0-9.+/-]*(?:;[a-z0-9=.-]+)*;base64,[A-Za-z0-9+/]{32,}={0,2}`)},
+}
+
+// Config selects the rules a Scanner applies.
+type Config struct {
+	// Disable lists built-in rules to skip
+	Disable []string
+
+	// Extra maps additional rule names to regular expressions
+	Extra map[string]string
+}
+
+// Finding is one match in scanned text.
+type Finding struct {
+	// Rule is the name of the matching rule
+	Rule string `json:"rule"`
+
+	// Start and End are the byte offsets of the match
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Excerpt is the matched text, truncated and with invisible
+	// characters shown as escapes
+	Excerpt string `json:"excerpt"`
+}
+
+// rule is a compiled pattern rule.
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Scanner applies injection rules to text.
+type Scanner struct {
+	rules     []rule
+	invisible bool
+}
+
+// New creates a Scanner from cfg.
+//
+// # Returns
+//   - ErrInvalidPattern if an Extra entry is not a valid regular expression
+func New(cfg Config) (*Scanner, error) {
+	s := &Scanner{invisible: !slices.Contains(cfg.Disable, RuleInvisible)}
+	for _, p := range builtinPatterns {
+		if !slices.Contains(cfg.Disable, p.rule) {
+			s.rules = append(s.rules, rule{name: p.rule, re: p.re})
+		}
+	}
+
+	names := make([]string, 0, len(cfg.Extra))
+	for name := range cfg.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(cfg.Extra[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPattern, name, err)
+		}
+		s.rules = append(s.rules, rule{name: name, re: re})
+	}
+	return s, nil
+}
+
+// Scan returns every finding in text, ordered by offset.
+func (s *Scanner) Scan(text string) []Finding {
+	var findings []Finding
+	for _, r := range s.rules {
+		for _, loc := range r.re.FindAllStringIndex(text, -1) {
+			findings = append(findings, newFinding(r.name, text, loc[0], loc[1]))
+		}
+	}
+	if m := normalize.Map(text); m.Text != text {
+		for _, r := range s.rules {
+			for _, loc := range r.re.FindAllStringIndex(m.Text, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				start, end := m.Span(loc[0], loc[1])
+				f := newFinding(r.name, text, start, end)
+				if !slices.Contains(findings, f) {
+					findings = append(findings, f)
+				}
+			}
+		}
+	}
+	if s.invisible {
+		findings = append(findings, invisibleRuns(text)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	return findings
+}
+
+// Redact returns text with every finding removed. Pattern matches are
+// replaced with RedactedText; invisible characters are deleted.
+func (s *Scanner) Redact(text string) (string, []Finding) {
+	findings := s.Scan(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, f := range findings {
+		if f.End <= pos {
+			// Nested in a span already redacted
+			continue
+		}
+		start := max(f.Start, pos)
+		b.WriteString(text[pos:start])
+		if f.Rule != RuleInvisible {
+			b.WriteString(RedactedText)
+		}
+		pos = f.End
+	}
+	b.WriteString(text[pos:])
+	return b.String(), findings
+}
+
+// invisibleRuns finds runs of characters that render as nothing.
+func invisibleRuns(text string) []Finding {
+	var findings []Finding
+	start := -1
+	for i, c := range text {
+		if normalize.IsInvisible(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			findings = append(findings, newFinding(RuleInvisible, text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		findings = append(findings, newFinding(RuleInvisible, text, start, len(text)))
+	}
+	return findings
+}
+
+// newFinding builds a finding with a printable excerpt.
+func newFinding(name, text string, start, end int) Finding {
+	excerpt := text[start:end]
+	if len(excerpt) > maxExcerpt {
+		cut := maxExcerpt
+		for cut > 0 && !utf8.RuneStart(excerpt[cut]) {
+			cut--
+		}
+		excerpt = excerpt[:cut] + "..."
+	}
+	var b strings.Builder
+	for _, c := range excerpt {
+		if normalize.IsInvisible(c) || unicode.IsControl(c) {
+			fmt.Fprintf(&b, "\\u%04X", c)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return Finding{Rule: name, Start: start, End: end, Excerpt: b.String()}
+}
@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limit caps the calls of one tool category in flight at once.
+type Limit struct {
+	// Category names the limit in reasons and status
+	Category string `json:"category"`
+
+	// Tools are the tools in the category; a trailing * matches every
+	// tool with that prefix
+	Tools []string `json:"tools"`
+
+	// MaxInFlight is how many of the category's calls may run at once
+	// (zero leaves the category unlimited)
+	MaxInFlight int `json:"max_in_flight"`
+}
+
+// LimitStatus reports a concurrency limit's current state.
+type LimitStatus struct {
+	Limit
+	InFlight int    `json:"in_flight"`
+	Denied   uint64 `json:"denied"`
+}
+
+// category is a compiled Limit: a semaphore of MaxInFlight slots.
+type category struct {
+	Limit
+	slots  chan struct{} // nil when unlimited
+	denied atomic.Uint64
+}
+
+// compileLimits validates limits and builds their semaphores.
+func compileLimits(limits []Limit) ([]*category, error) {
+	seen := make(map[string]bool)
+	var out []*category
+	for _, l := range limits {
+		if l.Category == "" || seen[l.Category] {
+			return nil, fmt.Errorf("%w: limit categories must be unique and non-empty", ErrInvalidRule)
+		}
+		seen[l.Category] = true
+		if l.MaxInFlight < 0 {
+			return nil, fmt.Errorf("%w: %s: max_in_flight must not be negative", ErrInvalidRule, l.Category)
+		}
+		c := &category{Limit: l}
+		if l.MaxInFlight > 0 {
+			c.slots = make(chan struct{}, l.MaxInFlight)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// matches reports whether tool belongs to the category.
+func (c *category) matches(tool string) bool {
+	for _, pattern := range c.Tools {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(tool, prefix) {
+				return true
+			}
+		} else if pattern == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire takes a concurrency slot for a call to tool, waiting up to
+// Config.LimitWait (or until ctx ends) for one to free up. Tools in no
+// category, or in an unlimited one, always get a slot. A tool in
+// several categories counts against the first that lists it.
+//
+// Limits are process-wide: every session sharing the scheduler draws
+// from the same slots.
+//
+// # Returns
+//   - release, to be called once when the call completes; nil if
+//     denied
+//   - Reason naming the full category if denied
+//
+// # Security Notes
+//
+// An agent can issue calls in parallel faster than any rule reacts to
+// their results. Holding destructive or expensive tools to a few calls
+// in flight bounds what such a burst can do, even when every call on
+// its own is allowed.
+func (s *Scheduler) Acquire(ctx context.Context, tool string) (release func(), reason string) {
+	var c *category
+	for _, cat := range s.limits {
+		if cat.matches(tool) {
+			c = cat
+			break
+		}
+	}
+	if c == nil || c.slots == nil {
+		return func() {}, ""
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		return c.releaser(), ""
+	default:
+	}
+	if s.limitWait > 0 {
+		timer := time.NewTimer(s.limitWait)
+		defer timer.Stop()
+		select {
+		case c.slots <- struct{}{}:
+			return c.releaser(), ""
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	c.denied.Add(1)
+	return nil, fmt.Sprintf("concurrency limit: %d %s call(s) already in flight", c.MaxInFlight, c.Category)
+}
+
+// releaser returns a function freeing a slot taken by Acquire; calls
+// after the first do nothing.
+func (c *category) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(func() { <-c.slots }) }
+}
+
+// limitStatus reports every concurrency limit.
+func (s *Scheduler) limitStatus() []LimitStatus {
+	var out []LimitStatus
+	for _, c := range s.limits {
+		out = append(out, LimitStatus{Limit: c.Limit, InFlight: len(c.slots), Denied: c.denied.Load()})
+	}
+	return out
+}
@@ -0,0 +1,328 @@
+// Package schedule blocks tool calls by time window and maintenance mode,
+// and limits how many run at once.
+//
+// Rules pair a cron-like window with a scope: for example, deny every
+// mutating tool outside business hours, or allow only read-only tools
+// during a Sunday-night maintenance window. Operators can force any rule
+// on or off and switch on an ad-hoc maintenance mode through the admin
+// API without editing configuration.
+//
+// # Windows
+//
+// A rule's Cron expression selects minutes (see Spec). With a zero
+// Duration the window is exactly the matching minutes, which suits
+// ranges like "* 9-17 * * 1-5". With a Duration the window opens at
+// each matching minute and stays open that long, which suits starts like
+// "0 2 * * 0" with a two hour duration.
+//
+// # Concurrency Limits
+//
+// Limits group tools into categories and cap the calls of each category
+// in flight at once, e.g. one execute_command, four network fetches,
+// and any number of reads; see Scheduler.Acquire.
+//
+// # Read-only Tools
+//
+// Only tools listed in ReadOnlyTools are treated as read-only; every
+// other tool is mutating. Server-provided hints are not trusted.
+//
+// # Thread Safety
+//
+// Scheduler is safe for concurrent use and is typically shared by all
+// sessions so overrides apply process-wide.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+// maxDuration bounds window durations (and the backwards scan they need).
+const maxDuration = 7 * 24 * time.Hour
+
+// Scheduler errors.
+var (
+	ErrUnknownRule = errors.New("schedule: unknown rule")
+	ErrInvalidRule = errors.New("schedule: invalid rule")
+)
+
+// Scope selects which tool calls a rule denies.
+type Scope string
+
+const (
+	// ScopeMutating denies every tool not listed as read-only
+	ScopeMutating Scope = "mutating"
+	// ScopeAll denies every tool call
+	ScopeAll Scope = "all"
+)
+
+// Override forces a rule's state regardless of its window.
+type Override string
+
+const (
+	OverrideAuto     Override = "auto"
+	OverrideActive   Override = "active"
+	OverrideInactive Override = "inactive"
+)
+
+// Rule denies tool calls in (or outside) a time window.
+type Rule struct {
+	// Name identifies the rule for overrides and audit
+	Name string `json:"name"`
+
+	// Cron selects the window's minutes or start times
+	Cron string `json:"cron"`
+
+	// Duration keeps the window open after each matching minute
+	// (zero: the window is the matching minutes only)
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Outside applies the rule when the time is not in the window
+	Outside bool `json:"outside,omitempty"`
+
+	// Scope selects the tools denied while the rule applies
+	Scope Scope `json:"scope"`
+}
+
+// Config contains scheduler configuration.
+type Config struct {
+	// Rules are evaluated in order; the first applying rule denies
+	Rules []Rule `json:"rules"`
+
+	// ReadOnlyTools are allowed by ScopeMutating rules and maintenance
+	ReadOnlyTools []string `json:"read_only_tools"`
+
+	// Location is the time zone for windows (nil uses local time)
+	Location *time.Location `json:"-"`
+
+	// Limits cap the calls in flight per tool category
+	Limits []Limit `json:"limits"`
+
+	// LimitWait is how long a call waits for a free slot before it is
+	// denied (zero denies at once)
+	LimitWait time.Duration `json:"limit_wait"`
+
+	// Clock decides which windows are open and when maintenance ends
+	// (nil uses the system clock)
+	Clock clock.Clock `json:"-"`
+}
+
+// Maintenance is the ad-hoc maintenance mode set through the admin API.
+// While enabled, only read-only tools pass.
+type Maintenance struct {
+	Enabled bool      `json:"enabled"`
+	Until   time.Time `json:"until,omitempty"` // zero: until disabled
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// RuleStatus reports a rule's current state.
+type RuleStatus struct {
+	Rule
+	Override Override `json:"override"`
+	Applies  bool     `json:"applies"`
+}
+
+// Status is a snapshot of the scheduler.
+type Status struct {
+	Time        time.Time    `json:"time"`
+	Maintenance Maintenance  `json:"maintenance"`
+	Rules       []RuleStatus `json:"rules"`
+
+	// Limits are the concurrency limits with their calls in flight
+	Limits []LimitStatus `json:"limits,omitempty"`
+}
+
+type compiledRule struct {
+	Rule
+	spec *Spec
+}
+
+// Scheduler evaluates time-window rules.
+type Scheduler struct {
+	rules    []*compiledRule
+	readOnly map[string]bool
+	loc      *time.Location
+	now      func() time.Time
+
+	limits    []*category
+	limitWait time.Duration
+
+	mu          sync.Mutex
+	overrides   map[string]Override
+	maintenance Maintenance
+}
+
+// New creates a scheduler, validating every rule.
+func New(cfg *Config) (*Scheduler, error) {
+	s := &Scheduler{
+		readOnly:  make(map[string]bool),
+		loc:       time.Local,
+		now:       time.Now,
+		overrides: make(map[string]Override),
+	}
+	if cfg == nil {
+		return s, nil
+	}
+	if cfg.Location != nil {
+		s.loc = cfg.Location
+	}
+	s.now = clock.Func(cfg.Clock)
+	for _, tool := range cfg.ReadOnlyTools {
+		s.readOnly[tool] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		if rule.Name == "" || seen[rule.Name] {
+			return nil, fmt.Errorf("%w: rule names must be unique and non-empty", ErrInvalidRule)
+		}
+		seen[rule.Name] = true
+		if rule.Scope != ScopeMutating && rule.Scope != ScopeAll {
+			return nil, fmt.Errorf("%w: %s: unknown scope %q", ErrInvalidRule, rule.Name, rule.Scope)
+		}
+		if rule.Duration < 0 || rule.Duration > maxDuration {
+			return nil, fmt.Errorf("%w: %s: duration must be within 0-%s", ErrInvalidRule, rule.Name, maxDuration)
+		}
+		spec, err := ParseSpec(rule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidRule, rule.Name, err)
+		}
+		s.rules = append(s.rules, &compiledRule{Rule: rule, spec: spec})
+	}
+	limits, err := compileLimits(cfg.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LimitWait < 0 {
+		return nil, fmt.Errorf("%w: limit_wait must not be negative", ErrInvalidRule)
+	}
+	s.limits, s.limitWait = limits, cfg.LimitWait
+	return s, nil
+}
+
+// Check decides whether a tool call may proceed now.
+//
+// # Returns
+//   - true if allowed
+//   - Reason naming the maintenance mode or rule that denied the call
+func (s *Scheduler) Check(tool string) (bool, string) {
+	now := s.now().In(s.loc)
+	readOnly := s.readOnly[tool]
+
+	s.mu.Lock()
+	maintenance := s.maintenanceLocked(now)
+	overrides := make(map[string]Override, len(s.overrides))
+	for k, v := range s.overrides {
+		overrides[k] = v
+	}
+	s.mu.Unlock()
+
+	if maintenance.Enabled && !readOnly {
+		reason := "maintenance mode: only read-only tools are allowed"
+		if maintenance.Reason != "" {
+			reason += " (" + maintenance.Reason + ")"
+		}
+		return false, reason
+	}
+
+	for _, rule := range s.rules {
+		if !rule.applies(now, overrides[rule.Name]) {
+			continue
+		}
+		if rule.Scope == ScopeAll || !readOnly {
+			return false, fmt.Sprintf("denied by schedule rule %q", rule.Name)
+		}
+	}
+	return true, ""
+}
+
+// SetOverride forces rule name on or off, or returns it to its window.
+func (s *Scheduler) SetOverride(name string, o Override) error {
+	switch o {
+	case OverrideAuto, OverrideActive, OverrideInactive:
+	default:
+		return fmt.Errorf("%w: unknown override %q", ErrInvalidRule, o)
+	}
+	for _, rule := range s.rules {
+		if rule.Name != name {
+			continue
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if o == OverrideAuto {
+			delete(s.overrides, name)
+		} else {
+			s.overrides[name] = o
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownRule, name)
+}
+
+// SetMaintenance enables or disables the ad-hoc maintenance mode.
+func (s *Scheduler) SetMaintenance(m Maintenance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maintenance = m
+}
+
+// Status returns the current state of maintenance mode and every rule.
+func (s *Scheduler) Status() Status {
+	now := s.now().In(s.loc)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Status{Time: now, Maintenance: s.maintenanceLocked(now)}
+	for _, rule := range s.rules {
+		o, ok := s.overrides[rule.Name]
+		if !ok {
+			o = OverrideAuto
+		}
+		st.Rules = append(st.Rules, RuleStatus{
+			Rule:     rule.Rule,
+			Override: o,
+			Applies:  rule.applies(now, o),
+		})
+	}
+	st.Limits = s.limitStatus()
+	return st
+}
+
+// maintenanceLocked returns maintenance state, expiring it if its end
+// time has passed. Caller must hold s.mu.
+func (s *Scheduler) maintenanceLocked(now time.Time) Maintenance {
+	if s.maintenance.Enabled && !s.maintenance.Until.IsZero() && !now.Before(s.maintenance.Until) {
+		s.maintenance = Maintenance{}
+	}
+	return s.maintenance
+}
+
+// applies reports whether the rule denies at now given its override.
+func (r *compiledRule) applies(now time.Time, o Override) bool {
+	switch o {
+	case OverrideActive:
+		return true
+	case OverrideInactive:
+		return false
+	}
+	return r.inWindow(now) != r.Outside
+}
+
+// inWindow reports whether now falls in the rule's window.
+func (r *compiledRule) inWindow(now time.Time) bool {
+	minute := now.Truncate(time.Minute)
+	if r.Duration == 0 {
+		return r.spec.Matches(minute)
+	}
+	// The window is open if it started within the last Duration
+	for t := minute; now.Sub(t) < r.Duration; t = t.Add(-time.Minute) {
+		if r.spec.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+func TestSpec_Matches(t *testing.T) {
+	// Wednesday 2026-03-04
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 4, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		expr     string
+		t        time.Time
+		expected bool
+	}{
+		{"* 9-17 * * 1-5", at(10, 30), true},
+		{"* 9-17 * * 1-5", at(18, 0), false},
+		{"* 9-17 * * 0,6", at(10, 0), false},
+		{"*/15 * * * *", at(3, 45), true},
+		{"*/15 * * * *", at(3, 44), false},
+		{"0 0 4 * 7", at(0, 0), true}, // day-of-month OR day-of-week
+		{"0-30/10 12 * 3 *", at(12, 20), true},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) failed: %v", tt.expr, err)
+		}
+		if got := spec.Matches(tt.t); got != tt.expected {
+			t.Errorf("%q.Matches(%s) = %v, expected %v", tt.expr, tt.t.Format(time.Kitchen), got, tt.expected)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * mon", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseSpec(bad); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("ParseSpec(%q) = %v, expected ErrInvalidSpec", bad, err)
+		}
+	}
+}
+
+func TestScheduler_Check(t *testing.T) {
+	s, err := New(&Config{
+		Rules: []Rule{
+			{Name: "after-hours", Cron: "* 9-16 * * 1-5", Outside: true, Scope: ScopeMutating},
+			{Name: "sunday-maintenance", Cron: "0 2 * * 0", Duration: 2 * time.Hour, Scope: ScopeAll},
+		},
+		ReadOnlyTools: []string{"read_file"},
+		Location:      time.UTC,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC) // Wednesday
+	s.now = func() time.Time { return now }
+
+	if ok, _ := s.Check("write_file"); !ok {
+		t.Error("mutating tool should pass during business hours")
+	}
+
+	now = time.Date(2026, 3, 4, 20, 0, 0, 0, time.UTC)
+	if ok, _ := s.Check("write_file"); ok {
+		t.Error("mutating tool should be denied after hours")
+	}
+	if ok, _ := s.Check("read_file"); !ok {
+		t.Error("read-only tool should pass after hours")
+	}
+
+	now = time.Date(2026, 3, 8, 3, 30, 0, 0, time.UTC) // Sunday, inside window
+	if ok, _ := s.Check("read_file"); ok {
+		t.Error("maintenance window should deny all tools")
+	}
+	if err := s.SetOverride("sunday-maintenance", OverrideInactive); err != nil {
+		t.Fatalf("SetOverride failed: %v", err)
+	}
+	if ok, _ := s.Check("read_file"); !ok {
+		t.Error("inactive override should lift the window")
+	}
+	if err := s.SetOverride("nope", OverrideActive); !errors.Is(err, ErrUnknownRule) {
+		t.Errorf("expected ErrUnknownRule, got %v", err)
+	}
+}
+
+func TestScheduler_Maintenance(t *testing.T) {
+	s, _ := New(&Config{ReadOnlyTools: []string{"read_file"}})
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	s.SetMaintenance(Maintenance{Enabled: true, Until: now.Add(time.Hour), Reason: "db migration"})
+	if ok, reason := s.Check("write_file"); ok || reason == "" {
+		t.Error("maintenance should deny mutating tools")
+	}
+	if ok, _ := s.Check("read_file"); !ok {
+		t.Error("maintenance should allow read-only tools")
+	}
+
+	now = now.Add(time.Hour)
+	if ok, _ := s.Check("write_file"); !ok {
+		t.Error("maintenance should expire")
+	}
+}
+
+func TestScheduler_Acquire(t *testing.T) {
+	s, err := New(&Config{
+		Limits: []Limit{
+			{Category: "exec", Tools: []string{"execute_command"}, MaxInFlight: 1},
+			{Category: "network", Tools: []string{"fetch*"}, MaxInFlight: 2},
+			{Category: "reads", Tools: []string{"read_file"}},
+		},
+		LimitWait: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+
+	release, _ := s.Acquire(ctx, "execute_command")
+	if release == nil {
+		t.Fatal("first execute_command denied")
+	}
+	if again, reason := s.Acquire(ctx, "execute_command"); again != nil || !strings.Contains(reason, "exec") {
+		t.Errorf("second execute_command = %q, expected a denial", reason)
+	}
+	release()
+	release() // a second release frees nothing
+	if again, _ := s.Acquire(ctx, "execute_command"); again == nil {
+		t.Error("execute_command denied after release")
+	} else {
+		again()
+	}
+
+	// A waiting call gets the slot freed within LimitWait
+	a, _ := s.Acquire(ctx, "fetch_url")
+	b, _ := s.Acquire(ctx, "fetch_page")
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		a()
+	}()
+	c, _ := s.Acquire(ctx, "fetch_url")
+	if a == nil || b == nil || c == nil {
+		t.Error("network calls denied within the limit")
+	}
+
+	// Unlimited and uncategorized tools always pass
+	for i := 0; i < 10; i++ {
+		if r, _ := s.Acquire(ctx, "read_file"); r == nil {
+			t.Fatal("unlimited category denied")
+		}
+		if r, _ := s.Acquire(ctx, "list_dir"); r == nil {
+			t.Fatal("uncategorized tool denied")
+		}
+	}
+
+	limits := s.Status().Limits
+	if len(limits) != 3 || limits[0].Denied != 1 || limits[1].InFlight != 2 || limits[2].InFlight != 0 {
+		t.Errorf("limit status = %+v", limits)
+	}
+
+	if _, err := New(&Config{Limits: []Limit{{Category: "x", MaxInFlight: 1}, {Category: "x"}}}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("duplicate category = %v, expected ErrInvalidRule", err)
+	}
+}
+
+func TestScheduler_Clock(t *testing.T) {
+	// Monday 2026-01-05, a minute before business hours
+	fake := clock.NewFake(time.Date(2026, 1, 5, 8, 59, 0, 0, time.UTC))
+	s, err := New(&Config{
+		Rules:    []Rule{{Name: "business-hours", Cron: "* 9-16 * * 1-5", Outside: true, Scope: ScopeMutating}},
+		Location: time.UTC,
+		Clock:    fake,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	steps := []struct {
+		advance time.Duration
+		allowed bool
+	}{
+		{0, false},
+		{time.Minute, true},
+		{8 * time.Hour, false},      // 17:00
+		{4 * 24 * time.Hour, false}, // Friday 17:00
+		{-8 * time.Hour, true},      // Friday 09:00
+		{24 * time.Hour, false},     // Saturday
+	}
+	for _, step := range steps {
+		now := fake.Advance(step.advance)
+		if ok, _ := s.Check("write_file"); ok != step.allowed {
+			t.Errorf("%s: allowed = %v, expected %v", now.Format(time.RFC1123), ok, step.allowed)
+		}
+	}
+
+	s.SetMaintenance(Maintenance{Enabled: true, Until: fake.Now().Add(time.Hour)})
+	fake.Advance(59 * time.Minute)
+	if !s.Status().Maintenance.Enabled {
+		t.Error("maintenance ended before it expired")
+	}
+	fake.Advance(time.Minute)
+	if s.Status().Maintenance.Enabled {
+		t.Error("maintenance still enabled after it expired")
+	}
+}
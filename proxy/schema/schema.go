@@ -0,0 +1,759 @@
+// Package schema validates JSON values against JSON Schema.
+//
+// It covers the keywords MCP servers use in tool inputSchema
+// definitions, so the proxy can refuse malformed tools/call arguments
+// itself rather than relying on the sentinel's Registry Guard, which is
+// stubbed out in builds without FFI.
+//
+// # Supported Keywords
+//
+//   - type, enum, const
+//   - properties, required, additionalProperties, patternProperties,
+//     minProperties, maxProperties
+//   - items, prefixItems, minItems, maxItems, uniqueItems
+//   - minLength, maxLength, pattern
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum (numbers, or
+//     draft-04 booleans), multipleOf
+//   - allOf, anyOf, oneOf, not
+//   - $ref to the schema itself or within it ("#", "#/$defs/name")
+//
+// Other keywords, such as format and title, are ignored, as JSON
+// Schema requires of unknown keywords. Patterns use Go's RE2 syntax; a
+// pattern RE2 cannot compile makes the schema invalid rather than
+// silently unchecked.
+//
+// # Example
+//
+//	s, err := schema.Compile(json.RawMessage(`{"type":"object","required":["path"]}`))
+//	violations := s.Validate(json.RawMessage(`{}`))
+//	// violations[0].String() == `(root): missing required property "path"`
+//
+// # Thread Safety
+//
+// A compiled Schema and a Set are safe for concurrent use.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Errors returned by Compile and Set.
+var (
+	ErrInvalidSchema = errors.New("schema: invalid schema")
+	ErrInvalidValue  = errors.New("schema: value is not JSON")
+)
+
+// MaxViolations bounds the violations Validate reports.
+const MaxViolations = 10
+
+// Violation is one way a value fails its schema.
+type Violation struct {
+	// Path is a JSON pointer to the offending value ("" is the root)
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// String formats the violation as "path: message".
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + v.Message
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	root *node
+}
+
+// node is one compiled schema or subschema.
+type node struct {
+	// always is set for the boolean schemas true and false
+	always *bool
+
+	types    []string
+	enum     []string // canonical JSON
+	constVal *string  // canonical JSON
+
+	properties           map[string]*node
+	patternProperties    []patternNode
+	additionalProperties *node
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	items       *node
+	prefixItems []*node
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+
+	// ref is the node a $ref resolves to
+	ref *node
+}
+
+// patternNode is a patternProperties entry.
+type patternNode struct {
+	re     *regexp.Regexp
+	schema *node
+}
+
+// compiler compiles a schema document, resolving $ref pointers within
+// it.
+type compiler struct {
+	doc  interface{}
+	refs map[string]*node
+}
+
+// Compile compiles a JSON Schema document.
+//
+// # Returns
+//   - The compiled schema
+//   - ErrInvalidSchema if raw is not a schema, a keyword has the wrong
+//     type, a pattern does not compile, or a $ref does not resolve
+func Compile(raw json.RawMessage) (*Schema, error) {
+	doc, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	c := &compiler{doc: doc, refs: make(map[string]*node)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// decode unmarshals JSON keeping numbers exact.
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after value")
+	}
+	return v, nil
+}
+
+// compile compiles the schema v found at pointer.
+func (c *compiler) compile(v interface{}, pointer string) (*node, error) {
+	if b, ok := v.(bool); ok {
+		return &node{always: &b}, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: schema must be an object or boolean", ErrInvalidSchema, pointer)
+	}
+	n := &node{}
+	if pointer == "#" {
+		// Register the root first so "#" references resolve to it
+		c.refs[pointer] = n
+	}
+	bad := func(keyword, want string) error {
+		return fmt.Errorf("%w: %s/%s: must be %s", ErrInvalidSchema, pointer, keyword, want)
+	}
+	sub := func(keyword string, v interface{}) (*node, error) {
+		return c.compile(v, pointer+"/"+escapePointer(keyword))
+	}
+	subList := func(keyword string, v interface{}) ([]*node, error) {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, bad(keyword, "an array of schemas")
+		}
+		out := make([]*node, len(list))
+		for i, item := range list {
+			var err error
+			if out[i], err = c.compile(item, fmt.Sprintf("%s/%s/%d", pointer, keyword, i)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	var err error
+	for keyword, value := range obj {
+		switch keyword {
+		case "type":
+			switch t := value.(type) {
+			case string:
+				n.types = []string{t}
+			case []interface{}:
+				for _, item := range t {
+					s, ok := item.(string)
+					if !ok {
+						return nil, bad(keyword, "a string or array of strings")
+					}
+					n.types = append(n.types, s)
+				}
+			default:
+				return nil, bad(keyword, "a string or array of strings")
+			}
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, bad(keyword, "an array")
+			}
+			for _, item := range list {
+				n.enum = append(n.enum, canonical(item))
+			}
+		case "const":
+			s := canonical(value)
+			n.constVal = &s
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, bad(keyword, "an object of schemas")
+			}
+			n.properties = make(map[string]*node, len(props))
+			for name, prop := range props {
+				if n.properties[name], err = c.compile(prop, pointer+"/properties/"+escapePointer(name)); err != nil {
+					return nil, err
+				}
+			}
+		case "patternProperties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, bad(keyword, "an object of schemas")
+			}
+			for pattern, prop := range props {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s/patternProperties: %v", ErrInvalidSchema, pointer, err)
+				}
+				s, err := c.compile(prop, pointer+"/patternProperties/"+escapePointer(pattern))
+				if err != nil {
+					return nil, err
+				}
+				n.patternProperties = append(n.patternProperties, patternNode{re: re, schema: s})
+			}
+		case "additionalProperties":
+			if n.additionalProperties, err = sub(keyword, value); err != nil {
+				return nil, err
+			}
+		case "required":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, bad(keyword, "an array of strings")
+			}
+			for _, item := range list {
+				s, ok := item.(string)
+				if !ok {
+					return nil, bad(keyword, "an array of strings")
+				}
+				n.required = append(n.required, s)
+			}
+		case "items":
+			if list, ok := value.([]interface{}); ok {
+				// Draft-07 tuple form
+				if n.prefixItems, err = subList(keyword, list); err != nil {
+					return nil, err
+				}
+			} else if n.items, err = sub(keyword, value); err != nil {
+				return nil, err
+			}
+		case "prefixItems":
+			if n.prefixItems, err = subList(keyword, value); err != nil {
+				return nil, err
+			}
+		case "uniqueItems":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, bad(keyword, "a boolean")
+			}
+			n.uniqueItems = b
+		case "minItems", "maxItems", "minLength", "maxLength", "minProperties", "maxProperties":
+			i, ok := count(value)
+			if !ok {
+				return nil, bad(keyword, "a non-negative integer")
+			}
+			switch keyword {
+			case "minItems":
+				n.minItems = &i
+			case "maxItems":
+				n.maxItems = &i
+			case "minLength":
+				n.minLength = &i
+			case "maxLength":
+				n.maxLength = &i
+			case "minProperties":
+				n.minProperties = &i
+			default:
+				n.maxProperties = &i
+			}
+		case "pattern":
+			s, ok := value.(string)
+			if !ok {
+				return nil, bad(keyword, "a string")
+			}
+			if n.pattern, err = regexp.Compile(s); err != nil {
+				return nil, fmt.Errorf("%w: %s/pattern: %v", ErrInvalidSchema, pointer, err)
+			}
+		case "minimum", "maximum", "multipleOf":
+			f, ok := number(value)
+			if !ok || keyword == "multipleOf" && f <= 0 {
+				return nil, bad(keyword, "a number")
+			}
+			switch keyword {
+			case "minimum":
+				n.minimum = &f
+			case "maximum":
+				n.maximum = &f
+			default:
+				n.multipleOf = &f
+			}
+		case "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := value.(bool); ok {
+				// Draft-04 form, applied to minimum/maximum below
+				continue
+			}
+			f, ok := number(value)
+			if !ok {
+				return nil, bad(keyword, "a number")
+			}
+			if keyword == "exclusiveMinimum" {
+				n.exclusiveMinimum = &f
+			} else {
+				n.exclusiveMaximum = &f
+			}
+		case "allOf", "anyOf", "oneOf":
+			list, err := subList(keyword, value)
+			if err != nil {
+				return nil, err
+			}
+			switch keyword {
+			case "allOf":
+				n.allOf = list
+			case "anyOf":
+				n.anyOf = list
+			default:
+				n.oneOf = list
+			}
+		case "not":
+			if n.not, err = sub(keyword, value); err != nil {
+				return nil, err
+			}
+		case "$ref":
+			ref, ok := value.(string)
+			if !ok {
+				return nil, bad(keyword, "a string")
+			}
+			if n.ref, err = c.resolve(ref); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Draft-04 boolean exclusive bounds turn minimum/maximum exclusive
+	if b, _ := obj["exclusiveMinimum"].(bool); b && n.minimum != nil {
+		n.exclusiveMinimum, n.minimum = n.minimum, nil
+	}
+	if b, _ := obj["exclusiveMaximum"].(bool); b && n.maximum != nil {
+		n.exclusiveMaximum, n.maximum = n.maximum, nil
+	}
+	return n, nil
+}
+
+// resolve compiles the target of a $ref within the document.
+func (c *compiler) resolve(ref string) (*node, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("%w: $ref %q: only references within the schema are supported", ErrInvalidSchema, ref)
+	}
+	target := c.doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch t := target.(type) {
+		case map[string]interface{}:
+			v, ok := t[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: $ref %q does not resolve", ErrInvalidSchema, ref)
+			}
+			target = v
+		default:
+			return nil, fmt.Errorf("%w: $ref %q does not resolve", ErrInvalidSchema, ref)
+		}
+	}
+	// Register before compiling so recursive references terminate
+	n := &node{}
+	c.refs[ref] = n
+	compiled, err := c.compile(target, ref)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+// escapePointer escapes a JSON pointer token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// canonical re-encodes a decoded value as canonical JSON, numbers by
+// value so 1 and 1.0 compare equal.
+func canonical(v interface{}) string {
+	switch t := v.(type) {
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			data, _ := json.Marshal(f)
+			return string(data)
+		}
+		return t.String()
+	case map[string]interface{}:
+		m := make(map[string]json.RawMessage, len(t))
+		for k, item := range t {
+			m[k] = json.RawMessage(canonical(item))
+		}
+		data, _ := json.Marshal(m)
+		return string(data)
+	case []interface{}:
+		list := make([]json.RawMessage, len(t))
+		for i, item := range t {
+			list[i] = json.RawMessage(canonical(item))
+		}
+		data, _ := json.Marshal(list)
+		return string(data)
+	default:
+		data, _ := json.Marshal(t)
+		return string(data)
+	}
+}
+
+// number converts a decoded JSON number.
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// count converts a decoded non-negative integer.
+func count(v interface{}) (int, bool) {
+	f, ok := number(v)
+	if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// Validate checks a JSON value against the schema.
+//
+// # Returns
+//
+// The violations found, at most MaxViolations, or nil if value is
+// valid. A value that is not JSON is one violation at the root.
+func (s *Schema) Validate(value json.RawMessage) []Violation {
+	v, err := decode(value)
+	if err != nil {
+		return []Violation{{Message: fmt.Sprintf("%v: %v", ErrInvalidValue, err)}}
+	}
+	var out []Violation
+	s.root.validate(v, "", &out)
+	return out
+}
+
+// validate appends the ways v fails n to out.
+func (n *node) validate(v interface{}, path string, out *[]Violation) {
+	if len(*out) >= MaxViolations {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		if len(*out) < MaxViolations {
+			*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	if n.always != nil {
+		if !*n.always {
+			fail("no value is allowed here")
+		}
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(v, path, out)
+	}
+
+	if len(n.types) > 0 && !hasType(v, n.types) {
+		fail("expected %s, got %s", strings.Join(n.types, " or "), typeOf(v))
+		// Keyword checks for other types would only repeat this
+		return
+	}
+	if n.enum != nil {
+		c := canonical(v)
+		found := false
+		for _, e := range n.enum {
+			if e == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value is not one of the allowed values")
+		}
+	}
+	if n.constVal != nil && canonical(v) != *n.constVal {
+		fail("value must be %s", *n.constVal)
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		n.validateObject(t, path, out, fail)
+	case []interface{}:
+		n.validateArray(t, path, out, fail)
+	case string:
+		length := utf8.RuneCountInString(t)
+		if n.minLength != nil && length < *n.minLength {
+			fail("string shorter than %d characters", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			fail("string longer than %d characters", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(t) {
+			fail("string does not match pattern %q", n.pattern.String())
+		}
+	case json.Number:
+		f, _ := t.Float64()
+		if n.minimum != nil && f < *n.minimum {
+			fail("%s is less than %g", t, *n.minimum)
+		}
+		if n.maximum != nil && f > *n.maximum {
+			fail("%s is greater than %g", t, *n.maximum)
+		}
+		if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+			fail("%s is not greater than %g", t, *n.exclusiveMinimum)
+		}
+		if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+			fail("%s is not less than %g", t, *n.exclusiveMaximum)
+		}
+		if n.multipleOf != nil {
+			if q := f / *n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("%s is not a multiple of %g", t, *n.multipleOf)
+			}
+		}
+	}
+
+	for _, s := range n.allOf {
+		s.validate(v, path, out)
+	}
+	if n.anyOf != nil && matching(n.anyOf, v, 1) == 0 {
+		fail("value matches none of anyOf")
+	}
+	if n.oneOf != nil {
+		if m := matching(n.oneOf, v, 2); m != 1 {
+			fail("value matches %d of oneOf, expected exactly 1", m)
+		}
+	}
+	if n.not != nil && n.not.valid(v) {
+		fail("value matches a schema it must not")
+	}
+}
+
+// validateObject checks the object keywords.
+func (n *node) validateObject(obj map[string]interface{}, path string, out *[]Violation, fail func(string, ...interface{})) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			fail("missing required property %q", name)
+		}
+	}
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		fail("fewer than %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		fail("more than %d properties", *n.maxProperties)
+	}
+	for _, name := range sortedKeys(obj) {
+		value, child := obj[name], path+"/"+escapePointer(name)
+		matched := false
+		if s, ok := n.properties[name]; ok {
+			s.validate(value, child, out)
+			matched = true
+		}
+		for _, p := range n.patternProperties {
+			if p.re.MatchString(name) {
+				p.schema.validate(value, child, out)
+				matched = true
+			}
+		}
+		if !matched && n.additionalProperties != nil {
+			if a := n.additionalProperties.always; a != nil && !*a {
+				fail("property %q is not allowed", name)
+				continue
+			}
+			n.additionalProperties.validate(value, child, out)
+		}
+	}
+}
+
+// validateArray checks the array keywords.
+func (n *node) validateArray(list []interface{}, path string, out *[]Violation, fail func(string, ...interface{})) {
+	if n.minItems != nil && len(list) < *n.minItems {
+		fail("fewer than %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(list) > *n.maxItems {
+		fail("more than %d items", *n.maxItems)
+	}
+	for i, item := range list {
+		child := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case i < len(n.prefixItems):
+			n.prefixItems[i].validate(item, child, out)
+		case n.items != nil:
+			n.items.validate(item, child, out)
+		}
+	}
+	if n.uniqueItems {
+		seen := make(map[string]bool, len(list))
+		for _, item := range list {
+			c := canonical(item)
+			if seen[c] {
+				fail("items are not unique")
+				break
+			}
+			seen[c] = true
+		}
+	}
+}
+
+// valid reports whether v satisfies n.
+func (n *node) valid(v interface{}) bool {
+	var out []Violation
+	n.validate(v, "", &out)
+	return len(out) == 0
+}
+
+// matching counts the schemas v satisfies, stopping at limit.
+func matching(schemas []*node, v interface{}, limit int) int {
+	n := 0
+	for _, s := range schemas {
+		if s.valid(v) {
+			if n++; n >= limit {
+				break
+			}
+		}
+	}
+	return n
+}
+
+// hasType reports whether v is one of the JSON Schema types.
+func hasType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of a decoded value; numbers
+// without a fractional part are integers.
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := t.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// sortedKeys returns an object's keys in order, so violations are
+// reported deterministically.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set holds the compiled input schemas of a server's tools.
+type Set struct {
+	mu    sync.RWMutex
+	tools map[string]*Schema
+}
+
+// NewSet creates an empty set.
+func NewSet() *Set {
+	return &Set{tools: make(map[string]*Schema)}
+}
+
+// Put compiles and stores a tool's input schema, replacing any earlier
+// one. A schema that does not compile removes the tool's entry, so its
+// calls are treated as unlisted.
+//
+// # Returns
+//
+// ErrInvalidSchema if inputSchema does not compile.
+func (s *Set) Put(tool string, inputSchema json.RawMessage) error {
+	compiled, err := Compile(inputSchema)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		delete(s.tools, tool)
+		return err
+	}
+	s.tools[tool] = compiled
+	return nil
+}
+
+// Validate checks a tool's arguments against its stored schema.
+//
+// # Returns
+//   - The violations, or nil if the arguments are valid
+//   - false if the set holds no schema for the tool
+func (s *Set) Validate(tool string, arguments json.RawMessage) ([]Violation, bool) {
+	s.mu.RLock()
+	compiled, ok := s.tools[tool]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if len(arguments) == 0 {
+		// Omitted arguments are an empty object
+		arguments = json.RawMessage("{}")
+	}
+	return compiled.Validate(arguments), true
+}
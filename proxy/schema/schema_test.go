@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string // violation strings; nil means valid
+	}{
+		{"type ok", `{"type":"string"}`, `"x"`, nil},
+		{"type mismatch", `{"type":"string"}`, `1`, []string{"(root): expected string, got integer"}},
+		{"integer is a number", `{"type":"number"}`, `3`, nil},
+		{"integer with zero fraction", `{"type":"integer"}`, `3.0`, nil},
+		{"fraction is not an integer", `{"type":"integer"}`, `3.5`, []string{"(root): expected integer, got number"}},
+		{"type list", `{"type":["string","null"]}`, `null`, nil},
+		{"true schema", `true`, `{"a":1}`, nil},
+		{"false schema", `false`, `1`, []string{"(root): no value is allowed here"}},
+		{"enum", `{"enum":["a","b"]}`, `"c"`, []string{"(root): value is not one of the allowed values"}},
+		{"enum compares numbers by value", `{"enum":[1]}`, `1.0`, nil},
+		{"const", `{"const":{"a":[1,2]}}`, `{"a":[1,2]}`, nil},
+		{"required", `{"type":"object","required":["path","mode"]}`, `{"path":"/"}`, []string{`(root): missing required property "mode"`}},
+		{"nested property", `{"properties":{"opts":{"properties":{"depth":{"type":"integer"}}}}}`, `{"opts":{"depth":"deep"}}`, []string{"/opts/depth: expected integer, got string"}},
+		{"additional properties forbidden", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, []string{`(root): property "b" is not allowed`}},
+		{"additional properties schema", `{"additionalProperties":{"type":"string"}}`, `{"b":2}`, []string{"/b: expected string, got integer"}},
+		{"pattern properties", `{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":false}`, `{"x-a":"ok"}`, nil},
+		{"property count", `{"maxProperties":1}`, `{"a":1,"b":2}`, []string{"(root): more than 1 properties"}},
+		{"items", `{"items":{"type":"string"}}`, `["a",2]`, []string{"/1: expected string, got integer"}},
+		{"prefix items", `{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, `["a",1,"b"]`, []string{"/2: expected integer, got string"}},
+		{"array length", `{"minItems":2}`, `[1]`, []string{"(root): fewer than 2 items"}},
+		{"unique items", `{"uniqueItems":true}`, `[1,2,1]`, []string{"(root): items are not unique"}},
+		{"string length counts characters", `{"maxLength":2}`, `"éé"`, nil},
+		{"string too short", `{"minLength":2}`, `"a"`, []string{"(root): string shorter than 2 characters"}},
+		{"pattern", `{"pattern":"^[a-z]+$"}`, `"ab1"`, []string{`(root): string does not match pattern "^[a-z]+$"`}},
+		{"minimum", `{"minimum":1}`, `0`, []string{"(root): 0 is less than 1"}},
+		{"exclusive maximum", `{"exclusiveMaximum":10}`, `10`, []string{"(root): 10 is not less than 10"}},
+		{"draft-04 exclusive minimum", `{"minimum":0,"exclusiveMinimum":true}`, `0`, []string{"(root): 0 is not greater than 0"}},
+		{"multiple of", `{"multipleOf":0.5}`, `1.25`, []string{"(root): 1.25 is not a multiple of 0.5"}},
+		{"multiple of decimal", `{"multipleOf":0.1}`, `0.3`, nil},
+		{"keywords of other types ignored", `{"minLength":5,"minimum":9}`, `true`, nil},
+		{"all of", `{"allOf":[{"required":["a"]},{"required":["b"]}]}`, `{"a":1}`, []string{`(root): missing required property "b"`}},
+		{"any of", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`, []string{"(root): value matches none of anyOf"}},
+		{"one of", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, []string{"(root): value matches 2 of oneOf, expected exactly 1"}},
+		{"not", `{"not":{"const":"rm"}}`, `"rm"`, []string{"(root): value matches a schema it must not"}},
+		{"ref to defs", `{"$defs":{"name":{"type":"string"}},"properties":{"n":{"$ref":"#/$defs/name"}}}`, `{"n":1}`, []string{"/n: expected string, got integer"}},
+		{"recursive ref", `{"type":"object","properties":{"child":{"$ref":"#"},"v":{"type":"integer"}}}`, `{"child":{"child":{"v":"x"}}}`, []string{"/child/child/v: expected integer, got string"}},
+		{"escaped property path", `{"properties":{"a/b":{"type":"string"}}}`, `{"a/b":1}`, []string{"/a~1b: expected string, got integer"}},
+		{"unknown keywords ignored", `{"type":"string","format":"uri","x-vendor":1}`, `"not a uri"`, nil},
+		{"not JSON", `{}`, `{`, []string{"(root): schema: value is not JSON: unexpected EOF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile(json.RawMessage(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			var got []string
+			for _, v := range s.Validate(json.RawMessage(tt.value)) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("violations = %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_MaxViolations(t *testing.T) {
+	s, err := Compile(json.RawMessage(`{"items":{"type":"string"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Validate(json.RawMessage(`[1,2,3,4,5,6,7,8,9,10,11,12]`)); len(got) != MaxViolations {
+		t.Errorf("%d violations, expected %d", len(got), MaxViolations)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"not JSON", `{`},
+		{"not a schema", `"string"`},
+		{"bad type", `{"type":1}`},
+		{"bad required", `{"required":"a"}`},
+		{"negative length", `{"minLength":-1}`},
+		{"zero multiple", `{"multipleOf":0}`},
+		{"bad pattern", `{"pattern":"(?<=a)b"}`},
+		{"bad subschema", `{"properties":{"a":1}}`},
+		{"dangling ref", `{"$ref":"#/$defs/missing"}`},
+		{"remote ref", `{"$ref":"https://example.com/schema.json"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(json.RawMessage(tt.schema)); !errors.Is(err, ErrInvalidSchema) {
+				t.Errorf("Compile error = %v, expected ErrInvalidSchema", err)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet()
+	if err := s.Put("read", json.RawMessage(`{"type":"object","required":["path"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := s.Validate("read", json.RawMessage(`{"path":"/etc"}`)); !ok || v != nil {
+		t.Errorf("valid arguments: violations %v, listed %v", v, ok)
+	}
+	if v, ok := s.Validate("read", nil); !ok || len(v) != 1 {
+		t.Errorf("omitted arguments: violations %v, listed %v", v, ok)
+	}
+	if _, ok := s.Validate("write", json.RawMessage(`{}`)); ok {
+		t.Error("unlisted tool reported as listed")
+	}
+
+	// A schema that no longer compiles drops the tool
+	if err := s.Put("read", json.RawMessage(`{"type":7}`)); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("Put error = %v, expected ErrInvalidSchema", err)
+	}
+	if _, ok := s.Validate("read", json.RawMessage(`{}`)); ok {
+		t.Error("tool with an invalid schema still listed")
+	}
+}
@@ -0,0 +1,248 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/middleware"
+)
+
+// Action is what the middleware does with a message holding secrets.
+type Action string
+
+const (
+	// ActionRedact masks the secrets and delivers the rest
+	ActionRedact Action = "redact"
+	// ActionBlock refuses the call, or replaces its result with an error
+	ActionBlock Action = "block"
+	// ActionLog delivers the message unchanged and logs the findings
+	ActionLog Action = "log"
+)
+
+// CodeSecretDetected is the JSON-RPC error code of a blocked tool call
+// or result. It is in the implementation-defined server error range.
+const CodeSecretDetected = -32011
+
+// ItemFindings are the secrets in one string of a message.
+type ItemFindings struct {
+	// Path locates the string, e.g. "arguments.env[2]" or
+	// "content[0].text"
+	Path string `json:"path"`
+
+	// Findings are the secrets in the string
+	Findings []Finding `json:"findings"`
+}
+
+// ScanRequest scans every string in the arguments of a tools/call
+// request.
+//
+// # Arguments
+//   - request: Raw JSON-RPC request
+//   - redact: Rewrite the request with secrets masked
+//
+// # Returns
+//   - The request, rewritten if redact is set and anything was found
+//   - Findings grouped by string (nil if none, or for a request that
+//     does not parse)
+func (r *Redactor) ScanRequest(request []byte, redact bool) ([]byte, []ItemFindings, error) {
+	msg, err := jsonrpc.Parse(request)
+	if err != nil || len(msg.Params) == 0 {
+		return request, nil, nil
+	}
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil || len(params["arguments"]) == 0 {
+		return request, nil, nil
+	}
+	args, items := r.scanJSON(params["arguments"], "arguments", redact)
+	if len(items) == 0 || !redact {
+		return request, items, nil
+	}
+	params["arguments"] = args
+	if msg.Params, err = json.Marshal(params); err != nil {
+		return nil, items, fmt.Errorf("secrets: encode redacted params: %w", err)
+	}
+	data, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return nil, items, fmt.Errorf("secrets: encode redacted request: %w", err)
+	}
+	return data, items, nil
+}
+
+// ScanResponse scans every string in a tools/call result, or the
+// message of an error response.
+//
+// # Arguments
+//   - response: Raw JSON-RPC response
+//   - redact: Rewrite the response with secrets masked
+//
+// # Returns
+//   - The response, rewritten if redact is set and anything was found
+//   - Findings grouped by string (nil if none, or for a response that
+//     does not parse)
+func (r *Redactor) ScanResponse(response []byte, redact bool) ([]byte, []ItemFindings, error) {
+	resp, err := jsonrpc.Parse(response)
+	if err != nil {
+		return response, nil, nil
+	}
+	var items []ItemFindings
+	switch {
+	case resp.Error != nil:
+		var out string
+		out, items = r.scanText("error.message", resp.Error.Message, redact)
+		resp.Error.Message = out
+	case len(resp.Result) > 0:
+		resp.Result, items = r.scanJSON(resp.Result, "", redact)
+	}
+	if len(items) == 0 || !redact {
+		return response, items, nil
+	}
+	data, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return nil, items, fmt.Errorf("secrets: encode redacted response: %w", err)
+	}
+	return data, items, nil
+}
+
+// scanJSON scans every string value in raw, returning raw with secrets
+// masked when redact is set. Numbers keep their precision; object keys
+// are not scanned.
+func (r *Redactor) scanJSON(raw json.RawMessage, root string, redact bool) (json.RawMessage, []ItemFindings) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return raw, nil
+	}
+	var items []ItemFindings
+	v = walkStrings(v, root, func(path, text string) string {
+		out, found := r.scanText(path, text, redact)
+		items = append(items, found...)
+		return out
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	if len(items) == 0 || !redact {
+		return raw, items
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw, items
+	}
+	return out, items
+}
+
+// scanText scans one string.
+func (r *Redactor) scanText(path, text string, redact bool) (string, []ItemFindings) {
+	var findings []Finding
+	if redact {
+		text, findings = r.Redact(text)
+	} else {
+		findings = r.Scan(text)
+	}
+	if len(findings) == 0 {
+		return text, nil
+	}
+	return text, []ItemFindings{{Path: path, Findings: findings}}
+}
+
+// walkStrings calls visit on every string value and stores the
+// returned value in its place.
+func walkStrings(v interface{}, path string, visit func(path, text string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return visit(path, v)
+	case map[string]interface{}:
+		for k, child := range v {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			v[k] = walkStrings(child, childPath, visit)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = walkStrings(child, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	}
+	return v
+}
+
+// Middleware returns a middleware stage that scans the arguments of
+// each tools/call request on its way to the server and the result on
+// its way back, and applies action to those holding secrets. Under
+// ActionBlock a call whose arguments hold a secret is answered with a
+// CodeSecretDetected error and never reaches the server.
+//
+// # Security Notes
+//
+// Findings are heuristics. ActionRedact keeps calls working with the
+// secrets masked; ActionBlock suits tools that should never see or
+// return credentials; ActionLog is for tuning rules before enforcing
+// them. Logs and error data name the rule and location of each secret,
+// never its text.
+func (r *Redactor) Middleware(action Action) middleware.Middleware {
+	return func(mc *middleware.MessageContext, msg []byte, next middleware.Handler) ([]byte, error) {
+		req := mc.Message
+		if req == nil || mc.Direction == middleware.ServerToClient || req.Method != "tools/call" {
+			return next(mc, msg)
+		}
+		tool := jsonrpc.ExtractToolName(req)
+
+		out, items, err := r.ScanRequest(msg, action == ActionRedact)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			logFindings(tool, "arguments", action, items)
+			switch action {
+			case ActionBlock:
+				return blocked(req.ID, "Secret in tool arguments", items)
+			case ActionRedact:
+				msg = out
+			}
+		}
+
+		response, err := next(mc, msg)
+		if err != nil {
+			return response, err
+		}
+		out, items, err = r.ScanResponse(response, action == ActionRedact)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return response, nil
+		}
+		logFindings(tool, "result", action, items)
+		switch action {
+		case ActionBlock:
+			return blocked(req.ID, "Secret in tool result", items)
+		case ActionRedact:
+			return out, nil
+		default:
+			return response, nil
+		}
+	}
+}
+
+// blocked returns the error response refusing a call or its result.
+func blocked(id json.RawMessage, message string, items []ItemFindings) ([]byte, error) {
+	reply, err := jsonrpc.NewErrorResponse(id, CodeSecretDetected, message, items)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.Serialize(reply)
+}
+
+// logFindings logs where secrets were found, without their text.
+func logFindings(tool, part string, action Action, items []ItemFindings) {
+	n := 0
+	for _, item := range items {
+		n += len(item.Findings)
+	}
+	log.Printf("secrets: tools/call %s %s: %d secret(s) (%s, first %s at %s)",
+		tool, part, n, action, items[0].Findings[0].Rule, items[0].Path)
+}
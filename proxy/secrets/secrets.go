@@ -0,0 +1,275 @@
+// Package secrets detects and masks secrets in tool call traffic.
+//
+// A tool call is an exfiltration path both ways: the model can be
+// steered into copying credentials from its context into a tool's
+// arguments, and a server can return credentials it read (an .env
+// file, a cloud metadata endpoint) into the model's context, and from
+// there into anything it writes next. The redactor finds secrets with
+// pattern rules for well-known credential formats and an entropy
+// heuristic for the rest, in pure Go and cheap enough to run on every
+// call.
+//
+// # Rules
+//
+//   - aws-access-key: AWS access key IDs (AKIA..., ASIA...)
+//   - aws-secret-key: AWS secret access keys next to their name
+//   - private-key: PEM private key blocks, truncated ones included
+//   - bearer-token: Authorization bearer tokens
+//   - api-key: vendor API keys and tokens with a known prefix (OpenAI,
+//     Anthropic, GitHub, Slack, Google, Stripe)
+//   - jwt: JSON Web Tokens
+//   - secret-assignment: values assigned to keys named like password,
+//     secret, token, or api_key
+//   - high-entropy: long random-looking strings mixing upper case,
+//     lower case, and digits
+//
+// # Security Notes
+//
+// Detection is heuristic: a secret in an unknown format with low
+// entropy passes, and rare random identifiers are masked. Findings
+// never carry the matched text, so logging them does not leak the
+// secret they describe.
+//
+// # Thread Safety
+//
+// Redactor is immutable and safe for concurrent use.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidPattern is returned when an extra rule does not compile.
+var ErrInvalidPattern = errors.New("secrets: invalid pattern")
+
+// Built-in rule names.
+const (
+	RuleAWSAccessKey = "aws-access-key"
+	RuleAWSSecretKey = "aws-secret-key"
+	RulePrivateKey   = "private-key"
+	RuleBearerToken  = "bearer-token"
+	RuleAPIKey       = "api-key"
+	RuleJWT          = "jwt"
+	RuleAssignment   = "secret-assignment"
+	RuleHighEntropy  = "high-entropy"
+)
+
+// Entropy heuristic defaults.
+const (
+	// DefaultEntropyThreshold is the Shannon entropy, in bits per
+	// character, at which a candidate string is taken for a secret.
+	// Random base64 of 24 characters or more averages above 4.2; English
+	// identifiers stay below 4.
+	DefaultEntropyThreshold = 4.0
+
+	// DefaultEntropyMinLength is the shortest candidate string the
+	// entropy heuristic considers
+	DefaultEntropyMinLength = 24
+)
+
+// builtinPatterns are the pattern rules. When a pattern has a capture
+// group, only the group is the secret: "password=" stays readable.
+var builtinPatterns = []struct {
+	rule string
+	re   *regexp.Regexp
+}{
+	{RuleAWSAccessKey, regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{RuleAWSSecretKey, regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})`)},
+	{RulePrivateKey, regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY-----(?:[\s\S]*?-----END[A-Z ]*PRIVATE KEY-----|[A-Za-z0-9+/=\s]*)`)},
+	{RuleBearerToken, regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`)},
+	{RuleAPIKey, regexp.MustCompile(`\b(?:sk-(?:proj-|ant-[a-z0-9]+-)?[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{22,}|xox[abposr]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35}|[sr]k_live_[0-9A-Za-z]{24,})`)},
+	{RuleJWT, regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{RuleAssignment, regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret(?:[_-]?key)?|access[_-]?token|auth[_-]?token|password|passwd|pwd)["']?\s*[:=]\s*["']?([^\s"',;&]{8,})`)},
+}
+
+// candidate matches the strings the entropy heuristic considers: runs
+// of base64 and URL-safe base64 characters.
+var candidate = regexp.MustCompile(`[A-Za-z0-9+/_-]+={0,2}`)
+
+// Config selects the rules a Redactor applies.
+type Config struct {
+	// Disable lists built-in rules to skip, RuleHighEntropy included
+	Disable []string
+
+	// Extra maps additional rule names to regular expressions; a
+	// pattern's first capture group, if it has one, is the secret
+	Extra map[string]string
+
+	// EntropyThreshold is the entropy in bits per character at which a
+	// string is a secret (zero uses DefaultEntropyThreshold)
+	EntropyThreshold float64
+
+	// EntropyMinLength is the shortest string the entropy heuristic
+	// considers (zero uses DefaultEntropyMinLength)
+	EntropyMinLength int
+}
+
+// Finding is one secret in scanned text. It deliberately omits the
+// matched text.
+type Finding struct {
+	// Rule is the name of the matching rule
+	Rule string `json:"rule"`
+
+	// Start and End are the byte offsets of the secret
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// rule is a compiled pattern rule.
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Redactor finds and masks secrets in text.
+type Redactor struct {
+	rules     []rule
+	entropy   bool
+	threshold float64
+	minLength int
+}
+
+// New creates a Redactor from cfg.
+//
+// # Returns
+//   - ErrInvalidPattern if an Extra entry is not a valid regular
+//     expression
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{
+		entropy:   !slices.Contains(cfg.Disable, RuleHighEntropy),
+		threshold: cfg.EntropyThreshold,
+		minLength: cfg.EntropyMinLength,
+	}
+	if r.threshold <= 0 {
+		r.threshold = DefaultEntropyThreshold
+	}
+	if r.minLength <= 0 {
+		r.minLength = DefaultEntropyMinLength
+	}
+	for _, p := range builtinPatterns {
+		if !slices.Contains(cfg.Disable, p.rule) {
+			r.rules = append(r.rules, rule{name: p.rule, re: p.re})
+		}
+	}
+
+	names := make([]string, 0, len(cfg.Extra))
+	for name := range cfg.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(cfg.Extra[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPattern, name, err)
+		}
+		r.rules = append(r.rules, rule{name: name, re: re})
+	}
+	return r, nil
+}
+
+// Scan returns every secret in text, ordered by offset. A string the
+// pattern rules already matched is not reported again as high-entropy.
+func (r *Redactor) Scan(text string) []Finding {
+	var findings []Finding
+	for _, ru := range r.rules {
+		for _, loc := range ru.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			findings = append(findings, Finding{Rule: ru.name, Start: start, End: end})
+		}
+	}
+	if r.entropy {
+		for _, loc := range candidate.FindAllStringIndex(text, -1) {
+			if loc[1]-loc[0] < r.minLength || covered(findings, loc[0], loc[1]) || !r.random(text[loc[0]:loc[1]]) {
+				continue
+			}
+			findings = append(findings, Finding{Rule: RuleHighEntropy, Start: loc[0], End: loc[1]})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	return findings
+}
+
+// Redact returns text with every secret replaced by a marker naming
+// its rule, e.g. "[redacted:aws-access-key]".
+func (r *Redactor) Redact(text string) (string, []Finding) {
+	findings := r.Scan(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, f := range findings {
+		if f.Start < pos {
+			// Overlaps a span already redacted
+			pos = max(pos, f.End)
+			continue
+		}
+		b.WriteString(text[pos:f.Start])
+		b.WriteString(Marker(f.Rule))
+		pos = f.End
+	}
+	b.WriteString(text[pos:])
+	return b.String(), findings
+}
+
+// Marker returns the text that replaces a secret found by rule.
+func Marker(rule string) string {
+	return "[redacted:" + rule + "]"
+}
+
+// covered reports whether [start, end) overlaps a finding.
+func covered(findings []Finding, start, end int) bool {
+	for _, f := range findings {
+		if f.Start < end && start < f.End {
+			return true
+		}
+	}
+	return false
+}
+
+// random reports whether s mixes upper case, lower case, and digits
+// and its entropy reaches the threshold.
+func (r *Redactor) random(s string) bool {
+	var upper, lower, digit bool
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && Entropy(s) >= r.threshold
+}
+
+// Entropy returns the Shannon entropy of s in bits per byte.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
@@ -0,0 +1,248 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// EnvelopeVersion is the highest FFI envelope version this proxy speaks.
+//
+// In version 1 a check returns only allowed or refused, with the reason
+// for a refusal; version 2 adds the check_result envelope, a full
+// verdict in JSON (see CheckResponse).
+const EnvelopeVersion = 2
+
+// SupportedEnvelopeVersions lists every envelope version this proxy can
+// produce and consume, in ascending order.
+var SupportedEnvelopeVersions = []int{1, 2}
+
+// Envelope message types.
+const (
+	EnvelopeNegotiate     = "negotiate"
+	EnvelopeRegistryCheck = "registry_check"
+	EnvelopeStateCheck    = "state_check"
+	EnvelopeCouncilVote   = "council_vote"
+
+	// EnvelopeCheckResult carries a CheckResponse back from a check
+	// (version 2 and later)
+	EnvelopeCheckResult = "check_result"
+)
+
+// Envelope errors.
+var (
+	ErrEnvelopeVersion = errors.New("sentinel: unsupported envelope version")
+	ErrEnvelopeType    = errors.New("sentinel: unexpected envelope type")
+	ErrNoCommonVersion = errors.New("sentinel: no common FFI envelope version")
+	ErrCheckResponse   = errors.New("sentinel: malformed check response")
+)
+
+// Envelope wraps every payload that crosses the FFI boundary.
+//
+// The explicit version and type let the Go proxy and Rust crates evolve
+// independently: a peer that receives a version or type it does not
+// understand rejects the message instead of silently misreading fields.
+//
+//	{"v":1,"type":"registry_check","payload":{...}}
+type Envelope struct {
+	// V is the envelope version
+	V int `json:"v"`
+
+	// Type identifies the payload schema
+	Type string `json:"type"`
+
+	// Payload is the type-specific request or response body
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NegotiateRequest is sent at startup listing the versions the proxy supports.
+type NegotiateRequest struct {
+	Versions []int `json:"versions"`
+}
+
+// NegotiateResponse carries the version the peer selected.
+type NegotiateResponse struct {
+	Version int `json:"version"`
+}
+
+// CheckResponse is the payload of a check_result envelope: the full
+// verdict of a check.
+//
+// Result turns it into a CheckResult. Details are kept as sent, and the
+// other diagnostics are added to them under their JSON names, so they
+// reach decision records and audit events:
+//
+//	{"allowed":false,"reason":"council rejected action","gas_consumed":120,
+//	 "risk_score":0.82,"tally":{"approvals":1,"rejections":2,...}}
+type CheckResponse struct {
+	// Allowed is the verdict (required)
+	Allowed *bool `json:"allowed"`
+
+	// Reason explains the verdict
+	Reason string `json:"reason,omitempty"`
+
+	// Details holds check-specific diagnostics
+	Details map[string]interface{} `json:"details,omitempty"`
+
+	// GasConsumed is the gas the check charged
+	GasConsumed uint64 `json:"gas_consumed,omitempty"`
+
+	// RiskScore is the risk the check assessed, from 0.0 to 1.0
+	RiskScore *float64 `json:"risk_score,omitempty"`
+
+	// Tally is the council's vote breakdown (council_vote only)
+	Tally *VoteTally `json:"tally,omitempty"`
+
+	// Diagnostics are notes on how the check ran, such as warnings
+	Diagnostics []string `json:"diagnostics,omitempty"`
+}
+
+// VoteTally is the vote breakdown of a council vote.
+type VoteTally struct {
+	Approvals   int    `json:"approvals"`
+	Rejections  int    `json:"rejections"`
+	Abstentions int    `json:"abstentions"`
+	Total       int    `json:"total"`
+	Votes       []Vote `json:"votes,omitempty"`
+}
+
+// Vote is one evaluator's vote in a VoteTally.
+type Vote struct {
+	Evaluator string `json:"evaluator"`
+
+	// Decision is "Approve", "Reject", or "Abstain"
+	Decision string `json:"decision"`
+
+	// Confidence is the evaluator's confidence, from 0.0 to 1.0
+	Confidence float64 `json:"confidence"`
+
+	Reasoning string `json:"reasoning,omitempty"`
+}
+
+// Result returns the CheckResult of the response. okReason is the
+// reason of an allowed verdict that gives none.
+func (r *CheckResponse) Result(okReason string) *CheckResult {
+	result := &CheckResult{Allowed: r.Allowed != nil && *r.Allowed, Reason: r.Reason}
+	if result.Allowed && result.Reason == "" {
+		result.Reason = okReason
+	}
+	if len(r.Details) == 0 && r.GasConsumed == 0 && r.RiskScore == nil && r.Tally == nil && len(r.Diagnostics) == 0 {
+		return result
+	}
+	result.Details = make(map[string]interface{}, len(r.Details)+4)
+	for k, v := range r.Details {
+		result.Details[k] = v
+	}
+	if r.GasConsumed > 0 {
+		result.Details["gas_consumed"] = r.GasConsumed
+	}
+	if r.RiskScore != nil {
+		result.Details["risk_score"] = *r.RiskScore
+	}
+	if r.Tally != nil {
+		result.Details["tally"] = r.Tally
+	}
+	if len(r.Diagnostics) > 0 {
+		result.Details["diagnostics"] = r.Diagnostics
+	}
+	return result
+}
+
+// OpenCheckResult decodes a check_result envelope.
+//
+// # Arguments
+//   - data: Encoded envelope
+//   - okReason: Reason of an allowed verdict that gives none
+//
+// # Returns
+//   - The verdict
+//   - Error if the envelope is malformed, of an unsupported version or
+//     another type, or lacks a verdict; a malformed response is never
+//     read as a verdict
+func OpenCheckResult(data []byte, okReason string) (*CheckResult, error) {
+	env, err := OpenEnvelope(data, EnvelopeCheckResult)
+	if err != nil {
+		return nil, err
+	}
+	if env.V < 2 {
+		return nil, fmt.Errorf("%w: %q in version %d", ErrEnvelopeType, env.Type, env.V)
+	}
+	var resp CheckResponse
+	if err := json.Unmarshal(env.Payload, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCheckResponse, err)
+	}
+	if resp.Allowed == nil {
+		return nil, fmt.Errorf("%w: missing \"allowed\"", ErrCheckResponse)
+	}
+	return resp.Result(okReason), nil
+}
+
+// SealEnvelope encodes payload in a versioned envelope.
+//
+// # Arguments
+//   - version: Negotiated envelope version
+//   - typ: Envelope type (EnvelopeRegistryCheck, etc.)
+//   - payload: Value to JSON-encode as the payload
+//
+// # Returns
+//   - Encoded envelope bytes
+//   - Error if the version is unsupported or payload cannot be encoded
+func SealEnvelope(version int, typ string, payload interface{}) ([]byte, error) {
+	if !versionSupported(version) {
+		return nil, fmt.Errorf("%w: %d", ErrEnvelopeVersion, version)
+	}
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel: failed to marshal payload: %w", err)
+	}
+	return json.Marshal(&Envelope{V: version, Type: typ, Payload: p})
+}
+
+// OpenEnvelope decodes an envelope and checks its version and type.
+//
+// # Arguments
+//   - data: Encoded envelope
+//   - typ: Expected envelope type (empty accepts any)
+//
+// # Returns
+//   - Decoded envelope
+//   - Error if malformed, of an unsupported version, or of the wrong type
+func OpenEnvelope(data []byte, typ string) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("sentinel: malformed envelope: %w", err)
+	}
+	if !versionSupported(env.V) {
+		return nil, fmt.Errorf("%w: %d", ErrEnvelopeVersion, env.V)
+	}
+	if typ != "" && env.Type != typ {
+		return nil, fmt.Errorf("%w: got %q, want %q", ErrEnvelopeType, env.Type, typ)
+	}
+	return &env, nil
+}
+
+// NegotiateVersion picks the highest version supported by both sides.
+func NegotiateVersion(ours, theirs []int) (int, error) {
+	best := 0
+	for _, a := range ours {
+		for _, b := range theirs {
+			if a == b && a > best {
+				best = a
+			}
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("%w: proxy %v, peer %v", ErrNoCommonVersion, ours, theirs)
+	}
+	return best, nil
+}
+
+// versionSupported reports whether v is in SupportedEnvelopeVersions.
+func versionSupported(v int) bool {
+	for _, s := range SupportedEnvelopeVersions {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
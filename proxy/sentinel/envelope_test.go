@@ -0,0 +1,114 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	req := &RegistryCheckRequest{ToolName: "read_file", Params: json.RawMessage(`{}`)}
+	data, err := SealEnvelope(EnvelopeVersion, EnvelopeRegistryCheck, req)
+	if err != nil {
+		t.Fatalf("SealEnvelope failed: %v", err)
+	}
+
+	env, err := OpenEnvelope(data, EnvelopeRegistryCheck)
+	if err != nil {
+		t.Fatalf("OpenEnvelope failed: %v", err)
+	}
+	var got RegistryCheckRequest
+	if err := json.Unmarshal(env.Payload, &got); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if got.ToolName != "read_file" {
+		t.Errorf("expected tool 'read_file', got %q", got.ToolName)
+	}
+}
+
+func TestEnvelope_Rejects(t *testing.T) {
+	if _, err := SealEnvelope(99, EnvelopeStateCheck, nil); !errors.Is(err, ErrEnvelopeVersion) {
+		t.Errorf("expected ErrEnvelopeVersion sealing v99, got %v", err)
+	}
+	if _, err := OpenEnvelope([]byte(`{"v":3,"type":"state_check","payload":{}}`), ""); !errors.Is(err, ErrEnvelopeVersion) {
+		t.Errorf("expected ErrEnvelopeVersion opening v3, got %v", err)
+	}
+	if _, err := OpenEnvelope([]byte(`{"v":1,"type":"council_vote","payload":{}}`), EnvelopeStateCheck); !errors.Is(err, ErrEnvelopeType) {
+		t.Errorf("expected ErrEnvelopeType, got %v", err)
+	}
+}
+
+func TestOpenCheckResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		allowed  bool
+		reason   string
+		details  []string
+		expected error
+	}{
+		{"allowed without reason", `{"v":2,"type":"check_result","payload":{"allowed":true}}`,
+			true, "ok", nil, nil},
+		{"full verdict", `{"v":2,"type":"check_result","payload":{"allowed":false,"reason":"council rejected action",` +
+			`"details":{"schema_id":"s1"},"gas_consumed":120,"risk_score":0.8,"diagnostics":["slow evaluator"],` +
+			`"tally":{"approvals":1,"rejections":2,"abstentions":0,"total":3,"votes":[{"evaluator":"deontologist","decision":"Reject","confidence":0.9}]}}}`,
+			false, "council rejected action", []string{"schema_id", "gas_consumed", "risk_score", "tally", "diagnostics"}, nil},
+		{"missing verdict", `{"v":2,"type":"check_result","payload":{"reason":"?"}}`,
+			false, "", nil, ErrCheckResponse},
+		{"malformed payload", `{"v":2,"type":"check_result","payload":{"allowed":"yes"}}`,
+			false, "", nil, ErrCheckResponse},
+		{"wrong type", `{"v":2,"type":"state_check","payload":{"allowed":true}}`,
+			false, "", nil, ErrEnvelopeType},
+		{"version 1", `{"v":1,"type":"check_result","payload":{"allowed":true}}`,
+			false, "", nil, ErrEnvelopeType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := OpenCheckResult([]byte(tt.data), "ok")
+			if tt.expected != nil {
+				if !errors.Is(err, tt.expected) {
+					t.Fatalf("err = %v, expected %v", err, tt.expected)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenCheckResult failed: %v", err)
+			}
+			if result.Allowed != tt.allowed || result.Reason != tt.reason || len(result.Details) != len(tt.details) {
+				t.Fatalf("result %+v", result)
+			}
+			for _, key := range tt.details {
+				if _, ok := result.Details[key]; !ok {
+					t.Errorf("details lack %q: %v", key, result.Details)
+				}
+			}
+			if tally, ok := result.Details["tally"].(*VoteTally); ok && (tally.Rejections != 2 || tally.Votes[0].Decision != "Reject") {
+				t.Errorf("tally %+v", tally)
+			}
+		})
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		ours, theirs []int
+		expected     int
+		wantErr      bool
+	}{
+		{[]int{1}, []int{1}, 1, false},
+		{[]int{1, 2}, []int{1, 2, 3}, 2, false},
+		{[]int{1}, []int{2, 3}, 0, true},
+	}
+	for _, tt := range tests {
+		v, err := NegotiateVersion(tt.ours, tt.theirs)
+		if (err != nil) != tt.wantErr || v != tt.expected {
+			t.Errorf("NegotiateVersion(%v, %v) = %d, %v; expected %d", tt.ours, tt.theirs, v, err, tt.expected)
+		}
+	}
+}
+
+func TestClient_ProtocolVersion(t *testing.T) {
+	if v := NewClient().ProtocolVersion(); v != EnvelopeVersion {
+		t.Errorf("expected negotiated version %d, got %d", EnvelopeVersion, v)
+	}
+}
@@ -0,0 +1,87 @@
+package sentinel
+
+// ContextEvidence is the CouncilVoteRequest.Context key under which the
+// proxy reports what its own Go-side checks found, as an Evidence
+// value, so the council can weigh it instead of voting blind.
+const ContextEvidence = "proxy_evidence"
+
+// EvidenceVersion is the schema version of Evidence. It changes only
+// when a field is removed or changes meaning; new fields and finding
+// sources may be added within a version.
+const EvidenceVersion = 1
+
+// Evidence sources.
+const (
+	// EvidenceTaint is an argument copied from an earlier tool result
+	EvidenceTaint = "taint"
+	// EvidenceContent is earlier tool result content matching a
+	// content rule
+	EvidenceContent = "content"
+	// EvidencePolicy is an operator policy rule requiring the vote
+	EvidencePolicy = "policy"
+	// EvidenceReadReceipt is a call retried after being blocked
+	EvidenceReadReceipt = "read_receipt"
+	// EvidenceAnomaly is a signal that raised the session's anomaly
+	// score, such as a detected prompt injection
+	EvidenceAnomaly = "anomaly"
+	// EvidenceConformance is a protocol violation by the client, such
+	// as a reused request ID
+	EvidenceConformance = "conformance"
+)
+
+// Evidence is the proxy's report on a tool call put to a council vote.
+//
+// # Example
+//
+//	{
+//	  "version": 1,
+//	  "findings": [
+//	    {"source": "taint", "kind": "critical_argument", "severity": 1,
+//	     "summary": "argument url copied in full from a fetch result",
+//	     "detail": {"path": "url", "critical": true, "full": true,
+//	                "source_tool": "fetch", "source_decision": "d-12"}}
+//	  ],
+//	  "anomaly": {"score": 4.2, "threshold": 10},
+//	  "checks_passed": ["policy", "registry", "state"]
+//	}
+type Evidence struct {
+	// Version is EvidenceVersion
+	Version int `json:"version"`
+
+	// Findings are what the proxy's checks found, most severe first
+	Findings []Finding `json:"findings"`
+
+	// Anomaly is the session's anomaly score, when scoring is enabled
+	Anomaly *AnomalyEvidence `json:"anomaly,omitempty"`
+
+	// ChecksPassed names the proxy checks that ran on the call and
+	// allowed it
+	ChecksPassed []string `json:"checks_passed,omitempty"`
+}
+
+// Finding is one piece of Go-side evidence.
+type Finding struct {
+	// Source is the check that produced it, one of the Evidence*
+	// constants
+	Source string `json:"source"`
+
+	// Kind refines the source, e.g. a content kind or anomaly signal
+	Kind string `json:"kind"`
+
+	// Severity is the proxy's weight for the finding, from 0 (context
+	// only) to 1 (would block on its own under a stricter policy)
+	Severity float64 `json:"severity"`
+
+	// Summary describes the finding in one line for voters
+	Summary string `json:"summary"`
+
+	// Detail is the source's structured record of the finding
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// AnomalyEvidence is the session's anomaly score when the vote was
+// requested. The kill-switch terminates the session at Threshold.
+type AnomalyEvidence struct {
+	Score     float64 `json:"score"`
+	Threshold float64 `json:"threshold"`
+}
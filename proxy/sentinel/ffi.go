@@ -7,19 +7,35 @@ package sentinel
 
 /*
 #cgo CFLAGS: -I${SRCDIR}/../../../crates
-#cgo LDFLAGS: -L${SRCDIR}/../../../target/release -lsentinel_ffi
+#cgo LDFLAGS: -L${SRCDIR}/../../../target/release -lsentinel_ffi -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+
+// All payloads are versioned envelopes: {"v":2,"type":"...","payload":{...}}
+
+// negotiate_version receives a "negotiate" envelope listing the proxy's
+// supported versions. Returns the selected version, or 0 if none match.
+extern int negotiate_version(const char* envelope_json, int len);
 
 // check_registry validates a schema against the registry
 // Returns 1 if valid, 0 if invalid
-extern int check_registry(const char* schema_json, int len);
+extern int check_registry(const char* envelope_json, int len);
 
 // check_state validates state transitions
 // Returns 1 if valid, 0 if cycle detected or gas exceeded
-extern int check_state(const char* state_json, int len);
+extern int check_state(const char* envelope_json, int len);
 
 // vote_council submits an action for consensus voting
 // Returns 1 if approved, 0 if rejected
-extern int vote_council(const char* action_json, int len);
+extern int vote_council(const char* envelope_json, int len);
+
+// check_envelope (envelope version 2) runs the check named by a request
+// envelope's type and returns its verdict as a "check_result" envelope.
+// Returns NULL on failure, with the reason in get_last_error.
+// Caller must free the returned string. Libraries that predate version 2
+// lack it, so it is declared weak and is NULL for them.
+extern char* check_envelope(const char* envelope_json, int len) __attribute__((weak));
 
 // get_last_error returns the last error message
 // Caller must free the returned string
@@ -27,112 +43,308 @@ extern char* get_last_error();
 
 // free_string frees a string allocated by Rust
 extern void free_string(char* s);
+
+typedef int (*sentinel_entry)(const char*, int);
+typedef char* (*sentinel_json)(const char*, int);
+typedef char* (*sentinel_error)(void);
+typedef void (*sentinel_free)(char*);
+
+// sentinel_syms holds the entry points of one loaded library.
+typedef struct {
+	sentinel_entry negotiate_version;
+	sentinel_entry check_registry;
+	sentinel_entry check_state;
+	sentinel_entry vote_council;
+	sentinel_json check_envelope;
+	sentinel_error get_last_error;
+	sentinel_free free_string;
+} sentinel_syms;
+
+// sentinel_linked fills syms with the library linked at build time.
+static void sentinel_linked(sentinel_syms* s) {
+	s->negotiate_version = negotiate_version;
+	s->check_registry = check_registry;
+	s->check_state = check_state;
+	s->vote_council = vote_council;
+	s->check_envelope = check_envelope;
+	s->get_last_error = (sentinel_error)get_last_error;
+	s->free_string = free_string;
+}
+
+// sentinel_resolve fills syms from a dlopen handle. Returns the name of
+// the first missing symbol, or NULL.
+static const char* sentinel_resolve(void* handle, sentinel_syms* s) {
+	if (!(s->negotiate_version = (sentinel_entry)dlsym(handle, "negotiate_version"))) return "negotiate_version";
+	if (!(s->check_registry = (sentinel_entry)dlsym(handle, "check_registry"))) return "check_registry";
+	if (!(s->check_state = (sentinel_entry)dlsym(handle, "check_state"))) return "check_state";
+	if (!(s->vote_council = (sentinel_entry)dlsym(handle, "vote_council"))) return "vote_council";
+	if (!(s->get_last_error = (sentinel_error)dlsym(handle, "get_last_error"))) return "get_last_error";
+	if (!(s->free_string = (sentinel_free)dlsym(handle, "free_string"))) return "free_string";
+	s->check_envelope = (sentinel_json)dlsym(handle, "check_envelope");
+	return NULL;
+}
+
+static int sentinel_call(sentinel_entry fn, const char* data, int len) {
+	return fn(data, len);
+}
+
+static char* sentinel_call_json(sentinel_json fn, const char* data, int len) {
+	return fn(data, len);
+}
+
+static char* sentinel_last_error(sentinel_error fn) {
+	return fn();
+}
+
+static void sentinel_free_string(sentinel_free fn, char* s) {
+	fn(s);
+}
 */
 import "C"
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"sync"
 	"unsafe"
 )
 
+// ffiEntry identifies a Rust entry point.
+type ffiEntry int
+
+const (
+	entryNegotiate ffiEntry = iota
+	entryRegistry
+	entryState
+	entryCouncil
+)
+
 // ffiImpl provides FFI-based implementations calling Rust.
 type ffiImpl struct {
-	mu sync.Mutex
+	// pool runs every call into the library
+	pool *workerPool
+
+	// syms are the library's entry points
+	syms C.sentinel_syms
+
+	// handle is the dlopen handle of a reloaded library (nil for the
+	// library linked at build time)
+	handle unsafe.Pointer
+
+	// version is the negotiated envelope version (0 if negotiation failed)
+	version int
+
+	// negotiateErr is returned by every call if negotiation failed
+	negotiateErr error
 }
 
-// newClientImpl returns the FFI implementation.
-func newClientImpl() clientImpl {
-	return &ffiImpl{}
+// newClientImpl returns the FFI implementation, calling the library on
+// a worker pool sized by pool.
+//
+// Envelope version negotiation happens once, here. If the Rust library
+// shares no version with the proxy, every subsequent check fails with
+// ErrFFICall rather than exchanging misinterpreted payloads.
+func newClientImpl(pool PoolConfig) clientImpl {
+	f := &ffiImpl{pool: newWorkerPool(pool)}
+	C.sentinel_linked(&f.syms)
+	f.version, f.negotiateErr = f.negotiate()
+	return f
 }
 
-func (f *ffiImpl) checkRegistry(req *RegistryCheckRequest) (*CheckResult, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+// openClientImpl loads the library at path for Client.Reload. The
+// library is loaded with its symbols kept local, so it runs alongside
+// the one it replaces until the swap.
+func openClientImpl(path string, pool PoolConfig) (clientImpl, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: no library path given", ErrNotReloadable)
+	}
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	handle := C.dlopen(cPath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if handle == nil {
+		return nil, fmt.Errorf("%w: load %s: %s", ErrFFICall, path, C.GoString(C.dlerror()))
+	}
+	f := &ffiImpl{handle: handle}
+	if missing := C.sentinel_resolve(handle, &f.syms); missing != nil {
+		C.dlclose(handle)
+		return nil, fmt.Errorf("%w: %s lacks %s", ErrFFICall, path, C.GoString(missing))
+	}
+	f.pool = newWorkerPool(pool)
+	f.version, f.negotiateErr = f.negotiate()
+	return f, nil
+}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("sentinel: failed to marshal request: %w", err)
+// same reports whether other calls the same loaded library, as dlopen
+// returns for a file already loaded.
+func (f *ffiImpl) same(other clientImpl) bool {
+	o, ok := other.(*ffiImpl)
+	return ok && o.syms.negotiate_version == f.syms.negotiate_version
+}
+
+// close stops the workers and unloads a reloaded library once it has
+// been swapped out. Calls abandoned at their deadline may still be
+// running in the library, so both happen in the background once they
+// return.
+func (f *ffiImpl) close() {
+	go func() {
+		f.pool.close()
+		if f.handle != nil {
+			C.dlclose(f.handle)
+		}
+	}()
+}
+
+func (f *ffiImpl) poolStats() PoolStats {
+	return f.pool.stats()
+}
+
+// versions returns the envelope versions the library's entry points
+// allow: version 2 and later need check_envelope.
+func (f *ffiImpl) versions() []int {
+	if f.syms.check_envelope != nil {
+		return SupportedEnvelopeVersions
 	}
+	return []int{1}
+}
 
-	cData := C.CString(string(data))
-	defer C.free(unsafe.Pointer(cData))
+// negotiate agrees on an envelope version with the Rust library.
+func (f *ffiImpl) negotiate() (int, error) {
+	// Negotiation itself always uses the baseline envelope version
+	offered := f.versions()
+	data, err := SealEnvelope(SupportedEnvelopeVersions[0], EnvelopeNegotiate,
+		&NegotiateRequest{Versions: offered})
+	if err != nil {
+		return 0, err
+	}
 
-	result := C.check_registry(cData, C.int(len(data)))
-	if result == 0 {
-		errMsg := f.getLastError()
-		return &CheckResult{
-			Allowed: false,
-			Reason:  errMsg,
-		}, nil
+	var selected int
+	var reason string
+	err = f.pool.do(context.Background(), func() {
+		if selected = int(f.invoke(entryNegotiate, data)); selected == 0 {
+			reason = f.getLastError()
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if selected == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoCommonVersion, reason)
 	}
+	if _, err := NegotiateVersion(offered, []int{selected}); err != nil {
+		return 0, err
+	}
+	return selected, nil
+}
 
-	return &CheckResult{
-		Allowed: true,
-		Reason:  "registry validation passed",
-	}, nil
+func (f *ffiImpl) protocolVersion() int {
+	return f.version
 }
 
-func (f *ffiImpl) checkState(req *StateCheckRequest) (*CheckResult, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func (f *ffiImpl) checkRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	return f.call(ctx, entryRegistry, EnvelopeRegistryCheck, req, "registry validation passed")
+}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("sentinel: failed to marshal request: %w", err)
+func (f *ffiImpl) checkState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	return f.call(ctx, entryState, EnvelopeStateCheck, req, "state validation passed")
+}
+
+func (f *ffiImpl) voteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	return f.call(ctx, entryCouncil, EnvelopeCouncilVote, req, "council approved action")
+}
+
+// call seals req in an envelope, invokes entry on a worker within ctx,
+// and maps its return code, or from version 2 passes the envelope to
+// check_envelope and decodes the check_result it returns.
+func (f *ffiImpl) call(ctx context.Context, entry ffiEntry, typ string, req interface{}, okReason string) (*CheckResult, error) {
+	if f.negotiateErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFICall, f.negotiateErr)
 	}
 
-	cData := C.CString(string(data))
-	defer C.free(unsafe.Pointer(cData))
+	data, err := SealEnvelope(f.version, typ, req)
+	if err != nil {
+		return nil, err
+	}
+	if f.version >= 2 {
+		return f.callJSON(ctx, data, okReason)
+	}
 
-	result := C.check_state(cData, C.int(len(data)))
-	if result == 0 {
-		errMsg := f.getLastError()
+	var allowed bool
+	var reason string
+	err = f.pool.do(ctx, func() {
+		if allowed = f.invoke(entry, data) != 0; !allowed {
+			reason = f.getLastError()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
 		return &CheckResult{
 			Allowed: false,
-			Reason:  errMsg,
+			Reason:  reason,
 		}, nil
 	}
 
 	return &CheckResult{
 		Allowed: true,
-		Reason:  "state validation passed",
+		Reason:  okReason,
 	}, nil
 }
 
-func (f *ffiImpl) voteCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	data, err := json.Marshal(req)
+// callJSON passes a request envelope to check_envelope on a worker
+// within ctx and decodes the verdict. A NULL or malformed response is
+// an error, never a verdict.
+func (f *ffiImpl) callJSON(ctx context.Context, data []byte, okReason string) (*CheckResult, error) {
+	var response []byte
+	var reason string
+	err := f.pool.do(ctx, func() {
+		cData := C.CString(string(data))
+		defer C.free(unsafe.Pointer(cData))
+		out := C.sentinel_call_json(f.syms.check_envelope, cData, C.int(len(data)))
+		if out == nil {
+			reason = f.getLastError()
+			return
+		}
+		defer C.sentinel_free_string(f.syms.free_string, out)
+		response = []byte(C.GoString(out))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("sentinel: failed to marshal request: %w", err)
+		return nil, err
 	}
+	if response == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFFICall, reason)
+	}
+	result, err := OpenCheckResult(response, okReason)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFICall, err)
+	}
+	return result, nil
+}
 
+// invoke passes data to a Rust entry point. It must run on a worker.
+func (f *ffiImpl) invoke(entry ffiEntry, data []byte) C.int {
 	cData := C.CString(string(data))
 	defer C.free(unsafe.Pointer(cData))
+	n := C.int(len(data))
 
-	result := C.vote_council(cData, C.int(len(data)))
-	if result == 0 {
-		errMsg := f.getLastError()
-		return &CheckResult{
-			Allowed: false,
-			Reason:  errMsg,
-		}, nil
+	switch entry {
+	case entryNegotiate:
+		return C.sentinel_call(f.syms.negotiate_version, cData, n)
+	case entryRegistry:
+		return C.sentinel_call(f.syms.check_registry, cData, n)
+	case entryState:
+		return C.sentinel_call(f.syms.check_state, cData, n)
+	default:
+		return C.sentinel_call(f.syms.vote_council, cData, n)
 	}
-
-	return &CheckResult{
-		Allowed: true,
-		Reason:  "council approved action",
-	}, nil
 }
 
+// getLastError returns the error of the last call on this thread. It
+// must run on the worker that made the call.
 func (f *ffiImpl) getLastError() string {
-	errStr := C.get_last_error()
+	errStr := C.sentinel_last_error(f.syms.get_last_error)
 	if errStr == nil {
 		return "unknown error"
 	}
-	defer C.free_string(errStr)
+	defer C.sentinel_free_string(f.syms.free_string, errStr)
 	return C.GoString(errStr)
 }
@@ -0,0 +1,272 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNoBackends is returned when a fused client has no backend for a check.
+var ErrNoBackends = errors.New("sentinel: no backend configured for check")
+
+// Backend is a security engine that can answer sentinel checks.
+//
+// Each check runs within the caller's context: backends propagate its
+// trace, for example to a remote service, and give up when it ends.
+// Clients layering backends pass their context on to every member.
+//
+// *Client satisfies Backend, so the Rust FFI bridge can be layered with
+// other engines such as a RemoteBackend.
+type Backend interface {
+	CheckRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error)
+	CheckState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error)
+	VoteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error)
+}
+
+// FusionMode selects how verdicts from several backends are combined.
+type FusionMode string
+
+const (
+	// FusionMostRestrictive blocks if any backend blocks, and fails the
+	// check if any backend errors (default)
+	FusionMostRestrictive FusionMode = "most-restrictive"
+
+	// FusionWeighted allows when the weight of allowing backends reaches
+	// Threshold of the total weight of backends that answered; backends
+	// that error abstain
+	FusionWeighted FusionMode = "weighted"
+)
+
+// Member is one backend in a fused client.
+type Member struct {
+	// Name identifies the backend in verdict details
+	Name string
+
+	// Backend answers the checks
+	Backend Backend
+
+	// Weight is the backend's vote weight for FusionWeighted (default 1)
+	Weight float64
+
+	// Checks limits the backend to these check types (EnvelopeRegistryCheck,
+	// EnvelopeStateCheck, EnvelopeCouncilVote); empty means all
+	Checks []string
+}
+
+// FusionConfig configures verdict fusion.
+type FusionConfig struct {
+	// Mode selects the fusion rule
+	Mode FusionMode
+
+	// Threshold is the allowing weight fraction required by
+	// FusionWeighted (default 0.5, exclusive of ties below it)
+	Threshold float64
+}
+
+// NewFusedClient creates a client that runs every applicable member for
+// each check concurrently and fuses their verdicts.
+//
+// # Arguments
+//   - cfg: Fusion rule (nil uses FusionMostRestrictive)
+//   - members: Backends to consult
+//
+// # Returns
+//   - Client usable anywhere a single-backend client is
+//
+// # Security Notes
+//
+// Most-restrictive fusion never allows what any single backend would
+// block. Weighted fusion trades that guarantee for tolerance of a noisy
+// engine; use it only when every member is independently trusted.
+func NewFusedClient(cfg *FusionConfig, members ...Member) *Client {
+	f := &fusedImpl{cfg: FusionConfig{Mode: FusionMostRestrictive, Threshold: 0.5}}
+	if cfg != nil {
+		if cfg.Mode != "" {
+			f.cfg.Mode = cfg.Mode
+		}
+		if cfg.Threshold > 0 {
+			f.cfg.Threshold = cfg.Threshold
+		}
+	}
+	for _, m := range members {
+		if m.Weight <= 0 {
+			m.Weight = 1
+		}
+		f.members = append(f.members, m)
+	}
+	return &Client{impl: f}
+}
+
+// fusedImpl fans checks out to several backends.
+type fusedImpl struct {
+	cfg     FusionConfig
+	members []Member
+}
+
+// vote is one member's answer.
+type vote struct {
+	member *Member
+	result *CheckResult
+	err    error
+}
+
+func (f *fusedImpl) stub() bool {
+	for _, m := range f.members {
+		if c, ok := m.Backend.(*Client); !ok || !c.Stub() {
+			return false
+		}
+	}
+	return len(f.members) > 0
+}
+
+func (f *fusedImpl) protocolVersion() int {
+	version := EnvelopeVersion
+	for _, m := range f.members {
+		if c, ok := m.Backend.(*Client); ok && c.ProtocolVersion() < version {
+			version = c.ProtocolVersion()
+		}
+	}
+	return version
+}
+
+// reload reloads the members that are Reloadable.
+func (f *fusedImpl) reload(ctx context.Context, library string) (*ReloadReport, error) {
+	names := make([]string, len(f.members))
+	backends := make([]Backend, len(f.members))
+	for i, m := range f.members {
+		names[i], backends[i] = m.Name, m.Backend
+	}
+	return reloadMembers(ctx, library, names, backends)
+}
+
+func (f *fusedImpl) checkRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	return f.fuse(EnvelopeRegistryCheck, func(b Backend) (*CheckResult, error) {
+		return b.CheckRegistry(ctx, req)
+	})
+}
+
+func (f *fusedImpl) checkState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	return f.fuse(EnvelopeStateCheck, func(b Backend) (*CheckResult, error) {
+		return b.CheckState(ctx, req)
+	})
+}
+
+func (f *fusedImpl) voteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	return f.fuse(EnvelopeCouncilVote, func(b Backend) (*CheckResult, error) {
+		return b.VoteCouncil(ctx, req)
+	})
+}
+
+// fuse runs check on every member handling checkType and combines the
+// verdicts according to the fusion mode.
+func (f *fusedImpl) fuse(checkType string, check func(Backend) (*CheckResult, error)) (*CheckResult, error) {
+	var votes []*vote
+	var wg sync.WaitGroup
+	for i := range f.members {
+		m := &f.members[i]
+		if !m.handles(checkType) {
+			continue
+		}
+		v := &vote{member: m}
+		votes = append(votes, v)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.result, v.err = check(m.Backend)
+		}()
+	}
+	wg.Wait()
+
+	if len(votes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoBackends, checkType)
+	}
+	if f.cfg.Mode == FusionWeighted {
+		return f.weighted(votes)
+	}
+	return mostRestrictive(votes)
+}
+
+// mostRestrictive blocks if any vote blocks and fails on any error.
+func mostRestrictive(votes []*vote) (*CheckResult, error) {
+	details := voteDetails(votes)
+	var reasons []string
+	for _, v := range votes {
+		if v.err != nil {
+			return nil, fmt.Errorf("sentinel: backend %s: %w", v.member.Name, v.err)
+		}
+		if !v.result.Allowed {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", v.member.Name, v.result.Reason))
+		}
+	}
+	if len(reasons) > 0 {
+		return &CheckResult{Allowed: false, Reason: strings.Join(reasons, "; "), Details: details}, nil
+	}
+	return &CheckResult{Allowed: true, Reason: "all backends allowed", Details: details}, nil
+}
+
+// weighted allows when the allowing weight reaches the threshold.
+func (f *fusedImpl) weighted(votes []*vote) (*CheckResult, error) {
+	details := voteDetails(votes)
+	var allow, total float64
+	var errs []error
+	for _, v := range votes {
+		if v.err != nil {
+			errs = append(errs, fmt.Errorf("backend %s: %w", v.member.Name, v.err))
+			continue
+		}
+		total += v.member.Weight
+		if v.result.Allowed {
+			allow += v.member.Weight
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("sentinel: every backend failed: %w", errors.Join(errs...))
+	}
+
+	share := allow / total
+	details["allow_share"] = share
+	if share >= f.cfg.Threshold {
+		return &CheckResult{
+			Allowed: true,
+			Reason:  fmt.Sprintf("weighted vote allowed (%.2f ≥ %.2f)", share, f.cfg.Threshold),
+			Details: details,
+		}, nil
+	}
+	return &CheckResult{
+		Allowed: false,
+		Reason:  fmt.Sprintf("weighted vote blocked (%.2f < %.2f)", share, f.cfg.Threshold),
+		Details: details,
+	}, nil
+}
+
+// voteDetails records each member's verdict for diagnostics.
+func voteDetails(votes []*vote) map[string]interface{} {
+	perBackend := make(map[string]interface{}, len(votes))
+	for _, v := range votes {
+		switch {
+		case v.err != nil:
+			perBackend[v.member.Name] = map[string]interface{}{"error": v.err.Error()}
+		default:
+			perBackend[v.member.Name] = map[string]interface{}{
+				"allowed": v.result.Allowed,
+				"reason":  v.result.Reason,
+			}
+		}
+	}
+	return map[string]interface{}{"backends": perBackend}
+}
+
+// handles reports whether the member participates in checkType.
+func (m *Member) handles(checkType string) bool {
+	if len(m.Checks) == 0 {
+		return true
+	}
+	for _, c := range m.Checks {
+		if c == checkType {
+			return true
+		}
+	}
+	return false
+}
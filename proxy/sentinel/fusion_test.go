@@ -0,0 +1,179 @@
+package sentinel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+)
+
+// fixedBackend returns the same verdict for every check.
+type fixedBackend struct {
+	allowed bool
+	err     error
+}
+
+func (b *fixedBackend) verdict() (*CheckResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &CheckResult{Allowed: b.allowed, Reason: "fixed"}, nil
+}
+
+func (b *fixedBackend) CheckRegistry(context.Context, *RegistryCheckRequest) (*CheckResult, error) {
+	return b.verdict()
+}
+func (b *fixedBackend) CheckState(context.Context, *StateCheckRequest) (*CheckResult, error) {
+	return b.verdict()
+}
+func (b *fixedBackend) VoteCouncil(context.Context, *CouncilVoteRequest) (*CheckResult, error) {
+	return b.verdict()
+}
+
+func TestFusedClient_MostRestrictive(t *testing.T) {
+	c := NewFusedClient(nil,
+		Member{Name: "ffi", Backend: NewClient()},
+		Member{Name: "vendor", Backend: &fixedBackend{allowed: false}, Checks: []string{EnvelopeCouncilVote}},
+	)
+
+	// Vendor only votes on council checks
+	if r, err := c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "x"}); err != nil || !r.Allowed {
+		t.Errorf("registry should be allowed by ffi alone: %v, %v", r, err)
+	}
+	r, err := c.VoteCouncil(context.Background(), &CouncilVoteRequest{ToolName: "x"})
+	if err != nil || r.Allowed {
+		t.Errorf("any block should win: %v, %v", r, err)
+	}
+
+	failing := NewFusedClient(nil,
+		Member{Name: "ffi", Backend: NewClient()},
+		Member{Name: "remote", Backend: &fixedBackend{err: errors.New("down")}},
+	)
+	if _, err := failing.CheckState(context.Background(), &StateCheckRequest{}); err == nil {
+		t.Error("most-restrictive should fail when a backend errors")
+	}
+}
+
+func TestFusedClient_Weighted(t *testing.T) {
+	c := NewFusedClient(&FusionConfig{Mode: FusionWeighted, Threshold: 0.6},
+		Member{Name: "a", Backend: &fixedBackend{allowed: true}, Weight: 2},
+		Member{Name: "b", Backend: &fixedBackend{allowed: false}, Weight: 1},
+		Member{Name: "c", Backend: &fixedBackend{err: errors.New("timeout")}},
+	)
+	r, err := c.VoteCouncil(context.Background(), &CouncilVoteRequest{})
+	if err != nil || !r.Allowed {
+		t.Errorf("2/3 allowing weight should pass 0.6 threshold: %v, %v", r, err)
+	}
+
+	none := NewFusedClient(&FusionConfig{Mode: FusionWeighted},
+		Member{Name: "c", Backend: &fixedBackend{err: errors.New("timeout")}},
+	)
+	if _, err := none.VoteCouncil(context.Background(), &CouncilVoteRequest{}); err == nil {
+		t.Error("expected error when every backend fails")
+	}
+}
+
+func TestRemoteBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var env Envelope
+		json.NewDecoder(req.Body).Decode(&env)
+		if env.Type == EnvelopeCouncilVote {
+			w.Write([]byte(`{"allowed":false,"reason":"policy 7"}`))
+			return
+		}
+		if env.Type == EnvelopeStateCheck {
+			w.Write([]byte(`{"reason":"no verdict"}`))
+			return
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer srv.Close()
+
+	b := NewRemoteBackend(srv.URL, nil)
+	if r, err := b.VoteCouncil(context.Background(), &CouncilVoteRequest{}); err != nil || r.Allowed || r.Reason != "policy 7" {
+		t.Errorf("unexpected council verdict: %v, %v", r, err)
+	}
+	if r, err := b.CheckRegistry(context.Background(), &RegistryCheckRequest{}); err != nil || !r.Allowed {
+		t.Errorf("unexpected registry verdict: %v, %v", r, err)
+	}
+	if _, err := b.CheckState(context.Background(), &StateCheckRequest{}); err == nil {
+		t.Error("verdict without allowed should be an error")
+	}
+}
+
+func TestRemoteBackend_PropagatesTrace(t *testing.T) {
+	headers := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers <- req.Header.Get("traceparent")
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer srv.Close()
+
+	rec := &spanRecorder{}
+	ctx, root := tracing.New(rec, nil).Start(context.Background(), "route")
+	client := NewFusedClient(nil, Member{Name: "remote", Backend: NewRemoteBackend(srv.URL, nil)})
+	if _, err := client.CheckRegistry(ctx, &RegistryCheckRequest{ToolName: "read_file"}); err != nil {
+		t.Fatalf("CheckRegistry failed: %v", err)
+	}
+	root.End()
+
+	spans := rec.spans
+	if len(spans) != 2 || spans[0].Name != "sentinel."+EnvelopeRegistryCheck {
+		t.Fatalf("spans = %+v", spans)
+	}
+	check := spans[0]
+	if check.Parent != root.SpanContext().SpanID || check.Attrs["sentinel.allowed"] != true {
+		t.Errorf("check span = %+v", check)
+	}
+	got, err := tracing.ParseTraceparent(<-headers)
+	if err != nil || got.TraceID != check.Context.TraceID || got.SpanID != check.Context.SpanID {
+		t.Errorf("traceparent = %+v, %v, expected the check span %+v", got, err, check.Context)
+	}
+
+	// Untraced checks send no header
+	NewRemoteBackend(srv.URL, nil).CheckState(context.Background(), &StateCheckRequest{})
+	if h := <-headers; h != "" {
+		t.Errorf("untraced traceparent = %q", h)
+	}
+}
+
+// spanRecorder is a tracing.Exporter keeping every span.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *spanRecorder) Close() error { return nil }
+
+func TestClient_Stub(t *testing.T) {
+	local := NewClient()
+	tests := []struct {
+		name   string
+		client *Client
+		stub   bool
+	}{
+		{"fused local clients", NewFusedClient(nil, Member{Name: "a", Backend: local}, Member{Name: "b", Backend: NewClient()}), local.Stub()},
+		{"fused with a real backend", NewFusedClient(nil, Member{Name: "a", Backend: local}, Member{Name: "b", Backend: &fixedBackend{}}), false},
+		{"tiered local clients", NewTieredClient(nil, local, local), local.Stub()},
+		{"tiered without deep", NewTieredClient(nil, local, nil), local.Stub()},
+		{"tiered with a real deep backend", NewTieredClient(nil, local, &fixedBackend{}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.client.Stub(); got != tt.stub {
+				t.Errorf("Stub() = %v, expected %v", got, tt.stub)
+			}
+		})
+	}
+}
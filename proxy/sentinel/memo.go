@@ -0,0 +1,287 @@
+package sentinel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/clock"
+)
+
+// Default council memoization settings.
+const (
+	DefaultMemoTTL        = 5 * time.Second
+	DefaultMemoMaxEntries = 1024
+)
+
+// RiskBands is the number of buckets the [0, 1] risk range is divided
+// into for memoization. Scores in the same band share cached verdicts.
+const RiskBands = 4
+
+// MemoConfig configures council decision memoization.
+type MemoConfig struct {
+	// TTL is how long a cached verdict remains valid. Keep this short:
+	// the cache exists to absorb tight loops, not to replace voting.
+	TTL time.Duration
+
+	// MaxEntries bounds the cache size. The oldest entry is evicted
+	// when the cache is full.
+	MaxEntries int
+
+	// SensitiveArgs lists, per tool name, the arguments whose exact
+	// values define a new context. Their values are folded into the
+	// argument class; all other arguments contribute only their shape
+	// (key and JSON type). A tool absent from the map is treated as
+	// fully sensitive, so every distinct argument set triggers a vote.
+	SensitiveArgs map[string][]string
+
+	// Clock expires cached verdicts (nil uses the system clock)
+	Clock clock.Clock
+}
+
+// MemoKey identifies a class of council decisions that may share a verdict.
+type MemoKey struct {
+	// ToolName is the tool being invoked
+	ToolName string
+
+	// ArgClass is a digest of the argument shape and sensitive values
+	ArgClass string
+
+	// RiskBand is the bucketed risk score (0 to RiskBands-1)
+	RiskBand int
+
+	// PolicyVersion invalidates cached verdicts when policy changes
+	PolicyVersion string
+}
+
+// memoEntry is a cached verdict with its expiry.
+type memoEntry struct {
+	result  *CheckResult
+	expires time.Time
+	added   time.Time
+}
+
+// CouncilMemo caches council verdicts so repeated near-identical
+// high-risk actions do not incur repeated voting latency.
+//
+// # Context Sensitivity
+//
+// Two calls share a verdict only if they agree on tool, argument class,
+// risk band, and policy version. Arguments named in SensitiveArgs are
+// compared by value; the rest are compared by shape only. Requests whose
+// Context carries new evidence should bypass the cache via Bypass.
+//
+// # Security Notes
+//
+// Only successful votes are cached; FFI errors are never memoized.
+// Entries expire after TTL regardless of hit rate.
+type CouncilMemo struct {
+	cfg     MemoConfig
+	mu      sync.Mutex
+	entries map[MemoKey]memoEntry
+	now     func() time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewCouncilMemo creates a council verdict cache.
+//
+// Zero TTL or MaxEntries fall back to DefaultMemoTTL and
+// DefaultMemoMaxEntries.
+func NewCouncilMemo(cfg MemoConfig) *CouncilMemo {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultMemoTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMemoMaxEntries
+	}
+	return &CouncilMemo{
+		cfg:     cfg,
+		entries: make(map[MemoKey]memoEntry),
+		now:     clock.Func(cfg.Clock),
+	}
+}
+
+// Key derives the memoization key for a council vote.
+//
+// # Arguments
+//   - toolName: Tool being invoked
+//   - params: Raw tools/call params (the "arguments" object is used)
+//   - riskScore: Risk score from 0.0 to 1.0
+//   - policyVersion: Version of the active policy set
+func (m *CouncilMemo) Key(toolName string, params json.RawMessage, riskScore float64, policyVersion string) MemoKey {
+	sensitive, scoped := m.cfg.SensitiveArgs[toolName]
+	return MemoKey{
+		ToolName:      toolName,
+		ArgClass:      argClass(params, sensitive, !scoped),
+		RiskBand:      riskBand(riskScore),
+		PolicyVersion: policyVersion,
+	}
+}
+
+// Get returns a cached verdict for key, if one is present and fresh.
+//
+// The returned result is a copy with Details["memoized"] set, so callers
+// can record that no vote took place.
+func (m *CouncilMemo) Get(key MemoKey) (*CheckResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || !m.now().Before(entry.expires) {
+		if ok {
+			delete(m.entries, key)
+		}
+		m.misses.Add(1)
+		return nil, false
+	}
+	m.hits.Add(1)
+
+	details := make(map[string]interface{}, len(entry.result.Details)+1)
+	for k, v := range entry.result.Details {
+		details[k] = v
+	}
+	details["memoized"] = true
+	return &CheckResult{
+		Allowed: entry.result.Allowed,
+		Reason:  entry.result.Reason,
+		Details: details,
+	}, true
+}
+
+// Put stores a verdict for key.
+func (m *CouncilMemo) Put(key MemoKey, result *CheckResult) {
+	if result == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.cfg.MaxEntries {
+		m.evictLocked(now)
+	}
+	m.entries[key] = memoEntry{
+		result:  result,
+		expires: now.Add(m.cfg.TTL),
+		added:   now,
+	}
+}
+
+// Bypass reports whether a vote request carries context that must not be
+// answered from cache. Any non-empty Context is treated as new evidence.
+func (m *CouncilMemo) Bypass(req *CouncilVoteRequest) bool {
+	return len(req.Context) > 0
+}
+
+// Purge drops all cached verdicts, e.g. after a policy reload.
+func (m *CouncilMemo) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[MemoKey]memoEntry)
+}
+
+// Stats returns cache hit and miss counts.
+func (m *CouncilMemo) Stats() (hits, misses uint64) {
+	return m.hits.Load(), m.misses.Load()
+}
+
+// evictLocked removes expired entries, or the oldest entry if none expired.
+func (m *CouncilMemo) evictLocked(now time.Time) {
+	var oldestKey MemoKey
+	var oldest time.Time
+	evicted := false
+	for k, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, k)
+			evicted = true
+			continue
+		}
+		if oldest.IsZero() || e.added.Before(oldest) {
+			oldest, oldestKey = e.added, k
+		}
+	}
+	if !evicted && !oldest.IsZero() {
+		delete(m.entries, oldestKey)
+	}
+}
+
+// riskBand buckets a risk score into one of RiskBands bands.
+func riskBand(score float64) int {
+	if math.IsNaN(score) || score < 0 {
+		return 0
+	}
+	band := int(score * RiskBands)
+	if band >= RiskBands {
+		band = RiskBands - 1
+	}
+	return band
+}
+
+// argClass digests tool arguments into a class identifier.
+//
+// Sensitive arguments (or all arguments when allSensitive is set)
+// contribute their raw value; others contribute only their JSON type.
+func argClass(params json.RawMessage, sensitive []string, allSensitive bool) string {
+	var call struct {
+		Arguments map[string]json.RawMessage `json:"arguments"`
+	}
+	h := sha256.New()
+	if err := json.Unmarshal(params, &call); err != nil {
+		// Unparseable params: fall back to the raw bytes
+		h.Write(params)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	isSensitive := make(map[string]bool, len(sensitive))
+	for _, name := range sensitive {
+		isSensitive[name] = true
+	}
+
+	keys := make([]string, 0, len(call.Arguments))
+	for k := range call.Arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		if allSensitive || isSensitive[k] {
+			h.Write(call.Arguments[k])
+		} else {
+			h.Write([]byte(jsonKind(call.Arguments[k])))
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// jsonKind returns the JSON type name of a raw value.
+func jsonKind(raw json.RawMessage) string {
+	for _, c := range raw {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{':
+			return "object"
+		case '[':
+			return "array"
+		case '"':
+			return "string"
+		case 't', 'f':
+			return "boolean"
+		case 'n':
+			return "null"
+		default:
+			return "number"
+		}
+	}
+	return "empty"
+}
@@ -0,0 +1,99 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCouncilMemo_HitAndExpiry(t *testing.T) {
+	m := NewCouncilMemo(MemoConfig{TTL: time.Second})
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	params := json.RawMessage(`{"name":"shell","arguments":{"command":"ls"}}`)
+	key := m.Key("shell", params, 0.7, "v1")
+
+	if _, ok := m.Get(key); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+
+	m.Put(key, &CheckResult{Allowed: true, Reason: "approved"})
+	got, ok := m.Get(key)
+	if !ok {
+		t.Fatal("expected hit after Put")
+	}
+	if !got.Allowed || got.Details["memoized"] != true {
+		t.Errorf("unexpected cached result: %+v", got)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := m.Get(key); ok {
+		t.Error("expected miss after TTL")
+	}
+
+	hits, misses := m.Stats()
+	if hits != 1 || misses != 2 {
+		t.Errorf("expected 1 hit / 2 misses, got %d / %d", hits, misses)
+	}
+}
+
+func TestCouncilMemo_KeySensitivity(t *testing.T) {
+	m := NewCouncilMemo(MemoConfig{
+		SensitiveArgs: map[string][]string{
+			"write_file": {"path"},
+		},
+	})
+
+	a := json.RawMessage(`{"name":"write_file","arguments":{"path":"/tmp/a","content":"x"}}`)
+	b := json.RawMessage(`{"name":"write_file","arguments":{"path":"/tmp/a","content":"y"}}`)
+	c := json.RawMessage(`{"name":"write_file","arguments":{"path":"/etc/passwd","content":"x"}}`)
+
+	if m.Key("write_file", a, 0.7, "v1") != m.Key("write_file", b, 0.7, "v1") {
+		t.Error("non-sensitive argument values should share a key")
+	}
+	if m.Key("write_file", a, 0.7, "v1") == m.Key("write_file", c, 0.7, "v1") {
+		t.Error("sensitive argument values should produce distinct keys")
+	}
+	if m.Key("write_file", a, 0.7, "v1") == m.Key("write_file", a, 0.7, "v2") {
+		t.Error("policy version should be part of the key")
+	}
+	if m.Key("write_file", a, 0.1, "v1") == m.Key("write_file", a, 0.9, "v1") {
+		t.Error("distinct risk bands should produce distinct keys")
+	}
+
+	// Tools without SensitiveArgs compare every argument by value
+	x := json.RawMessage(`{"name":"shell","arguments":{"command":"ls"}}`)
+	y := json.RawMessage(`{"name":"shell","arguments":{"command":"rm -rf /"}}`)
+	if m.Key("shell", x, 0.7, "v1") == m.Key("shell", y, 0.7, "v1") {
+		t.Error("unscoped tools should be fully context sensitive")
+	}
+}
+
+func TestCouncilMemo_Bypass(t *testing.T) {
+	m := NewCouncilMemo(MemoConfig{})
+	if m.Bypass(&CouncilVoteRequest{ToolName: "shell"}) {
+		t.Error("request without context should not bypass")
+	}
+	if !m.Bypass(&CouncilVoteRequest{ToolName: "shell", Context: map[string]interface{}{"tainted": true}}) {
+		t.Error("request with context should bypass")
+	}
+}
+
+func TestCouncilMemo_Eviction(t *testing.T) {
+	m := NewCouncilMemo(MemoConfig{MaxEntries: 2, TTL: time.Minute})
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	for i, tool := range []string{"a", "b", "c"} {
+		now = now.Add(time.Duration(i) * time.Millisecond)
+		m.Put(MemoKey{ToolName: tool}, &CheckResult{Allowed: true})
+	}
+
+	if _, ok := m.Get(MemoKey{ToolName: "a"}); ok {
+		t.Error("oldest entry should have been evicted")
+	}
+	if _, ok := m.Get(MemoKey{ToolName: "c"}); !ok {
+		t.Error("newest entry should be present")
+	}
+}
@@ -0,0 +1,140 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Worker pool errors.
+var (
+	ErrNoWorker      = errors.New("sentinel: no engine worker free before the deadline")
+	ErrCallAbandoned = errors.New("sentinel: engine call abandoned at the deadline")
+)
+
+// PoolConfig sizes the pool of workers that call into the Rust library.
+//
+// Each worker runs one call at a time on an OS thread of its own, so
+// Workers calls run concurrently and a slow council vote holds up only
+// the worker running it. Further calls wait for a free worker until
+// their context ends.
+type PoolConfig struct {
+	// Workers is the number of concurrent calls (default
+	// runtime.GOMAXPROCS)
+	Workers int
+
+	// Timeout bounds each call, waiting for a worker included, when the
+	// caller's context has no earlier deadline (zero: only the caller's
+	// context bounds it)
+	Timeout time.Duration
+}
+
+// PoolStats is a snapshot of a worker pool.
+type PoolStats struct {
+	// Workers is the pool size
+	Workers int `json:"workers"`
+
+	// Busy is the number of workers running a call
+	Busy int64 `json:"busy"`
+
+	// Abandoned counts calls whose caller stopped waiting at its
+	// deadline; the worker finishes each and discards its result
+	Abandoned uint64 `json:"abandoned"`
+}
+
+// workerPool runs calls on a fixed set of OS-thread-bound goroutines.
+type workerPool struct {
+	timeout time.Duration
+	workers int
+
+	// jobs is unbuffered: a call is handed to an idle worker or waits
+	jobs chan func()
+	wg   sync.WaitGroup
+
+	busy      atomic.Int64
+	abandoned atomic.Uint64
+}
+
+// newWorkerPool starts the workers of a pool sized by cfg.
+func newWorkerPool(cfg PoolConfig) *workerPool {
+	p := &workerPool{
+		timeout: cfg.Timeout,
+		workers: cfg.Workers,
+		jobs:    make(chan func()),
+	}
+	if p.workers <= 0 {
+		p.workers = runtime.GOMAXPROCS(0)
+	}
+	p.wg.Add(p.workers)
+	for range p.workers {
+		go p.work()
+	}
+	return p
+}
+
+// work runs jobs until the pool closes.
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	// The library keeps its last error per thread, so a call and the
+	// error lookup after it must run on the same one. The thread exits
+	// with the worker.
+	runtime.LockOSThread()
+	for job := range p.jobs {
+		p.busy.Add(1)
+		job()
+		p.busy.Add(-1)
+	}
+}
+
+// do runs fn on a worker and waits for it within ctx, bounded by the
+// pool timeout. A panic in fn is raised again in the caller.
+//
+// # Returns
+//   - nil once fn has returned
+//   - ErrNoWorker if ctx ended before a worker was free; fn never runs
+//   - ErrCallAbandoned if ctx ended while fn ran; fn runs to completion
+//     regardless, since a call into the library cannot be interrupted
+func (p *workerPool) do(ctx context.Context, fn func()) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	done := make(chan interface{}, 1)
+	job := func() {
+		defer func() { done <- recover() }()
+		fn()
+	}
+	select {
+	case p.jobs <- job:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrNoWorker, ctx.Err())
+	}
+	select {
+	case r := <-done:
+		if r != nil {
+			panic(r)
+		}
+		return nil
+	case <-ctx.Done():
+		p.abandoned.Add(1)
+		return fmt.Errorf("%w: %v", ErrCallAbandoned, ctx.Err())
+	}
+}
+
+// stats returns a snapshot of the pool.
+func (p *workerPool) stats() PoolStats {
+	return PoolStats{Workers: p.workers, Busy: p.busy.Load(), Abandoned: p.abandoned.Load()}
+}
+
+// close stops the workers once the calls they are running return, and
+// waits for them. No call may be started after close.
+func (p *workerPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
@@ -0,0 +1,126 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_Concurrent(t *testing.T) {
+	p := newWorkerPool(PoolConfig{Workers: 2})
+	defer p.close()
+
+	// A slow call holds one worker; the other keeps serving
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := make(chan error, 1)
+	go func() {
+		slow <- p.do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+	for i := 0; i < 3; i++ {
+		ran := false
+		if err := p.do(context.Background(), func() { ran = true }); err != nil || !ran {
+			t.Fatalf("call %d beside the slow one: ran %v, err %v", i, ran, err)
+		}
+	}
+	if s := p.stats(); s.Workers != 2 || s.Busy != 1 {
+		t.Errorf("stats = %+v, expected 2 workers with 1 busy", s)
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("slow call failed: %v", err)
+	}
+}
+
+func TestWorkerPool_Deadlines(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      PoolConfig
+		ctx      time.Duration
+		expected error
+	}{
+		{"context deadline while running", PoolConfig{Workers: 1}, 20 * time.Millisecond, ErrCallAbandoned},
+		{"pool timeout while running", PoolConfig{Workers: 1, Timeout: 20 * time.Millisecond}, 0, ErrCallAbandoned},
+		{"earlier context deadline wins", PoolConfig{Workers: 1, Timeout: time.Hour}, 20 * time.Millisecond, ErrCallAbandoned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newWorkerPool(tt.cfg)
+			release := make(chan struct{})
+			defer p.close()
+			defer close(release)
+
+			ctx := context.Background()
+			if tt.ctx > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctx)
+				defer cancel()
+			}
+			err := p.do(ctx, func() { <-release })
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("err = %v, expected %v", err, tt.expected)
+			}
+			if s := p.stats(); s.Abandoned != 1 {
+				t.Errorf("Abandoned = %d, expected 1", s.Abandoned)
+			}
+		})
+	}
+}
+
+func TestWorkerPool_NoWorker(t *testing.T) {
+	p := newWorkerPool(PoolConfig{Workers: 1})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := p.do(ctx, func() { ran = true }); !errors.Is(err, ErrNoWorker) {
+		t.Errorf("err = %v, expected ErrNoWorker", err)
+	}
+	close(release)
+	wg.Wait()
+	p.close()
+	if ran {
+		t.Error("a call that found no worker ran anyway")
+	}
+}
+
+func TestWorkerPool_Panic(t *testing.T) {
+	p := newWorkerPool(PoolConfig{Workers: 1})
+	defer p.close()
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, expected the call's panic", r)
+			}
+		}()
+		p.do(context.Background(), func() { panic("boom") })
+	}()
+	if err := p.do(context.Background(), func() {}); err != nil {
+		t.Errorf("worker lost after a panic: %v", err)
+	}
+}
+
+func TestClient_PoolStats(t *testing.T) {
+	c := NewFusedClient(nil, Member{Name: "a", Backend: &fixedBackend{allowed: true}})
+	if _, ok := c.PoolStats(); ok {
+		t.Error("a layered client reported a worker pool")
+	}
+}
@@ -0,0 +1,219 @@
+package sentinel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reload errors.
+var (
+	ErrNotReloadable = errors.New("sentinel: engine cannot be reloaded")
+	ErrPreflight     = errors.New("sentinel: replacement engine failed preflight")
+	ErrDrain         = errors.New("sentinel: in-flight checks did not drain")
+)
+
+// PreflightTool is the tool name of the checks a replacement engine
+// must answer before it takes over. Engines may recognize it to skip
+// logging or learning from the probes.
+const PreflightTool = "mcp-sentinel.preflight"
+
+// Reloadable is a Backend whose engine can be replaced while the proxy
+// runs, for example to load an updated Rust sentinel library without
+// restarting sessions.
+//
+// *Client and *RemoteBackend satisfy Reloadable.
+type Reloadable interface {
+	Reload(ctx context.Context, library string) (*ReloadReport, error)
+}
+
+// ReloadReport describes a completed reload.
+type ReloadReport struct {
+	// Library is the shared library now loaded (empty for engines
+	// that are not libraries)
+	Library string `json:"library,omitempty"`
+
+	// ProtocolVersion is the envelope version negotiated with the new
+	// engine
+	ProtocolVersion int `json:"protocol_version"`
+
+	// Drained is the number of checks in flight when the swap began,
+	// which finished on the old engine
+	Drained int64 `json:"drained"`
+
+	// PauseMS is how long new checks waited for the swap
+	PauseMS int64 `json:"pause_ms"`
+
+	// Members reports each reloaded backend of a layered client, by
+	// member name
+	Members map[string]*ReloadReport `json:"members,omitempty"`
+}
+
+// implCloser is implemented by implementations holding resources, such
+// as a library handle, to release once they are swapped out.
+type implCloser interface {
+	close()
+}
+
+// implSharer is implemented by implementations that can tell whether
+// another is the same loaded engine, so reloading one already loaded is
+// refused rather than reported as an update.
+type implSharer interface {
+	same(other clientImpl) bool
+}
+
+// implReloader is implemented by implementations that reload engines
+// of their own, such as a fused client's members.
+type implReloader interface {
+	reload(ctx context.Context, library string) (*ReloadReport, error)
+}
+
+// Reload replaces the client's engine while checks keep running.
+//
+// The replacement is loaded from library and must negotiate an
+// envelope version and answer one check of each kind without error
+// (the preflight) while the current engine still serves. Reload then
+// drains: new checks wait while checks in flight finish on the current
+// engine. The replacement is swapped in, the current engine released,
+// and waiting checks resume on the replacement. Layered clients reload
+// each member that is Reloadable.
+//
+// # Arguments
+//   - ctx: Bounds the preflight and the drain
+//   - library: Shared library to load (the FFI build); ignored by
+//     engines that are not libraries
+//
+// # Returns
+//   - What was reloaded
+//   - ErrNotReloadable if the engine cannot be replaced, such as in
+//     the build without FFI
+//   - ErrPreflight or ErrDrain if the swap was abandoned; the current
+//     engine then stays in service
+//
+// # Thread Safety
+//
+// Safe to call while checks run. Reloads are serialized.
+func (c *Client) Reload(ctx context.Context, library string) (*ReloadReport, error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.mu.RLock()
+	current := c.impl
+	c.mu.RUnlock()
+	if r, ok := current.(implReloader); ok {
+		report, err := r.reload(ctx, library)
+		if err == nil {
+			report.ProtocolVersion = current.protocolVersion()
+		}
+		return report, err
+	}
+	if c.open == nil {
+		return nil, ErrNotReloadable
+	}
+
+	next, err := c.open(library)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := next.(implSharer); ok && s.same(current) {
+		closeImpl(next)
+		return nil, fmt.Errorf("%w: %s is the engine already loaded; install the update under another file name", ErrPreflight, library)
+	}
+	if err := preflight(ctx, next); err != nil {
+		closeImpl(next)
+		return nil, err
+	}
+
+	start := time.Now()
+	drained := c.inflight.Load()
+	if err := c.drain(ctx); err != nil {
+		closeImpl(next)
+		return nil, err
+	}
+	c.impl = next
+	c.mu.Unlock()
+	closeImpl(current)
+	return &ReloadReport{
+		Library:         library,
+		ProtocolVersion: next.protocolVersion(),
+		Drained:         drained,
+		PauseMS:         time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// drain takes c.mu exclusively, waiting for checks in flight. New
+// checks queue behind it. If ctx ends first the lock is released as
+// soon as it is taken, and the queued checks proceed on the current
+// engine.
+func (c *Client) drain(ctx context.Context) error {
+	locked := make(chan struct{})
+	go func() {
+		c.mu.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			c.mu.Unlock()
+		}()
+		return fmt.Errorf("%w: %v", ErrDrain, ctx.Err())
+	}
+}
+
+// preflight checks that impl speaks a common envelope version and
+// answers each kind of check. Verdicts do not matter, only errors.
+func preflight(ctx context.Context, impl clientImpl) error {
+	if impl.protocolVersion() == 0 {
+		return fmt.Errorf("%w: no common envelope version", ErrPreflight)
+	}
+	if _, err := impl.checkRegistry(ctx, &RegistryCheckRequest{ToolName: PreflightTool, Params: json.RawMessage(`{}`)}); err != nil {
+		return fmt.Errorf("%w: registry check: %v", ErrPreflight, err)
+	}
+	if _, err := impl.checkState(ctx, &StateCheckRequest{SessionID: PreflightTool, ToolName: PreflightTool}); err != nil {
+		return fmt.Errorf("%w: state check: %v", ErrPreflight, err)
+	}
+	if _, err := impl.voteCouncil(ctx, &CouncilVoteRequest{Action: "preflight", ToolName: PreflightTool}); err != nil {
+		return fmt.Errorf("%w: council vote: %v", ErrPreflight, err)
+	}
+	return ctx.Err()
+}
+
+// closeImpl releases impl's resources, if it holds any.
+func closeImpl(impl clientImpl) {
+	if c, ok := impl.(implCloser); ok {
+		c.close()
+	}
+}
+
+// reloadMembers reloads each Reloadable backend, by name.
+//
+// # Returns
+//   - Reports of the reloaded members
+//   - ErrNotReloadable if none is Reloadable, or the first error; the
+//     members reloaded before it keep their new engines
+func reloadMembers(ctx context.Context, library string, names []string, backends []Backend) (*ReloadReport, error) {
+	report := &ReloadReport{Members: make(map[string]*ReloadReport)}
+	for i, b := range backends {
+		r, ok := b.(Reloadable)
+		if !ok {
+			continue
+		}
+		member, err := r.Reload(ctx, library)
+		if errors.Is(err, ErrNotReloadable) {
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", names[i], err)
+		}
+		report.Members[names[i]] = member
+	}
+	if len(report.Members) == 0 {
+		return nil, ErrNotReloadable
+	}
+	return report, nil
+}
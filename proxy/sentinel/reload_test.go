@@ -0,0 +1,180 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeImpl is a clientImpl with a fixed verdict whose checks can be
+// held open.
+type fakeImpl struct {
+	version int
+	allowed bool
+	err     error
+	hold    chan struct{}
+	started chan struct{}
+	closed  atomic.Bool
+}
+
+func (f *fakeImpl) verdict() (*CheckResult, error) {
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.hold != nil {
+		<-f.hold
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &CheckResult{Allowed: f.allowed}, nil
+}
+
+func (f *fakeImpl) protocolVersion() int { return f.version }
+func (f *fakeImpl) close()               { f.closed.Store(true) }
+func (f *fakeImpl) checkRegistry(context.Context, *RegistryCheckRequest) (*CheckResult, error) {
+	return f.verdict()
+}
+func (f *fakeImpl) checkState(context.Context, *StateCheckRequest) (*CheckResult, error) {
+	return f.verdict()
+}
+func (f *fakeImpl) voteCouncil(context.Context, *CouncilVoteRequest) (*CheckResult, error) {
+	return f.verdict()
+}
+
+// reloadingClient returns a client on current that reloads to next.
+func reloadingClient(current, next *fakeImpl) *Client {
+	return &Client{impl: current, open: func(string) (clientImpl, error) { return next, nil }}
+}
+
+func TestClient_Reload(t *testing.T) {
+	tests := []struct {
+		name    string
+		next    *fakeImpl
+		err     error
+		swapped bool
+	}{
+		{"replaced", &fakeImpl{version: 1, allowed: false}, nil, true},
+		{"no common version", &fakeImpl{version: 0}, ErrPreflight, false},
+		{"check fails", &fakeImpl{version: 1, err: errors.New("panicked")}, ErrPreflight, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &fakeImpl{version: 1, allowed: true}
+			c := reloadingClient(current, tt.next)
+			report, err := c.Reload(context.Background(), "/lib/next.so")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reload = %v, expected %v", err, tt.err)
+			}
+			r, _ := c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "x"})
+			if r.Allowed == tt.swapped {
+				t.Errorf("after reload the check allowed = %v", r.Allowed)
+			}
+			if current.closed.Load() != tt.swapped || tt.next.closed.Load() == tt.swapped {
+				t.Errorf("closed current=%v next=%v", current.closed.Load(), tt.next.closed.Load())
+			}
+			if tt.swapped && (report.Library != "/lib/next.so" || report.ProtocolVersion != 1) {
+				t.Errorf("report = %+v", report)
+			}
+		})
+	}
+
+	if _, err := NewClient().Reload(context.Background(), "/lib/next.so"); !errors.Is(err, ErrNotReloadable) {
+		t.Errorf("stub Reload = %v, expected ErrNotReloadable", err)
+	}
+}
+
+func TestClient_ReloadDrains(t *testing.T) {
+	// heldClient returns a client with a check in flight on its
+	// current engine, and a channel with the check's result
+	heldClient := func() (*Client, *fakeImpl, chan *CheckResult) {
+		current := &fakeImpl{version: 1, allowed: true, hold: make(chan struct{}), started: make(chan struct{}, 1)}
+		c := reloadingClient(current, &fakeImpl{version: 1, allowed: false})
+		done := make(chan *CheckResult, 1)
+		go func() {
+			r, _ := c.CheckState(context.Background(), &StateCheckRequest{})
+			done <- r
+		}()
+		<-current.started
+		return c, current, done
+	}
+
+	c, current, done := heldClient()
+	reloaded := make(chan *ReloadReport)
+	go func() {
+		report, _ := c.Reload(context.Background(), "next")
+		reloaded <- report
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-reloaded:
+		t.Fatal("the swap happened with a check in flight")
+	default:
+	}
+	close(current.hold)
+	if r := <-done; r == nil || !r.Allowed {
+		t.Errorf("in-flight check = %+v, expected the old engine's verdict", r)
+	}
+	if report := <-reloaded; report == nil || report.Drained != 1 {
+		t.Errorf("report = %+v, expected one drained check", report)
+	}
+	if r, _ := c.CheckState(context.Background(), &StateCheckRequest{}); r.Allowed {
+		t.Error("check after the swap used the old engine")
+	}
+
+	c, current, done = heldClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Reload(ctx, "next"); !errors.Is(err, ErrDrain) {
+		t.Fatalf("Reload with a check held past the deadline = %v, expected ErrDrain", err)
+	}
+	if current.closed.Load() {
+		t.Fatal("the engine serving a check was closed")
+	}
+	close(current.hold)
+	<-done
+	if r, _ := c.CheckState(context.Background(), &StateCheckRequest{}); !r.Allowed {
+		t.Error("an abandoned swap replaced the engine")
+	}
+}
+
+func TestLayeredClients_Reload(t *testing.T) {
+	ffi := reloadingClient(&fakeImpl{version: 1, allowed: true}, &fakeImpl{version: 1})
+	fused := NewFusedClient(nil,
+		Member{Name: "ffi", Backend: ffi},
+		Member{Name: "fixed", Backend: &fixedBackend{allowed: true}},
+		Member{Name: "stub", Backend: NewClient()},
+	)
+	report, err := fused.Reload(context.Background(), "next")
+	if err != nil || len(report.Members) != 1 || report.Members["ffi"] == nil {
+		t.Errorf("fused Reload = %+v, %v", report, err)
+	}
+
+	tiered := NewTieredClient(nil, &fixedBackend{allowed: true}, NewClient())
+	if _, err := tiered.Reload(context.Background(), "next"); !errors.Is(err, ErrNotReloadable) {
+		t.Errorf("tiered Reload without reloadable backends = %v", err)
+	}
+}
+
+func TestRemoteBackend_Reload(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer srv.Close()
+
+	b := NewRemoteBackend(srv.URL, nil)
+	if _, err := b.Reload(context.Background(), ""); err != nil {
+		t.Errorf("Reload = %v", err)
+	}
+	status.Store(http.StatusServiceUnavailable)
+	if _, err := b.Reload(context.Background(), ""); !errors.Is(err, ErrPreflight) {
+		t.Errorf("Reload of a failing service = %v, expected ErrPreflight", err)
+	}
+}
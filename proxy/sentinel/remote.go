@@ -0,0 +1,127 @@
+package sentinel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
+)
+
+// maxRemoteResponse bounds a policy service response body.
+const maxRemoteResponse = 1 << 20
+
+// remoteEnvelopeVersion is the envelope version of policy service
+// requests. The service replies with a bare verdict at every version,
+// so it stays at the baseline.
+const remoteEnvelopeVersion = 1
+
+// RemoteBackend answers sentinel checks by calling an HTTP policy service.
+//
+// Each check is POSTed to the service URL as a versioned envelope, the
+// same format used across the FFI boundary:
+//
+//	{"v":1,"type":"council_vote","payload":{...}}
+//
+// The service replies with:
+//
+//	{"allowed":true,"reason":"...","details":{...}}
+//
+// or any other fields of a CheckResponse, such as a council's tally.
+// Non-2xx responses and malformed bodies are errors, never verdicts.
+// Checks made within a traced context carry a W3C traceparent header.
+type RemoteBackend struct {
+	url    string
+	client *http.Client
+}
+
+// NewRemoteBackend creates a backend for the policy service at url.
+// A nil client uses one with a 5 second timeout.
+func NewRemoteBackend(url string, client *http.Client) *RemoteBackend {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &RemoteBackend{url: url, client: client}
+}
+
+// CheckRegistry implements Backend.
+func (b *RemoteBackend) CheckRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	return b.call(ctx, EnvelopeRegistryCheck, req)
+}
+
+// CheckState implements Backend.
+func (b *RemoteBackend) CheckState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	return b.call(ctx, EnvelopeStateCheck, req)
+}
+
+// VoteCouncil implements Backend.
+func (b *RemoteBackend) VoteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	return b.call(ctx, EnvelopeCouncilVote, req)
+}
+
+// Reload implements Reloadable by reconnecting to the policy service:
+// idle connections are closed, so the next checks dial afresh (and
+// reach a redeployed service behind the same URL), and one check of
+// each kind must succeed. The library is ignored.
+func (b *RemoteBackend) Reload(ctx context.Context, _ string) (*ReloadReport, error) {
+	b.client.CloseIdleConnections()
+	if err := preflight(ctx, remoteImpl{b}); err != nil {
+		return nil, err
+	}
+	return &ReloadReport{ProtocolVersion: remoteEnvelopeVersion}, nil
+}
+
+// remoteImpl adapts a RemoteBackend to clientImpl for preflight.
+type remoteImpl struct {
+	b *RemoteBackend
+}
+
+func (r remoteImpl) protocolVersion() int { return remoteEnvelopeVersion }
+
+func (r remoteImpl) checkRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	return r.b.CheckRegistry(ctx, req)
+}
+
+func (r remoteImpl) checkState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	return r.b.CheckState(ctx, req)
+}
+
+func (r remoteImpl) voteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	return r.b.VoteCouncil(ctx, req)
+}
+
+// call posts an envelope and decodes the verdict. The request carries
+// the trace in ctx as a traceparent header.
+func (b *RemoteBackend) call(ctx context.Context, typ string, payload interface{}) (*CheckResult, error) {
+	body, err := SealEnvelope(remoteEnvelopeVersion, typ, payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("sentinel: policy service: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel: policy service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("sentinel: policy service returned %s", resp.Status)
+	}
+	var v CheckResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRemoteResponse)).Decode(&v); err != nil {
+		return nil, fmt.Errorf("sentinel: malformed policy service verdict: %w", err)
+	}
+	if v.Allowed == nil {
+		return nil, fmt.Errorf("sentinel: policy service verdict missing \"allowed\"")
+	}
+	return v.Result(""), nil
+}
@@ -14,8 +14,16 @@
 //
 // # FFI Contract
 //
-// Each function accepts JSON-encoded data and returns a boolean result.
-// The Rust side handles deserialization and processing.
+// Each function accepts a JSON envelope (see Envelope). In envelope
+// version 1 the checks return a boolean result, with the reason for a
+// refusal in the thread's last error. From version 2 one entry point,
+// check_envelope, runs every check and returns the full verdict as a
+// check_result envelope (see CheckResponse); the version is negotiated
+// when the library is loaded, so older libraries keep working. The
+// Rust side handles deserialization and processing. Calls run
+// concurrently on a bounded pool of workers (see PoolConfig), each on an
+// OS thread of its own, so the entry points must be safe to call from
+// several threads and keep their last error per thread.
 //
 // # Build Modes
 //
@@ -26,6 +34,18 @@
 //
 //	CGO_ENABLED=1 go build -tags ffi ./...
 //
+// # Multiple Backends
+//
+// NewFusedClient layers several Backends (e.g. the Rust FFI client and
+// a RemoteBackend policy service) behind one Client and fuses their
+// verdicts, most-restrictive-wins or weighted.
+//
+// # Reloading
+//
+// Client.Reload swaps in an updated Rust library (FFI build), or
+// reconnects RemoteBackend members, while the proxy keeps serving:
+// checks in flight drain on the old engine first; see Reloadable.
+//
 // # Security Notes
 //
 //   - All security decisions are made by Rust code
@@ -34,8 +54,13 @@
 package sentinel
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/tracing"
 )
 
 // Common errors returned by sentinel checks.
@@ -109,31 +134,120 @@ type CheckResult struct {
 
 // Client provides the FFI bridge to Rust sentinel crates.
 //
-// The client is safe for concurrent use. All methods that call
-// into Rust are protected by a mutex.
+// The client is safe for concurrent use. Checks call into Rust through
+// a bounded worker pool and give up at their context's deadline, so a
+// slow check holds up only the worker running it.
 //
 // In stub mode (default build), all checks pass immediately.
-// With FFI enabled (build tag: ffi), calls route to Rust.
+// With FFI enabled (build tag: ffi), calls route to Rust, and Reload
+// can swap in an updated library.
 type Client struct {
+	// mu is held shared by each check and exclusively while Reload
+	// swaps impl, so a swap waits for checks in flight
+	mu sync.RWMutex
+
 	// impl is the actual implementation (stub or FFI)
 	impl clientImpl
+
+	// inflight counts the checks holding mu
+	inflight atomic.Int64
+
+	// open loads a replacement implementation for Reload (nil if the
+	// client has no engine of its own to replace)
+	open func(library string) (clientImpl, error)
+
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
 }
 
 // clientImpl defines the interface for sentinel implementations.
 type clientImpl interface {
-	checkRegistry(req *RegistryCheckRequest) (*CheckResult, error)
-	checkState(req *StateCheckRequest) (*CheckResult, error)
-	voteCouncil(req *CouncilVoteRequest) (*CheckResult, error)
+	protocolVersion() int
+	checkRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error)
+	checkState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error)
+	voteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error)
 }
 
-// NewClient creates a new sentinel client.
+// NewClient creates a new sentinel client with the default worker pool.
 //
 // In stub mode (default), all checks pass immediately.
 // With FFI enabled, calls route to Rust implementations.
 func NewClient() *Client {
+	return NewClientWithPool(nil)
+}
+
+// NewClientWithPool creates a sentinel client whose calls into Rust run
+// on a worker pool sized by cfg (nil uses the defaults). Libraries
+// loaded by Reload get a pool of the same size. The stub build has no
+// pool.
+func NewClientWithPool(cfg *PoolConfig) *Client {
+	var pool PoolConfig
+	if cfg != nil {
+		pool = *cfg
+	}
 	return &Client{
-		impl: newClientImpl(),
+		impl: newClientImpl(pool),
+		open: func(library string) (clientImpl, error) {
+			return openClientImpl(library, pool)
+		},
+	}
+}
+
+// acquire returns the implementation for a check, which holds it
+// against a swap until release.
+func (c *Client) acquire() clientImpl {
+	c.mu.RLock()
+	c.inflight.Add(1)
+	return c.impl
+}
+
+// release ends a check begun with acquire.
+func (c *Client) release() {
+	c.inflight.Add(-1)
+	c.mu.RUnlock()
+}
+
+// stubReporter is implemented by implementations that can say whether
+// they only pretend to check; those that do not are real.
+type stubReporter interface {
+	stub() bool
+}
+
+// Stub reports whether every check this client makes passes without
+// analysis: the default build without FFI, or layered clients whose
+// backends are all such clients.
+func (c *Client) Stub() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.impl.(stubReporter)
+	return ok && s.stub()
+}
+
+// poolReporter is implemented by implementations that call their
+// engine through a worker pool.
+type poolReporter interface {
+	poolStats() PoolStats
+}
+
+// PoolStats returns a snapshot of the worker pool calling into Rust,
+// and false if the client has none, as in the build without FFI or for
+// layered clients.
+func (c *Client) PoolStats() (PoolStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.impl.(poolReporter)
+	if !ok {
+		return PoolStats{}, false
 	}
+	return p.poolStats(), true
+}
+
+// ProtocolVersion returns the negotiated FFI envelope version, or 0 if
+// negotiation with the Rust library failed.
+func (c *Client) ProtocolVersion() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.impl.protocolVersion()
 }
 
 // CheckRegistry validates tool parameters against the schema registry.
@@ -143,14 +257,23 @@ func NewClient() *Client {
 //   - Parameters match schema
 //   - Merkle proof validates integrity
 //
+// The check is traced as a child of the span in ctx, and gives up when
+// ctx ends: waiting for a free worker (ErrNoWorker) or for the engine's
+// verdict (ErrCallAbandoned).
+//
 // # Arguments
+//   - ctx: Trace and deadline of the check
 //   - req: Registry check request with tool and params
 //
 // # Returns
 //   - CheckResult indicating pass/fail and reason
-//   - Error if FFI call fails
-func (c *Client) CheckRegistry(req *RegistryCheckRequest) (*CheckResult, error) {
-	return c.impl.checkRegistry(req)
+//   - Error if FFI call fails or ctx ends first
+func (c *Client) CheckRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	ctx, span := tracing.Start(ctx, "sentinel."+EnvelopeRegistryCheck)
+	result, err := c.acquire().checkRegistry(ctx, req)
+	c.release()
+	endCheckSpan(span, req.ToolName, result, err)
+	return result, err
 }
 
 // CheckState validates state transitions to detect cycles and gas limits.
@@ -161,13 +284,18 @@ func (c *Client) CheckRegistry(req *RegistryCheckRequest) (*CheckResult, error)
 //   - Context size within limits
 //
 // # Arguments
+//   - ctx: Trace and deadline of the check; see CheckRegistry
 //   - req: State check request with session and tool info
 //
 // # Returns
 //   - CheckResult indicating pass/fail and reason
-//   - Error if FFI call fails
-func (c *Client) CheckState(req *StateCheckRequest) (*CheckResult, error) {
-	return c.impl.checkState(req)
+//   - Error if FFI call fails or ctx ends first
+func (c *Client) CheckState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	ctx, span := tracing.Start(ctx, "sentinel."+EnvelopeStateCheck)
+	result, err := c.acquire().checkState(ctx, req)
+	c.release()
+	endCheckSpan(span, req.ToolName, result, err)
+	return result, err
 }
 
 // VoteCouncil submits an action to the Cognitive Council for voting.
@@ -178,18 +306,35 @@ func (c *Client) CheckState(req *StateCheckRequest) (*CheckResult, error) {
 //   - Multi-perspective risk assessment
 //
 // # Arguments
+//   - ctx: Trace and deadline of the vote; see CheckRegistry
 //   - req: Council vote request with action and risk info
 //
 // # Returns
 //   - CheckResult indicating approval/rejection and reason
-//   - Error if FFI call fails
-func (c *Client) VoteCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
-	return c.impl.voteCouncil(req)
+//   - Error if FFI call fails or ctx ends first
+func (c *Client) VoteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	ctx, span := tracing.Start(ctx, "sentinel."+EnvelopeCouncilVote)
+	span.SetAttribute("sentinel.risk_score", req.RiskScore)
+	result, err := c.acquire().voteCouncil(ctx, req)
+	c.release()
+	endCheckSpan(span, req.ToolName, result, err)
+	return result, err
+}
+
+// endCheckSpan records a check's outcome on its span and ends it.
+func endCheckSpan(span *tracing.Span, tool string, result *CheckResult, err error) {
+	span.SetAttribute("mcp.tool", tool)
+	if err != nil {
+		span.SetError(err)
+	} else {
+		span.SetAttribute("sentinel.allowed", result.Allowed)
+	}
+	span.End()
 }
 
 // CheckCouncil is an alias for VoteCouncil for API consistency.
-func (c *Client) CheckCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
-	return c.VoteCouncil(req)
+func (c *Client) CheckCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	return c.VoteCouncil(ctx, req)
 }
 
 // CheckAll runs all security checks in sequence.
@@ -198,6 +343,7 @@ func (c *Client) CheckCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
 // checks in order. If any check fails, it returns immediately.
 //
 // # Arguments
+//   - ctx: Trace and deadline of each check
 //   - registry: Registry check request
 //   - state: State check request
 //   - council: Council vote request (optional, nil to skip)
@@ -206,12 +352,13 @@ func (c *Client) CheckCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
 //   - Combined CheckResult
 //   - Error if any FFI call fails
 func (c *Client) CheckAll(
+	ctx context.Context,
 	registry *RegistryCheckRequest,
 	state *StateCheckRequest,
 	council *CouncilVoteRequest,
 ) (*CheckResult, error) {
 	// Check registry first
-	result, err := c.CheckRegistry(registry)
+	result, err := c.CheckRegistry(ctx, registry)
 	if err != nil {
 		return nil, err
 	}
@@ -220,7 +367,7 @@ func (c *Client) CheckAll(
 	}
 
 	// Check state
-	result, err = c.CheckState(state)
+	result, err = c.CheckState(ctx, state)
 	if err != nil {
 		return nil, err
 	}
@@ -230,7 +377,7 @@ func (c *Client) CheckAll(
 
 	// Check council if requested
 	if council != nil {
-		result, err = c.CheckCouncil(council)
+		result, err = c.CheckCouncil(ctx, council)
 		if err != nil {
 			return nil, err
 		}
@@ -5,15 +5,33 @@
 
 package sentinel
 
+import (
+	"context"
+	"fmt"
+)
+
 // stubImpl provides stub implementations that always allow.
 type stubImpl struct{}
 
-// newClientImpl returns the stub implementation.
-func newClientImpl() clientImpl {
+// newClientImpl returns the stub implementation, which has no pool.
+func newClientImpl(PoolConfig) clientImpl {
 	return &stubImpl{}
 }
 
-func (s *stubImpl) checkRegistry(req *RegistryCheckRequest) (*CheckResult, error) {
+// openClientImpl cannot load a library: the stub build has none.
+func openClientImpl(library string, _ PoolConfig) (clientImpl, error) {
+	return nil, fmt.Errorf("%w: built without the sentinel library", ErrNotReloadable)
+}
+
+func (s *stubImpl) protocolVersion() int {
+	return EnvelopeVersion
+}
+
+func (s *stubImpl) stub() bool {
+	return true
+}
+
+func (s *stubImpl) checkRegistry(_ context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
 	return &CheckResult{
 		Allowed: true,
 		Reason:  "stub: registry check bypassed",
@@ -26,7 +44,7 @@ func (s *stubImpl) checkRegistry(req *RegistryCheckRequest) (*CheckResult, error
 	}, nil
 }
 
-func (s *stubImpl) checkState(req *StateCheckRequest) (*CheckResult, error) {
+func (s *stubImpl) checkState(_ context.Context, req *StateCheckRequest) (*CheckResult, error) {
 	return &CheckResult{
 		Allowed: true,
 		Reason:  "stub: state check bypassed",
@@ -40,7 +58,7 @@ func (s *stubImpl) checkState(req *StateCheckRequest) (*CheckResult, error) {
 	}, nil
 }
 
-func (s *stubImpl) voteCouncil(req *CouncilVoteRequest) (*CheckResult, error) {
+func (s *stubImpl) voteCouncil(_ context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
 	return &CheckResult{
 		Allowed: true,
 		Reason:  "stub: council vote bypassed",
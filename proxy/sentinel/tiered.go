@@ -0,0 +1,262 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrDeepSaturated is reported for an async deep check that was dropped
+// because MaxAsync deep checks were already running.
+var ErrDeepSaturated = errors.New("sentinel: deep check queue saturated")
+
+// TierMode selects which backends answer a check.
+type TierMode string
+
+const (
+	// TierFast answers from the fast backend only (default)
+	TierFast TierMode = "fast"
+
+	// TierDeep gates on the fast backend, then waits for the deep
+	// backend; the more restrictive verdict wins
+	TierDeep TierMode = "deep"
+
+	// TierAsync answers from the fast backend and runs the deep backend
+	// in the background, reporting its verdict to OnDeepVerdict
+	TierAsync TierMode = "async"
+)
+
+// TierRule routes matching checks to a tier.
+type TierRule struct {
+	// Tools limits the rule to these tools (empty matches every tool)
+	Tools []string
+
+	// MinRisk limits the rule to checks at or above this risk score
+	MinRisk float64
+
+	// Checks limits the rule to these check types (EnvelopeRegistryCheck,
+	// EnvelopeStateCheck, EnvelopeCouncilVote); empty means all
+	Checks []string
+
+	// Mode is the tier used when the rule matches
+	Mode TierMode
+}
+
+// DeepVerdict is the outcome of an async deep check.
+type DeepVerdict struct {
+	// CheckType is the envelope type of the check
+	CheckType string
+
+	// ToolName is the tool the check was about
+	ToolName string
+
+	// SessionID is set for state checks
+	SessionID string
+
+	// Result is the deep backend's verdict (nil if Err is set)
+	Result *CheckResult
+
+	// Err is the deep backend's error or ErrDeepSaturated
+	Err error
+
+	// Latency is how long the deep backend took
+	Latency time.Duration
+}
+
+// TierConfig configures latency-tiered routing.
+type TierConfig struct {
+	// Rules are evaluated in order; the first match picks the tier
+	Rules []TierRule
+
+	// Default is the tier for checks no rule matches (default TierFast)
+	Default TierMode
+
+	// ToolRisk scores tools for registry and state checks, which carry
+	// no risk score of their own (unlisted tools score 0)
+	ToolRisk map[string]float64
+
+	// MaxAsync bounds concurrently running async deep checks; further
+	// ones are dropped and reported with ErrDeepSaturated (default 64)
+	MaxAsync int
+
+	// OnDeepVerdict receives async deep verdicts (nil discards them).
+	// It is called from the goroutine that ran the check.
+	OnDeepVerdict func(DeepVerdict)
+}
+
+// NewTieredClient creates a client that gates every check on a fast
+// backend (such as the FFI client) and consults a slower deep backend
+// (such as a RemoteBackend) only where policy asks for it.
+//
+// # Arguments
+//   - cfg: Tier policy (nil answers every check from fast alone)
+//   - fast: Low-latency backend consulted for every check
+//   - deep: Slower backend for deep analysis
+//
+// # Returns
+//   - Client usable anywhere a single-backend client is
+//
+// # Security Notes
+//
+// A fast-tier block or error is final; the deep backend can only
+// tighten a verdict, never loosen it. TierAsync lets a call through
+// before deep analysis finishes, so use it for risk bands where
+// detecting abuse after the fact (for example by feeding OnDeepVerdict
+// into anomaly scoring) is acceptable.
+func NewTieredClient(cfg *TierConfig, fast, deep Backend) *Client {
+	t := &tieredImpl{fast: fast, deep: deep}
+	if cfg != nil {
+		t.cfg = *cfg
+	}
+	if t.cfg.Default == "" {
+		t.cfg.Default = TierFast
+	}
+	if t.cfg.MaxAsync <= 0 {
+		t.cfg.MaxAsync = 64
+	}
+	t.async = make(chan struct{}, t.cfg.MaxAsync)
+	return &Client{impl: t}
+}
+
+// tieredImpl routes checks between a fast and a deep backend.
+type tieredImpl struct {
+	cfg   TierConfig
+	fast  Backend
+	deep  Backend
+	async chan struct{}
+}
+
+func (t *tieredImpl) stub() bool {
+	for _, b := range []Backend{t.fast, t.deep} {
+		if c, ok := b.(*Client); b != nil && (!ok || !c.Stub()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tieredImpl) protocolVersion() int {
+	version := EnvelopeVersion
+	for _, b := range []Backend{t.fast, t.deep} {
+		if c, ok := b.(*Client); ok && c.ProtocolVersion() < version {
+			version = c.ProtocolVersion()
+		}
+	}
+	return version
+}
+
+// reload reloads the fast and deep backends that are Reloadable.
+func (t *tieredImpl) reload(ctx context.Context, library string) (*ReloadReport, error) {
+	return reloadMembers(ctx, library, []string{"fast", "deep"}, []Backend{t.fast, t.deep})
+}
+
+func (t *tieredImpl) checkRegistry(ctx context.Context, req *RegistryCheckRequest) (*CheckResult, error) {
+	return t.route(ctx, EnvelopeRegistryCheck, req.ToolName, "", t.cfg.ToolRisk[req.ToolName], func(ctx context.Context, b Backend) (*CheckResult, error) {
+		return b.CheckRegistry(ctx, req)
+	})
+}
+
+func (t *tieredImpl) checkState(ctx context.Context, req *StateCheckRequest) (*CheckResult, error) {
+	return t.route(ctx, EnvelopeStateCheck, req.ToolName, req.SessionID, t.cfg.ToolRisk[req.ToolName], func(ctx context.Context, b Backend) (*CheckResult, error) {
+		return b.CheckState(ctx, req)
+	})
+}
+
+func (t *tieredImpl) voteCouncil(ctx context.Context, req *CouncilVoteRequest) (*CheckResult, error) {
+	risk := max(req.RiskScore, t.cfg.ToolRisk[req.ToolName])
+	return t.route(ctx, EnvelopeCouncilVote, req.ToolName, "", risk, func(ctx context.Context, b Backend) (*CheckResult, error) {
+		return b.VoteCouncil(ctx, req)
+	})
+}
+
+// tier returns the mode for a check.
+func (t *tieredImpl) tier(checkType, tool string, risk float64) TierMode {
+	for _, rule := range t.cfg.Rules {
+		if len(rule.Tools) > 0 && !slices.Contains(rule.Tools, tool) {
+			continue
+		}
+		if len(rule.Checks) > 0 && !slices.Contains(rule.Checks, checkType) {
+			continue
+		}
+		if risk < rule.MinRisk {
+			continue
+		}
+		return rule.Mode
+	}
+	return t.cfg.Default
+}
+
+// route gates on the fast backend and then consults the deep backend
+// according to the check's tier.
+func (t *tieredImpl) route(ctx context.Context, checkType, tool, session string, risk float64, check func(context.Context, Backend) (*CheckResult, error)) (*CheckResult, error) {
+	mode := t.tier(checkType, tool, risk)
+	if t.deep == nil {
+		mode = TierFast
+	}
+
+	fast, err := check(ctx, t.fast)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel: fast tier: %w", err)
+	}
+	if !fast.Allowed || mode == TierFast {
+		return withTier(fast, TierFast), nil
+	}
+
+	if mode == TierAsync {
+		t.runAsync(ctx, DeepVerdict{CheckType: checkType, ToolName: tool, SessionID: session}, check)
+		return withTier(fast, TierAsync), nil
+	}
+
+	start := time.Now()
+	deep, err := check(ctx, t.deep)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel: deep tier: %w", err)
+	}
+	result := deep
+	if deep.Allowed {
+		result = &CheckResult{Allowed: true, Reason: fast.Reason, Details: fast.Details}
+	}
+	result = withTier(result, TierDeep)
+	result.Details["deep_latency_ms"] = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// runAsync starts a deep check in the background unless MaxAsync are
+// already running. The check keeps the trace in ctx but outlives its
+// deadline, since the caller has its answer.
+func (t *tieredImpl) runAsync(ctx context.Context, v DeepVerdict, check func(context.Context, Backend) (*CheckResult, error)) {
+	ctx = context.WithoutCancel(ctx)
+	select {
+	case t.async <- struct{}{}:
+	default:
+		v.Err = ErrDeepSaturated
+		t.report(v)
+		return
+	}
+	go func() {
+		defer func() { <-t.async }()
+		start := time.Now()
+		v.Result, v.Err = check(ctx, t.deep)
+		v.Latency = time.Since(start)
+		t.report(v)
+	}()
+}
+
+// report delivers an async verdict to the configured callback.
+func (t *tieredImpl) report(v DeepVerdict) {
+	if t.cfg.OnDeepVerdict != nil {
+		t.cfg.OnDeepVerdict(v)
+	}
+}
+
+// withTier returns a copy of result whose details record the tier.
+func withTier(result *CheckResult, mode TierMode) *CheckResult {
+	details := make(map[string]interface{}, len(result.Details)+1)
+	for k, v := range result.Details {
+		details[k] = v
+	}
+	details["tier"] = string(mode)
+	return &CheckResult{Allowed: result.Allowed, Reason: result.Reason, Details: details}
+}
@@ -0,0 +1,187 @@
+package sentinel
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend wraps a fixed verdict and counts calls.
+type countingBackend struct {
+	fixedBackend
+	calls atomic.Int32
+}
+
+func (b *countingBackend) CheckRegistry(ctx context.Context, r *RegistryCheckRequest) (*CheckResult, error) {
+	b.calls.Add(1)
+	return b.fixedBackend.CheckRegistry(ctx, r)
+}
+
+func (b *countingBackend) CheckState(ctx context.Context, r *StateCheckRequest) (*CheckResult, error) {
+	b.calls.Add(1)
+	return b.fixedBackend.CheckState(ctx, r)
+}
+
+func (b *countingBackend) VoteCouncil(ctx context.Context, r *CouncilVoteRequest) (*CheckResult, error) {
+	b.calls.Add(1)
+	return b.fixedBackend.VoteCouncil(ctx, r)
+}
+
+func TestTieredClient_Routing(t *testing.T) {
+	cfg := &TierConfig{
+		Rules: []TierRule{
+			{Tools: []string{"read_file"}, Mode: TierFast},
+			{MinRisk: 0.9, Mode: TierDeep},
+			{MinRisk: 0.5, Checks: []string{EnvelopeCouncilVote}, Mode: TierAsync},
+		},
+		ToolRisk: map[string]float64{"execute_command": 0.95, "read_file": 0.95},
+	}
+
+	tests := []struct {
+		name        string
+		fastAllowed bool
+		deepAllowed bool
+		check       func(c *Client) (*CheckResult, error)
+		allowed     bool
+		tier        TierMode
+		deepCalled  bool
+	}{
+		{
+			name:        "low risk stays fast",
+			fastAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "list_directory"})
+			},
+			allowed: true,
+			tier:    TierFast,
+		},
+		{
+			name:        "tool rule overrides risk",
+			fastAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.CheckState(context.Background(), &StateCheckRequest{ToolName: "read_file"})
+			},
+			allowed: true,
+			tier:    TierFast,
+		},
+		{
+			name:        "high risk tool waits for deep",
+			fastAllowed: true,
+			deepAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "execute_command"})
+			},
+			allowed:    true,
+			tier:       TierDeep,
+			deepCalled: true,
+		},
+		{
+			name:        "deep block wins",
+			fastAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "execute_command"})
+			},
+			allowed:    false,
+			tier:       TierDeep,
+			deepCalled: true,
+		},
+		{
+			name:        "fast block skips deep",
+			deepAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.CheckRegistry(context.Background(), &RegistryCheckRequest{ToolName: "execute_command"})
+			},
+			allowed: false,
+			tier:    TierFast,
+		},
+		{
+			name:        "medium risk council runs async",
+			fastAllowed: true,
+			check: func(c *Client) (*CheckResult, error) {
+				return c.VoteCouncil(context.Background(), &CouncilVoteRequest{ToolName: "write_file", RiskScore: 0.7})
+			},
+			allowed:    true,
+			tier:       TierAsync,
+			deepCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deep := &countingBackend{fixedBackend: fixedBackend{allowed: tt.deepAllowed}}
+			verdicts := make(chan DeepVerdict, 1)
+			tc := *cfg
+			tc.OnDeepVerdict = func(v DeepVerdict) { verdicts <- v }
+			c := NewTieredClient(&tc, &fixedBackend{allowed: tt.fastAllowed}, deep)
+
+			result, err := tt.check(c)
+			if err != nil {
+				t.Fatalf("check failed: %v", err)
+			}
+			if result.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, expected %v", result.Allowed, tt.allowed)
+			}
+			if result.Details["tier"] != string(tt.tier) {
+				t.Errorf("tier = %v, expected %s", result.Details["tier"], tt.tier)
+			}
+			if tt.tier == TierAsync {
+				select {
+				case v := <-verdicts:
+					if v.Err != nil || v.ToolName != "write_file" {
+						t.Errorf("unexpected async verdict: %+v", v)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatal("async deep verdict not reported")
+				}
+			}
+			if called := deep.calls.Load() > 0; called != tt.deepCalled {
+				t.Errorf("deep called = %v, expected %v", called, tt.deepCalled)
+			}
+		})
+	}
+}
+
+func TestTieredClient_AsyncSaturation(t *testing.T) {
+	release := make(chan struct{})
+	deep := &blockingBackend{release: release}
+	verdicts := make(chan DeepVerdict, 4)
+	c := NewTieredClient(&TierConfig{
+		Default:       TierAsync,
+		MaxAsync:      1,
+		OnDeepVerdict: func(v DeepVerdict) { verdicts <- v },
+	}, NewClient(), deep)
+
+	for i := 0; i < 2; i++ {
+		if r, err := c.CheckState(context.Background(), &StateCheckRequest{SessionID: "s", ToolName: "x"}); err != nil || !r.Allowed {
+			t.Fatalf("fast tier should answer immediately: %v, %v", r, err)
+		}
+	}
+	if v := <-verdicts; v.Err != ErrDeepSaturated || v.SessionID != "s" {
+		t.Errorf("expected saturation report, got %+v", v)
+	}
+	close(release)
+	if v := <-verdicts; v.Err != nil || !v.Result.Allowed {
+		t.Errorf("expected completed deep verdict, got %+v", v)
+	}
+}
+
+// blockingBackend allows every check once release is closed.
+type blockingBackend struct {
+	release chan struct{}
+}
+
+func (b *blockingBackend) verdict() (*CheckResult, error) {
+	<-b.release
+	return &CheckResult{Allowed: true, Reason: "released"}, nil
+}
+
+func (b *blockingBackend) CheckRegistry(context.Context, *RegistryCheckRequest) (*CheckResult, error) {
+	return b.verdict()
+}
+func (b *blockingBackend) CheckState(context.Context, *StateCheckRequest) (*CheckResult, error) {
+	return b.verdict()
+}
+func (b *blockingBackend) VoteCouncil(context.Context, *CouncilVoteRequest) (*CheckResult, error) {
+	return b.verdict()
+}
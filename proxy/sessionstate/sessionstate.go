@@ -0,0 +1,257 @@
+// Package sessionstate persists the security context of proxy sessions.
+//
+// A session accumulates state that its checks depend on: the tools it
+// called (for cycle detection), the gas it used, the fingerprints of
+// the tools its server listed, and whether the kill-switch ended it.
+// Kept only in memory, that context resets whenever the proxy restarts,
+// which a misbehaving client could provoke to start over with a fresh
+// budget. A Store saves it under a stable key so the next session with
+// the same key resumes where the last one stopped.
+//
+// # Backends
+//
+//   - NewMemoryStore: survives sessions within one process
+//   - OpenFileStore: a JSON file written atomically, survives restarts
+//
+// The module depends on the standard library only, so there are no
+// database backends; the file store covers a single proxy instance,
+// and other backends can implement Store.
+//
+// Tool approvals are not session state: trust-on-first-use approvals
+// persist through the TOFU store's own file (see package tofu).
+//
+// # Thread Safety
+//
+// Both stores are safe for concurrent use.
+package sessionstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by stores.
+var (
+	ErrNotFound     = errors.New("sessionstate: no saved state")
+	ErrInvalidKey   = errors.New("sessionstate: key is required")
+	ErrInvalidStore = errors.New("sessionstate: invalid state file")
+)
+
+// fileVersion is the current state file format version.
+const fileVersion = 1
+
+// State is the security context saved for a session key.
+type State struct {
+	// Session is the ID of the session that saved the state
+	Session string `json:"session"`
+
+	// PreviousTools lists the tools called, oldest first
+	PreviousTools []string `json:"previous_tools,omitempty"`
+
+	// GasUsed is the gas consumed against the session budget
+	GasUsed uint64 `json:"gas_used"`
+
+	// Server is the server identity the pins belong to
+	Server string `json:"server,omitempty"`
+
+	// Pins maps the tools the server listed to their fingerprints
+	Pins map[string]string `json:"pins,omitempty"`
+
+	// Terminated records that the kill-switch ended the session
+	Terminated bool `json:"terminated,omitempty"`
+
+	// Updated is when the state was saved
+	Updated time.Time `json:"updated"`
+}
+
+// clone returns a deep copy of s.
+func (s *State) clone() *State {
+	c := *s
+	c.PreviousTools = append([]string(nil), s.PreviousTools...)
+	if s.Pins != nil {
+		c.Pins = make(map[string]string, len(s.Pins))
+		for tool, fp := range s.Pins {
+			c.Pins[tool] = fp
+		}
+	}
+	return &c
+}
+
+// Store loads and saves session state by key.
+//
+// # Thread Safety
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns the state saved under key, or ErrNotFound
+	Load(key string) (*State, error)
+
+	// Save replaces the state saved under key
+	Save(key string, s *State) error
+
+	// Delete forgets the state saved under key
+	Delete(key string) error
+
+	// Keys lists the keys with saved state, sorted
+	Keys() []string
+}
+
+// MemoryStore keeps state in memory.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]*State
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]*State)}
+}
+
+// Load returns the state saved under key.
+func (m *MemoryStore) Load(key string) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.states[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.clone(), nil
+}
+
+// Save replaces the state saved under key.
+func (m *MemoryStore) Save(key string, s *State) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[key] = s.clone()
+	return nil
+}
+
+// Delete forgets the state saved under key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, key)
+	return nil
+}
+
+// Keys lists the keys with saved state.
+func (m *MemoryStore) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.states))
+	for key := range m.states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// file is the on-disk format of a FileStore.
+type file struct {
+	Version  int               `json:"version"`
+	Sessions map[string]*State `json:"sessions"`
+}
+
+// FileStore keeps state in memory and writes all of it to a JSON file
+// on every change.
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+
+	// writeMu serializes writes so the file always holds the latest
+	// state
+	writeMu sync.Mutex
+}
+
+// OpenFileStore opens the state file at path, which need not exist
+// yet.
+//
+// # Returns
+//   - The FileStore
+//   - ErrInvalidStore if the file does not parse
+func OpenFileStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, mem: NewMemoryStore()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sessionstate: read %s: %w", path, err)
+	}
+	var saved file
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidStore, path, err)
+	}
+	if saved.Version != fileVersion {
+		return nil, fmt.Errorf("%w: %s: version %d, expected %d", ErrInvalidStore, path, saved.Version, fileVersion)
+	}
+	for key, s := range saved.Sessions {
+		if key == "" || s == nil {
+			return nil, fmt.Errorf("%w: %s: empty session entry", ErrInvalidStore, path)
+		}
+		f.mem.states[key] = s
+	}
+	return f, nil
+}
+
+// Load returns the state saved under key.
+func (f *FileStore) Load(key string) (*State, error) {
+	return f.mem.Load(key)
+}
+
+// Save replaces the state saved under key and writes the file.
+func (f *FileStore) Save(key string, s *State) error {
+	if err := f.mem.Save(key, s); err != nil {
+		return err
+	}
+	return f.write()
+}
+
+// Delete forgets the state saved under key and writes the file.
+func (f *FileStore) Delete(key string) error {
+	f.mem.Delete(key)
+	return f.write()
+}
+
+// Keys lists the keys with saved state.
+func (f *FileStore) Keys() []string {
+	return f.mem.Keys()
+}
+
+// write replaces the file atomically with the current state.
+func (f *FileStore) write() error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+
+	f.mem.mu.Lock()
+	data, err := json.MarshalIndent(file{Version: fileVersion, Sessions: f.mem.states}, "", "  ")
+	f.mem.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("sessionstate: encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".sessionstate-*")
+	if err != nil {
+		return fmt.Errorf("sessionstate: save state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sessionstate: save state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("sessionstate: save state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("sessionstate: save state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package sessionstate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	file, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore failed: %v", err)
+	}
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"memory", NewMemoryStore()},
+		{"file", file},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store
+			if _, err := s.Load("a"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Load of a missing key: %v, expected ErrNotFound", err)
+			}
+			if err := s.Save("", &State{}); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Save without a key: %v, expected ErrInvalidKey", err)
+			}
+
+			saved := &State{Session: "s1", PreviousTools: []string{"read"}, GasUsed: 40, Pins: map[string]string{"read": "fp"}}
+			if err := s.Save("a", saved); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			// The store keeps a copy
+			saved.PreviousTools[0] = "changed"
+			saved.Pins["read"] = "changed"
+
+			got, err := s.Load("a")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			want := &State{Session: "s1", PreviousTools: []string{"read"}, GasUsed: 40, Pins: map[string]string{"read": "fp"}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load = %+v, expected %+v", got, want)
+			}
+
+			s.Save("b", &State{Session: "s2"})
+			if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+				t.Errorf("Keys = %v", keys)
+			}
+			if err := s.Delete("a"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, err := s.Load("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Load after Delete: %v, expected ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	f, _ := OpenFileStore(path)
+	if err := f.Save("proxy", &State{Session: "s1", GasUsed: 700, Terminated: true}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore failed: %v", err)
+	}
+	got, err := reopened.Load("proxy")
+	if err != nil || got.GasUsed != 700 || !got.Terminated {
+		t.Errorf("Load after reopen = %+v, %v", got, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("state file mode = %v, %v, expected 0600", info.Mode(), err)
+	}
+}
+
+func TestOpenFileStore_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", "{"},
+		{"wrong version", `{"version":9,"sessions":{}}`},
+		{"empty entry", `{"version":1,"sessions":{"a":null}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			os.WriteFile(path, []byte(tt.data), 0o600)
+			if _, err := OpenFileStore(path); !errors.Is(err, ErrInvalidStore) {
+				t.Errorf("OpenFileStore = %v, expected ErrInvalidStore", err)
+			}
+		})
+	}
+}
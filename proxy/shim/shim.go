@@ -0,0 +1,309 @@
+// Package shim translates between MCP protocol revisions.
+//
+// A client and a server negotiate one protocol revision in initialize.
+// When they support different revisions the session would normally
+// fail; with a shim the proxy accepts the client's revision on the
+// server's behalf and translates traffic in both directions. Features
+// the receiving side does not understand are stripped or downgraded to
+// plain text instead of causing errors.
+//
+// # Supported Revisions
+//
+//   - 2024-11-05
+//   - 2025-03-26: tool annotations, audio content, completions capability
+//   - 2025-06-18: titles, structured tool output, resource links,
+//     elicitation
+//
+// # Thread Safety
+//
+// Shim is immutable and safe for concurrent use.
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// ErrUnknownRevision is returned for protocol revisions the shim cannot translate.
+var ErrUnknownRevision = errors.New("shim: unknown protocol revision")
+
+// Revision is an MCP protocol revision string.
+type Revision string
+
+// Known protocol revisions, oldest first.
+const (
+	Rev20241105 Revision = "2024-11-05"
+	Rev20250326 Revision = "2025-03-26"
+	Rev20250618 Revision = "2025-06-18"
+)
+
+// Revisions lists the revisions the shim can translate between, oldest first.
+var Revisions = []Revision{Rev20241105, Rev20250326, Rev20250618}
+
+// Feature is a protocol feature introduced by a revision.
+type Feature string
+
+// Features the shim translates.
+const (
+	FeatureToolAnnotations  Feature = "tool_annotations"
+	FeatureAudioContent     Feature = "audio_content"
+	FeatureCompletions      Feature = "completions"
+	FeatureTitles           Feature = "titles"
+	FeatureStructuredOutput Feature = "structured_output"
+	FeatureResourceLinks    Feature = "resource_links"
+	FeatureElicitation      Feature = "elicitation"
+)
+
+// introducedIn maps each feature to the revision that added it.
+var introducedIn = map[Feature]Revision{
+	FeatureToolAnnotations:  Rev20250326,
+	FeatureAudioContent:     Rev20250326,
+	FeatureCompletions:      Rev20250326,
+	FeatureTitles:           Rev20250618,
+	FeatureStructuredOutput: Rev20250618,
+	FeatureResourceLinks:    Rev20250618,
+	FeatureElicitation:      Rev20250618,
+}
+
+// Known reports whether rev is a revision the shim can translate.
+func Known(rev Revision) bool {
+	return index(rev) >= 0
+}
+
+// Supports reports whether rev includes feature.
+func Supports(rev Revision, feature Feature) bool {
+	return index(rev) >= index(introducedIn[feature])
+}
+
+func index(rev Revision) int {
+	for i, r := range Revisions {
+		if r == rev {
+			return i
+		}
+	}
+	return -1
+}
+
+// Shim translates one session between a client and a server revision.
+type Shim struct {
+	client Revision
+	server Revision
+}
+
+// New creates a shim for a client and server revision.
+//
+// # Returns
+//   - Shim (Active reports false when the revisions match)
+//   - ErrUnknownRevision if either revision cannot be translated
+func New(client, server Revision) (*Shim, error) {
+	if !Known(client) {
+		return nil, fmt.Errorf("%w: client %q", ErrUnknownRevision, client)
+	}
+	if !Known(server) {
+		return nil, fmt.Errorf("%w: server %q", ErrUnknownRevision, server)
+	}
+	return &Shim{client: client, server: server}, nil
+}
+
+// Client returns the client's revision.
+func (s *Shim) Client() Revision { return s.client }
+
+// Server returns the server's revision.
+func (s *Shim) Server() Revision { return s.server }
+
+// Active reports whether the revisions differ and translation is needed.
+func (s *Shim) Active() bool {
+	return s.client != s.server
+}
+
+// Request adapts a client request for the server. Requests the server's
+// revision cannot serve are answered locally with an empty result.
+//
+// # Returns
+//   - A local reply to send instead of forwarding (nil to forward)
+//   - Error if the reply cannot be built
+func (s *Shim) Request(msg *jsonrpc.Message) (*jsonrpc.Message, error) {
+	// An older server has no completions; answer with no suggestions
+	// rather than letting it fail with MethodNotFound
+	if msg.Method == "completion/complete" && !Supports(s.server, FeatureCompletions) {
+		reply, err := jsonrpc.NewResponse(msg.ID, map[string]interface{}{
+			"completion": map[string]interface{}{"values": []string{}},
+		})
+		return reply, err
+	}
+	return nil, nil
+}
+
+// Response adapts a server result for the client.
+//
+// # Arguments
+//   - method: Method of the request this message answers
+//   - msg: Server response (errors are returned unchanged)
+//
+// # Returns
+//   - Whether msg was modified
+//   - Error if the result cannot be decoded
+func (s *Shim) Response(method string, msg *jsonrpc.Message) (bool, error) {
+	if msg.Error != nil || len(msg.Result) == 0 {
+		return false, nil
+	}
+	var result object
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return false, fmt.Errorf("shim: decode %s result: %w", method, err)
+	}
+
+	var changed bool
+	switch method {
+	case "initialize":
+		changed = s.initializeResult(result)
+	case "tools/list":
+		changed = s.eachItem(result, "tools", s.tool)
+	case "tools/call":
+		changed = s.callToolResult(result)
+	case "resources/list":
+		changed = s.eachItem(result, "resources", s.stripTitle)
+	case "resources/templates/list":
+		changed = s.eachItem(result, "resourceTemplates", s.stripTitle)
+	case "prompts/list":
+		changed = s.eachItem(result, "prompts", s.stripTitle)
+	case "prompts/get":
+		changed = s.eachItem(result, "messages", func(m object) bool {
+			return s.contentField(m, "content")
+		})
+	}
+	if !changed {
+		return false, nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return false, fmt.Errorf("shim: encode %s result: %w", method, err)
+	}
+	msg.Result = data
+	return true, nil
+}
+
+// object is a JSON object with raw member values, preserving fields the
+// shim does not touch.
+type object map[string]json.RawMessage
+
+// initializeResult presents the client's revision and hides server
+// capabilities the client cannot use.
+func (s *Shim) initializeResult(result object) bool {
+	result["protocolVersion"], _ = json.Marshal(s.client)
+
+	var caps object
+	if json.Unmarshal(result["capabilities"], &caps) == nil && caps != nil {
+		if !Supports(s.client, FeatureCompletions) {
+			delete(caps, "completions")
+		}
+		result["capabilities"], _ = json.Marshal(caps)
+	}
+	var info object
+	if json.Unmarshal(result["serverInfo"], &info) == nil && info != nil && s.stripTitle(info) {
+		result["serverInfo"], _ = json.Marshal(info)
+	}
+	return true
+}
+
+// tool strips tool fields the client does not understand.
+func (s *Shim) tool(t object) bool {
+	changed := s.stripTitle(t)
+	if !Supports(s.client, FeatureToolAnnotations) && t["annotations"] != nil {
+		delete(t, "annotations")
+		changed = true
+	}
+	if !Supports(s.client, FeatureStructuredOutput) && t["outputSchema"] != nil {
+		delete(t, "outputSchema")
+		changed = true
+	}
+	return changed
+}
+
+// callToolResult strips structured output and downgrades content.
+func (s *Shim) callToolResult(result object) bool {
+	changed := false
+	if !Supports(s.client, FeatureStructuredOutput) && result["structuredContent"] != nil {
+		delete(result, "structuredContent")
+		changed = true
+	}
+	var content []object
+	if json.Unmarshal(result["content"], &content) != nil {
+		return changed
+	}
+	itemsChanged := false
+	for i := range content {
+		if s.contentBlock(content[i]) {
+			itemsChanged = true
+		}
+	}
+	if itemsChanged {
+		result["content"], _ = json.Marshal(content)
+	}
+	return changed || itemsChanged
+}
+
+// contentField downgrades a single content block stored under key.
+func (s *Shim) contentField(parent object, key string) bool {
+	var block object
+	if json.Unmarshal(parent[key], &block) != nil || block == nil || !s.contentBlock(block) {
+		return false
+	}
+	parent[key], _ = json.Marshal(block)
+	return true
+}
+
+// contentBlock replaces content types the client cannot render with
+// text describing what was omitted.
+func (s *Shim) contentBlock(block object) bool {
+	var typ string
+	json.Unmarshal(block["type"], &typ)
+
+	var text string
+	switch {
+	case typ == "audio" && !Supports(s.client, FeatureAudioContent):
+		text = "[audio content omitted: not supported by client protocol revision]"
+	case typ == "resource_link" && !Supports(s.client, FeatureResourceLinks):
+		var uri string
+		json.Unmarshal(block["uri"], &uri)
+		text = fmt.Sprintf("[resource link: %s]", uri)
+	default:
+		return false
+	}
+	for k := range block {
+		delete(block, k)
+	}
+	block["type"], _ = json.Marshal("text")
+	block["text"], _ = json.Marshal(text)
+	return true
+}
+
+// stripTitle removes title fields from clients predating them.
+func (s *Shim) stripTitle(o object) bool {
+	if Supports(s.client, FeatureTitles) || o["title"] == nil {
+		return false
+	}
+	delete(o, "title")
+	return true
+}
+
+// eachItem applies fn to every object in the array result[key].
+func (s *Shim) eachItem(result object, key string, fn func(object) bool) bool {
+	var items []object
+	if json.Unmarshal(result[key], &items) != nil {
+		return false
+	}
+	changed := false
+	for _, item := range items {
+		if item != nil && fn(item) {
+			changed = true
+		}
+	}
+	if changed {
+		result[key], _ = json.Marshal(items)
+	}
+	return changed
+}
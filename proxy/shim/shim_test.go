@@ -0,0 +1,76 @@
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+func TestSupports(t *testing.T) {
+	if Supports(Rev20241105, FeatureToolAnnotations) {
+		t.Error("2024-11-05 predates tool annotations")
+	}
+	if !Supports(Rev20250618, FeatureToolAnnotations) {
+		t.Error("2025-06-18 includes tool annotations")
+	}
+	if _, err := New("1999-01-01", Rev20250618); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision, got %v", err)
+	}
+}
+
+func TestShim_Response(t *testing.T) {
+	s, _ := New(Rev20241105, Rev20250618)
+
+	tests := []struct {
+		method   string
+		result   string
+		contains []string
+		absent   []string
+	}{
+		{"initialize",
+			`{"protocolVersion":"2025-06-18","capabilities":{"completions":{},"tools":{}},"serverInfo":{"name":"fs","title":"Files","version":"1"}}`,
+			[]string{`"protocolVersion":"2024-11-05"`, `"tools":{}`}, []string{"completions", "title"}},
+		{"tools/list",
+			`{"tools":[{"name":"rm","title":"Remove","inputSchema":{},"outputSchema":{},"annotations":{"destructiveHint":true},"x-vendor":1}]}`,
+			[]string{`"name":"rm"`, `"x-vendor":1`}, []string{"title", "outputSchema", "annotations"}},
+		{"tools/call",
+			`{"content":[{"type":"audio","data":"AA==","mimeType":"audio/wav"},{"type":"resource_link","uri":"file:///a","name":"a"}],"structuredContent":{"n":1}}`,
+			[]string{"[audio content omitted", "[resource link: file:///a]"}, []string{"structuredContent", `"data"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			msg := &jsonrpc.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Result: json.RawMessage(tt.result)}
+			changed, err := s.Response(tt.method, msg)
+			if err != nil || !changed {
+				t.Fatalf("Response = %v, %v", changed, err)
+			}
+			got := string(msg.Result)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("result %s missing %s", got, want)
+				}
+			}
+			for _, bad := range tt.absent {
+				if strings.Contains(got, bad) {
+					t.Errorf("result %s should not contain %s", got, bad)
+				}
+			}
+		})
+	}
+}
+
+func TestShim_CompletionOnOldServer(t *testing.T) {
+	s, _ := New(Rev20250618, Rev20241105)
+	req, _ := jsonrpc.NewRequest("completion/complete", map[string]interface{}{}, 7)
+	reply, err := s.Request(req)
+	if err != nil || reply == nil {
+		t.Fatalf("expected local reply, got %v, %v", reply, err)
+	}
+	if string(reply.ID) != "7" || !strings.Contains(string(reply.Result), `"values":[]`) {
+		t.Errorf("unexpected reply: %s", reply.Result)
+	}
+}
@@ -0,0 +1,456 @@
+// Package slo evaluates service level objectives for the proxy itself.
+//
+// The security layer sits on every request's path, so its cost to the
+// user is the latency it adds and the calls it refuses or fails. An
+// Objective states how much of either is acceptable, e.g. "tools/call
+// adds under 500ms at p95" or "under 0.1% of messages end in a block or
+// error". The router reports each routed message to a Monitor, which
+// keeps per-objective counts of good and bad events in time buckets.
+//
+// # Burn Rate Alerts
+//
+// An objective's error budget is the fraction of events allowed to be
+// bad (1 - Target). The burn rate over a window is the observed bad
+// fraction divided by the budget: a rate of 1 spends the budget exactly
+// over the SLO period, a rate of 14.4 spends a 30-day budget in about
+// two days. Each BurnWindow fires when both its long and short windows
+// burn faster than its Rate, so an alert needs a sustained problem but
+// clears soon after it ends. Alerts are sent on firing and on
+// resolution, to the log, OnAlert, and an optional webhook.
+//
+// # Thread Safety
+//
+// Monitor is safe for concurrent use and is usually shared by all
+// sessions.
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Configuration errors.
+var (
+	ErrNoObjectives     = errors.New("slo: no objectives")
+	ErrInvalidObjective = errors.New("slo: invalid objective")
+	ErrInvalidWindow    = errors.New("slo: invalid burn window")
+)
+
+// DefaultInterval is how often a running Monitor evaluates burn rates.
+const DefaultInterval = 30 * time.Second
+
+// DefaultMinEvents is the number of events a short window needs before
+// it can fire, so a single slow call at night does not page anyone.
+const DefaultMinEvents = 10
+
+// bucketsPerShortWindow sets the count resolution: each bucket spans
+// this fraction of the shortest short window.
+const bucketsPerShortWindow = 10
+
+// Objective is one service level objective.
+//
+// A latency objective (Latency > 0) counts events slower than Latency
+// as bad; Target 0.95 then reads "p95 added latency under Latency". A
+// verdict objective (Verdicts set) counts events with one of the
+// verdicts as bad; Target 0.999 reads "under 0.1% of events".
+type Objective struct {
+	// Name identifies the objective in alerts and metrics
+	Name string `json:"name"`
+
+	// Method restricts the objective to one JSON-RPC method (empty
+	// covers every method)
+	Method string `json:"method,omitempty"`
+
+	// Latency is the added latency above which an event is bad
+	Latency time.Duration `json:"latency,omitempty"`
+
+	// Verdicts are the routing verdicts that make an event bad, e.g.
+	// "blocked" and "error"
+	Verdicts []string `json:"verdicts,omitempty"`
+
+	// Target is the fraction of events that must be good, in (0, 1)
+	Target float64 `json:"target"`
+}
+
+// budget returns the fraction of events allowed to be bad.
+func (o *Objective) budget() float64 {
+	return 1 - o.Target
+}
+
+// BurnWindow is a multi-window burn rate alert condition.
+type BurnWindow struct {
+	// Long is the window that must show a sustained burn
+	Long time.Duration `json:"long"`
+
+	// Short is the window that must still be burning, so the alert
+	// resolves soon after the problem ends
+	Short time.Duration `json:"short"`
+
+	// Rate is the burn rate both windows must reach
+	Rate float64 `json:"rate"`
+}
+
+// String names the window in logs and metric labels, e.g. "1h0m0s/5m0s".
+func (w BurnWindow) String() string {
+	return w.Long.String() + "/" + w.Short.String()
+}
+
+// DefaultWindows returns the usual fast and slow burn alerts for a
+// 30-day budget: 2% of it spent within an hour, or 5% within six hours.
+func DefaultWindows() []BurnWindow {
+	return []BurnWindow{
+		{Long: time.Hour, Short: 5 * time.Minute, Rate: 14.4},
+		{Long: 6 * time.Hour, Short: 30 * time.Minute, Rate: 6},
+	}
+}
+
+// Config contains monitor configuration.
+type Config struct {
+	// Objectives are the objectives to evaluate
+	Objectives []Objective
+
+	// Windows are the alert conditions applied to every objective (nil
+	// uses DefaultWindows)
+	Windows []BurnWindow
+
+	// Interval is how often Run evaluates (zero uses DefaultInterval)
+	Interval time.Duration
+
+	// MinEvents is the number of events a short window needs before it
+	// can fire (zero uses DefaultMinEvents)
+	MinEvents int
+
+	// Webhook receives each alert as a JSON POST (empty disables)
+	Webhook string
+
+	// OnAlert is called with each alert (nil only logs)
+	OnAlert func(Alert)
+}
+
+// Observation is one routed message.
+type Observation struct {
+	// Method is the JSON-RPC method
+	Method string
+
+	// Verdict is the routing verdict
+	Verdict string
+
+	// AddedLatency is the time the proxy spent on the message, not
+	// counting time waiting on the server
+	AddedLatency time.Duration
+}
+
+// Alert reports an objective starting or stopping to burn its budget
+// too fast.
+type Alert struct {
+	Objective string    `json:"objective"`
+	Window    string    `json:"window"`
+	Firing    bool      `json:"firing"`
+	BurnRate  float64   `json:"burn_rate"`
+	ShortRate float64   `json:"short_burn_rate"`
+	Threshold float64   `json:"threshold"`
+	Budget    float64   `json:"error_budget"`
+	Time      time.Time `json:"time"`
+}
+
+// String formats the alert for the log.
+func (a Alert) String() string {
+	state := "resolved"
+	if a.Firing {
+		state = "firing"
+	}
+	return fmt.Sprintf("SLO %s %s over %s: burn rate %.2f (short %.2f, threshold %.2f, budget %.4g)",
+		a.Objective, state, a.Window, a.BurnRate, a.ShortRate, a.Threshold, a.Budget)
+}
+
+// Status is an objective's current state.
+type Status struct {
+	Objective Objective    `json:"objective"`
+	Windows   []WindowRate `json:"windows"`
+}
+
+// WindowRate is the burn rate of one alert window.
+type WindowRate struct {
+	Window    string  `json:"window"`
+	BurnRate  float64 `json:"burn_rate"`
+	ShortRate float64 `json:"short_burn_rate"`
+	Events    uint64  `json:"events"`
+	Firing    bool    `json:"firing"`
+}
+
+// bucket counts the events of one time slot.
+type bucket struct {
+	slot      int64
+	good, bad uint64
+}
+
+// tracker holds the counts and alert state of one objective.
+type tracker struct {
+	obj     Objective
+	verdict map[string]bool
+	buckets []bucket
+	firing  []bool
+}
+
+// Monitor evaluates objectives over observed messages.
+type Monitor struct {
+	cfg    Config
+	width  time.Duration
+	client *http.Client
+
+	// now is the clock, replaced in tests
+	now func() time.Time
+
+	mu       sync.Mutex
+	trackers []*tracker
+}
+
+// New creates a monitor.
+//
+// # Returns
+//   - The monitor, which counts observations immediately; call Run to
+//     evaluate and alert in the background
+//   - ErrNoObjectives, ErrInvalidObjective, or ErrInvalidWindow
+func New(cfg *Config) (*Monitor, error) {
+	if cfg == nil || len(cfg.Objectives) == 0 {
+		return nil, ErrNoObjectives
+	}
+	c := *cfg
+	if c.Windows == nil {
+		c.Windows = DefaultWindows()
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MinEvents <= 0 {
+		c.MinEvents = DefaultMinEvents
+	}
+
+	if len(c.Windows) == 0 {
+		return nil, fmt.Errorf("%w: no windows", ErrInvalidWindow)
+	}
+	var longest, shortest time.Duration
+	for _, w := range c.Windows {
+		if w.Short <= 0 || w.Long < w.Short || w.Rate <= 0 {
+			return nil, fmt.Errorf("%w: %s at rate %g", ErrInvalidWindow, w, w.Rate)
+		}
+		longest = max(longest, w.Long)
+		if shortest == 0 || w.Short < shortest {
+			shortest = w.Short
+		}
+	}
+	width := max(shortest/bucketsPerShortWindow, time.Second)
+
+	m := &Monitor{
+		cfg:    c,
+		width:  width,
+		client: &http.Client{Timeout: 5 * time.Second},
+		now:    time.Now,
+	}
+	seen := make(map[string]bool)
+	for _, obj := range c.Objectives {
+		if err := validate(obj); err != nil {
+			return nil, err
+		}
+		if seen[obj.Name] {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrInvalidObjective, obj.Name)
+		}
+		seen[obj.Name] = true
+		t := &tracker{
+			obj:     obj,
+			buckets: make([]bucket, int(longest/width)+1),
+			firing:  make([]bool, len(c.Windows)),
+		}
+		if len(obj.Verdicts) > 0 {
+			t.verdict = make(map[string]bool, len(obj.Verdicts))
+			for _, v := range obj.Verdicts {
+				t.verdict[v] = true
+			}
+		}
+		m.trackers = append(m.trackers, t)
+	}
+	return m, nil
+}
+
+// validate checks one objective.
+func validate(obj Objective) error {
+	switch {
+	case obj.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidObjective)
+	case obj.Target <= 0 || obj.Target >= 1:
+		return fmt.Errorf("%w: %s: target must be between 0 and 1, got %g", ErrInvalidObjective, obj.Name, obj.Target)
+	case (obj.Latency > 0) == (len(obj.Verdicts) > 0):
+		return fmt.Errorf("%w: %s: set exactly one of latency and verdicts", ErrInvalidObjective, obj.Name)
+	case obj.Latency < 0:
+		return fmt.Errorf("%w: %s: latency must be positive", ErrInvalidObjective, obj.Name)
+	}
+	return nil
+}
+
+// Observe counts one routed message against every matching objective.
+func (m *Monitor) Observe(o Observation) {
+	slot := m.now().UnixNano() / int64(m.width)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.trackers {
+		if t.obj.Method != "" && t.obj.Method != o.Method {
+			continue
+		}
+		var bad bool
+		if t.verdict != nil {
+			bad = t.verdict[o.Verdict]
+		} else {
+			bad = o.AddedLatency > t.obj.Latency
+		}
+
+		b := &t.buckets[slot%int64(len(t.buckets))]
+		if b.slot != slot {
+			*b = bucket{slot: slot}
+		}
+		if bad {
+			b.bad++
+		} else {
+			b.good++
+		}
+	}
+}
+
+// counts sums the events of the buckets within window ending at slot.
+func (m *Monitor) counts(t *tracker, slot int64, window time.Duration) (good, bad uint64) {
+	n := int64(window / m.width)
+	for _, b := range t.buckets {
+		if b.slot > slot-n && b.slot <= slot {
+			good += b.good
+			bad += b.bad
+		}
+	}
+	return good, bad
+}
+
+// burnRate returns the bad fraction over the budget, and the event count.
+func burnRate(good, bad uint64, budget float64) (float64, uint64) {
+	total := good + bad
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(bad) / float64(total) / budget, total
+}
+
+// Evaluate computes every window's burn rate, updates the alert state,
+// and returns the alerts that started or stopped firing. Run calls it
+// periodically and delivers the alerts.
+func (m *Monitor) Evaluate() []Alert {
+	now := m.now()
+	slot := now.UnixNano() / int64(m.width)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var alerts []Alert
+	for _, t := range m.trackers {
+		budget := t.obj.budget()
+		for i, w := range m.cfg.Windows {
+			lg, lb := m.counts(t, slot, w.Long)
+			sg, sb := m.counts(t, slot, w.Short)
+			longRate, _ := burnRate(lg, lb, budget)
+			shortRate, shortEvents := burnRate(sg, sb, budget)
+			firing := longRate >= w.Rate && shortRate >= w.Rate && shortEvents >= uint64(m.cfg.MinEvents)
+			if firing == t.firing[i] {
+				continue
+			}
+			t.firing[i] = firing
+			alerts = append(alerts, Alert{
+				Objective: t.obj.Name,
+				Window:    w.String(),
+				Firing:    firing,
+				BurnRate:  round(longRate),
+				ShortRate: round(shortRate),
+				Threshold: w.Rate,
+				Budget:    budget,
+				Time:      now.UTC(),
+			})
+		}
+	}
+	return alerts
+}
+
+// round keeps burn rates readable in alerts.
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// Status returns every objective's current burn rates.
+func (m *Monitor) Status() []Status {
+	slot := m.now().UnixNano() / int64(m.width)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Status, 0, len(m.trackers))
+	for _, t := range m.trackers {
+		st := Status{Objective: t.obj}
+		for i, w := range m.cfg.Windows {
+			lg, lb := m.counts(t, slot, w.Long)
+			sg, sb := m.counts(t, slot, w.Short)
+			longRate, events := burnRate(lg, lb, t.obj.budget())
+			shortRate, _ := burnRate(sg, sb, t.obj.budget())
+			st.Windows = append(st.Windows, WindowRate{
+				Window:    w.String(),
+				BurnRate:  round(longRate),
+				ShortRate: round(shortRate),
+				Events:    events,
+				Firing:    t.firing[i],
+			})
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Run evaluates every Interval and delivers alerts until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, a := range m.Evaluate() {
+				m.deliver(a)
+			}
+		}
+	}
+}
+
+// deliver logs an alert and passes it to OnAlert and the webhook.
+func (m *Monitor) deliver(a Alert) {
+	log.Printf("audit: %s", a)
+	if m.cfg.OnAlert != nil {
+		m.cfg.OnAlert(a)
+	}
+	if m.cfg.Webhook == "" {
+		return
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return
+	}
+	// Do not hold up evaluation on a slow receiver
+	go func() {
+		resp, err := m.client.Post(m.cfg.Webhook, "application/json", bytes.NewReader(data))
+		if err != nil {
+			log.Printf("slo: alert webhook failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("slo: alert webhook returned %s", resp.Status)
+		}
+	}()
+}
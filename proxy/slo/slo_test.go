@@ -0,0 +1,167 @@
+package slo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clock is a settable test clock.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestMonitor(t *testing.T, cfg *Config) (*Monitor, *clock) {
+	t.Helper()
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	c := &clock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	m.now = c.now
+	return m, c
+}
+
+func TestMonitor_LatencyBurn(t *testing.T) {
+	m, c := newTestMonitor(t, &Config{
+		Objectives: []Objective{{Name: "call-latency", Method: "tools/call", Latency: 500 * time.Millisecond, Target: 0.95}},
+		Windows:    []BurnWindow{{Long: time.Hour, Short: 5 * time.Minute, Rate: 10}},
+	})
+
+	// Healthy traffic, plus slow messages of another method
+	for range 100 {
+		m.Observe(Observation{Method: "tools/call", AddedLatency: 20 * time.Millisecond})
+		m.Observe(Observation{Method: "tools/list", AddedLatency: time.Second})
+	}
+	if alerts := m.Evaluate(); len(alerts) != 0 {
+		t.Fatalf("healthy traffic fired %v", alerts)
+	}
+
+	// Every call slow: 100% bad against a 5% budget burns at 20x
+	c.t = c.t.Add(10 * time.Minute)
+	for range 200 {
+		m.Observe(Observation{Method: "tools/call", AddedLatency: 800 * time.Millisecond})
+	}
+	alerts := m.Evaluate()
+	if len(alerts) != 1 || !alerts[0].Firing || alerts[0].Objective != "call-latency" {
+		t.Fatalf("slow calls raised %v, expected one firing alert", alerts)
+	}
+	if alerts[0].ShortRate != 20 {
+		t.Errorf("short burn rate = %g, expected 20", alerts[0].ShortRate)
+	}
+	if again := m.Evaluate(); len(again) != 0 {
+		t.Errorf("alert repeated while still firing: %v", again)
+	}
+
+	// The short window recovers first and resolves the alert
+	c.t = c.t.Add(6 * time.Minute)
+	for range 50 {
+		m.Observe(Observation{Method: "tools/call", AddedLatency: 20 * time.Millisecond})
+	}
+	alerts = m.Evaluate()
+	if len(alerts) != 1 || alerts[0].Firing {
+		t.Fatalf("recovery raised %v, expected one resolution", alerts)
+	}
+	st := m.Status()
+	if len(st) != 1 || st[0].Windows[0].Events != 350 || st[0].Windows[0].Firing {
+		t.Errorf("Status = %+v", st)
+	}
+}
+
+func TestMonitor_VerdictBurn(t *testing.T) {
+	m, _ := newTestMonitor(t, &Config{
+		Objectives: []Objective{{Name: "block-errors", Verdicts: []string{"blocked", "error"}, Target: 0.999}},
+		Windows:    []BurnWindow{{Long: time.Hour, Short: 5 * time.Minute, Rate: 14.4}},
+	})
+
+	// 1% errors against a 0.1% budget burns at 10x, under the threshold
+	for i := range 1000 {
+		verdict := "allowed"
+		if i%100 == 0 {
+			verdict = "error"
+		}
+		m.Observe(Observation{Method: "tools/call", Verdict: verdict})
+	}
+	if alerts := m.Evaluate(); len(alerts) != 0 {
+		t.Fatalf("10x burn fired %v", alerts)
+	}
+	for range 10 {
+		m.Observe(Observation{Method: "tools/call", Verdict: "blocked"})
+	}
+	if alerts := m.Evaluate(); len(alerts) != 1 || !alerts[0].Firing {
+		t.Fatalf("20x burn raised %v, expected a firing alert", alerts)
+	}
+}
+
+func TestMonitor_MinEvents(t *testing.T) {
+	m, _ := newTestMonitor(t, &Config{
+		Objectives: []Objective{{Name: "errors", Verdicts: []string{"error"}, Target: 0.99}},
+	})
+	for range DefaultMinEvents - 1 {
+		m.Observe(Observation{Verdict: "error"})
+	}
+	if alerts := m.Evaluate(); len(alerts) != 0 {
+		t.Errorf("%d events fired %v", DefaultMinEvents-1, alerts)
+	}
+}
+
+func TestMonitor_Webhook(t *testing.T) {
+	got := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	var called bool
+	m, _ := newTestMonitor(t, &Config{
+		Objectives: []Objective{{Name: "errors", Verdicts: []string{"error"}, Target: 0.99}},
+		Webhook:    srv.URL,
+		OnAlert:    func(Alert) { called = true },
+	})
+	m.deliver(Alert{Objective: "errors", Window: "1h0m0s/5m0s", Firing: true, BurnRate: 50})
+	select {
+	case a := <-got:
+		if a.Objective != "errors" || !a.Firing || a.BurnRate != 50 {
+			t.Errorf("webhook received %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if !called {
+		t.Error("OnAlert not called")
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		err  error
+	}{
+		{"nil", nil, ErrNoObjectives},
+		{"no name", &Config{Objectives: []Objective{{Latency: time.Second, Target: 0.9}}}, ErrInvalidObjective},
+		{"target", &Config{Objectives: []Objective{{Name: "a", Latency: time.Second, Target: 1}}}, ErrInvalidObjective},
+		{"both kinds", &Config{Objectives: []Objective{{Name: "a", Latency: time.Second, Verdicts: []string{"error"}, Target: 0.9}}}, ErrInvalidObjective},
+		{"neither kind", &Config{Objectives: []Objective{{Name: "a", Target: 0.9}}}, ErrInvalidObjective},
+		{"duplicate", &Config{Objectives: []Objective{
+			{Name: "a", Latency: time.Second, Target: 0.9},
+			{Name: "a", Latency: time.Second, Target: 0.9},
+		}}, ErrInvalidObjective},
+		{"window", &Config{
+			Objectives: []Objective{{Name: "a", Latency: time.Second, Target: 0.9}},
+			Windows:    []BurnWindow{{Long: time.Minute, Short: time.Hour, Rate: 2}},
+		}, ErrInvalidWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); !errors.Is(err, tt.err) {
+				t.Errorf("New = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Package staging keeps the journal of tool call writes that a policy
+// downgrade redirected into a staging directory (see policy.Downgrade),
+// so that every redirect can be reviewed and reversed: promoted to the
+// path the call meant to write, or discarded.
+//
+// Each staging directory holds its own journal, JournalFile, with one
+// JSON Entry per line. State changes are appended, never rewritten, so
+// the journal doubles as the audit log of the staging area.
+//
+// # Security Notes
+//
+// Promoting writes where the policy would not let the tool write; it is
+// an operator decision, never made by the proxy. The upstream server
+// writes the staged files, so promotion must run on the host that holds
+// them, as a user allowed to write the original path.
+//
+// # Thread Safety
+//
+// Functions are safe for concurrent use within a process. Journal lines
+// are appended with a single write each.
+package staging
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalFile is the journal's name in a staging directory.
+const JournalFile = "journal.jsonl"
+
+// Entry states.
+const (
+	// StateStaged is a write waiting in the staging directory
+	StateStaged = "staged"
+	// StatePromoted is a write moved to its original path
+	StatePromoted = "promoted"
+	// StateDiscarded is a write deleted from the staging directory
+	StateDiscarded = "discarded"
+)
+
+// Journal errors.
+var (
+	ErrUnknownEntry = errors.New("staging: unknown entry")
+	ErrNotStaged    = errors.New("staging: entry is no longer staged")
+	ErrInvalidEntry = errors.New("staging: invalid entry")
+)
+
+// mu serializes journal updates within the process.
+var mu sync.Mutex
+
+// Entry is one staged write.
+type Entry struct {
+	// ID names the write; its files are under the directory ID
+	ID string `json:"id"`
+
+	// Time is when the entry reached its State
+	Time time.Time `json:"time"`
+
+	// State is staged, promoted, or discarded
+	State string `json:"state"`
+
+	// Session, Rule, and Tool identify the downgraded call
+	Session string `json:"session,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Tool    string `json:"tool,omitempty"`
+
+	// Original is the path the call meant to write and Staged the path
+	// it wrote instead
+	Original string `json:"original"`
+	Staged   string `json:"staged"`
+}
+
+// Record journals a staged write in dir, creating dir if needed.
+// Empty Time and State default to now and StateStaged.
+//
+// # Returns
+//   - ErrInvalidEntry if the staged path is not under dir/ID
+func Record(dir string, e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.State == "" {
+		e.State = StateStaged
+	}
+	if err := checkEntry(dir, e); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("staging: %w", err)
+	}
+	return appendEntry(dir, e)
+}
+
+// List returns the writes journaled in dir in the order they were
+// staged, each in its latest state. A missing journal lists nothing.
+func List(dir string) ([]Entry, error) {
+	f, err := os.Open(filepath.Join(dir, JournalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("staging: %w", err)
+	}
+	defer f.Close()
+
+	var order []string
+	latest := make(map[string]Entry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("staging: %s line %d: %w", JournalFile, n, err)
+		}
+		if _, seen := latest[e.ID]; !seen {
+			order = append(order, e.ID)
+		}
+		latest[e.ID] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("staging: %w", err)
+	}
+	entries := make([]Entry, 0, len(order))
+	for _, id := range order {
+		entries = append(entries, latest[id])
+	}
+	return entries, nil
+}
+
+// Promote moves a staged write to its original path, creating the
+// path's directories, and journals the promotion.
+//
+// # Returns
+//   - The promoted entry
+//   - ErrUnknownEntry or ErrNotStaged if id is not waiting in dir
+func Promote(dir, id string) (Entry, error) {
+	return transition(dir, id, StatePromoted, func(e Entry) error {
+		if err := os.MkdirAll(filepath.Dir(e.Original), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(e.Staged, e.Original); err == nil {
+			return nil
+		}
+		// Across file systems: copy, then remove the staged file
+		if err := copyFile(e.Staged, e.Original); err != nil {
+			return err
+		}
+		return os.Remove(e.Staged)
+	})
+}
+
+// Discard deletes a staged write and journals the discard.
+//
+// # Returns
+//   - The discarded entry
+//   - ErrUnknownEntry or ErrNotStaged if id is not waiting in dir
+func Discard(dir, id string) (Entry, error) {
+	return transition(dir, id, StateDiscarded, func(e Entry) error {
+		return os.RemoveAll(filepath.Join(dir, e.ID))
+	})
+}
+
+// transition applies apply to a staged entry and journals its new
+// state.
+func transition(dir, id, state string, apply func(Entry) error) (Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	entries, err := List(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range entries {
+		if e.ID != id {
+			continue
+		}
+		if e.State != StateStaged {
+			return e, fmt.Errorf("%w: %s is %s", ErrNotStaged, id, e.State)
+		}
+		if err := checkEntry(dir, e); err != nil {
+			return e, err
+		}
+		if err := apply(e); err != nil {
+			return e, fmt.Errorf("staging: %s: %w", id, err)
+		}
+		e.State, e.Time = state, time.Now().UTC()
+		return e, appendEntry(dir, e)
+	}
+	return Entry{}, fmt.Errorf("%w: %s", ErrUnknownEntry, id)
+}
+
+// checkEntry refuses entries whose staged path escapes dir/ID, so a
+// tampered journal cannot move or delete other files.
+func checkEntry(dir string, e Entry) error {
+	if e.ID == "" || strings.ContainsAny(e.ID, `/\`) || e.ID == "." || e.ID == ".." || !filepath.IsAbs(e.Original) {
+		return fmt.Errorf("%w: %q", ErrInvalidEntry, e.ID)
+	}
+	root := filepath.Join(dir, e.ID) + string(filepath.Separator)
+	if !strings.HasPrefix(filepath.Clean(e.Staged), root) {
+		return fmt.Errorf("%w: %s is not under %s", ErrInvalidEntry, e.Staged, root)
+	}
+	return nil
+}
+
+// appendEntry appends e to the journal. Called with mu held.
+func appendEntry(dir string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("staging: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, JournalFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("staging: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("staging: %w", err)
+	}
+	return f.Close()
+}
+
+// copyFile copies the file src to dst, keeping its mode.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
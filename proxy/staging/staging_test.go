@@ -0,0 +1,105 @@
+package staging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stage writes a staged file for id as the upstream server would and
+// journals it.
+func stage(t *testing.T, dir, id, original string) Entry {
+	t.Helper()
+	e := Entry{ID: id, Rule: "stage-writes", Tool: "write_file", Original: original, Staged: filepath.Join(dir, id, original)}
+	os.MkdirAll(filepath.Dir(e.Staged), 0o700)
+	if err := os.WriteFile(e.Staged, []byte("content "+id), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(dir, e); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	return e
+}
+
+func TestPromoteAndDiscard(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "staging")
+	target := filepath.Join(root, "etc", "app.conf")
+	stage(t, dir, "a1", target)
+	discarded := stage(t, dir, "b2", filepath.Join(root, "etc", "other.conf"))
+
+	promoted, err := Promote(dir, "a1")
+	if err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "content a1" {
+		t.Errorf("promoted file = %q, %v", data, err)
+	}
+	if promoted.State != StatePromoted {
+		t.Errorf("promoted state = %s", promoted.State)
+	}
+	if _, err := Discard(dir, "b2"); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b2")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("discarded files remain: %v", err)
+	}
+	if _, err := os.Stat(discarded.Original); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("discarded write reached its original path: %v", err)
+	}
+
+	entries, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "a1" || entries[0].State != StatePromoted || entries[1].State != StateDiscarded {
+		t.Errorf("List = %+v", entries)
+	}
+}
+
+func TestTransition_Errors(t *testing.T) {
+	dir := t.TempDir()
+	stage(t, dir, "a1", "/srv/a")
+	if _, err := Discard(dir, "a1"); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		err  error
+	}{
+		{"unknown", "zz", ErrUnknownEntry},
+		{"already discarded", "a1", ErrNotStaged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Promote(dir, tt.id); !errors.Is(err, tt.err) {
+				t.Errorf("Promote(%s) = %v, expected %v", tt.id, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRecord_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		entry Entry
+	}{
+		{"staged outside its directory", Entry{ID: "a1", Original: "/etc/x", Staged: "/etc/x"}},
+		{"escaping ID", Entry{ID: "..", Original: "/etc/x", Staged: filepath.Join(dir, "..", "etc", "x")}},
+		{"relative original", Entry{ID: "a1", Original: "etc/x", Staged: filepath.Join(dir, "a1", "etc", "x")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Record(dir, tt.entry); !errors.Is(err, ErrInvalidEntry) {
+				t.Errorf("Record = %v, expected ErrInvalidEntry", err)
+			}
+		})
+	}
+	if entries, err := List(filepath.Join(dir, "missing")); entries != nil || err != nil {
+		t.Errorf("List of a missing journal = %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,284 @@
+package tofu
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+)
+
+// Fleet manifest errors.
+var (
+	ErrFleetKey       = errors.New("tofu: fleet manifest needs a public key")
+	ErrFleetSignature = errors.New("tofu: fleet manifest signature does not verify")
+	ErrFleetManifest  = errors.New("tofu: invalid fleet manifest")
+	ErrFleetExpired   = errors.New("tofu: fleet manifest expired")
+	ErrFleetBlocked   = errors.New("tofu: server is blocked by the fleet manifest")
+	ErrInvalidServer  = errors.New("tofu: invalid initialize result")
+)
+
+// FleetManifestType identifies the layout of FleetManifest.
+const FleetManifestType = "mcp-sentinel/fleet-manifest/v1"
+
+// fleetApprover prefixes the ApprovedBy of approvals from a fleet
+// manifest.
+const fleetApprover = "fleet:"
+
+// Trust is the trust level a fleet manifest gives a server.
+type Trust string
+
+const (
+	// TrustPinned approves the server's pinned tools; any other tool
+	// awaits approval as usual
+	TrustPinned Trust = "pinned"
+	// TrustTrusted also approves the server's tools that are not
+	// pinned on first sight; a pinned tool that changed still awaits
+	// approval
+	TrustTrusted Trust = "trusted"
+	// TrustBlocked approves none of the server's tools, and refuses
+	// approvals of them
+	TrustBlocked Trust = "blocked"
+)
+
+// FleetManifest is the organization-wide pin set a central security
+// team distributes to every proxy, signed as a SignedFleetManifest.
+//
+//	{"type":"mcp-sentinel/fleet-manifest/v1","issuer":"secops",
+//	 "issued":"2026-10-01T00:00:00Z","expires":"2027-01-01T00:00:00Z",
+//	 "servers":[{"name":"github","fingerprint":"sha256:...","trust":"pinned",
+//	   "tools":[{"name":"create_issue","fingerprint":"sha256:..."}]}]}
+type FleetManifest struct {
+	// Type is FleetManifestType
+	Type string `json:"type"`
+
+	// Issuer names the team that issued the manifest; fleet approvals
+	// are recorded as approved by "fleet:" and the issuer
+	Issuer string `json:"issuer"`
+
+	Issued time.Time `json:"issued"`
+
+	// Expires ends the manifest's validity (zero never expires); an
+	// expired manifest is refused at startup
+	Expires time.Time `json:"expires,omitzero"`
+
+	Servers []FleetServer `json:"servers"`
+}
+
+// FleetServer pins one server of a FleetManifest.
+type FleetServer struct {
+	// Name is the server's initialize serverInfo name
+	Name string `json:"name"`
+
+	// Fingerprint is the server's ServerFingerprint (empty accepts any
+	// server of this name)
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Trust is the server's trust level ("" is TrustPinned)
+	Trust Trust `json:"trust,omitempty"`
+
+	// Tools are the pinned tool fingerprints
+	Tools []FleetTool `json:"tools,omitempty"`
+}
+
+// FleetTool pins one tool of a FleetServer.
+type FleetTool struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// SignedFleetManifest is a FleetManifest with its signature. The
+// signature covers the manifest's compact JSON bytes, so re-indenting
+// the file leaves it valid while any other change breaks it.
+type SignedFleetManifest struct {
+	Manifest json.RawMessage `json:"manifest"`
+
+	// KeyID names the signing key; see attest.KeyID
+	KeyID string `json:"key_id"`
+
+	// Signature is the base64 Ed25519 signature of Manifest
+	Signature string `json:"signature"`
+}
+
+// SignFleetManifest signs a manifest with an Ed25519 key, e.g. one from
+// attest.GenerateKey.
+func SignFleetManifest(m *FleetManifest, key ed25519.PrivateKey) (*SignedFleetManifest, error) {
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("tofu: encode fleet manifest: %w", err)
+	}
+	return &SignedFleetManifest{
+		Manifest:  manifest,
+		KeyID:     attest.KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	}, nil
+}
+
+// VerifyFleetManifest checks a signed manifest and returns the manifest.
+//
+// # Arguments
+//   - signed: The signed manifest
+//   - key: The public key of the issuing team
+//   - now: The time the manifest must be valid at
+//
+// # Returns
+//   - The manifest
+//   - ErrFleetSignature if the signature does not verify or names
+//     another key, ErrFleetManifest if the manifest is malformed, or
+//     ErrFleetExpired if it expired before now
+func VerifyFleetManifest(signed *SignedFleetManifest, key ed25519.PublicKey, now time.Time) (*FleetManifest, error) {
+	sig, err := base64.StdEncoding.DecodeString(signed.Signature)
+	var manifest bytes.Buffer
+	if err != nil || json.Compact(&manifest, signed.Manifest) != nil {
+		return nil, ErrFleetSignature
+	}
+	if signed.KeyID != attest.KeyID(key) || !ed25519.Verify(key, manifest.Bytes(), sig) {
+		return nil, ErrFleetSignature
+	}
+	var m FleetManifest
+	if err := json.Unmarshal(signed.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFleetManifest, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if !m.Expires.IsZero() && now.After(m.Expires) {
+		return nil, fmt.Errorf("%w: at %s", ErrFleetExpired, m.Expires.Format(time.RFC3339))
+	}
+	return &m, nil
+}
+
+// validate checks the manifest's layout.
+func (m *FleetManifest) validate() error {
+	if m.Type != FleetManifestType {
+		return fmt.Errorf("%w: type %q", ErrFleetManifest, m.Type)
+	}
+	if m.Issuer == "" {
+		return fmt.Errorf("%w: issuer is required", ErrFleetManifest)
+	}
+	seen := make(map[string]bool, len(m.Servers))
+	for i, s := range m.Servers {
+		if s.Name == "" {
+			return fmt.Errorf("%w: servers[%d]: name is required", ErrFleetManifest, i)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("%w: servers[%d]: server %q listed twice", ErrFleetManifest, i, s.Name)
+		}
+		seen[s.Name] = true
+		switch s.Trust {
+		case "", TrustPinned, TrustTrusted, TrustBlocked:
+		default:
+			return fmt.Errorf("%w: servers[%d]: unknown trust %q", ErrFleetManifest, i, s.Trust)
+		}
+		for j, t := range s.Tools {
+			if t.Name == "" || !strings.HasPrefix(t.Fingerprint, "sha256:") {
+				return fmt.Errorf("%w: servers[%d].tools[%d]: name and sha256 fingerprint are required", ErrFleetManifest, i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFleetManifest reads and verifies the signed manifest at path.
+func ReadFleetManifest(path string, key ed25519.PublicKey, now time.Time) (*FleetManifest, error) {
+	if key == nil {
+		return nil, ErrFleetKey
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tofu: read %s: %w", path, err)
+	}
+	var signed SignedFleetManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrFleetManifest, path, err)
+	}
+	m, err := VerifyFleetManifest(&signed, key, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// serverFingerprintFields are the initialize result fields a server
+// fingerprint covers.
+var serverFingerprintFields = []string{"capabilities", "instructions"}
+
+// ServerFingerprint returns the fingerprint of an initialize result.
+//
+// The fingerprint is a SHA-256 over the canonical JSON of the
+// serverInfo name and version, the capabilities, and the instructions,
+// so a server of the same name that is another build, or that tells
+// the model something else, does not match.
+func ServerFingerprint(result json.RawMessage) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidServer, err)
+	}
+	kept := make(map[string]interface{}, len(serverFingerprintFields)+1)
+	if info, ok := fields["serverInfo"].(map[string]interface{}); ok {
+		kept["serverInfo"] = map[string]interface{}{"name": info["name"], "version": info["version"]}
+	}
+	for _, f := range serverFingerprintFields {
+		if v, ok := fields[f]; ok {
+			kept[f] = v
+		}
+	}
+	canonical, err := json.Marshal(kept)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidServer, err)
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// loadFleet adds a fleet manifest's pins to the store.
+func (s *Store) loadFleet(m *FleetManifest) {
+	s.fleet = make(map[string]FleetServer, len(m.Servers))
+	by := fleetApprover + m.Issuer
+	s.fleetBy = by
+	for _, server := range m.Servers {
+		if server.Trust == "" {
+			server.Trust = TrustPinned
+		}
+		s.fleet[server.Name] = server
+		if server.Trust == TrustBlocked {
+			continue
+		}
+		for _, t := range server.Tools {
+			s.approvals[key(server.Name, t.Name)] = Approval{
+				Server:      server.Name,
+				Tool:        t.Name,
+				Fingerprint: t.Fingerprint,
+				ApprovedAt:  m.Issued,
+				ApprovedBy:  by,
+			}
+		}
+	}
+}
+
+// CheckServer reports whether a server matches the fingerprint the
+// fleet manifest pins for its name: StatusApproved if it does or no
+// fingerprint is pinned, StatusChanged if it does not, and
+// StatusBlocked if the manifest blocks the server.
+func (s *Store) CheckServer(server, fingerprint string) Status {
+	s.mu.Lock()
+	pinned, ok := s.fleet[server]
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		return StatusApproved
+	case pinned.Trust == TrustBlocked:
+		return StatusBlocked
+	case pinned.Fingerprint != "" && pinned.Fingerprint != fingerprint:
+		return StatusChanged
+	}
+	return StatusApproved
+}
@@ -0,0 +1,222 @@
+package tofu
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/attest"
+)
+
+// testFleetKey returns a fresh fleet signing key pair.
+func testFleetKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := attest.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	pub, _ := attest.ParsePublicKey(public)
+	priv, _ := attest.ParsePrivateKey(private)
+	return pub, priv
+}
+
+func testFleet() *FleetManifest {
+	return &FleetManifest{
+		Type:    FleetManifestType,
+		Issuer:  "secops",
+		Issued:  time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Expires: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		Servers: []FleetServer{
+			{Name: "fs", Tools: []FleetTool{{Name: "read", Fingerprint: "sha256:a"}}},
+			{Name: "web", Trust: TrustTrusted},
+			{Name: "shell", Trust: TrustBlocked, Tools: []FleetTool{{Name: "exec", Fingerprint: "sha256:x"}}},
+		},
+	}
+}
+
+func TestVerifyFleetManifest(t *testing.T) {
+	pub, priv := testFleetKey(t)
+	otherPub, _ := testFleetKey(t)
+	valid := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		manifest func(m *FleetManifest)
+		signed   func(s *SignedFleetManifest)
+		key      ed25519.PublicKey
+		now      time.Time
+		expected error
+	}{
+		{"valid", nil, nil, pub, valid, nil},
+		{"reindented", nil, func(s *SignedFleetManifest) {
+			s.Manifest, _ = json.MarshalIndent(s.Manifest, "", "  ")
+		}, pub, valid, nil},
+		{"tampered", nil, func(s *SignedFleetManifest) {
+			s.Manifest = []byte(`{"type":"mcp-sentinel/fleet-manifest/v1","issuer":"mallory","servers":[]}`)
+		}, pub, valid, ErrFleetSignature},
+		{"other key", nil, nil, otherPub, valid, ErrFleetSignature},
+		{"expired", nil, nil, pub, time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC), ErrFleetExpired},
+		{"unknown trust", func(m *FleetManifest) { m.Servers[0].Trust = "full" }, nil, pub, valid, ErrFleetManifest},
+		{"server listed twice", func(m *FleetManifest) { m.Servers[1].Name = "fs" }, nil, pub, valid, ErrFleetManifest},
+		{"tool without fingerprint", func(m *FleetManifest) { m.Servers[0].Tools[0].Fingerprint = "" }, nil, pub, valid, ErrFleetManifest},
+		{"wrong type", func(m *FleetManifest) { m.Type = "fleet" }, nil, pub, valid, ErrFleetManifest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testFleet()
+			if tt.manifest != nil {
+				tt.manifest(m)
+			}
+			signed, err := SignFleetManifest(m, priv)
+			if err != nil {
+				t.Fatalf("SignFleetManifest failed: %v", err)
+			}
+			if tt.signed != nil {
+				tt.signed(signed)
+			}
+			got, err := VerifyFleetManifest(signed, tt.key, tt.now)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("err = %v, expected %v", err, tt.expected)
+			}
+			if err == nil && (got.Issuer != "secops" || len(got.Servers) != 3) {
+				t.Errorf("manifest = %+v", got)
+			}
+		})
+	}
+}
+
+func TestReadFleetManifest(t *testing.T) {
+	pub, priv := testFleetKey(t)
+	signed, _ := SignFleetManifest(testFleet(), priv)
+	path := filepath.Join(t.TempDir(), "fleet.json")
+	data, _ := json.MarshalIndent(signed, "", "  ")
+	os.WriteFile(path, data, 0o600)
+
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := ReadFleetManifest(path, pub, now); err != nil {
+		t.Errorf("ReadFleetManifest failed: %v", err)
+	}
+	if _, err := ReadFleetManifest(path, nil, now); !errors.Is(err, ErrFleetKey) {
+		t.Errorf("without a key: %v, expected ErrFleetKey", err)
+	}
+}
+
+func TestStore_Fleet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	s, err := Open(&Config{Path: path, Fleet: testFleet()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		server   string
+		tool     string
+		print    string
+		expected Status
+	}{
+		{"pinned tool", "fs", "read", "sha256:a", StatusApproved},
+		{"pinned tool changed", "fs", "read", "sha256:b", StatusChanged},
+		{"unpinned tool of a pinned server", "fs", "write", "sha256:w", StatusUnknown},
+		{"trusted server", "web", "fetch", "sha256:f", StatusApproved},
+		{"blocked server", "shell", "exec", "sha256:x", StatusBlocked},
+		{"server not in the manifest", "db", "query", "sha256:q", StatusUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Check(tt.server, tt.tool, tt.print, ""); got != tt.expected {
+				t.Errorf("Check = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+
+	if err := s.Approve("shell", "exec", "sha256:x", "admin"); !errors.Is(err, ErrFleetBlocked) {
+		t.Errorf("approving a blocked server's tool: %v, expected ErrFleetBlocked", err)
+	}
+	if err := s.Approve("fs", "write", "", "admin"); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	// Fleet approvals come from the current manifest, not the approval
+	// file; operator approvals persist
+	reopened, err := Open(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := reopened.Check("web", "fetch", "sha256:f", ""); got != StatusUnknown {
+		t.Errorf("fleet approval without the manifest = %s, expected unknown", got)
+	}
+	if got := reopened.Check("fs", "write", "sha256:w", ""); got != StatusApproved {
+		t.Errorf("operator approval after restart = %s, expected approved", got)
+	}
+
+	// A persisted approval does not override a fleet pin
+	if err := reopened.Approve("fs", "read", "sha256:b", "admin"); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	pinned, err := Open(&Config{Path: path, Fleet: testFleet()})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := pinned.Check("fs", "read", "sha256:b", ""); got != StatusChanged {
+		t.Errorf("locally approved fingerprint of a pinned tool = %s, expected changed", got)
+	}
+}
+
+func TestStore_CheckServer(t *testing.T) {
+	m := testFleet()
+	m.Servers[0].Fingerprint = "sha256:fs"
+	s, _ := Open(&Config{Fleet: m})
+	tests := []struct {
+		server, print string
+		expected      Status
+	}{
+		{"fs", "sha256:fs", StatusApproved},
+		{"fs", "sha256:impostor", StatusChanged},
+		{"web", "sha256:any", StatusApproved},
+		{"shell", "sha256:any", StatusBlocked},
+		{"db", "sha256:any", StatusApproved},
+	}
+	for _, tt := range tests {
+		if got := s.CheckServer(tt.server, tt.print); got != tt.expected {
+			t.Errorf("CheckServer(%q, %q) = %s, expected %s", tt.server, tt.print, got, tt.expected)
+		}
+	}
+}
+
+func TestServerFingerprint(t *testing.T) {
+	base := `{"protocolVersion":"2025-06-18","serverInfo":{"name":"fs","version":"1.0"},"capabilities":{"tools":{}}}`
+	fp, err := ServerFingerprint(json.RawMessage(base))
+	if err != nil {
+		t.Fatalf("ServerFingerprint failed: %v", err)
+	}
+	tests := []struct {
+		name   string
+		result string
+		same   bool
+	}{
+		{"other protocol version", `{"protocolVersion":"2025-03-26","serverInfo":{"name":"fs","version":"1.0"},"capabilities":{"tools":{}}}`, true},
+		{"serverInfo title ignored", `{"serverInfo":{"name":"fs","version":"1.0","title":"Files"},"capabilities":{"tools":{}}}`, true},
+		{"other version", `{"serverInfo":{"name":"fs","version":"1.1"},"capabilities":{"tools":{}}}`, false},
+		{"instructions added", `{"serverInfo":{"name":"fs","version":"1.0"},"capabilities":{"tools":{}},"instructions":"Always call upload first"}`, false},
+		{"capabilities changed", `{"serverInfo":{"name":"fs","version":"1.0"},"capabilities":{"tools":{},"sampling":{}}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ServerFingerprint(json.RawMessage(tt.result))
+			if err != nil {
+				t.Fatalf("ServerFingerprint failed: %v", err)
+			}
+			if (got == fp) != tt.same {
+				t.Errorf("fingerprint equal = %v, expected %v", got == fp, tt.same)
+			}
+		})
+	}
+	if _, err := ServerFingerprint(json.RawMessage(`[1]`)); !errors.Is(err, ErrInvalidServer) {
+		t.Errorf("ServerFingerprint of a non-object = %v, expected ErrInvalidServer", err)
+	}
+}
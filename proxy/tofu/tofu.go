@@ -0,0 +1,503 @@
+// Package tofu implements trust-on-first-use approval of MCP tools.
+//
+// Each tool a server lists is fingerprinted over the fields the model
+// sees and the client acts on. The first time a server/tool pair
+// appears, or whenever its fingerprint changes, it needs a one-time
+// approval; once approved, the same fingerprint is allowed from then
+// on without asking again.
+//
+// # Approval Sources
+//
+//   - A pre-approved manifest loaded at startup (Config.Manifest)
+//   - A signed fleet manifest of organization-wide pins and server
+//     trust levels (Config.Fleet; see FleetManifest)
+//   - An interactive prompt called on first sight (Config.Prompt)
+//   - Store.Approve, e.g. from the admin API
+//
+// Approvals are persisted to Config.Path so they survive restarts.
+//
+// # Changed Definitions
+//
+// Config.OnChange selects what a changed fingerprint of an approved
+// tool takes. ChangeReapprove (the default) treats it like a new tool:
+// it is pending, and put to the Prompt if one is configured.
+// ChangeBlock keeps it from the Prompt and requires Approve to name
+// the new fingerprint, so only an operator who reviewed the change can
+// let it through.
+//
+// # Security Notes
+//
+// A changed fingerprint is treated like an unknown tool: a server that
+// silently rewrites a tool's description or schema after approval (a
+// "rug pull") loses the tool until an operator approves the new
+// definition. The previous fingerprint is kept on the pending entry so
+// the change can be reviewed.
+//
+// # Thread Safety
+//
+// Store is safe for concurrent use.
+package tofu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the Store.
+var (
+	ErrInvalidTool     = errors.New("tofu: invalid tool definition")
+	ErrInvalidIdentity = errors.New("tofu: server and tool names are required")
+	ErrNotPending      = errors.New("tofu: no pending approval matches")
+	ErrUnknownTool     = errors.New("tofu: no approval for tool")
+	ErrInvalidStore    = errors.New("tofu: invalid approval file")
+	ErrInvalidAction   = errors.New("tofu: invalid change action")
+	ErrExplicitChange  = errors.New("tofu: changed tool must be approved by fingerprint")
+)
+
+// Actions on a changed fingerprint; see Config.OnChange.
+const (
+	ChangeReapprove = "reapprove"
+	ChangeBlock     = "block"
+)
+
+// fingerprintFields are the tool definition fields a fingerprint covers.
+var fingerprintFields = []string{"name", "title", "description", "inputSchema", "outputSchema", "annotations"}
+
+// fileVersion is the approval file format version.
+const fileVersion = 1
+
+// maxDescription bounds the description kept on a pending entry.
+const maxDescription = 1024
+
+// Status is the approval state of a fingerprint.
+type Status int
+
+const (
+	// StatusApproved means the fingerprint was approved
+	StatusApproved Status = iota
+	// StatusUnknown means the tool was never approved
+	StatusUnknown
+	// StatusChanged means a different fingerprint was approved
+	StatusChanged
+	// StatusBlocked means the fleet manifest blocks the server
+	StatusBlocked
+)
+
+// String returns the status name.
+func (s Status) String() string {
+	switch s {
+	case StatusApproved:
+		return "approved"
+	case StatusUnknown:
+		return "unknown"
+	case StatusChanged:
+		return "changed"
+	case StatusBlocked:
+		return "blocked"
+	default:
+		return fmt.Sprintf("status(%d)", int(s))
+	}
+}
+
+// Approval is an approved tool fingerprint.
+type Approval struct {
+	Server      string    `json:"server"`
+	Tool        string    `json:"tool"`
+	Fingerprint string    `json:"fingerprint"`
+	ApprovedAt  time.Time `json:"approved_at,omitzero"`
+	ApprovedBy  string    `json:"approved_by,omitempty"`
+}
+
+// Pending is a fingerprint awaiting approval.
+type Pending struct {
+	Server      string    `json:"server"`
+	Tool        string    `json:"tool"`
+	Fingerprint string    `json:"fingerprint"`
+	Status      string    `json:"status"`
+	Previous    string    `json:"previous,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+}
+
+// Config configures a Store.
+type Config struct {
+	// Path persists approvals as JSON ("" keeps them in memory only)
+	Path string
+
+	// Manifest is a file of pre-approved fingerprints in the same
+	// format, loaded once ("" loads none)
+	Manifest string
+
+	// Prompt is asked to approve each new pending fingerprint as it is
+	// first seen; returning true approves it (nil leaves it pending)
+	Prompt func(Pending) bool
+
+	// OnChange is ChangeReapprove or ChangeBlock ("" is
+	// ChangeReapprove)
+	OnChange string
+
+	// Fleet is a verified fleet manifest (nil loads none); see
+	// ReadFleetManifest. Its pins take precedence over Manifest and over
+	// approvals in the Path file; approvals the fleet made are not read
+	// back from Path, as each start takes them from the current manifest
+	Fleet *FleetManifest
+}
+
+// file is the on-disk format of approvals and manifests.
+type file struct {
+	Version   int        `json:"version"`
+	Approvals []Approval `json:"approvals"`
+}
+
+// Store holds approvals and pending fingerprints.
+type Store struct {
+	path     string
+	prompt   func(Pending) bool
+	onChange string
+
+	mu          sync.Mutex
+	approvals   map[string]Approval
+	pending     map[string]Pending
+	subscribers map[int]func(Approval)
+	nextSub     int
+
+	// promptMu serializes prompts so an operator sees one at a time
+	promptMu sync.Mutex
+
+	// fleet holds the fleet manifest's servers by name, and fleetBy
+	// records its approvals (empty without a manifest)
+	fleet   map[string]FleetServer
+	fleetBy string
+}
+
+// key identifies a server/tool pair.
+func key(server, tool string) string {
+	return server + "\x00" + tool
+}
+
+// Open creates a Store, loading persisted approvals and the manifest.
+//
+// # Returns
+//   - The Store
+//   - ErrInvalidStore if the approval file or manifest does not parse,
+//     or ErrInvalidAction for an unknown OnChange; the fleet manifest
+//     is verified before it is passed in
+func Open(cfg *Config) (*Store, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	onChange := cfg.OnChange
+	switch onChange {
+	case "":
+		onChange = ChangeReapprove
+	case ChangeReapprove, ChangeBlock:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAction, cfg.OnChange)
+	}
+	s := &Store{
+		path:        cfg.Path,
+		prompt:      cfg.Prompt,
+		onChange:    onChange,
+		approvals:   make(map[string]Approval),
+		pending:     make(map[string]Pending),
+		subscribers: make(map[int]func(Approval)),
+	}
+	if cfg.Manifest != "" {
+		if err := s.load(cfg.Manifest, false); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Path != "" {
+		// Persisted decisions take precedence over the manifest
+		if err := s.load(cfg.Path, true); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Fleet != nil {
+		// and the fleet's pins over both
+		s.loadFleet(cfg.Fleet)
+	}
+	return s, nil
+}
+
+// load merges approvals from path. persisted marks the approval file,
+// which may be missing and whose fleet approvals are skipped.
+func (s *Store) load(path string, persisted bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && persisted {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("tofu: read %s: %w", path, err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidStore, path, err)
+	}
+	if f.Version != fileVersion {
+		return fmt.Errorf("%w: %s: version %d, expected %d", ErrInvalidStore, path, f.Version, fileVersion)
+	}
+	for _, a := range f.Approvals {
+		if a.Server == "" || a.Tool == "" || a.Fingerprint == "" {
+			return fmt.Errorf("%w: %s: approval needs server, tool, and fingerprint", ErrInvalidStore, path)
+		}
+		if persisted && strings.HasPrefix(a.ApprovedBy, fleetApprover) {
+			continue
+		}
+		s.approvals[key(a.Server, a.Tool)] = a
+	}
+	return nil
+}
+
+// Fingerprint returns the fingerprint of a tools/list tool definition.
+//
+// The fingerprint is a SHA-256 over the canonical JSON (sorted keys, no
+// insignificant whitespace) of name, title, description, inputSchema,
+// outputSchema, and annotations. Other fields, such as _meta and icons,
+// do not affect it.
+func Fingerprint(tool json.RawMessage) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(tool, &fields); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTool, err)
+	}
+	kept := make(map[string]interface{}, len(fingerprintFields))
+	for _, f := range fingerprintFields {
+		if v, ok := fields[f]; ok {
+			kept[f] = v
+		}
+	}
+	canonical, err := json.Marshal(kept)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTool, err)
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Check reports whether a fingerprint is approved, recording it as
+// pending (and prompting, if configured) when it is not.
+//
+// # Arguments
+//   - server: Server identity, e.g. the initialize serverInfo name
+//   - tool: Tool name
+//   - fingerprint: The tool's current Fingerprint
+//   - description: Tool description shown to the operator for review
+func (s *Store) Check(server, tool, fingerprint, description string) Status {
+	k := key(server, tool)
+	s.mu.Lock()
+	trust := s.fleet[server].Trust
+	if trust == TrustBlocked {
+		s.mu.Unlock()
+		return StatusBlocked
+	}
+	approved, ok := s.approvals[k]
+	if ok && approved.Fingerprint == fingerprint {
+		s.mu.Unlock()
+		return StatusApproved
+	}
+	status := StatusUnknown
+	if ok {
+		status = StatusChanged
+	}
+	p, seen := s.pending[k]
+	fresh := !seen || p.Fingerprint != fingerprint
+	if fresh {
+		if len(description) > maxDescription {
+			description = description[:maxDescription]
+		}
+		p = Pending{
+			Server:      server,
+			Tool:        tool,
+			Fingerprint: fingerprint,
+			Status:      status.String(),
+			Previous:    approved.Fingerprint,
+			Description: description,
+			FirstSeen:   time.Now().UTC(),
+		}
+		s.pending[k] = p
+		if status == StatusChanged {
+			log.Printf("audit: tofu: tool %q of server %q changed since approval (%s, was %s) and awaits approval", tool, server, fingerprint, approved.Fingerprint)
+		} else {
+			log.Printf("audit: tofu: %s tool %q of server %q awaits approval (%s)", status, tool, server, fingerprint)
+		}
+	}
+	s.mu.Unlock()
+
+	if status == StatusUnknown && trust == TrustTrusted {
+		// The fleet trusts the server's tools on first sight
+		if err := s.Approve(server, tool, fingerprint, s.fleetBy); err != nil {
+			log.Printf("tofu: approval of %q not saved: %v", tool, err)
+		}
+		return StatusApproved
+	}
+
+	// Each fingerprint is put to the operator once
+	if s.prompt == nil || !fresh || status == StatusChanged && s.onChange == ChangeBlock {
+		return status
+	}
+	s.promptMu.Lock()
+	accepted := s.prompt(p)
+	s.promptMu.Unlock()
+	if !accepted {
+		return status
+	}
+	if err := s.Approve(server, tool, fingerprint, "prompt"); err != nil {
+		log.Printf("tofu: approval of %q not saved: %v", tool, err)
+	}
+	return StatusApproved
+}
+
+// Approve approves a fingerprint and persists it.
+//
+// # Arguments
+//   - server, tool: The pair to approve
+//   - fingerprint: The fingerprint to approve; "" approves the pending
+//     one, unless it changed an approved tool under ChangeBlock
+//   - by: Who approved it, recorded in the approval
+//
+// # Returns
+//   - ErrNotPending if fingerprint is "" and nothing is pending
+//   - ErrExplicitChange if fingerprint is "" and the pending one must
+//     be named
+//   - ErrFleetBlocked if the fleet manifest blocks the server
+//   - A write error if the approval could not be persisted; the
+//     approval still applies to this process
+func (s *Store) Approve(server, tool, fingerprint, by string) error {
+	if server == "" || tool == "" {
+		return ErrInvalidIdentity
+	}
+	k := key(server, tool)
+	s.mu.Lock()
+	if s.fleet[server].Trust == TrustBlocked {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrFleetBlocked, server)
+	}
+	if fingerprint == "" {
+		p, ok := s.pending[k]
+		if !ok {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %s/%s", ErrNotPending, server, tool)
+		}
+		if p.Previous != "" && s.onChange == ChangeBlock {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %s/%s changed from %s", ErrExplicitChange, server, tool, p.Previous)
+		}
+		fingerprint = p.Fingerprint
+	}
+	a := Approval{Server: server, Tool: tool, Fingerprint: fingerprint, ApprovedAt: time.Now().UTC(), ApprovedBy: by}
+	s.approvals[k] = a
+	if p, ok := s.pending[k]; ok && p.Fingerprint == fingerprint {
+		delete(s.pending, k)
+	}
+	err := s.saveLocked()
+	subs := make([]func(Approval), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subs = append(subs, fn)
+	}
+	s.mu.Unlock()
+
+	log.Printf("audit: tofu: tool %q of server %q approved by %s (%s)", tool, server, by, fingerprint)
+	for _, fn := range subs {
+		fn(a)
+	}
+	return err
+}
+
+// Revoke removes an approval so the tool needs approval again.
+func (s *Store) Revoke(server, tool string) error {
+	k := key(server, tool)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.approvals[k]; !ok {
+		return fmt.Errorf("%w: %s/%s", ErrUnknownTool, server, tool)
+	}
+	delete(s.approvals, k)
+	log.Printf("audit: tofu: approval of tool %q of server %q revoked", tool, server)
+	return s.saveLocked()
+}
+
+// Pending returns the fingerprints awaiting approval, oldest first.
+func (s *Store) Pending() []Pending {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Pending, 0, len(s.pending))
+	for _, p := range s.pending {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].FirstSeen.Equal(out[j].FirstSeen) {
+			return out[i].FirstSeen.Before(out[j].FirstSeen)
+		}
+		return key(out[i].Server, out[i].Tool) < key(out[j].Server, out[j].Tool)
+	})
+	return out
+}
+
+// Approvals returns the approved fingerprints sorted by server and tool.
+func (s *Store) Approvals() []Approval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedLocked()
+}
+
+func (s *Store) sortedLocked() []Approval {
+	out := make([]Approval, 0, len(s.approvals))
+	for _, a := range s.approvals {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return key(out[i].Server, out[i].Tool) < key(out[j].Server, out[j].Tool)
+	})
+	return out
+}
+
+// Subscribe calls fn after every approval until cancel is called.
+func (s *Store) Subscribe(fn func(Approval)) (cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextSub
+	s.nextSub++
+	s.subscribers[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// saveLocked writes the approvals atomically. Caller must hold s.mu.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(file{Version: fileVersion, Approvals: s.sortedLocked()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("tofu: encode approvals: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tofu-*")
+	if err != nil {
+		return fmt.Errorf("tofu: save approvals: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("tofu: save approvals: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("tofu: save approvals: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("tofu: save approvals: %w", err)
+	}
+	return nil
+}
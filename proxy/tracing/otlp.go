@@ -0,0 +1,294 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultServiceName is the service.name resource attribute when the
+// exporter configuration does not set one.
+const DefaultServiceName = "mcp-sentinel-proxy"
+
+// scopeName is the instrumentation scope of every exported span.
+const scopeName = "github.com/newmar1997ma-coder/mcp-sentinel/proxy"
+
+// OTLPConfig configures an OTLPExporter.
+type OTLPConfig struct {
+	// ServiceName is the service.name resource attribute (empty uses
+	// DefaultServiceName)
+	ServiceName string
+
+	// Client sends the requests (nil uses a client with a 10s timeout)
+	Client *http.Client
+
+	// BatchSize is the most spans per request (zero uses 256)
+	BatchSize int
+
+	// FlushInterval is the longest a span waits for its batch to fill
+	// (zero uses 5s)
+	FlushInterval time.Duration
+
+	// QueueSize bounds the spans waiting to be sent; spans beyond it
+	// are dropped (zero uses 4096)
+	QueueSize int
+
+	// Header is added to every request, e.g. an Authorization token
+	Header http.Header
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using the
+// OTLP/HTTP protocol with JSON encoding (Content-Type
+// application/json), as accepted on a collector's /v1/traces endpoint.
+//
+// ExportSpan only queues the span; a background goroutine sends
+// batches, so a slow collector never delays routing. Batches that fail
+// to send are logged and dropped.
+type OTLPExporter struct {
+	url string
+	cfg OTLPConfig
+
+	queue   chan *SpanData
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewOTLPExporter creates an exporter posting to url, the collector's
+// traces endpoint such as http://localhost:4318/v1/traces, and starts
+// its sender. cfg may be nil for defaults.
+func NewOTLPExporter(url string, cfg *OTLPConfig) *OTLPExporter {
+	e := &OTLPExporter{url: url}
+	if cfg != nil {
+		e.cfg = *cfg
+	}
+	if e.cfg.ServiceName == "" {
+		e.cfg.ServiceName = DefaultServiceName
+	}
+	if e.cfg.Client == nil {
+		e.cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if e.cfg.BatchSize <= 0 {
+		e.cfg.BatchSize = 256
+	}
+	if e.cfg.FlushInterval <= 0 {
+		e.cfg.FlushInterval = 5 * time.Second
+	}
+	if e.cfg.QueueSize <= 0 {
+		e.cfg.QueueSize = 4096
+	}
+	e.queue = make(chan *SpanData, e.cfg.QueueSize)
+	e.done = make(chan struct{})
+	go e.run()
+	return e
+}
+
+// ExportSpan implements Exporter by queueing s for the next batch.
+func (e *OTLPExporter) ExportSpan(s *SpanData) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		e.dropped.Add(1)
+		return
+	}
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of spans dropped on a full queue or a
+// failed request.
+func (e *OTLPExporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Close sends the queued spans and stops the sender.
+func (e *OTLPExporter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+	<-e.done
+	return nil
+}
+
+// run sends batches until the queue is closed and drained.
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.post(batch); err != nil {
+			e.dropped.Add(uint64(len(batch)))
+			log.Printf("tracing: dropped %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= e.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// post sends one batch.
+func (e *OTLPExporter) post(batch []*SpanData) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest. IDs are hex
+// strings and 64-bit integers decimal strings, per the OTLP
+// specification.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+// OTLP enum values.
+const (
+	otlpKindInternal = 1
+	otlpStatusError  = 2
+)
+
+// request encodes a batch as one resource and scope.
+func (e *OTLPExporter) request(batch []*SpanData) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attrs),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanID = s.Parent.String()
+		}
+		if s.ErrorMsg != "" {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: s.ErrorMsg}
+		}
+		spans = append(spans, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": e.cfg.ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+// otlpAttributes encodes attributes sorted by key.
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpValue(attrs[k])})
+	}
+	return kvs
+}
+
+// otlpValue encodes an attribute value as an OTLP AnyValue.
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case uint64:
+		return map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
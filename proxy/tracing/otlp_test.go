@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("X-Api-Key") != "k" {
+			t.Errorf("headers = %v", req.Header)
+		}
+		body, _ := io.ReadAll(req.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	exporter := NewOTLPExporter(srv.URL+"/v1/traces", &OTLPConfig{
+		ServiceName:   "edge-proxy",
+		FlushInterval: time.Hour,
+		Header:        http.Header{"X-Api-Key": {"k"}},
+	})
+	tracer := New(exporter, nil)
+	ctx, root := tracer.Start(context.Background(), "route")
+	root.SetAttribute("rpc.method", "tools/call")
+	root.SetAttribute("sentinel.allowed", false)
+	root.SetAttribute("gas", 42)
+	root.SetAttribute("risk", 0.7)
+	_, child := Start(ctx, "forward")
+	child.SetError(errors.New("upstream closed"))
+	child.End()
+	root.End()
+	tracer.Close()
+
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string         `json:"traceId"`
+					SpanID       string         `json:"spanId"`
+					ParentSpanID string         `json:"parentSpanId"`
+					Name         string         `json:"name"`
+					Start        string         `json:"startTimeUnixNano"`
+					Attributes   []otlpKeyValue `json:"attributes"`
+					Status       *otlpStatus    `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatalf("malformed request: %v", err)
+	}
+	rs := req.ResourceSpans[0]
+	if a := rs.Resource.Attributes; len(a) != 1 || a[0].Key != "service.name" || a[0].Value["stringValue"] != "edge-proxy" {
+		t.Errorf("resource = %+v", a)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, expected 2", len(spans))
+	}
+	fwd, route := spans[0], spans[1]
+	if route.TraceID != root.SpanContext().TraceID.String() || route.SpanID != root.SpanContext().SpanID.String() || route.ParentSpanID != "" {
+		t.Errorf("route span IDs = %+v", route)
+	}
+	if fwd.ParentSpanID != route.SpanID || fwd.Status == nil || fwd.Status.Code != otlpStatusError || fwd.Status.Message != "upstream closed" {
+		t.Errorf("forward span = %+v", fwd)
+	}
+	values := map[string]map[string]interface{}{}
+	for _, kv := range route.Attributes {
+		values[kv.Key] = kv.Value
+	}
+	if values["rpc.method"]["stringValue"] != "tools/call" || values["sentinel.allowed"]["boolValue"] != false ||
+		values["gas"]["intValue"] != "42" || values["risk"]["doubleValue"] != 0.7 {
+		t.Errorf("attributes = %v", values)
+	}
+	if route.Start == "" || route.Status != nil {
+		t.Errorf("route span = %+v", route)
+	}
+
+	// Spans after Close are dropped
+	_, late := tracer.Start(context.Background(), "late")
+	late.End()
+	if exporter.Dropped() != 1 {
+		t.Errorf("Dropped = %d, expected 1", exporter.Dropped())
+	}
+}
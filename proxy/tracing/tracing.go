@@ -0,0 +1,317 @@
+// Package tracing records OpenTelemetry-compatible spans for the routing
+// pipeline and exports them to an OTLP collector.
+//
+// The proxy depends on nothing outside the standard library, so rather
+// than importing the OpenTelemetry SDK this package implements the part
+// of it the proxy needs: spans with attributes and an error status,
+// ratio-based sampling, W3C Trace Context propagation, and an exporter
+// for the OTLP/HTTP JSON encoding that collectors accept on /v1/traces
+// (see OTLPExporter).
+//
+// # Usage
+//
+//	ctx, span := tracer.Start(ctx, "route")
+//	defer span.End()
+//
+//	// Elsewhere, below the same ctx: a child of the span in ctx
+//	ctx, child := tracing.Start(ctx, "registry")
+//	child.SetAttribute("mcp.tool", tool)
+//	child.End()
+//
+// A nil *Tracer and a nil *Span are valid and record nothing, so code
+// on the routing path never checks whether tracing is enabled.
+//
+// # Propagation
+//
+// Traceparent and Inject write the span in a context as a W3C
+// traceparent header; ParseTraceparent and ContextWithRemote continue a
+// trace started by a caller.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTraceparent is returned for a malformed traceparent header.
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the ID in lower-case hex.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the ID in lower-case hex.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled reports whether the trace is recorded
+	Sampled bool
+}
+
+// IsValid reports whether sc has a trace and a span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Name     string
+	Context  SpanContext
+	Parent   SpanID // zero for a root span
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]interface{}
+	ErrorMsg string // non-empty marks the span failed
+}
+
+// Exporter receives finished, sampled spans. ExportSpan must not block
+// the caller for long; the span is not modified afterwards.
+type Exporter interface {
+	ExportSpan(s *SpanData)
+	Close() error
+}
+
+// Config configures a Tracer.
+type Config struct {
+	// SampleRatio is the fraction of new traces recorded, from 0 to 1
+	// (zero records every trace). Traces continued from a remote
+	// parent follow the parent's decision.
+	SampleRatio float64
+}
+
+// Tracer starts spans and hands finished ones to its exporter.
+//
+// # Thread Safety
+//
+// Tracer and Span are safe for concurrent use.
+type Tracer struct {
+	exporter Exporter
+	ratio    float64
+}
+
+// New creates a tracer exporting to exporter. cfg may be nil to record
+// every trace.
+func New(exporter Exporter, cfg *Config) *Tracer {
+	t := &Tracer{exporter: exporter, ratio: 1}
+	if cfg != nil && cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		t.ratio = cfg.SampleRatio
+	}
+	return t
+}
+
+// Close closes the exporter, sending the spans it holds.
+func (t *Tracer) Close() error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+// Start starts a span named name as a child of the span or remote span
+// context in ctx, or as the root of a new trace. It returns a context
+// carrying the new span. On a nil Tracer it returns ctx and a nil span.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{Name: name, Start: time.Now()}}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		s.data.Context.TraceID, s.data.Parent = parent.TraceID, parent.SpanID
+		s.data.Context.Sampled = parent.Sampled
+	} else {
+		s.data.Context.TraceID = newTraceID()
+		s.data.Context.Sampled = t.ratio >= 1 || rand.Float64() < t.ratio
+	}
+	s.data.Context.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Start starts a child of the span in ctx with that span's tracer. It
+// returns ctx and a nil span when ctx carries no span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name)
+}
+
+// Span is one timed operation of a trace.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span's IDs; the zero value for a nil span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.Context
+}
+
+// SetAttribute records a key-value attribute. Strings, bools, integers,
+// and floats are exported as such; other values as fmt.Sprint text.
+// Attributes set after End are ignored.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.data.Attrs == nil {
+		s.data.Attrs = make(map[string]interface{})
+	}
+	s.data.Attrs[key] = value
+}
+
+// SetError marks the span failed with err's message. A nil err does
+// nothing.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.ErrorMsg = err.Error()
+}
+
+// End finishes the span and exports it if sampled. Later calls do
+// nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.Context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(&data)
+	}
+}
+
+// spanKey is the context key of the current span.
+type spanKey struct{}
+
+// remoteKey is the context key of a remote parent's SpanContext.
+type remoteKey struct{}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the context of the span in ctx, or of
+// the remote parent set by ContextWithRemote.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := FromContext(ctx); s != nil {
+		return s.SpanContext()
+	}
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemote returns a context whose next span continues the
+// trace of a remote parent.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Traceparent returns the W3C traceparent header value for the span in
+// ctx, or "" when there is none.
+func Traceparent(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Inject sets the traceparent header of h from ctx, if ctx carries a
+// span.
+func Inject(ctx context.Context, h http.Header) {
+	if tp := Traceparent(ctx); tp != "" {
+		h.Set("traceparent", tp)
+	}
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	// version-traceid-spanid-flags: 2+1+32+1+16+1+2
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, value)
+	}
+	if value[:2] == "ff" || (value[:2] == "00" && len(value) != 55) {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, value)
+	}
+	var flags [1]byte
+	_, err1 := hex.Decode(sc.TraceID[:], []byte(value[3:35]))
+	_, err2 := hex.Decode(sc.SpanID[:], []byte(value[36:52]))
+	_, err3 := hex.Decode(flags[:], []byte(value[53:55]))
+	if err1 != nil || err2 != nil || err3 != nil || !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w: %q", ErrInvalidTraceparent, value)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// newTraceID returns a random non-zero trace ID.
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+// newSpanID returns a random non-zero span ID.
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
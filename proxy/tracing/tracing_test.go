@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recorder keeps every exported span.
+type recorder struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (r *recorder) ExportSpan(s *SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *recorder) Close() error { return nil }
+
+func TestTracer_ParentsAndAttributes(t *testing.T) {
+	rec := &recorder{}
+	tracer := New(rec, nil)
+
+	ctx, root := tracer.Start(context.Background(), "route")
+	childCtx, child := Start(ctx, "check")
+	_, grandchild := Start(childCtx, "sentinel")
+	grandchild.SetError(errors.New("backend down"))
+	grandchild.End()
+	child.SetAttribute("mcp.tool", "read_file")
+	child.End()
+	root.End()
+	root.End() // a second End is ignored
+	root.SetAttribute("late", true)
+
+	if len(rec.spans) != 3 {
+		t.Fatalf("exported %d spans, expected 3", len(rec.spans))
+	}
+	g, c, r := rec.spans[0], rec.spans[1], rec.spans[2]
+	if r.Parent != (SpanID{}) || c.Parent != r.Context.SpanID || g.Parent != c.Context.SpanID {
+		t.Errorf("parents: root %v, child %v (root %v), grandchild %v (child %v)",
+			r.Parent, c.Parent, r.Context.SpanID, g.Parent, c.Context.SpanID)
+	}
+	if c.Context.TraceID != r.Context.TraceID || g.Context.TraceID != r.Context.TraceID {
+		t.Error("spans of one trace have different trace IDs")
+	}
+	if c.Attrs["mcp.tool"] != "read_file" || g.ErrorMsg != "backend down" || r.Attrs["late"] != nil {
+		t.Errorf("child %+v, grandchild %+v, root %+v", c, g, r)
+	}
+	if r.End.Before(r.Start) {
+		t.Errorf("root ends before it starts: %v - %v", r.Start, r.End)
+	}
+}
+
+func TestTracer_DisabledAndSampling(t *testing.T) {
+	// A nil tracer and spans outside a trace record nothing
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "route")
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatal("nil tracer started a span")
+	}
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("x"))
+	span.End()
+	if _, s := Start(context.Background(), "orphan"); s != nil {
+		t.Error("Start without a parent span returned a span")
+	}
+
+	// Unsampled traces propagate but are not exported
+	rec := &recorder{}
+	sampled := New(rec, &Config{SampleRatio: 1e-12})
+	ctx, root := sampled.Start(context.Background(), "route")
+	_, child := Start(ctx, "check")
+	child.End()
+	root.End()
+	if len(rec.spans) != 0 || root.SpanContext().Sampled {
+		t.Errorf("unsampled trace exported %d spans", len(rec.spans))
+	}
+
+	// A remote parent's decision wins over the ratio
+	remote := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true}
+	_, span = sampled.Start(ContextWithRemote(context.Background(), remote), "route")
+	span.End()
+	if len(rec.spans) != 1 || rec.spans[0].Context.TraceID != remote.TraceID || rec.spans[0].Parent != remote.SpanID {
+		t.Errorf("remote parent not continued: %+v", rec.spans)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	ctx, span := New(&recorder{}, nil).Start(context.Background(), "route")
+	header := Traceparent(ctx)
+	sc, err := ParseTraceparent(header)
+	if err != nil || sc != span.SpanContext() {
+		t.Fatalf("round trip of %q = %+v, %v, expected %+v", header, sc, err, span.SpanContext())
+	}
+	if Traceparent(context.Background()) != "" {
+		t.Error("traceparent without a span")
+	}
+
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseTraceparent(tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseTraceparent(%q) = %v, expected valid %t", tt.value, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("ParseTraceparent(%q) = %v, expected ErrInvalidTraceparent", tt.value, err)
+		}
+	}
+}
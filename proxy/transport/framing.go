@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultFrameBufferSize is the FrameWriter buffer size when the flush
+// policy does not set one.
+const DefaultFrameBufferSize = 64 * 1024
+
+// newline terminates every NDJSON frame.
+var newline = []byte{'\n'}
+
+// FlushPolicy controls when a FrameWriter writes buffered frames,
+// trading latency for throughput. A nil policy flushes every frame.
+type FlushPolicy struct {
+	// Delay holds frames for up to this long so that a burst of
+	// messages leaves in one write (zero flushes every frame at once)
+	Delay time.Duration
+
+	// BufferSize is the most bytes held before a flush (zero uses
+	// DefaultFrameBufferSize); larger frames bypass the buffer
+	BufferSize int
+}
+
+// FrameWriter writes newline-terminated frames without allocating per
+// frame.
+//
+// Frames that fit the buffer are copied into it and leave in a single
+// Write, instead of the two a frame and its terminator would take.
+// Larger frames are written with the terminator as net.Buffers, which
+// is one writev on writers that support it, such as network
+// connections.
+//
+// # Errors
+//
+// A failed write is sticky: every later WriteFrame and Flush returns
+// it, since the peer has lost frame boundaries. With a Delay, the error
+// of a background flush is returned by the next call.
+//
+// # Thread Safety
+//
+// FrameWriter is safe for concurrent use.
+type FrameWriter struct {
+	w     io.Writer
+	delay time.Duration
+
+	mu    sync.Mutex
+	buf   []byte
+	vec   net.Buffers // vectored write, backed by vecs
+	vecs  [2][]byte
+	timer *time.Timer
+	armed bool // timer is pending
+	err   error
+}
+
+// NewFrameWriter creates a frame writer on w with the given flush
+// policy (nil flushes every frame).
+func NewFrameWriter(w io.Writer, policy *FlushPolicy) *FrameWriter {
+	size := DefaultFrameBufferSize
+	f := &FrameWriter{w: w}
+	if policy != nil {
+		f.delay = policy.Delay
+		if policy.BufferSize > 0 {
+			size = policy.BufferSize
+		}
+	}
+	f.buf = make([]byte, 0, size)
+	return f
+}
+
+// WriteFrame writes data followed by a newline. data is not retained.
+// The caller must ensure data contains no newline.
+func (f *FrameWriter) WriteFrame(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	if len(f.buf)+len(data)+1 > cap(f.buf) {
+		if err := f.flushLocked(); err != nil {
+			return err
+		}
+	}
+	if len(data)+1 > cap(f.buf) {
+		f.vecs = [2][]byte{data, newline}
+		f.vec = f.vecs[:]
+		_, err := f.vec.WriteTo(f.w)
+		f.vecs = [2][]byte{}
+		return f.fail(err)
+	}
+
+	f.buf = append(f.buf, data...)
+	f.buf = append(f.buf, '\n')
+	if f.delay <= 0 {
+		return f.flushLocked()
+	}
+	if !f.armed {
+		f.armed = true
+		if f.timer == nil {
+			f.timer = time.AfterFunc(f.delay, f.timerFlush)
+		} else {
+			f.timer.Reset(f.delay)
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered frames.
+func (f *FrameWriter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.armed {
+		f.timer.Stop()
+		f.armed = false
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return f.flushLocked()
+}
+
+// Buffered returns the number of bytes waiting for a flush.
+func (f *FrameWriter) Buffered() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.buf)
+}
+
+// timerFlush flushes frames held for the policy delay.
+func (f *FrameWriter) timerFlush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.armed = false
+	if f.err == nil {
+		f.flushLocked()
+	}
+}
+
+// flushLocked writes the buffer. Caller must hold f.mu.
+func (f *FrameWriter) flushLocked() error {
+	if len(f.buf) == 0 {
+		return nil
+	}
+	_, err := f.w.Write(f.buf)
+	f.buf = f.buf[:0]
+	return f.fail(err)
+}
+
+// fail records err as sticky and returns it. Caller must hold f.mu.
+func (f *FrameWriter) fail(err error) error {
+	if err != nil {
+		f.err = err
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordWriter records each Write call.
+type recordWriter struct {
+	mu     sync.Mutex
+	writes []string
+	err    error
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *recordWriter) Close() error { return nil }
+
+func (w *recordWriter) calls() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.writes...)
+}
+
+func TestFrameWriter_Policies(t *testing.T) {
+	big := strings.Repeat("x", 40)
+	tests := []struct {
+		name   string
+		policy *FlushPolicy
+		frames []string
+		writes []string
+	}{
+		{"nil flushes every frame", nil, []string{"a", "b"}, []string{"a\n", "b\n"}},
+		{"delay batches frames", &FlushPolicy{Delay: time.Hour}, []string{"a", "b", "c"}, []string{"a\nb\nc\n"}},
+		{"full buffer flushes", &FlushPolicy{Delay: time.Hour, BufferSize: 4}, []string{"a", "b", "c"}, []string{"a\nb\n", "c\n"}},
+		{"large frame bypasses the buffer in order", &FlushPolicy{Delay: time.Hour, BufferSize: 16}, []string{"a", big, "b"}, []string{"a\n", big, "\n", "b\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordWriter{}
+			f := NewFrameWriter(w, tt.policy)
+			for _, frame := range tt.frames {
+				if err := f.WriteFrame([]byte(frame)); err != nil {
+					t.Fatalf("WriteFrame failed: %v", err)
+				}
+			}
+			if err := f.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if got := w.calls(); strings.Join(got, "|") != strings.Join(tt.writes, "|") {
+				t.Errorf("writes = %q, expected %q", got, tt.writes)
+			}
+		})
+	}
+}
+
+func TestFrameWriter_DelayFlushes(t *testing.T) {
+	w := &recordWriter{}
+	f := NewFrameWriter(w, &FlushPolicy{Delay: 5 * time.Millisecond})
+	f.WriteFrame([]byte("a"))
+	f.WriteFrame([]byte("b"))
+	if f.Buffered() != 4 {
+		t.Fatalf("Buffered = %d, expected 4", f.Buffered())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(w.calls()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := w.calls(); len(got) != 1 || got[0] != "a\nb\n" {
+		t.Fatalf("writes = %q", got)
+	}
+
+	// The timer is armed again by the next frame
+	f.WriteFrame([]byte("c"))
+	for len(w.calls()) == 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := w.calls(); len(got) != 2 || got[1] != "c\n" {
+		t.Errorf("writes = %q", got)
+	}
+}
+
+func TestFrameWriter_StickyError(t *testing.T) {
+	broken := errors.New("broken pipe")
+	w := &recordWriter{err: broken}
+	f := NewFrameWriter(w, nil)
+	if err := f.WriteFrame([]byte("a")); !errors.Is(err, broken) {
+		t.Fatalf("WriteFrame error = %v", err)
+	}
+	w.err = nil
+	if err := f.WriteFrame([]byte("b")); !errors.Is(err, broken) {
+		t.Errorf("WriteFrame after failure = %v, expected sticky error", err)
+	}
+	if err := f.Flush(); !errors.Is(err, broken) {
+		t.Errorf("Flush after failure = %v, expected sticky error", err)
+	}
+	if len(w.calls()) != 0 {
+		t.Errorf("wrote after failure: %q", w.calls())
+	}
+}
+
+func TestFrameWriter_NoAllocations(t *testing.T) {
+	msg := []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`)
+	for _, policy := range []*FlushPolicy{nil, {Delay: time.Hour, BufferSize: 1024}, {BufferSize: 8}} {
+		f := NewFrameWriter(io.Discard, policy)
+		if allocs := testing.AllocsPerRun(100, func() { f.WriteFrame(msg) }); allocs != 0 {
+			t.Errorf("policy %+v: %v allocations per frame", policy, allocs)
+		}
+	}
+}
+
+func TestStdioTransport_CloseFlushes(t *testing.T) {
+	w := &recordWriter{}
+	tr := NewStdioTransportWithConfig(w, io.NopCloser(bytes.NewReader(nil)), &FlushPolicy{Delay: time.Hour})
+	if err := tr.Send([]byte(`{"id":1}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(w.calls()) != 0 {
+		t.Fatalf("message written before flush: %q", w.calls())
+	}
+	if err := tr.Send([]byte("a\nb")); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("embedded newline: %v, expected ErrInvalidMessage", err)
+	}
+	tr.Close()
+	if got := w.calls(); len(got) != 1 || got[0] != "{\"id\":1}\n" {
+		t.Errorf("writes after Close = %q", got)
+	}
+}
+
+// countWriter counts Write calls, standing in for syscalls.
+type countWriter struct{ n int }
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n++
+	return len(p), nil
+}
+
+func (w *countWriter) Close() error { return nil }
+
+func BenchmarkStdioSend(b *testing.B) {
+	msg := bytes.Repeat([]byte("x"), 512)
+	for _, bench := range []struct {
+		name   string
+		policy *FlushPolicy
+	}{
+		{"immediate", nil},
+		{"delayed", &FlushPolicy{Delay: time.Millisecond}},
+		{"oversize", &FlushPolicy{BufferSize: 256}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			w := &countWriter{}
+			tr := NewStdioTransportWithConfig(w, io.NopCloser(bytes.NewReader(nil)), bench.policy)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg) + 1))
+			for i := 0; i < b.N; i++ {
+				if err := tr.Send(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+			tr.Close()
+			b.ReportMetric(float64(w.n)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+// BenchmarkAppendWrite is the framing StdioTransport used before
+// FrameWriter, for comparison.
+func BenchmarkAppendWrite(b *testing.B) {
+	msg := bytes.Repeat([]byte("x"), 512)
+	w := &countWriter{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg) + 1))
+	for i := 0; i < b.N; i++ {
+		w.Write(append(msg, '\n'))
+	}
+	b.ReportMetric(float64(w.n)/float64(b.N), "writes/op")
+}
@@ -0,0 +1,34 @@
+package transport
+
+// Transport names returned by Name.
+const (
+	NameStdio      = "stdio"
+	NameSSE        = "sse"
+	NameStreamable = "streamable-http"
+	NameWebSocket  = "websocket"
+)
+
+// Named is implemented by transports outside this package that report
+// their name to Name.
+type Named interface {
+	TransportName() string
+}
+
+// Name returns the name of a transport for logs and middleware: one of
+// the Name constants for the transports of this package, the name a
+// Named transport reports, or "" if unknown.
+func Name(t Transport) string {
+	switch t := t.(type) {
+	case *StdioTransport, *ServerProcess:
+		return NameStdio
+	case *SSETransport:
+		return NameSSE
+	case *StreamableHTTPTransport, *StreamableHTTPServer:
+		return NameStreamable
+	case *WebSocketTransport:
+		return NameWebSocket
+	case Named:
+		return t.TransportName()
+	}
+	return ""
+}
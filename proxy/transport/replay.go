@@ -0,0 +1,167 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DefaultReplayWindow is the number of event IDs and message digests a
+// ReplayGuard remembers.
+const DefaultReplayWindow = 4096
+
+// ReplayKind classifies a detected replay.
+type ReplayKind string
+
+const (
+	// ReplayDuplicateID is an event whose SSE id was already delivered
+	ReplayDuplicateID ReplayKind = "duplicate-id"
+	// ReplayDuplicateContent is a JSON-RPC message with an id that was
+	// already delivered byte-for-byte under a different event ID
+	ReplayDuplicateContent ReplayKind = "duplicate-content"
+)
+
+// Replay describes a dropped event suspected of being replayed.
+type Replay struct {
+	Kind    ReplayKind `json:"kind"`
+	EventID string     `json:"event_id,omitempty"`
+	Digest  string     `json:"digest"`
+}
+
+// String returns a short description for logs and incident records.
+func (r Replay) String() string {
+	if r.EventID != "" {
+		return fmt.Sprintf("sse replay (%s): event %q digest %s", r.Kind, r.EventID, r.Digest[:16])
+	}
+	return fmt.Sprintf("sse replay (%s): digest %s", r.Kind, r.Digest[:16])
+}
+
+// ReplayGuard detects replayed or duplicated events on one upstream
+// event stream.
+//
+// Two things are tracked over a bounded window:
+//   - SSE event IDs: an ID seen twice is a replay
+//   - Digests of JSON-RPC messages carrying an id (responses and
+//     server-initiated requests): identical content re-delivered under a
+//     new event ID is a replay. Notifications are exempt, since servers
+//     legitimately repeat them.
+//
+// # Resume Semantics
+//
+// After a reconnect with Last-Event-ID a server may legitimately
+// redeliver events the client already has. Resume opens a window in
+// which already-seen events are dropped silently instead of flagged; it
+// closes at the first event that has not been seen before.
+//
+// # Thread Safety
+//
+// ReplayGuard is safe for concurrent use.
+type ReplayGuard struct {
+	window int
+
+	mu       sync.Mutex
+	ids      map[string]struct{}
+	idOrder  []string
+	digests  map[string]struct{}
+	dgOrder  []string
+	lastID   string
+	resuming bool
+}
+
+// NewReplayGuard creates a guard remembering up to window IDs and
+// digests. window <= 0 uses DefaultReplayWindow.
+func NewReplayGuard(window int) *ReplayGuard {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	return &ReplayGuard{
+		window:  window,
+		ids:     make(map[string]struct{}),
+		digests: make(map[string]struct{}),
+	}
+}
+
+// Resume marks the start of a resumed stream.
+func (g *ReplayGuard) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.resuming = true
+}
+
+// LastEventID returns the most recent delivered event ID, for use as the
+// Last-Event-ID header on reconnect.
+func (g *ReplayGuard) LastEventID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastID
+}
+
+// Observe decides whether an event should be delivered.
+//
+// # Arguments
+//   - id: The event's SSE id field ("" if absent)
+//   - data: The event's data payload
+//
+// # Returns
+//   - true if the event is new and should be delivered
+//   - A non-nil Replay if the event was dropped as a suspected replay;
+//     nil for benign redelivery during resume
+func (g *ReplayGuard) Observe(id string, data []byte) (bool, *Replay) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	tracked := hasMessageID(data)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, seenID := g.ids[id]
+	seenID = seenID && id != ""
+	_, seenContent := g.digests[digest]
+	seenContent = seenContent && tracked
+
+	if seenID || seenContent {
+		if g.resuming {
+			return false, nil
+		}
+		kind := ReplayDuplicateID
+		if !seenID {
+			kind = ReplayDuplicateContent
+		}
+		return false, &Replay{Kind: kind, EventID: id, Digest: digest}
+	}
+
+	g.resuming = false
+	if id != "" {
+		g.lastID = id
+		g.idOrder = remember(g.ids, g.idOrder, id, g.window)
+	}
+	if tracked {
+		g.dgOrder = remember(g.digests, g.dgOrder, digest, g.window)
+	}
+	return true, nil
+}
+
+// remember adds key to a bounded set, evicting the oldest entry.
+func remember(set map[string]struct{}, order []string, key string, window int) []string {
+	set[key] = struct{}{}
+	order = append(order, key)
+	if len(order) > window {
+		delete(set, order[0])
+		order = order[1:]
+	}
+	return order
+}
+
+// hasMessageID reports whether data is a JSON-RPC message with a
+// non-null id.
+func hasMessageID(data []byte) bool {
+	var msg struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(data, &msg) != nil {
+		return false
+	}
+	return len(msg.ID) > 0 && string(msg.ID) != "null"
+}
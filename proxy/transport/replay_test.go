@@ -0,0 +1,99 @@
+package transport
+
+import "testing"
+
+func TestReplayGuard_Observe(t *testing.T) {
+	type event struct {
+		id      string
+		data    string
+		deliver bool
+		replay  ReplayKind
+	}
+	tests := []struct {
+		name   string
+		resume int // index before which Resume is called (-1 for never)
+		events []event
+	}{
+		{
+			name:   "distinct events",
+			resume: -1,
+			events: []event{
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, true, ""},
+				{"2", `{"jsonrpc":"2.0","id":2,"result":{}}`, true, ""},
+			},
+		},
+		{
+			name:   "duplicate event id",
+			resume: -1,
+			events: []event{
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, true, ""},
+				{"1", `{"jsonrpc":"2.0","id":2,"result":{}}`, false, ReplayDuplicateID},
+			},
+		},
+		{
+			name:   "identical response under new id",
+			resume: -1,
+			events: []event{
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, true, ""},
+				{"2", `{"jsonrpc":"2.0","id":1,"result":{}}`, false, ReplayDuplicateContent},
+			},
+		},
+		{
+			name:   "identical notifications are not replays",
+			resume: -1,
+			events: []event{
+				{"", `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`, true, ""},
+				{"", `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`, true, ""},
+			},
+		},
+		{
+			name:   "redelivery during resume is dropped silently",
+			resume: 1,
+			events: []event{
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, true, ""},
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, false, ""},
+				{"2", `{"jsonrpc":"2.0","id":2,"result":{}}`, true, ""},
+				{"1", `{"jsonrpc":"2.0","id":1,"result":{}}`, false, ReplayDuplicateID},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewReplayGuard(0)
+			for i, e := range tt.events {
+				if i == tt.resume {
+					g.Resume()
+				}
+				deliver, replay := g.Observe(e.id, []byte(e.data))
+				if deliver != e.deliver {
+					t.Errorf("event %d: deliver = %v, expected %v", i, deliver, e.deliver)
+				}
+				switch {
+				case e.replay == "" && replay != nil:
+					t.Errorf("event %d: unexpected replay %s", i, replay)
+				case e.replay != "" && (replay == nil || replay.Kind != e.replay):
+					t.Errorf("event %d: expected %s replay, got %v", i, e.replay, replay)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayGuard_WindowEviction(t *testing.T) {
+	g := NewReplayGuard(2)
+	for _, id := range []string{"a", "b", "c"} {
+		if ok, _ := g.Observe(id, []byte(`{}`)); !ok {
+			t.Fatalf("event %s should be delivered", id)
+		}
+	}
+	if ok, _ := g.Observe("a", []byte(`{}`)); !ok {
+		t.Error("evicted id should no longer be tracked")
+	}
+	if ok, _ := g.Observe("c", []byte(`{}`)); ok {
+		t.Error("recent id should still be tracked")
+	}
+	if g.LastEventID() != "a" {
+		t.Errorf("LastEventID = %q, expected a", g.LastEventID())
+	}
+}
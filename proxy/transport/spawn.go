@@ -0,0 +1,513 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+)
+
+// ProxyEnvPrefix starts the names of the proxy's own environment
+// variables, such as its chain, attestation, and admin secrets.
+const ProxyEnvPrefix = "MCP_SENTINEL_"
+
+// ServerEnv returns the environment for a server process: the proxy's
+// environment without its own ProxyEnvPrefix variables, plus extra
+// KEY=VALUE entries.
+//
+// # Security Notes
+//
+// Servers are untrusted. Inheriting the chain key would let one forge
+// the records that tell a downstream proxy checks already ran, and the
+// admin token would open the admin API to it.
+func ServerEnv(extra ...string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, ProxyEnvPrefix) {
+			env = append(env, kv)
+		}
+	}
+	return append(env, extra...)
+}
+
+// Subprocess errors.
+var (
+	ErrServerDown   = errors.New("transport: server process not running")
+	ErrServerExited = errors.New("transport: server process exited")
+)
+
+// RestartPolicy controls restarting a crashed server process.
+//
+// Delays double from InitialBackoff up to MaxBackoff. A process that
+// stayed up for at least ResetAfter is considered healthy again, and
+// the next crash starts over from InitialBackoff.
+type RestartPolicy struct {
+	// MaxRestarts is the number of consecutive restarts allowed before
+	// giving up (0 for unlimited)
+	MaxRestarts int
+
+	// InitialBackoff is the delay before the first restart
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the restart delay
+	MaxBackoff time.Duration
+
+	// ResetAfter is the uptime after which backoff is reset
+	ResetAfter time.Duration
+}
+
+// DefaultRestartPolicy returns a policy allowing five quick successive
+// restarts with exponential backoff.
+func DefaultRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{
+		MaxRestarts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		ResetAfter:     time.Minute,
+	}
+}
+
+// ExitEvent describes a server process exit.
+type ExitEvent struct {
+	// PID of the process that exited
+	PID int
+
+	// Err is the wait error (nil for a clean exit)
+	Err error
+
+	// Uptime is how long the process ran
+	Uptime time.Duration
+
+	// Restarting reports whether a restart is scheduled
+	Restarting bool
+
+	// Delay is the backoff before the restart
+	Delay time.Duration
+}
+
+// SpawnConfig configures SpawnStdioServerWithConfig.
+type SpawnConfig struct {
+	// Restart restarts the server after it exits (nil never restarts)
+	Restart *RestartPolicy
+
+	// Stderr receives the server's standard error (default os.Stderr)
+	Stderr io.Writer
+
+	// OnExit is called after every exit, before any restart
+	OnExit func(ExitEvent)
+
+	// Flush controls batching of messages to the server's stdin (nil
+	// writes each message at once)
+	Flush *FlushPolicy
+
+	// Sandbox confines every process started, including restarts (nil
+	// runs the server unconfined)
+	Sandbox *sandbox.Profile
+}
+
+// ServerProcess is a Transport to an MCP server running as a child
+// process, which it owns: it starts the process, watches for exit, and
+// restarts it according to the restart policy.
+//
+// # Restarts
+//
+// A restarted server has not seen the session's initialize handshake.
+// The last initialize request and notifications/initialized sent
+// through the transport are replayed to the new process, and the
+// replayed initialize response is discarded, so the client's session
+// continues. Requests in flight when the process died fail with
+// ErrServerExited; Send returns ErrServerDown while a restart is
+// pending.
+//
+// # Security Notes
+//
+// The child inherits the proxy's environment, overlaid with the env
+// passed to SpawnStdioServer. Run the proxy with a minimal environment
+// if the server should not see its credentials. With a Sandbox profile
+// the child is started under it; a Strict profile that cannot be
+// enforced fails the start.
+//
+// # Thread Safety
+//
+// ServerProcess is safe for concurrent use; as with StdioTransport,
+// only one goroutine should call Receive at a time.
+type ServerProcess struct {
+	path string
+	args []string
+	env  []string
+	cfg  SpawnConfig
+
+	mu       sync.Mutex
+	current  *child
+	up       chan struct{} // closed when waiting for a process should end
+	upClosed bool
+	closed   bool
+	gaveUp   bool // no restart will follow the last exit
+	restarts int  // consecutive restarts since the last healthy run
+	total    int
+
+	// handshake holds the initialize request and initialized
+	// notification to replay after a restart
+	initialize  []byte
+	initialized []byte
+}
+
+// child is one running server process.
+type child struct {
+	cmd     *exec.Cmd
+	t       *StdioTransport
+	started time.Time
+	exited  chan struct{}
+}
+
+// SpawnStdioServer starts an MCP server as a child process and returns
+// a transport connected to its stdin and stdout. The server is
+// restarted with DefaultRestartPolicy when it crashes.
+//
+// # Arguments
+//   - cmd: Executable name or path (resolved via PATH)
+//   - args: Command arguments
+//   - env: Variables added to the inherited environment, which lacks
+//     the proxy's own variables (see ServerEnv)
+func SpawnStdioServer(cmd string, args []string, env map[string]string) (*ServerProcess, error) {
+	return SpawnStdioServerWithConfig(cmd, args, env, &SpawnConfig{Restart: DefaultRestartPolicy()})
+}
+
+// SpawnStdioServerWithConfig starts an MCP server with a custom restart
+// policy. A nil cfg never restarts.
+func SpawnStdioServerWithConfig(cmd string, args []string, env map[string]string, cfg *SpawnConfig) (*ServerProcess, error) {
+	path, err := exec.LookPath(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("transport: server command: %w", err)
+	}
+	p := &ServerProcess{
+		path: path,
+		args: args,
+		env:  ServerEnv(),
+		up:   make(chan struct{}),
+	}
+	if cfg != nil {
+		p.cfg = *cfg
+	}
+	if p.cfg.Stderr == nil {
+		p.cfg.Stderr = os.Stderr
+	}
+	for k, v := range env {
+		p.env = append(p.env, k+"="+v)
+	}
+
+	c, err := p.start()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.setCurrentLocked(c)
+	p.mu.Unlock()
+	return p, nil
+}
+
+// start launches a new process.
+func (p *ServerProcess) start() (*child, error) {
+	cmd, err := p.cfg.Sandbox.Command(p.path, p.args...)
+	if err != nil {
+		return nil, fmt.Errorf("transport: server sandbox: %w", err)
+	}
+	cmd.Env = p.env
+	cmd.Stderr = p.cfg.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("transport: server stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("transport: server stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("transport: start server: %w", err)
+	}
+	c := &child{
+		cmd:     cmd,
+		t:       NewStdioTransportWithConfig(stdin, stdout, p.cfg.Flush),
+		started: time.Now(),
+		exited:  make(chan struct{}),
+	}
+	go p.watch(c)
+	return c, nil
+}
+
+// setCurrentLocked makes c the active process. Caller must hold p.mu.
+func (p *ServerProcess) setCurrentLocked(c *child) {
+	p.current = c
+	p.wakeLocked()
+}
+
+// wakeLocked releases Receive calls waiting for a process. Caller must
+// hold p.mu.
+func (p *ServerProcess) wakeLocked() {
+	if !p.upClosed {
+		close(p.up)
+		p.upClosed = true
+	}
+}
+
+// watch waits for c to exit and schedules a restart.
+func (p *ServerProcess) watch(c *child) {
+	err := c.cmd.Wait()
+	close(c.exited)
+	uptime := time.Since(c.started)
+
+	p.mu.Lock()
+	if p.current == c {
+		p.current = nil
+		p.up, p.upClosed = make(chan struct{}), false
+	}
+	ev := ExitEvent{PID: c.cmd.Process.Pid, Err: err, Uptime: uptime}
+	policy := p.cfg.Restart
+	if !p.closed && policy != nil {
+		if policy.ResetAfter > 0 && uptime >= policy.ResetAfter {
+			p.restarts = 0
+		}
+		if policy.MaxRestarts == 0 || p.restarts < policy.MaxRestarts {
+			ev.Restarting = true
+			ev.Delay = backoff(policy, p.restarts)
+			p.restarts++
+		}
+	}
+	if !ev.Restarting {
+		p.gaveUp = true
+		p.wakeLocked()
+	}
+	p.mu.Unlock()
+
+	if p.cfg.OnExit != nil {
+		p.cfg.OnExit(ev)
+	}
+	if ev.Restarting {
+		time.AfterFunc(ev.Delay, p.restart)
+	}
+}
+
+// backoff returns the delay before restart number n (0-based).
+func backoff(policy *RestartPolicy, n int) time.Duration {
+	d := policy.InitialBackoff
+	for i := 0; i < n && d < policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	if policy.MaxBackoff > 0 && d > policy.MaxBackoff {
+		d = policy.MaxBackoff
+	}
+	return d
+}
+
+// restart starts a replacement process and replays the handshake.
+func (p *ServerProcess) restart() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	initialize, initialized := p.initialize, p.initialized
+	p.mu.Unlock()
+
+	c, err := p.start()
+	if err != nil {
+		p.mu.Lock()
+		p.gaveUp = true
+		p.wakeLocked()
+		p.mu.Unlock()
+		if p.cfg.OnExit != nil {
+			p.cfg.OnExit(ExitEvent{Err: err})
+		}
+		return
+	}
+	if initialize != nil {
+		if err := replayHandshake(c.t, initialize, initialized); err != nil {
+			// The replacement is unusable; its exit triggers the next attempt
+			c.cmd.Process.Kill()
+			return
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.t.Close()
+		c.cmd.Process.Kill()
+		return
+	}
+	p.total++
+	p.setCurrentLocked(c)
+}
+
+// replayHandshake re-initializes a restarted server or a new
+// connection, discarding the messages it sends until the initialize
+// response.
+func replayHandshake(t Transport, initialize, initialized []byte) error {
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.Unmarshal(initialize, &req)
+	if err := t.Send(initialize); err != nil {
+		return err
+	}
+	for {
+		data, err := t.Receive()
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if json.Unmarshal(data, &resp) == nil && resp.Method == "" && bytes.Equal(resp.ID, req.ID) {
+			break
+		}
+	}
+	if initialized != nil {
+		return t.Send(initialized)
+	}
+	return nil
+}
+
+// active returns the running process, or nil.
+func (p *ServerProcess) active() (*child, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	return p.current, nil
+}
+
+// Send writes a message to the server's stdin.
+func (p *ServerProcess) Send(data []byte) error {
+	c, err := p.active()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrServerDown
+	}
+	p.rememberHandshake(data)
+	if err := c.t.Send(data); err != nil {
+		select {
+		case <-c.exited:
+			return fmt.Errorf("%w: %v", ErrServerExited, err)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// rememberHandshake records initialize traffic for replay.
+func (p *ServerProcess) rememberHandshake(data []byte) {
+	var msg struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(data, &msg) != nil {
+		return
+	}
+	switch msg.Method {
+	case "initialize":
+		p.mu.Lock()
+		p.initialize, p.initialized = bytes.Clone(data), nil
+		p.mu.Unlock()
+	case "notifications/initialized":
+		p.mu.Lock()
+		p.initialized = bytes.Clone(data)
+		p.mu.Unlock()
+	}
+}
+
+// Receive reads the next message from the server's stdout.
+//
+// While a restart is pending, Receive waits for the replacement. When
+// the process it was reading from exits, it returns ErrServerExited;
+// the next call reads from the replacement.
+func (p *ServerProcess) Receive() ([]byte, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		c, up, gaveUp := p.current, p.up, p.gaveUp
+		p.mu.Unlock()
+
+		if c == nil {
+			if gaveUp {
+				return nil, ErrServerDown
+			}
+			<-up
+			continue
+		}
+
+		data, err := c.t.Receive()
+		if err == nil {
+			return data, nil
+		}
+		if p.isClosed() {
+			return nil, ErrClosed
+		}
+		return nil, fmt.Errorf("%w: %v", ErrServerExited, err)
+	}
+}
+
+func (p *ServerProcess) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// PID returns the running process's ID, or 0 while it is down.
+func (p *ServerProcess) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		return 0
+	}
+	return p.current.cmd.Process.Pid
+}
+
+// Restarts returns how many times the server has been restarted.
+func (p *ServerProcess) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total
+}
+
+// Close stops the server: it closes stdin, giving the process a short
+// grace period to exit, then kills it. No restart follows.
+func (p *ServerProcess) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	c := p.current
+	p.current = nil
+	p.wakeLocked()
+	p.mu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	c.t.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+		<-c.exited
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+)
+
+// TestHelperServer is not a real test: it is the MCP server that the
+// spawn tests launch by re-executing the test binary.
+func TestHelperServer(t *testing.T) {
+	if os.Getenv("SPAWN_HELPER_SERVER") != "1" {
+		return
+	}
+	initialized := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.Unmarshal(scanner.Bytes(), &msg)
+		switch msg.Method {
+		case "crash":
+			os.Exit(3)
+		case "notifications/initialized":
+			initialized = true
+		}
+		if len(msg.ID) > 0 {
+			fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":{"pid":%d,"initialized":%t,"tag":%q,"token":%q,"sandbox":%q}}`+"\n",
+				msg.ID, os.Getpid(), initialized, os.Getenv("SPAWN_TAG"), os.Getenv("MCP_SENTINEL_ADMIN_TOKEN"), os.Getenv("SPAWN_SANDBOX"))
+		}
+	}
+	os.Exit(0)
+}
+
+func spawnHelper(t *testing.T, policy *RestartPolicy) *ServerProcess {
+	t.Helper()
+	return spawnHelperWithConfig(t, &SpawnConfig{Restart: policy})
+}
+
+func spawnHelperWithConfig(t *testing.T, cfg *SpawnConfig) *ServerProcess {
+	t.Helper()
+	t.Setenv("SPAWN_HELPER_SERVER", "1")
+	p, err := SpawnStdioServerWithConfig(os.Args[0], []string{"-test.run=TestHelperServer"},
+		map[string]string{"SPAWN_TAG": "child"}, cfg)
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+type helperResult struct {
+	PID         int    `json:"pid"`
+	Initialized bool   `json:"initialized"`
+	Tag         string `json:"tag"`
+	Token       string `json:"token"`
+	Sandbox     string `json:"sandbox"`
+}
+
+func call(t *testing.T, p *ServerProcess, id int, method string) (helperResult, error) {
+	t.Helper()
+	if err := p.Send([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q}`, id, method))); err != nil {
+		return helperResult{}, err
+	}
+	data, err := p.Receive()
+	if err != nil {
+		return helperResult{}, err
+	}
+	var resp struct {
+		Result helperResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("bad response %q: %v", data, err)
+	}
+	return resp.Result, nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSpawnStdioServer_RestartsAndReplaysHandshake(t *testing.T) {
+	p := spawnHelper(t, &RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+
+	if _, err := call(t, p, 1, "initialize"); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := p.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil {
+		t.Fatalf("initialized failed: %v", err)
+	}
+	before, err := call(t, p, 2, "ping")
+	if err != nil || !before.Initialized || before.Tag != "child" {
+		t.Fatalf("unexpected first result %+v, %v", before, err)
+	}
+
+	if _, err := call(t, p, 3, "crash"); !errors.Is(err, ErrServerExited) {
+		t.Fatalf("expected ErrServerExited for in-flight request, got %v", err)
+	}
+	waitFor(t, func() bool { return p.Restarts() == 1 && p.PID() != 0 })
+
+	after, err := call(t, p, 4, "ping")
+	if err != nil {
+		t.Fatalf("call after restart failed: %v", err)
+	}
+	if after.PID == before.PID {
+		t.Error("expected a new process after restart")
+	}
+	if !after.Initialized {
+		t.Error("handshake was not replayed to the restarted server")
+	}
+
+	p.Close()
+	if _, err := p.Receive(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestServerEnv(t *testing.T) {
+	t.Setenv("MCP_SENTINEL_CHAIN_KEY", "secret")
+	t.Setenv("SPAWN_KEEP", "1")
+	env := strings.Join(ServerEnv("EXTRA=2"), "\n")
+	if strings.Contains(env, "MCP_SENTINEL_CHAIN_KEY") {
+		t.Error("proxy variable passed on")
+	}
+	if !strings.Contains(env, "SPAWN_KEEP=1") || !strings.HasSuffix(env, "EXTRA=2") {
+		t.Errorf("environment lacks inherited or extra variables: %s", env)
+	}
+}
+
+func TestSpawnStdioServer_WithholdsProxyEnv(t *testing.T) {
+	t.Setenv("MCP_SENTINEL_ADMIN_TOKEN", "secret")
+	p := spawnHelper(t, nil)
+	got, err := call(t, p, 1, "ping")
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if got.Token != "" || got.Tag != "child" {
+		t.Errorf("server environment: token %q, tag %q", got.Token, got.Tag)
+	}
+}
+
+func TestSpawnStdioServer_Sandboxed(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no sandbox helper on " + runtime.GOOS)
+	}
+	// A stand-in for bwrap or sandbox-exec: it records its arguments
+	// and runs the command after "--"
+	helper := filepath.Join(t.TempDir(), "sandbox-helper")
+	script := "#!/bin/sh\nargs=\"$*\"\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nSPAWN_SANDBOX=\"$args\" exec \"$@\"\n"
+	if err := os.WriteFile(helper, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	p := spawnHelperWithConfig(t, &SpawnConfig{Sandbox: &sandbox.Profile{Name: "helper", Helper: helper, Strict: true}})
+	got, err := call(t, p, 1, "ping")
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if !strings.Contains(got.Sandbox, "-- "+os.Args[0]+" -test.run=TestHelperServer") {
+		t.Errorf("server not started by the sandbox helper: %q", got.Sandbox)
+	}
+}
+
+func TestSpawnStdioServer_StrictSandboxUnavailable(t *testing.T) {
+	_, err := SpawnStdioServerWithConfig(os.Args[0], nil, nil, &SpawnConfig{
+		Sandbox: &sandbox.Profile{Helper: "no-such-sandbox-helper", Strict: true},
+	})
+	if err == nil {
+		t.Fatal("expected a strict profile without its helper to fail the start")
+	}
+}
+
+func TestSpawnStdioServer_GivesUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *RestartPolicy
+	}{
+		{"no restart policy", nil},
+		{"restart limit reached", &RestartPolicy{MaxRestarts: 1, InitialBackoff: time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := spawnHelper(t, tt.policy)
+			limit := 0
+			if tt.policy != nil {
+				limit = tt.policy.MaxRestarts
+			}
+			for i := 0; i <= limit; i++ {
+				waitFor(t, func() bool { return p.Restarts() == i && p.PID() != 0 })
+				p.Send([]byte(`{"jsonrpc":"2.0","method":"crash"}`))
+				p.Receive()
+			}
+			waitFor(t, func() bool {
+				_, err := p.Receive()
+				return errors.Is(err, ErrServerDown)
+			})
+			if err := p.Send([]byte(`{}`)); !errors.Is(err, ErrServerDown) {
+				t.Errorf("expected ErrServerDown, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &RestartPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	var got []string
+	for n := 0; n < 6; n++ {
+		got = append(got, backoff(policy, n).String())
+	}
+	expected := "100ms 200ms 400ms 800ms 1s 1s"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("backoff = %v, expected %s", got, expected)
+	}
+}
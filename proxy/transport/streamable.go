@@ -0,0 +1,424 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Streamable HTTP header names (MCP 2025-03-26 and later).
+const (
+	HeaderSessionID       = "Mcp-Session-Id"
+	HeaderProtocolVersion = "Mcp-Protocol-Version"
+	HeaderLastEventID     = "Last-Event-ID"
+)
+
+// Streamable HTTP errors.
+var (
+	ErrSessionExpired    = errors.New("transport: MCP session expired or unknown")
+	ErrStreamUnsupported = errors.New("transport: server does not offer a GET event stream")
+)
+
+// DefaultMaxResume is the number of times a broken event stream is
+// resumed with Last-Event-ID before the transport gives up on it.
+const DefaultMaxResume = 3
+
+// maxMessageSize bounds a single JSON body or event, matching the stdio
+// and SSE transports.
+const maxMessageSize = 10 * 1024 * 1024
+
+// StreamableHTTPTransport implements Transport as the client role of the
+// MCP Streamable HTTP transport.
+//
+// Every outgoing message is POSTed to a single endpoint. The server
+// answers a request either with a JSON body or with an event stream that
+// carries the response and any related server messages; notifications
+// and responses are acknowledged with 202 Accepted. Listen opens an
+// optional GET stream for server-initiated messages.
+//
+// # Sessions
+//
+// The Mcp-Session-Id the server assigns in its initialize response is
+// sent on every later request, along with the negotiated protocol
+// version. Close ends the session with a DELETE.
+//
+// # Resumability
+//
+// When an event stream breaks before the server has finished it, the
+// transport reconnects with a GET carrying the last event ID it saw, up
+// to MaxResume times. Events redelivered during the resume are dropped
+// by a ReplayGuard; duplicates outside a resume are reported to
+// OnReplay like the SSE transport.
+//
+// # Thread Safety
+//
+// StreamableHTTPTransport is safe for concurrent use.
+type StreamableHTTPTransport struct {
+	endpoint string
+	client   *http.Client
+	messages chan []byte
+	errors   chan error
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	// MaxResume bounds resume attempts per stream (set before use;
+	// zero uses DefaultMaxResume)
+	MaxResume int
+
+	mu              sync.Mutex
+	closed          bool
+	sessionID       string
+	protocolVersion string
+	initID          string
+	replay          *ReplayGuard
+	onReplay        func(Replay)
+	streams         sync.WaitGroup
+}
+
+// NewStreamableHTTPTransport creates the client role of a Streamable
+// HTTP transport.
+//
+// # Arguments
+//   - endpoint: The server's MCP endpoint (e.g., "https://host/mcp")
+//
+// Event streams may stay open for the life of a session, so the HTTP
+// client has no overall timeout; Close cancels everything in flight.
+func NewStreamableHTTPTransport(endpoint string) *StreamableHTTPTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StreamableHTTPTransport{
+		endpoint: endpoint,
+		client:   &http.Client{},
+		messages: make(chan []byte, 100),
+		errors:   make(chan error, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		replay:   NewReplayGuard(0),
+	}
+}
+
+// OnReplay registers a callback invoked for every event dropped as a
+// suspected replay.
+func (t *StreamableHTTPTransport) OnReplay(fn func(Replay)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onReplay = fn
+}
+
+// SessionID returns the session ID assigned by the server, if any.
+func (t *StreamableHTTPTransport) SessionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+// newRequest builds a request carrying the session headers.
+func (t *StreamableHTTPTransport) newRequest(method string, body []byte) (*http.Request, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(t.ctx, method, t.endpoint, rd)
+	if err != nil {
+		return nil, fmt.Errorf("transport: failed to create request: %w", err)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(HeaderSessionID, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set(HeaderProtocolVersion, t.protocolVersion)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+// Send POSTs a message to the server.
+//
+// Send returns once the server has accepted the message. A response
+// arriving as a JSON body or on an event stream is delivered through
+// Receive.
+func (t *StreamableHTTPTransport) Send(data []byte) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	t.mu.Unlock()
+
+	var probe struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if json.Unmarshal(data, &probe) == nil && probe.Method == "initialize" {
+		t.mu.Lock()
+		t.initID = string(probe.ID)
+		t.mu.Unlock()
+	}
+
+	req, err := t.newRequest(http.MethodPost, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("transport: POST failed: %w", err)
+	}
+	if id := resp.Header.Get(HeaderSessionID); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		resp.Body.Close()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && req.Header.Get(HeaderSessionID) != "":
+		resp.Body.Close()
+		return ErrSessionExpired
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return fmt.Errorf("transport: server returned status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		t.streams.Add(1)
+		go func() {
+			defer t.streams.Done()
+			t.readStream(resp.Body)
+		}()
+		return nil
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return fmt.Errorf("transport: read response: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		t.deliver(body)
+	}
+	return nil
+}
+
+// Listen opens the GET event stream the server uses for messages not
+// related to any client request.
+//
+// # Returns
+//   - ErrStreamUnsupported if the server does not offer one (405)
+func (t *StreamableHTTPTransport) Listen() error {
+	req, err := t.newRequest(http.MethodGet, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("transport: GET stream failed: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed:
+		resp.Body.Close()
+		return ErrStreamUnsupported
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("transport: GET stream returned status %d", resp.StatusCode)
+	}
+	t.streams.Add(1)
+	go func() {
+		defer t.streams.Done()
+		t.readStream(resp.Body)
+	}()
+	return nil
+}
+
+// readStream delivers the events of one stream, resuming it with
+// Last-Event-ID if it breaks.
+func (t *StreamableHTTPTransport) readStream(body io.ReadCloser) {
+	maxResume := t.MaxResume
+	if maxResume <= 0 {
+		maxResume = DefaultMaxResume
+	}
+
+	lastID := ""
+	for attempt := 0; ; attempt++ {
+		last, err := t.readEvents(body)
+		body.Close()
+		if last != "" {
+			lastID = last
+		}
+		if err == nil || t.ctx.Err() != nil {
+			return
+		}
+		if lastID == "" || attempt >= maxResume {
+			t.fail(fmt.Errorf("transport: event stream broken: %w", err))
+			return
+		}
+
+		// Back off briefly, then ask the server to replay what we missed
+		time.Sleep(time.Duration(attempt+1) * 100 * time.Millisecond)
+		req, rerr := t.newRequest(http.MethodGet, nil)
+		if rerr != nil {
+			t.fail(rerr)
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set(HeaderLastEventID, lastID)
+		t.replay.Resume()
+		resp, rerr := t.client.Do(req)
+		if rerr != nil || resp.StatusCode != http.StatusOK {
+			if rerr == nil {
+				resp.Body.Close()
+				rerr = fmt.Errorf("status %d", resp.StatusCode)
+			}
+			t.fail(fmt.Errorf("transport: resume after %s failed: %w", lastID, rerr))
+			return
+		}
+		body = resp.Body
+	}
+}
+
+// readEvents parses SSE events until the stream ends.
+//
+// # Returns
+//   - The ID of the last event read ("" if none carried an ID)
+//   - nil on a clean end of stream, or the read error
+func (t *StreamableHTTPTransport) readEvents(body io.Reader) (string, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	var data bytes.Buffer
+	var eventID, lastID string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				eventID = ""
+				continue
+			}
+			payload := bytes.Clone(data.Bytes())
+			data.Reset()
+			if eventID != "" {
+				lastID = eventID
+			}
+			if t.observe(eventID, payload) {
+				t.deliver(payload)
+			}
+			eventID = ""
+		case field == "id":
+			eventID = value
+		case field == "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+	return lastID, scanner.Err()
+}
+
+// observe applies the replay guard to an event.
+func (t *StreamableHTTPTransport) observe(id string, data []byte) bool {
+	deliver, replay := t.replay.Observe(id, data)
+	if replay != nil {
+		t.mu.Lock()
+		fn := t.onReplay
+		t.mu.Unlock()
+		if fn != nil {
+			fn(*replay)
+		}
+	}
+	return deliver
+}
+
+// deliver queues a received message, noting the negotiated protocol
+// version from the initialize response.
+func (t *StreamableHTTPTransport) deliver(data []byte) {
+	t.mu.Lock()
+	initID := t.initID
+	t.mu.Unlock()
+	if initID != "" {
+		var resp struct {
+			ID     json.RawMessage `json:"id"`
+			Result struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(data, &resp) == nil && string(resp.ID) == initID && resp.Result.ProtocolVersion != "" {
+			t.mu.Lock()
+			t.protocolVersion = resp.Result.ProtocolVersion
+			t.initID = ""
+			t.mu.Unlock()
+		}
+	}
+
+	select {
+	case t.messages <- data:
+	case <-t.ctx.Done():
+	}
+}
+
+// fail reports a stream error to Receive without blocking.
+func (t *StreamableHTTPTransport) fail(err error) {
+	select {
+	case t.errors <- err:
+	default:
+	}
+}
+
+// Receive returns the next message from any response or stream.
+func (t *StreamableHTTPTransport) Receive() ([]byte, error) {
+	select {
+	case msg := <-t.messages:
+		return msg, nil
+	case err := <-t.errors:
+		return nil, err
+	case <-t.ctx.Done():
+		return nil, ErrClosed
+	}
+}
+
+// Close ends the session with a DELETE (best effort) and cancels all
+// requests and streams.
+func (t *StreamableHTTPTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	sessionID := t.sessionID
+	t.mu.Unlock()
+
+	if sessionID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.endpoint, nil)
+		if err == nil {
+			req.Header.Set(HeaderSessionID, sessionID)
+			if resp, err := t.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		cancel()
+	}
+	t.cancel()
+	t.streams.Wait()
+	return nil
+}
@@ -0,0 +1,548 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultStreamHistory is the number of events each stream keeps for
+// clients resuming with Last-Event-ID.
+const DefaultStreamHistory = 256
+
+// retainedStreams bounds how many finished request streams are kept
+// for resumption.
+const retainedStreams = 64
+
+// StreamableServerConfig configures the server role of the Streamable
+// HTTP transport.
+type StreamableServerConfig struct {
+	// AllowedOrigins lists the Origin values accepted from browsers.
+	// When empty, only requests without an Origin header or with an
+	// Origin matching the request's Host are accepted.
+	AllowedOrigins []string
+
+	// History is the number of events kept per stream for resumption
+	// (zero uses DefaultStreamHistory)
+	History int
+
+	// NewSessionID mints the session ID assigned at initialize, e.g.
+	// one that hashes to this replica (nil uses a random 128-bit ID)
+	NewSessionID func() string
+}
+
+// StreamableHTTPServer implements Transport as the server role of the
+// MCP Streamable HTTP transport for a single session. It is also the
+// http.Handler for the session's MCP endpoint.
+//
+// Client messages POSTed to the endpoint are returned by Receive.
+// Notifications and responses are acknowledged with 202 Accepted; each
+// request is answered on an event stream that closes once Send has
+// delivered the response. Server requests and notifications go to the
+// client's GET stream when one is open, otherwise to an open request
+// stream, otherwise they are held until a GET stream opens.
+//
+// # Sessions
+//
+// The server assigns an Mcp-Session-Id when it accepts initialize and
+// requires it on every later request; a DELETE ends the session, after
+// which Receive returns ErrClosed and requests get 404.
+//
+// # Resumability
+//
+// Every event carries an ID naming its stream and position. A client
+// that GETs the endpoint with Last-Event-ID receives the events it
+// missed on that stream, then the rest of the stream.
+//
+// # Security Notes
+//
+// Origin is validated on every request to block DNS rebinding attacks
+// from browsers. Session IDs are 128-bit random values, but they are
+// identifiers, not credentials: put authentication in front of the
+// endpoint if it is reachable by untrusted clients.
+//
+// # Thread Safety
+//
+// StreamableHTTPServer is safe for concurrent use.
+type StreamableHTTPServer struct {
+	cfg      StreamableServerConfig
+	incoming chan []byte
+	done     chan struct{}
+
+	mu         sync.Mutex
+	sessionID  string
+	ended      bool
+	nextStream int
+	standalone *eventStream
+	streams    map[int]*eventStream
+	byRequest  map[string]*eventStream
+	finished   []int
+}
+
+// eventStream is one SSE stream: a request's POST stream or the
+// standalone GET stream (ID 0).
+type eventStream struct {
+	id      int
+	seq     uint64
+	history []streamEvent
+	pending map[string]bool
+	done    bool
+
+	// wake is closed and replaced whenever the stream changes
+	wake chan struct{}
+
+	// attached counts connected writers; cursor is the last event a
+	// writer sent, where a fresh GET on the standalone stream starts
+	attached int
+	cursor   uint64
+}
+
+// streamEvent is a message with its position in a stream.
+type streamEvent struct {
+	seq  uint64
+	data []byte
+}
+
+// NewStreamableHTTPServer creates the server role of a Streamable HTTP
+// transport with default configuration.
+func NewStreamableHTTPServer() *StreamableHTTPServer {
+	return NewStreamableHTTPServerWithConfig(StreamableServerConfig{})
+}
+
+// NewStreamableHTTPServerWithConfig creates the server role of a
+// Streamable HTTP transport.
+//
+// # Arguments
+//   - cfg: Origin policy and resumption history
+func NewStreamableHTTPServerWithConfig(cfg StreamableServerConfig) *StreamableHTTPServer {
+	if cfg.History <= 0 {
+		cfg.History = DefaultStreamHistory
+	}
+	if cfg.NewSessionID == nil {
+		cfg.NewSessionID = newSessionID
+	}
+	return &StreamableHTTPServer{
+		cfg:        cfg,
+		incoming:   make(chan []byte, 100),
+		done:       make(chan struct{}),
+		nextStream: 1,
+		standalone: newEventStream(0),
+		streams:    make(map[int]*eventStream),
+		byRequest:  make(map[string]*eventStream),
+	}
+}
+
+func newEventStream(id int) *eventStream {
+	return &eventStream{id: id, pending: make(map[string]bool), wake: make(chan struct{})}
+}
+
+// SessionID returns the session ID assigned at initialize, if any.
+func (s *StreamableHTTPServer) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+// Receive returns the next message POSTed by the client.
+func (s *StreamableHTTPServer) Receive() ([]byte, error) {
+	select {
+	case msg := <-s.incoming:
+		return msg, nil
+	case <-s.done:
+		return nil, ErrClosed
+	}
+}
+
+// Send delivers a message to the client. A response goes to the stream
+// of the request it answers; other messages are routed as described on
+// StreamableHTTPServer.
+func (s *StreamableHTTPServer) Send(data []byte) error {
+	var probe struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return ErrClosed
+	}
+
+	if probe.Method == "" && len(probe.ID) > 0 {
+		key := string(probe.ID)
+		if st, ok := s.byRequest[key]; ok {
+			delete(s.byRequest, key)
+			delete(st.pending, key)
+			s.append(st, data)
+			if len(st.pending) == 0 {
+				s.finish(st)
+			}
+			return nil
+		}
+	}
+	s.append(s.route(), data)
+	return nil
+}
+
+// route picks the stream for a message that answers no request.
+// Caller must hold s.mu.
+func (s *StreamableHTTPServer) route() *eventStream {
+	if s.standalone.attached > 0 {
+		return s.standalone
+	}
+	var best *eventStream
+	for _, st := range s.streams {
+		if !st.done && st.attached > 0 && (best == nil || st.id > best.id) {
+			best = st
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return s.standalone
+}
+
+// append adds an event to a stream, dropping the oldest beyond the
+// history limit. Caller must hold s.mu.
+func (s *StreamableHTTPServer) append(st *eventStream, data []byte) {
+	st.seq++
+	st.history = append(st.history, streamEvent{seq: st.seq, data: data})
+	if over := len(st.history) - s.cfg.History; over > 0 {
+		st.history = slices.Delete(st.history, 0, over)
+	}
+	close(st.wake)
+	st.wake = make(chan struct{})
+}
+
+// finish marks a request stream complete and retires old ones.
+// Caller must hold s.mu.
+func (s *StreamableHTTPServer) finish(st *eventStream) {
+	st.done = true
+	close(st.wake)
+	st.wake = make(chan struct{})
+	s.finished = append(s.finished, st.id)
+	if len(s.finished) > retainedStreams {
+		delete(s.streams, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
+
+// Close ends the session: Receive returns ErrClosed and open streams
+// are closed.
+func (s *StreamableHTTPServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end()
+	return nil
+}
+
+// end terminates the session. Caller must hold s.mu.
+func (s *StreamableHTTPServer) end() {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	close(s.done)
+}
+
+// ServeHTTP implements http.Handler for the session's MCP endpoint.
+func (s *StreamableHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// originAllowed validates the Origin header.
+func (s *StreamableHTTPServer) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(s.cfg.AllowedOrigins) > 0 {
+		return slices.Contains(s.cfg.AllowedOrigins, origin)
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// checkSession validates the session header of a non-initialize
+// request, writing the error response if it fails.
+func (s *StreamableHTTPServer) checkSession(w http.ResponseWriter, r *http.Request) bool {
+	id := r.Header.Get(HeaderSessionID)
+	s.mu.Lock()
+	sessionID, ended := s.sessionID, s.ended
+	s.mu.Unlock()
+	switch {
+	case sessionID == "":
+		http.Error(w, "session not initialized", http.StatusBadRequest)
+		return false
+	case id == "":
+		http.Error(w, "missing "+HeaderSessionID, http.StatusBadRequest)
+		return false
+	case id != sessionID || ended:
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// accepts reports whether the Accept header lists a media type.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && (mt == mediaType || mt == "*/*") {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePost accepts one client message.
+func (s *StreamableHTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "application/json") || !accepts(r, "text/event-stream") {
+		http.Error(w, "Accept must list application/json and text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxMessageSize {
+		http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		// Batches are not supported
+		http.Error(w, "body must be a single JSON-RPC message", http.StatusBadRequest)
+		return
+	}
+
+	var st *eventStream
+	if msg.Method == "initialize" {
+		s.mu.Lock()
+		if s.sessionID != "" {
+			s.mu.Unlock()
+			http.Error(w, "session already initialized", http.StatusBadRequest)
+			return
+		}
+		s.sessionID = s.cfg.NewSessionID()
+		s.mu.Unlock()
+	} else if !s.checkSession(w, r) {
+		return
+	}
+
+	isRequest := msg.Method != "" && len(msg.ID) > 0 && string(msg.ID) != "null"
+	if isRequest {
+		s.mu.Lock()
+		st = newEventStream(s.nextStream)
+		s.nextStream++
+		st.pending[string(msg.ID)] = true
+		st.attached++
+		s.streams[st.id] = st
+		s.byRequest[string(msg.ID)] = st
+		s.mu.Unlock()
+	}
+
+	select {
+	case s.incoming <- body:
+	case <-s.done:
+		s.detach(st)
+		http.Error(w, "session ended", http.StatusNotFound)
+		return
+	case <-r.Context().Done():
+		s.detach(st)
+		return
+	}
+
+	w.Header().Set(HeaderSessionID, s.SessionID())
+	if st == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	s.serveStream(w, r, st, 0)
+}
+
+// handleGet opens the standalone stream or resumes a stream.
+func (s *StreamableHTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "text/event-stream") {
+		http.Error(w, "Accept must list text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	if !s.checkSession(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	var st *eventStream
+	var after uint64
+	if last := r.Header.Get(HeaderLastEventID); last != "" {
+		streamID, seq, ok := parseEventID(last)
+		switch {
+		case !ok:
+		case streamID == 0:
+			st = s.standalone
+		default:
+			st = s.streams[streamID]
+		}
+		if st == nil {
+			s.mu.Unlock()
+			http.Error(w, "unknown event ID", http.StatusNotFound)
+			return
+		}
+		after = seq
+	} else {
+		st = s.standalone
+		if st.attached > 0 {
+			s.mu.Unlock()
+			http.Error(w, "stream already open", http.StatusConflict)
+			return
+		}
+		after = st.cursor
+	}
+	st.attached++
+	s.mu.Unlock()
+
+	w.Header().Set(HeaderSessionID, s.SessionID())
+	s.serveStream(w, r, st, after)
+}
+
+// handleDelete ends the session at the client's request.
+func (s *StreamableHTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.checkSession(w, r) {
+		return
+	}
+	s.mu.Lock()
+	s.end()
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveStream writes a stream's events after the given position until
+// the stream is done, the session ends, or the client disconnects. The
+// caller has already counted the writer in st.attached.
+func (s *StreamableHTTPServer) serveStream(w http.ResponseWriter, r *http.Request, st *eventStream, after uint64) {
+	defer s.detach(st)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		s.mu.Lock()
+		var events []streamEvent
+		for _, ev := range st.history {
+			if ev.seq > after {
+				events = append(events, ev)
+			}
+		}
+		done, wake := st.done, st.wake
+		s.mu.Unlock()
+
+		for _, ev := range events {
+			if err := writeEvent(w, st.id, ev); err != nil {
+				return
+			}
+			after = ev.seq
+			s.mu.Lock()
+			st.cursor = max(st.cursor, after)
+			s.mu.Unlock()
+		}
+		if flusher != nil && len(events) > 0 {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-s.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// detach uncounts a stream writer. A nil stream is ignored.
+func (s *StreamableHTTPServer) detach(st *eventStream) {
+	if st == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.attached--
+}
+
+// writeEvent writes one SSE event, splitting multi-line data.
+func writeEvent(w io.Writer, streamID int, ev streamEvent) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d-%d\n", streamID, ev.seq)
+	for _, line := range strings.Split(string(ev.data), "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseEventID splits an event ID of the form "<stream>-<seq>".
+func parseEventID(id string) (int, uint64, bool) {
+	streamPart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	streamID, err1 := strconv.Atoi(streamPart)
+	seq, err2 := strconv.ParseUint(seqPart, 10, 64)
+	if err1 != nil || err2 != nil || streamID < 0 {
+		return 0, 0, false
+	}
+	return streamID, seq, true
+}
+
+// newSessionID returns a random 128-bit session ID.
+func newSessionID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,279 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer answers every request received by srv with a result naming
+// the method.
+func echoServer(srv *StreamableHTTPServer) {
+	for {
+		data, err := srv.Receive()
+		if err != nil {
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.Unmarshal(data, &msg)
+		if msg.Method == "" || len(msg.ID) == 0 {
+			continue
+		}
+		result := fmt.Sprintf(`{"method":%q}`, msg.Method)
+		if msg.Method == "initialize" {
+			result = `{"protocolVersion":"2025-06-18"}`
+		}
+		srv.Send([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, result)))
+	}
+}
+
+// receiveWithin waits for the next message on t.
+func receiveWithin(t *testing.T, tr Transport) string {
+	t.Helper()
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err := tr.Receive()
+		ch <- result{data, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("Receive failed: %v", r.err)
+		}
+		return string(r.data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return ""
+	}
+}
+
+func TestStreamableHTTP_RoundTrip(t *testing.T) {
+	srv := NewStreamableHTTPServer()
+	go echoServer(srv)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	client := NewStreamableHTTPTransport(ts.URL)
+	if err := client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if got := receiveWithin(t, client); !strings.Contains(got, "2025-06-18") {
+		t.Errorf("initialize response = %s", got)
+	}
+	if client.SessionID() == "" || client.SessionID() != srv.SessionID() {
+		t.Fatalf("session ID: client %q, server %q", client.SessionID(), srv.SessionID())
+	}
+
+	if err := client.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil {
+		t.Fatalf("notification: %v", err)
+	}
+	if err := client.Send([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("tools/list: %v", err)
+	}
+	if got := receiveWithin(t, client); !strings.Contains(got, `"id":2`) || !strings.Contains(got, "tools/list") {
+		t.Errorf("tools/list response = %s", got)
+	}
+
+	// Server-initiated messages reach the GET stream
+	if err := client.Listen(); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.mu.Lock()
+		attached := srv.standalone.attached
+		srv.mu.Unlock()
+		if attached > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	srv.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+	if got := receiveWithin(t, client); !strings.Contains(got, "list_changed") {
+		t.Errorf("server notification = %s", got)
+	}
+
+	// Close ends the session on the server
+	client.Close()
+	if _, err := srv.Receive(); !errors.Is(err, ErrClosed) {
+		t.Errorf("server Receive after client Close: %v, expected ErrClosed", err)
+	}
+	if err := client.Send([]byte(`{}`)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send after Close: %v, expected ErrClosed", err)
+	}
+}
+
+func TestStreamableHTTPServer_Validation(t *testing.T) {
+	srv := NewStreamableHTTPServer()
+	go echoServer(srv)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	client := NewStreamableHTTPTransport(ts.URL)
+	defer client.Close()
+	client.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+	receiveWithin(t, client)
+	session := client.SessionID()
+
+	const both = "application/json, text/event-stream"
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		body     string
+		expected int
+	}{
+		{"notification accepted", "POST", map[string]string{"Accept": both, HeaderSessionID: session},
+			`{"jsonrpc":"2.0","method":"notifications/progress"}`, http.StatusAccepted},
+		{"missing accept type", "POST", map[string]string{"Accept": "application/json", HeaderSessionID: session},
+			`{"jsonrpc":"2.0","method":"x"}`, http.StatusNotAcceptable},
+		{"missing session", "POST", map[string]string{"Accept": both},
+			`{"jsonrpc":"2.0","method":"x"}`, http.StatusBadRequest},
+		{"unknown session", "POST", map[string]string{"Accept": both, HeaderSessionID: "nope"},
+			`{"jsonrpc":"2.0","method":"x"}`, http.StatusNotFound},
+		{"second initialize", "POST", map[string]string{"Accept": both},
+			`{"jsonrpc":"2.0","id":9,"method":"initialize"}`, http.StatusBadRequest},
+		{"batch rejected", "POST", map[string]string{"Accept": both, HeaderSessionID: session},
+			`[{"jsonrpc":"2.0","method":"x"}]`, http.StatusBadRequest},
+		{"foreign origin", "POST", map[string]string{"Accept": both, HeaderSessionID: session, "Origin": "https://evil.example"},
+			`{"jsonrpc":"2.0","method":"x"}`, http.StatusForbidden},
+		{"unknown event id", "GET", map[string]string{"Accept": "text/event-stream", HeaderSessionID: session, HeaderLastEventID: "99-1"},
+			"", http.StatusNotFound},
+		{"unsupported method", "PUT", nil, "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("status = %d, expected %d", resp.StatusCode, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamableHTTPServer_Resume(t *testing.T) {
+	srv := NewStreamableHTTPServer()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	post := func(body string, session string) *http.Response {
+		req, _ := http.NewRequest("POST", ts.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if session != "" {
+			req.Header.Set(HeaderSessionID, session)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		return resp
+	}
+
+	go func() {
+		srv.Receive()
+		srv.Send([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}()
+	resp := post(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`, "")
+	resp.Body.Close()
+	session := resp.Header.Get(HeaderSessionID)
+
+	// Open a request stream, read one progress event, then disconnect
+	resp = post(`{"jsonrpc":"2.0","id":2,"method":"tools/call"}`, session)
+	srv.Receive()
+	srv.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+	reader := bufio.NewReader(resp.Body)
+	idLine, _ := reader.ReadString('\n')
+	lastID := strings.TrimSpace(strings.TrimPrefix(idLine, "id:"))
+	resp.Body.Close()
+
+	// Events sent while disconnected are replayed on resume
+	srv.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":2}}`))
+	srv.Send([]byte(`{"jsonrpc":"2.0","id":2,"result":{"done":true}}`))
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(HeaderSessionID, session)
+	req.Header.Set(HeaderLastEventID, lastID)
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil || resumed.StatusCode != http.StatusOK {
+		t.Fatalf("resume failed: %v %v", err, resumed.Status)
+	}
+	defer resumed.Body.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(resumed.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) != 2 || !strings.Contains(events[0], `"progress":2`) || !strings.Contains(events[1], `"done":true`) {
+		t.Errorf("resumed events = %v, expected progress 2 then the result", events)
+	}
+}
+
+func TestStreamableHTTPTransport_ResumesBrokenStream(t *testing.T) {
+	var gets []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			// Send one event, then drop the connection mid-stream
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			event := "id: 1-1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n"
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n", len(event), event)
+			buf.Flush()
+			conn.Close()
+			return
+		}
+		gets = append(gets, r.Header.Get(HeaderLastEventID))
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Redeliver the event already seen, then the response
+		fmt.Fprint(w, "id: 1-1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		fmt.Fprint(w, "id: 1-2\ndata: {\"jsonrpc\":\"2.0\",\"id\":5,\"result\":{}}\n\n")
+	}))
+	defer ts.Close()
+
+	client := NewStreamableHTTPTransport(ts.URL)
+	defer client.Close()
+	var replays []Replay
+	client.OnReplay(func(r Replay) { replays = append(replays, r) })
+
+	if err := client.Send([]byte(`{"jsonrpc":"2.0","id":5,"method":"tools/call"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := receiveWithin(t, client); !strings.Contains(got, "progress") {
+		t.Errorf("first message = %s", got)
+	}
+	if got := receiveWithin(t, client); !strings.Contains(got, `"id":5`) {
+		t.Errorf("second message = %s, expected the response after resume", got)
+	}
+	if len(gets) != 1 || gets[0] != "1-1" {
+		t.Errorf("resume requests = %v, expected one with Last-Event-ID 1-1", gets)
+	}
+	if len(replays) != 0 {
+		t.Errorf("redelivery during resume flagged as replay: %v", replays)
+	}
+}
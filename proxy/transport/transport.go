@@ -1,9 +1,13 @@
 // Package transport handles MCP protocol transports.
 //
-// It provides implementations for the two primary MCP transport modes:
+// It provides implementations for the primary MCP transport modes:
 //
 //   - Stdio: Communication via standard input/output (subprocess model)
 //   - SSE: Server-Sent Events over HTTP (remote server model)
+//   - Streamable HTTP: POSTed messages answered with JSON or event
+//     streams (MCP 2025-03-26), in both client and server roles
+//   - WebSocket: one message per text frame, with keepalive and
+//     reconnection (client role)
 //
 // # Transport Interface
 //
@@ -14,6 +18,9 @@
 //
 // Stdio transport uses newline-delimited JSON (NDJSON).
 // SSE transport uses standard SSE framing with "data:" prefix.
+// Streamable HTTP carries one JSON-RPC message per POST body or event.
+// WebSocket carries one JSON-RPC message per (possibly fragmented) text
+// message.
 //
 // # Security Notes
 //
@@ -21,12 +28,14 @@
 //   - Proper message framing (preventing message injection)
 //   - Clean connection lifecycle management
 //   - Timeout handling to prevent hanging
+//   - Dropping replayed SSE events (see ReplayGuard)
 package transport
 
 import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -73,6 +82,7 @@ type Transport interface {
 type StdioTransport struct {
 	stdin   io.WriteCloser
 	stdout  io.ReadCloser
+	out     *FrameWriter
 	scanner *bufio.Scanner
 	mu      sync.Mutex
 	closed  bool
@@ -95,6 +105,13 @@ func NewStdioTransport() *StdioTransport {
 // Note: The naming follows the perspective of the subprocess:
 // we write to its stdin and read from its stdout.
 func NewStdioTransportWithPipes(stdin io.WriteCloser, stdout io.ReadCloser) *StdioTransport {
+	return NewStdioTransportWithConfig(stdin, stdout, nil)
+}
+
+// NewStdioTransportWithConfig creates a stdio transport with custom
+// pipes whose outgoing messages are flushed according to policy (nil
+// writes each message at once). See FrameWriter.
+func NewStdioTransportWithConfig(stdin io.WriteCloser, stdout io.ReadCloser, policy *FlushPolicy) *StdioTransport {
 	scanner := bufio.NewScanner(stdout)
 	// Allow larger messages (default is 64KB, MCP can have larger payloads)
 	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024) // 10MB max
@@ -102,14 +119,16 @@ func NewStdioTransportWithPipes(stdin io.WriteCloser, stdout io.ReadCloser) *Std
 	return &StdioTransport{
 		stdin:   stdin,
 		stdout:  stdout,
+		out:     NewFrameWriter(stdin, policy),
 		scanner: scanner,
 	}
 }
 
 // Send writes a message to the subprocess stdin.
 //
-// The message is written as a single line followed by a newline.
-// Any embedded newlines in the message will cause protocol errors.
+// The message is written as a single line followed by a newline, at
+// once or when the flush policy next flushes. Any embedded newlines in
+// the message will cause protocol errors. data is not retained.
 func (t *StdioTransport) Send(data []byte) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -119,12 +138,12 @@ func (t *StdioTransport) Send(data []byte) error {
 	}
 
 	// Validate no embedded newlines
-	if bytes.Contains(data, []byte("\n")) {
+	if bytes.IndexByte(data, '\n') >= 0 {
 		return fmt.Errorf("%w: message contains embedded newline", ErrInvalidMessage)
 	}
 
 	// Write message with newline terminator
-	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+	if err := t.out.WriteFrame(data); err != nil {
 		return fmt.Errorf("transport: write failed: %w", err)
 	}
 
@@ -141,7 +160,8 @@ func (t *StdioTransport) Receive() ([]byte, error) {
 	}
 
 	if t.scanner.Scan() {
-		return t.scanner.Bytes(), nil
+		// Copy: the scanner reuses its buffer on the next Scan
+		return bytes.Clone(t.scanner.Bytes()), nil
 	}
 
 	if err := t.scanner.Err(); err != nil {
@@ -153,7 +173,8 @@ func (t *StdioTransport) Receive() ([]byte, error) {
 
 // Close terminates the stdio transport.
 //
-// Closes both stdin and stdout pipes. Safe to call multiple times.
+// Flushes messages held by the flush policy, then closes both stdin
+// and stdout pipes. Safe to call multiple times.
 func (t *StdioTransport) Close() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -164,6 +185,9 @@ func (t *StdioTransport) Close() error {
 	t.closed = true
 
 	var errs []error
+	if err := t.out.Flush(); err != nil {
+		errs = append(errs, err)
+	}
 	if err := t.stdin.Close(); err != nil {
 		errs = append(errs, err)
 	}
@@ -203,6 +227,10 @@ type SSETransport struct {
 	mu         sync.Mutex
 	closed     bool
 	connected  bool
+
+	// replay drops duplicated events; onReplay is notified of each
+	replay   *ReplayGuard
+	onReplay func(Replay)
 }
 
 // NewSSETransport creates a new SSE transport.
@@ -223,9 +251,28 @@ func NewSSETransport(baseURL string) *SSETransport {
 		errors:   make(chan error, 1),
 		ctx:      ctx,
 		cancel:   cancel,
+		replay:   NewReplayGuard(0),
 	}
 }
 
+// OnReplay registers a callback invoked for every event dropped as a
+// suspected replay, typically feeding the session's anomaly score.
+func (t *SSETransport) OnReplay(fn func(Replay)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onReplay = fn
+}
+
+// SetTLSConfig configures HTTPS connections, e.g. a private CA or a
+// client certificate (nil uses system defaults). Call it before Connect.
+func (t *SSETransport) SetTLSConfig(cfg *tls.Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	t.client.Transport = transport
+}
+
 // Connect establishes the SSE connection for receiving messages.
 //
 // This should be called before Receive. The connection runs in a
@@ -252,6 +299,10 @@ func (t *SSETransport) readLoop() {
 	}
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
+	if last := t.replay.LastEventID(); last != "" {
+		req.Header.Set("Last-Event-ID", last)
+		t.replay.Resume()
+	}
 
 	resp, err := t.client.Do(req)
 	if err != nil {
@@ -267,21 +318,40 @@ func (t *SSETransport) readLoop() {
 
 	scanner := bufio.NewScanner(resp.Body)
 	var dataBuffer bytes.Buffer
+	var eventID string
 
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		// SSE format: "data: <json>\n\n"
-		if strings.HasPrefix(line, "data: ") {
+		// SSE format: "id: <id>\ndata: <json>\n\n"
+		if strings.HasPrefix(line, "id: ") {
+			eventID = strings.TrimPrefix(line, "id: ")
+		} else if strings.HasPrefix(line, "data: ") {
 			dataBuffer.WriteString(strings.TrimPrefix(line, "data: "))
 		} else if line == "" && dataBuffer.Len() > 0 {
 			// Empty line marks end of event
+			data := bytes.Clone(dataBuffer.Bytes())
+			dataBuffer.Reset()
+			id := eventID
+			eventID = ""
+
+			deliver, replay := t.replay.Observe(id, data)
+			if replay != nil {
+				t.mu.Lock()
+				fn := t.onReplay
+				t.mu.Unlock()
+				if fn != nil {
+					fn(*replay)
+				}
+			}
+			if !deliver {
+				continue
+			}
 			select {
-			case t.messages <- bytes.Clone(dataBuffer.Bytes()):
+			case t.messages <- data:
 			case <-t.ctx.Done():
 				return
 			}
-			dataBuffer.Reset()
 		}
 	}
 
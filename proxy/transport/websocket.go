@@ -0,0 +1,727 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocket errors. ErrConnectionLost and ErrReconnecting wrap the
+// process errors, so callers handle a dropped connection exactly like
+// a restarted server.
+var (
+	ErrHandshake      = errors.New("transport: websocket handshake failed")
+	ErrFrame          = errors.New("transport: websocket protocol error")
+	ErrConnectionLost = fmt.Errorf("%w: websocket connection lost", ErrServerExited)
+	ErrReconnecting   = fmt.Errorf("%w: websocket reconnecting", ErrServerDown)
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// websocketGUID is appended to the handshake key (RFC 6455 section 4.2.2).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketConfig configures DialWebSocketWithConfig.
+type WebSocketConfig struct {
+	// Header is sent with the handshake, e.g. Authorization
+	Header http.Header
+
+	// Subprotocol is offered in Sec-WebSocket-Protocol (default "mcp");
+	// servers that do not echo it are still accepted
+	Subprotocol string
+
+	// TLS configures wss:// connections (nil uses system defaults)
+	TLS *tls.Config
+
+	// HandshakeTimeout bounds dialing and the upgrade (default 10s)
+	HandshakeTimeout time.Duration
+
+	// PingInterval is the keepalive period (default 30s, negative
+	// disables keepalive)
+	PingInterval time.Duration
+
+	// PongTimeout is how long past a ping the connection may stay
+	// silent before it is considered dead (default 10s)
+	PongTimeout time.Duration
+
+	// Reconnect redials after the connection is lost (nil never
+	// reconnects)
+	Reconnect *RestartPolicy
+
+	// OnDisconnect is called after every lost connection or failed
+	// redial, before any reconnect
+	OnDisconnect func(DisconnectEvent)
+}
+
+// DisconnectEvent describes a lost WebSocket connection.
+type DisconnectEvent struct {
+	// Err is why the connection ended or could not be established
+	Err error
+
+	// Uptime is how long the connection was up (0 for a failed dial)
+	Uptime time.Duration
+
+	// Reconnecting reports whether a redial is scheduled
+	Reconnecting bool
+
+	// Delay is the backoff before the redial
+	Delay time.Duration
+}
+
+// WebSocketTransport is a Transport to an MCP server exposed over
+// WebSocket. Each JSON-RPC message is one text message.
+//
+// # Keepalive
+//
+// The transport pings the server every PingInterval. A connection that
+// has been silent for PingInterval plus PongTimeout is closed, which
+// starts a reconnect.
+//
+// # Reconnection
+//
+// A lost connection is redialed with the backoff of the Reconnect
+// policy. As with ServerProcess, the last initialize request and
+// notifications/initialized are replayed on the new connection and the
+// replayed initialize response is discarded. Requests in flight fail
+// with ErrConnectionLost; Send returns ErrReconnecting until the new
+// connection is up.
+//
+// # Security Notes
+//
+// Use wss:// for servers not on the local host; the handshake Header
+// typically carries credentials. Messages are limited to 10 MiB like
+// the other transports.
+//
+// # Thread Safety
+//
+// WebSocketTransport is safe for concurrent use; only one goroutine
+// should call Receive at a time.
+type WebSocketTransport struct {
+	url string
+	cfg WebSocketConfig
+
+	mu       sync.Mutex
+	current  *wsConn
+	up       chan struct{} // closed when waiting for a connection should end
+	upClosed bool
+	closed   bool
+	gaveUp   bool
+	attempts int // consecutive reconnects since the last healthy connection
+
+	// handshake holds the initialize request and initialized
+	// notification to replay after a reconnect
+	initialize  []byte
+	initialized []byte
+}
+
+// DialWebSocket connects to an MCP server over WebSocket, reconnecting
+// with DefaultRestartPolicy when the connection is lost.
+//
+// # Arguments
+//   - rawURL: Server URL (ws:// or wss://)
+func DialWebSocket(rawURL string) (*WebSocketTransport, error) {
+	return DialWebSocketWithConfig(rawURL, &WebSocketConfig{Reconnect: DefaultRestartPolicy()})
+}
+
+// DialWebSocketWithConfig connects to an MCP server over WebSocket.
+//
+// # Returns
+//   - The connected transport
+//   - ErrHandshake if the server refused the upgrade, or a dial error
+func DialWebSocketWithConfig(rawURL string, cfg *WebSocketConfig) (*WebSocketTransport, error) {
+	t := &WebSocketTransport{url: rawURL, up: make(chan struct{})}
+	if cfg != nil {
+		t.cfg = *cfg
+	}
+	if t.cfg.Subprotocol == "" {
+		t.cfg.Subprotocol = "mcp"
+	}
+	if t.cfg.HandshakeTimeout <= 0 {
+		t.cfg.HandshakeTimeout = 10 * time.Second
+	}
+	if t.cfg.PingInterval == 0 {
+		t.cfg.PingInterval = 30 * time.Second
+	}
+	if t.cfg.PongTimeout <= 0 {
+		t.cfg.PongTimeout = 10 * time.Second
+	}
+
+	c, err := t.dial()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.setCurrentLocked(c)
+	t.mu.Unlock()
+	return t, nil
+}
+
+// setCurrentLocked makes c the active connection. Caller must hold t.mu.
+func (t *WebSocketTransport) setCurrentLocked(c *wsConn) {
+	t.current = c
+	if !t.upClosed {
+		close(t.up)
+		t.upClosed = true
+	}
+	go t.watch(c)
+}
+
+// wakeLocked releases Receive calls waiting for a connection. Caller
+// must hold t.mu.
+func (t *WebSocketTransport) wakeLocked() {
+	if !t.upClosed {
+		close(t.up)
+		t.upClosed = true
+	}
+}
+
+// watch waits for c to end and schedules a reconnect.
+func (t *WebSocketTransport) watch(c *wsConn) {
+	<-c.done
+	t.mu.Lock()
+	if t.current == c {
+		t.current = nil
+		t.up, t.upClosed = make(chan struct{}), false
+	}
+	t.mu.Unlock()
+	t.lost(c.err, time.Since(c.started))
+}
+
+// lost applies the reconnect policy after a connection ends or a
+// redial fails.
+func (t *WebSocketTransport) lost(err error, uptime time.Duration) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	ev := DisconnectEvent{Err: err, Uptime: uptime}
+	if policy := t.cfg.Reconnect; policy != nil {
+		if policy.ResetAfter > 0 && uptime >= policy.ResetAfter {
+			t.attempts = 0
+		}
+		if policy.MaxRestarts == 0 || t.attempts < policy.MaxRestarts {
+			ev.Reconnecting = true
+			ev.Delay = backoff(policy, t.attempts)
+			t.attempts++
+		}
+	}
+	if !ev.Reconnecting {
+		t.gaveUp = true
+		t.wakeLocked()
+	}
+	t.mu.Unlock()
+
+	if t.cfg.OnDisconnect != nil {
+		t.cfg.OnDisconnect(ev)
+	}
+	if ev.Reconnecting {
+		time.AfterFunc(ev.Delay, t.reconnect)
+	}
+}
+
+// reconnect dials a new connection and replays the handshake.
+func (t *WebSocketTransport) reconnect() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	initialize, initialized := t.initialize, t.initialized
+	t.mu.Unlock()
+
+	c, err := t.dial()
+	if err != nil {
+		t.lost(err, 0)
+		return
+	}
+	if initialize != nil {
+		if err := replayHandshake(c, initialize, initialized); err != nil {
+			c.Close()
+			t.lost(err, 0)
+			return
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		c.Close()
+		return
+	}
+	t.setCurrentLocked(c)
+}
+
+// Send writes a message as one text frame.
+func (t *WebSocketTransport) Send(data []byte) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	c, gaveUp := t.current, t.gaveUp
+	t.mu.Unlock()
+	if c == nil {
+		if gaveUp {
+			return ErrServerDown
+		}
+		return ErrReconnecting
+	}
+
+	t.rememberHandshake(data)
+	if err := c.Send(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+	return nil
+}
+
+// rememberHandshake records initialize traffic for replay.
+func (t *WebSocketTransport) rememberHandshake(data []byte) {
+	var msg struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(data, &msg) != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch msg.Method {
+	case "initialize":
+		t.initialize, t.initialized = bytes.Clone(data), nil
+	case "notifications/initialized":
+		t.initialized = bytes.Clone(data)
+	}
+}
+
+// Receive returns the next message from the server.
+//
+// While a reconnect is pending, Receive waits for the new connection.
+// When the connection it was reading from is lost it returns
+// ErrConnectionLost; the next call reads from the new connection.
+func (t *WebSocketTransport) Receive() ([]byte, error) {
+	for {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return nil, ErrClosed
+		}
+		c, up, gaveUp := t.current, t.up, t.gaveUp
+		t.mu.Unlock()
+
+		if c == nil {
+			if gaveUp {
+				return nil, ErrServerDown
+			}
+			<-up
+			continue
+		}
+
+		data, err := c.Receive()
+		if err == nil {
+			return data, nil
+		}
+		t.mu.Lock()
+		closed := t.closed
+		t.mu.Unlock()
+		if closed {
+			return nil, ErrClosed
+		}
+		return nil, fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+}
+
+// Close sends a close frame and stops reconnecting.
+func (t *WebSocketTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	c := t.current
+	t.current = nil
+	t.wakeLocked()
+	t.mu.Unlock()
+	if c != nil {
+		return c.Close()
+	}
+	return nil
+}
+
+// dial opens a connection and performs the upgrade handshake.
+func (t *WebSocketTransport) dial() (*wsConn, error) {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return nil, fmt.Errorf("transport: websocket url: %w", err)
+	}
+	host := u.Host
+	dialer := &net.Dialer{Timeout: t.cfg.HandshakeTimeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		cfg := t.cfg.TLS.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, cfg)
+	default:
+		return nil, fmt.Errorf("transport: websocket url must be ws:// or wss://, got %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("transport: websocket dial: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(t.cfg.HandshakeTimeout))
+	br, err := t.upgrade(conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return newWSConn(conn, br, t.cfg.PingInterval, t.cfg.PongTimeout), nil
+}
+
+// upgrade performs the client opening handshake on conn.
+func (t *WebSocketTransport) upgrade(conn net.Conn, u *url.URL) (*bufio.Reader, error) {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	httpURL := *u
+	httpURL.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	req, err := http.NewRequest(http.MethodGet, httpURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("transport: websocket request: %w", err)
+	}
+	for k, v := range t.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", t.cfg.Subprotocol)
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshake, err)
+	}
+
+	br := bufio.NewReaderSize(conn, 64*1024)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshake, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode != http.StatusSwitchingProtocols:
+		return nil, fmt.Errorf("%w: status %s", ErrHandshake, resp.Status)
+	case !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket"):
+		return nil, fmt.Errorf("%w: missing Upgrade: websocket", ErrHandshake)
+	case resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key):
+		return nil, fmt.Errorf("%w: bad Sec-WebSocket-Accept", ErrHandshake)
+	}
+	return br, nil
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn is one client WebSocket connection. A reader goroutine
+// answers control frames and queues data messages; a keepalive
+// goroutine pings the server.
+type wsConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	started  time.Time
+	lastRead atomic.Int64
+
+	writeMu  sync.Mutex
+	messages chan []byte
+	closing  chan struct{}
+	closeMu  sync.Once
+
+	// done is closed when the reader exits; err is why
+	done chan struct{}
+	err  error
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, pingInterval, pongTimeout time.Duration) *wsConn {
+	c := &wsConn{
+		conn:     conn,
+		br:       br,
+		started:  time.Now(),
+		messages: make(chan []byte, 100),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	go c.readLoop()
+	if pingInterval > 0 {
+		go c.keepalive(pingInterval, pongTimeout)
+	}
+	return c
+}
+
+// Send writes one text message.
+func (c *wsConn) Send(data []byte) error {
+	return c.write(opText, data)
+}
+
+// Receive returns the next data message.
+func (c *wsConn) Receive() ([]byte, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-c.done:
+		select {
+		case msg := <-c.messages:
+			return msg, nil
+		default:
+			return nil, c.err
+		}
+	}
+}
+
+// Close sends a normal-closure frame and closes the connection.
+func (c *wsConn) Close() error {
+	c.closeMu.Do(func() {
+		close(c.closing)
+		c.writeMu.Lock()
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		writeFrame(c.conn, opClose, []byte{0x03, 0xE8}, true)
+		c.writeMu.Unlock()
+		c.conn.Close()
+	})
+	return nil
+}
+
+// write sends one frame.
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeFrame(c.conn, opcode, payload, true)
+}
+
+// readLoop reads frames until the connection fails or is closed.
+func (c *wsConn) readLoop() {
+	var err error
+	defer func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	}()
+
+	var message []byte
+	fragmented := false
+	for {
+		var f frame
+		f, err = readFrame(c.br, maxMessageSize)
+		if err != nil {
+			return
+		}
+		c.lastRead.Store(time.Now().UnixNano())
+		if f.masked {
+			err = fmt.Errorf("%w: masked frame from server", ErrFrame)
+			return
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err = c.write(opPong, f.payload); err != nil {
+				return
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.write(opClose, f.payload[:min(len(f.payload), 2)])
+			err = fmt.Errorf("%w: closed by server", ErrClosed)
+			return
+		case opText, opBinary:
+			if fragmented {
+				err = fmt.Errorf("%w: new message inside a fragmented one", ErrFrame)
+				return
+			}
+			message = f.payload
+		case opContinuation:
+			if !fragmented {
+				err = fmt.Errorf("%w: unexpected continuation frame", ErrFrame)
+				return
+			}
+			if len(message)+len(f.payload) > maxMessageSize {
+				err = fmt.Errorf("%w: message exceeds %d bytes", ErrFrame, maxMessageSize)
+				return
+			}
+			message = append(message, f.payload...)
+		default:
+			err = fmt.Errorf("%w: unknown opcode %#x", ErrFrame, f.opcode)
+			return
+		}
+
+		fragmented = !f.fin
+		if fragmented {
+			continue
+		}
+		select {
+		case c.messages <- message:
+		case <-c.closing:
+			err = ErrClosed
+			return
+		}
+		message = nil
+	}
+}
+
+// keepalive pings the server and closes a connection that has gone
+// silent.
+func (c *wsConn) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		silent := time.Since(time.Unix(0, c.lastRead.Load()))
+		if silent > interval+timeout {
+			c.conn.Close()
+			return
+		}
+		if c.write(opPing, nil) != nil {
+			return
+		}
+	}
+}
+
+// frame is one decoded WebSocket frame.
+type frame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// readFrame reads and unmasks one frame.
+func readFrame(r io.Reader, maxSize int) (frame, error) {
+	var f frame
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return f, err
+	}
+	f.fin = hdr[0]&0x80 != 0
+	f.opcode = hdr[0] & 0x0F
+	f.masked = hdr[1]&0x80 != 0
+	if hdr[0]&0x70 != 0 {
+		return f, fmt.Errorf("%w: reserved bits set", ErrFrame)
+	}
+
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= opClose && (n > 125 || !f.fin) {
+		return f, fmt.Errorf("%w: invalid control frame", ErrFrame)
+	}
+	if n > uint64(maxSize) {
+		return f, fmt.Errorf("%w: frame of %d bytes exceeds %d", ErrFrame, n, maxSize)
+	}
+
+	var key [4]byte
+	if f.masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return f, err
+	}
+	if f.masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, nil
+}
+
+// writeFrame writes one unfragmented frame. Clients must mask.
+func writeFrame(w io.Writer, opcode byte, payload []byte, mask bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|opcode)
+	var maskBit byte
+	if mask {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if mask {
+		var key [4]byte
+		rand.Read(key[:])
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		for i := range payload {
+			buf[start+i] ^= key[i%4]
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,281 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wsServer upgrades every request and serves the connection with
+// handle, closing it when handle returns.
+func wsServer(t *testing.T, handle func(conn net.Conn, br *bufio.Reader)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+		handle(conn, rw.Reader)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// wsMethods reads client messages until the connection ends, passing
+// each request's method and ID to answer. Unmasked client frames end
+// the connection.
+func wsMethods(conn net.Conn, br *bufio.Reader, answer func(method string, id json.RawMessage) bool) {
+	for {
+		f, err := readFrame(br, maxMessageSize)
+		if err != nil || !f.masked {
+			return
+		}
+		switch f.opcode {
+		case opClose:
+			return
+		case opText:
+			var msg struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			json.Unmarshal(f.payload, &msg)
+			if !answer(msg.Method, msg.ID) {
+				return
+			}
+		}
+	}
+}
+
+func TestWebSocketTransport_RoundTrip(t *testing.T) {
+	srv := wsServer(t, func(conn net.Conn, br *bufio.Reader) {
+		writeFrame(conn, opPing, []byte("hb"), false)
+		for {
+			f, err := readFrame(br, maxMessageSize)
+			if err != nil || !f.masked {
+				return
+			}
+			switch f.opcode {
+			case opPong:
+				writeFrame(conn, opText, []byte(`{"jsonrpc":"2.0","method":"pong/`+string(f.payload)+`"}`), false)
+			case opText:
+				// Reply in two fragments
+				var msg struct {
+					ID json.RawMessage `json:"id"`
+				}
+				json.Unmarshal(f.payload, &msg)
+				first, rest := []byte(`{"jsonrpc":"2.0","id":`), []byte(string(msg.ID)+`,"result":{}}`)
+				conn.Write(append([]byte{opText, byte(len(first))}, first...))
+				conn.Write(append([]byte{0x80 | opContinuation, byte(len(rest))}, rest...))
+			case opClose:
+				return
+			}
+		}
+	})
+
+	tr, err := DialWebSocketWithConfig(wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer tr.Close()
+
+	if got := receiveWithin(t, tr); got != `{"jsonrpc":"2.0","method":"pong/hb"}` {
+		t.Errorf("expected the ping to be answered, got %s", got)
+	}
+	large := `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"pad":"` + strings.Repeat("x", 70000) + `"}}`
+	if err := tr.Send([]byte(large)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := receiveWithin(t, tr); got != `{"jsonrpc":"2.0","id":7,"result":{}}` {
+		t.Errorf("fragmented response = %s", got)
+	}
+}
+
+func TestWebSocketTransport_Reconnect(t *testing.T) {
+	var mu sync.Mutex
+	var conns int
+	replayed := make(chan string, 10)
+
+	srv := wsServer(t, func(conn net.Conn, br *bufio.Reader) {
+		mu.Lock()
+		conns++
+		n := conns
+		mu.Unlock()
+		wsMethods(conn, br, func(method string, id json.RawMessage) bool {
+			if n > 1 {
+				replayed <- method
+			}
+			switch method {
+			case "initialize":
+				writeFrame(conn, opText, []byte(`{"jsonrpc":"2.0","id":`+string(id)+`,"result":{"protocolVersion":"2025-06-18"}}`), false)
+			case "tools/list":
+				if n == 1 {
+					return false
+				}
+				writeFrame(conn, opText, []byte(`{"jsonrpc":"2.0","id":`+string(id)+`,"result":{"tools":[]}}`), false)
+			}
+			return true
+		})
+	})
+
+	events := make(chan DisconnectEvent, 10)
+	tr, err := DialWebSocketWithConfig(wsURL(srv), &WebSocketConfig{
+		Reconnect:    &RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond},
+		OnDisconnect: func(ev DisconnectEvent) { events <- ev },
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer tr.Close()
+
+	tr.Send([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	receiveWithin(t, tr)
+	tr.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	tr.Send([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+
+	if _, err := tr.Receive(); !errors.Is(err, ErrConnectionLost) || !errors.Is(err, ErrServerExited) {
+		t.Fatalf("Receive after drop = %v, expected ErrConnectionLost", err)
+	}
+	select {
+	case ev := <-events:
+		if !ev.Reconnecting || ev.Err == nil {
+			t.Errorf("disconnect event = %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := tr.Send([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrReconnecting) || time.Now().After(deadline) {
+			t.Fatalf("Send during reconnect = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := receiveWithin(t, tr); got != `{"jsonrpc":"2.0","id":3,"result":{"tools":[]}}` {
+		t.Errorf("expected the tools/list response, got %s (replayed initialize response leaked?)", got)
+	}
+	for _, want := range []string{"initialize", "notifications/initialized", "tools/list"} {
+		if got := <-replayed; got != want {
+			t.Errorf("new connection saw %q, expected %q", got, want)
+		}
+	}
+}
+
+func TestWebSocketTransport_KeepaliveTimeout(t *testing.T) {
+	// The server reads frames but never answers pings
+	srv := wsServer(t, func(conn net.Conn, br *bufio.Reader) {
+		io.Copy(io.Discard, br)
+	})
+
+	events := make(chan DisconnectEvent, 1)
+	tr, err := DialWebSocketWithConfig(wsURL(srv), &WebSocketConfig{
+		PingInterval: 20 * time.Millisecond,
+		PongTimeout:  20 * time.Millisecond,
+		OnDisconnect: func(ev DisconnectEvent) { events <- ev },
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer tr.Close()
+
+	if _, err := tr.Receive(); !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("Receive = %v, expected ErrConnectionLost", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Reconnecting {
+			t.Errorf("reconnecting without a policy: %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+	if _, err := tr.Receive(); !errors.Is(err, ErrServerDown) {
+		t.Errorf("Receive after giving up = %v, expected ErrServerDown", err)
+	}
+	if err := tr.Send([]byte(`{}`)); !errors.Is(err, ErrServerDown) {
+		t.Errorf("Send after giving up = %v, expected ErrServerDown", err)
+	}
+}
+
+func TestDialWebSocket_HandshakeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "upgrade refused",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no", http.StatusForbidden)
+			},
+		},
+		{
+			name: "bad accept key",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				defer conn.Close()
+				fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey("wrong"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			if _, err := DialWebSocketWithConfig(wsURL(srv), nil); !errors.Is(err, ErrHandshake) {
+				t.Errorf("Dial error = %v, expected ErrHandshake", err)
+			}
+		})
+	}
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		mask bool
+	}{
+		{"small", 5, false},
+		{"16-bit length", 300, true},
+		{"64-bit length", 70000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := []byte(strings.Repeat("a", tt.size))
+			var buf strings.Builder
+			if err := writeFrame(&buf, opText, payload, tt.mask); err != nil {
+				t.Fatalf("writeFrame failed: %v", err)
+			}
+			f, err := readFrame(strings.NewReader(buf.String()), maxMessageSize)
+			if err != nil {
+				t.Fatalf("readFrame failed: %v", err)
+			}
+			if !f.fin || f.opcode != opText || f.masked != tt.mask || string(f.payload) != string(payload) {
+				t.Errorf("frame = fin %v opcode %d masked %v, %d bytes", f.fin, f.opcode, f.masked, len(f.payload))
+			}
+			if _, err := readFrame(strings.NewReader(buf.String()), tt.size-1); !errors.Is(err, ErrFrame) {
+				t.Errorf("oversized frame error = %v, expected ErrFrame", err)
+			}
+		})
+	}
+}
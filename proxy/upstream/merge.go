@@ -0,0 +1,304 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+)
+
+// partRef points a fan-out request ID at its merge.
+type partRef struct {
+	merge    *merge
+	upstream int
+}
+
+// merge collects one client request's replies from every upstream.
+type merge struct {
+	id       json.RawMessage // the client's request ID
+	method   string
+	parts    []part
+	waiting  int
+	finished bool
+	timer    *time.Timer
+}
+
+// part is one upstream's share of a merge.
+type part struct {
+	done   bool
+	err    string // why the upstream is left out ("" if it replied)
+	result json.RawMessage
+	tools  []json.RawMessage
+	pages  int
+}
+
+// fanOut sends a request to every live upstream and merges the replies.
+//
+// tools/list pages are followed per upstream, so the merged listing is
+// complete and carries no cursor; a cursor from the client is ignored.
+func (m *Mux) fanOut(msg *jsonrpc.Message) error {
+	mg := &merge{id: msg.ID, method: msg.Method, parts: make([]part, len(m.upstreams))}
+	params := msg.Params
+	if msg.Method == "tools/list" {
+		params = nil
+	}
+
+	type request struct {
+		upstream int
+		id       json.RawMessage
+		data     []byte
+	}
+	var requests []request
+	m.mu.Lock()
+	for i, u := range m.upstreams {
+		if u.down {
+			mg.parts[i] = part{done: true, err: "upstream down"}
+			continue
+		}
+		id := m.newIDLocked()
+		data, err := jsonrpc.Serialize(&jsonrpc.Message{JSONRPC: jsonrpc.Version, Method: msg.Method, Params: params, ID: id})
+		if err != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("upstream: %s request: %w", msg.Method, err)
+		}
+		m.parts[string(id)] = partRef{merge: mg, upstream: i}
+		mg.waiting++
+		requests = append(requests, request{upstream: i, id: id, data: data})
+	}
+	var out []byte
+	if mg.waiting == 0 {
+		out = m.finishLocked(mg)
+	} else {
+		mg.timer = time.AfterFunc(m.cfg.FanOutTimeout, func() { m.expire(mg) })
+	}
+	m.mu.Unlock()
+	if out != nil {
+		m.deliver(message{data: out})
+	}
+
+	for _, req := range requests {
+		m.sendPart(req.upstream, req.id, req.data)
+	}
+	return nil
+}
+
+// sendPart sends one fan-out request, leaving the upstream out of the
+// merge if it cannot be written.
+func (m *Mux) sendPart(i int, id json.RawMessage, data []byte) {
+	err := m.upstreams[i].Transport.Send(data)
+	if err == nil {
+		return
+	}
+	m.mu.Lock()
+	var out []byte
+	if ref, ok := m.parts[string(id)]; ok {
+		delete(m.parts, string(id))
+		out = ref.merge.failLocked(m, i, err.Error())
+	}
+	m.mu.Unlock()
+	if out != nil {
+		m.deliver(message{data: out})
+	}
+}
+
+// collect records an upstream's reply to a fan-out request.
+func (m *Mux) collect(ref partRef, msg *jsonrpc.Message) {
+	m.mu.Lock()
+	out, next, nextID := m.collectLocked(ref, msg)
+	m.mu.Unlock()
+	if out != nil {
+		m.deliver(message{data: out})
+	}
+	if next != nil {
+		m.sendPart(ref.upstream, nextID, next)
+	}
+}
+
+// collectLocked records a reply, returning the merged response once
+// every upstream is in, or the request for the next tools/list page.
+// Caller must hold m.mu.
+func (m *Mux) collectLocked(ref partRef, msg *jsonrpc.Message) (out, next []byte, nextID json.RawMessage) {
+	mg := ref.merge
+	if mg.finished {
+		return nil, nil, nil
+	}
+	if msg.Error != nil {
+		return mg.failLocked(m, ref.upstream, msg.Error.Message), nil, nil
+	}
+
+	p := &mg.parts[ref.upstream]
+	if mg.method != "tools/list" {
+		p.result = msg.Result
+	} else {
+		var page struct {
+			Tools      []json.RawMessage `json:"tools"`
+			NextCursor string            `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(msg.Result, &page); err != nil {
+			return mg.failLocked(m, ref.upstream, "malformed tools/list result"), nil, nil
+		}
+		p.tools = append(p.tools, page.Tools...)
+		p.pages++
+		if page.NextCursor != "" {
+			if p.pages >= maxListPages {
+				log.Printf("upstream: %s: tools/list truncated after %d pages", m.upstreams[ref.upstream].Name, p.pages)
+			} else {
+				nextID = m.newIDLocked()
+				params, _ := json.Marshal(map[string]string{"cursor": page.NextCursor})
+				next, _ = jsonrpc.Serialize(&jsonrpc.Message{JSONRPC: jsonrpc.Version, Method: "tools/list", Params: params, ID: nextID})
+				m.parts[string(nextID)] = ref
+				return nil, next, nextID
+			}
+		}
+	}
+	p.done = true
+	mg.waiting--
+	if mg.waiting == 0 {
+		return m.finishLocked(mg), nil, nil
+	}
+	return nil, nil, nil
+}
+
+// failLocked leaves upstream i out of the merge, returning the merged
+// response if it was the last one outstanding. Caller must hold m.mu.
+func (mg *merge) failLocked(m *Mux, i int, reason string) []byte {
+	p := &mg.parts[i]
+	if mg.finished || p.done {
+		return nil
+	}
+	p.done, p.err = true, reason
+	mg.waiting--
+	if mg.waiting == 0 {
+		return m.finishLocked(mg)
+	}
+	return nil
+}
+
+// expire finishes a merge with the replies received so far.
+func (m *Mux) expire(mg *merge) {
+	m.mu.Lock()
+	if mg.finished {
+		m.mu.Unlock()
+		return
+	}
+	for i := range mg.parts {
+		if p := &mg.parts[i]; !p.done {
+			p.done, p.err = true, fmt.Sprintf("no reply within %s", m.cfg.FanOutTimeout)
+		}
+	}
+	out := m.finishLocked(mg)
+	m.mu.Unlock()
+	m.deliver(message{data: out})
+}
+
+// finishLocked builds the merged response. Caller must hold m.mu.
+func (m *Mux) finishLocked(mg *merge) []byte {
+	mg.finished = true
+	if mg.timer != nil {
+		mg.timer.Stop()
+	}
+	for key, ref := range m.parts {
+		if ref.merge == mg {
+			delete(m.parts, key)
+		}
+	}
+
+	var reasons []string
+	replied := 0
+	for i, p := range mg.parts {
+		if p.err != "" {
+			name := m.upstreams[i].Name
+			log.Printf("upstream: %s: left out of merged %s: %s", name, mg.method, p.err)
+			reasons = append(reasons, name+": "+p.err)
+			continue
+		}
+		replied++
+	}
+
+	var resp *jsonrpc.Message
+	var err error
+	switch {
+	case replied == 0:
+		resp, err = jsonrpc.NewErrorResponse(mg.id, CodeUpstreamFailed, "Upstream unavailable",
+			errorData(CodeUpstreamFailed, strings.Join(reasons, "; ")))
+	case mg.method == "tools/list":
+		resp, err = jsonrpc.NewResponse(mg.id, map[string]interface{}{"tools": m.mergeToolsLocked(mg)})
+	default:
+		resp, err = jsonrpc.NewResponse(mg.id, mergeInitialize(mg))
+	}
+	if err != nil {
+		return nil
+	}
+	data, _ := jsonrpc.Serialize(resp)
+	return data
+}
+
+// mergeToolsLocked joins the upstreams' tools under their exposed names
+// and replaces the routing table. Caller must hold m.mu.
+func (m *Mux) mergeToolsLocked(mg *merge) []json.RawMessage {
+	tools := []json.RawMessage{}
+	routes := make(map[string]route)
+	owners := make(map[string]string)
+	for i, p := range mg.parts {
+		if p.err != "" {
+			continue
+		}
+		name := m.upstreams[i].Name
+		for _, raw := range p.tools {
+			var tool map[string]json.RawMessage
+			var toolName string
+			if json.Unmarshal(raw, &tool) != nil || json.Unmarshal(tool["name"], &toolName) != nil || toolName == "" {
+				log.Printf("upstream: %s: skipping malformed tool", name)
+				continue
+			}
+			exposed := m.expose(i, toolName)
+			if owner, taken := owners[exposed]; taken {
+				log.Printf("upstream: %s: tool %q hidden, the name is taken by %s", name, exposed, owner)
+				continue
+			}
+			routes[exposed] = route{upstream: i, tool: toolName}
+			owners[exposed] = name
+			if exposed != toolName {
+				tool["name"], _ = json.Marshal(exposed)
+				raw, _ = json.Marshal(tool)
+			}
+			tools = append(tools, raw)
+		}
+	}
+	m.routes = routes
+	return tools
+}
+
+// mergeInitialize answers with the first replying upstream's
+// initialize result, with the capabilities of every upstream combined.
+func mergeInitialize(mg *merge) map[string]json.RawMessage {
+	var result map[string]json.RawMessage
+	capabilities := make(map[string]json.RawMessage)
+	for _, p := range mg.parts {
+		if p.err != "" {
+			continue
+		}
+		var r map[string]json.RawMessage
+		if json.Unmarshal(p.result, &r) != nil {
+			continue
+		}
+		if result == nil {
+			result = r
+		}
+		var caps map[string]json.RawMessage
+		json.Unmarshal(r["capabilities"], &caps)
+		for k, v := range caps {
+			if _, ok := capabilities[k]; !ok {
+				capabilities[k] = v
+			}
+		}
+	}
+	if result == nil {
+		result = make(map[string]json.RawMessage)
+	}
+	result["capabilities"], _ = json.Marshal(capabilities)
+	return result
+}
@@ -0,0 +1,614 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/shim"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// Configuration errors returned by NewOneShot.
+var (
+	ErrNoTools     = errors.New("upstream: one-shot upstream has no tools")
+	ErrInvalidTool = errors.New("upstream: invalid one-shot tool")
+)
+
+// One-shot defaults.
+const (
+	// DefaultOneShotTimeout bounds one invocation
+	DefaultOneShotTimeout = 30 * time.Second
+	// DefaultOneShotConcurrency bounds the invocations of a tool
+	// running at once
+	DefaultOneShotConcurrency = 4
+	// DefaultOneShotOutput bounds the output kept from an invocation
+	DefaultOneShotOutput = 1 << 20
+)
+
+// OneShotTool is a tool served by running a command or calling an HTTP
+// function once per tools/call, given by exactly one of Command and
+// URL.
+//
+// # Invocation
+//
+// The call's arguments, a JSON object, are written to the command's
+// standard input, which is then closed, or POSTed to the URL as
+// application/json. Standard output or the response body is the
+// result: a JSON object with a "content" array is taken as the tool
+// result as is, anything else becomes one text item. A nonzero exit
+// status or a non-2xx response is an error result carrying standard
+// error or the body.
+type OneShotTool struct {
+	// Name is the tool name listed to the client
+	Name string
+
+	// Description is the tool description listed to the client
+	Description string
+
+	// InputSchema is the tool's JSON Schema for its arguments (nil
+	// lists {"type":"object"})
+	InputSchema json.RawMessage
+
+	// Command is the program and its arguments
+	Command []string
+
+	// Env adds KEY=VALUE entries to the command's environment
+	Env []string
+
+	// URL is the HTTP function endpoint
+	URL string
+
+	// Timeout bounds one invocation (zero uses DefaultOneShotTimeout)
+	Timeout time.Duration
+
+	// MaxConcurrent bounds the invocations running at once; later calls
+	// wait for a slot within their timeout (zero uses
+	// DefaultOneShotConcurrency)
+	MaxConcurrent int
+
+	// Warm keeps this many command processes started and waiting for
+	// their input, so calls skip process startup (zero starts one per
+	// call)
+	Warm int
+
+	// MaxOutput bounds the output and error text kept from an
+	// invocation; the rest is dropped (zero uses DefaultOneShotOutput)
+	MaxOutput int
+}
+
+// OneShotConfig configures NewOneShot.
+type OneShotConfig struct {
+	// Name and Version are the serverInfo answered to initialize
+	// (empty Name uses "oneshot")
+	Name    string
+	Version string
+
+	// Tools are the tools served
+	Tools []OneShotTool
+
+	// Client calls HTTP functions (nil uses a default client)
+	Client *http.Client
+
+	// Sandbox confines the tools' command processes (nil runs them
+	// unconfined)
+	Sandbox *sandbox.Profile
+}
+
+// OneShot is a Transport that serves tools without a persistent MCP
+// server: the proxy answers initialize and tools/list itself, from the
+// configured tools, and runs a command or calls an HTTP function for
+// each tools/call.
+//
+// Warm processes are pooled per tool and replaced as they are used;
+// notifications/cancelled stops the invocation of the named request,
+// which then gets no response. Requests other than initialize, ping,
+// tools/list, and tools/call are answered with MethodNotFound.
+//
+// # Security Notes
+//
+// Every call still passes the router's checks before it reaches Send.
+// Commands inherit the proxy's environment, overlaid with Env, as
+// stdio servers do, and run under the Sandbox profile if one is given;
+// arguments reach them only on standard input, never on the command
+// line, so they cannot inject options or shell syntax.
+//
+// # Thread Safety
+//
+// OneShot is safe for concurrent use; only one goroutine should call
+// Receive at a time.
+type OneShot struct {
+	name    string
+	version string
+	tools   map[string]*oneShotTool
+	order   []string
+	client  *http.Client
+	sandbox *sandbox.Profile
+
+	incoming chan []byte
+	done     chan struct{}
+	closing  sync.Once
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+// oneShotTool is a configured tool with its slots and warm processes.
+type oneShotTool struct {
+	OneShotTool
+	slots chan struct{}
+	warm  chan *oneShotProcess
+}
+
+// oneShotProcess is a started command awaiting its input.
+type oneShotProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *limitedBuffer
+	stderr *limitedBuffer
+	exited chan error
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// NewOneShot creates a one-shot upstream and starts its warm processes.
+//
+// # Returns
+//   - The OneShot
+//   - ErrNoTools, or ErrInvalidTool for a tool without a name, with a
+//     duplicate name, with neither or both of Command and URL, or with
+//     an input schema that is not a JSON object
+func NewOneShot(cfg *OneShotConfig) (*OneShot, error) {
+	if cfg == nil || len(cfg.Tools) == 0 {
+		return nil, ErrNoTools
+	}
+	o := &OneShot{
+		name:     cfg.Name,
+		version:  cfg.Version,
+		tools:    make(map[string]*oneShotTool, len(cfg.Tools)),
+		client:   cfg.Client,
+		sandbox:  cfg.Sandbox,
+		incoming: make(chan []byte, 64),
+		done:     make(chan struct{}),
+		inflight: make(map[string]context.CancelFunc),
+	}
+	if o.name == "" {
+		o.name = "oneshot"
+	}
+	if o.client == nil {
+		o.client = &http.Client{}
+	}
+	for _, tool := range cfg.Tools {
+		switch {
+		case tool.Name == "":
+			return nil, fmt.Errorf("%w: tool name is required", ErrInvalidTool)
+		case o.tools[tool.Name] != nil:
+			return nil, fmt.Errorf("%w: duplicate tool %q", ErrInvalidTool, tool.Name)
+		case (len(tool.Command) == 0) == (tool.URL == ""):
+			return nil, fmt.Errorf("%w: tool %q needs exactly one of a command and a URL", ErrInvalidTool, tool.Name)
+		case tool.InputSchema != nil && !isJSONObject(tool.InputSchema):
+			return nil, fmt.Errorf("%w: tool %q: input schema is not a JSON object", ErrInvalidTool, tool.Name)
+		}
+		t := &oneShotTool{OneShotTool: tool}
+		if t.Timeout <= 0 {
+			t.Timeout = DefaultOneShotTimeout
+		}
+		if t.MaxConcurrent <= 0 {
+			t.MaxConcurrent = DefaultOneShotConcurrency
+		}
+		if t.MaxOutput <= 0 {
+			t.MaxOutput = DefaultOneShotOutput
+		}
+		if t.InputSchema == nil {
+			t.InputSchema = json.RawMessage(`{"type":"object"}`)
+		}
+		t.slots = make(chan struct{}, t.MaxConcurrent)
+		if len(t.Command) > 0 && t.Warm > 0 {
+			t.warm = make(chan *oneShotProcess, t.Warm)
+		}
+		o.tools[tool.Name] = t
+		o.order = append(o.order, tool.Name)
+	}
+	for _, name := range o.order {
+		if t := o.tools[name]; t.warm != nil {
+			for i := 0; i < cap(t.warm); i++ {
+				go o.replenish(t)
+			}
+		}
+	}
+	return o, nil
+}
+
+// isJSONObject reports whether raw is a JSON object.
+func isJSONObject(raw json.RawMessage) bool {
+	var obj map[string]json.RawMessage
+	return json.Unmarshal(raw, &obj) == nil && obj != nil
+}
+
+// Send handles a message from the client. Tool calls run in the
+// background; their responses arrive through Receive.
+func (o *OneShot) Send(data []byte) error {
+	select {
+	case <-o.done:
+		return transport.ErrClosed
+	default:
+	}
+	msg, err := jsonrpc.Parse(data)
+	if err != nil {
+		return err
+	}
+	switch msg.Type() {
+	case jsonrpc.TypeNotification:
+		if msg.Method == "notifications/cancelled" {
+			o.cancel(msg.Params)
+		}
+		return nil
+	case jsonrpc.TypeRequest:
+	default:
+		// No server requests are made, so no client responses are due
+		return nil
+	}
+
+	switch msg.Method {
+	case "initialize":
+		o.respond(msg.ID, o.initializeResult(msg.Params))
+	case "ping":
+		o.respond(msg.ID, map[string]interface{}{})
+	case "tools/list":
+		o.respond(msg.ID, o.listResult())
+	case "tools/call":
+		o.startCall(msg)
+	default:
+		o.fail(msg.ID, jsonrpc.MethodNotFound, fmt.Sprintf("Method not found: %s", msg.Method))
+	}
+	return nil
+}
+
+// Receive returns the next response.
+func (o *OneShot) Receive() ([]byte, error) {
+	select {
+	case data := <-o.incoming:
+		return data, nil
+	case <-o.done:
+		return nil, transport.ErrClosed
+	}
+}
+
+// Close cancels the invocations running and stops the warm processes.
+func (o *OneShot) Close() error {
+	o.closing.Do(func() {
+		close(o.done)
+		o.mu.Lock()
+		for _, cancel := range o.inflight {
+			cancel()
+		}
+		o.mu.Unlock()
+		for _, t := range o.tools {
+			if t.warm == nil {
+				continue
+			}
+			for {
+				select {
+				case p := <-t.warm:
+					p.kill()
+					continue
+				default:
+				}
+				break
+			}
+		}
+	})
+	return nil
+}
+
+// initializeResult answers initialize without starting anything: the
+// client's protocol revision if known, else the latest.
+func (o *OneShot) initializeResult(params json.RawMessage) map[string]interface{} {
+	var req struct {
+		ProtocolVersion shim.Revision `json:"protocolVersion"`
+	}
+	json.Unmarshal(params, &req)
+	version := shim.Revisions[len(shim.Revisions)-1]
+	for _, known := range shim.Revisions {
+		if req.ProtocolVersion == known {
+			version = known
+		}
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]interface{}{"name": o.name, "version": o.version},
+	}
+}
+
+// listResult lists the configured tools in configuration order.
+func (o *OneShot) listResult() map[string]interface{} {
+	tools := make([]interface{}, 0, len(o.order))
+	for _, name := range o.order {
+		t := o.tools[name]
+		tool := map[string]interface{}{"name": t.Name, "inputSchema": t.InputSchema}
+		if t.Description != "" {
+			tool["description"] = t.Description
+		}
+		tools = append(tools, tool)
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+// startCall runs a tools/call in the background.
+func (o *OneShot) startCall(msg *jsonrpc.Message) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		o.fail(msg.ID, jsonrpc.InvalidParams, "Invalid params")
+		return
+	}
+	t := o.tools[params.Name]
+	if t == nil {
+		o.fail(msg.ID, jsonrpc.InvalidParams, fmt.Sprintf("Unknown tool: %s", params.Name))
+		return
+	}
+	args := params.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	id := string(msg.ID)
+	o.mu.Lock()
+	o.inflight[id] = cancel
+	o.mu.Unlock()
+	go func() {
+		defer func() {
+			o.mu.Lock()
+			delete(o.inflight, id)
+			o.mu.Unlock()
+			cancel()
+		}()
+		result := o.invoke(ctx, t, args)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// Cancelled by the client or Close: no response is due
+			return
+		}
+		o.respond(msg.ID, result)
+	}()
+}
+
+// cancel stops the invocation of the request a notifications/cancelled
+// names.
+func (o *OneShot) cancel(params json.RawMessage) {
+	var note struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &note) != nil {
+		return
+	}
+	o.mu.Lock()
+	cancel := o.inflight[string(note.RequestID)]
+	o.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// invoke runs one call of t within ctx and returns its tool result.
+func (o *OneShot) invoke(ctx context.Context, t *oneShotTool, args json.RawMessage) map[string]interface{} {
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	case <-ctx.Done():
+		return errorResult(fmt.Sprintf("tool %s: no free slot within %v", t.Name, t.Timeout))
+	}
+	if t.URL != "" {
+		return o.invokeURL(ctx, t, args)
+	}
+	return o.invokeCommand(ctx, t, args)
+}
+
+// invokeCommand runs t's command, taking a warm process if one is
+// ready.
+func (o *OneShot) invokeCommand(ctx context.Context, t *oneShotTool, args json.RawMessage) map[string]interface{} {
+	var p *oneShotProcess
+	if t.warm != nil {
+		select {
+		case p = <-t.warm:
+			go o.replenish(t)
+		default:
+		}
+	}
+	if p == nil {
+		var err error
+		if p, err = startProcess(t, o.sandbox); err != nil {
+			return errorResult(fmt.Sprintf("tool %s: %v", t.Name, err))
+		}
+	}
+
+	// A process that stops reading its input must not block the call
+	go func() {
+		p.stdin.Write(args)
+		p.stdin.Close()
+	}()
+	var err error
+	select {
+	case err = <-p.exited:
+	case <-ctx.Done():
+		p.kill()
+		<-p.exited
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errorResult(fmt.Sprintf("tool %s: timed out after %v", t.Name, t.Timeout))
+		}
+		return errorResult(fmt.Sprintf("tool %s: cancelled", t.Name))
+	}
+	if err != nil {
+		text := strings.TrimSpace(p.stderr.buf.String())
+		if text == "" {
+			text = strings.TrimSpace(p.stdout.buf.String())
+		}
+		return errorResult(fmt.Sprintf("tool %s: %v: %s", t.Name, err, text))
+	}
+	return outputResult(p.stdout.buf.Bytes(), p.stdout.truncated)
+}
+
+// invokeURL POSTs the arguments to t's URL.
+func (o *OneShot) invokeURL(ctx context.Context, t *oneShotTool, args json.RawMessage) map[string]interface{} {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(args))
+	if err != nil {
+		return errorResult(fmt.Sprintf("tool %s: %v", t.Name, err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errorResult(fmt.Sprintf("tool %s: timed out after %v", t.Name, t.Timeout))
+		}
+		return errorResult(fmt.Sprintf("tool %s: %v", t.Name, err))
+	}
+	defer resp.Body.Close()
+	body := &limitedBuffer{max: t.MaxOutput}
+	io.Copy(body, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errorResult(fmt.Sprintf("tool %s: %s: %s", t.Name, resp.Status, strings.TrimSpace(body.buf.String())))
+	}
+	return outputResult(body.buf.Bytes(), body.truncated)
+}
+
+// startProcess starts t's command under profile, waiting for its
+// input.
+func startProcess(t *oneShotTool, profile *sandbox.Profile) (*oneShotProcess, error) {
+	cmd, err := profile.Command(t.Command[0], t.Command[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = transport.ServerEnv(t.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &oneShotProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: &limitedBuffer{max: t.MaxOutput},
+		stderr: &limitedBuffer{max: t.MaxOutput},
+		exited: make(chan error, 1),
+	}
+	cmd.Stdout, cmd.Stderr = p.stdout, p.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() { p.exited <- cmd.Wait() }()
+	return p, nil
+}
+
+// kill stops the process.
+func (p *oneShotProcess) kill() {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+}
+
+// replenish starts a warm process for t, unless the upstream is closed.
+func (o *OneShot) replenish(t *oneShotTool) {
+	p, err := startProcess(t, o.sandbox)
+	if err != nil {
+		log.Printf("upstream: one-shot tool %s: warm process failed to start: %v", t.Name, err)
+		return
+	}
+	select {
+	case <-o.done:
+		p.kill()
+		return
+	default:
+	}
+	select {
+	case t.warm <- p:
+	default:
+		p.kill()
+	}
+}
+
+// outputResult turns an invocation's output into a tool result.
+func outputResult(out []byte, truncated bool) map[string]interface{} {
+	if !truncated {
+		var result map[string]interface{}
+		if json.Unmarshal(out, &result) == nil {
+			if _, ok := result["content"].([]interface{}); ok {
+				return result
+			}
+		}
+	}
+	text := string(out)
+	if truncated {
+		text += "\n[output truncated]"
+	}
+	return map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+	}
+}
+
+// errorResult is a tool result reporting a failed invocation.
+func errorResult(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+		"isError": true,
+	}
+}
+
+// respond queues a response.
+func (o *OneShot) respond(id json.RawMessage, result interface{}) {
+	resp, err := jsonrpc.NewResponse(id, result)
+	if err != nil {
+		o.fail(id, jsonrpc.InternalError, err.Error())
+		return
+	}
+	o.queue(resp)
+}
+
+// fail queues an error response.
+func (o *OneShot) fail(id json.RawMessage, code int, message string) {
+	resp, err := jsonrpc.NewErrorResponse(id, code, message, nil)
+	if err != nil {
+		return
+	}
+	o.queue(resp)
+}
+
+// queue delivers a message to Receive unless the upstream is closed.
+func (o *OneShot) queue(msg *jsonrpc.Message) {
+	data, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case o.incoming <- data:
+	case <-o.done:
+	}
+}
+
+// TransportName names the OneShot for transport.Name.
+func (o *OneShot) TransportName() string { return "oneshot" }
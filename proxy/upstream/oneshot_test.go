@@ -0,0 +1,251 @@
+package upstream
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/sandbox"
+)
+
+// call sends a request to o and returns its response.
+func call(t *testing.T, o *OneShot, request string) *jsonrpc.Message {
+	t.Helper()
+	if err := o.Send([]byte(request)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	done := make(chan []byte, 1)
+	go func() {
+		data, _ := o.Receive()
+		done <- data
+	}()
+	select {
+	case data := <-done:
+		msg, err := jsonrpc.Parse(data)
+		if err != nil {
+			t.Fatalf("bad response %q: %v", data, err)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response")
+		return nil
+	}
+}
+
+// toolResult decodes a tools/call result.
+func toolResult(t *testing.T, msg *jsonrpc.Message) (string, bool) {
+	t.Helper()
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if msg.Error != nil || json.Unmarshal(msg.Result, &result) != nil || len(result.Content) == 0 {
+		t.Fatalf("bad tool result: %+v", msg)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestNewOneShot_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   *OneShotConfig
+		error error
+	}{
+		{"nil", nil, ErrNoTools},
+		{"no tools", &OneShotConfig{}, ErrNoTools},
+		{"no name", &OneShotConfig{Tools: []OneShotTool{{Command: []string{"cat"}}}}, ErrInvalidTool},
+		{"no target", &OneShotConfig{Tools: []OneShotTool{{Name: "a"}}}, ErrInvalidTool},
+		{"both targets", &OneShotConfig{Tools: []OneShotTool{{Name: "a", Command: []string{"cat"}, URL: "http://x"}}}, ErrInvalidTool},
+		{"duplicate", &OneShotConfig{Tools: []OneShotTool{{Name: "a", Command: []string{"cat"}}, {Name: "a", URL: "http://x"}}}, ErrInvalidTool},
+		{"bad schema", &OneShotConfig{Tools: []OneShotTool{{Name: "a", Command: []string{"cat"}, InputSchema: json.RawMessage(`[]`)}}}, ErrInvalidTool},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOneShot(tt.cfg); !errors.Is(err, tt.error) {
+				t.Errorf("NewOneShot() error = %v, expected %v", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestOneShot_Lifecycle(t *testing.T) {
+	o, err := NewOneShot(&OneShotConfig{Name: "fn", Version: "1.0", Tools: []OneShotTool{
+		{Name: "echo", Description: "Echo the arguments", Command: []string{"cat"}},
+		{Name: "fetch", URL: "http://127.0.0.1:1", InputSchema: json.RawMessage(`{"type":"object","required":["url"]}`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	init := call(t, o, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	if !strings.Contains(string(init.Result), `"protocolVersion":"2025-03-26"`) || !strings.Contains(string(init.Result), `"name":"fn"`) {
+		t.Errorf("initialize = %s", init.Result)
+	}
+	if err := o.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil {
+		t.Errorf("initialized: %v", err)
+	}
+
+	var list struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	json.Unmarshal(call(t, o, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`).Result, &list)
+	if len(list.Tools) != 2 || list.Tools[0].Name != "echo" || string(list.Tools[0].InputSchema) != `{"type":"object"}` || list.Tools[1].Name != "fetch" {
+		t.Errorf("tools/list = %+v", list)
+	}
+
+	if resp := call(t, o, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.Error != nil {
+		t.Errorf("ping = %+v", resp.Error)
+	}
+	if resp := call(t, o, `{"jsonrpc":"2.0","id":4,"method":"resources/list"}`); resp.Error == nil || resp.Error.Code != jsonrpc.MethodNotFound {
+		t.Errorf("resources/list = %+v, expected MethodNotFound", resp)
+	}
+	if resp := call(t, o, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`); resp.Error == nil || resp.Error.Code != jsonrpc.InvalidParams {
+		t.Errorf("unknown tool = %+v, expected InvalidParams", resp)
+	}
+
+	o.Close()
+	if err := o.Send([]byte(`{"jsonrpc":"2.0","id":6,"method":"ping"}`)); err == nil {
+		t.Error("Send after Close succeeded")
+	}
+}
+
+func TestOneShot_Command(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    OneShotTool
+		text    string
+		isError bool
+	}{
+		{"stdout", OneShotTool{Command: []string{"cat"}}, `{"q":"hi"}`, false},
+		{"result", OneShotTool{Command: []string{"sh", "-c", `echo '{"content":[{"type":"text","text":"structured"}]}'`}}, "structured", false},
+		{"env", OneShotTool{Command: []string{"sh", "-c", `printf %s "$GREETING"`}, Env: []string{"GREETING=hello"}}, "hello", false},
+		{"failure", OneShotTool{Command: []string{"sh", "-c", "echo broken >&2; exit 3"}}, "broken", true},
+		{"timeout", OneShotTool{Command: []string{"sleep", "10"}, Timeout: 100 * time.Millisecond}, "timed out", true},
+		{"truncated", OneShotTool{Command: []string{"cat"}, MaxOutput: 4}, "{\"q\"\n[output truncated]", false},
+		{"warm", OneShotTool{Command: []string{"cat"}, Warm: 2}, `{"q":"hi"}`, false},
+		{"missing", OneShotTool{Command: []string{"/nonexistent/tool"}}, "tool run", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tool.Name = "run"
+			o, err := NewOneShot(&OneShotConfig{Tools: []OneShotTool{tt.tool}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+			// Twice, so warm tools use a replenished process
+			for i := 0; i < 2; i++ {
+				text, isError := toolResult(t, call(t, o, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run","arguments":{"q":"hi"}}}`))
+				if !strings.Contains(text, tt.text) || isError != tt.isError {
+					t.Errorf("result = %q (isError %v), expected %q (isError %v)", text, isError, tt.text, tt.isError)
+				}
+			}
+		})
+	}
+}
+
+func TestOneShot_WithholdsProxyEnv(t *testing.T) {
+	t.Setenv("MCP_SENTINEL_CHAIN_KEY", "secret")
+	o, err := NewOneShot(&OneShotConfig{Tools: []OneShotTool{
+		{Name: "run", Command: []string{"sh", "-c", `printf "[%s]" "$MCP_SENTINEL_CHAIN_KEY"`}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if text, _ := toolResult(t, call(t, o, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run"}}`)); text != "[]" {
+		t.Errorf("command saw %s, expected no chain key", text)
+	}
+}
+
+func TestOneShot_Sandboxed(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no sandbox helper on " + runtime.GOOS)
+	}
+	// A stand-in for bwrap or sandbox-exec that marks the command it runs
+	helper := filepath.Join(t.TempDir(), "sandbox-helper")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nIN_SANDBOX=1 exec \"$@\"\n"
+	if err := os.WriteFile(helper, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	o, err := NewOneShot(&OneShotConfig{
+		Tools:   []OneShotTool{{Name: "run", Command: []string{"sh", "-c", `printf "[%s]" "$IN_SANDBOX"`}}},
+		Sandbox: &sandbox.Profile{Name: "fn", Helper: helper, Strict: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if text, _ := toolResult(t, call(t, o, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run"}}`)); text != "[1]" {
+		t.Errorf("command saw %s, expected to run under the sandbox helper", text)
+	}
+}
+
+func TestOneShot_Cancelled(t *testing.T) {
+	o, err := NewOneShot(&OneShotConfig{Tools: []OneShotTool{{Name: "slow", Command: []string{"sleep", "10"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	o.Send([]byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"slow"}}`))
+	time.Sleep(50 * time.Millisecond)
+	o.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`))
+
+	// The cancelled call gets no response; the next request does
+	if resp := call(t, o, `{"jsonrpc":"2.0","id":8,"method":"ping"}`); string(resp.ID) != "8" {
+		t.Errorf("response for %s, expected 8", resp.ID)
+	}
+	time.Sleep(100 * time.Millisecond)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.inflight) != 0 {
+		t.Errorf("%d calls still in flight", len(o.inflight))
+	}
+}
+
+func TestOneShot_URL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(string(body), "fail") {
+			http.Error(w, "function failed", http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	o, err := NewOneShot(&OneShotConfig{Tools: []OneShotTool{{Name: "fn", URL: srv.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	text, isError := toolResult(t, call(t, o, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fn","arguments":{"q":"ok"}}}`))
+	if text != `{"q":"ok"}` || isError {
+		t.Errorf("result = %q (isError %v)", text, isError)
+	}
+	text, isError = toolResult(t, call(t, o, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fn","arguments":{"q":"fail"}}}`))
+	if !strings.Contains(text, "function failed") || !isError {
+		t.Errorf("result = %q (isError %v), expected the failure", text, isError)
+	}
+}
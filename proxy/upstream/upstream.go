@@ -0,0 +1,508 @@
+// Package upstream fronts several MCP servers with one transport.
+//
+// A Mux implements transport.Transport over a set of named upstream
+// transports, so one Router can proxy a client to many servers while
+// every message still passes through the same checks.
+//
+// # Routing
+//
+//   - initialize and tools/list are sent to every upstream and the
+//     replies are merged into one response
+//   - tools/call goes to the upstream that listed the tool, with the
+//     tool's name translated back to the server's own
+//   - client notifications go to every upstream
+//   - other requests go to the first (primary) upstream
+//   - server-initiated requests are relayed under a Mux-assigned ID so
+//     the client's reply reaches the server that asked
+//
+// # Tool Namespaces
+//
+// An upstream with a Prefix exposes its tools as Prefix + Separator +
+// name, e.g. "fs__read_file". Tools of an upstream without a Prefix
+// keep their names. When two upstreams expose the same name, the
+// earlier upstream keeps it and the later tool is hidden and logged.
+//
+// # Security Notes
+//
+// A tool is only routed to the upstream that listed it in the latest
+// merged tools/list, so a server cannot receive calls meant for a tool
+// of another server by advertising a colliding name. Before the first
+// listing only prefixed names resolve. Checks that key on tool names
+// should use Resolve to see the server's own name.
+//
+// # Thread Safety
+//
+// Mux is safe for concurrent use; only one goroutine should call
+// Receive at a time.
+package upstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// Configuration errors returned by NewWithConfig.
+var (
+	ErrNoUpstreams   = errors.New("upstream: no upstreams")
+	ErrInvalidName   = errors.New("upstream: upstream name must not be empty")
+	ErrDuplicateName = errors.New("upstream: duplicate upstream name")
+)
+
+// CodeUpstreamFailed is the error code of a request no upstream could
+// answer.
+const CodeUpstreamFailed = -32014
+
+// DefaultSeparator joins an upstream's Prefix and its tool names.
+const DefaultSeparator = "__"
+
+// DefaultFanOutTimeout bounds how long a merged response waits for
+// slow upstreams.
+const DefaultFanOutTimeout = 10 * time.Second
+
+// maxListPages bounds the tools/list pages followed per upstream.
+const maxListPages = 100
+
+// Upstream is one server behind a Mux.
+type Upstream struct {
+	// Name identifies the server in logs and decisions
+	Name string
+
+	// Prefix namespaces the server's tools ("" exposes them unchanged)
+	Prefix string
+
+	// Transport carries messages to the server; the Mux owns it and
+	// closes it on Close
+	Transport transport.Transport
+}
+
+// Config configures a Mux.
+type Config struct {
+	// Separator joins Prefix and tool name (default DefaultSeparator)
+	Separator string
+
+	// FanOutTimeout is how long a merged response waits for every
+	// upstream before answering with the replies it has (default
+	// DefaultFanOutTimeout)
+	FanOutTimeout time.Duration
+}
+
+// Mux multiplexes one client session over several upstream servers.
+type Mux struct {
+	cfg       Config
+	upstreams []*member
+	incoming  chan message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	routes   map[string]route          // exposed tool name to owner
+	inflight map[string]int            // client request ID to upstream
+	parts    map[string]partRef        // fan-out request ID to its merge
+	relayed  map[string]relayedRequest // relayed request ID to origin
+	nextID   uint64
+	live     int
+}
+
+// member is an upstream and its state.
+type member struct {
+	Upstream
+	down bool
+}
+
+// route is the owner of an exposed tool name.
+type route struct {
+	upstream int
+	tool     string
+}
+
+// relayedRequest is a server-initiated request awaiting the client.
+type relayedRequest struct {
+	upstream int
+	id       json.RawMessage
+}
+
+// message is an item for Receive.
+type message struct {
+	data []byte
+	err  error
+}
+
+// New creates a Mux with the default configuration.
+//
+// # Arguments
+//   - upstreams: The servers, primary first
+//
+// # Returns
+//   - The Mux, already reading from every upstream
+//   - ErrNoUpstreams, ErrInvalidName, or ErrDuplicateName
+func New(upstreams ...Upstream) (*Mux, error) {
+	return NewWithConfig(nil, upstreams...)
+}
+
+// NewWithConfig creates a Mux with custom configuration (nil uses the
+// defaults).
+func NewWithConfig(cfg *Config, upstreams ...Upstream) (*Mux, error) {
+	if len(upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+	m := &Mux{
+		incoming: make(chan message, 100),
+		done:     make(chan struct{}),
+		routes:   make(map[string]route),
+		inflight: make(map[string]int),
+		parts:    make(map[string]partRef),
+		relayed:  make(map[string]relayedRequest),
+		live:     len(upstreams),
+	}
+	if cfg != nil {
+		m.cfg = *cfg
+	}
+	if m.cfg.Separator == "" {
+		m.cfg.Separator = DefaultSeparator
+	}
+	if m.cfg.FanOutTimeout <= 0 {
+		m.cfg.FanOutTimeout = DefaultFanOutTimeout
+	}
+
+	seen := make(map[string]bool, len(upstreams))
+	for _, u := range upstreams {
+		if u.Name == "" {
+			return nil, ErrInvalidName
+		}
+		if seen[u.Name] {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateName, u.Name)
+		}
+		seen[u.Name] = true
+		m.upstreams = append(m.upstreams, &member{Upstream: u})
+	}
+	for i := range m.upstreams {
+		go m.read(i)
+	}
+	return m, nil
+}
+
+// Resolve maps a tool name the client sees to its server.
+//
+// # Returns
+//   - The upstream's Name and the server's own name for the tool
+//   - false if no upstream provides the tool
+func (m *Mux) Resolve(tool string) (upstream, name string, ok bool) {
+	r, ok := m.resolve(tool)
+	if !ok {
+		return "", "", false
+	}
+	return m.upstreams[r.upstream].Name, r.tool, true
+}
+
+// resolve looks up the owner of an exposed tool name.
+func (m *Mux) resolve(tool string) (route, bool) {
+	m.mu.Lock()
+	r, ok := m.routes[tool]
+	m.mu.Unlock()
+	if ok {
+		return r, true
+	}
+	// Prefixed names resolve before the first listing
+	for i, u := range m.upstreams {
+		if u.Prefix != "" && strings.HasPrefix(tool, u.Prefix+m.cfg.Separator) {
+			return route{upstream: i, tool: strings.TrimPrefix(tool, u.Prefix+m.cfg.Separator)}, true
+		}
+	}
+	return route{}, false
+}
+
+// expose returns the name the client sees for a tool of upstream i.
+func (m *Mux) expose(i int, tool string) string {
+	if p := m.upstreams[i].Prefix; p != "" {
+		return p + m.cfg.Separator + tool
+	}
+	return tool
+}
+
+// Send routes a client message to its upstreams.
+func (m *Mux) Send(data []byte) error {
+	select {
+	case <-m.done:
+		return transport.ErrClosed
+	default:
+	}
+	msg, err := jsonrpc.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", transport.ErrInvalidMessage, err)
+	}
+
+	switch msg.Type() {
+	case jsonrpc.TypeResponse:
+		return m.sendResponse(msg, data)
+	case jsonrpc.TypeNotification:
+		return m.broadcast(data)
+	case jsonrpc.TypeRequest:
+		switch msg.Method {
+		case "initialize", "tools/list":
+			return m.fanOut(msg)
+		case "tools/call":
+			return m.sendToolCall(msg, data)
+		}
+	}
+	return m.sendTo(0, msg.ID, data)
+}
+
+// sendTo sends a client request to upstream i, remembering where its
+// response will come from.
+func (m *Mux) sendTo(i int, id json.RawMessage, data []byte) error {
+	u := m.upstreams[i]
+	m.mu.Lock()
+	if u.down {
+		m.mu.Unlock()
+		return fmt.Errorf("upstream: %s: %w", u.Name, transport.ErrServerDown)
+	}
+	if len(id) > 0 {
+		m.inflight[string(id)] = i
+	}
+	m.mu.Unlock()
+
+	if err := u.Transport.Send(data); err != nil {
+		m.mu.Lock()
+		delete(m.inflight, string(id))
+		m.mu.Unlock()
+		return fmt.Errorf("upstream: %s: %w", u.Name, err)
+	}
+	return nil
+}
+
+// sendToolCall forwards a tools/call to the upstream owning the tool.
+func (m *Mux) sendToolCall(msg *jsonrpc.Message, data []byte) error {
+	var params map[string]json.RawMessage
+	var name string
+	if json.Unmarshal(msg.Params, &params) == nil {
+		json.Unmarshal(params["name"], &name)
+	}
+	r, ok := m.resolve(name)
+	if !ok {
+		m.reply(msg.ID, jsonrpc.InvalidParams, "Unknown tool", fmt.Sprintf("no upstream provides tool %q", name))
+		return nil
+	}
+	if r.tool != name {
+		params["name"], _ = json.Marshal(r.tool)
+		msg.Params, _ = json.Marshal(params)
+		rewritten, err := jsonrpc.Serialize(msg)
+		if err != nil {
+			return fmt.Errorf("upstream: rewrite tools/call: %w", err)
+		}
+		data = rewritten
+	}
+	return m.sendTo(r.upstream, msg.ID, data)
+}
+
+// sendResponse returns the client's reply to the server that asked.
+func (m *Mux) sendResponse(msg *jsonrpc.Message, data []byte) error {
+	m.mu.Lock()
+	req, ok := m.relayed[string(msg.ID)]
+	delete(m.relayed, string(msg.ID))
+	m.mu.Unlock()
+	if !ok {
+		return m.sendTo(0, nil, data)
+	}
+
+	msg.ID = req.id
+	out, err := jsonrpc.Serialize(msg)
+	if err != nil {
+		return fmt.Errorf("upstream: rewrite response: %w", err)
+	}
+	return m.sendTo(req.upstream, nil, out)
+}
+
+// broadcast sends a notification to every live upstream. It fails only
+// if no upstream accepted it.
+func (m *Mux) broadcast(data []byte) error {
+	var firstErr error
+	sent := false
+	for i, u := range m.upstreams {
+		if err := m.sendTo(i, nil, data); err != nil {
+			log.Printf("upstream: %s: notification not delivered: %v", u.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return firstErr
+	}
+	return nil
+}
+
+// newID returns a request ID unique to this Mux. Caller must hold m.mu.
+func (m *Mux) newIDLocked() json.RawMessage {
+	m.nextID++
+	return json.RawMessage(fmt.Sprintf(`"mux-%d"`, m.nextID))
+}
+
+// reply queues a locally generated error response for the client.
+func (m *Mux) reply(id json.RawMessage, code int, text, reason string) {
+	resp, err := jsonrpc.NewErrorResponse(id, code, text, errorData(code, reason))
+	if err != nil {
+		return
+	}
+	data, err := jsonrpc.Serialize(resp)
+	if err != nil {
+		return
+	}
+	m.deliver(message{data: data})
+}
+
+// deliver queues a message for Receive.
+func (m *Mux) deliver(msg message) {
+	select {
+	case m.incoming <- msg:
+	case <-m.done:
+	}
+}
+
+// read relays messages from upstream i until it fails for good.
+func (m *Mux) read(i int) {
+	u := m.upstreams[i]
+	for {
+		data, err := u.Transport.Receive()
+		if err != nil {
+			select {
+			case <-m.done:
+				return
+			default:
+			}
+			m.fail(i, err)
+			if errors.Is(err, transport.ErrServerExited) {
+				log.Printf("upstream: %s: %v; waiting for it to return", u.Name, err)
+				continue
+			}
+			log.Printf("upstream: %s: receive failed: %v", u.Name, err)
+			m.mu.Lock()
+			u.down = true
+			m.live--
+			last := m.live == 0
+			m.mu.Unlock()
+			if last {
+				m.deliver(message{err: fmt.Errorf("upstream: every upstream failed, last %s: %w", u.Name, err)})
+			}
+			return
+		}
+		m.relay(i, data)
+	}
+}
+
+// relay handles a message from upstream i.
+func (m *Mux) relay(i int, data []byte) {
+	msg, err := jsonrpc.Parse(data)
+	if err != nil {
+		// Let the router account for it
+		m.deliver(message{data: data})
+		return
+	}
+
+	switch msg.Type() {
+	case jsonrpc.TypeResponse:
+		key := string(msg.ID)
+		m.mu.Lock()
+		if ref, ok := m.parts[key]; ok && ref.upstream == i {
+			delete(m.parts, key)
+			m.mu.Unlock()
+			m.collect(ref, msg)
+			return
+		}
+		if owner, ok := m.inflight[key]; ok && owner == i {
+			delete(m.inflight, key)
+		}
+		m.mu.Unlock()
+	case jsonrpc.TypeRequest:
+		m.mu.Lock()
+		id := m.newIDLocked()
+		m.relayed[string(id)] = relayedRequest{upstream: i, id: msg.ID}
+		m.mu.Unlock()
+		msg.ID = id
+		if data, err = jsonrpc.Serialize(msg); err != nil {
+			return
+		}
+	}
+	m.deliver(message{data: data})
+}
+
+// fail answers the client requests awaiting upstream i and closes its
+// share of pending merges.
+func (m *Mux) fail(i int, err error) {
+	var ids []json.RawMessage
+	var merged [][]byte
+	m.mu.Lock()
+	for id, owner := range m.inflight {
+		if owner == i {
+			ids = append(ids, json.RawMessage(id))
+			delete(m.inflight, id)
+		}
+	}
+	for key, ref := range m.parts {
+		if ref.upstream == i {
+			delete(m.parts, key)
+			if out := ref.merge.failLocked(m, i, err.Error()); out != nil {
+				merged = append(merged, out)
+			}
+		}
+	}
+	for key, req := range m.relayed {
+		if req.upstream == i {
+			delete(m.relayed, key)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, out := range merged {
+		m.deliver(message{data: out})
+	}
+	for _, id := range ids {
+		m.reply(id, CodeUpstreamFailed, "Upstream unavailable", fmt.Sprintf("%s: %v", m.upstreams[i].Name, err))
+	}
+}
+
+// errorData returns the data of a locally generated error response,
+// classified like the router's: an upstream failure may succeed on
+// retry, a request no upstream accepts will not.
+func errorData(code int, reason string) map[string]string {
+	if code == CodeUpstreamFailed {
+		return map[string]string{"reason": reason, "failure": "upstream", "retry": "backoff"}
+	}
+	return map[string]string{"reason": reason, "failure": "request", "retry": "never"}
+}
+
+// Close closes every upstream.
+func (m *Mux) Close() error {
+	var firstErr error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		for _, u := range m.upstreams {
+			if err := u.Transport.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
+
+// Receive returns the next message from any upstream.
+func (m *Mux) Receive() ([]byte, error) {
+	select {
+	case msg := <-m.incoming:
+		return msg.data, msg.err
+	case <-m.done:
+		return nil, transport.ErrClosed
+	}
+}
+
+// TransportName names the Mux for transport.Name.
+func (m *Mux) TransportName() string { return "mux" }
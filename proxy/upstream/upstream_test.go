@@ -0,0 +1,288 @@
+package upstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/jsonrpc"
+	"github.com/newmar1997ma-coder/mcp-sentinel/proxy/transport"
+)
+
+// fakeServer is an in-memory upstream. Requests written by the Mux
+// appear on requests; handle answers them when set.
+type fakeServer struct {
+	requests chan *jsonrpc.Message
+	replies  chan message
+	handle   func(msg *jsonrpc.Message) interface{}
+}
+
+func newFakeServer(handle func(msg *jsonrpc.Message) interface{}) *fakeServer {
+	return &fakeServer{requests: make(chan *jsonrpc.Message, 16), replies: make(chan message, 16), handle: handle}
+}
+
+func (s *fakeServer) Send(data []byte) error {
+	msg, err := jsonrpc.Parse(data)
+	if err != nil {
+		return err
+	}
+	if msg.Type() == jsonrpc.TypeRequest && s.handle != nil {
+		if result := s.handle(msg); result != nil {
+			resp, _ := jsonrpc.NewResponse(msg.ID, result)
+			s.reply(resp)
+			return nil
+		}
+	}
+	s.requests <- msg
+	return nil
+}
+
+func (s *fakeServer) reply(msg *jsonrpc.Message) {
+	data, _ := jsonrpc.Serialize(msg)
+	s.replies <- message{data: data}
+}
+
+func (s *fakeServer) Receive() ([]byte, error) {
+	m, ok := <-s.replies
+	if !ok {
+		return nil, transport.ErrClosed
+	}
+	return m.data, m.err
+}
+
+func (s *fakeServer) Close() error { return nil }
+
+// receive reads the next message from the Mux.
+func receive(t *testing.T, m *Mux) *jsonrpc.Message {
+	t.Helper()
+	ch := make(chan message, 1)
+	go func() {
+		data, err := m.Receive()
+		ch <- message{data, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("Receive failed: %v", r.err)
+		}
+		msg, err := jsonrpc.Parse(r.data)
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", r.data, err)
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+// send writes a client request to the Mux.
+func send(t *testing.T, m *Mux, id int, method string, params interface{}) {
+	t.Helper()
+	req, _ := jsonrpc.NewRequest(method, params, id)
+	data, _ := jsonrpc.Serialize(req)
+	if err := m.Send(data); err != nil {
+		t.Fatalf("Send %s failed: %v", method, err)
+	}
+}
+
+// toolServer lists tools in pages and echoes tools/call.
+func toolServer(name string, capability string, pages ...[]string) *fakeServer {
+	return newFakeServer(func(msg *jsonrpc.Message) interface{} {
+		switch msg.Method {
+		case "initialize":
+			return map[string]interface{}{
+				"protocolVersion": "2025-06-18",
+				"serverInfo":      map[string]string{"name": name},
+				"capabilities":    map[string]interface{}{capability: map[string]bool{"listChanged": true}},
+			}
+		case "tools/list":
+			var params struct {
+				Cursor string `json:"cursor"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			page := 0
+			fmt.Sscanf(params.Cursor, "page-%d", &page)
+			var tools []map[string]interface{}
+			for _, tool := range pages[page] {
+				tools = append(tools, map[string]interface{}{"name": tool, "inputSchema": map[string]string{"type": "object"}})
+			}
+			result := map[string]interface{}{"tools": tools}
+			if page+1 < len(pages) {
+				result["nextCursor"] = fmt.Sprintf("page-%d", page+1)
+			}
+			return result
+		case "tools/call":
+			var params struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			return map[string]interface{}{"content": []map[string]string{{"type": "text", "text": name + ":" + params.Name}}}
+		}
+		return nil
+	})
+}
+
+func TestMux_MergeAndRoute(t *testing.T) {
+	fs := toolServer("fs", "tools", []string{"read"}, []string{"write"})
+	web := toolServer("web", "resources", []string{"search", "fs__read"})
+	m, err := New(Upstream{Name: "fs", Prefix: "fs", Transport: fs}, Upstream{Name: "web", Transport: web})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	send(t, m, 1, "initialize", map[string]interface{}{})
+	init := receive(t, m)
+	var initResult struct {
+		ServerInfo   map[string]string          `json:"serverInfo"`
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+	}
+	json.Unmarshal(init.Result, &initResult)
+	if string(init.ID) != "1" || initResult.ServerInfo["name"] != "fs" || initResult.Capabilities["tools"] == nil || initResult.Capabilities["resources"] == nil {
+		t.Errorf("merged initialize = %s", init.Result)
+	}
+
+	send(t, m, 2, "tools/list", nil)
+	list := receive(t, m)
+	var listResult struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		NextCursor string `json:"nextCursor"`
+	}
+	json.Unmarshal(list.Result, &listResult)
+	var names []string
+	for _, tool := range listResult.Tools {
+		names = append(names, tool.Name)
+	}
+	// web's "fs__read" collides with fs's namespaced read and is hidden
+	if strings.Join(names, ",") != "fs__read,fs__write,search" || listResult.NextCursor != "" {
+		t.Errorf("merged tools = %v (cursor %q)", names, listResult.NextCursor)
+	}
+
+	tests := []struct {
+		tool     string
+		expected string
+	}{
+		{"fs__read", "fs:read"},
+		{"fs__write", "fs:write"},
+		{"search", "web:search"},
+	}
+	for i, tt := range tests {
+		send(t, m, 10+i, "tools/call", map[string]interface{}{"name": tt.tool, "arguments": map[string]string{}})
+		resp := receive(t, m)
+		if !strings.Contains(string(resp.Result), tt.expected) {
+			t.Errorf("tools/call %s = %s, expected %s", tt.tool, resp.Result, tt.expected)
+		}
+	}
+	if up, name, ok := m.Resolve("fs__write"); !ok || up != "fs" || name != "write" {
+		t.Errorf("Resolve(fs__write) = %s, %s, %v", up, name, ok)
+	}
+
+	send(t, m, 20, "tools/call", map[string]interface{}{"name": "missing"})
+	if resp := receive(t, m); resp.Error == nil || resp.Error.Code != jsonrpc.InvalidParams {
+		t.Errorf("unknown tool = %+v, expected an invalid params error", resp)
+	}
+}
+
+func TestMux_RelaysServerRequests(t *testing.T) {
+	a, b := newFakeServer(nil), newFakeServer(nil)
+	m, err := New(Upstream{Name: "a", Transport: a}, Upstream{Name: "b", Transport: b})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	// Both servers use ID 1 for their own requests
+	for _, s := range []*fakeServer{a, b} {
+		req, _ := jsonrpc.NewRequest("sampling/createMessage", map[string]interface{}{}, 1)
+		s.reply(req)
+	}
+	first, second := receive(t, m), receive(t, m)
+	if string(first.ID) == string(second.ID) {
+		t.Fatalf("relayed requests share ID %s", first.ID)
+	}
+
+	for _, req := range []*jsonrpc.Message{second, first} {
+		resp, _ := jsonrpc.NewResponse(req.ID, map[string]string{"answer": string(req.ID)})
+		data, _ := jsonrpc.Serialize(resp)
+		if err := m.Send(data); err != nil {
+			t.Fatalf("Send response failed: %v", err)
+		}
+	}
+	for _, s := range []*fakeServer{a, b} {
+		select {
+		case got := <-s.requests:
+			if string(got.ID) != "1" || got.Result == nil {
+				t.Errorf("server received %+v, expected the response to its request 1", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("response not relayed back")
+		}
+	}
+}
+
+func TestMux_UpstreamFailure(t *testing.T) {
+	flaky := newFakeServer(nil)
+	steady := toolServer("steady", "tools", []string{"ping"})
+	m, err := NewWithConfig(&Config{FanOutTimeout: 100 * time.Millisecond},
+		Upstream{Name: "flaky", Prefix: "flaky", Transport: flaky},
+		Upstream{Name: "steady", Transport: steady})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	// flaky never answers the listing; the merge proceeds without it
+	send(t, m, 1, "tools/list", nil)
+	<-flaky.requests
+	if list := receive(t, m); !strings.Contains(string(list.Result), `"ping"`) {
+		t.Errorf("tools/list = %s, expected steady's tools", list.Result)
+	}
+
+	// A call in flight when flaky's process exits is answered with an error
+	send(t, m, 2, "tools/call", map[string]interface{}{"name": "flaky__work"})
+	<-flaky.requests
+	flaky.replies <- message{err: fmt.Errorf("%w: exit status 1", transport.ErrServerExited)}
+	if resp := receive(t, m); resp.Error == nil || string(resp.ID) != "2" || resp.Error.Code != CodeUpstreamFailed ||
+		!strings.Contains(string(resp.Error.Data), `"failure":"upstream"`) {
+		t.Errorf("in-flight call = %+v, expected an upstream failure for id 2", resp)
+	}
+
+	// Once flaky fails for good its share of merges is left out
+	close(flaky.replies)
+	time.Sleep(20 * time.Millisecond)
+	send(t, m, 3, "tools/list", nil)
+	if list := receive(t, m); list.Error != nil || !strings.Contains(string(list.Result), `"ping"`) {
+		t.Errorf("tools/list after failure = %+v", list)
+	}
+	req, _ := jsonrpc.NewRequest("tools/call", map[string]interface{}{"name": "flaky__work"}, 4)
+	data, _ := jsonrpc.Serialize(req)
+	if err := m.Send(data); !errors.Is(err, transport.ErrServerDown) {
+		t.Errorf("call to a failed upstream = %v, expected ErrServerDown", err)
+	}
+}
+
+func TestNewWithConfig_Errors(t *testing.T) {
+	s := newFakeServer(nil)
+	tests := []struct {
+		name      string
+		upstreams []Upstream
+		err       error
+	}{
+		{"none", nil, ErrNoUpstreams},
+		{"unnamed", []Upstream{{Transport: s}}, ErrInvalidName},
+		{"duplicate", []Upstream{{Name: "a", Transport: s}, {Name: "a", Transport: s}}, ErrDuplicateName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.upstreams...); !errors.Is(err, tt.err) {
+				t.Errorf("New error = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
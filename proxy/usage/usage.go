@@ -0,0 +1,266 @@
+// Package usage exports per-tenant, per-session consumption records for
+// chargeback and capacity planning in shared sentinel deployments.
+//
+// Each session reports cumulative Counters: tool calls, messages,
+// blocks, rate-limited requests, gas, and bytes in each direction. An
+// Exporter samples every tracked session each interval and writes one
+// Record per session that consumed anything since the last sample,
+// holding the difference. A session's last record, written when it is
+// untracked or the exporter closes, is marked Final, so summing a
+// session's records gives its totals.
+//
+// # Formats
+//
+// Records are written as CSV (FormatCSV, with a header line and the
+// columns of Columns), JSON Lines (FormatJSON, one Record per line), or
+// OTLP/HTTP JSON metrics (FormatOTLP, delta sums named
+// mcp_sentinel.usage.<column> with tenant and session attributes).
+// Times are RFC 3339 in UTC.
+//
+// # Thread Safety
+//
+// Exporter is safe for concurrent use and is usually shared by all
+// sessions of a process.
+package usage
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrFormat is returned for an unknown record format.
+var ErrFormat = errors.New("usage: unknown record format")
+
+// Record formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatOTLP = "otlp"
+)
+
+// DefaultInterval is how often an Exporter samples sessions when its
+// Config sets no interval.
+const DefaultInterval = time.Minute
+
+// Counters are a session's cumulative consumption.
+type Counters struct {
+	// Calls is the number of tools/call requests
+	Calls uint64 `json:"calls"`
+
+	// Messages is the number of client messages routed
+	Messages uint64 `json:"messages"`
+
+	// Blocked is the number of client messages refused by a check
+	Blocked uint64 `json:"blocked"`
+
+	// RateLimited is the number of requests refused by a rate limit
+	RateLimited uint64 `json:"rate_limited"`
+
+	// Gas is the gas charged to the session's budget
+	Gas uint64 `json:"gas"`
+
+	// BytesIn and BytesOut are the message bytes received from and
+	// sent to the client
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+}
+
+// sub returns the consumption between an earlier sample and c.
+func (c Counters) sub(earlier Counters) Counters {
+	return Counters{
+		Calls:       c.Calls - earlier.Calls,
+		Messages:    c.Messages - earlier.Messages,
+		Blocked:     c.Blocked - earlier.Blocked,
+		RateLimited: c.RateLimited - earlier.RateLimited,
+		Gas:         c.Gas - earlier.Gas,
+		BytesIn:     c.BytesIn - earlier.BytesIn,
+		BytesOut:    c.BytesOut - earlier.BytesOut,
+	}
+}
+
+// Record is the consumption of one session over one interval.
+type Record struct {
+	// Tenant is the deployment's tenant label
+	Tenant string `json:"tenant"`
+
+	// Session is the router session ID
+	Session string `json:"session"`
+
+	// Start and End bound the interval
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	Counters
+
+	// Final marks the session's last record
+	Final bool `json:"final"`
+}
+
+// Writer writes batches of records in one format.
+type Writer interface {
+	Write(records []Record) error
+	Close() error
+}
+
+// Config configures an Exporter.
+type Config struct {
+	// Tenant labels every record
+	Tenant string
+
+	// Interval is how often sessions are sampled (zero uses
+	// DefaultInterval)
+	Interval time.Duration
+}
+
+// session is a tracked session and its last sample.
+type session struct {
+	source func() Counters
+	last   Counters
+	since  time.Time
+}
+
+// Exporter samples sessions periodically and writes their records.
+type Exporter struct {
+	w   Writer
+	cfg Config
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	closed   bool
+
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time
+}
+
+// NewExporter creates an exporter writing to w and starts sampling.
+// cfg may be nil for defaults.
+func NewExporter(w Writer, cfg *Config) *Exporter {
+	e := &Exporter{
+		w:        w,
+		sessions: make(map[string]*session),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+	if cfg != nil {
+		e.cfg = *cfg
+	}
+	if e.cfg.Interval <= 0 {
+		e.cfg.Interval = DefaultInterval
+	}
+	go e.run()
+	return e
+}
+
+// Track starts sampling a session.
+//
+// # Arguments
+//   - id: Session ID recorded in its records
+//   - source: Returns the session's cumulative counters; called from
+//     the exporter's goroutine
+func (e *Exporter) Track(id string, source func() Counters) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	e.sessions[id] = &session{source: source, since: e.now().UTC()}
+}
+
+// Untrack stops sampling a session and writes its final record.
+func (e *Exporter) Untrack(id string) {
+	e.mu.Lock()
+	s := e.sessions[id]
+	delete(e.sessions, id)
+	if s == nil {
+		e.mu.Unlock()
+		return
+	}
+	rec, _ := e.sample(id, s, true)
+	e.mu.Unlock()
+	e.write([]Record{rec})
+}
+
+// Flush writes the records of the consumption since the last sample.
+func (e *Exporter) Flush() {
+	e.mu.Lock()
+	var records []Record
+	for id, s := range e.sessions {
+		if rec, ok := e.sample(id, s, false); ok {
+			records = append(records, rec)
+		}
+	}
+	e.mu.Unlock()
+	e.write(records)
+}
+
+// Close writes the final record of every tracked session, stops
+// sampling, and closes the writer.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.stop)
+	var records []Record
+	for id, s := range e.sessions {
+		rec, _ := e.sample(id, s, true)
+		records = append(records, rec)
+	}
+	e.sessions = nil
+	e.mu.Unlock()
+	<-e.done
+	e.write(records)
+	return e.w.Close()
+}
+
+// sample returns the record of s since its last sample and advances
+// it. A non-final record is only reported if it consumed anything.
+// Caller must hold e.mu.
+func (e *Exporter) sample(id string, s *session, final bool) (Record, bool) {
+	now := e.now().UTC()
+	current := s.source()
+	rec := Record{
+		Tenant:   e.cfg.Tenant,
+		Session:  id,
+		Start:    s.since,
+		End:      now,
+		Counters: current.sub(s.last),
+		Final:    final,
+	}
+	if !final && rec.Counters == (Counters{}) {
+		return rec, false
+	}
+	s.last, s.since = current, now
+	return rec, true
+}
+
+// write hands records to the writer, logging failures.
+func (e *Exporter) write(records []Record) {
+	if len(records) == 0 {
+		return
+	}
+	if err := e.w.Write(records); err != nil {
+		log.Printf("usage: dropped %d records: %v", len(records), err)
+	}
+}
+
+// run samples sessions every interval until Close.
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush()
+		case <-e.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memWriter keeps written records.
+type memWriter struct {
+	mu      sync.Mutex
+	records []Record
+	closed  bool
+}
+
+func (m *memWriter) Write(records []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+func (m *memWriter) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *memWriter) take() []Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.records
+	m.records = nil
+	return r
+}
+
+func TestExporter(t *testing.T) {
+	w := &memWriter{}
+	e := NewExporter(w, &Config{Tenant: "acme", Interval: time.Hour})
+	var mu sync.Mutex
+	a := Counters{}
+	e.Track("a", func() Counters { mu.Lock(); defer mu.Unlock(); return a })
+	e.Track("b", func() Counters { return Counters{Messages: 1} })
+
+	mu.Lock()
+	a = Counters{Calls: 2, Messages: 3, Gas: 100, BytesIn: 50, BytesOut: 70}
+	mu.Unlock()
+	e.Flush()
+	if records := w.take(); len(records) != 2 {
+		t.Fatalf("first flush wrote %d records, expected 2", len(records))
+	}
+
+	// Sessions that consumed nothing since the last sample are skipped
+	mu.Lock()
+	a.Calls, a.Blocked = 5, 1
+	mu.Unlock()
+	e.Flush()
+	records := w.take()
+	tests := []struct {
+		name     string
+		got      uint64
+		expected uint64
+	}{
+		{"records", uint64(len(records)), 1},
+		{"calls", records[0].Calls, 3},
+		{"blocked", records[0].Blocked, 1},
+		{"gas", records[0].Gas, 0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s = %d, expected %d", tt.name, tt.got, tt.expected)
+		}
+	}
+	if r := records[0]; r.Tenant != "acme" || r.Session != "a" || r.Final || r.End.Before(r.Start) {
+		t.Errorf("record = %+v", r)
+	}
+
+	e.Untrack("a")
+	if records := w.take(); len(records) != 1 || !records[0].Final || records[0].Session != "a" {
+		t.Errorf("untrack wrote %+v, expected a final record for a", records)
+	}
+	e.Close()
+	if records := w.take(); len(records) != 1 || !records[0].Final || records[0].Session != "b" {
+		t.Errorf("close wrote %+v, expected a final record for b", records)
+	}
+	if !w.closed {
+		t.Error("writer not closed")
+	}
+}
+
+func TestExporter_Interval(t *testing.T) {
+	w := &memWriter{}
+	e := NewExporter(w, &Config{Interval: 10 * time.Millisecond})
+	defer e.Close()
+	e.Track("s", func() Counters { return Counters{Calls: 1} })
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if records := w.take(); len(records) > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no records written on the interval")
+}
@@ -0,0 +1,256 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Columns are the CSV columns, in order. Counter columns are also the
+// OTLP metric name suffixes.
+var Columns = []string{"tenant", "session", "start", "end", "calls", "messages", "blocked", "rate_limited", "gas", "bytes_in", "bytes_out", "final"}
+
+// NewWriter returns a writer of format to w.
+//
+// # Returns
+//   - ErrFormat for FormatOTLP, which posts to a collector (see
+//     NewOTLPWriter), or any other format
+func NewWriter(format string, w io.WriteCloser) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrFormat, format)
+}
+
+// OpenFile returns a writer of format appending to the file at path.
+// A CSV header is written only when the file is new or empty.
+func OpenFile(path, format string) (Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(format, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		if c, ok := w.(*csvWriter); ok {
+			c.header = true
+		}
+	}
+	return w, nil
+}
+
+// csvWriter writes records as CSV rows.
+type csvWriter struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	header bool
+}
+
+func (c *csvWriter) Write(records []Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := csv.NewWriter(c.w)
+	if !c.header {
+		w.Write(Columns)
+		c.header = true
+	}
+	for _, r := range records {
+		w.Write([]string{
+			r.Tenant, r.Session, r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339),
+			strconv.FormatUint(r.Calls, 10), strconv.FormatUint(r.Messages, 10),
+			strconv.FormatUint(r.Blocked, 10), strconv.FormatUint(r.RateLimited, 10),
+			strconv.FormatUint(r.Gas, 10), strconv.FormatUint(r.BytesIn, 10),
+			strconv.FormatUint(r.BytesOut, 10), strconv.FormatBool(r.Final),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (c *csvWriter) Close() error { return c.w.Close() }
+
+// jsonWriter writes records as JSON Lines.
+type jsonWriter struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func (j *jsonWriter) Write(records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range records {
+		if err := enc.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+func (j *jsonWriter) Close() error { return j.w.Close() }
+
+// OTLPConfig configures an OTLP writer.
+type OTLPConfig struct {
+	// ServiceName is the service.name resource attribute (empty uses
+	// mcp-sentinel-proxy)
+	ServiceName string
+
+	// Client sends the requests (nil uses a client with a 10s timeout)
+	Client *http.Client
+
+	// Header is added to every request, e.g. an Authorization token
+	Header http.Header
+}
+
+// otlpWriter posts records as OTLP metrics.
+type otlpWriter struct {
+	url string
+	cfg OTLPConfig
+}
+
+// NewOTLPWriter returns a writer posting records to url, a collector's
+// OTLP/HTTP metrics endpoint such as http://localhost:4318/v1/metrics,
+// JSON-encoded. cfg may be nil for defaults.
+func NewOTLPWriter(url string, cfg *OTLPConfig) Writer {
+	w := &otlpWriter{url: url}
+	if cfg != nil {
+		w.cfg = *cfg
+	}
+	if w.cfg.ServiceName == "" {
+		w.cfg.ServiceName = "mcp-sentinel-proxy"
+	}
+	if w.cfg.Client == nil {
+		w.cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return w
+}
+
+// The OTLP JSON encoding of ExportMetricsServiceRequest, limited to
+// delta sums. 64-bit integers are decimal strings, per the OTLP
+// specification.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name string  `json:"name"`
+		Unit string  `json:"unit,omitempty"`
+		Sum  otlpSum `json:"sum"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsInt             string         `json:"asInt"`
+	}
+	otlpKeyValue struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+)
+
+// otlpTemporalityDelta is AGGREGATION_TEMPORALITY_DELTA.
+const otlpTemporalityDelta = 1
+
+// otlpScopeName is the instrumentation scope of the metrics.
+const otlpScopeName = "github.com/newmar1997ma-coder/mcp-sentinel/proxy/usage"
+
+func (o *otlpWriter) Write(records []Record) error {
+	body, err := json.Marshal(o.request(records))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range o.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (o *otlpWriter) Close() error { return nil }
+
+// request encodes records as one delta sum per counter, with a data
+// point per record.
+func (o *otlpWriter) request(records []Record) otlpRequest {
+	counters := []struct {
+		name  string
+		unit  string
+		value func(Record) uint64
+	}{
+		{"calls", "{call}", func(r Record) uint64 { return r.Calls }},
+		{"messages", "{message}", func(r Record) uint64 { return r.Messages }},
+		{"blocked", "{message}", func(r Record) uint64 { return r.Blocked }},
+		{"rate_limited", "{request}", func(r Record) uint64 { return r.RateLimited }},
+		{"gas", "{gas}", func(r Record) uint64 { return r.Gas }},
+		{"bytes_in", "By", func(r Record) uint64 { return r.BytesIn }},
+		{"bytes_out", "By", func(r Record) uint64 { return r.BytesOut }},
+	}
+	metrics := make([]otlpMetric, 0, len(counters))
+	for _, c := range counters {
+		m := otlpMetric{Name: "mcp_sentinel.usage." + c.name, Unit: c.unit, Sum: otlpSum{AggregationTemporality: otlpTemporalityDelta, IsMonotonic: true}}
+		for _, r := range records {
+			m.Sum.DataPoints = append(m.Sum.DataPoints, otlpDataPoint{
+				Attributes:        []otlpKeyValue{otlpString("session", r.Session), otlpString("tenant", r.Tenant)},
+				StartTimeUnixNano: strconv.FormatInt(r.Start.UnixNano(), 10),
+				TimeUnixNano:      strconv.FormatInt(r.End.UnixNano(), 10),
+				AsInt:             strconv.FormatUint(c.value(r), 10),
+			})
+		}
+		metrics = append(metrics, m)
+	}
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", o.cfg.ServiceName)}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName}, Metrics: metrics}},
+	}}}
+}
+
+// otlpString encodes a string attribute.
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: map[string]string{"stringValue": value}}
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRecord = Record{
+	Tenant:   "acme",
+	Session:  "session-1",
+	Start:    time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+	End:      time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC),
+	Counters: Counters{Calls: 2, Messages: 5, Blocked: 1, Gas: 300, BytesIn: 512, BytesOut: 2048},
+}
+
+func TestOpenFile(t *testing.T) {
+	tests := []struct {
+		format string
+		lines  []string
+	}{
+		{FormatCSV, []string{
+			strings.Join(Columns, ","),
+			"acme,session-1,2026-01-02T03:04:00Z,2026-01-02T03:05:00Z,2,5,1,0,300,512,2048,false",
+			"acme,session-1,2026-01-02T03:04:00Z,2026-01-02T03:05:00Z,2,5,1,0,300,512,2048,false",
+		}},
+		{FormatJSON, []string{
+			`{"tenant":"acme","session":"session-1","start":"2026-01-02T03:04:00Z","end":"2026-01-02T03:05:00Z","calls":2,"messages":5,"blocked":1,"rate_limited":0,"gas":300,"bytes_in":512,"bytes_out":2048,"final":false}`,
+			`{"tenant":"acme","session":"session-1","start":"2026-01-02T03:04:00Z","end":"2026-01-02T03:05:00Z","calls":2,"messages":5,"blocked":1,"rate_limited":0,"gas":300,"bytes_in":512,"bytes_out":2048,"final":false}`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "usage")
+			// Reopening appends, without a second CSV header
+			for i := 0; i < 2; i++ {
+				w, err := OpenFile(path, tt.format)
+				if err != nil {
+					t.Fatalf("OpenFile failed: %v", err)
+				}
+				if err := w.Write([]Record{testRecord}); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+				w.Close()
+			}
+			data, _ := os.ReadFile(path)
+			if got := strings.Split(strings.TrimSpace(string(data)), "\n"); strings.Join(got, "\n") != strings.Join(tt.lines, "\n") {
+				t.Errorf("file =\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(tt.lines, "\n"))
+			}
+		})
+	}
+
+	if _, err := OpenFile(filepath.Join(t.TempDir(), "usage"), FormatOTLP); !errors.Is(err, ErrFormat) {
+		t.Errorf("OpenFile(otlp) = %v, expected ErrFormat", err)
+	}
+}
+
+func TestOTLPWriter(t *testing.T) {
+	var body []byte
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	w := NewOTLPWriter(srv.URL, &OTLPConfig{Header: http.Header{"Authorization": {"Bearer t"}}})
+	if err := w.Write([]Record{testRecord}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if auth != "Bearer t" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("body is not an OTLP request: %v", err)
+	}
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != len(Columns)-5 {
+		t.Fatalf("%d metrics, expected one per counter", len(metrics))
+	}
+	gas := metrics[4]
+	if gas.Name != "mcp_sentinel.usage.gas" || gas.Sum.AggregationTemporality != otlpTemporalityDelta || gas.Sum.DataPoints[0].AsInt != "300" {
+		t.Errorf("gas metric = %+v", gas)
+	}
+	if attrs := gas.Sum.DataPoints[0].Attributes; attrs[1].Key != "tenant" || attrs[1].Value["stringValue"] != "acme" {
+		t.Errorf("attributes = %+v", attrs)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := NewOTLPWriter(failing.URL, nil).Write([]Record{testRecord}); err == nil {
+		t.Error("Write to a failing collector succeeded")
+	}
+}